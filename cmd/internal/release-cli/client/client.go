@@ -139,24 +139,114 @@ func (g *Client) BranchExists(projectID int, branchName string) (bool, error) {
 	return true, nil
 }
 
-func (g *Client) MergeRequestExistsByPattern(projectID int, pattern *regexp.Regexp) (bool, error) {
+// MergeRequestExistsByPattern checks if an open merge request found by search, authored by author if not empty, has a
+// title matching pattern.
+func (g *Client) MergeRequestExistsByPattern(projectID int, search, author string, pattern *regexp.Regexp) (bool, error) {
+	mr, err := g.FindMergeRequestByPattern(projectID, search, author, pattern)
+	if err != nil {
+		return false, err
+	}
+	return mr != nil, nil
+}
+
+// FindMergeRequestByPattern returns the open merge request found by search, authored by author if not empty, whose
+// title matches pattern, or nil if there is none.
+func (g *Client) FindMergeRequestByPattern(projectID int, search, author string, pattern *regexp.Regexp) (*gitlab.MergeRequest, error) {
 	state := "opened"
-	search := "Update gitlab-org/container-registry"
 	opts := &gitlab.ListProjectMergeRequestsOptions{
 		Search: &search,
 		State:  &state,
 	}
+	if author != "" {
+		opts.AuthorUsername = &author
+	}
 
 	mrs, _, err := g.client.MergeRequests.ListProjectMergeRequests(projectID, opts)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	for _, mr := range mrs {
 		if pattern.MatchString(mr.Title) {
-			return true, nil
+			return mr, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// FindMergeRequestByBranch returns the open merge request from the given source branch, or nil if there is none.
+func (g *Client) FindMergeRequestByBranch(projectID int, branchName string) (*gitlab.MergeRequest, error) {
+	mrs, _, err := g.client.MergeRequests.ListProjectMergeRequests(projectID, &gitlab.ListProjectMergeRequestsOptions{
+		SourceBranch: gitlab.String(branchName),
+		State:        gitlab.String("opened"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+	return mrs[0], nil
+}
+
+// ListIssueNotes returns the bodies of all notes of an issue.
+func (g *Client) ListIssueNotes(projectID, issueIID int) ([]string, error) {
+	opts := &gitlab.ListIssueNotesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+
+	var bodies []string
+	for {
+		notes, resp, err := g.client.Notes.ListIssueNotes(projectID, issueIID, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range notes {
+			bodies = append(bodies, n.Body)
 		}
+		if resp.NextPage == 0 {
+			return bodies, nil
+		}
+		opts.Page = resp.NextPage
 	}
+}
 
-	return false, nil
+// CreateIssueNote adds a note to an issue.
+func (g *Client) CreateIssueNote(projectID, issueIID int, body string) error {
+	_, _, err := g.client.Notes.CreateIssueNote(projectID, issueIID, &gitlab.CreateIssueNoteOptions{Body: &body})
+	return err
+}
+
+// CompareCommits returns the commits reachable from to but not from from, oldest first.
+func (g *Client) CompareCommits(projectID int, from, to string) ([]*gitlab.Commit, error) {
+	cmp, _, err := g.client.Repositories.Compare(projectID, &gitlab.CompareOptions{
+		From: gitlab.String(from),
+		To:   gitlab.String(to),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cmp.CompareTimeout {
+		return nil, fmt.Errorf("comparison between %s and %s timed out", from, to)
+	}
+	return cmp.Commits, nil
+}
+
+// CreateTag creates an annotated tag pointing to ref.
+func (g *Client) CreateTag(projectID int, tagName, ref, message string) (*gitlab.Tag, error) {
+	tag, _, err := g.client.Tags.CreateTag(projectID, &gitlab.CreateTagOptions{
+		TagName: &tagName,
+		Ref:     &ref,
+		Message: &message,
+	})
+	return tag, err
+}
+
+// CreateRelease creates a release for an existing tag.
+func (g *Client) CreateRelease(projectID int, tagName, name, description string) (*gitlab.Release, error) {
+	release, _, err := g.client.Releases.CreateRelease(projectID, &gitlab.CreateReleaseOptions{
+		TagName:     &tagName,
+		Name:        &name,
+		Description: &description,
+	})
+	return release, err
 }
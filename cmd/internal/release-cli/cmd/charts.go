@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"regexp"
 
 	"github.com/docker/distribution/cmd/internal/release-cli/client"
 	"github.com/docker/distribution/cmd/internal/release-cli/slack"
@@ -41,35 +40,39 @@ var chartsCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
+		webhookURLs, err := release.slackWebhookURLs(webhookUrl)
+		if err != nil {
+			log.Fatalf("Error reading Slack channels: %v", err)
+		}
+
 		gitlabClient := client.NewClient(accessToken)
 
-		patternStr := fmt.Sprintf(`Update gitlab-org/container-registry from .* to %s`, version)
-		pattern, err := regexp.Compile(patternStr)
+		pattern, err := release.mergeRequestPattern(version)
 		if err != nil {
-			log.Fatalf("Error compiling regex pattern: %v", err)
+			log.Fatalf("Invalid merge request pattern: %v", err)
 		}
 
-		exists, err := gitlabClient.MergeRequestExistsByPattern(release.ProjectID, pattern)
+		exists, err := gitlabClient.MergeRequestExistsByPattern(release.ProjectID, release.MRSearch, release.MRAuthor, pattern)
 		if err != nil {
 			log.Printf("Error checking if MR exists: %v", err)
 		}
 
 		if exists {
-			log.Printf("Merge Request matching pattern '%s' already exists. Aborting.", patternStr)
+			log.Printf("Merge Request matching pattern '%s' already exists. Aborting.", pattern)
 			return
 		}
 
 		pipelineURL, err := gitlabClient.SendRequestToDeps(release.ProjectID, triggerToken, release.Ref)
 		if err != nil {
 			msg := fmt.Sprintf("%s release: Failed to trigger Charts version bump MR: %s", version, err.Error())
-			err = slack.SendSlackNotification(webhookUrl, msg)
+			err = slack.SendSlackNotifications(webhookURLs, msg)
 			if err != nil {
 				log.Printf("Failed to send error notification to Slack: %v", err)
 			}
 			log.Fatalf(msg)
 		}
 		msg := fmt.Sprintf("%s release: Charts version bump MR trigger pipeline: %s", version, pipelineURL)
-		err = slack.SendSlackNotification(webhookUrl, msg)
+		err = slack.SendSlackNotifications(webhookURLs, msg)
 		if err != nil {
 			log.Printf("Failed to send notification to Slack: %v", err)
 		}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"regexp"
 	"strings"
 
+	"github.com/docker/distribution/cmd/internal/release-cli/utils"
 	"github.com/spf13/viper"
 )
 
@@ -16,6 +20,27 @@ type Release struct {
 	Ref           string   `mapstructure:"ref"`
 	MRTitle       string   `mapstructure:"mr_title"`
 	Paths         []string `mapstructure:"paths"`
+	// Rules locate the registry version of each environment in the files of the project, for releases that update
+	// environment files (k8s).
+	Rules []utils.VersionRule `mapstructure:"rules"`
+	// Labels are applied to the merge requests created by the release.
+	Labels []string `mapstructure:"labels"`
+	// MRSearch, MRAuthor and MRTitlePattern identify an existing merge request for the release, for releases that
+	// trigger the GitLab Dependency Bot rather than creating merge requests. MRTitlePattern is a regular expression
+	// matched against the titles of the open merge requests found by MRSearch, authored by MRAuthor if set.
+	MRSearch       string `mapstructure:"mr_search"`
+	MRAuthor       string `mapstructure:"mr_author"`
+	MRTitlePattern string `mapstructure:"mr_title_pattern"`
+	// SlackChannels are the names of the Slack channels notified about the release, defined in the top-level
+	// `slack_channels` setting. The webhook URL given by the `--slack-webhook-url` flag is used if not set.
+	SlackChannels []string `mapstructure:"slack_channels"`
+	// Mention is the group mentioned in reminders about a stalled merge request of the release, overriding the
+	// `reminders.mention` setting.
+	Mention string `mapstructure:"mention"`
+
+	// slackChannels maps the names of the configured Slack channels to the environment variables holding their webhook
+	// URLs.
+	slackChannels map[string]string
 }
 
 func (r *Release) String() string {
@@ -23,10 +48,62 @@ func (r *Release) String() string {
 		r.ProjectID, r.BranchName, r.CommitMessage, r.Ref, r.MRTitle, strings.Join(r.Paths, ", "))
 }
 
+// mergeRequestPattern returns the pattern matching the title of an existing merge request for version.
+func (r *Release) mergeRequestPattern(version string) (*regexp.Regexp, error) {
+	if r.MRSearch == "" || r.MRTitlePattern == "" {
+		return nil, errors.New("mr_search and mr_title_pattern must be set")
+	}
+	return regexp.Compile(strings.ReplaceAll(r.MRTitlePattern, versionPlaceholder, regexp.QuoteMeta(version)))
+}
+
+// slackWebhookURLs returns the webhook URLs of the Slack channels to notify about the release. defaultURL is used if
+// the release doesn't name any channel.
+func (r *Release) slackWebhookURLs(defaultURL string) ([]string, error) {
+	if len(r.SlackChannels) == 0 {
+		if defaultURL == "" {
+			return nil, errors.New("no Slack channels configured and no Slack webhook URL given")
+		}
+		return []string{defaultURL}, nil
+	}
+
+	urls := make([]string, 0, len(r.SlackChannels))
+	for _, name := range r.SlackChannels {
+		// viper lowercases map keys
+		env, ok := r.slackChannels[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown Slack channel %q", name)
+		}
+		url := os.Getenv(env)
+		if url == "" {
+			return nil, fmt.Errorf("webhook URL of Slack channel %q is empty, set %s", name, env)
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
+
+// rulesByFile groups the release rules by file, in the order files first appear in the rules.
+func (r *Release) rulesByFile() ([]string, map[string][]utils.VersionRule) {
+	var files []string
+	rules := make(map[string][]utils.VersionRule)
+	for _, rule := range r.Rules {
+		if _, ok := rules[rule.File]; !ok {
+			files = append(files, rule.File)
+		}
+		rules[rule.File] = append(rules[rule.File], rule)
+	}
+	return files, rules
+}
+
 func initConfig() {
-	viper.SetConfigName("config")
-	viper.AddConfigPath("cmd/internal/release-cli/config")
-	viper.SetConfigType("yaml")
+	if ConfigFile != "" {
+		// the format is given by the file extension, e.g. YAML or JSON
+		viper.SetConfigFile(ConfigFile)
+	} else {
+		viper.SetConfigName("config")
+		viper.AddConfigPath("cmd/internal/release-cli/config")
+		viper.SetConfigType("yaml")
+	}
 
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
@@ -46,6 +123,8 @@ func readConfig(cmd, version string) (*Release, error) {
 		return nil, err
 	}
 
+	release.slackChannels = viper.GetStringMapString("slack_channels")
+
 	// replace version placeholder in branch name, commit message and MR title (if applicable)
 	release.BranchName = strings.ReplaceAll(release.BranchName, versionPlaceholder, version)
 	release.CommitMessage = strings.ReplaceAll(release.CommitMessage, versionPlaceholder, version)
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfig_JSON(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	fileName := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(fileName, []byte(`{
+  "slack_channels": {"Delivery": "SLACK_DELIVERY_WEBHOOK_URL"},
+  "releases": {
+    "charts": {
+      "project_id": 1,
+      "ref": "main",
+      "mr_search": "Update registry",
+      "mr_author": "dependency-bot",
+      "mr_title_pattern": "Update registry to {VERSION}",
+      "labels": ["group::container registry"],
+      "slack_channels": ["Delivery"]
+    }
+  }
+}`), 0o600))
+	viper.SetConfigFile(fileName)
+
+	release, err := readConfig("charts", "v3.86.0-gitlab")
+	require.NoError(t, err)
+	require.Equal(t, 1, release.ProjectID)
+	require.Equal(t, "Update registry", release.MRSearch)
+	require.Equal(t, "dependency-bot", release.MRAuthor)
+	require.Equal(t, []string{"group::container registry"}, release.Labels)
+
+	t.Setenv("SLACK_DELIVERY_WEBHOOK_URL", "https://hooks.slack.test/delivery")
+	urls, err := release.slackWebhookURLs("https://hooks.slack.test/default")
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://hooks.slack.test/delivery"}, urls)
+}
+
+func TestRelease_MergeRequestPattern(t *testing.T) {
+	r := &Release{MRSearch: "Update registry", MRTitlePattern: "Update registry from .* to {VERSION}"}
+
+	pattern, err := r.mergeRequestPattern("v3.86.0-gitlab")
+	require.NoError(t, err)
+	require.True(t, pattern.MatchString("Update registry from v3.85.0-gitlab to v3.86.0-gitlab"))
+	// the version is matched literally
+	require.False(t, pattern.MatchString("Update registry from v3.85.0-gitlab to v3x86x0-gitlab"))
+
+	_, err = (&Release{MRTitlePattern: "Update registry"}).mergeRequestPattern("v3.86.0-gitlab")
+	require.EqualError(t, err, "mr_search and mr_title_pattern must be set")
+}
+
+func TestRelease_SlackWebhookURLs(t *testing.T) {
+	t.Setenv("SLACK_RELEASES_WEBHOOK_URL", "https://hooks.slack.test/releases")
+	t.Setenv("SLACK_DELIVERY_WEBHOOK_URL", "https://hooks.slack.test/delivery")
+	t.Setenv("SLACK_EMPTY_WEBHOOK_URL", "")
+	channels := map[string]string{
+		"releases": "SLACK_RELEASES_WEBHOOK_URL",
+		"delivery": "SLACK_DELIVERY_WEBHOOK_URL",
+		"empty":    "SLACK_EMPTY_WEBHOOK_URL",
+	}
+
+	tests := []struct {
+		name       string
+		channels   []string
+		defaultURL string
+		want       []string
+		wantErr    string
+	}{
+		{
+			name:       "default",
+			defaultURL: "https://hooks.slack.test/default",
+			want:       []string{"https://hooks.slack.test/default"},
+		},
+		{
+			name:    "no default",
+			wantErr: "no Slack channels configured and no Slack webhook URL given",
+		},
+		{
+			name:       "routed",
+			channels:   []string{"releases", "Delivery"},
+			defaultURL: "https://hooks.slack.test/default",
+			want:       []string{"https://hooks.slack.test/releases", "https://hooks.slack.test/delivery"},
+		},
+		{
+			name:     "unknown channel",
+			channels: []string{"releases", "unknown"},
+			wantErr:  `unknown Slack channel "unknown"`,
+		},
+		{
+			name:     "empty webhook URL",
+			channels: []string{"empty"},
+			wantErr:  `webhook URL of Slack channel "empty" is empty, set SLACK_EMPTY_WEBHOOK_URL`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Release{SlackChannels: tt.channels, slackChannels: channels}
+			urls, err := r.slackWebhookURLs(tt.defaultURL)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, urls)
+		})
+	}
+}
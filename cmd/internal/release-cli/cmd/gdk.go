@@ -36,12 +36,6 @@ var gdkCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		labels := &gitlab.Labels{
-			"workflow::ready for review",
-			"group::container registry",
-			"devops::package",
-		}
-
 		reviewerIDs := utils.ParseReviewerIDs(os.Getenv("MR_REVIWER_IDS"))
 
 		release, err := readConfig(cmd.Use, version)
@@ -50,6 +44,11 @@ var gdkCmd = &cobra.Command{
 			return
 		}
 
+		webhookURLs, err := release.slackWebhookURLs(webhookUrl)
+		if err != nil {
+			log.Fatalf("Error reading Slack channels: %v", err)
+		}
+
 		gdkClient := client.NewClient(accessTokenGDK)
 		registryClient := client.NewClient(accessTokenRegistry)
 
@@ -90,10 +89,10 @@ var gdkCmd = &cobra.Command{
 			}
 		}
 
-		mr, err := gdkClient.CreateMergeRequest(release.ProjectID, branch, desc, release.Ref, release.MRTitle, labels, reviewerIDs)
+		mr, err := gdkClient.CreateMergeRequest(release.ProjectID, branch, desc, release.Ref, release.MRTitle, (*gitlab.Labels)(&release.Labels), reviewerIDs)
 		if err != nil {
 			msg := fmt.Sprintf("%s release: Failed to create GDK version bump MR: %s", version, err.Error())
-			err = slack.SendSlackNotification(webhookUrl, msg)
+			err = slack.SendSlackNotifications(webhookURLs, msg)
 			if err != nil {
 				log.Printf("Failed to send error notification to Slack: %v", err)
 			}
@@ -101,7 +100,7 @@ var gdkCmd = &cobra.Command{
 		}
 
 		msg := fmt.Sprintf("%s release: GDK version bump MR: %s", version, mr.WebURL)
-		err = slack.SendSlackNotification(webhookUrl, msg)
+		err = slack.SendSlackNotifications(webhookURLs, msg)
 		if err != nil {
 			log.Printf("Failed to send notification to Slack: %v", err)
 		}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/docker/distribution/cmd/internal/release-cli/client"
 	"github.com/docker/distribution/cmd/internal/release-cli/slack"
@@ -18,8 +19,6 @@ var k8sCmd = &cobra.Command{
 	Use:   "k8s",
 	Short: "Manage K8s Workloads release",
 	Run: func(cmd *cobra.Command, args []string) {
-		var suffix string
-
 		version := os.Getenv("CI_COMMIT_TAG")
 		if version == "" {
 			log.Fatal("Version is empty. Aborting.")
@@ -42,23 +41,8 @@ var k8sCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		labels := &gitlab.Labels{
-			"workflow::ready for review",
-			"team::Delivery",
-			"Service::Container Registry",
-		}
-
-		switch stage {
-		case "gprd":
-			suffix = "_gprd"
-		case "gprd-cny":
-			suffix = "_gprd_cny"
-		case "gstg-pre":
-			suffix = "_gstg_pre"
-		default:
-			log.Fatalf("unknown stage supplied: %q", stage)
-		}
-		newCmd := fmt.Sprintf("%s%s", cmd.Use, suffix)
+		// each stage has its own release configuration, e.g. `k8s_gprd_cny` for the `gprd-cny` stage
+		newCmd := fmt.Sprintf("%s_%s", cmd.Use, strings.ReplaceAll(stage, "-", "_"))
 
 		release, err := readConfig(newCmd, version)
 		if err != nil {
@@ -66,6 +50,20 @@ var k8sCmd = &cobra.Command{
 			return
 		}
 
+		webhookURLs, err := release.slackWebhookURLs(webhookUrl)
+		if err != nil {
+			log.Fatalf("Error reading Slack channels: %v", err)
+		}
+
+		if len(release.Rules) == 0 {
+			log.Fatalf("unknown stage supplied: %q, no rules found in config for %q", stage, newCmd)
+		}
+		for _, rule := range release.Rules {
+			if err := rule.Validate(); err != nil {
+				log.Fatalf("Invalid rule for stage %q: %v", stage, err)
+			}
+		}
+
 		k8sClient := client.NewClient(accessTokenK8s)
 		registryClient := client.NewClient(accessTokenRegistry)
 
@@ -89,26 +87,27 @@ var k8sCmd = &cobra.Command{
 			log.Fatalf("Failed to get changelog: %v", err)
 		}
 
-		for i := range release.Paths {
-			fileName, err := k8sClient.GetFile(release.Paths[i], release.Ref, release.ProjectID)
+		files, rules := release.rulesByFile()
+		for _, path := range files {
+			fileName, err := k8sClient.GetFile(path, release.Ref, release.ProjectID)
 			if err != nil {
 				log.Fatalf("Failed to get the file: %v", err)
 			}
 
-			fileChange, err := utils.UpdateFileInK8s(fileName, stage, version)
+			fileChange, err := utils.UpdateFileWithRules(fileName, rules[path], version)
 			if err != nil {
 				log.Fatalf("Failed to update file: %v", err)
 			}
-			_, err = k8sClient.CreateCommit(release.ProjectID, fileChange, release.Paths[i], release.CommitMessage, branch)
+			_, err = k8sClient.CreateCommit(release.ProjectID, fileChange, path, release.CommitMessage, branch)
 			if err != nil {
 				log.Fatalf("Failed to create commit: %v", err)
 			}
 		}
 
-		mr, err := k8sClient.CreateMergeRequest(release.ProjectID, branch, desc, release.Ref, release.MRTitle, labels, reviewerIDs)
+		mr, err := k8sClient.CreateMergeRequest(release.ProjectID, branch, desc, release.Ref, release.MRTitle, (*gitlab.Labels)(&release.Labels), reviewerIDs)
 		if err != nil {
 			msg := fmt.Sprintf("%s release: Failed to create K8s Workloads version bump MR (%s): %s", version, stage, err.Error())
-			err = slack.SendSlackNotification(webhookUrl, msg)
+			err = slack.SendSlackNotifications(webhookURLs, msg)
 			if err != nil {
 				log.Printf("Failed to send error notification to Slack: %v", err)
 			}
@@ -116,7 +115,7 @@ var k8sCmd = &cobra.Command{
 		}
 
 		msg := fmt.Sprintf("%s release: K8s Workloads version bump MR (%s): %s", version, stage, mr.WebURL)
-		err = slack.SendSlackNotification(webhookUrl, msg)
+		err = slack.SendSlackNotifications(webhookURLs, msg)
 		if err != nil {
 			log.Printf("Failed to send notification to Slack: %v", err)
 		}
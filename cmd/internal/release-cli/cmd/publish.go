@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/cmd/internal/release-cli/client"
+	"github.com/docker/distribution/cmd/internal/release-cli/slack"
+	"github.com/docker/distribution/cmd/internal/release-cli/utils"
+	"github.com/spf13/cobra"
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Create a GitLab release with a changelog generated from conventional commits",
+	Run: func(cmd *cobra.Command, args []string) {
+		previousTag, err := cmd.Flags().GetString("previous-tag")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		tag, err := cmd.Flags().GetString("tag")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ref, err := cmd.Flags().GetString("ref")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if ref == "" {
+			ref = os.Getenv("CI_COMMIT_SHA")
+		}
+		if ref == "" {
+			log.Fatal("Ref is empty. Aborting.")
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		accessToken, err := cmd.Flags().GetString("registry-access-token")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		webhookUrl, err := cmd.Flags().GetString("slack-webhook-url")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		projectID, err := strconv.Atoi(os.Getenv("CI_PROJECT_ID"))
+		if err != nil {
+			log.Fatalf("Invalid project ID: %v", err)
+		}
+		projectURL := os.Getenv("CI_PROJECT_URL")
+
+		gitlabClient := client.NewClient(accessToken)
+
+		cc, err := gitlabClient.CompareCommits(projectID, previousTag, ref)
+		if err != nil {
+			log.Fatalf("Failed to list commits since %s: %v", previousTag, err)
+		}
+		commits := make([]utils.Commit, 0, len(cc))
+		for _, c := range cc {
+			commits = append(commits, utils.Commit{SHA: c.ID, Title: c.Title})
+		}
+
+		changelog := utils.GenerateChangelog(projectURL, previousTag, tag, time.Now().UTC(), commits)
+		if dryRun {
+			fmt.Println(changelog)
+			return
+		}
+
+		if _, err := gitlabClient.CreateTag(projectID, tag, ref, fmt.Sprintf("chore(release): %s", utils.VersionFromTag(tag))); err != nil {
+			log.Fatalf("Failed to create tag: %v", err)
+		}
+
+		if _, err := gitlabClient.CreateRelease(projectID, tag, tag, changelog); err != nil {
+			log.Fatalf("Failed to create release: %v", err)
+		}
+
+		msg := fmt.Sprintf("%s release: GitLab release created: %s/-/releases/%s", tag, projectURL, tag)
+		if webhookUrl != "" {
+			if err := slack.SendSlackNotification(webhookUrl, msg); err != nil {
+				log.Printf("Failed to send notification to Slack: %v", err)
+			}
+		}
+
+		log.Println(msg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(publishCmd)
+
+	publishCmd.Flags().String("previous-tag", "", "Tag of the previous release, e.g. v3.85.0-gitlab")
+	publishCmd.Flags().String("tag", "", "Tag of the new release, e.g. v3.86.0-gitlab")
+	publishCmd.Flags().String("ref", "", "Commit, branch or tag to release, defaults to the CI commit")
+	publishCmd.Flags().Bool("dry-run", false, "Print the changelog without creating the tag and release")
+	publishCmd.MarkFlagRequired("previous-tag")
+	publishCmd.MarkFlagRequired("tag")
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/cmd/internal/release-cli/client"
+	"github.com/docker/distribution/cmd/internal/release-cli/slack"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xanzy/go-gitlab"
+)
+
+// Reminders configures the reminders about stalled version bump merge requests.
+type Reminders struct {
+	// Thresholds are the ages of a merge request after which a reminder is posted, in ascending order. Each reminder
+	// after the first is escalated.
+	Thresholds []time.Duration
+	// Mention is the group mentioned in reminders, unless the release sets its own.
+	Mention string
+}
+
+func readReminders() (*Reminders, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var r Reminders
+	for _, s := range viper.GetStringSlice("reminders.thresholds") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reminder threshold: %w", err)
+		}
+		if d <= 0 || (len(r.Thresholds) > 0 && d <= r.Thresholds[len(r.Thresholds)-1]) {
+			return nil, errors.New("reminder thresholds must be positive and in ascending order")
+		}
+		r.Thresholds = append(r.Thresholds, d)
+	}
+	if len(r.Thresholds) == 0 {
+		return nil, errors.New("no reminder thresholds configured")
+	}
+	r.Mention = viper.GetString("reminders.mention")
+
+	return &r, nil
+}
+
+// level returns the number of thresholds a merge request of the given age is past, which is 0 if no reminder is due.
+func (r *Reminders) level(age time.Duration) int {
+	level := 0
+	for _, t := range r.Thresholds {
+		if age >= t {
+			level++
+		}
+	}
+	return level
+}
+
+// reminderMarker is a hidden marker added to the release issue note of a reminder, so that each reminder is only
+// posted once.
+func reminderMarker(mrURL string, level int) string {
+	return fmt.Sprintf("<!-- release-cli reminder %s level %d -->", mrURL, level)
+}
+
+func reminded(notes []string, marker string) bool {
+	for _, n := range notes {
+		if strings.Contains(n, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func reminderMessage(version, target, mrURL, mention string, age time.Duration, level int) string {
+	msg := fmt.Sprintf("%s release: %s version bump MR has been open for %dh: %s", version, target, int(age.Hours()), mrURL)
+	if level > 1 {
+		msg = fmt.Sprintf(":warning: %s. This MR is stalled (reminder %d), please prioritise it", msg, level)
+	}
+	if mention != "" {
+		msg = fmt.Sprintf("%s %s", mention, msg)
+	}
+	return msg
+}
+
+// findReleaseMergeRequest returns the open version bump merge request of a release, or nil if there is none.
+func findReleaseMergeRequest(c *client.Client, release *Release, version string) (*gitlab.MergeRequest, error) {
+	if release.BranchName != "" {
+		return c.FindMergeRequestByBranch(release.ProjectID, release.BranchName)
+	}
+	if release.MRSearch != "" {
+		pattern, err := release.mergeRequestPattern(version)
+		if err != nil {
+			return nil, err
+		}
+		return c.FindMergeRequestByPattern(release.ProjectID, release.MRSearch, release.MRAuthor, pattern)
+	}
+	return nil, nil
+}
+
+var remindCmd = &cobra.Command{
+	Use:   "remind",
+	Short: "Remind about stalled version bump MRs",
+	Run: func(cmd *cobra.Command, args []string) {
+		version := os.Getenv("CI_COMMIT_TAG")
+		if version == "" {
+			log.Fatal("Version is empty. Aborting.")
+		}
+
+		projectID, err := strconv.Atoi(os.Getenv("CI_PROJECT_ID"))
+		if err != nil {
+			log.Fatalf("Invalid project ID: %v", err)
+		}
+
+		issueIID, err := cmd.Flags().GetInt("release-issue-iid")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		accessTokenTarget, err := cmd.Flags().GetString("target-access-token")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		accessTokenRegistry, err := cmd.Flags().GetString("registry-access-token")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		webhookUrl, err := cmd.Flags().GetString("slack-webhook-url")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		reminders, err := readReminders()
+		if err != nil {
+			log.Fatalf("Error reading config: %v", err)
+		}
+
+		targetClient := client.NewClient(accessTokenTarget)
+		registryClient := client.NewClient(accessTokenRegistry)
+
+		notes, err := registryClient.ListIssueNotes(projectID, issueIID)
+		if err != nil {
+			log.Fatalf("Failed to list release issue notes: %v", err)
+		}
+
+		targets := make([]string, 0)
+		for target := range viper.GetStringMap("releases") {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			release, err := readConfig(target, version)
+			if err != nil {
+				log.Fatalf("Error reading config: %v", err)
+			}
+
+			mr, err := findReleaseMergeRequest(targetClient, release, version)
+			if err != nil {
+				log.Printf("Failed to find %s version bump MR: %v", target, err)
+				continue
+			}
+			if mr == nil || mr.CreatedAt == nil {
+				log.Printf("No open %s version bump MR.", target)
+				continue
+			}
+
+			age := time.Since(*mr.CreatedAt)
+			level := reminders.level(age)
+			if level == 0 {
+				continue
+			}
+			marker := reminderMarker(mr.WebURL, level)
+			if reminded(notes, marker) {
+				continue
+			}
+
+			mention := release.Mention
+			if mention == "" {
+				mention = reminders.Mention
+			}
+			msg := reminderMessage(version, target, mr.WebURL, mention, age, level)
+
+			if err := registryClient.CreateIssueNote(projectID, issueIID, msg+"\n\n"+marker); err != nil {
+				log.Printf("Failed to post reminder on the release issue: %v", err)
+				continue
+			}
+
+			webhookURLs, err := release.slackWebhookURLs(webhookUrl)
+			if err == nil {
+				err = slack.SendSlackNotifications(webhookURLs, msg)
+			}
+			if err != nil {
+				log.Printf("Failed to send reminder to Slack: %v", err)
+			}
+
+			log.Println(msg)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(remindCmd)
+
+	remindCmd.Flags().Int("release-issue-iid", 0, "IID of the Release Plan issue of the version")
+	remindCmd.Flags().String("target-access-token", "", "Access token with read access to the merge requests of the release targets")
+	remindCmd.MarkFlagRequired("release-issue-iid")
+	remindCmd.MarkFlagRequired("target-access-token")
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadReminders(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    *Reminders
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			config: "reminders:\n  thresholds: [24h, 72h]\n  mention: '@registry'\n",
+			want:   &Reminders{Thresholds: []time.Duration{24 * time.Hour, 72 * time.Hour}, Mention: "@registry"},
+		},
+		{
+			name:    "no thresholds",
+			config:  "reminders:\n  mention: '@registry'\n",
+			wantErr: "no reminder thresholds configured",
+		},
+		{
+			name:    "invalid threshold",
+			config:  "reminders:\n  thresholds: [1d]\n",
+			wantErr: `invalid reminder threshold: time: unknown unit "d" in duration "1d"`,
+		},
+		{
+			name:    "unordered thresholds",
+			config:  "reminders:\n  thresholds: [72h, 24h]\n",
+			wantErr: "reminder thresholds must be positive and in ascending order",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+
+			fileName := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(fileName, []byte(tt.config), 0o600))
+			viper.SetConfigFile(fileName)
+
+			r, err := readReminders()
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, r)
+		})
+	}
+}
+
+func TestReminders_Level(t *testing.T) {
+	r := &Reminders{Thresholds: []time.Duration{24 * time.Hour, 72 * time.Hour}}
+
+	require.Zero(t, r.level(23*time.Hour))
+	require.Equal(t, 1, r.level(24*time.Hour))
+	require.Equal(t, 1, r.level(71*time.Hour))
+	require.Equal(t, 2, r.level(100*time.Hour))
+}
+
+func TestReminded(t *testing.T) {
+	notes := []string{
+		"Release started",
+		"reminder\n\n" + reminderMarker("https://gitlab.test/mr/1", 1),
+	}
+
+	require.True(t, reminded(notes, reminderMarker("https://gitlab.test/mr/1", 1)))
+	require.False(t, reminded(notes, reminderMarker("https://gitlab.test/mr/1", 2)))
+	require.False(t, reminded(notes, reminderMarker("https://gitlab.test/mr/2", 1)))
+}
+
+func TestReminderMessage(t *testing.T) {
+	require.Equal(t,
+		"@registry v3.86.0-gitlab release: k8s_gprd version bump MR has been open for 25h: https://gitlab.test/mr/1",
+		reminderMessage("v3.86.0-gitlab", "k8s_gprd", "https://gitlab.test/mr/1", "@registry", 25*time.Hour+30*time.Minute, 1))
+	require.Equal(t,
+		":warning: v3.86.0-gitlab release: gdk version bump MR has been open for 80h: https://gitlab.test/mr/2. This MR is stalled (reminder 2), please prioritise it",
+		reminderMessage("v3.86.0-gitlab", "gdk", "https://gitlab.test/mr/2", "", 80*time.Hour, 2))
+}
@@ -4,6 +4,7 @@ import "github.com/spf13/cobra"
 
 var RegistryToken string
 var SlackWebhookURL string
+var ConfigFile string
 
 var rootCmd = &cobra.Command{
 	Use:   "release",
@@ -17,7 +18,7 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVarP(&RegistryToken, "registry-access-token", "", "", "Registry Access Token")
-	rootCmd.PersistentFlags().StringVarP(&SlackWebhookURL, "slack-webhook-url", "", "", "Slack Webhook URL")
+	rootCmd.PersistentFlags().StringVarP(&SlackWebhookURL, "slack-webhook-url", "", "", "Slack Webhook URL, used for releases without Slack channels")
+	rootCmd.PersistentFlags().StringVarP(&ConfigFile, "config", "c", "", "Config file (YAML or JSON), defaults to cmd/internal/release-cli/config/config.yaml")
 	rootCmd.MarkFlagRequired("registry-access-token")
-	rootCmd.MarkFlagRequired("slack-webhook-url")
 }
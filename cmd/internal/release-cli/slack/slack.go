@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -37,3 +39,17 @@ func SendSlackNotification(webhookUrl string, msg string) error {
 
 	return nil
 }
+
+// SendSlackNotifications sends msg to each of the webhook URLs, returning an error if any of the notifications failed.
+func SendSlackNotifications(webhookURLs []string, msg string) error {
+	var failed []string
+	for _, url := range webhookURLs {
+		if err := SendSlackNotification(url, msg); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d notifications failed: %s", len(failed), len(webhookURLs), strings.Join(failed, "; "))
+	}
+	return nil
+}
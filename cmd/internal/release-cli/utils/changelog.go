@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Commit is a commit included in a release.
+type Commit struct {
+	SHA string
+	// Title is the first line of the commit message, which for squashed merge requests is the merge request title.
+	Title string
+}
+
+// changelogSection is a section of the changelog, listing the commits of a conventional commit type.
+type changelogSection struct {
+	Type  string
+	Title string
+}
+
+// changelogSections are the sections of the changelog, in order. These match the release notes generated by
+// semantic-release (see `.releaserc.yml`), and commits of other types are left out.
+var changelogSections = []changelogSection{
+	{Type: "feat", Title: "✨ Features ✨"},
+	{Type: "fix", Title: "🐛 Bug Fixes 🐛"},
+	{Type: "perf", Title: "⚡️ Performance Improvements ⚡️"},
+	{Type: "revert", Title: "⏮️️ Reverts ⏮️️"},
+	{Type: "build", Title: "⚙️ Build ⚙️"},
+}
+
+const breakingChangesTitle = "⚠ BREAKING CHANGES"
+
+var conventionalCommitRegex = regexp.MustCompile(`^(\w+)(?:\(([^)]+)\))?(!)?: (.+)$`)
+
+// conventionalCommit is a commit title parsed according to the conventional commits specification, e.g.
+// `fix(storage)!: subject`.
+type conventionalCommit struct {
+	sha      string
+	typ      string
+	scope    string
+	breaking bool
+	subject  string
+}
+
+func parseConventionalCommit(c Commit) (conventionalCommit, bool) {
+	m := conventionalCommitRegex.FindStringSubmatch(strings.TrimSpace(c.Title))
+	if m == nil {
+		return conventionalCommit{}, false
+	}
+	return conventionalCommit{
+		sha:      c.SHA,
+		typ:      strings.ToLower(m[1]),
+		scope:    m[2],
+		breaking: m[3] != "",
+		subject:  m[4],
+	}, true
+}
+
+// VersionFromTag returns the version of a release tag, e.g. `3.86.0` for `v3.86.0-gitlab`.
+func VersionFromTag(tag string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(tag, "v"), "-gitlab")
+}
+
+// GenerateChangelog returns the changelog of the release tagged tag, made of commits since previousTag and grouped by
+// conventional commit type, in the format of CHANGELOG.md. projectURL is the web URL of the project, used to link to
+// commits and to the comparison between tags. Commits that don't follow the conventional commits specification, or
+// whose type has no section, are left out, unless they are breaking changes.
+func GenerateChangelog(projectURL, previousTag, tag string, date time.Time, commits []Commit) string {
+	var breaking []conventionalCommit
+	bySection := make(map[string][]conventionalCommit)
+	for _, c := range commits {
+		cc, ok := parseConventionalCommit(c)
+		if !ok {
+			continue
+		}
+		if cc.breaking {
+			breaking = append(breaking, cc)
+		}
+		bySection[cc.typ] = append(bySection[cc.typ], cc)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## [%s](%s/compare/%s...%s) (%s)\n", VersionFromTag(tag), projectURL, previousTag, tag, date.Format("2006-01-02"))
+
+	writeSection := func(title string, cc []conventionalCommit) {
+		if len(cc) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n\n### %s\n\n", title)
+		for _, c := range cc {
+			b.WriteString("* ")
+			if c.scope != "" {
+				fmt.Fprintf(&b, "**%s:** ", c.scope)
+			}
+			short := c.sha
+			if len(short) > 7 {
+				short = short[:7]
+			}
+			fmt.Fprintf(&b, "%s ([%s](%s/commit/%s))\n", c.subject, short, projectURL, c.sha)
+		}
+	}
+
+	writeSection(breakingChangesTitle, breaking)
+	for _, s := range changelogSections {
+		writeSection(s.Title, bySection[s.Type])
+	}
+
+	return b.String()
+}
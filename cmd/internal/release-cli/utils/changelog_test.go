@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateChangelog(t *testing.T) {
+	commits := []Commit{
+		{SHA: "851773b57c20c907fa831bc7a2f628f4f62b8386", Title: "feat: add artifact_type_id to manifests table"},
+		{SHA: "e1f820b3baf09844552ebf5a0c61901d2254b528", Title: "perf(db): test connection to database fqdn"},
+		{SHA: "38ef156ecdfb9a6f2d233e9fd2f6f2b3a3f7b973", Title: "feat(cache): use redis repository cache for manifest upload operation"},
+		{SHA: "ab57e955fca4526c1b69d325b6d397be02dbbd1d", Title: "fix: use correct name for manifests artifact ID FK constraint"},
+		{SHA: "f456ee00ff3cf226f96e5e86c225669e5e228cab", Title: "feat(storage)!: remove OSS and Swift storage drivers"},
+		{SHA: "1111111111111111111111111111111111111111", Title: "chore: update CI images"},
+		{SHA: "2222222222222222222222222222222222222222", Title: "Merge branch 'feature' into 'master'"},
+	}
+	date := time.Date(2023, 11, 2, 10, 0, 0, 0, time.UTC)
+
+	out := GenerateChangelog("https://gitlab.com/gitlab-org/container-registry", "v3.85.0-gitlab", "v3.86.0-gitlab", date, commits)
+	require.Equal(t, `## [3.86.0](https://gitlab.com/gitlab-org/container-registry/compare/v3.85.0-gitlab...v3.86.0-gitlab) (2023-11-02)
+
+
+### ⚠ BREAKING CHANGES
+
+* **storage:** remove OSS and Swift storage drivers ([f456ee0](https://gitlab.com/gitlab-org/container-registry/commit/f456ee00ff3cf226f96e5e86c225669e5e228cab))
+
+
+### ✨ Features ✨
+
+* add artifact_type_id to manifests table ([851773b](https://gitlab.com/gitlab-org/container-registry/commit/851773b57c20c907fa831bc7a2f628f4f62b8386))
+* **cache:** use redis repository cache for manifest upload operation ([38ef156](https://gitlab.com/gitlab-org/container-registry/commit/38ef156ecdfb9a6f2d233e9fd2f6f2b3a3f7b973))
+* **storage:** remove OSS and Swift storage drivers ([f456ee0](https://gitlab.com/gitlab-org/container-registry/commit/f456ee00ff3cf226f96e5e86c225669e5e228cab))
+
+
+### 🐛 Bug Fixes 🐛
+
+* use correct name for manifests artifact ID FK constraint ([ab57e95](https://gitlab.com/gitlab-org/container-registry/commit/ab57e955fca4526c1b69d325b6d397be02dbbd1d))
+
+
+### ⚡️ Performance Improvements ⚡️
+
+* **db:** test connection to database fqdn ([e1f820b](https://gitlab.com/gitlab-org/container-registry/commit/e1f820b3baf09844552ebf5a0c61901d2254b528))
+`, out)
+}
+
+func TestGenerateChangelog_NoChanges(t *testing.T) {
+	out := GenerateChangelog("https://gitlab.test/registry", "v1.0.0-gitlab", "v1.0.1-gitlab", time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), []Commit{
+		{SHA: "abc", Title: "docs: fix typo"},
+	})
+	require.Equal(t, "## [1.0.1](https://gitlab.test/registry/compare/v1.0.0-gitlab...v1.0.1-gitlab) (2023-01-02)\n", out)
+}
+
+func TestVersionFromTag(t *testing.T) {
+	require.Equal(t, "3.86.0", VersionFromTag("v3.86.0-gitlab"))
+	require.Equal(t, "1.0.0", VersionFromTag("1.0.0"))
+}
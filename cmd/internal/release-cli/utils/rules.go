@@ -0,0 +1,397 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VersionRule describes where the registry version of an environment is set in a file of a release target. The
+// version is located with either a YAML path or a regular expression.
+type VersionRule struct {
+	// Environment is the name of the environment the rule applies to, e.g. `gprd`.
+	Environment string `mapstructure:"environment"`
+	// File is the path of the file to update in the target project.
+	File string `mapstructure:"file"`
+	// YAMLPath is a dot-separated list of mapping keys leading to the version scalar, e.g. `gprd.registry_version`. A
+	// `*` segment matches any single key or sequence item, and a `**` segment matches any number of levels. The path
+	// must match exactly one scalar.
+	YAMLPath string `mapstructure:"yaml_path"`
+	// Regex is a regular expression with a single capture group, e.g. `registry_version: (v[0-9.]+-gitlab)`. The
+	// capture group of every match is replaced with the version.
+	Regex string `mapstructure:"regex"`
+}
+
+func (r VersionRule) String() string {
+	if r.YAMLPath != "" {
+		return fmt.Sprintf("%s: %s (yaml path %q)", r.Environment, r.File, r.YAMLPath)
+	}
+	return fmt.Sprintf("%s: %s (regex %q)", r.Environment, r.File, r.Regex)
+}
+
+// Validate checks that the rule names an environment and file, and locates the version in exactly one way.
+func (r VersionRule) Validate() error {
+	if r.Environment == "" {
+		return errors.New("environment must be set")
+	}
+	if r.File == "" {
+		return fmt.Errorf("%s: file must be set", r.Environment)
+	}
+	if (r.YAMLPath == "") == (r.Regex == "") {
+		return fmt.Errorf("%s: exactly one of yaml_path or regex must be set", r.Environment)
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("%s: invalid regex: %w", r.Environment, err)
+		}
+		if re.NumSubexp() != 1 {
+			return fmt.Errorf("%s: regex must have exactly one capture group", r.Environment)
+		}
+	}
+	return nil
+}
+
+// Apply returns content with the version located by the rule replaced with version. The rest of the content,
+// including comments and formatting, is left untouched. It fails if the version can't be located, or if anything
+// other than the located values would change.
+func (r VersionRule) Apply(content []byte, version string) ([]byte, error) {
+	out, paths, err := r.apply(content, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChanges(content, out, paths, version); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", r.Environment, r.File, err)
+	}
+	return out, nil
+}
+
+// apply replaces the version located by the rule, returning the updated content and the paths of the values it
+// targets.
+func (r VersionRule) apply(content []byte, version string) ([]byte, []string, error) {
+	if err := r.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if r.YAMLPath != "" {
+		return r.applyYAMLPath(content, version)
+	}
+	return r.applyRegex(content, version)
+}
+
+func (r VersionRule) applyRegex(content []byte, version string) ([]byte, []string, error) {
+	re := regexp.MustCompile(r.Regex)
+
+	matches := re.FindAllSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return nil, nil, fmt.Errorf("%s: no match for regex %q in %s", r.Environment, r.Regex, r.File)
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, m := range matches {
+		// m[2] and m[3] delimit the capture group, which is -1 if the group didn't participate in the match
+		if m[2] < 0 {
+			continue
+		}
+		out.Write(content[last:m[2]])
+		out.WriteString(version)
+		last = m[3]
+	}
+	out.Write(content[last:])
+
+	// the targeted values are the ones the regex changed, which must all be set to the version
+	changes, err := diffYAML(content, out.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: regex %q in %s: %w", r.Environment, r.Regex, r.File, err)
+	}
+	paths := make([]string, 0, len(changes))
+	for _, c := range changes {
+		if c.value != version {
+			return nil, nil, fmt.Errorf("%s: regex %q in %s changed %s to %q instead of the version", r.Environment, r.Regex, r.File, c.path, c.value)
+		}
+		paths = append(paths, c.path)
+	}
+
+	return out.Bytes(), paths, nil
+}
+
+func (r VersionRule) applyYAMLPath(content []byte, version string) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: parsing %s: %w", r.Environment, r.File, err)
+	}
+
+	var matches []yamlMatch
+	findYAMLPath(&doc, strings.Split(r.YAMLPath, "."), nil, &matches)
+	switch len(matches) {
+	case 0:
+		return nil, nil, fmt.Errorf("%s: no scalar at yaml path %q in %s", r.Environment, r.YAMLPath, r.File)
+	case 1:
+	default:
+		paths := make([]string, 0, len(matches))
+		for _, m := range matches {
+			paths = append(paths, m.path)
+		}
+		return nil, nil, fmt.Errorf("%s: yaml path %q is ambiguous in %s, matching %s", r.Environment, r.YAMLPath, r.File, strings.Join(paths, ", "))
+	}
+	n := matches[0].node
+
+	// replace the scalar in the original content rather than re-encoding the document, which would not preserve its
+	// formatting
+	start, ok := offsetOf(content, n.Line, n.Column)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: invalid position of yaml path %q in %s", r.Environment, r.YAMLPath, r.File)
+	}
+
+	var end int
+	replacement := version
+	switch n.Style {
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		quote := content[start]
+		i := bytes.IndexByte(content[start+1:], quote)
+		if i < 0 {
+			return nil, nil, fmt.Errorf("%s: unterminated string at yaml path %q in %s", r.Environment, r.YAMLPath, r.File)
+		}
+		end = start + 1 + i + 1
+		replacement = string(quote) + version + string(quote)
+	case 0, yaml.TaggedStyle:
+		end = start + len(n.Value)
+	default:
+		return nil, nil, fmt.Errorf("%s: unsupported scalar style at yaml path %q in %s", r.Environment, r.YAMLPath, r.File)
+	}
+	if end > len(content) {
+		return nil, nil, fmt.Errorf("%s: invalid position of yaml path %q in %s", r.Environment, r.YAMLPath, r.File)
+	}
+
+	out := make([]byte, 0, len(content)-(end-start)+len(replacement))
+	out = append(out, content[:start]...)
+	out = append(out, replacement...)
+	out = append(out, content[end:]...)
+
+	return out, []string{matches[0].path}, nil
+}
+
+// yamlMatch is a scalar node matched by a YAML path, along with its concrete path, e.g. `regions.0.registry_version`.
+type yamlMatch struct {
+	node *yaml.Node
+	path string
+}
+
+// findYAMLPath appends the scalar nodes matching path under n to matches, in document order. at is the concrete path
+// of n.
+func findYAMLPath(n *yaml.Node, path, at []string, matches *[]yamlMatch) {
+	if n.Kind == yaml.DocumentNode {
+		if len(n.Content) > 0 {
+			findYAMLPath(n.Content[0], path, at, matches)
+		}
+		return
+	}
+	if n.Kind == yaml.AliasNode {
+		findYAMLPath(n.Alias, path, at, matches)
+		return
+	}
+	if len(path) == 0 {
+		if n.Kind != yaml.ScalarNode {
+			return
+		}
+		// `**` may reach the same node through different expansions
+		for _, m := range *matches {
+			if m.node == n {
+				return
+			}
+		}
+		*matches = append(*matches, yamlMatch{node: n, path: strings.Join(at, ".")})
+		return
+	}
+
+	seg := path[0]
+	if seg == "**" {
+		findYAMLPath(n, path[1:], at, matches)
+		for _, child := range yamlChildren(n, at) {
+			findYAMLPath(child.node, path, child.at, matches)
+		}
+		return
+	}
+
+	for _, child := range yamlChildren(n, at) {
+		if seg == "*" || child.key == seg {
+			findYAMLPath(child.node, path[1:], child.at, matches)
+		}
+	}
+}
+
+// yamlChild is a mapping value or sequence item, along with its key or index and concrete path.
+type yamlChild struct {
+	node *yaml.Node
+	key  string
+	at   []string
+}
+
+// yamlChildren returns the mapping values or sequence items of n, whose concrete path is at.
+func yamlChildren(n *yaml.Node, at []string) []yamlChild {
+	var children []yamlChild
+	child := func(node *yaml.Node, key string) yamlChild {
+		p := make([]string, len(at), len(at)+1)
+		copy(p, at)
+		return yamlChild{node: node, key: key, at: append(p, key)}
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			children = append(children, child(n.Content[i+1], n.Content[i].Value))
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			children = append(children, child(item, strconv.Itoa(i)))
+		}
+	}
+	return children
+}
+
+// yamlChange is a scalar whose value differs between two versions of a document.
+type yamlChange struct {
+	path  string
+	value string
+}
+
+// diffYAML returns the scalars whose value differs between the documents before and after, which must otherwise have
+// the same structure.
+func diffYAML(before, after []byte) ([]yamlChange, error) {
+	var b, a yaml.Node
+	if err := yaml.Unmarshal(before, &b); err != nil {
+		return nil, fmt.Errorf("parsing original content: %w", err)
+	}
+	if err := yaml.Unmarshal(after, &a); err != nil {
+		return nil, fmt.Errorf("parsing updated content: %w", err)
+	}
+
+	var changes []yamlChange
+	if err := diffYAMLNodes(&b, &a, nil, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func diffYAMLNodes(b, a *yaml.Node, at []string, changes *[]yamlChange) error {
+	path := strings.Join(at, ".")
+	if path == "" {
+		path = "the document root"
+	}
+
+	if b.Kind != a.Kind || len(b.Content) != len(a.Content) {
+		return fmt.Errorf("structure changed at %s", path)
+	}
+	if b.Kind == yaml.ScalarNode {
+		if b.Value != a.Value {
+			*changes = append(*changes, yamlChange{path: strings.Join(at, "."), value: a.Value})
+		}
+		return nil
+	}
+	if b.Kind == yaml.AliasNode {
+		return nil
+	}
+
+	for i := range b.Content {
+		next := at
+		switch b.Kind {
+		case yaml.MappingNode:
+			if i%2 == 0 {
+				// keys must not change
+				if b.Content[i].Value != a.Content[i].Value {
+					return fmt.Errorf("key %q changed to %q at %s", b.Content[i].Value, a.Content[i].Value, path)
+				}
+				continue
+			}
+			next = append(append([]string{}, at...), b.Content[i-1].Value)
+		case yaml.SequenceNode:
+			next = append(append([]string{}, at...), strconv.Itoa(i))
+		}
+		if err := diffYAMLNodes(b.Content[i], a.Content[i], next, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyChanges checks that the only values that differ between the documents before and after are the ones at the
+// given paths, and that these are set to version.
+func verifyChanges(before, after []byte, paths []string, version string) error {
+	intended := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		intended[p] = true
+	}
+
+	changes, err := diffYAML(before, after)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if !intended[c.path] {
+			return fmt.Errorf("unintended change of %s to %q", c.path, c.value)
+		}
+		if c.value != version {
+			return fmt.Errorf("%s changed to %q instead of %q", c.path, c.value, version)
+		}
+	}
+
+	return nil
+}
+
+// offsetOf returns the byte offset of the 1-based line and column (in characters) in content.
+func offsetOf(content []byte, line, column int) (int, bool) {
+	offset := 0
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(content[offset:], '\n')
+		if i < 0 {
+			return 0, false
+		}
+		offset += i + 1
+	}
+	for c := 1; c < column; c++ {
+		if offset >= len(content) || content[offset] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(content[offset:])
+		offset += size
+	}
+	if offset >= len(content) {
+		return 0, false
+	}
+	return offset, true
+}
+
+// UpdateFileWithRules applies the rules to the file at fileName, a temporary copy of a file of the target project,
+// and returns the updated content. It fails unless exactly the values targeted by the rules changed, to version. The
+// temporary file is removed.
+func UpdateFileWithRules(fileName string, rules []VersionRule, version string) ([]byte, error) {
+	original, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(fileName)
+
+	content := original
+	var paths []string
+	for _, r := range rules {
+		var targeted []string
+		content, targeted, err = r.apply(content, version)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, targeted...)
+	}
+
+	if err := verifyChanges(original, content, paths, version); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+
+	return content, nil
+}
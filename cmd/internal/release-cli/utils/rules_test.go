@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const environmentsYAML = `# registry versions per environment
+gprd:
+  registry:
+    chart: v1.0.0
+    values:
+      registry_version: v3.85.0-gitlab # current
+gprd-cny:
+  registry:
+    values:
+      registry_version: "v3.85.0-gitlab"
+regions:
+  - name: us-east1
+    registry_version: 'v3.84.0-gitlab'
+  - name: europe-west1
+    registry_version: v3.84.0-gitlab
+`
+
+func TestVersionRule_Apply_YAMLPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name: "exact path",
+			path: "gprd.registry.values.registry_version",
+			expected: `# registry versions per environment
+gprd:
+  registry:
+    chart: v1.0.0
+    values:
+      registry_version: v3.86.0-gitlab # current
+gprd-cny:
+  registry:
+    values:
+      registry_version: "v3.85.0-gitlab"
+regions:
+  - name: us-east1
+    registry_version: 'v3.84.0-gitlab'
+  - name: europe-west1
+    registry_version: v3.84.0-gitlab
+`,
+		},
+		{
+			name: "any depth",
+			path: "**.gprd-cny.**.registry_version",
+			expected: `# registry versions per environment
+gprd:
+  registry:
+    chart: v1.0.0
+    values:
+      registry_version: v3.85.0-gitlab # current
+gprd-cny:
+  registry:
+    values:
+      registry_version: "v3.86.0-gitlab"
+regions:
+  - name: us-east1
+    registry_version: 'v3.84.0-gitlab'
+  - name: europe-west1
+    registry_version: v3.84.0-gitlab
+`,
+		},
+		{
+			name: "wildcard",
+			path: "*.registry.chart",
+			expected: `# registry versions per environment
+gprd:
+  registry:
+    chart: v3.86.0-gitlab
+    values:
+      registry_version: v3.85.0-gitlab # current
+gprd-cny:
+  registry:
+    values:
+      registry_version: "v3.85.0-gitlab"
+regions:
+  - name: us-east1
+    registry_version: 'v3.84.0-gitlab'
+  - name: europe-west1
+    registry_version: v3.84.0-gitlab
+`,
+		},
+		{
+			name: "sequence index",
+			path: "regions.1.registry_version",
+			expected: `# registry versions per environment
+gprd:
+  registry:
+    chart: v1.0.0
+    values:
+      registry_version: v3.85.0-gitlab # current
+gprd-cny:
+  registry:
+    values:
+      registry_version: "v3.85.0-gitlab"
+regions:
+  - name: us-east1
+    registry_version: 'v3.84.0-gitlab'
+  - name: europe-west1
+    registry_version: v3.86.0-gitlab
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := VersionRule{Environment: "test", File: "bases/test.yaml", YAMLPath: tt.path}
+			out, err := r.Apply([]byte(environmentsYAML), "v3.86.0-gitlab")
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(out))
+		})
+	}
+}
+
+func TestVersionRule_Apply_YAMLPathErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		path    string
+		wantErr string
+	}{
+		{
+			name:    "not found",
+			content: environmentsYAML,
+			path:    "gstg.registry_version",
+			wantErr: `test: no scalar at yaml path "gstg.registry_version" in bases/test.yaml`,
+		},
+		{
+			name:    "not a scalar",
+			content: environmentsYAML,
+			path:    "gprd.registry",
+			wantErr: `test: no scalar at yaml path "gprd.registry" in bases/test.yaml`,
+		},
+		{
+			name:    "ambiguous",
+			content: environmentsYAML,
+			path:    "regions.*.registry_version",
+			wantErr: `test: yaml path "regions.*.registry_version" is ambiguous in bases/test.yaml, matching regions.0.registry_version, regions.1.registry_version`,
+		},
+		{
+			name:    "ambiguous at any depth",
+			content: environmentsYAML,
+			path:    "**.registry_version",
+			wantErr: `test: yaml path "**.registry_version" is ambiguous in bases/test.yaml, matching gprd.registry.values.registry_version, gprd-cny.registry.values.registry_version, regions.0.registry_version, regions.1.registry_version`,
+		},
+		{
+			name:    "invalid yaml",
+			content: "gprd: [",
+			path:    "gprd",
+			wantErr: "test: parsing bases/test.yaml: yaml: line 1: did not find expected node content",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := VersionRule{Environment: "test", File: "bases/test.yaml", YAMLPath: tt.path}
+			_, err := r.Apply([]byte(tt.content), "v3.86.0-gitlab")
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVersionRule_Apply_Regex(t *testing.T) {
+	r := VersionRule{Environment: "pre", File: "bases/pre.yaml", Regex: `registry_version: (v[0-9.]+-gitlab)`}
+
+	out, err := r.Apply([]byte(environmentsYAML), "v3.86.0-gitlab")
+	require.NoError(t, err)
+	require.Equal(t, `# registry versions per environment
+gprd:
+  registry:
+    chart: v1.0.0
+    values:
+      registry_version: v3.86.0-gitlab # current
+gprd-cny:
+  registry:
+    values:
+      registry_version: "v3.85.0-gitlab"
+regions:
+  - name: us-east1
+    registry_version: 'v3.84.0-gitlab'
+  - name: europe-west1
+    registry_version: v3.86.0-gitlab
+`, string(out))
+
+	_, err = r.Apply([]byte("registry_version: latest\n"), "v3.86.0-gitlab")
+	require.EqualError(t, err, `pre: no match for regex "registry_version: (v[0-9.]+-gitlab)" in bases/pre.yaml`)
+}
+
+func TestVersionRule_Apply_RegexUnintendedChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		regex   string
+		wantErr string
+	}{
+		{
+			name:    "partial value",
+			regex:   `registry_version: (v[0-9.]+)-gitlab`,
+			wantErr: `pre: regex "registry_version: (v[0-9.]+)-gitlab" in bases/pre.yaml changed gprd.registry.values.registry_version to "v3.86.0-gitlab-gitlab" instead of the version`,
+		},
+		{
+			name:    "key",
+			regex:   `(chart): v1.0.0`,
+			wantErr: `pre: regex "(chart): v1.0.0" in bases/pre.yaml: key "chart" changed to "v3.86.0-gitlab" at gprd.registry`,
+		},
+		{
+			name:    "structure",
+			regex:   `name: (europe-west1\n    registry_version: v3.84.0-gitlab)`,
+			wantErr: `pre: regex "name: (europe-west1\\n    registry_version: v3.84.0-gitlab)" in bases/pre.yaml: structure changed at regions.1`,
+		},
+		{
+			name:    "invalid yaml",
+			regex:   `(- name: us-east1)`,
+			wantErr: `pre: regex "(- name: us-east1)" in bases/pre.yaml: parsing updated content: yaml: line 13: mapping values are not allowed in this context`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := VersionRule{Environment: "pre", File: "bases/pre.yaml", Regex: tt.regex}
+			_, err := r.Apply([]byte(environmentsYAML), "v3.86.0-gitlab")
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVerifyChanges(t *testing.T) {
+	before := []byte("gprd:\n  registry_version: v1\n  chart: v1\n")
+
+	require.NoError(t, verifyChanges(before, before, []string{"gprd.registry_version"}, "v2"))
+	require.NoError(t, verifyChanges(before, []byte("# updated\ngprd:\n  registry_version: v2\n  chart: v1\n"), []string{"gprd.registry_version"}, "v2"))
+
+	err := verifyChanges(before, []byte("gprd:\n  registry_version: v2\n  chart: v2\n"), []string{"gprd.registry_version"}, "v2")
+	require.EqualError(t, err, `unintended change of gprd.chart to "v2"`)
+
+	err = verifyChanges(before, []byte("gprd:\n  registry_version: v3\n  chart: v1\n"), []string{"gprd.registry_version"}, "v2")
+	require.EqualError(t, err, `gprd.registry_version changed to "v3" instead of "v2"`)
+
+	err = verifyChanges(before, []byte("gprd:\n  registry_version: v2\n"), []string{"gprd.registry_version"}, "v2")
+	require.EqualError(t, err, "structure changed at gprd")
+}
+
+func TestVersionRule_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    VersionRule
+		wantErr string
+	}{
+		{name: "yaml path", rule: VersionRule{Environment: "gprd", File: "gprd.yaml", YAMLPath: "gprd.registry_version"}},
+		{name: "regex", rule: VersionRule{Environment: "gprd", File: "gprd.yaml", Regex: "version: (.+)"}},
+		{
+			name:    "no environment",
+			rule:    VersionRule{File: "gprd.yaml", YAMLPath: "gprd.registry_version"},
+			wantErr: "environment must be set",
+		},
+		{
+			name:    "no file",
+			rule:    VersionRule{Environment: "gprd", YAMLPath: "gprd.registry_version"},
+			wantErr: "gprd: file must be set",
+		},
+		{
+			name:    "neither yaml path nor regex",
+			rule:    VersionRule{Environment: "gprd", File: "gprd.yaml"},
+			wantErr: "gprd: exactly one of yaml_path or regex must be set",
+		},
+		{
+			name:    "both yaml path and regex",
+			rule:    VersionRule{Environment: "gprd", File: "gprd.yaml", YAMLPath: "gprd.registry_version", Regex: "version: (.+)"},
+			wantErr: "gprd: exactly one of yaml_path or regex must be set",
+		},
+		{
+			name:    "invalid regex",
+			rule:    VersionRule{Environment: "gprd", File: "gprd.yaml", Regex: "version: ("},
+			wantErr: "gprd: invalid regex: error parsing regexp: missing closing ): `version: (`",
+		},
+		{
+			name:    "regex without capture group",
+			rule:    VersionRule{Environment: "gprd", File: "gprd.yaml", Regex: "version: .+"},
+			wantErr: "gprd: regex must have exactly one capture group",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateFileWithRules(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "gprd.yaml")
+	require.NoError(t, os.WriteFile(fileName, []byte(environmentsYAML), 0o600))
+
+	rules := []VersionRule{
+		{Environment: "gprd", File: "bases/gprd.yaml", YAMLPath: "**.gprd.**.registry_version"},
+		{Environment: "gprd-cny", File: "bases/gprd.yaml", YAMLPath: "**.gprd-cny.**.registry_version"},
+	}
+	out, err := UpdateFileWithRules(fileName, rules, "v3.86.0-gitlab")
+	require.NoError(t, err)
+	require.Contains(t, string(out), "registry_version: v3.86.0-gitlab # current")
+	require.Contains(t, string(out), `registry_version: "v3.86.0-gitlab"`)
+
+	// the temporary copy of the file is removed
+	require.NoFileExists(t, fileName)
+}
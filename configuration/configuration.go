@@ -59,6 +59,9 @@ type Configuration struct {
 	// Storage is the configuration for the registry's storage driver
 	Storage Storage `yaml:"storage"`
 
+	// Isolation is the configuration for storing the objects of top-level namespaces in dedicated storage drivers
+	Isolation Isolation `yaml:"isolation,omitempty"`
+
 	// Database is the configuration for the registry's metadata database
 	Database Database `yaml:"database"`
 
@@ -75,6 +78,9 @@ type Configuration struct {
 	// Profiling configures external profiling services.
 	Profiling Profiling `yaml:"profiling,omitempty"`
 
+	// Tracing configures distributed tracing with OpenTelemetry.
+	Tracing Tracing `yaml:"tracing,omitempty"`
+
 	// HTTP contains configuration parameters for the registry's http
 	// interface.
 	HTTP struct {
@@ -101,6 +107,21 @@ type Configuration struct {
 		// receives a stop signal
 		DrainTimeout time.Duration `yaml:"draintimeout,omitempty"`
 
+		// Deadlines configures the maximum duration of Distribution API requests.
+		Deadlines Deadlines `yaml:"deadlines,omitempty"`
+
+		// RequestLimits protects the registry against oversized request bodies and slow clients.
+		RequestLimits RequestLimits `yaml:"requestlimits,omitempty"`
+
+		// TrustedProxies is the list of CIDR ranges of the reverse proxies in front of the registry. The client IP,
+		// used for direct upload trust checks and per-IP rate limits, is taken from the X-Forwarded-For header only
+		// for requests received from these. Defaults to none, in which case the client IP is the remote address of
+		// the connection.
+		TrustedProxies []string `yaml:"trustedproxies,omitempty"`
+
+		// CORS configures Cross-Origin Resource Sharing for each API, allowing browser-based clients to call them.
+		CORS CORS `yaml:"cors,omitempty"`
+
 		// TLS instructs the http server to listen with a TLS configuration.
 		// This only support simple tls configuration with a cert and key.
 		// Mostly, this is useful for testing situations or simple deployments
@@ -108,6 +129,11 @@ type Configuration struct {
 		// a proxy or make a proposal to add support here.
 		TLS TLS `yaml:"tls,omitempty"`
 
+		// Listeners configures multiple addresses to listen on, each with its own TLS configuration, for example to
+		// serve TLS over IPv6 and plain HTTP over a Unix socket at the same time. Can't be used along with Addr, Net
+		// and TLS, which configure a single listener.
+		Listeners []Listener `yaml:"listeners,omitempty"`
+
 		// Headers is a set of headers to include in HTTP responses. A common
 		// use case for this would be security headers such as
 		// Strict-Transport-Security. The map keys are the header names, and
@@ -126,6 +152,8 @@ type Configuration struct {
 			Prometheus struct {
 				Enabled bool   `yaml:"enabled,omitempty"`
 				Path    string `yaml:"path,omitempty"`
+				// Repositories configures the metrics of the most active repositories.
+				Repositories PrometheusRepositories `yaml:"repositories,omitempty"`
 			} `yaml:"prometheus,omitempty"`
 			// Pprof configures a pprof server, which listens at `/debug/pprof`.
 			Pprof struct {
@@ -141,6 +169,9 @@ type Configuration struct {
 		} `yaml:"http2,omitempty"`
 	} `yaml:"http,omitempty"`
 
+	// GRPC configures the gRPC metadata API, the counterpart of the GitLab v1 HTTP API for internal consumers.
+	GRPC GRPC `yaml:"grpc,omitempty"`
+
 	// Notifications specifies configuration about various endpoint to which
 	// registry events are dispatched.
 	Notifications Notifications `yaml:"notifications,omitempty"`
@@ -164,6 +195,15 @@ type Configuration struct {
 			ReferenceLimit int `yaml:"referencelimit,omitempty"`
 			// PayloadSizeLimit is the maximum data size in bytes of manifest payloads. Set to zero to disable.
 			PayloadSizeLimit int `yaml:"payloadsizelimit,omitempty"`
+			// PayloadSizeLimits overrides PayloadSizeLimit for repositories matching a path pattern. The first matching
+			// rule applies.
+			PayloadSizeLimits []ManifestPayloadSizeLimitRule `yaml:"payloadsizelimits,omitempty"`
+			// ReferenceVerification configures how the references of manifest lists are verified.
+			ReferenceVerification ManifestReferenceVerification `yaml:"referenceverification,omitempty"`
+			// BlobMount configures the mounting of blobs referenced by pushed manifests from other repositories.
+			BlobMount ManifestBlobMount `yaml:"blobmount,omitempty"`
+			// ArtifactMediaTypes configures the registration of the media types of OCI artifacts unknown to the registry.
+			ArtifactMediaTypes ManifestArtifactMediaTypes `yaml:"artifactmediatypes,omitempty"`
 			// URLs configures validation for URLs in pushed manifests.
 			URLs struct {
 				// Allow specifies regular expressions (https://godoc.org/regexp/syntax)
@@ -186,10 +226,94 @@ type Configuration struct {
 			// manifests. When non-empty, the registry will enforce
 			// the class in authorized resources.
 			Classes []string `yaml:"classes"`
+			// ImmutableTags is a list of rules that mark tags matching a set of patterns as immutable for
+			// repositories matching a path pattern. Immutable tags cannot be overwritten once created.
+			ImmutableTags []ImmutableTagRule `yaml:"immutabletags,omitempty"`
+			// Signatures is a list of rules that require manifests pulled from repositories matching a path pattern to
+			// be signed with a trusted key or certificate. Pulls of unsigned manifests are denied.
+			Signatures []SignatureRule `yaml:"signatures,omitempty"`
 		} `yaml:"repository,omitempty"`
 	} `yaml:"policy,omitempty"`
 
 	GC GC `yaml:"gc,omitempty"`
+
+	// RemoteMount configures cross-registry blob mounting.
+	RemoteMount RemoteMount `yaml:"remotemount,omitempty"`
+
+	// RateLimiter configures request rate limiting.
+	RateLimiter RateLimiter `yaml:"ratelimiter,omitempty"`
+
+	// Uploads configures blob uploads.
+	Uploads Uploads `yaml:"uploads,omitempty"`
+
+	// Audit configures the audit log of write operations.
+	Audit Audit `yaml:"audit,omitempty"`
+
+	// Replication configures the mirroring of pushed images to downstream registries.
+	Replication Replication `yaml:"replication,omitempty"`
+
+	// Maintenance configures maintenance windows, during which write operations are rejected.
+	Maintenance Maintenance `yaml:"maintenance,omitempty"`
+
+	// Compatibility configures compatibility modes for legacy clients and content.
+	Compatibility Compatibility `yaml:"compatibility,omitempty"`
+
+	// Proxy configures the registry as a pull-through cache of a remote registry. Requires the database to be enabled.
+	Proxy Proxy `yaml:"proxy,omitempty"`
+}
+
+// ManifestPayloadSizeLimitRule overrides the manifest payload size limit for one or more repositories.
+type ManifestPayloadSizeLimitRule struct {
+	// Repository is a glob pattern (see https://pkg.go.dev/path#Match) matched against the repository path. An empty
+	// value matches all repositories.
+	Repository string `yaml:"repository,omitempty"`
+	// Limit is the maximum data size in bytes of manifest payloads. Set to zero to disable.
+	Limit int `yaml:"limit"`
+}
+
+// ImmutableTagRule marks tags as immutable in one or more repositories.
+type ImmutableTagRule struct {
+	// Repository is a glob pattern (see https://pkg.go.dev/path#Match) matched against the repository path. An empty
+	// value matches all repositories.
+	Repository string `yaml:"repository,omitempty"`
+	// Patterns is a list of glob patterns (e.g. `v*`, `release-*`) matched against tag names. Tags matching any of
+	// these patterns cannot be overwritten once created.
+	Patterns []string `yaml:"patterns,omitempty"`
+}
+
+// SignatureMode determines what happens when a pulled manifest has no valid signature.
+type SignatureMode string
+
+const (
+	// SignatureModeEnforce denies pulls of manifests without a valid signature.
+	SignatureModeEnforce SignatureMode = "enforce"
+	// SignatureModeWarn logs pulls of manifests without a valid signature, but allows them.
+	SignatureModeWarn SignatureMode = "warn"
+)
+
+// SignatureRule requires manifests pulled from one or more repositories to be signed with cosign or notation.
+type SignatureRule struct {
+	// Repository is a glob pattern (see https://pkg.go.dev/path#Match) matched against the repository path. An empty
+	// value matches all repositories. The first matching rule applies.
+	Repository string `yaml:"repository,omitempty"`
+	// Mode is either `enforce` or `warn`. Defaults to `enforce`.
+	Mode SignatureMode `yaml:"mode,omitempty"`
+	// Cosign configures the trust roots for cosign signatures.
+	Cosign CosignTrustRoots `yaml:"cosign,omitempty"`
+	// Notation configures the trust roots for notation signatures.
+	Notation NotationTrustRoots `yaml:"notation,omitempty"`
+}
+
+// CosignTrustRoots configures the keys trusted to sign images with cosign.
+type CosignTrustRoots struct {
+	// Keys is a list of paths to PEM encoded ECDSA, RSA or Ed25519 public keys.
+	Keys []string `yaml:"keys,omitempty"`
+}
+
+// NotationTrustRoots configures the certificate authorities trusted to sign images with notation.
+type NotationTrustRoots struct {
+	// Certificates is a list of paths to PEM files with the root certificates that signing certificates must chain to.
+	Certificates []string `yaml:"certificates,omitempty"`
 }
 
 // TLS specifies the settings for the http server to listen with a TLS configuration.
@@ -228,6 +352,102 @@ type TLS struct {
 	} `yaml:"letsencrypt,omitempty"`
 }
 
+// GRPC configures the gRPC metadata API server.
+type GRPC struct {
+	// Addr is the bind address of the gRPC server, e.g. `localhost:5002`. The gRPC API is disabled if not set.
+	Addr string `yaml:"addr,omitempty"`
+	// Net is the network of the address, one of tcp (IPv4 and IPv6), tcp4 (IPv4 only), tcp6 (IPv6 only) or unix.
+	// Defaults to tcp.
+	Net string `yaml:"net,omitempty"`
+	// TLS configures TLS for the gRPC server. Client certificates signed by one of the ClientCAs are required if set
+	// (mTLS). Let's Encrypt is not supported.
+	TLS TLS `yaml:"tls,omitempty"`
+}
+
+// Listener configures an address for the HTTP server to listen on.
+type Listener struct {
+	// Net is the network of the address, one of tcp (IPv4 and IPv6), tcp4 (IPv4 only), tcp6 (IPv6 only) or unix.
+	// Defaults to tcp.
+	Net string `yaml:"net,omitempty"`
+	// Addr is the bind address, or the socket path for the unix network.
+	Addr string `yaml:"addr,omitempty"`
+	// TLS configures TLS for this listener. Plain HTTP is served if neither a certificate nor Let's Encrypt are
+	// configured.
+	TLS TLS `yaml:"tls,omitempty"`
+}
+
+// Deadlines configures the maximum duration of Distribution API requests. A deadline propagates to the metadata
+// database queries and storage driver calls made while serving a request, which are canceled once it's exceeded.
+type Deadlines struct {
+	// Default is the deadline of requests that don't match any rule. Zero means no deadline.
+	Default time.Duration `yaml:"default,omitempty"`
+	// Rules sets the deadline of specific requests. The first matching rule applies.
+	Rules []DeadlineRule `yaml:"rules,omitempty"`
+}
+
+// DeadlineRule sets the deadline of requests matching a route and a set of methods.
+type DeadlineRule struct {
+	// Route is the name of a Distribution API route, such as manifest or blob-upload-chunk. Empty matches any route.
+	Route string `yaml:"route,omitempty"`
+	// Methods is the list of HTTP methods to match. Empty matches any method.
+	Methods []string `yaml:"methods,omitempty"`
+	// Deadline is the maximum duration of matching requests. Zero means no deadline.
+	Deadline time.Duration `yaml:"deadline,omitempty"`
+}
+
+// RequestLimits protects the registry against oversized request bodies and slow clients, such as slowloris attacks.
+// Limits apply per route class: the blob class covers blob uploads and OCI image layout archive imports, and the API
+// class covers all other requests, such as manifest pushes and GitLab API requests.
+type RequestLimits struct {
+	// ReadHeaderTimeout is the maximum duration for reading request headers. It applies to all requests, as the route
+	// is only known once headers are read. Zero means no timeout.
+	ReadHeaderTimeout time.Duration `yaml:"readheadertimeout,omitempty"`
+	// MaxBodySize is the maximum size in bytes of API class request bodies. Zero means no limit other than the ones
+	// built into each endpoint.
+	MaxBodySize int64 `yaml:"maxbodysize,omitempty"`
+	// BodyTimeouts is the maximum duration without receiving request body data for each route class.
+	BodyTimeouts BodyTimeouts `yaml:"bodytimeouts,omitempty"`
+}
+
+// BodyTimeouts configures the maximum duration without receiving request body data for each route class. Zero means
+// no timeout.
+type BodyTimeouts struct {
+	// Blob is the timeout of blob uploads and OCI image layout archive imports.
+	Blob time.Duration `yaml:"blob,omitempty"`
+	// API is the timeout of all other requests.
+	API time.Duration `yaml:"api,omitempty"`
+}
+
+// CORS configures the Cross-Origin Resource Sharing policy of each API. CORS is disabled for APIs without a policy.
+type CORS struct {
+	// Distribution is the policy of the Distribution API (/v2/).
+	Distribution CORSPolicy `yaml:"distribution,omitempty"`
+	// GitLab is the policy of the GitLab API (/gitlab/v1/).
+	GitLab CORSPolicy `yaml:"gitlab,omitempty"`
+}
+
+// CORSPolicy determines which cross-origin requests browsers are allowed to make.
+type CORSPolicy struct {
+	// Enabled enables CORS.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// AllowedOrigins is the list of origins allowed to make requests, such as https://example.com. A single `*`
+	// allows any origin.
+	AllowedOrigins []string `yaml:"allowedorigins,omitempty"`
+	// AllowedMethods is the list of HTTP methods allowed. Defaults to GET and HEAD.
+	AllowedMethods []string `yaml:"allowedmethods,omitempty"`
+	// AllowedHeaders is the list of request headers allowed. Defaults to Accept, Authorization and Content-Type.
+	AllowedHeaders []string `yaml:"allowedheaders,omitempty"`
+	// ExposedHeaders is the list of response headers that browsers expose to clients, in addition to the CORS-safelisted
+	// response headers.
+	ExposedHeaders []string `yaml:"exposedheaders,omitempty"`
+	// AllowCredentials allows requests with credentials, such as cookies and authorization headers. Can't be used
+	// along with a `*` origin.
+	AllowCredentials bool `yaml:"allowcredentials,omitempty"`
+	// MaxAge is the duration for which browsers can cache the response of preflight requests. Browsers use their own
+	// default if not set.
+	MaxAge time.Duration `yaml:"maxage,omitempty"`
+}
+
 // DebugTLS specifies the TLS settings for the HTTP Debug server
 type DebugTLS struct {
 	// Enabled is only used to check if TLS is enabled for the debug monitoring service
@@ -255,6 +475,13 @@ type RedisTLS struct {
 	Enabled bool `yaml:"enabled,omitempty"`
 	// Insecure disables server name verification when connecting over TLS.
 	Insecure bool `yaml:"insecure,omitempty"`
+	// CAFile is the path to a PEM encoded CA bundle used to verify the server certificate, instead of the system
+	// certificate pool.
+	CAFile string `yaml:"cafile,omitempty"`
+	// CertFile is the path to a PEM encoded client certificate, for servers that require mutual TLS.
+	CertFile string `yaml:"certfile,omitempty"`
+	// KeyFile is the path to the PEM encoded private key of CertFile.
+	KeyFile string `yaml:"keyfile,omitempty"`
 }
 
 // RedisPool configures the behavior of the redis connection pool.
@@ -277,6 +504,15 @@ type RedisCache struct {
 	Addr string `yaml:"addr,omitempty"`
 	// MainName specifies the main server name. Only for Sentinel connections.
 	MainName string `yaml:"mainname,omitempty"`
+	// SentinelUsername is the ACL user to authenticate with on the Sentinel servers. Only for Sentinel connections.
+	SentinelUsername string `yaml:"sentinelusername,omitempty"`
+	// SentinelPassword is the password to authenticate with on the Sentinel servers. Only for Sentinel connections.
+	SentinelPassword string `yaml:"sentinelpassword,omitempty"`
+	// Cluster enables the Redis Cluster mode, in which case Addr is a list of seed node addresses separated by commas.
+	// Multiple addresses without MainName also select this mode.
+	Cluster bool `yaml:"cluster,omitempty"`
+	// Username is the ACL user to authenticate with. Requires Redis 6 or later. Defaults to the `default` user.
+	Username string `yaml:"username,omitempty"`
 	// Password string to use when making a connection.
 	Password string `yaml:"password,omitempty"`
 	// DB specifies the database to connect to on the redis instance.
@@ -291,6 +527,88 @@ type RedisCache struct {
 	TLS RedisTLS `yaml:"tls,omitempty"`
 	// Pool configures the behavior of the redis connection pool.
 	Pool RedisPool `yaml:"pool,omitempty"`
+	// Manifests configures the caching of manifests from the metadata database.
+	Manifests RedisCacheManifests `yaml:"manifests,omitempty"`
+	// UploadSessions configures the persistence of blob upload sessions.
+	UploadSessions RedisCacheUploadSessions `yaml:"uploadsessions,omitempty"`
+}
+
+// defaultManifestReferenceVerificationTimeout is the default deadline for verifying all references of a manifest list
+// concurrently.
+const defaultManifestReferenceVerificationTimeout = 30 * time.Second
+
+// ManifestReferenceVerification configures the verification of the manifests referenced by manifest lists when they
+// are pushed.
+type ManifestReferenceVerification struct {
+	// Concurrency is the maximum number of references verified concurrently. References are verified sequentially
+	// if set to zero or one, which is the default.
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// Timeout is the deadline for verifying all references concurrently. Pushes fail if any reference was not
+	// verified by then. Defaults to 30 seconds. Only applies if Concurrency is greater than one.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// defaultManifestBlobMountConcurrency is the default number of blobs mounted concurrently during manifest pushes.
+const defaultManifestBlobMountConcurrency = 5
+
+// ManifestArtifactMediaTypes configures the registration of the artifact and configuration media types of pushed OCI
+// artifact manifests which are unknown to the registry. Media types are registered in the database, where the number of
+// media types is limited, so only those matching an allowed pattern are registered. Manifests with other unknown media
+// types are rejected.
+type ManifestArtifactMediaTypes struct {
+	// Allow specifies regular expressions (https://godoc.org/regexp/syntax) that unknown media types must match to be
+	// registered. None are registered if empty.
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// ManifestBlobMount configures the mounting of blobs referenced by pushed manifests that are unknown to the target
+// repository, from other repositories that the client has pull access to. This requires the metadata database.
+type ManifestBlobMount struct {
+	// Enabled enables mounting blobs during manifest pushes, instead of rejecting manifests that reference them.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Concurrency is the maximum number of blobs mounted concurrently. Defaults to 5.
+	Concurrency int `yaml:"concurrency,omitempty"`
+}
+
+// defaultRedisCacheManifestsTTL is the default expiration of cached manifests and tags.
+const defaultRedisCacheManifestsTTL = 10 * time.Minute
+
+// RedisCacheManifests configures the caching of manifest payloads and tag references from the metadata database.
+type RedisCacheManifests struct {
+	// Enabled toggles the caching of manifests. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// TTL is the expiration applied to cached manifests and tags. Defaults to 10 minutes.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+}
+
+const (
+	defaultPrometheusRepositoriesLimit    = 100
+	defaultPrometheusRepositoriesHalfLife = time.Hour
+)
+
+// PrometheusRepositories configures the pull and push metrics of the most active repositories. Only a limited number of
+// repositories is tracked at a time to bound the cardinality of the metrics.
+type PrometheusRepositories struct {
+	// Enabled toggles the repository metrics. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Limit is the maximum number of repositories tracked at a time. Defaults to 100.
+	Limit int `yaml:"limit,omitempty"`
+	// HalfLife is the time after which the activity of a repository halves if it's not pulled or pushed. Defaults to 1
+	// hour.
+	HalfLife time.Duration `yaml:"halflife,omitempty"`
+}
+
+// defaultRedisCacheUploadSessionsTTL is the default expiration of blob upload sessions. It matches the default age of
+// uploads removed by upload purging.
+const defaultRedisCacheUploadSessionsTTL = 168 * time.Hour
+
+// RedisCacheUploadSessions configures the persistence of blob upload sessions in Redis, so that uploads can be resumed
+// by any registry instance.
+type RedisCacheUploadSessions struct {
+	// Enabled toggles the persistence of upload sessions. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// TTL is the expiration applied to upload sessions, refreshed on every chunk. Defaults to 168 hours.
+	TTL time.Duration `yaml:"ttl,omitempty"`
 }
 
 // Redis configures the redis instance(s) available to the application. Separate Redis instances for different
@@ -301,6 +619,15 @@ type Redis struct {
 	Addr string `yaml:"addr,omitempty"`
 	// MainName specifies the main server name. Only for Sentinel connections.
 	MainName string `yaml:"mainname,omitempty"`
+	// SentinelUsername is the ACL user to authenticate with on the Sentinel servers. Only for Sentinel connections.
+	SentinelUsername string `yaml:"sentinelusername,omitempty"`
+	// SentinelPassword is the password to authenticate with on the Sentinel servers. Only for Sentinel connections.
+	SentinelPassword string `yaml:"sentinelpassword,omitempty"`
+	// Cluster enables the Redis Cluster mode, in which case Addr is a list of seed node addresses separated by commas.
+	// Multiple addresses without MainName also select this mode.
+	Cluster bool `yaml:"cluster,omitempty"`
+	// Username is the ACL user to authenticate with. Requires Redis 6 or later. Defaults to the `default` user.
+	Username string `yaml:"username,omitempty"`
 	// Password string to use when making a connection.
 	Password string `yaml:"password,omitempty"`
 	// DB specifies the database to connect to on the redis instance.
@@ -342,6 +669,245 @@ type GC struct {
 	ReviewAfter time.Duration `yaml:"reviewafter,omitempty"`
 }
 
+// RemoteMount configures cross-registry blob mounting, which allows clients to request the registry to fetch a blob
+// directly from another registry instead of downloading and re-uploading it.
+type RemoteMount struct {
+	// Enabled enables cross-registry blob mounting.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Registries is the list of hosts (`host[:port]`) that blobs can be mounted from. Requests targeting any other
+	// registry are denied.
+	Registries []string `yaml:"registries,omitempty"`
+	// Timeout is the maximum duration of a single remote blob transfer. Defaults to 10 minutes.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Insecure allows mounting blobs from registries over plain HTTP. Should only be used for testing purposes.
+	Insecure bool `yaml:"insecure,omitempty"`
+}
+
+// Replication configures the mirroring of pushed images to one or more downstream registries.
+type Replication struct {
+	// Targets is the list of downstream registries that pushed images are replicated to.
+	Targets []ReplicationTarget `yaml:"targets,omitempty"`
+}
+
+// ReplicationTarget configures a downstream registry that pushed images are replicated to. Images are pushed to the
+// repository with the same path on the downstream registry.
+type ReplicationTarget struct {
+	// Name identifies the target. Must be unique.
+	Name string `yaml:"name"`
+	// Disabled skips the target.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// URL is the base URL of the downstream registry, e.g. `https://registry.example.com`.
+	URL string `yaml:"url"`
+	// Username and Password are the credentials used to authenticate against the downstream registry, either directly
+	// with basic authentication or to obtain bearer tokens from its token server.
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// Repositories is a list of glob patterns (e.g. `my-group/*`) matched against repository paths. Only images pushed
+	// to matching repositories are replicated. If empty, all repositories are replicated.
+	Repositories []string `yaml:"repositories,omitempty"`
+	// Timeout is the maximum duration of a single replication attempt. Defaults to 5 minutes.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Threshold is the maximum number of attempts to replicate an image before giving up. Defaults to 5.
+	Threshold int `yaml:"threshold,omitempty"`
+	// Backoff is the time to wait between attempts. Defaults to 10 seconds.
+	Backoff time.Duration `yaml:"backoff,omitempty"`
+	// Workers is the number of images replicated concurrently. Defaults to 2.
+	Workers int `yaml:"workers,omitempty"`
+	// QueueSize is the maximum number of images waiting to be replicated. Images pushed while the queue is full are
+	// not replicated. Defaults to 1000.
+	QueueSize int `yaml:"queuesize,omitempty"`
+}
+
+// Proxy configures the registry as a pull-through cache of a remote registry. Manifests and blobs that are not found
+// locally are fetched from the repository with the same path on the remote registry and recorded in the database.
+type Proxy struct {
+	// RemoteURL is the base URL of the remote registry, e.g. `https://registry-1.docker.io`. The pull-through cache
+	// is disabled if empty.
+	RemoteURL string `yaml:"remoteurl,omitempty"`
+	// Username and Password are the credentials used to authenticate against the remote registry, either directly
+	// with basic authentication or to obtain bearer tokens from its token server.
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// TTL is for how long cached tags are served before they are deleted, so that they are fetched from the remote
+	// registry again on the next pull. The manifests and blobs no longer referenced are then deleted by the online
+	// garbage collector. Defaults to 7 days.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+	// Interval is the interval between runs of the worker deleting expired tags. Defaults to 1 minute.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// BatchSize is the maximum number of expired tags deleted in each run. Defaults to 100.
+	BatchSize int `yaml:"batchsize,omitempty"`
+	// Timeout is the maximum duration of fetching an image from the remote registry. Defaults to 10 minutes.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Enabled reports whether the registry is a pull-through cache.
+func (p Proxy) Enabled() bool {
+	return p.RemoteURL != ""
+}
+
+// Keys that requests can be rate limited by.
+const (
+	RateLimitKeyIP         = "ip"
+	RateLimitKeyToken      = "token"
+	RateLimitKeyRepository = "repository"
+)
+
+// RateLimiter configures request rate limiting. Request counts are kept in the Redis cache, so that limits apply to
+// the registry as a whole and not to each instance.
+type RateLimiter struct {
+	// Enabled enables rate limiting. Requires the Redis cache to be enabled.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Limiters is the list of limits enforced on each request. A request is rejected if any of them is exceeded.
+	Limiters []Limiter `yaml:"limiters,omitempty"`
+}
+
+// Limiter configures a limit applied separately to each distinct value of the request attribute identified by Key.
+type Limiter struct {
+	// Name identifies the limiter. Must be unique.
+	Name string `yaml:"name"`
+	// Key is the request attribute to limit by, one of RateLimitKeyIP, RateLimitKeyToken or RateLimitKeyRepository.
+	Key string `yaml:"key"`
+	// Burst is the maximum number of requests allowed within any one second window. Zero means no limit.
+	Burst int `yaml:"burst,omitempty"`
+	// Sustained is the maximum number of requests allowed within any Period window. Zero means no limit.
+	Sustained int `yaml:"sustained,omitempty"`
+	// Period is the length of the window for Sustained. Defaults to 1 minute.
+	Period time.Duration `yaml:"period,omitempty"`
+}
+
+// Uploads configures blob uploads.
+type Uploads struct {
+	// Chunks configures the size constraints of chunked blob uploads.
+	Chunks UploadChunks `yaml:"chunks,omitempty"`
+	// DrainTimeout is the maximum time to wait for open blob uploads and in-flight manifest puts to complete when
+	// draining the registry, before shutting it down. Defaults to 5m.
+	DrainTimeout time.Duration `yaml:"draintimeout,omitempty"`
+	// Direct configures direct blob uploads, where trusted clients upload blob data straight to the storage backend.
+	Direct UploadsDirect `yaml:"direct,omitempty"`
+	// Import configures the import of images from OCI image layout archives through the GitLab API.
+	Import UploadsImport `yaml:"import,omitempty"`
+}
+
+// UploadsImport configures the import of images from OCI image layout archives, which are either uploaded in the
+// request body or downloaded by the registry from a URL.
+type UploadsImport struct {
+	// MaxSize is the maximum size in bytes of an imported archive. Zero means no maximum.
+	MaxSize int64 `yaml:"maxsize,omitempty"`
+	// Hosts is the list of hosts (`host[:port]`) that archives can be downloaded from over HTTPS. If empty, archives
+	// can only be uploaded in the request body.
+	Hosts []string `yaml:"hosts,omitempty"`
+	// Timeout is the maximum duration of an archive download. Defaults to 30 minutes.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// UploadsDirect configures direct blob uploads. When starting a blob upload, trusted clients can request presigned
+// URLs to upload the blob data directly to the storage backend, in parts, bypassing the registry. The registry then
+// verifies the uploaded data against the expected digest when the upload is completed. This requires the
+// REGISTRY_FF_DIRECT_UPLOADS feature flag and a storage driver that supports direct uploads (currently only s3).
+type UploadsDirect struct {
+	// Enabled enables direct blob uploads.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// TrustedClients is the list of CIDR ranges of the clients allowed to upload directly to the storage backend.
+	TrustedClients []string `yaml:"trustedclients,omitempty"`
+	// Expiry is the validity of the presigned upload URLs. Defaults to 1h.
+	Expiry time.Duration `yaml:"expiry,omitempty"`
+	// MaxParts is the maximum number of parts that clients can request upload URLs for. Defaults to 100.
+	MaxParts int `yaml:"maxparts,omitempty"`
+}
+
+// UploadChunks configures the size constraints of chunks uploaded with blob upload PATCH requests. Chunks outside these
+// limits are rejected with a 416 Requested Range Not Satisfiable error.
+type UploadChunks struct {
+	// MinSize is the minimum size in bytes of each chunk with a Content-Range header. The final chunk, whether uploaded
+	// with a PATCH request or with the PUT request that completes the upload, is exempt. Advertised to clients with the OCI-Chunk-Min-Length
+	// header. Zero means no minimum.
+	MinSize int64 `yaml:"minsize,omitempty"`
+	// MaxSize is the maximum size in bytes of the body of each PATCH request, with or without a Content-Range header.
+	// Zero means no maximum.
+	MaxSize int64 `yaml:"maxsize,omitempty"`
+}
+
+// Maintenance configures maintenance windows. During a maintenance window, write operations, such as pushes and
+// deletes, are rejected with a 503 Service Unavailable response, while reads are still served. This allows performing
+// operations that require the registry contents to remain unchanged, such as storage migrations.
+type Maintenance struct {
+	// Windows is the list of scheduled maintenance windows. Windows can be changed at runtime by reloading the
+	// configuration (SIGHUP) or through the debug server.
+	Windows []MaintenanceWindow `yaml:"windows,omitempty"`
+}
+
+// MaintenanceWindow is a period of time during which write operations are rejected.
+type MaintenanceWindow struct {
+	// Start is the time at which the window starts, as an RFC 3339 timestamp.
+	Start time.Time `yaml:"start"`
+	// End is the time at which the window ends, as an RFC 3339 timestamp. Must be after Start.
+	End time.Time `yaml:"end"`
+	// Reason optionally describes the maintenance. Included in the error detail of rejected requests.
+	Reason string `yaml:"reason,omitempty"`
+}
+
+// Compatibility configures compatibility modes for legacy clients and content.
+type Compatibility struct {
+	// Schema1 configures the handling of stored schema 1 manifests.
+	Schema1 struct {
+		// ConvertOnRead enables serving stored schema 1 manifests converted to schema 2 when pulled by tag by clients
+		// that accept schema 2 manifests, instead of rejecting them as invalid.
+		ConvertOnRead bool `yaml:"convertonread,omitempty"`
+	} `yaml:"schema1,omitempty"`
+}
+
+// Audit log sinks.
+const (
+	AuditSinkFile   = "file"
+	AuditSinkSyslog = "syslog"
+	AuditSinkHTTP   = "http"
+)
+
+// Audit configures the audit log, which records every push, delete and tag operation, as well as online garbage
+// collection removals, as a structured JSON record written to the configured sink.
+type Audit struct {
+	// Enabled enables the audit log.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Sink is the destination of audit records, one of AuditSinkFile, AuditSinkSyslog or AuditSinkHTTP.
+	Sink string `yaml:"sink,omitempty"`
+	// HMACKey is an optional secret used to compute the hash of each record with HMAC-SHA256 instead of SHA-256, so
+	// that records can not be forged without knowing it.
+	HMACKey string `yaml:"hmackey,omitempty"`
+	// File configures the file sink.
+	File AuditFile `yaml:"file,omitempty"`
+	// Syslog configures the syslog sink.
+	Syslog AuditSyslog `yaml:"syslog,omitempty"`
+	// HTTP configures the HTTP sink.
+	HTTP AuditHTTP `yaml:"http,omitempty"`
+}
+
+// AuditFile configures the audit log file sink, which appends records to a file, one per line.
+type AuditFile struct {
+	// Path is the path of the file. It is created if it does not exist.
+	Path string `yaml:"path,omitempty"`
+}
+
+// AuditSyslog configures the audit log syslog sink.
+type AuditSyslog struct {
+	// Network is the network used to connect to the syslog daemon, such as `udp` or `tcp`. If empty, the local syslog
+	// daemon is used.
+	Network string `yaml:"network,omitempty"`
+	// Addr is the address of the syslog daemon. Ignored if Network is empty.
+	Addr string `yaml:"addr,omitempty"`
+	// Tag is the tag of syslog messages. Defaults to `registry-audit`.
+	Tag string `yaml:"tag,omitempty"`
+}
+
+// AuditHTTP configures the audit log HTTP sink, which sends each record in the body of a POST request.
+type AuditHTTP struct {
+	// URL is the endpoint records are sent to.
+	URL string `yaml:"url,omitempty"`
+	// Timeout is the timeout of each request. Defaults to 5 seconds.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Headers lists static headers to add to each request.
+	Headers http.Header `yaml:"headers,omitempty"`
+}
+
 // GCBlobs configures the blob worker.
 type GCBlobs struct {
 	// Disabled disables the blob worker.
@@ -385,6 +951,28 @@ type StackdriverProfiler struct {
 	KeyFile string `yaml:"keyfile,omitempty"`
 }
 
+// Tracing configures distributed tracing with OpenTelemetry. Spans are exported to an OpenTelemetry collector using
+// the OTLP protocol over HTTP.
+type Tracing struct {
+	// Enabled can be set to `true` to enable tracing.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// ServiceName is the name of the service under which spans are reported. Defaults to `container-registry`.
+	ServiceName string `yaml:"servicename,omitempty"`
+	// Endpoint is the host and port of the OTLP HTTP receiver that spans are exported to. Defaults to `localhost:4318`.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// URLPath is the URL path of the OTLP HTTP receiver. Defaults to `/v1/traces`.
+	URLPath string `yaml:"urlpath,omitempty"`
+	// Insecure can be set to `true` to export spans over plain HTTP instead of HTTPS.
+	Insecure bool `yaml:"insecure,omitempty"`
+	// Headers are additional HTTP headers sent with every export request, such as authentication tokens.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Timeout is the maximum duration of each export request. Defaults to 10s.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// SampleRate is the fraction of traces started by the registry that are sampled, between 0 and 1. Traces started
+	// upstream follow the sampling decision of the caller. Defaults to 1 (sample all).
+	SampleRate *float64 `yaml:"samplerate,omitempty"`
+}
+
 // Database is the configuration for the registry's metadata database
 type Database struct {
 	// Enabled can be used to enable or bypass the metadata database
@@ -432,8 +1020,250 @@ type Database struct {
 	// Discovery has the required configuration parameters to find a service's host and port
 	// from a DNS server.
 	Discovery Discovery `yaml:"discovery,omitempty"`
+	// LoadBalancing configures the routing of read-only queries to replicas.
+	LoadBalancing DatabaseLoadBalancing `yaml:"loadbalancing,omitempty"`
+	// SizeRecalculation configures the background recalculation of repository and namespace sizes.
+	SizeRecalculation DatabaseSizeRecalculation `yaml:"sizerecalculation,omitempty"`
+	// BackgroundMigrations configures the processing of batched background migrations.
+	BackgroundMigrations DatabaseBackgroundMigrations `yaml:"backgroundmigrations,omitempty"`
+	// PullTracking configures the recording of the last time tags and manifests were pulled.
+	PullTracking DatabasePullTracking `yaml:"pulltracking,omitempty"`
+	// CleanupPolicies configures the background execution of repository tag cleanup policies.
+	CleanupPolicies DatabaseCleanupPolicies `yaml:"cleanuppolicies,omitempty"`
+	// MirrorFS enables writing manifests, tags and layer links to the filesystem metadata in addition to the database,
+	// so that it can be used as a fallback for reads. Defaults to false.
+	MirrorFS bool `yaml:"mirrorfs,omitempty"`
+	// CircuitBreaker configures the detection of a degraded database.
+	CircuitBreaker DatabaseCircuitBreaker `yaml:"circuitbreaker,omitempty"`
+	// UploadPurging configures the purging of abandoned blob uploads tracked in the database.
+	UploadPurging DatabaseUploadPurging `yaml:"uploadpurging,omitempty"`
+	// BlobVerification configures the background verification of the integrity of blobs in storage.
+	BlobVerification DatabaseBlobVerification `yaml:"blobverification,omitempty"`
+	// RepositoryPurges configures the background processing of repository purges.
+	RepositoryPurges DatabaseRepositoryPurges `yaml:"repositorypurges,omitempty"`
+	// NamespaceStatistics configures the recording of daily usage statistics of top-level namespaces.
+	NamespaceStatistics DatabaseNamespaceStatistics `yaml:"namespacestatistics,omitempty"`
+}
+
+// DatabaseLoadBalancing configures the routing of read-only queries to a set of replicas of the primary database.
+// Replicas share all connection settings with the primary, except for the host.
+type DatabaseLoadBalancing struct {
+	// Enabled toggles database load balancing. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Hosts is the list of replica hostnames.
+	Hosts []string `yaml:"hosts,omitempty"`
+	// ReplicaCheckInterval is how often the replication lag of replicas is checked. Defaults to 30 seconds.
+	ReplicaCheckInterval time.Duration `yaml:"replicacheckinterval,omitempty"`
+	// MaxReplicationLagBytes is the maximum amount of WAL data that a replica can lag behind the primary to be
+	// considered up to date. Defaults to 8MiB.
+	MaxReplicationLagBytes int64 `yaml:"maxreplicationlagbytes,omitempty"`
+	// MaxReplicationLagTime is the maximum amount of time that a replica can lag behind the primary to be considered up
+	// to date. Defaults to 1 minute.
+	MaxReplicationLagTime time.Duration `yaml:"maxreplicationlagtime,omitempty"`
+	// StickyDuration is how long read-only queries for a repository are routed to the primary after a write to that
+	// repository. Defaults to 30 seconds.
+	StickyDuration time.Duration `yaml:"stickyduration,omitempty"`
 }
 
+const (
+	defaultDatabaseLoadBalancingReplicaCheckInterval   = 30 * time.Second
+	defaultDatabaseLoadBalancingMaxReplicationLagBytes = 8 * 1024 * 1024
+	defaultDatabaseLoadBalancingMaxReplicationLagTime  = 1 * time.Minute
+	defaultDatabaseLoadBalancingStickyDuration         = 30 * time.Second
+)
+
+// DatabaseSizeRecalculation configures the background worker that periodically recalculates the size of repositories
+// and namespaces, detecting drifts between the sizes cached in Redis and the actual ones. Requires the Redis cache.
+type DatabaseSizeRecalculation struct {
+	// Enabled toggles the background size recalculation. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the time to wait between worker runs. Defaults to 1 minute.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// BatchSize is the maximum number of repositories processed in each worker run. Defaults to 100.
+	BatchSize int `yaml:"batchsize,omitempty"`
+}
+
+const (
+	defaultDatabaseSizeRecalculationInterval  = 1 * time.Minute
+	defaultDatabaseSizeRecalculationBatchSize = 100
+)
+
+// DatabaseBackgroundMigrations configures the background worker that processes batched background migrations, which
+// are data migrations enqueued by schema migrations and applied in small batches while the registry is online.
+type DatabaseBackgroundMigrations struct {
+	// Enabled toggles the processing of background migrations. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the time to wait between batches, used to throttle background migrations. Defaults to 1 second.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// MaxAttempts is the maximum number of consecutive failed attempts to process a batch before marking the background
+	// migration as failed. Defaults to 3.
+	MaxAttempts int `yaml:"maxattempts,omitempty"`
+	// StatementTimeout is the maximum duration of each statement executed while processing a batch. Defaults to 30
+	// seconds.
+	StatementTimeout time.Duration `yaml:"statementtimeout,omitempty"`
+}
+
+// DatabasePullTracking configures the recording of the last time tags and manifests were pulled. Pulls are throttled
+// and written to the database in batches, so the recorded timestamps are approximate.
+type DatabasePullTracking struct {
+	// Enabled toggles pull tracking. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Throttle is the minimum time between two recorded pulls of the same tag or manifest by each registry instance.
+	// Defaults to 10 minutes.
+	Throttle time.Duration `yaml:"throttle,omitempty"`
+	// FlushInterval is the time to wait between writes of recorded pulls to the database. Defaults to 30 seconds.
+	FlushInterval time.Duration `yaml:"flushinterval,omitempty"`
+	// BatchSize is the maximum number of pulls written to the database in each statement. Defaults to 500.
+	BatchSize int `yaml:"batchsize,omitempty"`
+}
+
+// DatabaseCleanupPolicies configures the background worker that executes the tag cleanup policies of repositories, as
+// set through the GitLab V1 API. Policies can be previewed through the API even if the worker is disabled.
+type DatabaseCleanupPolicies struct {
+	// Enabled toggles the execution of cleanup policies. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the time to wait between checks for due policies. Defaults to 1 minute.
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+const defaultDatabaseCleanupPoliciesInterval = 1 * time.Minute
+
+// DatabaseUploadPurging configures the background worker that purges blob uploads tracked in the database once they
+// are older than a given age, deleting their files and aborting any multipart upload left in progress on the storage
+// backend.
+type DatabaseUploadPurging struct {
+	// Enabled toggles the purging of uploads. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the time to wait between runs. Defaults to 10 minutes.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Age is the time after which an upload that has not been completed or canceled is purged. Defaults to 24 hours.
+	Age time.Duration `yaml:"age,omitempty"`
+	// BatchSize is the maximum number of uploads purged in each run. Defaults to 100.
+	BatchSize int `yaml:"batchsize,omitempty"`
+}
+
+const (
+	defaultDatabaseUploadPurgingInterval  = 10 * time.Minute
+	defaultDatabaseUploadPurgingAge       = 24 * time.Hour
+	defaultDatabaseUploadPurgingBatchSize = 100
+)
+
+// DatabaseBlobVerification configures the background worker that periodically reads a random sample of the blobs
+// tracked in the database from storage, and verifies that their content matches their digest. Blobs that fail the
+// verification are recorded and reported.
+type DatabaseBlobVerification struct {
+	// Enabled toggles the verification of blobs. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the time to wait between runs. Defaults to 1 hour.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// SampleSize is the number of blobs verified in each run. Defaults to 10.
+	SampleSize int `yaml:"samplesize,omitempty"`
+	// MaxSize is the size in bytes above which sampled blobs are skipped, to bound the amount of data read from storage
+	// in each run. Defaults to 0 (no limit).
+	MaxSize int64 `yaml:"maxsize,omitempty"`
+}
+
+const (
+	defaultDatabaseBlobVerificationInterval   = 1 * time.Hour
+	defaultDatabaseBlobVerificationSampleSize = 10
+)
+
+// DatabaseRepositoryPurges configures the background worker that processes the repository purges requested through the
+// GitLab V1 API. Each purge is processed in batches, deleting the tags, then the manifests and finally the blob links of
+// the repository, waiting for the configured interval between batches to avoid load spikes on the database.
+type DatabaseRepositoryPurges struct {
+	// Enabled toggles the processing of repository purges. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the time to wait between batches of the same purge. Defaults to 1 second.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// BatchSize is the maximum number of tags, manifests or blob links deleted in each batch. Defaults to 100.
+	BatchSize int `yaml:"batchsize,omitempty"`
+}
+
+const (
+	defaultDatabaseRepositoryPurgesInterval  = 1 * time.Second
+	defaultDatabaseRepositoryPurgesBatchSize = 100
+)
+
+// DatabaseNamespaceStatistics configures the recording of daily usage statistics (pushes, pulls, bytes added and bytes
+// reclaimed by online garbage collection) of top-level namespaces, exposed through the GitLab V1 API. Counters are
+// aggregated in memory and written to the database every flush interval.
+type DatabaseNamespaceStatistics struct {
+	// Enabled toggles the recording of namespace statistics. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// FlushInterval is the time to wait between writes of aggregated counters to the database. Defaults to 30 seconds.
+	FlushInterval time.Duration `yaml:"flushinterval,omitempty"`
+	// Retention is for how long daily statistics are kept in the database. Defaults to 90 days.
+	Retention time.Duration `yaml:"retention,omitempty"`
+}
+
+const (
+	defaultDatabaseNamespaceStatisticsFlushInterval = 30 * time.Second
+	defaultDatabaseNamespaceStatisticsRetention     = 90 * 24 * time.Hour
+)
+
+// DatabaseCircuitBreaker configures a circuit breaker that periodically checks the database and opens when it's
+// degraded. While open, read requests are served from the filesystem metadata if MirrorFS is enabled.
+type DatabaseCircuitBreaker struct {
+	// Enabled toggles the circuit breaker. Defaults to false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the time to wait between database checks. Defaults to 5 seconds.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// LatencyThreshold is the maximum duration of a check for it to be considered successful. Defaults to 1 second.
+	LatencyThreshold time.Duration `yaml:"latencythreshold,omitempty"`
+	// FailureThreshold is the number of consecutive failed checks after which the circuit breaker opens. Defaults to 3.
+	FailureThreshold int `yaml:"failurethreshold,omitempty"`
+	// RecoveryThreshold is the number of consecutive successful checks after which the circuit breaker closes again.
+	// Defaults to 3.
+	RecoveryThreshold int `yaml:"recoverythreshold,omitempty"`
+}
+
+const (
+	defaultDatabaseCircuitBreakerInterval          = 5 * time.Second
+	defaultDatabaseCircuitBreakerLatencyThreshold  = 1 * time.Second
+	defaultDatabaseCircuitBreakerFailureThreshold  = 3
+	defaultDatabaseCircuitBreakerRecoveryThreshold = 3
+)
+
+const (
+	defaultDatabasePullTrackingThrottle      = 10 * time.Minute
+	defaultDatabasePullTrackingFlushInterval = 30 * time.Second
+	defaultDatabasePullTrackingBatchSize     = 500
+)
+
+const (
+	defaultDatabaseBackgroundMigrationsInterval         = 1 * time.Second
+	defaultDatabaseBackgroundMigrationsMaxAttempts      = 3
+	defaultDatabaseBackgroundMigrationsStatementTimeout = 30 * time.Second
+)
+
+const (
+	defaultAuditSyslogTag   = "registry-audit"
+	defaultAuditHTTPTimeout = 5 * time.Second
+)
+
+const (
+	defaultReplicationTimeout   = 5 * time.Minute
+	defaultReplicationThreshold = 5
+	defaultReplicationBackoff   = 10 * time.Second
+	defaultReplicationWorkers   = 2
+	defaultReplicationQueueSize = 1000
+)
+
+const (
+	defaultProxyTTL       = 7 * 24 * time.Hour
+	defaultProxyInterval  = time.Minute
+	defaultProxyBatchSize = 100
+	defaultProxyTimeout   = 10 * time.Minute
+)
+
+const (
+	defaultTracingServiceName = "container-registry"
+	defaultTracingEndpoint    = "localhost:4318"
+	defaultTracingTimeout     = 10 * time.Second
+	defaultTracingSampleRate  = 1.0
+)
+
 // Discovery has the required configuration parameters to find a service's host and port
 // from a DNS server.
 type Discovery struct {
@@ -561,8 +1391,61 @@ type Health struct {
 		// unhealthy state
 		Threshold int `yaml:"threshold,omitempty"`
 	} `yaml:"storagedriver,omitempty"`
+	// Readiness configures the checks of the dependencies required to serve requests, reported by the readiness
+	// endpoint of the debug server.
+	Readiness Readiness `yaml:"readiness,omitempty"`
 }
 
+// Readiness configures the checks of the dependencies required to serve requests. Unlike other health checks, failing
+// readiness checks do not make the registry reject requests, they only make the readiness endpoint report it as not
+// ready, so that it's taken out of rotation. Checks are only performed for configured dependencies.
+type Readiness struct {
+	// Enabled can be set to `true` to enable the readiness checks.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the duration in between checks. Defaults to 10s.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Timeout is the maximum duration of each check. Defaults to 2s.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Threshold is the default number of consecutive failed checks after which a dependency is reported as failed.
+	// Defaults to 3.
+	Threshold int `yaml:"threshold,omitempty"`
+	// Database configures the check of the database primary.
+	Database ReadinessCheck `yaml:"database,omitempty"`
+	// Redis configures the check of the Redis instances.
+	Redis ReadinessCheck `yaml:"redis,omitempty"`
+	// StorageDriver configures the check of the storage driver.
+	StorageDriver ReadinessCheck `yaml:"storagedriver,omitempty"`
+	// Notifications configures the check of the notification endpoints backlog.
+	Notifications ReadinessNotifications `yaml:"notifications,omitempty"`
+}
+
+// ReadinessCheck configures the readiness check of a dependency.
+type ReadinessCheck struct {
+	// Disabled can be set to `true` to skip the check.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Threshold overrides the default number of consecutive failed checks after which the dependency is reported as
+	// failed.
+	Threshold int `yaml:"threshold,omitempty"`
+}
+
+// ReadinessNotifications configures the readiness check of the notification endpoints backlog.
+type ReadinessNotifications struct {
+	// Disabled can be set to `true` to skip the check.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Threshold overrides the default number of consecutive failed checks after which the backlog is reported as
+	// failed.
+	Threshold int `yaml:"threshold,omitempty"`
+	// MaxPending is the maximum number of events pending delivery to any endpoint. Defaults to 1000.
+	MaxPending int `yaml:"maxpending,omitempty"`
+}
+
+const (
+	defaultReadinessInterval                = 10 * time.Second
+	defaultReadinessTimeout                 = 2 * time.Second
+	defaultReadinessThreshold               = 3
+	defaultReadinessNotificationsMaxPending = 1000
+)
+
 // v0_1Configuration is a Version 0.1 Configuration struct
 // This is currently aliased to Configuration, as it is the current version
 type v0_1Configuration Configuration
@@ -882,6 +1765,18 @@ func (storage Storage) MarshalYAML() (interface{}, error) {
 	return map[string]Parameters(storage), nil
 }
 
+// Isolation defines the configuration for the namespace isolation mode, where the objects of each listed top-level
+// namespace are stored in a dedicated storage driver instance (e.g. a separate bucket, with its own credentials)
+// instead of the one configured in Storage, which remains in use for all other namespaces.
+type Isolation struct {
+	// Enabled enables the namespace isolation mode.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Namespaces maps top-level namespace names to the configuration of their dedicated storage driver, which follows
+	// the same format as Storage. Storage maintenance, cache, delete and redirect settings are not supported here, as
+	// those configured in Storage apply to all namespaces.
+	Namespaces map[string]Storage `yaml:"namespaces,omitempty"`
+}
+
 // Auth defines the configuration for registry authorization.
 type Auth map[string]Parameters
 
@@ -944,24 +1839,118 @@ func (auth Auth) MarshalYAML() (interface{}, error) {
 type Notifications struct {
 	// EventConfig is the configuration for the event format that is sent to each Endpoint.
 	EventConfig Events `yaml:"events,omitempty"`
-	// Endpoints is a list of http configurations for endpoints that
-	// respond to webhook notifications. In the future, we may allow other
-	// kinds of endpoints, such as external queues.
+	// Queue configures how events pending delivery to endpoints are queued.
+	Queue NotificationsQueue `yaml:"queue,omitempty"`
+	// Endpoints is a list of configurations for endpoints that respond to
+	// notifications. Events are delivered through the transport set in each
+	// endpoint's type, which defaults to http webhooks.
 	Endpoints []Endpoint `yaml:"endpoints,omitempty"`
 }
 
-// Endpoint describes the configuration of an http webhook notification
-// endpoint.
+// Notification queue types.
+const (
+	NotificationsQueueTypeMemory   = "memory"
+	NotificationsQueueTypeDatabase = "database"
+)
+
+// NotificationsQueue configures the queue of events pending delivery to each endpoint. The in-memory queue loses
+// pending events on shutdown, while the database queue persists them in the metadata database, so that they are
+// delivered after a restart or by another registry instance. Events whose delivery fails are kept for replay.
+type NotificationsQueue struct {
+	// Type is the queue type, either `memory` (default) or `database`. The latter requires the database to be enabled.
+	Type string `yaml:"type,omitempty"`
+	// MaxAttempts is the number of failed delivery attempts after which an event is marked as failed and set aside
+	// for replay. Only used by the database queue. Defaults to 10.
+	MaxAttempts int `yaml:"maxattempts,omitempty"`
+	// Retention is how long failed events are kept for replay before being deleted. Only used by the database queue.
+	// Defaults to 7 days.
+	Retention time.Duration `yaml:"retention,omitempty"`
+	// Interval is how often the database queue is checked for events queued by other registry instances, requeued
+	// for replay or left behind on shutdown. Only used by the database queue. Defaults to 5 seconds.
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+const (
+	defaultNotificationsQueueMaxAttempts = 10
+	defaultNotificationsQueueRetention   = 7 * 24 * time.Hour
+	defaultNotificationsQueueInterval    = 5 * time.Second
+)
+
+// Notification endpoint types.
+const (
+	EndpointTypeHTTP  = "http"
+	EndpointTypeKafka = "kafka"
+	EndpointTypeNATS  = "nats"
+)
+
+// Endpoint describes the configuration of a notification endpoint.
 type Endpoint struct {
-	Name              string        `yaml:"name"`              // identifies the endpoint in the registry instance.
-	Disabled          bool          `yaml:"disabled"`          // disables the endpoint
-	URL               string        `yaml:"url"`               // post url for the endpoint.
-	Headers           http.Header   `yaml:"headers"`           // static headers that should be added to all requests
-	Timeout           time.Duration `yaml:"timeout"`           // HTTP timeout
-	Threshold         int           `yaml:"threshold"`         // circuit breaker threshold before backing off on failure
-	Backoff           time.Duration `yaml:"backoff"`           // backoff duration
-	IgnoredMediaTypes []string      `yaml:"ignoredmediatypes"` // target media types to ignore
-	Ignore            Ignore        `yaml:"ignore"`            // ignore event types
+	Name              string          `yaml:"name"`              // identifies the endpoint in the registry instance.
+	Disabled          bool            `yaml:"disabled"`          // disables the endpoint
+	Type              string          `yaml:"type,omitempty"`    // transport used to deliver events, http (default), kafka or nats
+	URL               string          `yaml:"url"`               // post url for the endpoint.
+	Headers           http.Header     `yaml:"headers"`           // static headers that should be added to all requests
+	Timeout           time.Duration   `yaml:"timeout"`           // HTTP, Kafka write or NATS acknowledgement timeout
+	Threshold         int             `yaml:"threshold"`         // circuit breaker threshold before backing off on failure
+	Backoff           time.Duration   `yaml:"backoff"`           // backoff duration
+	IgnoredMediaTypes []string        `yaml:"ignoredmediatypes"` // target media types to ignore
+	Ignore            Ignore          `yaml:"ignore"`            // ignore event types
+	Kafka             KafkaEndpoint   `yaml:"kafka,omitempty"`   // kafka transport settings, used when type is kafka
+	NATS              NATSEndpoint    `yaml:"nats,omitempty"`    // nats transport settings, used when type is nats
+	TLS               EndpointTLS     `yaml:"tls,omitempty"`     // tls settings, used when type is http
+	Signing           EndpointSigning `yaml:"signing,omitempty"` // payload signing settings, used when type is http
+}
+
+// EndpointTLS specifies settings for TLS connections to an HTTP notification endpoint.
+type EndpointTLS struct {
+	// Insecure disables the verification of the endpoint certificate.
+	Insecure bool `yaml:"insecure,omitempty"`
+	// CAFile is the path to a PEM encoded CA bundle used to verify the endpoint certificate, instead of the system
+	// certificate pool.
+	CAFile string `yaml:"cafile,omitempty"`
+	// CertFile is the path to a PEM encoded client certificate, for endpoints that require mutual TLS.
+	CertFile string `yaml:"certfile,omitempty"`
+	// KeyFile is the path to the PEM encoded private key of CertFile.
+	KeyFile string `yaml:"keyfile,omitempty"`
+}
+
+// EndpointSigning configures the signing of the payload of requests to an HTTP notification endpoint, so that it can
+// verify that events were sent by the registry.
+type EndpointSigning struct {
+	// Secret is the key used to compute the HMAC-SHA256 signature of each request body. Signing is disabled if empty.
+	Secret string `yaml:"secret,omitempty"`
+	// Header is the name of the request header holding the signature. Defaults to `X-Registry-Signature-256`.
+	Header string `yaml:"header,omitempty"`
+}
+
+// KafkaEndpoint configures the delivery of notifications to a Kafka topic.
+type KafkaEndpoint struct {
+	// Brokers is the list of Kafka broker addresses, in the form host:port.
+	Brokers []string `yaml:"brokers,omitempty"`
+	// Topic is the name of the topic to which events are published.
+	Topic string `yaml:"topic,omitempty"`
+	// BatchSize is the maximum number of events published at once.
+	BatchSize int `yaml:"batchsize,omitempty"`
+	// BatchTimeout is the maximum amount of time to wait for a batch to fill up before publishing it.
+	BatchTimeout time.Duration `yaml:"batchtimeout,omitempty"`
+	// TLS enables TLS for connections to the brokers.
+	TLS bool `yaml:"tls,omitempty"`
+}
+
+// NATSEndpoint configures the delivery of notifications to a NATS JetStream stream.
+type NATSEndpoint struct {
+	// Servers is the list of NATS server URLs, e.g. `nats://nats.example.com:4222`.
+	Servers []string `yaml:"servers,omitempty"`
+	// Subject is a Go template used to build the subject to which each event is published. The template is rendered
+	// against the event, e.g. `registry.events.{{ .Action }}`.
+	Subject string `yaml:"subject,omitempty"`
+	// MaxPending is the maximum number of published events waiting for an acknowledgement from the server. When this
+	// limit is reached, delivery is paused and events remain queued in memory.
+	MaxPending int `yaml:"maxpending,omitempty"`
+	// CredentialsFile is the path to a NATS user credentials file.
+	CredentialsFile string `yaml:"credentialsfile,omitempty"`
+	// TLS enables TLS for connections to the servers.
+	TLS bool `yaml:"tls,omitempty"`
 }
 
 // Events configures notification events.
@@ -1086,9 +2075,220 @@ func ApplyDefaults(config *Configuration) {
 	if config.HTTP.Debug.Prometheus.Enabled && config.HTTP.Debug.Prometheus.Path == "" {
 		config.HTTP.Debug.Prometheus.Path = "/metrics"
 	}
+	if pr := &config.HTTP.Debug.Prometheus.Repositories; pr.Enabled {
+		if pr.Limit == 0 {
+			pr.Limit = defaultPrometheusRepositoriesLimit
+		}
+		if pr.HalfLife == 0 {
+			pr.HalfLife = defaultPrometheusRepositoriesHalfLife
+		}
+	}
 	if config.Redis.Addr != "" && config.Redis.Pool.Size == 0 {
 		config.Redis.Pool.Size = 10
 	}
+	if config.Database.LoadBalancing.Enabled {
+		lb := &config.Database.LoadBalancing
+		if lb.ReplicaCheckInterval == 0 {
+			lb.ReplicaCheckInterval = defaultDatabaseLoadBalancingReplicaCheckInterval
+		}
+		if lb.MaxReplicationLagBytes == 0 {
+			lb.MaxReplicationLagBytes = defaultDatabaseLoadBalancingMaxReplicationLagBytes
+		}
+		if lb.MaxReplicationLagTime == 0 {
+			lb.MaxReplicationLagTime = defaultDatabaseLoadBalancingMaxReplicationLagTime
+		}
+		if lb.StickyDuration == 0 {
+			lb.StickyDuration = defaultDatabaseLoadBalancingStickyDuration
+		}
+	}
+	if config.Database.SizeRecalculation.Enabled {
+		sr := &config.Database.SizeRecalculation
+		if sr.Interval == 0 {
+			sr.Interval = defaultDatabaseSizeRecalculationInterval
+		}
+		if sr.BatchSize == 0 {
+			sr.BatchSize = defaultDatabaseSizeRecalculationBatchSize
+		}
+	}
+	if config.Database.BackgroundMigrations.Enabled {
+		bm := &config.Database.BackgroundMigrations
+		if bm.Interval == 0 {
+			bm.Interval = defaultDatabaseBackgroundMigrationsInterval
+		}
+		if bm.MaxAttempts == 0 {
+			bm.MaxAttempts = defaultDatabaseBackgroundMigrationsMaxAttempts
+		}
+		if bm.StatementTimeout == 0 {
+			bm.StatementTimeout = defaultDatabaseBackgroundMigrationsStatementTimeout
+		}
+	}
+	if config.Database.PullTracking.Enabled {
+		pt := &config.Database.PullTracking
+		if pt.Throttle == 0 {
+			pt.Throttle = defaultDatabasePullTrackingThrottle
+		}
+		if pt.FlushInterval == 0 {
+			pt.FlushInterval = defaultDatabasePullTrackingFlushInterval
+		}
+		if pt.BatchSize == 0 {
+			pt.BatchSize = defaultDatabasePullTrackingBatchSize
+		}
+	}
+	if config.Database.CleanupPolicies.Enabled && config.Database.CleanupPolicies.Interval == 0 {
+		config.Database.CleanupPolicies.Interval = defaultDatabaseCleanupPoliciesInterval
+	}
+	if config.Database.UploadPurging.Enabled {
+		up := &config.Database.UploadPurging
+		if up.Interval == 0 {
+			up.Interval = defaultDatabaseUploadPurgingInterval
+		}
+		if up.Age == 0 {
+			up.Age = defaultDatabaseUploadPurgingAge
+		}
+		if up.BatchSize == 0 {
+			up.BatchSize = defaultDatabaseUploadPurgingBatchSize
+		}
+	}
+	if config.Database.BlobVerification.Enabled {
+		bv := &config.Database.BlobVerification
+		if bv.Interval == 0 {
+			bv.Interval = defaultDatabaseBlobVerificationInterval
+		}
+		if bv.SampleSize == 0 {
+			bv.SampleSize = defaultDatabaseBlobVerificationSampleSize
+		}
+	}
+	if config.Database.RepositoryPurges.Enabled {
+		rp := &config.Database.RepositoryPurges
+		if rp.Interval == 0 {
+			rp.Interval = defaultDatabaseRepositoryPurgesInterval
+		}
+		if rp.BatchSize == 0 {
+			rp.BatchSize = defaultDatabaseRepositoryPurgesBatchSize
+		}
+	}
+	if config.Database.NamespaceStatistics.Enabled {
+		ns := &config.Database.NamespaceStatistics
+		if ns.FlushInterval == 0 {
+			ns.FlushInterval = defaultDatabaseNamespaceStatisticsFlushInterval
+		}
+		if ns.Retention == 0 {
+			ns.Retention = defaultDatabaseNamespaceStatisticsRetention
+		}
+	}
+	if config.Notifications.Queue.Type == NotificationsQueueTypeDatabase {
+		q := &config.Notifications.Queue
+		if q.MaxAttempts == 0 {
+			q.MaxAttempts = defaultNotificationsQueueMaxAttempts
+		}
+		if q.Retention == 0 {
+			q.Retention = defaultNotificationsQueueRetention
+		}
+		if q.Interval == 0 {
+			q.Interval = defaultNotificationsQueueInterval
+		}
+	}
+	if config.Database.CircuitBreaker.Enabled {
+		cb := &config.Database.CircuitBreaker
+		if cb.Interval == 0 {
+			cb.Interval = defaultDatabaseCircuitBreakerInterval
+		}
+		if cb.LatencyThreshold == 0 {
+			cb.LatencyThreshold = defaultDatabaseCircuitBreakerLatencyThreshold
+		}
+		if cb.FailureThreshold == 0 {
+			cb.FailureThreshold = defaultDatabaseCircuitBreakerFailureThreshold
+		}
+		if cb.RecoveryThreshold == 0 {
+			cb.RecoveryThreshold = defaultDatabaseCircuitBreakerRecoveryThreshold
+		}
+	}
+	if config.Audit.Enabled {
+		if config.Audit.Syslog.Tag == "" {
+			config.Audit.Syslog.Tag = defaultAuditSyslogTag
+		}
+		if config.Audit.HTTP.Timeout == 0 {
+			config.Audit.HTTP.Timeout = defaultAuditHTTPTimeout
+		}
+	}
+	for i := range config.Replication.Targets {
+		rt := &config.Replication.Targets[i]
+		if rt.Timeout == 0 {
+			rt.Timeout = defaultReplicationTimeout
+		}
+		if rt.Threshold == 0 {
+			rt.Threshold = defaultReplicationThreshold
+		}
+		if rt.Backoff == 0 {
+			rt.Backoff = defaultReplicationBackoff
+		}
+		if rt.Workers == 0 {
+			rt.Workers = defaultReplicationWorkers
+		}
+		if rt.QueueSize == 0 {
+			rt.QueueSize = defaultReplicationQueueSize
+		}
+	}
+	if p := &config.Proxy; p.Enabled() {
+		if p.TTL == 0 {
+			p.TTL = defaultProxyTTL
+		}
+		if p.Interval == 0 {
+			p.Interval = defaultProxyInterval
+		}
+		if p.BatchSize == 0 {
+			p.BatchSize = defaultProxyBatchSize
+		}
+		if p.Timeout == 0 {
+			p.Timeout = defaultProxyTimeout
+		}
+	}
+	for i := range config.Policy.Repository.Signatures {
+		if r := &config.Policy.Repository.Signatures[i]; r.Mode == "" {
+			r.Mode = SignatureModeEnforce
+		}
+	}
+	if r := &config.Health.Readiness; r.Enabled {
+		if r.Interval == 0 {
+			r.Interval = defaultReadinessInterval
+		}
+		if r.Timeout == 0 {
+			r.Timeout = defaultReadinessTimeout
+		}
+		if r.Threshold == 0 {
+			r.Threshold = defaultReadinessThreshold
+		}
+		if r.Notifications.MaxPending == 0 {
+			r.Notifications.MaxPending = defaultReadinessNotificationsMaxPending
+		}
+	}
+	if config.Tracing.Enabled {
+		if config.Tracing.ServiceName == "" {
+			config.Tracing.ServiceName = defaultTracingServiceName
+		}
+		if config.Tracing.Endpoint == "" {
+			config.Tracing.Endpoint = defaultTracingEndpoint
+		}
+		if config.Tracing.Timeout == 0 {
+			config.Tracing.Timeout = defaultTracingTimeout
+		}
+		if config.Tracing.SampleRate == nil {
+			rate := defaultTracingSampleRate
+			config.Tracing.SampleRate = &rate
+		}
+	}
+	if rv := &config.Validation.Manifests.ReferenceVerification; rv.Concurrency > 1 && rv.Timeout == 0 {
+		rv.Timeout = defaultManifestReferenceVerificationTimeout
+	}
+	if bm := &config.Validation.Manifests.BlobMount; bm.Enabled && bm.Concurrency <= 0 {
+		bm.Concurrency = defaultManifestBlobMountConcurrency
+	}
+	if config.Redis.Cache.Manifests.Enabled && config.Redis.Cache.Manifests.TTL == 0 {
+		config.Redis.Cache.Manifests.TTL = defaultRedisCacheManifestsTTL
+	}
+	if config.Redis.Cache.UploadSessions.Enabled && config.Redis.Cache.UploadSessions.TTL == 0 {
+		config.Redis.Cache.UploadSessions.TTL = defaultRedisCacheUploadSessionsTTL
+	}
 
 	// copy TLS config to debug server when enabled and debug TLS certificate is empty
 	if config.HTTP.Debug.TLS.Enabled {
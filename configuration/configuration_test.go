@@ -95,21 +95,27 @@ var configStruct = Configuration{
 		},
 	},
 	HTTP: struct {
-		Addr         string        `yaml:"addr,omitempty"`
-		Net          string        `yaml:"net,omitempty"`
-		Host         string        `yaml:"host,omitempty"`
-		Prefix       string        `yaml:"prefix,omitempty"`
-		Secret       string        `yaml:"secret,omitempty"`
-		RelativeURLs bool          `yaml:"relativeurls,omitempty"`
-		DrainTimeout time.Duration `yaml:"draintimeout,omitempty"`
-		TLS          TLS           `yaml:"tls,omitempty"`
-		Headers      http.Header   `yaml:"headers,omitempty"`
-		Debug        struct {
+		Addr           string        `yaml:"addr,omitempty"`
+		Net            string        `yaml:"net,omitempty"`
+		Host           string        `yaml:"host,omitempty"`
+		Prefix         string        `yaml:"prefix,omitempty"`
+		Secret         string        `yaml:"secret,omitempty"`
+		RelativeURLs   bool          `yaml:"relativeurls,omitempty"`
+		DrainTimeout   time.Duration `yaml:"draintimeout,omitempty"`
+		Deadlines      Deadlines     `yaml:"deadlines,omitempty"`
+		RequestLimits  RequestLimits `yaml:"requestlimits,omitempty"`
+		TrustedProxies []string      `yaml:"trustedproxies,omitempty"`
+		CORS           CORS          `yaml:"cors,omitempty"`
+		TLS            TLS           `yaml:"tls,omitempty"`
+		Listeners      []Listener    `yaml:"listeners,omitempty"`
+		Headers        http.Header   `yaml:"headers,omitempty"`
+		Debug          struct {
 			Addr       string   `yaml:"addr,omitempty"`
 			TLS        DebugTLS `yaml:"tls,omitempty"`
 			Prometheus struct {
-				Enabled bool   `yaml:"enabled,omitempty"`
-				Path    string `yaml:"path,omitempty"`
+				Enabled      bool                   `yaml:"enabled,omitempty"`
+				Path         string                 `yaml:"path,omitempty"`
+				Repositories PrometheusRepositories `yaml:"repositories,omitempty"`
 			} `yaml:"prometheus,omitempty"`
 			Pprof struct {
 				Enabled bool `yaml:"enabled,omitempty"`
@@ -885,6 +891,151 @@ http:
 	testParameter(t, yml, "REGISTRY_HTTP_DEBUG_TLS_CLIENTCAS", tt, validator)
 }
 
+func TestParseHTTPListeners(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  listeners:
+    - net: tcp6
+      addr: "[::]:5000"
+      tls:
+        certificate: /path/to/cert.pem
+        key: /path/to/key.pem
+        minimumtls: tls1.3
+    - net: unix
+      addr: /run/registry/registry.sock
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := []Listener{
+		{
+			Net:  "tcp6",
+			Addr: "[::]:5000",
+			TLS:  TLS{Certificate: "/path/to/cert.pem", Key: "/path/to/key.pem", MinimumTLS: "tls1.3"},
+		},
+		{Net: "unix", Addr: "/run/registry/registry.sock"},
+	}
+	require.Equal(t, want, config.HTTP.Listeners)
+}
+
+func TestParseHTTPDeadlines(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  deadlines:
+    default: 2m
+    rules:
+      - route: manifest
+        methods: [GET, HEAD]
+        deadline: 10s
+      - route: blob
+        deadline: 0s
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := Deadlines{
+		Default: 2 * time.Minute,
+		Rules: []DeadlineRule{
+			{Route: "manifest", Methods: []string{"GET", "HEAD"}, Deadline: 10 * time.Second},
+			{Route: "blob"},
+		},
+	}
+	require.Equal(t, want, config.HTTP.Deadlines)
+}
+
+func TestParseHTTPRequestLimits(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  requestlimits:
+    readheadertimeout: 10s
+    maxbodysize: 1048576
+    bodytimeouts:
+      blob: 1h
+      api: 30s
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := RequestLimits{
+		ReadHeaderTimeout: 10 * time.Second,
+		MaxBodySize:       1 << 20,
+		BodyTimeouts:      BodyTimeouts{Blob: time.Hour, API: 30 * time.Second},
+	}
+	require.Equal(t, want, config.HTTP.RequestLimits)
+}
+
+func TestParseHTTPTrustedProxies(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  trustedproxies:
+    - 127.0.0.0/8
+    - 10.0.0.0/8
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.0/8", "10.0.0.0/8"}, config.HTTP.TrustedProxies)
+}
+
+func TestParseHTTPCORS(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  cors:
+    gitlab:
+      enabled: true
+      allowedorigins: [https://ui.example.com]
+      allowedmethods: [GET, DELETE]
+      exposedheaders: [Link]
+      allowcredentials: true
+      maxage: 10m
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := CORS{
+		GitLab: CORSPolicy{
+			Enabled:          true,
+			AllowedOrigins:   []string{"https://ui.example.com"},
+			AllowedMethods:   []string{"GET", "DELETE"},
+			ExposedHeaders:   []string{"Link"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+	}
+	require.Equal(t, want, config.HTTP.CORS)
+}
+
+func TestParseGRPC(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+grpc:
+  addr: localhost:5002
+  tls:
+    certificate: /path/to/cert.pem
+    key: /path/to/key.pem
+    clientcas:
+      - /path/to/ca.pem
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := GRPC{
+		Addr: "localhost:5002",
+		TLS:  TLS{Certificate: "/path/to/cert.pem", Key: "/path/to/key.pem", ClientCAs: []string{"/path/to/ca.pem"}},
+	}
+	require.Equal(t, want, config.GRPC)
+}
+
 func TestParseHTTPMonitoringStackdriverEnabled(t *testing.T) {
 	yml := `
 version: 0.1
@@ -1101,6 +1252,181 @@ redis:
 	testParameter(t, yml, "REGISTRY_REDIS_MAINNAME", tt, validator)
 }
 
+func TestParseRedis_Username(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  username: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "foo",
+			want:  "foo",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Username)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_USERNAME", tt, validator)
+}
+
+func TestParseRedis_SentinelUsername(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  sentinelusername: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "foo",
+			want:  "foo",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.SentinelUsername)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_SENTINELUSERNAME", tt, validator)
+}
+
+func TestParseRedis_SentinelPassword(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  sentinelpassword: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "foo",
+			want:  "foo",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.SentinelPassword)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_SENTINELPASSWORD", tt, validator)
+}
+
+func TestParseRedis_Cluster(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cluster: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.Cluster))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CLUSTER", tt, validator)
+}
+
+func TestParseRedis_TLS_CAFile(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  tls:
+    cafile: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "/path/to/file.pem",
+			want:  "/path/to/file.pem",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.TLS.CAFile)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_TLS_CAFILE", tt, validator)
+}
+
+func TestParseRedis_TLS_CertFile(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  tls:
+    certfile: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "/path/to/file.pem",
+			want:  "/path/to/file.pem",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.TLS.CertFile)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_TLS_CERTFILE", tt, validator)
+}
+
+func TestParseRedis_TLS_KeyFile(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  tls:
+    keyfile: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "/path/to/file.pem",
+			want:  "/path/to/file.pem",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.TLS.KeyFile)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_TLS_KEYFILE", tt, validator)
+}
+
 func TestParseRedisPool_MaxOpen(t *testing.T) {
 	yml := `
 version: 0.1
@@ -1328,459 +1654,1562 @@ database:
 	testParameter(t, yml, "REGISTRY_DATABASE_DRAINTIMEOUT", tt, validator)
 }
 
-func TestParseDatabasePool_MaxIdle(t *testing.T) {
+func TestParseDatabaseLoadBalancing_Enabled(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
 database:
-  pool:
-    maxidle: %s
+  loadbalancing:
+    enabled: %s
 `
-	tt := []parameterTest{
-		{
-			name:  "sample",
-			value: "10",
-			want:  10,
-		},
-		{
-			name: "default",
-			want: 0,
-		},
-	}
+	tt := boolParameterTests(false)
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Database.Pool.MaxIdle)
+		require.Equal(t, want, strconv.FormatBool(got.Database.LoadBalancing.Enabled))
 	}
 
-	testParameter(t, yml, "REGISTRY_DATABASE_POOL_MAXIDLE", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_LOADBALANCING_ENABLED", tt, validator)
 }
 
-func TestParseDatabasePool_MaxOpen(t *testing.T) {
+func TestParseDatabaseLoadBalancing_Hosts(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
 database:
-  pool:
-    maxopen: %s
+  loadbalancing:
+    enabled: true
+    hosts: %s
 `
 	tt := []parameterTest{
 		{
-			name:  "sample",
-			value: "10",
-			want:  10,
+			name:  "slice",
+			value: `["replica1.example.com", "replica2.example.com"]`,
+			want:  []string{"replica1.example.com", "replica2.example.com"},
+		},
+		{
+			name:  "empty",
+			value: "[]",
+			want:  []string{},
 		},
 		{
 			name: "default",
-			want: 0,
+			want: nil,
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Database.Pool.MaxOpen)
+		require.ElementsMatch(t, want, got.Database.LoadBalancing.Hosts)
 	}
 
-	testParameter(t, yml, "REGISTRY_DATABASE_POOL_MAXOPEN", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_LOADBALANCING_HOSTS", tt, validator)
 }
 
-func TestParseDatabasePool_MaxLifetime(t *testing.T) {
+func TestParseDatabaseLoadBalancing_Defaults(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
 database:
-  pool:
-    maxlifetime: %s
+  loadbalancing:
+    enabled: true
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	lb := config.Database.LoadBalancing
+	require.Equal(t, defaultDatabaseLoadBalancingReplicaCheckInterval, lb.ReplicaCheckInterval)
+	require.Equal(t, int64(defaultDatabaseLoadBalancingMaxReplicationLagBytes), lb.MaxReplicationLagBytes)
+	require.Equal(t, defaultDatabaseLoadBalancingMaxReplicationLagTime, lb.MaxReplicationLagTime)
+	require.Equal(t, defaultDatabaseLoadBalancingStickyDuration, lb.StickyDuration)
+}
+
+func TestParseDatabaseLoadBalancing_StickyDuration(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  loadbalancing:
+    enabled: true
+    stickyduration: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "10s",
-			want:  10 * time.Second,
+			value: "1m",
+			want:  1 * time.Minute,
 		},
 		{
 			name: "default",
-			want: time.Duration(0),
+			want: defaultDatabaseLoadBalancingStickyDuration,
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Database.Pool.MaxLifetime)
+		require.Equal(t, want, got.Database.LoadBalancing.StickyDuration)
 	}
 
-	testParameter(t, yml, "REGISTRY_DATABASE_POOL_MAXLIFETIME", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_LOADBALANCING_STICKYDURATION", tt, validator)
 }
 
-func TestParseReportingSentry_Enabled(t *testing.T) {
+func TestParseDatabaseSizeRecalculation_Enabled(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-reporting:
-  sentry:
+database:
+  sizerecalculation:
     enabled: %s
 `
 	tt := boolParameterTests(false)
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, strconv.FormatBool(got.Reporting.Sentry.Enabled))
+		require.Equal(t, want, strconv.FormatBool(got.Database.SizeRecalculation.Enabled))
 	}
 
-	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_ENABLED", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_SIZERECALCULATION_ENABLED", tt, validator)
 }
 
-func TestParseReportingSentry_DSN(t *testing.T) {
+func TestParseDatabaseSizeRecalculation_Interval(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-reporting:
-  sentry:
-    dsn: %s
+database:
+  sizerecalculation:
+    enabled: true
+    interval: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "https://examplePublicKey@o0.ingest.sentry.io/0",
-			want:  "https://examplePublicKey@o0.ingest.sentry.io/0",
+			value: "10m",
+			want:  10 * time.Minute,
 		},
 		{
 			name: "default",
-			want: "",
+			want: defaultDatabaseSizeRecalculationInterval,
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Reporting.Sentry.DSN)
+		require.Equal(t, want, got.Database.SizeRecalculation.Interval)
 	}
 
-	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_DSN", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_SIZERECALCULATION_INTERVAL", tt, validator)
 }
 
-func TestParseReportingSentry_Environment(t *testing.T) {
+func TestParseDatabaseSizeRecalculation_BatchSize(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-reporting:
-  sentry:
-    environment: %s
+database:
+  sizerecalculation:
+    enabled: true
+    batchsize: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "development",
-			want:  "development",
+			value: "500",
+			want:  "500",
 		},
 		{
 			name: "default",
-			want: "",
+			want: strconv.Itoa(defaultDatabaseSizeRecalculationBatchSize),
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Reporting.Sentry.Environment)
+		require.Equal(t, want, strconv.Itoa(got.Database.SizeRecalculation.BatchSize))
 	}
 
-	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_ENVIRONMENT", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_SIZERECALCULATION_BATCHSIZE", tt, validator)
 }
 
-func checkStructs(c *C, t reflect.Type, structsChecked map[string]struct{}) {
-	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Map || t.Kind() == reflect.Slice {
-		t = t.Elem()
-	}
-
-	if t.Kind() != reflect.Struct {
-		return
-	}
-	if _, present := structsChecked[t.String()]; present {
-		// Already checked this type
-		return
-	}
-
-	structsChecked[t.String()] = struct{}{}
-
-	byUpperCase := make(map[string]int)
-	for i := 0; i < t.NumField(); i++ {
-		sf := t.Field(i)
-
-		// Check that the yaml tag does not contain an _.
-		yamlTag := sf.Tag.Get("yaml")
-		if strings.Contains(yamlTag, "_") {
-			c.Fatalf("yaml field name includes _ character: %s", yamlTag)
-		}
-		upper := strings.ToUpper(sf.Name)
-		if _, present := byUpperCase[upper]; present {
-			c.Fatalf("field name collision in configuration object: %s", sf.Name)
-		}
-		byUpperCase[upper] = i
+func TestParseDatabaseBackgroundMigrations_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  backgroundmigrations:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
 
-		checkStructs(c, sf.Type, structsChecked)
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Database.BackgroundMigrations.Enabled))
 	}
-}
 
-// TestValidateConfigStruct makes sure that the config struct has no members
-// with yaml tags that would be ambiguous to the environment variable parser.
-func (suite *ConfigSuite) TestValidateConfigStruct(c *C) {
-	structsChecked := make(map[string]struct{})
-	checkStructs(c, reflect.TypeOf(Configuration{}), structsChecked)
+	testParameter(t, yml, "REGISTRY_DATABASE_BACKGROUNDMIGRATIONS_ENABLED", tt, validator)
 }
 
-func copyConfig(config Configuration) *Configuration {
-	configCopy := new(Configuration)
-
-	configCopy.Version = MajorMinorVersion(config.Version.Major(), config.Version.Minor())
-	configCopy.Loglevel = config.Loglevel
-	configCopy.Log = config.Log
-	configCopy.Log.Fields = make(map[string]interface{}, len(config.Log.Fields))
-	for k, v := range config.Log.Fields {
-		configCopy.Log.Fields[k] = v
-	}
-
-	configCopy.Storage = Storage{config.Storage.Type(): Parameters{}}
-	for k, v := range config.Storage.Parameters() {
-		configCopy.Storage.setParameter(k, v)
-	}
-
-	configCopy.Database = config.Database
-
-	configCopy.Reporting = Reporting{
-		Sentry: SentryReporting{config.Reporting.Sentry.Enabled, config.Reporting.Sentry.DSN, config.Reporting.Sentry.Environment},
-	}
-
-	configCopy.Auth = Auth{config.Auth.Type(): Parameters{}}
-	for k, v := range config.Auth.Parameters() {
-		configCopy.Auth.setParameter(k, v)
-	}
-
-	configCopy.Notifications = Notifications{Endpoints: []Endpoint{}}
-	for _, v := range config.Notifications.Endpoints {
-		configCopy.Notifications.Endpoints = append(configCopy.Notifications.Endpoints, v)
+func TestParseDatabaseBackgroundMigrations_Interval(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  backgroundmigrations:
+    enabled: true
+    interval: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "500ms",
+			want:  500 * time.Millisecond,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseBackgroundMigrationsInterval,
+		},
 	}
 
-	configCopy.HTTP.Headers = make(http.Header)
-	for k, v := range config.HTTP.Headers {
-		configCopy.HTTP.Headers[k] = v
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.BackgroundMigrations.Interval)
 	}
 
-	return configCopy
+	testParameter(t, yml, "REGISTRY_DATABASE_BACKGROUNDMIGRATIONS_INTERVAL", tt, validator)
 }
 
-func TestParseValidation_Manifests_PayloadSizeLimit(t *testing.T) {
+func TestParseDatabaseBackgroundMigrations_MaxAttempts(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-validation:
-  manifests:
-        payloadsizelimit: %s
+database:
+  backgroundmigrations:
+    enabled: true
+    maxattempts: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "10",
-			want:  10,
+			value: "5",
+			want:  "5",
 		},
 		{
 			name: "default",
-			want: 0,
+			want: strconv.Itoa(defaultDatabaseBackgroundMigrationsMaxAttempts),
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Validation.Manifests.PayloadSizeLimit)
+		require.Equal(t, want, strconv.Itoa(got.Database.BackgroundMigrations.MaxAttempts))
 	}
 
-	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_PAYLOADSIZELIMIT", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_BACKGROUNDMIGRATIONS_MAXATTEMPTS", tt, validator)
 }
 
-func TestParseRedisCache_Enabled(t *testing.T) {
+func TestParseDatabaseBackgroundMigrations_StatementTimeout(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-redis:
-  cache:
-    enabled: %s
+database:
+  backgroundmigrations:
+    enabled: true
+    statementtimeout: %s
 `
-	tt := boolParameterTests(false)
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "2m",
+			want:  2 * time.Minute,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseBackgroundMigrationsStatementTimeout,
+		},
+	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.Enabled))
+		require.Equal(t, want, got.Database.BackgroundMigrations.StatementTimeout)
 	}
 
-	testParameter(t, yml, "REGISTRY_REDIS_CACHE_ENABLED", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_BACKGROUNDMIGRATIONS_STATEMENTTIMEOUT", tt, validator)
 }
 
-func TestParseRedisCache_TLS_Enabled(t *testing.T) {
+func TestParseDatabasePullTracking_Throttle(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-redis:
-  cache:
+database:
+  pulltracking:
     enabled: true
-    tls:
-      enabled: %s
+    throttle: %s
 `
-	tt := boolParameterTests(false)
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1h",
+			want:  time.Hour,
+		},
+		{
+			name: "default",
+			want: defaultDatabasePullTrackingThrottle,
+		},
+	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.TLS.Enabled))
+		require.Equal(t, want, got.Database.PullTracking.Throttle)
 	}
 
-	testParameter(t, yml, "REGISTRY_REDIS_CACHE_TLS_ENABLED", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_PULLTRACKING_THROTTLE", tt, validator)
 }
 
-func TestParseRedisCache_TLS_Insecure(t *testing.T) {
+func TestParseDatabasePullTracking_BatchSize(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-redis:
-  cache:
+database:
+  pulltracking:
     enabled: true
-    tls:
-      insecure: %s
+    batchsize: %s
 `
-	tt := boolParameterTests(false)
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "100",
+			want:  "100",
+		},
+		{
+			name: "default",
+			want: strconv.Itoa(defaultDatabasePullTrackingBatchSize),
+		},
+	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.TLS.Insecure))
+		require.Equal(t, want, strconv.Itoa(got.Database.PullTracking.BatchSize))
 	}
 
-	testParameter(t, yml, "REGISTRY_REDIS_CACHE_TLS_INSECURE", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_PULLTRACKING_BATCHSIZE", tt, validator)
 }
 
-func TestParseRedisCache_Addr(t *testing.T) {
+func TestParseDatabaseBlobVerification_Interval(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-redis:
-  cache:
+database:
+  blobverification:
     enabled: true
-    addr: %s
+    interval: %s
 `
 	tt := []parameterTest{
 		{
-			name:  "single",
-			value: "0.0.0.0:6379",
-			want:  "0.0.0.0:6379",
-		},
-		{
-			name:  "multiple",
-			value: "0.0.0.0:16379,0.0.0.0:26379",
-			want:  "0.0.0.0:16379,0.0.0.0:26379",
+			name:  "sample",
+			value: "10m",
+			want:  10 * time.Minute,
 		},
 		{
 			name: "default",
-			want: "",
+			want: defaultDatabaseBlobVerificationInterval,
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Redis.Cache.Addr)
+		require.Equal(t, want, got.Database.BlobVerification.Interval)
 	}
 
-	testParameter(t, yml, "REGISTRY_REDIS_CACHE_ADDR", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_BLOBVERIFICATION_INTERVAL", tt, validator)
 }
 
-func TestParseRedisCache_MainName(t *testing.T) {
+func TestParseDatabaseBlobVerification_SampleSize(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-redis:
-  cache:
+database:
+  blobverification:
     enabled: true
-    mainname: %s
+    samplesize: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "myredismainserver",
-			want:  "myredismainserver",
+			value: "50",
+			want:  "50",
 		},
 		{
 			name: "default",
-			want: "",
+			want: strconv.Itoa(defaultDatabaseBlobVerificationSampleSize),
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Redis.Cache.MainName)
+		require.Equal(t, want, strconv.Itoa(got.Database.BlobVerification.SampleSize))
 	}
 
-	testParameter(t, yml, "REGISTRY_REDIS_CACHE_MAINNAME", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_BLOBVERIFICATION_SAMPLESIZE", tt, validator)
 }
 
-func TestParseRedisCache_Pool_MaxOpen(t *testing.T) {
+func TestParseDatabaseCleanupPolicies_Interval(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-redis:
-  cache:
+database:
+  cleanuppolicies:
     enabled: true
-    pool:
-      size: %s
+    interval: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "10",
-			want:  10,
+			value: "5m",
+			want:  5 * time.Minute,
 		},
 		{
-			name: "empty",
-			want: 0,
+			name: "default",
+			want: defaultDatabaseCleanupPoliciesInterval,
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Redis.Cache.Pool.Size)
+		require.Equal(t, want, got.Database.CleanupPolicies.Interval)
 	}
 
-	testParameter(t, yml, "REGISTRY_REDIS_CACHE_POOL_SIZE", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_CLEANUPPOLICIES_INTERVAL", tt, validator)
 }
 
-func TestParseRedisCache_Pool_MaxLifeTime(t *testing.T) {
+func TestParseDatabase_MirrorFS(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-redis:
-  cache:
+database:
+  mirrorfs: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Database.MirrorFS))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_MIRRORFS", tt, validator)
+}
+
+func TestParseDatabaseUploadPurging_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  uploadpurging:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Database.UploadPurging.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_UPLOADPURGING_ENABLED", tt, validator)
+}
+
+func TestParseDatabaseUploadPurging_Interval(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  uploadpurging:
     enabled: true
-    pool:
-      maxlifetime: %s
+    interval: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
 			value: "1h",
-			want:  1 * time.Hour,
+			want:  time.Hour,
 		},
 		{
-			name: "empty",
-			want: time.Duration(0),
+			name: "default",
+			want: defaultDatabaseUploadPurgingInterval,
 		},
 	}
 
 	validator := func(t *testing.T, want interface{}, got *Configuration) {
-		require.Equal(t, want, got.Redis.Cache.Pool.MaxLifetime)
+		require.Equal(t, want, got.Database.UploadPurging.Interval)
 	}
 
-	testParameter(t, yml, "REGISTRY_REDIS_CACHE_POOL_MAXLIFETIME", tt, validator)
+	testParameter(t, yml, "REGISTRY_DATABASE_UPLOADPURGING_INTERVAL", tt, validator)
 }
 
-func TestParseRedisCache_Pool_IdleTimeout(t *testing.T) {
+func TestParseDatabaseUploadPurging_Age(t *testing.T) {
 	yml := `
 version: 0.1
 storage: inmemory
-redis:
-  cache:
+database:
+  uploadpurging:
     enabled: true
-    pool:
-      idletimeout: %s
+    age: %s
 `
 	tt := []parameterTest{
 		{
 			name:  "sample",
-			value: "300s",
-			want:  300 * time.Second,
+			value: "72h",
+			want:  72 * time.Hour,
 		},
 		{
-			name: "empty",
-			want: time.Duration(0),
+			name: "default",
+			want: defaultDatabaseUploadPurgingAge,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.UploadPurging.Age)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_UPLOADPURGING_AGE", tt, validator)
+}
+
+func TestParseDatabaseUploadPurging_BatchSize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  uploadpurging:
+    enabled: true
+    batchsize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "500",
+			want:  500,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseUploadPurgingBatchSize,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.UploadPurging.BatchSize)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_UPLOADPURGING_BATCHSIZE", tt, validator)
+}
+
+func TestParseDatabaseRepositoryPurges_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  repositorypurges:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Database.RepositoryPurges.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_REPOSITORYPURGES_ENABLED", tt, validator)
+}
+
+func TestParseDatabaseRepositoryPurges_Interval(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  repositorypurges:
+    enabled: true
+    interval: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "500ms",
+			want:  500 * time.Millisecond,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseRepositoryPurgesInterval,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.RepositoryPurges.Interval)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_REPOSITORYPURGES_INTERVAL", tt, validator)
+}
+
+func TestParseDatabaseRepositoryPurges_BatchSize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  repositorypurges:
+    enabled: true
+    batchsize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "50",
+			want:  50,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseRepositoryPurgesBatchSize,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.RepositoryPurges.BatchSize)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_REPOSITORYPURGES_BATCHSIZE", tt, validator)
+}
+
+func TestParseDatabaseNamespaceStatistics_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  namespacestatistics:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Database.NamespaceStatistics.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_NAMESPACESTATISTICS_ENABLED", tt, validator)
+}
+
+func TestParseDatabaseNamespaceStatistics_FlushInterval(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  namespacestatistics:
+    enabled: true
+    flushinterval: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1m",
+			want:  time.Minute,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseNamespaceStatisticsFlushInterval,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.NamespaceStatistics.FlushInterval)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_NAMESPACESTATISTICS_FLUSHINTERVAL", tt, validator)
+}
+
+func TestParseDatabaseNamespaceStatistics_Retention(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  namespacestatistics:
+    enabled: true
+    retention: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "720h",
+			want:  720 * time.Hour,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseNamespaceStatisticsRetention,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.NamespaceStatistics.Retention)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_NAMESPACESTATISTICS_RETENTION", tt, validator)
+}
+
+func TestParseCompatibilitySchema1_ConvertOnRead(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+compatibility:
+  schema1:
+    convertonread: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Compatibility.Schema1.ConvertOnRead))
+	}
+
+	testParameter(t, yml, "REGISTRY_COMPATIBILITY_SCHEMA1_CONVERTONREAD", tt, validator)
+}
+
+func TestParseDatabaseCircuitBreaker_Interval(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  circuitbreaker:
+    enabled: true
+    interval: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10s",
+			want:  10 * time.Second,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseCircuitBreakerInterval,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.CircuitBreaker.Interval)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_CIRCUITBREAKER_INTERVAL", tt, validator)
+}
+
+func TestParseDatabaseCircuitBreaker_LatencyThreshold(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  circuitbreaker:
+    enabled: true
+    latencythreshold: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "500ms",
+			want:  500 * time.Millisecond,
+		},
+		{
+			name: "default",
+			want: defaultDatabaseCircuitBreakerLatencyThreshold,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.CircuitBreaker.LatencyThreshold)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_CIRCUITBREAKER_LATENCYTHRESHOLD", tt, validator)
+}
+
+func TestParseDatabaseCircuitBreaker_FailureThreshold(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  circuitbreaker:
+    enabled: true
+    failurethreshold: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5",
+			want:  "5",
+		},
+		{
+			name: "default",
+			want: strconv.Itoa(defaultDatabaseCircuitBreakerFailureThreshold),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.Itoa(got.Database.CircuitBreaker.FailureThreshold))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_CIRCUITBREAKER_FAILURETHRESHOLD", tt, validator)
+}
+
+func TestParseDatabaseCircuitBreaker_RecoveryThreshold(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  circuitbreaker:
+    enabled: true
+    recoverythreshold: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5",
+			want:  "5",
+		},
+		{
+			name: "default",
+			want: strconv.Itoa(defaultDatabaseCircuitBreakerRecoveryThreshold),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.Itoa(got.Database.CircuitBreaker.RecoveryThreshold))
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_CIRCUITBREAKER_RECOVERYTHRESHOLD", tt, validator)
+}
+
+func TestParseDatabasePool_MaxIdle(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  pool:
+    maxidle: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.Pool.MaxIdle)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_POOL_MAXIDLE", tt, validator)
+}
+
+func TestParseDatabasePool_MaxOpen(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  pool:
+    maxopen: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.Pool.MaxOpen)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_POOL_MAXOPEN", tt, validator)
+}
+
+func TestParseDatabasePool_MaxLifetime(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+database:
+  pool:
+    maxlifetime: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10s",
+			want:  10 * time.Second,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Database.Pool.MaxLifetime)
+	}
+
+	testParameter(t, yml, "REGISTRY_DATABASE_POOL_MAXLIFETIME", tt, validator)
+}
+
+func TestParseReportingSentry_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+reporting:
+  sentry:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Reporting.Sentry.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_ENABLED", tt, validator)
+}
+
+func TestParseReportingSentry_DSN(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+reporting:
+  sentry:
+    dsn: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "https://examplePublicKey@o0.ingest.sentry.io/0",
+			want:  "https://examplePublicKey@o0.ingest.sentry.io/0",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Reporting.Sentry.DSN)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_DSN", tt, validator)
+}
+
+func TestParseReportingSentry_Environment(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+reporting:
+  sentry:
+    environment: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "development",
+			want:  "development",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Reporting.Sentry.Environment)
+	}
+
+	testParameter(t, yml, "REGISTRY_REPORTING_SENTRY_ENVIRONMENT", tt, validator)
+}
+
+func checkStructs(c *C, t reflect.Type, structsChecked map[string]struct{}) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Map || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	if _, present := structsChecked[t.String()]; present {
+		// Already checked this type
+		return
+	}
+
+	structsChecked[t.String()] = struct{}{}
+
+	byUpperCase := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		// Check that the yaml tag does not contain an _.
+		yamlTag := sf.Tag.Get("yaml")
+		if strings.Contains(yamlTag, "_") {
+			c.Fatalf("yaml field name includes _ character: %s", yamlTag)
+		}
+		upper := strings.ToUpper(sf.Name)
+		if _, present := byUpperCase[upper]; present {
+			c.Fatalf("field name collision in configuration object: %s", sf.Name)
+		}
+		byUpperCase[upper] = i
+
+		checkStructs(c, sf.Type, structsChecked)
+	}
+}
+
+// TestValidateConfigStruct makes sure that the config struct has no members
+// with yaml tags that would be ambiguous to the environment variable parser.
+func (suite *ConfigSuite) TestValidateConfigStruct(c *C) {
+	structsChecked := make(map[string]struct{})
+	checkStructs(c, reflect.TypeOf(Configuration{}), structsChecked)
+}
+
+func copyConfig(config Configuration) *Configuration {
+	configCopy := new(Configuration)
+
+	configCopy.Version = MajorMinorVersion(config.Version.Major(), config.Version.Minor())
+	configCopy.Loglevel = config.Loglevel
+	configCopy.Log = config.Log
+	configCopy.Log.Fields = make(map[string]interface{}, len(config.Log.Fields))
+	for k, v := range config.Log.Fields {
+		configCopy.Log.Fields[k] = v
+	}
+
+	configCopy.Storage = Storage{config.Storage.Type(): Parameters{}}
+	for k, v := range config.Storage.Parameters() {
+		configCopy.Storage.setParameter(k, v)
+	}
+
+	configCopy.Database = config.Database
+
+	configCopy.Reporting = Reporting{
+		Sentry: SentryReporting{config.Reporting.Sentry.Enabled, config.Reporting.Sentry.DSN, config.Reporting.Sentry.Environment},
+	}
+
+	configCopy.Auth = Auth{config.Auth.Type(): Parameters{}}
+	for k, v := range config.Auth.Parameters() {
+		configCopy.Auth.setParameter(k, v)
+	}
+
+	configCopy.Notifications = Notifications{Queue: config.Notifications.Queue, Endpoints: []Endpoint{}}
+	for _, v := range config.Notifications.Endpoints {
+		configCopy.Notifications.Endpoints = append(configCopy.Notifications.Endpoints, v)
+	}
+
+	configCopy.HTTP.Headers = make(http.Header)
+	for k, v := range config.HTTP.Headers {
+		configCopy.HTTP.Headers[k] = v
+	}
+
+	return configCopy
+}
+
+func TestParseValidation_Manifests_PayloadSizeLimit(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+        payloadsizelimit: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.PayloadSizeLimit)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_PAYLOADSIZELIMIT", tt, validator)
+}
+
+func TestParseValidation_Manifests_ReferenceVerification_Concurrency(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    referenceverification:
+      concurrency: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.ReferenceVerification.Concurrency)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_REFERENCEVERIFICATION_CONCURRENCY", tt, validator)
+}
+
+func TestParseValidation_Manifests_ReferenceVerification_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    referenceverification:
+      concurrency: 10
+      timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1m",
+			want:  time.Minute,
+		},
+		{
+			name: "default",
+			want: defaultManifestReferenceVerificationTimeout,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.ReferenceVerification.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_REFERENCEVERIFICATION_TIMEOUT", tt, validator)
+}
+
+func TestParseValidation_Manifests_BlobMount_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    blobmount:
+      enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Validation.Manifests.BlobMount.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_BLOBMOUNT_ENABLED", tt, validator)
+}
+
+func TestParseValidation_Manifests_BlobMount_Concurrency(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    blobmount:
+      enabled: true
+      concurrency: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: defaultManifestBlobMountConcurrency,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Validation.Manifests.BlobMount.Concurrency)
+	}
+
+	testParameter(t, yml, "REGISTRY_VALIDATION_MANIFESTS_BLOBMOUNT_CONCURRENCY", tt, validator)
+}
+
+func TestParseValidation_Manifests_ArtifactMediaTypes(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    artifactmediatypes:
+      allow:
+        - ^application/vnd\.example\.
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+	require.Equal(t, []string{`^application/vnd\.example\.`}, config.Validation.Manifests.ArtifactMediaTypes.Allow)
+}
+
+func TestParseRedisCache_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_ENABLED", tt, validator)
+}
+
+func TestParseRedisCache_TLS_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    tls:
+      enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.TLS.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_TLS_ENABLED", tt, validator)
+}
+
+func TestParseRedisCache_TLS_Insecure(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    tls:
+      insecure: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.TLS.Insecure))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_TLS_INSECURE", tt, validator)
+}
+
+func TestParseRedisCache_Addr(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    addr: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "single",
+			value: "0.0.0.0:6379",
+			want:  "0.0.0.0:6379",
+		},
+		{
+			name:  "multiple",
+			value: "0.0.0.0:16379,0.0.0.0:26379",
+			want:  "0.0.0.0:16379,0.0.0.0:26379",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.Addr)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_ADDR", tt, validator)
+}
+
+func TestParseRedisCache_MainName(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    mainname: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "myredismainserver",
+			want:  "myredismainserver",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.MainName)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_MAINNAME", tt, validator)
+}
+
+func TestParseRedisCache_Username(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    username: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "foo",
+			want:  "foo",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.Username)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_USERNAME", tt, validator)
+}
+
+func TestParseRedisCache_SentinelUsername(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    sentinelusername: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "foo",
+			want:  "foo",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.SentinelUsername)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_SENTINELUSERNAME", tt, validator)
+}
+
+func TestParseRedisCache_SentinelPassword(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    sentinelpassword: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "foo",
+			want:  "foo",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.SentinelPassword)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_SENTINELPASSWORD", tt, validator)
+}
+
+func TestParseRedisCache_Cluster(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    cluster: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.Cluster))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_CLUSTER", tt, validator)
+}
+
+func TestParseRedisCache_TLS_CAFile(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    tls:
+      cafile: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "/path/to/file.pem",
+			want:  "/path/to/file.pem",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.TLS.CAFile)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_TLS_CAFILE", tt, validator)
+}
+
+func TestParseRedisCache_TLS_CertFile(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    tls:
+      certfile: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "/path/to/file.pem",
+			want:  "/path/to/file.pem",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.TLS.CertFile)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_TLS_CERTFILE", tt, validator)
+}
+
+func TestParseRedisCache_TLS_KeyFile(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    tls:
+      keyfile: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "/path/to/file.pem",
+			want:  "/path/to/file.pem",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.TLS.KeyFile)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_TLS_KEYFILE", tt, validator)
+}
+
+func TestParseRedisCache_Pool_MaxOpen(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    pool:
+      size: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "empty",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.Pool.Size)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_POOL_SIZE", tt, validator)
+}
+
+func TestParseRedisCache_Pool_MaxLifeTime(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    pool:
+      maxlifetime: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1h",
+			want:  1 * time.Hour,
+		},
+		{
+			name: "empty",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.Pool.MaxLifetime)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_POOL_MAXLIFETIME", tt, validator)
+}
+
+func TestParseRedisCache_Pool_IdleTimeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    pool:
+      idletimeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "300s",
+			want:  300 * time.Second,
+		},
+		{
+			name: "empty",
+			want: time.Duration(0),
 		},
 	}
 
@@ -1788,5 +3217,1152 @@ redis:
 		require.Equal(t, want, got.Redis.Cache.Pool.IdleTimeout)
 	}
 
-	testParameter(t, yml, "REGISTRY_REDIS_CACHE_POOL_IDLETIMEOUT", tt, validator)
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_POOL_IDLETIMEOUT", tt, validator)
+}
+
+func TestParseRedisCache_Manifests_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    manifests:
+      enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.Manifests.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_MANIFESTS_ENABLED", tt, validator)
+}
+
+func TestParseRedisCache_Manifests_TTL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    manifests:
+      enabled: true
+      ttl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1h",
+			want:  1 * time.Hour,
+		},
+		{
+			name: "default",
+			want: defaultRedisCacheManifestsTTL,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.Manifests.TTL)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_MANIFESTS_TTL", tt, validator)
+}
+
+func TestParseRedisCache_UploadSessions_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    uploadsessions:
+      enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Redis.Cache.UploadSessions.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_UPLOADSESSIONS_ENABLED", tt, validator)
+}
+
+func TestParseRedisCache_UploadSessions_TTL(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+redis:
+  cache:
+    enabled: true
+    uploadsessions:
+      enabled: true
+      ttl: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "24h",
+			want:  24 * time.Hour,
+		},
+		{
+			name: "default",
+			want: defaultRedisCacheUploadSessionsTTL,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Redis.Cache.UploadSessions.TTL)
+	}
+
+	testParameter(t, yml, "REGISTRY_REDIS_CACHE_UPLOADSESSIONS_TTL", tt, validator)
+}
+
+func TestParseHTTPDebugPrometheusRepositories(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+http:
+  debug:
+    addr: localhost:5001
+    prometheus:
+      enabled: true
+      repositories:
+        enabled: true
+        limit: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10",
+			want:  10,
+		},
+		{
+			name: "default",
+			want: defaultPrometheusRepositoriesLimit,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.HTTP.Debug.Prometheus.Repositories.Limit)
+		require.Equal(t, defaultPrometheusRepositoriesHalfLife, got.HTTP.Debug.Prometheus.Repositories.HalfLife)
+	}
+
+	testParameter(t, yml, "REGISTRY_HTTP_DEBUG_PROMETHEUS_REPOSITORIES_LIMIT", tt, validator)
+}
+
+func TestParsePolicyRepository_ImmutableTags(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+policy:
+  repository:
+    immutabletags: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: `[{repository: "my-group/*", patterns: ["v*", "release-*"]}, {patterns: ["stable"]}]`,
+			want: []ImmutableTagRule{
+				{Repository: "my-group/*", Patterns: []string{"v*", "release-*"}},
+				{Patterns: []string{"stable"}},
+			},
+		},
+		{
+			name: "default",
+			want: []ImmutableTagRule(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Policy.Repository.ImmutableTags)
+	}
+
+	testParameter(t, yml, "REGISTRY_POLICY_REPOSITORY_IMMUTABLETAGS", tt, validator)
+}
+
+func TestParseRemoteMount_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+remotemount:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.RemoteMount.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_REMOTEMOUNT_ENABLED", tt, validator)
+}
+
+func TestParseRemoteMount_Registries(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+remotemount:
+  registries: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: `["registry.example.com", "registry.example.org:5000"]`,
+			want:  []string{"registry.example.com", "registry.example.org:5000"},
+		},
+		{
+			name: "default",
+			want: []string(nil),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.RemoteMount.Registries)
+	}
+
+	testParameter(t, yml, "REGISTRY_REMOTEMOUNT_REGISTRIES", tt, validator)
+}
+
+func TestParseRemoteMount_Timeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+remotemount:
+  timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5m",
+			want:  5 * time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.RemoteMount.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_REMOTEMOUNT_TIMEOUT", tt, validator)
+}
+
+func TestParseRateLimiter_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+ratelimiter:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.RateLimiter.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_RATELIMITER_ENABLED", tt, validator)
+}
+
+func TestParseRateLimiter_Limiters(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+ratelimiter:
+  enabled: true
+  limiters:
+    - name: ip
+      key: ip
+      burst: 100
+      sustained: 3000
+      period: 5m
+    - name: repository
+      key: repository
+      sustained: 600
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := []Limiter{
+		{Name: "ip", Key: RateLimitKeyIP, Burst: 100, Sustained: 3000, Period: 5 * time.Minute},
+		{Name: "repository", Key: RateLimitKeyRepository, Sustained: 600},
+	}
+	require.Equal(t, want, config.RateLimiter.Limiters)
+}
+
+func TestParseUploads_ChunksMinSize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  chunks:
+    minsize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5242880",
+			want:  int64(5242880),
+		},
+		{
+			name: "default",
+			want: int64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Uploads.Chunks.MinSize)
+	}
+
+	testParameter(t, yml, "REGISTRY_UPLOADS_CHUNKS_MINSIZE", tt, validator)
+}
+
+func TestParseUploads_ChunksMaxSize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  chunks:
+    maxsize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "104857600",
+			want:  int64(104857600),
+		},
+		{
+			name: "default",
+			want: int64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Uploads.Chunks.MaxSize)
+	}
+
+	testParameter(t, yml, "REGISTRY_UPLOADS_CHUNKS_MAXSIZE", tt, validator)
+}
+
+func TestParseUploads_DirectEnabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  direct:
+    enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Uploads.Direct.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_UPLOADS_DIRECT_ENABLED", tt, validator)
+}
+
+func TestParseUploads_DirectExpiry(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  direct:
+    expiry: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "30m",
+			want:  30 * time.Minute,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Uploads.Direct.Expiry)
+	}
+
+	testParameter(t, yml, "REGISTRY_UPLOADS_DIRECT_EXPIRY", tt, validator)
+}
+
+func TestParseUploads_DirectMaxParts(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  direct:
+    maxparts: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "500",
+			want:  500,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Uploads.Direct.MaxParts)
+	}
+
+	testParameter(t, yml, "REGISTRY_UPLOADS_DIRECT_MAXPARTS", tt, validator)
+}
+
+func TestParseUploads_DirectTrustedClients(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  direct:
+    trustedclients:
+      - 10.0.0.0/8
+      - 192.168.1.0/24
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.0/8", "192.168.1.0/24"}, config.Uploads.Direct.TrustedClients)
+}
+
+func TestParseUploads_ImportMaxSize(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  import:
+    maxsize: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10737418240",
+			want:  int64(10737418240),
+		},
+		{
+			name: "default",
+			want: int64(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Uploads.Import.MaxSize)
+	}
+
+	testParameter(t, yml, "REGISTRY_UPLOADS_IMPORT_MAXSIZE", tt, validator)
+}
+
+func TestParseUploads_ImportTimeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  import:
+    timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1h",
+			want:  time.Hour,
+		},
+		{
+			name: "default",
+			want: time.Duration(0),
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Uploads.Import.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_UPLOADS_IMPORT_TIMEOUT", tt, validator)
+}
+
+func TestParseUploads_ImportHosts(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+uploads:
+  import:
+    hosts:
+      - artifacts.example.com
+      - storage.example.com:8443
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+	require.Equal(t, []string{"artifacts.example.com", "storage.example.com:8443"}, config.Uploads.Import.Hosts)
+}
+
+func TestParseAudit_Enabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  enabled: %s
+`
+	tt := boolParameterTests(false)
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, strconv.FormatBool(got.Audit.Enabled))
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_ENABLED", tt, validator)
+}
+
+func TestParseAudit_Sink(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  sink: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "file",
+			value: AuditSinkFile,
+			want:  AuditSinkFile,
+		},
+		{
+			name:  "syslog",
+			value: AuditSinkSyslog,
+			want:  AuditSinkSyslog,
+		},
+		{
+			name:  "http",
+			value: AuditSinkHTTP,
+			want:  AuditSinkHTTP,
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Audit.Sink)
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_SINK", tt, validator)
+}
+
+func TestParseAudit_FilePath(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  file:
+    path: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "/var/log/registry/audit.log",
+			want:  "/var/log/registry/audit.log",
+		},
+		{
+			name: "default",
+			want: "",
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Audit.File.Path)
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_FILE_PATH", tt, validator)
+}
+
+func TestParseAudit_SyslogTag(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  enabled: true
+  syslog:
+    tag: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "foo",
+			want:  "foo",
+		},
+		{
+			name: "default",
+			want: defaultAuditSyslogTag,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Audit.Syslog.Tag)
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_SYSLOG_TAG", tt, validator)
+}
+
+func TestParseAudit_HTTPTimeout(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  enabled: true
+  http:
+    timeout: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "10s",
+			want:  10 * time.Second,
+		},
+		{
+			name: "default",
+			want: defaultAuditHTTPTimeout,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Audit.HTTP.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_AUDIT_HTTP_TIMEOUT", tt, validator)
+}
+
+func TestParseAudit_HTTPHeaders(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+audit:
+  enabled: true
+  sink: http
+  http:
+    url: https://audit.example.com
+    headers:
+      Authorization: [Bearer foo]
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	require.Equal(t, "https://audit.example.com", config.Audit.HTTP.URL)
+	require.Equal(t, http.Header{"Authorization": []string{"Bearer foo"}}, config.Audit.HTTP.Headers)
+}
+
+func TestParseProxy(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+proxy:
+  remoteurl: https://registry-1.docker.io
+  username: puller
+  password: secret
+  ttl: 24h
+  batchsize: 50
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := Proxy{
+		RemoteURL: "https://registry-1.docker.io",
+		Username:  "puller",
+		Password:  "secret",
+		TTL:       24 * time.Hour,
+		Interval:  time.Minute,
+		BatchSize: 50,
+		Timeout:   10 * time.Minute,
+	}
+	require.Equal(t, want, config.Proxy)
+	require.True(t, config.Proxy.Enabled())
+}
+
+func TestParseProxy_Disabled(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+	require.Equal(t, Proxy{}, config.Proxy)
+	require.False(t, config.Proxy.Enabled())
+}
+
+func TestParseNotifications_KafkaEndpoint(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  endpoints:
+    - name: kafka
+      type: kafka
+      timeout: 5s
+      threshold: 3
+      backoff: 2s
+      kafka:
+        brokers:
+          - kafka-1.example.com:9092
+          - kafka-2.example.com:9092
+        topic: registry-events
+        batchsize: 50
+        batchtimeout: 500ms
+        tls: true
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := []Endpoint{
+		{
+			Name:      "kafka",
+			Type:      EndpointTypeKafka,
+			Timeout:   5 * time.Second,
+			Threshold: 3,
+			Backoff:   2 * time.Second,
+			Kafka: KafkaEndpoint{
+				Brokers:      []string{"kafka-1.example.com:9092", "kafka-2.example.com:9092"},
+				Topic:        "registry-events",
+				BatchSize:    50,
+				BatchTimeout: 500 * time.Millisecond,
+				TLS:          true,
+			},
+		},
+	}
+	require.Equal(t, want, config.Notifications.Endpoints)
+}
+
+func TestParseNotifications_NATSEndpoint(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  endpoints:
+    - name: nats
+      type: nats
+      timeout: 5s
+      nats:
+        servers:
+          - nats://nats-1.example.com:4222
+          - nats://nats-2.example.com:4222
+        subject: "registry.{{ .Action }}.{{ token .Target.Repository }}"
+        maxpending: 512
+        credentialsfile: /path/to/registry.creds
+        tls: true
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := []Endpoint{
+		{
+			Name:    "nats",
+			Type:    EndpointTypeNATS,
+			Timeout: 5 * time.Second,
+			NATS: NATSEndpoint{
+				Servers:         []string{"nats://nats-1.example.com:4222", "nats://nats-2.example.com:4222"},
+				Subject:         "registry.{{ .Action }}.{{ token .Target.Repository }}",
+				MaxPending:      512,
+				CredentialsFile: "/path/to/registry.creds",
+				TLS:             true,
+			},
+		},
+	}
+	require.Equal(t, want, config.Notifications.Endpoints)
+}
+
+func TestParseNotifications_TLSAndSigning(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  endpoints:
+    - name: webhook
+      url: https://webhook.example.com/events
+      timeout: 1s
+      tls:
+        cafile: /path/to/ca.pem
+        certfile: /path/to/client.pem
+        keyfile: /path/to/client-key.pem
+      signing:
+        secret: s3cr3t
+        header: X-Signature
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := []Endpoint{
+		{
+			Name:    "webhook",
+			URL:     "https://webhook.example.com/events",
+			Timeout: time.Second,
+			TLS: EndpointTLS{
+				CAFile:   "/path/to/ca.pem",
+				CertFile: "/path/to/client.pem",
+				KeyFile:  "/path/to/client-key.pem",
+			},
+			Signing: EndpointSigning{
+				Secret: "s3cr3t",
+				Header: "X-Signature",
+			},
+		},
+	}
+	require.Equal(t, want, config.Notifications.Endpoints)
+}
+
+func TestParseNotifications_Queue(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  queue:
+    type: database
+    maxattempts: 5
+    retention: 24h
+    interval: 1s
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := NotificationsQueue{
+		Type:        NotificationsQueueTypeDatabase,
+		MaxAttempts: 5,
+		Retention:   24 * time.Hour,
+		Interval:    time.Second,
+	}
+	require.Equal(t, want, config.Notifications.Queue)
+}
+
+func TestParseNotifications_QueueDefaults(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+notifications:
+  queue:
+    type: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "database",
+			value: NotificationsQueueTypeDatabase,
+			want: NotificationsQueue{
+				Type:        NotificationsQueueTypeDatabase,
+				MaxAttempts: defaultNotificationsQueueMaxAttempts,
+				Retention:   defaultNotificationsQueueRetention,
+				Interval:    defaultNotificationsQueueInterval,
+			},
+		},
+		{
+			name:  "memory",
+			value: NotificationsQueueTypeMemory,
+			want:  NotificationsQueue{Type: NotificationsQueueTypeMemory},
+		},
+		{
+			name: "default",
+			want: NotificationsQueue{},
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Notifications.Queue)
+	}
+
+	testParameter(t, yml, "REGISTRY_NOTIFICATIONS_QUEUE_TYPE", tt, validator)
+}
+
+func TestParseTracing_Endpoint(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+tracing:
+  enabled: true
+  endpoint: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "otel-collector:4318",
+			want:  "otel-collector:4318",
+		},
+		{
+			name: "default",
+			want: defaultTracingEndpoint,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Tracing.Endpoint)
+		require.Equal(t, defaultTracingServiceName, got.Tracing.ServiceName)
+		require.Equal(t, defaultTracingTimeout, got.Tracing.Timeout)
+	}
+
+	testParameter(t, yml, "REGISTRY_TRACING_ENDPOINT", tt, validator)
+}
+
+func TestParseTracing_SampleRate(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+tracing:
+  enabled: true
+  samplerate: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "0.25",
+			want:  0.25,
+		},
+		{
+			name:  "zero",
+			value: "0",
+			want:  0.0,
+		},
+		{
+			name: "default",
+			want: defaultTracingSampleRate,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.NotNil(t, got.Tracing.SampleRate)
+		require.Equal(t, want, *got.Tracing.SampleRate)
+	}
+
+	testParameter(t, yml, "REGISTRY_TRACING_SAMPLERATE", tt, validator)
+}
+
+func TestParseHealthReadiness_Threshold(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+health:
+  readiness:
+    enabled: true
+    threshold: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "5",
+			want:  5,
+		},
+		{
+			name: "default",
+			want: defaultReadinessThreshold,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Health.Readiness.Threshold)
+		require.Equal(t, defaultReadinessInterval, got.Health.Readiness.Interval)
+		require.Equal(t, defaultReadinessTimeout, got.Health.Readiness.Timeout)
+		require.Equal(t, defaultReadinessNotificationsMaxPending, got.Health.Readiness.Notifications.MaxPending)
+	}
+
+	testParameter(t, yml, "REGISTRY_HEALTH_READINESS_THRESHOLD", tt, validator)
+}
+
+func TestParseHealthReadiness_DatabaseThreshold(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+health:
+  readiness:
+    enabled: true
+    database:
+      threshold: %s
+`
+	tt := []parameterTest{
+		{
+			name:  "sample",
+			value: "1",
+			want:  1,
+		},
+		{
+			name: "default",
+			want: 0,
+		},
+	}
+
+	validator := func(t *testing.T, want interface{}, got *Configuration) {
+		require.Equal(t, want, got.Health.Readiness.Database.Threshold)
+	}
+
+	testParameter(t, yml, "REGISTRY_HEALTH_READINESS_DATABASE_THRESHOLD", tt, validator)
+}
+
+func TestParseReplication_Targets(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+replication:
+  targets:
+    - name: mirror
+      url: https://mirror.example.com
+      username: replicator
+      password: secret
+      repositories:
+        - my-group/*
+      threshold: 3
+      workers: 4
+    - name: defaults
+      url: https://mirror.example.org
+      disabled: true
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := []ReplicationTarget{
+		{
+			Name:         "mirror",
+			URL:          "https://mirror.example.com",
+			Username:     "replicator",
+			Password:     "secret",
+			Repositories: []string{"my-group/*"},
+			Timeout:      5 * time.Minute,
+			Threshold:    3,
+			Backoff:      10 * time.Second,
+			Workers:      4,
+			QueueSize:    1000,
+		},
+		{
+			Name:      "defaults",
+			URL:       "https://mirror.example.org",
+			Disabled:  true,
+			Timeout:   5 * time.Minute,
+			Threshold: 5,
+			Backoff:   10 * time.Second,
+			Workers:   2,
+			QueueSize: 1000,
+		},
+	}
+	require.Equal(t, want, config.Replication.Targets)
+}
+
+func TestParseMaintenance_Windows(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+maintenance:
+  windows:
+    - start: 2024-01-20T02:00:00Z
+      end: 2024-01-20T04:30:00Z
+      reason: storage migration
+    - start: 2024-02-03T22:00:00+01:00
+      end: 2024-02-03T23:00:00+01:00
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := []MaintenanceWindow{
+		{
+			Start:  time.Date(2024, 1, 20, 2, 0, 0, 0, time.UTC),
+			End:    time.Date(2024, 1, 20, 4, 30, 0, 0, time.UTC),
+			Reason: "storage migration",
+		},
+		{
+			Start: time.Date(2024, 2, 3, 21, 0, 0, 0, time.UTC),
+			End:   time.Date(2024, 2, 3, 22, 0, 0, 0, time.UTC),
+		},
+	}
+	require.Len(t, config.Maintenance.Windows, len(want))
+	for i, w := range want {
+		require.True(t, w.Start.Equal(config.Maintenance.Windows[i].Start), "windows[%d].start", i)
+		require.True(t, w.End.Equal(config.Maintenance.Windows[i].End), "windows[%d].end", i)
+		require.Equal(t, w.Reason, config.Maintenance.Windows[i].Reason, "windows[%d].reason", i)
+	}
+}
+
+func TestParsePolicyRepositorySignatures(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+policy:
+  repository:
+    signatures:
+      - repository: my-group/*
+        mode: warn
+        cosign:
+          keys:
+            - /etc/registry/cosign.pub
+      - notation:
+          certificates:
+            - /etc/registry/ca.pem
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := []SignatureRule{
+		{
+			Repository: "my-group/*",
+			Mode:       SignatureModeWarn,
+			Cosign:     CosignTrustRoots{Keys: []string{"/etc/registry/cosign.pub"}},
+		},
+		{
+			Mode:     SignatureModeEnforce,
+			Notation: NotationTrustRoots{Certificates: []string{"/etc/registry/ca.pem"}},
+		},
+	}
+	require.Equal(t, want, config.Policy.Repository.Signatures)
+}
+
+func TestParseValidationManifestsPayloadSizeLimits(t *testing.T) {
+	yml := `
+version: 0.1
+storage: inmemory
+validation:
+  manifests:
+    payloadsizelimit: 1024
+    payloadsizelimits:
+      - repository: ml-group/*
+        limit: 4096
+      - repository: unlimited/*
+        limit: 0
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	require.Equal(t, 1024, config.Validation.Manifests.PayloadSizeLimit)
+	want := []ManifestPayloadSizeLimitRule{
+		{Repository: "ml-group/*", Limit: 4096},
+		{Repository: "unlimited/*", Limit: 0},
+	}
+	require.Equal(t, want, config.Validation.Manifests.PayloadSizeLimits)
+}
+
+func TestParseIsolation(t *testing.T) {
+	yml := `
+version: 0.1
+storage:
+  s3:
+    bucket: shared
+isolation:
+  enabled: true
+  namespaces:
+    tenant-a:
+      s3:
+        bucket: tenant-a
+        accesskey: a
+        secretkey: secret-a
+    tenant-b: inmemory
+`
+	config, err := Parse(bytes.NewReader([]byte(yml)))
+	require.NoError(t, err)
+
+	want := Isolation{
+		Enabled: true,
+		Namespaces: map[string]Storage{
+			"tenant-a": {"s3": Parameters{"bucket": "tenant-a", "accesskey": "a", "secretkey": "secret-a"}},
+			"tenant-b": {"inmemory": Parameters{}},
+		},
+	}
+	require.Equal(t, want, config.Isolation)
+	require.Equal(t, "s3", config.Isolation.Namespaces["tenant-a"].Type())
+
+	config, err = Parse(bytes.NewReader([]byte("version: 0.1\nstorage: inmemory\n")))
+	require.NoError(t, err)
+	require.False(t, config.Isolation.Enabled)
+	require.Empty(t, config.Isolation.Namespaces)
 }
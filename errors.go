@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/opencontainers/go-digest"
 )
@@ -138,6 +139,18 @@ func (err ErrManifestReferencesExceedLimit) Error() string {
 	return fmt.Sprintf("%d manifest references exceed reference limit of %d", err.References, err.Limit)
 }
 
+// ErrManifestReferencesVerificationTimeout is returned when the references of a manifest could not all be verified
+// within the configured deadline.
+type ErrManifestReferencesVerificationTimeout struct {
+	Unverified int
+	References int
+	Timeout    time.Duration
+}
+
+func (err ErrManifestReferencesVerificationTimeout) Error() string {
+	return fmt.Sprintf("%d of %d manifest references could not be verified within %s", err.Unverified, err.References, err.Timeout)
+}
+
 // ErrManifestPayloadSizeExceedsLimit is returned when a manifest is bigger than the configured payload
 // size limit.
 type ErrManifestPayloadSizeExceedsLimit struct {
@@ -0,0 +1,218 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/context"
+)
+
+// ReadinessRegistry is the registry of the checks of the dependencies required to serve requests, used by the
+// readiness handler. Unlike the checks of DefaultRegistry, failing readiness checks do not make the application reject
+// requests, they only signal that it should be taken out of rotation.
+var ReadinessRegistry = NewRegistry()
+
+// errNotChecked is the status of a Probe before its first check completes.
+var errNotChecked = errors.New("not checked yet")
+
+// Result is the detailed status of a check.
+type Result struct {
+	// Error is the error of the last check, if failed.
+	Error string `json:"error,omitempty"`
+	// Failures is the number of consecutive failed checks.
+	Failures int `json:"failures,omitempty"`
+	// CheckedAt is the time of the last check. Nil if not checked yet.
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	// DurationSeconds is the duration of the last check.
+	DurationSeconds float64 `json:"duration_s,omitempty"`
+}
+
+// Reporter is implemented by checkers that provide the details of their last check.
+type Reporter interface {
+	Checker
+
+	// Report returns the details of the last check.
+	Report() Result
+}
+
+// Probe is a Checker that periodically runs another, keeping track of the details of the last check. A Probe is
+// unhealthy until its first check completes, and after failing threshold consecutive checks.
+type Probe struct {
+	check     Checker
+	threshold int
+
+	mu        sync.Mutex
+	status    error
+	failures  int
+	checkedAt time.Time
+	duration  time.Duration
+}
+
+// NewProbe creates a new Probe that runs check every period, starting immediately. A threshold of zero or less is
+// handled as one.
+func NewProbe(check Checker, period time.Duration, threshold int) *Probe {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	p := &Probe{check: check, threshold: threshold, status: errNotChecked}
+
+	go func() {
+		t := time.NewTicker(period)
+		for {
+			p.run()
+			<-t.C
+		}
+	}()
+
+	return p
+}
+
+// run runs the underlying check once and records its outcome.
+func (p *Probe) run() {
+	start := time.Now()
+	err := p.check.Check()
+	d := time.Since(start)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		p.failures = 0
+	} else {
+		p.failures++
+	}
+	p.status = err
+	p.checkedAt = start
+	p.duration = d
+}
+
+// Check implements Checker.
+func (p *Probe) Check() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.checkedAt.IsZero() {
+		return p.status
+	}
+	if p.failures >= p.threshold {
+		return p.status
+	}
+	return nil
+}
+
+// Report implements Reporter.
+func (p *Probe) Report() Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := Result{Failures: p.failures}
+	if !p.checkedAt.IsZero() {
+		checkedAt := p.checkedAt
+		r.CheckedAt = &checkedAt
+		r.DurationSeconds = p.duration.Seconds()
+	}
+	if p.status != nil {
+		r.Error = p.status.Error()
+	}
+	return r
+}
+
+// CheckReport is the status of a check in a readiness report.
+type CheckReport struct {
+	Status string `json:"status"`
+	Result
+}
+
+// Report is the response of the readiness handler.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckReport `json:"checks"`
+}
+
+const (
+	statusOK       = "ok"
+	statusFailed   = "failed"
+	statusReady    = "ready"
+	statusNotReady = "not_ready"
+)
+
+// Report returns the detailed status of all registered checks. Checks that are not a Reporter only provide their
+// current error, if any.
+func (registry *Registry) Report() Report {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	report := Report{Status: statusReady, Checks: make(map[string]CheckReport, len(registry.registeredChecks))}
+	for name, c := range registry.registeredChecks {
+		var cs CheckReport
+		if r, ok := c.(Reporter); ok {
+			cs.Result = r.Report()
+		}
+
+		if err := c.Check(); err != nil {
+			cs.Status = statusFailed
+			cs.Error = err.Error()
+			report.Status = statusNotReady
+		} else {
+			cs.Status = statusOK
+		}
+		report.Checks[name] = cs
+	}
+
+	return report
+}
+
+// ReadinessHandler returns a handler that reports whether the application is ready to serve requests, according to
+// the checks registered in registry. It responds with a JSON report of all checks, and a 503 status if any of them
+// failed, or 200 otherwise.
+func ReadinessHandler(registry *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+
+		report := registry.Report()
+		status := http.StatusOK
+		if report.Status != statusReady {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, status, report)
+	})
+}
+
+// LivenessHandler reports that the application is alive, regardless of the status of its dependencies. A failed
+// dependency should take the application out of rotation, through ReadinessHandler, but not restart it.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status string `json:"status"`
+	}{Status: statusOK})
+}
+
+// writeJSON completes the request with the given status and v serialized as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	p, err := json.Marshal(v)
+	if err != nil {
+		context.GetLogger(context.Background()).Errorf("error serializing health report: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", fmt.Sprint(len(p)))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	if _, err := w.Write(p); err != nil {
+		context.GetLogger(context.Background()).Errorf("error writing health report response body: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// toggle is a Checker whose status can be changed concurrently.
+type toggle struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (tg *toggle) Check() error {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	return tg.err
+}
+
+func (tg *toggle) set(err error) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	tg.err = err
+}
+
+func TestProbe(t *testing.T) {
+	check := &toggle{}
+	p := &Probe{check: check, threshold: 2, status: errNotChecked}
+
+	// not ready until checked
+	require.EqualError(t, p.Check(), errNotChecked.Error())
+	require.Equal(t, Result{Error: errNotChecked.Error()}, p.Report())
+
+	p.run()
+	require.NoError(t, p.Check())
+	r := p.Report()
+	require.NotNil(t, r.CheckedAt)
+	require.Empty(t, r.Error)
+	require.Zero(t, r.Failures)
+
+	// failures below the threshold are reported but don't fail the check
+	check.set(errors.New("foo"))
+	p.run()
+	require.NoError(t, p.Check())
+	r = p.Report()
+	require.Equal(t, "foo", r.Error)
+	require.Equal(t, 1, r.Failures)
+
+	p.run()
+	require.EqualError(t, p.Check(), "foo")
+	require.Equal(t, 2, p.Report().Failures)
+
+	// a successful check resets the failures
+	check.set(nil)
+	p.run()
+	require.NoError(t, p.Check())
+	require.Zero(t, p.Report().Failures)
+}
+
+func TestNewProbe(t *testing.T) {
+	p := NewProbe(CheckFunc(func() error { return nil }), time.Hour, 0)
+	require.Equal(t, 1, p.threshold)
+
+	// the first check runs immediately
+	require.Eventually(t, func() bool { return p.Check() == nil }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReadinessHandler(t *testing.T) {
+	registry := NewRegistry()
+	db := &toggle{}
+	registry.Register("database", db)
+	registry.Register("storage", CheckFunc(func() error { return nil }))
+
+	get := func(t *testing.T) (int, Report) {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		ReadinessHandler(registry).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+		require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var report Report
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		return rec.Code, report
+	}
+
+	code, report := get(t)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, Report{
+		Status: statusReady,
+		Checks: map[string]CheckReport{
+			"database": {Status: statusOK},
+			"storage":  {Status: statusOK},
+		},
+	}, report)
+
+	db.set(errors.New("connection refused"))
+	code, report = get(t)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, Report{
+		Status: statusNotReady,
+		Checks: map[string]CheckReport{
+			"database": {Status: statusFailed, Result: Result{Error: "connection refused"}},
+			"storage":  {Status: statusOK},
+		},
+	}, report)
+}
+
+func TestReadinessHandler_MethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ReadinessHandler(NewRegistry()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/readiness", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestLivenessHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	LivenessHandler(rec, httptest.NewRequest(http.MethodGet, "/liveness", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
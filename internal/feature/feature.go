@@ -30,6 +30,12 @@ var OngoingRenameCheck = Feature{
 	EnvVariable: "REGISTRY_FF_ONGOING_RENAME_CHECK",
 }
 
+// DirectUploads allows trusted clients to upload blob data directly to the storage backend, using presigned URLs
+// obtained when starting a blob upload. It also requires direct uploads to be enabled in the `uploads` configuration.
+var DirectUploads = Feature{
+	EnvVariable: "REGISTRY_FF_DIRECT_UPLOADS",
+}
+
 // testFeature is used for testing purposes only
 var testFeature = Feature{
 	EnvVariable: "REGISTRY_FF_TEST",
@@ -38,6 +44,7 @@ var testFeature = Feature{
 var all = []Feature{
 	testFeature,
 	OngoingRenameCheck,
+	DirectUploads,
 }
 
 // KnownEnvVar evaluates whether the input string matches the name of one of the known feature flag env vars.
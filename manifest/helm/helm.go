@@ -0,0 +1,70 @@
+// Package helm defines the media types of Helm charts stored as OCI artifacts and extracts the chart metadata from
+// their manifests.
+package helm
+
+import (
+	"encoding/json"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// MediaTypeConfig is the media type of the configuration of a Helm chart. Its payload is the chart metadata, as
+	// found in the chart's Chart.yaml file, in JSON format.
+	MediaTypeConfig = "application/vnd.cncf.helm.config.v1+json"
+	// MediaTypeChartContent is the media type of the layer holding the chart archive.
+	MediaTypeChartContent = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	// MediaTypeChartProvenance is the media type of the layer holding the provenance file of a signed chart.
+	MediaTypeChartProvenance = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+	// MediaTypeChartContentLegacy is the media type of the layer holding the chart archive, as pushed by the
+	// experimental OCI support of Helm versions prior to 3.7.
+	MediaTypeChartContentLegacy = "application/vnd.cncf.helm.chart.content.layer.v1+tar"
+	// MediaTypeChartMetaLegacy is the media type of the layer holding the chart metadata, as pushed by the
+	// experimental OCI support of Helm versions prior to 3.7.
+	MediaTypeChartMetaLegacy = "application/vnd.cncf.helm.chart.meta.layer.v1+json"
+)
+
+// MediaTypes is the list of media types used by Helm charts.
+var MediaTypes = []string{
+	MediaTypeConfig,
+	MediaTypeChartContent,
+	MediaTypeChartProvenance,
+	MediaTypeChartContentLegacy,
+	MediaTypeChartMetaLegacy,
+}
+
+// Metadata is the metadata of a Helm chart.
+type Metadata struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ChartMetadata returns the metadata of the Helm chart described by a manifest with the given configuration media
+// type, manifest annotations and configuration payload. The chart name and version are read from the
+// `org.opencontainers.image.title` and `org.opencontainers.image.version` manifest annotations, which Helm sets on
+// push, falling back to the configuration payload for those not set. False is returned if the manifest is not a Helm
+// chart, or if neither the chart name nor version are known.
+func ChartMetadata(configMediaType string, annotations map[string]string, config []byte) (Metadata, bool) {
+	if configMediaType != MediaTypeConfig {
+		return Metadata{}, false
+	}
+
+	md := Metadata{
+		Name:    annotations[v1.AnnotationTitle],
+		Version: annotations[v1.AnnotationVersion],
+	}
+	if (md.Name == "" || md.Version == "") && len(config) > 0 {
+		var cfg Metadata
+		// the configuration may be invalid or not stored if over the size limit, in which case we rely on annotations
+		if err := json.Unmarshal(config, &cfg); err == nil {
+			if md.Name == "" {
+				md.Name = cfg.Name
+			}
+			if md.Version == "" {
+				md.Version = cfg.Version
+			}
+		}
+	}
+
+	return md, md.Name != "" || md.Version != ""
+}
@@ -0,0 +1,73 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChartMetadata(t *testing.T) {
+	config := []byte(`{"name":"my-chart","version":"1.2.3","apiVersion":"v2"}`)
+
+	tt := []struct {
+		name            string
+		configMediaType string
+		annotations     map[string]string
+		config          []byte
+		expected        Metadata
+		expectedOK      bool
+	}{
+		{
+			name:            "not a chart",
+			configMediaType: "application/vnd.oci.image.config.v1+json",
+			annotations:     map[string]string{"org.opencontainers.image.title": "foo"},
+			config:          config,
+		},
+		{
+			name:            "annotations",
+			configMediaType: MediaTypeConfig,
+			annotations: map[string]string{
+				"org.opencontainers.image.title":   "annotated-chart",
+				"org.opencontainers.image.version": "2.0.0",
+			},
+			config:     config,
+			expected:   Metadata{Name: "annotated-chart", Version: "2.0.0"},
+			expectedOK: true,
+		},
+		{
+			name:            "config fallback",
+			configMediaType: MediaTypeConfig,
+			config:          config,
+			expected:        Metadata{Name: "my-chart", Version: "1.2.3"},
+			expectedOK:      true,
+		},
+		{
+			name:            "partial annotations",
+			configMediaType: MediaTypeConfig,
+			annotations:     map[string]string{"org.opencontainers.image.version": "2.0.0"},
+			config:          config,
+			expected:        Metadata{Name: "my-chart", Version: "2.0.0"},
+			expectedOK:      true,
+		},
+		{
+			name:            "config not stored",
+			configMediaType: MediaTypeConfig,
+			annotations:     map[string]string{"org.opencontainers.image.title": "annotated-chart"},
+			expected:        Metadata{Name: "annotated-chart"},
+			expectedOK:      true,
+		},
+		{
+			name:            "invalid config",
+			configMediaType: MediaTypeConfig,
+			config:          []byte("{"),
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			md, ok := ChartMetadata(test.configMediaType, test.annotations, test.config)
+			require.Equal(t, test.expectedOK, ok)
+			require.Equal(t, test.expected, md)
+		})
+	}
+}
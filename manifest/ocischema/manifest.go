@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest"
@@ -20,6 +21,38 @@ var (
 	}
 )
 
+const (
+	// encryptedLayerSuffix is appended by ocicrypt to the media type of the layers it encrypts.
+	encryptedLayerSuffix = "+encrypted"
+
+	mediaTypeImageLayerNonDistributableZstd = "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd"
+)
+
+// IsEncryptedLayer reports whether mediaType is the media type of a layer encrypted with ocicrypt, such as
+// `application/vnd.oci.image.layer.v1.tar+gzip+encrypted`.
+func IsEncryptedLayer(mediaType string) bool {
+	return strings.HasSuffix(mediaType, encryptedLayerSuffix) && len(mediaType) > len(encryptedLayerSuffix)
+}
+
+// DecryptedLayerMediaType returns the media type of an encrypted layer once decrypted. Other media types are returned
+// unchanged.
+func DecryptedLayerMediaType(mediaType string) string {
+	if !IsEncryptedLayer(mediaType) {
+		return mediaType
+	}
+	return strings.TrimSuffix(mediaType, encryptedLayerSuffix)
+}
+
+// IsNonDistributableLayer reports whether mediaType is the media type of a non-distributable layer, encrypted or not.
+func IsNonDistributableLayer(mediaType string) bool {
+	switch DecryptedLayerMediaType(mediaType) {
+	case v1.MediaTypeImageLayerNonDistributable, v1.MediaTypeImageLayerNonDistributableGzip, mediaTypeImageLayerNonDistributableZstd:
+		return true
+	default:
+		return false
+	}
+}
+
 func init() {
 	ocischemaFunc := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
 		m := new(DeserializedManifest)
@@ -41,6 +74,10 @@ func init() {
 type Manifest struct {
 	manifest.Versioned
 
+	// ArtifactType contains the type of an artifact when the manifest is used for an artifact. This OPTIONAL property
+	// MUST be set when the config media type is set to the empty value.
+	ArtifactType string `json:"artifactType,omitempty"`
+
 	// Config references the image configuration as a blob.
 	Config distribution.Descriptor `json:"config"`
 
@@ -152,11 +189,13 @@ func (m *DeserializedManifest) Subject() distribution.Descriptor {
 	return *m.Manifest.Subject
 }
 
+// ArtifactType returns the artifact type of the manifest, if any.
+func (m *DeserializedManifest) ArtifactType() string { return m.Manifest.ArtifactType }
+
 func (m *DeserializedManifest) DistributableLayers() []distribution.Descriptor {
 	var ll []distribution.Descriptor
 	for _, l := range m.Layers() {
-		switch l.MediaType {
-		case v1.MediaTypeImageLayerNonDistributable, v1.MediaTypeImageLayerNonDistributableGzip:
+		if IsNonDistributableLayer(l.MediaType) {
 			continue
 		}
 		ll = append(ll, l)
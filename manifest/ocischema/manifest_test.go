@@ -205,6 +205,54 @@ func TestManifestWithSubject(t *testing.T) {
 	require.Equal(t, 3, len(references))
 }
 
+func TestManifestWithArtifactType(t *testing.T) {
+	expectedManifestSerialization := []byte(`{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.oci.image.manifest.v1+json",
+   "artifactType": "application/vnd.example.sbom.v1",
+   "config": {
+      "mediaType": "application/vnd.oci.empty.v1+json",
+      "size": 2,
+      "digest": "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+   },
+   "layers": [
+      {
+         "mediaType": "application/spdx+json",
+         "size": 153263,
+         "digest": "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b"
+      }
+   ]
+}`)
+
+	manifest := Manifest{
+		Versioned:    SchemaVersion,
+		ArtifactType: "application/vnd.example.sbom.v1",
+		Config: distribution.Descriptor{
+			MediaType: "application/vnd.oci.empty.v1+json",
+			Size:      2,
+			Digest:    "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+		},
+		Layers: []distribution.Descriptor{
+			{
+				MediaType: "application/spdx+json",
+				Size:      153263,
+				Digest:    "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b",
+			},
+		},
+	}
+
+	deserialized, err := FromStruct(manifest)
+	require.NoError(t, err)
+
+	_, canonical, _ := deserialized.Payload()
+	require.Truef(t, bytes.Equal(expectedManifestSerialization, canonical),
+		"manifest bytes not equal: %q != %q", string(canonical), string(expectedManifestSerialization))
+
+	var unmarshalled DeserializedManifest
+	require.NoError(t, json.Unmarshal(canonical, &unmarshalled))
+	require.Equal(t, "application/vnd.example.sbom.v1", unmarshalled.ArtifactType())
+}
+
 func mediaTypeTest(t *testing.T, mediaType string, shouldError bool) {
 	manifest := makeTestManifest(mediaType)
 
@@ -314,6 +362,16 @@ func TestDistributableLayers(t *testing.T) {
 				Size:      977463,
 				MediaType: "application/vnd.foo.image.layer.v0.tar+gzip",
 			},
+			{
+				Digest:    "sha256:0a2b1f6eb8e5e5bb1b2a0c5ee2de3d3a8c07c5ef8f34c19c3a0a0a6d4dd0b9c1",
+				Size:      5412,
+				MediaType: "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip+encrypted",
+			},
+			{
+				Digest:    "sha256:9c4e1e2b6e9c4ebf7c8b1a4a0f2b76b5f5d0a8c6e2e7bb8c4fbd3a5c2f1e7d60",
+				Size:      8231,
+				MediaType: "application/vnd.oci.image.layer.v1.tar+gzip+encrypted",
+			},
 		},
 	}
 
@@ -331,8 +389,71 @@ func TestDistributableLayers(t *testing.T) {
 			Size:      977463,
 			MediaType: "application/vnd.foo.image.layer.v0.tar+gzip",
 		},
+		{
+			Digest:    "sha256:9c4e1e2b6e9c4ebf7c8b1a4a0f2b76b5f5d0a8c6e2e7bb8c4fbd3a5c2f1e7d60",
+			Size:      8231,
+			MediaType: "application/vnd.oci.image.layer.v1.tar+gzip+encrypted",
+		},
 	}
 
 	dls := dm.DistributableLayers()
 	require.Equal(t, expectedLayers, dls)
 }
+
+func TestEncryptedLayers(t *testing.T) {
+	tcs := []struct {
+		mediaType        string
+		encrypted        bool
+		decrypted        string
+		nonDistributable bool
+	}{
+		{v1.MediaTypeImageLayerGzip, false, v1.MediaTypeImageLayerGzip, false},
+		{"application/vnd.oci.image.layer.v1.tar+encrypted", true, v1.MediaTypeImageLayer, false},
+		{"application/vnd.oci.image.layer.v1.tar+gzip+encrypted", true, v1.MediaTypeImageLayerGzip, false},
+		{"application/vnd.oci.image.layer.v1.tar+zstd+encrypted", true, "application/vnd.oci.image.layer.v1.tar+zstd", false},
+		{v1.MediaTypeImageLayerNonDistributable, false, v1.MediaTypeImageLayerNonDistributable, true},
+		{"application/vnd.oci.image.layer.nondistributable.v1.tar+gzip+encrypted", true, v1.MediaTypeImageLayerNonDistributableGzip, true},
+		{"+encrypted", false, "+encrypted", false},
+	}
+
+	for _, tc := range tcs {
+		require.Equal(t, tc.encrypted, IsEncryptedLayer(tc.mediaType), tc.mediaType)
+		require.Equal(t, tc.decrypted, DecryptedLayerMediaType(tc.mediaType), tc.mediaType)
+		require.Equal(t, tc.nonDistributable, IsNonDistributableLayer(tc.mediaType), tc.mediaType)
+	}
+}
+
+func TestEncryptedLayerAnnotations(t *testing.T) {
+	// ocicrypt keeps the wrapped keys and the encryption options of each layer in its annotations
+	payload := []byte(`{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.oci.image.manifest.v1+json",
+   "config": {
+      "mediaType": "application/vnd.oci.image.config.v1+json",
+      "size": 985,
+      "digest": "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b"
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.oci.image.layer.v1.tar+gzip+encrypted",
+         "size": 8231,
+         "digest": "sha256:9c4e1e2b6e9c4ebf7c8b1a4a0f2b76b5f5d0a8c6e2e7bb8c4fbd3a5c2f1e7d60",
+         "annotations": {
+            "org.opencontainers.image.enc.keys.jwe": "ZXlKaGJHY2lPaUpTVTBFdFQwRkZVQ0lzSW1WdVl5STZJa0V5TlRaSFEwMGlmUT09",
+            "org.opencontainers.image.enc.pubopts": "eyJjaXBoZXIiOiJBRVNfMjU2X0NUUl9ITUFDX1NIQTI1NiJ9"
+         }
+      }
+   ]
+}`)
+
+	var dm DeserializedManifest
+	require.NoError(t, dm.UnmarshalJSON(payload))
+	require.Equal(t, map[string]string{
+		"org.opencontainers.image.enc.keys.jwe": "ZXlKaGJHY2lPaUpTVTBFdFQwRkZVQ0lzSW1WdVl5STZJa0V5TlRaSFEwMGlmUT09",
+		"org.opencontainers.image.enc.pubopts":  "eyJjaXBoZXIiOiJBRVNfMjU2X0NUUl9ITUFDX1NIQTI1NiJ9",
+	}, dm.Layers()[0].Annotations)
+
+	_, p, err := dm.Payload()
+	require.NoError(t, err)
+	require.Equal(t, payload, p)
+}
@@ -0,0 +1,134 @@
+package schema1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+// LayerDescriber describes the layers referenced by a schema1 manifest. The
+// size and uncompressed digest of layers are not recorded in schema1
+// manifests, but are required to convert them to schema2.
+type LayerDescriber interface {
+	// Describe returns the descriptor of the layer blob with the given
+	// digest, along with the digest of its uncompressed contents (diff ID).
+	Describe(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, digest.Digest, error)
+}
+
+// ToSchema2 converts a schema1 manifest to an equivalent schema2 manifest.
+// The image configuration is built from the v1 compatibility history of the
+// manifest and published to bs if it doesn't exist there yet. Layers marked
+// as throwaway in the history are omitted, as they are in schema2 manifests.
+func ToSchema2(ctx context.Context, m *SignedManifest, bs distribution.BlobService, ld LayerDescriber) (*schema2.DeserializedManifest, error) {
+	if len(m.History) == 0 {
+		return nil, errors.New("empty history when trying to convert schema1 manifest")
+	}
+	if len(m.FSLayers) != len(m.History) {
+		return nil, fmt.Errorf("number of layers and number of history entries must match: len(%v) != len(%v)", m.FSLayers, m.History)
+	}
+
+	type v1Compatibility struct {
+		Created         time.Time `json:"created"`
+		Author          string    `json:"author,omitempty"`
+		Comment         string    `json:"comment,omitempty"`
+		ContainerConfig struct {
+			Cmd []string
+		} `json:"container_config,omitempty"`
+		ThrowAway bool `json:"throwaway,omitempty"`
+	}
+
+	var (
+		history []imageHistory
+		diffIDs []diffID
+		layers  []distribution.Describable
+	)
+
+	// History and layers are listed from the top-most to the base one in
+	// schema1 manifests, while schema2 lists them the other way around.
+	for i := len(m.History) - 1; i >= 0; i-- {
+		var v1c v1Compatibility
+		if err := json.Unmarshal([]byte(m.History[i].V1Compatibility), &v1c); err != nil {
+			return nil, fmt.Errorf("unmarshaling v1 compatibility history %d: %w", i, err)
+		}
+
+		history = append(history, imageHistory{
+			Created:    v1c.Created,
+			Author:     v1c.Author,
+			CreatedBy:  strings.Join(v1c.ContainerConfig.Cmd, " "),
+			Comment:    v1c.Comment,
+			EmptyLayer: v1c.ThrowAway,
+		})
+		if v1c.ThrowAway {
+			continue
+		}
+
+		desc, dID, err := ld.Describe(ctx, m.FSLayers[i].BlobSum)
+		if err != nil {
+			return nil, fmt.Errorf("describing layer %s: %w", m.FSLayers[i].BlobSum, err)
+		}
+		desc.MediaType = schema2.MediaTypeLayer
+		layers = append(layers, desc)
+		diffIDs = append(diffIDs, diffID(dID))
+	}
+
+	configJSON, err := makeConfigFromV1Config([]byte(m.History[0].V1Compatibility), diffIDs, history)
+	if err != nil {
+		return nil, err
+	}
+
+	mb := schema2.NewManifestBuilder(bs, schema2.MediaTypeImageConfig, configJSON)
+	for _, l := range layers {
+		if err := mb.AppendReference(l); err != nil {
+			return nil, err
+		}
+	}
+
+	built, err := mb.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return built.(*schema2.DeserializedManifest), nil
+}
+
+// imageHistory is a history entry of an image configuration.
+type imageHistory struct {
+	Created    time.Time `json:"created"`
+	Author     string    `json:"author,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
+
+// makeConfigFromV1Config creates an image config from the top-most legacy V1
+// image config of a schema1 manifest, the diff IDs of its layers and its
+// history, both ordered from the base layer to the top-most one.
+func makeConfigFromV1Config(v1ConfigJSON []byte, diffIDs []diffID, history []imageHistory) ([]byte, error) {
+	var configAsMap map[string]*json.RawMessage
+	if err := json.Unmarshal(v1ConfigJSON, &configAsMap); err != nil {
+		return nil, err
+	}
+
+	// Delete fields that don't exist in image configs
+	for _, k := range []string{"id", "parent", "Size", "parent_id", "layer_id", "throwaway"} {
+		delete(configAsMap, k)
+	}
+
+	if diffIDs == nil {
+		diffIDs = make([]diffID, 0)
+	}
+	configAsMap["rootfs"] = rawJSON(struct {
+		Type    string   `json:"type"`
+		DiffIDs []diffID `json:"diff_ids"`
+	}{Type: "layers", DiffIDs: diffIDs})
+	configAsMap["history"] = rawJSON(history)
+
+	return json.Marshal(configAsMap)
+}
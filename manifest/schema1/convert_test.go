@@ -0,0 +1,210 @@
+package schema1
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/libtrust"
+	"github.com/opencontainers/go-digest"
+)
+
+type mockLayerDescriber struct {
+	diffIDs map[digest.Digest]digest.Digest
+}
+
+func (ld *mockLayerDescriber) Describe(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, digest.Digest, error) {
+	if dID, ok := ld.diffIDs[dgst]; ok {
+		return distribution.Descriptor{Digest: dgst, Size: 10, MediaType: "application/octet-stream"}, dID, nil
+	}
+	return distribution.Descriptor{}, "", distribution.ErrBlobUnknown
+}
+
+// recordingBlobService is a mockBlobService that keeps the contents of the blobs put in it.
+type recordingBlobService struct {
+	*mockBlobService
+	blobs map[digest.Digest][]byte
+}
+
+func (bs *recordingBlobService) Put(ctx context.Context, mediaType string, p []byte) (distribution.Descriptor, error) {
+	d, err := bs.mockBlobService.Put(ctx, mediaType, p)
+	if err == nil {
+		bs.blobs[d.Digest] = p
+	}
+	return d, err
+}
+
+func TestToSchema2(t *testing.T) {
+	imgJSON := `{
+    "architecture": "amd64",
+    "config": {
+        "Cmd": ["/bin/sh", "-c", "echo hi"],
+        "Env": ["PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"]
+    },
+    "container_config": {
+        "Cmd": ["/bin/sh", "-c", "#(nop) CMD [\"/bin/sh\" \"-c\" \"echo hi\"]"]
+    },
+    "created": "2015-11-04T23:06:32.365666163Z",
+    "docker_version": "1.9.0-dev",
+    "history": [
+        {
+            "created": "2015-10-31T22:22:54.690851953Z",
+            "created_by": "/bin/sh -c #(nop) ADD file:a3bc1e842b69636f9df5256c49c5374fb4eef1e281fe3f282c65fb853ee171c5 in /"
+        },
+        {
+            "created": "2015-11-04T23:06:30.934316144Z",
+            "created_by": "/bin/sh -c #(nop) ENV derived=true",
+            "empty_layer": true
+        },
+        {
+            "author": "Alyssa P. Hacker <alyspdev@example.com>",
+            "created": "2015-11-04T23:06:32.083868454Z",
+            "created_by": "/bin/sh -c dd if=/dev/zero of=/file bs=1024 count=1024"
+        },
+        {
+            "created": "2015-11-04T23:06:32.365666163Z",
+            "created_by": "/bin/sh -c #(nop) CMD [\"/bin/sh\" \"-c\" \"echo hi\"]",
+            "empty_layer": true
+        }
+    ],
+    "os": "linux",
+    "rootfs": {
+        "diff_ids": [
+            "sha256:c6f988f4874bb0add23a778f753c65efe992244e148a1d2ec2a8b664fb66bbd1",
+            "sha256:13f53e08df5a220ab6d13c58b2bf83a59cbdc2e04d0a3f041ddf4b0ba4112d49"
+        ],
+        "type": "layers"
+    }
+}`
+
+	layers := []digest.Digest{
+		"sha256:86e0e091d0da6bde2456dbb48306f3956bbeb2eae1b5b9a43045843f69fe4aaa",
+		"sha256:b4ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4",
+	}
+	diffIDs := []digest.Digest{
+		"sha256:c6f988f4874bb0add23a778f753c65efe992244e148a1d2ec2a8b664fb66bbd1",
+		"sha256:13f53e08df5a220ab6d13c58b2bf83a59cbdc2e04d0a3f041ddf4b0ba4112d49",
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("could not generate key for testing: %v", err)
+	}
+
+	bs := &recordingBlobService{
+		mockBlobService: &mockBlobService{descriptors: make(map[digest.Digest]distribution.Descriptor)},
+		blobs:           make(map[digest.Digest][]byte),
+	}
+
+	ref, err := reference.WithName("testrepo")
+	if err != nil {
+		t.Fatalf("could not parse reference: %v", err)
+	}
+
+	// build a schema1 manifest out of a known image configuration, then convert it back
+	builder := NewConfigManifestBuilder(bs, pk, ref, []byte(imgJSON))
+	for _, d := range layers {
+		if err := builder.AppendReference(distribution.Descriptor{Digest: d}); err != nil {
+			t.Fatalf("AppendReference returned error: %v", err)
+		}
+	}
+	signed, err := builder.Build(dcontext.Background())
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+
+	ld := &mockLayerDescriber{diffIDs: map[digest.Digest]digest.Digest{layers[0]: diffIDs[0], layers[1]: diffIDs[1]}}
+
+	m, err := ToSchema2(dcontext.Background(), signed.(*SignedManifest), bs, ld)
+	if err != nil {
+		t.Fatalf("ToSchema2 returned error: %v", err)
+	}
+
+	if m.SchemaVersion != 2 || m.MediaType != schema2.MediaTypeManifest {
+		t.Fatal("converted manifest is not a schema2 manifest")
+	}
+
+	expectedLayers := []distribution.Descriptor{
+		{MediaType: schema2.MediaTypeLayer, Size: 10, Digest: layers[0]},
+		{MediaType: schema2.MediaTypeLayer, Size: 10, Digest: layers[1]},
+	}
+	if !reflect.DeepEqual(m.Manifest.Layers, expectedLayers) {
+		t.Fatalf("wrong layers list: %v", m.Manifest.Layers)
+	}
+
+	// the image configuration must have been put in the blob store
+	if m.Manifest.Config.MediaType != schema2.MediaTypeImageConfig {
+		t.Fatalf("wrong config media type: %s", m.Manifest.Config.MediaType)
+	}
+	if _, err := bs.Stat(dcontext.Background(), m.Manifest.Config.Digest); err != nil {
+		t.Fatal("image configuration was not put in the blob store")
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(bs.blobs[m.Manifest.Config.Digest], &config); err != nil {
+		t.Fatalf("could not unmarshal image configuration: %v", err)
+	}
+	for _, k := range []string{"id", "parent", "throwaway"} {
+		if _, ok := config[k]; ok {
+			t.Errorf("image configuration has unexpected key %q", k)
+		}
+	}
+	if string(config["architecture"]) != `"amd64"` {
+		t.Errorf("wrong architecture: %s", config["architecture"])
+	}
+	if string(config["config"]) != `{"Cmd":["/bin/sh","-c","echo hi"],"Env":["PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"]}` {
+		t.Errorf("wrong container configuration: %s", config["config"])
+	}
+
+	expectedRootFS := `{"type":"layers","diff_ids":["sha256:c6f988f4874bb0add23a778f753c65efe992244e148a1d2ec2a8b664fb66bbd1","sha256:13f53e08df5a220ab6d13c58b2bf83a59cbdc2e04d0a3f041ddf4b0ba4112d49"]}`
+	if string(config["rootfs"]) != expectedRootFS {
+		t.Errorf("wrong rootfs. expected:\n%s\ngot:\n%s", expectedRootFS, config["rootfs"])
+	}
+
+	expectedHistory := `[` +
+		`{"created":"2015-10-31T22:22:54.690851953Z","created_by":"/bin/sh -c #(nop) ADD file:a3bc1e842b69636f9df5256c49c5374fb4eef1e281fe3f282c65fb853ee171c5 in /"},` +
+		`{"created":"2015-11-04T23:06:30.934316144Z","created_by":"/bin/sh -c #(nop) ENV derived=true","empty_layer":true},` +
+		`{"created":"2015-11-04T23:06:32.083868454Z","author":"Alyssa P. Hacker \u003calyspdev@example.com\u003e","created_by":"/bin/sh -c dd if=/dev/zero of=/file bs=1024 count=1024"},` +
+		`{"created":"2015-11-04T23:06:32.365666163Z","created_by":"/bin/sh -c #(nop) CMD [\"/bin/sh\" \"-c\" \"echo hi\"]","empty_layer":true}` +
+		`]`
+	if string(config["history"]) != expectedHistory {
+		t.Errorf("wrong history. expected:\n%s\ngot:\n%s", expectedHistory, config["history"])
+	}
+
+	// conversions are deterministic
+	again, err := ToSchema2(dcontext.Background(), signed.(*SignedManifest), bs, ld)
+	if err != nil {
+		t.Fatalf("ToSchema2 returned error: %v", err)
+	}
+	_, p1, _ := m.Payload()
+	_, p2, _ := again.Payload()
+	if string(p1) != string(p2) {
+		t.Fatal("converting the same manifest twice produced different manifests")
+	}
+}
+
+func TestToSchema2_Invalid(t *testing.T) {
+	bs := &mockBlobService{descriptors: make(map[digest.Digest]distribution.Descriptor)}
+	ld := &mockLayerDescriber{}
+
+	m := &SignedManifest{}
+	if _, err := ToSchema2(dcontext.Background(), m, bs, ld); err == nil {
+		t.Fatal("expected error converting manifest without history")
+	}
+
+	m.FSLayers = []FSLayer{{BlobSum: digestSHA256GzippedEmptyTar}}
+	m.History = []History{{V1Compatibility: `{}`}, {V1Compatibility: `{}`}}
+	if _, err := ToSchema2(dcontext.Background(), m, bs, ld); err == nil {
+		t.Fatal("expected error converting manifest with mismatching layers and history")
+	}
+
+	m.History = m.History[:1]
+	if _, err := ToSchema2(dcontext.Background(), m, bs, ld); err == nil {
+		t.Fatal("expected error converting manifest with unknown layers")
+	}
+}
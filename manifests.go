@@ -53,6 +53,10 @@ type ManifestOCI interface {
 	// This value, used by the referrers API, indicates a relationship
 	// to the specified manifest.
 	Subject() Descriptor
+
+	// This OPTIONAL property contains the type of an artifact when the manifest
+	// is used for an artifact.
+	ArtifactType() string
 }
 
 // ManifestBuilder creates a manifest allowing one to include dependencies.
@@ -8,6 +8,7 @@ import (
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/notifications/meta"
 	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/tracing"
 	"github.com/docker/distribution/uuid"
 	"github.com/opencontainers/go-digest"
 )
@@ -48,11 +49,12 @@ func NewBridge(ub URLBuilder, source SourceRecord, actor ActorRecord, request Re
 // http.Request, associating it with a request id.
 func NewRequestRecord(id string, r *http.Request) RequestRecord {
 	return RequestRecord{
-		ID:        id,
-		Addr:      context.RemoteAddr(r),
-		Host:      r.Host,
-		Method:    r.Method,
-		UserAgent: r.UserAgent(),
+		ID:           id,
+		Addr:         context.RemoteAddr(r),
+		Host:         r.Host,
+		Method:       r.Method,
+		UserAgent:    r.UserAgent(),
+		TraceContext: tracing.TraceContextFrom(r.Context()),
 	}
 }
 
@@ -10,6 +10,7 @@ import (
 // EndpointConfig covers the optional configuration parameters for an active
 // endpoint.
 type EndpointConfig struct {
+	Type              string
 	Headers           http.Header
 	Timeout           time.Duration
 	Threshold         int
@@ -17,10 +18,19 @@ type EndpointConfig struct {
 	IgnoredMediaTypes []string
 	Transport         *http.Transport `json:"-"`
 	Ignore            configuration.Ignore
+	Kafka             configuration.KafkaEndpoint
+	NATS              configuration.NATSEndpoint
+	Queue             QueueConfig
+	TLS               configuration.EndpointTLS
+	Signing           configuration.EndpointSigning `json:"-"`
 }
 
 // defaults set any zero-valued fields to a reasonable default.
 func (ec *EndpointConfig) defaults() {
+	if ec.Type == "" {
+		ec.Type = configuration.EndpointTypeHTTP
+	}
+
 	if ec.Timeout <= 0 {
 		ec.Timeout = time.Second
 	}
@@ -36,10 +46,29 @@ func (ec *EndpointConfig) defaults() {
 	if ec.Transport == nil {
 		ec.Transport = http.DefaultTransport.(*http.Transport)
 	}
+
+	if ec.Signing.Secret != "" && ec.Signing.Header == "" {
+		ec.Signing.Header = DefaultSignatureHeader
+	}
+
+	if ec.Queue.Store != nil {
+		if ec.Queue.MaxAttempts <= 0 {
+			ec.Queue.MaxAttempts = 10
+		}
+		if ec.Queue.Interval <= 0 {
+			ec.Queue.Interval = 5 * time.Second
+		}
+	}
 }
 
-// Endpoint is a reliable, queued, thread-safe sink that notify external http
-// services when events are written. Writes are non-blocking and always
+// backlog is implemented by the endpoint queues to report how far behind delivery is.
+type backlog interface {
+	// oldestPending returns the timestamp of the oldest event pending delivery, or the zero time if there are none.
+	oldestPending() time.Time
+}
+
+// Endpoint is a reliable, queued, thread-safe sink that notify external
+// services when events are written, through the transport matching its type. Writes are non-blocking and always
 // succeed for callers but events may be queued internally.
 type Endpoint struct {
 	Sink
@@ -49,9 +78,11 @@ type Endpoint struct {
 	EndpointConfig
 
 	metrics *safeMetrics
+	queue   backlog
 }
 
-// NewEndpoint returns a running endpoint, ready to receive events.
+// NewEndpoint returns a running endpoint, ready to receive events. The
+// configuration is expected to have been checked with Validate beforehand.
 func NewEndpoint(name, url string, config EndpointConfig) *Endpoint {
 	var endpoint Endpoint
 	endpoint.name = name
@@ -60,12 +91,15 @@ func NewEndpoint(name, url string, config EndpointConfig) *Endpoint {
 	endpoint.defaults()
 	endpoint.metrics = newSafeMetrics(name)
 
-	// Configures the inmemory queue, retry, http pipeline.
-	endpoint.Sink = newHTTPSink(
-		endpoint.url, endpoint.Timeout, endpoint.Headers,
-		endpoint.Transport, endpoint.metrics.httpStatusListener())
-	endpoint.Sink = newRetryingSink(endpoint.Sink, endpoint.Threshold, endpoint.Backoff)
-	endpoint.Sink = newEventQueue(endpoint.Sink, endpoint.metrics.eventQueueListener())
+	// Configures the queue, retry, transport pipeline. The queue is persisted if a store is set, or kept in memory.
+	endpoint.Sink = transports[endpoint.Type].newSink(&endpoint)
+	if endpoint.Queue.Store != nil {
+		pq := newPersistentQueue(name, endpoint.Sink, endpoint.Queue.Store, endpoint.Queue.Interval, endpoint.metrics.eventQueueListener())
+		endpoint.Sink, endpoint.queue = pq, pq
+	} else {
+		eq := newEventQueue(endpoint.Sink, endpoint.metrics.eventQueueListener())
+		endpoint.Sink, endpoint.queue = eq, eq
+	}
 	mediaTypes := append(config.Ignore.MediaTypes, config.IgnoredMediaTypes...)
 	endpoint.Sink = newIgnoredSink(endpoint.Sink, mediaTypes, config.Ignore.Actions)
 
@@ -73,6 +107,12 @@ func NewEndpoint(name, url string, config EndpointConfig) *Endpoint {
 	return &endpoint
 }
 
+// Close closes the endpoint, flushing the events pending delivery, and stops tracking its stats.
+func (e *Endpoint) Close() error {
+	unregister(e)
+	return e.Sink.Close()
+}
+
 // Name returns the name of the endpoint, generally used for debugging.
 func (e *Endpoint) Name() string {
 	return e.name
@@ -83,6 +123,12 @@ func (e *Endpoint) URL() string {
 	return e.url
 }
 
+// OldestPending returns the timestamp of the oldest event pending delivery to the endpoint, or the zero time if there
+// are none.
+func (e *Endpoint) OldestPending() time.Time {
+	return e.queue.oldestPending()
+}
+
 // ReadMetrics populates em with metrics from the endpoint.
 func (e *Endpoint) ReadMetrics(em *EndpointMetrics) {
 	e.metrics.Lock()
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/tracing"
 )
 
 // EventAction constants used in action field of Event.
@@ -90,6 +91,9 @@ type Target struct {
 	// Tag provides the tag
 	Tag string `json:"tag,omitempty"`
 
+	// Tags provides the list of tags affected by a bulk operation, such as a bulk tag delete.
+	Tags []string `json:"tags,omitempty"`
+
 	// References provides the references descriptors.
 	References []distribution.Descriptor `json:"references,omitempty"`
 }
@@ -131,6 +135,10 @@ type RequestRecord struct {
 
 	// UserAgent contains the user agent header of the request.
 	UserAgent string `json:"useragent"`
+
+	// TraceContext holds the trace context of the request, used to propagate it to event deliveries. It's not part of
+	// the event payload.
+	TraceContext tracing.TraceContext `json:"-"`
 }
 
 // SourceRecord identifies the registry node that generated the event. Put
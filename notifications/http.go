@@ -2,19 +2,42 @@ package notifications
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// DefaultSignatureHeader is the request header holding the signature of the
+// payload of webhook requests, unless configured otherwise.
+const DefaultSignatureHeader = "X-Registry-Signature-256"
+
 // httpSink implements a single-flight, http notification endpoint. This is
 // very lightweight in that it only makes an attempt at an http request.
 // Reliability should be provided by the caller.
 type httpSink struct {
 	url string
 
+	// signingSecret is the key used to sign request bodies, if not empty.
+	signingSecret   string
+	signatureHeader string
+
 	mu        sync.Mutex
 	closed    bool
 	client    *http.Client
@@ -71,16 +94,39 @@ func (hs *httpSink) Write(event *Event) error {
 		return fmt.Errorf("%v: error marshaling event envelope: %v", hs, err)
 	}
 
-	body := bytes.NewReader(p)
-	resp, err := hs.client.Post(hs.url, EventsMediaType, body)
+	// continue the trace of the request that originated the event, if any
+	ctx, span := tracing.StartSpan(tracing.WithTraceContext(context.Background(), event.Request.TraceContext), "notifications.deliver",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("notifications.action", event.Action)),
+	)
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hs.url, bytes.NewReader(p))
 	if err != nil {
 		for _, listener := range hs.listeners {
 			listener.err(event)
 		}
+		return fmt.Errorf("%v: error creating request: %v", hs, err)
+	}
+	req.Header.Set("Content-Type", EventsMediaType)
+	if hs.signingSecret != "" {
+		req.Header.Set(hs.signatureHeader, SignPayload(hs.signingSecret, p))
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		for _, listener := range hs.listeners {
+			listener.err(event)
+		}
 
 		return fmt.Errorf("%v: error posting: %v", hs, err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, trace.SpanKindClient))
 
 	// The notifier will treat any 2xx or 3xx response as accepted by the
 	// endpoint.
@@ -137,3 +183,57 @@ func (hrt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, err
 
 	return hrt.Transport.RoundTrip(&nreq)
 }
+
+// SignPayload returns the signature of a webhook request body p, as sent in
+// the signature header: the hex encoded HMAC-SHA256 of p using secret as the
+// key, prefixed with `sha256=`. Receivers should compute the signature of the
+// body they received and compare it to the header value in constant time.
+func SignPayload(secret string, p []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(p)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateHTTPConfig checks the TLS and signing settings of an http endpoint.
+func validateHTTPConfig(config EndpointConfig) error {
+	if config.Signing.Secret == "" && config.Signing.Header != "" {
+		return errors.New("http: a signing secret is required to set the signature header")
+	}
+	if _, err := httpTLSConfig(config.TLS); err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	return nil
+}
+
+// httpTLSConfig builds the client TLS configuration for an http endpoint. It
+// returns nil if no TLS settings are set, so that the defaults of the
+// transport apply.
+func httpTLSConfig(config configuration.EndpointTLS) (*tls.Config, error) {
+	if config == (configuration.EndpointTLS{}) {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.Insecure,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading TLS CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in TLS CA file %q", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -1,20 +1,35 @@
 package notifications
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"math/big"
 	"mime"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/tracing"
 	"github.com/stretchr/testify/require"
 )
 
@@ -262,3 +277,167 @@ func TestHTTPSink_Errors(t *testing.T) {
 
 	require.Equal(t, metrics.EndpointMetrics, expectedMetrics)
 }
+
+func TestHTTPSink_TraceContext(t *testing.T) {
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := newHTTPSink(server.URL, 0, nil, nil)
+	defer sink.Close()
+
+	event := createTestEvent("push", "library/test", layerMediaType)
+	event.Request.TraceContext = tracing.TraceContext{TraceParent: traceParent}
+	require.NoError(t, sink.Write(&event))
+
+	// the delivery continues the trace of the originating request
+	require.Equal(t, traceParent, got.Get("traceparent"))
+
+	// events without trace context are delivered without it
+	event.Request.TraceContext = tracing.TraceContext{}
+	require.NoError(t, sink.Write(&event))
+	require.Empty(t, got.Get("traceparent"))
+}
+
+func TestHTTPSink_Signing(t *testing.T) {
+	var header string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		header = r.Header.Get(DefaultSignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := newTestHTTPEndpointSink(t, server.URL, EndpointConfig{
+		Signing: configuration.EndpointSigning{Secret: "s3cr3t"},
+	})
+	defer sink.Close()
+
+	event := createTestEvent("push", "library/test", layerMediaType)
+	require.NoError(t, sink.Write(&event))
+
+	require.NotEmpty(t, body)
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(body)
+	require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), header)
+	require.Equal(t, header, SignPayload("s3cr3t", body))
+}
+
+func TestHTTPSink_NoSigning(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := newHTTPSink(server.URL, 0, nil, nil)
+	defer sink.Close()
+
+	event := createTestEvent("push", "library/test", layerMediaType)
+	require.NoError(t, sink.Write(&event))
+	require.Empty(t, got.Get(DefaultSignatureHeader))
+}
+
+func TestHTTPSink_MutualTLS(t *testing.T) {
+	clientCert, clientKey := writeTestClientCertificate(t)
+	clientPool := x509.NewCertPool()
+	pem, err := os.ReadFile(clientCert)
+	require.NoError(t, err)
+	require.True(t, clientPool.AppendCertsFromPEM(pem))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientPool}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pemEncode("CERTIFICATE", server.Certificate().Raw)
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+
+	write := func(config configuration.EndpointTLS) error {
+		httpTLS, err := httpTLSConfig(config)
+		require.NoError(t, err)
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = httpTLS
+		sink := newHTTPSink(server.URL, time.Second, nil, tr)
+		defer sink.Close()
+
+		event := createTestEvent("push", "library/test", layerMediaType)
+		return sink.Write(&event)
+	}
+
+	// the server certificate is trusted and the client certificate is presented
+	require.NoError(t, write(configuration.EndpointTLS{CAFile: caFile, CertFile: clientCert, KeyFile: clientKey}))
+	// the server requires a client certificate
+	require.Error(t, write(configuration.EndpointTLS{CAFile: caFile}))
+	// the server certificate is not trusted
+	require.Error(t, write(configuration.EndpointTLS{CertFile: clientCert, KeyFile: clientKey}))
+
+	// endpoints deliver with the configured TLS settings
+	sink := newTestHTTPEndpointSink(t, server.URL, EndpointConfig{
+		TLS: configuration.EndpointTLS{CAFile: caFile, CertFile: clientCert, KeyFile: clientKey},
+	})
+	defer sink.Close()
+
+	event := createTestEvent("push", "library/test", layerMediaType)
+	require.NoError(t, sink.Write(&event))
+}
+
+// newTestHTTPEndpointSink builds the delivery sink of an http endpoint with the given configuration, without the queue
+// in front of it or registering the endpoint.
+func newTestHTTPEndpointSink(t *testing.T, url string, config EndpointConfig) Sink {
+	t.Helper()
+
+	e := &Endpoint{name: t.Name(), url: url, EndpointConfig: config, metrics: newSafeMetrics(t.Name())}
+	e.defaults()
+	require.NoError(t, e.Validate())
+
+	return transports[configuration.EndpointTypeHTTP].newSink(e)
+}
+
+// writeTestClientCertificate writes a self-signed client certificate and its key to PEM files, returning their paths.
+func writeTestClientCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "registry"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pemEncode("CERTIFICATE", der), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pemEncode("EC PRIVATE KEY", keyDER), 0o600))
+
+	return certFile, keyFile
+}
+
+func pemEncode(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
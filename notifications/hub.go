@@ -0,0 +1,123 @@
+package notifications
+
+import (
+	"fmt"
+	"sync"
+)
+
+// hubSubscriptionBufferSize is the number of events buffered for each hub subscription. Events are dropped for
+// subscribers that fall behind by more than this, so that a slow client never holds back the delivery of events.
+const hubSubscriptionBufferSize = 64
+
+// Hub is a sink that fans out events to in-process subscribers, such as clients streaming the events of a repository.
+// Unlike endpoints, delivery is best-effort: events are not retried nor persisted, and only subscribers connected at
+// the time an event is written receive it.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[*hubSubscription]struct{}
+	closed bool
+	// disconnected is set once all subscriptions are closed with Disconnect. Subscriptions are then refused.
+	disconnected bool
+}
+
+type hubSubscription struct {
+	repository string
+	events     chan *Event
+}
+
+// NewHub returns a new Hub without subscribers.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*hubSubscription]struct{})}
+}
+
+// Subscribe registers a subscriber for the events of repository. Events are received through the returned channel,
+// which is closed when the subscription is cancelled with the returned function or the hub is closed or disconnected.
+// Cancelling a subscription more than once is a no-op.
+func (h *Hub) Subscribe(repository string) (<-chan *Event, func()) {
+	sub := &hubSubscription{
+		repository: repository,
+		events:     make(chan *Event, hubSubscriptionBufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed || h.disconnected {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub.events, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.subs[sub]; ok {
+			delete(h.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subs)
+}
+
+// Write delivers event to the subscribers of the target repository. It never blocks: the event is dropped for
+// subscribers whose buffer is full.
+func (h *Hub) Write(event *Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return ErrSinkClosed
+	}
+
+	for sub := range h.subs {
+		if sub.repository != event.Target.Repository {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Disconnect closes all subscriptions and refuses new ones, so that subscribers can finish gracefully during
+// shutdown. Unlike Close, the hub keeps accepting events, which are simply discarded.
+func (h *Hub) Disconnect() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.disconnected = true
+	h.closeSubscriptions()
+}
+
+// Close closes the hub and all of its subscriptions.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return fmt.Errorf("hub: already closed")
+	}
+	h.closed = true
+	h.closeSubscriptions()
+
+	return nil
+}
+
+// closeSubscriptions closes all subscriptions. Must be called with the mutex held.
+func (h *Hub) closeSubscriptions() {
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,64 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+
+	fooEvents, cancelFoo := h.Subscribe("library/foo")
+	barEvents, cancelBar := h.Subscribe("library/bar")
+	defer cancelBar()
+	require.Equal(t, 2, h.Subscribers())
+
+	event := createTestEvent("push", "library/foo", "blob")
+	require.NoError(t, h.Write(&event))
+
+	require.Len(t, fooEvents, 1)
+	require.Equal(t, &event, <-fooEvents)
+	require.Empty(t, barEvents)
+
+	cancelFoo()
+	cancelFoo()
+	require.Equal(t, 1, h.Subscribers())
+	_, ok := <-fooEvents
+	require.False(t, ok)
+
+	// events are dropped for subscribers that fall behind
+	for i := 0; i < hubSubscriptionBufferSize+1; i++ {
+		event := createTestEvent("push", "library/bar", "blob")
+		require.NoError(t, h.Write(&event))
+	}
+	require.Len(t, barEvents, hubSubscriptionBufferSize)
+
+	require.NoError(t, h.Close())
+	require.Error(t, h.Close())
+	require.Zero(t, h.Subscribers())
+	require.ErrorIs(t, h.Write(&event), ErrSinkClosed)
+
+	events, _ := h.Subscribe("library/foo")
+	_, ok = <-events
+	require.False(t, ok)
+}
+
+func TestHub_Disconnect(t *testing.T) {
+	h := NewHub()
+	defer h.Close()
+
+	events, cancel := h.Subscribe("library/foo")
+	h.Disconnect()
+	cancel()
+	require.Zero(t, h.Subscribers())
+	_, ok := <-events
+	require.False(t, ok)
+
+	// events are still accepted but new subscriptions are refused
+	event := createTestEvent("push", "library/foo", "blob")
+	require.NoError(t, h.Write(&event))
+	events, _ = h.Subscribe("library/foo")
+	_, ok = <-events
+	require.False(t, ok)
+}
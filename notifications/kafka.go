@@ -0,0 +1,261 @@
+package notifications
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultKafkaBatchSize    = 100
+	defaultKafkaBatchTimeout = time.Second
+
+	// kafkaWriterFlushInterval is how often the producer flushes incomplete per-partition batches. Events are already
+	// batched by the sink, so this is kept short to avoid delaying their delivery any further.
+	kafkaWriterFlushInterval = 10 * time.Millisecond
+)
+
+func validateKafkaConfig(config EndpointConfig) error {
+	if len(config.Kafka.Brokers) == 0 {
+		return errors.New("kafka: at least one broker must be configured")
+	}
+	if config.Kafka.Topic == "" {
+		return errors.New("kafka: topic must be set")
+	}
+	return nil
+}
+
+// kafkaWriter is the subset of kafka.Writer used by kafkaSink.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaSink publishes events to a Kafka topic. Events are keyed by repository path, so that all events for a
+// repository land on the same partition and are consumed in order. Events are accumulated and published in batches.
+// A batch is retried until acknowledged by all in-sync replicas or the sink is closed, providing at-least-once
+// delivery. Consumers must therefore be prepared to handle duplicates, which can be detected using the event ID.
+type kafkaSink struct {
+	writer       kafkaWriter
+	topic        string
+	timeout      time.Duration
+	batchSize    int
+	batchTimeout time.Duration
+	backoff      time.Duration
+	listeners    []deliveryListener
+
+	events    chan *Event
+	closed    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newKafkaSink returns a sink that publishes events to the configured Kafka topic.
+func newKafkaSink(config configuration.KafkaEndpoint, timeout time.Duration, threshold int, backoff time.Duration, listeners ...deliveryListener) *kafkaSink {
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(config.Brokers...),
+		Topic:        config.Topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: kafkaWriterFlushInterval,
+		WriteTimeout: timeout,
+		MaxAttempts:  threshold,
+		RequiredAcks: kafka.RequireAll,
+	}
+	if config.BatchSize > 0 {
+		w.BatchSize = config.BatchSize
+	}
+	if config.TLS {
+		w.Transport = &kafka.Transport{TLS: &tls.Config{MinVersion: tls.VersionTLS12}}
+	}
+
+	return newKafkaSinkWithWriter(w, config, timeout, backoff, listeners...)
+}
+
+func newKafkaSinkWithWriter(w kafkaWriter, config configuration.KafkaEndpoint, timeout, backoff time.Duration, listeners ...deliveryListener) *kafkaSink {
+	ks := &kafkaSink{
+		writer:       w,
+		topic:        config.Topic,
+		timeout:      timeout,
+		batchSize:    config.BatchSize,
+		batchTimeout: config.BatchTimeout,
+		backoff:      backoff,
+		listeners:    listeners,
+		closed:       make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if ks.batchSize <= 0 {
+		ks.batchSize = defaultKafkaBatchSize
+	}
+	if ks.batchTimeout <= 0 {
+		ks.batchTimeout = defaultKafkaBatchTimeout
+	}
+	ks.events = make(chan *Event, ks.batchSize)
+
+	go ks.run()
+	return ks
+}
+
+// Write adds the event to the next batch. It blocks while the current batch is being published, which means that
+// events are held in the endpoint queue until Kafka is available.
+func (ks *kafkaSink) Write(event *Event) error {
+	select {
+	case <-ks.closed:
+		return ErrSinkClosed
+	default:
+	}
+
+	select {
+	case ks.events <- event:
+		return nil
+	case <-ks.closed:
+		return ErrSinkClosed
+	}
+}
+
+// Close makes a last attempt to publish pending events and closes the Kafka producer.
+func (ks *kafkaSink) Close() error {
+	err := fmt.Errorf("kafkasink: already closed")
+	ks.closeOnce.Do(func() {
+		close(ks.closed)
+		<-ks.done
+		err = ks.writer.Close()
+	})
+
+	return err
+}
+
+func (ks *kafkaSink) String() string {
+	return fmt.Sprintf("kafkaSink{%s}", ks.topic)
+}
+
+// run accumulates events in batches, publishing each one when full or after the batch timeout since its first event.
+func (ks *kafkaSink) run() {
+	defer close(ks.done)
+
+	var (
+		batch []*Event
+		flush <-chan time.Time
+	)
+	for {
+		select {
+		case event := <-ks.events:
+			if len(batch) == 0 {
+				flush = time.After(ks.batchTimeout)
+			}
+			batch = append(batch, event)
+			if len(batch) < ks.batchSize {
+				continue
+			}
+		case <-flush:
+		case <-ks.closed:
+			// Drain events accepted before closing and give them a single chance to be published.
+		drain:
+			for {
+				select {
+				case event := <-ks.events:
+					batch = append(batch, event)
+				default:
+					break drain
+				}
+			}
+			if len(batch) > 0 {
+				if err := ks.publish(batch); err != nil {
+					logrus.Warnf("%v: error publishing events on close, these events will be lost: %v", ks, err)
+				}
+			}
+			return
+		}
+
+		ks.publishWithRetry(batch)
+		batch, flush = nil, nil
+	}
+}
+
+// publishWithRetry publishes a batch of events, retrying after the configured backoff until it succeeds or the sink
+// is closed.
+func (ks *kafkaSink) publishWithRetry(batch []*Event) {
+	for {
+		err := ks.publish(batch)
+		if err == nil {
+			return
+		}
+		logrus.Errorf("%v: error publishing events: %v, retrying", ks, err)
+
+		select {
+		case <-time.After(ks.backoff):
+		case <-ks.closed:
+			logrus.Warnf("%v: closed while retrying, %d events will be lost", ks, len(batch))
+			return
+		}
+	}
+}
+
+// publish makes a single attempt to publish a batch of events. Events that cannot be marshaled are discarded.
+func (ks *kafkaSink) publish(batch []*Event) error {
+	msgs := make([]kafka.Message, 0, len(batch))
+	events := make([]*Event, 0, len(batch))
+	for _, event := range batch {
+		msg, err := newKafkaMessage(event)
+		if err != nil {
+			logrus.Errorf("%v: error marshaling event, discarding: %v", ks, err)
+			for _, listener := range ks.listeners {
+				listener.err(event)
+			}
+			continue
+		}
+		msgs = append(msgs, msg)
+		events = append(events, event)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	if ks.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ks.timeout)
+		defer cancel()
+	}
+
+	if err := ks.writer.WriteMessages(ctx, msgs...); err != nil {
+		for _, event := range events {
+			for _, listener := range ks.listeners {
+				listener.failure(event)
+			}
+		}
+		return err
+	}
+
+	for _, event := range events {
+		for _, listener := range ks.listeners {
+			listener.success(event)
+		}
+	}
+	return nil
+}
+
+// newKafkaMessage builds the Kafka message for an event. The message value is an envelope with a single event, the same
+// format used by the HTTP transport, and the key is the repository path, which determines the target partition.
+func newKafkaMessage(event *Event) (kafka.Message, error) {
+	p, err := json.Marshal(Envelope{Events: []Event{*event}})
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("marshaling event envelope: %w", err)
+	}
+
+	return kafka.Message{
+		Key:   []byte(event.Target.Repository),
+		Value: p,
+		Headers: []kafka.Header{
+			{Key: "Content-Type", Value: []byte(EventsMediaType)},
+			{Key: "Event-Action", Value: []byte(event.Action)},
+		},
+	}, nil
+}
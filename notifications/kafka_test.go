@@ -0,0 +1,195 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+// testKafkaWriter records published messages, failing the first `failures` calls.
+type testKafkaWriter struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	batches  [][]kafka.Message
+	closed   bool
+}
+
+func (w *testKafkaWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.calls++
+	if w.calls <= w.failures {
+		return errors.New("leader not available")
+	}
+	w.batches = append(w.batches, msgs)
+	return nil
+}
+
+func (w *testKafkaWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	return nil
+}
+
+func (w *testKafkaWriter) messages() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var msgs []kafka.Message
+	for _, b := range w.batches {
+		msgs = append(msgs, b...)
+	}
+	return msgs
+}
+
+func TestKafkaSink_Batching(t *testing.T) {
+	w := &testKafkaWriter{}
+	config := configuration.KafkaEndpoint{Topic: "registry", BatchSize: 3, BatchTimeout: time.Hour}
+	ks := newKafkaSinkWithWriter(w, config, time.Second, time.Millisecond)
+
+	for _, repo := range []string{"foo/a", "foo/b", "foo/a", "foo/c"} {
+		event := createTestEvent("push", repo, "blob")
+		require.NoError(t, ks.Write(&event))
+	}
+
+	// the first three events fill a batch, which is published right away
+	require.Eventually(t, func() bool { return len(w.messages()) == 3 }, time.Second, time.Millisecond)
+
+	// the remaining event is published when closing
+	require.NoError(t, ks.Close())
+	require.True(t, w.closed)
+	require.Len(t, w.batches, 2)
+	require.Len(t, w.batches[0], 3)
+	require.Len(t, w.batches[1], 1)
+
+	msgs := w.messages()
+	for i, repo := range []string{"foo/a", "foo/b", "foo/a", "foo/c"} {
+		require.Equal(t, repo, string(msgs[i].Key))
+
+		var envelope Envelope
+		require.NoError(t, json.Unmarshal(msgs[i].Value, &envelope))
+		require.Len(t, envelope.Events, 1)
+		require.Equal(t, repo, envelope.Events[0].Target.Repository)
+	}
+
+	event := createTestEvent("push", "foo/a", "blob")
+	require.ErrorIs(t, ks.Write(&event), ErrSinkClosed)
+	require.Error(t, ks.Close())
+}
+
+func TestKafkaSink_BatchTimeout(t *testing.T) {
+	w := &testKafkaWriter{}
+	config := configuration.KafkaEndpoint{Topic: "registry", BatchSize: 100, BatchTimeout: 10 * time.Millisecond}
+	ks := newKafkaSinkWithWriter(w, config, time.Second, time.Millisecond)
+	defer ks.Close()
+
+	event := createTestEvent("push", "foo/a", "blob")
+	require.NoError(t, ks.Write(&event))
+
+	require.Eventually(t, func() bool { return len(w.messages()) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestKafkaSink_Retry(t *testing.T) {
+	w := &testKafkaWriter{failures: 3}
+	metrics := newSafeMetrics(t.Name())
+	config := configuration.KafkaEndpoint{Topic: "registry", BatchSize: 2, BatchTimeout: time.Hour}
+	ks := newKafkaSinkWithWriter(w, config, time.Second, time.Millisecond, metrics.deliveryListener())
+	defer ks.Close()
+
+	for _, repo := range []string{"foo/a", "foo/b"} {
+		event := createTestEvent("push", repo, "blob")
+		require.NoError(t, ks.Write(&event))
+	}
+
+	// the batch is retried until it succeeds, so no events are lost
+	require.Eventually(t, func() bool { return len(w.messages()) == 2 }, time.Second, time.Millisecond)
+
+	metrics.Lock()
+	defer metrics.Unlock()
+	require.Equal(t, 2, metrics.Successes)
+	require.Equal(t, 6, metrics.Failures)
+}
+
+func TestEndpointConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  EndpointConfig
+		wantErr string
+	}{
+		{name: "default"},
+		{name: "http", config: EndpointConfig{Type: configuration.EndpointTypeHTTP}},
+		{
+			name: "kafka",
+			config: EndpointConfig{
+				Type:  configuration.EndpointTypeKafka,
+				Kafka: configuration.KafkaEndpoint{Brokers: []string{"localhost:9092"}, Topic: "registry"},
+			},
+		},
+		{
+			name: "kafka without brokers",
+			config: EndpointConfig{
+				Type:  configuration.EndpointTypeKafka,
+				Kafka: configuration.KafkaEndpoint{Topic: "registry"},
+			},
+			wantErr: "kafka: at least one broker must be configured",
+		},
+		{
+			name: "kafka without topic",
+			config: EndpointConfig{
+				Type:  configuration.EndpointTypeKafka,
+				Kafka: configuration.KafkaEndpoint{Brokers: []string{"localhost:9092"}},
+			},
+			wantErr: "kafka: topic must be set",
+		},
+		{
+			name:   "http with signing",
+			config: EndpointConfig{Signing: configuration.EndpointSigning{Secret: "s3cr3t"}},
+		},
+		{
+			name:    "http with signature header but no secret",
+			config:  EndpointConfig{Signing: configuration.EndpointSigning{Header: "X-Signature"}},
+			wantErr: "http: a signing secret is required to set the signature header",
+		},
+		{
+			name:    "http with missing client certificate",
+			config:  EndpointConfig{TLS: configuration.EndpointTLS{CertFile: "/missing/cert.pem", KeyFile: "/missing/key.pem"}},
+			wantErr: "http: loading TLS client certificate: open /missing/cert.pem: no such file or directory",
+		},
+		{
+			name: "kafka with signing",
+			config: EndpointConfig{
+				Type:    configuration.EndpointTypeKafka,
+				Kafka:   configuration.KafkaEndpoint{Brokers: []string{"localhost:9092"}, Topic: "registry"},
+				Signing: configuration.EndpointSigning{Secret: "s3cr3t"},
+			},
+			wantErr: "kafka: tls and signing settings only apply to http endpoints",
+		},
+		{
+			name:    "unknown",
+			config:  EndpointConfig{Type: "smtp"},
+			wantErr: `unknown endpoint type "smtp", must be one of ["http" "kafka" "nats"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	prometheus "github.com/docker/distribution/metrics"
 	"github.com/docker/go-metrics"
+	promclient "github.com/prometheus/client_golang/prometheus"
 )
 
 var (
@@ -19,6 +21,8 @@ var (
 	statusCounter = prometheus.NotificationsNamespace.NewLabeledCounter("status", "The number of status code", "code")
 	// errorCounter counts the total nuymber of events that were not sent due to internal errors
 	errorCounter = prometheus.NotificationsNamespace.NewLabeledCounter("errors", "The number of events that were not sent due to internal errors", "endpoint")
+	// backlogAgeDesc describes the age of the oldest event pending delivery to each endpoint
+	backlogAgeDesc = prometheus.NotificationsNamespace.NewDesc("backlog_age", "The age of the oldest event pending delivery to the endpoint", metrics.Seconds, "endpoint")
 )
 
 // EndpointMetrics track various actions taken by the endpoint, typically by
@@ -64,6 +68,14 @@ func (sm *safeMetrics) eventQueueListener() eventQueueListener {
 	}
 }
 
+// deliveryListener returns the listener for non-http sinks that updates the
+// relevant counters.
+func (sm *safeMetrics) deliveryListener() deliveryListener {
+	return &endpointMetricsDeliveryListener{
+		safeMetrics: sm,
+	}
+}
+
 // endpointMetricsHTTPStatusListener increments counters related to http sinks
 // for the relevant events.
 type endpointMetricsHTTPStatusListener struct {
@@ -100,6 +112,38 @@ func (emsl *endpointMetricsHTTPStatusListener) err(event *Event) {
 	errorCounter.WithValues(emsl.Endpoint).Inc(1)
 }
 
+// endpointMetricsDeliveryListener increments counters related to non-http
+// sinks for the relevant events.
+type endpointMetricsDeliveryListener struct {
+	*safeMetrics
+}
+
+var _ deliveryListener = &endpointMetricsDeliveryListener{}
+
+func (emdl *endpointMetricsDeliveryListener) success(event *Event) {
+	emdl.safeMetrics.Lock()
+	defer emdl.safeMetrics.Unlock()
+	emdl.Successes++
+
+	eventsCounter.WithValues("Successes", event.Action, event.artifact(), emdl.Endpoint).Inc(1)
+}
+
+func (emdl *endpointMetricsDeliveryListener) failure(event *Event) {
+	emdl.safeMetrics.Lock()
+	defer emdl.safeMetrics.Unlock()
+	emdl.Failures++
+
+	eventsCounter.WithValues("Failures", event.Action, event.artifact(), emdl.Endpoint).Inc(1)
+}
+
+func (emdl *endpointMetricsDeliveryListener) err(event *Event) {
+	emdl.safeMetrics.Lock()
+	defer emdl.safeMetrics.Unlock()
+	emdl.Errors++
+
+	errorCounter.WithValues(emdl.Endpoint).Inc(1)
+}
+
 // endpointMetricsEventQueueListener maintains the incoming events counter and
 // the queues pending count.
 type endpointMetricsEventQueueListener struct {
@@ -125,20 +169,64 @@ func (eqc *endpointMetricsEventQueueListener) egress(event *Event) {
 	pendingGauge.Dec(1)
 }
 
+// backlogAgeCollector reports the age of the oldest event pending delivery to each registered endpoint. The age is
+// computed at collection time, so it keeps growing while delivery is stuck.
+type backlogAgeCollector struct{}
+
+// Describe implements prometheus.Collector.
+func (backlogAgeCollector) Describe(ch chan<- *promclient.Desc) {
+	ch <- backlogAgeDesc
+}
+
+// Collect implements prometheus.Collector.
+func (backlogAgeCollector) Collect(ch chan<- promclient.Metric) {
+	endpoints.mu.Lock()
+	defer endpoints.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range endpoints.registered {
+		var age float64
+		if t := e.OldestPending(); !t.IsZero() && t.Before(now) {
+			age = now.Sub(t).Seconds()
+		}
+		ch <- promclient.MustNewConstMetric(backlogAgeDesc, promclient.GaugeValue, age, e.Name())
+	}
+}
+
 // endpoints is global registry of endpoints used to report metrics to expvar
 var endpoints struct {
 	registered []*Endpoint
 	mu         sync.Mutex
 }
 
-// register places the endpoint into expvar so that stats are tracked.
+// register places the endpoint into expvar so that stats are tracked. An endpoint with the same name, such as one
+// being replaced after reloading the configuration, is unregistered, so that metrics are not reported twice.
 func register(e *Endpoint) {
 	endpoints.mu.Lock()
 	defer endpoints.mu.Unlock()
 
+	for i, r := range endpoints.registered {
+		if r.Name() == e.Name() {
+			endpoints.registered = append(endpoints.registered[:i], endpoints.registered[i+1:]...)
+			break
+		}
+	}
 	endpoints.registered = append(endpoints.registered, e)
 }
 
+// unregister stops tracking the stats of the endpoint.
+func unregister(e *Endpoint) {
+	endpoints.mu.Lock()
+	defer endpoints.mu.Unlock()
+
+	for i, r := range endpoints.registered {
+		if r == e {
+			endpoints.registered = append(endpoints.registered[:i], endpoints.registered[i+1:]...)
+			return
+		}
+	}
+}
+
 func init() {
 	// NOTE(stevvooe): Setup registry metrics structure to report to expvar.
 	// Ideally, we do more metrics through logging but we need some nice
@@ -181,5 +269,6 @@ func init() {
 	registry.(*expvar.Map).Set("notifications", &notifications)
 
 	// register prometheus metrics
+	prometheus.NotificationsNamespace.Add(backlogAgeCollector{})
 	metrics.Register(prometheus.NotificationsNamespace)
 }
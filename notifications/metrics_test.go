@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"expvar"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMetricsExpvar(t *testing.T) {
@@ -26,3 +30,55 @@ func TestMetricsExpvar(t *testing.T) {
 		t.Logf("expected one-element []interface{}, got %#v", v)
 	}
 }
+
+func TestBacklogAgeCollector(t *testing.T) {
+	// only report the endpoint under test
+	endpoints.mu.Lock()
+	registered := endpoints.registered
+	endpoints.registered = nil
+	endpoints.mu.Unlock()
+	defer func() {
+		endpoints.mu.Lock()
+		endpoints.registered = registered
+		endpoints.mu.Unlock()
+	}()
+
+	store := newMemoryEventStore()
+	sink := newRetryingSink(&flakySink{rate: 1, Sink: &testSink{}}, 10, time.Millisecond)
+	pq := newPersistentQueue("backlog", sink, store, time.Hour)
+	defer pq.Close()
+	register(&Endpoint{name: "backlog", queue: pq})
+
+	require.Zero(t, testutil.ToFloat64(backlogAgeCollector{}))
+
+	event := createTestEvent("push", "library/test", "blob")
+	require.NoError(t, pq.Write(&event))
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(backlogAgeCollector{}) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRegister_ReplacesEndpointWithSameName(t *testing.T) {
+	endpoints.mu.Lock()
+	registered := endpoints.registered
+	endpoints.registered = nil
+	endpoints.mu.Unlock()
+	defer func() {
+		endpoints.mu.Lock()
+		endpoints.registered = registered
+		endpoints.mu.Unlock()
+	}()
+
+	old := &Endpoint{name: "a"}
+	register(old)
+	register(&Endpoint{name: "b"})
+	replacement := &Endpoint{name: "a"}
+	register(replacement)
+	require.Equal(t, []*Endpoint{{name: "b"}, replacement}, endpoints.registered)
+
+	// unregistering a replaced endpoint leaves its replacement registered
+	unregister(old)
+	require.Len(t, endpoints.registered, 2)
+	unregister(replacement)
+	require.Equal(t, []*Endpoint{{name: "b"}}, endpoints.registered)
+}
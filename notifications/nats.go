@@ -0,0 +1,292 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultNATSSubject    = "registry.events.{{ .Action }}"
+	defaultNATSMaxPending = 256
+)
+
+// natsSubjectReplacer replaces characters with a special meaning in NATS subjects, such as the token separator (`.`)
+// and wildcards (`*` and `>`), as well as whitespace, which is not allowed.
+var natsSubjectReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "\t", "_", "\r", "_", "\n", "_")
+
+// natsSubjectFuncs are the functions available to subject templates.
+var natsSubjectFuncs = template.FuncMap{
+	// token escapes a value so that it can be used as a single subject token, e.g. a repository path, which may
+	// contain dots.
+	"token": natsSubjectReplacer.Replace,
+}
+
+func parseNATSSubject(subject string) (*template.Template, error) {
+	if subject == "" {
+		subject = defaultNATSSubject
+	}
+	return template.New("subject").Funcs(natsSubjectFuncs).Option("missingkey=error").Parse(subject)
+}
+
+func validateNATSConfig(config EndpointConfig) error {
+	if len(config.NATS.Servers) == 0 {
+		return errors.New("nats: at least one server must be configured")
+	}
+	if config.NATS.MaxPending < 0 {
+		return errors.New("nats: maxpending must be a positive integer")
+	}
+
+	tmpl, err := parseNATSSubject(config.NATS.Subject)
+	if err != nil {
+		return fmt.Errorf("nats: invalid subject: %w", err)
+	}
+	// render the template against a sample event to catch references to unknown fields early
+	event := createEvent(EventActionPush)
+	event.Target.Repository = "sample/repository"
+	if _, err := renderNATSSubject(tmpl, event); err != nil {
+		return fmt.Errorf("nats: invalid subject: %w", err)
+	}
+	return nil
+}
+
+func renderNATSSubject(tmpl *template.Template, event *Event) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+
+	subject := buf.String()
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") || strings.HasPrefix(subject, ".") ||
+		strings.HasSuffix(subject, ".") || strings.Contains(subject, "..") {
+		return "", fmt.Errorf("rendered subject %q is not valid", subject)
+	}
+	return subject, nil
+}
+
+// natsPublisher is the subset of nats.JetStreamContext used by natsSink.
+type natsPublisher interface {
+	PublishMsgAsync(m *nats.Msg, opts ...nats.PubOpt) (nats.PubAckFuture, error)
+	PublishAsyncComplete() <-chan struct{}
+}
+
+// natsSink publishes events to NATS JetStream. Events are published asynchronously and each one is republished until
+// acknowledged by the server or the sink is closed. The number of events waiting for an acknowledgement is limited, so
+// that when JetStream is slow or unavailable, writes fail and the retrying sink in front of this one backs off,
+// keeping events queued in memory. The event ID is set as the JetStream message ID, so the server discards duplicates
+// caused by retries within the stream deduplication window.
+type natsSink struct {
+	config    configuration.NATSEndpoint
+	subject   *template.Template
+	ackWait   time.Duration
+	backoff   time.Duration
+	listeners []deliveryListener
+
+	// connect establishes the connection to NATS, returning a publisher and a function to close the connection.
+	connect func() (natsPublisher, func(), error)
+
+	mu        sync.Mutex
+	js        natsPublisher
+	closeConn func()
+	closed    bool
+
+	// stop is closed when the sink is closed, interrupting retries.
+	stop chan struct{}
+	// inflight tracks the goroutines waiting for acknowledgements.
+	inflight sync.WaitGroup
+}
+
+// newNATSSink returns a sink that publishes events to NATS JetStream. The connection is established on the first
+// write, so that the registry can start while NATS is unavailable.
+func newNATSSink(config configuration.NATSEndpoint, timeout, backoff time.Duration, listeners ...deliveryListener) *natsSink {
+	// the subject template was checked on validation
+	subject, _ := parseNATSSubject(config.Subject)
+
+	ns := &natsSink{
+		config:    config,
+		subject:   subject,
+		ackWait:   timeout,
+		backoff:   backoff,
+		listeners: listeners,
+		stop:      make(chan struct{}),
+	}
+	ns.connect = ns.dial
+	return ns
+}
+
+func (ns *natsSink) dial() (natsPublisher, func(), error) {
+	opts := []nats.Option{
+		nats.Name("container-registry"),
+		nats.Timeout(ns.ackWait),
+		nats.MaxReconnects(-1),
+	}
+	if ns.config.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(ns.config.CredentialsFile))
+	}
+	if ns.config.TLS {
+		opts = append(opts, nats.Secure(&tls.Config{MinVersion: tls.VersionTLS12}))
+	}
+
+	nc, err := nats.Connect(strings.Join(ns.config.Servers, ","), opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to nats: %w", err)
+	}
+
+	maxPending := ns.config.MaxPending
+	if maxPending <= 0 {
+		maxPending = defaultNATSMaxPending
+	}
+	js, err := nc.JetStream(nats.PublishAsyncMaxPending(maxPending))
+	if err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("creating jetstream context: %w", err)
+	}
+
+	return js, nc.Close, nil
+}
+
+// Write publishes an event, returning an error if the connection to NATS cannot be established or too many events
+// are waiting for an acknowledgement. It is the caller's responsibility to retry on error.
+func (ns *natsSink) Write(event *Event) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.closed {
+		return ErrSinkClosed
+	}
+
+	if ns.js == nil {
+		js, closeConn, err := ns.connect()
+		if err != nil {
+			for _, listener := range ns.listeners {
+				listener.failure(event)
+			}
+			return fmt.Errorf("%v: %w", ns, err)
+		}
+		ns.js, ns.closeConn = js, closeConn
+	}
+
+	msg, err := ns.newMessage(event)
+	if err != nil {
+		for _, listener := range ns.listeners {
+			listener.err(event)
+		}
+		return fmt.Errorf("%v: error building message: %w", ns, err)
+	}
+
+	f, err := ns.js.PublishMsgAsync(msg)
+	if err != nil {
+		for _, listener := range ns.listeners {
+			listener.failure(event)
+		}
+		return fmt.Errorf("%v: error publishing: %w", ns, err)
+	}
+
+	ns.inflight.Add(1)
+	go ns.awaitAck(event, f)
+
+	return nil
+}
+
+// awaitAck waits for the acknowledgement of a published event, republishing it after the configured backoff if
+// publishing fails or no acknowledgement is received in time.
+func (ns *natsSink) awaitAck(event *Event, f nats.PubAckFuture) {
+	defer ns.inflight.Done()
+
+	msg := f.Msg()
+	for {
+		var err error
+		select {
+		case <-f.Ok():
+			for _, listener := range ns.listeners {
+				listener.success(event)
+			}
+			return
+		case err = <-f.Err():
+		case <-time.After(ns.ackWait):
+			err = errors.New("timeout waiting for acknowledgement")
+		case <-ns.stop:
+			logrus.Warnf("%v: closed while waiting for acknowledgement, event %s may be lost", ns, event.ID)
+			return
+		}
+
+		for _, listener := range ns.listeners {
+			listener.failure(event)
+		}
+		logrus.Errorf("%v: error publishing event %s: %v, retrying", ns, event.ID, err)
+
+		for {
+			select {
+			case <-time.After(ns.backoff):
+			case <-ns.stop:
+				logrus.Warnf("%v: closed while retrying, event %s will be lost", ns, event.ID)
+				return
+			}
+
+			if f, err = ns.js.PublishMsgAsync(msg); err == nil {
+				break
+			}
+			logrus.Errorf("%v: error publishing event %s: %v, retrying", ns, event.ID, err)
+		}
+	}
+}
+
+func (ns *natsSink) newMessage(event *Event) (*nats.Msg, error) {
+	subject, err := renderNATSSubject(ns.subject, event)
+	if err != nil {
+		return nil, fmt.Errorf("rendering subject: %w", err)
+	}
+
+	p, err := json.Marshal(Envelope{Events: []Event{*event}})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling event envelope: %w", err)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = p
+	msg.Header.Set("Content-Type", EventsMediaType)
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+
+	return msg, nil
+}
+
+// Close waits up to the acknowledgement timeout for pending events to be acknowledged and closes the connection.
+func (ns *natsSink) Close() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.closed {
+		return fmt.Errorf("natssink: already closed")
+	}
+	ns.closed = true
+
+	if ns.js != nil {
+		select {
+		case <-ns.js.PublishAsyncComplete():
+		case <-time.After(ns.ackWait):
+			logrus.Warnf("%v: timeout waiting for pending events to be acknowledged", ns)
+		}
+	}
+
+	close(ns.stop)
+	ns.inflight.Wait()
+
+	if ns.closeConn != nil {
+		ns.closeConn()
+	}
+	return nil
+}
+
+func (ns *natsSink) String() string {
+	return fmt.Sprintf("natsSink{%s}", strings.Join(ns.config.Servers, ","))
+}
@@ -0,0 +1,200 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+// testPubAckFuture is a nats.PubAckFuture resolved by the testNATSPublisher.
+type testPubAckFuture struct {
+	msg *nats.Msg
+	ok  chan *nats.PubAck
+	err chan error
+}
+
+func (f *testPubAckFuture) Ok() <-chan *nats.PubAck { return f.ok }
+func (f *testPubAckFuture) Err() <-chan error       { return f.err }
+func (f *testPubAckFuture) Msg() *nats.Msg          { return f.msg }
+
+// testNATSPublisher records published messages and acknowledges them, failing the first `failures` acknowledgements.
+// Publishing fails while `full` is set, simulating too many pending acknowledgements.
+type testNATSPublisher struct {
+	mu        sync.Mutex
+	failures  int
+	full      bool
+	acks      int
+	published []*nats.Msg
+	acked     []*nats.Msg
+}
+
+func (p *testNATSPublisher) PublishMsgAsync(m *nats.Msg, _ ...nats.PubOpt) (nats.PubAckFuture, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.full {
+		return nil, errors.New("nats: stalled with too many outstanding async published messages")
+	}
+	p.published = append(p.published, m)
+
+	f := &testPubAckFuture{msg: m, ok: make(chan *nats.PubAck, 1), err: make(chan error, 1)}
+	p.acks++
+	if p.acks <= p.failures {
+		f.err <- errors.New("nats: no response from stream")
+	} else {
+		p.acked = append(p.acked, m)
+		f.ok <- &nats.PubAck{}
+	}
+	return f, nil
+}
+
+func (p *testNATSPublisher) PublishAsyncComplete() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func (p *testNATSPublisher) ackedMessages() []*nats.Msg {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]*nats.Msg(nil), p.acked...)
+}
+
+func newTestNATSSink(t *testing.T, p *testNATSPublisher, subject string, listeners ...deliveryListener) *natsSink {
+	t.Helper()
+
+	config := configuration.NATSEndpoint{Servers: []string{"nats://127.0.0.1:4222"}, Subject: subject}
+	require.NoError(t, validateNATSConfig(EndpointConfig{NATS: config}))
+
+	ns := newNATSSink(config, time.Second, time.Millisecond, listeners...)
+	ns.connect = func() (natsPublisher, func(), error) { return p, func() {}, nil }
+	return ns
+}
+
+func TestNATSSink_Write(t *testing.T) {
+	p := &testNATSPublisher{}
+	ns := newTestNATSSink(t, p, "registry.{{ .Action }}.{{ token .Target.Repository }}")
+
+	event := createTestEvent("push", "group/my.app", "blob")
+	require.NoError(t, ns.Write(&event))
+	require.NoError(t, ns.Close())
+
+	msgs := p.ackedMessages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, "registry.push.group/my_app", msgs[0].Subject)
+	require.Equal(t, event.ID, msgs[0].Header.Get(nats.MsgIdHdr))
+	require.Equal(t, EventsMediaType, msgs[0].Header.Get("Content-Type"))
+
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal(msgs[0].Data, &envelope))
+	require.Len(t, envelope.Events, 1)
+	require.Equal(t, event.ID, envelope.Events[0].ID)
+
+	require.ErrorIs(t, ns.Write(&event), ErrSinkClosed)
+	require.Error(t, ns.Close())
+}
+
+func TestNATSSink_DefaultSubject(t *testing.T) {
+	p := &testNATSPublisher{}
+	ns := newTestNATSSink(t, p, "")
+
+	event := createTestEvent("delete", "foo/bar", "blob")
+	require.NoError(t, ns.Write(&event))
+	require.NoError(t, ns.Close())
+
+	msgs := p.ackedMessages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, "registry.events.delete", msgs[0].Subject)
+}
+
+func TestNATSSink_RetryOnFailedAck(t *testing.T) {
+	p := &testNATSPublisher{failures: 2}
+	metrics := newSafeMetrics(t.Name())
+	ns := newTestNATSSink(t, p, "", metrics.deliveryListener())
+	defer ns.Close()
+
+	event := createTestEvent("push", "foo/bar", "blob")
+	require.NoError(t, ns.Write(&event))
+
+	// the event is republished until acknowledged
+	require.Eventually(t, func() bool { return len(p.ackedMessages()) == 1 }, time.Second, time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		metrics.Lock()
+		defer metrics.Unlock()
+		return metrics.Successes == 1
+	}, time.Second, time.Millisecond)
+
+	metrics.Lock()
+	defer metrics.Unlock()
+	require.Equal(t, 2, metrics.Failures)
+}
+
+func TestNATSSink_Backpressure(t *testing.T) {
+	p := &testNATSPublisher{full: true}
+	ns := newTestNATSSink(t, p, "")
+
+	// writes fail while too many events are pending acknowledgement, so that a retrying sink backs off
+	rs := newRetryingSink(ns, 1, 10*time.Millisecond)
+	defer rs.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		event := createTestEvent("push", "foo/bar", "blob")
+		require.NoError(t, rs.Write(&event))
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("write should block while publishing is stalled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	p.mu.Lock()
+	p.full = false
+	p.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("write should succeed once publishing resumes")
+	}
+	require.Eventually(t, func() bool { return len(p.ackedMessages()) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestValidateNATSConfig(t *testing.T) {
+	servers := []string{"nats://127.0.0.1:4222"}
+
+	tests := []struct {
+		name    string
+		config  configuration.NATSEndpoint
+		wantErr string
+	}{
+		{name: "default subject", config: configuration.NATSEndpoint{Servers: servers}},
+		{name: "templated subject", config: configuration.NATSEndpoint{Servers: servers, Subject: "registry.{{ .Action }}.{{ token .Target.Repository }}"}},
+		{name: "no servers", wantErr: "nats: at least one server must be configured"},
+		{name: "negative max pending", config: configuration.NATSEndpoint{Servers: servers, MaxPending: -1}, wantErr: "nats: maxpending must be a positive integer"},
+		{name: "invalid template", config: configuration.NATSEndpoint{Servers: servers, Subject: "registry.{{ .Action"}, wantErr: "nats: invalid subject: template: subject:1: unclosed action"},
+		{name: "unknown field", config: configuration.NATSEndpoint{Servers: servers, Subject: "registry.{{ .Foo }}"}, wantErr: "can't evaluate field Foo"},
+		{name: "invalid subject", config: configuration.NATSEndpoint{Servers: servers, Subject: "registry..{{ .Action }}"}, wantErr: `nats: invalid subject: rendered subject "registry..push" is not valid`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNATSConfig(EndpointConfig{NATS: tt.config})
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
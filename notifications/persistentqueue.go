@@ -0,0 +1,273 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// persistentQueueBatchSize is the maximum number of events claimed from the store at once.
+	persistentQueueBatchSize = 10
+	// persistentQueueLease is how long claimed events are locked for delivery by a registry instance. Events are
+	// claimed again, and therefore delivered more than once, if not delivered or marked as failed by then, which
+	// should only happen if the instance is stopped while delivering them.
+	persistentQueueLease = 10 * time.Minute
+	// persistentQueueStoreTimeout bounds each call to the store.
+	persistentQueueStoreTimeout = 10 * time.Second
+)
+
+// StoredEvent is an event persisted in an EventStore.
+type StoredEvent struct {
+	ID      int64
+	Payload []byte
+}
+
+// EventStore persists the events pending delivery to endpoints, so that they survive restarts and can be delivered
+// by any registry instance sharing the store.
+type EventStore interface {
+	// Add persists an event pending delivery to endpoint.
+	Add(ctx context.Context, endpoint string, payload []byte) error
+	// Claim leases up to limit events pending delivery to endpoint, oldest first. Leased events are not claimed
+	// again until the lease expires.
+	Claim(ctx context.Context, endpoint string, limit int, lease time.Duration) ([]StoredEvent, error)
+	// Delete removes a delivered event.
+	Delete(ctx context.Context, id int64) error
+	// Fail marks an event whose delivery failed, excluding it from delivery until requeued.
+	Fail(ctx context.Context, id int64) error
+	// OldestPending returns the creation time of the oldest event pending delivery to endpoint, or the zero time if
+	// there are none.
+	OldestPending(ctx context.Context, endpoint string) (time.Time, error)
+}
+
+// QueueConfig configures the queue of events pending delivery to an endpoint. Events are queued in memory unless a
+// Store is set.
+type QueueConfig struct {
+	// Store persists the queued events.
+	Store EventStore `json:"-"`
+	// MaxAttempts is the number of failed delivery attempts after which a persisted event is marked as failed.
+	MaxAttempts int
+	// Interval is how often the store is checked for events pending delivery.
+	Interval time.Duration
+}
+
+// persistedEvent is the payload of an event in an EventStore. The trace context is not part of the event payload, so
+// it's stored alongside.
+type persistedEvent struct {
+	Event        *Event               `json:"event"`
+	TraceContext tracing.TraceContext `json:"trace_context,omitempty"`
+}
+
+// persistentQueue accepts all messages into an EventStore for asynchronous consumption by a sink. Unlike eventQueue,
+// events survive restarts and are shared with the other registry instances using the same store, any of which may
+// deliver them. Events whose delivery fails are marked as such in the store instead of being dropped. Delivery is
+// at-least-once.
+type persistentQueue struct {
+	endpoint  string
+	sink      Sink
+	store     EventStore
+	interval  time.Duration
+	listeners []eventQueueListener
+
+	mu     sync.Mutex
+	oldest time.Time
+
+	wake      chan struct{}
+	closed    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newPersistentQueue returns a queue to the provided sink, backed by store. Listeners are notified when events are
+// claimed for delivery by this queue and when they're done with.
+func newPersistentQueue(endpoint string, sink Sink, store EventStore, interval time.Duration, listeners ...eventQueueListener) *persistentQueue {
+	pq := &persistentQueue{
+		endpoint:  endpoint,
+		sink:      sink,
+		store:     store,
+		interval:  interval,
+		listeners: listeners,
+		wake:      make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go pq.run()
+	return pq
+}
+
+// Write persists an event into the queue, failing if the queue has been closed or the store is unavailable.
+func (pq *persistentQueue) Write(event *Event) error {
+	select {
+	case <-pq.closed:
+		return ErrSinkClosed
+	default:
+	}
+
+	p, err := json.Marshal(persistedEvent{Event: event, TraceContext: event.Request.TraceContext})
+	if err != nil {
+		return fmt.Errorf("persistentqueue: marshaling event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), persistentQueueStoreTimeout)
+	defer cancel()
+
+	if err := pq.store.Add(ctx, pq.endpoint, p); err != nil {
+		return fmt.Errorf("persistentqueue: persisting event: %w", err)
+	}
+
+	pq.mu.Lock()
+	if pq.oldest.IsZero() {
+		pq.oldest = event.Timestamp
+	}
+	pq.mu.Unlock()
+
+	// deliver right away instead of waiting for the next check
+	select {
+	case pq.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Close stops the delivery of events and closes the sink. Events still pending delivery are left in the store.
+func (pq *persistentQueue) Close() error {
+	err := fmt.Errorf("persistentqueue: already closed")
+	pq.closeOnce.Do(func() {
+		close(pq.closed)
+		// closing the sink interrupts any ongoing delivery retries
+		err = pq.sink.Close()
+		<-pq.done
+	})
+
+	return err
+}
+
+// oldestPending returns the creation time of the oldest event pending delivery, as of the last check of the store.
+func (pq *persistentQueue) oldestPending() time.Time {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	return pq.oldest
+}
+
+// run is the main goroutine to deliver persisted events to the target sink.
+func (pq *persistentQueue) run() {
+	defer close(pq.done)
+
+	ticker := time.NewTicker(pq.interval)
+	defer ticker.Stop()
+
+	for {
+		pq.deliver()
+
+		select {
+		case <-pq.closed:
+			return
+		case <-pq.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver delivers events pending delivery in batches until there are none left or the queue is closed.
+func (pq *persistentQueue) deliver() {
+	for {
+		select {
+		case <-pq.closed:
+			return
+		default:
+		}
+
+		pq.refreshOldest()
+
+		ctx, cancel := context.WithTimeout(context.Background(), persistentQueueStoreTimeout)
+		ee, err := pq.store.Claim(ctx, pq.endpoint, persistentQueueBatchSize, persistentQueueLease)
+		cancel()
+		if err != nil {
+			logrus.WithError(err).Errorf("persistentqueue: error claiming events for %v", pq.endpoint)
+			return
+		}
+
+		for _, e := range ee {
+			if !pq.deliverOne(e) {
+				return
+			}
+		}
+
+		if len(ee) < persistentQueueBatchSize {
+			pq.refreshOldest()
+			return
+		}
+	}
+}
+
+// deliverOne writes a persisted event to the sink and then deletes it from the store, or marks it as failed if the
+// write failed. It returns false if the queue was closed meanwhile.
+func (pq *persistentQueue) deliverOne(e StoredEvent) bool {
+	var pe persistedEvent
+	if err := json.Unmarshal(e.Payload, &pe); err != nil || pe.Event == nil {
+		logrus.WithError(err).Errorf("persistentqueue: invalid payload for event %d of %v, marking as failed", e.ID, pq.endpoint)
+		pq.fail(e.ID)
+		return true
+	}
+	event := pe.Event
+	event.Request.TraceContext = pe.TraceContext
+
+	for _, listener := range pq.listeners {
+		listener.ingress(event)
+	}
+	err := pq.sink.Write(event)
+	for _, listener := range pq.listeners {
+		listener.egress(event)
+	}
+
+	switch {
+	case err == nil:
+		ctx, cancel := context.WithTimeout(context.Background(), persistentQueueStoreTimeout)
+		defer cancel()
+		if err := pq.store.Delete(ctx, e.ID); err != nil {
+			// the event will be delivered again once the lease expires
+			logrus.WithError(err).Errorf("persistentqueue: error deleting delivered event %d of %v", e.ID, pq.endpoint)
+		}
+	case errors.Is(err, ErrSinkClosed):
+		// left in the store, to be delivered once the lease expires
+		return false
+	default:
+		logrus.WithError(err).Warnf("persistentqueue: error writing event %d to %v, marking as failed", e.ID, pq.endpoint)
+		pq.fail(e.ID)
+	}
+
+	return true
+}
+
+func (pq *persistentQueue) fail(id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), persistentQueueStoreTimeout)
+	defer cancel()
+
+	if err := pq.store.Fail(ctx, id); err != nil {
+		logrus.WithError(err).Errorf("persistentqueue: error marking event %d of %v as failed", id, pq.endpoint)
+	}
+}
+
+func (pq *persistentQueue) refreshOldest() {
+	ctx, cancel := context.WithTimeout(context.Background(), persistentQueueStoreTimeout)
+	defer cancel()
+
+	t, err := pq.store.OldestPending(ctx, pq.endpoint)
+	if err != nil {
+		logrus.WithError(err).Errorf("persistentqueue: error finding oldest pending event for %v", pq.endpoint)
+		return
+	}
+
+	pq.mu.Lock()
+	pq.oldest = t
+	pq.mu.Unlock()
+}
@@ -0,0 +1,265 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/tracing"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryEventStore is an EventStore that keeps events in memory, ignoring leases.
+type memoryEventStore struct {
+	mu      sync.Mutex
+	nextID  int64
+	events  map[int64]*memoryStoredEvent
+	claimed map[int64]bool
+	addErr  error
+}
+
+type memoryStoredEvent struct {
+	StoredEvent
+	endpoint  string
+	createdAt time.Time
+	failed    bool
+}
+
+func newMemoryEventStore() *memoryEventStore {
+	return &memoryEventStore{
+		events:  make(map[int64]*memoryStoredEvent),
+		claimed: make(map[int64]bool),
+	}
+}
+
+func (s *memoryEventStore) Add(_ context.Context, endpoint string, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.addErr != nil {
+		return s.addErr
+	}
+	s.nextID++
+	s.events[s.nextID] = &memoryStoredEvent{
+		StoredEvent: StoredEvent{ID: s.nextID, Payload: payload},
+		endpoint:    endpoint,
+		createdAt:   time.Now(),
+	}
+	return nil
+}
+
+func (s *memoryEventStore) pending(endpoint string) []*memoryStoredEvent {
+	ee := make([]*memoryStoredEvent, 0)
+	for _, e := range s.events {
+		if e.endpoint == endpoint && !e.failed {
+			ee = append(ee, e)
+		}
+	}
+	sort.Slice(ee, func(i, j int) bool { return ee[i].ID < ee[j].ID })
+	return ee
+}
+
+func (s *memoryEventStore) Claim(_ context.Context, endpoint string, limit int, _ time.Duration) ([]StoredEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ee := make([]StoredEvent, 0)
+	for _, e := range s.pending(endpoint) {
+		if len(ee) == limit {
+			break
+		}
+		if s.claimed[e.ID] {
+			continue
+		}
+		s.claimed[e.ID] = true
+		ee = append(ee, e.StoredEvent)
+	}
+	return ee, nil
+}
+
+func (s *memoryEventStore) Delete(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.events, id)
+	delete(s.claimed, id)
+	return nil
+}
+
+func (s *memoryEventStore) Fail(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.events[id]; ok {
+		e.failed = true
+	}
+	delete(s.claimed, id)
+	return nil
+}
+
+func (s *memoryEventStore) OldestPending(_ context.Context, endpoint string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ee := s.pending(endpoint); len(ee) > 0 {
+		return ee[0].createdAt, nil
+	}
+	return time.Time{}, nil
+}
+
+func (s *memoryEventStore) count() (pending, failed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.events {
+		if e.failed {
+			failed++
+		} else {
+			pending++
+		}
+	}
+	return pending, failed
+}
+
+func TestPersistentQueue(t *testing.T) {
+	const nEvents = 25
+	var ts testSink
+	store := newMemoryEventStore()
+	metrics := newSafeMetrics(t.Name())
+	pq := newPersistentQueue("test", &ts, store, 10*time.Millisecond, metrics.eventQueueListener())
+
+	event := createTestEvent("push", "library/test", "blob")
+	event.Request.TraceContext = tracing.TraceContext{TraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}
+	for i := 0; i < nEvents; i++ {
+		require.NoError(t, pq.Write(&event))
+	}
+
+	require.Eventually(t, func() bool {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		return len(ts.events) == nEvents
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return pq.oldestPending().IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	checkClose(t, pq)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	require.True(t, ts.closed)
+	require.Equal(t, event.ID, ts.events[0].ID)
+	require.Equal(t, event.Target.Repository, ts.events[0].Target.Repository)
+	require.Equal(t, event.Request.TraceContext, ts.events[0].Request.TraceContext)
+
+	pending, failed := store.count()
+	require.Zero(t, pending)
+	require.Zero(t, failed)
+
+	metrics.Lock()
+	defer metrics.Unlock()
+	require.Equal(t, nEvents, metrics.Events)
+	require.Zero(t, metrics.Pending)
+}
+
+func TestPersistentQueue_DeliversEventsAddedElsewhere(t *testing.T) {
+	var ts testSink
+	store := newMemoryEventStore()
+
+	// left behind by a previous run or queued by another registry instance
+	other := newPersistentQueue("test", &testSink{}, store, time.Hour)
+	require.NoError(t, other.Close())
+	event := createTestEvent("push", "library/test", "blob")
+	require.NoError(t, other.store.Add(context.Background(), "test", mustMarshalPersistedEvent(t, &event)))
+
+	pq := newPersistentQueue("test", &ts, store, 10*time.Millisecond)
+	defer pq.Close()
+
+	require.Eventually(t, func() bool {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		return len(ts.events) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPersistentQueue_MarksFailedDeliveries(t *testing.T) {
+	var ts testSink
+	store := newMemoryEventStore()
+	sink := newRetryingSink(&flakySink{rate: 1, Sink: &ts}, 10, time.Millisecond)
+	sink.maxAttempts = 2
+	pq := newPersistentQueue("test", sink, store, 10*time.Millisecond)
+
+	event := createTestEvent("push", "library/test", "blob")
+	require.NoError(t, pq.Write(&event))
+
+	require.Eventually(t, func() bool {
+		_, failed := store.count()
+		return failed == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, pq.Close())
+
+	pending, _ := store.count()
+	require.Zero(t, pending)
+	require.True(t, pq.oldestPending().IsZero())
+}
+
+func TestPersistentQueue_MarksInvalidPayloadsAsFailed(t *testing.T) {
+	var ts testSink
+	store := newMemoryEventStore()
+	require.NoError(t, store.Add(context.Background(), "test", []byte("{")))
+
+	pq := newPersistentQueue("test", &ts, store, 10*time.Millisecond)
+	defer pq.Close()
+
+	require.Eventually(t, func() bool {
+		_, failed := store.count()
+		return failed == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPersistentQueue_WriteFailsIfStoreUnavailable(t *testing.T) {
+	store := newMemoryEventStore()
+	store.addErr = errors.New("unavailable")
+	pq := newPersistentQueue("test", &testSink{}, store, time.Hour)
+	defer pq.Close()
+
+	event := createTestEvent("push", "library/test", "blob")
+	err := pq.Write(&event)
+	require.Error(t, err)
+	require.ErrorIs(t, err, store.addErr)
+}
+
+func TestPersistentQueue_LeavesPendingEventsOnClose(t *testing.T) {
+	store := newMemoryEventStore()
+	// never succeeds, so the event is being retried when the queue is closed
+	sink := newRetryingSink(&flakySink{rate: 1, Sink: &testSink{}}, 10, time.Millisecond)
+	pq := newPersistentQueue("test", sink, store, time.Hour)
+
+	event := createTestEvent("push", "library/test", "blob")
+	require.NoError(t, pq.Write(&event))
+	require.Eventually(t, func() bool {
+		return !pq.oldestPending().IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, pq.Close())
+
+	pending, failed := store.count()
+	require.Equal(t, 1, pending)
+	require.Zero(t, failed)
+}
+
+func mustMarshalPersistedEvent(t *testing.T, event *Event) []byte {
+	t.Helper()
+
+	store := newMemoryEventStore()
+	pq := &persistentQueue{endpoint: "test", store: store, closed: make(chan struct{}), wake: make(chan struct{}, 1)}
+	require.NoError(t, pq.Write(event))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.events[1].Payload
+}
@@ -93,6 +93,16 @@ func (qb *QueueBridge) TagDeleted(repo reference.Named, tag string) error {
 	return qb.sink.Write(event)
 }
 
+// TagsDeleted creates and queues a single event with the repository details and the names of all tags deleted at
+// once, such as through a bulk tag delete.
+func (qb *QueueBridge) TagsDeleted(repo reference.Named, tags []string) error {
+	event := qb.createEvent(EventActionDelete)
+	event.Target.Repository = repo.Name()
+	event.Target.Tags = tags
+
+	return qb.sink.Write(event)
+}
+
 func (qb *QueueBridge) createManifestEvent(action string, repo reference.Named, sm distribution.Manifest) (*Event, error) {
 	event := qb.createEvent(action)
 	event.Target.Repository = repo.Name()
@@ -110,6 +110,8 @@ type eventQueue struct {
 	cond      *sync.Cond
 	mu        sync.Mutex
 	closed    bool
+	// inflight is the event being written to the sink, if any.
+	inflight *Event
 }
 
 // eventQueueListener is called when various events happen on the queue.
@@ -181,12 +183,31 @@ func (eq *eventQueue) run() {
 			logrus.Warnf("eventqueue: error writing events to %v, these events will be lost: %v", eq.sink, err)
 		}
 
+		eq.mu.Lock()
+		eq.inflight = nil
+		eq.mu.Unlock()
+
 		for _, listener := range eq.listeners {
 			listener.egress(block)
 		}
 	}
 }
 
+// oldestPending returns the timestamp of the oldest event pending delivery, including the one being written to the
+// sink, or the zero time if there are none.
+func (eq *eventQueue) oldestPending() time.Time {
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
+
+	if eq.inflight != nil {
+		return eq.inflight.Timestamp
+	}
+	if front := eq.events.Front(); front != nil {
+		return front.Value.(*Event).Timestamp
+	}
+	return time.Time{}
+}
+
 // next encompasses the critical section of the run loop. When the queue is
 // empty, it will block on the condition. If new data arrives, it will wake
 // and return a block. When closed, a nil slice will be returned.
@@ -205,6 +226,7 @@ func (eq *eventQueue) next() *Event {
 	front := eq.events.Front()
 	block := front.Value.(*Event)
 	eq.events.Remove(front)
+	eq.inflight = block
 
 	return block
 }
@@ -265,6 +287,10 @@ type retryingSink struct {
 	sink   Sink
 	closed bool
 
+	// maxAttempts is the number of failed attempts after which a write gives up and returns an error. Writes are
+	// retried indefinitely if zero.
+	maxAttempts int
+
 	// circuit breaker heuristics
 	failures struct {
 		threshold int
@@ -298,6 +324,8 @@ func (rs *retryingSink) Write(event *Event) error {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
 
+	var attempts int
+
 retry:
 
 	if rs.closed {
@@ -316,6 +344,11 @@ retry:
 			return err
 		}
 
+		attempts++
+		if rs.maxAttempts > 0 && attempts >= rs.maxAttempts {
+			return fmt.Errorf("retryingsink: giving up after %d attempts: %w", attempts, err)
+		}
+
 		logrus.Errorf("retryingsink: error writing events: %v, retrying", err)
 		goto retry
 	}
@@ -164,6 +164,28 @@ func TestRetryingSink(t *testing.T) {
 	}
 }
 
+func TestRetryingSink_MaxAttempts(t *testing.T) {
+	var ts testSink
+	flaky := &flakySink{
+		rate: 1, // always fail
+		Sink: &ts,
+	}
+	s := newRetryingSink(flaky, 2, time.Millisecond)
+	s.maxAttempts = 3
+
+	event := createTestEvent("push", "library/test", "blob")
+	err := s.Write(&event)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrSinkClosed)
+	require.Contains(t, err.Error(), "giving up after 3 attempts")
+
+	checkClose(t, s)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	require.Empty(t, ts.events)
+}
+
 type testSink struct {
 	events []*Event
 	mu     sync.Mutex
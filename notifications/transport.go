@@ -0,0 +1,109 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/sirupsen/logrus"
+)
+
+// transport builds the sink that delivers events to the destination of an endpoint. The in-memory queue and ignore
+// filters are added on top of it by the endpoint, so the returned sink only has to take care of delivery and
+// retries.
+type transport struct {
+	// validate checks that the endpoint configuration holds all settings required by the transport.
+	validate func(config EndpointConfig) error
+	// newSink builds the delivery sink for an endpoint with defaults applied.
+	newSink func(e *Endpoint) Sink
+}
+
+// transports holds the supported transports, keyed by endpoint type.
+var transports = map[string]transport{
+	configuration.EndpointTypeHTTP: {
+		validate: validateHTTPConfig,
+		newSink: func(e *Endpoint) Sink {
+			tr := e.Transport
+			tlsConfig, err := httpTLSConfig(e.TLS)
+			if err != nil {
+				// settings are validated on startup, so this is only hit if the files changed in the meantime
+				logrus.Errorf("http: %v, using default TLS settings for endpoint %s", err, e.name)
+			} else if tlsConfig != nil {
+				tr = tr.Clone()
+				tr.TLSClientConfig = tlsConfig
+			}
+
+			s := newHTTPSink(e.url, e.Timeout, e.Headers, tr, e.metrics.httpStatusListener())
+			s.signingSecret, s.signatureHeader = e.Signing.Secret, e.Signing.Header
+			return newEndpointRetryingSink(e, s)
+		},
+	},
+	configuration.EndpointTypeKafka: {
+		validate: validateKafkaConfig,
+		newSink: func(e *Endpoint) Sink {
+			// The Kafka sink batches and retries on its own, so it does not need a retrying sink in front.
+			return newKafkaSink(e.Kafka, e.Timeout, e.Threshold, e.Backoff, e.metrics.deliveryListener())
+		},
+	},
+	configuration.EndpointTypeNATS: {
+		validate: validateNATSConfig,
+		newSink: func(e *Endpoint) Sink {
+			s := newNATSSink(e.NATS, e.Timeout, e.Backoff, e.metrics.deliveryListener())
+			return newEndpointRetryingSink(e, s)
+		},
+	},
+}
+
+// newEndpointRetryingSink wraps the delivery sink s of endpoint e in a retrying sink. Writes are retried indefinitely
+// unless the endpoint has a persistent queue, where events are set aside as failed after the configured attempts.
+func newEndpointRetryingSink(e *Endpoint, s Sink) *retryingSink {
+	rs := newRetryingSink(s, e.Threshold, e.Backoff)
+	if e.Queue.Store != nil {
+		rs.maxAttempts = e.Queue.MaxAttempts
+	}
+	return rs
+}
+
+func transportTypes() []string {
+	types := make([]string, 0, len(transports))
+	for t := range transports {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// deliveryListener is called on the outcome of delivering events through a transport other than HTTP, for which there
+// is no status code to report.
+type deliveryListener interface {
+	success(event *Event)
+	failure(event *Event)
+	err(event *Event)
+}
+
+// Validate checks that the endpoint type is supported and that all settings required by it are set.
+func (ec EndpointConfig) Validate() error {
+	typ := ec.Type
+	if typ == "" {
+		typ = configuration.EndpointTypeHTTP
+	}
+
+	t, ok := transports[typ]
+	if !ok {
+		return fmt.Errorf("unknown endpoint type %q, must be one of %q", ec.Type, transportTypes())
+	}
+
+	// The Kafka sink accepts events into batches that are published asynchronously, so it can't tell the persistent
+	// queue whether an event was delivered.
+	if typ == configuration.EndpointTypeKafka && ec.Queue.Store != nil {
+		return errors.New("kafka: endpoints do not support the database queue")
+	}
+
+	if typ != configuration.EndpointTypeHTTP &&
+		(ec.TLS != (configuration.EndpointTLS{}) || ec.Signing != (configuration.EndpointSigning{})) {
+		return fmt.Errorf("%s: tls and signing settings only apply to http endpoints", typ)
+	}
+
+	return t.validate(ec)
+}
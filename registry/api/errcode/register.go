@@ -93,6 +93,31 @@ var (
 		Description:    "Returned when the client cancels the request",
 		HTTPStatusCode: http.StatusBadRequest,
 	})
+
+	// ErrorCodeRequestTimeout provides an error to report a request that exceeded its configured deadline.
+	ErrorCodeRequestTimeout = Register("errcode", ErrorDescriptor{
+		Value:          "REQUESTTIMEOUT",
+		Message:        "request deadline exceeded",
+		Description:    "Returned when the request could not be served within its configured deadline",
+		HTTPStatusCode: http.StatusGatewayTimeout,
+	})
+
+	// ErrorCodeRequestBodyTooLarge provides an error to report a request body exceeding the configured maximum size.
+	ErrorCodeRequestBodyTooLarge = Register("errcode", ErrorDescriptor{
+		Value:          "REQUESTBODYTOOLARGE",
+		Message:        "request body too large",
+		Description:    "Returned when the request body exceeds the maximum size configured for its route",
+		HTTPStatusCode: http.StatusRequestEntityTooLarge,
+	})
+
+	// ErrorCodeRequestBodyTimeout provides an error to report a request body that was not received within the
+	// configured timeout, usually because the client is sending it too slowly.
+	ErrorCodeRequestBodyTimeout = Register("errcode", ErrorDescriptor{
+		Value:          "REQUESTBODYTIMEOUT",
+		Message:        "request body timeout",
+		Description:    "Returned when the request body could not be read within the timeout configured for its route",
+		HTTPStatusCode: http.StatusRequestTimeout,
+	})
 )
 
 var nextCode = 1000
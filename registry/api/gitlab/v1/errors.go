@@ -33,6 +33,14 @@ func InvalidQueryParamValuePatternErrorDetail(key string, pattern *regexp.Regexp
 	return fmt.Sprintf("the '%s' query parameter value must match the pattern '%s'", key, pattern)
 }
 
+func InvalidQueryParamValueRegexErrorDetail(key string, maxLength int) string {
+	return fmt.Sprintf("the '%s' query parameter value must be a valid regular expression of up to %d characters", key, maxLength)
+}
+
+func InvalidQueryParamValueMediaTypeErrorDetail(key string, maxLength int) string {
+	return fmt.Sprintf("the '%s' query parameter value must be a valid media type of up to %d characters", key, maxLength)
+}
+
 func MutuallyExclusiveParametersErrorDetail(keys ...string) string {
 	return fmt.Sprintf("keys: %+v are mutually exclusive", keys)
 }
@@ -69,6 +77,38 @@ var ErrorCodeNotImplemented = errcode.Register(errGroup, errcode.ErrorDescriptor
 	HTTPStatusCode: http.StatusNotFound,
 })
 
+// ErrorCodeCleanupPolicyUnknown is returned when a repository has no cleanup policy.
+var ErrorCodeCleanupPolicyUnknown = errcode.Register(errGroup, errcode.ErrorDescriptor{
+	Value:          "CLEANUP_POLICY_UNKNOWN",
+	Message:        "cleanup policy unknown",
+	Description:    "The repository has no cleanup policy",
+	HTTPStatusCode: http.StatusNotFound,
+})
+
+// ErrorCodeRepositoryPurgeUnknown is returned when a repository was never purged.
+var ErrorCodeRepositoryPurgeUnknown = errcode.Register(errGroup, errcode.ErrorDescriptor{
+	Value:          "REPOSITORY_PURGE_UNKNOWN",
+	Message:        "repository purge unknown",
+	Description:    "The repository was never purged",
+	HTTPStatusCode: http.StatusNotFound,
+})
+
+// ErrorCodeRepositoryMetadataUnknown is returned when a repository has no metadata.
+var ErrorCodeRepositoryMetadataUnknown = errcode.Register(errGroup, errcode.ErrorDescriptor{
+	Value:          "REPOSITORY_METADATA_UNKNOWN",
+	Message:        "repository metadata unknown",
+	Description:    "The repository has no metadata",
+	HTTPStatusCode: http.StatusNotFound,
+})
+
+// ErrorCodeImageLayoutInvalid is returned when an imported archive is not a valid OCI image layout.
+var ErrorCodeImageLayoutInvalid = errcode.Register(errGroup, errcode.ErrorDescriptor{
+	Value:          "IMAGE_LAYOUT_INVALID",
+	Message:        "invalid OCI image layout",
+	Description:    "The imported archive is not a valid OCI image layout",
+	HTTPStatusCode: http.StatusBadRequest,
+})
+
 // ErrorCodeUnknownProjectPath is returned when a project path could not be found for a repository.
 var ErrorCodeUnknownProjectPath = errcode.Register(errGroup, errcode.ErrorDescriptor{
 	Value:          "UNKNOWN_PROJECT_PATH_CLAIM",
@@ -97,6 +137,22 @@ func InvalidPatchBodyTypeErrorDetail(key string, patterns ...string) string {
 	return fmt.Sprintf("the '%s' body parameter value must match the following pattern(s): %s", key, strings.Join(patterns, ", "))
 }
 
+func InvalidBodyParamValuePatternErrorDetail(key string, pattern *regexp.Regexp) string {
+	return fmt.Sprintf("the '%s' body parameter values must match the pattern '%s'", key, pattern)
+}
+
+func InvalidBodyParamValueRegexErrorDetail(key string, maxLength int) string {
+	return fmt.Sprintf("the '%s' body parameter value must be a valid regular expression of up to %d characters", key, maxLength)
+}
+
+func InvalidBodyParamLengthErrorDetail(key string, min, max int) string {
+	return fmt.Sprintf("the '%s' body parameter must have between %d and %d elements", key, min, max)
+}
+
+func MissingBodyParamErrorDetail(keys ...string) string {
+	return fmt.Sprintf("one of the following body parameters must be set: %s", strings.Join(keys, ", "))
+}
+
 func MissingServerDependencyTypeErrorDetail(key string) string {
 	return fmt.Sprintf("the server is missing the dependency '%s'", key)
 }
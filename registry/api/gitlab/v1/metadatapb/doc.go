@@ -0,0 +1,5 @@
+// Package metadatapb contains the protocol buffer definitions and generated code of the gRPC metadata API, the
+// counterpart of the GitLab v1 HTTP API for internal consumers.
+package metadatapb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative metadata.proto
@@ -0,0 +1,235 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: metadata.proto
+
+// Package gitlab.registry.v1 is the gRPC counterpart of the GitLab v1 API, for internal consumers that make a high
+// volume of metadata calls, such as GitLab Rails and housekeeping jobs.
+
+package metadatapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Metadata_GetRepository_FullMethodName      = "/gitlab.registry.v1.Metadata/GetRepository"
+	Metadata_ListRepositoryTags_FullMethodName = "/gitlab.registry.v1.Metadata/ListRepositoryTags"
+	Metadata_ListGCAgents_FullMethodName       = "/gitlab.registry.v1.Metadata/ListGCAgents"
+	Metadata_UpdateGCAgents_FullMethodName     = "/gitlab.registry.v1.Metadata/UpdateGCAgents"
+)
+
+// MetadataClient is the client API for Metadata service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MetadataClient interface {
+	// GetRepository returns a repository, optionally with its size. Requires pull access to the repository.
+	GetRepository(ctx context.Context, in *GetRepositoryRequest, opts ...grpc.CallOption) (*Repository, error)
+	// ListRepositoryTags lists the tags of a repository in lexicographical order. Requires pull access to the
+	// repository.
+	ListRepositoryTags(ctx context.Context, in *ListRepositoryTagsRequest, opts ...grpc.CallOption) (*ListRepositoryTagsResponse, error)
+	// ListGCAgents lists the online GC agents of the serving registry instance. Requires access to `registry:gc:*`.
+	ListGCAgents(ctx context.Context, in *ListGCAgentsRequest, opts ...grpc.CallOption) (*ListGCAgentsResponse, error)
+	// UpdateGCAgents pauses or resumes the online GC agents of the serving registry instance. Requires access to
+	// `registry:gc:*`.
+	UpdateGCAgents(ctx context.Context, in *UpdateGCAgentsRequest, opts ...grpc.CallOption) (*ListGCAgentsResponse, error)
+}
+
+type metadataClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMetadataClient(cc grpc.ClientConnInterface) MetadataClient {
+	return &metadataClient{cc}
+}
+
+func (c *metadataClient) GetRepository(ctx context.Context, in *GetRepositoryRequest, opts ...grpc.CallOption) (*Repository, error) {
+	out := new(Repository)
+	err := c.cc.Invoke(ctx, Metadata_GetRepository_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metadataClient) ListRepositoryTags(ctx context.Context, in *ListRepositoryTagsRequest, opts ...grpc.CallOption) (*ListRepositoryTagsResponse, error) {
+	out := new(ListRepositoryTagsResponse)
+	err := c.cc.Invoke(ctx, Metadata_ListRepositoryTags_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metadataClient) ListGCAgents(ctx context.Context, in *ListGCAgentsRequest, opts ...grpc.CallOption) (*ListGCAgentsResponse, error) {
+	out := new(ListGCAgentsResponse)
+	err := c.cc.Invoke(ctx, Metadata_ListGCAgents_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metadataClient) UpdateGCAgents(ctx context.Context, in *UpdateGCAgentsRequest, opts ...grpc.CallOption) (*ListGCAgentsResponse, error) {
+	out := new(ListGCAgentsResponse)
+	err := c.cc.Invoke(ctx, Metadata_UpdateGCAgents_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MetadataServer is the server API for Metadata service.
+// All implementations must embed UnimplementedMetadataServer
+// for forward compatibility
+type MetadataServer interface {
+	// GetRepository returns a repository, optionally with its size. Requires pull access to the repository.
+	GetRepository(context.Context, *GetRepositoryRequest) (*Repository, error)
+	// ListRepositoryTags lists the tags of a repository in lexicographical order. Requires pull access to the
+	// repository.
+	ListRepositoryTags(context.Context, *ListRepositoryTagsRequest) (*ListRepositoryTagsResponse, error)
+	// ListGCAgents lists the online GC agents of the serving registry instance. Requires access to `registry:gc:*`.
+	ListGCAgents(context.Context, *ListGCAgentsRequest) (*ListGCAgentsResponse, error)
+	// UpdateGCAgents pauses or resumes the online GC agents of the serving registry instance. Requires access to
+	// `registry:gc:*`.
+	UpdateGCAgents(context.Context, *UpdateGCAgentsRequest) (*ListGCAgentsResponse, error)
+	mustEmbedUnimplementedMetadataServer()
+}
+
+// UnimplementedMetadataServer must be embedded to have forward compatible implementations.
+type UnimplementedMetadataServer struct {
+}
+
+func (UnimplementedMetadataServer) GetRepository(context.Context, *GetRepositoryRequest) (*Repository, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRepository not implemented")
+}
+func (UnimplementedMetadataServer) ListRepositoryTags(context.Context, *ListRepositoryTagsRequest) (*ListRepositoryTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRepositoryTags not implemented")
+}
+func (UnimplementedMetadataServer) ListGCAgents(context.Context, *ListGCAgentsRequest) (*ListGCAgentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGCAgents not implemented")
+}
+func (UnimplementedMetadataServer) UpdateGCAgents(context.Context, *UpdateGCAgentsRequest) (*ListGCAgentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateGCAgents not implemented")
+}
+func (UnimplementedMetadataServer) mustEmbedUnimplementedMetadataServer() {}
+
+// UnsafeMetadataServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MetadataServer will
+// result in compilation errors.
+type UnsafeMetadataServer interface {
+	mustEmbedUnimplementedMetadataServer()
+}
+
+func RegisterMetadataServer(s grpc.ServiceRegistrar, srv MetadataServer) {
+	s.RegisterService(&Metadata_ServiceDesc, srv)
+}
+
+func _Metadata_GetRepository_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRepositoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetadataServer).GetRepository(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Metadata_GetRepository_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetadataServer).GetRepository(ctx, req.(*GetRepositoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Metadata_ListRepositoryTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRepositoryTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetadataServer).ListRepositoryTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Metadata_ListRepositoryTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetadataServer).ListRepositoryTags(ctx, req.(*ListRepositoryTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Metadata_ListGCAgents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGCAgentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetadataServer).ListGCAgents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Metadata_ListGCAgents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetadataServer).ListGCAgents(ctx, req.(*ListGCAgentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Metadata_UpdateGCAgents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateGCAgentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetadataServer).UpdateGCAgents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Metadata_UpdateGCAgents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetadataServer).UpdateGCAgents(ctx, req.(*UpdateGCAgentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Metadata_ServiceDesc is the grpc.ServiceDesc for Metadata service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Metadata_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gitlab.registry.v1.Metadata",
+	HandlerType: (*MetadataServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetRepository",
+			Handler:    _Metadata_GetRepository_Handler,
+		},
+		{
+			MethodName: "ListRepositoryTags",
+			Handler:    _Metadata_ListRepositoryTags_Handler,
+		},
+		{
+			MethodName: "ListGCAgents",
+			Handler:    _Metadata_ListGCAgents_Handler,
+		},
+		{
+			MethodName: "UpdateGCAgents",
+			Handler:    _Metadata_UpdateGCAgents_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "metadata.proto",
+}
@@ -4,8 +4,11 @@
 package v1
 
 import (
+	"net/http"
+
 	"github.com/docker/distribution/reference"
 	"github.com/gorilla/mux"
+	"github.com/opencontainers/go-digest"
 )
 
 // Route is the name and path pair of a GitLab v1 API route.
@@ -41,6 +44,168 @@ var (
 		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/tags/list/",
 		ID:   Base.Path + "repositories/{name}/tags/list",
 	}
+	// RepositoryTagsBulkDelete is the API route for the repository tags bulk delete endpoint.
+	RepositoryTagsBulkDelete = Route{
+		Name: "repository-tags-bulk-delete",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/tags/",
+		ID:   Base.Path + "repositories/{name}/tags",
+	}
+	// RepositoryImmutableTags is the API route for the repository immutable tag patterns endpoint.
+	RepositoryImmutableTags = Route{
+		Name: "repository-immutable-tags",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/tags/immutability/",
+		ID:   Base.Path + "repositories/{name}/tags/immutability",
+	}
+	// RepositoryTagHistory is the API route for the repository tag history endpoint.
+	RepositoryTagHistory = Route{
+		Name: "repository-tag-history",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/tags/history/{tag:" + reference.TagRegexp.String() + "}/",
+		ID:   Base.Path + "repositories/{name}/tags/history/{tag}",
+	}
+	// RepositoryManifest is the API route for the repository manifest details endpoint. The manifest is identified by
+	// tag or digest.
+	RepositoryManifest = Route{
+		Name: "repository-manifest",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/manifests/{reference:" + reference.TagRegexp.String() + "|" + digest.DigestRegexp.String() + "}/",
+		ID:   Base.Path + "repositories/{name}/manifests/{reference}",
+	}
+	// RepositoryManifestExport is the API route for the repository manifest export endpoint. The manifest is identified
+	// by tag or digest.
+	RepositoryManifestExport = Route{
+		Name: "repository-manifest-export",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/manifests/{reference:" + reference.TagRegexp.String() + "|" + digest.DigestRegexp.String() + "}/export/",
+		ID:   Base.Path + "repositories/{name}/manifests/{reference}/export",
+	}
+	// RepositoryLayoutImport is the API route for importing images from an OCI image layout archive into a repository.
+	RepositoryLayoutImport = Route{
+		Name: "repository-layout-import",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/import/",
+		ID:   Base.Path + "repositories/{name}/import",
+	}
+	// RepositoryEvents is the API route for the repository event stream endpoint.
+	RepositoryEvents = Route{
+		Name: "repository-events",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/events/",
+		ID:   Base.Path + "repositories/{name}/events",
+	}
+	// RepositoryCleanupPolicy is the API route for the repository tag cleanup policy endpoint.
+	RepositoryCleanupPolicy = Route{
+		Name: "repository-cleanup-policy",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/tags/cleanup-policy/",
+		ID:   Base.Path + "repositories/{name}/tags/cleanup-policy",
+	}
+	// RepositoryCleanupPolicyPreview is the API route for the repository tag cleanup policy dry-run endpoint.
+	RepositoryCleanupPolicyPreview = Route{
+		Name: "repository-cleanup-policy-preview",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/tags/cleanup-policy/preview/",
+		ID:   Base.Path + "repositories/{name}/tags/cleanup-policy/preview",
+	}
+	// RepositoryPurge is the API route for starting and polling the asynchronous purge of all tags, manifests and blob
+	// links of a repository.
+	RepositoryPurge = Route{
+		Name: "repository-purge",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/purge/",
+		ID:   Base.Path + "repositories/{name}/purge",
+	}
+	// RepositoryUploads is the API route for listing the in-progress blob uploads of a repository.
+	RepositoryUploads = Route{
+		Name: "repository-uploads",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/uploads/",
+		ID:   Base.Path + "repositories/{name}/uploads",
+	}
+	// RepositoryUpload is the API route for canceling an in-progress blob upload of a repository.
+	RepositoryUpload = Route{
+		Name: "repository-upload",
+		Path: Base.Path + "repositories/{name:" + reference.NameRegexp.String() + "}/uploads/{uuid:[a-zA-Z0-9-_.=]+}/",
+		ID:   Base.Path + "repositories/{name}/uploads/{uuid}",
+	}
+	// GCAgents is the API route for inspecting, pausing and resuming the online garbage collection agents.
+	GCAgents = Route{
+		Name: "gc-agents",
+		Path: Base.Path + "gc/agents/",
+		ID:   Base.Path + "gc/agents",
+	}
+	// GCReviews is the API route for scheduling the immediate online garbage collection review of a blob or repository.
+	GCReviews = Route{
+		Name: "gc-reviews",
+		Path: Base.Path + "gc/reviews/",
+		ID:   Base.Path + "gc/reviews",
+	}
+	// SizeRecalculation is the API route for inspecting and triggering the background size recalculation.
+	SizeRecalculation = Route{
+		Name: "size-recalculation",
+		Path: Base.Path + "sizes/recalculation/",
+		ID:   Base.Path + "sizes/recalculation",
+	}
+	// BlobDeduplication is the API route for the blob deduplication statistics.
+	BlobDeduplication = Route{
+		Name: "blob-deduplication",
+		Path: Base.Path + "statistics/deduplication/",
+		ID:   Base.Path + "statistics/deduplication",
+	}
+	// BlobVerification is the API route for the report of the background blob integrity verification.
+	BlobVerification = Route{
+		Name: "blob-verification",
+		Path: Base.Path + "blobs/verification/",
+		ID:   Base.Path + "blobs/verification",
+	}
+	// Replication is the API route for inspecting the replication to downstream registries.
+	Replication = Route{
+		Name: "replication",
+		Path: Base.Path + "replication/",
+		ID:   Base.Path + "replication",
+	}
+	// Notifications is the API route for inspecting the persistent queue of notification events.
+	Notifications = Route{
+		Name: "notifications",
+		Path: Base.Path + "notifications/",
+		ID:   Base.Path + "notifications",
+	}
+	// NotificationsReplay is the API route for requeuing notification events whose delivery failed.
+	NotificationsReplay = Route{
+		Name: "notifications-replay",
+		Path: Base.Path + "notifications/replay/",
+		ID:   Base.Path + "notifications/replay",
+	}
+	// GroupRepositories is the API route for the list of repositories under a group (namespace) path.
+	GroupRepositories = Route{
+		Name: "group-repositories",
+		Path: Base.Path + "groups/{name:" + reference.NameRegexp.String() + "}/repositories/",
+		ID:   Base.Path + "groups/{name}/repositories",
+	}
+	// GroupManifestSearch is the API route for searching manifests by annotation under a group (namespace) path.
+	GroupManifestSearch = Route{
+		Name: "group-manifest-search",
+		Path: Base.Path + "groups/{name:" + reference.NameRegexp.String() + "}/manifests/search/",
+		ID:   Base.Path + "groups/{name}/manifests/search",
+	}
+	// GroupStatistics is the API route for the daily usage statistics of a top-level namespace.
+	GroupStatistics = Route{
+		Name: "group-statistics",
+		Path: Base.Path + "groups/{name:" + reference.NameRegexp.String() + "}/statistics/",
+		ID:   Base.Path + "groups/{name}/statistics",
+	}
+	// RepositorySettings is the API route for the repository settings endpoint. It lives under a dedicated prefix so that
+	// it can not be confused with the Repositories route for repositories whose path ends with `/settings`.
+	RepositorySettings = Route{
+		Name: "repository-settings",
+		Path: Base.Path + "repository-settings/{name:" + reference.NameRegexp.String() + "}/",
+		ID:   Base.Path + "repository-settings/{name}",
+	}
+	// RepositoryMetadata is the API route for the repository metadata endpoint. It lives under a dedicated prefix for the
+	// same reason as RepositorySettings.
+	RepositoryMetadata = Route{
+		Name: "repository-metadata",
+		Path: Base.Path + "repository-metadata/{name:" + reference.NameRegexp.String() + "}/",
+		ID:   Base.Path + "repository-metadata/{name}",
+	}
+	// RepositoryLabels is the API route for the repository labels endpoint. It lives under a dedicated prefix for the
+	// same reason as RepositorySettings.
+	RepositoryLabels = Route{
+		Name: "repository-labels",
+		Path: Base.Path + "repository-labels/{name:" + reference.NameRegexp.String() + "}/",
+		ID:   Base.Path + "repository-labels/{name}",
+	}
 	// SubRepositories is the API route for the sub-repositories list.
 	SubRepositories = Route{
 		Name: "sub-repositories",
@@ -68,8 +233,48 @@ func RouterWithPrefix(prefix string) *mux.Router {
 	router.Path(Base.Path).Name(Base.Name)
 	router.Path(RepositoryImport.Path).Name(RepositoryImport.Name)
 	router.Path(RepositoryTags.Path).Name(RepositoryTags.Name)
+	router.Path(RepositoryImmutableTags.Path).Name(RepositoryImmutableTags.Name)
+	router.Path(RepositoryTagHistory.Path).Name(RepositoryTagHistory.Name)
+	router.Path(RepositoryManifest.Path).Name(RepositoryManifest.Name)
+	// Only GET requests are routed here, so that PATCH requests for repositories whose path ends with
+	// `/manifests/<tag>/export` fall through to the Repositories route.
+	router.Path(RepositoryManifestExport.Path).Methods(http.MethodGet).Name(RepositoryManifestExport.Name)
+	// Only GET requests are routed here, so that PATCH requests for repositories whose path ends with `/events` fall
+	// through to the Repositories route.
+	router.Path(RepositoryEvents.Path).Methods(http.MethodGet).Name(RepositoryEvents.Name)
+	// Only POST requests are routed here, so that GET and PATCH requests for repositories whose path ends with `/import`
+	// fall through to the Repositories route.
+	router.Path(RepositoryLayoutImport.Path).Methods(http.MethodPost).Name(RepositoryLayoutImport.Name)
+	// Only GET and POST requests are routed here, so that PATCH requests for repositories whose path ends with `/purge`
+	// fall through to the Repositories route.
+	router.Path(RepositoryPurge.Path).Methods(http.MethodGet, http.MethodPost).Name(RepositoryPurge.Name)
+	// Only GET requests are routed here, so that PATCH requests for repositories whose path ends with `/uploads` fall
+	// through to the Repositories route.
+	router.Path(RepositoryUploads.Path).Methods(http.MethodGet).Name(RepositoryUploads.Name)
+	// Only DELETE requests are routed here, so that GET and PATCH requests for repositories whose path ends with
+	// `/uploads/<uuid>` fall through to the Repositories route.
+	router.Path(RepositoryUpload.Path).Methods(http.MethodDelete).Name(RepositoryUpload.Name)
+	router.Path(RepositoryCleanupPolicyPreview.Path).Name(RepositoryCleanupPolicyPreview.Name)
+	router.Path(RepositoryCleanupPolicy.Path).Name(RepositoryCleanupPolicy.Name)
+	// Only DELETE requests are routed here, so that GET and PATCH requests for repositories whose path ends with `/tags`
+	// fall through to the Repositories route.
+	router.Path(RepositoryTagsBulkDelete.Path).Methods(http.MethodDelete).Name(RepositoryTagsBulkDelete.Name)
 	router.Path(Repositories.Path).Name(Repositories.Name)
 	router.Path(SubRepositories.Path).Name(SubRepositories.Name)
+	router.Path(RepositorySettings.Path).Name(RepositorySettings.Name)
+	router.Path(RepositoryMetadata.Path).Name(RepositoryMetadata.Name)
+	router.Path(RepositoryLabels.Path).Name(RepositoryLabels.Name)
+	router.Path(GroupRepositories.Path).Name(GroupRepositories.Name)
+	router.Path(GroupManifestSearch.Path).Name(GroupManifestSearch.Name)
+	router.Path(GroupStatistics.Path).Name(GroupStatistics.Name)
+	router.Path(GCAgents.Path).Name(GCAgents.Name)
+	router.Path(GCReviews.Path).Name(GCReviews.Name)
+	router.Path(SizeRecalculation.Path).Name(SizeRecalculation.Name)
+	router.Path(BlobDeduplication.Path).Name(BlobDeduplication.Name)
+	router.Path(BlobVerification.Path).Name(BlobVerification.Name)
+	router.Path(Replication.Path).Name(Replication.Name)
+	router.Path(Notifications.Path).Name(Notifications.Name)
+	router.Path(NotificationsReplay.Path).Name(NotificationsReplay.Name)
 
 	return rootRouter
 }
@@ -150,6 +150,18 @@ func (ub *Builder) BuildTagsURL(name reference.Named, values ...url.Values) (str
 	return appendValuesURL(tagsURL, values...).String(), nil
 }
 
+// BuildReferrersURL constructs a url to list the referrers of the manifest identified by ref.
+func (ub *Builder) BuildReferrersURL(ref reference.Canonical, values ...url.Values) (string, error) {
+	route := ub.cloneDistributionRoute(v2.RouteNameReferrers)
+
+	referrersURL, err := route.URL("name", ref.Name(), "digest", ref.Digest().String())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(referrersURL, values...).String(), nil
+}
+
 // BuildTagURL constructs an url for a tag.
 func (ub *Builder) BuildTagURL(ref reference.NamedTagged) (string, error) {
 	route := ub.cloneDistributionRoute(v2.RouteNameTag)
@@ -261,6 +273,141 @@ func (ub *Builder) BuildGitlabV1RepositoryTagsURL(name reference.Named, values .
 	return appendValuesURL(u, values...).String(), nil
 }
 
+// BuildGitlabV1RepositoryTagsBulkDeleteURL constructs a URL for the Gitlab v1 API repository tags bulk delete route by
+// name.
+func (ub *Builder) BuildGitlabV1RepositoryTagsBulkDeleteURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryTagsBulkDelete)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1RepositoryTagHistoryURL constructs a URL for the Gitlab v1 API repository tag history route by name and
+// tag.
+func (ub *Builder) BuildGitlabV1RepositoryTagHistoryURL(ref reference.NamedTagged, values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryTagHistory)
+
+	u, err := route.URL("name", ref.Name(), "tag", ref.Tag())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1RepositoryManifestURL constructs a URL for the Gitlab v1 API repository manifest route by name and
+// tag or digest.
+func (ub *Builder) BuildGitlabV1RepositoryManifestURL(ref reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryManifest)
+
+	tagOrDigest := ""
+	switch v := ref.(type) {
+	case reference.Tagged:
+		tagOrDigest = v.Tag()
+	case reference.Digested:
+		tagOrDigest = v.Digest().String()
+	default:
+		return "", fmt.Errorf("reference must have a tag or digest")
+	}
+
+	u, err := route.URL("name", ref.Name(), "reference", tagOrDigest)
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1RepositoryManifestExportURL constructs a URL for the Gitlab v1 API repository manifest export route by
+// name and tag or digest.
+func (ub *Builder) BuildGitlabV1RepositoryManifestExportURL(ref reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryManifestExport)
+
+	tagOrDigest := ""
+	switch v := ref.(type) {
+	case reference.Tagged:
+		tagOrDigest = v.Tag()
+	case reference.Digested:
+		tagOrDigest = v.Digest().String()
+	default:
+		return "", fmt.Errorf("reference must have a tag or digest")
+	}
+
+	u, err := route.URL("name", ref.Name(), "reference", tagOrDigest)
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1RepositoryLayoutImportURL constructs a URL for the Gitlab v1 API repository OCI image layout import
+// route by name.
+func (ub *Builder) BuildGitlabV1RepositoryLayoutImportURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryLayoutImport)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1RepositoryEventsURL constructs a URL for the Gitlab v1 API repository events route by name.
+func (ub *Builder) BuildGitlabV1RepositoryEventsURL(name reference.Named) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryEvents)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1RepositoryPurgeURL constructs a URL for the Gitlab v1 API repository purge route by name.
+func (ub *Builder) BuildGitlabV1RepositoryPurgeURL(name reference.Named) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryPurge)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1RepositoryCleanupPolicyURL constructs a URL for the Gitlab v1 API repository cleanup policy route by
+// name.
+func (ub *Builder) BuildGitlabV1RepositoryCleanupPolicyURL(name reference.Named) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryCleanupPolicy)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1RepositoryCleanupPolicyPreviewURL constructs a URL for the Gitlab v1 API repository cleanup policy
+// preview route by name.
+func (ub *Builder) BuildGitlabV1RepositoryCleanupPolicyPreviewURL(name reference.Named) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryCleanupPolicyPreview)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
 // BuildGitlabV1RepositoryImportURL constructs a URL for the Gitlab v1 API
 // repository import route by name.
 func (ub *Builder) BuildGitlabV1RepositoryImportURL(name reference.Named, values ...url.Values) (string, error) {
@@ -286,6 +433,199 @@ func (ub *Builder) BuildGitlabV1SubRepositoriesURL(name reference.Named, values
 	return appendValuesURL(u, values...).String(), nil
 }
 
+// BuildGitlabV1GroupRepositoriesURL constructs a URL for the Gitlab v1 API group repositories route by name.
+func (ub *Builder) BuildGitlabV1GroupRepositoriesURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.GroupRepositories)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1GroupManifestSearchURL constructs a URL for the Gitlab v1 API group manifest search route by name.
+func (ub *Builder) BuildGitlabV1GroupManifestSearchURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.GroupManifestSearch)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1GroupStatisticsURL constructs a URL for the Gitlab v1 API group statistics route by name.
+func (ub *Builder) BuildGitlabV1GroupStatisticsURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.GroupStatistics)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1RepositorySettingsURL constructs a URL for the Gitlab v1 API repository settings route by name.
+func (ub *Builder) BuildGitlabV1RepositorySettingsURL(name reference.Named) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositorySettings)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1RepositoryMetadataURL constructs a URL for the Gitlab v1 API repository metadata route by name.
+func (ub *Builder) BuildGitlabV1RepositoryMetadataURL(name reference.Named) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryMetadata)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1RepositoryUploadsURL constructs a URL for the Gitlab v1 API repository uploads route by name.
+func (ub *Builder) BuildGitlabV1RepositoryUploadsURL(name reference.Named) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryUploads)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1RepositoryUploadURL constructs a URL for the Gitlab v1 API repository upload route by name and upload
+// UUID.
+func (ub *Builder) BuildGitlabV1RepositoryUploadURL(name reference.Named, uuid string) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryUpload)
+
+	u, err := route.URL("name", name.Name(), "uuid", uuid)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1RepositoryLabelsURL constructs a URL for the Gitlab v1 API repository labels route by name.
+func (ub *Builder) BuildGitlabV1RepositoryLabelsURL(name reference.Named) (string, error) {
+	route := ub.cloneGitLabRoute(v1.RepositoryLabels)
+
+	u, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1GCAgentsURL constructs a URL for the Gitlab v1 API online GC agents route.
+func (ub *Builder) BuildGitlabV1GCAgentsURL() (string, error) {
+	route := ub.cloneGitLabRoute(v1.GCAgents)
+
+	u, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1SizeRecalculationURL constructs a URL for the Gitlab v1 API size recalculation route.
+func (ub *Builder) BuildGitlabV1SizeRecalculationURL() (string, error) {
+	route := ub.cloneGitLabRoute(v1.SizeRecalculation)
+
+	u, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1BlobDeduplicationURL constructs a URL for the Gitlab v1 API blob deduplication statistics route.
+func (ub *Builder) BuildGitlabV1BlobDeduplicationURL(values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.BlobDeduplication)
+
+	u, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1BlobVerificationURL constructs a URL for the Gitlab v1 API blob verification report route.
+func (ub *Builder) BuildGitlabV1BlobVerificationURL(values ...url.Values) (string, error) {
+	route := ub.cloneGitLabRoute(v1.BlobVerification)
+
+	u, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(u, values...).String(), nil
+}
+
+// BuildGitlabV1ReplicationURL constructs a URL for the Gitlab v1 API replication status route.
+func (ub *Builder) BuildGitlabV1ReplicationURL() (string, error) {
+	route := ub.cloneGitLabRoute(v1.Replication)
+
+	u, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1GCReviewsURL constructs a URL for the Gitlab v1 API online GC reviews route.
+func (ub *Builder) BuildGitlabV1GCReviewsURL() (string, error) {
+	route := ub.cloneGitLabRoute(v1.GCReviews)
+
+	u, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1NotificationsURL constructs a URL for the Gitlab v1 API notifications queue route.
+func (ub *Builder) BuildGitlabV1NotificationsURL() (string, error) {
+	route := ub.cloneGitLabRoute(v1.Notifications)
+
+	u, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// BuildGitlabV1NotificationsReplayURL constructs a URL for the Gitlab v1 API notifications replay route.
+func (ub *Builder) BuildGitlabV1NotificationsReplayURL() (string, error) {
+	route := ub.cloneGitLabRoute(v1.NotificationsReplay)
+
+	u, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
 // cloneDistributionRoute returns a clone of the named route from the
 // distribution router. Routes must be cloned to avoid modifying them during
 // url generation.
@@ -91,6 +91,17 @@ func makeBuilderTestCases(builder *Builder) []urlBuilderTestCase {
 				return builder.BuildBlobURL(ref)
 			},
 		},
+		{
+			description:  "build referrers url",
+			expectedPath: "/v2/foo/bar/referrers/sha256:3b3692957d439ac1928219a83fac91e7bf96c153725526874673ae1f2023f8d5?artifactType=application%2Fvnd.amazon.soci.index.v1%2Bjson",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				ref, _ := reference.WithDigest(fooBarRef, "sha256:3b3692957d439ac1928219a83fac91e7bf96c153725526874673ae1f2023f8d5")
+				return builder.BuildReferrersURL(ref, url.Values{
+					"artifactType": []string{"application/vnd.amazon.soci.index.v1+json"},
+				})
+			},
+		},
 		{
 			description:  "build blob upload url",
 			expectedPath: "/v2/foo/bar/blobs/uploads/",
@@ -191,6 +202,238 @@ func makeBuilderTestCases(builder *Builder) []urlBuilderTestCase {
 				})
 			},
 		},
+		{
+			description:  "test Gitlab v1 repository tags bulk delete url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/tags/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryTagsBulkDeleteURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository tag history url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/tags/history/latest/?n=10",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				tagged, err := reference.WithTag(fooBarRef, "latest")
+				if err != nil {
+					return "", err
+				}
+				return builder.BuildGitlabV1RepositoryTagHistoryURL(tagged, url.Values{"n": []string{"10"}})
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository manifest url by tag",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/manifests/latest/?config=true",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				tagged, err := reference.WithTag(fooBarRef, "latest")
+				if err != nil {
+					return "", err
+				}
+				return builder.BuildGitlabV1RepositoryManifestURL(tagged, url.Values{"config": []string{"true"}})
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository manifest url by digest",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/manifests/sha256:3b3692957d439ac1928219a83fac91e7bf96c153725526874673ae1f2023f8d5/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				canonical, err := reference.WithDigest(fooBarRef, "sha256:3b3692957d439ac1928219a83fac91e7bf96c153725526874673ae1f2023f8d5")
+				if err != nil {
+					return "", err
+				}
+				return builder.BuildGitlabV1RepositoryManifestURL(canonical)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository manifest export url by tag",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/manifests/latest/export/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				tagged, err := reference.WithTag(fooBarRef, "latest")
+				if err != nil {
+					return "", err
+				}
+				return builder.BuildGitlabV1RepositoryManifestExportURL(tagged)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository manifest export url by digest",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/manifests/sha256:3b3692957d439ac1928219a83fac91e7bf96c153725526874673ae1f2023f8d5/export/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				canonical, err := reference.WithDigest(fooBarRef, "sha256:3b3692957d439ac1928219a83fac91e7bf96c153725526874673ae1f2023f8d5")
+				if err != nil {
+					return "", err
+				}
+				return builder.BuildGitlabV1RepositoryManifestExportURL(canonical)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository layout import url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/import/?url=https%3A%2F%2Fartifacts.example.com%2Fimage.tar",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryLayoutImportURL(fooBarRef, url.Values{"url": []string{"https://artifacts.example.com/image.tar"}})
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository events url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/events/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryEventsURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository purge url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/purge/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryPurgeURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository cleanup policy url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/tags/cleanup-policy/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryCleanupPolicyURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository cleanup policy preview url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/tags/cleanup-policy/preview/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryCleanupPolicyPreviewURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 group repositories url",
+			expectedPath: "/gitlab/v1/groups/foo/bar/repositories/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1GroupRepositoriesURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 group repositories url with pagination",
+			expectedPath: "/gitlab/v1/groups/foo/bar/repositories/?last=foo%2Fbar%2Fbaz&n=10",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1GroupRepositoriesURL(fooBarRef, url.Values{"n": []string{"10"}, "last": []string{"foo/bar/baz"}})
+			},
+		},
+		{
+			description:  "test Gitlab v1 group manifest search url",
+			expectedPath: "/gitlab/v1/groups/foo/bar/manifests/search/?annotation=org.opencontainers.image.source%3Dhttps%3A%2F%2Fexample.com",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1GroupManifestSearchURL(fooBarRef, url.Values{"annotation": []string{"org.opencontainers.image.source=https://example.com"}})
+			},
+		},
+		{
+			description:  "test Gitlab v1 group statistics url",
+			expectedPath: "/gitlab/v1/groups/foo/bar/statistics/?from=2023-12-01&to=2023-12-31",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1GroupStatisticsURL(fooBarRef, url.Values{"from": []string{"2023-12-01"}, "to": []string{"2023-12-31"}})
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository settings url",
+			expectedPath: "/gitlab/v1/repository-settings/foo/bar/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositorySettingsURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository metadata url",
+			expectedPath: "/gitlab/v1/repository-metadata/foo/bar/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryMetadataURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository labels url",
+			expectedPath: "/gitlab/v1/repository-labels/foo/bar/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryLabelsURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository uploads url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/uploads/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryUploadsURL(fooBarRef)
+			},
+		},
+		{
+			description:  "test Gitlab v1 repository upload url",
+			expectedPath: "/gitlab/v1/repositories/foo/bar/uploads/uuid-4321/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1RepositoryUploadURL(fooBarRef, "uuid-4321")
+			},
+		},
+		{
+			description:  "test Gitlab v1 GC agents url",
+			expectedPath: "/gitlab/v1/gc/agents/",
+			expectedErr:  nil,
+			build:        builder.BuildGitlabV1GCAgentsURL,
+		},
+		{
+			description:  "test Gitlab v1 size recalculation url",
+			expectedPath: "/gitlab/v1/sizes/recalculation/",
+			expectedErr:  nil,
+			build:        builder.BuildGitlabV1SizeRecalculationURL,
+		},
+		{
+			description:  "test Gitlab v1 blob deduplication url",
+			expectedPath: "/gitlab/v1/statistics/deduplication/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1BlobDeduplicationURL()
+			},
+		},
+		{
+			description:  "test Gitlab v1 blob verification url",
+			expectedPath: "/gitlab/v1/blobs/verification/",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return builder.BuildGitlabV1BlobVerificationURL()
+			},
+		},
+		{
+			description:  "test Gitlab v1 replication url",
+			expectedPath: "/gitlab/v1/replication/",
+			expectedErr:  nil,
+			build:        builder.BuildGitlabV1ReplicationURL,
+		},
+		{
+			description:  "test Gitlab v1 gc reviews url",
+			expectedPath: "/gitlab/v1/gc/reviews/",
+			expectedErr:  nil,
+			build:        builder.BuildGitlabV1GCReviewsURL,
+		},
+		{
+			description:  "test Gitlab v1 notifications url",
+			expectedPath: "/gitlab/v1/notifications/",
+			expectedErr:  nil,
+			build:        builder.BuildGitlabV1NotificationsURL,
+		},
+		{
+			description:  "test Gitlab v1 notifications replay url",
+			expectedPath: "/gitlab/v1/notifications/replay/",
+			expectedErr:  nil,
+			build:        builder.BuildGitlabV1NotificationsReplayURL,
+		},
 	}
 }
 
@@ -41,6 +41,14 @@ var (
 		Description: "A uuid identifying the upload. This field can accept characters that match `[a-zA-Z0-9-_.=]+`.",
 	}
 
+	chunkDigestQueryParameter = ParameterDescriptor{
+		Name:        "digest",
+		Type:        "query",
+		Format:      "<digest>",
+		Regexp:      digest.DigestRegexp,
+		Description: "Digest of the data in the request body, if known. The data is validated against it as soon as it is received. On mismatch, the upload is canceled and must be restarted.",
+	}
+
 	digestPathParameter = ParameterDescriptor{
 		Name:        "digest",
 		Type:        "path",
@@ -89,6 +97,13 @@ var (
 		Format:      "<uuid>",
 	}
 
+	ociChunkMinLengthHeader = ParameterDescriptor{
+		Name:        "OCI-Chunk-Min-Length",
+		Description: "Minimum size in bytes of each chunk uploaded with a `PATCH` request, except for the final chunk. Only present if the registry enforces a minimum chunk size.",
+		Type:        "integer",
+		Format:      "<minimum chunk size>",
+	}
+
 	digestHeader = ParameterDescriptor{
 		Name:        "Docker-Content-Digest",
 		Description: "Digest of the targeted content for the request.",
@@ -103,6 +118,62 @@ var (
 		Format:      `<<url>?n=<last n value>&last=<last entry from response>>; rel="next"`,
 	}
 
+	ifNoneMatchHeader = ParameterDescriptor{
+		Name:        "If-None-Match",
+		Type:        "string",
+		Description: "Etag of a previously retrieved response. If it matches the current one, a 304 Not Modified response without a body is returned.",
+		Format:      `"<etag>"`,
+	}
+
+	ifMatchTagHeader = ParameterDescriptor{
+		Name:        "If-Match",
+		Type:        "string",
+		Description: "Comma separated list of manifest digests. If set and `reference` is a tag, the request only succeeds if the tag exists and points to one of these manifests, or if the tag exists when set to `*`. Otherwise, a 412 Precondition Failed response is returned.",
+		Format:      `"<digest>"`,
+	}
+
+	tagPreconditionFailedResponseDescriptor = ResponseDescriptor{
+		Name:        "Tag Precondition Failed",
+		Description: "The request included an `If-Match` header and the tag does not exist or does not point to any of the listed manifests, usually because it was changed concurrently.",
+		StatusCode:  http.StatusPreconditionFailed,
+		Body: BodyDescriptor{
+			ContentType: "application/json",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeTagPreconditionFailed,
+		},
+	}
+
+	tagProtectedResponseDescriptor = ResponseDescriptor{
+		Name:        "Tag Protected",
+		Description: "The tag matches one of the protected tag patterns that the auth token was issued with, and therefore can not be pushed or deleted.",
+		StatusCode:  http.StatusForbidden,
+		Body: BodyDescriptor{
+			ContentType: "application/json",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeTagProtected,
+		},
+	}
+
+	listEtagHeader = ParameterDescriptor{
+		Name:        "Etag",
+		Type:        "string",
+		Description: "Opaque identifier of the response body and pagination links, which can be sent in the `If-None-Match` header of subsequent requests.",
+		Format:      `"<etag>"`,
+	}
+
+	listNotModifiedResponseDescriptor = ResponseDescriptor{
+		Name:        "Not Modified",
+		StatusCode:  http.StatusNotModified,
+		Description: "The list did not change since it was retrieved with the `Etag` sent in the `If-None-Match` header.",
+		Headers: []ParameterDescriptor{
+			listEtagHeader,
+		},
+	}
+
 	paginationParameters = []ParameterDescriptor{
 		{
 			Name:        "n",
@@ -118,6 +189,51 @@ var (
 			Format:      "<integer>",
 			Required:    false,
 		},
+		{
+			Name:        "count",
+			Type:        "boolean",
+			Description: "Set to `true` to include the total number of entries and pages in the `X-Total-Count` and `X-Total-Pages` headers. Requires the metadata database.",
+			Format:      "<boolean>",
+			Required:    false,
+		},
+	}
+
+	totalCountHeader = ParameterDescriptor{
+		Name:        "X-Total-Count",
+		Type:        "integer",
+		Description: "Total number of entries across all pages. Only present if requested with `count=true` and the registry uses the metadata database.",
+		Format:      "<integer>",
+	}
+
+	totalPagesHeader = ParameterDescriptor{
+		Name:        "X-Total-Pages",
+		Type:        "integer",
+		Description: "Total number of pages for the requested page size `n`. Only present if requested with `count=true` and the registry uses the metadata database.",
+		Format:      "<integer>",
+	}
+
+	catalogFilterParameters = []ParameterDescriptor{
+		{
+			Name:        "prefix",
+			Type:        "string",
+			Description: "Only include repositories whose path starts with prefix. Requires the metadata database.",
+			Format:      "<string>",
+			Required:    false,
+		},
+		{
+			Name:        "modified_since",
+			Type:        "string",
+			Description: "Only include repositories modified at or after the given time. Requires the metadata database.",
+			Format:      "<RFC 3339 timestamp>",
+			Required:    false,
+		},
+		{
+			Name:        "label",
+			Type:        "string",
+			Description: "Only include repositories with a label with the given key and value, or just key for any value. Can be repeated to require multiple labels. Requires the metadata database.",
+			Format:      "<key>[=<value>]",
+			Required:    false,
+		},
 	}
 
 	unauthorizedResponseDescriptor = ResponseDescriptor{
@@ -452,6 +568,7 @@ var routeDescriptors = []RouteDescriptor{
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
+							ifNoneMatchHeader,
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -467,6 +584,7 @@ var routeDescriptors = []RouteDescriptor{
 										Description: "Length of the JSON response body.",
 										Format:      "<length>",
 									},
+									listEtagHeader,
 								},
 								Body: BodyDescriptor{
 									ContentType: "application/json",
@@ -479,6 +597,7 @@ var routeDescriptors = []RouteDescriptor{
 }`,
 								},
 							},
+							listNotModifiedResponseDescriptor,
 						},
 						Failures: []ResponseDescriptor{
 							unauthorizedResponseDescriptor,
@@ -490,6 +609,7 @@ var routeDescriptors = []RouteDescriptor{
 					{
 						Name:            "Tags Paginated",
 						Description:     "Return a portion of the tags for the specified repository.",
+						Headers:         []ParameterDescriptor{ifNoneMatchHeader},
 						PathParameters:  []ParameterDescriptor{nameParameterDescriptor},
 						QueryParameters: paginationParameters,
 						Successes: []ResponseDescriptor{
@@ -503,7 +623,10 @@ var routeDescriptors = []RouteDescriptor{
 										Description: "Length of the JSON response body.",
 										Format:      "<length>",
 									},
+									listEtagHeader,
 									linkHeader,
+									totalCountHeader,
+									totalPagesHeader,
 								},
 								Body: BodyDescriptor{
 									ContentType: "application/json",
@@ -516,6 +639,7 @@ var routeDescriptors = []RouteDescriptor{
 }`,
 								},
 							},
+							listNotModifiedResponseDescriptor,
 						},
 						Failures: []ResponseDescriptor{
 							unauthorizedResponseDescriptor,
@@ -542,6 +666,7 @@ var routeDescriptors = []RouteDescriptor{
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
+							ifMatchTagHeader,
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -591,6 +716,8 @@ var routeDescriptors = []RouteDescriptor{
 									errcode.ErrorCodeUnsupported,
 								},
 							},
+							tagPreconditionFailedResponseDescriptor,
+							tagProtectedResponseDescriptor,
 						},
 					},
 				},
@@ -616,6 +743,14 @@ var routeDescriptors = []RouteDescriptor{
 							nameParameterDescriptor,
 							referenceParameterDescriptor,
 						},
+						QueryParameters: []ParameterDescriptor{
+							{
+								Name:        "platform",
+								Type:        "string",
+								Format:      "<os>/<architecture>[/<variant>]",
+								Description: "If `reference` identifies a manifest list or image index, the manifest for the given platform is returned instead. The `Docker-Content-Digest` header is the digest of the returned manifest. Ignored for other manifests.",
+							},
+						},
 						Successes: []ResponseDescriptor{
 							{
 								Description: "The manifest identified by `name` and `reference`. The contents can be used to identify and resolve resources required to run the specified image.",
@@ -636,6 +771,7 @@ var routeDescriptors = []RouteDescriptor{
 								ErrorCodes: []errcode.ErrorCode{
 									ErrorCodeNameInvalid,
 									ErrorCodeTagInvalid,
+									ErrorCodePlatformInvalid,
 								},
 								Body: BodyDescriptor{
 									ContentType: "application/json",
@@ -646,6 +782,18 @@ var routeDescriptors = []RouteDescriptor{
 							repositoryNotFoundResponseDescriptor,
 							deniedResponseDescriptor,
 							tooManyRequestsDescriptor,
+							{
+								Name:        "Signature Required",
+								Description: "The repository matches a signature policy and the manifest does not have a valid signature from a trusted signer.",
+								StatusCode:  http.StatusForbidden,
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodeManifestSignatureRequired,
+								},
+							},
 						},
 					},
 				},
@@ -658,6 +806,7 @@ var routeDescriptors = []RouteDescriptor{
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
+							ifMatchTagHeader,
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -680,6 +829,12 @@ var routeDescriptors = []RouteDescriptor{
 									},
 									contentLengthZeroHeader,
 									digestHeader,
+									{
+										Name:        "OCI-Subject",
+										Type:        "digest",
+										Description: "Digest of the subject of the uploaded manifest, if any. Only present if the metadata database is enabled, in which case clients do not need to maintain a referrers tag schema image index for the subject.",
+										Format:      "<digest>",
+									},
 								},
 							},
 						},
@@ -734,6 +889,20 @@ var routeDescriptors = []RouteDescriptor{
 									errcode.ErrorCodeUnsupported,
 								},
 							},
+							{
+								Name:        "Immutable Tag",
+								Description: "The tag already exists, points to a different manifest and matches one of the immutable tag patterns configured for the repository.",
+								StatusCode:  http.StatusConflict,
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodeTagImmutable,
+								},
+							},
+							tagPreconditionFailedResponseDescriptor,
+							tagProtectedResponseDescriptor,
 						},
 					},
 				},
@@ -746,11 +915,20 @@ var routeDescriptors = []RouteDescriptor{
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
+							ifMatchTagHeader,
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
 							referenceParameterDescriptor,
 						},
+						QueryParameters: []ParameterDescriptor{
+							{
+								Name:        "cascade",
+								Type:        "string",
+								Format:      "referrers",
+								Description: "If set to `referrers`, all manifests that reference the deleted manifest as their subject, directly or through other referrers, are deleted as well. Only supported when deleting manifests by digest, and if enabled in the registry configuration.",
+							},
+						},
 						Successes: []ResponseDescriptor{
 							{
 								StatusCode: http.StatusAccepted,
@@ -789,7 +967,7 @@ var routeDescriptors = []RouteDescriptor{
 							},
 							{
 								Name:        "Not allowed",
-								Description: "Manifest or tag delete is not allowed because the registry is configured as a pull-through cache or `delete` has been disabled.",
+								Description: "Manifest or tag delete is not allowed because the registry is configured as a pull-through cache or `delete` has been disabled, or the `cascade` query parameter is invalid or not allowed.",
 								StatusCode:  http.StatusMethodNotAllowed,
 								ErrorCodes: []errcode.ErrorCode{
 									errcode.ErrorCodeUnsupported,
@@ -807,6 +985,8 @@ var routeDescriptors = []RouteDescriptor{
 									Format:      errorsBody,
 								},
 							},
+							tagPreconditionFailedResponseDescriptor,
+							tagProtectedResponseDescriptor,
 						},
 					},
 				},
@@ -814,6 +994,95 @@ var routeDescriptors = []RouteDescriptor{
 		},
 	},
 
+	{
+		Name:        RouteNameReferrers,
+		Path:        "/v2/{name:" + reference.NameRegexp.String() + "}/referrers/{digest:" + digest.DigestRegexp.String() + "}",
+		Entity:      "Referrers",
+		Description: "Retrieve the manifests that reference a manifest as their subject, such as signatures, SBOMs or seekable layer indexes (e.g. SOCI indexes) used by lazy-pulling snapshotters.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      http.MethodGet,
+				Description: "Fetch the list of referrers of the manifest identified by `name` and `digest`.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+							{
+								Name:        "digest",
+								Type:        "path",
+								Required:    true,
+								Format:      digest.DigestRegexp.String(),
+								Description: "Digest of the subject manifest.",
+							},
+						},
+						QueryParameters: []ParameterDescriptor{
+							{
+								Name:        "artifactType",
+								Type:        "query",
+								Format:      "<artifact type>",
+								Description: "If set, only referrers with this artifact type are returned.",
+							},
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "An image index listing the descriptors of the referrers. The list is empty if the subject manifest has no referrers or does not exist.",
+								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "OCI-Filters-Applied",
+										Type:        "string",
+										Description: "Set to `artifactType` if the list was filtered by artifact type.",
+										Format:      "artifactType",
+									},
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/vnd.oci.image.index.v1+json",
+									Format: `{
+    "schemaVersion": 2,
+    "mediaType": "application/vnd.oci.image.index.v1+json",
+    "manifests": [
+        {
+            "mediaType": <media type>,
+            "artifactType": <artifact type>,
+            "digest": <digest>,
+            "size": <size>,
+            "annotations": {
+                <key>: <value>,
+                ...
+            }
+        },
+        ...
+    ]
+}`,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Name:        "Invalid Digest",
+								Description: "The specified `digest` is invalid.",
+								StatusCode:  http.StatusBadRequest,
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodeDigestInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							unauthorizedResponseDescriptor,
+							deniedResponseDescriptor,
+							tooManyRequestsDescriptor,
+						},
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:        RouteNameBlob,
 		Path:        "/v2/{name:" + reference.NameRegexp.String() + "}/blobs/{digest:" + digest.DigestRegexp.String() + "}",
@@ -836,7 +1105,7 @@ var routeDescriptors = []RouteDescriptor{
 						},
 						Successes: []ResponseDescriptor{
 							{
-								Description: "The blob identified by `digest` is available. The blob content will be present in the body of the request.",
+								Description: "The blob identified by `digest` is available. The blob content will be present in the body of the request. Content is verified against `digest` as it is served, and the response is cut short of its `Content-Length` if it does not match.",
 								StatusCode:  http.StatusOK,
 								Headers: []ParameterDescriptor{
 									{
@@ -906,7 +1175,7 @@ var routeDescriptors = []RouteDescriptor{
 							{
 								Name:        "Range",
 								Type:        "string",
-								Description: "HTTP Range header specifying blob chunk.",
+								Description: "HTTP Range header specifying blob chunk. Multiple comma-separated ranges may be requested, in which case the chunks are returned in a `multipart/byteranges` body, each with its own `Content-Range` header. If the request is redirected to the storage backend, the header must be resent to it.",
 								Format:      "bytes=<start>-<end>",
 							},
 						},
@@ -1127,6 +1396,12 @@ var routeDescriptors = []RouteDescriptor{
 							hostHeader,
 							authHeader,
 							contentLengthZeroHeader,
+							{
+								Name:        "Gitlab-Direct-Upload-Parts",
+								Type:        "header",
+								Format:      "<number of parts>",
+								Description: "Requests a direct upload of the blob data to the storage backend, in the given number of parts. Only honored for trusted clients when direct uploads are enabled.",
+							},
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -1149,6 +1424,7 @@ var routeDescriptors = []RouteDescriptor{
 										Description: "Range header indicating the progress of the upload. When starting an upload, it will return an empty range, since no content has been received.",
 									},
 									dockerUploadUUIDHeader,
+									ociChunkMinLengthHeader,
 								},
 							},
 						},
@@ -1159,6 +1435,7 @@ var routeDescriptors = []RouteDescriptor{
 								ErrorCodes: []errcode.ErrorCode{
 									ErrorCodeDigestInvalid,
 									ErrorCodeNameInvalid,
+									ErrorCodeDirectUploadInvalid,
 								},
 							},
 							unauthorizedResponseDescriptor,
@@ -1174,6 +1451,12 @@ var routeDescriptors = []RouteDescriptor{
 							hostHeader,
 							authHeader,
 							contentLengthZeroHeader,
+							{
+								Name:        "Gitlab-Remote-Mount-Authorization",
+								Type:        "header",
+								Format:      "<scheme> <token>",
+								Description: "Credentials forwarded in the `Authorization` header of the request to the remote registry identified by `from_registry`.",
+							},
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -1191,7 +1474,13 @@ var routeDescriptors = []RouteDescriptor{
 								Type:        "query",
 								Format:      "<repository name>",
 								Regexp:      reference.NameRegexp,
-								Description: `Name of the source repository.`,
+								Description: `Name of the source repository. If omitted, the blob is mounted from any repository containing it that the client has pull access to, if the metadata database is enabled.`,
+							},
+							{
+								Name:        "from_registry",
+								Type:        "query",
+								Format:      "<registry URL>",
+								Description: `Base URL of the remote registry hosting the source repository. Only honored if cross-registry blob mounting is enabled.`,
 							},
 						},
 						Successes: []ResponseDescriptor{
@@ -1269,6 +1558,24 @@ var routeDescriptors = []RouteDescriptor{
 										Format:      "0-<offset>",
 										Description: "Range indicating the current progress of the upload.",
 									},
+									{
+										Name:        "Gitlab-Upload-Bytes-Per-Second",
+										Type:        "integer",
+										Format:      "<rate>",
+										Description: "The average rate at which the upload data was received since the upload started.",
+									},
+									{
+										Name:        "Gitlab-Upload-Seconds-Since-Last-Chunk",
+										Type:        "integer",
+										Format:      "<seconds>",
+										Description: "The time elapsed since the last chunk of the upload was received. Omitted if no data was received yet, or if unknown.",
+									},
+									{
+										Name:        "Gitlab-Upload-Partial-Digest",
+										Type:        "digest",
+										Format:      "<digest>",
+										Description: "The digest of the data received so far. Omitted if unknown.",
+									},
 									contentLengthZeroHeader,
 									dockerUploadUUIDHeader,
 								},
@@ -1318,6 +1625,9 @@ var routeDescriptors = []RouteDescriptor{
 							nameParameterDescriptor,
 							uuidParameterDescriptor,
 						},
+						QueryParameters: []ParameterDescriptor{
+							chunkDigestQueryParameter,
+						},
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
@@ -1387,6 +1697,9 @@ var routeDescriptors = []RouteDescriptor{
 							nameParameterDescriptor,
 							uuidParameterDescriptor,
 						},
+						QueryParameters: []ParameterDescriptor{
+							chunkDigestQueryParameter,
+						},
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
@@ -1457,7 +1770,7 @@ var routeDescriptors = []RouteDescriptor{
 								},
 							},
 							{
-								Description: "The `Content-Range` specification cannot be accepted, either because it does not overlap with the current progress or it is invalid.",
+								Description: "The `Content-Range` specification cannot be accepted, either because it does not overlap with the current progress, it is invalid or the size of the chunk is outside the limits configured for the registry.",
 								StatusCode:  http.StatusRequestedRangeNotSatisfiable,
 							},
 							unauthorizedResponseDescriptor,
@@ -1631,6 +1944,7 @@ var routeDescriptors = []RouteDescriptor{
 					{
 						Name:        "Catalog Fetch",
 						Description: "Request an unabridged list of repositories available.  The implementation may impose a maximum limit and return a partial set with pagination links.",
+						Headers:     []ParameterDescriptor{ifNoneMatchHeader},
 						Successes: []ResponseDescriptor{
 							{
 								Description: "Returns the unabridged list of repositories as a json response.",
@@ -1642,6 +1956,7 @@ var routeDescriptors = []RouteDescriptor{
 										Description: "Length of the JSON response body.",
 										Format:      "<length>",
 									},
+									listEtagHeader,
 								},
 								Body: BodyDescriptor{
 									ContentType: "application/json",
@@ -1653,11 +1968,13 @@ var routeDescriptors = []RouteDescriptor{
 }`,
 								},
 							},
+							listNotModifiedResponseDescriptor,
 						},
 					},
 					{
 						Name:            "Catalog Fetch Paginated",
 						Description:     "Return the specified portion of repositories.",
+						Headers:         []ParameterDescriptor{ifNoneMatchHeader},
 						QueryParameters: paginationParameters,
 						Successes: []ResponseDescriptor{
 							{
@@ -1679,7 +1996,63 @@ var routeDescriptors = []RouteDescriptor{
 										Description: "Length of the JSON response body.",
 										Format:      "<length>",
 									},
+									listEtagHeader,
+									linkHeader,
+									totalCountHeader,
+									totalPagesHeader,
+								},
+							},
+							listNotModifiedResponseDescriptor,
+						},
+					},
+					{
+						Name:            "Catalog Fetch Filtered",
+						Description:     "Return the specified portion of repositories whose path starts with a prefix, that were modified since a given time and/or that have given labels. Pagination parameters are optional and the filters are preserved in the `Link` header.",
+						Headers:         []ParameterDescriptor{ifNoneMatchHeader},
+						QueryParameters: append(append([]ParameterDescriptor{}, paginationParameters...), catalogFilterParameters...),
+						Successes: []ResponseDescriptor{
+							{
+								StatusCode: http.StatusOK,
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format: `{
+	"repositories": [
+		<name>,
+		...
+	]
+}`,
+								},
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON response body.",
+										Format:      "<length>",
+									},
+									listEtagHeader,
 									linkHeader,
+									totalCountHeader,
+									totalPagesHeader,
+									{
+										Name:        "OCI-Filters-Applied",
+										Type:        "string",
+										Description: "Comma-separated list of the filters applied to the list, if any.",
+										Format:      "prefix,modified_since,label",
+									},
+								},
+							},
+							listNotModifiedResponseDescriptor,
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Description: "The `modified_since` or `label` parameters are invalid or the registry does not use the metadata database.",
+								StatusCode:  http.StatusBadRequest,
+								ErrorCodes: []errcode.ErrorCode{
+									ErrorCodeCatalogFilterInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
 								},
 							},
 						},
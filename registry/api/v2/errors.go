@@ -177,7 +177,7 @@ var (
 	ErrorCodeInvalidContentRange = errcode.Register(errGroup, errcode.ErrorDescriptor{
 		Value:          "CONTENT_RANGE_INVALID",
 		Message:        "invalid content range",
-		Description:    "If a layer chunk is uploaded with the range out of order, this error will be returned",
+		Description:    "If a layer chunk is uploaded with the range out of order, or its size is outside the limits configured for the registry, this error will be returned",
 		HTTPStatusCode: http.StatusRequestedRangeNotSatisfiable,
         })
 
@@ -197,4 +197,84 @@ var (
 		to return) is not an integer, "n" is negative or "n" is bigger than the maximum allowed.`,
 		HTTPStatusCode: http.StatusBadRequest,
 	})
+
+	// ErrorCodeTagImmutable is returned when attempting to overwrite a tag that matches an immutability rule.
+	ErrorCodeTagImmutable = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "TAG_IMMUTABLE",
+		Message: "tag is immutable",
+		Description: `Returned when a manifest push attempts to overwrite an existing tag
+		that matches one of the immutable tag patterns configured for the repository.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	// ErrorCodeTagProtected is returned when attempting to push or delete a tag that matches one of the protected tag
+	// patterns of the auth token.
+	ErrorCodeTagProtected = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "TAG_PROTECTED",
+		Message: "tag is protected",
+		Description: `Returned when a manifest push or a tag or manifest delete targets a tag
+		that matches one of the protected tag patterns that the auth token was issued with.`,
+		HTTPStatusCode: http.StatusForbidden,
+	})
+
+	// ErrorCodeTagPreconditionFailed is returned when the tag targeted by a request with an If-Match header does not
+	// point to any of the expected manifests.
+	ErrorCodeTagPreconditionFailed = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "TAG_PRECONDITION_FAILED",
+		Message: "tag does not point to the expected manifest",
+		Description: `Returned when a manifest push or tag delete includes an If-Match header
+		and the tag does not exist or does not point to any of the manifest digests
+		listed in the header, usually because the tag was changed concurrently.`,
+		HTTPStatusCode: http.StatusPreconditionFailed,
+	})
+
+	// ErrorCodeManifestSignatureRequired is returned when pulling a manifest without a valid signature from a
+	// repository that matches a signature policy.
+	ErrorCodeManifestSignatureRequired = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "MANIFEST_SIGNATURE_REQUIRED",
+		Message: "manifest does not have a valid signature",
+		Description: `Returned when a manifest pull is denied because the repository
+		matches a signature policy and the manifest is not signed, or none of its
+		signatures is valid and made by a trusted signer.`,
+		HTTPStatusCode: http.StatusForbidden,
+	})
+
+	// ErrorCodeCatalogFilterInvalid is returned when the `prefix`, `modified_since` or `label` catalog parameters are not
+	// valid.
+	ErrorCodeCatalogFilterInvalid = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "CATALOG_FILTER_INVALID",
+		Message: "invalid catalog filter",
+		Description: `Returned when the "modified_since" parameter is not a valid RFC 3339
+		timestamp, a "label" parameter is not a valid label condition, or when catalog
+		filters are used without the metadata database.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// ErrorCodePlatformInvalid is returned when the `platform` manifest parameter is not valid.
+	ErrorCodePlatformInvalid = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "PLATFORM_INVALID",
+		Message: "invalid platform",
+		Description: `Returned when the "platform" parameter of a manifest request is not
+		in the "os/architecture[/variant]" format.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// ErrorCodeDirectUploadInvalid is returned when a direct blob upload request is not valid.
+	ErrorCodeDirectUploadInvalid = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "DIRECT_UPLOAD_INVALID",
+		Message: "invalid direct upload",
+		Description: `Returned when the "Gitlab-Direct-Upload-Parts" header of a blob upload
+		request is not a valid number of parts, or when data is sent through the registry
+		for a blob upload started as a direct upload.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// ErrorCodeRepositoryReadOnly is returned when attempting to push to or delete from a read-only repository.
+	ErrorCodeRepositoryReadOnly = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "REPOSITORY_READ_ONLY",
+		Message: "repository is read-only",
+		Description: `Returned when attempting to push to or delete from a repository that
+		was made read-only through its settings. Pulls are not affected.`,
+		HTTPStatusCode: http.StatusMethodNotAllowed,
+	})
 )
@@ -13,6 +13,7 @@ const (
 	RouteNameBlobUpload      = "blob-upload"
 	RouteNameBlobUploadChunk = "blob-upload-chunk"
 	RouteNameCatalog         = "catalog"
+	RouteNameReferrers       = "referrers"
 
 	RoutePathBase            = "/v2/"
 	RoutePathManifest        = "/v2/{name}/manifests/{reference}"
@@ -22,6 +23,7 @@ const (
 	RoutePathBlobUpload      = "/v2/{name}/blobs/uploads/"
 	RoutePathBlobUploadChunk = "/v2/{name}/blobs/uploads/{uuid}"
 	RoutePathCatalog         = "/v2/_catalog"
+	RoutePathReferrers       = "/v2/{name}/referrers/{digest}"
 )
 
 func RoutePath(routeName string) string {
@@ -42,6 +44,8 @@ func RoutePath(routeName string) string {
 		return RoutePathBlobUploadChunk
 	case RouteNameCatalog:
 		return RoutePathCatalog
+	case RouteNameReferrers:
+		return RoutePathReferrers
 	default:
 		return ""
 	}
@@ -0,0 +1,205 @@
+// Package audit provides a structured audit log for write operations, such as pushes, deletes and garbage collection
+// removals. Records are chained together with a hash of each record and the one that preceded it, so that
+// modifications, deletions and insertions can be detected by verifying the trail with Verify.
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/uuid"
+	"github.com/opencontainers/go-digest"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionPush          = "push"
+	ActionMount         = "mount"
+	ActionDelete        = "delete"
+	ActionGCDelete      = "gc_delete"
+	ActionCleanupDelete = "cleanup_delete"
+	ActionPurgeDelete   = "purge_delete"
+)
+
+// Artifacts that actions apply to.
+const (
+	ArtifactBlob     = "blob"
+	ArtifactManifest = "manifest"
+	ArtifactTag      = "tag"
+)
+
+// Actor identifies who performed an action.
+type Actor struct {
+	// Name is the user name, as found in the auth token or basic auth credentials.
+	Name string `json:"name,omitempty"`
+	// Type is the user type, as found in the auth token.
+	Type string `json:"type,omitempty"`
+	// Addr is the IP address of the client.
+	Addr string `json:"addr,omitempty"`
+}
+
+// GCActor is the actor of actions performed by online garbage collection.
+var GCActor = Actor{Name: "registry.gc", Type: "system"}
+
+// CleanupActor is the actor of actions performed by repository cleanup policies.
+var CleanupActor = Actor{Name: "registry.cleanup", Type: "system"}
+
+// PurgeActor is the actor of actions performed by repository purges.
+var PurgeActor = Actor{Name: "registry.purge", Type: "system"}
+
+// Record is a single entry in the audit log.
+type Record struct {
+	// Chain identifies the Logger that produced the record. Each registry instance starts a new chain.
+	Chain string `json:"chain"`
+	// Sequence is the position of the record in the chain, starting at 1.
+	Sequence uint64 `json:"sequence"`
+	// Time is the time at which the record was created, in UTC.
+	Time time.Time `json:"time"`
+	// Action is the performed action, one of the Action* constants.
+	Action string `json:"action"`
+	// Artifact is the type of artifact the action applies to, one of the Artifact* constants.
+	Artifact string `json:"artifact"`
+	// Actor identifies who performed the action.
+	Actor Actor `json:"actor"`
+	// Repository is the path of the target repository.
+	Repository string `json:"repository,omitempty"`
+	// RepositoryID is the database ID of the target repository, used when the path is unknown.
+	RepositoryID int64 `json:"repository_id,omitempty"`
+	// Digest is the digest of the target blob or manifest.
+	Digest digest.Digest `json:"digest,omitempty"`
+	// Tag is the name of the target tag.
+	Tag string `json:"tag,omitempty"`
+	// MediaType is the media type of the target blob or manifest.
+	MediaType string `json:"media_type,omitempty"`
+	// CorrelationID is the correlation ID of the request or background job that performed the action.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// PreviousHash is the hash of the previous record in the chain, or empty for the first record.
+	PreviousHash string `json:"previous_hash"`
+	// Hash is the hex encoded SHA-256 (or HMAC-SHA256, if a key was provided) of the JSON encoded record, excluding
+	// the hash itself.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Logger writes records to a Sink. A nil Logger discards all records. This type is safe for concurrent use.
+type Logger struct {
+	sink  Sink
+	key   []byte
+	chain string
+
+	mu       sync.Mutex
+	sequence uint64
+	prevHash string
+}
+
+// LoggerOption provides functional options for NewLogger.
+type LoggerOption func(*Logger)
+
+// WithHMACKey sets a key used to compute record hashes with HMAC-SHA256 instead of SHA-256. Without a key, anyone
+// with write access to the trail may recompute the hashes of all records following a modified one.
+func WithHMACKey(key []byte) LoggerOption {
+	return func(l *Logger) {
+		l.key = key
+	}
+}
+
+// NewLogger creates a new Logger that writes records to sink, starting a new chain.
+func NewLogger(sink Sink, opts ...LoggerOption) *Logger {
+	l := &Logger{
+		sink:  sink,
+		chain: uuid.Generate().String(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Log completes r with the chain details, the current time and the correlation ID found in ctx (unless already
+// set) and writes it to the sink. Write failures are logged, as the recorded action has already taken place.
+func (l *Logger) Log(ctx context.Context, r Record) {
+	if l == nil {
+		return
+	}
+
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Time = r.Time.UTC()
+	if r.CorrelationID == "" {
+		r.CorrelationID = correlation.ExtractFromContext(ctx)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Chain = l.chain
+	r.Sequence = l.sequence + 1
+	r.PreviousHash = l.prevHash
+
+	b, err := seal(&r, l.key)
+	if err == nil {
+		err = l.sink.Write(b)
+	}
+	if err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).WithFields(log.Fields{
+			"action":     r.Action,
+			"artifact":   r.Artifact,
+			"repository": r.Repository,
+			"digest":     r.Digest,
+			"tag":        r.Tag,
+		}).Error("failed to write audit record")
+		return
+	}
+
+	// only advance the chain once the record was written, so that failures do not break it
+	l.sequence = r.Sequence
+	l.prevHash = r.Hash
+}
+
+// Close closes the underlying sink.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.sink.Close()
+}
+
+// seal computes and sets the hash of r, returning its JSON encoding.
+func seal(r *Record, key []byte) ([]byte, error) {
+	r.Hash = ""
+	h, err := hash(r, key)
+	if err != nil {
+		return nil, err
+	}
+	r.Hash = h
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("encoding audit record: %w", err)
+	}
+	return b, nil
+}
+
+func hash(r *Record, key []byte) (string, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("encoding audit record: %w", err)
+	}
+
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(b)
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
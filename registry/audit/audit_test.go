@@ -0,0 +1,182 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/registry/audit"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+// bufferSink is an audit.Sink that writes records to a buffer, one per line, optionally failing.
+type bufferSink struct {
+	bytes.Buffer
+	err error
+}
+
+func (s *bufferSink) Write(record []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.Buffer.Write(record)
+	return s.WriteByte('\n')
+}
+
+func (*bufferSink) Close() error {
+	return nil
+}
+
+func decodeRecords(t *testing.T, b []byte) []audit.Record {
+	t.Helper()
+
+	var rr []audit.Record
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var r audit.Record
+		require.NoError(t, dec.Decode(&r))
+		rr = append(rr, r)
+	}
+	return rr
+}
+
+func TestLogger_Log(t *testing.T) {
+	sink := &bufferSink{}
+	l := audit.NewLogger(sink)
+	ctx := correlation.ContextWithCorrelation(context.Background(), "foo")
+
+	actor := audit.Actor{Name: "john", Type: "personal_access_token", Addr: "127.0.0.1"}
+	dgst := digest.FromString("bar")
+	l.Log(ctx, audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactManifest, Actor: actor, Repository: "a/b", Digest: dgst})
+	l.Log(ctx, audit.Record{Action: audit.ActionDelete, Artifact: audit.ArtifactTag, Actor: actor, Repository: "a/b", Tag: "latest"})
+
+	rr := decodeRecords(t, sink.Bytes())
+	require.Len(t, rr, 2)
+
+	require.NotEmpty(t, rr[0].Chain)
+	require.Equal(t, rr[0].Chain, rr[1].Chain)
+	require.Equal(t, uint64(1), rr[0].Sequence)
+	require.Equal(t, uint64(2), rr[1].Sequence)
+	require.Empty(t, rr[0].PreviousHash)
+	require.NotEmpty(t, rr[0].Hash)
+	require.Equal(t, rr[0].Hash, rr[1].PreviousHash)
+
+	require.Equal(t, audit.ActionPush, rr[0].Action)
+	require.Equal(t, audit.ArtifactManifest, rr[0].Artifact)
+	require.Equal(t, actor, rr[0].Actor)
+	require.Equal(t, "a/b", rr[0].Repository)
+	require.Equal(t, dgst, rr[0].Digest)
+	require.Equal(t, "foo", rr[0].CorrelationID)
+	require.False(t, rr[0].Time.IsZero())
+	require.Equal(t, "latest", rr[1].Tag)
+
+	require.NoError(t, audit.Verify(bytes.NewReader(sink.Bytes()), nil))
+}
+
+func TestLogger_Log_Nil(t *testing.T) {
+	var l *audit.Logger
+
+	require.NotPanics(t, func() {
+		l.Log(context.Background(), audit.Record{Action: audit.ActionPush})
+	})
+	require.NoError(t, l.Close())
+}
+
+func TestLogger_Log_WriteError(t *testing.T) {
+	sink := &bufferSink{err: errors.New("foo")}
+	l := audit.NewLogger(sink)
+	ctx := context.Background()
+
+	// failed writes must not break the chain
+	l.Log(ctx, audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactBlob})
+	sink.err = nil
+	l.Log(ctx, audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactBlob})
+
+	rr := decodeRecords(t, sink.Bytes())
+	require.Len(t, rr, 1)
+	require.Equal(t, uint64(1), rr[0].Sequence)
+	require.Empty(t, rr[0].PreviousHash)
+}
+
+func TestVerify(t *testing.T) {
+	key := []byte("secret")
+
+	// interleave the records of two chains, as written by two registry instances
+	sink := &bufferSink{}
+	l1 := audit.NewLogger(sink, audit.WithHMACKey(key))
+	l2 := audit.NewLogger(sink, audit.WithHMACKey(key))
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		l1.Log(ctx, audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactBlob, Repository: "a"})
+		l2.Log(ctx, audit.Record{Action: audit.ActionDelete, Artifact: audit.ArtifactBlob, Repository: "b"})
+	}
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, lines, 6)
+
+	join := func(lines ...string) string {
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name    string
+		trail   string
+		key     []byte
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			trail: join(lines...),
+			key:   key,
+		},
+		{
+			name:  "rotated",
+			trail: join(lines[2:]...),
+			key:   key,
+		},
+		{
+			name:    "wrong key",
+			trail:   join(lines...),
+			wantErr: "line 1: record hash mismatch",
+		},
+		{
+			name:    "modified",
+			trail:   join(append(lines[:3:3], append([]string{strings.Replace(lines[3], `"b"`, `"c"`, 1)}, lines[4:]...)...)...),
+			key:     key,
+			wantErr: "line 4: record hash mismatch",
+		},
+		{
+			name:    "removed",
+			trail:   join(append(lines[:2:2], lines[3:]...)...),
+			key:     key,
+			wantErr: "line 4: record does not follow sequence 1",
+		},
+		{
+			name:    "reordered",
+			trail:   join(lines[2], lines[0]),
+			key:     key,
+			wantErr: "line 2: record does not follow sequence 2",
+		},
+		{
+			name:    "invalid",
+			trail:   join(lines[0], "foo"),
+			key:     key,
+			wantErr: "line 2: decoding audit record",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := audit.Verify(strings.NewReader(test.trail), test.key)
+			if test.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
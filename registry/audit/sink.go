@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/syslog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink is the destination of audit records.
+type Sink interface {
+	// Write writes a single JSON encoded record.
+	Write(record []byte) error
+	// Close releases any resources held by the sink.
+	Close() error
+}
+
+// FileSink appends records to a file, one per line.
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileSink opens (or creates) the file at path for appending.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log file: %w", err)
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Write implements Sink.
+func (s *FileSink) Write(record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// write each record with a single call, so that concurrent writers appending to the same file do not interleave
+	if _, err := s.f.Write(append(record, '\n')); err != nil {
+		return fmt.Errorf("writing audit record to file: %w", err)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+// SyslogSink writes records to a syslog daemon, with the informational severity and the auth private facility.
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog daemon at addr over network (e.g. "udp" or "tcp"). If network is empty, the
+// local syslog daemon is used. Records are tagged with tag.
+func NewSyslogSink(network, addr, tag string) (*SyslogSink, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTHPRIV, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+// Write implements Sink.
+func (s *SyslogSink) Write(record []byte) error {
+	if _, err := s.w.Write(record); err != nil {
+		return fmt.Errorf("writing audit record to syslog: %w", err)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
+
+// HTTPSink sends each record in the body of a POST request to a remote endpoint. Records are sent synchronously, so
+// the endpoint latency adds up to that of every write operation, up to the configured timeout.
+type HTTPSink struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+// NewHTTPSink creates a new HTTPSink. The provided headers are added to every request.
+func NewHTTPSink(url string, timeout time.Duration, headers http.Header) *HTTPSink {
+	return &HTTPSink{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Write implements Sink.
+func (s *HTTPSink) Write(record []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.url, bytes.NewReader(record))
+	if err != nil {
+		return fmt.Errorf("creating audit record request: %w", err)
+	}
+	for k, vv := range s.headers {
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending audit record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sending audit record: unexpected response status %q", resp.Status)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *HTTPSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
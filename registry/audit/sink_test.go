@@ -0,0 +1,108 @@
+package audit_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/audit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, os.WriteFile(path, []byte("{}\n"), 0o600))
+
+	// records are appended to existing files
+	s, err := audit.NewFileSink(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Write([]byte(`{"a":1}`)))
+	require.NoError(t, s.Write([]byte(`{"b":2}`)))
+	require.NoError(t, s.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "{}\n{\"a\":1}\n{\"b\":2}\n", string(b))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestFileSink_Error(t *testing.T) {
+	_, err := audit.NewFileSink(filepath.Join(t.TempDir(), "foo", "audit.log"))
+	require.Error(t, err)
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s, err := audit.NewSyslogSink("udp", conn.LocalAddr().String(), "registry-audit")
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Write([]byte(`{"a":1}`)))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	msg := string(buf[:n])
+	// priority is LOG_AUTHPRIV (10) * 8 + LOG_INFO (6)
+	require.True(t, strings.HasPrefix(msg, "<86>"), msg)
+	require.Contains(t, msg, "registry-audit")
+	require.True(t, strings.HasSuffix(strings.TrimSpace(msg), `{"a":1}`), msg)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var gotBody, gotContentType, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		gotBody = string(b)
+		gotContentType = r.Header.Get("Content-Type")
+		gotAuth = r.Header.Get("Authorization")
+
+		if gotBody == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := audit.NewHTTPSink(srv.URL, time.Second, http.Header{"Authorization": []string{"Bearer foo"}})
+	defer s.Close()
+
+	require.NoError(t, s.Write([]byte(`{"a":1}`)))
+	require.Equal(t, `{"a":1}`, gotBody)
+	require.Equal(t, "application/json", gotContentType)
+	require.Equal(t, "Bearer foo", gotAuth)
+
+	err := s.Write([]byte("fail"))
+	require.ErrorContains(t, err, "unexpected response status")
+}
+
+func TestHTTPSink_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	s := audit.NewHTTPSink(srv.URL, 10*time.Millisecond, nil)
+	defer s.Close()
+
+	require.Error(t, s.Write([]byte(`{"a":1}`)))
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Verify reads an audit trail of JSON encoded records, one per line, and checks the integrity of every chain found
+// in it. Records of different chains may be interleaved, as happens when multiple registry instances write to the
+// same sink. The same key used to write the records must be provided, or nil if none was. An error is returned for
+// the first record whose hash does not match its contents or that does not follow the previous record in its chain.
+// Note that the removal of the most recent records of a chain can not be detected.
+func Verify(rd io.Reader, key []byte) error {
+	type link struct {
+		sequence uint64
+		hash     string
+	}
+	chains := make(map[string]link)
+
+	s := bufio.NewScanner(rd)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+
+		var r Record
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return fmt.Errorf("line %d: decoding audit record: %w", line, err)
+		}
+
+		want := r.Hash
+		r.Hash = ""
+		got, err := hash(&r, key)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if got != want {
+			return fmt.Errorf("line %d: record hash mismatch", line)
+		}
+
+		prev, ok := chains[r.Chain]
+		if !ok {
+			// the trail may have been rotated, in which case the first record seen is not the first of the chain
+			prev = link{sequence: r.Sequence - 1, hash: r.PreviousHash}
+		}
+		if r.Sequence != prev.sequence+1 || r.PreviousHash != prev.hash {
+			return fmt.Errorf("line %d: record does not follow sequence %d of chain %q", line, prev.sequence, r.Chain)
+		}
+		chains[r.Chain] = link{sequence: r.Sequence, hash: want}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("reading audit trail: %w", err)
+	}
+
+	return nil
+}
@@ -36,6 +36,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 const (
@@ -78,6 +79,33 @@ type Resource struct {
 	ProjectPath string
 }
 
+// WildcardSuffix is the suffix of wildcard resource names, which cover all resources nested under the name prefix, at
+// any depth. For example, "group/*" covers "group/project" and "group/subgroup/project", but not "group".
+const WildcardSuffix = "/*"
+
+// IsWildcard reports whether the resource name is a wildcard.
+func (r Resource) IsWildcard() bool {
+	return len(r.Name) > len(WildcardSuffix) && strings.HasSuffix(r.Name, WildcardSuffix)
+}
+
+// Covers reports whether the resource name is the given name, or a wildcard that covers it.
+func (r Resource) Covers(name string) bool {
+	if r.Name == name {
+		return true
+	}
+	return r.IsWildcard() && strings.HasPrefix(name, strings.TrimSuffix(r.Name, "*"))
+}
+
+// WildcardNames returns the wildcard resource names that cover name, from the most to the least specific. For example,
+// "a/b/c" is covered by "a/b/*" and "a/*".
+func WildcardNames(name string) []string {
+	var names []string
+	for i := strings.LastIndex(name, "/"); i > 0; i = strings.LastIndex(name[:i], "/") {
+		names = append(names, name[:i]+WildcardSuffix)
+	}
+	return names
+}
+
 // Access describes a specific action that is
 // requested or allowed for a given resource.
 type Access struct {
@@ -190,6 +218,46 @@ func AuthorizedResources(ctx context.Context) []Resource {
 	return nil
 }
 
+// WithGrantedAccess returns a context with all the access granted to the request by the access controller, which may
+// go beyond the access that the request was authorized for.
+func WithGrantedAccess(ctx context.Context, access []Access) context.Context {
+	return context.WithValue(ctx, grantedAccessKey{}, access)
+}
+
+type grantedAccessKey struct{}
+
+// GrantedAccess returns all the access granted to this request, if known.
+func GrantedAccess(ctx context.Context) []Access {
+	if access, ok := ctx.Value(grantedAccessKey{}).([]Access); ok {
+		return access
+	}
+
+	return nil
+}
+
+// WithProtectedTagPatterns returns a context with the protected tag patterns granted to the request by the access
+// controller, keyed by repository name. Repository names may be wildcards.
+func WithProtectedTagPatterns(ctx context.Context, patterns map[string][]string) context.Context {
+	return context.WithValue(ctx, protectedTagPatternsKey{}, patterns)
+}
+
+type protectedTagPatternsKey struct{}
+
+// ProtectedTagPatterns returns the patterns of the protected tags of the named repository, including those granted
+// for wildcard names covering it, if any.
+func ProtectedTagPatterns(ctx context.Context, name string) []string {
+	patterns, ok := ctx.Value(protectedTagPatternsKey{}).(map[string][]string)
+	if !ok {
+		return nil
+	}
+
+	var res []string
+	for _, n := range append([]string{name}, WildcardNames(name)...) {
+		res = append(res, patterns[n]...)
+	}
+	return res
+}
+
 // InitFunc is the type of an AccessController factory function and is used
 // to register the constructor for different AccesController backends.
 type InitFunc func(options map[string]interface{}) (AccessController, error)
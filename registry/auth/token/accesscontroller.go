@@ -11,8 +11,10 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/libtrust"
 )
@@ -45,10 +47,24 @@ func newAccessSet(accessItems ...auth.Access) accessSet {
 }
 
 // contains returns whether or not the given access is in this accessSet.
+// Access to a repository is also granted by repository wildcards that cover
+// it, such as "group/*" for "group/project".
 func (s accessSet) contains(access auth.Access) bool {
 	actionSet, ok := s[access.Resource]
-	if ok {
-		return actionSet.contains(access.Action)
+	if ok && actionSet.contains(access.Action) {
+		return true
+	}
+
+	if access.Type != "repository" {
+		return false
+	}
+
+	for _, name := range auth.WildcardNames(access.Name) {
+		resource := access.Resource
+		resource.Name = name
+		if actionSet, ok := s[resource]; ok && actionSet.contains(access.Action) {
+			return true
+		}
 	}
 
 	return false
@@ -68,6 +84,13 @@ func (s accessSet) scopeParam() string {
 	return strings.Join(scopes, " ")
 }
 
+const (
+	// anonymousUserType is the user type of requests authorized without a token.
+	anonymousUserType = "anonymous"
+	// maxClockSkew is the maximum clock skew that can be configured for the NBF and EXP claim checks.
+	maxClockSkew = 10 * time.Minute
+)
+
 // Errors used and exported by this package.
 var (
 	ErrInsufficientScope = errors.New("insufficient scope")
@@ -129,10 +152,81 @@ func (ac authChallenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
 type accessController struct {
 	realm        string
 	autoRedirect bool
-	issuer       string
 	service      string
-	rootCerts    *x509.CertPool
-	trustedKeys  map[string]libtrust.PublicKey
+	issuers      map[string]*trustedIssuer
+	// anonymousPull holds the repositories that can be pulled without a token.
+	anonymousPull []auth.Resource
+	// leeway is the configured clock skew for the NBF and EXP claim checks, if any.
+	leeway *time.Duration
+	// replayCache records the IDs of the tokens from one-time issuers, if any.
+	replayCache ReplayCache
+}
+
+// allowsAnonymous returns whether all the given access items can be granted without a token, which is only the case
+// for pulls of repositories covered by the anonymous pull allowlist.
+func (ac *accessController) allowsAnonymous(accessItems []auth.Access) bool {
+	if len(ac.anonymousPull) == 0 || len(accessItems) == 0 {
+		return false
+	}
+
+	for _, access := range accessItems {
+		if access.Type != "repository" || access.Action != "pull" {
+			return false
+		}
+
+		var covered bool
+		for _, r := range ac.anonymousPull {
+			if r.Covers(access.Name) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+
+	return true
+}
+
+// trustedIssuer holds the verification options of tokens from a trusted issuer.
+type trustedIssuer struct {
+	name        string
+	audiences   []string
+	rootCerts   *x509.CertPool
+	trustedKeys map[string]libtrust.PublicKey
+	jwks        *jwks
+	// oneTime is set if each token from this issuer can only be used once.
+	oneTime bool
+}
+
+// verifyOptions returns the options to verify a token from this issuer, signed by the key with the given ID, if any.
+func (ti *trustedIssuer) verifyOptions(ctx context.Context, keyID string) (VerifyOptions, error) {
+	opts := VerifyOptions{
+		TrustedIssuers:    []string{ti.name},
+		AcceptedAudiences: ti.audiences,
+		Roots:             ti.rootCerts,
+		TrustedKeys:       ti.trustedKeys,
+	}
+	if opts.Roots == nil {
+		opts.Roots = x509.NewCertPool()
+	}
+
+	if ti.jwks != nil {
+		keys, err := ti.jwks.trustedKeys(ctx, keyID)
+		if err != nil {
+			return opts, err
+		}
+		opts.TrustedKeys = make(map[string]libtrust.PublicKey, len(ti.trustedKeys)+len(keys))
+		for id, k := range ti.trustedKeys {
+			opts.TrustedKeys[id] = k
+		}
+		for id, k := range keys {
+			opts.TrustedKeys[id] = k
+		}
+	}
+
+	return opts, nil
 }
 
 // tokenAccessOptions is a convenience type for handling
@@ -143,6 +237,21 @@ type tokenAccessOptions struct {
 	issuer         string
 	service        string
 	rootCertBundle string
+	issuers        []issuerOptions
+	anonymousPull  []string
+	clockSkew      *time.Duration
+	oneTime        bool
+	replayCache    ReplayCache
+}
+
+// issuerOptions are the options of an additional trusted issuer, listed in the issuers option.
+type issuerOptions struct {
+	issuer         string
+	audiences      []string
+	rootCertBundle string
+	jwks           string
+	jwksRefresh    time.Duration
+	oneTime        bool
 }
 
 // checkOptions gathers the necessary options
@@ -150,11 +259,20 @@ type tokenAccessOptions struct {
 func checkOptions(options map[string]interface{}) (tokenAccessOptions, error) {
 	var opts tokenAccessOptions
 
+	if v, ok := options["issuers"]; ok && v != nil {
+		issuers, err := checkIssuersOption(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.issuers = issuers
+	}
+
+	// issuer and rootcertbundle are only optional if additional issuers are listed
 	keys := []string{"realm", "issuer", "service", "rootcertbundle"}
 	vals := make([]string, 0, len(keys))
 	for _, key := range keys {
 		val, ok := options[key].(string)
-		if !ok {
+		if !ok && (len(opts.issuers) == 0 || (key != "issuer" && key != "rootcertbundle")) {
 			return opts, fmt.Errorf("token auth requires a valid option string: %q", key)
 		}
 		vals = append(vals, val)
@@ -162,6 +280,10 @@ func checkOptions(options map[string]interface{}) (tokenAccessOptions, error) {
 
 	opts.realm, opts.issuer, opts.service, opts.rootCertBundle = vals[0], vals[1], vals[2], vals[3]
 
+	if (opts.issuer == "") != (opts.rootCertBundle == "") {
+		return opts, errors.New("token auth requires both issuer and rootcertbundle options, or neither of them")
+	}
+
 	autoRedirectVal, ok := options["autoredirect"]
 	if ok {
 		autoRedirect, ok := autoRedirectVal.(bool)
@@ -171,25 +293,163 @@ func checkOptions(options map[string]interface{}) (tokenAccessOptions, error) {
 		opts.autoRedirect = autoRedirect
 	}
 
+	if v, ok := options["anonymouspull"]; ok && v != nil {
+		patterns, err := checkAnonymousPullOption(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.anonymousPull = patterns
+	}
+
+	if v, ok := options["clockskew"]; ok && v != nil {
+		s, ok := v.(string)
+		if !ok {
+			return opts, errors.New("token auth requires a valid option duration: clockskew")
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 || d > maxClockSkew {
+			return opts, fmt.Errorf("token auth requires a valid option duration between 0s and %s: clockskew", maxClockSkew)
+		}
+		opts.clockSkew = &d
+	}
+
+	if v, ok := options["onetime"]; ok {
+		oneTime, ok := v.(bool)
+		if !ok {
+			return opts, errors.New("token auth requires a valid option bool: onetime")
+		}
+		opts.oneTime = oneTime
+	}
+
+	if v, ok := options[ReplayCacheOption]; ok && v != nil {
+		rc, ok := v.(ReplayCache)
+		if !ok {
+			return opts, fmt.Errorf("token auth option %q can not be configured", ReplayCacheOption)
+		}
+		opts.replayCache = rc
+	}
+
 	return opts, nil
 }
 
-// newAccessController creates an accessController using the given options.
-func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
-	config, err := checkOptions(options)
-	if err != nil {
-		return nil, err
+// checkAnonymousPullOption parses the list of repository patterns that can be pulled without a token. Each pattern is
+// either a repository name, or a repository path followed by auth.WildcardSuffix.
+func checkAnonymousPullOption(v interface{}) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("token auth requires a valid option list: anonymouspull")
 	}
 
-	fp, err := os.Open(config.rootCertBundle)
+	patterns := make([]string, 0, len(list))
+	for i, item := range list {
+		pattern, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("token auth anonymouspull[%d]: invalid pattern %v", i, item)
+		}
+		if _, err := reference.WithName(strings.TrimSuffix(pattern, auth.WildcardSuffix)); err != nil {
+			return nil, fmt.Errorf("token auth anonymouspull[%d]: invalid pattern %q: %w", i, pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+// checkIssuersOption parses the list of additional trusted issuers.
+func checkIssuersOption(v interface{}) ([]issuerOptions, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("token auth requires a valid option list: issuers")
+	}
+
+	issuers := make([]issuerOptions, 0, len(list))
+	for i, item := range list {
+		params, err := stringMap(item)
+		if err != nil {
+			return nil, fmt.Errorf("token auth issuers[%d]: %w", i, err)
+		}
+
+		var opts issuerOptions
+		for key, val := range params {
+			var ok bool
+			switch key {
+			case "issuer":
+				opts.issuer, ok = val.(string)
+			case "rootcertbundle":
+				opts.rootCertBundle, ok = val.(string)
+			case "jwks":
+				opts.jwks, ok = val.(string)
+			case "jwksrefresh":
+				var s string
+				if s, ok = val.(string); ok {
+					opts.jwksRefresh, err = time.ParseDuration(s)
+					ok = err == nil && opts.jwksRefresh >= 0
+				}
+			case "onetime":
+				opts.oneTime, ok = val.(bool)
+			case "audiences":
+				var auds []interface{}
+				if auds, ok = val.([]interface{}); ok {
+					for _, a := range auds {
+						var aud string
+						if aud, ok = a.(string); !ok {
+							break
+						}
+						opts.audiences = append(opts.audiences, aud)
+					}
+				}
+			default:
+				return nil, fmt.Errorf("token auth issuers[%d]: unknown option %q", i, key)
+			}
+			if !ok {
+				return nil, fmt.Errorf("token auth issuers[%d]: invalid option %q", i, key)
+			}
+		}
+
+		if opts.issuer == "" {
+			return nil, fmt.Errorf("token auth issuers[%d]: issuer is required", i)
+		}
+		if opts.rootCertBundle == "" && opts.jwks == "" {
+			return nil, fmt.Errorf("token auth issuers[%d]: at least one of rootcertbundle or jwks is required", i)
+		}
+		issuers = append(issuers, opts)
+	}
+
+	return issuers, nil
+}
+
+// stringMap converts a map parsed from the configuration file to a map with string keys.
+func stringMap(v interface{}) (map[string]interface{}, error) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		sm := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid key %v", k)
+			}
+			sm[key] = val
+		}
+		return sm, nil
+	default:
+		return nil, fmt.Errorf("invalid value %v", v)
+	}
+}
+
+// loadRootCertBundle reads the certificates in the given bundle file, returning them as a pool along with their public
+// keys, indexed by key ID.
+func loadRootCertBundle(path string) (*x509.CertPool, map[string]libtrust.PublicKey, error) {
+	fp, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to open token auth root certificate bundle file %q: %s", config.rootCertBundle, err)
+		return nil, nil, fmt.Errorf("unable to open token auth root certificate bundle file %q: %s", path, err)
 	}
 	defer fp.Close()
 
 	rawCertBundle, err := io.ReadAll(fp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read token auth root certificate bundle file %q: %s", config.rootCertBundle, err)
+		return nil, nil, fmt.Errorf("unable to read token auth root certificate bundle file %q: %s", path, err)
 	}
 
 	var rootCerts []*x509.Certificate
@@ -198,7 +458,7 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		if pemBlock.Type == "CERTIFICATE" {
 			cert, err := x509.ParseCertificate(pemBlock.Bytes)
 			if err != nil {
-				return nil, fmt.Errorf("unable to parse token auth root certificate: %s", err)
+				return nil, nil, fmt.Errorf("unable to parse token auth root certificate: %s", err)
 			}
 
 			rootCerts = append(rootCerts, cert)
@@ -208,7 +468,7 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 	}
 
 	if len(rootCerts) == 0 {
-		return nil, errors.New("token auth requires at least one token signing root certificate")
+		return nil, nil, errors.New("token auth requires at least one token signing root certificate")
 	}
 
 	rootPool := x509.NewCertPool()
@@ -217,18 +477,63 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		rootPool.AddCert(rootCert)
 		pubKey, err := libtrust.FromCryptoPublicKey(crypto.PublicKey(rootCert.PublicKey))
 		if err != nil {
-			return nil, fmt.Errorf("unable to get public key from token auth root certificate: %s", err)
+			return nil, nil, fmt.Errorf("unable to get public key from token auth root certificate: %s", err)
 		}
 		trustedKeys[pubKey.KeyID()] = pubKey
 	}
 
+	return rootPool, trustedKeys, nil
+}
+
+// newAccessController creates an accessController using the given options.
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	config, err := checkOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := make(map[string]*trustedIssuer, len(config.issuers)+1)
+	if config.issuer != "" {
+		config.issuers = append([]issuerOptions{{issuer: config.issuer, rootCertBundle: config.rootCertBundle, oneTime: config.oneTime}}, config.issuers...)
+	}
+
+	for _, opts := range config.issuers {
+		if _, ok := issuers[opts.issuer]; ok {
+			return nil, fmt.Errorf("token auth issuer %q is configured more than once", opts.issuer)
+		}
+
+		if opts.oneTime && config.replayCache == nil {
+			return nil, fmt.Errorf("token auth issuer %q is one-time, which requires the redis cache to be enabled", opts.issuer)
+		}
+
+		ti := &trustedIssuer{name: opts.issuer, audiences: opts.audiences, oneTime: opts.oneTime}
+		if len(ti.audiences) == 0 {
+			ti.audiences = []string{config.service}
+		}
+		if opts.rootCertBundle != "" {
+			if ti.rootCerts, ti.trustedKeys, err = loadRootCertBundle(opts.rootCertBundle); err != nil {
+				return nil, err
+			}
+		}
+		if opts.jwks != "" {
+			ti.jwks = newJWKS(opts.jwks, opts.jwksRefresh)
+		}
+		issuers[opts.issuer] = ti
+	}
+
+	anonymousPull := make([]auth.Resource, 0, len(config.anonymousPull))
+	for _, pattern := range config.anonymousPull {
+		anonymousPull = append(anonymousPull, auth.Resource{Type: "repository", Name: pattern})
+	}
+
 	return &accessController{
-		realm:        config.realm,
-		autoRedirect: config.autoRedirect,
-		issuer:       config.issuer,
-		service:      config.service,
-		rootCerts:    rootPool,
-		trustedKeys:  trustedKeys,
+		realm:         config.realm,
+		autoRedirect:  config.autoRedirect,
+		service:       config.service,
+		issuers:       issuers,
+		anonymousPull: anonymousPull,
+		leeway:        config.clockSkew,
+		replayCache:   config.replayCache,
 	}, nil
 }
 
@@ -247,7 +552,12 @@ func (ac *accessController) Authorized(ctx context.Context, accessItems ...auth.
 		return nil, err
 	}
 
-	parts := strings.Split(req.Header.Get("Authorization"), " ")
+	header := req.Header.Get("Authorization")
+	if header == "" && ac.allowsAnonymous(accessItems) {
+		return auth.WithUser(ctx, auth.UserInfo{Type: anonymousUserType}), nil
+	}
+
+	parts := strings.Split(header, " ")
 
 	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
 		challenge.err = ErrTokenRequired
@@ -262,13 +572,21 @@ func (ac *accessController) Authorized(ctx context.Context, accessItems ...auth.
 		return nil, challenge
 	}
 
-	verifyOpts := VerifyOptions{
-		TrustedIssuers:    []string{ac.issuer},
-		AcceptedAudiences: []string{ac.service},
-		Roots:             ac.rootCerts,
-		TrustedKeys:       ac.trustedKeys,
+	issuer, ok := ac.issuers[token.Claims.Issuer]
+	if !ok {
+		dcontext.GetLogger(ctx).WithField("issuer", token.Claims.Issuer).Error("token from untrusted issuer")
+		challenge.err = ErrInvalidToken
+		return nil, challenge
+	}
+
+	verifyOpts, err := issuer.verifyOptions(ctx, token.Header.KeyID)
+	if err != nil {
+		dcontext.GetLogger(ctx).WithError(err).WithField("issuer", issuer.name).Error("unable to get token issuer keys")
+		challenge.err = ErrInvalidToken
+		return nil, challenge
 	}
 
+	verifyOpts.Leeway = ac.leeway
 	if err = token.Verify(verifyOpts); err != nil {
 		challenge.err = err
 		return nil, challenge
@@ -282,11 +600,44 @@ func (ac *accessController) Authorized(ctx context.Context, accessItems ...auth.
 		}
 	}
 
+	if issuer.oneTime {
+		if err := ac.checkReplay(ctx, token, verifyOpts.leeway()); err != nil {
+			challenge.err = err
+			return nil, challenge
+		}
+	}
+
 	ctx = auth.WithResources(ctx, token.resources())
+	ctx = auth.WithGrantedAccess(ctx, token.grantedAccess())
+	ctx = auth.WithProtectedTagPatterns(ctx, token.protectedTagPatterns())
 
 	return auth.WithUser(ctx, auth.UserInfo{Name: token.Claims.Subject, Type: token.Claims.AuthType, JWT: token.Claims.User}), nil
 }
 
+// checkReplay records the ID of a token from a one-time issuer, until it expires, returning ErrInvalidToken if the token
+// has no ID or was already used. Tokens are also rejected if they can not be recorded, so that they can never be reused.
+func (ac *accessController) checkReplay(ctx context.Context, token *Token, leeway time.Duration) error {
+	l := dcontext.GetLogger(ctx).WithField("issuer", token.Claims.Issuer)
+
+	if token.Claims.JWTID == "" {
+		l.Error("token from one-time issuer has no ID")
+		return ErrInvalidToken
+	}
+
+	until := time.Unix(token.Claims.Expiration, 0).Add(leeway)
+	first, err := ac.replayCache.Record(ctx, token.Claims.Issuer, token.Claims.JWTID, until)
+	if err != nil {
+		l.WithError(err).Error("unable to record one-time token ID")
+		return ErrInvalidToken
+	}
+	if !first {
+		l.WithField("jti", token.Claims.JWTID).Warn("one-time token was already used")
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
 // init handles registering the token auth backend.
 func init() {
 	auth.Register("token", auth.InitFunc(newAccessController))
@@ -0,0 +1,151 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/libtrust"
+)
+
+const (
+	// defaultJWKSRefreshInterval is the interval at which the keys of a JWKS endpoint are refreshed by default.
+	defaultJWKSRefreshInterval = time.Hour
+	// jwksMinRefreshInterval is the minimum interval between two fetches of a JWKS endpoint, so that tokens signed by
+	// unknown keys can't be used to flood it.
+	jwksMinRefreshInterval = time.Minute
+	// jwksFetchTimeout is the maximum time to wait for a JWKS endpoint to respond.
+	jwksFetchTimeout = 10 * time.Second
+	// jwksMaxSize is the maximum size of a JWKS endpoint response.
+	jwksMaxSize = 1 << 20
+)
+
+// jwks caches the public keys published at a JSON Web Key Set (JWKS) endpoint, as described in
+// https://tools.ietf.org/html/rfc7517#section-5. Keys are fetched on first use and refreshed periodically, or earlier if
+// a token is signed by an unknown key, to handle key rotation.
+type jwks struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]libtrust.PublicKey
+	fetchedAt time.Time
+}
+
+func newJWKS(url string, refresh time.Duration) *jwks {
+	if refresh <= 0 {
+		refresh = defaultJWKSRefreshInterval
+	}
+	return &jwks{
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: jwksFetchTimeout},
+	}
+}
+
+// trustedKeys returns the cached keys, indexed by their key ID, fetching them first if they are stale or if none of them
+// has the given key ID. If fetching fails, the previously cached keys are returned, if any.
+func (j *jwks) trustedKeys(ctx context.Context, keyID string) (map[string]libtrust.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	age := time.Since(j.fetchedAt)
+	_, known := j.keys[keyID]
+	if j.keys != nil && age < j.refresh && (known || keyID == "" || age < jwksMinRefreshInterval) {
+		return j.keys, nil
+	}
+
+	keys, err := j.fetch(ctx)
+	if err != nil {
+		if j.keys == nil {
+			return nil, err
+		}
+		dcontext.GetLogger(ctx).WithError(err).WithField("url", j.url).Warn("unable to refresh JWKS, using cached keys")
+		return j.keys, nil
+	}
+
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return j.keys, nil
+}
+
+// fetch retrieves and parses the keys published at the JWKS endpoint.
+func (j *jwks) fetch(ctx context.Context) (map[string]libtrust.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating JWKS request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS from %q: %w", j.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS from %q: unexpected status %s", j.url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS from %q: %w", j.url, err)
+	}
+
+	keys, err := parseJWKS(body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing JWKS from %q: %w", j.url, err)
+	}
+	return keys, nil
+}
+
+// parseJWKS parses the signing keys of a JWKS document, indexed by both their key ID, as set by the issuer, and their
+// libtrust fingerprint. Keys used for encryption or of unsupported types are ignored.
+func parseJWKS(data []byte) (map[string]libtrust.PublicKey, error) {
+	var set struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]libtrust.PublicKey, 2*len(set.Keys))
+	for _, jwk := range set.Keys {
+		if use, _ := jwk["use"].(string); use != "" && use != "sig" {
+			continue
+		}
+		if kty, _ := jwk["kty"].(string); kty != "RSA" && kty != "EC" {
+			continue
+		}
+
+		// libtrust expects key IDs to be the key fingerprint, which is not the case for most issuers
+		kid, _ := jwk["kid"].(string)
+		delete(jwk, "kid")
+
+		raw, err := json.Marshal(jwk)
+		if err != nil {
+			return nil, err
+		}
+		key, err := libtrust.UnmarshalPublicKeyJWK(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", kid, err)
+		}
+
+		if kid != "" {
+			keys[kid] = key
+		}
+		keys[key.KeyID()] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+	return keys, nil
+}
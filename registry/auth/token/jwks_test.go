@@ -0,0 +1,50 @@
+package token
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/libtrust"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJWKS(t *testing.T) {
+	sigKey, err := libtrust.GenerateECP256PrivateKey()
+	require.NoError(t, err)
+	encKey, err := libtrust.GenerateRSA2048PrivateKey()
+	require.NoError(t, err)
+
+	jwk := func(key libtrust.PrivateKey, kid, use string) map[string]interface{} {
+		p, err := key.PublicKey().MarshalJSON()
+		require.NoError(t, err)
+
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal(p, &m))
+		m["kid"] = kid
+		if use != "" {
+			m["use"] = use
+		}
+		return m
+	}
+
+	p, err := json.Marshal(map[string]interface{}{
+		"keys": []interface{}{
+			jwk(sigKey, "sig-1", ""),
+			jwk(encKey, "enc-1", "enc"),
+			map[string]interface{}{"kty": "oct", "kid": "hmac-1", "k": "c2VjcmV0"},
+		},
+	})
+	require.NoError(t, err)
+
+	keys, err := parseJWKS(p)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, sigKey.KeyID(), keys["sig-1"].KeyID())
+	require.Equal(t, sigKey.KeyID(), keys[sigKey.KeyID()].KeyID())
+
+	_, err = parseJWKS([]byte(`{"keys": []}`))
+	require.EqualError(t, err, "no signing keys found")
+
+	_, err = parseJWKS([]byte(`{"keys": [{"kty": "EC", "kid": "bad", "crv": "P-256"}]}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package token
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// ReplayCacheOption is the option holding the ReplayCache used to reject reused one-time tokens. Unlike other
+	// options, it is not read from the configuration but set by the registry when the Redis cache is enabled.
+	ReplayCacheOption = "replaycache"
+
+	replayCacheKeyPrefix = "registry:auth:jti"
+)
+
+// ReplayCache records the IDs of one-time tokens, so that each of them is accepted only once.
+type ReplayCache interface {
+	// Record records the token with the given issuer and ID until the given time, returning false if it was already
+	// recorded.
+	Record(ctx context.Context, issuer, id string, until time.Time) (bool, error)
+}
+
+// redisReplayCache is a ReplayCache backed by Redis, shared by all registry instances.
+type redisReplayCache struct {
+	client redis.UniversalClient
+}
+
+// NewRedisReplayCache builds a ReplayCache that records token IDs in Redis.
+func NewRedisReplayCache(client redis.UniversalClient) ReplayCache {
+	return &redisReplayCache{client: client}
+}
+
+// Record records the token with the given issuer and ID until the given time, returning false if it was already
+// recorded. Token IDs are hashed, as they are arbitrary strings chosen by the issuer.
+func (c *redisReplayCache) Record(ctx context.Context, issuer, id string, until time.Time) (bool, error) {
+	ttl := time.Until(until)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+
+	sum := sha256.Sum256([]byte(issuer + "\x00" + id))
+	key := replayCacheKeyPrefix + ":" + hex.EncodeToString(sum[:])
+
+	return c.client.SetNX(ctx, key, 1, ttl).Result()
+}
@@ -0,0 +1,48 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisReplayCache(t *testing.T) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	c := NewRedisReplayCache(client)
+	ctx := context.Background()
+	until := time.Now().Add(time.Minute)
+
+	first, err := c.Record(ctx, "a", "foo", until)
+	require.NoError(t, err)
+	require.True(t, first)
+
+	first, err = c.Record(ctx, "a", "foo", until)
+	require.NoError(t, err)
+	require.False(t, first)
+
+	// IDs are scoped by issuer
+	first, err = c.Record(ctx, "b", "foo", until)
+	require.NoError(t, err)
+	require.True(t, first)
+
+	// and recorded until the given time
+	srv.FastForward(time.Minute)
+	first, err = c.Record(ctx, "a", "foo", until)
+	require.NoError(t, err)
+	require.True(t, first)
+
+	// already expired tokens are still recorded for a while
+	first, err = c.Record(ctx, "a", "bar", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.True(t, first)
+	first, err = c.Record(ctx, "a", "bar", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.False(t, first)
+}
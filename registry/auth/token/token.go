@@ -63,6 +63,9 @@ type ClaimSet struct {
 type Meta struct {
 	// ProjectPath contains the full path of the GitLab project of a repository that a token was issued for.
 	ProjectPath string `json:"project_path"`
+	// ProtectedTagPatterns contains the regular expressions matching the names of the tags of a repository which are
+	// protected from being pushed or deleted by the token bearer.
+	ProtectedTagPatterns []string `json:"protected_tag_patterns,omitempty"`
 }
 
 // Header describes the header section of a JSON Web Token.
@@ -89,6 +92,16 @@ type VerifyOptions struct {
 	AcceptedAudiences []string
 	Roots             *x509.CertPool
 	TrustedKeys       map[string]libtrust.PublicKey
+	// Leeway overrides the default Leeway added to NBF and EXP claim checks, if set.
+	Leeway *time.Duration
+}
+
+// leeway returns the leeway to add to NBF and EXP claim checks.
+func (opts VerifyOptions) leeway() time.Duration {
+	if opts.Leeway != nil {
+		return *opts.Leeway
+	}
+	return Leeway
 }
 
 // NewToken parses the given raw token string
@@ -165,14 +178,15 @@ func (t *Token) Verify(verifyOpts VerifyOptions) error {
 
 	// Verify that the token is currently usable and not expired.
 	currentTime := time.Now()
+	leeway := verifyOpts.leeway()
 
-	ExpWithLeeway := time.Unix(t.Claims.Expiration, 0).Add(Leeway)
+	ExpWithLeeway := time.Unix(t.Claims.Expiration, 0).Add(leeway)
 	if currentTime.After(ExpWithLeeway) {
 		logger.WithFields(log.Fields{"valid_until": ExpWithLeeway}).Warn("token has expired")
 		return ErrInvalidToken
 	}
 
-	NotBeforeWithLeeway := time.Unix(t.Claims.NotBefore, 0).Add(-Leeway)
+	NotBeforeWithLeeway := time.Unix(t.Claims.NotBefore, 0).Add(-leeway)
 	if currentTime.Before(NotBeforeWithLeeway) {
 		logger.WithFields(log.Fields{"valid_after": NotBeforeWithLeeway}).Warn("token used before time")
 		return ErrInvalidToken
@@ -369,6 +383,18 @@ func (t *Token) accessSet() accessSet {
 	return accessSet
 }
 
+// grantedAccess returns all the access granted by the `access` section of this token, one item per resource action.
+func (t *Token) grantedAccess() []auth.Access {
+	var access []auth.Access
+	for resource, set := range t.accessSet() {
+		for _, action := range set.keys() {
+			access = append(access, auth.Access{Resource: resource, Action: action})
+		}
+	}
+
+	return access
+}
+
 func (t *Token) resources() []auth.Resource {
 	if t.Claims == nil {
 		return nil
@@ -395,6 +421,25 @@ func (t *Token) resources() []auth.Resource {
 	return resources
 }
 
+// protectedTagPatterns returns the protected tag patterns of all repository resources, keyed by repository name.
+func (t *Token) protectedTagPatterns() map[string][]string {
+	if t.Claims == nil {
+		return nil
+	}
+
+	patterns := make(map[string][]string)
+	for _, resourceActions := range t.Claims.Access {
+		if resourceActions.Type != "repository" || resourceActions.Meta == nil {
+			continue
+		}
+		if len(resourceActions.Meta.ProtectedTagPatterns) > 0 {
+			patterns[resourceActions.Name] = append(patterns[resourceActions.Name], resourceActions.Meta.ProtectedTagPatterns...)
+		}
+	}
+
+	return patterns
+}
+
 func (t *Token) compactRaw() string {
 	return fmt.Sprintf("%s.%s", t.Raw, joseBase64UrlEncode(t.Signature))
 }
@@ -8,10 +8,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -533,7 +536,7 @@ func TestNewAccessControllerPemBlock(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if len(ac.(*accessController).rootCerts.Subjects()) != 2 {
+	if len(ac.(*accessController).issuers[issuer].rootCerts.Subjects()) != 2 {
 		t.Fatal("accessController has the wrong number of certificates")
 	}
 }
@@ -559,8 +562,8 @@ func TestAccessController_Meta(t *testing.T) {
 		expectedProjectPaths []string
 	}{
 		{"no meta object", []*Meta{nil}, nil},
-		{"one meta object with project", []*Meta{{"foo/bar"}}, []string{"foo/bar"}},
-		{"multiple meta objects with projects", []*Meta{{"foo/bar"}, {"bar/foo"}}, []string{"foo/bar", "bar/foo"}},
+		{"one meta object with project", []*Meta{{ProjectPath: "foo/bar"}}, []string{"foo/bar"}},
+		{"multiple meta objects with projects", []*Meta{{ProjectPath: "foo/bar"}, {ProjectPath: "bar/foo"}}, []string{"foo/bar", "bar/foo"}},
 	}
 
 	for _, test := range tests {
@@ -584,6 +587,47 @@ func TestAccessController_Meta(t *testing.T) {
 	}
 }
 
+// TestAccessController_ProtectedTagPatterns tests that the protected tag patterns of repositories are correctly
+// unmarshalled from the JWT into the context.
+func TestAccessController_ProtectedTagPatterns(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, "https://registry.gitlab.com/v2/group/project/manifests/v1", nil)
+	require.NoError(t, err)
+	ctx := dcontext.WithRequest(dcontext.Background(), req)
+
+	access := auth.Access{
+		Resource: auth.Resource{
+			Type: "repository",
+			Name: "group/project",
+		},
+		Action: "push",
+	}
+	actions := []*ResourceActions{
+		{
+			Type:    access.Type,
+			Name:    access.Name,
+			Actions: []string{"pull", "push"},
+			Meta:    &Meta{ProjectPath: "group/project", ProtectedTagPatterns: []string{`^v\d+`}},
+		},
+		{
+			Type:    access.Type,
+			Name:    "group/*",
+			Actions: []string{"pull"},
+			Meta:    &Meta{ProtectedTagPatterns: []string{"^latest$"}},
+		},
+		{
+			Type:    access.Type,
+			Name:    "other/project",
+			Actions: []string{"pull"},
+		},
+	}
+
+	authCtx := newTestAuthContext(t, ctx, req, actions, access)
+
+	require.ElementsMatch(t, []string{`^v\d+`, "^latest$"}, auth.ProtectedTagPatterns(authCtx, "group/project"))
+	require.ElementsMatch(t, []string{"^latest$"}, auth.ProtectedTagPatterns(authCtx, "group/sub/project"))
+	require.Empty(t, auth.ProtectedTagPatterns(authCtx, "other/project"))
+}
+
 // newTestAuthContext creates a valid JWT token with the requested access controls and passes it through the accesscontoller's `Authorized`
 // in order to : assert the JWT is still valid (with a meta field embedded in it) AND to return a context with a possibly embedded meta object.
 func newTestAuthContext(t *testing.T, ctx context.Context, req *http.Request, actions []*ResourceActions, access ...auth.Access) context.Context {
@@ -622,3 +666,558 @@ func newTestAuthContext(t *testing.T, ctx context.Context, req *http.Request, ac
 
 	return authCtx
 }
+
+// makeTestTokenWithKeyID makes a token signed by key that references it only by the given key ID, like most OIDC
+// providers do.
+func makeTestTokenWithKeyID(t *testing.T, issuer, audience string, access []*ResourceActions, key libtrust.PrivateKey, keyID string) string {
+	t.Helper()
+
+	header, err := json.Marshal(&Header{Type: "JWT", SigningAlg: "ES256", KeyID: keyID})
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims, err := json.Marshal(&ClaimSet{
+		Issuer:     issuer,
+		Subject:    "foo",
+		Audience:   audience,
+		Expiration: now.Add(5 * time.Minute).Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+		Access:     access,
+	})
+	require.NoError(t, err)
+
+	payload := joseBase64UrlEncode(header) + TokenSeparator + joseBase64UrlEncode(claims)
+	sig, _, err := key.Sign(strings.NewReader(payload), crypto.SHA256)
+	require.NoError(t, err)
+
+	return payload + TokenSeparator + joseBase64UrlEncode(sig)
+}
+
+// jwksServer serves the public keys of the given private keys as a JWKS, with the given key IDs.
+type jwksServer struct {
+	mu       sync.Mutex
+	keys     map[string]libtrust.PrivateKey
+	requests int
+}
+
+func (s *jwksServer) setKeys(keys map[string]libtrust.PrivateKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	var set struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	for kid, key := range s.keys {
+		p, err := key.PublicKey().MarshalJSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var jwk map[string]interface{}
+		if err := json.Unmarshal(p, &jwk); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jwk["kid"] = kid
+		jwk["use"] = "sig"
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(set)
+}
+
+func TestAccessController_MultipleIssuers(t *testing.T) {
+	rootKeys, err := makeRootKeys(3)
+	require.NoError(t, err)
+
+	rootCertBundleFilename, err := writeTempRootCerts(rootKeys[:1])
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(rootCertBundleFilename) })
+
+	js := &jwksServer{keys: map[string]libtrust.PrivateKey{"key-1": rootKeys[1]}}
+	srv := httptest.NewServer(js)
+	t.Cleanup(srv.Close)
+
+	const (
+		gitlabIssuer = "gitlab-issuer"
+		oidcIssuer   = "https://oidc.example.com"
+		service      = "container_registry"
+	)
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":          "https://gitlab.com/jwt/auth",
+		"issuer":         gitlabIssuer,
+		"service":        service,
+		"rootcertbundle": rootCertBundleFilename,
+		"issuers": []interface{}{
+			map[interface{}]interface{}{
+				"issuer":    oidcIssuer,
+				"jwks":      srv.URL,
+				"audiences": []interface{}{"registry", "other"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	access := auth.Access{Resource: auth.Resource{Type: "repository", Name: "foo/bar"}, Action: "pull"}
+	actions := []*ResourceActions{{Type: access.Type, Name: access.Name, Actions: []string{access.Action}}}
+
+	authorize := func(rawToken string) error {
+		req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/foo/bar/tags/list", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+rawToken)
+
+		_, err = ac.Authorized(dcontext.WithRequest(context.Background(), req), access)
+		return err
+	}
+
+	token, err := makeTestToken(gitlabIssuer, service, actions, rootKeys[0], 1, time.Now(), time.Now().Add(5*time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, authorize(token.compactRaw()))
+
+	require.NoError(t, authorize(makeTestTokenWithKeyID(t, oidcIssuer, "registry", actions, rootKeys[1], "key-1")))
+	require.NoError(t, authorize(makeTestTokenWithKeyID(t, oidcIssuer, "other", actions, rootKeys[1], "key-1")))
+
+	// audiences are configured per issuer
+	require.EqualError(t, authorize(makeTestTokenWithKeyID(t, oidcIssuer, service, actions, rootKeys[1], "key-1")), ErrInvalidToken.Error())
+	// keys are trusted per issuer
+	require.EqualError(t, authorize(makeTestTokenWithKeyID(t, gitlabIssuer, service, actions, rootKeys[1], "key-1")), ErrInvalidToken.Error())
+	require.EqualError(t, authorize(makeTestTokenWithKeyID(t, "unknown", service, actions, rootKeys[1], "key-1")), ErrInvalidToken.Error())
+
+	// rotated keys are fetched once the refresh backoff is over
+	js.setKeys(map[string]libtrust.PrivateKey{"key-2": rootKeys[2]})
+	rotated := makeTestTokenWithKeyID(t, oidcIssuer, "registry", actions, rootKeys[2], "key-2")
+	require.EqualError(t, authorize(rotated), ErrInvalidToken.Error())
+	require.Equal(t, 1, js.requests)
+
+	ac.(*accessController).issuers[oidcIssuer].jwks.fetchedAt = time.Now().Add(-jwksMinRefreshInterval)
+	require.NoError(t, authorize(rotated))
+	require.Equal(t, 2, js.requests)
+	require.EqualError(t, authorize(makeTestTokenWithKeyID(t, oidcIssuer, "registry", actions, rootKeys[1], "key-1")), ErrInvalidToken.Error())
+}
+
+func TestCheckOptions_Issuers(t *testing.T) {
+	base := func(issuers interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"realm":   "https://gitlab.com/jwt/auth",
+			"service": "container_registry",
+			"issuers": issuers,
+		}
+	}
+
+	opts, err := checkOptions(base([]interface{}{
+		map[interface{}]interface{}{"issuer": "a", "jwks": "https://a.example.com/jwks", "jwksrefresh": "10m"},
+		map[string]interface{}{"issuer": "b", "rootcertbundle": "/b.crt", "audiences": []interface{}{"x"}},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, []issuerOptions{
+		{issuer: "a", jwks: "https://a.example.com/jwks", jwksRefresh: 10 * time.Minute},
+		{issuer: "b", rootCertBundle: "/b.crt", audiences: []string{"x"}},
+	}, opts.issuers)
+
+	tcs := map[string]struct {
+		options map[string]interface{}
+		err     string
+	}{
+		"no issuers": {
+			options: base(nil),
+			err:     `token auth requires a valid option string: "issuer"`,
+		},
+		"not a list": {
+			options: base("a"),
+			err:     "token auth requires a valid option list: issuers",
+		},
+		"missing issuer": {
+			options: base([]interface{}{map[string]interface{}{"jwks": "https://a.example.com/jwks"}}),
+			err:     "token auth issuers[0]: issuer is required",
+		},
+		"missing keys": {
+			options: base([]interface{}{map[string]interface{}{"issuer": "a"}}),
+			err:     "token auth issuers[0]: at least one of rootcertbundle or jwks is required",
+		},
+		"invalid refresh": {
+			options: base([]interface{}{map[string]interface{}{"issuer": "a", "jwks": "https://a.example.com/jwks", "jwksrefresh": "soon"}}),
+			err:     `token auth issuers[0]: invalid option "jwksrefresh"`,
+		},
+		"invalid onetime": {
+			options: base([]interface{}{map[string]interface{}{"issuer": "a", "jwks": "https://a.example.com/jwks", "onetime": "yes"}}),
+			err:     `token auth issuers[0]: invalid option "onetime"`,
+		},
+		"configured replay cache": {
+			options: func() map[string]interface{} {
+				o := base([]interface{}{map[string]interface{}{"issuer": "a", "jwks": "https://a.example.com/jwks"}})
+				o[ReplayCacheOption] = true
+				return o
+			}(),
+			err: `token auth option "replaycache" can not be configured`,
+		},
+		"unknown option": {
+			options: base([]interface{}{map[string]interface{}{"issuer": "a", "jwks": "https://a.example.com/jwks", "foo": "bar"}}),
+			err:     `token auth issuers[0]: unknown option "foo"`,
+		},
+		"issuer without bundle": {
+			options: func() map[string]interface{} {
+				o := base([]interface{}{map[string]interface{}{"issuer": "a", "jwks": "https://a.example.com/jwks"}})
+				o["issuer"] = "b"
+				return o
+			}(),
+			err: "token auth requires both issuer and rootcertbundle options, or neither of them",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := checkOptions(tc.options)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestAccessSet_Wildcard(t *testing.T) {
+	token := &Token{Claims: &ClaimSet{Access: []*ResourceActions{
+		{Type: "repository", Name: "group/*", Actions: []string{"pull"}},
+		{Type: "repository", Name: "group/subgroup/*", Actions: []string{"push"}},
+		{Type: "repository", Name: "other", Actions: []string{"pull"}},
+		{Type: "registry", Name: "catalog/*", Actions: []string{"*"}},
+	}}}
+	set := token.accessSet()
+
+	access := func(typ, name, action string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: typ, Name: name}, Action: action}
+	}
+
+	tcs := []struct {
+		access   auth.Access
+		expected bool
+	}{
+		{access("repository", "group/project", "pull"), true},
+		{access("repository", "group/subgroup/project", "pull"), true},
+		{access("repository", "group/subgroup/project", "push"), true},
+		{access("repository", "group/project", "push"), false},
+		{access("repository", "group", "pull"), false},
+		{access("repository", "groupie/project", "pull"), false},
+		{access("repository", "other", "pull"), true},
+		{access("repository", "other/project", "pull"), false},
+		// wildcards only apply to repositories
+		{access("registry", "catalog/foo", "pull"), false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(fmt.Sprintf("%s:%s:%s", tc.access.Type, tc.access.Name, tc.access.Action), func(t *testing.T) {
+			require.Equal(t, tc.expected, set.contains(tc.access))
+		})
+	}
+}
+
+func TestAccessController_Wildcard(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://registry.gitlab.com/v2/group/subgroup/project/tags/list", nil)
+	require.NoError(t, err)
+	ctx := dcontext.WithRequest(dcontext.Background(), req)
+
+	access := auth.Access{Resource: auth.Resource{Type: "repository", Name: "group/subgroup/project"}, Action: "pull"}
+	actions := []*ResourceActions{{Type: "repository", Name: "group/*", Actions: []string{"pull"}}}
+
+	authCtx := newTestAuthContext(t, ctx, req, actions, access)
+
+	resources := auth.AuthorizedResources(authCtx)
+	require.Len(t, resources, 1)
+	require.True(t, resources[0].Covers(access.Name))
+}
+
+func TestAccessController_GrantedAccess(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, "https://registry.gitlab.com/v2/group/project/manifests/latest", nil)
+	require.NoError(t, err)
+	ctx := dcontext.WithRequest(dcontext.Background(), req)
+
+	access := auth.Access{Resource: auth.Resource{Type: "repository", Name: "group/project"}, Action: "push"}
+	actions := []*ResourceActions{
+		{Type: "repository", Name: "group/project", Actions: []string{"pull", "push"}},
+		{Type: "repository", Name: "group/other", Actions: []string{"pull"}},
+	}
+
+	authCtx := newTestAuthContext(t, ctx, req, actions, access)
+
+	granted := auth.GrantedAccess(authCtx)
+	require.ElementsMatch(t, []auth.Access{
+		{Resource: auth.Resource{Type: "repository", Name: "group/project"}, Action: "pull"},
+		{Resource: auth.Resource{Type: "repository", Name: "group/project"}, Action: "push"},
+		{Resource: auth.Resource{Type: "repository", Name: "group/other"}, Action: "pull"},
+	}, granted)
+}
+
+func TestAccessController_AnonymousPull(t *testing.T) {
+	rootKeys, err := makeRootKeys(1)
+	require.NoError(t, err)
+
+	rootCertBundleFilename, err := writeTempRootCerts(rootKeys)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(rootCertBundleFilename) })
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":          "https://gitlab.com/jwt/auth",
+		"issuer":         "gitlab-issuer",
+		"service":        "container_registry",
+		"rootcertbundle": rootCertBundleFilename,
+		"anonymouspull":  []interface{}{"public/*", "library/alpine"},
+	})
+	require.NoError(t, err)
+
+	pull := func(name string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "pull"}
+	}
+	push := pull("public/foo")
+	push.Action = "push"
+
+	tcs := map[string]struct {
+		header  string
+		access  []auth.Access
+		allowed bool
+	}{
+		"covered by wildcard":       {access: []auth.Access{pull("public/foo/bar")}, allowed: true},
+		"exact":                     {access: []auth.Access{pull("library/alpine")}, allowed: true},
+		"not covered":               {access: []auth.Access{pull("private/foo")}},
+		"wildcard base":             {access: []auth.Access{pull("public")}},
+		"exact descendant":          {access: []auth.Access{pull("library/alpine/foo")}},
+		"push":                      {access: []auth.Access{pull("public/foo"), push}},
+		"mount from private":        {access: []auth.Access{pull("public/foo"), pull("private/foo")}},
+		"no access":                 {},
+		"with invalid bearer token": {header: "Bearer foo", access: []auth.Access{pull("public/foo")}},
+		"with basic credentials":    {header: "Basic Zm9vOmJhcg==", access: []auth.Access{pull("public/foo")}},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "https://registry.gitlab.com/v2/", nil)
+			require.NoError(t, err)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			authCtx, err := ac.Authorized(dcontext.WithRequest(context.Background(), req), tc.access...)
+			if !tc.allowed {
+				require.Error(t, err)
+				require.Implements(t, (*auth.Challenge)(nil), err)
+				return
+			}
+
+			require.NoError(t, err)
+			userInfo, ok := authCtx.Value(auth.UserKey).(auth.UserInfo)
+			require.True(t, ok)
+			require.Equal(t, auth.UserInfo{Type: anonymousUserType}, userInfo)
+		})
+	}
+}
+
+func TestCheckOptions_AnonymousPull(t *testing.T) {
+	options := func(patterns interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"realm":          "https://gitlab.com/jwt/auth",
+			"issuer":         "gitlab-issuer",
+			"service":        "container_registry",
+			"rootcertbundle": "/root/certs/bundle",
+			"anonymouspull":  patterns,
+		}
+	}
+
+	opts, err := checkOptions(options([]interface{}{"public/*", "library/alpine"}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"public/*", "library/alpine"}, opts.anonymousPull)
+
+	_, err = checkOptions(options("public/*"))
+	require.EqualError(t, err, "token auth requires a valid option list: anonymouspull")
+
+	_, err = checkOptions(options([]interface{}{"*"}))
+	require.ErrorContains(t, err, `token auth anonymouspull[0]: invalid pattern "*"`)
+
+	_, err = checkOptions(options([]interface{}{"Public/*"}))
+	require.ErrorContains(t, err, `token auth anonymouspull[0]: invalid pattern "Public/*"`)
+}
+
+func TestCheckOptions_ClockSkew(t *testing.T) {
+	options := func(skew interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"realm":          "https://gitlab.com/jwt/auth",
+			"issuer":         "gitlab-issuer",
+			"service":        "container_registry",
+			"rootcertbundle": "/root/certs/bundle",
+			"clockskew":      skew,
+		}
+	}
+
+	opts, err := checkOptions(options(nil))
+	require.NoError(t, err)
+	require.Nil(t, opts.clockSkew)
+
+	for s, d := range map[string]time.Duration{"0s": 0, "30s": 30 * time.Second, "10m": 10 * time.Minute} {
+		opts, err = checkOptions(options(s))
+		require.NoError(t, err)
+		require.Equal(t, &d, opts.clockSkew)
+	}
+
+	for _, s := range []interface{}{"soon", "-1s", "11m", 30} {
+		_, err = checkOptions(options(s))
+		require.Error(t, err)
+	}
+}
+
+func TestAccessController_ClockSkew(t *testing.T) {
+	rootKeys, err := makeRootKeys(1)
+	require.NoError(t, err)
+
+	rootCertBundleFilename, err := writeTempRootCerts(rootKeys)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(rootCertBundleFilename) })
+
+	const (
+		issuer  = "gitlab-issuer"
+		service = "container_registry"
+	)
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":          "https://gitlab.com/jwt/auth",
+		"issuer":         issuer,
+		"service":        service,
+		"rootcertbundle": rootCertBundleFilename,
+		"clockskew":      "5s",
+	})
+	require.NoError(t, err)
+
+	access := auth.Access{Resource: auth.Resource{Type: "repository", Name: "foo/bar"}, Action: "pull"}
+	actions := []*ResourceActions{{Type: access.Type, Name: access.Name, Actions: []string{access.Action}}}
+
+	authorize := func(nbf, exp time.Time) error {
+		token, err := makeTestToken(issuer, service, actions, rootKeys[0], 0, nbf, exp)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/foo/bar/tags/list", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token.compactRaw())
+
+		_, err = ac.Authorized(dcontext.WithRequest(context.Background(), req), access)
+		return err
+	}
+
+	now := time.Now()
+	require.NoError(t, authorize(now, now.Add(time.Minute)))
+	// within the configured skew
+	require.NoError(t, authorize(now.Add(3*time.Second), now.Add(time.Minute)))
+	require.NoError(t, authorize(now.Add(-time.Minute), now.Add(-3*time.Second)))
+	// outside the configured skew, but within the default leeway
+	require.EqualError(t, authorize(now.Add(30*time.Second), now.Add(time.Minute)), ErrInvalidToken.Error())
+	require.EqualError(t, authorize(now.Add(-time.Minute), now.Add(-30*time.Second)), ErrInvalidToken.Error())
+}
+
+// replayCacheFunc is a ReplayCache that delegates to a function.
+type replayCacheFunc func(issuer, id string, until time.Time) (bool, error)
+
+func (f replayCacheFunc) Record(_ context.Context, issuer, id string, until time.Time) (bool, error) {
+	return f(issuer, id, until)
+}
+
+func TestAccessController_OneTime(t *testing.T) {
+	rootKeys, err := makeRootKeys(2)
+	require.NoError(t, err)
+
+	rootCertBundleFilename, err := writeTempRootCerts(rootKeys[:1])
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(rootCertBundleFilename) })
+	otherCertBundleFilename, err := writeTempRootCerts(rootKeys[1:])
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(otherCertBundleFilename) })
+
+	const (
+		gitlabIssuer = "gitlab-issuer"
+		otherIssuer  = "other-issuer"
+		service      = "container_registry"
+	)
+
+	options := map[string]interface{}{
+		"realm":          "https://gitlab.com/jwt/auth",
+		"issuer":         gitlabIssuer,
+		"service":        service,
+		"rootcertbundle": rootCertBundleFilename,
+		"issuers": []interface{}{
+			map[interface{}]interface{}{
+				"issuer":         otherIssuer,
+				"rootcertbundle": otherCertBundleFilename,
+				"onetime":        true,
+			},
+		},
+	}
+
+	// one-time issuers require a replay cache
+	_, err = newAccessController(options)
+	require.EqualError(t, err, `token auth issuer "other-issuer" is one-time, which requires the redis cache to be enabled`)
+
+	var cacheErr error
+	seen := make(map[string]time.Time)
+	options[ReplayCacheOption] = replayCacheFunc(func(issuer, id string, until time.Time) (bool, error) {
+		if cacheErr != nil {
+			return false, cacheErr
+		}
+		key := issuer + ":" + id
+		if _, ok := seen[key]; ok {
+			return false, nil
+		}
+		seen[key] = until
+		return true, nil
+	})
+	ac, err := newAccessController(options)
+	require.NoError(t, err)
+
+	access := auth.Access{Resource: auth.Resource{Type: "repository", Name: "foo/bar"}, Action: "pull"}
+	actions := []*ResourceActions{{Type: access.Type, Name: access.Name, Actions: []string{access.Action}}}
+
+	authorize := func(token *Token) error {
+		req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/foo/bar/tags/list", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token.compactRaw())
+
+		_, err = ac.Authorized(dcontext.WithRequest(context.Background(), req), access)
+		return err
+	}
+
+	exp := time.Now().Add(5 * time.Minute)
+
+	// tokens from other issuers can be reused
+	token, err := makeTestToken(gitlabIssuer, service, actions, rootKeys[0], 0, time.Now(), exp)
+	require.NoError(t, err)
+	require.NoError(t, authorize(token))
+	require.NoError(t, authorize(token))
+	require.Empty(t, seen)
+
+	// tokens from one-time issuers can't
+	token, err = makeTestToken(otherIssuer, service, actions, rootKeys[1], 0, time.Now(), exp)
+	require.NoError(t, err)
+	require.NoError(t, authorize(token))
+	require.EqualError(t, authorize(token), ErrInvalidToken.Error())
+	require.Len(t, seen, 1)
+	// and are recorded until they expire, leeway included
+	require.Equal(t, time.Unix(exp.Unix(), 0).Add(Leeway), seen[otherIssuer+":"+token.Claims.JWTID])
+
+	// tokens with insufficient scope are not recorded
+	other := auth.Access{Resource: auth.Resource{Type: "repository", Name: "foo/baz"}, Action: "pull"}
+	token, err = makeTestToken(otherIssuer, service, actions, rootKeys[1], 0, time.Now(), exp)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/foo/baz/tags/list", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token.compactRaw())
+	_, err = ac.Authorized(dcontext.WithRequest(context.Background(), req), other)
+	require.EqualError(t, err, ErrInsufficientScope.Error())
+	require.Len(t, seen, 1)
+
+	// tokens are rejected if they can't be recorded
+	cacheErr = errors.New("connection refused")
+	require.EqualError(t, authorize(token), ErrInvalidToken.Error())
+	cacheErr = nil
+	require.NoError(t, authorize(token))
+}
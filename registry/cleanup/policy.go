@@ -0,0 +1,88 @@
+// Package cleanup provides the evaluation of repository tag cleanup policies and a background worker that executes
+// them when due.
+package cleanup
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// LatestTag is the name of the tag that cleanup policies never delete, as clients pull it by default.
+const LatestTag = "latest"
+
+// compileFull compiles a regular expression that must match tag names in full.
+func compileFull(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// publishedAt returns the last time a tag was pointed to a manifest, either when created or last updated.
+func publishedAt(t *models.Tag) time.Time {
+	if t.UpdatedAt.Valid && t.UpdatedAt.Time.After(t.CreatedAt) {
+		return t.UpdatedAt.Time
+	}
+	return t.CreatedAt
+}
+
+// Evaluate returns the tags in tt that policy p deletes at the given time, sorted by name. A tag is deleted if:
+//
+//   - its name matches p.NameRegexDelete and does not match p.NameRegexKeep, both in full;
+//   - it is not among the p.KeepN most recently published tags that pass the previous rule;
+//   - it was published more than p.OlderThan before now, if set;
+//   - it is not the "latest" tag, nor matches any of the given immutable tag patterns.
+//
+// An error is returned if any of the policy regular expressions is invalid.
+func Evaluate(p *models.CleanupPolicy, tt models.Tags, immutablePatterns []string, now time.Time) (models.Tags, error) {
+	deleteRegex, err := compileFull(p.NameRegexDelete)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delete name regex: %w", err)
+	}
+	var keepRegex *regexp.Regexp
+	if p.NameRegexKeep != "" {
+		if keepRegex, err = compileFull(p.NameRegexKeep); err != nil {
+			return nil, fmt.Errorf("invalid keep name regex: %w", err)
+		}
+	}
+
+	candidates := make(models.Tags, 0, len(tt))
+	for _, t := range tt {
+		if t.Name == LatestTag || !deleteRegex.MatchString(t.Name) {
+			continue
+		}
+		if keepRegex != nil && keepRegex.MatchString(t.Name) {
+			continue
+		}
+		if datastore.IsImmutableTagName(t.Name, immutablePatterns) {
+			continue
+		}
+		candidates = append(candidates, t)
+	}
+
+	// keep the most recently published tags, breaking ties by name for a deterministic outcome
+	sort.SliceStable(candidates, func(i, j int) bool {
+		pi, pj := publishedAt(candidates[i]), publishedAt(candidates[j])
+		if pi.Equal(pj) {
+			return candidates[i].Name < candidates[j].Name
+		}
+		return pi.After(pj)
+	})
+	if p.KeepN >= len(candidates) {
+		return models.Tags{}, nil
+	}
+	candidates = candidates[p.KeepN:]
+
+	deleted := make(models.Tags, 0, len(candidates))
+	for _, t := range candidates {
+		if p.OlderThan > 0 && now.Sub(publishedAt(t)) < p.OlderThan {
+			continue
+		}
+		deleted = append(deleted, t)
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].Name < deleted[j].Name })
+
+	return deleted, nil
+}
@@ -0,0 +1,109 @@
+package cleanup_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/cleanup"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/stretchr/testify/require"
+)
+
+func names(tt models.Tags) []string {
+	nn := make([]string, 0, len(tt))
+	for _, t := range tt {
+		nn = append(nn, t.Name)
+	}
+	return nn
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2023, 10, 30, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tag := func(name string, age time.Duration) *models.Tag {
+		return &models.Tag{Name: name, CreatedAt: now.Add(-age)}
+	}
+	tt := models.Tags{
+		tag("latest", 10*day),
+		tag("v1.0.0", 30*day),
+		tag("v1.1.0", 20*day),
+		tag("v1.2.0", 10*day),
+		tag("v1.3.0", 1*day),
+		tag("main-abc", 15*day),
+		tag("main-def", 2*day),
+		tag("stable", 40*day),
+	}
+	// republished tags count as published when updated
+	tt = append(tt, &models.Tag{
+		Name:      "v0.9.0",
+		CreatedAt: now.Add(-50 * day),
+		UpdatedAt: sql.NullTime{Time: now.Add(-5 * day), Valid: true},
+	})
+
+	tcs := []struct {
+		name      string
+		policy    models.CleanupPolicy
+		immutable []string
+		want      []string
+	}{
+		{
+			name:   "delete all but latest",
+			policy: models.CleanupPolicy{NameRegexDelete: ".*"},
+			want:   []string{"main-abc", "main-def", "stable", "v0.9.0", "v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0"},
+		},
+		{
+			name:   "regex matches in full",
+			policy: models.CleanupPolicy{NameRegexDelete: "main"},
+			want:   []string{},
+		},
+		{
+			name:   "keep regex",
+			policy: models.CleanupPolicy{NameRegexDelete: ".*", NameRegexKeep: "v.+|stable"},
+			want:   []string{"main-abc", "main-def"},
+		},
+		{
+			name:   "keep n",
+			policy: models.CleanupPolicy{NameRegexDelete: "v.+", KeepN: 2},
+			want:   []string{"v1.0.0", "v1.1.0", "v1.2.0"},
+		},
+		{
+			name:   "keep n greater than matches",
+			policy: models.CleanupPolicy{NameRegexDelete: "main-.+", KeepN: 5},
+			want:   []string{},
+		},
+		{
+			name:   "older than",
+			policy: models.CleanupPolicy{NameRegexDelete: ".*", OlderThan: 14 * day},
+			want:   []string{"main-abc", "stable", "v1.0.0", "v1.1.0"},
+		},
+		{
+			name:   "keep n and older than",
+			policy: models.CleanupPolicy{NameRegexDelete: "v.+", KeepN: 3, OlderThan: 25 * day},
+			want:   []string{"v1.0.0"},
+		},
+		{
+			name:      "immutable tags",
+			policy:    models.CleanupPolicy{NameRegexDelete: "v.+"},
+			immutable: []string{"v1.*"},
+			want:      []string{"v0.9.0"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := cleanup.Evaluate(&tc.policy, tt, tc.immutable, now)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, names(got))
+		})
+	}
+}
+
+func TestEvaluate_InvalidRegex(t *testing.T) {
+	_, err := cleanup.Evaluate(&models.CleanupPolicy{NameRegexDelete: "("}, nil, nil, time.Now())
+	require.ErrorContains(t, err, "invalid delete name regex")
+
+	_, err = cleanup.Evaluate(&models.CleanupPolicy{NameRegexDelete: ".*", NameRegexKeep: "["}, nil, nil, time.Now())
+	require.ErrorContains(t, err, "invalid keep name regex")
+}
@@ -0,0 +1,146 @@
+package cleanup
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+const (
+	componentKey = "component"
+	workerName   = "registry.cleanup.Worker"
+
+	defaultInterval = time.Minute
+)
+
+// PolicyStore is the subset of datastore.CleanupPolicyStore used to execute due cleanup policies.
+type PolicyStore interface {
+	ClaimNextDue(ctx context.Context) (*models.CleanupPolicy, error)
+	UpdateLastRunAt(ctx context.Context, p *models.CleanupPolicy) error
+}
+
+// Executor executes a cleanup policy, deleting the tags selected by Evaluate. It returns the names of the deleted tags.
+type Executor interface {
+	Execute(ctx context.Context, p *models.CleanupPolicy) ([]string, error)
+}
+
+// Worker periodically executes due cleanup policies. Policies are claimed one at a time, so multiple registry instances
+// can cooperate, and rescheduled when claimed, so a failed execution is only retried on the next scheduled run.
+type Worker struct {
+	store    PolicyStore
+	executor Executor
+	logger   log.Logger
+	interval time.Duration
+}
+
+// WorkerOption provides functional options for NewWorker.
+type WorkerOption func(*Worker)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) WorkerOption {
+	return func(w *Worker) {
+		w.logger = l
+	}
+}
+
+// WithInterval sets the interval between checks for due policies. Defaults to 1 minute.
+func WithInterval(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.interval = d
+	}
+}
+
+func (w *Worker) applyDefaults() {
+	if w.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		w.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if w.interval == 0 {
+		w.interval = defaultInterval
+	}
+}
+
+// NewWorker creates a new Worker.
+func NewWorker(store PolicyStore, executor Executor, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		store:    store,
+		executor: executor,
+	}
+	w.applyDefaults()
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.logger = w.logger.WithFields(log.Fields{componentKey: workerName})
+
+	return w
+}
+
+// Run claims and executes the next due cleanup policy, if any. It returns the executed policy, or nil if none was due,
+// and the names of the deleted tags.
+func (w *Worker) Run(ctx context.Context) (*models.CleanupPolicy, []string, error) {
+	p, err := w.store.ClaimNextDue(ctx)
+	if err != nil || p == nil {
+		return nil, nil, err
+	}
+
+	deleted, err := w.executor.Execute(ctx, p)
+	if err != nil {
+		return p, deleted, err
+	}
+
+	return p, deleted, w.store.UpdateLastRunAt(ctx, p)
+}
+
+// Start starts the Worker. This is a blocking call that, every configured interval, executes all due policies until
+// the provided context is canceled.
+func (w *Worker) Start(ctx context.Context) error {
+	w.logger.WithFields(log.Fields{"interval_s": w.interval.Seconds()}).Info("starting cleanup policies worker")
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		for ctx.Err() == nil {
+			id := correlation.SafeRandomID()
+			rCtx := correlation.ContextWithCorrelation(ctx, id)
+			l := w.logger.WithFields(log.Fields{correlation.FieldName: id})
+			rCtx = log.WithLogger(rCtx, l)
+
+			start := time.Now()
+			p, deleted, err := w.Run(rCtx)
+			if p == nil && err == nil {
+				// no more due policies
+				break
+			}
+			if p != nil {
+				l = l.WithFields(log.Fields{
+					"repository_path": p.RepositoryPath,
+					"repository_id":   p.RepositoryID,
+					"tags_count":      len(deleted),
+				})
+			}
+			l = l.WithFields(log.Fields{"duration_s": time.Since(start).Seconds()})
+			if err != nil {
+				l.WithError(err).Error("cleanup policy execution failed")
+				// avoid hammering a failing database, remaining due policies are executed on the next tick
+				break
+			}
+			l.Info("cleanup policy executed")
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package cleanup_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/cleanup"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePolicyStore is a cleanup.PolicyStore that serves a fixed queue of due policies.
+type fakePolicyStore struct {
+	mu       sync.Mutex
+	due      []*models.CleanupPolicy
+	claimErr error
+	ran      []*models.CleanupPolicy
+}
+
+func (s *fakePolicyStore) ClaimNextDue(context.Context) (*models.CleanupPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.claimErr != nil {
+		return nil, s.claimErr
+	}
+	if len(s.due) == 0 {
+		return nil, nil
+	}
+	p := s.due[0]
+	s.due = s.due[1:]
+	return p, nil
+}
+
+func (s *fakePolicyStore) UpdateLastRunAt(_ context.Context, p *models.CleanupPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ran = append(s.ran, p)
+	return nil
+}
+
+func (s *fakePolicyStore) executed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.ran)
+}
+
+// fakeExecutor is a cleanup.Executor that deletes a fixed list of tags, failing for the given repositories.
+type fakeExecutor struct {
+	deleted []string
+	fails   map[int64]error
+}
+
+func (e *fakeExecutor) Execute(_ context.Context, p *models.CleanupPolicy) ([]string, error) {
+	if err := e.fails[p.RepositoryID]; err != nil {
+		return nil, err
+	}
+	return e.deleted, nil
+}
+
+func TestWorker_Run(t *testing.T) {
+	p := &models.CleanupPolicy{NamespaceID: 1, RepositoryID: 2}
+	s := &fakePolicyStore{due: []*models.CleanupPolicy{p}}
+	e := &fakeExecutor{deleted: []string{"a", "b"}}
+	w := cleanup.NewWorker(s, e)
+
+	got, deleted, err := w.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, p, got)
+	require.Equal(t, []string{"a", "b"}, deleted)
+	require.Equal(t, []*models.CleanupPolicy{p}, s.ran)
+
+	// nothing else is due
+	got, deleted, err = w.Run(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, got)
+	require.Nil(t, deleted)
+}
+
+func TestWorker_Run_ExecuteError(t *testing.T) {
+	p := &models.CleanupPolicy{NamespaceID: 1, RepositoryID: 2}
+	s := &fakePolicyStore{due: []*models.CleanupPolicy{p}}
+	e := &fakeExecutor{fails: map[int64]error{2: errors.New("foo")}}
+	w := cleanup.NewWorker(s, e)
+
+	got, _, err := w.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.Equal(t, p, got)
+	// failed executions are not recorded as runs
+	require.Empty(t, s.ran)
+}
+
+func TestWorker_Run_ClaimError(t *testing.T) {
+	s := &fakePolicyStore{claimErr: errors.New("foo")}
+	w := cleanup.NewWorker(s, &fakeExecutor{})
+
+	got, _, err := w.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.Nil(t, got)
+}
+
+func TestWorker_Start(t *testing.T) {
+	s := &fakePolicyStore{due: []*models.CleanupPolicy{
+		{NamespaceID: 1, RepositoryID: 1},
+		{NamespaceID: 1, RepositoryID: 2},
+		{NamespaceID: 1, RepositoryID: 3},
+	}}
+	w := cleanup.NewWorker(s, &fakeExecutor{}, cleanup.WithInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- w.Start(ctx) }()
+
+	// all due policies are executed
+	require.Eventually(t, func() bool { return s.executed() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+}
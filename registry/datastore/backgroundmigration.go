@@ -0,0 +1,219 @@
+//go:generate mockgen -package mocks -destination mocks/backgroundmigration.go . BackgroundMigrationStore
+
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// BackgroundMigrationStore is the interface that a background migration store should conform to.
+type BackgroundMigrationStore interface {
+	// FindAll finds all background migrations, sorted by ID.
+	FindAll(ctx context.Context) (models.BackgroundMigrations, error)
+	// FindByName finds a background migration by name. Returns nil if not found.
+	FindByName(ctx context.Context, name string) (*models.BackgroundMigration, error)
+	// Create saves a new background migration.
+	Create(ctx context.Context, m *models.BackgroundMigration) error
+	// Next reads and locks the active background migration with the lowest ID.
+	Next(ctx context.Context) (*models.BackgroundMigration, error)
+	// Update updates the status and progress of a background migration.
+	Update(ctx context.Context, m *models.BackgroundMigration) error
+}
+
+type backgroundMigrationStore struct {
+	db Queryer
+}
+
+// NewBackgroundMigrationStore builds a new backgroundMigrationStore.
+func NewBackgroundMigrationStore(db Queryer) BackgroundMigrationStore {
+	return &backgroundMigrationStore{db: db}
+}
+
+const backgroundMigrationColumns = `id,
+			name,
+			job_name,
+			table_name,
+			column_name,
+			status,
+			batch_size,
+			min_value,
+			max_value,
+			next_value,
+			processed_rows,
+			failed_attempts,
+			error_message,
+			created_at,
+			updated_at,
+			started_at,
+			finished_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBackgroundMigration(row rowScanner) (*models.BackgroundMigration, error) {
+	m := new(models.BackgroundMigration)
+	err := row.Scan(&m.ID, &m.Name, &m.JobName, &m.TableName, &m.ColumnName, &m.Status, &m.BatchSize, &m.MinValue,
+		&m.MaxValue, &m.NextValue, &m.ProcessedRows, &m.FailedAttempts, &m.ErrorMessage, &m.CreatedAt, &m.UpdatedAt,
+		&m.StartedAt, &m.FinishedAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("scanning background migration: %w", err)
+		}
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// FindAll finds all background migrations, sorted by ID.
+func (s *backgroundMigrationStore) FindAll(ctx context.Context) (models.BackgroundMigrations, error) {
+	defer metrics.InstrumentQuery(ctx, "background_migration_find_all")()
+
+	q := `SELECT
+			` + backgroundMigrationColumns + `
+		FROM
+			background_migrations
+		ORDER BY
+			id`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("finding background migrations: %w", err)
+	}
+	defer rows.Close()
+
+	mm := make(models.BackgroundMigrations, 0)
+	for rows.Next() {
+		m, err := scanBackgroundMigration(rows)
+		if err != nil {
+			return nil, err
+		}
+		mm = append(mm, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning background migrations: %w", err)
+	}
+
+	return mm, nil
+}
+
+// FindByName finds a background migration by name. Returns nil if not found.
+func (s *backgroundMigrationStore) FindByName(ctx context.Context, name string) (*models.BackgroundMigration, error) {
+	defer metrics.InstrumentQuery(ctx, "background_migration_find_by_name")()
+
+	q := `SELECT
+			` + backgroundMigrationColumns + `
+		FROM
+			background_migrations
+		WHERE
+			name = $1`
+
+	m, err := scanBackgroundMigration(s.db.QueryRowContext(ctx, q, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("finding background migration by name: %w", err)
+	}
+
+	return m, nil
+}
+
+// Create saves a new background migration. The status defaults to active and the next value to the minimum value.
+func (s *backgroundMigrationStore) Create(ctx context.Context, m *models.BackgroundMigration) error {
+	defer metrics.InstrumentQuery(ctx, "background_migration_create")()
+
+	q := `INSERT INTO background_migrations (name, job_name, table_name, column_name, status, batch_size, min_value,
+			max_value, next_value)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING
+			id, created_at`
+
+	if m.Status == "" {
+		m.Status = models.BackgroundMigrationActive
+	}
+	if m.NextValue == 0 {
+		m.NextValue = m.MinValue
+	}
+
+	row := s.db.QueryRowContext(ctx, q, m.Name, m.JobName, m.TableName, m.ColumnName, m.Status, m.BatchSize,
+		m.MinValue, m.MaxValue, m.NextValue)
+	if err := row.Scan(&m.ID, &m.CreatedAt); err != nil {
+		return fmt.Errorf("creating background migration: %w", err)
+	}
+
+	return nil
+}
+
+// Next reads and locks the active background migration with the lowest ID, so that background migrations are processed
+// one at a time and in order. This method may be called safely from multiple concurrent goroutines or processes. A
+// `SELECT FOR UPDATE` is used to ensure that only one caller processes the migration at a time. The operation does not
+// block, and no error is returned if there are no active migrations or the next one is locked by another process. A
+// `nil` record is returned in this situation.
+func (s *backgroundMigrationStore) Next(ctx context.Context) (*models.BackgroundMigration, error) {
+	defer metrics.InstrumentQuery(ctx, "background_migration_next")()
+
+	q := `SELECT
+			` + backgroundMigrationColumns + `
+		FROM
+			background_migrations
+		WHERE
+			id = (
+				SELECT
+					min(id)
+				FROM
+					background_migrations
+				WHERE
+					status = 'active')
+		FOR UPDATE
+			SKIP LOCKED`
+
+	m, err := scanBackgroundMigration(s.db.QueryRowContext(ctx, q))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fetching next background migration: %w", err)
+	}
+
+	return m, nil
+}
+
+// Update updates the status and progress of a background migration. The updated_at timestamp is set automatically.
+func (s *backgroundMigrationStore) Update(ctx context.Context, m *models.BackgroundMigration) error {
+	defer metrics.InstrumentQuery(ctx, "background_migration_update")()
+
+	q := `UPDATE
+			background_migrations
+		SET
+			status = $1,
+			next_value = $2,
+			processed_rows = $3,
+			failed_attempts = $4,
+			error_message = $5,
+			started_at = $6,
+			finished_at = $7,
+			updated_at = now()
+		WHERE
+			id = $8
+		RETURNING
+			updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, m.Status, m.NextValue, m.ProcessedRows, m.FailedAttempts, m.ErrorMessage,
+		m.StartedAt, m.FinishedAt, m.ID)
+	if err := row.Scan(&m.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("background migration not found")
+		}
+		return fmt.Errorf("updating background migration: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func unloadBackgroundMigrationFixtures(tb testing.TB) {
+	tb.Helper()
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.BackgroundMigrationsTable))
+}
+
+func createBackgroundMigration(tb testing.TB, s datastore.BackgroundMigrationStore, name string, status models.BackgroundMigrationStatus) *models.BackgroundMigration {
+	tb.Helper()
+
+	m := &models.BackgroundMigration{
+		Name:       name,
+		JobName:    "copy_foo_to_bar",
+		TableName:  "manifests",
+		ColumnName: "id",
+		Status:     status,
+		BatchSize:  10,
+		MinValue:   1,
+		MaxValue:   25,
+	}
+	require.NoError(tb, s.Create(suite.ctx, m))
+
+	return m
+}
+
+func TestBackgroundMigrationStore_Create(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	m := createBackgroundMigration(t, s, "backfill_foo", "")
+
+	require.NotEmpty(t, m.ID)
+	require.NotEmpty(t, m.CreatedAt)
+	require.Equal(t, models.BackgroundMigrationActive, m.Status)
+	require.Equal(t, m.MinValue, m.NextValue)
+}
+
+func TestBackgroundMigrationStore_Create_NonUniqueNameFails(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	createBackgroundMigration(t, s, "backfill_foo", "")
+
+	err := s.Create(suite.ctx, &models.BackgroundMigration{
+		Name:       "backfill_foo",
+		JobName:    "other",
+		TableName:  "blobs",
+		ColumnName: "id",
+		BatchSize:  1,
+	})
+	require.Error(t, err)
+}
+
+func TestBackgroundMigrationStore_FindByName(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	expected := createBackgroundMigration(t, s, "backfill_foo", "")
+
+	m, err := s.FindByName(suite.ctx, "backfill_foo")
+	require.NoError(t, err)
+	require.Equal(t, expected.ID, m.ID)
+	require.Equal(t, expected.JobName, m.JobName)
+	require.Equal(t, expected.TableName, m.TableName)
+	require.Equal(t, expected.ColumnName, m.ColumnName)
+	require.Equal(t, expected.BatchSize, m.BatchSize)
+	require.Equal(t, expected.MaxValue, m.MaxValue)
+	require.Equal(t, int64(0), m.ProcessedRows)
+	require.False(t, m.StartedAt.Valid)
+	require.False(t, m.FinishedAt.Valid)
+}
+
+func TestBackgroundMigrationStore_FindByName_NotFound(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	m, err := s.FindByName(suite.ctx, "foo")
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
+
+func TestBackgroundMigrationStore_FindAll(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	createBackgroundMigration(t, s, "a", "")
+	createBackgroundMigration(t, s, "b", models.BackgroundMigrationFinished)
+
+	mm, err := s.FindAll(suite.ctx)
+	require.NoError(t, err)
+	require.Len(t, mm, 2)
+	require.Equal(t, "a", mm[0].Name)
+	require.Equal(t, "b", mm[1].Name)
+}
+
+func TestBackgroundMigrationStore_Next(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	createBackgroundMigration(t, s, "a", models.BackgroundMigrationFinished)
+	createBackgroundMigration(t, s, "b", models.BackgroundMigrationFailed)
+	expected := createBackgroundMigration(t, s, "c", "")
+	createBackgroundMigration(t, s, "d", "")
+
+	tx, err := suite.db.BeginTx(suite.ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	m, err := datastore.NewBackgroundMigrationStore(tx).Next(suite.ctx)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	require.Equal(t, expected.ID, m.ID)
+
+	// the next migration is locked, so other transactions must not get any migration, not even the one after it
+	tx2, err := suite.db.BeginTx(suite.ctx, nil)
+	require.NoError(t, err)
+	defer tx2.Rollback()
+
+	m, err = datastore.NewBackgroundMigrationStore(tx2).Next(suite.ctx)
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
+
+func TestBackgroundMigrationStore_Next_None(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	createBackgroundMigration(t, s, "a", models.BackgroundMigrationFinished)
+
+	m, err := s.Next(suite.ctx)
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
+
+func TestBackgroundMigrationStore_Update(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	m := createBackgroundMigration(t, s, "a", "")
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	m.Status = models.BackgroundMigrationFinished
+	m.NextValue = 26
+	m.ProcessedRows = 20
+	m.FailedAttempts = 1
+	m.ErrorMessage = sql.NullString{String: "foo", Valid: true}
+	m.StartedAt = sql.NullTime{Time: now, Valid: true}
+	m.FinishedAt = sql.NullTime{Time: now, Valid: true}
+	require.NoError(t, s.Update(suite.ctx, m))
+	require.True(t, m.UpdatedAt.Valid)
+
+	actual, err := s.FindByName(suite.ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, m.Status, actual.Status)
+	require.Equal(t, m.NextValue, actual.NextValue)
+	require.Equal(t, m.ProcessedRows, actual.ProcessedRows)
+	require.Equal(t, m.FailedAttempts, actual.FailedAttempts)
+	require.Equal(t, m.ErrorMessage, actual.ErrorMessage)
+	require.Equal(t, now, actual.StartedAt.Time.UTC())
+	require.Equal(t, now, actual.FinishedAt.Time.UTC())
+}
+
+func TestBackgroundMigrationStore_Update_NotFound(t *testing.T) {
+	unloadBackgroundMigrationFixtures(t)
+
+	s := datastore.NewBackgroundMigrationStore(suite.db)
+	err := s.Update(suite.ctx, &models.BackgroundMigration{ID: 100, Status: models.BackgroundMigrationActive})
+	require.EqualError(t, err, "background migration not found")
+}
@@ -0,0 +1,41 @@
+package bbm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/docker/distribution/registry/datastore"
+)
+
+// Job processes a batch of a background migration, namely the rows of the migration table whose batching column value
+// is between start and end (inclusive), and returns the number of rows it processed. Jobs run within the transaction
+// that records the migration progress, so a batch is either fully processed and tracked or not at all. Jobs must be
+// idempotent, as the same batch is retried after a failure.
+type Job func(ctx context.Context, db datastore.Queryer, start, end int64) (int64, error)
+
+var (
+	jobsMu sync.RWMutex
+	jobs   = make(map[string]Job)
+)
+
+// Register makes a job available to background migrations under the given name, which is the one to use as job name
+// when enqueuing background migrations. It is meant to be called from init functions and panics if a job with the
+// same name is already registered.
+func Register(name string, job Job) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	if _, ok := jobs[name]; ok {
+		panic(fmt.Sprintf("background migration job %q already registered", name))
+	}
+	jobs[name] = job
+}
+
+func findJob(name string) (Job, bool) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+
+	job, ok := jobs[name]
+	return job, ok
+}
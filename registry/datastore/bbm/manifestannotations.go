@@ -0,0 +1,73 @@
+package bbm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// BackfillManifestAnnotationsJob is the name of the job that indexes the annotations of the OCI image manifests and
+// indexes pushed before annotations were indexed on push.
+const BackfillManifestAnnotationsJob = "backfill_manifest_annotations"
+
+func init() {
+	Register(BackfillManifestAnnotationsJob, backfillManifestAnnotations)
+}
+
+// backfillManifestAnnotations indexes the annotations of the OCI image manifests and indexes with an ID between start
+// and end, skipping those that exceed the length limits of the manifest_annotations table, as done on push. It returns
+// the number of manifests in the batch, which includes those without annotations.
+func backfillManifestAnnotations(ctx context.Context, db datastore.Queryer, start, end int64) (int64, error) {
+	q := `WITH batch AS (
+			SELECT
+				m.top_level_namespace_id,
+				m.repository_id,
+				m.id,
+				convert_from(m.payload, 'UTF8')::jsonb -> 'annotations' AS annotations
+			FROM
+				manifests AS m
+				JOIN media_types AS mt ON mt.id = m.media_type_id
+			WHERE
+				m.id BETWEEN $1 AND $2
+				AND mt.media_type IN ($3, $4)
+		),
+		indexed AS (
+			INSERT INTO manifest_annotations (top_level_namespace_id, repository_id, manifest_id, key, value)
+			SELECT
+				b.top_level_namespace_id,
+				b.repository_id,
+				b.id,
+				a.key,
+				a.value
+			FROM
+				batch AS b
+				CROSS JOIN LATERAL jsonb_each_text(
+					CASE jsonb_typeof(b.annotations)
+					WHEN 'object' THEN
+						b.annotations
+					ELSE
+						'{}'::jsonb
+					END) AS a
+			WHERE
+				a.value IS NOT NULL
+				AND char_length(a.key) <= $5
+				AND char_length(a.value) <= $6
+			ON CONFLICT
+				DO NOTHING
+		)
+		SELECT
+			count(*)
+		FROM
+			batch`
+
+	var n int64
+	row := db.QueryRowContext(ctx, q, start, end, v1.MediaTypeImageManifest, v1.MediaTypeImageIndex,
+		datastore.MaxAnnotationKeyLength, datastore.MaxAnnotationValueLength)
+	if err := row.Scan(&n); err != nil {
+		return 0, fmt.Errorf("backfilling manifest annotations: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,313 @@
+// Package bbm provides a worker for batched background migrations (BBM). These are data migrations, such as the
+// population of a new column, too slow to run as regular schema migrations on large installations. Instead, a schema
+// migration enqueues a background migration in the background_migrations table, which registry instances then process
+// in small batches, with a configurable pause between them, while serving requests.
+package bbm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal"
+	"github.com/hashicorp/go-multierror"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+const (
+	componentKey = "component"
+	workerName   = "registry.datastore.bbm.Worker"
+
+	defaultInterval         = time.Second
+	defaultIdleInterval     = time.Minute
+	defaultMaxAttempts      = 3
+	defaultStatementTimeout = 30 * time.Second
+
+	batchSavepoint     = "background_migration_batch"
+	maxErrorMessageLen = 1024
+)
+
+var (
+	// for test purposes (mocking)
+	systemClock                         internal.Clock = clock.New()
+	backgroundMigrationStoreConstructor                = datastore.NewBackgroundMigrationStore
+)
+
+// Result holds the outcome of a single Worker run.
+type Result struct {
+	// Migration is the name of the processed background migration. Empty if there was none to process.
+	Migration string
+	// Start and End are the bounds (inclusive) of the processed batch. Both are zero if no batch was processed.
+	Start, End int64
+	// Rows is the number of rows processed by the batch job.
+	Rows int64
+	// Finished is true if the run completed the background migration.
+	Finished bool
+	// Failed is true if the run marked the background migration as failed.
+	Failed bool
+}
+
+// Worker processes background migrations, one batch at a time. Multiple workers, within the same or different registry
+// instances, can run concurrently. Background migrations are processed in order, one at a time, and only one worker
+// processes a given migration at any point.
+type Worker struct {
+	db               datastore.Handler
+	logger           log.Logger
+	interval         time.Duration
+	maxAttempts      int
+	statementTimeout time.Duration
+}
+
+// WorkerOption provides functional options for NewWorker.
+type WorkerOption func(*Worker)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) WorkerOption {
+	return func(w *Worker) {
+		w.logger = l
+	}
+}
+
+// WithInterval sets the pause between batches, used to throttle background migrations. Defaults to 1 second.
+func WithInterval(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.interval = d
+	}
+}
+
+// WithMaxAttempts sets the maximum number of consecutive failed attempts to process a batch before marking the
+// background migration as failed. Defaults to 3.
+func WithMaxAttempts(n int) WorkerOption {
+	return func(w *Worker) {
+		w.maxAttempts = n
+	}
+}
+
+// WithStatementTimeout sets the maximum duration of each statement executed by batch jobs. Defaults to 30 seconds.
+func WithStatementTimeout(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.statementTimeout = d
+	}
+}
+
+func (w *Worker) applyDefaults() {
+	if w.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		w.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if w.interval == 0 {
+		w.interval = defaultInterval
+	}
+	if w.maxAttempts == 0 {
+		w.maxAttempts = defaultMaxAttempts
+	}
+	if w.statementTimeout == 0 {
+		w.statementTimeout = defaultStatementTimeout
+	}
+}
+
+// NewWorker creates a new Worker.
+func NewWorker(db datastore.Handler, opts ...WorkerOption) *Worker {
+	w := &Worker{db: db}
+	w.applyDefaults()
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.logger = w.logger.WithFields(log.Fields{componentKey: workerName})
+
+	return w
+}
+
+// Run processes the next batch of the active background migration with the lowest ID, if any. The batch job and the
+// migration progress are committed in the same transaction. If the job fails, its changes are discarded, but the
+// failure is recorded, and the migration is marked as failed once the maximum number of attempts is reached.
+func (w *Worker) Run(ctx context.Context) (*Result, error) {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	s := backgroundMigrationStoreConstructor(tx)
+	m, err := s.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("committing database transaction: %w", err)
+		}
+		return &Result{}, nil
+	}
+
+	res := &Result{Migration: m.Name}
+	now := systemClock.Now()
+	if !m.StartedAt.Valid {
+		m.StartedAt = sql.NullTime{Time: now, Valid: true}
+	}
+
+	var jobErr error
+	job, ok := findJob(m.JobName)
+	switch {
+	case !ok:
+		jobErr = fmt.Errorf("unknown background migration job %q", m.JobName)
+		m.Status = models.BackgroundMigrationFailed
+		m.ErrorMessage = errorMessage(jobErr)
+	case m.NextValue > m.MaxValue:
+		// nothing left to process, e.g. the table was empty when the migration was enqueued
+	default:
+		res.Start = m.NextValue
+		res.End = m.NextValue + int64(m.BatchSize) - 1
+		if res.End > m.MaxValue {
+			res.End = m.MaxValue
+		}
+
+		res.Rows, jobErr = w.runJob(ctx, tx, job, res.Start, res.End)
+		if jobErr != nil {
+			var tErr *txError
+			if errors.As(jobErr, &tErr) {
+				// the transaction is unusable, so the failure can't be recorded
+				return res, jobErr
+			}
+			m.FailedAttempts++
+			m.ErrorMessage = errorMessage(jobErr)
+			if m.FailedAttempts >= w.maxAttempts {
+				m.Status = models.BackgroundMigrationFailed
+			}
+			break
+		}
+
+		m.NextValue = res.End + 1
+		m.ProcessedRows += res.Rows
+		m.FailedAttempts = 0
+		m.ErrorMessage = sql.NullString{}
+	}
+
+	if m.Status == models.BackgroundMigrationActive && m.NextValue > m.MaxValue {
+		m.Status = models.BackgroundMigrationFinished
+		m.FinishedAt = sql.NullTime{Time: now, Valid: true}
+	}
+	res.Finished = m.Status == models.BackgroundMigrationFinished
+	res.Failed = m.Status == models.BackgroundMigrationFailed
+
+	if err := s.Update(ctx, m); err != nil {
+		return res, multierror.Append(jobErr, err).ErrorOrNil()
+	}
+	if err := tx.Commit(); err != nil {
+		return res, multierror.Append(jobErr, fmt.Errorf("committing database transaction: %w", err)).ErrorOrNil()
+	}
+
+	return res, jobErr
+}
+
+// txError wraps errors that leave the transaction unusable.
+type txError struct {
+	err error
+}
+
+func (e *txError) Error() string {
+	return e.err.Error()
+}
+
+func (e *txError) Unwrap() error {
+	return e.err
+}
+
+// runJob runs a batch job within a savepoint, so that its changes can be rolled back without losing the lock on the
+// migration, and with a statement timeout, so that a batch can't hold locks for too long.
+func (w *Worker) runJob(ctx context.Context, tx datastore.Transactor, job Job, start, end int64) (int64, error) {
+	q := fmt.Sprintf("SET LOCAL statement_timeout = %d", w.statementTimeout.Milliseconds())
+	if _, err := tx.ExecContext(ctx, q); err != nil {
+		return 0, &txError{fmt.Errorf("setting statement timeout: %w", err)}
+	}
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+batchSavepoint); err != nil {
+		return 0, &txError{fmt.Errorf("creating savepoint: %w", err)}
+	}
+
+	n, err := job(ctx, tx, start, end)
+	if err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+batchSavepoint); rbErr != nil {
+			return 0, &txError{multierror.Append(err, fmt.Errorf("rolling back to savepoint: %w", rbErr))}
+		}
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func errorMessage(err error) sql.NullString {
+	msg := err.Error()
+	if len(msg) > maxErrorMessageLen {
+		msg = msg[:maxErrorMessageLen]
+	}
+	return sql.NullString{String: msg, Valid: true}
+}
+
+// Start starts the Worker. This is a blocking call that processes background migrations until the provided context is
+// canceled. After each run, the worker pauses for the configured interval, or for a longer period if there was nothing
+// to process.
+func (w *Worker) Start(ctx context.Context) error {
+	w.logger.WithFields(log.Fields{
+		"interval_s":          w.interval.Seconds(),
+		"max_attempts":        w.maxAttempts,
+		"statement_timeout_s": w.statementTimeout.Seconds(),
+	}).Info("starting background migrations worker")
+
+	t := time.NewTimer(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		id := correlation.SafeRandomID()
+		rCtx := correlation.ContextWithCorrelation(ctx, id)
+		l := w.logger.WithFields(log.Fields{correlation.FieldName: id})
+		rCtx = log.WithLogger(rCtx, l)
+
+		start := time.Now()
+		res, err := w.Run(rCtx)
+
+		next := w.interval
+		if err == nil && res.Migration == "" {
+			next = defaultIdleInterval
+		}
+		t.Reset(next)
+
+		if res != nil && res.Migration != "" {
+			l = l.WithFields(log.Fields{
+				"migration": res.Migration,
+				"start":     res.Start,
+				"end":       res.End,
+				"rows":      res.Rows,
+				"finished":  res.Finished,
+				"failed":    res.Failed,
+			})
+		}
+		l = l.WithFields(log.Fields{"duration_s": time.Since(start).Seconds()})
+		if err != nil {
+			l.WithError(err).Error("background migration run failed")
+			continue
+		}
+		if res.Migration == "" {
+			l.Debug("no background migration to process")
+			continue
+		}
+		l.Info("background migration run complete")
+	}
+}
@@ -0,0 +1,378 @@
+package bbm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore"
+	storemock "github.com/docker/distribution/registry/datastore/mocks"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	okJobName   = "test_ok"
+	failJobName = "test_fail"
+)
+
+var (
+	errFakeJob = errors.New("fake job error")
+
+	// jobCalls records the ranges passed to the test jobs
+	jobCalls [][2]int64
+)
+
+func init() {
+	Register(okJobName, func(_ context.Context, _ datastore.Queryer, start, end int64) (int64, error) {
+		jobCalls = append(jobCalls, [2]int64{start, end})
+		return end - start + 1, nil
+	})
+	Register(failJobName, func(_ context.Context, _ datastore.Queryer, start, end int64) (int64, error) {
+		jobCalls = append(jobCalls, [2]int64{start, end})
+		return 0, errFakeJob
+	})
+}
+
+func stubClock(tb testing.TB, t time.Time) clock.Clock {
+	tb.Helper()
+
+	mock := clock.NewMock()
+	mock.Set(t)
+	testutil.StubClock(tb, &systemClock, mock)
+
+	return mock
+}
+
+func mockStore(tb testing.TB, ctrl *gomock.Controller) *storemock.MockBackgroundMigrationStore {
+	tb.Helper()
+
+	s := storemock.NewMockBackgroundMigrationStore(ctrl)
+	bkp := backgroundMigrationStoreConstructor
+	backgroundMigrationStoreConstructor = func(db datastore.Queryer) datastore.BackgroundMigrationStore { return s }
+	tb.Cleanup(func() { backgroundMigrationStoreConstructor = bkp })
+
+	jobCalls = nil
+
+	return s
+}
+
+func fakeMigration(jobName string) *models.BackgroundMigration {
+	return &models.BackgroundMigration{
+		ID:         1,
+		Name:       "backfill_foo",
+		JobName:    jobName,
+		TableName:  "manifests",
+		ColumnName: "id",
+		Status:     models.BackgroundMigrationActive,
+		BatchSize:  10,
+		MinValue:   1,
+		MaxValue:   25,
+		NextValue:  1,
+	}
+}
+
+// expectJobRun sets the expectations for the statements that wrap a batch job.
+func expectJobRun(txMock *storemock.MockTransactor, jobErr bool) *gomock.Call {
+	calls := []*gomock.Call{
+		txMock.EXPECT().ExecContext(gomock.Any(), "SET LOCAL statement_timeout = 30000").Return(nil, nil).Times(1),
+		txMock.EXPECT().ExecContext(gomock.Any(), "SAVEPOINT "+batchSavepoint).Return(nil, nil).Times(1),
+	}
+	if jobErr {
+		calls = append(calls, txMock.EXPECT().ExecContext(gomock.Any(), "ROLLBACK TO SAVEPOINT "+batchSavepoint).Return(nil, nil).Times(1))
+	}
+	gomock.InOrder(calls...)
+
+	return calls[len(calls)-1]
+}
+
+func TestNewWorker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	w := NewWorker(storemock.NewMockHandler(ctrl))
+	require.NotNil(t, w.logger)
+	require.Equal(t, defaultInterval, w.interval)
+	require.Equal(t, defaultMaxAttempts, w.maxAttempts)
+	require.Equal(t, defaultStatementTimeout, w.statementTimeout)
+}
+
+func TestNewWorker_WithOptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	logger := log.GetLogger(log.WithContext(context.Background()))
+	w := NewWorker(
+		storemock.NewMockHandler(ctrl),
+		WithLogger(logger),
+		WithInterval(time.Minute),
+		WithMaxAttempts(5),
+		WithStatementTimeout(time.Hour),
+	)
+	require.Equal(t, logger.WithFields(log.Fields{componentKey: workerName}), w.logger)
+	require.Equal(t, time.Minute, w.interval)
+	require.Equal(t, 5, w.maxAttempts)
+	require.Equal(t, time.Hour, w.statementTimeout)
+}
+
+func TestWorker_Run_BeginTxError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore(t, ctrl)
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(nil, errFakeJob).Times(1)
+
+	_, err := NewWorker(dbMock).Run(context.Background())
+	require.EqualError(t, err, "creating database transaction: fake job error")
+}
+
+func TestWorker_Run_None(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(nil, nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res, err := NewWorker(dbMock).Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &Result{}, res)
+	require.Empty(t, jobCalls)
+}
+
+func TestWorker_Run_Batch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	m := fakeMigration(okJobName)
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(m, nil).Times(1),
+		expectJobRun(txMock, false),
+		sMock.EXPECT().Update(gomock.Any(), m).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res, err := NewWorker(dbMock).Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &Result{Migration: m.Name, Start: 1, End: 10, Rows: 10}, res)
+	require.Equal(t, [][2]int64{{1, 10}}, jobCalls)
+
+	require.Equal(t, models.BackgroundMigrationActive, m.Status)
+	require.Equal(t, int64(11), m.NextValue)
+	require.Equal(t, int64(10), m.ProcessedRows)
+	require.Equal(t, sql.NullTime{Time: clockMock.Now(), Valid: true}, m.StartedAt)
+	require.False(t, m.FinishedAt.Valid)
+}
+
+func TestWorker_Run_LastBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	m := fakeMigration(okJobName)
+	m.NextValue = 21
+	m.ProcessedRows = 20
+	m.FailedAttempts = 1
+	m.ErrorMessage = sql.NullString{String: "foo", Valid: true}
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(m, nil).Times(1),
+		expectJobRun(txMock, false),
+		sMock.EXPECT().Update(gomock.Any(), m).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res, err := NewWorker(dbMock).Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &Result{Migration: m.Name, Start: 21, End: 25, Rows: 5, Finished: true}, res)
+	require.Equal(t, [][2]int64{{21, 25}}, jobCalls)
+
+	require.Equal(t, models.BackgroundMigrationFinished, m.Status)
+	require.Equal(t, int64(26), m.NextValue)
+	require.Equal(t, int64(25), m.ProcessedRows)
+	require.Zero(t, m.FailedAttempts)
+	require.False(t, m.ErrorMessage.Valid)
+	require.Equal(t, sql.NullTime{Time: clockMock.Now(), Valid: true}, m.FinishedAt)
+}
+
+func TestWorker_Run_EmptyRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+	stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	m := fakeMigration(okJobName)
+	m.MinValue, m.MaxValue, m.NextValue = 1, 0, 1
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(m, nil).Times(1),
+		sMock.EXPECT().Update(gomock.Any(), m).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res, err := NewWorker(dbMock).Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &Result{Migration: m.Name, Finished: true}, res)
+	require.Empty(t, jobCalls)
+	require.Equal(t, models.BackgroundMigrationFinished, m.Status)
+}
+
+func TestWorker_Run_JobError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+	stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	m := fakeMigration(failJobName)
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(m, nil).Times(1),
+		expectJobRun(txMock, true),
+		sMock.EXPECT().Update(gomock.Any(), m).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res, err := NewWorker(dbMock).Run(context.Background())
+	require.ErrorIs(t, err, errFakeJob)
+	require.Equal(t, &Result{Migration: m.Name, Start: 1, End: 10}, res)
+
+	// progress is unchanged, but the failure is recorded
+	require.Equal(t, models.BackgroundMigrationActive, m.Status)
+	require.Equal(t, int64(1), m.NextValue)
+	require.Zero(t, m.ProcessedRows)
+	require.Equal(t, 1, m.FailedAttempts)
+	require.Equal(t, sql.NullString{String: errFakeJob.Error(), Valid: true}, m.ErrorMessage)
+}
+
+func TestWorker_Run_JobError_MaxAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+	stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	m := fakeMigration(failJobName)
+	m.FailedAttempts = defaultMaxAttempts - 1
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(m, nil).Times(1),
+		expectJobRun(txMock, true),
+		sMock.EXPECT().Update(gomock.Any(), m).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res, err := NewWorker(dbMock).Run(context.Background())
+	require.ErrorIs(t, err, errFakeJob)
+	require.True(t, res.Failed)
+	require.Equal(t, models.BackgroundMigrationFailed, m.Status)
+	require.Equal(t, defaultMaxAttempts, m.FailedAttempts)
+}
+
+func TestWorker_Run_UnknownJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+	stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	m := fakeMigration("unknown")
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(m, nil).Times(1),
+		sMock.EXPECT().Update(gomock.Any(), m).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res, err := NewWorker(dbMock).Run(context.Background())
+	require.EqualError(t, err, `unknown background migration job "unknown"`)
+	require.True(t, res.Failed)
+	require.Equal(t, models.BackgroundMigrationFailed, m.Status)
+	require.Equal(t, `unknown background migration job "unknown"`, m.ErrorMessage.String)
+}
+
+func TestWorker_Run_SavepointError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+	stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	m := fakeMigration(okJobName)
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(m, nil).Times(1),
+		txMock.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(nil, nil).Times(1),
+		txMock.EXPECT().ExecContext(gomock.Any(), "SAVEPOINT "+batchSavepoint).Return(nil, errFakeJob).Times(1),
+		txMock.EXPECT().Rollback().Return(nil).Times(1),
+	)
+
+	_, err := NewWorker(dbMock).Run(context.Background())
+	require.EqualError(t, err, "creating savepoint: fake job error")
+	require.Empty(t, jobCalls)
+	require.Zero(t, m.FailedAttempts)
+}
+
+func TestWorker_Run_UpdateError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	sMock := mockStore(t, ctrl)
+	stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	m := fakeMigration(okJobName)
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		sMock.EXPECT().Next(gomock.Any()).Return(m, nil).Times(1),
+		expectJobRun(txMock, false),
+		sMock.EXPECT().Update(gomock.Any(), m).Return(errFakeJob).Times(1),
+		txMock.EXPECT().Rollback().Return(nil).Times(1),
+	)
+
+	_, err := NewWorker(dbMock).Run(context.Background())
+	require.ErrorIs(t, err, errFakeJob)
+}
+
+func TestErrorMessage(t *testing.T) {
+	msg := errorMessage(errors.New(strings.Repeat("a", maxErrorMessageLen+1)))
+	require.True(t, msg.Valid)
+	require.Len(t, msg.String, maxErrorMessageLen)
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	require.PanicsWithValue(t, `background migration job "test_ok" already registered`, func() {
+		Register(okJobName, nil)
+	})
+}
@@ -16,6 +16,7 @@ import (
 type BlobReader interface {
 	FindAll(ctx context.Context) (models.Blobs, error)
 	FindByDigest(ctx context.Context, d digest.Digest) (*models.Blob, error)
+	FindAllAfterDigest(ctx context.Context, d digest.Digest, limit int) (models.Blobs, error)
 	Count(ctx context.Context) (int, error)
 }
 
@@ -92,7 +93,7 @@ func scanFullBlobs(rows *sql.Rows) (models.Blobs, error) {
 
 // FindByDigest finds a blob by digest.
 func (s *blobStore) FindByDigest(ctx context.Context, d digest.Digest) (*models.Blob, error) {
-	defer metrics.InstrumentQuery("blob_find_by_digest")()
+	defer metrics.InstrumentQuery(ctx, "blob_find_by_digest")()
 	q := `SELECT
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
@@ -115,7 +116,7 @@ func (s *blobStore) FindByDigest(ctx context.Context, d digest.Digest) (*models.
 
 // FindAll finds all blobs.
 func (s *blobStore) FindAll(ctx context.Context) (models.Blobs, error) {
-	defer metrics.InstrumentQuery("blob_find_all")()
+	defer metrics.InstrumentQuery(ctx, "blob_find_all")()
 	q := `SELECT
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
@@ -132,9 +133,39 @@ func (s *blobStore) FindAll(ctx context.Context) (models.Blobs, error) {
 	return scanFullBlobs(rows)
 }
 
+// FindAllAfterDigest finds up to limit blobs whose digest sorts after d (exclusive), ordered by digest. Blobs are
+// sorted by the binary representation of their digest, so all SHA256 digests sort before SHA512 ones.
+func (s *blobStore) FindAllAfterDigest(ctx context.Context, d digest.Digest, limit int) (models.Blobs, error) {
+	defer metrics.InstrumentQuery(ctx, "blob_find_all_after_digest")()
+	q := `SELECT
+			mt.media_type,
+			encode(b.digest, 'hex') as digest,
+			b.size,
+			b.created_at
+		FROM
+			blobs AS b
+			JOIN media_types AS mt ON b.media_type_id = mt.id
+		WHERE
+			b.digest > decode($1, 'hex')
+		ORDER BY
+			b.digest
+		LIMIT $2`
+
+	dgst, err := NewDigest(d)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, q, dgst, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding blobs after digest: %w", err)
+	}
+
+	return scanFullBlobs(rows)
+}
+
 // Count counts all blobs.
 func (s *blobStore) Count(ctx context.Context) (int, error) {
-	defer metrics.InstrumentQuery("blob_count")()
+	defer metrics.InstrumentQuery(ctx, "blob_count")()
 	q := "SELECT COUNT(*) FROM blobs"
 	var count int
 
@@ -147,7 +178,7 @@ func (s *blobStore) Count(ctx context.Context) (int, error) {
 
 // Create saves a new blob.
 func (s *blobStore) Create(ctx context.Context, b *models.Blob) error {
-	defer metrics.InstrumentQuery("blob_create")()
+	defer metrics.InstrumentQuery(ctx, "blob_create")()
 	q := `INSERT INTO blobs (digest, media_type_id, size)
 			VALUES (decode($1, 'hex'), $2, $3)
 		RETURNING
@@ -174,7 +205,7 @@ func (s *blobStore) Create(ctx context.Context, b *models.Blob) error {
 // on write operations between the corresponding read (FindByDigest) and write (Create) operations. Separate Find* and
 // Create method calls should be preferred to this when race conditions are not a concern.
 func (s *blobStore) CreateOrFind(ctx context.Context, b *models.Blob) error {
-	defer metrics.InstrumentQuery("blob_create_or_find")()
+	defer metrics.InstrumentQuery(ctx, "blob_create_or_find")()
 	q := `INSERT INTO blobs (digest, media_type_id, size)
 			VALUES (decode($1, 'hex'), $2, $3)
 		ON CONFLICT (digest)
@@ -209,7 +240,7 @@ func (s *blobStore) CreateOrFind(ctx context.Context, b *models.Blob) error {
 
 // Delete deletes a blob.
 func (s *blobStore) Delete(ctx context.Context, d digest.Digest) error {
-	defer metrics.InstrumentQuery("blob_delete")()
+	defer metrics.InstrumentQuery(ctx, "blob_delete")()
 	q := "DELETE FROM blobs WHERE digest = decode($1, 'hex')"
 
 	dgst, err := NewDigest(d)
@@ -228,6 +228,33 @@ func TestBlobStore_All(t *testing.T) {
 	require.Equal(t, expected, bb)
 }
 
+func TestBlobStore_FindAllAfterDigest(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewBlobStore(suite.db)
+	// see testdata/fixtures/blobs.sql
+	bb, err := s.FindAllAfterDigest(suite.ctx, "sha256:0000000000000000000000000000000000000000000000000000000000000000", 2)
+	require.NoError(t, err)
+	require.Len(t, bb, 2)
+	require.Equal(t, digest.Digest("sha256:0159a862a1d3a25886b9f029af200f15a27bd0a5552b5861f34b1cb02cc14fb2"), bb[0].Digest)
+	require.Equal(t, digest.Digest("sha256:0a450fb93c7bd4ee53d05ba63842d6c2cf73089198cbaccc115d470e6ae2ffc9"), bb[1].Digest)
+
+	// the lower bound is exclusive
+	bb, err = s.FindAllAfterDigest(suite.ctx, bb[0].Digest, 1)
+	require.NoError(t, err)
+	require.Len(t, bb, 1)
+	require.Equal(t, digest.Digest("sha256:0a450fb93c7bd4ee53d05ba63842d6c2cf73089198cbaccc115d470e6ae2ffc9"), bb[0].Digest)
+}
+
+func TestBlobStore_FindAllAfterDigest_NotFound(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewBlobStore(suite.db)
+	bb, err := s.FindAllAfterDigest(suite.ctx, "sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 10)
+	require.NoError(t, err)
+	require.Empty(t, bb)
+}
+
 func TestBlobStore_All_NotFound(t *testing.T) {
 	unloadBlobFixtures(t)
 
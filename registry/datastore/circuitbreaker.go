@@ -0,0 +1,201 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+type circuitBreakerOpts struct {
+	logger            *logrus.Entry
+	checkInterval     time.Duration
+	latencyThreshold  time.Duration
+	failureThreshold  int
+	recoveryThreshold int
+	listeners         []func(open bool)
+}
+
+// CircuitBreakerOption is used to pass options to NewCircuitBreaker.
+type CircuitBreakerOption func(*circuitBreakerOpts)
+
+// WithCircuitBreakerLogger configures the logger for the circuit breaker.
+func WithCircuitBreakerLogger(l *logrus.Entry) CircuitBreakerOption {
+	return func(opts *circuitBreakerOpts) {
+		opts.logger = l
+	}
+}
+
+// WithCircuitBreakerCheckInterval configures how often the database is checked.
+func WithCircuitBreakerCheckInterval(d time.Duration) CircuitBreakerOption {
+	return func(opts *circuitBreakerOpts) {
+		opts.checkInterval = d
+	}
+}
+
+// WithCircuitBreakerLatencyThreshold configures the maximum duration of a check for it to be considered successful.
+func WithCircuitBreakerLatencyThreshold(d time.Duration) CircuitBreakerOption {
+	return func(opts *circuitBreakerOpts) {
+		opts.latencyThreshold = d
+	}
+}
+
+// WithCircuitBreakerThresholds configures the number of consecutive failed checks after which the circuit breaker
+// opens and the number of consecutive successful checks after which it closes again.
+func WithCircuitBreakerThresholds(failures, recoveries int) CircuitBreakerOption {
+	return func(opts *circuitBreakerOpts) {
+		opts.failureThreshold = failures
+		opts.recoveryThreshold = recoveries
+	}
+}
+
+// WithCircuitBreakerListener registers a function that is called with the new state whenever the circuit breaker
+// opens or closes. Listeners are called synchronously, so they should not block.
+func WithCircuitBreakerListener(fn func(open bool)) CircuitBreakerOption {
+	return func(opts *circuitBreakerOpts) {
+		opts.listeners = append(opts.listeners, fn)
+	}
+}
+
+func applyCircuitBreakerOptions(opts []CircuitBreakerOption) circuitBreakerOpts {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	config := circuitBreakerOpts{
+		logger:            logrus.NewEntry(log),
+		checkInterval:     5 * time.Second,
+		latencyThreshold:  time.Second,
+		failureThreshold:  3,
+		recoveryThreshold: 3,
+	}
+
+	for _, v := range opts {
+		v(&config)
+	}
+
+	return config
+}
+
+// CircuitBreaker periodically checks the responsiveness of a database and opens after a number of consecutive checks
+// fail or take longer than the configured latency threshold, signaling that the database is degraded. Once open, it
+// closes again after a number of consecutive successful checks. Callers can then avoid the database while the circuit
+// breaker is open, such as by serving requests from an alternative source.
+type CircuitBreaker struct {
+	db   Queryer
+	opts circuitBreakerOpts
+
+	mu        sync.Mutex
+	open      bool
+	failures  int
+	successes int
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewCircuitBreaker creates a new circuit breaker for the given database. The circuit breaker starts closed.
+func NewCircuitBreaker(db Queryer, opts ...CircuitBreakerOption) *CircuitBreaker {
+	return &CircuitBreaker{
+		db:   db,
+		opts: applyCircuitBreakerOptions(opts),
+		done: make(chan struct{}),
+	}
+}
+
+// IsOpen reports whether the circuit breaker is open, i.e., whether the database is considered degraded.
+func (cb *CircuitBreaker) IsOpen() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.open
+}
+
+// Check checks the database once, updating the state of the circuit breaker accordingly.
+func (cb *CircuitBreaker) Check(ctx context.Context) {
+	start := time.Now()
+	err := cb.ping(ctx)
+	cb.record(err, time.Since(start))
+}
+
+func (cb *CircuitBreaker) ping(ctx context.Context) error {
+	defer metrics.InstrumentQuery(ctx, "circuit_breaker_check")()
+
+	ctx, cancel := context.WithTimeout(ctx, cb.opts.latencyThreshold)
+	defer cancel()
+
+	var v int
+	if err := cb.db.QueryRowContext(ctx, "SELECT 1").Scan(&v); err != nil {
+		return fmt.Errorf("checking database: %w", err)
+	}
+
+	return nil
+}
+
+// record updates the state of the circuit breaker with the outcome of a check.
+func (cb *CircuitBreaker) record(err error, latency time.Duration) {
+	l := cb.opts.logger.WithFields(logrus.Fields{"latency_s": latency.Seconds()})
+	failed := err != nil || latency > cb.opts.latencyThreshold
+
+	cb.mu.Lock()
+	if failed {
+		cb.failures++
+		cb.successes = 0
+	} else {
+		cb.successes++
+		cb.failures = 0
+	}
+
+	var changed bool
+	switch {
+	case !cb.open && cb.failures >= cb.opts.failureThreshold:
+		cb.open, changed = true, true
+	case cb.open && cb.successes >= cb.opts.recoveryThreshold:
+		cb.open, changed = false, true
+	}
+	open := cb.open
+	cb.mu.Unlock()
+
+	if failed {
+		l.WithError(err).Debug("database check failed")
+	}
+	if !changed {
+		return
+	}
+
+	if open {
+		l.WithError(err).Warn("database is degraded, opening circuit breaker")
+	} else {
+		l.Info("database recovered, closing circuit breaker")
+	}
+	metrics.CircuitBreakerTransition(open)
+	for _, fn := range cb.opts.listeners {
+		fn(open)
+	}
+}
+
+// Start checks the database periodically, according to the configured interval, until ctx is done or the circuit
+// breaker is closed. This method blocks and should be run in a goroutine.
+func (cb *CircuitBreaker) Start(ctx context.Context) {
+	t := time.NewTicker(cb.opts.checkInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cb.done:
+			return
+		case <-t.C:
+			cb.Check(ctx)
+		}
+	}
+}
+
+// Close stops the periodic checks started with Start.
+func (cb *CircuitBreaker) Close() {
+	cb.closeOnce.Do(func() { close(cb.done) })
+}
@@ -0,0 +1,95 @@
+package datastore_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+var circuitBreakerCheckQuery = regexp.QuoteMeta("SELECT 1")
+
+func expectCircuitBreakerCheck(mock sqlmock.Sqlmock, err error, delay time.Duration) {
+	e := mock.ExpectQuery(circuitBreakerCheckQuery).WillDelayFor(delay)
+	if err != nil {
+		e.WillReturnError(err)
+		return
+	}
+	e.WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	db, mock := mockDB(t)
+
+	var transitions []bool
+	cb := datastore.NewCircuitBreaker(db,
+		datastore.WithCircuitBreakerThresholds(2, 2),
+		datastore.WithCircuitBreakerLatencyThreshold(50*time.Millisecond),
+		datastore.WithCircuitBreakerListener(func(open bool) { transitions = append(transitions, open) }),
+	)
+	ctx := context.Background()
+
+	require.False(t, cb.IsOpen())
+
+	// a failure followed by a success does not open the circuit breaker
+	expectCircuitBreakerCheck(mock, errors.New("database down"), 0)
+	cb.Check(ctx)
+	expectCircuitBreakerCheck(mock, nil, 0)
+	cb.Check(ctx)
+	require.False(t, cb.IsOpen())
+
+	// consecutive errors and slow checks open the circuit breaker
+	expectCircuitBreakerCheck(mock, errors.New("database down"), 0)
+	cb.Check(ctx)
+	require.False(t, cb.IsOpen())
+	expectCircuitBreakerCheck(mock, nil, 100*time.Millisecond)
+	cb.Check(ctx)
+	require.True(t, cb.IsOpen())
+
+	// further failures keep it open without new transitions
+	expectCircuitBreakerCheck(mock, errors.New("database down"), 0)
+	cb.Check(ctx)
+	require.True(t, cb.IsOpen())
+
+	// consecutive successful checks close it again
+	expectCircuitBreakerCheck(mock, nil, 0)
+	cb.Check(ctx)
+	require.True(t, cb.IsOpen())
+	expectCircuitBreakerCheck(mock, nil, 0)
+	cb.Check(ctx)
+	require.False(t, cb.IsOpen())
+
+	require.Equal(t, []bool{true, false}, transitions)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCircuitBreaker_Start(t *testing.T) {
+	db, mock := mockDB(t)
+
+	opened := make(chan struct{})
+	cb := datastore.NewCircuitBreaker(db,
+		datastore.WithCircuitBreakerThresholds(1, 1),
+		datastore.WithCircuitBreakerCheckInterval(10*time.Millisecond),
+		datastore.WithCircuitBreakerListener(func(open bool) {
+			if open {
+				close(opened)
+			}
+		}),
+	)
+
+	expectCircuitBreakerCheck(mock, errors.New("database down"), 0)
+	go cb.Start(context.Background())
+	t.Cleanup(cb.Close)
+
+	select {
+	case <-opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the circuit breaker to open")
+	}
+	require.True(t, cb.IsOpen())
+}
@@ -0,0 +1,199 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// CleanupPolicyStore is the interface that a cleanup policy store should conform to.
+type CleanupPolicyStore interface {
+	FindByRepository(ctx context.Context, r *models.Repository) (*models.CleanupPolicy, error)
+	CreateOrUpdate(ctx context.Context, p *models.CleanupPolicy) error
+	DeleteByRepository(ctx context.Context, r *models.Repository) (bool, error)
+	ClaimNextDue(ctx context.Context) (*models.CleanupPolicy, error)
+	UpdateLastRunAt(ctx context.Context, p *models.CleanupPolicy) error
+}
+
+// cleanupPolicyStore is the concrete implementation of a CleanupPolicyStore.
+type cleanupPolicyStore struct {
+	db Queryer
+}
+
+// NewCleanupPolicyStore builds a new cleanup policy store.
+func NewCleanupPolicyStore(db Queryer) CleanupPolicyStore {
+	return &cleanupPolicyStore{db: db}
+}
+
+const cleanupPolicyColumns = `cp.top_level_namespace_id,
+			cp.repository_id,
+			cp.enabled,
+			EXTRACT(epoch FROM cp.cadence)::bigint,
+			cp.name_regex_delete,
+			COALESCE(cp.name_regex_keep, ''),
+			cp.keep_n,
+			EXTRACT(epoch FROM cp.older_than)::bigint,
+			cp.next_run_at,
+			cp.last_run_at,
+			cp.created_at,
+			cp.updated_at`
+
+func scanCleanupPolicy(row *sql.Row, extra ...any) (*models.CleanupPolicy, error) {
+	p := new(models.CleanupPolicy)
+	var cadence, olderThan int64
+
+	dest := []any{
+		&p.NamespaceID, &p.RepositoryID, &p.Enabled, &cadence, &p.NameRegexDelete, &p.NameRegexKeep, &p.KeepN,
+		&olderThan, &p.NextRunAt, &p.LastRunAt, &p.CreatedAt, &p.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("scanning cleanup policy: %w", err)
+		}
+		return nil, nil
+	}
+	p.Cadence = time.Duration(cadence) * time.Second
+	p.OlderThan = time.Duration(olderThan) * time.Second
+
+	return p, nil
+}
+
+// FindByRepository finds the cleanup policy of a given repository. Returns nil if the repository has no policy.
+func (s *cleanupPolicyStore) FindByRepository(ctx context.Context, r *models.Repository) (*models.CleanupPolicy, error) {
+	defer metrics.InstrumentQuery(ctx, "cleanup_policy_find_by_repository")()
+	q := `SELECT
+			` + cleanupPolicyColumns + `
+		FROM
+			cleanup_policies AS cp
+		WHERE
+			cp.top_level_namespace_id = $1
+			AND cp.repository_id = $2`
+
+	return scanCleanupPolicy(s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID))
+}
+
+// CreateOrUpdate creates the cleanup policy of a repository or replaces the existing one. The first run is scheduled
+// one cadence after the policy is created. Existing policies are rescheduled likewise if their cadence changes or if
+// they are re-enabled, otherwise their next run is preserved.
+func (s *cleanupPolicyStore) CreateOrUpdate(ctx context.Context, p *models.CleanupPolicy) error {
+	defer metrics.InstrumentQuery(ctx, "cleanup_policy_create_or_update")()
+	q := `INSERT INTO cleanup_policies AS cp (top_level_namespace_id, repository_id, enabled, cadence, name_regex_delete,
+				name_regex_keep, keep_n, older_than, next_run_at)
+			VALUES ($1, $2, $3, make_interval(secs => $4), $5, NULLIF($6, ''), $7, make_interval(secs => $8),
+				now() + make_interval(secs => $4))
+		ON CONFLICT (top_level_namespace_id, repository_id)
+			DO UPDATE SET
+				enabled = EXCLUDED.enabled,
+				cadence = EXCLUDED.cadence,
+				name_regex_delete = EXCLUDED.name_regex_delete,
+				name_regex_keep = EXCLUDED.name_regex_keep,
+				keep_n = EXCLUDED.keep_n,
+				older_than = EXCLUDED.older_than,
+				next_run_at = CASE WHEN cp.cadence <> EXCLUDED.cadence
+					OR NOT cp.enabled THEN
+					EXCLUDED.next_run_at
+				ELSE
+					cp.next_run_at
+				END,
+				updated_at = now()
+		RETURNING
+			next_run_at, last_run_at, created_at, updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, p.NamespaceID, p.RepositoryID, p.Enabled, p.Cadence.Seconds(), p.NameRegexDelete,
+		p.NameRegexKeep, p.KeepN, p.OlderThan.Seconds())
+	if err := row.Scan(&p.NextRunAt, &p.LastRunAt, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return fmt.Errorf("creating or updating cleanup policy: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteByRepository deletes the cleanup policy of a given repository. A boolean is returned to denote whether the
+// policy was deleted or not. This avoids the need for a separate preceding `SELECT` to find if it exists.
+func (s *cleanupPolicyStore) DeleteByRepository(ctx context.Context, r *models.Repository) (bool, error) {
+	defer metrics.InstrumentQuery(ctx, "cleanup_policy_delete_by_repository")()
+	q := `DELETE FROM cleanup_policies
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	res, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID)
+	if err != nil {
+		return false, fmt.Errorf("deleting cleanup policy: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("deleting cleanup policy: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+// ClaimNextDue finds the enabled cleanup policy that has been due the longest and schedules its next run, one cadence
+// from now, so that it is not claimed again until then. Policies being claimed concurrently are skipped, allowing
+// multiple registry instances to cooperate. Returns nil if no policy is due.
+func (s *cleanupPolicyStore) ClaimNextDue(ctx context.Context) (*models.CleanupPolicy, error) {
+	defer metrics.InstrumentQuery(ctx, "cleanup_policy_claim_next_due")()
+	q := `UPDATE
+			cleanup_policies AS cp
+		SET
+			next_run_at = now() + cp.cadence
+		FROM
+			repositories AS r
+		WHERE (cp.top_level_namespace_id, cp.repository_id) = (
+				SELECT
+					top_level_namespace_id,
+					repository_id
+				FROM
+					cleanup_policies
+				WHERE
+					enabled
+					AND next_run_at <= now()
+				ORDER BY
+					next_run_at
+				LIMIT 1
+				FOR UPDATE
+					SKIP LOCKED)
+			AND r.top_level_namespace_id = cp.top_level_namespace_id
+			AND r.id = cp.repository_id
+		RETURNING
+			` + cleanupPolicyColumns + `,
+			r.path`
+
+	var path string
+	p, err := scanCleanupPolicy(s.db.QueryRowContext(ctx, q), &path)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	p.RepositoryPath = path
+
+	return p, nil
+}
+
+// UpdateLastRunAt records that the given cleanup policy was just executed.
+func (s *cleanupPolicyStore) UpdateLastRunAt(ctx context.Context, p *models.CleanupPolicy) error {
+	defer metrics.InstrumentQuery(ctx, "cleanup_policy_update_last_run_at")()
+	q := `UPDATE
+			cleanup_policies
+		SET
+			last_run_at = now()
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+		RETURNING
+			last_run_at`
+
+	if err := s.db.QueryRowContext(ctx, q, p.NamespaceID, p.RepositoryID).Scan(&p.LastRunAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the policy was deleted in the meantime
+			return nil
+		}
+		return fmt.Errorf("updating cleanup policy last run at: %w", err)
+	}
+
+	return nil
+}
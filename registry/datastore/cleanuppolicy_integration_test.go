@@ -0,0 +1,161 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadCleanupPolicyFixtures(tb testing.TB) {
+	tb.Helper()
+
+	reloadRepositoryFixtures(tb)
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.CleanupPoliciesTable))
+}
+
+// makeCleanupPolicyDue moves the next run of the cleanup policy of a given repository to the past.
+func makeCleanupPolicyDue(t *testing.T, r *models.Repository, ago time.Duration) {
+	t.Helper()
+
+	_, err := suite.db.ExecContext(suite.ctx, `UPDATE cleanup_policies SET next_run_at = now() - make_interval(secs => $3)
+		WHERE top_level_namespace_id = $1 AND repository_id = $2`, r.NamespaceID, r.ID, ago.Seconds())
+	require.NoError(t, err)
+}
+
+func TestCleanupPolicyStore_CreateOrUpdate(t *testing.T) {
+	reloadCleanupPolicyFixtures(t)
+
+	s := datastore.NewCleanupPolicyStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	p, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	// create
+	p = &models.CleanupPolicy{
+		NamespaceID:     r.NamespaceID,
+		RepositoryID:    r.ID,
+		Enabled:         true,
+		Cadence:         24 * time.Hour,
+		NameRegexDelete: ".*",
+		NameRegexKeep:   "v.+",
+		KeepN:           5,
+		OlderThan:       14 * 24 * time.Hour,
+	}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, p))
+	require.NotZero(t, p.CreatedAt)
+	require.False(t, p.UpdatedAt.Valid)
+	require.False(t, p.LastRunAt.Valid)
+	require.WithinDuration(t, p.CreatedAt.Add(p.Cadence), p.NextRunAt, time.Second)
+
+	got, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, p.Cadence, got.Cadence)
+	require.Equal(t, p.OlderThan, got.OlderThan)
+	require.Equal(t, p.NameRegexDelete, got.NameRegexDelete)
+	require.Equal(t, p.NameRegexKeep, got.NameRegexKeep)
+	require.Equal(t, p.KeepN, got.KeepN)
+	require.True(t, got.Enabled)
+
+	// updates without changing the cadence do not reschedule the policy
+	nextRunAt := p.NextRunAt
+	p.KeepN = 10
+	p.NameRegexKeep = ""
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, p))
+	require.True(t, p.UpdatedAt.Valid)
+	require.True(t, nextRunAt.Equal(p.NextRunAt))
+
+	got, err = s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, 10, got.KeepN)
+	require.Empty(t, got.NameRegexKeep)
+
+	// changing the cadence does
+	p.Cadence = time.Hour
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, p))
+	require.True(t, p.NextRunAt.Before(nextRunAt))
+
+	// other repositories are not affected
+	got, err = s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestCleanupPolicyStore_DeleteByRepository(t *testing.T) {
+	reloadCleanupPolicyFixtures(t)
+
+	s := datastore.NewCleanupPolicyStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	found, err := s.DeleteByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	p := &models.CleanupPolicy{NamespaceID: 1, RepositoryID: 3, Enabled: true, Cadence: time.Hour, NameRegexDelete: ".*"}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, p))
+
+	found, err = s.DeleteByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	got, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestCleanupPolicyStore_ClaimNextDue(t *testing.T) {
+	reloadCleanupPolicyFixtures(t)
+
+	s := datastore.NewCleanupPolicyStore(suite.db)
+	r3 := &models.Repository{NamespaceID: 1, ID: 3}
+	r4 := &models.Repository{NamespaceID: 1, ID: 4}
+	r6 := &models.Repository{NamespaceID: 2, ID: 6}
+
+	for _, r := range []*models.Repository{r3, r4, r6} {
+		p := &models.CleanupPolicy{NamespaceID: r.NamespaceID, RepositoryID: r.ID, Enabled: true, Cadence: time.Hour, NameRegexDelete: ".*"}
+		require.NoError(t, s.CreateOrUpdate(suite.ctx, p))
+	}
+
+	// nothing is due yet
+	p, err := s.ClaimNextDue(suite.ctx)
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	// disabled policies are never due
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, &models.CleanupPolicy{
+		NamespaceID: r6.NamespaceID, RepositoryID: r6.ID, Enabled: false, Cadence: time.Hour, NameRegexDelete: ".*",
+	}))
+	makeCleanupPolicyDue(t, r3, time.Minute)
+	makeCleanupPolicyDue(t, r4, time.Hour)
+	makeCleanupPolicyDue(t, r6, 2*time.Hour)
+
+	// the policy due the longest is claimed first
+	p, err = s.ClaimNextDue(suite.ctx)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, r4.ID, p.RepositoryID)
+	require.Equal(t, "gitlab-org/gitlab-test/frontend", p.RepositoryPath)
+	require.True(t, p.NextRunAt.After(time.Now()))
+
+	p, err = s.ClaimNextDue(suite.ctx)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, r3.ID, p.RepositoryID)
+
+	require.NoError(t, s.UpdateLastRunAt(suite.ctx, p))
+	got, err := s.FindByRepository(suite.ctx, r3)
+	require.NoError(t, err)
+	require.True(t, got.LastRunAt.Valid)
+
+	// claimed policies are rescheduled
+	p, err = s.ClaimNextDue(suite.ctx)
+	require.NoError(t, err)
+	require.Nil(t, p)
+}
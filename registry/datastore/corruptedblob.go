@@ -0,0 +1,153 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+)
+
+// CorruptedBlobStore is the interface that a corrupted blob store should conform to. It records the blobs whose
+// content in storage failed an integrity verification.
+type CorruptedBlobStore interface {
+	// Record records b as corrupted. If b was already recorded, its actual digest, reason and last check time are
+	// updated, keeping the time at which the corruption was first detected. The detection and check times of b are
+	// populated.
+	Record(ctx context.Context, b *models.CorruptedBlob) error
+	// Delete deletes the record of the blob with digest d, if any, returning true if it existed.
+	Delete(ctx context.Context, d digest.Digest) (bool, error)
+	// FindAll finds up to limit corrupted blobs, most recently detected first.
+	FindAll(ctx context.Context, limit int) ([]*models.CorruptedBlob, error)
+	// Count counts all corrupted blobs.
+	Count(ctx context.Context) (int, error)
+}
+
+type corruptedBlobStore struct {
+	db Queryer
+}
+
+// NewCorruptedBlobStore builds a new corruptedBlobStore.
+func NewCorruptedBlobStore(db Queryer) CorruptedBlobStore {
+	return &corruptedBlobStore{db: db}
+}
+
+// Record records b as corrupted. If b was already recorded, its actual digest, reason and last check time are updated,
+// keeping the time at which the corruption was first detected. The detection and check times of b are populated.
+func (s *corruptedBlobStore) Record(ctx context.Context, b *models.CorruptedBlob) error {
+	defer metrics.InstrumentQuery(ctx, "corrupted_blob_record")()
+
+	q := `INSERT INTO corrupted_blobs (digest, actual_digest, size, reason)
+			VALUES (decode($1, 'hex'), decode(NULLIF($2, ''), 'hex'), $3, $4)
+		ON CONFLICT (digest)
+			DO UPDATE SET
+				actual_digest = EXCLUDED.actual_digest, size = EXCLUDED.size, reason = EXCLUDED.reason, checked_at = now()
+		RETURNING
+			detected_at, checked_at`
+
+	dgst, err := NewDigest(b.Digest)
+	if err != nil {
+		return err
+	}
+	var actual Digest
+	if b.ActualDigest != "" {
+		if actual, err = NewDigest(b.ActualDigest); err != nil {
+			return err
+		}
+	}
+
+	row := s.db.QueryRowContext(ctx, q, dgst, actual, b.Size, b.Reason)
+	if err := row.Scan(&b.DetectedAt, &b.CheckedAt); err != nil {
+		return fmt.Errorf("recording corrupted blob: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes the record of the blob with digest d, if any, returning true if it existed.
+func (s *corruptedBlobStore) Delete(ctx context.Context, d digest.Digest) (bool, error) {
+	defer metrics.InstrumentQuery(ctx, "corrupted_blob_delete")()
+
+	q := "DELETE FROM corrupted_blobs WHERE digest = decode($1, 'hex')"
+
+	dgst, err := NewDigest(d)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.db.ExecContext(ctx, q, dgst)
+	if err != nil {
+		return false, fmt.Errorf("deleting corrupted blob: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("deleting corrupted blob: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// FindAll finds up to limit corrupted blobs, most recently detected first.
+func (s *corruptedBlobStore) FindAll(ctx context.Context, limit int) ([]*models.CorruptedBlob, error) {
+	defer metrics.InstrumentQuery(ctx, "corrupted_blob_find_all")()
+
+	q := `SELECT
+			encode(digest, 'hex'),
+			encode(actual_digest, 'hex'),
+			size,
+			reason,
+			detected_at,
+			checked_at
+		FROM
+			corrupted_blobs
+		ORDER BY
+			detected_at DESC,
+			digest
+		LIMIT $1`
+
+	rows, err := s.db.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding corrupted blobs: %w", err)
+	}
+	defer rows.Close()
+
+	bb := make([]*models.CorruptedBlob, 0)
+	for rows.Next() {
+		var dgst Digest
+		var actual sql.NullString
+		b := new(models.CorruptedBlob)
+
+		if err := rows.Scan(&dgst, &actual, &b.Size, &b.Reason, &b.DetectedAt, &b.CheckedAt); err != nil {
+			return nil, fmt.Errorf("scanning corrupted blob: %w", err)
+		}
+		if b.Digest, err = dgst.Parse(); err != nil {
+			return nil, err
+		}
+		if actual.Valid {
+			if b.ActualDigest, err = Digest(actual.String).Parse(); err != nil {
+				return nil, err
+			}
+		}
+		bb = append(bb, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning corrupted blobs: %w", err)
+	}
+
+	return bb, nil
+}
+
+// Count counts all corrupted blobs.
+func (s *corruptedBlobStore) Count(ctx context.Context) (int, error) {
+	defer metrics.InstrumentQuery(ctx, "corrupted_blob_count")()
+
+	q := "SELECT COUNT(*) FROM corrupted_blobs"
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting corrupted blobs: %w", err)
+	}
+
+	return count, nil
+}
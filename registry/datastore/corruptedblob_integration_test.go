@@ -0,0 +1,133 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadCorruptedBlobFixtures(tb testing.TB) {
+	tb.Helper()
+
+	reloadBlobFixtures(tb)
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.CorruptedBlobsTable))
+}
+
+func TestCorruptedBlobStore_Record(t *testing.T) {
+	reloadCorruptedBlobFixtures(t)
+
+	s := datastore.NewCorruptedBlobStore(suite.db)
+
+	// see testdata/fixtures/blobs.sql
+	b := &models.CorruptedBlob{
+		Digest:       "sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+		ActualDigest: "sha256:1d9136cd62c9b60083de7763cfac547b1e571d10648393ade10325055a810556",
+		Size:         2802957,
+		Reason:       "digest_mismatch",
+	}
+	require.NoError(t, s.Record(suite.ctx, b))
+	require.NotZero(t, b.DetectedAt)
+	require.Equal(t, b.DetectedAt, b.CheckedAt)
+
+	bb, err := s.FindAll(suite.ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []*models.CorruptedBlob{b}, bb)
+
+	// recording again keeps the detection time
+	detectedAt := b.DetectedAt
+	b = &models.CorruptedBlob{
+		Digest: b.Digest,
+		Size:   b.Size,
+		Reason: "missing",
+	}
+	require.NoError(t, s.Record(suite.ctx, b))
+	require.Equal(t, detectedAt, b.DetectedAt)
+	require.True(t, b.CheckedAt.After(b.DetectedAt))
+
+	bb, err = s.FindAll(suite.ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []*models.CorruptedBlob{b}, bb)
+	require.Empty(t, bb[0].ActualDigest)
+}
+
+func TestCorruptedBlobStore_Record_UnknownBlob(t *testing.T) {
+	reloadCorruptedBlobFixtures(t)
+
+	s := datastore.NewCorruptedBlobStore(suite.db)
+	b := &models.CorruptedBlob{
+		Digest: "sha256:b9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+		Size:   1,
+		Reason: "missing",
+	}
+	require.Error(t, s.Record(suite.ctx, b))
+}
+
+func TestCorruptedBlobStore_FindAll_And_Count(t *testing.T) {
+	reloadCorruptedBlobFixtures(t)
+
+	s := datastore.NewCorruptedBlobStore(suite.db)
+
+	count, err := s.Count(suite.ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// see testdata/fixtures/blobs.sql
+	dgsts := []digest.Digest{
+		"sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+		"sha256:6b0937e234ce911b75630b744fb12836fe01bda5f7db203927edbb1390bc7e21",
+		"sha256:a0696058fc76fe6f456289f5611efe5c3411814e686f59f28b2e2069ed9e7d28",
+	}
+	for _, dgst := range dgsts {
+		require.NoError(t, s.Record(suite.ctx, &models.CorruptedBlob{Digest: dgst, Reason: "missing"}))
+	}
+
+	count, err = s.Count(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, len(dgsts), count)
+
+	bb, err := s.FindAll(suite.ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, bb, 2)
+	require.False(t, bb[0].DetectedAt.Before(bb[1].DetectedAt))
+}
+
+func TestCorruptedBlobStore_Delete(t *testing.T) {
+	reloadCorruptedBlobFixtures(t)
+
+	s := datastore.NewCorruptedBlobStore(suite.db)
+	dgst := digest.Digest("sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9")
+	require.NoError(t, s.Record(suite.ctx, &models.CorruptedBlob{Digest: dgst, Reason: "missing"}))
+
+	found, err := s.Delete(suite.ctx, dgst)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	found, err = s.Delete(suite.ctx, dgst)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	count, err := s.Count(suite.ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestCorruptedBlobStore_CascadeOnBlobDelete(t *testing.T) {
+	reloadCorruptedBlobFixtures(t)
+
+	s := datastore.NewCorruptedBlobStore(suite.db)
+	dgst := digest.Digest("sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9")
+	require.NoError(t, s.Record(suite.ctx, &models.CorruptedBlob{Digest: dgst, Reason: "missing"}))
+
+	require.NoError(t, datastore.NewBlobStore(suite.db).Delete(suite.ctx, dgst))
+
+	count, err := s.Count(suite.ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
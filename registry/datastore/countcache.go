@@ -0,0 +1,92 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/log"
+	gocache "github.com/eko/gocache/lib/v4/cache"
+	libstore "github.com/eko/gocache/lib/v4/store"
+	"github.com/opencontainers/go-digest"
+	"github.com/redis/go-redis/v9"
+)
+
+// CountCache is a cache for the total number of entries of paginated lists, such as the repository catalog or the tags
+// of a repository. Counts are identified by an arbitrary key, which must uniquely identify the list and the filters
+// applied to it. As there is no invalidation, cached counts are eventually consistent within the cache expiration.
+type CountCache interface {
+	Get(ctx context.Context, key string) (int, bool)
+	Set(ctx context.Context, key string, count int)
+}
+
+// noOpCountCache satisfies the CountCache, but does not cache anything. Useful as a default and for testing.
+type noOpCountCache struct{}
+
+// NewNoOpCountCache creates a new non-operational cache for list counts. This implementation does nothing and returns
+// nothing for all its methods.
+func NewNoOpCountCache() *noOpCountCache {
+	return &noOpCountCache{}
+}
+
+func (n *noOpCountCache) Get(context.Context, string) (int, bool) { return 0, false }
+func (n *noOpCountCache) Set(context.Context, string, int)        {}
+
+// centralCountCache is the interface for the centralized list count cache backed by Redis.
+type centralCountCache struct {
+	// cache provides access to the raw gocache interface
+	cache *gocache.Cache[any]
+	// ttl is the expiration applied to all cached counts.
+	ttl time.Duration
+}
+
+// NewCentralCountCache creates an interface for the centralized list count cache backed by Redis. All cached counts
+// expire after ttl.
+func NewCentralCountCache(cache *gocache.Cache[any], ttl time.Duration) *centralCountCache {
+	return &centralCountCache{cache, ttl}
+}
+
+// key generates a valid Redis key string for a given count key. The used key format is described in
+// https://gitlab.com/gitlab-org/container-registry/-/blob/master/docs-gitlab/redis-dev-guidelines.md#key-format.
+func (c *centralCountCache) key(key string) string {
+	return fmt.Sprintf("registry:api:{count:%s}", digest.FromString(key).Hex())
+}
+
+// Get implements CountCache. False is returned if the count is not cached.
+func (c *centralCountCache) Get(ctx context.Context, key string) (int, bool) {
+	getCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	tmp, err := c.cache.Get(getCtx, c.key(key))
+	if err != nil {
+		// a wrapped redis.Nil is returned when the key is not found in Redis
+		if !errors.Is(err, redis.Nil) {
+			log.GetLogger(log.WithContext(ctx)).WithError(err).Error("failed to read count from cache")
+		}
+		return 0, false
+	}
+
+	s, ok := tmp.(string)
+	if !ok {
+		return 0, false
+	}
+	count, err := strconv.Atoi(s)
+	if err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to parse count from cache")
+		return 0, false
+	}
+
+	return count, true
+}
+
+// Set implements CountCache.
+func (c *centralCountCache) Set(ctx context.Context, key string, count int) {
+	setCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	if err := c.cache.Set(setCtx, c.key(key), strconv.Itoa(count), libstore.WithExpiration(c.ttl)); err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to write count to cache")
+	}
+}
@@ -0,0 +1,50 @@
+package datastore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCentralCountCache(t *testing.T) {
+	ttl := 30 * time.Second
+	c := testutil.NewRedisCacheController(t, 0)
+	cache := datastore.NewCentralCountCache(c.Cache, ttl)
+	ctx := context.Background()
+
+	_, ok := cache.Get(ctx, "tags:1:4")
+	require.False(t, ok)
+
+	cache.Set(ctx, "tags:1:4", 42)
+
+	key := "registry:api:{count:fa86fccdee0105a88ff7a91be0f2ef7d7a5a2de2d621d3b365adaa4f3bb3fd80}"
+	require.True(t, c.Exists(key))
+	require.Equal(t, ttl, c.TTL(key))
+
+	count, ok := cache.Get(ctx, "tags:1:4")
+	require.True(t, ok)
+	require.Equal(t, 42, count)
+
+	// counts are stored per key
+	_, ok = cache.Get(ctx, "tags:1:5")
+	require.False(t, ok)
+
+	// zero counts are cached as well
+	cache.Set(ctx, "tags:1:5", 0)
+	count, ok = cache.Get(ctx, "tags:1:5")
+	require.True(t, ok)
+	require.Zero(t, count)
+}
+
+func TestNoOpCountCache(t *testing.T) {
+	cache := datastore.NewNoOpCountCache()
+	ctx := context.Background()
+
+	cache.Set(ctx, "tags:1:4", 42)
+	_, ok := cache.Get(ctx, "tags:1:4")
+	require.False(t, ok)
+}
@@ -56,6 +56,14 @@ func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (Transactor, err
 	return &Tx{tx}, err
 }
 
+// Address returns the host:port of the database server, or an empty string if unknown.
+func (db *DB) Address() string {
+	if db.dsn == nil {
+		return ""
+	}
+	return db.dsn.Address()
+}
+
 // Begin wraps sql.Tx from the inner sql.DB within a datastore.Tx.
 func (db *DB) Begin() (Transactor, error) {
 	return db.BeginTx(context.Background(), nil)
@@ -0,0 +1,208 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// Supported intervals for DeduplicationStore.History.
+const (
+	DeduplicationIntervalDay   = "day"
+	DeduplicationIntervalWeek  = "week"
+	DeduplicationIntervalMonth = "month"
+)
+
+// DeduplicationStore is the interface that defines reporting operations on blob deduplication. All of them aggregate
+// over every blob and repository blob link, and are therefore expensive on large registries.
+type DeduplicationStore interface {
+	// Stats returns the current deduplication stats.
+	Stats(ctx context.Context) (*models.DeduplicationStats, error)
+	// TopSharedBlobs returns up to limit blobs linked to more than one repository, sorted by saved bytes, in
+	// descending order.
+	TopSharedBlobs(ctx context.Context, limit int) ([]*models.SharedBlob, error)
+	// History returns the deduplication stats at the end of the last limit periods with any blob or link created in
+	// them, in chronological order. Periods are of the given interval, one of DeduplicationIntervalDay,
+	// DeduplicationIntervalWeek or DeduplicationIntervalMonth. Stats are derived from the creation time of the
+	// currently stored blobs and links, so past deletions are not accounted for.
+	History(ctx context.Context, interval string, limit int) ([]*models.DeduplicationSnapshot, error)
+}
+
+// deduplicationStore is the concrete implementation of a DeduplicationStore.
+type deduplicationStore struct {
+	// db can be either a *sql.DB or *sql.Tx
+	db Queryer
+}
+
+// NewDeduplicationStore builds a new deduplicationStore.
+func NewDeduplicationStore(db Queryer) DeduplicationStore {
+	return &deduplicationStore{db: db}
+}
+
+// Stats implements DeduplicationStore.
+func (s *deduplicationStore) Stats(ctx context.Context) (*models.DeduplicationStats, error) {
+	defer metrics.InstrumentQuery(ctx, "deduplication_stats")()
+	q := `SELECT
+			u.blobs,
+			u.bytes,
+			l.blobs,
+			l.bytes
+		FROM (
+			SELECT
+				COUNT(*) AS blobs,
+				COALESCE(SUM(size), 0) AS bytes
+			FROM
+				blobs) AS u,
+			(
+				SELECT
+					COUNT(*) AS blobs,
+					COALESCE(SUM(b.size), 0) AS bytes
+				FROM
+					repository_blobs AS rb
+					JOIN blobs AS b ON b.digest = rb.blob_digest) AS l`
+
+	st := new(models.DeduplicationStats)
+	if err := s.db.QueryRowContext(ctx, q).Scan(&st.UniqueBlobs, &st.UniqueBytes, &st.LinkedBlobs, &st.LinkedBytes); err != nil {
+		return nil, fmt.Errorf("calculating deduplication stats: %w", err)
+	}
+
+	return st, nil
+}
+
+// TopSharedBlobs implements DeduplicationStore.
+func (s *deduplicationStore) TopSharedBlobs(ctx context.Context, limit int) ([]*models.SharedBlob, error) {
+	defer metrics.InstrumentQuery(ctx, "deduplication_top_shared_blobs")()
+	q := `SELECT
+			mt.media_type,
+			encode(b.digest, 'hex') AS digest,
+			b.size,
+			rb.repositories
+		FROM (
+			SELECT
+				blob_digest,
+				COUNT(*) AS repositories
+			FROM
+				repository_blobs
+			GROUP BY
+				blob_digest
+			HAVING
+				COUNT(*) > 1) AS rb
+			JOIN blobs AS b ON b.digest = rb.blob_digest
+			JOIN media_types AS mt ON mt.id = b.media_type_id
+		ORDER BY
+			b.size * (rb.repositories - 1) DESC,
+			b.digest
+		LIMIT $1`
+
+	rows, err := s.db.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding top shared blobs: %w", err)
+	}
+	defer rows.Close()
+
+	bb := make([]*models.SharedBlob, 0)
+	for rows.Next() {
+		var dgst Digest
+		b := new(models.SharedBlob)
+		if err := rows.Scan(&b.MediaType, &dgst, &b.Size, &b.Repositories); err != nil {
+			return nil, fmt.Errorf("scanning shared blob: %w", err)
+		}
+		d, err := dgst.Parse()
+		if err != nil {
+			return nil, err
+		}
+		b.Digest = d
+		bb = append(bb, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning shared blobs: %w", err)
+	}
+
+	return bb, nil
+}
+
+// History implements DeduplicationStore.
+func (s *deduplicationStore) History(ctx context.Context, interval string, limit int) ([]*models.DeduplicationSnapshot, error) {
+	switch interval {
+	case DeduplicationIntervalDay, DeduplicationIntervalWeek, DeduplicationIntervalMonth:
+	default:
+		return nil, fmt.Errorf("unknown deduplication history interval %q", interval)
+	}
+
+	defer metrics.InstrumentQuery(ctx, "deduplication_history")()
+	// Window functions are evaluated before ORDER BY and LIMIT, so the stats of the returned periods are cumulative
+	// over all periods, not only the returned ones.
+	q := `WITH u AS (
+			SELECT
+				date_trunc($1, created_at) AS period,
+				COUNT(*) AS blobs,
+				SUM(size) AS bytes
+			FROM
+				blobs
+			GROUP BY
+				1
+		),
+		l AS (
+			SELECT
+				date_trunc($1, rb.created_at) AS period,
+				COUNT(*) AS blobs,
+				SUM(b.size) AS bytes
+			FROM
+				repository_blobs AS rb
+				JOIN blobs AS b ON b.digest = rb.blob_digest
+			GROUP BY
+				1
+		),
+		p AS (
+			SELECT
+				period
+			FROM
+				u
+			UNION
+			SELECT
+				period
+			FROM
+				l
+		)
+		SELECT
+			p.period,
+			SUM(COALESCE(u.blobs, 0)) OVER w,
+			SUM(COALESCE(u.bytes, 0)) OVER w,
+			SUM(COALESCE(l.blobs, 0)) OVER w,
+			SUM(COALESCE(l.bytes, 0)) OVER w
+		FROM
+			p
+			LEFT JOIN u ON u.period = p.period
+			LEFT JOIN l ON l.period = p.period
+		WINDOW w AS (ORDER BY p.period)
+		ORDER BY
+			p.period DESC
+		LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, q, interval, limit)
+	if err != nil {
+		return nil, fmt.Errorf("calculating deduplication history: %w", err)
+	}
+	defer rows.Close()
+
+	ss := make([]*models.DeduplicationSnapshot, 0)
+	for rows.Next() {
+		st := new(models.DeduplicationSnapshot)
+		if err := rows.Scan(&st.Time, &st.UniqueBlobs, &st.UniqueBytes, &st.LinkedBlobs, &st.LinkedBytes); err != nil {
+			return nil, fmt.Errorf("scanning deduplication snapshot: %w", err)
+		}
+		ss = append(ss, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning deduplication snapshots: %w", err)
+	}
+
+	// reverse into chronological order
+	for i, j := 0, len(ss)-1; i < j; i, j = i+1, j-1 {
+		ss[i], ss[j] = ss[j], ss[i]
+	}
+
+	return ss, nil
+}
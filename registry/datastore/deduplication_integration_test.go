@@ -0,0 +1,103 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicationStore_Stats(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewDeduplicationStore(suite.db)
+	st, err := s.Stats(suite.ctx)
+	require.NoError(t, err)
+
+	// see testdata/fixtures/blobs.sql and testdata/fixtures/repository_blobs.sql
+	require.Equal(t, &models.DeduplicationStats{
+		UniqueBlobs: 27,
+		UniqueBytes: 62249555,
+		LinkedBlobs: 27,
+		LinkedBytes: 64211389,
+	}, st)
+}
+
+func TestDeduplicationStore_Stats_Empty(t *testing.T) {
+	unloadBlobFixtures(t)
+
+	s := datastore.NewDeduplicationStore(suite.db)
+	st, err := s.Stats(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, &models.DeduplicationStats{}, st)
+}
+
+func TestDeduplicationStore_TopSharedBlobs(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewDeduplicationStore(suite.db)
+	bb, err := s.TopSharedBlobs(suite.ctx, 2)
+	require.NoError(t, err)
+
+	// see testdata/fixtures/blobs.sql and testdata/fixtures/repository_blobs.sql
+	expected := []*models.SharedBlob{
+		{
+			MediaType:    "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			Digest:       "sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+			Size:         2802957,
+			Repositories: 4,
+		},
+		{
+			MediaType:    "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			Digest:       "sha256:6b0937e234ce911b75630b744fb12836fe01bda5f7db203927edbb1390bc7e21",
+			Size:         108,
+			Repositories: 4,
+		},
+	}
+	require.Equal(t, expected, bb)
+}
+
+func TestDeduplicationStore_TopSharedBlobs_None(t *testing.T) {
+	unloadBlobFixtures(t)
+
+	s := datastore.NewDeduplicationStore(suite.db)
+	bb, err := s.TopSharedBlobs(suite.ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, bb)
+}
+
+func TestDeduplicationStore_History(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewDeduplicationStore(suite.db)
+	ss, err := s.History(suite.ctx, datastore.DeduplicationIntervalMonth, 3)
+	require.NoError(t, err)
+	require.Len(t, ss, 3)
+
+	// see testdata/fixtures/blobs.sql and testdata/fixtures/repository_blobs.sql, stats are cumulative from the first
+	// period (2020-03), even if not returned
+	expected := []struct {
+		year  int
+		month time.Month
+		stats models.DeduplicationStats
+	}{
+		{2020, time.May, models.DeduplicationStats{UniqueBlobs: 10, UniqueBytes: 53778258, LinkedBlobs: 15, LinkedBytes: 62186975}},
+		{2021, time.November, models.DeduplicationStats{UniqueBlobs: 22, UniqueBytes: 55802672, LinkedBlobs: 27, LinkedBytes: 64211389}},
+		{2022, time.February, models.DeduplicationStats{UniqueBlobs: 27, UniqueBytes: 62249555, LinkedBlobs: 27, LinkedBytes: 64211389}},
+	}
+	for i, e := range expected {
+		require.Equal(t, e.year, ss[i].Time.UTC().Year())
+		require.Equal(t, e.month, ss[i].Time.UTC().Month())
+		require.Equal(t, e.stats, ss[i].DeduplicationStats)
+	}
+}
+
+func TestDeduplicationStore_History_UnknownInterval(t *testing.T) {
+	s := datastore.NewDeduplicationStore(suite.db)
+	_, err := s.History(suite.ctx, "year", 10)
+	require.EqualError(t, err, `unknown deduplication history interval "year"`)
+}
@@ -0,0 +1,107 @@
+// Package dump exports and imports registry metadata as a portable stream of newline-delimited JSON (NDJSON) records.
+// Unlike a database dump, the format is independent of the database engine and schema, which allows moving metadata
+// across database engines or replicating it to air-gapped registries.
+//
+// A dump starts with a header record, followed by the records of each repository, grouped together and in this order:
+// the repository itself, its blob links, its manifests and its tags. Each record is a JSON object with a `type`
+// attribute and a `data` attribute, whose shape depends on the type.
+package dump
+
+import (
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// FormatVersion is the version of the dump format. It's incremented on breaking changes to the format.
+const FormatVersion = 1
+
+// RecordType identifies the type of a dump record.
+type RecordType string
+
+const (
+	// RecordHeader is the type of the first record of a dump.
+	RecordHeader RecordType = "header"
+	// RecordRepository is the type of repository records.
+	RecordRepository RecordType = "repository"
+	// RecordBlob is the type of blob link records.
+	RecordBlob RecordType = "blob"
+	// RecordManifest is the type of manifest records.
+	RecordManifest RecordType = "manifest"
+	// RecordTag is the type of tag records.
+	RecordTag RecordType = "tag"
+)
+
+type record struct {
+	Type RecordType  `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Header describes a dump.
+type Header struct {
+	FormatVersion int `json:"format_version"`
+	// SchemaVersion is the ID of the latest schema migration applied to the source database. Informational only.
+	SchemaVersion string    `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
+
+// Repository is a repository record.
+type Repository struct {
+	Path string `json:"path"`
+}
+
+// Blob is a record for a blob linked to a repository.
+type Blob struct {
+	Repository string        `json:"repository"`
+	Digest     digest.Digest `json:"digest"`
+	MediaType  string        `json:"media_type"`
+	Size       int64         `json:"size"`
+}
+
+// Layer is a layer of a manifest.
+type Layer struct {
+	Digest    digest.Digest `json:"digest"`
+	MediaType string        `json:"media_type"`
+	Size      int64         `json:"size"`
+}
+
+// Configuration is the configuration of a manifest.
+type Configuration struct {
+	Digest    digest.Digest `json:"digest"`
+	MediaType string        `json:"media_type"`
+	Payload   []byte        `json:"payload"`
+}
+
+// Manifest is a manifest record. Payloads are base64 encoded, to preserve them byte for byte.
+type Manifest struct {
+	Repository             string          `json:"repository"`
+	Digest                 digest.Digest   `json:"digest"`
+	MediaType              string          `json:"media_type"`
+	SchemaVersion          int             `json:"schema_version"`
+	TotalSize              int64           `json:"total_size"`
+	Payload                []byte          `json:"payload"`
+	Configuration          *Configuration  `json:"configuration,omitempty"`
+	ArtifactType           string          `json:"artifact_type,omitempty"`
+	NonConformant          bool            `json:"non_conformant,omitempty"`
+	NonDistributableLayers bool            `json:"non_distributable_layers,omitempty"`
+	ChartName              string          `json:"chart_name,omitempty"`
+	ChartVersion           string          `json:"chart_version,omitempty"`
+	Layers                 []Layer         `json:"layers,omitempty"`
+	References             []digest.Digest `json:"references,omitempty"`
+	Subject                digest.Digest   `json:"subject,omitempty"`
+}
+
+// Tag is a tag record.
+type Tag struct {
+	Repository string        `json:"repository"`
+	Name       string        `json:"name"`
+	Manifest   digest.Digest `json:"manifest"`
+}
+
+// Stats holds the number of records exported or imported, by type.
+type Stats struct {
+	Repositories int `json:"repositories"`
+	Blobs        int `json:"blobs"`
+	Manifests    int `json:"manifests"`
+	Tags         int `json:"tags"`
+}
@@ -0,0 +1,114 @@
+//go:build integration
+
+package dump
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/migrations"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newDB(t *testing.T) *datastore.DB {
+	t.Helper()
+
+	db, err := testutil.NewDBFromEnv()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, testutil.TruncateAllTables(db))
+		require.NoError(t, db.Close())
+	})
+
+	_, err = migrations.NewMigrator(db.DB).Up()
+	require.NoError(t, err)
+
+	return db
+}
+
+func mockClock(t *testing.T) {
+	t.Helper()
+
+	c := clock.NewMock()
+	c.Set(time.Date(2023, 10, 26, 9, 0, 0, 0, time.UTC))
+	systemClock = c
+	t.Cleanup(func() { systemClock = clock.New() })
+}
+
+func reloadFixtures(t *testing.T, db *datastore.DB) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	testutil.ReloadFixtures(
+		t, db, filepath.Dir(wd),
+		testutil.NamespacesTable, testutil.RepositoriesTable, testutil.BlobsTable, testutil.RepositoryBlobsTable,
+		testutil.ManifestsTable, testutil.ManifestReferencesTable, testutil.LayersTable, testutil.TagsTable,
+	)
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	mockClock(t)
+	db := newDB(t)
+	ctx := context.Background()
+	reloadFixtures(t, db)
+
+	var exported bytes.Buffer
+	exportStats, err := Export(ctx, db, &exported)
+	require.NoError(t, err)
+	require.NotZero(t, exportStats.Repositories)
+	require.NotZero(t, exportStats.Manifests)
+	require.NotZero(t, exportStats.Tags)
+
+	require.NoError(t, testutil.TruncateAllTables(db))
+
+	importStats, err := Import(ctx, db, bytes.NewReader(exported.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, exportStats, importStats)
+
+	// exporting the imported metadata must produce the exact same dump
+	var reexported bytes.Buffer
+	_, err = Export(ctx, db, &reexported)
+	require.NoError(t, err)
+	require.Equal(t, exported.String(), reexported.String())
+}
+
+func TestImport_IsIdempotent(t *testing.T) {
+	mockClock(t)
+	db := newDB(t)
+	ctx := context.Background()
+	reloadFixtures(t, db)
+
+	var exported bytes.Buffer
+	_, err := Export(ctx, db, &exported)
+	require.NoError(t, err)
+
+	// importing the dump over the metadata it was exported from must not change it
+	_, err = Import(ctx, db, bytes.NewReader(exported.Bytes()))
+	require.NoError(t, err)
+
+	var reexported bytes.Buffer
+	_, err = Export(ctx, db, &reexported)
+	require.NoError(t, err)
+	require.Equal(t, exported.String(), reexported.String())
+}
+
+func TestExport_EmptyDatabase(t *testing.T) {
+	mockClock(t)
+	db := newDB(t)
+	require.NoError(t, testutil.TruncateAllTables(db))
+
+	var buf bytes.Buffer
+	stats, err := Export(context.Background(), db, &buf)
+	require.NoError(t, err)
+	require.Equal(t, &Stats{}, stats)
+	require.Contains(t, buf.String(), `"type":"header"`)
+	require.Contains(t, buf.String(), `"exported_at":"2023-10-26T09:00:00Z"`)
+}
@@ -0,0 +1,70 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_Encoding(t *testing.T) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	require.NoError(t, enc.Encode(&record{Type: RecordHeader, Data: &Header{
+		FormatVersion: FormatVersion,
+		SchemaVersion: "20231026090000_create_import_checkpoints_table",
+		ExportedAt:    time.Date(2023, 10, 26, 9, 0, 0, 0, time.UTC),
+	}}))
+	require.NoError(t, enc.Encode(&record{Type: RecordTag, Data: &Tag{
+		Repository: "a/b",
+		Name:       "latest",
+		Manifest:   "sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d1fbc4d8a9a5",
+	}}))
+
+	expected := `{"type":"header","data":{"format_version":1,"schema_version":"20231026090000_create_import_checkpoints_table","exported_at":"2023-10-26T09:00:00Z"}}
+{"type":"tag","data":{"repository":"a/b","name":"latest","manifest":"sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d1fbc4d8a9a5"}}
+`
+	require.Equal(t, expected, buf.String())
+}
+
+func TestImport_InvalidDump(t *testing.T) {
+	tcs := map[string]struct {
+		input string
+		err   string
+	}{
+		"empty": {
+			input: "",
+			err:   "empty dump",
+		},
+		"malformed": {
+			input: "{",
+			err:   "reading header",
+		},
+		"missing header": {
+			input: `{"type":"repository","data":{"path":"a"}}`,
+			err:   `expected header record, got "repository"`,
+		},
+		"unsupported version": {
+			input: `{"type":"header","data":{"format_version":2}}`,
+			err:   "unsupported dump format version 2, expected 1",
+		},
+		"record before repository": {
+			input: `{"type":"header","data":{"format_version":1}}
+{"type":"tag","data":{"repository":"a","name":"latest","manifest":"sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d1fbc4d8a9a5"}}`,
+			err: `tag record for repository "a" out of order`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := Import(context.Background(), nil, strings.NewReader(tc.input))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
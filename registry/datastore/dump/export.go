@@ -0,0 +1,188 @@
+package dump
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/benbjohnson/clock"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/migrations"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal"
+	"github.com/opencontainers/go-digest"
+)
+
+const exportBatchSize = 100
+
+// for test purposes (mocking)
+var systemClock internal.Clock = clock.New()
+
+// Export writes all repositories, blob links, manifests and tags in db to w. The export runs within a read-only
+// repeatable read transaction, so it reflects a consistent snapshot of the database while it's in use.
+func Export(ctx context.Context, db *datastore.DB, w io.Writer) (*Stats, error) {
+	version, err := migrations.NewMigrator(db.DB).Version()
+	if err != nil {
+		return nil, fmt.Errorf("reading schema version: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("beginning export transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	e := &exporter{
+		enc:           json.NewEncoder(w),
+		repoStore:     datastore.NewRepositoryStore(tx),
+		manifestStore: datastore.NewManifestStore(tx),
+		stats:         new(Stats),
+	}
+
+	if err := e.write(RecordHeader, &Header{
+		FormatVersion: FormatVersion,
+		SchemaVersion: version,
+		ExportedAt:    systemClock.Now().UTC(),
+	}); err != nil {
+		return nil, err
+	}
+
+	var lastID int64
+	for {
+		rr, err := e.repoStore.FindAllAfterID(ctx, lastID, exportBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(rr) == 0 {
+			break
+		}
+		for _, r := range rr {
+			if err := e.exportRepository(ctx, r); err != nil {
+				return nil, fmt.Errorf("exporting repository %q: %w", r.Path, err)
+			}
+		}
+		lastID = rr[len(rr)-1].ID
+	}
+
+	return e.stats, nil
+}
+
+type exporter struct {
+	enc           *json.Encoder
+	repoStore     datastore.RepositoryStore
+	manifestStore datastore.ManifestStore
+	stats         *Stats
+}
+
+func (e *exporter) write(t RecordType, data interface{}) error {
+	if err := e.enc.Encode(&record{Type: t, Data: data}); err != nil {
+		return fmt.Errorf("writing %s record: %w", t, err)
+	}
+	return nil
+}
+
+func (e *exporter) exportRepository(ctx context.Context, r *models.Repository) error {
+	if err := e.write(RecordRepository, &Repository{Path: r.Path}); err != nil {
+		return err
+	}
+	e.stats.Repositories++
+
+	bb, err := e.repoStore.Blobs(ctx, r)
+	if err != nil {
+		return err
+	}
+	// stores don't sort all lists, so sort them here to produce deterministic dumps
+	sort.Slice(bb, func(i, j int) bool { return bb[i].Digest < bb[j].Digest })
+	for _, b := range bb {
+		if err := e.write(RecordBlob, &Blob{Repository: r.Path, Digest: b.Digest, MediaType: b.MediaType, Size: b.Size}); err != nil {
+			return err
+		}
+		e.stats.Blobs++
+	}
+
+	mm, err := e.repoStore.Manifests(ctx, r)
+	if err != nil {
+		return err
+	}
+	digests := make(map[int64]digest.Digest, len(mm))
+	for _, m := range mm {
+		digests[m.ID] = m.Digest
+	}
+	for _, m := range mm {
+		rec, err := e.manifestRecord(ctx, r, m, digests)
+		if err != nil {
+			return err
+		}
+		if err := e.write(RecordManifest, rec); err != nil {
+			return err
+		}
+		e.stats.Manifests++
+	}
+
+	tt, err := e.repoStore.Tags(ctx, r)
+	if err != nil {
+		return err
+	}
+	sort.Slice(tt, func(i, j int) bool { return tt[i].Name < tt[j].Name })
+	for _, t := range tt {
+		dgst, ok := digests[t.ManifestID]
+		if !ok {
+			return fmt.Errorf("tag %q references unknown manifest %d", t.Name, t.ManifestID)
+		}
+		if err := e.write(RecordTag, &Tag{Repository: r.Path, Name: t.Name, Manifest: dgst}); err != nil {
+			return err
+		}
+		e.stats.Tags++
+	}
+
+	return nil
+}
+
+func (e *exporter) manifestRecord(ctx context.Context, r *models.Repository, m *models.Manifest, digests map[int64]digest.Digest) (*Manifest, error) {
+	rec := &Manifest{
+		Repository:             r.Path,
+		Digest:                 m.Digest,
+		MediaType:              m.MediaType,
+		SchemaVersion:          m.SchemaVersion,
+		TotalSize:              m.TotalSize,
+		Payload:                m.Payload,
+		ArtifactType:           m.ArtifactType.String,
+		NonConformant:          m.NonConformant,
+		NonDistributableLayers: m.NonDistributableLayers,
+		ChartName:              m.ChartName.String,
+		ChartVersion:           m.ChartVersion.String,
+	}
+	if m.Configuration != nil {
+		rec.Configuration = &Configuration{
+			Digest:    m.Configuration.Digest,
+			MediaType: m.Configuration.MediaType,
+			Payload:   m.Configuration.Payload,
+		}
+	}
+	if m.SubjectID.Valid {
+		rec.Subject = digests[m.SubjectID.Int64]
+	}
+
+	layers, err := e.manifestStore.LayerBlobs(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range layers {
+		rec.Layers = append(rec.Layers, Layer{Digest: l.Digest, MediaType: l.MediaType, Size: l.Size})
+	}
+	sort.Slice(rec.Layers, func(i, j int) bool { return rec.Layers[i].Digest < rec.Layers[j].Digest })
+
+	refs, err := e.manifestStore.References(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		rec.References = append(rec.References, ref.Digest)
+	}
+	sort.Slice(rec.References, func(i, j int) bool { return rec.References[i] < rec.References[j] })
+
+	return rec, nil
+}
@@ -0,0 +1,308 @@
+package dump
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+)
+
+const mtOctetStream = "application/octet-stream"
+
+type rawRecord struct {
+	Type RecordType      `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Import reads a dump from r and writes its contents to db. Each repository is imported within its own transaction.
+// Records that already exist in the database are left untouched, except for tags, which are updated to point to the
+// dumped manifest. This makes the import idempotent, so an interrupted import can be rerun safely.
+func Import(ctx context.Context, db *datastore.DB, r io.Reader) (*Stats, error) {
+	dec := json.NewDecoder(r)
+
+	var raw rawRecord
+	if err := dec.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty dump")
+		}
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if raw.Type != RecordHeader {
+		return nil, fmt.Errorf("expected %s record, got %q", RecordHeader, raw.Type)
+	}
+	var h Header
+	if err := json.Unmarshal(raw.Data, &h); err != nil {
+		return nil, fmt.Errorf("decoding header: %w", err)
+	}
+	if h.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported dump format version %d, expected %d", h.FormatVersion, FormatVersion)
+	}
+	log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{
+		"format_version": h.FormatVersion,
+		"schema_version": h.SchemaVersion,
+		"exported_at":    h.ExportedAt,
+	}).Info("importing metadata dump")
+
+	imp := &importer{db: db, stats: new(Stats)}
+	defer imp.rollback()
+
+	for {
+		var raw rawRecord
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("reading record: %w", err)
+		}
+		if err := imp.importRecord(ctx, &raw); err != nil {
+			return nil, err
+		}
+	}
+	if err := imp.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return imp.stats, nil
+}
+
+// importer holds the state of the repository being imported.
+type importer struct {
+	db    *datastore.DB
+	stats *Stats
+
+	tx            datastore.Transactor
+	repo          *models.Repository
+	repoStore     datastore.RepositoryStore
+	manifestStore datastore.ManifestStore
+	blobStore     datastore.BlobStore
+	tagStore      datastore.TagStore
+	manifests     map[digest.Digest]*models.Manifest
+	// pending are the manifests with references or subjects to associate once all manifests of the repository exist
+	pending []*Manifest
+}
+
+func (imp *importer) importRecord(ctx context.Context, raw *rawRecord) error {
+	if raw.Type == RecordRepository {
+		var rec Repository
+		if err := json.Unmarshal(raw.Data, &rec); err != nil {
+			return fmt.Errorf("decoding %s record: %w", raw.Type, err)
+		}
+		if err := imp.commit(ctx); err != nil {
+			return err
+		}
+		return imp.begin(ctx, rec.Path)
+	}
+
+	var recRepo struct {
+		Repository string `json:"repository"`
+	}
+	if err := json.Unmarshal(raw.Data, &recRepo); err != nil {
+		return fmt.Errorf("decoding %s record: %w", raw.Type, err)
+	}
+	if imp.repo == nil || imp.repo.Path != recRepo.Repository {
+		return fmt.Errorf("%s record for repository %q out of order", raw.Type, recRepo.Repository)
+	}
+
+	switch raw.Type {
+	case RecordBlob:
+		var rec Blob
+		if err := json.Unmarshal(raw.Data, &rec); err != nil {
+			return fmt.Errorf("decoding %s record: %w", raw.Type, err)
+		}
+		return imp.importBlob(ctx, &rec)
+	case RecordManifest:
+		var rec Manifest
+		if err := json.Unmarshal(raw.Data, &rec); err != nil {
+			return fmt.Errorf("decoding %s record: %w", raw.Type, err)
+		}
+		return imp.importManifest(ctx, &rec)
+	case RecordTag:
+		var rec Tag
+		if err := json.Unmarshal(raw.Data, &rec); err != nil {
+			return fmt.Errorf("decoding %s record: %w", raw.Type, err)
+		}
+		return imp.importTag(ctx, &rec)
+	default:
+		return fmt.Errorf("unknown record type %q", raw.Type)
+	}
+}
+
+func (imp *importer) begin(ctx context.Context, path string) error {
+	tx, err := imp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning repository transaction: %w", err)
+	}
+	imp.tx = tx
+	imp.repoStore = datastore.NewRepositoryStore(tx)
+	imp.manifestStore = datastore.NewManifestStore(tx)
+	imp.blobStore = datastore.NewBlobStore(tx)
+	imp.tagStore = datastore.NewTagStore(tx)
+	imp.manifests = make(map[digest.Digest]*models.Manifest)
+	imp.pending = nil
+
+	imp.repo, err = imp.repoStore.CreateOrFindByPath(ctx, path)
+	if err != nil {
+		return fmt.Errorf("creating repository %q: %w", path, err)
+	}
+	imp.stats.Repositories++
+
+	return nil
+}
+
+// commit associates the pending manifests and commits the transaction of the current repository, if any.
+func (imp *importer) commit(ctx context.Context) error {
+	if imp.tx == nil {
+		return nil
+	}
+
+	for _, rec := range imp.pending {
+		m := imp.manifests[rec.Digest]
+		for _, ref := range rec.References {
+			child, err := imp.findManifest(ctx, ref)
+			if err != nil {
+				return err
+			}
+			if err := imp.manifestStore.AssociateManifest(ctx, m, child); err != nil {
+				return fmt.Errorf("associating manifest %q with %q: %w", ref, rec.Digest, err)
+			}
+		}
+		if rec.Subject != "" {
+			subject, err := imp.findManifest(ctx, rec.Subject)
+			if err != nil {
+				return err
+			}
+			if _, err := imp.manifestStore.AssociateSubject(ctx, m, subject); err != nil {
+				return fmt.Errorf("associating subject %q with %q: %w", rec.Subject, rec.Digest, err)
+			}
+		}
+	}
+
+	if err := imp.tx.Commit(); err != nil {
+		return fmt.Errorf("committing repository %q transaction: %w", imp.repo.Path, err)
+	}
+	imp.tx = nil
+
+	return nil
+}
+
+func (imp *importer) rollback() {
+	if imp.tx != nil {
+		_ = imp.tx.Rollback()
+	}
+}
+
+func (imp *importer) findManifest(ctx context.Context, dgst digest.Digest) (*models.Manifest, error) {
+	if m, ok := imp.manifests[dgst]; ok {
+		return m, nil
+	}
+	m, err := imp.repoStore.FindManifestByDigest(ctx, imp.repo, dgst)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("manifest %q not found in repository %q", dgst, imp.repo.Path)
+	}
+	imp.manifests[dgst] = m
+
+	return m, nil
+}
+
+func (imp *importer) createBlob(ctx context.Context, dgst digest.Digest, mediaType string, size int64) error {
+	if err := imp.blobStore.CreateOrFind(ctx, &models.Blob{Digest: dgst, MediaType: mediaType, Size: size}); err != nil {
+		return fmt.Errorf("creating blob %q: %w", dgst, err)
+	}
+	return nil
+}
+
+func (imp *importer) importBlob(ctx context.Context, rec *Blob) error {
+	if err := imp.createBlob(ctx, rec.Digest, rec.MediaType, rec.Size); err != nil {
+		return err
+	}
+	if err := imp.repoStore.LinkBlob(ctx, imp.repo, rec.Digest); err != nil {
+		return fmt.Errorf("linking blob %q: %w", rec.Digest, err)
+	}
+	imp.stats.Blobs++
+
+	return nil
+}
+
+func (imp *importer) importManifest(ctx context.Context, rec *Manifest) error {
+	m := &models.Manifest{
+		NamespaceID:            imp.repo.NamespaceID,
+		RepositoryID:           imp.repo.ID,
+		TotalSize:              rec.TotalSize,
+		SchemaVersion:          rec.SchemaVersion,
+		MediaType:              rec.MediaType,
+		Digest:                 rec.Digest,
+		Payload:                rec.Payload,
+		NonConformant:          rec.NonConformant,
+		NonDistributableLayers: rec.NonDistributableLayers,
+	}
+	if rec.ArtifactType != "" {
+		m.ArtifactType = sql.NullString{String: rec.ArtifactType, Valid: true}
+	}
+	if rec.ChartName != "" {
+		m.ChartName = sql.NullString{String: rec.ChartName, Valid: true}
+	}
+	if rec.ChartVersion != "" {
+		m.ChartVersion = sql.NullString{String: rec.ChartVersion, Valid: true}
+	}
+	if rec.Configuration != nil {
+		// the configuration blob is usually linked, and therefore created, already
+		if err := imp.createBlob(ctx, rec.Configuration.Digest, mtOctetStream, 0); err != nil {
+			return err
+		}
+		m.Configuration = &models.Configuration{
+			MediaType: rec.Configuration.MediaType,
+			Digest:    rec.Configuration.Digest,
+			Payload:   rec.Configuration.Payload,
+		}
+	}
+
+	if err := imp.manifestStore.CreateOrFind(ctx, m); err != nil {
+		return fmt.Errorf("creating manifest %q: %w", rec.Digest, err)
+	}
+	imp.manifests[m.Digest] = m
+
+	for _, l := range rec.Layers {
+		if err := imp.createBlob(ctx, l.Digest, mtOctetStream, l.Size); err != nil {
+			return err
+		}
+		if err := imp.manifestStore.AssociateLayerBlob(ctx, m, &models.Blob{Digest: l.Digest, MediaType: l.MediaType, Size: l.Size}); err != nil {
+			return fmt.Errorf("associating layer %q with manifest %q: %w", l.Digest, rec.Digest, err)
+		}
+	}
+	if len(rec.References) > 0 || rec.Subject != "" {
+		imp.pending = append(imp.pending, rec)
+	}
+	imp.stats.Manifests++
+
+	return nil
+}
+
+func (imp *importer) importTag(ctx context.Context, rec *Tag) error {
+	m, err := imp.findManifest(ctx, rec.Manifest)
+	if err != nil {
+		return fmt.Errorf("importing tag %q: %w", rec.Name, err)
+	}
+
+	t := &models.Tag{
+		Name:         rec.Name,
+		NamespaceID:  imp.repo.NamespaceID,
+		RepositoryID: imp.repo.ID,
+		ManifestID:   m.ID,
+	}
+	if err := imp.tagStore.CreateOrUpdate(ctx, t); err != nil {
+		return fmt.Errorf("importing tag %q: %w", rec.Name, err)
+	}
+	imp.stats.Tags++
+
+	return nil
+}
@@ -14,6 +14,8 @@ var (
 	ErrRefManifestNotFound = fmt.Errorf("referenced %w", ErrManifestNotFound)
 	// ErrManifestReferencedInList is returned when attempting to delete a manifest referenced in at least one list.
 	ErrManifestReferencedInList = errors.New("manifest referenced by manifest list")
+	// ErrTagImmutable is returned when attempting to point an existing immutable tag to a different manifest.
+	ErrTagImmutable = errors.New("tag is immutable")
 )
 
 // ErrUnknownMediaType is returned when attempting to save a manifest containing references with unknown media types.
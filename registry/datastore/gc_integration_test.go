@@ -424,6 +424,7 @@ func TestGC_TrackDeletedManifests(t *testing.T) {
 	require.Equal(t, 0, tt[0].ReviewCount)
 	require.Equal(t, b.Digest, tt[0].Digest)
 	require.Equal(t, "manifest_delete", tt[0].Event)
+	require.Equal(t, m.Configuration.MediaType, tt[0].ConfigMediaType)
 	assertGCReviewDelayInMinMaxRange(t, tt[0].ReviewAfter, deletedAt)
 	// ignore the few milliseconds between deleting the manifest and queueing a task in response to it
 	require.Less(t, tt[0].CreatedAt, deletedAt.Add(200*time.Millisecond))
@@ -11,15 +11,22 @@ import (
 
 	"github.com/docker/distribution/registry/datastore/metrics"
 	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
 )
 
+// GCAdminReviewEvent is the event of GC review tasks queued on demand through the admin API.
+const GCAdminReviewEvent = "admin_review"
+
 type GCBlobTaskStore interface {
 	FindAll(ctx context.Context) ([]*models.GCBlobTask, error)
 	Count(ctx context.Context) (int, error)
+	NextReviewAfter(ctx context.Context) (sql.NullTime, error)
 	Next(ctx context.Context) (*models.GCBlobTask, error)
 	Postpone(ctx context.Context, b *models.GCBlobTask, d time.Duration) error
 	IsDangling(ctx context.Context, b *models.GCBlobTask) (bool, error)
 	Delete(ctx context.Context, b *models.GCBlobTask) error
+	ReviewNow(ctx context.Context, d digest.Digest) error
+	ReviewRepositoryBlobsNow(ctx context.Context, r *models.Repository) (int64, error)
 }
 
 type gcBlobTaskStore struct {
@@ -37,9 +44,10 @@ func scanFullGCBlobTasks(rows *sql.Rows) ([]*models.GCBlobTask, error) {
 
 	for rows.Next() {
 		var dgst Digest
+		var cfgMediaType sql.NullString
 		r := new(models.GCBlobTask)
 
-		err := rows.Scan(&r.ReviewAfter, &r.ReviewCount, &dgst, &r.CreatedAt, &r.Event)
+		err := rows.Scan(&r.ReviewAfter, &r.ReviewCount, &dgst, &r.CreatedAt, &r.Event, &cfgMediaType, &r.NamespaceID)
 		if err != nil {
 			return nil, fmt.Errorf("scanning GC blob task: %w", err)
 		}
@@ -49,6 +57,7 @@ func scanFullGCBlobTasks(rows *sql.Rows) ([]*models.GCBlobTask, error) {
 			return nil, err
 		}
 		r.Digest = d
+		r.ConfigMediaType = cfgMediaType.String
 
 		rr = append(rr, r)
 	}
@@ -62,8 +71,9 @@ func scanFullGCBlobTasks(rows *sql.Rows) ([]*models.GCBlobTask, error) {
 func scanFullGCBlobTask(row *sql.Row) (*models.GCBlobTask, error) {
 	b := new(models.GCBlobTask)
 	var dgst Digest
+	var cfgMediaType sql.NullString
 
-	err := row.Scan(&b.ReviewAfter, &b.ReviewCount, &dgst, &b.CreatedAt, &b.Event)
+	err := row.Scan(&b.ReviewAfter, &b.ReviewCount, &dgst, &b.CreatedAt, &b.Event, &cfgMediaType, &b.NamespaceID)
 	if err != nil {
 		return nil, fmt.Errorf("scanning GC blob task: %w", err)
 	}
@@ -73,20 +83,23 @@ func scanFullGCBlobTask(row *sql.Row) (*models.GCBlobTask, error) {
 		return nil, err
 	}
 	b.Digest = d
+	b.ConfigMediaType = cfgMediaType.String
 
 	return b, nil
 }
 
 // FindAll finds all GC blob tasks.
 func (s *gcBlobTaskStore) FindAll(ctx context.Context) ([]*models.GCBlobTask, error) {
-	defer metrics.InstrumentQuery("gc_blob_task_find_all")()
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_find_all")()
 
 	q := `SELECT
 			review_after,
 			review_count,
 			encode(digest, 'hex') as digest,
 			created_at,
-			event
+			event,
+			(SELECT media_type FROM media_types WHERE id = config_media_type_id) AS config_media_type,
+			top_level_namespace_id
 		FROM
 			gc_blob_review_queue`
 	rows, err := s.db.QueryContext(ctx, q)
@@ -99,7 +112,7 @@ func (s *gcBlobTaskStore) FindAll(ctx context.Context) ([]*models.GCBlobTask, er
 
 // Count counts all GC blob tasks.
 func (s *gcBlobTaskStore) Count(ctx context.Context) (int, error) {
-	defer metrics.InstrumentQuery("gc_blob_task_count")()
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_count")()
 
 	q := "SELECT COUNT(*) FROM gc_blob_review_queue"
 	var count int
@@ -111,20 +124,37 @@ func (s *gcBlobTaskStore) Count(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// NextReviewAfter returns the earliest review_after among all GC blob tasks, regardless of whether they are locked or
+// not. The returned value is invalid (not set) if there are no tasks.
+func (s *gcBlobTaskStore) NextReviewAfter(ctx context.Context) (sql.NullTime, error) {
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_next_review_after")()
+
+	q := "SELECT MIN(review_after) FROM gc_blob_review_queue"
+	var t sql.NullTime
+
+	if err := s.db.QueryRowContext(ctx, q).Scan(&t); err != nil {
+		return t, fmt.Errorf("finding next GC blob task review date: %w", err)
+	}
+
+	return t, nil
+}
+
 // Next reads and locks the blob review queue row with the oldest review_after before the current date. In case of a
 // draw (multiple unlocked records with the same review_after) the returned row is the one that was first inserted.
 // This method may be called safely from multiple concurrent goroutines or processes. A `SELECT FOR UPDATE` is used to
 // ensure that callers don't get the same record. The operation does not block, and no error is returned if there are
 // no rows or none is available (i.e., all locked by other processes). A `nil` record is returned in this situation.
 func (s *gcBlobTaskStore) Next(ctx context.Context) (*models.GCBlobTask, error) {
-	defer metrics.InstrumentQuery("gc_blob_task_next")()
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_next")()
 
 	q := `SELECT
 			review_after,
 			review_count,
 			encode(digest, 'hex') AS digest,
 			created_at,
-			event
+			event,
+			(SELECT media_type FROM media_types WHERE id = config_media_type_id) AS config_media_type,
+			top_level_namespace_id
 		FROM
 			gc_blob_review_queue
 		WHERE
@@ -150,7 +180,7 @@ func (s *gcBlobTaskStore) Next(ctx context.Context) (*models.GCBlobTask, error)
 // Postpone moves the review_after of a blob task forward by a given amount of time. The review_count is automatically
 // incremented.
 func (s *gcBlobTaskStore) Postpone(ctx context.Context, b *models.GCBlobTask, d time.Duration) error {
-	defer metrics.InstrumentQuery("gc_blob_task_postpone")()
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_postpone")()
 
 	q := `UPDATE
 			gc_blob_review_queue
@@ -187,7 +217,7 @@ func (s *gcBlobTaskStore) Postpone(ctx context.Context, b *models.GCBlobTask, d
 
 // Delete deletes a blob task from the blob review queue.
 func (s *gcBlobTaskStore) Delete(ctx context.Context, b *models.GCBlobTask) error {
-	defer metrics.InstrumentQuery("gc_blob_task_delete")()
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_delete")()
 
 	q := "DELETE FROM gc_blob_review_queue WHERE digest = decode($1, 'hex')"
 	dgst, err := NewDigest(b.Digest)
@@ -211,7 +241,7 @@ func (s *gcBlobTaskStore) Delete(ctx context.Context, b *models.GCBlobTask) erro
 
 // IsDangling determines if the blob referenced by the GC blob task is eligible for deletion or not.
 func (s *gcBlobTaskStore) IsDangling(ctx context.Context, b *models.GCBlobTask) (bool, error) {
-	defer metrics.InstrumentQuery("gc_blob_task_is_dangling")()
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_is_dangling")()
 
 	q := `SELECT
 			EXISTS (
@@ -241,3 +271,74 @@ func (s *gcBlobTaskStore) IsDangling(ctx context.Context, b *models.GCBlobTask)
 
 	return !referenced, nil
 }
+
+// ReviewNow schedules the review of the blob with the given digest for the current date, creating the corresponding
+// task if it does not exist yet. Returns ErrNotFound if the blob does not exist.
+func (s *gcBlobTaskStore) ReviewNow(ctx context.Context, d digest.Digest) error {
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_review_now")()
+
+	q := `INSERT INTO gc_blob_review_queue (digest, review_after, event)
+		SELECT
+			digest,
+			now(),
+			$2
+		FROM
+			blobs
+		WHERE
+			digest = decode($1, 'hex')
+		ON CONFLICT (digest)
+			DO UPDATE SET
+				review_after = now(), event = $2`
+
+	dgst, err := NewDigest(d)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, q, dgst, GCAdminReviewEvent)
+	if err != nil {
+		return fmt.Errorf("scheduling GC blob task review: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("scheduling GC blob task review: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ReviewRepositoryBlobsNow schedules the review of all blobs linked to the given repository for the current date,
+// creating the corresponding tasks if they do not exist yet. Returns the number of scheduled tasks.
+func (s *gcBlobTaskStore) ReviewRepositoryBlobsNow(ctx context.Context, r *models.Repository) (int64, error) {
+	defer metrics.InstrumentQuery(ctx, "gc_blob_task_review_repository_blobs_now")()
+
+	// the digest order prevents deadlocks with concurrent upserts of the same tasks
+	q := `INSERT INTO gc_blob_review_queue (digest, review_after, event)
+		SELECT
+			blob_digest,
+			now(),
+			$3
+		FROM
+			repository_blobs
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+		ORDER BY
+			blob_digest
+		ON CONFLICT (digest)
+			DO UPDATE SET
+				review_after = now(), event = $3`
+
+	res, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID, GCAdminReviewEvent)
+	if err != nil {
+		return 0, fmt.Errorf("scheduling GC blob task reviews: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("scheduling GC blob task reviews: %w", err)
+	}
+
+	return count, nil
+}
@@ -86,6 +86,27 @@ func TestGcBlobTaskStore_Count(t *testing.T) {
 	require.Equal(t, 4, count)
 }
 
+func TestGcBlobTaskStore_NextReviewAfter(t *testing.T) {
+	reloadGCBlobTaskFixtures(t)
+
+	s := datastore.NewGCBlobTaskStore(suite.db)
+	next, err := s.NextReviewAfter(suite.ctx)
+	require.NoError(t, err)
+	require.True(t, next.Valid)
+
+	// see testdata/fixtures/gc_blob_review_queue.sql
+	require.Equal(t, testutil.ParseTimestamp(t, "2020-03-03 17:57:23.405516", next.Time.Location()), next.Time)
+}
+
+func TestGcBlobTaskStore_NextReviewAfter_None(t *testing.T) {
+	unloadGCBlobTaskFixtures(t)
+
+	s := datastore.NewGCBlobTaskStore(suite.db)
+	next, err := s.NextReviewAfter(suite.ctx)
+	require.NoError(t, err)
+	require.False(t, next.Valid)
+}
+
 func nextGCBlobTask(t *testing.T) (datastore.Transactor, *models.GCBlobTask) {
 	t.Helper()
 
@@ -331,3 +352,66 @@ func TestGcBlobTaskStore_IsDangling_No_LayerInUse(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, yn)
 }
+
+func TestGcBlobTaskStore_ReviewNow(t *testing.T) {
+	// see testdata/fixtures/[gc_blob_review_queue|blobs].sql
+	reloadBlobFixtures(t)
+	reloadGCBlobTaskFixtures(t)
+
+	s := datastore.NewGCBlobTaskStore(suite.db)
+
+	// existing task
+	d := digest.Digest("sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9")
+	before := time.Now()
+	require.NoError(t, s.ReviewNow(suite.ctx, d))
+
+	b := pickGCBlobTaskByDigest(t, suite.db, d)
+	require.NotNil(t, b)
+	require.WithinDuration(t, before, b.ReviewAfter, time.Minute)
+
+	// new task
+	d = "sha256:f01256086224ded321e042e74135d72d5f108089a1cda03ab4820dfc442807c1"
+	require.False(t, existsGCBlobTaskByDigest(t, suite.db, d))
+	require.NoError(t, s.ReviewNow(suite.ctx, d))
+
+	tt, err := s.FindAll(suite.ctx)
+	require.NoError(t, err)
+	var found bool
+	for _, task := range tt {
+		if task.Digest == d {
+			found = true
+			require.Equal(t, datastore.GCAdminReviewEvent, task.Event)
+			require.WithinDuration(t, before, task.ReviewAfter, time.Minute)
+		}
+	}
+	require.True(t, found)
+}
+
+func TestGcBlobTaskStore_ReviewNow_NotFound(t *testing.T) {
+	s := datastore.NewGCBlobTaskStore(suite.db)
+	err := s.ReviewNow(suite.ctx, randomDigest(t))
+	require.ErrorIs(t, err, datastore.ErrNotFound)
+}
+
+func TestGcBlobTaskStore_ReviewRepositoryBlobsNow(t *testing.T) {
+	// see testdata/fixtures/[gc_blob_review_queue|repository_blobs].sql
+	reloadBlobFixtures(t)
+	reloadGCBlobTaskFixtures(t)
+
+	s := datastore.NewGCBlobTaskStore(suite.db)
+	n, err := s.ReviewRepositoryBlobsNow(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, n)
+
+	for _, d := range []digest.Digest{
+		"sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9",
+		"sha256:6b0937e234ce911b75630b744fb12836fe01bda5f7db203927edbb1390bc7e21",
+		"sha256:f01256086224ded321e042e74135d72d5f108089a1cda03ab4820dfc442807c1",
+	} {
+		require.True(t, existsGCBlobTaskByDigest(t, suite.db, d))
+	}
+
+	n, err = s.ReviewRepositoryBlobsNow(suite.ctx, &models.Repository{NamespaceID: 1, ID: 100})
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
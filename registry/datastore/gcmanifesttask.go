@@ -21,10 +21,12 @@ type GCManifestTaskStore interface {
 	FindAndLockBefore(ctx context.Context, namespaceID, repositoryID, manifestID int64, date time.Time) (*models.GCManifestTask, error)
 	FindAndLockNBefore(ctx context.Context, namespaceID, repositoryID int64, manifestIDs []int64, date time.Time) ([]*models.GCManifestTask, error)
 	Count(ctx context.Context) (int, error)
+	NextReviewAfter(ctx context.Context) (sql.NullTime, error)
 	Next(ctx context.Context) (*models.GCManifestTask, error)
 	Postpone(ctx context.Context, b *models.GCManifestTask, d time.Duration) error
 	IsDangling(ctx context.Context, b *models.GCManifestTask) (bool, error)
 	Delete(ctx context.Context, b *models.GCManifestTask) error
+	ReviewRepositoryManifestsNow(ctx context.Context, r *models.Repository) (int64, error)
 }
 
 type gcManifestTaskStore struct {
@@ -70,7 +72,7 @@ func scanFullGCManifestTask(row *sql.Row) (*models.GCManifestTask, error) {
 
 // FindAll finds all GC manifest tasks.
 func (s *gcManifestTaskStore) FindAll(ctx context.Context) ([]*models.GCManifestTask, error) {
-	defer metrics.InstrumentQuery("gc_manifest_task_find_all")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_find_all")()
 	q := `SELECT
 			top_level_namespace_id,
 			repository_id,
@@ -92,7 +94,7 @@ func (s *gcManifestTaskStore) FindAll(ctx context.Context) ([]*models.GCManifest
 // FindAndLock finds a GC manifest task and locks it against writes. This query blocks if the row exists but is already
 // locked by another process.
 func (s *gcManifestTaskStore) FindAndLock(ctx context.Context, namespaceID, repositoryID, manifestID int64) (*models.GCManifestTask, error) {
-	defer metrics.InstrumentQuery("gc_manifest_task_find_and_lock")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_find_and_lock")()
 	q := `SELECT
 			top_level_namespace_id,
 			repository_id,
@@ -115,7 +117,7 @@ func (s *gcManifestTaskStore) FindAndLock(ctx context.Context, namespaceID, repo
 // FindAndLockBefore finds a GC manifest task scheduled for review before date and locks it against writes. This query
 // blocks if the row exists but is already locked by another process.
 func (s *gcManifestTaskStore) FindAndLockBefore(ctx context.Context, namespaceID, repositoryID, manifestID int64, date time.Time) (*models.GCManifestTask, error) {
-	defer metrics.InstrumentQuery("gc_manifest_task_find_and_lock_before")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_find_and_lock_before")()
 	q := `SELECT
 			top_level_namespace_id,
 			repository_id,
@@ -139,7 +141,7 @@ func (s *gcManifestTaskStore) FindAndLockBefore(ctx context.Context, namespaceID
 // FindAndLockNBefore finds multiple GC manifest tasks scheduled for review before date and locks them against writes.
 // This query blocks if any row exists but is already locked by another process.
 func (s *gcManifestTaskStore) FindAndLockNBefore(ctx context.Context, namespaceID, repositoryID int64, manifestIDs []int64, date time.Time) ([]*models.GCManifestTask, error) {
-	defer metrics.InstrumentQuery("gc_manifest_task_find_and_lock_n_before")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_find_and_lock_n_before")()
 	q := `SELECT
 			top_level_namespace_id,
 			repository_id,
@@ -177,7 +179,7 @@ func (s *gcManifestTaskStore) FindAndLockNBefore(ctx context.Context, namespaceI
 
 // Count counts all GC manifest tasks.
 func (s *gcManifestTaskStore) Count(ctx context.Context) (int, error) {
-	defer metrics.InstrumentQuery("gc_manifest_task_count")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_count")()
 	q := "SELECT COUNT(*) FROM gc_manifest_review_queue"
 	var count int
 
@@ -188,13 +190,28 @@ func (s *gcManifestTaskStore) Count(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// NextReviewAfter returns the earliest review_after among all GC manifest tasks, regardless of whether they are locked or
+// not. The returned value is invalid (not set) if there are no tasks.
+func (s *gcManifestTaskStore) NextReviewAfter(ctx context.Context) (sql.NullTime, error) {
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_next_review_after")()
+
+	q := "SELECT MIN(review_after) FROM gc_manifest_review_queue"
+	var t sql.NullTime
+
+	if err := s.db.QueryRowContext(ctx, q).Scan(&t); err != nil {
+		return t, fmt.Errorf("finding next GC manifest task review date: %w", err)
+	}
+
+	return t, nil
+}
+
 // Next reads and locks the manifest review queue row with the oldest review_after before the current date. In case of a
 // draw (multiple unlocked records with the same review_after) the returned row is the one that was first inserted.
 // This method may be called safely from multiple concurrent goroutines or processes. A `SELECT FOR UPDATE` is used to
 // ensure that callers don't get the same record. The operation does not block, and no error is returned if there are
 // no rows or none is available (i.e., all locked by other processes). A `nil` record is returned in this situation.
 func (s *gcManifestTaskStore) Next(ctx context.Context) (*models.GCManifestTask, error) {
-	defer metrics.InstrumentQuery("gc_manifest_task_next")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_next")()
 	q := `SELECT
 			top_level_namespace_id,
 			repository_id,
@@ -228,7 +245,7 @@ func (s *gcManifestTaskStore) Next(ctx context.Context) (*models.GCManifestTask,
 // Postpone moves the review_after of a manifest task forward by a given amount of time. The review_count is
 // automatically incremented.
 func (s *gcManifestTaskStore) Postpone(ctx context.Context, m *models.GCManifestTask, d time.Duration) error {
-	defer metrics.InstrumentQuery("gc_manifest_task_postpone")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_postpone")()
 	q := `UPDATE
 			gc_manifest_review_queue
 		SET
@@ -262,7 +279,7 @@ func (s *gcManifestTaskStore) Postpone(ctx context.Context, m *models.GCManifest
 
 // IsDangling determines if the manifest referenced by the GC manifest task is eligible for deletion or not.
 func (s *gcManifestTaskStore) IsDangling(ctx context.Context, m *models.GCManifestTask) (bool, error) {
-	defer metrics.InstrumentQuery("gc_manifest_task_is_dangling")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_is_dangling")()
 	q := `SELECT
 			 EXISTS (
 				 SELECT
@@ -293,7 +310,7 @@ func (s *gcManifestTaskStore) IsDangling(ctx context.Context, m *models.GCManife
 
 // Delete deletes a manifest task from the manifest review queue.
 func (s *gcManifestTaskStore) Delete(ctx context.Context, m *models.GCManifestTask) error {
-	defer metrics.InstrumentQuery("gc_manifest_task_delete")()
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_delete")()
 	q := `DELETE FROM gc_manifest_review_queue
 		WHERE top_level_namespace_id = $1
 			AND repository_id = $2
@@ -313,3 +330,39 @@ func (s *gcManifestTaskStore) Delete(ctx context.Context, m *models.GCManifestTa
 
 	return nil
 }
+
+// ReviewRepositoryManifestsNow schedules the review of all manifests of the given repository for the current date,
+// creating the corresponding tasks if they do not exist yet. Returns the number of scheduled tasks.
+func (s *gcManifestTaskStore) ReviewRepositoryManifestsNow(ctx context.Context, r *models.Repository) (int64, error) {
+	defer metrics.InstrumentQuery(ctx, "gc_manifest_task_review_repository_manifests_now")()
+
+	// the ID order prevents deadlocks with concurrent upserts of the same tasks
+	q := `INSERT INTO gc_manifest_review_queue (top_level_namespace_id, repository_id, manifest_id, review_after, event)
+		SELECT
+			top_level_namespace_id,
+			repository_id,
+			id,
+			now(),
+			$3
+		FROM
+			manifests
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+		ORDER BY
+			id
+		ON CONFLICT (top_level_namespace_id, repository_id, manifest_id)
+			DO UPDATE SET
+				review_after = now(), event = $3`
+
+	res, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID, GCAdminReviewEvent)
+	if err != nil {
+		return 0, fmt.Errorf("scheduling GC manifest task reviews: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("scheduling GC manifest task reviews: %w", err)
+	}
+
+	return count, nil
+}
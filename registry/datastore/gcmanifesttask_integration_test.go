@@ -353,6 +353,27 @@ func TestGcManifestTaskStore_Count(t *testing.T) {
 	require.Equal(t, 4, count)
 }
 
+func TestGcManifestTaskStore_NextReviewAfter(t *testing.T) {
+	reloadGCManifestTaskFixtures(t)
+
+	s := datastore.NewGCManifestTaskStore(suite.db)
+	next, err := s.NextReviewAfter(suite.ctx)
+	require.NoError(t, err)
+	require.True(t, next.Valid)
+
+	// see testdata/fixtures/gc_manifest_review_queue.sql
+	require.Equal(t, testutil.ParseTimestamp(t, "2020-03-03 17:50:26.461745", next.Time.Location()), next.Time)
+}
+
+func TestGcManifestTaskStore_NextReviewAfter_None(t *testing.T) {
+	unloadGCManifestTaskFixtures(t)
+
+	s := datastore.NewGCManifestTaskStore(suite.db)
+	next, err := s.NextReviewAfter(suite.ctx)
+	require.NoError(t, err)
+	require.False(t, next.Valid)
+}
+
 func nextGCManifestTask(t *testing.T) (datastore.Transactor, *models.GCManifestTask) {
 	t.Helper()
 
@@ -626,3 +647,30 @@ func TestGcManifestTaskStore_IsDangling_No_TaggedAndReferencedByList(t *testing.
 	require.NoError(t, err)
 	require.False(t, yn)
 }
+
+func TestGcManifestTaskStore_ReviewRepositoryManifestsNow(t *testing.T) {
+	// see testdata/fixtures/manifests.sql
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.GCManifestReviewQueueTable))
+
+	s := datastore.NewGCManifestTaskStore(suite.db)
+	before := time.Now()
+	n, err := s.ReviewRepositoryManifestsNow(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, n)
+
+	tt, err := s.FindAll(suite.ctx)
+	require.NoError(t, err)
+	ids := make([]int64, 0, len(tt))
+	for _, m := range tt {
+		require.EqualValues(t, 3, m.RepositoryID)
+		require.Equal(t, datastore.GCAdminReviewEvent, m.Event)
+		require.WithinDuration(t, before, m.ReviewAfter, time.Minute)
+		ids = append(ids, m.ManifestID)
+	}
+	require.ElementsMatch(t, []int64{1, 2, 6}, ids)
+
+	n, err = s.ReviewRepositoryManifestsNow(suite.ctx, &models.Repository{NamespaceID: 1, ID: 100})
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
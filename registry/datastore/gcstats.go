@@ -0,0 +1,174 @@
+//go:generate mockgen -package mocks -destination mocks/gcstats.go . GCStatsStore
+
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// Names of the online GC review queues, as recorded in the GC review stats.
+const (
+	GCBlobReviewQueue     = "blobs"
+	GCManifestReviewQueue = "manifests"
+)
+
+// GCStatsStore is the interface that a GC stats store should conform to. It reports the state of the online GC review
+// queues and keeps hourly counts of the reviews processed by the online GC workers.
+type GCStatsStore interface {
+	// RecordReview counts a review of a task from the given queue in the stats of the current hour.
+	RecordReview(ctx context.Context, queue string, dangling, failed bool) error
+	// FindReviewStats finds the hourly review stats of all queues since the given time, oldest first.
+	FindReviewStats(ctx context.Context, since time.Time) ([]*models.GCReviewStats, error)
+	// DeleteReviewStatsBefore deletes the hourly review stats older than the given time, returning the number of
+	// deleted rows.
+	DeleteReviewStatsBefore(ctx context.Context, t time.Time) (int64, error)
+	// QueueStats reports the state of the blob and manifest review queues.
+	QueueStats(ctx context.Context) ([]*models.GCQueueStats, error)
+}
+
+type gcStatsStore struct {
+	db Queryer
+}
+
+// NewGCStatsStore builds a new gcStatsStore.
+func NewGCStatsStore(db Queryer) GCStatsStore {
+	return &gcStatsStore{db: db}
+}
+
+// RecordReview counts a review of a task from the given queue in the stats of the current hour. Failed reviews are
+// not counted as processed.
+func (s *gcStatsStore) RecordReview(ctx context.Context, queue string, dangling, failed bool) error {
+	defer metrics.InstrumentQuery(ctx, "gc_stats_record_review")()
+
+	q := `INSERT INTO gc_review_stats (hour, queue, processed, dangling, failed)
+			VALUES (date_trunc('hour', now()), $1, $2, $3, $4)
+		ON CONFLICT (queue, hour)
+			DO UPDATE SET
+				processed = gc_review_stats.processed + EXCLUDED.processed,
+				dangling = gc_review_stats.dangling + EXCLUDED.dangling,
+				failed = gc_review_stats.failed + EXCLUDED.failed`
+
+	var processed, dng, fld int
+	if failed {
+		fld = 1
+	} else {
+		processed = 1
+		if dangling {
+			dng = 1
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, q, queue, processed, dng, fld); err != nil {
+		return fmt.Errorf("recording GC review: %w", err)
+	}
+
+	return nil
+}
+
+// FindReviewStats finds the hourly review stats of all queues since the given time, oldest first.
+func (s *gcStatsStore) FindReviewStats(ctx context.Context, since time.Time) ([]*models.GCReviewStats, error) {
+	defer metrics.InstrumentQuery(ctx, "gc_stats_find_review_stats")()
+
+	q := `SELECT
+			queue,
+			hour,
+			processed,
+			dangling,
+			failed
+		FROM
+			gc_review_stats
+		WHERE
+			hour >= date_trunc('hour', $1::timestamptz)
+		ORDER BY
+			hour,
+			queue`
+
+	rows, err := s.db.QueryContext(ctx, q, since)
+	if err != nil {
+		return nil, fmt.Errorf("finding GC review stats: %w", err)
+	}
+	defer rows.Close()
+
+	ss := make([]*models.GCReviewStats, 0)
+	for rows.Next() {
+		st := new(models.GCReviewStats)
+		if err := rows.Scan(&st.Queue, &st.Hour, &st.Processed, &st.Dangling, &st.Failed); err != nil {
+			return nil, fmt.Errorf("scanning GC review stats: %w", err)
+		}
+		ss = append(ss, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning GC review stats: %w", err)
+	}
+
+	return ss, nil
+}
+
+// DeleteReviewStatsBefore deletes the hourly review stats older than the given time, returning the number of deleted
+// rows.
+func (s *gcStatsStore) DeleteReviewStatsBefore(ctx context.Context, t time.Time) (int64, error) {
+	defer metrics.InstrumentQuery(ctx, "gc_stats_delete_review_stats_before")()
+
+	q := "DELETE FROM gc_review_stats WHERE hour < $1"
+
+	res, err := s.db.ExecContext(ctx, q, t)
+	if err != nil {
+		return 0, fmt.Errorf("deleting GC review stats: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("deleting GC review stats: %w", err)
+	}
+
+	return n, nil
+}
+
+// QueueStats reports the state of the blob and manifest review queues, in this order.
+func (s *gcStatsStore) QueueStats(ctx context.Context) ([]*models.GCQueueStats, error) {
+	defer metrics.InstrumentQuery(ctx, "gc_stats_queue_stats")()
+
+	q := `SELECT
+			$1 AS queue,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE review_after < now()),
+			MIN(review_after) FILTER (WHERE review_after < now()),
+			COUNT(*) FILTER (WHERE review_count > 0),
+			COALESCE(MAX(review_count), 0)
+		FROM
+			gc_blob_review_queue
+		UNION ALL
+		SELECT
+			$2 AS queue,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE review_after < now()),
+			MIN(review_after) FILTER (WHERE review_after < now()),
+			COUNT(*) FILTER (WHERE review_count > 0),
+			COALESCE(MAX(review_count), 0)
+		FROM
+			gc_manifest_review_queue`
+
+	rows, err := s.db.QueryContext(ctx, q, GCBlobReviewQueue, GCManifestReviewQueue)
+	if err != nil {
+		return nil, fmt.Errorf("finding GC queue stats: %w", err)
+	}
+	defer rows.Close()
+
+	ss := make([]*models.GCQueueStats, 0, 2)
+	for rows.Next() {
+		st := new(models.GCQueueStats)
+		if err := rows.Scan(&st.Queue, &st.Size, &st.Pending, &st.OldestPendingReviewAfter, &st.Retried, &st.MaxReviewCount); err != nil {
+			return nil, fmt.Errorf("scanning GC queue stats: %w", err)
+		}
+		ss = append(ss, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning GC queue stats: %w", err)
+	}
+
+	return ss, nil
+}
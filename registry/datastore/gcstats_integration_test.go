@@ -0,0 +1,117 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func unloadGCReviewStatsFixtures(tb testing.TB) {
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.GCReviewStatsTable))
+}
+
+func TestGCStatsStore_RecordReview(t *testing.T) {
+	unloadGCReviewStatsFixtures(t)
+
+	s := datastore.NewGCStatsStore(suite.db)
+	require.NoError(t, s.RecordReview(suite.ctx, datastore.GCBlobReviewQueue, true, false))
+	require.NoError(t, s.RecordReview(suite.ctx, datastore.GCBlobReviewQueue, false, false))
+	require.NoError(t, s.RecordReview(suite.ctx, datastore.GCBlobReviewQueue, false, true))
+	require.NoError(t, s.RecordReview(suite.ctx, datastore.GCManifestReviewQueue, true, false))
+
+	ss, err := s.FindReviewStats(suite.ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.NotEmpty(t, ss)
+
+	// stats are bucketed by hour, which may have changed between reviews, so sum them up by queue
+	got := make(map[string]models.GCReviewStats)
+	for _, st := range ss {
+		require.Equal(t, st.Hour.Truncate(time.Hour), st.Hour)
+		require.WithinDuration(t, time.Now(), st.Hour, time.Hour)
+
+		sum := got[st.Queue]
+		sum.Processed += st.Processed
+		sum.Dangling += st.Dangling
+		sum.Failed += st.Failed
+		got[st.Queue] = sum
+	}
+	require.Equal(t, map[string]models.GCReviewStats{
+		datastore.GCBlobReviewQueue:     {Processed: 2, Dangling: 1, Failed: 1},
+		datastore.GCManifestReviewQueue: {Processed: 1, Dangling: 1},
+	}, got)
+}
+
+func TestGCStatsStore_FindReviewStats_None(t *testing.T) {
+	unloadGCReviewStatsFixtures(t)
+
+	s := datastore.NewGCStatsStore(suite.db)
+	ss, err := s.FindReviewStats(suite.ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, ss)
+}
+
+func TestGCStatsStore_DeleteReviewStatsBefore(t *testing.T) {
+	unloadGCReviewStatsFixtures(t)
+
+	_, err := suite.db.ExecContext(suite.ctx, `INSERT INTO gc_review_stats (hour, queue, processed)
+		VALUES ('2023-12-01 10:00:00+00', 'blobs', 5), ('2023-12-02 10:00:00+00', 'blobs', 3)`)
+	require.NoError(t, err)
+
+	s := datastore.NewGCStatsStore(suite.db)
+	n, err := s.DeleteReviewStatsBefore(suite.ctx, time.Date(2023, 12, 2, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	ss, err := s.FindReviewStats(suite.ctx, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+	require.Equal(t, int64(3), ss[0].Processed)
+	require.True(t, time.Date(2023, 12, 2, 10, 0, 0, 0, time.UTC).Equal(ss[0].Hour))
+}
+
+func TestGCStatsStore_QueueStats(t *testing.T) {
+	reloadGCBlobTaskFixtures(t)
+	reloadGCManifestTaskFixtures(t)
+
+	s := datastore.NewGCStatsStore(suite.db)
+	ss, err := s.QueueStats(suite.ctx)
+	require.NoError(t, err)
+	require.Len(t, ss, 2)
+
+	// see testdata/fixtures/gc_blob_review_queue.sql
+	require.Equal(t, datastore.GCBlobReviewQueue, ss[0].Queue)
+	require.Equal(t, 4, ss[0].Size)
+	require.Equal(t, 3, ss[0].Pending)
+	require.True(t, ss[0].OldestPendingReviewAfter.Valid)
+	require.Equal(t, testutil.ParseTimestamp(t, "2020-03-03 17:57:23.405516", ss[0].OldestPendingReviewAfter.Time.Location()), ss[0].OldestPendingReviewAfter.Time)
+	require.Equal(t, 2, ss[0].Retried)
+	require.Equal(t, 3, ss[0].MaxReviewCount)
+
+	// see testdata/fixtures/gc_manifest_review_queue.sql
+	require.Equal(t, datastore.GCManifestReviewQueue, ss[1].Queue)
+	require.Equal(t, 4, ss[1].Size)
+	require.Equal(t, 3, ss[1].Pending)
+	require.True(t, ss[1].OldestPendingReviewAfter.Valid)
+	require.Equal(t, testutil.ParseTimestamp(t, "2020-03-03 17:50:26.461745", ss[1].OldestPendingReviewAfter.Time.Location()), ss[1].OldestPendingReviewAfter.Time)
+	require.Equal(t, 1, ss[1].Retried)
+	require.Equal(t, 2, ss[1].MaxReviewCount)
+}
+
+func TestGCStatsStore_QueueStats_Empty(t *testing.T) {
+	unloadGCBlobTaskFixtures(t)
+	unloadGCManifestTaskFixtures(t)
+
+	s := datastore.NewGCStatsStore(suite.db)
+	ss, err := s.QueueStats(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*models.GCQueueStats{
+		{Queue: datastore.GCBlobReviewQueue},
+		{Queue: datastore.GCManifestReviewQueue},
+	}, ss)
+}
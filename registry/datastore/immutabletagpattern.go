@@ -0,0 +1,84 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// ImmutableTagPatternStore is the interface that an immutable tag pattern store should conform to.
+type ImmutableTagPatternStore interface {
+	FindByRepository(ctx context.Context, r *models.Repository) ([]string, error)
+	ReplaceForRepository(ctx context.Context, r *models.Repository, patterns []string) error
+}
+
+// immutableTagPatternStore is the concrete implementation of an ImmutableTagPatternStore.
+type immutableTagPatternStore struct {
+	db Queryer
+}
+
+// NewImmutableTagPatternStore builds a new immutable tag pattern store.
+func NewImmutableTagPatternStore(db Queryer) ImmutableTagPatternStore {
+	return &immutableTagPatternStore{db: db}
+}
+
+// FindByRepository finds all immutable tag patterns of a given repository, sorted by pattern.
+func (s *immutableTagPatternStore) FindByRepository(ctx context.Context, r *models.Repository) ([]string, error) {
+	defer metrics.InstrumentQuery(ctx, "immutable_tag_pattern_find_by_repository")()
+	q := `SELECT
+			pattern
+		FROM
+			immutable_tag_patterns
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+		ORDER BY
+			pattern`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("finding immutable tag patterns: %w", err)
+	}
+	defer rows.Close()
+
+	pp := make([]string, 0)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("scanning immutable tag pattern: %w", err)
+		}
+		pp = append(pp, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning immutable tag patterns: %w", err)
+	}
+
+	return pp, nil
+}
+
+// ReplaceForRepository replaces all immutable tag patterns of a given repository with the given ones. This should be
+// executed within a transaction to avoid leaving the repository without patterns in case of failure.
+func (s *immutableTagPatternStore) ReplaceForRepository(ctx context.Context, r *models.Repository, patterns []string) error {
+	defer metrics.InstrumentQuery(ctx, "immutable_tag_pattern_replace_for_repository")()
+
+	q := `DELETE FROM immutable_tag_patterns
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2`
+	if _, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID); err != nil {
+		return fmt.Errorf("deleting immutable tag patterns: %w", err)
+	}
+
+	q = `INSERT INTO immutable_tag_patterns (top_level_namespace_id, repository_id, pattern)
+			VALUES ($1, $2, $3)
+		ON CONFLICT
+			DO NOTHING`
+	for _, p := range patterns {
+		if _, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID, p); err != nil {
+			return fmt.Errorf("creating immutable tag pattern: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImmutableTagPatternStore_ReplaceForRepository(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.ImmutableTagPatternsTable))
+
+	s := datastore.NewImmutableTagPatternStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	pp, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Empty(t, pp)
+
+	err = s.ReplaceForRepository(suite.ctx, r, []string{"v*", "release-*", "v*"})
+	require.NoError(t, err)
+
+	pp, err = s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, []string{"release-*", "v*"}, pp)
+
+	err = s.ReplaceForRepository(suite.ctx, r, []string{"stable"})
+	require.NoError(t, err)
+
+	pp, err = s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, []string{"stable"}, pp)
+
+	// other repositories are not affected
+	pp, err = s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+	require.Empty(t, pp)
+}
+
+func TestImmutableTagPatternStore_ReplaceForRepository_Empty(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.ImmutableTagPatternsTable))
+
+	s := datastore.NewImmutableTagPatternStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	require.NoError(t, s.ReplaceForRepository(suite.ctx, r, []string{"v*"}))
+	require.NoError(t, s.ReplaceForRepository(suite.ctx, r, nil))
+
+	pp, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Empty(t, pp)
+}
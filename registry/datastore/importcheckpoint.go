@@ -0,0 +1,98 @@
+//go:generate mockgen -package mocks -destination mocks/importcheckpoint.go . ImportCheckpointStore
+
+package datastore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+)
+
+// ImportCheckpointStore is the interface that an import checkpoint store should conform to. A checkpoint records that
+// an import step completed for a given repository, so that interrupted imports can be resumed.
+type ImportCheckpointStore interface {
+	// FindPaths finds the paths of all repositories with a checkpoint for the given import step.
+	FindPaths(ctx context.Context, step string) ([]string, error)
+	// Create records a checkpoint for the given import step and repository path. It's a no-op if one already exists.
+	Create(ctx context.Context, step, path string) error
+	// DeleteAll deletes all checkpoints for the given import step. Returns the number of deleted checkpoints.
+	DeleteAll(ctx context.Context, step string) (int64, error)
+}
+
+type importCheckpointStore struct {
+	db Queryer
+}
+
+// NewImportCheckpointStore builds a new importCheckpointStore.
+func NewImportCheckpointStore(db Queryer) ImportCheckpointStore {
+	return &importCheckpointStore{db: db}
+}
+
+// FindPaths finds the paths of all repositories with a checkpoint for the given import step, sorted by path.
+func (s *importCheckpointStore) FindPaths(ctx context.Context, step string) ([]string, error) {
+	defer metrics.InstrumentQuery(ctx, "import_checkpoint_find_paths")()
+
+	q := `SELECT
+			repository_path
+		FROM
+			import_checkpoints
+		WHERE
+			step = $1
+		ORDER BY
+			repository_path`
+
+	rows, err := s.db.QueryContext(ctx, q, step)
+	if err != nil {
+		return nil, fmt.Errorf("finding import checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	pp := make([]string, 0)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("scanning import checkpoint: %w", err)
+		}
+		pp = append(pp, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning import checkpoints: %w", err)
+	}
+
+	return pp, nil
+}
+
+// Create records a checkpoint for the given import step and repository path. It's a no-op if one already exists.
+func (s *importCheckpointStore) Create(ctx context.Context, step, path string) error {
+	defer metrics.InstrumentQuery(ctx, "import_checkpoint_create")()
+
+	q := `INSERT INTO import_checkpoints (step, repository_path)
+			VALUES ($1, $2)
+		ON CONFLICT (step, repository_path)
+			DO NOTHING`
+
+	if _, err := s.db.ExecContext(ctx, q, step, path); err != nil {
+		return fmt.Errorf("creating import checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAll deletes all checkpoints for the given import step. Returns the number of deleted checkpoints.
+func (s *importCheckpointStore) DeleteAll(ctx context.Context, step string) (int64, error) {
+	defer metrics.InstrumentQuery(ctx, "import_checkpoint_delete_all")()
+
+	q := "DELETE FROM import_checkpoints WHERE step = $1"
+
+	res, err := s.db.ExecContext(ctx, q, step)
+	if err != nil {
+		return 0, fmt.Errorf("deleting import checkpoints: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("counting deleted import checkpoints: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,78 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func unloadImportCheckpointFixtures(tb testing.TB) {
+	tb.Helper()
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.ImportCheckpointsTable))
+}
+
+func TestImportCheckpointStore_Create(t *testing.T) {
+	unloadImportCheckpointFixtures(t)
+
+	s := datastore.NewImportCheckpointStore(suite.db)
+	require.NoError(t, s.Create(suite.ctx, "pre_import", "b"))
+	require.NoError(t, s.Create(suite.ctx, "pre_import", "a"))
+	require.NoError(t, s.Create(suite.ctx, "repository_import", "c"))
+
+	pp, err := s.FindPaths(suite.ctx, "pre_import")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, pp)
+}
+
+func TestImportCheckpointStore_Create_IsIdempotent(t *testing.T) {
+	unloadImportCheckpointFixtures(t)
+
+	s := datastore.NewImportCheckpointStore(suite.db)
+	require.NoError(t, s.Create(suite.ctx, "pre_import", "a"))
+	require.NoError(t, s.Create(suite.ctx, "pre_import", "a"))
+
+	pp, err := s.FindPaths(suite.ctx, "pre_import")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, pp)
+}
+
+func TestImportCheckpointStore_Create_UnknownStepFails(t *testing.T) {
+	unloadImportCheckpointFixtures(t)
+
+	s := datastore.NewImportCheckpointStore(suite.db)
+	require.Error(t, s.Create(suite.ctx, "foo", "a"))
+}
+
+func TestImportCheckpointStore_FindPaths_None(t *testing.T) {
+	unloadImportCheckpointFixtures(t)
+
+	s := datastore.NewImportCheckpointStore(suite.db)
+	pp, err := s.FindPaths(suite.ctx, "pre_import")
+	require.NoError(t, err)
+	require.Empty(t, pp)
+}
+
+func TestImportCheckpointStore_DeleteAll(t *testing.T) {
+	unloadImportCheckpointFixtures(t)
+
+	s := datastore.NewImportCheckpointStore(suite.db)
+	require.NoError(t, s.Create(suite.ctx, "pre_import", "a"))
+	require.NoError(t, s.Create(suite.ctx, "pre_import", "b"))
+	require.NoError(t, s.Create(suite.ctx, "repository_import", "a"))
+
+	n, err := s.DeleteAll(suite.ctx, "pre_import")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, n)
+
+	pp, err := s.FindPaths(suite.ctx, "pre_import")
+	require.NoError(t, err)
+	require.Empty(t, pp)
+
+	pp, err = s.FindPaths(suite.ctx, "repository_import")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, pp)
+}
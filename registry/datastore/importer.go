@@ -16,6 +16,7 @@ import (
 	"github.com/docker/distribution/log"
 	"github.com/docker/distribution/manifest/manifestlist"
 	mlcompat "github.com/docker/distribution/manifest/manifestlist/compat"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema1"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/datastore/models"
@@ -23,6 +24,7 @@ import (
 	"github.com/jackc/pgconn"
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/googleapi"
 )
 
@@ -40,8 +42,10 @@ type Importer struct {
 	db              *DB
 	repositoryStore RepositoryStore
 	manifestStore   ManifestStore
+	annotationStore ManifestAnnotationStore
 	tagStore        TagStore
 	blobStore       BlobStore
+	checkpointStore ImportCheckpointStore
 
 	importDanglingManifests bool
 	importDanglingBlobs     bool
@@ -51,6 +55,8 @@ type Importer struct {
 	rowCount                bool
 	testingDelay            time.Duration
 	preImportRetryTimeout   time.Duration
+	resume                  bool
+	repositoryConcurrency   int
 }
 
 // ImporterOption provides functional options for the Importer.
@@ -117,12 +123,29 @@ func WithPreImportRetryTimeout(d time.Duration) ImporterOption {
 	}
 }
 
+// WithResume configures the Importer to skip repositories for which the current import step was already completed by a
+// previous (interrupted) run. Without this option, the checkpoints of previous runs are discarded when an import step
+// starts.
+func WithResume(imp *Importer) {
+	imp.resume = true
+}
+
+// WithRepositoryConcurrency configures the Importer to (pre)import n repositories concurrently. Ignored for dry runs,
+// which must use a single transaction.
+func WithRepositoryConcurrency(n int) ImporterOption {
+	return func(imp *Importer) {
+		imp.repositoryConcurrency = n
+	}
+}
+
 // NewImporter creates a new Importer.
 func NewImporter(db *DB, registry distribution.Namespace, opts ...ImporterOption) *Importer {
 	imp := &Importer{
 		registry:       registry,
 		db:             db,
 		tagConcurrency: 1,
+		// default repository (pre)import concurrency
+		repositoryConcurrency: 1,
 		// default manifest pre import retry timeout
 		preImportRetryTimeout: time.Minute,
 	}
@@ -148,9 +171,20 @@ func (imp *Importer) beginTx(ctx context.Context) (Transactor, error) {
 
 func (imp *Importer) loadStores(db Queryer) {
 	imp.manifestStore = NewManifestStore(db)
+	imp.annotationStore = NewManifestAnnotationStore(db)
 	imp.blobStore = NewBlobStore(db)
 	imp.repositoryStore = NewRepositoryStore(db)
 	imp.tagStore = NewTagStore(db)
+	imp.checkpointStore = NewImportCheckpointStore(db)
+}
+
+// withStores returns a shallow copy of the Importer with stores bound to db. This allows repositories to be (pre)imported
+// concurrently, each within its own transaction if required.
+func (imp *Importer) withStores(db Queryer) *Importer {
+	c := *imp
+	c.loadStores(db)
+
+	return &c
 }
 
 func (imp *Importer) findOrCreateDBManifest(ctx context.Context, dbRepo *models.Repository, m *models.Manifest) (*models.Manifest, error) {
@@ -163,6 +197,9 @@ func (imp *Importer) findOrCreateDBManifest(ctx context.Context, dbRepo *models.
 		if err := imp.manifestStore.Create(ctx, m); err != nil {
 			return nil, fmt.Errorf("creating manifest: %w", err)
 		}
+		if err := imp.annotationStore.Create(ctx, m, PayloadAnnotations(m.Payload)); err != nil {
+			return nil, fmt.Errorf("indexing manifest annotations: %w", err)
+		}
 		dbManifest = m
 	}
 
@@ -228,6 +265,12 @@ func (imp *Importer) importLayers(ctx context.Context, dbRepo *models.Repository
 		if err != nil {
 			// Log and continue on this failure.
 			l.WithFields(log.Fields{"media_type": fsLayer.MediaType}).WithError(err).Warn("error checking for existence of layer media type")
+		} else if !exists && ocischema.IsEncryptedLayer(fsLayer.MediaType) {
+			// layers encrypted with ocicrypt are registered on demand, as they may use any plain layer media type
+			if _, err = mtStore.CreateOrFind(ctx, fsLayer.MediaType); err != nil {
+				l.WithFields(log.Fields{"media_type": fsLayer.MediaType}).WithError(err).Warn("error registering encrypted layer media type")
+			}
+			exists = err == nil
 		}
 
 		// Slightly paranoid, but let's not trust the boolean value returned by the
@@ -286,8 +329,7 @@ func (imp *Importer) importManifestV2(ctx context.Context, fsRepo distribution.R
 		return nil, fmt.Errorf("importing layers: %w", err)
 	}
 
-	// find or create DB manifest
-	dbManifest, err := imp.findOrCreateDBManifest(ctx, dbRepo, &models.Manifest{
+	dbm := &models.Manifest{
 		NamespaceID:   dbRepo.NamespaceID,
 		RepositoryID:  dbRepo.ID,
 		TotalSize:     m.TotalSize(),
@@ -301,7 +343,11 @@ func (imp *Importer) importManifestV2(ctx context.Context, fsRepo distribution.R
 			Digest:    dbConfigBlob.Digest,
 			Payload:   configPayload,
 		},
-	})
+	}
+	SetHelmChartMetadata(dbm, m)
+
+	// find or create DB manifest
+	dbManifest, err := imp.findOrCreateDBManifest(ctx, dbRepo, dbm)
 	if err != nil {
 		return nil, err
 	}
@@ -968,7 +1014,8 @@ func (imp *Importer) ImportAll(ctx context.Context) error {
 		}
 	}
 
-	if err := imp.importAllRepositories(ctx); err != nil {
+	// the legacy import doesn't record checkpoints
+	if err := imp.importAllRepositories(ctx, unknown); err != nil {
 		return err
 	}
 
@@ -1003,6 +1050,19 @@ const (
 	commonBlobs
 )
 
+// checkpointName returns the name under which the step checkpoints are recorded in the database. Only steps processed
+// per repository have checkpoints.
+func (s step) checkpointName() string {
+	switch s {
+	case preImport:
+		return "pre_import"
+	case repoImport:
+		return "repository_import"
+	default:
+		return ""
+	}
+}
+
 // doImport manages which import steps to run and ensure pre and post import
 // tasks are handled consistently across import steps. Included import steps are
 // always ran in the following order: pre import, repository import, common blobs.
@@ -1066,7 +1126,7 @@ func (imp *Importer) doImport(ctx context.Context, required step, steps ...step)
 		}
 	}
 	if repos {
-		if err := imp.importAllRepositories(ctx); err != nil {
+		if err := imp.importAllRepositories(ctx, repoImport); err != nil {
 			return fmt.Errorf("importing all repositories: %w", err)
 		}
 	}
@@ -1121,15 +1181,76 @@ func (imp *Importer) ImportBlobs(ctx context.Context) error {
 	return imp.doImport(ctx, commonBlobs)
 }
 
-func (imp *Importer) preImportAllRepositories(ctx context.Context) error {
+// forEachRepository calls fn for each repository in the storage backend, using up to repositoryConcurrency concurrent
+// workers and stopping at the first error. When resuming, repositories with a checkpoint for the given step are skipped,
+// otherwise any checkpoints left by previous runs of the step are discarded first. Checkpoints are ignored for steps
+// without them.
+func (imp *Importer) forEachRepository(ctx context.Context, s step, fn func(ctx context.Context, path string, index int) error) error {
 	repositoryEnumerator, ok := imp.registry.(distribution.RepositoryEnumerator)
 	if !ok {
 		return errors.New("building repository enumerator")
 	}
 
+	l := log.GetLogger(log.WithContext(ctx))
+	checkpoints := make(map[string]struct{})
+	switch {
+	case s.checkpointName() == "":
+		// the step has no checkpoints
+	case imp.resume:
+		pp, err := imp.checkpointStore.FindPaths(ctx, s.checkpointName())
+		if err != nil {
+			return err
+		}
+		for _, p := range pp {
+			checkpoints[p] = struct{}{}
+		}
+		l.WithFields(log.Fields{"checkpoints": len(checkpoints)}).Info("resuming import")
+	default:
+		n, err := imp.checkpointStore.DeleteAll(ctx, s.checkpointName())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			l.WithFields(log.Fields{"checkpoints": n}).Info("discarded checkpoints of previous import")
+		}
+	}
+
+	// dry runs use a single transaction, which can't be shared by concurrent workers
+	concurrency := imp.repositoryConcurrency
+	if concurrency < 1 || imp.dryRun {
+		concurrency = 1
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
 	index := 0
-	return repositoryEnumerator.Enumerate(ctx, func(path string) error {
+	err := repositoryEnumerator.Enumerate(gctx, func(path string) error {
 		index++
+		if _, ok := checkpoints[path]; ok {
+			l.WithFields(log.Fields{"repository": path, "count": index}).Info("skipping repository with checkpoint")
+			return nil
+		}
+		if concurrency == 1 {
+			return fn(ctx, path, index)
+		}
+
+		i := index
+		g.Go(func() error {
+			return fn(gctx, path, i)
+		})
+		// stop enumerating as soon as a worker fails
+		return gctx.Err()
+	})
+	if gErr := g.Wait(); gErr != nil {
+		return gErr
+	}
+
+	return err
+}
+
+func (imp *Importer) preImportAllRepositories(ctx context.Context) error {
+	return imp.forEachRepository(ctx, preImport, func(ctx context.Context, path string, index int) error {
 		repoStart := time.Now()
 		l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": path, "count": index})
 		l.Info("pre importing repository")
@@ -1159,6 +1280,10 @@ func (imp *Importer) preImportAllRepositories(ctx context.Context) error {
 			return nil
 		}
 
+		if err := imp.checkpointStore.Create(ctx, preImport.checkpointName(), path); err != nil {
+			return err
+		}
+
 		repoEnd := time.Since(repoStart).Seconds()
 		l.WithFields(log.Fields{"duration_s": repoEnd}).Info("repository pre import complete")
 
@@ -1198,31 +1323,27 @@ func (imp *Importer) importBlobs(ctx context.Context) error {
 	return nil
 }
 
-func (imp *Importer) importAllRepositories(ctx context.Context) error {
-	var tx Transactor
-	var err error
-
-	repositoryEnumerator, ok := imp.registry.(distribution.RepositoryEnumerator)
-	if !ok {
-		return errors.New("error building repository enumerator")
-	}
-
-	index := 0
-	return repositoryEnumerator.Enumerate(ctx, func(path string) error {
+// importAllRepositories imports all repositories, recording checkpoints for step s, unless unknown.
+func (imp *Importer) importAllRepositories(ctx context.Context, s step) error {
+	return imp.forEachRepository(ctx, s, func(ctx context.Context, path string, index int) error {
+		// dry runs use a single transaction, otherwise each repository and its checkpoint are committed together
+		w := imp
+		var tx Transactor
 		if !imp.dryRun {
-			tx, err = imp.beginTx(ctx)
+			var err error
+			tx, err = imp.db.BeginTx(ctx, nil)
 			if err != nil {
 				return fmt.Errorf("beginning repository transaction: %w", err)
 			}
 			defer tx.Rollback()
+			w = imp.withStores(tx)
 		}
 
-		index++
 		start := time.Now()
 		l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": path, "count": index})
 		l.Info("importing repository")
 
-		if err := imp.importRepository(ctx, path); err != nil {
+		if err := w.importRepository(ctx, path); err != nil {
 			l.WithError(err).Error("error importing repository")
 			// if the storage driver failed to find a repository path (usually due to missing `_manifests/revisions`
 			// or `_manifests/tags` folders) continue to the next one, otherwise stop as the error is unknown.
@@ -1232,6 +1353,12 @@ func (imp *Importer) importAllRepositories(ctx context.Context) error {
 			return nil
 		}
 
+		if s != unknown {
+			if err := w.checkpointStore.Create(ctx, s.checkpointName(), path); err != nil {
+				return err
+			}
+		}
+
 		end := time.Since(start).Seconds()
 		l.WithFields(log.Fields{"duration_s": end}).Info("repository import complete")
 
@@ -1239,9 +1366,6 @@ func (imp *Importer) importAllRepositories(ctx context.Context) error {
 			if err := tx.Commit(); err != nil {
 				return fmt.Errorf("commit repository transaction: %w", err)
 			}
-
-			// reset stores to use the main connection handler instead of the last (committed/rolled back) transaction
-			imp.loadStores(imp.db)
 		}
 
 		return nil
@@ -15,6 +15,7 @@ import (
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/datastore/testutil"
 	"github.com/docker/distribution/registry/storage"
 	storageDriver "github.com/docker/distribution/registry/storage/driver"
@@ -699,3 +700,270 @@ func TestImporter_ImportAllRepositories(t *testing.T) {
 	require.NoError(t, imp.ImportAllRepositories(suite.ctx))
 	validateImport(t, suite.db)
 }
+
+func TestImporter_ImportAllRepositories_Resume(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	// simulate an interrupted import that already completed a-simple
+	cs := datastore.NewImportCheckpointStore(suite.db)
+	require.NoError(t, cs.Create(suite.ctx, "repository_import", "a-simple"))
+
+	imp := newImporter(t, suite.db, datastore.WithResume)
+	require.NoError(t, imp.ImportAllRepositories(suite.ctx))
+
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.FindByPath(suite.ctx, "a-simple")
+	require.NoError(t, err)
+	require.Nil(t, r)
+	r, err = rs.FindByPath(suite.ctx, "b-nested")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+
+	pp, err := cs.FindPaths(suite.ctx, "repository_import")
+	require.NoError(t, err)
+	require.Len(t, pp, 8)
+}
+
+func TestImporter_ImportAllRepositories_DiscardsCheckpointsWithoutResume(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	cs := datastore.NewImportCheckpointStore(suite.db)
+	require.NoError(t, cs.Create(suite.ctx, "repository_import", "a-simple"))
+	require.NoError(t, cs.Create(suite.ctx, "repository_import", "foo"))
+
+	imp := newImporter(t, suite.db)
+	require.NoError(t, imp.ImportAllRepositories(suite.ctx))
+	validateImport(t, suite.db)
+}
+
+func TestImporter_FullImport_WithRepositoryConcurrency(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	imp := newImporter(t, suite.db, datastore.WithRepositoryConcurrency(4))
+	// Just check that there was no error and that all repositories were imported, we can't validate against golden
+	// files as with concurrency the order of rows may change.
+	require.NoError(t, imp.FullImport(suite.ctx))
+
+	cs := datastore.NewImportCheckpointStore(suite.db)
+	pp, err := cs.FindPaths(suite.ctx, "pre_import")
+	require.NoError(t, err)
+	require.Len(t, pp, 8)
+	pp, err = cs.FindPaths(suite.ctx, "repository_import")
+	require.NoError(t, err)
+	require.Len(t, pp, 8)
+
+	count, err := datastore.NewRepositoryStore(suite.db).Count(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 8, count)
+}
+
+func TestImporter_Validate(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	imp := newImporter(t, suite.db)
+	report, err := imp.Validate(suite.ctx)
+	require.NoError(t, err)
+
+	require.Equal(t, 8, report.Repositories)
+	require.NotZero(t, report.Tags)
+	require.NotZero(t, report.Manifests)
+	require.Zero(t, report.Summary[datastore.ValidationIssueBrokenTagLink])
+	require.Zero(t, report.Summary[datastore.ValidationIssueMissingBlob])
+
+	// nothing should have been written to the database
+	count, err := datastore.NewRepositoryStore(suite.db).Count(suite.ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestImporter_Validate_Issues(t *testing.T) {
+	tcs := []struct {
+		root     string
+		expected datastore.ValidationIssueType
+	}{
+		{root: "bad-tag-link", expected: datastore.ValidationIssueBrokenTagLink},
+		{root: "missing-tag-link", expected: datastore.ValidationIssueBrokenTagLink},
+		{root: "bad-manifest-link", expected: datastore.ValidationIssueBrokenManifest},
+		{root: "unlinked-layers", expected: datastore.ValidationIssueMissingBlob},
+		{root: "unlinked-config", expected: datastore.ValidationIssueMissingBlob},
+		{root: "unknown-layer-mediatype", expected: datastore.ValidationIssueUnknownMediaType},
+		{root: "unknown-manifestconfig-mediatype", expected: datastore.ValidationIssueUnknownMediaType},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.root, func(t *testing.T) {
+			require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+			imp := newImporterWithRoot(t, suite.db, tc.root, datastore.WithRepositoryConcurrency(2))
+			report, err := imp.Validate(suite.ctx)
+			require.NoError(t, err)
+			require.False(t, report.Valid())
+			require.NotZero(t, report.Summary[tc.expected])
+			require.Len(t, report.Issues, sumSummary(report.Summary))
+		})
+	}
+}
+
+func sumSummary(summary map[datastore.ValidationIssueType]int) int {
+	var n int
+	for _, c := range summary {
+		n += c
+	}
+	return n
+}
+
+func TestImporter_SyncOnce(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	imp := newImporter(t, suite.db)
+	res, err := imp.SyncOnce(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 8, res.Repositories)
+	require.Zero(t, res.DeletedTags)
+
+	// a second pass mirrors the same data
+	res, err = imp.SyncOnce(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 8, res.Repositories)
+	require.Zero(t, res.DeletedTags)
+}
+
+func TestImporter_SyncOnce_DeletesStaleTags(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	imp := newImporter(t, suite.db)
+	_, err := imp.SyncOnce(suite.ctx)
+	require.NoError(t, err)
+
+	// simulate the deletion of a tag from the filesystem by adding one that doesn't exist there to the database
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.FindByPath(suite.ctx, "a-simple")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	tt, err := rs.Tags(suite.ctx, r)
+	require.NoError(t, err)
+	require.NotEmpty(t, tt)
+	require.NoError(t, datastore.NewTagStore(suite.db).CreateOrUpdate(suite.ctx, &models.Tag{
+		Name:         "stale",
+		NamespaceID:  r.NamespaceID,
+		RepositoryID: r.ID,
+		ManifestID:   tt[0].ManifestID,
+	}))
+
+	res, err := imp.SyncOnce(suite.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.DeletedTags)
+
+	after, err := rs.Tags(suite.ctx, r)
+	require.NoError(t, err)
+	require.Len(t, after, len(tt))
+	for _, tag := range after {
+		require.NotEqual(t, "stale", tag.Name)
+	}
+}
+
+func TestImporter_CheckMirroring(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	imp := newImporter(t, suite.db)
+	require.NoError(t, imp.FullImport(suite.ctx))
+
+	report, err := imp.CheckMirroring(suite.ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, 8, report.Repositories)
+	require.NotZero(t, report.Tags)
+	require.NotZero(t, report.Manifests)
+
+	// schema 1 manifests are not imported, so their tags are the only divergence
+	require.Len(t, report.Divergences, 1)
+	require.Equal(t, "d-schema1", report.Divergences[0].Repository)
+	require.Equal(t, datastore.DivergenceTagMissingInDatabase, report.Divergences[0].Type)
+	require.Equal(t, datastore.DivergenceSeverityCritical, report.Divergences[0].Severity)
+	require.NotEmpty(t, report.Divergences[0].FilesystemDigest)
+	require.Empty(t, report.Divergences[0].DatabaseDigest)
+
+	// sampling limits the number of checked repositories
+	report, err = imp.CheckMirroring(suite.ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Repositories)
+}
+
+func TestImporter_CheckMirroring_Divergences(t *testing.T) {
+	require.NoError(t, testutil.TruncateAllTables(suite.db))
+
+	imp := newImporter(t, suite.db)
+	require.NoError(t, imp.FullImport(suite.ctx))
+
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.FindByPath(suite.ctx, "c-manifest-list")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	tt, err := rs.Tags(suite.ctx, r)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(tt), 2)
+	mm, err := rs.Manifests(suite.ctx, r)
+	require.NoError(t, err)
+	require.Greater(t, len(mm), 1)
+
+	ts := datastore.NewTagStore(suite.db)
+
+	// a tag only in the database
+	require.NoError(t, ts.CreateOrUpdate(suite.ctx, &models.Tag{
+		Name:         "db-only",
+		NamespaceID:  r.NamespaceID,
+		RepositoryID: r.ID,
+		ManifestID:   tt[0].ManifestID,
+	}))
+
+	// a tag only in the filesystem
+	deleted, err := rs.DeleteTagByName(suite.ctx, r, tt[0].Name)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	// a tag pointing to a different manifest
+	var other *models.Manifest
+	for _, m := range mm {
+		if m.ID != tt[1].ManifestID {
+			other = m
+			break
+		}
+	}
+	require.NotNil(t, other)
+	require.NoError(t, ts.CreateOrUpdate(suite.ctx, &models.Tag{
+		Name:         tt[1].Name,
+		NamespaceID:  r.NamespaceID,
+		RepositoryID: r.ID,
+		ManifestID:   other.ID,
+	}))
+
+	report, err := imp.CheckMirroring(suite.ctx, 0)
+	require.NoError(t, err)
+
+	// the tag of the schema 1 repository is also missing in the database, see TestImporter_CheckMirroring
+	require.True(t, report.Critical())
+	require.Equal(t, 3, report.Summary[datastore.DivergenceSeverityCritical])
+	require.Equal(t, 1, report.Summary[datastore.DivergenceSeverityWarning])
+	require.Equal(t, 2, report.Types[datastore.DivergenceTagMissingInDatabase])
+	require.Equal(t, 1, report.Types[datastore.DivergenceTagDigestMismatch])
+	require.Equal(t, 1, report.Types[datastore.DivergenceTagMissingInFilesystem])
+
+	// critical divergences are listed first
+	require.Len(t, report.Divergences, 4)
+	for i, d := range report.Divergences[:3] {
+		require.Equal(t, datastore.DivergenceSeverityCritical, d.Severity, i)
+	}
+	require.Equal(t, "c-manifest-list", report.Divergences[0].Repository)
+	require.Equal(t, "c-manifest-list", report.Divergences[1].Repository)
+	require.Equal(t, "d-schema1", report.Divergences[2].Repository)
+
+	d := report.Divergences[3]
+	require.Equal(t, datastore.DivergenceSeverityWarning, d.Severity)
+	require.Equal(t, "c-manifest-list", d.Repository)
+	require.Equal(t, "db-only", d.Tag)
+	require.Empty(t, d.FilesystemDigest)
+	for _, m := range mm {
+		if m.ID == tt[0].ManifestID {
+			require.Equal(t, m.Digest, d.DatabaseDigest)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/storage/driver"
+)
+
+// SyncResult summarizes a sync pass.
+type SyncResult struct {
+	// Repositories is the number of repositories mirrored into the database.
+	Repositories int
+	// DeletedTags is the number of database tags deleted because they no longer exist in the filesystem.
+	DeletedTags int
+
+	mu sync.Mutex
+}
+
+func (r *SyncResult) add(deletedTags int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Repositories++
+	r.DeletedTags += deletedTags
+}
+
+// SyncOnce mirrors the filesystem metadata of all repositories into the database. Tags created or updated since the
+// last pass, and the manifests they point to, are imported, while tags no longer present in the filesystem are deleted
+// from the database. Manifests already in the database are not imported again, so subsequent passes only process what
+// changed. Each repository is synced within its own transaction.
+func (imp *Importer) SyncOnce(ctx context.Context) (*SyncResult, error) {
+	res := new(SyncResult)
+
+	err := imp.forEachRepository(ctx, unknown, func(ctx context.Context, path string, index int) error {
+		l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": path, "count": index})
+		l.Debug("syncing repository")
+
+		tx, err := imp.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("beginning repository transaction: %w", err)
+		}
+		defer tx.Rollback()
+		w := imp.withStores(tx)
+
+		if err := w.importRepository(ctx, path); err != nil {
+			// if the storage driver failed to find a repository path (usually due to missing `_manifests/revisions`
+			// or `_manifests/tags` folders) continue to the next one, otherwise stop as the error is unknown.
+			if !(errors.As(err, &driver.PathNotFoundError{}) || errors.As(err, &distribution.ErrRepositoryUnknown{})) {
+				return fmt.Errorf("syncing repository %q: %w", path, err)
+			}
+			l.WithError(err).Warn("skipping repository")
+			return nil
+		}
+
+		deleted, err := w.deleteStaleTags(ctx, path)
+		if err != nil {
+			return fmt.Errorf("syncing repository %q: %w", path, err)
+		}
+		if len(deleted) > 0 {
+			l.WithFields(log.Fields{"tags": deleted}).Info("deleted tags no longer in the filesystem")
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit repository transaction: %w", err)
+		}
+		res.add(len(deleted))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// deleteStaleTags deletes the database tags of a repository that no longer exist in the filesystem.
+func (imp *Importer) deleteStaleTags(ctx context.Context, path string) ([]string, error) {
+	dbRepo, err := imp.repositoryStore.FindByPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("finding repository in database: %w", err)
+	}
+	if dbRepo == nil {
+		return nil, nil
+	}
+
+	named, err := reference.WithName(path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repository name: %w", err)
+	}
+	fsRepo, err := imp.registry.Repository(ctx, named)
+	if err != nil {
+		return nil, fmt.Errorf("constructing filesystem repository: %w", err)
+	}
+
+	fsTags, err := fsRepo.Tags(ctx).All(ctx)
+	if err != nil && !errors.As(err, &distribution.ErrRepositoryUnknown{}) {
+		return nil, fmt.Errorf("reading tags: %w", err)
+	}
+	current := make(map[string]struct{}, len(fsTags))
+	for _, t := range fsTags {
+		current[t] = struct{}{}
+	}
+
+	dbTags, err := imp.repositoryStore.Tags(ctx, dbRepo)
+	if err != nil {
+		return nil, fmt.Errorf("finding tags in database: %w", err)
+	}
+	var stale []string
+	for _, t := range dbTags {
+		if _, ok := current[t.Name]; !ok {
+			stale = append(stale, t.Name)
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	return imp.repositoryStore.DeleteTagsByNames(ctx, dbRepo, stale)
+}
+
+// Sync runs a sync pass (see SyncOnce) every interval until ctx is canceled, continuously mirroring new filesystem
+// writes into the database. This allows the database to be populated and validated while the registry keeps serving
+// requests from the filesystem metadata, before switching to the database. Errors are logged and retried on the next
+// pass.
+func (imp *Importer) Sync(ctx context.Context, interval time.Duration) error {
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"sync": true})
+	ctx = log.WithLogger(ctx, l)
+	l.WithFields(log.Fields{"interval_s": interval.Seconds()}).Info("starting continuous metadata sync")
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	for pass := 1; ; pass++ {
+		select {
+		case <-ctx.Done():
+			l.Info("context canceled, stopping metadata sync")
+			return nil
+		case <-t.C:
+		}
+
+		start := time.Now()
+		res, err := imp.SyncOnce(ctx)
+		pl := l.WithFields(log.Fields{"pass": pass, "duration_s": time.Since(start).Seconds()})
+		switch {
+		case err != nil && ctx.Err() != nil:
+			pl.Info("context canceled, stopping metadata sync")
+			return nil
+		case err != nil:
+			pl.WithError(err).Error("metadata sync pass failed")
+		default:
+			pl.WithFields(log.Fields{
+				"repositories": res.Repositories,
+				"deleted_tags": res.DeletedTags,
+			}).Info("metadata sync pass complete")
+		}
+
+		t.Reset(interval)
+	}
+}
@@ -0,0 +1,398 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/manifest/manifestlist"
+	mlcompat "github.com/docker/distribution/manifest/manifestlist/compat"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ValidationIssueType identifies a class of filesystem metadata inconsistencies.
+type ValidationIssueType string
+
+const (
+	// ValidationIssueBrokenTagLink is a tag whose link is missing or corrupted.
+	ValidationIssueBrokenTagLink ValidationIssueType = "broken_tag_link"
+	// ValidationIssueBrokenManifest is a manifest whose link is corrupted, its revision missing or its payload empty.
+	ValidationIssueBrokenManifest ValidationIssueType = "broken_manifest"
+	// ValidationIssueMissingBlob is a blob referenced by a manifest but not linked to the repository.
+	ValidationIssueMissingBlob ValidationIssueType = "missing_blob"
+	// ValidationIssueMissingManifest is a manifest referenced by a manifest list but not found in the repository.
+	ValidationIssueMissingManifest ValidationIssueType = "missing_manifest"
+	// ValidationIssueUnknownMediaType is a manifest, configuration or layer media type unknown to the database.
+	ValidationIssueUnknownMediaType ValidationIssueType = "unknown_media_type"
+	// ValidationIssueOrphanedUpload is a blob upload that was started but never completed or canceled.
+	ValidationIssueOrphanedUpload ValidationIssueType = "orphaned_upload"
+)
+
+// ValidationIssue is a filesystem metadata inconsistency found during validation.
+type ValidationIssue struct {
+	Type       ValidationIssueType `json:"type"`
+	Repository string              `json:"repository,omitempty"`
+	Tag        string              `json:"tag,omitempty"`
+	// Manifest is the digest of the manifest where the issue was found, if any.
+	Manifest digest.Digest `json:"manifest,omitempty"`
+	// Reference is the digest of the missing blob or manifest, if any.
+	Reference digest.Digest `json:"reference,omitempty"`
+	MediaType string        `json:"media_type,omitempty"`
+	Path      string        `json:"path,omitempty"`
+	Detail    string        `json:"detail"`
+}
+
+// ValidationReport is the outcome of a filesystem metadata validation.
+type ValidationReport struct {
+	Repositories int                         `json:"repositories"`
+	Tags         int                         `json:"tags"`
+	Manifests    int                         `json:"manifests"`
+	Summary      map[ValidationIssueType]int `json:"summary"`
+	Issues       []*ValidationIssue          `json:"issues"`
+
+	mu sync.Mutex
+}
+
+// NewValidationReport creates a new, empty ValidationReport.
+func NewValidationReport() *ValidationReport {
+	return &ValidationReport{
+		Summary: make(map[ValidationIssueType]int),
+		Issues:  make([]*ValidationIssue, 0),
+	}
+}
+
+// AddIssue records an issue. It's safe for concurrent use.
+func (r *ValidationReport) AddIssue(i *ValidationIssue) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Issues = append(r.Issues, i)
+	r.Summary[i.Type]++
+}
+
+func (r *ValidationReport) addCounts(tags, manifests int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Repositories++
+	r.Tags += tags
+	r.Manifests += manifests
+}
+
+// Valid determines whether the validation found no issues.
+func (r *ValidationReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+// Sort sorts issues by repository, tag, manifest, type and path, making reports comparable across runs regardless of
+// the repository concurrency.
+func (r *ValidationReport) Sort() {
+	sort.SliceStable(r.Issues, func(i, j int) bool {
+		a, b := r.Issues[i], r.Issues[j]
+		switch {
+		case a.Repository != b.Repository:
+			return a.Repository < b.Repository
+		case a.Tag != b.Tag:
+			return a.Tag < b.Tag
+		case a.Manifest != b.Manifest:
+			return a.Manifest < b.Manifest
+		case a.Type != b.Type:
+			return a.Type < b.Type
+		default:
+			return a.Path < b.Path
+		}
+	})
+}
+
+// repositoryValidator validates the metadata of a single repository, visiting each manifest once.
+type repositoryValidator struct {
+	imp      *Importer
+	report   *ValidationReport
+	fsRepo   distribution.Repository
+	path     string
+	manifest distribution.ManifestService
+	visited  map[digest.Digest]struct{}
+	// mediaTypes caches the existence of media types in the database
+	mediaTypes *sync.Map
+}
+
+// Validate scans the filesystem metadata of all repositories and reports the inconsistencies that would cause the
+// import to skip or fail on tags, manifests or blobs: broken tag links, broken manifests, manifests referencing missing
+// blobs or manifests and media types unknown to the database. Only tagged manifests and their references are
+// validated, as only these are imported. Nothing is written to the database.
+func (imp *Importer) Validate(ctx context.Context) (*ValidationReport, error) {
+	start := time.Now()
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"validate_only": true})
+	ctx = log.WithLogger(ctx, l)
+	l.Info("starting metadata validation")
+
+	report := NewValidationReport()
+	mediaTypes := new(sync.Map)
+
+	err := imp.forEachRepository(ctx, unknown, func(ctx context.Context, path string, index int) error {
+		l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": path, "count": index})
+		l.Info("validating repository")
+
+		named, err := reference.WithName(path)
+		if err != nil {
+			return fmt.Errorf("parsing repository name: %w", err)
+		}
+		fsRepo, err := imp.registry.Repository(ctx, named)
+		if err != nil {
+			return fmt.Errorf("constructing filesystem repository: %w", err)
+		}
+		manifestService, err := fsRepo.Manifests(ctx)
+		if err != nil {
+			return fmt.Errorf("constructing manifest service: %w", err)
+		}
+
+		v := &repositoryValidator{
+			imp:        imp,
+			report:     report,
+			fsRepo:     fsRepo,
+			path:       path,
+			manifest:   manifestService,
+			visited:    make(map[digest.Digest]struct{}),
+			mediaTypes: mediaTypes,
+		}
+		tags, err := v.validateTags(ctx)
+		if err != nil {
+			return fmt.Errorf("validating repository %q: %w", path, err)
+		}
+		report.addCounts(tags, len(v.visited))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.Sort()
+	l.WithFields(log.Fields{
+		"duration_s": time.Since(start).Seconds(),
+		"issues":     len(report.Issues),
+	}).Info("metadata validation complete")
+
+	return report, nil
+}
+
+func (v *repositoryValidator) addIssue(i *ValidationIssue) {
+	i.Repository = v.path
+	v.report.AddIssue(i)
+}
+
+func (v *repositoryValidator) validateTags(ctx context.Context) (int, error) {
+	tagService := v.fsRepo.Tags(ctx)
+	tags, err := tagService.All(ctx)
+	if err != nil {
+		if errors.As(err, &distribution.ErrRepositoryUnknown{}) || errors.As(err, &driver.PathNotFoundError{}) {
+			// no tags, nothing to validate
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading tags: %w", err)
+	}
+
+	for _, tag := range tags {
+		desc, err := tagService.Get(ctx, tag)
+		if err != nil {
+			switch {
+			case errors.As(err, &distribution.ErrTagUnknown{}):
+				v.addIssue(&ValidationIssue{Type: ValidationIssueBrokenTagLink, Tag: tag, Detail: "missing tag link"})
+				continue
+			case errors.Is(err, digest.ErrDigestInvalidFormat):
+				v.addIssue(&ValidationIssue{Type: ValidationIssueBrokenTagLink, Tag: tag, Detail: "corrupted tag link"})
+				continue
+			default:
+				return 0, fmt.Errorf("reading tag %q details: %w", tag, err)
+			}
+		}
+
+		if err := v.validateManifest(ctx, tag, desc.Digest); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(tags), nil
+}
+
+func (v *repositoryValidator) validateManifest(ctx context.Context, tag string, dgst digest.Digest) error {
+	if _, ok := v.visited[dgst]; ok {
+		return nil
+	}
+	v.visited[dgst] = struct{}{}
+
+	m, err := v.manifest.Get(ctx, dgst)
+	if err != nil {
+		issue := &ValidationIssue{Type: ValidationIssueBrokenManifest, Tag: tag, Manifest: dgst}
+		switch {
+		case errors.As(err, &distribution.ErrManifestEmpty{}):
+			issue.Detail = "empty manifest payload"
+		case errors.As(err, &distribution.ErrManifestUnknownRevision{}):
+			issue.Detail = "unknown manifest revision"
+		case errors.Is(err, digest.ErrDigestInvalidFormat):
+			issue.Detail = "corrupted manifest link"
+		case errors.Is(err, distribution.ErrSchemaV1Unsupported):
+			issue.Type = ValidationIssueUnknownMediaType
+			issue.MediaType = schema1.MediaTypeSignedManifest
+			issue.Detail = "unsupported v1 manifest"
+		default:
+			return fmt.Errorf("retrieving manifest %q from filesystem: %w", dgst, err)
+		}
+		v.addIssue(issue)
+		return nil
+	}
+
+	switch fsManifest := m.(type) {
+	case *schema1.SignedManifest:
+		v.addIssue(&ValidationIssue{
+			Type:      ValidationIssueUnknownMediaType,
+			Tag:       tag,
+			Manifest:  dgst,
+			MediaType: schema1.MediaTypeSignedManifest,
+			Detail:    "unsupported v1 manifest",
+		})
+	case *manifestlist.DeserializedManifestList:
+		if mlcompat.LikelyBuildxCache(fsManifest) {
+			ocim, err := mlcompat.OCIManifestFromBuildkitIndex(fsManifest)
+			if err != nil {
+				return fmt.Errorf("converting buildkit index to manifest: %w", err)
+			}
+			return v.validateManifestV2(ctx, tag, dgst, ocim)
+		}
+
+		mediaType := fsManifest.MediaType
+		if mediaType == "" {
+			mediaType = v1.MediaTypeImageIndex
+		}
+		if err := v.validateMediaType(ctx, tag, dgst, mediaType, "manifest list"); err != nil {
+			return err
+		}
+		for _, ref := range fsManifest.References() {
+			exists, err := v.manifest.Exists(ctx, ref.Digest)
+			if err != nil && !errors.Is(err, digest.ErrDigestInvalidFormat) {
+				return fmt.Errorf("checking for existence of manifest %q: %w", ref.Digest, err)
+			}
+			if !exists {
+				v.addIssue(&ValidationIssue{
+					Type:      ValidationIssueMissingManifest,
+					Tag:       tag,
+					Manifest:  dgst,
+					Reference: ref.Digest,
+					Detail:    "manifest list references a manifest not found in the repository",
+				})
+				continue
+			}
+			if err := v.validateManifest(ctx, tag, ref.Digest); err != nil {
+				return err
+			}
+		}
+	case distribution.ManifestV2:
+		return v.validateManifestV2(ctx, tag, dgst, fsManifest)
+	default:
+		v.addIssue(&ValidationIssue{
+			Type:     ValidationIssueUnknownMediaType,
+			Tag:      tag,
+			Manifest: dgst,
+			Detail:   fmt.Sprintf("unknown manifest class %T", m),
+		})
+	}
+
+	return nil
+}
+
+func (v *repositoryValidator) validateManifestV2(ctx context.Context, tag string, dgst digest.Digest, m distribution.ManifestV2) error {
+	if err := v.validateMediaType(ctx, tag, dgst, m.Version().MediaType, "manifest"); err != nil {
+		return err
+	}
+
+	cfg := m.Config()
+	if err := v.validateMediaType(ctx, tag, dgst, cfg.MediaType, "configuration"); err != nil {
+		return err
+	}
+	if err := v.validateBlob(ctx, tag, dgst, cfg.Digest, "configuration"); err != nil {
+		return err
+	}
+
+	for _, layer := range m.DistributableLayers() {
+		if err := v.validateMediaType(ctx, tag, dgst, layer.MediaType, "layer"); err != nil {
+			return err
+		}
+		if err := v.validateBlob(ctx, tag, dgst, layer.Digest, "layer"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *repositoryValidator) validateBlob(ctx context.Context, tag string, dgst, ref digest.Digest, kind string) error {
+	if ref == "" {
+		return nil
+	}
+
+	_, err := v.fsRepo.Blobs(ctx).Stat(ctx, ref)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, distribution.ErrBlobUnknown):
+		v.addIssue(&ValidationIssue{
+			Type:      ValidationIssueMissingBlob,
+			Tag:       tag,
+			Manifest:  dgst,
+			Reference: ref,
+			Detail:    kind + " blob is not linked to the repository",
+		})
+		return nil
+	case errors.Is(err, digest.ErrDigestInvalidFormat):
+		v.addIssue(&ValidationIssue{
+			Type:      ValidationIssueMissingBlob,
+			Tag:       tag,
+			Manifest:  dgst,
+			Reference: ref,
+			Detail:    "corrupted " + kind + " blob link",
+		})
+		return nil
+	default:
+		return fmt.Errorf("checking for access to blob %q: %w", ref, err)
+	}
+}
+
+// validateMediaType reports media types unknown to the database. This is skipped if the Importer has no database.
+func (v *repositoryValidator) validateMediaType(ctx context.Context, tag string, dgst digest.Digest, mediaType, kind string) error {
+	if v.imp.db == nil || mediaType == "" {
+		return nil
+	}
+
+	var exists bool
+	if cached, ok := v.mediaTypes.Load(mediaType); ok {
+		exists = cached.(bool)
+	} else {
+		var err error
+		exists, err = NewMediaTypeStore(v.imp.db).Exists(ctx, mediaType)
+		if err != nil {
+			return fmt.Errorf("checking for existence of media type %q: %w", mediaType, err)
+		}
+		v.mediaTypes.Store(mediaType, exists)
+	}
+
+	if !exists {
+		v.addIssue(&ValidationIssue{
+			Type:      ValidationIssueUnknownMediaType,
+			Tag:       tag,
+			Manifest:  dgst,
+			MediaType: mediaType,
+			Detail:    "unknown " + kind + " media type",
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,422 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/metrics"
+	gocache "github.com/eko/gocache/lib/v4/cache"
+	libstore "github.com/eko/gocache/lib/v4/store"
+	"github.com/opencontainers/go-digest"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// replicaCheckTimeout is the timeout applied to the replication lag check of each replica.
+const replicaCheckTimeout = 5 * time.Second
+
+type loadBalancerOpts struct {
+	logger               *logrus.Entry
+	replicaCheckInterval time.Duration
+	maxLagBytes          int64
+	maxLagTime           time.Duration
+	stickyDuration       time.Duration
+	stickyCache          StickyCache
+}
+
+// LoadBalancerOption is used to pass options to NewDBLoadBalancer.
+type LoadBalancerOption func(*loadBalancerOpts)
+
+// WithLoadBalancerLogger configures the logger for the load balancer.
+func WithLoadBalancerLogger(l *logrus.Entry) LoadBalancerOption {
+	return func(opts *loadBalancerOpts) {
+		opts.logger = l
+	}
+}
+
+// WithReplicaCheckInterval configures how often the replication lag of replicas is checked.
+func WithReplicaCheckInterval(d time.Duration) LoadBalancerOption {
+	return func(opts *loadBalancerOpts) {
+		opts.replicaCheckInterval = d
+	}
+}
+
+// WithMaxReplicationLag configures the maximum amount of WAL data and time that a replica can lag behind the primary
+// to be used for read-only queries. A replica is considered up to date if it's within both limits.
+func WithMaxReplicationLag(bytes int64, d time.Duration) LoadBalancerOption {
+	return func(opts *loadBalancerOpts) {
+		opts.maxLagBytes = bytes
+		opts.maxLagTime = d
+	}
+}
+
+// WithStickyDuration configures how long read-only queries for a repository are routed to the primary after a write
+// to that repository.
+func WithStickyDuration(d time.Duration) LoadBalancerOption {
+	return func(opts *loadBalancerOpts) {
+		opts.stickyDuration = d
+	}
+}
+
+// WithStickyCache configures the cache in which writes to repositories are recorded. Defaults to an in-memory cache,
+// which is local to the registry process. A cache shared by all registry instances, such as the one created with
+// NewCentralStickyCache, is required for clients to read their own writes when their requests are served by different
+// instances.
+func WithStickyCache(c StickyCache) LoadBalancerOption {
+	return func(opts *loadBalancerOpts) {
+		opts.stickyCache = c
+	}
+}
+
+func applyLoadBalancerOptions(opts []LoadBalancerOption) loadBalancerOpts {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	config := loadBalancerOpts{
+		logger:               logrus.NewEntry(log),
+		replicaCheckInterval: 30 * time.Second,
+		maxLagBytes:          8 * 1024 * 1024,
+		maxLagTime:           time.Minute,
+		stickyDuration:       30 * time.Second,
+	}
+
+	for _, v := range opts {
+		v(&config)
+	}
+	if config.stickyCache == nil {
+		config.stickyCache = newLocalStickyCache()
+	}
+
+	return config
+}
+
+// StickyCache records the repositories that were recently written to, for which read-only queries must be routed to
+// the primary.
+type StickyCache interface {
+	// Set records a write to the repository with the given path, which expires after ttl.
+	Set(ctx context.Context, path string, ttl time.Duration)
+	// Exists reports whether there is an unexpired write recorded for the repository with the given path.
+	Exists(ctx context.Context, path string) bool
+}
+
+// localStickyCache is an in-memory StickyCache, only shared by the load balancers of a single registry process.
+type localStickyCache struct {
+	mu sync.Mutex
+	// until maps repository paths to the time until which their read-only queries must use the primary.
+	until map[string]time.Time
+}
+
+func newLocalStickyCache() *localStickyCache {
+	return &localStickyCache{until: make(map[string]time.Time)}
+}
+
+// Set implements StickyCache.
+func (c *localStickyCache) Set(_ context.Context, path string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.until[path] = time.Now().Add(ttl)
+}
+
+// Exists implements StickyCache.
+func (c *localStickyCache) Exists(_ context.Context, path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[path]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(c.until, path)
+		return false
+	}
+
+	return true
+}
+
+// prune removes expired records, which would otherwise accumulate for repositories that are not read from after being
+// written to.
+func (c *localStickyCache) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for path, until := range c.until {
+		if now.After(until) {
+			delete(c.until, path)
+		}
+	}
+}
+
+// centralStickyCache is a StickyCache backed by Redis, shared by all registry instances.
+type centralStickyCache struct {
+	cache *gocache.Cache[any]
+}
+
+// NewCentralStickyCache creates a StickyCache backed by Redis, so that writes recorded by any registry instance are
+// seen by all of them. Failing to reach Redis is treated as if no write was recorded.
+func NewCentralStickyCache(cache *gocache.Cache[any]) StickyCache {
+	return &centralStickyCache{cache: cache}
+}
+
+// key generates a valid Redis key string for the sticky record of a repository. The used key format is described in
+// https://gitlab.com/gitlab-org/container-registry/-/blob/master/docs-gitlab/redis-dev-guidelines.md#key-format.
+func (c *centralStickyCache) key(path string) string {
+	nsPrefix := strings.Split(path, "/")[0]
+	hex := digest.FromString(path).Hex()
+	return fmt.Sprintf("registry:db:{repository:%s:%s}:sticky", nsPrefix, hex)
+}
+
+// Set implements StickyCache.
+func (c *centralStickyCache) Set(ctx context.Context, path string, ttl time.Duration) {
+	setCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	if err := c.cache.Set(setCtx, c.key(path), "1", libstore.WithExpiration(ttl)); err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to record repository write in cache")
+	}
+}
+
+// Exists implements StickyCache.
+func (c *centralStickyCache) Exists(ctx context.Context, path string) bool {
+	getCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	if _, err := c.cache.Get(getCtx, c.key(path)); err != nil {
+		// a wrapped redis.Nil is returned when the key is not found in Redis
+		if !errors.Is(err, redis.Nil) {
+			log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to read repository write from cache")
+		}
+		return false
+	}
+
+	return true
+}
+
+// replica is a database replica along with its latest known status.
+type replica struct {
+	*DB
+	name string
+	// up is 1 if the replica was reachable and up to date during the last check, 0 otherwise.
+	up int32
+}
+
+func (r *replica) isUp() bool {
+	return atomic.LoadInt32(&r.up) == 1
+}
+
+// setUp updates the replica status and reports whether it changed.
+func (r *replica) setUp(up bool) bool {
+	var v int32
+	if up {
+		v = 1
+	}
+	return atomic.SwapInt32(&r.up, v) != v
+}
+
+// DBLoadBalancer routes read-only queries across a set of replicas of the primary database. Replicas are used in a
+// round-robin fashion, skipping those that were unreachable or lagging too far behind the primary during the last
+// check. Queries for repositories that were recently written to stick to the primary, so that clients can read their
+// own writes. Writes are recorded in the configured StickyCache, which is local to the process unless otherwise set
+// (see WithStickyCache). The primary is used whenever no replica is available.
+type DBLoadBalancer struct {
+	primary  *DB
+	replicas []*replica
+	opts     loadBalancerOpts
+
+	// next is the round-robin counter used to select a replica.
+	next uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDBLoadBalancer creates a new load balancer for the primary and replicas database connection handlers. Replicas
+// are not used until their first successful check (see CheckReplicas and StartReplicaChecking).
+func NewDBLoadBalancer(primary *DB, replicas []*DB, opts ...LoadBalancerOption) *DBLoadBalancer {
+	lb := &DBLoadBalancer{
+		primary: primary,
+		opts:    applyLoadBalancerOptions(opts),
+		done:    make(chan struct{}),
+	}
+	for i, db := range replicas {
+		name := fmt.Sprintf("replica-%d", i)
+		if db.dsn != nil {
+			name = db.dsn.Address()
+		}
+		lb.replicas = append(lb.replicas, &replica{DB: db, name: name})
+	}
+
+	return lb
+}
+
+// Primary returns the primary database connection handler.
+func (lb *DBLoadBalancer) Primary() *DB {
+	return lb.primary
+}
+
+// Replicas returns the connection handlers of all replicas, regardless of their state.
+func (lb *DBLoadBalancer) Replicas() []*DB {
+	dbs := make([]*DB, 0, len(lb.replicas))
+	for _, r := range lb.replicas {
+		dbs = append(dbs, r.DB)
+	}
+
+	return dbs
+}
+
+// Replica returns a database connection handler for read-only queries related to the repository with the given path.
+// The path can be empty for queries that are not related to a specific repository. The primary is returned if there
+// was a recent write to the repository or if no replica is available.
+func (lb *DBLoadBalancer) Replica(ctx context.Context, path string) Handler {
+	if path != "" && lb.opts.stickyCache.Exists(ctx, path) {
+		return lb.primary
+	}
+
+	n := uint64(len(lb.replicas))
+	start := atomic.AddUint64(&lb.next, 1)
+	for i := uint64(0); i < n; i++ {
+		if r := lb.replicas[(start+i)%n]; r.isUp() {
+			return r.DB
+		}
+	}
+
+	return lb.primary
+}
+
+// RecordWrite records a write to the repository with the given path, making its read-only queries stick to the
+// primary for the configured sticky duration.
+func (lb *DBLoadBalancer) RecordWrite(ctx context.Context, path string) {
+	lb.opts.stickyCache.Set(ctx, path, lb.opts.stickyDuration)
+}
+
+// CheckReplicas checks the replication lag of all replicas, updating their status accordingly. A replica is only used
+// for read-only queries if it's reachable and lagging behind the primary by no more than the configured maximum
+// amount of WAL data and time. The lag in bytes can only be determined if the primary is reachable, otherwise only
+// the lag in time is checked.
+func (lb *DBLoadBalancer) CheckReplicas(ctx context.Context) {
+	l := lb.opts.logger
+
+	primaryLSN, err := lb.primaryLSN(ctx)
+	if err != nil {
+		l.WithError(err).Warn("failed to get primary database WAL position, checking replication lag by time only")
+	}
+
+	for _, r := range lb.replicas {
+		rl := l.WithFields(logrus.Fields{"replica": r.name})
+
+		lagBytes, lagTime, err := replicationLag(ctx, r.DB, primaryLSN)
+		up := err == nil && lagTime <= lb.opts.maxLagTime && (primaryLSN == "" || lagBytes <= lb.opts.maxLagBytes)
+
+		if !r.setUp(up) {
+			continue
+		}
+		switch {
+		case up:
+			rl.Info("database replica is up to date, routing read-only queries to it")
+		case err != nil:
+			rl.WithError(err).Warn("failed to check database replica, routing read-only queries away from it")
+		default:
+			rl.WithFields(logrus.Fields{
+				"lag_bytes": lagBytes,
+				"lag_s":     lagTime.Seconds(),
+			}).Warn("database replica is lagging behind, routing read-only queries away from it")
+		}
+	}
+
+	if c, ok := lb.opts.stickyCache.(*localStickyCache); ok {
+		c.prune()
+	}
+}
+
+func (lb *DBLoadBalancer) primaryLSN(ctx context.Context) (string, error) {
+	defer metrics.InstrumentQuery(ctx, "load_balancer_primary_lsn")()
+
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	var lsn string
+	if err := lb.primary.QueryRowContext(ctx, "SELECT pg_current_wal_insert_lsn()::text").Scan(&lsn); err != nil {
+		return "", fmt.Errorf("getting primary WAL insert position: %w", err)
+	}
+
+	return lsn, nil
+}
+
+// replicationLag determines the replication lag of a replica, in bytes of WAL data behind primaryLSN and in time since
+// the last replayed transaction. The lag in time is zero if the replica replayed all the WAL data it received, as the
+// time since the last replayed transaction only grows while there are no writes on the primary. The lag in bytes is
+// zero if primaryLSN is empty.
+func replicationLag(ctx context.Context, db Queryer, primaryLSN string) (int64, time.Duration, error) {
+	defer metrics.InstrumentQuery(ctx, "load_balancer_replication_lag")()
+
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	var lagSeconds float64
+	q := `SELECT
+			CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN
+				0
+			ELSE
+				COALESCE(EXTRACT(EPOCH FROM (now() - pg_last_xact_replay_timestamp())), 0)
+			END::float8`
+	if err := db.QueryRowContext(ctx, q).Scan(&lagSeconds); err != nil {
+		return 0, 0, fmt.Errorf("getting replication lag time: %w", err)
+	}
+	lagTime := time.Duration(lagSeconds * float64(time.Second))
+
+	if primaryLSN == "" {
+		return 0, lagTime, nil
+	}
+
+	var lagBytes int64
+	q = "SELECT COALESCE(pg_wal_lsn_diff($1::pg_lsn, pg_last_wal_replay_lsn()), 0)::bigint"
+	if err := db.QueryRowContext(ctx, q, primaryLSN).Scan(&lagBytes); err != nil {
+		return 0, 0, fmt.Errorf("getting replication lag bytes: %w", err)
+	}
+
+	return lagBytes, lagTime, nil
+}
+
+// StartReplicaChecking checks the replicas immediately and then periodically, according to the configured interval,
+// until ctx is done or the load balancer is closed. This method blocks and should be run in a goroutine.
+func (lb *DBLoadBalancer) StartReplicaChecking(ctx context.Context) {
+	t := time.NewTicker(lb.opts.replicaCheckInterval)
+	defer t.Stop()
+
+	for {
+		lb.CheckReplicas(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-lb.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Close stops the replica checking and closes the connection handlers of all replicas. The primary connection handler
+// is not closed, as it's owned by the caller.
+func (lb *DBLoadBalancer) Close() error {
+	lb.closeOnce.Do(func() { close(lb.done) })
+
+	// all replicas are closed regardless of errors, but only the first one is returned
+	var err error
+	for _, r := range lb.replicas {
+		if closeErr := r.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing replica %s: %w", r.name, closeErr)
+		}
+	}
+
+	return err
+}
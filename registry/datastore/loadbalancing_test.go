@@ -0,0 +1,188 @@
+package datastore_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func mockDB(t *testing.T) (*datastore.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return &datastore.DB{DB: db}, mock
+}
+
+var (
+	primaryLSNQuery = regexp.QuoteMeta("SELECT pg_current_wal_insert_lsn()::text")
+	lagTimeQuery    = "SELECT.+CASE WHEN pg_last_wal_receive_lsn\\(\\) = pg_last_wal_replay_lsn\\(\\)"
+	lagBytesQuery   = regexp.QuoteMeta("SELECT COALESCE(pg_wal_lsn_diff($1::pg_lsn, pg_last_wal_replay_lsn()), 0)::bigint")
+)
+
+func expectReplicationLag(mock sqlmock.Sqlmock, lsn string, lagSeconds float64, lagBytes int64) {
+	mock.ExpectQuery(lagTimeQuery).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(lagSeconds))
+	if lsn != "" {
+		mock.ExpectQuery(lagBytesQuery).WithArgs(lsn).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(lagBytes))
+	}
+}
+
+func TestDBLoadBalancer_Replica(t *testing.T) {
+	primary, primaryMock := mockDB(t)
+	replica1, replica1Mock := mockDB(t)
+	replica2, replica2Mock := mockDB(t)
+
+	lb := datastore.NewDBLoadBalancer(primary, []*datastore.DB{replica1, replica2},
+		datastore.WithMaxReplicationLag(100, time.Minute))
+	ctx := context.Background()
+
+	// replicas are not used before being checked
+	require.Same(t, primary, lb.Replica(ctx, "foo/bar"))
+
+	// replica 1 is up to date, replica 2 is lagging behind in bytes
+	lsn := "0/3000060"
+	primaryMock.ExpectQuery(primaryLSNQuery).WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow(lsn))
+	expectReplicationLag(replica1Mock, lsn, 1, 50)
+	expectReplicationLag(replica2Mock, lsn, 1, 200)
+	lb.CheckReplicas(ctx)
+
+	for i := 0; i < 3; i++ {
+		require.Same(t, replica1, lb.Replica(ctx, "foo/bar"))
+	}
+
+	// replica 1 is up to date in bytes but lagging behind in time, replica 2 is up to date
+	primaryMock.ExpectQuery(primaryLSNQuery).WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow(lsn))
+	expectReplicationLag(replica1Mock, lsn, 3600, 50)
+	expectReplicationLag(replica2Mock, lsn, 1, 50)
+	lb.CheckReplicas(ctx)
+
+	for i := 0; i < 3; i++ {
+		require.Same(t, replica2, lb.Replica(ctx, "foo/bar"))
+	}
+
+	// both replicas are up to date and used in a round-robin fashion
+	primaryMock.ExpectQuery(primaryLSNQuery).WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow(lsn))
+	expectReplicationLag(replica1Mock, lsn, 0, 0)
+	expectReplicationLag(replica2Mock, lsn, 0, 0)
+	lb.CheckReplicas(ctx)
+
+	first := lb.Replica(ctx, "foo/bar")
+	second := lb.Replica(ctx, "foo/bar")
+	require.NotSame(t, first, second)
+	require.Contains(t, []datastore.Handler{replica1, replica2}, first)
+	require.Contains(t, []datastore.Handler{replica1, replica2}, second)
+
+	// if the primary is unreachable the lag is checked by time only, replica 1 is unreachable
+	primaryMock.ExpectQuery(primaryLSNQuery).WillReturnError(errors.New("primary down"))
+	replica1Mock.ExpectQuery(lagTimeQuery).WillReturnError(errors.New("replica down"))
+	expectReplicationLag(replica2Mock, "", 1, 0)
+	lb.CheckReplicas(ctx)
+
+	for i := 0; i < 3; i++ {
+		require.Same(t, replica2, lb.Replica(ctx, "foo/bar"))
+	}
+
+	// no replicas are up to date
+	primaryMock.ExpectQuery(primaryLSNQuery).WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow(lsn))
+	replica1Mock.ExpectQuery(lagTimeQuery).WillReturnError(errors.New("replica down"))
+	expectReplicationLag(replica2Mock, lsn, 3600, 200)
+	lb.CheckReplicas(ctx)
+
+	require.Same(t, primary, lb.Replica(ctx, "foo/bar"))
+
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replica1Mock.ExpectationsWereMet())
+	require.NoError(t, replica2Mock.ExpectationsWereMet())
+}
+
+func TestDBLoadBalancer_RecordWrite(t *testing.T) {
+	primary, primaryMock := mockDB(t)
+	replica, replicaMock := mockDB(t)
+
+	lb := datastore.NewDBLoadBalancer(primary, []*datastore.DB{replica}, datastore.WithStickyDuration(50*time.Millisecond))
+	ctx := context.Background()
+
+	primaryMock.ExpectQuery(primaryLSNQuery).WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/0"))
+	expectReplicationLag(replicaMock, "0/0", 0, 0)
+	lb.CheckReplicas(ctx)
+
+	require.Same(t, replica, lb.Replica(ctx, "foo/bar"))
+
+	// queries for a recently written repository stick to the primary, while others are unaffected
+	lb.RecordWrite(ctx, "foo/bar")
+	require.Same(t, primary, lb.Replica(ctx, "foo/bar"))
+	require.Same(t, replica, lb.Replica(ctx, "foo/baz"))
+	require.Same(t, replica, lb.Replica(ctx, ""))
+
+	// until the sticky duration elapses
+	require.Eventually(t, func() bool { return lb.Replica(ctx, "foo/bar") == datastore.Handler(replica) }, time.Second, 10*time.Millisecond)
+}
+
+func TestDBLoadBalancer_RecordWrite_CentralStickyCache(t *testing.T) {
+	primary, primaryMock := mockDB(t)
+	replica, replicaMock := mockDB(t)
+	c := testutil.NewRedisCacheController(t, 0)
+	ctx := context.Background()
+
+	// load balancers of two registry instances sharing the same cache
+	lb1 := datastore.NewDBLoadBalancer(primary, []*datastore.DB{replica},
+		datastore.WithStickyDuration(time.Minute), datastore.WithStickyCache(datastore.NewCentralStickyCache(c.Cache)))
+	lb2 := datastore.NewDBLoadBalancer(primary, []*datastore.DB{replica},
+		datastore.WithStickyDuration(time.Minute), datastore.WithStickyCache(datastore.NewCentralStickyCache(c.Cache)))
+
+	for _, lb := range []*datastore.DBLoadBalancer{lb1, lb2} {
+		primaryMock.ExpectQuery(primaryLSNQuery).WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/0"))
+		expectReplicationLag(replicaMock, "0/0", 0, 0)
+		lb.CheckReplicas(ctx)
+	}
+
+	// a write recorded by one instance makes the queries of the other stick to the primary
+	lb1.RecordWrite(ctx, "foo/bar")
+	require.Same(t, primary, lb2.Replica(ctx, "foo/bar"))
+	require.Same(t, replica, lb2.Replica(ctx, "foo/baz"))
+
+	key := "registry:db:{repository:foo:cc5d46bdb4991c6eae3eb739c9c8a7a46fe9654fab79c47b4fe48383b5b25e1c}:sticky"
+	require.True(t, c.Exists(key))
+	require.Equal(t, time.Minute, c.TTL(key))
+}
+
+func TestDBLoadBalancer_StartReplicaChecking(t *testing.T) {
+	primary, primaryMock := mockDB(t)
+	replica, replicaMock := mockDB(t)
+
+	lb := datastore.NewDBLoadBalancer(primary, []*datastore.DB{replica}, datastore.WithReplicaCheckInterval(time.Hour))
+	ctx := context.Background()
+
+	primaryMock.ExpectQuery(primaryLSNQuery).WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/0"))
+	expectReplicationLag(replicaMock, "0/0", 0, 0)
+	replicaMock.ExpectClose()
+
+	done := make(chan struct{})
+	go func() {
+		lb.StartReplicaChecking(ctx)
+		close(done)
+	}()
+
+	// replicas are checked immediately
+	require.Eventually(t, func() bool { return lb.Replica(ctx, "") == datastore.Handler(replica) }, time.Second, 10*time.Millisecond)
+
+	// and checking stops once the load balancer is closed
+	require.NoError(t, lb.Close())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("replica checking did not stop after closing the load balancer")
+	}
+
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replicaMock.ExpectationsWereMet())
+}
@@ -8,6 +8,9 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/helm"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/registry/datastore/metrics"
 	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/jackc/pgconn"
@@ -29,6 +32,8 @@ type ManifestWriter interface {
 	CreateOrFind(ctx context.Context, m *models.Manifest) error
 	AssociateManifest(ctx context.Context, ml *models.Manifest, m *models.Manifest) error
 	DissociateManifest(ctx context.Context, ml *models.Manifest, m *models.Manifest) error
+	AssociateSubject(ctx context.Context, m *models.Manifest, subject *models.Manifest) (bool, error)
+	AssociateTagSchemaReferrer(ctx context.Context, m *models.Manifest, subject *models.Manifest) (bool, error)
 	AssociateLayerBlob(ctx context.Context, m *models.Manifest, b *models.Blob) error
 	DissociateLayerBlob(ctx context.Context, m *models.Manifest, b *models.Blob) error
 	Delete(ctx context.Context, namespaceID, repositoryID, id int64) (*digest.Digest, error)
@@ -57,7 +62,8 @@ func scanFullManifest(row *sql.Row) (*models.Manifest, error) {
 	m := new(models.Manifest)
 
 	err := row.Scan(&m.ID, &m.NamespaceID, &m.RepositoryID, &m.TotalSize, &m.SchemaVersion, &m.MediaType, &dgst, &m.Payload,
-		&cfgMediaType, &cfgDigest, &cfgPayload, &m.NonConformant, &m.NonDistributableLayers, &m.SubjectID, &m.CreatedAt)
+		&cfgMediaType, &cfgDigest, &cfgPayload, &m.NonConformant, &m.NonDistributableLayers, &m.SubjectID, &m.ArtifactType,
+		&m.ChartName, &m.ChartVersion, &m.CreatedAt)
 	if err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("scanning manifest: %w", err)
@@ -98,7 +104,8 @@ func scanFullManifests(rows *sql.Rows) (models.Manifests, error) {
 		m := new(models.Manifest)
 
 		err := rows.Scan(&m.ID, &m.NamespaceID, &m.RepositoryID, &m.TotalSize, &m.SchemaVersion, &m.MediaType, &dgst, &m.Payload,
-			&cfgMediaType, &cfgDigest, &cfgPayload, &m.NonConformant, &m.NonDistributableLayers, &m.SubjectID, &m.CreatedAt)
+			&cfgMediaType, &cfgDigest, &cfgPayload, &m.NonConformant, &m.NonDistributableLayers, &m.SubjectID, &m.ArtifactType,
+			&m.ChartName, &m.ChartVersion, &m.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("scanning manifest: %w", err)
 		}
@@ -133,7 +140,7 @@ func scanFullManifests(rows *sql.Rows) (models.Manifests, error) {
 
 // FindAll finds all manifests.
 func (s *manifestStore) FindAll(ctx context.Context) (models.Manifests, error) {
-	defer metrics.InstrumentQuery("manifest_find_all")()
+	defer metrics.InstrumentQuery(ctx, "manifest_find_all")()
 	q := `SELECT
 			m.id,
 			m.top_level_namespace_id,
@@ -149,11 +156,15 @@ func (s *manifestStore) FindAll(ctx context.Context) (models.Manifests, error) {
 			m.non_conformant,
 			m.non_distributable_layers,
 			m.subject_id,
+			mta.media_type as artifact_media_type,
+			m.chart_name,
+			m.chart_version,
 			m.created_at
 		FROM
 			manifests AS m
 			JOIN media_types AS mt ON mt.id = m.media_type_id
 			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
 		ORDER BY
 			id`
 
@@ -167,7 +178,7 @@ func (s *manifestStore) FindAll(ctx context.Context) (models.Manifests, error) {
 
 // Count counts all manifests.
 func (s *manifestStore) Count(ctx context.Context) (int, error) {
-	defer metrics.InstrumentQuery("manifest_count")()
+	defer metrics.InstrumentQuery(ctx, "manifest_count")()
 	q := "SELECT COUNT(*) FROM manifests"
 	var count int
 
@@ -180,7 +191,7 @@ func (s *manifestStore) Count(ctx context.Context) (int, error) {
 
 // LayerBlobs finds layer blobs associated with a manifest, through the `layers` relationship entity.
 func (s *manifestStore) LayerBlobs(ctx context.Context, m *models.Manifest) (models.Blobs, error) {
-	defer metrics.InstrumentQuery("manifest_layer_blobs")()
+	defer metrics.InstrumentQuery(ctx, "manifest_layer_blobs")()
 	q := `SELECT
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
@@ -205,7 +216,7 @@ func (s *manifestStore) LayerBlobs(ctx context.Context, m *models.Manifest) (mod
 
 // References finds all manifests directly referenced by a manifest (if any).
 func (s *manifestStore) References(ctx context.Context, m *models.Manifest) (models.Manifests, error) {
-	defer metrics.InstrumentQuery("manifest_references")()
+	defer metrics.InstrumentQuery(ctx, "manifest_references")()
 	q := `SELECT DISTINCT
 			m.id,
 			m.top_level_namespace_id,
@@ -221,6 +232,9 @@ func (s *manifestStore) References(ctx context.Context, m *models.Manifest) (mod
 			m.non_conformant,
 			m.non_distributable_layers,
 			m.subject_id,
+			mta.media_type as artifact_media_type,
+			m.chart_name,
+			m.chart_version,
 			m.created_at
 		FROM
 			manifests AS m
@@ -228,6 +242,7 @@ func (s *manifestStore) References(ctx context.Context, m *models.Manifest) (mod
 				AND mr.child_id = m.id
 			JOIN media_types AS mt ON mt.id = m.media_type_id
 			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
 		WHERE
 			m.top_level_namespace_id = $1
 			AND mr.repository_id = $2
@@ -263,10 +278,11 @@ func mapMediaType(ctx context.Context, db Queryer, mediaType string) (int, error
 
 // Create saves a new Manifest.
 func (s *manifestStore) Create(ctx context.Context, m *models.Manifest) error {
-	defer metrics.InstrumentQuery("manifest_create")()
+	defer metrics.InstrumentQuery(ctx, "manifest_create")()
 	q := `INSERT INTO manifests (top_level_namespace_id, repository_id, total_size, schema_version, media_type_id, digest, payload,
-				configuration_media_type_id, configuration_blob_digest, configuration_payload, non_conformant, non_distributable_layers, subject_id)
-			VALUES ($1, $2, $3, $4, $5, decode($6, 'hex'), $7, $8, decode($9, 'hex'), $10, $11, $12, $13)
+				configuration_media_type_id, configuration_blob_digest, configuration_payload, non_conformant, non_distributable_layers, subject_id,
+				artifact_media_type_id, chart_name, chart_version)
+			VALUES ($1, $2, $3, $4, $5, decode($6, 'hex'), $7, $8, decode($9, 'hex'), $10, $11, $12, $13, $14, $15, $16)
 		RETURNING
 			id, created_at`
 
@@ -298,8 +314,19 @@ func (s *manifestStore) Create(ctx context.Context, m *models.Manifest) error {
 		configPayload = &m.Configuration.Payload
 	}
 
+	var artifactMediaTypeID sql.NullInt32
+	if m.ArtifactType.Valid {
+		id, err := mapMediaType(ctx, s.db, m.ArtifactType.String)
+		if err != nil {
+			return fmt.Errorf("mapping artifact media type: %w", err)
+		}
+		artifactMediaTypeID.Valid = true
+		artifactMediaTypeID.Int32 = int32(id)
+	}
+
 	row := s.db.QueryRowContext(ctx, q, m.NamespaceID, m.RepositoryID, m.TotalSize, m.SchemaVersion, mediaTypeID, dgst, m.Payload,
-		configMediaTypeID, configDgst, configPayload, m.NonConformant, m.NonDistributableLayers, m.SubjectID)
+		configMediaTypeID, configDgst, configPayload, m.NonConformant, m.NonDistributableLayers, m.SubjectID, artifactMediaTypeID,
+		m.ChartName, m.ChartVersion)
 	if err := row.Scan(&m.ID, &m.CreatedAt); err != nil {
 		return fmt.Errorf("creating manifest: %w", err)
 	}
@@ -313,10 +340,11 @@ func (s *manifestStore) Create(ctx context.Context, m *models.Manifest) error {
 // and write (Create) operations.
 // Separate Find* and Create method calls should be preferred to this when race conditions are not a concern.
 func (s *manifestStore) CreateOrFind(ctx context.Context, m *models.Manifest) error {
-	defer metrics.InstrumentQuery("manifest_create_or_find")()
+	defer metrics.InstrumentQuery(ctx, "manifest_create_or_find")()
 	q := `INSERT INTO manifests (top_level_namespace_id, repository_id, total_size, schema_version, media_type_id, digest, payload,
-				configuration_media_type_id, configuration_blob_digest, configuration_payload, non_conformant, non_distributable_layers, subject_id)
-			VALUES ($1, $2, $3, $4, $5, decode($6, 'hex'), $7, $8, decode($9, 'hex'), $10, $11, $12, $13)
+				configuration_media_type_id, configuration_blob_digest, configuration_payload, non_conformant, non_distributable_layers, subject_id,
+				artifact_media_type_id, chart_name, chart_version)
+			VALUES ($1, $2, $3, $4, $5, decode($6, 'hex'), $7, $8, decode($9, 'hex'), $10, $11, $12, $13, $14, $15, $16)
 			ON CONFLICT (top_level_namespace_id, repository_id, digest) DO NOTHING
 		RETURNING
 			id, created_at`
@@ -349,8 +377,19 @@ func (s *manifestStore) CreateOrFind(ctx context.Context, m *models.Manifest) er
 		configPayload = &m.Configuration.Payload
 	}
 
+	var artifactMediaTypeID sql.NullInt32
+	if m.ArtifactType.Valid {
+		id, err := mapMediaType(ctx, s.db, m.ArtifactType.String)
+		if err != nil {
+			return fmt.Errorf("mapping artifact media type: %w", err)
+		}
+		artifactMediaTypeID.Valid = true
+		artifactMediaTypeID.Int32 = int32(id)
+	}
+
 	row := s.db.QueryRowContext(ctx, q, m.NamespaceID, m.RepositoryID, m.TotalSize, m.SchemaVersion, mediaTypeID, dgst, m.Payload,
-		configMediaTypeID, configDgst, configPayload, m.NonConformant, m.NonDistributableLayers, m.SubjectID)
+		configMediaTypeID, configDgst, configPayload, m.NonConformant, m.NonDistributableLayers, m.SubjectID, artifactMediaTypeID,
+		m.ChartName, m.ChartVersion)
 	if err := row.Scan(&m.ID, &m.CreatedAt); err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			return fmt.Errorf("creating manifest: %w", err)
@@ -367,7 +406,7 @@ func (s *manifestStore) CreateOrFind(ctx context.Context, m *models.Manifest) er
 
 // AssociateManifest associates a manifest with a manifest list. It does nothing if already associated.
 func (s *manifestStore) AssociateManifest(ctx context.Context, ml *models.Manifest, m *models.Manifest) error {
-	defer metrics.InstrumentQuery("manifest_associate_manifest")()
+	defer metrics.InstrumentQuery(ctx, "manifest_associate_manifest")()
 	if ml.ID == m.ID {
 		return fmt.Errorf("cannot associate a manifest with itself")
 	}
@@ -391,7 +430,7 @@ func (s *manifestStore) AssociateManifest(ctx context.Context, ml *models.Manife
 
 // DissociateManifest dissociates a manifest and a manifest list. It does nothing if not associated.
 func (s *manifestStore) DissociateManifest(ctx context.Context, ml *models.Manifest, m *models.Manifest) error {
-	defer metrics.InstrumentQuery("manifest_dissociate_manifest")()
+	defer metrics.InstrumentQuery(ctx, "manifest_dissociate_manifest")()
 	q := `DELETE FROM manifest_references
 		WHERE top_level_namespace_id = $1
 			AND repository_id = $2
@@ -410,9 +449,81 @@ func (s *manifestStore) DissociateManifest(ctx context.Context, ml *models.Manif
 	return nil
 }
 
+// AssociateSubject sets subject as the subject of manifest m, making m one of its referrers. It does nothing and
+// returns false if m already has a subject.
+func (s *manifestStore) AssociateSubject(ctx context.Context, m *models.Manifest, subject *models.Manifest) (bool, error) {
+	defer metrics.InstrumentQuery(ctx, "manifest_associate_subject")()
+	if m.ID == subject.ID {
+		return false, fmt.Errorf("cannot associate a manifest with itself")
+	}
+
+	q := `UPDATE
+			manifests
+		SET
+			subject_id = $4
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND id = $3
+			AND subject_id IS NULL`
+
+	res, err := s.db.ExecContext(ctx, q, m.NamespaceID, m.RepositoryID, m.ID, subject.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		// this can happen if the subject is deleted by the online GC while attempting to associate it
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return false, ErrRefManifestNotFound
+		}
+		return false, fmt.Errorf("associating subject: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("associating subject: %w", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	m.SubjectID = sql.NullInt64{Int64: subject.ID, Valid: true}
+	return true, nil
+}
+
+// AssociateTagSchemaReferrer records manifest m as a referrer of subject found through the referrers tag schema. These
+// associations are kept apart from the manifest subject_id, which only reflects the manifest payload, so that they are
+// not followed when cascading subject deletes. It does nothing and returns false if already associated.
+func (s *manifestStore) AssociateTagSchemaReferrer(ctx context.Context, m *models.Manifest, subject *models.Manifest) (bool, error) {
+	defer metrics.InstrumentQuery(ctx, "manifest_associate_tag_schema_referrer")()
+	if m.ID == subject.ID {
+		return false, fmt.Errorf("cannot associate a manifest with itself")
+	}
+
+	q := `INSERT INTO tag_schema_referrers (top_level_namespace_id, repository_id, subject_id, referrer_id)
+			VALUES ($1, $2, $3, $4)
+		ON CONFLICT (top_level_namespace_id, repository_id, subject_id, referrer_id)
+			DO NOTHING`
+
+	res, err := s.db.ExecContext(ctx, q, m.NamespaceID, m.RepositoryID, subject.ID, m.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		// this can happen if either manifest is deleted by the online GC while attempting to associate them
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return false, ErrRefManifestNotFound
+		}
+		return false, fmt.Errorf("associating tag schema referrer: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("associating tag schema referrer: %w", err)
+	}
+
+	return n > 0, nil
+}
+
 // AssociateLayerBlob associates a layer blob and a manifest. It does nothing if already associated.
 func (s *manifestStore) AssociateLayerBlob(ctx context.Context, m *models.Manifest, b *models.Blob) error {
-	defer metrics.InstrumentQuery("manifest_associate_layer_blob")()
+	defer metrics.InstrumentQuery(ctx, "manifest_associate_layer_blob")()
 	q := `INSERT INTO layers (top_level_namespace_id, repository_id, manifest_id, digest, media_type_id, size)
 			VALUES ($1, $2, $3, decode($4, 'hex'), $5, $6)
 		ON CONFLICT (top_level_namespace_id, repository_id, manifest_id, digest)
@@ -436,7 +547,7 @@ func (s *manifestStore) AssociateLayerBlob(ctx context.Context, m *models.Manife
 
 // DissociateLayerBlob dissociates a layer blob and a manifest. It does nothing if not associated.
 func (s *manifestStore) DissociateLayerBlob(ctx context.Context, m *models.Manifest, b *models.Blob) error {
-	defer metrics.InstrumentQuery("manifest_dissociate_layer_blob")()
+	defer metrics.InstrumentQuery(ctx, "manifest_dissociate_layer_blob")()
 	q := `DELETE FROM layers
 		WHERE top_level_namespace_id = $1
 			AND repository_id = $2
@@ -464,7 +575,7 @@ func (s *manifestStore) DissociateLayerBlob(ctx context.Context, m *models.Manif
 // need for a separate preceding `SELECT` to find if it exists. A manifest cannot be deleted if it is referenced by a
 // manifest list.
 func (s *manifestStore) Delete(ctx context.Context, namespaceID, repositoryID, id int64) (*digest.Digest, error) {
-	defer metrics.InstrumentQuery("manifest_delete")()
+	defer metrics.InstrumentQuery(ctx, "manifest_delete")()
 	q := `DELETE FROM manifests
 		WHERE top_level_namespace_id = $1
 			AND repository_id = $2
@@ -510,11 +621,15 @@ func findManifestByDigest(ctx context.Context, db Queryer, namespaceID, reposito
 			m.non_conformant,
 			m.non_distributable_layers,
 			m.subject_id,
+			mta.media_type as artifact_media_type,
+			m.chart_name,
+			m.chart_version,
 			m.created_at
 		FROM
 			manifests AS m
 			JOIN media_types AS mt ON mt.id = m.media_type_id
 			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
 		WHERE
 			m.top_level_namespace_id = $1
 			AND m.repository_id = $2
@@ -524,3 +639,22 @@ func findManifestByDigest(ctx context.Context, db Queryer, namespaceID, reposito
 
 	return scanFullManifest(row)
 }
+
+// SetHelmChartMetadata records the name and version of the Helm chart described by mfst in m, if mfst is a Helm chart.
+// The configuration of m, including its payload if within ConfigSizeLimit, must be set already.
+func SetHelmChartMetadata(m *models.Manifest, mfst distribution.Manifest) {
+	if m.Configuration == nil {
+		return
+	}
+
+	var annotations map[string]string
+	if om, ok := mfst.(*ocischema.DeserializedManifest); ok {
+		annotations = om.Annotations
+	}
+	md, ok := helm.ChartMetadata(m.Configuration.MediaType, annotations, m.Configuration.Payload)
+	if !ok {
+		return
+	}
+	m.ChartName = sql.NullString{String: md.Name, Valid: md.Name != ""}
+	m.ChartVersion = sql.NullString{String: md.Version, Valid: md.Version != ""}
+}
@@ -3,6 +3,7 @@
 package datastore_test
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"testing"
@@ -588,6 +589,76 @@ func TestManifestStore_Create(t *testing.T) {
 	require.NotEmpty(t, m.CreatedAt)
 }
 
+func TestManifestStore_Create_WithArtifactType(t *testing.T) {
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.ManifestsTable))
+
+	s := datastore.NewManifestStore(suite.db)
+	m := &models.Manifest{
+		NamespaceID:   2,
+		RepositoryID:  7,
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.oci.image.manifest.v1+json",
+		Digest:        "sha256:46b163863b462eadc1b17dca382ccbfb08a853cffc79e2049607f95455cc44fa",
+		Payload:       models.Payload(`{"schemaVersion":2,"artifactType":"application/json","config":{}}`),
+		ArtifactType:  sql.NullString{String: "application/json", Valid: true},
+	}
+	err := s.Create(suite.ctx, m)
+	require.NoError(t, err)
+
+	r := &models.Repository{NamespaceID: 2, ID: 7}
+	got, err := datastore.NewRepositoryStore(suite.db).FindManifestByDigest(suite.ctx, r, m.Digest)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Equal(t, m.ArtifactType, got.ArtifactType)
+}
+
+func TestManifestStore_Create_WithChartMetadata(t *testing.T) {
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.ManifestsTable))
+
+	s := datastore.NewManifestStore(suite.db)
+	m := &models.Manifest{
+		NamespaceID:   2,
+		RepositoryID:  7,
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.oci.image.manifest.v1+json",
+		Digest:        "sha256:46b163863b462eadc1b17dca382ccbfb08a853cffc79e2049607f95455cc44fa",
+		Payload:       models.Payload(`{"schemaVersion":2,"config":{}}`),
+		ChartName:     sql.NullString{String: "my-chart", Valid: true},
+		ChartVersion:  sql.NullString{String: "1.2.3", Valid: true},
+	}
+	err := s.Create(suite.ctx, m)
+	require.NoError(t, err)
+
+	r := &models.Repository{NamespaceID: 2, ID: 7}
+	got, err := datastore.NewRepositoryStore(suite.db).FindManifestByDigest(suite.ctx, r, m.Digest)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Equal(t, m.ChartName, got.ChartName)
+	require.Equal(t, m.ChartVersion, got.ChartVersion)
+}
+
+func TestManifestStore_Create_UnknownArtifactType(t *testing.T) {
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.ManifestsTable))
+
+	s := datastore.NewManifestStore(suite.db)
+	m := &models.Manifest{
+		NamespaceID:   2,
+		RepositoryID:  7,
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.oci.image.manifest.v1+json",
+		Digest:        "sha256:46b163863b462eadc1b17dca382ccbfb08a853cffc79e2049607f95455cc44fa",
+		Payload:       models.Payload(`{"schemaVersion":2,"artifactType":"application/vnd.foo","config":{}}`),
+		ArtifactType:  sql.NullString{String: "application/vnd.foo", Valid: true},
+	}
+	err := s.Create(suite.ctx, m)
+	var mtErr datastore.ErrUnknownMediaType
+	require.True(t, errors.As(err, &mtErr))
+	require.Equal(t, m.ArtifactType.String, mtErr.MediaType)
+}
+
 func TestManifestStore_Create_NonUniqueDigestFails(t *testing.T) {
 	reloadManifestFixtures(t)
 
@@ -893,6 +964,98 @@ func TestManifestStore_DissociateManifest_NotAssociatedDoesNotFail(t *testing.T)
 	require.NoError(t, err)
 }
 
+func TestManifestStore_AssociateSubject(t *testing.T) {
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.CreateByPath(suite.ctx, randomRepository(t).Path)
+	require.NoError(t, err)
+
+	s := datastore.NewManifestStore(suite.db)
+	subject := randomManifest(t, r, nil)
+	require.NoError(t, s.Create(suite.ctx, subject))
+	m := randomManifest(t, r, nil)
+	require.NoError(t, s.Create(suite.ctx, m))
+
+	ok, err := s.AssociateSubject(suite.ctx, m, subject)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, sql.NullInt64{Int64: subject.ID, Valid: true}, m.SubjectID)
+
+	mm, err := rs.ManifestReferrers(suite.ctx, r, subject)
+	require.NoError(t, err)
+	require.Len(t, mm, 1)
+	require.Equal(t, m.ID, mm[0].ID)
+
+	// the subject of a manifest is never replaced
+	other := randomManifest(t, r, nil)
+	require.NoError(t, s.Create(suite.ctx, other))
+	ok, err = s.AssociateSubject(suite.ctx, m, other)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, sql.NullInt64{Int64: subject.ID, Valid: true}, m.SubjectID)
+}
+
+func TestManifestStore_AssociateSubject_WithItselfFails(t *testing.T) {
+	s := datastore.NewManifestStore(suite.db)
+
+	m := &models.Manifest{NamespaceID: 2, RepositoryID: 6, ID: 5}
+	_, err := s.AssociateSubject(suite.ctx, m, m)
+	require.Error(t, err)
+}
+
+func TestManifestStore_AssociateTagSchemaReferrer(t *testing.T) {
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.CreateByPath(suite.ctx, randomRepository(t).Path)
+	require.NoError(t, err)
+
+	s := datastore.NewManifestStore(suite.db)
+	subject := randomManifest(t, r, nil)
+	require.NoError(t, s.Create(suite.ctx, subject))
+	m := randomManifest(t, r, nil)
+	require.NoError(t, s.Create(suite.ctx, m))
+
+	ok, err := s.AssociateTagSchemaReferrer(suite.ctx, m, subject)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	mm, err := rs.ManifestTagSchemaReferrers(suite.ctx, r, subject)
+	require.NoError(t, err)
+	require.Len(t, mm, 1)
+	require.Equal(t, m.ID, mm[0].ID)
+
+	// the subject of the manifest is left untouched
+	require.False(t, mm[0].SubjectID.Valid)
+	mm, err = rs.ManifestReferrers(suite.ctx, r, subject)
+	require.NoError(t, err)
+	require.Empty(t, mm)
+
+	// associating again does nothing
+	ok, err = s.AssociateTagSchemaReferrer(suite.ctx, m, subject)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestManifestStore_AssociateTagSchemaReferrer_WithItselfFails(t *testing.T) {
+	s := datastore.NewManifestStore(suite.db)
+
+	m := &models.Manifest{NamespaceID: 2, RepositoryID: 6, ID: 5}
+	_, err := s.AssociateTagSchemaReferrer(suite.ctx, m, m)
+	require.Error(t, err)
+}
+
+func TestManifestStore_AssociateTagSchemaReferrer_SubjectNotFound(t *testing.T) {
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.CreateByPath(suite.ctx, randomRepository(t).Path)
+	require.NoError(t, err)
+
+	s := datastore.NewManifestStore(suite.db)
+	m := randomManifest(t, r, nil)
+	require.NoError(t, s.Create(suite.ctx, m))
+
+	subject := &models.Manifest{NamespaceID: r.NamespaceID, RepositoryID: r.ID, ID: m.ID + 100}
+	_, err = s.AssociateTagSchemaReferrer(suite.ctx, m, subject)
+	require.ErrorIs(t, err, datastore.ErrRefManifestNotFound)
+}
+
 func TestManifestStore_AssociateLayerBlob_AlreadyAssociatedDoesNotFail(t *testing.T) {
 	reloadManifestFixtures(t)
 
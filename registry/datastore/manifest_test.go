@@ -0,0 +1,48 @@
+package datastore_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/docker/distribution/manifest/helm"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetHelmChartMetadata(t *testing.T) {
+	mfst := &ocischema.DeserializedManifest{
+		Manifest: ocischema.Manifest{
+			Annotations: map[string]string{
+				v1.AnnotationTitle:   "my-chart",
+				v1.AnnotationVersion: "1.2.3",
+			},
+		},
+	}
+
+	m := &models.Manifest{Configuration: &models.Configuration{MediaType: helm.MediaTypeConfig}}
+	datastore.SetHelmChartMetadata(m, mfst)
+	require.Equal(t, sql.NullString{String: "my-chart", Valid: true}, m.ChartName)
+	require.Equal(t, sql.NullString{String: "1.2.3", Valid: true}, m.ChartVersion)
+
+	// the version is not recorded if unknown
+	m = &models.Manifest{Configuration: &models.Configuration{
+		MediaType: helm.MediaTypeConfig,
+		Payload:   models.Payload(`{"name":"other-chart"}`),
+	}}
+	datastore.SetHelmChartMetadata(m, &ocischema.DeserializedManifest{})
+	require.Equal(t, sql.NullString{String: "other-chart", Valid: true}, m.ChartName)
+	require.False(t, m.ChartVersion.Valid)
+
+	// other artifacts are left untouched
+	m = &models.Manifest{Configuration: &models.Configuration{MediaType: v1.MediaTypeImageConfig}}
+	datastore.SetHelmChartMetadata(m, mfst)
+	require.False(t, m.ChartName.Valid)
+	require.False(t, m.ChartVersion.Valid)
+
+	m = &models.Manifest{}
+	datastore.SetHelmChartMetadata(m, mfst)
+	require.False(t, m.ChartName.Valid)
+}
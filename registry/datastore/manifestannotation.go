@@ -0,0 +1,252 @@
+//go:generate mockgen -package mocks -destination mocks/manifestannotation.go . ManifestAnnotationStore
+
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// MaxAnnotationKeyLength and MaxAnnotationValueLength are the maximum length of the key and value of an indexed
+	// manifest annotation, as enforced by the database schema. Longer annotations are not indexed.
+	MaxAnnotationKeyLength   = 255
+	MaxAnnotationValueLength = 255
+)
+
+// AnnotationMatch is a condition on the annotations of a manifest: it must have an annotation with the given key and,
+// unless AnyValue is set, the given value.
+type AnnotationMatch struct {
+	Key      string
+	Value    string
+	AnyValue bool
+}
+
+// AnnotationSearchParams are the parameters of a search for manifests by their annotations.
+type AnnotationSearchParams struct {
+	// Matches are the conditions that manifests must satisfy, all of them.
+	Matches []AnnotationMatch
+	// LastRepositoryPath and LastDigest identify the last manifest of the previous page, if any. Manifests are sorted
+	// by repository path and digest.
+	LastRepositoryPath string
+	LastDigest         digest.Digest
+	MaxEntries         int
+}
+
+// ManifestAnnotationStore is the interface that a manifest annotation store should conform to. It indexes the
+// annotations of manifests so that manifests can be searched by them.
+type ManifestAnnotationStore interface {
+	// Create indexes the given annotations of m. Annotations whose key or value exceed MaxAnnotationKeyLength or
+	// MaxAnnotationValueLength are skipped, as are annotations of m that are already indexed.
+	Create(ctx context.Context, m *models.Manifest, annotations map[string]string) error
+	// FindByManifest finds the indexed annotations of m.
+	FindByManifest(ctx context.Context, m *models.Manifest) (map[string]string, error)
+	// SearchForPath finds the manifests of the repositories under the path of r, including r itself, that satisfy all
+	// conditions in params. Manifests are sorted by repository path and digest. The namespace ID of r must be set.
+	SearchForPath(ctx context.Context, r *models.Repository, params AnnotationSearchParams) ([]*models.AnnotatedManifest, error)
+}
+
+// PayloadAnnotations returns the annotations of the manifest with the given payload, if any. Both image manifests and
+// image indexes can have annotations. Nil is returned if the payload is not a JSON object with valid annotations.
+func PayloadAnnotations(payload []byte) map[string]string {
+	var m struct {
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil
+	}
+
+	return m.Annotations
+}
+
+type manifestAnnotationStore struct {
+	db Queryer
+}
+
+// NewManifestAnnotationStore builds a new manifestAnnotationStore.
+func NewManifestAnnotationStore(db Queryer) ManifestAnnotationStore {
+	return &manifestAnnotationStore{db: db}
+}
+
+// Create indexes the given annotations of m. Annotations whose key or value exceed MaxAnnotationKeyLength or
+// MaxAnnotationValueLength are skipped, as are annotations of m that are already indexed.
+func (s *manifestAnnotationStore) Create(ctx context.Context, m *models.Manifest, annotations map[string]string) error {
+	keys := make([]string, 0, len(annotations))
+	for k, v := range annotations {
+		if len([]rune(k)) > MaxAnnotationKeyLength || len([]rune(v)) > MaxAnnotationValueLength {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	defer metrics.InstrumentQuery(ctx, "manifest_annotation_create")()
+
+	q := `INSERT INTO manifest_annotations (top_level_namespace_id, repository_id, manifest_id, key, value)
+			VALUES %s
+		ON CONFLICT
+			DO NOTHING`
+
+	values := make([]string, 0, len(keys))
+	args := []any{m.NamespaceID, m.RepositoryID, m.ID}
+	for _, k := range keys {
+		values = append(values, fmt.Sprintf("($1, $2, $3, $%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, k, annotations[k])
+	}
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(q, strings.Join(values, ", ")), args...); err != nil {
+		return fmt.Errorf("creating manifest annotations: %w", err)
+	}
+
+	return nil
+}
+
+// FindByManifest finds the indexed annotations of m.
+func (s *manifestAnnotationStore) FindByManifest(ctx context.Context, m *models.Manifest) (map[string]string, error) {
+	defer metrics.InstrumentQuery(ctx, "manifest_annotation_find_by_manifest")()
+
+	q := `SELECT
+			key,
+			value
+		FROM
+			manifest_annotations
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND manifest_id = $3`
+
+	rows, err := s.db.QueryContext(ctx, q, m.NamespaceID, m.RepositoryID, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("finding manifest annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := make(map[string]string)
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, fmt.Errorf("scanning manifest annotation: %w", err)
+		}
+		annotations[k] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning manifest annotations: %w", err)
+	}
+
+	return annotations, nil
+}
+
+// SearchForPath finds the manifests of the repositories under the path of r, including r itself, that satisfy all
+// conditions in params. Manifests are sorted by repository path and digest. The namespace ID of r must be set.
+func (s *manifestAnnotationStore) SearchForPath(ctx context.Context, r *models.Repository, params AnnotationSearchParams) ([]*models.AnnotatedManifest, error) {
+	defer metrics.InstrumentQuery(ctx, "manifest_annotation_search_for_path")()
+
+	q := `SELECT
+			m.id,
+			m.top_level_namespace_id,
+			m.repository_id,
+			r.path,
+			encode(m.digest, 'hex') AS digest,
+			mt.media_type,
+			m.created_at,
+			COALESCE((
+				SELECT
+					jsonb_agg(t.name ORDER BY t.name)
+				FROM tags AS t
+				WHERE
+					t.top_level_namespace_id = m.top_level_namespace_id
+					AND t.repository_id = m.repository_id
+					AND t.manifest_id = m.id), '[]'::jsonb) AS tags,
+			COALESCE((
+				SELECT
+					jsonb_object_agg(a.key, a.value)
+				FROM manifest_annotations AS a
+				WHERE
+					a.top_level_namespace_id = m.top_level_namespace_id
+					AND a.repository_id = m.repository_id
+					AND a.manifest_id = m.id), '{}'::jsonb) AS annotations
+		FROM
+			manifests AS m
+			JOIN repositories AS r ON r.top_level_namespace_id = m.top_level_namespace_id
+				AND r.id = m.repository_id
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+		WHERE
+			m.top_level_namespace_id = $1
+			AND (r.path = $2 OR r.path LIKE $3)
+			AND (r.path, m.digest) > ($4, decode($5, 'hex'))%s
+		ORDER BY
+			r.path,
+			m.digest
+		LIMIT $6`
+
+	var last Digest
+	if params.LastDigest != "" {
+		var err error
+		if last, err = NewDigest(params.LastDigest); err != nil {
+			return nil, err
+		}
+	}
+	args := []any{r.NamespaceID, r.Path, r.Path + "/%", params.LastRepositoryPath, last, params.MaxEntries}
+
+	var conditions strings.Builder
+	for _, match := range params.Matches {
+		conditions.WriteString(`
+			AND EXISTS (
+				SELECT
+					1
+				FROM
+					manifest_annotations AS a
+				WHERE
+					a.top_level_namespace_id = m.top_level_namespace_id
+					AND a.repository_id = m.repository_id
+					AND a.manifest_id = m.id`)
+		args = append(args, match.Key)
+		fmt.Fprintf(&conditions, "\n\t\t\t\t\tAND a.key = $%d", len(args))
+		if !match.AnyValue {
+			args = append(args, match.Value)
+			fmt.Fprintf(&conditions, "\n\t\t\t\t\tAND a.value = $%d", len(args))
+		}
+		conditions.WriteString(")")
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(q, conditions.String()), args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching manifests by annotations: %w", err)
+	}
+	defer rows.Close()
+
+	mm := make([]*models.AnnotatedManifest, 0)
+	for rows.Next() {
+		var dgst Digest
+		var tags, annotations []byte
+		m := new(models.AnnotatedManifest)
+		if err := rows.Scan(&m.ID, &m.NamespaceID, &m.RepositoryID, &m.RepositoryPath, &dgst, &m.MediaType, &m.CreatedAt, &tags, &annotations); err != nil {
+			return nil, fmt.Errorf("scanning annotated manifest: %w", err)
+		}
+		if m.Digest, err = dgst.Parse(); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(tags, &m.Tags); err != nil {
+			return nil, fmt.Errorf("parsing annotated manifest tags: %w", err)
+		}
+		if err := json.Unmarshal(annotations, &m.Annotations); err != nil {
+			return nil, fmt.Errorf("parsing annotated manifest annotations: %w", err)
+		}
+		mm = append(mm, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning annotated manifests: %w", err)
+	}
+
+	return mm, nil
+}
@@ -0,0 +1,214 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadManifestAnnotationFixtures(tb testing.TB) {
+	tb.Helper()
+
+	reloadManifestFixtures(tb)
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.ManifestAnnotationsTable))
+
+	s := datastore.NewManifestAnnotationStore(suite.db)
+	// gitlab-org/gitlab-test/backend
+	require.NoError(tb, s.Create(suite.ctx, &models.Manifest{NamespaceID: 1, RepositoryID: 3, ID: 1}, map[string]string{
+		"org.opencontainers.image.vendor": "GitLab",
+		"org.example.stage":               "staging",
+	}))
+	require.NoError(tb, s.Create(suite.ctx, &models.Manifest{NamespaceID: 1, RepositoryID: 3, ID: 2}, map[string]string{
+		"org.opencontainers.image.vendor": "GitLab",
+		"org.example.stage":               "production",
+	}))
+	// gitlab-org/gitlab-test/frontend
+	require.NoError(tb, s.Create(suite.ctx, &models.Manifest{NamespaceID: 1, RepositoryID: 4, ID: 3}, map[string]string{
+		"org.opencontainers.image.vendor": "GitLab",
+	}))
+	// a-test-group/foo
+	require.NoError(tb, s.Create(suite.ctx, &models.Manifest{NamespaceID: 2, RepositoryID: 6, ID: 5}, map[string]string{
+		"org.opencontainers.image.vendor": "GitLab",
+	}))
+}
+
+func TestManifestAnnotationStore_Create(t *testing.T) {
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.ManifestAnnotationsTable))
+
+	s := datastore.NewManifestAnnotationStore(suite.db)
+	m := &models.Manifest{NamespaceID: 1, RepositoryID: 3, ID: 1}
+
+	err := s.Create(suite.ctx, m, map[string]string{
+		"a":                      "1",
+		"b":                      "",
+		strings.Repeat("k", 256): "too long key",
+		"too long value":         strings.Repeat("v", 256),
+		strings.Repeat("ü", 255): strings.Repeat("ü", 255),
+	})
+	require.NoError(t, err)
+
+	// indexing the same annotations again is a no-op
+	require.NoError(t, s.Create(suite.ctx, m, map[string]string{"a": "2"}))
+	// as is indexing none
+	require.NoError(t, s.Create(suite.ctx, m, nil))
+
+	annotations, err := s.FindByManifest(suite.ctx, m)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"a":                      "1",
+		"b":                      "",
+		strings.Repeat("ü", 255): strings.Repeat("ü", 255),
+	}, annotations)
+}
+
+func TestManifestAnnotationStore_FindByManifest_None(t *testing.T) {
+	reloadManifestAnnotationFixtures(t)
+
+	s := datastore.NewManifestAnnotationStore(suite.db)
+	annotations, err := s.FindByManifest(suite.ctx, &models.Manifest{NamespaceID: 1, RepositoryID: 4, ID: 4})
+	require.NoError(t, err)
+	require.Empty(t, annotations)
+}
+
+func TestManifestAnnotationStore_FindByManifest_DeletedManifest(t *testing.T) {
+	reloadManifestAnnotationFixtures(t)
+
+	m := &models.Manifest{NamespaceID: 1, RepositoryID: 4, ID: 3}
+	_, err := suite.db.ExecContext(suite.ctx, "DELETE FROM manifests WHERE top_level_namespace_id = $1 AND repository_id = $2 AND id = $3",
+		m.NamespaceID, m.RepositoryID, m.ID)
+	require.NoError(t, err)
+
+	s := datastore.NewManifestAnnotationStore(suite.db)
+	annotations, err := s.FindByManifest(suite.ctx, m)
+	require.NoError(t, err)
+	require.Empty(t, annotations)
+}
+
+func TestManifestAnnotationStore_SearchForPath(t *testing.T) {
+	reloadManifestAnnotationFixtures(t)
+
+	s := datastore.NewManifestAnnotationStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, Path: "gitlab-org"}
+
+	digestOf := func(mm []*models.AnnotatedManifest) []digest.Digest {
+		dd := make([]digest.Digest, 0, len(mm))
+		for _, m := range mm {
+			dd = append(dd, m.Digest)
+		}
+		return dd
+	}
+
+	m1 := digest.Digest("sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155")
+	m2 := digest.Digest("sha256:56b4b2228127fd594c5ab2925409713bd015ae9aa27eef2e0ddd90bcb2b1533f")
+	m3 := digest.Digest("sha256:bca3c0bf2ca0cde987ad9cab2dac986047a0ccff282f1b23df282ef05e3a10a6")
+
+	tt := []struct {
+		name     string
+		repo     *models.Repository
+		params   datastore.AnnotationSearchParams
+		expected []digest.Digest
+	}{
+		{
+			name:     "key and value",
+			params:   datastore.AnnotationSearchParams{Matches: []datastore.AnnotationMatch{{Key: "org.opencontainers.image.vendor", Value: "GitLab"}}},
+			expected: []digest.Digest{m2, m1, m3},
+		},
+		{
+			name:     "any value",
+			params:   datastore.AnnotationSearchParams{Matches: []datastore.AnnotationMatch{{Key: "org.example.stage", AnyValue: true}}},
+			expected: []digest.Digest{m2, m1},
+		},
+		{
+			name: "all conditions",
+			params: datastore.AnnotationSearchParams{Matches: []datastore.AnnotationMatch{
+				{Key: "org.opencontainers.image.vendor", Value: "GitLab"},
+				{Key: "org.example.stage", Value: "production"},
+			}},
+			expected: []digest.Digest{m2},
+		},
+		{
+			name:     "value is case sensitive",
+			params:   datastore.AnnotationSearchParams{Matches: []datastore.AnnotationMatch{{Key: "org.opencontainers.image.vendor", Value: "gitlab"}}},
+			expected: []digest.Digest{},
+		},
+		{
+			name:     "sub path",
+			repo:     &models.Repository{NamespaceID: 1, Path: "gitlab-org/gitlab-test/frontend"},
+			params:   datastore.AnnotationSearchParams{Matches: []datastore.AnnotationMatch{{Key: "org.opencontainers.image.vendor", AnyValue: true}}},
+			expected: []digest.Digest{m3},
+		},
+		{
+			name:     "path prefix is not a parent",
+			repo:     &models.Repository{NamespaceID: 1, Path: "gitlab-org/gitlab-test/front"},
+			params:   datastore.AnnotationSearchParams{Matches: []datastore.AnnotationMatch{{Key: "org.opencontainers.image.vendor", AnyValue: true}}},
+			expected: []digest.Digest{},
+		},
+		{
+			name: "first page",
+			params: datastore.AnnotationSearchParams{
+				Matches:    []datastore.AnnotationMatch{{Key: "org.opencontainers.image.vendor", Value: "GitLab"}},
+				MaxEntries: 2,
+			},
+			expected: []digest.Digest{m2, m1},
+		},
+		{
+			name: "last page",
+			params: datastore.AnnotationSearchParams{
+				Matches:            []datastore.AnnotationMatch{{Key: "org.opencontainers.image.vendor", Value: "GitLab"}},
+				LastRepositoryPath: "gitlab-org/gitlab-test/backend",
+				LastDigest:         m1,
+				MaxEntries:         2,
+			},
+			expected: []digest.Digest{m3},
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			repo := r
+			if test.repo != nil {
+				repo = test.repo
+			}
+			if test.params.MaxEntries == 0 {
+				test.params.MaxEntries = 100
+			}
+
+			mm, err := s.SearchForPath(suite.ctx, repo, test.params)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, digestOf(mm))
+		})
+	}
+}
+
+func TestManifestAnnotationStore_SearchForPath_Details(t *testing.T) {
+	reloadManifestAnnotationFixtures(t)
+
+	s := datastore.NewManifestAnnotationStore(suite.db)
+	mm, err := s.SearchForPath(suite.ctx, &models.Repository{NamespaceID: 1, Path: "gitlab-org"}, datastore.AnnotationSearchParams{
+		Matches:    []datastore.AnnotationMatch{{Key: "org.example.stage", Value: "production"}},
+		MaxEntries: 100,
+	})
+	require.NoError(t, err)
+	require.Len(t, mm, 1)
+
+	m := mm[0]
+	require.Equal(t, int64(2), m.ID)
+	require.Equal(t, int64(1), m.NamespaceID)
+	require.Equal(t, int64(3), m.RepositoryID)
+	require.Equal(t, "gitlab-org/gitlab-test/backend", m.RepositoryPath)
+	require.Equal(t, "application/vnd.docker.distribution.manifest.v2+json", m.MediaType)
+	require.Equal(t, []string{"2.0.0", "latest"}, m.Tags)
+	require.Equal(t, map[string]string{
+		"org.opencontainers.image.vendor": "GitLab",
+		"org.example.stage":               "production",
+	}, m.Annotations)
+	require.NotZero(t, m.CreatedAt)
+}
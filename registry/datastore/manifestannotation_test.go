@@ -0,0 +1,15 @@
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadAnnotations(t *testing.T) {
+	require.Equal(t, map[string]string{"a": "1", "b": "2"}, datastore.PayloadAnnotations([]byte(`{"schemaVersion":2,"annotations":{"a":"1","b":"2"}}`)))
+	require.Nil(t, datastore.PayloadAnnotations([]byte(`{"schemaVersion":2}`)))
+	require.Nil(t, datastore.PayloadAnnotations([]byte(`{"annotations":{"a":1}}`)))
+	require.Nil(t, datastore.PayloadAnnotations([]byte(`not json`)))
+}
@@ -0,0 +1,278 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/models"
+	gocache "github.com/eko/gocache/lib/v4/cache"
+	"github.com/eko/gocache/lib/v4/marshaler"
+	libstore "github.com/eko/gocache/lib/v4/store"
+	"github.com/opencontainers/go-digest"
+	"github.com/redis/go-redis/v9"
+)
+
+// ManifestCache is a cache for *models.Manifest objects and the digests of the manifests that tags point to.
+//
+// Tags are versioned to prevent stale fills: GetTag returns the current generation of a tag along with its cached
+// digest, InvalidateTags moves tags to a new generation, and SetTag only caches a digest if the tag is still at the
+// generation obtained before reading it from the database.
+type ManifestCache interface {
+	Get(ctx context.Context, r *models.Repository, dgst digest.Digest) *models.Manifest
+	Set(ctx context.Context, r *models.Repository, m *models.Manifest)
+	Invalidate(ctx context.Context, r *models.Repository, digests ...digest.Digest)
+
+	GetTag(ctx context.Context, r *models.Repository, tagName string) (digest.Digest, int64)
+	SetTag(ctx context.Context, r *models.Repository, tagName string, dgst digest.Digest, generation int64)
+	InvalidateTags(ctx context.Context, r *models.Repository, tagNames ...string)
+}
+
+// noOpManifestCache satisfies the ManifestCache, but does not cache anything. Useful as a default and for testing.
+type noOpManifestCache struct{}
+
+// NewNoOpManifestCache creates a new non-operational cache for manifest objects. This implementation does nothing and
+// returns nothing for all its methods.
+func NewNoOpManifestCache() *noOpManifestCache {
+	return &noOpManifestCache{}
+}
+
+func (n *noOpManifestCache) Get(context.Context, *models.Repository, digest.Digest) *models.Manifest {
+	return nil
+}
+func (n *noOpManifestCache) Set(context.Context, *models.Repository, *models.Manifest)        {}
+func (n *noOpManifestCache) Invalidate(context.Context, *models.Repository, ...digest.Digest) {}
+func (n *noOpManifestCache) GetTag(context.Context, *models.Repository, string) (digest.Digest, int64) {
+	return "", 0
+}
+func (n *noOpManifestCache) SetTag(context.Context, *models.Repository, string, digest.Digest, int64) {
+}
+func (n *noOpManifestCache) InvalidateTags(context.Context, *models.Repository, ...string) {}
+
+// tagGenerationTTL is the expiration of tag generation keys. These only have to outlive the database reads that fill
+// the cache concurrently with an invalidation.
+const tagGenerationTTL = time.Hour
+
+// setTagScript caches a tag digest only if the generation of the tag is still the given one. Missing generation keys
+// are at generation 0.
+//
+// KEYS: the tag key and the tag generation key.
+// ARGV: the expected generation, the digest and the expiration in milliseconds, or 0 for none.
+var setTagScript = redis.NewScript(`
+if (redis.call("GET", KEYS[2]) or "0") ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// invalidateTagsScript deletes cached tags and moves them to a new generation, so that any concurrent fills that read
+// the tags before they were invalidated are discarded.
+//
+// KEYS: the key and the generation key of each tag, in that order.
+// ARGV: the expiration of generation keys in milliseconds.
+var invalidateTagsScript = redis.NewScript(`
+for i = 1, #KEYS, 2 do
+	redis.call("DEL", KEYS[i])
+	redis.call("INCR", KEYS[i + 1])
+	redis.call("PEXPIRE", KEYS[i + 1], ARGV[1])
+end
+return 0
+`)
+
+// centralManifestCache is the interface for the centralized manifest object cache backed by Redis.
+type centralManifestCache struct {
+	// cache provides access to the raw gocache interface
+	cache *gocache.Cache[any]
+	// client provides direct access to Redis, for the atomic operations on tags
+	client redis.UniversalClient
+	// marshaler provides access to a MessagePack backed marshaling interface
+	marshaler *marshaler.Marshaler
+	// ttl is the expiration applied to all cached manifests and tags. The global expiration of the cache store is used
+	// if zero.
+	ttl time.Duration
+}
+
+// NewCentralManifestCache creates an interface for the centralized manifest object cache backed by Redis, where client
+// is the client of the Redis server behind cache. All cached objects expire after ttl. If ttl is zero, manifests expire
+// after the global expiration of cache and tags do not expire.
+func NewCentralManifestCache(cache *gocache.Cache[any], client redis.UniversalClient, ttl time.Duration) *centralManifestCache {
+	return &centralManifestCache{cache, client, marshaler.New(cache), ttl}
+}
+
+// repositoryKey generates the Redis key prefix for the manifests and tags of a given repository. This shares the hash
+// tag of the repository object key (see centralRepositoryCache.key), so that all keys related to a repository are
+// assigned to the same hash slot.
+func (c *centralManifestCache) repositoryKey(path string) string {
+	nsPrefix := strings.Split(path, "/")[0]
+	hex := digest.FromString(path).Hex()
+	return fmt.Sprintf("registry:db:{repository:%s:%s}", nsPrefix, hex)
+}
+
+// manifestKey generates a valid Redis key string for a manifest with digest dgst in the repository with the given path.
+func (c *centralManifestCache) manifestKey(path string, dgst digest.Digest) string {
+	return fmt.Sprintf("%s:manifest:%s", c.repositoryKey(path), dgst)
+}
+
+// tagKey generates a valid Redis key string for a tag with the given name in repository r. Unlike manifests, cached
+// tags can't be checked against the repository they belong to, so the key includes the repository ID. This prevents
+// serving the tags of a repository that was since renamed or deleted to another repository created with the same path.
+func (c *centralManifestCache) tagKey(r *models.Repository, tagName string) string {
+	return fmt.Sprintf("%s:tag:%d:%s", c.repositoryKey(r.Path), r.ID, tagName)
+}
+
+// tagGenerationKey generates a valid Redis key string for the generation of a tag with the given name in repository r.
+// Tag names cannot contain colons, so this never collides with a tag key.
+func (c *centralManifestCache) tagGenerationKey(r *models.Repository, tagName string) string {
+	return fmt.Sprintf("%s:generation", c.tagKey(r, tagName))
+}
+
+func (c *centralManifestCache) setOptions() []libstore.Option {
+	if c.ttl == 0 {
+		return nil
+	}
+	return []libstore.Option{libstore.WithExpiration(c.ttl)}
+}
+
+// Get implements ManifestCache.
+func (c *centralManifestCache) Get(ctx context.Context, r *models.Repository, dgst digest.Digest) *models.Manifest {
+	l := log.GetLogger(log.WithContext(ctx))
+
+	getCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	tmp, err := c.marshaler.Get(getCtx, c.manifestKey(r.Path, dgst), new(models.Manifest))
+	if err != nil {
+		// a wrapped redis.Nil is returned when the key is not found in Redis
+		if !errors.Is(err, redis.Nil) {
+			l.WithError(err).Error("failed to read manifest from cache")
+		}
+		return nil
+	}
+
+	m, ok := tmp.(*models.Manifest)
+	if !ok {
+		l.Warn("failed to unmarshal manifest from cache")
+		return nil
+	}
+
+	// Double check that the obtained and decoded manifest object belongs to the repository and has the digest that
+	// we're looking for. This prevents leaking data from other repositories in case of a path hash collision, and
+	// serving manifests of a repository that was since deleted and recreated with the same path.
+	if m.RepositoryID != r.ID || m.Digest != dgst {
+		l.WithFields(log.Fields{
+			"repository_id":        r.ID,
+			"digest":               dgst,
+			"cached_repository_id": m.RepositoryID,
+			"cached_digest":        m.Digest,
+		}).Warn("mismatch detected when getting manifest from cache")
+		return nil
+	}
+
+	return m
+}
+
+// Set implements ManifestCache.
+func (c *centralManifestCache) Set(ctx context.Context, r *models.Repository, m *models.Manifest) {
+	if m == nil {
+		return
+	}
+	setCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	if err := c.marshaler.Set(setCtx, c.manifestKey(r.Path, m.Digest), m, c.setOptions()...); err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to write manifest to cache")
+	}
+}
+
+// Invalidate implements ManifestCache.
+func (c *centralManifestCache) Invalidate(ctx context.Context, r *models.Repository, digests ...digest.Digest) {
+	for _, dgst := range digests {
+		c.delete(ctx, c.manifestKey(r.Path, dgst))
+	}
+}
+
+// GetTag implements ManifestCache. An empty digest is returned if the tag is not cached. A negative generation is
+// returned if it could not be read, so that no digest is cached for the tag by SetTag.
+func (c *centralManifestCache) GetTag(ctx context.Context, r *models.Repository, tagName string) (digest.Digest, int64) {
+	l := log.GetLogger(log.WithContext(ctx))
+
+	getCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	// both keys share the hash tag of the repository, so they can be read at once even in Redis Cluster
+	vals, err := c.client.MGet(getCtx, c.tagKey(r, tagName), c.tagGenerationKey(r, tagName)).Result()
+	if err != nil {
+		l.WithError(err).Error("failed to read tag from cache")
+		return "", -1
+	}
+
+	var generation int64
+	if s, ok := vals[1].(string); ok {
+		if generation, err = strconv.ParseInt(s, 10, 64); err != nil {
+			l.WithError(err).Warn("failed to parse tag generation from cache")
+			return "", -1
+		}
+	}
+
+	s, ok := vals[0].(string)
+	if !ok {
+		return "", generation
+	}
+	dgst, err := digest.Parse(s)
+	if err != nil {
+		l.WithError(err).Warn("failed to parse tag digest from cache")
+		return "", generation
+	}
+
+	return dgst, generation
+}
+
+// SetTag implements ManifestCache. The tag is only cached if it was not invalidated since generation was read by GetTag.
+func (c *centralManifestCache) SetTag(ctx context.Context, r *models.Repository, tagName string, dgst digest.Digest, generation int64) {
+	if generation < 0 {
+		return
+	}
+	setCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	keys := []string{c.tagKey(r, tagName), c.tagGenerationKey(r, tagName)}
+	if err := setTagScript.Run(setCtx, c.client, keys, generation, dgst.String(), c.ttl.Milliseconds()).Err(); err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to write tag to cache")
+	}
+}
+
+// InvalidateTags implements ManifestCache.
+func (c *centralManifestCache) InvalidateTags(ctx context.Context, r *models.Repository, tagNames ...string) {
+	if len(tagNames) == 0 {
+		return
+	}
+	keys := make([]string, 0, 2*len(tagNames))
+	for _, name := range tagNames {
+		keys = append(keys, c.tagKey(r, name), c.tagGenerationKey(r, name))
+	}
+
+	delCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	if err := invalidateTagsScript.Run(delCtx, c.client, keys, tagGenerationTTL.Milliseconds()).Err(); err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).WithFields(log.Fields{"tag_names": tagNames}).Warn("failed to invalidate tags in cache")
+	}
+}
+
+func (c *centralManifestCache) delete(ctx context.Context, key string) {
+	delCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	if err := c.cache.Delete(delCtx, key); err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).WithFields(log.Fields{"key": key}).Warn("failed to invalidate key in cache")
+	}
+}
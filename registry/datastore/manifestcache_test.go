@@ -0,0 +1,111 @@
+package datastore_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCentralManifestCache(t *testing.T) {
+	repo := &models.Repository{ID: 1, NamespaceID: 1, Name: "gitlab", Path: "gitlab-org/gitlab"}
+	payload := models.Payload(`{"schemaVersion":2}`)
+	m := &models.Manifest{
+		ID:            2,
+		NamespaceID:   repo.NamespaceID,
+		RepositoryID:  repo.ID,
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.oci.image.manifest.v1+json",
+		Digest:        digest.FromBytes(payload),
+		Payload:       payload,
+		Configuration: &models.Configuration{
+			MediaType: "application/vnd.oci.image.config.v1+json",
+			Digest:    digest.FromString("config"),
+		},
+		SubjectID: sql.NullInt64{Int64: 3, Valid: true},
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	ttl := 10 * time.Minute
+	c := testutil.NewRedisCacheController(t, 0)
+	cache := datastore.NewCentralManifestCache(c.Cache, redis.NewClient(&redis.Options{Addr: c.Addr()}), ttl)
+	ctx := context.Background()
+
+	// manifests
+	require.Nil(t, cache.Get(ctx, repo, m.Digest))
+	cache.Set(ctx, repo, m)
+
+	key := "registry:db:{repository:gitlab-org:6fc8277be731c24196adfdfbbf4fab5a760941f1808efc8e2f37d1fae8b44ac3}:manifest:" + m.Digest.String()
+	require.True(t, c.Exists(key))
+	require.Equal(t, ttl, c.TTL(key))
+
+	got := cache.Get(ctx, repo, m.Digest)
+	require.NotNil(t, got)
+	got.CreatedAt = got.CreatedAt.UTC()
+	require.Equal(t, m, got)
+
+	// cached manifests of a different repository with the same path are ignored
+	require.Nil(t, cache.Get(ctx, &models.Repository{ID: 4, Path: repo.Path}, m.Digest))
+
+	cache.Invalidate(ctx, repo, m.Digest)
+	require.False(t, c.Exists(key))
+	require.Nil(t, cache.Get(ctx, repo, m.Digest))
+
+	// tags
+	dgst, gen := cache.GetTag(ctx, repo, "latest")
+	require.Empty(t, dgst)
+	require.Zero(t, gen)
+	cache.SetTag(ctx, repo, "latest", m.Digest, gen)
+
+	key = "registry:db:{repository:gitlab-org:6fc8277be731c24196adfdfbbf4fab5a760941f1808efc8e2f37d1fae8b44ac3}:tag:1:latest"
+	require.True(t, c.Exists(key))
+	require.Equal(t, ttl, c.TTL(key))
+	dgst, gen = cache.GetTag(ctx, repo, "latest")
+	require.Equal(t, m.Digest, dgst)
+	require.Zero(t, gen)
+
+	// cached tags of a different repository with the same path are ignored
+	dgst, _ = cache.GetTag(ctx, &models.Repository{ID: 4, Path: repo.Path}, "latest")
+	require.Empty(t, dgst)
+
+	cache.InvalidateTags(ctx, repo, "latest", "unknown")
+	require.False(t, c.Exists(key))
+	dgst, gen = cache.GetTag(ctx, repo, "latest")
+	require.Empty(t, dgst)
+	require.Equal(t, int64(1), gen)
+}
+
+func TestCentralManifestCache_SetTagAfterInvalidation(t *testing.T) {
+	repo := &models.Repository{ID: 1, NamespaceID: 1, Name: "gitlab", Path: "gitlab-org/gitlab"}
+	dgst := digest.FromString("manifest")
+
+	c := testutil.NewRedisCacheController(t, 0)
+	cache := datastore.NewCentralManifestCache(c.Cache, redis.NewClient(&redis.Options{Addr: c.Addr()}), 10*time.Minute)
+	ctx := context.Background()
+
+	// a reader misses the cache and reads the tag from the database, while the tag is concurrently updated
+	_, gen := cache.GetTag(ctx, repo, "latest")
+	cache.InvalidateTags(ctx, repo, "latest")
+
+	// the digest read before the invalidation is stale and must not be cached
+	cache.SetTag(ctx, repo, "latest", dgst, gen)
+	got, newGen := cache.GetTag(ctx, repo, "latest")
+	require.Empty(t, got)
+	require.Greater(t, newGen, gen)
+
+	key := "registry:db:{repository:gitlab-org:6fc8277be731c24196adfdfbbf4fab5a760941f1808efc8e2f37d1fae8b44ac3}:tag:1:latest:generation"
+	require.True(t, c.Exists(key))
+	require.Equal(t, time.Hour, c.TTL(key))
+
+	// readers that started after the invalidation fill the cache
+	cache.SetTag(ctx, repo, "latest", dgst, newGen)
+	got, _ = cache.GetTag(ctx, repo, "latest")
+	require.Equal(t, dgst, got)
+}
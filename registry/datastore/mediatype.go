@@ -13,6 +13,17 @@ type MediaTypeReader interface {
 	Exists(ctx context.Context, mt string) (bool, error)
 }
 
+// MediaTypeWriter is the interface that defines write operations for a media type store.
+type MediaTypeWriter interface {
+	CreateOrFind(ctx context.Context, mt string) (int, error)
+}
+
+// MediaTypeStore is the interface that a media type store should conform to.
+type MediaTypeStore interface {
+	MediaTypeReader
+	MediaTypeWriter
+}
+
 // mediaTypeStore is a concrete implementation of a media type store.
 type mediaTypeStore struct {
 	// db can be either a *sql.DB or *sql.Tx
@@ -25,7 +36,7 @@ func NewMediaTypeStore(db Queryer) *mediaTypeStore {
 }
 
 func (s *mediaTypeStore) Exists(ctx context.Context, mt string) (bool, error) {
-	defer metrics.InstrumentQuery("media_type_exists")()
+	defer metrics.InstrumentQuery(ctx, "media_type_exists")()
 	// Query returns "t" or "f", the subquery is only evaluated based on whether a
 	// row is returned or not, so the fields from the media_types table are ignored.
 	q := `SELECT
@@ -45,3 +56,36 @@ func (s *mediaTypeStore) Exists(ctx context.Context, mt string) (bool, error) {
 
 	return strconv.ParseBool(exists)
 }
+
+// CreateOrFind registers a media type if it does not exist yet and returns its ID. This is used to store media types
+// that are not known in advance, such as the artifact type of OCI artifact manifests.
+func (s *mediaTypeStore) CreateOrFind(ctx context.Context, mt string) (int, error) {
+	defer metrics.InstrumentQuery(ctx, "media_type_create_or_find")()
+	q := `WITH ins AS (
+			INSERT INTO media_types (media_type)
+				VALUES ($1)
+			ON CONFLICT (media_type)
+				DO NOTHING
+			RETURNING
+				id
+		)
+		SELECT
+			id
+		FROM
+			ins
+		UNION ALL
+		SELECT
+			id
+		FROM
+			media_types
+		WHERE
+			media_type = $1
+		LIMIT 1`
+
+	var id int
+	if err := s.db.QueryRowContext(ctx, q, mt).Scan(&id); err != nil {
+		return 0, fmt.Errorf("creating or finding media type: %w", err)
+	}
+
+	return id, nil
+}
@@ -5,6 +5,7 @@ package datastore_test
 import (
 	"testing"
 
+	"github.com/docker/distribution/manifest/helm"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/stretchr/testify/require"
 )
@@ -44,3 +45,45 @@ func TestMediaTypeStore_Exists(t *testing.T) {
 		})
 	}
 }
+
+func TestMediaTypeStore_CreateOrFind(t *testing.T) {
+	// use a transaction to avoid leaving the new media type behind for other tests
+	tx, err := suite.db.BeginTx(suite.ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	s := datastore.NewMediaTypeStore(tx)
+	mt := "application/vnd.example.artifact.v1"
+
+	exists, err := s.Exists(suite.ctx, mt)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	id, err := s.CreateOrFind(suite.ctx, mt)
+	require.NoError(t, err)
+	require.NotZero(t, id)
+
+	exists, err = s.Exists(suite.ctx, mt)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// creating it again returns the existing ID
+	id2, err := s.CreateOrFind(suite.ctx, mt)
+	require.NoError(t, err)
+	require.Equal(t, id, id2)
+
+	// media types seeded by migrations are found
+	id, err = s.CreateOrFind(suite.ctx, "application/json")
+	require.NoError(t, err)
+	require.NotZero(t, id)
+}
+
+func TestMediaTypeStore_Exists_HelmMediaTypes(t *testing.T) {
+	s := datastore.NewMediaTypeStore(suite.db)
+
+	for _, mt := range helm.MediaTypes {
+		exists, err := s.Exists(suite.ctx, mt)
+		require.NoError(t, err)
+		require.True(t, exists, mt)
+	}
+}
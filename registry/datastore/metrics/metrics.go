@@ -1,16 +1,22 @@
 package metrics
 
 import (
+	"context"
 	"time"
 
 	"github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/tracing"
 	"github.com/prometheus/client_golang/prometheus"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	queryDurationHist *prometheus.HistogramVec
-	queryTotal        *prometheus.CounterVec
-	timeSince         = time.Since // for test purposes only
+	queryDurationHist         *prometheus.HistogramVec
+	queryTotal                *prometheus.CounterVec
+	circuitBreakerOpen        prometheus.Gauge
+	circuitBreakerTransitions *prometheus.CounterVec
+	timeSince                 = time.Since // for test purposes only
 )
 
 const (
@@ -22,6 +28,14 @@ const (
 
 	queryTotalName = "queries_total"
 	queryTotalDesc = "A counter for database queries."
+
+	circuitBreakerStateLabel = "state"
+
+	circuitBreakerOpenName = "circuit_breaker_open"
+	circuitBreakerOpenDesc = "A gauge set to 1 while the database circuit breaker is open, 0 otherwise."
+
+	circuitBreakerTransitionsName = "circuit_breaker_transitions_total"
+	circuitBreakerTransitionsDesc = "A counter for database circuit breaker state transitions."
 )
 
 func init() {
@@ -46,14 +60,55 @@ func init() {
 		[]string{queryNameLabel},
 	)
 
+	circuitBreakerOpen = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      circuitBreakerOpenName,
+			Help:      circuitBreakerOpenDesc,
+		},
+	)
+
+	circuitBreakerTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      circuitBreakerTransitionsName,
+			Help:      circuitBreakerTransitionsDesc,
+		},
+		[]string{circuitBreakerStateLabel},
+	)
+
 	prometheus.MustRegister(queryDurationHist)
 	prometheus.MustRegister(queryTotal)
+	prometheus.MustRegister(circuitBreakerOpen)
+	prometheus.MustRegister(circuitBreakerTransitions)
 }
 
-func InstrumentQuery(name string) func() {
+// InstrumentQuery records the execution of the query with the given name. It starts a tracing span named after the
+// query and returns a function that ends it and records the query metrics, which must be called once the query
+// completes.
+func InstrumentQuery(ctx context.Context, name string) func() {
 	start := time.Now()
+	_, span := tracing.StartSpan(ctx, "db."+name, trace.WithAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationKey.String(name),
+	))
 	return func() {
+		span.End()
 		queryTotal.WithLabelValues(name).Inc()
 		queryDurationHist.WithLabelValues(name).Observe(timeSince(start).Seconds())
 	}
 }
+
+// CircuitBreakerTransition records a transition of the database circuit breaker to the open or closed state.
+func CircuitBreakerTransition(open bool) {
+	state := "closed"
+	var v float64
+	if open {
+		state = "open"
+		v = 1
+	}
+	circuitBreakerOpen.Set(v)
+	circuitBreakerTransitions.WithLabelValues(state).Inc()
+}
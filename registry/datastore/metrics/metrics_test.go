@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -23,10 +24,10 @@ func TestInstrumentQuery(t *testing.T) {
 
 	restore := mockTimeSince(10 * time.Millisecond)
 	defer restore()
-	InstrumentQuery(queryName)()
+	InstrumentQuery(context.Background(), queryName)()
 
 	mockTimeSince(20 * time.Millisecond)
-	InstrumentQuery(queryName)()
+	InstrumentQuery(context.Background(), queryName)()
 
 	var expected bytes.Buffer
 	expected.WriteString(`
@@ -56,3 +57,25 @@ registry_database_query_duration_seconds_count{name="foo_find_by_id"} 2
 	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, durationFullName, totalFullName)
 	require.NoError(t, err)
 }
+
+func TestCircuitBreakerTransition(t *testing.T) {
+	CircuitBreakerTransition(true)
+	CircuitBreakerTransition(false)
+	CircuitBreakerTransition(true)
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_database_circuit_breaker_open A gauge set to 1 while the database circuit breaker is open, 0 otherwise.
+# TYPE registry_database_circuit_breaker_open gauge
+registry_database_circuit_breaker_open 1
+# HELP registry_database_circuit_breaker_transitions_total A counter for database circuit breaker state transitions.
+# TYPE registry_database_circuit_breaker_transitions_total counter
+registry_database_circuit_breaker_transitions_total{state="closed"} 1
+registry_database_circuit_breaker_transitions_total{state="open"} 2
+`)
+	openFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, circuitBreakerOpenName)
+	transitionsFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, circuitBreakerTransitionsName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, openFullName, transitionsFullName)
+	require.NoError(t, err)
+}
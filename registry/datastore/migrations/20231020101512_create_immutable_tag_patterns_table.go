@@ -0,0 +1,30 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231020101512_create_immutable_tag_patterns_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS immutable_tag_patterns (
+					id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					pattern text NOT NULL,
+					CONSTRAINT pk_immutable_tag_patterns PRIMARY KEY (top_level_namespace_id, repository_id, id),
+					CONSTRAINT fk_immutable_tag_patterns_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT unique_immutable_tag_patterns_rpstry_id_and_pattern UNIQUE (top_level_namespace_id, repository_id, pattern),
+					CONSTRAINT check_immutable_tag_patterns_pattern_length CHECK ((char_length(pattern) <= 255))
+				)`,
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS immutable_tag_patterns CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231025090000_create_background_migrations_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS background_migrations (
+					id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					updated_at timestamp WITH time zone,
+					started_at timestamp WITH time zone,
+					finished_at timestamp WITH time zone,
+					batch_size integer NOT NULL,
+					failed_attempts integer NOT NULL DEFAULT 0,
+					min_value bigint NOT NULL,
+					max_value bigint NOT NULL,
+					next_value bigint NOT NULL,
+					processed_rows bigint NOT NULL DEFAULT 0,
+					name text NOT NULL,
+					job_name text NOT NULL,
+					table_name text NOT NULL,
+					column_name text NOT NULL,
+					status text NOT NULL DEFAULT 'active',
+					error_message text,
+					CONSTRAINT pk_background_migrations PRIMARY KEY (id),
+					CONSTRAINT unique_background_migrations_name UNIQUE (name),
+					CONSTRAINT check_background_migrations_name_length CHECK ((char_length(name) <= 255)),
+					CONSTRAINT check_background_migrations_job_name_length CHECK ((char_length(job_name) <= 255)),
+					CONSTRAINT check_background_migrations_table_name_length CHECK ((char_length(table_name) <= 255)),
+					CONSTRAINT check_background_migrations_column_name_length CHECK ((char_length(column_name) <= 255)),
+					CONSTRAINT check_background_migrations_error_message_length CHECK ((char_length(error_message) <= 1024)),
+					CONSTRAINT check_background_migrations_status CHECK (status IN ('active', 'finished', 'failed')),
+					CONSTRAINT check_background_migrations_batch_size_positive CHECK (batch_size > 0)
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_background_migrations_on_id_where_status_active ON background_migrations USING btree (id) WHERE status = 'active'",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_background_migrations_on_id_where_status_active CASCADE",
+				"DROP TABLE IF EXISTS background_migrations CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231026090000_create_import_checkpoints_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS import_checkpoints (
+					id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					step text NOT NULL,
+					repository_path text NOT NULL,
+					CONSTRAINT pk_import_checkpoints PRIMARY KEY (id),
+					CONSTRAINT unique_import_checkpoints_step_repository_path UNIQUE (step, repository_path),
+					CONSTRAINT check_import_checkpoints_repository_path_length CHECK ((char_length(repository_path) <= 255)),
+					CONSTRAINT check_import_checkpoints_step CHECK (step IN ('pre_import', 'repository_import'))
+				)`,
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS import_checkpoints CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
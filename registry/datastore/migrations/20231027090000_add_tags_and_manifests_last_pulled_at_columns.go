@@ -0,0 +1,22 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231027090000_add_tags_and_manifests_last_pulled_at_columns",
+			Up: []string{
+				"ALTER TABLE tags ADD COLUMN IF NOT EXISTS last_pulled_at timestamp WITH time zone",
+				"ALTER TABLE manifests ADD COLUMN IF NOT EXISTS last_pulled_at timestamp WITH time zone",
+			},
+			Down: []string{
+				"ALTER TABLE manifests DROP COLUMN IF EXISTS last_pulled_at",
+				"ALTER TABLE tags DROP COLUMN IF EXISTS last_pulled_at",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
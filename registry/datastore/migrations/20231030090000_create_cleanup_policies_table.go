@@ -0,0 +1,40 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231030090000_create_cleanup_policies_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS cleanup_policies (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					updated_at timestamp WITH time zone,
+					next_run_at timestamp WITH time zone NOT NULL,
+					last_run_at timestamp WITH time zone,
+					cadence interval NOT NULL,
+					older_than interval NOT NULL DEFAULT '0'::interval,
+					keep_n integer NOT NULL DEFAULT 0,
+					enabled boolean NOT NULL DEFAULT TRUE,
+					name_regex_delete text NOT NULL,
+					name_regex_keep text,
+					CONSTRAINT pk_cleanup_policies PRIMARY KEY (top_level_namespace_id, repository_id),
+					CONSTRAINT fk_cleanup_policies_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_cleanup_policies_keep_n_not_negative CHECK ((keep_n >= 0)),
+					CONSTRAINT check_cleanup_policies_name_regex_delete_length CHECK ((char_length(name_regex_delete) <= 255)),
+					CONSTRAINT check_cleanup_policies_name_regex_keep_length CHECK ((char_length(name_regex_keep) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_cleanup_policies_on_next_run_at_where_enabled ON cleanup_policies USING btree (next_run_at) WHERE enabled",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_cleanup_policies_on_next_run_at_where_enabled CASCADE",
+				"DROP TABLE IF EXISTS cleanup_policies CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
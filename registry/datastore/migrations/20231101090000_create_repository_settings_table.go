@@ -0,0 +1,29 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231101090000_create_repository_settings_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS repository_settings (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					updated_at timestamp WITH time zone,
+					manifest_payload_size_limit integer,
+					CONSTRAINT pk_repository_settings PRIMARY KEY (top_level_namespace_id, repository_id),
+					CONSTRAINT fk_repository_settings_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_repository_settings_manifest_payload_size_limit_positive CHECK ((manifest_payload_size_limit > 0))
+				)`,
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS repository_settings CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
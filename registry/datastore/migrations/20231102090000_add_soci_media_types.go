@@ -0,0 +1,34 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231102090000_add_soci_media_types",
+			Up: []string{
+				`INSERT INTO media_types (media_type)
+					VALUES
+						('application/vnd.amazon.soci.index.v1+json'),
+						('application/vnd.amazon.soci.index.v2+json'),
+						('application/vnd.oci.empty.v1+json')
+				EXCEPT
+				SELECT
+					media_type
+				FROM
+					media_types`,
+			},
+			Down: []string{
+				`DELETE FROM media_types
+					WHERE media_type IN (
+						'application/vnd.amazon.soci.index.v1+json',
+						'application/vnd.amazon.soci.index.v2+json',
+						'application/vnd.oci.empty.v1+json'
+					)`,
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
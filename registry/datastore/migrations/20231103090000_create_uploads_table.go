@@ -0,0 +1,31 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231103090000_create_uploads_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS uploads (
+					id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					uuid text NOT NULL,
+					repository_path text NOT NULL,
+					CONSTRAINT pk_uploads PRIMARY KEY (id),
+					CONSTRAINT unique_uploads_uuid UNIQUE (uuid),
+					CONSTRAINT check_uploads_uuid_length CHECK ((char_length(uuid) <= 255)),
+					CONSTRAINT check_uploads_repository_path_length CHECK ((char_length(repository_path) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_uploads_on_created_at ON uploads USING btree (created_at)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_uploads_on_created_at CASCADE",
+				"DROP TABLE IF EXISTS uploads CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
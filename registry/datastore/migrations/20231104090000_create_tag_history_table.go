@@ -0,0 +1,36 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231104090000_create_tag_history_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS tag_history (
+					id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					name text NOT NULL,
+					old_digest bytea,
+					new_digest bytea,
+					actor text,
+					CONSTRAINT pk_tag_history PRIMARY KEY (top_level_namespace_id, repository_id, id),
+					CONSTRAINT fk_tag_history_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_tag_history_name_length CHECK ((char_length(name) <= 255)),
+					CONSTRAINT check_tag_history_actor_length CHECK ((char_length(actor) <= 255)),
+					CONSTRAINT check_tag_history_digests_not_both_null CHECK ((old_digest IS NOT NULL OR new_digest IS NOT NULL))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_tag_history_on_top_lvl_nmspc_id_rpstry_id_name_id ON tag_history USING btree (top_level_namespace_id, repository_id, name, id)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_tag_history_on_top_lvl_nmspc_id_rpstry_id_name_id CASCADE",
+				"DROP TABLE IF EXISTS tag_history CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
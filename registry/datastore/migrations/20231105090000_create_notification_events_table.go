@@ -0,0 +1,33 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231105090000_create_notification_events_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS notification_events (
+					id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					locked_until timestamp WITH time zone,
+					failed_at timestamp WITH time zone,
+					endpoint text NOT NULL,
+					payload jsonb NOT NULL,
+					CONSTRAINT pk_notification_events PRIMARY KEY (id),
+					CONSTRAINT check_notification_events_endpoint_length CHECK ((char_length(endpoint) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_notification_events_on_endpoint_id_where_pending ON notification_events USING btree (endpoint, id) WHERE failed_at IS NULL",
+				"CREATE INDEX IF NOT EXISTS index_notification_events_on_failed_at_where_failed ON notification_events USING btree (failed_at) WHERE failed_at IS NOT NULL",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_notification_events_on_failed_at_where_failed CASCADE",
+				"DROP INDEX IF EXISTS index_notification_events_on_endpoint_id_where_pending CASCADE",
+				"DROP TABLE IF EXISTS notification_events CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231106090000_add_repository_settings_read_only_column",
+			Up: []string{
+				"ALTER TABLE repository_settings ADD COLUMN IF NOT EXISTS read_only boolean NOT NULL DEFAULT false",
+			},
+			Down: []string{
+				"ALTER TABLE repository_settings DROP COLUMN IF EXISTS read_only",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231113090000_add_gc_blob_review_queue_config_media_type_id_column",
+			Up: []string{
+				"ALTER TABLE gc_blob_review_queue ADD COLUMN IF NOT EXISTS config_media_type_id smallint",
+			},
+			Down: []string{
+				"ALTER TABLE gc_blob_review_queue DROP COLUMN IF EXISTS config_media_type_id",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
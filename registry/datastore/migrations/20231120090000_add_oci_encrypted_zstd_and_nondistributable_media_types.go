@@ -0,0 +1,37 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231120090000_add_oci_encrypted_zstd_and_nondistributable_media_types",
+			Up: []string{
+				`INSERT INTO media_types (media_type)
+					VALUES ('application/vnd.oci.image.layer.v1.tar+zstd+encrypted'),
+						('application/vnd.oci.image.layer.nondistributable.v1.tar+zstd'),
+						('application/vnd.oci.image.layer.nondistributable.v1.tar+encrypted'),
+						('application/vnd.oci.image.layer.nondistributable.v1.tar+gzip+encrypted'),
+						('application/vnd.oci.image.layer.nondistributable.v1.tar+zstd+encrypted')
+				EXCEPT
+				SELECT
+					media_type
+				FROM
+					media_types`,
+			},
+			Down: []string{
+				`DELETE FROM media_types
+					WHERE media_type IN (
+						'application/vnd.oci.image.layer.v1.tar+zstd+encrypted',
+						'application/vnd.oci.image.layer.nondistributable.v1.tar+zstd',
+						'application/vnd.oci.image.layer.nondistributable.v1.tar+encrypted',
+						'application/vnd.oci.image.layer.nondistributable.v1.tar+gzip+encrypted',
+						'application/vnd.oci.image.layer.nondistributable.v1.tar+zstd+encrypted'
+					)`,
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
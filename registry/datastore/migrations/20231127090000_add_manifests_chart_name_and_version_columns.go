@@ -0,0 +1,22 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231127090000_add_manifests_chart_name_and_version_columns",
+			Up: []string{
+				"ALTER TABLE manifests ADD COLUMN IF NOT EXISTS chart_name text",
+				"ALTER TABLE manifests ADD COLUMN IF NOT EXISTS chart_version text",
+			},
+			Down: []string{
+				"ALTER TABLE manifests DROP COLUMN IF EXISTS chart_version",
+				"ALTER TABLE manifests DROP COLUMN IF EXISTS chart_name",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231128090000_create_corrupted_blobs_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS corrupted_blobs (
+					detected_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					checked_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					size bigint NOT NULL,
+					digest bytea NOT NULL,
+					actual_digest bytea,
+					reason text NOT NULL,
+					CONSTRAINT pk_corrupted_blobs PRIMARY KEY (digest),
+					CONSTRAINT fk_corrupted_blobs_digest_blobs FOREIGN KEY (digest) REFERENCES blobs (digest) ON DELETE CASCADE,
+					CONSTRAINT check_corrupted_blobs_reason_length CHECK ((char_length(reason) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_corrupted_blobs_on_detected_at ON corrupted_blobs USING btree (detected_at)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_corrupted_blobs_on_detected_at CASCADE",
+				"DROP TABLE IF EXISTS corrupted_blobs CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
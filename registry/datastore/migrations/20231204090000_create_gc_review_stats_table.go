@@ -0,0 +1,28 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231204090000_create_gc_review_stats_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS gc_review_stats (
+					hour timestamp WITH time zone NOT NULL,
+					queue text NOT NULL,
+					processed bigint NOT NULL DEFAULT 0,
+					dangling bigint NOT NULL DEFAULT 0,
+					failed bigint NOT NULL DEFAULT 0,
+					CONSTRAINT pk_gc_review_stats PRIMARY KEY (queue, hour),
+					CONSTRAINT check_gc_review_stats_queue_length CHECK ((char_length(queue) <= 255))
+				)`,
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS gc_review_stats CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231211090000_create_manifest_annotations_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS manifest_annotations (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					manifest_id bigint NOT NULL,
+					key text NOT NULL,
+					value text NOT NULL,
+					CONSTRAINT pk_manifest_annotations PRIMARY KEY (top_level_namespace_id, repository_id, manifest_id, key),
+					CONSTRAINT fk_manifest_annotations_tp_lvl_nspc_id_rpstry_id_mfst_id_mnfsts FOREIGN KEY (top_level_namespace_id, repository_id, manifest_id) REFERENCES manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_manifest_annotations_key_length CHECK ((char_length(key) <= 255)),
+					CONSTRAINT check_manifest_annotations_value_length CHECK ((char_length(value) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_manifest_annotations_on_top_lvl_nmspc_id_key_value ON manifest_annotations USING btree (top_level_namespace_id, key, value)",
+				EnqueueBackgroundMigration("20231211090000_backfill_manifest_annotations", "backfill_manifest_annotations", "manifests", "id", 1000),
+			},
+			Down: []string{
+				DeleteBackgroundMigration("20231211090000_backfill_manifest_annotations"),
+				"DROP INDEX IF EXISTS index_manifest_annotations_on_top_lvl_nmspc_id_key_value CASCADE",
+				"DROP TABLE IF EXISTS manifest_annotations CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231218090000_create_repository_purges_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS repository_purges (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					updated_at timestamp WITH time zone,
+					next_run_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					completed_at timestamp WITH time zone,
+					tags_deleted bigint NOT NULL DEFAULT 0,
+					manifests_deleted bigint NOT NULL DEFAULT 0,
+					blobs_unlinked bigint NOT NULL DEFAULT 0,
+					last_error text,
+					CONSTRAINT pk_repository_purges PRIMARY KEY (top_level_namespace_id, repository_id),
+					CONSTRAINT fk_repository_purges_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_repository_purges_last_error_length CHECK ((char_length(last_error) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_repository_purges_on_next_run_at_where_not_completed ON repository_purges USING btree (next_run_at) WHERE completed_at IS NULL",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_repository_purges_on_next_run_at_where_not_completed CASCADE",
+				"DROP TABLE IF EXISTS repository_purges CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231225090000_create_namespace_statistics_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS namespace_statistics (
+					top_level_namespace_id bigint NOT NULL,
+					date date NOT NULL,
+					pushes bigint NOT NULL DEFAULT 0,
+					pulls bigint NOT NULL DEFAULT 0,
+					bytes_added bigint NOT NULL DEFAULT 0,
+					bytes_reclaimed bigint NOT NULL DEFAULT 0,
+					CONSTRAINT pk_namespace_statistics PRIMARY KEY (top_level_namespace_id, date),
+					CONSTRAINT fk_namespace_statistics_top_lvl_nmspc_id_top_lvl_nmspcs FOREIGN KEY (top_level_namespace_id) REFERENCES top_level_namespaces (id) ON DELETE CASCADE
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_namespace_statistics_on_date ON namespace_statistics USING btree (date)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_namespace_statistics_on_date CASCADE",
+				"DROP TABLE IF EXISTS namespace_statistics CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231225090100_add_gc_blob_review_queue_top_level_namespace_id_column",
+			Up: []string{
+				"ALTER TABLE gc_blob_review_queue ADD COLUMN IF NOT EXISTS top_level_namespace_id bigint",
+			},
+			Down: []string{
+				"ALTER TABLE gc_blob_review_queue DROP COLUMN IF EXISTS top_level_namespace_id",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20231225090300_update_gc_track_deleted_manifests_function",
+			Up: []string{
+				// updates function definition to record the namespace of orphaned configuration blobs, for GC reclaimed
+				// bytes to be attributed to it in the namespace statistics
+				`CREATE OR REPLACE FUNCTION gc_track_deleted_manifests ()
+					RETURNS TRIGGER
+					AS $$
+				BEGIN
+					IF OLD.configuration_blob_digest IS NOT NULL THEN
+						INSERT INTO gc_blob_review_queue (digest, review_after, event, config_media_type_id, top_level_namespace_id)
+							VALUES (OLD.configuration_blob_digest, gc_review_after ('manifest_delete'), 'manifest_delete', OLD.configuration_media_type_id, OLD.top_level_namespace_id)
+						ON CONFLICT (digest)
+							DO UPDATE SET
+								review_after = gc_review_after ('manifest_delete'), event = 'manifest_delete', config_media_type_id = OLD.configuration_media_type_id, top_level_namespace_id = OLD.top_level_namespace_id;
+					END IF;
+					RETURN NULL;
+				END;
+				$$
+				LANGUAGE plpgsql`,
+			},
+			Down: []string{
+				// restore previous function definition
+				`CREATE OR REPLACE FUNCTION gc_track_deleted_manifests ()
+					RETURNS TRIGGER
+					AS $$
+				BEGIN
+					IF OLD.configuration_blob_digest IS NOT NULL THEN
+						INSERT INTO gc_blob_review_queue (digest, review_after, event, config_media_type_id)
+							VALUES (OLD.configuration_blob_digest, gc_review_after ('manifest_delete'), 'manifest_delete', OLD.configuration_media_type_id)
+						ON CONFLICT (digest)
+							DO UPDATE SET
+								review_after = gc_review_after ('manifest_delete'), event = 'manifest_delete', config_media_type_id = OLD.configuration_media_type_id;
+					END IF;
+					RETURN NULL;
+				END;
+				$$
+				LANGUAGE plpgsql`,
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
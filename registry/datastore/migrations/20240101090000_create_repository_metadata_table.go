@@ -0,0 +1,32 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20240101090000_create_repository_metadata_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS repository_metadata (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					updated_at timestamp WITH time zone,
+					description text NOT NULL DEFAULT '',
+					readme text NOT NULL DEFAULT '',
+					links jsonb NOT NULL DEFAULT '[]'::jsonb,
+					CONSTRAINT pk_repository_metadata PRIMARY KEY (top_level_namespace_id, repository_id),
+					CONSTRAINT fk_repository_metadata_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_repository_metadata_description_length CHECK ((char_length(description) <= 255)),
+					CONSTRAINT check_repository_metadata_readme_length CHECK ((char_length(readme) <= 102400))
+				)`,
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS repository_metadata CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20240102090000_create_repository_labels_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS repository_labels (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					key text NOT NULL,
+					value text NOT NULL,
+					CONSTRAINT pk_repository_labels PRIMARY KEY (top_level_namespace_id, repository_id, key),
+					CONSTRAINT fk_repository_labels_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_repository_labels_key_length CHECK ((char_length(key) <= 63)),
+					CONSTRAINT check_repository_labels_value_length CHECK ((char_length(value) <= 255))
+				)`,
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS repository_labels CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
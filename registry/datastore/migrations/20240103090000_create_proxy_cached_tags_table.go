@@ -0,0 +1,30 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20240103090000_create_proxy_cached_tags_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS proxy_cached_tags (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					cached_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					name text NOT NULL,
+					CONSTRAINT pk_proxy_cached_tags PRIMARY KEY (top_level_namespace_id, repository_id, name),
+					CONSTRAINT fk_proxy_cached_tags_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES repositories (top_level_namespace_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_proxy_cached_tags_name_length CHECK ((char_length(name) <= 255))
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_proxy_cached_tags_on_cached_at ON proxy_cached_tags USING btree (cached_at)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_proxy_cached_tags_on_cached_at CASCADE",
+				"DROP TABLE IF EXISTS proxy_cached_tags CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
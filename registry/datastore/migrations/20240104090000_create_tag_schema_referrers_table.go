@@ -0,0 +1,32 @@
+package migrations
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func init() {
+	m := &Migration{
+		Migration: &migrate.Migration{
+			Id: "20240104090000_create_tag_schema_referrers_table",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS tag_schema_referrers (
+					top_level_namespace_id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					subject_id bigint NOT NULL,
+					referrer_id bigint NOT NULL,
+					created_at timestamp WITH time zone NOT NULL DEFAULT now(),
+					CONSTRAINT pk_tag_schema_referrers PRIMARY KEY (top_level_namespace_id, repository_id, subject_id, referrer_id),
+					CONSTRAINT fk_tag_schema_referrers_tp_lvl_nmspc_id_rpsty_id_sbjct_id_mnfsts FOREIGN KEY (top_level_namespace_id, repository_id, subject_id) REFERENCES manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE,
+					CONSTRAINT fk_tag_schema_referrers_tp_lvl_nmspc_id_rpsty_id_rfrr_id_mnfsts FOREIGN KEY (top_level_namespace_id, repository_id, referrer_id) REFERENCES manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE,
+					CONSTRAINT check_tag_schema_referrers_subject_id_and_referrer_id_differ CHECK (subject_id <> referrer_id)
+				)`,
+				"CREATE INDEX IF NOT EXISTS index_tag_schema_referrers_on_tp_lvl_nmspc_id_rpstry_id_rfrr_id ON tag_schema_referrers USING btree (top_level_namespace_id, repository_id, referrer_id)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS index_tag_schema_referrers_on_tp_lvl_nmspc_id_rpstry_id_rfrr_id CASCADE",
+				"DROP TABLE IF EXISTS tag_schema_referrers CASCADE",
+			},
+		},
+		PostDeployment: false,
+	}
+
+	allMigrations = append(allMigrations, m)
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import "fmt"
+
+// EnqueueBackgroundMigration returns a statement that enqueues a background migration, to be used in the Up statements
+// of the schema migration that introduces it. The migration processes the existing rows of table in batches of
+// batchSize consecutive values of column (usually the primary key), from its current minimum to its current maximum,
+// using the job registered with jobName in package bbm. Rows created afterwards are not processed, so the application
+// must already handle them (e.g. by populating a new column on insert) when the migration is enqueued. The statement is
+// a no-op if a background migration with the same name exists.
+func EnqueueBackgroundMigration(name, jobName, table, column string, batchSize int) string {
+	return fmt.Sprintf(`INSERT INTO background_migrations (name, job_name, table_name, column_name, batch_size, min_value,
+			max_value, next_value)
+		SELECT
+			'%[1]s',
+			'%[2]s',
+			'%[3]s',
+			'%[4]s',
+			%[5]d,
+			COALESCE(min(%[4]s), 1),
+			COALESCE(max(%[4]s), 0),
+			COALESCE(min(%[4]s), 1)
+		FROM
+			%[3]s
+		ON CONFLICT (name)
+			DO NOTHING`, name, jobName, table, column, batchSize)
+}
+
+// DeleteBackgroundMigration returns a statement that deletes a background migration, to be used in the Down statements
+// of the schema migration that introduced it.
+func DeleteBackgroundMigration(name string) string {
+	return fmt.Sprintf("DELETE FROM background_migrations WHERE name = '%s'", name)
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueueBackgroundMigration(t *testing.T) {
+	q := EnqueueBackgroundMigration("20231025100000_backfill_manifests_foo", "backfill_manifests_foo", "manifests", "id", 1000)
+
+	require.Contains(t, q, "INSERT INTO background_migrations")
+	require.Contains(t, q, "'20231025100000_backfill_manifests_foo',")
+	require.Contains(t, q, "'backfill_manifests_foo',")
+	require.Contains(t, q, "1000,")
+	require.Contains(t, q, "COALESCE(max(id), 0)")
+	require.Contains(t, q, "FROM\n\t\t\tmanifests")
+	require.Contains(t, q, "ON CONFLICT (name)")
+
+	// enqueuing does not lock the migrated table
+	require.Empty(t, alteredTables([]string{q}))
+}
+
+func TestDeleteBackgroundMigration(t *testing.T) {
+	require.Equal(t,
+		"DELETE FROM background_migrations WHERE name = '20231025100000_backfill_manifests_foo'",
+		DeleteBackgroundMigration("20231025100000_backfill_manifests_foo"),
+	)
+}
@@ -1,7 +1,10 @@
 package migrations
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
 	"time"
 
 	migrate "github.com/rubenv/sql-migrate"
@@ -122,16 +125,16 @@ func (m *migrator) DownNPlan(n int) ([]string, error) {
 	return m.plan(migrate.Down, n)
 }
 
-// migrationStatus represents the status of a migration. Unknown will be set to true if a migration was applied but is
+// MigrationStatus represents the status of a migration. Unknown will be set to true if a migration was applied but is
 // not known by the current build.
-type migrationStatus struct {
+type MigrationStatus struct {
 	Unknown        bool
 	PostDeployment bool
 	AppliedAt      *time.Time
 }
 
 // Status returns the status of all migrations, indexed by migration ID.
-func (m *migrator) Status() (map[string]*migrationStatus, error) {
+func (m *migrator) Status() (map[string]*MigrationStatus, error) {
 	applied, err := migrate.GetMigrationRecords(m.db, dialect)
 	if err != nil {
 		return nil, err
@@ -141,9 +144,9 @@ func (m *migrator) Status() (map[string]*migrationStatus, error) {
 		return nil, err
 	}
 
-	statuses := make(map[string]*migrationStatus, len(applied))
+	statuses := make(map[string]*MigrationStatus, len(applied))
 	for _, k := range known {
-		statuses[k.Id] = &migrationStatus{}
+		statuses[k.Id] = &MigrationStatus{}
 
 		if mig := m.findMigrationByID(k.Id); mig != nil && mig.PostDeployment {
 			statuses[k.Id].PostDeployment = true
@@ -152,7 +155,7 @@ func (m *migrator) Status() (map[string]*migrationStatus, error) {
 
 	for _, m := range applied {
 		if _, ok := statuses[m.Id]; !ok {
-			statuses[m.Id] = &migrationStatus{Unknown: true}
+			statuses[m.Id] = &MigrationStatus{Unknown: true}
 		}
 
 		statuses[m.Id].AppliedAt = &m.AppliedAt
@@ -161,6 +164,52 @@ func (m *migrator) Status() (map[string]*migrationStatus, error) {
 	return statuses, nil
 }
 
+// SchemaChecksum returns a SHA-256 checksum of the tables, columns and indexes in the public schema. Databases with the
+// same checksum have the same schema, regardless of how they got there, which allows detecting drift caused by manual
+// changes or diverging migration histories.
+func (m *migrator) SchemaChecksum() (string, error) {
+	q := `SELECT
+			'column',
+			table_name || '.' || column_name,
+			data_type || ' ' || is_nullable || ' ' || COALESCE(column_default, '')
+		FROM
+			information_schema.columns
+		WHERE
+			table_schema = 'public'
+		UNION ALL
+		SELECT
+			'index',
+			tablename || '.' || indexname,
+			indexdef
+		FROM
+			pg_indexes
+		WHERE
+			schemaname = 'public'
+		ORDER BY
+			1,
+			2`
+
+	rows, err := m.db.Query(q)
+	if err != nil {
+		return "", fmt.Errorf("reading database schema: %w", err)
+	}
+	defer rows.Close()
+
+	h := sha256.New()
+	for rows.Next() {
+		var kind, name, def string
+		if err := rows.Scan(&kind, &name, &def); err != nil {
+			return "", fmt.Errorf("scanning database schema: %w", err)
+		}
+		fmt.Fprintf(h, "%s\t%s\t%s\n", kind, name, def)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("scanning database schema: %w", err)
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // HasPending determines whether all known migrations are applied or not.
 func (m *migrator) HasPending() (bool, error) {
 	records, err := migrate.GetMigrationRecords(m.db, dialect)
@@ -757,6 +757,70 @@ func TestMigrator_HasPending_Yes_PendingPostDeployment(t *testing.T) {
 	require.True(t, pending)
 }
 
+func TestMigrator_SchemaChecksum(t *testing.T) {
+	db, err := testutil.NewDBFromEnv()
+	require.NoError(t, err)
+	defer cleanupDB(t, db)
+
+	m := migrations.NewMigrator(db.DB, migrations.Source(testmigrations.All()))
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	checksum, err := m.SchemaChecksum()
+	require.NoError(t, err)
+	require.Regexp(t, "^sha256:[a-f0-9]{64}$", checksum)
+
+	// make sure it's deterministic
+	again, err := m.SchemaChecksum()
+	require.NoError(t, err)
+	require.Equal(t, checksum, again)
+
+	// make sure it detects schema changes
+	_, err = db.DB.Exec("CREATE TABLE schema_checksum_test (id bigint)")
+	require.NoError(t, err)
+	defer db.DB.Exec("DROP TABLE schema_checksum_test")
+
+	changed, err := m.SchemaChecksum()
+	require.NoError(t, err)
+	require.NotEqual(t, checksum, changed)
+}
+
+func TestMigrator_UpNPreflight(t *testing.T) {
+	db, err := testutil.NewDBFromEnv()
+	require.NoError(t, err)
+	defer cleanupDB(t, db)
+
+	// apply up to the creation of configurations_test, which the next migration references
+	m := migrations.NewMigrator(db.DB, migrations.Source(testmigrations.All()))
+	_, err = m.UpN(3)
+	require.NoError(t, err)
+
+	res, err := m.UpNPreflight(1, 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"configurations_test"}, res.Tables)
+	require.False(t, res.Blocked())
+
+	// hold a lock on configurations_test from another session
+	tx, err := db.DB.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.Exec("LOCK TABLE configurations_test IN ACCESS SHARE MODE")
+	require.NoError(t, err)
+
+	res, err = m.UpNPreflight(1, 0)
+	require.NoError(t, err)
+	require.True(t, res.Blocked())
+	require.Len(t, res.Locks, 1)
+	require.Equal(t, "configurations_test", res.Locks[0].Table)
+	require.Equal(t, "AccessShareLock", res.Locks[0].Mode)
+	require.NotZero(t, res.Locks[0].PID)
+
+	// locks held by transactions younger than the threshold are ignored
+	res, err = m.UpNPreflight(1, time.Hour)
+	require.NoError(t, err)
+	require.False(t, res.Blocked())
+}
+
 func cleanupDB(t *testing.T, db *datastore.DB) {
 	_, err := db.DB.Exec("DELETE FROM " + migrationTableName)
 	require.NoError(t, err)
@@ -0,0 +1,139 @@
+package migrations
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tableRefRegexp matches the statements that lock an existing table in a mode that conflicts with regular reads or
+// writes, capturing the (possibly schema qualified and quoted) table name.
+var tableRefRegexp = regexp.MustCompile(`(?is)\b(?:` +
+	`ALTER\s+TABLE|` +
+	`DROP\s+TABLE|` +
+	`TRUNCATE(?:\s+TABLE)?|` +
+	`LOCK(?:\s+TABLE)?|` +
+	`PARTITION\s+OF|` +
+	`REFERENCES|` +
+	`CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:[\w"]+\s+)?ON|` +
+	`CREATE\s+(?:OR\s+REPLACE\s+)?TRIGGER\s+[\w"]+\s+(?:BEFORE|AFTER|INSTEAD\s+OF)\s+[\w\s,]*?\s+ON` +
+	`)\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w"]+(?:\.[\w"]+)?)`)
+
+// alteredTables returns the sorted and deduplicated names of the existing tables that the given statements lock. Schema
+// qualifiers and quotes are stripped.
+func alteredTables(statements []string) []string {
+	seen := make(map[string]struct{})
+	for _, s := range statements {
+		for _, match := range tableRefRegexp.FindAllStringSubmatch(s, -1) {
+			name := match[1]
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+			name = strings.Trim(name, `"`)
+			if name != "" {
+				seen[name] = struct{}{}
+			}
+		}
+	}
+
+	tables := make([]string, 0, len(seen))
+	for name := range seen {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+
+	return tables
+}
+
+// TableLock represents a lock held by another database session on a table, or on one of its partitions.
+type TableLock struct {
+	PID   int
+	Table string
+	Mode  string
+	State string
+	Query string
+	// Age is how long the transaction holding the lock has been open.
+	Age time.Duration
+}
+
+// PreflightResult is the result of a pre-flight check for a set of pending up migrations.
+type PreflightResult struct {
+	// Tables are the existing tables altered by the pending migrations.
+	Tables []string
+	// Locks are the locks held on Tables by transactions open for longer than the check threshold.
+	Locks []*TableLock
+}
+
+// Blocked determines whether applying the pending migrations is likely to wait on other sessions.
+func (r *PreflightResult) Blocked() bool {
+	return len(r.Locks) > 0
+}
+
+// UpNPreflight inspects the tables that the next n pending up migrations (all if n is 0) alter and looks for locks held
+// on them by transactions open for longer than threshold. Such transactions would block the migrations, which would in
+// turn block every other query on those tables while waiting.
+func (m *migrator) UpNPreflight(n int, threshold time.Duration) (*PreflightResult, error) {
+	plan, err := m.UpNPlan(n)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	for _, id := range plan {
+		if mig := m.findMigrationByID(id); mig != nil {
+			statements = append(statements, mig.Up...)
+		}
+	}
+
+	res := &PreflightResult{Tables: alteredTables(statements), Locks: make([]*TableLock, 0)}
+	if len(res.Tables) == 0 {
+		return res, nil
+	}
+
+	q := `SELECT DISTINCT
+			a.pid,
+			COALESCE(p.relname, c.relname),
+			l.mode,
+			COALESCE(a.state, ''),
+			COALESCE(a.query, ''),
+			EXTRACT(EPOCH FROM now() - a.xact_start)
+		FROM
+			pg_locks AS l
+			JOIN pg_class AS c ON c.oid = l.relation
+			JOIN pg_stat_activity AS a ON a.pid = l.pid
+			LEFT JOIN pg_inherits AS i ON i.inhrelid = c.oid
+			LEFT JOIN pg_class AS p ON p.oid = i.inhparent
+		WHERE
+			l.pid <> pg_backend_pid()
+			AND l.granted
+			AND (c.relname = ANY ($1::text[])
+				OR p.relname = ANY ($1::text[]))
+			AND a.xact_start < now() - make_interval(secs => $2)
+		ORDER BY
+			6 DESC,
+			1,
+			2`
+
+	rows, err := m.db.Query(q, res.Tables, threshold.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("finding locks on migrated tables: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var age float64
+		l := new(TableLock)
+		if err := rows.Scan(&l.PID, &l.Table, &l.Mode, &l.State, &l.Query, &age); err != nil {
+			return nil, fmt.Errorf("scanning table lock: %w", err)
+		}
+		l.Age = time.Duration(age * float64(time.Second))
+		res.Locks = append(res.Locks, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning table locks: %w", err)
+	}
+
+	return res, nil
+}
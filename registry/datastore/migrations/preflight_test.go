@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlteredTables(t *testing.T) {
+	tests := []struct {
+		name       string
+		statements []string
+		expected   []string
+	}{
+		{
+			name:     "none",
+			expected: []string{},
+		},
+		{
+			name: "new table without references",
+			statements: []string{
+				"CREATE TABLE IF NOT EXISTS foo (id bigint NOT NULL, CONSTRAINT pk_foo PRIMARY KEY (id))",
+			},
+			expected: []string{},
+		},
+		{
+			name: "new table with references",
+			statements: []string{
+				`CREATE TABLE IF NOT EXISTS foo (
+					id bigint NOT NULL,
+					repository_id bigint NOT NULL,
+					CONSTRAINT fk_foo_repository_id_repositories FOREIGN KEY (repository_id) REFERENCES repositories (id) ON DELETE CASCADE
+				)`,
+			},
+			expected: []string{"repositories"},
+		},
+		{
+			name: "partition",
+			statements: []string{
+				"CREATE TABLE partitions.foo_p_0 PARTITION OF public.foo FOR VALUES WITH (MODULUS 64, REMAINDER 0)",
+			},
+			expected: []string{"foo"},
+		},
+		{
+			name: "alter and drop tables",
+			statements: []string{
+				"ALTER TABLE public.manifests ADD COLUMN IF NOT EXISTS foo text",
+				`ALTER TABLE IF EXISTS ONLY "tags" DROP COLUMN bar`,
+				"DROP TABLE IF EXISTS blobs CASCADE",
+				"TRUNCATE gc_review_after_defaults",
+				"LOCK TABLE repositories IN ACCESS EXCLUSIVE MODE",
+			},
+			expected: []string{"blobs", "gc_review_after_defaults", "manifests", "repositories", "tags"},
+		},
+		{
+			name: "indexes",
+			statements: []string{
+				"CREATE INDEX IF NOT EXISTS index_manifests_on_foo ON manifests USING btree (foo)",
+				"CREATE UNIQUE INDEX CONCURRENTLY unique_tags_bar ON ONLY public.tags (bar)",
+				"CREATE INDEX ON layers (digest)",
+				"DROP INDEX IF EXISTS index_manifests_on_foo",
+			},
+			expected: []string{"layers", "manifests", "tags"},
+		},
+		{
+			name: "triggers",
+			statements: []string{
+				"CREATE TRIGGER gc_track_blob_uploads_trigger AFTER INSERT OR UPDATE OF digest ON blobs FOR EACH ROW EXECUTE PROCEDURE gc_track_blob_uploads ()",
+			},
+			expected: []string{"blobs"},
+		},
+		{
+			name: "deduplicated across statements",
+			statements: []string{
+				"ALTER TABLE manifests ADD COLUMN foo text",
+				"ALTER TABLE manifests ADD COLUMN bar text",
+			},
+			expected: []string{"manifests"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, alteredTables(test.statements))
+		})
+	}
+}
@@ -66,11 +66,12 @@ CREATE FUNCTION public.gc_track_deleted_layers ()
     AS $$
 BEGIN
     IF (TG_LEVEL = 'STATEMENT') THEN
-        INSERT INTO gc_blob_review_queue (digest, review_after, event)
+        INSERT INTO gc_blob_review_queue (digest, review_after, event, top_level_namespace_id)
         SELECT
             deleted_rows.digest,
             gc_review_after ('layer_delete'),
-            'layer_delete'
+            'layer_delete',
+            deleted_rows.top_level_namespace_id
         FROM
             old_table deleted_rows
         ORDER BY
@@ -78,13 +79,14 @@ BEGIN
         ON CONFLICT (digest)
             DO UPDATE SET
                 review_after = gc_review_after ('layer_delete'),
-                event = 'layer_delete';
+                event = 'layer_delete',
+                top_level_namespace_id = EXCLUDED.top_level_namespace_id;
     ELSIF (TG_LEVEL = 'ROW') THEN
-        INSERT INTO gc_blob_review_queue (digest, review_after, event)
-            VALUES (OLD.digest, gc_review_after ('layer_delete'), 'layer_delete')
+        INSERT INTO gc_blob_review_queue (digest, review_after, event, top_level_namespace_id)
+            VALUES (OLD.digest, gc_review_after ('layer_delete'), 'layer_delete', OLD.top_level_namespace_id)
         ON CONFLICT (digest)
             DO UPDATE SET
-                review_after = gc_review_after ('layer_delete'), event = 'layer_delete';
+                review_after = gc_review_after ('layer_delete'), event = 'layer_delete', top_level_namespace_id = OLD.top_level_namespace_id;
     END IF;
     RETURN NULL;
 END;
@@ -110,11 +112,11 @@ CREATE FUNCTION public.gc_track_deleted_manifests ()
     AS $$
 BEGIN
     IF OLD.configuration_blob_digest IS NOT NULL THEN
-        INSERT INTO gc_blob_review_queue (digest, review_after, event)
-            VALUES (OLD.configuration_blob_digest, gc_review_after ('manifest_delete'), 'manifest_delete')
+        INSERT INTO gc_blob_review_queue (digest, review_after, event, config_media_type_id, top_level_namespace_id)
+            VALUES (OLD.configuration_blob_digest, gc_review_after ('manifest_delete'), 'manifest_delete', OLD.configuration_media_type_id, OLD.top_level_namespace_id)
         ON CONFLICT (digest)
             DO UPDATE SET
-                review_after = gc_review_after ('manifest_delete'), event = 'manifest_delete';
+                review_after = gc_review_after ('manifest_delete'), event = 'manifest_delete', config_media_type_id = OLD.configuration_media_type_id, top_level_namespace_id = OLD.top_level_namespace_id;
     END IF;
     RETURN NULL;
 END;
@@ -4036,7 +4038,10 @@ CREATE TABLE public.manifests (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 )
 PARTITION BY HASH (top_level_namespace_id);
 
@@ -4056,7 +4061,10 @@ CREATE TABLE partitions.manifests_p_0 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_0
@@ -4078,7 +4086,10 @@ CREATE TABLE partitions.manifests_p_1 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_1
@@ -4100,7 +4111,10 @@ CREATE TABLE partitions.manifests_p_10 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_10
@@ -4122,7 +4136,10 @@ CREATE TABLE partitions.manifests_p_11 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_11
@@ -4144,7 +4161,10 @@ CREATE TABLE partitions.manifests_p_12 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_12
@@ -4166,7 +4186,10 @@ CREATE TABLE partitions.manifests_p_13 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_13
@@ -4188,7 +4211,10 @@ CREATE TABLE partitions.manifests_p_14 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_14
@@ -4210,7 +4236,10 @@ CREATE TABLE partitions.manifests_p_15 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_15
@@ -4232,7 +4261,10 @@ CREATE TABLE partitions.manifests_p_16 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_16
@@ -4254,7 +4286,10 @@ CREATE TABLE partitions.manifests_p_17 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_17
@@ -4276,7 +4311,10 @@ CREATE TABLE partitions.manifests_p_18 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_18
@@ -4298,7 +4336,10 @@ CREATE TABLE partitions.manifests_p_19 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_19
@@ -4320,7 +4361,10 @@ CREATE TABLE partitions.manifests_p_2 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_2
@@ -4342,7 +4386,10 @@ CREATE TABLE partitions.manifests_p_20 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_20
@@ -4364,7 +4411,10 @@ CREATE TABLE partitions.manifests_p_21 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_21
@@ -4386,7 +4436,10 @@ CREATE TABLE partitions.manifests_p_22 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_22
@@ -4408,7 +4461,10 @@ CREATE TABLE partitions.manifests_p_23 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_23
@@ -4430,7 +4486,10 @@ CREATE TABLE partitions.manifests_p_24 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_24
@@ -4452,7 +4511,10 @@ CREATE TABLE partitions.manifests_p_25 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_25
@@ -4474,7 +4536,10 @@ CREATE TABLE partitions.manifests_p_26 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_26
@@ -4496,7 +4561,10 @@ CREATE TABLE partitions.manifests_p_27 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_27
@@ -4518,7 +4586,10 @@ CREATE TABLE partitions.manifests_p_28 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_28
@@ -4540,7 +4611,10 @@ CREATE TABLE partitions.manifests_p_29 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_29
@@ -4562,7 +4636,10 @@ CREATE TABLE partitions.manifests_p_3 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_3
@@ -4584,7 +4661,10 @@ CREATE TABLE partitions.manifests_p_30 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_30
@@ -4606,7 +4686,10 @@ CREATE TABLE partitions.manifests_p_31 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_31
@@ -4628,7 +4711,10 @@ CREATE TABLE partitions.manifests_p_32 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_32
@@ -4650,7 +4736,10 @@ CREATE TABLE partitions.manifests_p_33 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_33
@@ -4672,7 +4761,10 @@ CREATE TABLE partitions.manifests_p_34 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_34
@@ -4694,7 +4786,10 @@ CREATE TABLE partitions.manifests_p_35 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_35
@@ -4716,7 +4811,10 @@ CREATE TABLE partitions.manifests_p_36 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_36
@@ -4738,7 +4836,10 @@ CREATE TABLE partitions.manifests_p_37 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_37
@@ -4760,7 +4861,10 @@ CREATE TABLE partitions.manifests_p_38 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_38
@@ -4782,7 +4886,10 @@ CREATE TABLE partitions.manifests_p_39 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_39
@@ -4804,7 +4911,10 @@ CREATE TABLE partitions.manifests_p_4 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_4
@@ -4826,7 +4936,10 @@ CREATE TABLE partitions.manifests_p_40 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_40
@@ -4848,7 +4961,10 @@ CREATE TABLE partitions.manifests_p_41 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_41
@@ -4870,7 +4986,10 @@ CREATE TABLE partitions.manifests_p_42 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_42
@@ -4892,7 +5011,10 @@ CREATE TABLE partitions.manifests_p_43 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_43
@@ -4914,7 +5036,10 @@ CREATE TABLE partitions.manifests_p_44 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_44
@@ -4936,7 +5061,10 @@ CREATE TABLE partitions.manifests_p_45 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_45
@@ -4958,7 +5086,10 @@ CREATE TABLE partitions.manifests_p_46 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_46
@@ -4980,7 +5111,10 @@ CREATE TABLE partitions.manifests_p_47 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_47
@@ -5002,7 +5136,10 @@ CREATE TABLE partitions.manifests_p_48 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_48
@@ -5024,7 +5161,10 @@ CREATE TABLE partitions.manifests_p_49 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_49
@@ -5046,7 +5186,10 @@ CREATE TABLE partitions.manifests_p_5 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_5
@@ -5068,7 +5211,10 @@ CREATE TABLE partitions.manifests_p_50 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_50
@@ -5090,7 +5236,10 @@ CREATE TABLE partitions.manifests_p_51 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_51
@@ -5112,7 +5261,10 @@ CREATE TABLE partitions.manifests_p_52 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_52
@@ -5134,7 +5286,10 @@ CREATE TABLE partitions.manifests_p_53 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_53
@@ -5156,7 +5311,10 @@ CREATE TABLE partitions.manifests_p_54 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_54
@@ -5178,7 +5336,10 @@ CREATE TABLE partitions.manifests_p_55 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_55
@@ -5200,7 +5361,10 @@ CREATE TABLE partitions.manifests_p_56 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_56
@@ -5222,7 +5386,10 @@ CREATE TABLE partitions.manifests_p_57 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_57
@@ -5244,7 +5411,10 @@ CREATE TABLE partitions.manifests_p_58 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_58
@@ -5266,7 +5436,10 @@ CREATE TABLE partitions.manifests_p_59 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_59
@@ -5288,7 +5461,10 @@ CREATE TABLE partitions.manifests_p_6 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_6
@@ -5310,7 +5486,10 @@ CREATE TABLE partitions.manifests_p_60 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_60
@@ -5332,7 +5511,10 @@ CREATE TABLE partitions.manifests_p_61 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_61
@@ -5354,7 +5536,10 @@ CREATE TABLE partitions.manifests_p_62 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_62
@@ -5376,7 +5561,10 @@ CREATE TABLE partitions.manifests_p_63 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_63
@@ -5398,7 +5586,10 @@ CREATE TABLE partitions.manifests_p_7 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_7
@@ -5420,7 +5611,10 @@ CREATE TABLE partitions.manifests_p_8 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_8
@@ -5442,7 +5636,10 @@ CREATE TABLE partitions.manifests_p_9 (
     total_size bigint NOT NULL,
     non_distributable_layers boolean DEFAULT FALSE,
     subject_id bigint,
-    artifact_media_type_id bigint
+    artifact_media_type_id bigint,
+    last_pulled_at timestamp with time zone,
+    chart_name text,
+    chart_version text
 );
 
 ALTER TABLE ONLY public.manifests ATTACH PARTITION partitions.manifests_p_9
@@ -6169,6 +6366,7 @@ CREATE TABLE public.tags (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 )
 PARTITION BY HASH (top_level_namespace_id);
@@ -6181,6 +6379,7 @@ CREATE TABLE partitions.tags_p_0 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6195,6 +6394,7 @@ CREATE TABLE partitions.tags_p_1 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6209,6 +6409,7 @@ CREATE TABLE partitions.tags_p_10 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6223,6 +6424,7 @@ CREATE TABLE partitions.tags_p_11 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6237,6 +6439,7 @@ CREATE TABLE partitions.tags_p_12 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6251,6 +6454,7 @@ CREATE TABLE partitions.tags_p_13 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6265,6 +6469,7 @@ CREATE TABLE partitions.tags_p_14 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6279,6 +6484,7 @@ CREATE TABLE partitions.tags_p_15 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6293,6 +6499,7 @@ CREATE TABLE partitions.tags_p_16 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6307,6 +6514,7 @@ CREATE TABLE partitions.tags_p_17 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6321,6 +6529,7 @@ CREATE TABLE partitions.tags_p_18 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6335,6 +6544,7 @@ CREATE TABLE partitions.tags_p_19 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6349,6 +6559,7 @@ CREATE TABLE partitions.tags_p_2 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6363,6 +6574,7 @@ CREATE TABLE partitions.tags_p_20 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6377,6 +6589,7 @@ CREATE TABLE partitions.tags_p_21 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6391,6 +6604,7 @@ CREATE TABLE partitions.tags_p_22 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6405,6 +6619,7 @@ CREATE TABLE partitions.tags_p_23 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6419,6 +6634,7 @@ CREATE TABLE partitions.tags_p_24 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6433,6 +6649,7 @@ CREATE TABLE partitions.tags_p_25 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6447,6 +6664,7 @@ CREATE TABLE partitions.tags_p_26 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6461,6 +6679,7 @@ CREATE TABLE partitions.tags_p_27 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6475,6 +6694,7 @@ CREATE TABLE partitions.tags_p_28 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6489,6 +6709,7 @@ CREATE TABLE partitions.tags_p_29 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6503,6 +6724,7 @@ CREATE TABLE partitions.tags_p_3 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6517,6 +6739,7 @@ CREATE TABLE partitions.tags_p_30 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6531,6 +6754,7 @@ CREATE TABLE partitions.tags_p_31 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6545,6 +6769,7 @@ CREATE TABLE partitions.tags_p_32 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6559,6 +6784,7 @@ CREATE TABLE partitions.tags_p_33 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6573,6 +6799,7 @@ CREATE TABLE partitions.tags_p_34 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6587,6 +6814,7 @@ CREATE TABLE partitions.tags_p_35 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6601,6 +6829,7 @@ CREATE TABLE partitions.tags_p_36 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6615,6 +6844,7 @@ CREATE TABLE partitions.tags_p_37 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6629,6 +6859,7 @@ CREATE TABLE partitions.tags_p_38 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6643,6 +6874,7 @@ CREATE TABLE partitions.tags_p_39 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6657,6 +6889,7 @@ CREATE TABLE partitions.tags_p_4 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6671,6 +6904,7 @@ CREATE TABLE partitions.tags_p_40 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6685,6 +6919,7 @@ CREATE TABLE partitions.tags_p_41 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6699,6 +6934,7 @@ CREATE TABLE partitions.tags_p_42 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6713,6 +6949,7 @@ CREATE TABLE partitions.tags_p_43 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6727,6 +6964,7 @@ CREATE TABLE partitions.tags_p_44 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6741,6 +6979,7 @@ CREATE TABLE partitions.tags_p_45 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6755,6 +6994,7 @@ CREATE TABLE partitions.tags_p_46 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6769,6 +7009,7 @@ CREATE TABLE partitions.tags_p_47 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6783,6 +7024,7 @@ CREATE TABLE partitions.tags_p_48 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6797,6 +7039,7 @@ CREATE TABLE partitions.tags_p_49 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6811,6 +7054,7 @@ CREATE TABLE partitions.tags_p_5 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6825,6 +7069,7 @@ CREATE TABLE partitions.tags_p_50 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6839,6 +7084,7 @@ CREATE TABLE partitions.tags_p_51 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6853,6 +7099,7 @@ CREATE TABLE partitions.tags_p_52 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6867,6 +7114,7 @@ CREATE TABLE partitions.tags_p_53 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6881,6 +7129,7 @@ CREATE TABLE partitions.tags_p_54 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6895,6 +7144,7 @@ CREATE TABLE partitions.tags_p_55 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6909,6 +7159,7 @@ CREATE TABLE partitions.tags_p_56 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6923,6 +7174,7 @@ CREATE TABLE partitions.tags_p_57 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6937,6 +7189,7 @@ CREATE TABLE partitions.tags_p_58 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6951,6 +7204,7 @@ CREATE TABLE partitions.tags_p_59 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6965,6 +7219,7 @@ CREATE TABLE partitions.tags_p_6 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6979,6 +7234,7 @@ CREATE TABLE partitions.tags_p_60 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -6993,6 +7249,7 @@ CREATE TABLE partitions.tags_p_61 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -7007,6 +7264,7 @@ CREATE TABLE partitions.tags_p_62 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -7021,6 +7279,7 @@ CREATE TABLE partitions.tags_p_63 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -7035,6 +7294,7 @@ CREATE TABLE partitions.tags_p_7 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -7049,6 +7309,7 @@ CREATE TABLE partitions.tags_p_8 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
@@ -7063,18 +7324,84 @@ CREATE TABLE partitions.tags_p_9 (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone,
     name text NOT NULL,
+    last_pulled_at timestamp with time zone,
     CONSTRAINT check_tags_name_length CHECK ((char_length(name) <= 255))
 );
 
 ALTER TABLE ONLY public.tags ATTACH PARTITION partitions.tags_p_9
 FOR VALUES WITH (MODULUS 64, REMAINDER 9);
 
+CREATE TABLE public.background_migrations (
+    id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    batch_size integer NOT NULL,
+    failed_attempts integer DEFAULT 0 NOT NULL,
+    min_value bigint NOT NULL,
+    max_value bigint NOT NULL,
+    next_value bigint NOT NULL,
+    processed_rows bigint DEFAULT 0 NOT NULL,
+    name text NOT NULL,
+    job_name text NOT NULL,
+    table_name text NOT NULL,
+    column_name text NOT NULL,
+    status text DEFAULT 'active'::text NOT NULL,
+    error_message text,
+    CONSTRAINT check_background_migrations_batch_size_positive CHECK ((batch_size > 0)),
+    CONSTRAINT check_background_migrations_column_name_length CHECK ((char_length(column_name) <= 255)),
+    CONSTRAINT check_background_migrations_error_message_length CHECK ((char_length(error_message) <= 1024)),
+    CONSTRAINT check_background_migrations_job_name_length CHECK ((char_length(job_name) <= 255)),
+    CONSTRAINT check_background_migrations_name_length CHECK ((char_length(name) <= 255)),
+    CONSTRAINT check_background_migrations_status CHECK ((status = ANY (ARRAY['active'::text, 'finished'::text, 'failed'::text]))),
+    CONSTRAINT check_background_migrations_table_name_length CHECK ((char_length(table_name) <= 255))
+);
+
+ALTER TABLE public.background_migrations
+    ALTER COLUMN id
+    ADD GENERATED BY DEFAULT AS IDENTITY (SEQUENCE NAME
+        public.background_migrations_id_seq START WITH 1 INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1);
+
+CREATE TABLE public.cleanup_policies (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone,
+    next_run_at timestamp with time zone NOT NULL,
+    last_run_at timestamp with time zone,
+    cadence interval NOT NULL,
+    older_than interval DEFAULT '00:00:00'::interval NOT NULL,
+    keep_n integer DEFAULT 0 NOT NULL,
+    enabled boolean DEFAULT true NOT NULL,
+    name_regex_delete text NOT NULL,
+    name_regex_keep text,
+    CONSTRAINT check_cleanup_policies_keep_n_not_negative CHECK ((keep_n >= 0)),
+    CONSTRAINT check_cleanup_policies_name_regex_delete_length CHECK ((char_length(name_regex_delete) <= 255)),
+    CONSTRAINT check_cleanup_policies_name_regex_keep_length CHECK ((char_length(name_regex_keep) <= 255))
+);
+
+CREATE TABLE public.corrupted_blobs (
+    detected_at timestamp with time zone DEFAULT now() NOT NULL,
+    checked_at timestamp with time zone DEFAULT now() NOT NULL,
+    size bigint NOT NULL,
+    digest bytea NOT NULL,
+    actual_digest bytea,
+    reason text NOT NULL,
+    CONSTRAINT check_corrupted_blobs_reason_length CHECK ((char_length(reason) <= 255))
+);
+
 CREATE TABLE public.gc_blob_review_queue (
     review_after timestamp with time zone DEFAULT (now() + '1 day'::interval) NOT NULL,
     review_count integer DEFAULT 0 NOT NULL,
     digest bytea NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     event text,
+    config_media_type_id smallint,
+    top_level_namespace_id bigint,
     CONSTRAINT check_gc_blob_review_queue_event_length CHECK ((char_length(event) <= 255)),
     CONSTRAINT check_gc_blob_review_queue_event_not_null CHECK ((event IS NOT NULL))
 );
@@ -7113,6 +7440,15 @@ CREATE TABLE public.gc_review_after_defaults (
     CONSTRAINT check_gc_review_after_defaults_event_length CHECK ((char_length(event) <= 255))
 );
 
+CREATE TABLE public.gc_review_stats (
+    hour timestamp with time zone NOT NULL,
+    queue text NOT NULL,
+    processed bigint DEFAULT 0 NOT NULL,
+    dangling bigint DEFAULT 0 NOT NULL,
+    failed bigint DEFAULT 0 NOT NULL,
+    CONSTRAINT check_gc_review_stats_queue_length CHECK ((char_length(queue) <= 255))
+);
+
 CREATE TABLE public.gc_tmp_blobs_manifests (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     digest bytea NOT NULL
@@ -7142,6 +7478,40 @@ ALTER TABLE public.manifests
         NO MAXVALUE
         CACHE 1);
 
+CREATE TABLE public.immutable_tag_patterns (
+    id bigint NOT NULL,
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    pattern text NOT NULL,
+    CONSTRAINT check_immutable_tag_patterns_pattern_length CHECK ((char_length(pattern) <= 255))
+);
+
+ALTER TABLE public.immutable_tag_patterns
+    ALTER COLUMN id
+    ADD GENERATED BY DEFAULT AS IDENTITY (SEQUENCE NAME
+        public.immutable_tag_patterns_id_seq START WITH 1 INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1);
+
+CREATE TABLE public.import_checkpoints (
+    id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    step text NOT NULL,
+    repository_path text NOT NULL,
+    CONSTRAINT check_import_checkpoints_repository_path_length CHECK ((char_length(repository_path) <= 255)),
+    CONSTRAINT check_import_checkpoints_step CHECK ((step = ANY (ARRAY['pre_import'::text, 'repository_import'::text])))
+);
+
+ALTER TABLE public.import_checkpoints
+    ALTER COLUMN id
+    ADD GENERATED BY DEFAULT AS IDENTITY (SEQUENCE NAME
+        public.import_checkpoints_id_seq START WITH 1 INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1);
+
 CREATE TABLE public.media_types (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     id smallint NOT NULL,
@@ -7157,6 +7527,24 @@ ALTER TABLE public.media_types
         NO MAXVALUE
         CACHE 1);
 
+CREATE TABLE public.notification_events (
+    id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    locked_until timestamp with time zone,
+    failed_at timestamp with time zone,
+    endpoint text NOT NULL,
+    payload jsonb NOT NULL,
+    CONSTRAINT check_notification_events_endpoint_length CHECK ((char_length(endpoint) <= 255))
+);
+
+ALTER TABLE public.notification_events
+    ALTER COLUMN id
+    ADD GENERATED BY DEFAULT AS IDENTITY (SEQUENCE NAME
+        public.notification_events_id_seq START WITH 1 INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1);
+
 CREATE TABLE public.repositories (
     id bigint NOT NULL,
     top_level_namespace_id bigint NOT NULL,
@@ -7186,6 +7574,16 @@ ALTER TABLE public.repository_blobs
         NO MAXVALUE
         CACHE 1);
 
+CREATE TABLE public.repository_settings (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone,
+    manifest_payload_size_limit integer,
+    read_only boolean DEFAULT false NOT NULL,
+    CONSTRAINT check_repository_settings_manifest_payload_size_limit_positive CHECK ((manifest_payload_size_limit > 0))
+);
+
 CREATE TABLE public.schema_migrations (
     id text NOT NULL,
     applied_at timestamp with time zone
@@ -7199,6 +7597,100 @@ ALTER TABLE public.tags
         NO MAXVALUE
         CACHE 1);
 
+CREATE TABLE public.manifest_annotations (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    manifest_id bigint NOT NULL,
+    key text NOT NULL,
+    value text NOT NULL,
+    CONSTRAINT check_manifest_annotations_key_length CHECK ((char_length(key) <= 255)),
+    CONSTRAINT check_manifest_annotations_value_length CHECK ((char_length(value) <= 255))
+);
+
+CREATE TABLE public.repository_purges (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone,
+    next_run_at timestamp with time zone DEFAULT now() NOT NULL,
+    completed_at timestamp with time zone,
+    tags_deleted bigint DEFAULT 0 NOT NULL,
+    manifests_deleted bigint DEFAULT 0 NOT NULL,
+    blobs_unlinked bigint DEFAULT 0 NOT NULL,
+    last_error text,
+    CONSTRAINT check_repository_purges_last_error_length CHECK ((char_length(last_error) <= 255))
+);
+
+CREATE TABLE public.namespace_statistics (
+    top_level_namespace_id bigint NOT NULL,
+    date date NOT NULL,
+    pushes bigint DEFAULT 0 NOT NULL,
+    pulls bigint DEFAULT 0 NOT NULL,
+    bytes_added bigint DEFAULT 0 NOT NULL,
+    bytes_reclaimed bigint DEFAULT 0 NOT NULL
+);
+
+CREATE TABLE public.repository_metadata (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone,
+    description text DEFAULT ''::text NOT NULL,
+    readme text DEFAULT ''::text NOT NULL,
+    links jsonb DEFAULT '[]'::jsonb NOT NULL,
+    CONSTRAINT check_repository_metadata_description_length CHECK ((char_length(description) <= 255)),
+    CONSTRAINT check_repository_metadata_readme_length CHECK ((char_length(readme) <= 102400))
+);
+
+CREATE TABLE public.repository_labels (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    key text NOT NULL,
+    value text NOT NULL,
+    CONSTRAINT check_repository_labels_key_length CHECK ((char_length(key) <= 63)),
+    CONSTRAINT check_repository_labels_value_length CHECK ((char_length(value) <= 255))
+);
+
+CREATE TABLE public.proxy_cached_tags (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    cached_at timestamp with time zone DEFAULT now() NOT NULL,
+    name text NOT NULL,
+    CONSTRAINT check_proxy_cached_tags_name_length CHECK ((char_length(name) <= 255))
+);
+
+CREATE TABLE public.tag_schema_referrers (
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    subject_id bigint NOT NULL,
+    referrer_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT check_tag_schema_referrers_subject_id_and_referrer_id_differ CHECK ((subject_id <> referrer_id))
+);
+
+CREATE TABLE public.tag_history (
+    id bigint NOT NULL,
+    top_level_namespace_id bigint NOT NULL,
+    repository_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    name text NOT NULL,
+    old_digest bytea,
+    new_digest bytea,
+    actor text,
+    CONSTRAINT check_tag_history_actor_length CHECK ((char_length(actor) <= 255)),
+    CONSTRAINT check_tag_history_digests_not_both_null CHECK (((old_digest IS NOT NULL) OR (new_digest IS NOT NULL))),
+    CONSTRAINT check_tag_history_name_length CHECK ((char_length(name) <= 255))
+);
+
+ALTER TABLE public.tag_history
+    ALTER COLUMN id
+    ADD GENERATED BY DEFAULT AS IDENTITY (SEQUENCE NAME
+        public.tag_history_id_seq START WITH 1 INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1);
+
 CREATE TABLE public.top_level_namespaces (
     id bigint NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
@@ -7215,6 +7707,23 @@ ALTER TABLE public.top_level_namespaces
         NO MAXVALUE
         CACHE 1);
 
+CREATE TABLE public.uploads (
+    id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    uuid text NOT NULL,
+    repository_path text NOT NULL,
+    CONSTRAINT check_uploads_repository_path_length CHECK ((char_length(repository_path) <= 255)),
+    CONSTRAINT check_uploads_uuid_length CHECK ((char_length(uuid) <= 255))
+);
+
+ALTER TABLE public.uploads
+    ALTER COLUMN id
+    ADD GENERATED BY DEFAULT AS IDENTITY (SEQUENCE NAME
+        public.uploads_id_seq START WITH 1 INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1);
+
 ALTER TABLE ONLY public.blobs
     ADD CONSTRAINT pk_blobs PRIMARY KEY (digest);
 
@@ -10534,6 +11043,15 @@ ALTER TABLE ONLY partitions.tags_p_9
 ALTER TABLE ONLY partitions.tags_p_9
     ADD CONSTRAINT tags_p_9_top_level_namespace_id_repository_id_name_key UNIQUE (top_level_namespace_id, repository_id, name);
 
+ALTER TABLE ONLY public.background_migrations
+    ADD CONSTRAINT pk_background_migrations PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.cleanup_policies
+    ADD CONSTRAINT pk_cleanup_policies PRIMARY KEY (top_level_namespace_id, repository_id);
+
+ALTER TABLE ONLY public.corrupted_blobs
+    ADD CONSTRAINT pk_corrupted_blobs PRIMARY KEY (digest);
+
 ALTER TABLE ONLY public.gc_blob_review_queue
     ADD CONSTRAINT pk_gc_blob_review_queue PRIMARY KEY (digest);
 
@@ -10543,21 +11061,72 @@ ALTER TABLE ONLY public.gc_manifest_review_queue
 ALTER TABLE ONLY public.gc_review_after_defaults
     ADD CONSTRAINT pk_gc_review_after_defaults PRIMARY KEY (event);
 
+ALTER TABLE ONLY public.gc_review_stats
+    ADD CONSTRAINT pk_gc_review_stats PRIMARY KEY (queue, hour);
+
 ALTER TABLE ONLY public.gc_tmp_blobs_manifests
     ADD CONSTRAINT pk_gc_tmp_blobs_manifests PRIMARY KEY (digest);
 
+ALTER TABLE ONLY public.immutable_tag_patterns
+    ADD CONSTRAINT pk_immutable_tag_patterns PRIMARY KEY (top_level_namespace_id, repository_id, id);
+
+ALTER TABLE ONLY public.import_checkpoints
+    ADD CONSTRAINT pk_import_checkpoints PRIMARY KEY (id);
+
 ALTER TABLE ONLY public.media_types
     ADD CONSTRAINT pk_media_types PRIMARY KEY (id);
 
+ALTER TABLE ONLY public.notification_events
+    ADD CONSTRAINT pk_notification_events PRIMARY KEY (id);
+
 ALTER TABLE ONLY public.repositories
     ADD CONSTRAINT pk_repositories PRIMARY KEY (top_level_namespace_id, id);
 
+ALTER TABLE ONLY public.repository_settings
+    ADD CONSTRAINT pk_repository_settings PRIMARY KEY (top_level_namespace_id, repository_id);
+
+ALTER TABLE ONLY public.manifest_annotations
+    ADD CONSTRAINT pk_manifest_annotations PRIMARY KEY (top_level_namespace_id, repository_id, manifest_id, key);
+
+ALTER TABLE ONLY public.repository_purges
+    ADD CONSTRAINT pk_repository_purges PRIMARY KEY (top_level_namespace_id, repository_id);
+
+ALTER TABLE ONLY public.namespace_statistics
+    ADD CONSTRAINT pk_namespace_statistics PRIMARY KEY (top_level_namespace_id, date);
+
+ALTER TABLE ONLY public.repository_metadata
+    ADD CONSTRAINT pk_repository_metadata PRIMARY KEY (top_level_namespace_id, repository_id);
+
+ALTER TABLE ONLY public.repository_labels
+    ADD CONSTRAINT pk_repository_labels PRIMARY KEY (top_level_namespace_id, repository_id, key);
+
+ALTER TABLE ONLY public.proxy_cached_tags
+    ADD CONSTRAINT pk_proxy_cached_tags PRIMARY KEY (top_level_namespace_id, repository_id, name);
+
+ALTER TABLE ONLY public.tag_schema_referrers
+    ADD CONSTRAINT pk_tag_schema_referrers PRIMARY KEY (top_level_namespace_id, repository_id, subject_id, referrer_id);
+
+ALTER TABLE ONLY public.tag_history
+    ADD CONSTRAINT pk_tag_history PRIMARY KEY (top_level_namespace_id, repository_id, id);
+
 ALTER TABLE ONLY public.top_level_namespaces
     ADD CONSTRAINT pk_top_level_namespaces PRIMARY KEY (id);
 
+ALTER TABLE ONLY public.uploads
+    ADD CONSTRAINT pk_uploads PRIMARY KEY (id);
+
 ALTER TABLE ONLY public.schema_migrations
     ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (id);
 
+ALTER TABLE ONLY public.background_migrations
+    ADD CONSTRAINT unique_background_migrations_name UNIQUE (name);
+
+ALTER TABLE ONLY public.immutable_tag_patterns
+    ADD CONSTRAINT unique_immutable_tag_patterns_rpstry_id_and_pattern UNIQUE (top_level_namespace_id, repository_id, pattern);
+
+ALTER TABLE ONLY public.import_checkpoints
+    ADD CONSTRAINT unique_import_checkpoints_step_repository_path UNIQUE (step, repository_path);
+
 ALTER TABLE ONLY public.media_types
     ADD CONSTRAINT unique_media_types_type UNIQUE (media_type);
 
@@ -10567,6 +11136,9 @@ ALTER TABLE ONLY public.repositories
 ALTER TABLE ONLY public.top_level_namespaces
     ADD CONSTRAINT unique_top_level_namespaces_name UNIQUE (name);
 
+ALTER TABLE ONLY public.uploads
+    ADD CONSTRAINT unique_uploads_uuid UNIQUE (uuid);
+
 CREATE INDEX index_blobs_on_media_type_id ON ONLY public.blobs USING btree (media_type_id);
 
 CREATE INDEX blobs_p_0_media_type_id_idx ON partitions.blobs_p_0 USING btree (media_type_id);
@@ -12257,10 +12829,24 @@ CREATE INDEX tags_p_8_top_level_namespace_id_repository_id_manifest_id_idx ON pa
 
 CREATE INDEX tags_p_9_top_level_namespace_id_repository_id_manifest_id_idx ON partitions.tags_p_9 USING btree (top_level_namespace_id, repository_id, manifest_id);
 
+CREATE INDEX index_background_migrations_on_id_where_status_active ON public.background_migrations USING btree (id)
+WHERE (status = 'active'::text);
+
+CREATE INDEX index_cleanup_policies_on_next_run_at_where_enabled ON public.cleanup_policies USING btree (next_run_at)
+WHERE enabled;
+
+CREATE INDEX index_corrupted_blobs_on_detected_at ON public.corrupted_blobs USING btree (detected_at);
+
 CREATE INDEX index_gc_blob_review_queue_on_review_after ON public.gc_blob_review_queue USING btree (review_after);
 
 CREATE INDEX index_gc_manifest_review_queue_on_review_after ON public.gc_manifest_review_queue USING btree (review_after);
 
+CREATE INDEX index_notification_events_on_endpoint_id_where_pending ON public.notification_events USING btree (endpoint, id)
+WHERE (failed_at IS NULL);
+
+CREATE INDEX index_notification_events_on_failed_at_where_failed ON public.notification_events USING btree (failed_at)
+WHERE (failed_at IS NOT NULL);
+
 CREATE INDEX index_repositories_on_id_where_deleted_at_not_null ON public.repositories USING btree (id)
 WHERE (deleted_at IS NOT NULL);
 
@@ -12268,6 +12854,21 @@ CREATE INDEX index_repositories_on_top_level_namespace_id_and_parent_id ON publi
 
 CREATE INDEX index_repositories_on_top_level_namespace_id_and_path_and_id ON public.repositories USING btree (top_level_namespace_id, path text_pattern_ops, id);
 
+CREATE INDEX index_manifest_annotations_on_top_lvl_nmspc_id_key_value ON public.manifest_annotations USING btree (top_level_namespace_id, key, value);
+
+CREATE INDEX index_repository_purges_on_next_run_at_where_not_completed ON public.repository_purges USING btree (next_run_at)
+WHERE (completed_at IS NULL);
+
+CREATE INDEX index_namespace_statistics_on_date ON public.namespace_statistics USING btree (date);
+
+CREATE INDEX index_tag_history_on_top_lvl_nmspc_id_rpstry_id_name_id ON public.tag_history USING btree (top_level_namespace_id, repository_id, name, id);
+
+CREATE INDEX index_uploads_on_created_at ON public.uploads USING btree (created_at);
+
+CREATE INDEX index_proxy_cached_tags_on_cached_at ON public.proxy_cached_tags USING btree (cached_at);
+
+CREATE INDEX index_tag_schema_referrers_on_tp_lvl_nmspc_id_rpstry_id_rfrr_id ON public.tag_schema_referrers USING btree (top_level_namespace_id, repository_id, referrer_id);
+
 ALTER INDEX public.index_blobs_on_media_type_id ATTACH PARTITION partitions.blobs_p_0_media_type_id_idx;
 
 ALTER INDEX public.pk_blobs ATTACH PARTITION partitions.blobs_p_0_pkey;
@@ -16161,6 +16762,12 @@ CREATE TRIGGER gc_track_tmp_blobs_manifests_trigger
 ALTER TABLE public.blobs
     ADD CONSTRAINT fk_blobs_media_type_id_media_types FOREIGN KEY (media_type_id) REFERENCES public.media_types (id);
 
+ALTER TABLE ONLY public.cleanup_policies
+    ADD CONSTRAINT fk_cleanup_policies_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.corrupted_blobs
+    ADD CONSTRAINT fk_corrupted_blobs_digest_blobs FOREIGN KEY (digest) REFERENCES public.blobs (digest) ON DELETE CASCADE;
+
 ALTER TABLE public.gc_blobs_configurations
     ADD CONSTRAINT fk_gc_blobs_configurations_digest_blobs FOREIGN KEY (digest) REFERENCES public.blobs (digest) ON DELETE CASCADE;
 
@@ -16203,6 +16810,9 @@ ALTER TABLE public.manifests
 ALTER TABLE public.manifests
     ADD CONSTRAINT fk_manifests_media_type_id_media_types FOREIGN KEY (media_type_id) REFERENCES public.media_types (id);
 
+ALTER TABLE ONLY public.immutable_tag_patterns
+    ADD CONSTRAINT fk_immutable_tag_patterns_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
 ALTER TABLE public.manifests
     ADD CONSTRAINT fk_manifests_subject_id_manifests FOREIGN KEY (top_level_namespace_id, repository_id, subject_id) REFERENCES public.manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE;
 
@@ -16218,6 +16828,36 @@ ALTER TABLE public.repository_blobs
 ALTER TABLE public.repository_blobs
     ADD CONSTRAINT fk_repository_blobs_top_lvl_nmspc_id_and_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
 
+ALTER TABLE ONLY public.repository_settings
+    ADD CONSTRAINT fk_repository_settings_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.manifest_annotations
+    ADD CONSTRAINT fk_manifest_annotations_tp_lvl_nspc_id_rpstry_id_mfst_id_mnfsts FOREIGN KEY (top_level_namespace_id, repository_id, manifest_id) REFERENCES public.manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.repository_purges
+    ADD CONSTRAINT fk_repository_purges_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.namespace_statistics
+    ADD CONSTRAINT fk_namespace_statistics_top_lvl_nmspc_id_top_lvl_nmspcs FOREIGN KEY (top_level_namespace_id) REFERENCES public.top_level_namespaces (id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.repository_metadata
+    ADD CONSTRAINT fk_repository_metadata_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.repository_labels
+    ADD CONSTRAINT fk_repository_labels_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.proxy_cached_tags
+    ADD CONSTRAINT fk_proxy_cached_tags_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.tag_schema_referrers
+    ADD CONSTRAINT fk_tag_schema_referrers_tp_lvl_nmspc_id_rpsty_id_sbjct_id_mnfsts FOREIGN KEY (top_level_namespace_id, repository_id, subject_id) REFERENCES public.manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.tag_schema_referrers
+    ADD CONSTRAINT fk_tag_schema_referrers_tp_lvl_nmspc_id_rpsty_id_rfrr_id_mnfsts FOREIGN KEY (top_level_namespace_id, repository_id, referrer_id) REFERENCES public.manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.tag_history
+    ADD CONSTRAINT fk_tag_history_rpstry_id_repositories FOREIGN KEY (top_level_namespace_id, repository_id) REFERENCES public.repositories (top_level_namespace_id, id) ON DELETE CASCADE;
+
 ALTER TABLE public.tags
     ADD CONSTRAINT fk_tags_repository_id_and_manifest_id_manifests FOREIGN KEY (top_level_namespace_id, repository_id, manifest_id) REFERENCES public.manifests (top_level_namespace_id, repository_id, id) ON DELETE CASCADE;
 
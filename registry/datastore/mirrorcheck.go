@@ -0,0 +1,328 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/opencontainers/go-digest"
+)
+
+// DivergenceSeverity classifies divergences between the filesystem metadata and the database by their impact on
+// switching to the database as the only source of metadata.
+type DivergenceSeverity string
+
+const (
+	// DivergenceSeverityCritical is a divergence that would change what clients pull once the filesystem metadata is
+	// no longer used, such as tags missing from the database or pointing to a different manifest.
+	DivergenceSeverityCritical DivergenceSeverity = "critical"
+	// DivergenceSeverityWarning is a divergence where the filesystem metadata lags behind the database. This only
+	// affects reads falling back to the filesystem metadata, and rolling back to it.
+	DivergenceSeverityWarning DivergenceSeverity = "warning"
+)
+
+// DivergenceType identifies a class of divergences between the filesystem metadata and the database.
+type DivergenceType string
+
+const (
+	// DivergenceTagMissingInDatabase is a tag found in the filesystem but not in the database.
+	DivergenceTagMissingInDatabase DivergenceType = "tag_missing_in_database"
+	// DivergenceTagDigestMismatch is a tag pointing to a different manifest in the filesystem and the database.
+	DivergenceTagDigestMismatch DivergenceType = "tag_digest_mismatch"
+	// DivergenceTagMissingInFilesystem is a tag found in the database but not in the filesystem.
+	DivergenceTagMissingInFilesystem DivergenceType = "tag_missing_in_filesystem"
+	// DivergenceManifestMissingInFilesystem is a manifest found in the database but not in the filesystem.
+	DivergenceManifestMissingInFilesystem DivergenceType = "manifest_missing_in_filesystem"
+)
+
+// Severity returns the severity of divergences of this type.
+func (t DivergenceType) Severity() DivergenceSeverity {
+	switch t {
+	case DivergenceTagMissingInDatabase, DivergenceTagDigestMismatch:
+		return DivergenceSeverityCritical
+	default:
+		return DivergenceSeverityWarning
+	}
+}
+
+// Divergence is a difference between the filesystem metadata and the database.
+type Divergence struct {
+	Type       DivergenceType     `json:"type"`
+	Severity   DivergenceSeverity `json:"severity"`
+	Repository string             `json:"repository"`
+	Tag        string             `json:"tag,omitempty"`
+	// FilesystemDigest is the digest of the manifest found in the filesystem, if any.
+	FilesystemDigest digest.Digest `json:"filesystem_digest,omitempty"`
+	// DatabaseDigest is the digest of the manifest found in the database, if any.
+	DatabaseDigest digest.Digest `json:"database_digest,omitempty"`
+	Detail         string        `json:"detail"`
+}
+
+// DivergenceReport is the outcome of a mirroring check.
+type DivergenceReport struct {
+	Repositories int                        `json:"repositories"`
+	Tags         int                        `json:"tags"`
+	Manifests    int                        `json:"manifests"`
+	Summary      map[DivergenceSeverity]int `json:"summary"`
+	Types        map[DivergenceType]int     `json:"types"`
+	Divergences  []*Divergence              `json:"divergences"`
+}
+
+// NewDivergenceReport creates a new, empty DivergenceReport.
+func NewDivergenceReport() *DivergenceReport {
+	return &DivergenceReport{
+		Summary:     map[DivergenceSeverity]int{DivergenceSeverityCritical: 0, DivergenceSeverityWarning: 0},
+		Types:       make(map[DivergenceType]int),
+		Divergences: make([]*Divergence, 0),
+	}
+}
+
+// AddDivergence records a divergence, setting its severity according to its type.
+func (r *DivergenceReport) AddDivergence(d *Divergence) {
+	d.Severity = d.Type.Severity()
+	r.Divergences = append(r.Divergences, d)
+	r.Summary[d.Severity]++
+	r.Types[d.Type]++
+}
+
+// Critical determines whether the check found critical divergences.
+func (r *DivergenceReport) Critical() bool {
+	return r.Summary[DivergenceSeverityCritical] > 0
+}
+
+// Sort sorts divergences by severity, with critical ones first, repository, tag and type.
+func (r *DivergenceReport) Sort() {
+	sort.SliceStable(r.Divergences, func(i, j int) bool {
+		a, b := r.Divergences[i], r.Divergences[j]
+		switch {
+		case a.Severity != b.Severity:
+			return a.Severity == DivergenceSeverityCritical
+		case a.Repository != b.Repository:
+			return a.Repository < b.Repository
+		case a.Tag != b.Tag:
+			return a.Tag < b.Tag
+		default:
+			return a.Type < b.Type
+		}
+	})
+}
+
+// mirrorCheckPageSize is the number of repositories read from the database at a time when sampling.
+const mirrorCheckPageSize = 1000
+
+// CheckMirroring compares the filesystem metadata of a random sample of up to sampleSize repositories with the database,
+// or of all repositories if sampleSize is 0, and reports the divergences found. Repositories are sampled from the
+// database, so repositories only found in the filesystem are not checked. Tags found to diverge are read again from
+// both sides before being reported, to rule out writes that happened during the check. Nothing is written to the
+// database or the filesystem.
+func (imp *Importer) CheckMirroring(ctx context.Context, sampleSize int) (*DivergenceReport, error) {
+	start := time.Now()
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"sample_size": sampleSize})
+	l.Info("starting mirroring check")
+
+	repos, err := imp.sampleRepositories(ctx, sampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	report := NewDivergenceReport()
+	for i, r := range repos {
+		l.WithFields(log.Fields{"repository": r.Path, "count": i + 1}).Info("checking repository")
+		if err := imp.checkRepositoryMirroring(ctx, report, r); err != nil {
+			return nil, fmt.Errorf("checking repository %q: %w", r.Path, err)
+		}
+	}
+
+	report.Sort()
+	l.WithFields(log.Fields{
+		"duration_s":   time.Since(start).Seconds(),
+		"repositories": report.Repositories,
+		"critical":     report.Summary[DivergenceSeverityCritical],
+		"warning":      report.Summary[DivergenceSeverityWarning],
+	}).Info("mirroring check complete")
+
+	return report, nil
+}
+
+// sampleRepositories selects a uniformly random sample of up to n repositories from the database, or all of them if
+// n is 0, sorted by path.
+func (imp *Importer) sampleRepositories(ctx context.Context, n int) (models.Repositories, error) {
+	var (
+		sample models.Repositories
+		seen   int
+		lastID int64
+	)
+
+	for {
+		rr, err := imp.repositoryStore.FindAllAfterID(ctx, lastID, mirrorCheckPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("finding repositories: %w", err)
+		}
+		if len(rr) == 0 {
+			break
+		}
+
+		// reservoir sampling, so that repositories don't have to be held in memory all at once
+		for _, r := range rr {
+			seen++
+			switch {
+			case n == 0 || len(sample) < n:
+				sample = append(sample, r)
+			default:
+				if j := rand.Intn(seen); j < n {
+					sample[j] = r
+				}
+			}
+		}
+		lastID = rr[len(rr)-1].ID
+	}
+
+	sort.Slice(sample, func(i, j int) bool { return sample[i].Path < sample[j].Path })
+
+	return sample, nil
+}
+
+func (imp *Importer) checkRepositoryMirroring(ctx context.Context, report *DivergenceReport, dbRepo *models.Repository) error {
+	named, err := reference.WithName(dbRepo.Path)
+	if err != nil {
+		return fmt.Errorf("parsing repository name: %w", err)
+	}
+	fsRepo, err := imp.registry.Repository(ctx, named)
+	if err != nil {
+		return fmt.Errorf("constructing filesystem repository: %w", err)
+	}
+	tagService := fsRepo.Tags(ctx)
+	manifestService, err := fsRepo.Manifests(ctx)
+	if err != nil {
+		return fmt.Errorf("constructing manifest service: %w", err)
+	}
+
+	dbManifests, err := imp.repositoryStore.Manifests(ctx, dbRepo)
+	if err != nil {
+		return fmt.Errorf("finding manifests in database: %w", err)
+	}
+	digests := make(map[int64]digest.Digest, len(dbManifests))
+	for _, m := range dbManifests {
+		digests[m.ID] = m.Digest
+	}
+
+	dbTagList, err := imp.repositoryStore.Tags(ctx, dbRepo)
+	if err != nil {
+		return fmt.Errorf("finding tags in database: %w", err)
+	}
+	dbTags := make(map[string]digest.Digest, len(dbTagList))
+	for _, t := range dbTagList {
+		dbTags[t.Name] = digests[t.ManifestID]
+	}
+
+	fsTagList, err := tagService.All(ctx)
+	if err != nil && !errors.As(err, &distribution.ErrRepositoryUnknown{}) && !errors.As(err, &driver.PathNotFoundError{}) {
+		return fmt.Errorf("reading tags: %w", err)
+	}
+	fsTags := make(map[string]digest.Digest, len(fsTagList))
+	for _, t := range fsTagList {
+		fsTags[t], err = fsTagDigest(ctx, tagService, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	// collect all tag names, so that tags are checked in a deterministic order
+	names := make([]string, 0, len(dbTags)+len(fsTags))
+	for t := range dbTags {
+		names = append(names, t)
+	}
+	for t := range fsTags {
+		if _, ok := dbTags[t]; !ok {
+			names = append(names, t)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fsDgst, dbDgst := fsTags[name], dbTags[name]
+		if fsDgst == dbDgst {
+			continue
+		}
+
+		// the tag may have been written while the check was running, read it again before reporting it
+		if fsDgst, err = fsTagDigest(ctx, tagService, name); err != nil {
+			return err
+		}
+		if dbDgst, err = imp.dbTagDigest(ctx, dbRepo, name); err != nil {
+			return err
+		}
+
+		d := &Divergence{Repository: dbRepo.Path, Tag: name, FilesystemDigest: fsDgst, DatabaseDigest: dbDgst}
+		switch {
+		case fsDgst == dbDgst:
+			continue
+		case dbDgst == "":
+			d.Type = DivergenceTagMissingInDatabase
+			d.Detail = "tag found in the filesystem but not in the database"
+		case fsDgst == "":
+			d.Type = DivergenceTagMissingInFilesystem
+			d.Detail = "tag found in the database but not in the filesystem"
+		default:
+			d.Type = DivergenceTagDigestMismatch
+			d.Detail = "tag points to a different manifest in the filesystem and the database"
+		}
+		report.AddDivergence(d)
+	}
+
+	for _, m := range dbManifests {
+		exists, err := manifestService.Exists(ctx, m.Digest)
+		if err != nil && !errors.Is(err, digest.ErrDigestInvalidFormat) {
+			return fmt.Errorf("checking for existence of manifest %q: %w", m.Digest, err)
+		}
+		if !exists {
+			report.AddDivergence(&Divergence{
+				Type:           DivergenceManifestMissingInFilesystem,
+				Repository:     dbRepo.Path,
+				DatabaseDigest: m.Digest,
+				Detail:         "manifest found in the database but not in the filesystem",
+			})
+		}
+	}
+
+	report.Repositories++
+	report.Tags += len(names)
+	report.Manifests += len(dbManifests)
+
+	return nil
+}
+
+// fsTagDigest returns the digest of the manifest a tag points to in the filesystem, or an empty digest if the tag is
+// not found or its link is corrupted.
+func fsTagDigest(ctx context.Context, ts distribution.TagService, name string) (digest.Digest, error) {
+	desc, err := ts.Get(ctx, name)
+	switch {
+	case err == nil:
+		return desc.Digest, nil
+	case errors.As(err, &distribution.ErrTagUnknown{}), errors.Is(err, digest.ErrDigestInvalidFormat):
+		return "", nil
+	default:
+		return "", fmt.Errorf("reading tag %q details: %w", name, err)
+	}
+}
+
+// dbTagDigest returns the digest of the manifest a tag points to in the database, or an empty digest if the tag is not
+// found.
+func (imp *Importer) dbTagDigest(ctx context.Context, r *models.Repository, name string) (digest.Digest, error) {
+	m, err := imp.repositoryStore.FindManifestByTagName(ctx, r, name)
+	if err != nil {
+		return "", fmt.Errorf("finding tag %q in database: %w", name, err)
+	}
+	if m == nil {
+		return "", nil
+	}
+	return m.Digest, nil
+}
@@ -108,6 +108,21 @@ func (mr *MockBlobStoreMockRecorder) FindAll(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockBlobStore)(nil).FindAll), arg0)
 }
 
+// FindAllAfterDigest mocks base method.
+func (m *MockBlobStore) FindAllAfterDigest(arg0 context.Context, arg1 digest.Digest, arg2 int) (models.Blobs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllAfterDigest", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Blobs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllAfterDigest indicates an expected call of FindAllAfterDigest.
+func (mr *MockBlobStoreMockRecorder) FindAllAfterDigest(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllAfterDigest", reflect.TypeOf((*MockBlobStore)(nil).FindAllAfterDigest), arg0, arg1, arg2)
+}
+
 // FindByDigest mocks base method.
 func (m *MockBlobStore) FindByDigest(arg0 context.Context, arg1 digest.Digest) (*models.Blob, error) {
 	m.ctrl.T.Helper()
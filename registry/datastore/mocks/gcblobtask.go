@@ -6,11 +6,13 @@ package mocks
 
 import (
 	context "context"
+	sql "database/sql"
 	reflect "reflect"
 	time "time"
 
 	models "github.com/docker/distribution/registry/datastore/models"
 	gomock "github.com/golang/mock/gomock"
+	digest "github.com/opencontainers/go-digest"
 )
 
 // MockGCBlobTaskStore is a mock of GCBlobTaskStore interface.
@@ -110,6 +112,21 @@ func (mr *MockGCBlobTaskStoreMockRecorder) Next(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockGCBlobTaskStore)(nil).Next), arg0)
 }
 
+// NextReviewAfter mocks base method.
+func (m *MockGCBlobTaskStore) NextReviewAfter(arg0 context.Context) (sql.NullTime, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextReviewAfter", arg0)
+	ret0, _ := ret[0].(sql.NullTime)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextReviewAfter indicates an expected call of NextReviewAfter.
+func (mr *MockGCBlobTaskStoreMockRecorder) NextReviewAfter(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextReviewAfter", reflect.TypeOf((*MockGCBlobTaskStore)(nil).NextReviewAfter), arg0)
+}
+
 // Postpone mocks base method.
 func (m *MockGCBlobTaskStore) Postpone(arg0 context.Context, arg1 *models.GCBlobTask, arg2 time.Duration) error {
 	m.ctrl.T.Helper()
@@ -123,3 +140,32 @@ func (mr *MockGCBlobTaskStoreMockRecorder) Postpone(arg0, arg1, arg2 interface{}
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Postpone", reflect.TypeOf((*MockGCBlobTaskStore)(nil).Postpone), arg0, arg1, arg2)
 }
+
+// ReviewNow mocks base method.
+func (m *MockGCBlobTaskStore) ReviewNow(arg0 context.Context, arg1 digest.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewNow", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReviewNow indicates an expected call of ReviewNow.
+func (mr *MockGCBlobTaskStoreMockRecorder) ReviewNow(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewNow", reflect.TypeOf((*MockGCBlobTaskStore)(nil).ReviewNow), arg0, arg1)
+}
+
+// ReviewRepositoryBlobsNow mocks base method.
+func (m *MockGCBlobTaskStore) ReviewRepositoryBlobsNow(arg0 context.Context, arg1 *models.Repository) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewRepositoryBlobsNow", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviewRepositoryBlobsNow indicates an expected call of ReviewRepositoryBlobsNow.
+func (mr *MockGCBlobTaskStoreMockRecorder) ReviewRepositoryBlobsNow(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewRepositoryBlobsNow", reflect.TypeOf((*MockGCBlobTaskStore)(nil).ReviewRepositoryBlobsNow), arg0, arg1)
+}
@@ -6,6 +6,7 @@ package mocks
 
 import (
 	context "context"
+	sql "database/sql"
 	reflect "reflect"
 	time "time"
 
@@ -155,6 +156,21 @@ func (mr *MockGCManifestTaskStoreMockRecorder) Next(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockGCManifestTaskStore)(nil).Next), arg0)
 }
 
+// NextReviewAfter mocks base method.
+func (m *MockGCManifestTaskStore) NextReviewAfter(arg0 context.Context) (sql.NullTime, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextReviewAfter", arg0)
+	ret0, _ := ret[0].(sql.NullTime)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextReviewAfter indicates an expected call of NextReviewAfter.
+func (mr *MockGCManifestTaskStoreMockRecorder) NextReviewAfter(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextReviewAfter", reflect.TypeOf((*MockGCManifestTaskStore)(nil).NextReviewAfter), arg0)
+}
+
 // Postpone mocks base method.
 func (m *MockGCManifestTaskStore) Postpone(arg0 context.Context, arg1 *models.GCManifestTask, arg2 time.Duration) error {
 	m.ctrl.T.Helper()
@@ -168,3 +184,18 @@ func (mr *MockGCManifestTaskStoreMockRecorder) Postpone(arg0, arg1, arg2 interfa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Postpone", reflect.TypeOf((*MockGCManifestTaskStore)(nil).Postpone), arg0, arg1, arg2)
 }
+
+// ReviewRepositoryManifestsNow mocks base method.
+func (m *MockGCManifestTaskStore) ReviewRepositoryManifestsNow(arg0 context.Context, arg1 *models.Repository) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewRepositoryManifestsNow", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviewRepositoryManifestsNow indicates an expected call of ReviewRepositoryManifestsNow.
+func (mr *MockGCManifestTaskStoreMockRecorder) ReviewRepositoryManifestsNow(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewRepositoryManifestsNow", reflect.TypeOf((*MockGCManifestTaskStore)(nil).ReviewRepositoryManifestsNow), arg0, arg1)
+}
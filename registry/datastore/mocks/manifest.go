@@ -64,6 +64,36 @@ func (mr *MockManifestStoreMockRecorder) AssociateManifest(arg0, arg1, arg2 inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssociateManifest", reflect.TypeOf((*MockManifestStore)(nil).AssociateManifest), arg0, arg1, arg2)
 }
 
+// AssociateSubject mocks base method.
+func (m *MockManifestStore) AssociateSubject(arg0 context.Context, arg1, arg2 *models.Manifest) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssociateSubject", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AssociateSubject indicates an expected call of AssociateSubject.
+func (mr *MockManifestStoreMockRecorder) AssociateSubject(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssociateSubject", reflect.TypeOf((*MockManifestStore)(nil).AssociateSubject), arg0, arg1, arg2)
+}
+
+// AssociateTagSchemaReferrer mocks base method.
+func (m *MockManifestStore) AssociateTagSchemaReferrer(arg0 context.Context, arg1, arg2 *models.Manifest) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssociateTagSchemaReferrer", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AssociateTagSchemaReferrer indicates an expected call of AssociateTagSchemaReferrer.
+func (mr *MockManifestStoreMockRecorder) AssociateTagSchemaReferrer(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssociateTagSchemaReferrer", reflect.TypeOf((*MockManifestStore)(nil).AssociateTagSchemaReferrer), arg0, arg1, arg2)
+}
+
 // Count mocks base method.
 func (m *MockManifestStore) Count(arg0 context.Context) (int, error) {
 	m.ctrl.T.Helper()
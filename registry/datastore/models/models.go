@@ -78,12 +78,17 @@ type Manifest struct {
 	Payload       Payload
 	Configuration *Configuration
 	SubjectID     sql.NullInt64
+	// ArtifactType is the type of an artifact when the manifest is used for an artifact (OCI image manifest only).
+	ArtifactType  sql.NullString
 	NonConformant bool
 	// NonDistributableLayers identifies whether a manifest references foreign/non-distributable layers. For now, we are
 	// not registering metadata about these layers, but we may wish to backfill that metadata in the future by parsing
 	// the manifest payload.
 	NonDistributableLayers bool
-	CreatedAt              time.Time
+	// ChartName and ChartVersion are the name and version of a Helm chart, when the manifest is used for one.
+	ChartName    sql.NullString
+	ChartVersion sql.NullString
+	CreatedAt    time.Time
 }
 
 // Manifests is a slice of Manifest pointers.
@@ -119,10 +124,67 @@ type TagDetail struct {
 	Digest       digest.Digest
 	ConfigDigest NullDigest
 	MediaType    string
+	ArtifactType sql.NullString
 	Size         int64
 	CreatedAt    time.Time
 	UpdatedAt    sql.NullTime
 	PublishedAt  time.Time
+	// LastPulledAt is the last time the tag, or the manifest it points to, was pulled. Only recorded if pull tracking
+	// is enabled.
+	LastPulledAt sql.NullTime
+	// ChartName and ChartVersion are the name and version of the Helm chart the tag points to, if any.
+	ChartName    sql.NullString
+	ChartVersion sql.NullString
+}
+
+// RepositorySummary is a repository along with aggregated information about its tags.
+type RepositorySummary struct {
+	Repository
+	TagCount int
+	// LastPublishedAt is the last time a tag was created or updated in the repository.
+	LastPublishedAt time.Time
+}
+
+// Pull is a virtual entity with no parallel on the database schema. It represents the pull of a manifest, either by
+// digest or through a tag, and is used to update the last pulled timestamp of both.
+type Pull struct {
+	NamespaceID  int64
+	RepositoryID int64
+	ManifestID   int64
+	// TagName is the name of the tag through which the manifest was pulled. Empty if pulled by digest.
+	TagName  string
+	PulledAt time.Time
+}
+
+// RepositorySizeBreakdown is a virtual entity with no parallel on the database schema. It provides a detailed view of
+// the deduplicated size of a repository, where only artifacts referenced by at least one tagged (directly or indirectly)
+// manifest are taken into account.
+type RepositorySizeBreakdown struct {
+	// LayersSize is the sum of the size of all unique layers. This matches the repository size.
+	LayersSize int64
+	// SharedLayersSize is the sum of the size of all unique layers also referenced by other repositories under the same
+	// top-level namespace.
+	SharedLayersSize int64
+	// ConfigsSize is the sum of the size of all unique configuration blobs.
+	ConfigsSize int64
+	// ManifestsSize is the sum of the size of all manifest payloads.
+	ManifestsSize int64
+	// LargestImages is the list of tagged images with the largest size, in descending order of size.
+	LargestImages []*ImageSize
+}
+
+// UniqueLayersSize is the sum of the size of all unique layers that are not referenced by any other repository under
+// the same top-level namespace.
+func (b *RepositorySizeBreakdown) UniqueLayersSize() int64 {
+	return b.LayersSize - b.SharedLayersSize
+}
+
+// ImageSize is a virtual entity with no parallel on the database schema. It represents the size of a tagged manifest.
+type ImageSize struct {
+	Tag       string
+	Digest    digest.Digest
+	MediaType string
+	Size      int64
 }
 
 type Blob struct {
@@ -135,6 +197,50 @@ type Blob struct {
 // Blobs is a slice of Blob pointers.
 type Blobs []*Blob
 
+// DeduplicationStats summarizes the storage saved by blob deduplication. Each blob is stored once, no matter how many
+// repositories it is linked to.
+type DeduplicationStats struct {
+	// UniqueBlobs and UniqueBytes are the number and total size of the stored blobs.
+	UniqueBlobs int64
+	UniqueBytes int64
+	// LinkedBlobs and LinkedBytes are the number and total size of the blobs linked to each repository, summed across
+	// all repositories. This is what would be stored without deduplication.
+	LinkedBlobs int64
+	LinkedBytes int64
+}
+
+// SavedBytes returns the number of bytes saved by deduplication.
+func (s DeduplicationStats) SavedBytes() int64 {
+	return s.LinkedBytes - s.UniqueBytes
+}
+
+// Ratio returns the deduplication ratio, the linked bytes per stored byte. It is zero if there are no blobs.
+func (s DeduplicationStats) Ratio() float64 {
+	if s.UniqueBytes == 0 {
+		return 0
+	}
+	return float64(s.LinkedBytes) / float64(s.UniqueBytes)
+}
+
+// DeduplicationSnapshot represents the deduplication stats at a point in time.
+type DeduplicationSnapshot struct {
+	Time time.Time
+	DeduplicationStats
+}
+
+// SharedBlob represents a blob linked to more than one repository.
+type SharedBlob struct {
+	MediaType    string
+	Digest       digest.Digest
+	Size         int64
+	Repositories int64
+}
+
+// SavedBytes returns the number of bytes saved by storing the blob once for all repositories it is linked to.
+func (b SharedBlob) SavedBytes() int64 {
+	return b.Size * (b.Repositories - 1)
+}
+
 // GCBlobTask represents a row in the gc_blob_review_queue table.
 type GCBlobTask struct {
 	ReviewAfter time.Time
@@ -142,6 +248,12 @@ type GCBlobTask struct {
 	Digest      digest.Digest
 	CreatedAt   time.Time
 	Event       string
+	// ConfigMediaType is the configuration media type of the deleted manifest(s) that referenced the blob, set only for
+	// configuration blobs queued for review after a manifest delete.
+	ConfigMediaType string
+	// NamespaceID is the ID of the top-level namespace that last referenced the blob, set only for blobs queued for
+	// review after a layer or manifest delete. Bytes reclaimed by deleting the blob are attributed to this namespace.
+	NamespaceID sql.NullInt64
 }
 
 // GCConfigLink represents a row in the gc_blobs_configurations table.
@@ -179,6 +291,32 @@ type GCReviewAfterDefault struct {
 	Value time.Duration
 }
 
+// GCReviewStats represents a row in the gc_review_stats table, with the outcome of the online GC reviews of a queue
+// during an hour.
+type GCReviewStats struct {
+	Queue     string
+	Hour      time.Time
+	Processed int64
+	Dangling  int64
+	Failed    int64
+}
+
+// GCQueueStats holds the state of an online GC review queue.
+type GCQueueStats struct {
+	Queue string
+	// Size is the number of tasks in the queue.
+	Size int
+	// Pending is the number of tasks due for review.
+	Pending int
+	// OldestPendingReviewAfter is the review date of the task that has been due for review the longest. It is not
+	// set if there are no pending tasks.
+	OldestPendingReviewAfter sql.NullTime
+	// Retried is the number of tasks that were postponed after failed reviews.
+	Retried int
+	// MaxReviewCount is the highest number of failed reviews of a single task.
+	MaxReviewCount int
+}
+
 // LeaseType defines the types of available leases on repositories
 type LeaseType string
 
@@ -193,3 +331,229 @@ type RepositoryLease struct {
 	Path      string
 	Type      LeaseType
 }
+
+// BackgroundMigrationStatus is the status of a background migration.
+type BackgroundMigrationStatus string
+
+const (
+	// BackgroundMigrationActive is the status of background migrations with batches left to process.
+	BackgroundMigrationActive BackgroundMigrationStatus = "active"
+	// BackgroundMigrationFinished is the status of background migrations with all batches processed.
+	BackgroundMigrationFinished BackgroundMigrationStatus = "finished"
+	// BackgroundMigrationFailed is the status of background migrations that exceeded the maximum number of consecutive
+	// failed attempts to process a batch. These are not picked up again until retried.
+	BackgroundMigrationFailed BackgroundMigrationStatus = "failed"
+)
+
+// BackgroundMigration represents a row in the background_migrations table. A background migration processes the rows
+// of TableName in batches of BatchSize consecutive ColumnName values, from MinValue to MaxValue (inclusive), using the
+// job registered as JobName. NextValue is the first value of the next batch to process.
+type BackgroundMigration struct {
+	ID             int64
+	Name           string
+	JobName        string
+	TableName      string
+	ColumnName     string
+	Status         BackgroundMigrationStatus
+	BatchSize      int
+	MinValue       int64
+	MaxValue       int64
+	NextValue      int64
+	ProcessedRows  int64
+	FailedAttempts int
+	ErrorMessage   sql.NullString
+	CreatedAt      time.Time
+	UpdatedAt      sql.NullTime
+	StartedAt      sql.NullTime
+	FinishedAt     sql.NullTime
+}
+
+// Progress returns the fraction of the value range already processed, between 0 and 1.
+func (m *BackgroundMigration) Progress() float64 {
+	if m.Status == BackgroundMigrationFinished || m.MaxValue < m.MinValue {
+		return 1
+	}
+	done := m.NextValue - m.MinValue
+	if done <= 0 {
+		return 0
+	}
+	return float64(done) / float64(m.MaxValue-m.MinValue+1)
+}
+
+// BackgroundMigrations is a slice of BackgroundMigration pointers.
+type BackgroundMigrations []*BackgroundMigration
+
+// RepositorySettings holds the settings of a repository that override the registry configuration.
+type RepositorySettings struct {
+	NamespaceID  int64
+	RepositoryID int64
+	// ManifestPayloadSizeLimit is not valid if the limit is not overridden for the repository.
+	ManifestPayloadSizeLimit sql.NullInt64
+	// ReadOnly repositories can be pulled from but not pushed to or deleted from.
+	ReadOnly  bool
+	CreatedAt time.Time
+	UpdatedAt sql.NullTime
+}
+
+// RepositoryMetadata holds the descriptive metadata of a repository, meant to be displayed by registry UIs.
+type RepositoryMetadata struct {
+	NamespaceID  int64
+	RepositoryID int64
+	Description  string
+	// Readme is a markdown document.
+	Readme    string
+	Links     []RepositoryLink
+	CreatedAt time.Time
+	UpdatedAt sql.NullTime
+}
+
+// RepositoryLink is a named link to an external resource related to a repository, such as its source code or
+// documentation.
+type RepositoryLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// CleanupPolicy represents a row in the cleanup_policies table. A cleanup policy deletes the tags of a repository whose
+// name matches NameRegexDelete, except those that match NameRegexKeep, the KeepN most recently published ones and, if
+// OlderThan is set, those published within that period. Policies are executed every Cadence, starting at NextRunAt.
+type CleanupPolicy struct {
+	NamespaceID  int64
+	RepositoryID int64
+	// RepositoryPath is the path of the repository. Only set for policies claimed for execution.
+	RepositoryPath  string
+	Enabled         bool
+	Cadence         time.Duration
+	NameRegexDelete string
+	// NameRegexKeep is empty if no tags are to be kept based on their name.
+	NameRegexKeep string
+	KeepN         int
+	// OlderThan is zero if tags are to be deleted regardless of their age.
+	OlderThan time.Duration
+	NextRunAt time.Time
+	LastRunAt sql.NullTime
+	CreatedAt time.Time
+	UpdatedAt sql.NullTime
+}
+
+// RepositoryPurge represents a row in the repository_purges table. It tracks the progress of the deletion of all tags,
+// manifests and blob links of a repository, which is processed in batches by a background worker.
+type RepositoryPurge struct {
+	NamespaceID  int64
+	RepositoryID int64
+	// RepositoryPath is the path of the repository. Only set for purges claimed for processing.
+	RepositoryPath   string
+	TagsDeleted      int64
+	ManifestsDeleted int64
+	BlobsUnlinked    int64
+	// LastError is the error of the last batch, if it failed. Failed batches are retried.
+	LastError   sql.NullString
+	NextRunAt   time.Time
+	CompletedAt sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   sql.NullTime
+}
+
+// Completed returns true if all tags, manifests and blob links of the repository were deleted.
+func (p *RepositoryPurge) Completed() bool {
+	return p.CompletedAt.Valid
+}
+
+// NamespaceStatistics represents a row in the namespace_statistics table. It holds the usage counters of a top-level
+// namespace for a given day (UTC).
+type NamespaceStatistics struct {
+	NamespaceID    int64
+	Date           time.Time
+	Pushes         int64
+	Pulls          int64
+	BytesAdded     int64
+	BytesReclaimed int64
+}
+
+// Add adds the counters of other to s.
+func (s *NamespaceStatistics) Add(other *NamespaceStatistics) {
+	s.Pushes += other.Pushes
+	s.Pulls += other.Pulls
+	s.BytesAdded += other.BytesAdded
+	s.BytesReclaimed += other.BytesReclaimed
+}
+
+// Upload represents a row in the uploads table. It tracks a blob upload from the moment it's started until it's
+// completed or canceled, so that abandoned uploads can be found and purged.
+type Upload struct {
+	ID             int64
+	UUID           string
+	RepositoryPath string
+	CreatedAt      time.Time
+}
+
+// ProxyCachedTag represents a row in the proxy_cached_tags table. It records when a tag was fetched from the remote
+// registry of the pull-through cache, so that it can be deleted once expired.
+type ProxyCachedTag struct {
+	NamespaceID    int64
+	RepositoryID   int64
+	RepositoryPath string
+	Name           string
+	CachedAt       time.Time
+}
+
+// TagHistoryEntry represents a row in the tag_history table. It records a tag transition, from the manifest with
+// OldDigest to the manifest with NewDigest. OldDigest is empty if the tag was created, and NewDigest if it was deleted.
+type TagHistoryEntry struct {
+	ID           int64
+	NamespaceID  int64
+	RepositoryID int64
+	Name         string
+	OldDigest    digest.Digest
+	NewDigest    digest.Digest
+	// Actor is the name of the user that caused the transition. Empty if unknown.
+	Actor     string
+	CreatedAt time.Time
+}
+
+// NotificationEvent represents a row in the notification_events table. It holds an event pending delivery to a
+// notification endpoint, or whose delivery failed, in which case FailedAt is set.
+type NotificationEvent struct {
+	ID        int64
+	Endpoint  string
+	Payload   []byte
+	CreatedAt time.Time
+	FailedAt  sql.NullTime
+}
+
+// NotificationEventStats summarizes the events of a notification endpoint in the notification_events table.
+type NotificationEventStats struct {
+	Endpoint string
+	Pending  int
+	Failed   int
+	// OldestPendingAt is the creation time of the oldest event pending delivery, if any.
+	OldestPendingAt sql.NullTime
+}
+
+// CorruptedBlob represents a row in the corrupted_blobs table. It records a blob whose content in storage failed an
+// integrity verification, either because it's missing (ActualDigest is empty) or because its digest doesn't match.
+type CorruptedBlob struct {
+	Digest       digest.Digest
+	ActualDigest digest.Digest
+	Size         int64
+	Reason       string
+	// DetectedAt is the time at which the corruption was first detected, while CheckedAt is the time of the latest
+	// verification that failed.
+	DetectedAt time.Time
+	CheckedAt  time.Time
+}
+
+// AnnotatedManifest represents a manifest found by its annotations, which are indexed in the manifest_annotations
+// table. It includes the path of its repository and the names of the tags pointing to it, if any.
+type AnnotatedManifest struct {
+	ID             int64
+	NamespaceID    int64
+	RepositoryID   int64
+	RepositoryPath string
+	Digest         digest.Digest
+	MediaType      string
+	CreatedAt      time.Time
+	Tags           []string
+	// Annotations are the indexed annotations of the manifest, which may not include all of its annotations.
+	Annotations map[string]string
+}
@@ -19,3 +19,34 @@ func TestRepository_TopLevelPathSegment(t *testing.T) {
 	r.Path = "foo/bar"
 	require.Equal(t, "foo", r.TopLevelPathSegment())
 }
+
+func TestDeduplicationStats(t *testing.T) {
+	s := DeduplicationStats{UniqueBytes: 100, LinkedBytes: 250}
+	require.Equal(t, int64(150), s.SavedBytes())
+	require.Equal(t, 2.5, s.Ratio())
+
+	require.Zero(t, DeduplicationStats{}.Ratio())
+}
+
+func TestSharedBlob_SavedBytes(t *testing.T) {
+	b := SharedBlob{Size: 100, Repositories: 3}
+	require.Equal(t, int64(200), b.SavedBytes())
+}
+
+func TestBackgroundMigration_Progress(t *testing.T) {
+	m := &BackgroundMigration{Status: BackgroundMigrationActive, MinValue: 1, MaxValue: 100, NextValue: 1}
+	require.Zero(t, m.Progress())
+
+	m.NextValue = 26
+	require.Equal(t, 0.25, m.Progress())
+
+	m.NextValue = 101
+	require.Equal(t, float64(1), m.Progress())
+
+	// empty range
+	m = &BackgroundMigration{Status: BackgroundMigrationActive, MinValue: 1, MaxValue: 0, NextValue: 1}
+	require.Equal(t, float64(1), m.Progress())
+
+	m = &BackgroundMigration{Status: BackgroundMigrationFinished, MinValue: 1, MaxValue: 100, NextValue: 50}
+	require.Equal(t, float64(1), m.Progress())
+}
@@ -51,7 +51,7 @@ func scanFullNamespace(row *sql.Row) (*models.Namespace, error) {
 
 // FindByName finds a namespace by name.
 func (s *namespaceStore) FindByName(ctx context.Context, name string) (*models.Namespace, error) {
-	defer metrics.InstrumentQuery("namespace_find_by_name")()
+	defer metrics.InstrumentQuery(ctx, "namespace_find_by_name")()
 	q := `SELECT
 			id,
 			name,
@@ -71,7 +71,7 @@ func (s *namespaceStore) FindByName(ctx context.Context, name string) (*models.N
 // we never delete namespace records from the database. This method works by 1) find namespace record 2) if found return
 // otherwise perform an upsert (using createOrFind).
 func (s *namespaceStore) SafeFindOrCreate(ctx context.Context, n *models.Namespace) error {
-	defer metrics.InstrumentQuery("namespace_safe_find_or_create")()
+	defer metrics.InstrumentQuery(ctx, "namespace_safe_find_or_create")()
 
 	tmp, err := s.FindByName(ctx, n.Name)
 	if err != nil {
@@ -89,7 +89,7 @@ func (s *namespaceStore) SafeFindOrCreate(ctx context.Context, n *models.Namespa
 // on write operations between the corresponding read (FindByName) and write (Create) operations. Separate Find* and
 // Create method calls should be preferred to this when race conditions are not a concern.
 func (s *namespaceStore) createOrFind(ctx context.Context, n *models.Namespace) error {
-	defer metrics.InstrumentQuery("namespace_create_or_find")()
+	defer metrics.InstrumentQuery(ctx, "namespace_create_or_find")()
 	q := `INSERT INTO top_level_namespaces (name)
 			VALUES ($1)
 		ON CONFLICT (name)
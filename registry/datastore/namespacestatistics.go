@@ -0,0 +1,151 @@
+//go:generate mockgen -package mocks -destination mocks/namespacestatistics.go . NamespaceStatisticsStore
+
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// NamespaceStatisticsStore is the interface that a namespace statistics store should conform to.
+type NamespaceStatisticsStore interface {
+	Increment(ctx context.Context, ss []*models.NamespaceStatistics) error
+	FindByNamespace(ctx context.Context, namespaceID int64, from, to time.Time) ([]*models.NamespaceStatistics, error)
+	DeleteOlderThan(ctx context.Context, date time.Time) (int64, error)
+}
+
+// namespaceStatisticsStore is the concrete implementation of a NamespaceStatisticsStore.
+type namespaceStatisticsStore struct {
+	db Queryer
+}
+
+// NewNamespaceStatisticsStore builds a new namespace statistics store.
+func NewNamespaceStatisticsStore(db Queryer) NamespaceStatisticsStore {
+	return &namespaceStatisticsStore{db: db}
+}
+
+// namespaceStatisticsKey identifies the counters of a namespace for a given day.
+type namespaceStatisticsKey struct {
+	namespaceID int64
+	date        string
+}
+
+const namespaceStatisticsDateLayout = "2006-01-02"
+
+// Increment adds the given counters to those of the corresponding namespaces and days, creating them if needed. The
+// date of each entry is truncated to the day, in UTC.
+func (s *namespaceStatisticsStore) Increment(ctx context.Context, ss []*models.NamespaceStatistics) error {
+	// a row can only be updated once per statement, so merge the counters of each namespace and day
+	merged := make(map[namespaceStatisticsKey]*models.NamespaceStatistics, len(ss))
+	for _, st := range ss {
+		k := namespaceStatisticsKey{st.NamespaceID, st.Date.UTC().Format(namespaceStatisticsDateLayout)}
+		if m, ok := merged[k]; ok {
+			m.Add(st)
+			continue
+		}
+		m := *st
+		merged[k] = &m
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+
+	// sort rows to acquire locks in a deterministic order and avoid deadlocks between concurrent writers
+	keys := make([]namespaceStatisticsKey, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespaceID != keys[j].namespaceID {
+			return keys[i].namespaceID < keys[j].namespaceID
+		}
+		return keys[i].date < keys[j].date
+	})
+
+	defer metrics.InstrumentQuery(ctx, "namespace_statistics_increment")()
+	q := `INSERT INTO namespace_statistics AS ns (top_level_namespace_id, date, pushes, pulls, bytes_added, bytes_reclaimed)
+			VALUES %s
+		ON CONFLICT (top_level_namespace_id, date)
+			DO UPDATE SET
+				pushes = ns.pushes + EXCLUDED.pushes,
+				pulls = ns.pulls + EXCLUDED.pulls,
+				bytes_added = ns.bytes_added + EXCLUDED.bytes_added,
+				bytes_reclaimed = ns.bytes_reclaimed + EXCLUDED.bytes_reclaimed`
+	q = fmt.Sprintf(q, pullValues(len(keys), "bigint", "date", "bigint", "bigint", "bigint", "bigint"))
+
+	args := make([]any, 0, 6*len(keys))
+	for _, k := range keys {
+		m := merged[k]
+		args = append(args, k.namespaceID, k.date, m.Pushes, m.Pulls, m.BytesAdded, m.BytesReclaimed)
+	}
+
+	if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
+		return fmt.Errorf("incrementing namespace statistics: %w", err)
+	}
+
+	return nil
+}
+
+// FindByNamespace finds the counters of a given namespace between the days of from and to, both inclusive, in UTC.
+// Days without any activity are omitted. Results are sorted by date.
+func (s *namespaceStatisticsStore) FindByNamespace(ctx context.Context, namespaceID int64, from, to time.Time) ([]*models.NamespaceStatistics, error) {
+	defer metrics.InstrumentQuery(ctx, "namespace_statistics_find_by_namespace")()
+	q := `SELECT
+			top_level_namespace_id,
+			date,
+			pushes,
+			pulls,
+			bytes_added,
+			bytes_reclaimed
+		FROM
+			namespace_statistics
+		WHERE
+			top_level_namespace_id = $1
+			AND date BETWEEN $2::date AND $3::date
+		ORDER BY
+			date`
+
+	rows, err := s.db.QueryContext(ctx, q, namespaceID,
+		from.UTC().Format(namespaceStatisticsDateLayout), to.UTC().Format(namespaceStatisticsDateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("finding namespace statistics: %w", err)
+	}
+	defer rows.Close()
+
+	ss := make([]*models.NamespaceStatistics, 0)
+	for rows.Next() {
+		st := new(models.NamespaceStatistics)
+		if err := rows.Scan(&st.NamespaceID, &st.Date, &st.Pushes, &st.Pulls, &st.BytesAdded, &st.BytesReclaimed); err != nil {
+			return nil, fmt.Errorf("scanning namespace statistics: %w", err)
+		}
+		ss = append(ss, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("finding namespace statistics: %w", err)
+	}
+
+	return ss, nil
+}
+
+// DeleteOlderThan deletes the counters of all namespaces for the days before that of date, in UTC. Returns the number
+// of deleted rows.
+func (s *namespaceStatisticsStore) DeleteOlderThan(ctx context.Context, date time.Time) (int64, error) {
+	defer metrics.InstrumentQuery(ctx, "namespace_statistics_delete_older_than")()
+	q := "DELETE FROM namespace_statistics WHERE date < $1::date"
+
+	res, err := s.db.ExecContext(ctx, q, date.UTC().Format(namespaceStatisticsDateLayout))
+	if err != nil {
+		return 0, fmt.Errorf("deleting namespace statistics: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("deleting namespace statistics: %w", err)
+	}
+
+	return n, nil
+}
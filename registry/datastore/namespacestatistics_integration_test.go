@@ -0,0 +1,97 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadNamespaceStatisticsFixtures(tb testing.TB) {
+	tb.Helper()
+
+	testutil.ReloadFixtures(tb, suite.db, suite.basePath, testutil.NamespacesTable)
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.NamespaceStatisticsTable))
+}
+
+func TestNamespaceStatisticsStore_Increment(t *testing.T) {
+	reloadNamespaceStatisticsFixtures(t)
+
+	s := datastore.NewNamespaceStatisticsStore(suite.db)
+	d1 := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	d2 := d1.Add(24 * time.Hour)
+
+	// entries for the same namespace and day are merged
+	err := s.Increment(suite.ctx, []*models.NamespaceStatistics{
+		{NamespaceID: 1, Date: d1, Pushes: 1, BytesAdded: 100},
+		{NamespaceID: 1, Date: d1.Add(time.Hour), Pulls: 2},
+		{NamespaceID: 1, Date: d2, Pulls: 1},
+		{NamespaceID: 2, Date: d1, BytesReclaimed: 50},
+	})
+	require.NoError(t, err)
+
+	// existing counters are incremented
+	err = s.Increment(suite.ctx, []*models.NamespaceStatistics{{NamespaceID: 1, Date: d1, Pushes: 1, BytesReclaimed: 10}})
+	require.NoError(t, err)
+
+	ss, err := s.FindByNamespace(suite.ctx, 1, d1, d2)
+	require.NoError(t, err)
+	require.Len(t, ss, 2)
+
+	require.Equal(t, "2023-12-01", ss[0].Date.Format("2006-01-02"))
+	require.EqualValues(t, 1, ss[0].NamespaceID)
+	require.EqualValues(t, 2, ss[0].Pushes)
+	require.EqualValues(t, 2, ss[0].Pulls)
+	require.EqualValues(t, 100, ss[0].BytesAdded)
+	require.EqualValues(t, 10, ss[0].BytesReclaimed)
+
+	require.Equal(t, "2023-12-02", ss[1].Date.Format("2006-01-02"))
+	require.EqualValues(t, 1, ss[1].Pulls)
+
+	// the range is inclusive
+	ss, err = s.FindByNamespace(suite.ctx, 1, d2, d2)
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+
+	ss, err = s.FindByNamespace(suite.ctx, 2, d1, d2)
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+	require.EqualValues(t, 50, ss[0].BytesReclaimed)
+}
+
+func TestNamespaceStatisticsStore_Increment_Empty(t *testing.T) {
+	reloadNamespaceStatisticsFixtures(t)
+
+	s := datastore.NewNamespaceStatisticsStore(suite.db)
+	require.NoError(t, s.Increment(suite.ctx, nil))
+}
+
+func TestNamespaceStatisticsStore_DeleteOlderThan(t *testing.T) {
+	reloadNamespaceStatisticsFixtures(t)
+
+	s := datastore.NewNamespaceStatisticsStore(suite.db)
+	d1 := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	d2 := d1.Add(24 * time.Hour)
+
+	err := s.Increment(suite.ctx, []*models.NamespaceStatistics{
+		{NamespaceID: 1, Date: d1, Pushes: 1},
+		{NamespaceID: 1, Date: d2, Pushes: 1},
+		{NamespaceID: 2, Date: d1, Pushes: 1},
+	})
+	require.NoError(t, err)
+
+	// the given day is retained
+	n, err := s.DeleteOlderThan(suite.ctx, d2.Add(time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, n)
+
+	ss, err := s.FindByNamespace(suite.ctx, 1, d1, d2)
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+	require.Equal(t, "2023-12-02", ss[0].Date.Format("2006-01-02"))
+}
@@ -0,0 +1,257 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// NotificationEventStore is the interface that a notification event store should conform to. It backs the persistent
+// queue of events pending delivery to notification endpoints, which is shared by all registry instances.
+type NotificationEventStore interface {
+	// Create persists a new event pending delivery. The ID and creation time of e are populated.
+	Create(ctx context.Context, e *models.NotificationEvent) error
+	// Claim finds up to limit events pending delivery to endpoint, oldest first, and locks them for the lease
+	// duration, during which they're not claimed again. Events locked by an expired lease can be claimed again.
+	Claim(ctx context.Context, endpoint string, limit int, lease time.Duration) ([]*models.NotificationEvent, error)
+	// Delete deletes the event with the given ID. It's a no-op if no such event exists.
+	Delete(ctx context.Context, id int64) error
+	// Fail marks the event with the given ID as failed, excluding it from delivery until requeued.
+	Fail(ctx context.Context, id int64) error
+	// Requeue marks the events that failed since the given time as pending delivery again. If endpoint is not empty,
+	// only events of that endpoint are requeued. The number of requeued events is returned.
+	Requeue(ctx context.Context, endpoint string, since time.Time) (int64, error)
+	// DeleteFailedBefore deletes the events that failed before t. The number of deleted events is returned.
+	DeleteFailedBefore(ctx context.Context, t time.Time) (int64, error)
+	// OldestPendingAt returns the creation time of the oldest event pending delivery to endpoint, or the zero time if
+	// there are none.
+	OldestPendingAt(ctx context.Context, endpoint string) (time.Time, error)
+	// Stats summarizes the pending and failed events of each endpoint, sorted by endpoint name.
+	Stats(ctx context.Context) ([]*models.NotificationEventStats, error)
+}
+
+type notificationEventStore struct {
+	db Queryer
+}
+
+// NewNotificationEventStore builds a new notificationEventStore.
+func NewNotificationEventStore(db Queryer) NotificationEventStore {
+	return &notificationEventStore{db: db}
+}
+
+// Create persists a new event pending delivery. The ID and creation time of e are populated.
+func (s *notificationEventStore) Create(ctx context.Context, e *models.NotificationEvent) error {
+	defer metrics.InstrumentQuery(ctx, "notification_event_create")()
+
+	q := `INSERT INTO notification_events (endpoint, payload)
+			VALUES ($1, $2)
+		RETURNING
+			id, created_at`
+
+	row := s.db.QueryRowContext(ctx, q, e.Endpoint, string(e.Payload))
+	if err := row.Scan(&e.ID, &e.CreatedAt); err != nil {
+		return fmt.Errorf("creating notification event: %w", err)
+	}
+
+	return nil
+}
+
+// Claim finds up to limit events pending delivery to endpoint, oldest first, and locks them for the lease duration,
+// during which they're not claimed again. Events locked by an expired lease can be claimed again.
+func (s *notificationEventStore) Claim(ctx context.Context, endpoint string, limit int, lease time.Duration) ([]*models.NotificationEvent, error) {
+	defer metrics.InstrumentQuery(ctx, "notification_event_claim")()
+
+	// rows locked by concurrent claims from other registry instances are skipped rather than waited for
+	q := `UPDATE
+			notification_events
+		SET
+			locked_until = now() + make_interval(secs => $3)
+		WHERE
+			id IN (
+				SELECT
+					id
+				FROM
+					notification_events
+				WHERE
+					endpoint = $1
+					AND failed_at IS NULL
+					AND (locked_until IS NULL
+						OR locked_until < now())
+				ORDER BY
+					id
+				LIMIT $2
+				FOR UPDATE
+					SKIP LOCKED)
+		RETURNING
+			id, endpoint, payload, created_at`
+
+	rows, err := s.db.QueryContext(ctx, q, endpoint, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claiming notification events: %w", err)
+	}
+	defer rows.Close()
+
+	ee := make([]*models.NotificationEvent, 0)
+	for rows.Next() {
+		e := new(models.NotificationEvent)
+		if err := rows.Scan(&e.ID, &e.Endpoint, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning notification event: %w", err)
+		}
+		ee = append(ee, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning notification events: %w", err)
+	}
+
+	// RETURNING does not preserve the order of the subquery
+	sort.Slice(ee, func(i, j int) bool { return ee[i].ID < ee[j].ID })
+
+	return ee, nil
+}
+
+// Delete deletes the event with the given ID. It's a no-op if no such event exists.
+func (s *notificationEventStore) Delete(ctx context.Context, id int64) error {
+	defer metrics.InstrumentQuery(ctx, "notification_event_delete")()
+
+	q := "DELETE FROM notification_events WHERE id = $1"
+
+	if _, err := s.db.ExecContext(ctx, q, id); err != nil {
+		return fmt.Errorf("deleting notification event: %w", err)
+	}
+
+	return nil
+}
+
+// Fail marks the event with the given ID as failed, excluding it from delivery until requeued.
+func (s *notificationEventStore) Fail(ctx context.Context, id int64) error {
+	defer metrics.InstrumentQuery(ctx, "notification_event_fail")()
+
+	q := `UPDATE
+			notification_events
+		SET
+			failed_at = now(),
+			locked_until = NULL
+		WHERE
+			id = $1`
+
+	if _, err := s.db.ExecContext(ctx, q, id); err != nil {
+		return fmt.Errorf("marking notification event as failed: %w", err)
+	}
+
+	return nil
+}
+
+// Requeue marks the events that failed since the given time as pending delivery again. If endpoint is not empty, only
+// events of that endpoint are requeued. The number of requeued events is returned.
+func (s *notificationEventStore) Requeue(ctx context.Context, endpoint string, since time.Time) (int64, error) {
+	defer metrics.InstrumentQuery(ctx, "notification_event_requeue")()
+
+	q := `UPDATE
+			notification_events
+		SET
+			failed_at = NULL
+		WHERE
+			failed_at IS NOT NULL
+			AND failed_at >= $1
+			AND ($2 = ''
+				OR endpoint = $2)`
+
+	res, err := s.db.ExecContext(ctx, q, since, endpoint)
+	if err != nil {
+		return 0, fmt.Errorf("requeuing notification events: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("counting requeued notification events: %w", err)
+	}
+
+	return n, nil
+}
+
+// DeleteFailedBefore deletes the events that failed before t. The number of deleted events is returned.
+func (s *notificationEventStore) DeleteFailedBefore(ctx context.Context, t time.Time) (int64, error) {
+	defer metrics.InstrumentQuery(ctx, "notification_event_delete_failed_before")()
+
+	q := "DELETE FROM notification_events WHERE failed_at < $1"
+
+	res, err := s.db.ExecContext(ctx, q, t)
+	if err != nil {
+		return 0, fmt.Errorf("deleting failed notification events: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("counting deleted notification events: %w", err)
+	}
+
+	return n, nil
+}
+
+// OldestPendingAt returns the creation time of the oldest event pending delivery to endpoint, or the zero time if
+// there are none.
+func (s *notificationEventStore) OldestPendingAt(ctx context.Context, endpoint string) (time.Time, error) {
+	defer metrics.InstrumentQuery(ctx, "notification_event_oldest_pending_at")()
+
+	q := `SELECT
+			created_at
+		FROM
+			notification_events
+		WHERE
+			endpoint = $1
+			AND failed_at IS NULL
+		ORDER BY
+			id
+		LIMIT 1`
+
+	var t time.Time
+	if err := s.db.QueryRowContext(ctx, q, endpoint).Scan(&t); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("finding oldest pending notification event: %w", err)
+	}
+
+	return t, nil
+}
+
+// Stats summarizes the pending and failed events of each endpoint, sorted by endpoint name.
+func (s *notificationEventStore) Stats(ctx context.Context) ([]*models.NotificationEventStats, error) {
+	defer metrics.InstrumentQuery(ctx, "notification_event_stats")()
+
+	q := `SELECT
+			endpoint,
+			count(*) FILTER (WHERE failed_at IS NULL),
+			count(*) FILTER (WHERE failed_at IS NOT NULL),
+			min(created_at) FILTER (WHERE failed_at IS NULL)
+		FROM
+			notification_events
+		GROUP BY
+			endpoint
+		ORDER BY
+			endpoint`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("summarizing notification events: %w", err)
+	}
+	defer rows.Close()
+
+	ss := make([]*models.NotificationEventStats, 0)
+	for rows.Next() {
+		st := new(models.NotificationEventStats)
+		if err := rows.Scan(&st.Endpoint, &st.Pending, &st.Failed, &st.OldestPendingAt); err != nil {
+			return nil, fmt.Errorf("scanning notification event stats: %w", err)
+		}
+		ss = append(ss, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning notification event stats: %w", err)
+	}
+
+	return ss, nil
+}
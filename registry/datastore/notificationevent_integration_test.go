@@ -0,0 +1,193 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func unloadNotificationEventFixtures(tb testing.TB) {
+	tb.Helper()
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.NotificationEventsTable))
+}
+
+func createNotificationEvents(t *testing.T, s datastore.NotificationEventStore, endpoint string, n int) []*models.NotificationEvent {
+	t.Helper()
+
+	ee := make([]*models.NotificationEvent, 0, n)
+	for i := 0; i < n; i++ {
+		e := &models.NotificationEvent{Endpoint: endpoint, Payload: []byte(`{"id":"x"}`)}
+		require.NoError(t, s.Create(suite.ctx, e))
+		require.NotZero(t, e.ID)
+		require.NotZero(t, e.CreatedAt)
+		ee = append(ee, e)
+	}
+	return ee
+}
+
+func TestNotificationEventStore_Claim(t *testing.T) {
+	unloadNotificationEventFixtures(t)
+
+	s := datastore.NewNotificationEventStore(suite.db)
+	ee := createNotificationEvents(t, s, "a", 3)
+	createNotificationEvents(t, s, "b", 1)
+
+	claimed, err := s.Claim(suite.ctx, "a", 2, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	require.Equal(t, ee[0].ID, claimed[0].ID)
+	require.Equal(t, ee[1].ID, claimed[1].ID)
+	require.Equal(t, "a", claimed[0].Endpoint)
+	require.JSONEq(t, `{"id":"x"}`, string(claimed[0].Payload))
+
+	// leased events are not claimed again
+	claimed, err = s.Claim(suite.ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, ee[2].ID, claimed[0].ID)
+
+	claimed, err = s.Claim(suite.ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+}
+
+func TestNotificationEventStore_Claim_ExpiredLease(t *testing.T) {
+	unloadNotificationEventFixtures(t)
+
+	s := datastore.NewNotificationEventStore(suite.db)
+	ee := createNotificationEvents(t, s, "a", 1)
+
+	claimed, err := s.Claim(suite.ctx, "a", 10, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	time.Sleep(10 * time.Millisecond)
+
+	claimed, err = s.Claim(suite.ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, ee[0].ID, claimed[0].ID)
+}
+
+func TestNotificationEventStore_Delete(t *testing.T) {
+	unloadNotificationEventFixtures(t)
+
+	s := datastore.NewNotificationEventStore(suite.db)
+	ee := createNotificationEvents(t, s, "a", 2)
+
+	require.NoError(t, s.Delete(suite.ctx, ee[0].ID))
+	// no-op if not found
+	require.NoError(t, s.Delete(suite.ctx, ee[0].ID))
+
+	claimed, err := s.Claim(suite.ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, ee[1].ID, claimed[0].ID)
+}
+
+func TestNotificationEventStore_FailAndRequeue(t *testing.T) {
+	unloadNotificationEventFixtures(t)
+
+	s := datastore.NewNotificationEventStore(suite.db)
+	a := createNotificationEvents(t, s, "a", 2)
+	b := createNotificationEvents(t, s, "b", 1)
+
+	_, err := s.Claim(suite.ctx, "a", 10, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, s.Fail(suite.ctx, a[0].ID))
+	require.NoError(t, s.Fail(suite.ctx, b[0].ID))
+
+	// failed events are not claimed
+	claimed, err := s.Claim(suite.ctx, "b", 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	// nothing failed since
+	n, err := s.Requeue(suite.ctx, "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = s.Requeue(suite.ctx, "a", time.Time{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+
+	// the lease is released on failure, so the requeued event can be claimed right away
+	claimed, err = s.Claim(suite.ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, a[0].ID, claimed[0].ID)
+
+	n, err = s.Requeue(suite.ctx, "", time.Time{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+}
+
+func TestNotificationEventStore_DeleteFailedBefore(t *testing.T) {
+	unloadNotificationEventFixtures(t)
+
+	s := datastore.NewNotificationEventStore(suite.db)
+	ee := createNotificationEvents(t, s, "a", 2)
+	require.NoError(t, s.Fail(suite.ctx, ee[0].ID))
+
+	n, err := s.DeleteFailedBefore(suite.ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = s.DeleteFailedBefore(suite.ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+
+	// pending events are kept
+	claimed, err := s.Claim(suite.ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, ee[1].ID, claimed[0].ID)
+}
+
+func TestNotificationEventStore_OldestPendingAt(t *testing.T) {
+	unloadNotificationEventFixtures(t)
+
+	s := datastore.NewNotificationEventStore(suite.db)
+
+	got, err := s.OldestPendingAt(suite.ctx, "a")
+	require.NoError(t, err)
+	require.True(t, got.IsZero())
+
+	ee := createNotificationEvents(t, s, "a", 2)
+	require.NoError(t, s.Fail(suite.ctx, ee[0].ID))
+
+	got, err = s.OldestPendingAt(suite.ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ee[1].CreatedAt.Equal(got))
+}
+
+func TestNotificationEventStore_Stats(t *testing.T) {
+	unloadNotificationEventFixtures(t)
+
+	s := datastore.NewNotificationEventStore(suite.db)
+	a := createNotificationEvents(t, s, "a", 3)
+	b := createNotificationEvents(t, s, "b", 1)
+	require.NoError(t, s.Fail(suite.ctx, a[0].ID))
+	require.NoError(t, s.Fail(suite.ctx, b[0].ID))
+
+	ss, err := s.Stats(suite.ctx)
+	require.NoError(t, err)
+	require.Len(t, ss, 2)
+
+	require.Equal(t, "a", ss[0].Endpoint)
+	require.Equal(t, 2, ss[0].Pending)
+	require.Equal(t, 1, ss[0].Failed)
+	require.True(t, ss[0].OldestPendingAt.Valid)
+	require.True(t, a[1].CreatedAt.Equal(ss[0].OldestPendingAt.Time))
+
+	require.Equal(t, "b", ss[1].Endpoint)
+	require.Zero(t, ss[1].Pending)
+	require.Equal(t, 1, ss[1].Failed)
+	require.False(t, ss[1].OldestPendingAt.Valid)
+}
@@ -0,0 +1,109 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// ProxyCachedTagStore is the interface that a proxy cached tag store should conform to.
+type ProxyCachedTagStore interface {
+	// Touch records the tag with the given name of repository r as cached now, replacing the time it was previously
+	// cached at, if any.
+	Touch(ctx context.Context, r *models.Repository, name string) error
+	// FindCachedBefore finds up to limit tags cached before t, oldest first.
+	FindCachedBefore(ctx context.Context, t time.Time, limit int) ([]*models.ProxyCachedTag, error)
+	// Delete deletes the record of the cached tag ct, unless it was cached again since it was found. It's a no-op if
+	// no such record exists.
+	Delete(ctx context.Context, ct *models.ProxyCachedTag) error
+}
+
+// proxyCachedTagStore is the concrete implementation of a ProxyCachedTagStore.
+type proxyCachedTagStore struct {
+	db Queryer
+}
+
+// NewProxyCachedTagStore builds a new proxy cached tag store.
+func NewProxyCachedTagStore(db Queryer) ProxyCachedTagStore {
+	return &proxyCachedTagStore{db: db}
+}
+
+// Touch records the tag with the given name of repository r as cached now, replacing the time it was previously
+// cached at, if any.
+func (s *proxyCachedTagStore) Touch(ctx context.Context, r *models.Repository, name string) error {
+	defer metrics.InstrumentQuery(ctx, "proxy_cached_tag_touch")()
+
+	q := `INSERT INTO proxy_cached_tags (top_level_namespace_id, repository_id, name)
+			VALUES ($1, $2, $3)
+		ON CONFLICT (top_level_namespace_id, repository_id, name)
+			DO UPDATE SET
+				cached_at = now()`
+
+	if _, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID, name); err != nil {
+		return fmt.Errorf("recording proxy cached tag: %w", err)
+	}
+
+	return nil
+}
+
+// FindCachedBefore finds up to limit tags cached before t, oldest first.
+func (s *proxyCachedTagStore) FindCachedBefore(ctx context.Context, t time.Time, limit int) ([]*models.ProxyCachedTag, error) {
+	defer metrics.InstrumentQuery(ctx, "proxy_cached_tag_find_cached_before")()
+
+	q := `SELECT
+			p.top_level_namespace_id,
+			p.repository_id,
+			r.path,
+			p.name,
+			p.cached_at
+		FROM
+			proxy_cached_tags AS p
+			JOIN repositories AS r ON r.top_level_namespace_id = p.top_level_namespace_id
+				AND r.id = p.repository_id
+		WHERE
+			p.cached_at < $1
+		ORDER BY
+			p.cached_at
+		LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, q, t, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding proxy cached tags: %w", err)
+	}
+	defer rows.Close()
+
+	tt := make([]*models.ProxyCachedTag, 0)
+	for rows.Next() {
+		ct := new(models.ProxyCachedTag)
+		if err := rows.Scan(&ct.NamespaceID, &ct.RepositoryID, &ct.RepositoryPath, &ct.Name, &ct.CachedAt); err != nil {
+			return nil, fmt.Errorf("scanning proxy cached tag: %w", err)
+		}
+		tt = append(tt, ct)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning proxy cached tags: %w", err)
+	}
+
+	return tt, nil
+}
+
+// Delete deletes the record of the cached tag ct, unless it was cached again since it was found. It's a no-op if no
+// such record exists.
+func (s *proxyCachedTagStore) Delete(ctx context.Context, ct *models.ProxyCachedTag) error {
+	defer metrics.InstrumentQuery(ctx, "proxy_cached_tag_delete")()
+
+	q := `DELETE FROM proxy_cached_tags
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name = $3
+			AND cached_at = $4`
+
+	if _, err := s.db.ExecContext(ctx, q, ct.NamespaceID, ct.RepositoryID, ct.Name, ct.CachedAt); err != nil {
+		return fmt.Errorf("deleting proxy cached tag: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func unloadProxyCachedTagFixtures(tb testing.TB) {
+	tb.Helper()
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.ProxyCachedTagsTable))
+}
+
+func TestProxyCachedTagStore_Touch(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	unloadProxyCachedTagFixtures(t)
+
+	s := datastore.NewProxyCachedTagStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	require.NoError(t, s.Touch(suite.ctx, r, "latest"))
+
+	tt, err := s.FindCachedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, tt, 1)
+	require.Equal(t, int64(1), tt[0].NamespaceID)
+	require.Equal(t, int64(3), tt[0].RepositoryID)
+	require.Equal(t, "gitlab-org/gitlab-test/backend", tt[0].RepositoryPath)
+	require.Equal(t, "latest", tt[0].Name)
+	require.NotZero(t, tt[0].CachedAt)
+
+	// touching again refreshes the time the tag was cached at
+	require.NoError(t, s.Touch(suite.ctx, r, "latest"))
+
+	tt2, err := s.FindCachedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, tt2, 1)
+	require.True(t, tt2[0].CachedAt.After(tt[0].CachedAt))
+}
+
+func TestProxyCachedTagStore_FindCachedBefore(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	unloadProxyCachedTagFixtures(t)
+
+	s := datastore.NewProxyCachedTagStore(suite.db)
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Touch(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3}, name))
+	}
+
+	tt, err := s.FindCachedBefore(suite.ctx, time.Now().Add(-time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, tt)
+
+	tt, err = s.FindCachedBefore(suite.ctx, time.Now().Add(time.Minute), 2)
+	require.NoError(t, err)
+	require.Len(t, tt, 2)
+}
+
+func TestProxyCachedTagStore_Delete(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	unloadProxyCachedTagFixtures(t)
+
+	s := datastore.NewProxyCachedTagStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	require.NoError(t, s.Touch(suite.ctx, r, "a"))
+	require.NoError(t, s.Touch(suite.ctx, r, "b"))
+
+	tt, err := s.FindCachedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, tt, 2)
+
+	require.NoError(t, s.Delete(suite.ctx, tt[0]))
+	// records of unknown tags are ignored
+	require.NoError(t, s.Delete(suite.ctx, &models.ProxyCachedTag{NamespaceID: 1, RepositoryID: 3, Name: "unknown"}))
+
+	tt2, err := s.FindCachedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, tt2, 1)
+	require.Equal(t, tt[1].Name, tt2[0].Name)
+}
+
+func TestProxyCachedTagStore_Delete_CachedAgain(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	unloadProxyCachedTagFixtures(t)
+
+	s := datastore.NewProxyCachedTagStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	require.NoError(t, s.Touch(suite.ctx, r, "latest"))
+
+	tt, err := s.FindCachedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, tt, 1)
+
+	// the record is kept if the tag was cached again after being found
+	require.NoError(t, s.Touch(suite.ctx, r, "latest"))
+	require.NoError(t, s.Delete(suite.ctx, tt[0]))
+
+	tt, err = s.FindCachedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, tt, 1)
+}
+
+func TestProxyCachedTagStore_RepositoryDeleteCascade(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	unloadProxyCachedTagFixtures(t)
+
+	s := datastore.NewProxyCachedTagStore(suite.db)
+	require.NoError(t, s.Touch(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3}, "latest"))
+
+	_, err := suite.db.ExecContext(suite.ctx, "DELETE FROM repositories WHERE top_level_namespace_id = 1 AND id = 3")
+	require.NoError(t, err)
+
+	tt, err := s.FindCachedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, tt)
+}
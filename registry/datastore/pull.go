@@ -0,0 +1,142 @@
+//go:generate mockgen -package mocks -destination mocks/pull.go . PullStore
+
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// PullStore is the interface that a pull store should conform to. It records the last time manifests and tags were
+// pulled.
+type PullStore interface {
+	// UpdateLastPulledAt sets the last pulled timestamp of the manifests, and tags if any, of the given pulls. Timestamps
+	// are never moved backwards, and manifests or tags that no longer exist are ignored.
+	UpdateLastPulledAt(ctx context.Context, pulls []*models.Pull) error
+}
+
+type pullStore struct {
+	db Queryer
+}
+
+// NewPullStore builds a new pullStore.
+func NewPullStore(db Queryer) PullStore {
+	return &pullStore{db: db}
+}
+
+type manifestPullKey struct {
+	namespaceID, repositoryID, manifestID int64
+}
+
+type tagPullKey struct {
+	namespaceID, repositoryID int64
+	name                      string
+}
+
+// UpdateLastPulledAt sets the last pulled timestamp of the manifests, and tags if any, of the given pulls. Timestamps
+// are never moved backwards, and manifests or tags that no longer exist are ignored.
+func (s *pullStore) UpdateLastPulledAt(ctx context.Context, pulls []*models.Pull) error {
+	// a row can only be updated once per statement, so keep the latest pull of each manifest and tag
+	manifests := make(map[manifestPullKey]time.Time, len(pulls))
+	tags := make(map[tagPullKey]time.Time)
+	for _, p := range pulls {
+		mk := manifestPullKey{p.NamespaceID, p.RepositoryID, p.ManifestID}
+		if p.PulledAt.After(manifests[mk]) {
+			manifests[mk] = p.PulledAt
+		}
+		if p.TagName == "" {
+			continue
+		}
+		tk := tagPullKey{p.NamespaceID, p.RepositoryID, p.TagName}
+		if p.PulledAt.After(tags[tk]) {
+			tags[tk] = p.PulledAt
+		}
+	}
+
+	if err := s.updateManifests(ctx, manifests); err != nil {
+		return err
+	}
+	return s.updateTags(ctx, tags)
+}
+
+// pullValues builds a VALUES list with n rows of the given SQL types.
+func pullValues(n int, types ...string) string {
+	rows := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		cols := make([]string, 0, len(types))
+		for j, t := range types {
+			cols = append(cols, fmt.Sprintf("$%d::%s", i*len(types)+j+1, t))
+		}
+		rows = append(rows, "("+strings.Join(cols, ", ")+")")
+	}
+	return strings.Join(rows, ", ")
+}
+
+func (s *pullStore) updateManifests(ctx context.Context, manifests map[manifestPullKey]time.Time) error {
+	if len(manifests) == 0 {
+		return nil
+	}
+	defer metrics.InstrumentQuery(ctx, "pull_update_manifests_last_pulled_at")()
+
+	q := `UPDATE
+			manifests AS m
+		SET
+			last_pulled_at = v.pulled_at
+		FROM (
+			VALUES %s) AS v (top_level_namespace_id, repository_id, id, pulled_at)
+		WHERE
+			m.top_level_namespace_id = v.top_level_namespace_id
+			AND m.repository_id = v.repository_id
+			AND m.id = v.id
+			AND (m.last_pulled_at IS NULL
+				OR m.last_pulled_at < v.pulled_at)`
+	q = fmt.Sprintf(q, pullValues(len(manifests), "bigint", "bigint", "bigint", "timestamptz"))
+
+	args := make([]any, 0, 4*len(manifests))
+	for k, t := range manifests {
+		args = append(args, k.namespaceID, k.repositoryID, k.manifestID, t)
+	}
+
+	if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
+		return fmt.Errorf("updating manifests last pulled at: %w", err)
+	}
+
+	return nil
+}
+
+func (s *pullStore) updateTags(ctx context.Context, tags map[tagPullKey]time.Time) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	defer metrics.InstrumentQuery(ctx, "pull_update_tags_last_pulled_at")()
+
+	q := `UPDATE
+			tags AS t
+		SET
+			last_pulled_at = v.pulled_at
+		FROM (
+			VALUES %s) AS v (top_level_namespace_id, repository_id, name, pulled_at)
+		WHERE
+			t.top_level_namespace_id = v.top_level_namespace_id
+			AND t.repository_id = v.repository_id
+			AND t.name = v.name
+			AND (t.last_pulled_at IS NULL
+				OR t.last_pulled_at < v.pulled_at)`
+	q = fmt.Sprintf(q, pullValues(len(tags), "bigint", "bigint", "text", "timestamptz"))
+
+	args := make([]any, 0, 4*len(tags))
+	for k, t := range tags {
+		args = append(args, k.namespaceID, k.repositoryID, k.name, t)
+	}
+
+	if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
+		return fmt.Errorf("updating tags last pulled at: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/stretchr/testify/require"
+)
+
+// lastPulledAt returns the last pulled timestamp of each tag in the given repository, indexed by tag name.
+func lastPulledAt(t *testing.T, r *models.Repository) map[string]time.Time {
+	t.Helper()
+
+	tt, err := datastore.NewRepositoryStore(suite.db).TagsDetailPaginated(suite.ctx, r, datastore.FilterParams{MaxEntries: 100})
+	require.NoError(t, err)
+
+	m := make(map[string]time.Time, len(tt))
+	for _, tag := range tt {
+		if tag.LastPulledAt.Valid {
+			m[tag.Name] = tag.LastPulledAt.Time.UTC()
+		}
+	}
+	return m
+}
+
+func TestPullStore_UpdateLastPulledAt(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewPullStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	require.Empty(t, lastPulledAt(t, r))
+
+	t1 := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	// tags 2.0.0 and latest point to manifest 2, tag 1.0.0 points to manifest 1 and tag 0.2.0 to manifest 6
+	err := s.UpdateLastPulledAt(suite.ctx, []*models.Pull{
+		{NamespaceID: 1, RepositoryID: 3, ManifestID: 2, TagName: "latest", PulledAt: t1},
+		{NamespaceID: 1, RepositoryID: 3, ManifestID: 2, TagName: "latest", PulledAt: t2},
+		{NamespaceID: 1, RepositoryID: 3, ManifestID: 1, PulledAt: t1},
+		// unknown manifests and tags are ignored
+		{NamespaceID: 1, RepositoryID: 3, ManifestID: 100, TagName: "foo", PulledAt: t1},
+	})
+	require.NoError(t, err)
+
+	// tags report the latest of their own and their manifest pull timestamps
+	require.Equal(t, map[string]time.Time{
+		"latest": t2,
+		"2.0.0":  t2,
+		"1.0.0":  t1,
+	}, lastPulledAt(t, r))
+
+	// timestamps never move backwards
+	err = s.UpdateLastPulledAt(suite.ctx, []*models.Pull{
+		{NamespaceID: 1, RepositoryID: 3, ManifestID: 2, TagName: "latest", PulledAt: t1},
+		{NamespaceID: 1, RepositoryID: 3, ManifestID: 6, TagName: "0.2.0", PulledAt: t1},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]time.Time{
+		"latest": t2,
+		"2.0.0":  t2,
+		"1.0.0":  t1,
+		"0.2.0":  t1,
+	}, lastPulledAt(t, r))
+
+	// other repositories are not affected
+	require.Empty(t, lastPulledAt(t, &models.Repository{NamespaceID: 1, ID: 4}))
+}
+
+func TestPullStore_UpdateLastPulledAt_Empty(t *testing.T) {
+	s := datastore.NewPullStore(suite.db)
+	require.NoError(t, s.UpdateLastPulledAt(suite.ctx, nil))
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -47,40 +48,62 @@ type FilterParams struct {
 	SortOrder   SortOrder
 	OrderBy     string
 	Name        string
+	NamePrefix  string
+	NameRegex   string
 	BeforeEntry string
 	LastEntry   string
 	PublishedAt string
 	MaxEntries  int
+	// PathPrefix and ModifiedSince filter the repositories listed in the catalog, if set.
+	PathPrefix    string
+	ModifiedSince time.Time
+	// ArtifactType filters the tags listed in the GitLab API by the artifact type of their manifest, if set.
+	ArtifactType string
+	// Labels filters the repositories listed in the catalog and the GitLab API by their labels, if set.
+	Labels []RepositoryLabelMatch
 }
 
 // RepositoryReader is the interface that defines read operations for a repository store.
 type RepositoryReader interface {
 	FindAll(ctx context.Context) (models.Repositories, error)
 	FindAllPaginated(ctx context.Context, filters FilterParams) (models.Repositories, error)
+	FindAllAfterID(ctx context.Context, id int64, limit int) (models.Repositories, error)
+	FindByID(ctx context.Context, namespaceID, id int64) (*models.Repository, error)
 	FindByPath(ctx context.Context, path string) (*models.Repository, error)
+	FindByBlob(ctx context.Context, d digest.Digest, pathPrefix string, excludedNamespaces []string) (*models.Repository, error)
 	FindDescendantsOf(ctx context.Context, id int64) (models.Repositories, error)
 	FindAncestorsOf(ctx context.Context, id int64) (models.Repositories, error)
 	FindSiblingsOf(ctx context.Context, id int64) (models.Repositories, error)
 	Count(ctx context.Context) (int, error)
 	CountAfterPath(ctx context.Context, path string) (int, error)
+	HasAfterPath(ctx context.Context, filters FilterParams) (bool, error)
 	CountPathSubRepositories(ctx context.Context, topLevelNamespaceID int64, path string) (int, error)
+	CountPaginated(ctx context.Context, filters FilterParams) (int, error)
+	CountRepositoriesWithTagsForPath(ctx context.Context, r *models.Repository, filters FilterParams) (int, error)
+	TagsCount(ctx context.Context, r *models.Repository, filters FilterParams) (int, error)
 	Manifests(ctx context.Context, r *models.Repository) (models.Manifests, error)
 	Tags(ctx context.Context, r *models.Repository) (models.Tags, error)
 	TagsPaginated(ctx context.Context, r *models.Repository, filters FilterParams) (models.Tags, error)
 	HasTagsAfterName(ctx context.Context, r *models.Repository, filters FilterParams) (bool, error)
 	HasTagsBeforeName(ctx context.Context, r *models.Repository, filters FilterParams) (bool, error)
 	ManifestTags(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Tags, error)
+	ManifestReferrers(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Manifests, error)
+	ManifestTagSchemaReferrers(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Manifests, error)
 	FindManifestByDigest(ctx context.Context, r *models.Repository, d digest.Digest) (*models.Manifest, error)
 	FindManifestByTagName(ctx context.Context, r *models.Repository, tagName string) (*models.Manifest, error)
 	FindTagByName(ctx context.Context, r *models.Repository, name string) (*models.Tag, error)
+	FindTagsByNames(ctx context.Context, r *models.Repository, names []string) (models.Tags, error)
+	FindTagsByNameRegex(ctx context.Context, r *models.Repository, regex string, limit int) (models.Tags, error)
 	Blobs(ctx context.Context, r *models.Repository) (models.Blobs, error)
 	FindBlob(ctx context.Context, r *models.Repository, d digest.Digest) (*models.Blob, error)
 	ExistsBlob(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error)
 	Size(ctx context.Context, r *models.Repository) (int64, error)
 	SizeWithDescendants(ctx context.Context, r *models.Repository) (int64, error)
 	EstimatedSizeWithDescendants(ctx context.Context, r *models.Repository) (int64, error)
+	SizeBreakdown(ctx context.Context, r *models.Repository, limit int) (*models.RepositorySizeBreakdown, error)
 	TagsDetailPaginated(ctx context.Context, r *models.Repository, filters FilterParams) ([]*models.TagDetail, error)
 	FindPagingatedRepositoriesForPath(ctx context.Context, r *models.Repository, filters FilterParams) (models.Repositories, error)
+	FindPaginatedSummariesForPath(ctx context.Context, r *models.Repository, filters FilterParams) ([]*models.RepositorySummary, error)
 }
 
 // RepositoryWriter is the interface that defines write operations for a repository store.
@@ -92,7 +115,10 @@ type RepositoryWriter interface {
 	Update(ctx context.Context, r *models.Repository) error
 	LinkBlob(ctx context.Context, r *models.Repository, d digest.Digest) error
 	UnlinkBlob(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error)
+	LockTagByName(ctx context.Context, r *models.Repository, name string) (*models.Tag, error)
+	LockManifestTags(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Tags, error)
 	DeleteTagByName(ctx context.Context, r *models.Repository, name string) (bool, error)
+	DeleteTagsByNames(ctx context.Context, r *models.Repository, names []string) ([]string, error)
 	DeleteManifest(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error)
 	RenamePathForSubRepositories(ctx context.Context, topLevelNamespaceID int64, oldPath, newPath string) error
 	Rename(ctx context.Context, r *models.Repository, newPath, newName string) error
@@ -199,6 +225,8 @@ type RepositoryCache interface {
 	Set(ctx context.Context, repo *models.Repository)
 	InvalidateSize(ctx context.Context, repo *models.Repository)
 
+	GetSizeWithDescendants(ctx context.Context, r *models.Repository) (int64, bool)
+	SetSizeWithDescendants(ctx context.Context, r *models.Repository, size int64)
 	SizeWithDescendantsTimedOut(ctx context.Context, r *models.Repository)
 	HasSizeWithDescendantsTimedOut(ctx context.Context, r *models.Repository) bool
 }
@@ -213,10 +241,14 @@ func NewNoOpRepositoryCache() *noOpRepositoryCache {
 	return &noOpRepositoryCache{}
 }
 
-func (n *noOpRepositoryCache) Get(context.Context, string) *models.Repository                  { return nil }
-func (n *noOpRepositoryCache) Set(context.Context, *models.Repository)                         {}
-func (n *noOpRepositoryCache) InvalidateSize(context.Context, *models.Repository)              {}
-func (n *noOpRepositoryCache) SizeWithDescendantsTimedOut(context.Context, *models.Repository) {}
+func (n *noOpRepositoryCache) Get(context.Context, string) *models.Repository     { return nil }
+func (n *noOpRepositoryCache) Set(context.Context, *models.Repository)            {}
+func (n *noOpRepositoryCache) InvalidateSize(context.Context, *models.Repository) {}
+func (n *noOpRepositoryCache) GetSizeWithDescendants(context.Context, *models.Repository) (int64, bool) {
+	return 0, false
+}
+func (n *noOpRepositoryCache) SetSizeWithDescendants(context.Context, *models.Repository, int64) {}
+func (n *noOpRepositoryCache) SizeWithDescendantsTimedOut(context.Context, *models.Repository)   {}
 func (n *noOpRepositoryCache) HasSizeWithDescendantsTimedOut(context.Context, *models.Repository) bool {
 	return false
 }
@@ -253,6 +285,16 @@ func (c *singleRepositoryCache) InvalidateSize(_ context.Context, r *models.Repo
 	}
 }
 
+// GetSizeWithDescendants is a noop. We're phasing out the singleRepositoryCache cache implementation in favor of the
+// centralRepositoryCache one, and the only consumer of the related functionality (size recalculation) requires the
+// latter.
+func (c *singleRepositoryCache) GetSizeWithDescendants(context.Context, *models.Repository) (int64, bool) {
+	return 0, false
+}
+
+// SetSizeWithDescendants is a noop. See GetSizeWithDescendants.
+func (c *singleRepositoryCache) SetSizeWithDescendants(context.Context, *models.Repository, int64) {}
+
 // SizeWithDescendantsTimedOut is a noop. We're phasing out the singleRepositoryCache cache implementation in favor of
 // the centralRepositoryCache one, and the only place where we'll be making use of the related functionality (estimated
 // size), the GitLab V1 API repositories handler, is explicitly making use of the latter.
@@ -295,6 +337,13 @@ func (c *centralRepositoryCache) sizeWithDescendantsTimedOutKey(path string) str
 	return fmt.Sprintf("%s:swd-timeout", c.key(path))
 }
 
+// sizeWithDescendantsKey generates a valid Redis key string for the last known size with descendants of a given
+// repository object. This is stored as a separate key instead of being embedded in the repository struct because
+// repository objects are often cached from freshly read database records, which would discard it.
+func (c *centralRepositoryCache) sizeWithDescendantsKey(path string) string {
+	return fmt.Sprintf("%s:swd-size", c.key(path))
+}
+
 // Get implements RepositoryCache.
 func (c *centralRepositoryCache) Get(ctx context.Context, path string) *models.Repository {
 	l := log.GetLogger(log.WithContext(ctx))
@@ -375,6 +424,44 @@ func (c *centralRepositoryCache) HasSizeWithDescendantsTimedOut(ctx context.Cont
 	return true
 }
 
+// GetSizeWithDescendants returns the last known size with descendants of repository r, as recorded by
+// SetSizeWithDescendants. The second return value is false if there is no such record.
+func (c *centralRepositoryCache) GetSizeWithDescendants(ctx context.Context, r *models.Repository) (int64, bool) {
+	getCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	tmp, err := c.cache.Get(getCtx, c.sizeWithDescendantsKey(r.Path))
+	if err != nil {
+		// a wrapped redis.Nil is returned when the key is not found in Redis
+		if !errors.Is(err, redis.Nil) {
+			log.GetLogger(log.WithContext(ctx)).WithError(err).Error("failed to read size with descendants from cache")
+		}
+		return 0, false
+	}
+
+	s, ok := tmp.(string)
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to parse size with descendants from cache")
+		return 0, false
+	}
+
+	return size, true
+}
+
+// SetSizeWithDescendants records the size with descendants of repository r.
+func (c *centralRepositoryCache) SetSizeWithDescendants(ctx context.Context, r *models.Repository, size int64) {
+	setCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+	defer cancel()
+
+	if err := c.cache.Set(setCtx, c.sizeWithDescendantsKey(r.Path), strconv.FormatInt(size, 10)); err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to write size with descendants to cache")
+	}
+}
+
 // InvalidateSize implements RepositoryCache.
 func (c *centralRepositoryCache) InvalidateSize(ctx context.Context, r *models.Repository) {
 	inValCtx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
@@ -420,13 +507,36 @@ func scanFullRepositories(rows *sql.Rows) (models.Repositories, error) {
 	return rr, nil
 }
 
+// FindByID finds a repository by ID, within the top-level namespace with the given ID.
+func (s *repositoryStore) FindByID(ctx context.Context, namespaceID, id int64) (*models.Repository, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_find_by_id")()
+	q := `SELECT
+			id,
+			top_level_namespace_id,
+			name,
+			path,
+			parent_id,
+			created_at,
+			updated_at
+		FROM
+			repositories
+		WHERE
+			top_level_namespace_id = $1
+			AND id = $2
+			AND deleted_at IS NULL`
+
+	row := s.db.QueryRowContext(ctx, q, namespaceID, id)
+
+	return scanFullRepository(row)
+}
+
 // FindByPath finds a repository by path.
 func (s *repositoryStore) FindByPath(ctx context.Context, path string) (*models.Repository, error) {
 	if cached := s.cache.Get(ctx, path); cached != nil {
 		return cached, nil
 	}
 
-	defer metrics.InstrumentQuery("repository_find_by_path")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_by_path")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -453,9 +563,71 @@ func (s *repositoryStore) FindByPath(ctx context.Context, path string) (*models.
 	return r, nil
 }
 
+// FindByBlob finds a repository that the blob with digest d is linked to, using the global index of repository blobs.
+// If pathPrefix is not empty, only repositories whose path starts with it are considered, and the search is restricted
+// to the top-level namespace of the prefix. Repositories in any of the top-level namespaces named in
+// excludedNamespaces are not considered either. Returns nil if there is none. Which repository is found when there
+// are several is undefined.
+func (s *repositoryStore) FindByBlob(ctx context.Context, d digest.Digest, pathPrefix string, excludedNamespaces []string) (*models.Repository, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_find_by_blob")()
+	q := `SELECT
+			r.id,
+			r.top_level_namespace_id,
+			r.name,
+			r.path,
+			r.parent_id,
+			r.created_at,
+			r.updated_at
+		FROM
+			repository_blobs AS rb
+			JOIN repositories AS r ON r.top_level_namespace_id = rb.top_level_namespace_id
+				AND r.id = rb.repository_id
+		WHERE
+			rb.blob_digest = decode($1, 'hex')
+			AND r.deleted_at IS NULL`
+
+	dgst, err := NewDigest(d)
+	if err != nil {
+		return nil, err
+	}
+	args := []any{dgst}
+
+	if pathPrefix != "" {
+		ns, _, _ := strings.Cut(pathPrefix, "/")
+		q += `
+			AND rb.top_level_namespace_id = (
+				SELECT
+					id
+				FROM
+					top_level_namespaces
+				WHERE
+					name = $2)
+			AND r.path LIKE $3`
+		args = append(args, ns, sqlPrefixMatch(pathPrefix))
+	}
+	if len(excludedNamespaces) > 0 {
+		placeholders, nsArgs := tagNamesArgs(excludedNamespaces, len(args)+1)
+		q += fmt.Sprintf(`
+			AND rb.top_level_namespace_id NOT IN (
+				SELECT
+					id
+				FROM
+					top_level_namespaces
+				WHERE
+					name IN (%s))`, placeholders)
+		args = append(args, nsArgs...)
+	}
+	q += `
+		LIMIT 1`
+
+	row := s.db.QueryRowContext(ctx, q, args...)
+
+	return scanFullRepository(row)
+}
+
 // FindAll finds all repositories.
 func (s *repositoryStore) FindAll(ctx context.Context) (models.Repositories, error) {
-	defer metrics.InstrumentQuery("repository_find_all")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_all")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -479,9 +651,10 @@ func (s *repositoryStore) FindAll(ctx context.Context) (models.Repositories, err
 // not have at least a manifest) are ignored. Also, even if there is no repository with a path of `filters.LastEntry`, the returned
 // repositories will always be those with a path lexicographically after `filters.LastEntry`. Finally, repositories are
 // lexicographically sorted. These constraints exists to preserve the existing API behavior (when doing a filesystem
-// walk based pagination).
+// walk based pagination). Optionally, repositories can be filtered by path prefix, modification time and labels using
+// `filters.PathPrefix`, `filters.ModifiedSince` and `filters.Labels`, respectively (see catalogFilters).
 func (s *repositoryStore) FindAllPaginated(ctx context.Context, filters FilterParams) (models.Repositories, error) {
-	defer metrics.InstrumentQuery("repository_find_all_paginated")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_all_paginated")()
 	q := `SELECT
 			r.id,
 			r.top_level_namespace_id,
@@ -500,11 +673,18 @@ func (s *repositoryStore) FindAllPaginated(ctx context.Context, filters FilterPa
 				WHERE
 					m.top_level_namespace_id = r.top_level_namespace_id
 					AND m.repository_id = r.id)
-			AND r.path > $1
+			AND r.path > $1`
+
+	args := []any{filters.LastEntry}
+	conds, condArgs := catalogFilters(filters, len(args)+1)
+	args = append(args, condArgs...)
+	q += conds + fmt.Sprintf(`
 		ORDER BY
 			r.path
-		LIMIT $2`
-	rows, err := s.db.QueryContext(ctx, q, filters.LastEntry, filters.MaxEntries)
+		LIMIT $%d`, len(args)+1)
+	args = append(args, filters.MaxEntries)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("finding repositories with pagination: %w", err)
 	}
@@ -512,9 +692,82 @@ func (s *repositoryStore) FindAllPaginated(ctx context.Context, filters FilterPa
 	return scanFullRepositories(rows)
 }
 
+// catalogFilters builds the SQL conditions to filter catalog repositories by path prefix, modification time and labels,
+// as set in `filters.PathPrefix`, `filters.ModifiedSince` and `filters.Labels`, for a query on the repositories table
+// aliased as `r`. Placeholders are numbered starting at `argPos`. An empty string and no arguments are returned if no
+// filter is set.
+//
+// A repository is considered modified since a given time if it was created or updated (e.g. renamed) since then, or if
+// any of its manifests was pushed or any of its tags was created or updated since then. Deletions are not accounted
+// for, as these leave no trace behind.
+func catalogFilters(filters FilterParams, argPos int) (string, []any) {
+	var (
+		conds string
+		args  []any
+	)
+	if filters.PathPrefix != "" {
+		conds += fmt.Sprintf("\n\t\t\tAND r.path LIKE $%d", argPos+len(args))
+		args = append(args, sqlPrefixMatch(filters.PathPrefix))
+	}
+	if !filters.ModifiedSince.IsZero() {
+		conds += fmt.Sprintf(`
+			AND (r.created_at >= $%[1]d
+				OR r.updated_at >= $%[1]d
+				OR EXISTS (
+					SELECT
+					FROM
+						manifests AS m
+					WHERE
+						m.top_level_namespace_id = r.top_level_namespace_id
+						AND m.repository_id = r.id
+						AND m.created_at >= $%[1]d)
+				OR EXISTS (
+					SELECT
+					FROM
+						tags AS t
+					WHERE
+						t.top_level_namespace_id = r.top_level_namespace_id
+						AND t.repository_id = r.id
+						AND GREATEST(t.created_at, t.updated_at) >= $%[1]d))`, argPos+len(args))
+		args = append(args, filters.ModifiedSince)
+	}
+	labelConds, labelArgs := repositoryLabelFilters(filters, argPos+len(args))
+	conds += labelConds
+	args = append(args, labelArgs...)
+
+	return conds, args
+}
+
+// FindAllAfterID finds up to limit repositories with an ID greater than id, sorted by ID. This allows iterating over all
+// repositories in batches, using the ID of the last repository of each batch as checkpoint for the next one.
+func (s *repositoryStore) FindAllAfterID(ctx context.Context, id int64, limit int) (models.Repositories, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_find_all_after_id")()
+	q := `SELECT
+			id,
+			top_level_namespace_id,
+			name,
+			path,
+			parent_id,
+			created_at,
+			updated_at
+		FROM
+			repositories
+		WHERE
+			id > $1
+		ORDER BY
+			id
+		LIMIT $2`
+	rows, err := s.db.QueryContext(ctx, q, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding repositories after ID: %w", err)
+	}
+
+	return scanFullRepositories(rows)
+}
+
 // FindDescendantsOf finds all descendants of a given repository.
 func (s *repositoryStore) FindDescendantsOf(ctx context.Context, id int64) (models.Repositories, error) {
-	defer metrics.InstrumentQuery("repository_find_descendants_of")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_descendants_of")()
 	q := `WITH RECURSIVE descendants AS (
 			SELECT
 				id,
@@ -558,7 +811,7 @@ func (s *repositoryStore) FindDescendantsOf(ctx context.Context, id int64) (mode
 
 // FindAncestorsOf finds all ancestors of a given repository.
 func (s *repositoryStore) FindAncestorsOf(ctx context.Context, id int64) (models.Repositories, error) {
-	defer metrics.InstrumentQuery("repository_find_ancestors_of")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_ancestors_of")()
 	q := `WITH RECURSIVE ancestors AS (
 			SELECT
 				id,
@@ -602,7 +855,7 @@ func (s *repositoryStore) FindAncestorsOf(ctx context.Context, id int64) (models
 
 // FindSiblingsOf finds all siblings of a given repository.
 func (s *repositoryStore) FindSiblingsOf(ctx context.Context, id int64) (models.Repositories, error) {
-	defer metrics.InstrumentQuery("repository_find_siblings_of")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_siblings_of")()
 	q := `SELECT
 			siblings.id,
 			siblings.top_level_namespace_id,
@@ -628,7 +881,7 @@ func (s *repositoryStore) FindSiblingsOf(ctx context.Context, id int64) (models.
 
 // Tags finds all tags of a given repository.
 func (s *repositoryStore) Tags(ctx context.Context, r *models.Repository) (models.Tags, error) {
-	defer metrics.InstrumentQuery("repository_tags")()
+	defer metrics.InstrumentQuery(ctx, "repository_tags")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -656,7 +909,7 @@ func (s *repositoryStore) Tags(ctx context.Context, r *models.Repository) (model
 // `filters.LastEntry`. Finally, tags are lexicographically sorted. These constraints exists to preserve the existing API behaviour
 // (when doing a filesystem walk based pagination).
 func (s *repositoryStore) TagsPaginated(ctx context.Context, r *models.Repository, filters FilterParams) (models.Tags, error) {
-	defer metrics.InstrumentQuery("repository_tags_paginated")()
+	defer metrics.InstrumentQuery(ctx, "repository_tags_paginated")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -690,7 +943,8 @@ func scanFullTagsDetail(rows *sql.Rows) ([]*models.TagDetail, error) {
 		var dgst Digest
 		var cfgDgst sql.NullString
 		t := new(models.TagDetail)
-		if err := rows.Scan(&t.Name, &dgst, &cfgDgst, &t.MediaType, &t.Size, &t.CreatedAt, &t.UpdatedAt, &t.PublishedAt); err != nil {
+		if err := rows.Scan(&t.Name, &dgst, &cfgDgst, &t.MediaType, &t.ArtifactType, &t.Size, &t.CreatedAt, &t.UpdatedAt, &t.PublishedAt, &t.LastPulledAt,
+			&t.ChartName, &t.ChartVersion); err != nil {
 			return nil, fmt.Errorf("scanning tag details: %w", err)
 		}
 
@@ -731,10 +985,62 @@ func sortTagsDesc(tags []*models.TagDetail) {
 // metacharacters supported in Postgres `LIKE` expressions.
 // See https://www.postgresql.org/docs/current/functions-matching.html#FUNCTIONS-LIKE for more details.
 func sqlPartialMatch(value string) string {
+	return fmt.Sprintf("%%%s%%", sqlEscapeLike(value))
+}
+
+// sqlPrefixMatch builds a string that can be passed as value for a SQL `LIKE` expression to match values starting with
+// the input value. The `_` and `%` metacharacters are escaped as in sqlPartialMatch.
+func sqlPrefixMatch(value string) string {
+	return fmt.Sprintf("%s%%", sqlEscapeLike(value))
+}
+
+func sqlEscapeLike(value string) string {
 	value = strings.ReplaceAll(value, "_", `\_`)
-	value = strings.ReplaceAll(value, "%", `\%`)
+	return strings.ReplaceAll(value, "%", `\%`)
+}
 
-	return fmt.Sprintf("%%%s%%", value)
+// tagNameFilters builds the SQL conditions to filter tags by name prefix and/or POSIX regular expression, as set in
+// `filters.NamePrefix` and `filters.NameRegex`, for the given name column. Placeholders are numbered starting at
+// `argPos`. An empty string and no arguments are returned if neither filter is set.
+func tagNameFilters(column string, filters FilterParams, argPos int) (string, []any) {
+	var (
+		conds string
+		args  []any
+	)
+	if filters.NamePrefix != "" {
+		conds += fmt.Sprintf("\n\t\t\tAND %s LIKE $%d", column, argPos+len(args))
+		args = append(args, sqlPrefixMatch(filters.NamePrefix))
+	}
+	if filters.NameRegex != "" {
+		conds += fmt.Sprintf("\n\t\t\tAND %s ~ $%d", column, argPos+len(args))
+		args = append(args, filters.NameRegex)
+	}
+
+	return conds, args
+}
+
+// tagArtifactTypeFilter builds the SQL condition to filter tags by the artifact type of their manifest, as set in
+// `filters.ArtifactType`, for the given manifest ID column. The placeholder is numbered `argPos`. An empty string and no
+// arguments are returned if the filter is not set. The enclosing query must bind the top-level namespace ID and the
+// repository ID to `$1` and `$2`, respectively.
+func tagArtifactTypeFilter(column string, filters FilterParams, argPos int) (string, []any) {
+	if filters.ArtifactType == "" {
+		return "", nil
+	}
+
+	cond := fmt.Sprintf(`
+			AND %s IN (
+				SELECT
+					am.id
+				FROM
+					manifests AS am
+					JOIN media_types AS amt ON amt.id = am.artifact_media_type_id
+				WHERE
+					am.top_level_namespace_id = $1
+					AND am.repository_id = $2
+					AND amt.media_type = $%d)`, column, argPos)
+
+	return cond, []any{filters.ArtifactType}
 }
 
 // TagsDetailPaginated finds up to `filters.MaxEntries` tags of a given repository with name lexicographically after `filters.LastEntry`. This is
@@ -742,9 +1048,11 @@ func sqlPartialMatch(value string) string {
 // Even if there is no tag with a name of `filters.LastEntry`, the returned tags will always be those with a path lexicographically
 // after `filters.LastEntry`. Tags are lexicographically sorted.
 // Optionally, it is possible to pass a string to be used as a  partial match filter for tag names using `filters.Name`.
-// The search is not filtered if this value is an empty string.
+// The search is not filtered if this value is an empty string. Similarly, tags can be filtered by name prefix and POSIX
+// regular expression using `filters.NamePrefix` and `filters.NameRegex`, respectively, and by manifest artifact type
+// using `filters.ArtifactType`.
 func (s *repositoryStore) TagsDetailPaginated(ctx context.Context, r *models.Repository, filters FilterParams) ([]*models.TagDetail, error) {
-	defer metrics.InstrumentQuery("repository_tags_detail_paginated")()
+	defer metrics.InstrumentQuery(ctx, "repository_tags_detail_paginated")()
 
 	q, args := tagsDetailPaginatedQuery(r, filters)
 	rows, err := s.db.QueryContext(ctx, q, args...)
@@ -755,26 +1063,34 @@ func (s *repositoryStore) TagsDetailPaginated(ctx context.Context, r *models.Rep
 	return scanFullTagsDetail(rows)
 }
 
+// tagNameFiltersMarker marks the position of the name filters in the query built by tagsDetailPaginatedQuery.
+const tagNameFiltersMarker = "/* tag name filters */"
+
 func tagsDetailPaginatedQuery(r *models.Repository, filters FilterParams) (string, []any) {
 	baseQuery := `SELECT
 			t.name,
 			encode(m.digest, 'hex') AS digest,
 			encode(m.configuration_blob_digest, 'hex') AS config_digest,
 			mt.media_type,
+			mta.media_type AS artifact_type,
 			m.total_size,
 			t.created_at,
 			t.updated_at,
-			GREATEST(t.created_at, t.updated_at) as published_at
+			GREATEST(t.created_at, t.updated_at) as published_at,
+			GREATEST(t.last_pulled_at, m.last_pulled_at) AS last_pulled_at,
+			m.chart_name,
+			m.chart_version
 		FROM
 			tags AS t
 			JOIN manifests AS m ON m.top_level_namespace_id = t.top_level_namespace_id
 				AND m.repository_id = t.repository_id
 				AND m.id = t.manifest_id
 			JOIN media_types AS mt ON mt.id = m.media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
 		WHERE
 			t.top_level_namespace_id = $1
 			AND t.repository_id = $2
-		  	AND t.name LIKE $3
+		  	AND t.name LIKE $3` + tagNameFiltersMarker + `
 			%s`
 
 	var (
@@ -812,6 +1128,14 @@ func tagsDetailPaginatedQuery(r *models.Repository, filters FilterParams) (strin
 
 	args = append(args, subArgs...)
 
+	// The placeholders of the name filters are numbered after all others, whose number depends on the pagination
+	// markers, so these can only be added once the rest of the query is built.
+	nameFilters, nameArgs := tagNameFilters("t.name", filters, len(args)+1)
+	args = append(args, nameArgs...)
+	typeFilter, typeArgs := tagArtifactTypeFilter("t.manifest_id", filters, len(args)+1)
+	args = append(args, typeArgs...)
+	q = strings.Replace(q, tagNameFiltersMarker, nameFilters+typeFilter, 1)
+
 	return q, args
 }
 
@@ -942,7 +1266,7 @@ func formatTagFilterWithPublishedAtWithoutName(comparisonSign, sortOrder SortOrd
 // pagination). Optionally, it is possible to pass a string to be used as a partial match filter for tag names using `filters.Name`.
 // The search is not filtered if this value is an empty string.
 func (s *repositoryStore) HasTagsAfterName(ctx context.Context, r *models.Repository, filters FilterParams) (bool, error) {
-	defer metrics.InstrumentQuery("repository_tags_count_after_name")()
+	defer metrics.InstrumentQuery(ctx, "repository_tags_count_after_name")()
 	q := `SELECT
 			1
 		FROM
@@ -967,6 +1291,13 @@ func (s *repositoryStore) HasTagsAfterName(ctx context.Context, r *models.Reposi
 		args = append(args, filters.PublishedAt)
 	}
 
+	nameFilters, nameArgs := tagNameFilters("name", filters, len(args)+1)
+	q += nameFilters
+	args = append(args, nameArgs...)
+	typeFilter, typeArgs := tagArtifactTypeFilter("manifest_id", filters, len(args)+1)
+	q += typeFilter
+	args = append(args, typeArgs...)
+
 	q = fmt.Sprintf(q, comparison)
 
 	var count int
@@ -989,7 +1320,7 @@ func (s *repositoryStore) HasTagsBeforeName(ctx context.Context, r *models.Repos
 		return false, nil
 	}
 
-	defer metrics.InstrumentQuery("repository_tags_count_before_name")()
+	defer metrics.InstrumentQuery(ctx, "repository_tags_count_before_name")()
 
 	q := `SELECT
 			1
@@ -1015,6 +1346,13 @@ func (s *repositoryStore) HasTagsBeforeName(ctx context.Context, r *models.Repos
 		args = append(args, filters.PublishedAt)
 	}
 
+	nameFilters, nameArgs := tagNameFilters("name", filters, len(args)+1)
+	q += nameFilters
+	args = append(args, nameArgs...)
+	typeFilter, typeArgs := tagArtifactTypeFilter("manifest_id", filters, len(args)+1)
+	q += typeFilter
+	args = append(args, typeArgs...)
+
 	q = fmt.Sprintf(q, comparison)
 	var count int
 	if err := s.db.QueryRowContext(ctx, q, args...).Scan(&count); err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -1026,7 +1364,7 @@ func (s *repositoryStore) HasTagsBeforeName(ctx context.Context, r *models.Repos
 
 // ManifestTags finds all tags of a given repository manifest.
 func (s *repositoryStore) ManifestTags(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Tags, error) {
-	defer metrics.InstrumentQuery("repository_manifest_tags")()
+	defer metrics.InstrumentQuery(ctx, "repository_manifest_tags")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -1052,7 +1390,7 @@ func (s *repositoryStore) ManifestTags(ctx context.Context, r *models.Repository
 
 // Count counts all repositories.
 func (s *repositoryStore) Count(ctx context.Context) (int, error) {
-	defer metrics.InstrumentQuery("repository_count")()
+	defer metrics.InstrumentQuery(ctx, "repository_count")()
 	q := "SELECT COUNT(*) FROM repositories"
 	var count int
 
@@ -1069,7 +1407,7 @@ func (s *repositoryStore) Count(ctx context.Context) (int, error) {
 // repositories will always be those with a path lexicographically after lastPath. These constraints exists to preserve
 // the existing API behavior (when doing a filesystem walk based pagination).
 func (s *repositoryStore) CountAfterPath(ctx context.Context, path string) (int, error) {
-	defer metrics.InstrumentQuery("repository_count_after_path")()
+	defer metrics.InstrumentQuery(ctx, "repository_count_after_path")()
 	q := `SELECT
 			COUNT(*)
 		FROM
@@ -1092,9 +1430,45 @@ func (s *repositoryStore) CountAfterPath(ctx context.Context, path string) (int,
 	return count, nil
 }
 
+// HasAfterPath returns whether there are repositories with path lexicographically after `filters.LastEntry`, matching
+// the same criteria as FindAllPaginated. This is used exclusively for the GET /v2/_catalog API route, to determine if
+// a next page exists.
+func (s *repositoryStore) HasAfterPath(ctx context.Context, filters FilterParams) (bool, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_has_after_path")()
+	q := `SELECT
+			1
+		FROM
+			repositories AS r
+		WHERE
+			EXISTS (
+				SELECT
+				FROM
+					manifests AS m
+				WHERE
+					m.top_level_namespace_id = r.top_level_namespace_id
+					AND m.repository_id = r.id)
+			AND r.path > $1`
+
+	args := []any{filters.LastEntry}
+	conds, condArgs := catalogFilters(filters, len(args)+1)
+	q += conds + `
+		LIMIT 1`
+	args = append(args, condArgs...)
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q, args...).Scan(&count); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("checking repositories lexicographically after path: %w", err)
+	}
+
+	return true, nil
+}
+
 // CountPathSubRepositories counts all sub repositories of a repository path (including the base repository).
 func (s *repositoryStore) CountPathSubRepositories(ctx context.Context, topLevelNamespaceID int64, path string) (int, error) {
-	defer metrics.InstrumentQuery("repository_count_sub_repositories")()
+	defer metrics.InstrumentQuery(ctx, "repository_count_sub_repositories")()
 
 	q := "SELECT COUNT(*) FROM repositories WHERE top_level_namespace_id = $1 AND (path = $2 OR path LIKE $3)"
 	var count int
@@ -1105,9 +1479,102 @@ func (s *repositoryStore) CountPathSubRepositories(ctx context.Context, topLevel
 	return count, nil
 }
 
+// CountPaginated counts all repositories listed by FindAllPaginated across all pages, i.e., all non-empty repositories
+// matching the path prefix, modification time and label filters (see catalogFilters), regardless of `filters.LastEntry`. This
+// is used exclusively for the total count headers of the GET /v2/_catalog API route.
+func (s *repositoryStore) CountPaginated(ctx context.Context, filters FilterParams) (int, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_count_paginated")()
+	q := `SELECT
+			COUNT(*)
+		FROM
+			repositories AS r
+		WHERE
+			EXISTS (
+				SELECT
+				FROM
+					manifests AS m
+				WHERE
+					m.top_level_namespace_id = r.top_level_namespace_id
+					AND m.repository_id = r.id)`
+
+	conds, args := catalogFilters(filters, 1)
+	q += conds
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q, args...).Scan(&count); err != nil {
+		return count, fmt.Errorf("counting repositories with pagination: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountRepositoriesWithTagsForPath counts all repositories with at least one tag under the path of the requested
+// repository, including the repository itself, matching the label filters in `filters.Labels`, if any. These are the
+// repositories listed across all pages by FindPagingatedRepositoriesForPath and FindPaginatedSummariesForPath. The
+// requested repository does not need to exist, but its path and namespace ID must be set.
+func (s *repositoryStore) CountRepositoriesWithTagsForPath(ctx context.Context, r *models.Repository, filters FilterParams) (int, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_count_repositories_with_tags_for_path")()
+	q := `SELECT
+			COUNT(*)
+		FROM
+			repositories AS r
+		WHERE
+			r.top_level_namespace_id = $1
+			AND (r.path = $2 OR r.path LIKE $3)
+			AND EXISTS (
+				SELECT
+				FROM
+					tags AS t
+				WHERE
+					t.top_level_namespace_id = r.top_level_namespace_id
+					AND t.repository_id = r.id)`
+
+	args := []any{r.NamespaceID, r.Path, r.Path + "/%"}
+	conds, labelArgs := repositoryLabelFilters(filters, len(args)+1)
+	q += conds
+	args = append(args, labelArgs...)
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q, args...).Scan(&count); err != nil {
+		return count, fmt.Errorf("counting repositories with tags for path: %w", err)
+	}
+
+	return count, nil
+}
+
+// TagsCount counts all tags of a given repository matching the name filters (`filters.Name`, `filters.NamePrefix` and
+// `filters.NameRegex`) and the artifact type filter (`filters.ArtifactType`), if set. Pagination markers are ignored, so
+// that the result is the total number of tags listed across all pages by TagsPaginated and TagsDetailPaginated.
+func (s *repositoryStore) TagsCount(ctx context.Context, r *models.Repository, filters FilterParams) (int, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_tags_count")()
+	q := `SELECT
+			COUNT(*)
+		FROM
+			tags
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name LIKE $3`
+
+	args := []any{r.NamespaceID, r.ID, sqlPartialMatch(filters.Name)}
+	nameFilters, nameArgs := tagNameFilters("name", filters, len(args)+1)
+	q += nameFilters
+	args = append(args, nameArgs...)
+	typeFilter, typeArgs := tagArtifactTypeFilter("manifest_id", filters, len(args)+1)
+	q += typeFilter
+	args = append(args, typeArgs...)
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, q, args...).Scan(&count); err != nil {
+		return count, fmt.Errorf("counting tags: %w", err)
+	}
+
+	return count, nil
+}
+
 // Manifests finds all manifests associated with a repository.
 func (s *repositoryStore) Manifests(ctx context.Context, r *models.Repository) (models.Manifests, error) {
-	defer metrics.InstrumentQuery("repository_manifests")()
+	defer metrics.InstrumentQuery(ctx, "repository_manifests")()
 	q := `SELECT
 			m.id,
 			m.top_level_namespace_id,
@@ -1123,11 +1590,15 @@ func (s *repositoryStore) Manifests(ctx context.Context, r *models.Repository) (
 			m.non_conformant,
 			m.non_distributable_layers,
 			m.subject_id,
+			mta.media_type as artifact_media_type,
+			m.chart_name,
+			m.chart_version,
 			m.created_at
 		FROM
 			manifests AS m
 			JOIN media_types AS mt ON mt.id = m.media_type_id
 			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
 		WHERE
 			m.top_level_namespace_id = $1
 			AND m.repository_id = $2
@@ -1141,9 +1612,95 @@ func (s *repositoryStore) Manifests(ctx context.Context, r *models.Repository) (
 	return scanFullManifests(rows)
 }
 
+// ManifestReferrers finds all manifests that reference a given manifest as their subject within a repository.
+func (s *repositoryStore) ManifestReferrers(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Manifests, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_manifest_referrers")()
+	q := `SELECT
+			m.id,
+			m.top_level_namespace_id,
+			m.repository_id,
+			m.total_size,
+			m.schema_version,
+			mt.media_type,
+			encode(m.digest, 'hex') as digest,
+			m.payload,
+			mtc.media_type as configuration_media_type,
+			encode(m.configuration_blob_digest, 'hex') as configuration_blob_digest,
+			m.configuration_payload,
+			m.non_conformant,
+			m.non_distributable_layers,
+			m.subject_id,
+			mta.media_type as artifact_media_type,
+			m.chart_name,
+			m.chart_version,
+			m.created_at
+		FROM
+			manifests AS m
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
+		WHERE
+			m.top_level_namespace_id = $1
+			AND m.repository_id = $2
+			AND m.subject_id = $3
+		ORDER BY m.id`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("finding manifest referrers: %w", err)
+	}
+
+	return scanFullManifests(rows)
+}
+
+// ManifestTagSchemaReferrers finds all manifests that were associated with a given manifest as referrers through the
+// referrers tag schema within a repository.
+func (s *repositoryStore) ManifestTagSchemaReferrers(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Manifests, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_manifest_tag_schema_referrers")()
+	q := `SELECT
+			m.id,
+			m.top_level_namespace_id,
+			m.repository_id,
+			m.total_size,
+			m.schema_version,
+			mt.media_type,
+			encode(m.digest, 'hex') as digest,
+			m.payload,
+			mtc.media_type as configuration_media_type,
+			encode(m.configuration_blob_digest, 'hex') as configuration_blob_digest,
+			m.configuration_payload,
+			m.non_conformant,
+			m.non_distributable_layers,
+			m.subject_id,
+			mta.media_type as artifact_media_type,
+			m.chart_name,
+			m.chart_version,
+			m.created_at
+		FROM
+			manifests AS m
+			JOIN tag_schema_referrers AS tsr ON tsr.top_level_namespace_id = m.top_level_namespace_id
+				AND tsr.repository_id = m.repository_id
+				AND tsr.referrer_id = m.id
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
+		WHERE
+			m.top_level_namespace_id = $1
+			AND m.repository_id = $2
+			AND tsr.subject_id = $3
+		ORDER BY m.id`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("finding manifest tag schema referrers: %w", err)
+	}
+
+	return scanFullManifests(rows)
+}
+
 // FindManifestByDigest finds a manifest by digest within a repository.
 func (s *repositoryStore) FindManifestByDigest(ctx context.Context, r *models.Repository, d digest.Digest) (*models.Manifest, error) {
-	defer metrics.InstrumentQuery("repository_find_manifest_by_digest")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_manifest_by_digest")()
 
 	dgst, err := NewDigest(d)
 	if err != nil {
@@ -1155,7 +1712,7 @@ func (s *repositoryStore) FindManifestByDigest(ctx context.Context, r *models.Re
 
 // FindManifestByTagName finds a manifest by tag name within a repository.
 func (s *repositoryStore) FindManifestByTagName(ctx context.Context, r *models.Repository, tagName string) (*models.Manifest, error) {
-	defer metrics.InstrumentQuery("repository_find_manifest_by_tag_name")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_manifest_by_tag_name")()
 	q := `SELECT
 			m.id,
 			m.top_level_namespace_id,
@@ -1171,11 +1728,15 @@ func (s *repositoryStore) FindManifestByTagName(ctx context.Context, r *models.R
 			m.non_conformant,
 			m.non_distributable_layers,
 			m.subject_id,
+			mta.media_type as artifact_media_type,
+			m.chart_name,
+			m.chart_version,
 			m.created_at
 		FROM
 			manifests AS m
 			JOIN media_types AS mt ON mt.id = m.media_type_id
 			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
 			JOIN tags AS t ON t.top_level_namespace_id = m.top_level_namespace_id
 				AND t.repository_id = m.repository_id
 				AND t.manifest_id = m.id
@@ -1191,7 +1752,7 @@ func (s *repositoryStore) FindManifestByTagName(ctx context.Context, r *models.R
 
 // Blobs finds all blobs associated with the repository.
 func (s *repositoryStore) Blobs(ctx context.Context, r *models.Repository) (models.Blobs, error) {
-	defer metrics.InstrumentQuery("repository_blobs")()
+	defer metrics.InstrumentQuery(ctx, "repository_blobs")()
 	q := `SELECT
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
@@ -1216,7 +1777,7 @@ func (s *repositoryStore) Blobs(ctx context.Context, r *models.Repository) (mode
 
 // FindBlob finds a blob by digest within a repository.
 func (s *repositoryStore) FindBlob(ctx context.Context, r *models.Repository, d digest.Digest) (*models.Blob, error) {
-	defer metrics.InstrumentQuery("repository_find_blob")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_blob")()
 	q := `SELECT
 			mt.media_type,
 			encode(b.digest, 'hex') as digest,
@@ -1242,7 +1803,7 @@ func (s *repositoryStore) FindBlob(ctx context.Context, r *models.Repository, d
 
 // ExistsBlob finds if a blob with a given digest exists within a repository.
 func (s *repositoryStore) ExistsBlob(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error) {
-	defer metrics.InstrumentQuery("repository_exists_blob")()
+	defer metrics.InstrumentQuery(ctx, "repository_exists_blob")()
 	q := `SELECT
 			EXISTS (
 				SELECT
@@ -1270,7 +1831,7 @@ func (s *repositoryStore) ExistsBlob(ctx context.Context, r *models.Repository,
 
 // Create saves a new repository.
 func (s *repositoryStore) Create(ctx context.Context, r *models.Repository) error {
-	defer metrics.InstrumentQuery("repository_create")()
+	defer metrics.InstrumentQuery(ctx, "repository_create")()
 
 	q := `INSERT INTO repositories (top_level_namespace_id, name, path, parent_id)
 			VALUES ($1, $2, $3, $4)
@@ -1289,7 +1850,7 @@ func (s *repositoryStore) Create(ctx context.Context, r *models.Repository) erro
 
 // FindTagByName finds a tag by name within a repository.
 func (s *repositoryStore) FindTagByName(ctx context.Context, r *models.Repository, name string) (*models.Tag, error) {
-	defer metrics.InstrumentQuery("repository_find_tag_by_name")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_tag_by_name")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -1309,6 +1870,155 @@ func (s *repositoryStore) FindTagByName(ctx context.Context, r *models.Repositor
 	return scanFullTag(row)
 }
 
+// LockTagByName finds a tag by name within a repository and locks it for update until the end of the current
+// transaction, so that it can not be changed or deleted concurrently. Must be called within a transaction.
+func (s *repositoryStore) LockTagByName(ctx context.Context, r *models.Repository, name string) (*models.Tag, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_lock_tag_by_name")()
+	q := `SELECT
+			id,
+			top_level_namespace_id,
+			name,
+			repository_id,
+			manifest_id,
+			created_at,
+			updated_at
+		FROM
+			tags
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name = $3
+		FOR UPDATE`
+	row := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID, name)
+
+	return scanFullTag(row)
+}
+
+// LockManifestTags locks a repository manifest and all of its tags for update until the end of the current
+// transaction, and returns the tags. Locking the manifest prevents tags and referrers from being pointed to it
+// concurrently, as their foreign keys must share the lock. Must be called within a transaction.
+func (s *repositoryStore) LockManifestTags(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Tags, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_lock_manifest_tags")()
+	q := `SELECT
+			id
+		FROM
+			manifests
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND id = $3
+		FOR UPDATE`
+	var id int64
+	if err := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID, m.ID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Tags{}, nil
+		}
+		return nil, fmt.Errorf("locking manifest: %w", err)
+	}
+
+	q = `SELECT
+			id,
+			top_level_namespace_id,
+			name,
+			repository_id,
+			manifest_id,
+			created_at,
+			updated_at
+		FROM
+			tags
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND manifest_id = $3
+		FOR UPDATE`
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("locking tags: %w", err)
+	}
+
+	return scanFullTags(rows)
+}
+
+// FindTagsByNames finds all tags within a repository with any of the given names. Names for which no tag exists are
+// ignored. Tags are lexicographically sorted.
+func (s *repositoryStore) FindTagsByNames(ctx context.Context, r *models.Repository, names []string) (models.Tags, error) {
+	if len(names) == 0 {
+		return models.Tags{}, nil
+	}
+
+	defer metrics.InstrumentQuery(ctx, "repository_find_tags_by_names")()
+	q := `SELECT
+			id,
+			top_level_namespace_id,
+			name,
+			repository_id,
+			manifest_id,
+			created_at,
+			updated_at
+		FROM
+			tags
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name IN (%s)
+		ORDER BY
+			name`
+
+	placeholders, args := tagNamesArgs(names, 3)
+	q = fmt.Sprintf(q, placeholders)
+	args = append([]any{r.NamespaceID, r.ID}, args...)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("finding tags by names: %w", err)
+	}
+
+	return scanFullTags(rows)
+}
+
+// FindTagsByNameRegex finds up to limit tags within a repository with a name that matches the given POSIX regular
+// expression. Tags are lexicographically sorted.
+func (s *repositoryStore) FindTagsByNameRegex(ctx context.Context, r *models.Repository, regex string, limit int) (models.Tags, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_find_tags_by_name_regex")()
+	q := `SELECT
+			id,
+			top_level_namespace_id,
+			name,
+			repository_id,
+			manifest_id,
+			created_at,
+			updated_at
+		FROM
+			tags
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name ~ $3
+		ORDER BY
+			name
+		LIMIT $4`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, regex, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding tags by name regex: %w", err)
+	}
+
+	return scanFullTags(rows)
+}
+
+// tagNamesArgs builds a comma separated list of numbered placeholders, starting at argPos, and the corresponding list
+// of arguments for the given tag names, for use in a SQL `IN` expression.
+func tagNamesArgs(names []string, argPos int) (string, []any) {
+	placeholders := make([]string, 0, len(names))
+	args := make([]any, 0, len(names))
+	for i, name := range names {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", argPos+i))
+		args = append(args, name)
+	}
+
+	return strings.Join(placeholders, ","), args
+}
+
 // Size returns the deduplicated size of a repository. This is the sum of the size of all unique layers referenced by
 // at least one tagged (directly or indirectly) manifest. No error is returned if the repository does not exist. It is
 // the caller's responsibility to ensure it exists before calling this method and proceed accordingly if that matters.
@@ -1317,7 +2027,7 @@ func (s *repositoryStore) Size(ctx context.Context, r *models.Repository) (int64
 	if r.Size != nil {
 		return *r.Size, nil
 	}
-	defer metrics.InstrumentQuery("repository_size")()
+	defer metrics.InstrumentQuery(ctx, "repository_size")()
 
 	q := `SELECT
 			coalesce(sum(q.size), 0)
@@ -1369,7 +2079,7 @@ func (s *repositoryStore) Size(ctx context.Context, r *models.Repository) (int64
 // topLevelSizeWithDescendants is an optimization for SizeWithDescendants when the target repository is a top-level
 // repository. This allows using an optimized SQL query for this specific scenario.
 func (s *repositoryStore) topLevelSizeWithDescendants(ctx context.Context, r *models.Repository) (int64, error) {
-	defer metrics.InstrumentQuery("repository_size_with_descendants_top_level")()
+	defer metrics.InstrumentQuery(ctx, "repository_size_with_descendants_top_level")()
 
 	q := `SELECT
 			coalesce(sum(q.size), 0)
@@ -1428,7 +2138,7 @@ func (s *repositoryStore) topLevelSizeWithDescendants(ctx context.Context, r *mo
 // nonTopLevelSizeWithDescendants is an optimization for SizeWithDescendants when the target repository is not a
 // top-level repository. This allows using an optimized SQL query for this specific scenario.
 func (s *repositoryStore) nonTopLevelSizeWithDescendants(ctx context.Context, r *models.Repository) (int64, error) {
-	defer metrics.InstrumentQuery("repository_size_with_descendants")()
+	defer metrics.InstrumentQuery(ctx, "repository_size_with_descendants")()
 
 	q := `SELECT
 			coalesce(sum(q.size), 0)
@@ -1522,16 +2232,20 @@ func (s *repositoryStore) SizeWithDescendants(ctx context.Context, r *models.Rep
 			// flag query failure for target repository to avoid consecutive failures
 			s.cache.SizeWithDescendantsTimedOut(ctx, r)
 		}
+		return 0, err
 	}
 
-	return size, err
+	// record the last known size, so that drifts can be detected during size recalculation
+	s.cache.SetSizeWithDescendants(ctx, r, size)
+
+	return size, nil
 }
 
 // estimateTopLevelSizeWithDescendants is a simplified alternative to topLevelSizeWithDescendants which does not exclude
 // estimateTopLevelSizeWithDescendants is a significantly faster alternative to topLevelSizeWithDescendants which does
 // not exclude unreferenced layers. Therefore, the measured size should be considered an estimate.
 func (s *repositoryStore) estimateTopLevelSizeWithDescendants(ctx context.Context, r *models.Repository) (int64, error) {
-	defer metrics.InstrumentQuery("repository_size_with_descendants_top_level_estimate")()
+	defer metrics.InstrumentQuery(ctx, "repository_size_with_descendants_top_level_estimate")()
 
 	q := `SELECT
 			coalesce(sum(q.size), 0)
@@ -1564,6 +2278,147 @@ func (s *repositoryStore) EstimatedSizeWithDescendants(ctx context.Context, r *m
 	return s.estimateTopLevelSizeWithDescendants(ctx, r)
 }
 
+// SizeBreakdown returns a detailed view of the deduplicated size of a repository, where only artifacts referenced by at
+// least one tagged (directly or indirectly) manifest are taken into account. The list of largest images is limited to
+// `limit` entries. No error is returned if the repository does not exist. It is the caller's responsibility to ensure
+// it exists before calling this method and proceed accordingly if that matters.
+func (s *repositoryStore) SizeBreakdown(ctx context.Context, r *models.Repository, limit int) (*models.RepositorySizeBreakdown, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_size_breakdown")()
+
+	q := `WITH RECURSIVE cte AS (
+			SELECT
+				m.id AS manifest_id
+			FROM
+				manifests AS m
+			WHERE
+				m.top_level_namespace_id = $1
+				AND m.repository_id = $2
+				AND EXISTS (
+					SELECT
+					FROM
+						tags AS t
+					WHERE
+						t.top_level_namespace_id = m.top_level_namespace_id
+						AND t.repository_id = m.repository_id
+						AND t.manifest_id = m.id)
+				UNION
+				SELECT
+					mr.child_id AS manifest_id
+				FROM
+					manifest_references AS mr
+					JOIN cte ON mr.parent_id = cte.manifest_id
+				WHERE
+					mr.top_level_namespace_id = $1
+					AND mr.repository_id = $2
+		),
+		lyr AS (
+			SELECT DISTINCT ON (l.digest)
+				l.digest,
+				l.size
+			FROM
+				layers AS l
+				JOIN cte ON l.top_level_namespace_id = $1
+					AND l.repository_id = $2
+					AND l.manifest_id = cte.manifest_id
+		),
+		mnf AS (
+			SELECT
+				m.configuration_blob_digest,
+				octet_length(m.payload) AS size
+			FROM
+				manifests AS m
+				JOIN cte ON m.top_level_namespace_id = $1
+					AND m.repository_id = $2
+					AND m.id = cte.manifest_id
+		)
+		SELECT
+			(
+				SELECT
+					coalesce(sum(lyr.size), 0)
+				FROM
+					lyr) AS layers_size,
+			(
+				SELECT
+					coalesce(sum(lyr.size), 0)
+				FROM
+					lyr
+				WHERE
+					EXISTS (
+						SELECT
+						FROM
+							layers AS l
+						WHERE
+							l.top_level_namespace_id = $1
+							AND l.digest = lyr.digest
+							AND l.repository_id <> $2)) AS shared_layers_size,
+			(
+				SELECT
+					coalesce(sum(b.size), 0)
+				FROM
+					blobs AS b
+				WHERE
+					b.digest IN (
+						SELECT
+							mnf.configuration_blob_digest
+						FROM
+							mnf
+						WHERE
+							mnf.configuration_blob_digest IS NOT NULL)) AS configs_size,
+			(
+				SELECT
+					coalesce(sum(mnf.size), 0)
+				FROM
+					mnf) AS manifests_size`
+
+	b := &models.RepositorySizeBreakdown{}
+	if err := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID).Scan(&b.LayersSize, &b.SharedLayersSize, &b.ConfigsSize, &b.ManifestsSize); err != nil {
+		return nil, fmt.Errorf("calculating repository size breakdown: %w", err)
+	}
+
+	q = `SELECT
+			t.name,
+			encode(m.digest, 'hex') AS digest,
+			mt.media_type,
+			m.total_size
+		FROM
+			tags AS t
+			JOIN manifests AS m ON m.top_level_namespace_id = t.top_level_namespace_id
+				AND m.repository_id = t.repository_id
+				AND m.id = t.manifest_id
+			JOIN media_types AS mt ON mt.id = m.media_type_id
+		WHERE
+			t.top_level_namespace_id = $1
+			AND t.repository_id = $2
+		ORDER BY
+			m.total_size DESC,
+			t.name
+		LIMIT $3`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding largest images: %w", err)
+	}
+	defer rows.Close()
+
+	b.LargestImages = make([]*models.ImageSize, 0)
+	for rows.Next() {
+		var dgst Digest
+		i := new(models.ImageSize)
+		if err := rows.Scan(&i.Tag, &dgst, &i.MediaType, &i.Size); err != nil {
+			return nil, fmt.Errorf("scanning image size: %w", err)
+		}
+		if i.Digest, err = dgst.Parse(); err != nil {
+			return nil, err
+		}
+		b.LargestImages = append(b.LargestImages, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning image sizes: %w", err)
+	}
+
+	return b, nil
+}
+
 // CreateOrFind attempts to create a repository. If the repository already exists (same path) that record is loaded from
 // the database into r. This is similar to a FindByPath followed by a Create, but without being prone to race conditions
 // on write operations between the corresponding read (FindByPath) and write (Create) operations. Separate Find* and
@@ -1583,7 +2438,7 @@ func (s *repositoryStore) CreateOrFind(ctx context.Context, r *models.Repository
 		r.NamespaceID = n.ID
 	}
 
-	defer metrics.InstrumentQuery("repository_create_or_find")()
+	defer metrics.InstrumentQuery(ctx, "repository_create_or_find")()
 
 	// First, check if the repository already exists, this avoids incrementing the repositories.id sequence
 	// unnecessarily as we know that the target repository will already exist for all requests except the first.
@@ -1647,7 +2502,7 @@ func (s *repositoryStore) CreateByPath(ctx context.Context, path string, opts ..
 		return nil, fmt.Errorf("finding or creating namespace: %w", err)
 	}
 
-	defer metrics.InstrumentQuery("repository_create_by_path")()
+	defer metrics.InstrumentQuery(ctx, "repository_create_by_path")()
 	r := &models.Repository{NamespaceID: n.ID, Name: repositoryName(path), Path: path}
 
 	for _, opt := range opts {
@@ -1676,7 +2531,7 @@ func (s *repositoryStore) CreateOrFindByPath(ctx context.Context, path string, o
 		return nil, fmt.Errorf("finding or creating namespace: %w", err)
 	}
 
-	defer metrics.InstrumentQuery("repository_create_or_find_by_path")()
+	defer metrics.InstrumentQuery(ctx, "repository_create_or_find_by_path")()
 	r := &models.Repository{NamespaceID: n.ID, Name: repositoryName(path), Path: path}
 
 	for _, opt := range opts {
@@ -1694,7 +2549,7 @@ func (s *repositoryStore) CreateOrFindByPath(ctx context.Context, path string, o
 
 // Update updates an existing repository.
 func (s *repositoryStore) Update(ctx context.Context, r *models.Repository) error {
-	defer metrics.InstrumentQuery("repository_update")()
+	defer metrics.InstrumentQuery(ctx, "repository_update")()
 	q := `UPDATE
 			repositories
 		SET
@@ -1720,7 +2575,7 @@ func (s *repositoryStore) Update(ctx context.Context, r *models.Repository) erro
 
 // LinkBlob links a blob to a repository. It does nothing if already linked.
 func (s *repositoryStore) LinkBlob(ctx context.Context, r *models.Repository, d digest.Digest) error {
-	defer metrics.InstrumentQuery("repository_link_blob")()
+	defer metrics.InstrumentQuery(ctx, "repository_link_blob")()
 	q := `INSERT INTO repository_blobs (top_level_namespace_id, repository_id, blob_digest)
 			VALUES ($1, $2, decode($3, 'hex'))
 		ON CONFLICT (top_level_namespace_id, repository_id, blob_digest)
@@ -1740,7 +2595,7 @@ func (s *repositoryStore) LinkBlob(ctx context.Context, r *models.Repository, d
 // UnlinkBlob unlinks a blob from a repository. It does nothing if not linked. A boolean is returned to denote whether
 // the link was deleted or not. This avoids the need for a separate preceding `SELECT` to find if it exists.
 func (s *repositoryStore) UnlinkBlob(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error) {
-	defer metrics.InstrumentQuery("repository_unlink_blob")()
+	defer metrics.InstrumentQuery(ctx, "repository_unlink_blob")()
 	q := "DELETE FROM repository_blobs WHERE top_level_namespace_id = $1 AND repository_id = $2 AND blob_digest = decode($3, 'hex')"
 
 	dgst, err := NewDigest(d)
@@ -1763,7 +2618,7 @@ func (s *repositoryStore) UnlinkBlob(ctx context.Context, r *models.Repository,
 // DeleteTagByName deletes a tag by name within a repository. A boolean is returned to denote whether the tag was
 // deleted or not. This avoids the need for a separate preceding `SELECT` to find if it exists.
 func (s *repositoryStore) DeleteTagByName(ctx context.Context, r *models.Repository, name string) (bool, error) {
-	defer metrics.InstrumentQuery("repository_delete_tag_by_name")()
+	defer metrics.InstrumentQuery(ctx, "repository_delete_tag_by_name")()
 	q := "DELETE FROM tags WHERE top_level_namespace_id = $1 AND repository_id = $2 AND name = $3"
 
 	res, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID, name)
@@ -1781,11 +2636,54 @@ func (s *repositoryStore) DeleteTagByName(ctx context.Context, r *models.Reposit
 	return count == 1, nil
 }
 
+// DeleteTagsByNames deletes all tags within a repository with any of the given names in a single statement. The names
+// of the deleted tags are returned, lexicographically sorted. Names for which no tag exists are ignored.
+func (s *repositoryStore) DeleteTagsByNames(ctx context.Context, r *models.Repository, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return []string{}, nil
+	}
+
+	defer metrics.InstrumentQuery(ctx, "repository_delete_tags_by_names")()
+	q := `DELETE FROM tags
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name IN (%s)
+		RETURNING
+			name`
+
+	placeholders, args := tagNamesArgs(names, 3)
+	q = fmt.Sprintf(q, placeholders)
+	args = append([]any{r.NamespaceID, r.ID}, args...)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("deleting tags: %w", err)
+	}
+	defer rows.Close()
+
+	deleted := make([]string, 0, len(names))
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning deleted tag: %w", err)
+		}
+		deleted = append(deleted, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("deleting tags: %w", err)
+	}
+	sort.Strings(deleted)
+
+	s.cache.InvalidateSize(ctx, r)
+
+	return deleted, nil
+}
+
 // DeleteManifest deletes a manifest from a repository. A boolean is returned to denote whether the manifest was deleted
 // or not. This avoids the need for a separate preceding `SELECT` to find if it exists. A manifest cannot be deleted if
 // it is referenced by a manifest list.
 func (s *repositoryStore) DeleteManifest(ctx context.Context, r *models.Repository, d digest.Digest) (bool, error) {
-	defer metrics.InstrumentQuery("repository_delete_manifest")()
+	defer metrics.InstrumentQuery(ctx, "repository_delete_manifest")()
 	q := "DELETE FROM manifests WHERE top_level_namespace_id = $1 AND repository_id = $2 AND digest = decode($3, 'hex')"
 
 	dgst, err := NewDigest(d)
@@ -1817,6 +2715,7 @@ func (s *repositoryStore) DeleteManifest(ctx context.Context, r *models.Reposito
 // Empty repositories (which do not have at least 1 tag) are ignored in the returned list.
 // Also, even if there is no repository with a path equivalent to `filters.LastEntry`, the returned
 // repositories will still be those with a base path of the requested repository and lexicographically after `filters.LastEntry`.
+// Optionally, repositories can be filtered by their labels using `filters.Labels`.
 func (s *repositoryStore) FindPagingatedRepositoriesForPath(ctx context.Context, r *models.Repository, filters FilterParams) (models.Repositories, error) {
 	// start from a path lexicographically before r.Path when no last path is available.
 	// this improves the query performance as we will not need to filter from `r.path > ""` in the query below.
@@ -1824,7 +2723,7 @@ func (s *repositoryStore) FindPagingatedRepositoriesForPath(ctx context.Context,
 		filters.LastEntry = lexicographicallyBeforePath(r.Path)
 	}
 
-	defer metrics.InstrumentQuery("repository_find_paginated_repositories_for_path")()
+	defer metrics.InstrumentQuery(ctx, "repository_find_paginated_repositories_for_path")()
 	q := `SELECT  
 			id,
 			top_level_namespace_id,
@@ -1839,11 +2738,16 @@ func (s *repositoryStore) FindPagingatedRepositoriesForPath(ctx context.Context,
 			(r.path = $1 OR r.path LIKE $2)
 			AND EXISTS ( SELECT FROM tags AS t WHERE t.top_level_namespace_id = r.top_level_namespace_id AND t.repository_id = r.id )
 			AND (r.path > $3 AND r.path < $4)
-			AND r.top_level_namespace_id = $5
+			AND r.top_level_namespace_id = $5%s
 		ORDER BY r.path
-		LIMIT $6`
+		LIMIT $%d`
 
-	rows, err := s.db.QueryContext(ctx, q, r.Path, r.Path+"/%", filters.LastEntry, lexicographicallyNextPath(r.Path), r.NamespaceID, filters.MaxEntries)
+	args := []any{r.Path, r.Path + "/%", filters.LastEntry, lexicographicallyNextPath(r.Path), r.NamespaceID}
+	conds, labelArgs := repositoryLabelFilters(filters, len(args)+1)
+	args = append(args, labelArgs...)
+	args = append(args, filters.MaxEntries)
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(q, conds, len(args)), args...)
 	if err != nil {
 		return nil, fmt.Errorf("finding pagingated list of repository for path: %w", err)
 	}
@@ -1851,12 +2755,78 @@ func (s *repositoryStore) FindPagingatedRepositoriesForPath(ctx context.Context,
 	return scanFullRepositories(rows)
 }
 
+// FindPaginatedSummariesForPath finds all repositories with at least one tag (up to `filters.MaxEntries` repositories)
+// under the path of the requested repository, including the repository itself, along with their tag count and the last
+// time a tag was published. Repositories are sorted by path and, as in FindPagingatedRepositoriesForPath, only those
+// with a path lexicographically after `filters.LastEntry` are returned, if set. Optionally, repositories can be filtered
+// by their labels using `filters.Labels`. The requested repository does not need to exist, but its path and namespace
+// ID must be set.
+func (s *repositoryStore) FindPaginatedSummariesForPath(ctx context.Context, r *models.Repository, filters FilterParams) ([]*models.RepositorySummary, error) {
+	if filters.LastEntry == "" {
+		filters.LastEntry = lexicographicallyBeforePath(r.Path)
+	}
+
+	defer metrics.InstrumentQuery(ctx, "repository_find_paginated_summaries_for_path")()
+	q := `SELECT
+			r.id,
+			r.top_level_namespace_id,
+			r.name,
+			r.path,
+			r.parent_id,
+			r.created_at,
+			r.updated_at,
+			t.tag_count,
+			t.last_published_at
+		FROM
+			repositories AS r
+			JOIN LATERAL (
+				SELECT
+					count(*) AS tag_count,
+					max(coalesce(tags.updated_at, tags.created_at)) AS last_published_at
+				FROM
+					tags
+				WHERE
+					tags.top_level_namespace_id = r.top_level_namespace_id
+					AND tags.repository_id = r.id) AS t ON t.tag_count > 0
+		WHERE
+			(r.path = $1 OR r.path LIKE $2)
+			AND (r.path > $3 AND r.path < $4)
+			AND r.top_level_namespace_id = $5%s
+		ORDER BY r.path
+		LIMIT $%d`
+
+	args := []any{r.Path, r.Path + "/%", filters.LastEntry, lexicographicallyNextPath(r.Path), r.NamespaceID}
+	conds, labelArgs := repositoryLabelFilters(filters, len(args)+1)
+	args = append(args, labelArgs...)
+	args = append(args, filters.MaxEntries)
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(q, conds, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("finding paginated repository summaries for path: %w", err)
+	}
+	defer rows.Close()
+
+	ss := make([]*models.RepositorySummary, 0)
+	for rows.Next() {
+		rs := new(models.RepositorySummary)
+		if err := rows.Scan(&rs.ID, &rs.NamespaceID, &rs.Name, &rs.Path, &rs.ParentID, &rs.CreatedAt, &rs.UpdatedAt, &rs.TagCount, &rs.LastPublishedAt); err != nil {
+			return nil, fmt.Errorf("scanning repository summary: %w", err)
+		}
+		ss = append(ss, rs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning repository summaries: %w", err)
+	}
+
+	return ss, nil
+}
+
 // RenamePathForSubRepositories updates all sub repositories that start with a repository `oldPath` to a `newPath`
 // e.g: All sub repositories with `oldPath`: my-group/my-sub-group/old-repo-name will be changed to:
 // my-group/my-sub-group/new-repo-name, where the `newPath` argument is `my-group/my-sub-group/new-repo-name`.
 // This does not change the base repository's path however.
 func (s *repositoryStore) RenamePathForSubRepositories(ctx context.Context, topLevelNamespaceID int64, oldPath, newPath string) error {
-	defer metrics.InstrumentQuery("repository_rename_sub_repositories_path")()
+	defer metrics.InstrumentQuery(ctx, "repository_rename_sub_repositories_path")()
 
 	q := "UPDATE repositories SET path = REPLACE(path, $1, $2) WHERE top_level_namespace_id = $3 AND path LIKE $4"
 	_, err := s.db.ExecContext(ctx, q, oldPath, newPath, topLevelNamespaceID, oldPath+"/%")
@@ -1870,7 +2840,7 @@ func (s *repositoryStore) RenamePathForSubRepositories(ctx context.Context, topL
 // This must always be followed by `RenamePathForSubRepositories` to make sure sub-repositories starting with
 // the `oldPath`of the repository are also updated to start with the `newPath`.
 func (s *repositoryStore) Rename(ctx context.Context, r *models.Repository, newPath, newName string) error {
-	defer metrics.InstrumentQuery("repository_rename")()
+	defer metrics.InstrumentQuery(ctx, "repository_rename")()
 
 	q := "UPDATE repositories SET path = $1, name = $2 WHERE top_level_namespace_id = $3 AND path = $4 RETURNING updated_at"
 	row := s.db.QueryRowContext(ctx, q, newPath, newName, r.NamespaceID, r.Path)
@@ -39,7 +39,7 @@ func TestRepositoryStore_FindByID(t *testing.T) {
 	reloadRepositoryFixtures(t)
 
 	s := datastore.NewRepositoryStore(suite.db)
-	r, err := s.FindByPath(suite.ctx, "gitlab-org")
+	r, err := s.FindByID(suite.ctx, 1, 1)
 	require.NoError(t, err)
 
 	// see testdata/fixtures/repositories.sql
@@ -55,7 +55,7 @@ func TestRepositoryStore_FindByID(t *testing.T) {
 
 func TestRepositoryStore_FindByID_NotFound(t *testing.T) {
 	s := datastore.NewRepositoryStore(suite.db)
-	r, err := s.FindByPath(suite.ctx, "a/b/c")
+	r, err := s.FindByID(suite.ctx, 1, 100)
 	require.Nil(t, r)
 	require.NoError(t, err)
 }
@@ -301,6 +301,27 @@ func TestRepositoryStore_FindAll_NotFound(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestRepositoryStore_FindAllAfterID(t *testing.T) {
+	reloadRepositoryFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/repositories.sql
+	rr, err := s.FindAllAfterID(suite.ctx, 0, 3)
+	require.NoError(t, err)
+	require.Len(t, rr, 3)
+	require.Equal(t, []int64{1, 2, 3}, []int64{rr[0].ID, rr[1].ID, rr[2].ID})
+
+	rr, err = s.FindAllAfterID(suite.ctx, 14, 3)
+	require.NoError(t, err)
+	require.Len(t, rr, 2)
+	require.Equal(t, []int64{15, 16}, []int64{rr[0].ID, rr[1].ID})
+
+	rr, err = s.FindAllAfterID(suite.ctx, 16, 3)
+	require.NoError(t, err)
+	require.Empty(t, rr)
+}
+
 func TestRepositoryStore_FindAllPaginated(t *testing.T) {
 	reloadManifestFixtures(t)
 
@@ -582,6 +603,122 @@ func TestRepositoryStore_FindAllPaginated(t *testing.T) {
 	}
 }
 
+func TestRepositoryStore_FindAllPaginated_WithFilters(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	// see testdata/fixtures/[repositories|manifests|tags].sql
+	tt := []struct {
+		name          string
+		limit         int
+		lastPath      string
+		prefix        string
+		modifiedSince time.Time
+		expectedPaths []string
+		expectedMore  bool
+	}{
+		{
+			name:   "prefix",
+			limit:  100,
+			prefix: "usage-group/",
+			expectedPaths: []string{
+				"usage-group/sub-group-1",
+				"usage-group/sub-group-1/repository-1",
+				"usage-group/sub-group-1/repository-2",
+				"usage-group/sub-group-2/repository-1",
+				"usage-group/sub-group-2/repository-1/sub-repository-1",
+			},
+		},
+		{
+			name:          "prefix is not a path segment",
+			limit:         100,
+			prefix:        "usage-group-",
+			expectedPaths: []string{"usage-group-2/sub-group-1/project-1"},
+		},
+		{
+			name:          "prefix with LIKE wildcard",
+			limit:         100,
+			prefix:        "usage_group",
+			expectedPaths: []string{},
+		},
+		{
+			name:  "modified since",
+			limit: 100,
+			// only tags of usage-group-2/sub-group-1/project-1 were created or updated since then
+			modifiedSince: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedPaths: []string{"usage-group-2/sub-group-1/project-1"},
+		},
+		{
+			name:          "modified since after all changes",
+			limit:         100,
+			modifiedSince: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedPaths: []string{},
+		},
+		{
+			name:          "prefix and modified since",
+			limit:         100,
+			prefix:        "usage-group/",
+			modifiedSince: time.Date(2022, 2, 22, 11, 50, 0, 0, time.UTC),
+			expectedPaths: []string{
+				"usage-group/sub-group-1",
+				"usage-group/sub-group-1/repository-2",
+				"usage-group/sub-group-2/repository-1",
+				"usage-group/sub-group-2/repository-1/sub-repository-1",
+			},
+		},
+		{
+			name:          "prefix and modified since with limit",
+			limit:         2,
+			prefix:        "usage-group/",
+			modifiedSince: time.Date(2022, 2, 22, 11, 50, 0, 0, time.UTC),
+			expectedPaths: []string{
+				"usage-group/sub-group-1",
+				"usage-group/sub-group-1/repository-2",
+			},
+			expectedMore: true,
+		},
+		{
+			name:          "prefix and modified since with limit and last path",
+			limit:         2,
+			lastPath:      "usage-group/sub-group-1/repository-2",
+			prefix:        "usage-group/",
+			modifiedSince: time.Date(2022, 2, 22, 11, 50, 0, 0, time.UTC),
+			expectedPaths: []string{
+				"usage-group/sub-group-2/repository-1",
+				"usage-group/sub-group-2/repository-1/sub-repository-1",
+			},
+		},
+	}
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			filters := datastore.FilterParams{
+				MaxEntries:    test.limit,
+				LastEntry:     test.lastPath,
+				PathPrefix:    test.prefix,
+				ModifiedSince: test.modifiedSince,
+			}
+
+			rr, err := s.FindAllPaginated(suite.ctx, filters)
+			require.NoError(t, err)
+
+			paths := make([]string, 0, len(rr))
+			for _, r := range rr {
+				paths = append(paths, r.Path)
+			}
+			require.Equal(t, test.expectedPaths, paths)
+
+			if len(rr) > 0 {
+				filters.LastEntry = rr[len(rr)-1].Path
+				more, err := s.HasAfterPath(suite.ctx, filters)
+				require.NoError(t, err)
+				require.Equal(t, test.expectedMore, more)
+			}
+		})
+	}
+}
+
 func TestRepositoryStore_FindAllPaginated_NoRepositories(t *testing.T) {
 	unloadManifestFixtures(t)
 
@@ -1015,6 +1152,7 @@ func TestRepositoryStore_HasTagsAfterName(t *testing.T) {
 		name     string
 		sort     datastore.SortOrder
 		lastName string
+		prefix   string
 		expected bool
 	}{
 		{
@@ -1072,6 +1210,18 @@ func TestRepositoryStore_HasTagsAfterName(t *testing.T) {
 			lastName: "z-does-not-exist",
 			expected: true,
 		},
+		{
+			name:     "nth with name prefix",
+			lastName: "1.0.0",
+			prefix:   "stable-",
+			expected: true,
+		},
+		{
+			name:     "last with name prefix",
+			lastName: "rc2",
+			prefix:   "rc",
+			expected: false,
+		},
 	}
 
 	s := datastore.NewRepositoryStore(suite.db)
@@ -1079,8 +1229,9 @@ func TestRepositoryStore_HasTagsAfterName(t *testing.T) {
 	for _, test := range tt {
 		t.Run(test.name, func(t *testing.T) {
 			filters := datastore.FilterParams{
-				SortOrder: test.sort,
-				LastEntry: test.lastName,
+				SortOrder:  test.sort,
+				LastEntry:  test.lastName,
+				NamePrefix: test.prefix,
 			}
 
 			c, err := s.HasTagsAfterName(suite.ctx, r, filters)
@@ -1201,6 +1352,86 @@ func TestRepositoryStore_ManifestTags(t *testing.T) {
 	require.Equal(t, expected, tt)
 }
 
+func TestRepositoryStore_ManifestReferrers(t *testing.T) {
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.CreateByPath(suite.ctx, randomRepository(t).Path)
+	require.NoError(t, err)
+
+	ms := datastore.NewManifestStore(suite.db)
+	subject := randomManifest(t, r, nil)
+	require.NoError(t, ms.Create(suite.ctx, subject))
+
+	// create two direct referrers and one referrer of a referrer, which should not be included
+	var expected models.Manifests
+	for i := 0; i < 2; i++ {
+		m := randomManifest(t, r, nil)
+		m.SubjectID = sql.NullInt64{Int64: subject.ID, Valid: true}
+		require.NoError(t, ms.Create(suite.ctx, m))
+		expected = append(expected, m)
+	}
+	m := randomManifest(t, r, nil)
+	m.SubjectID = sql.NullInt64{Int64: expected[0].ID, Valid: true}
+	require.NoError(t, ms.Create(suite.ctx, m))
+
+	mm, err := rs.ManifestReferrers(suite.ctx, r, subject)
+	require.NoError(t, err)
+	require.Len(t, mm, len(expected))
+	for i := range expected {
+		require.Equal(t, expected[i].ID, mm[i].ID)
+		require.Equal(t, expected[i].Digest, mm[i].Digest)
+		require.Equal(t, expected[i].SubjectID, mm[i].SubjectID)
+	}
+
+	// manifests without referrers
+	mm, err = rs.ManifestReferrers(suite.ctx, r, m)
+	require.NoError(t, err)
+	require.Empty(t, mm)
+}
+
+func TestRepositoryStore_ManifestTagSchemaReferrers(t *testing.T) {
+	rs := datastore.NewRepositoryStore(suite.db)
+	r, err := rs.CreateByPath(suite.ctx, randomRepository(t).Path)
+	require.NoError(t, err)
+
+	ms := datastore.NewManifestStore(suite.db)
+	subject := randomManifest(t, r, nil)
+	require.NoError(t, ms.Create(suite.ctx, subject))
+
+	// create two referrers associated through the tag schema and one that declares its subject, which is not included
+	var expected models.Manifests
+	for i := 0; i < 2; i++ {
+		m := randomManifest(t, r, nil)
+		require.NoError(t, ms.Create(suite.ctx, m))
+		_, err := ms.AssociateTagSchemaReferrer(suite.ctx, m, subject)
+		require.NoError(t, err)
+		expected = append(expected, m)
+	}
+	m := randomManifest(t, r, nil)
+	m.SubjectID = sql.NullInt64{Int64: subject.ID, Valid: true}
+	require.NoError(t, ms.Create(suite.ctx, m))
+
+	mm, err := rs.ManifestTagSchemaReferrers(suite.ctx, r, subject)
+	require.NoError(t, err)
+	require.Len(t, mm, len(expected))
+	for i := range expected {
+		require.Equal(t, expected[i].ID, mm[i].ID)
+		require.Equal(t, expected[i].Digest, mm[i].Digest)
+	}
+
+	// manifests without referrers
+	mm, err = rs.ManifestTagSchemaReferrers(suite.ctx, r, m)
+	require.NoError(t, err)
+	require.Empty(t, mm)
+
+	// deleting a referrer removes its association
+	_, err = ms.Delete(suite.ctx, r.NamespaceID, r.ID, expected[0].ID)
+	require.NoError(t, err)
+	mm, err = rs.ManifestTagSchemaReferrers(suite.ctx, r, subject)
+	require.NoError(t, err)
+	require.Len(t, mm, 1)
+	require.Equal(t, expected[1].ID, mm[0].ID)
+}
+
 func TestRepositoryStore_Count(t *testing.T) {
 	reloadRepositoryFixtures(t)
 
@@ -1330,6 +1561,160 @@ func TestRepositoryStore_CountPathSubRepositories(t *testing.T) {
 	}
 }
 
+func TestRepositoryStore_CountPaginated(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	// see testdata/fixtures/[repositories|manifests|tags].sql and TestRepositoryStore_FindAllPaginated_WithFilters
+	tt := []struct {
+		name          string
+		prefix        string
+		modifiedSince time.Time
+		expected      int
+	}{
+		{
+			name: "no filters",
+			// all non-empty repositories
+			expected: 10,
+		},
+		{
+			name:     "prefix",
+			prefix:   "usage-group/",
+			expected: 5,
+		},
+		{
+			name:     "prefix with LIKE wildcard",
+			prefix:   "usage_group",
+			expected: 0,
+		},
+		{
+			name:          "modified since",
+			modifiedSince: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected:      1,
+		},
+		{
+			name:          "prefix and modified since",
+			prefix:        "usage-group/",
+			modifiedSince: time.Date(2022, 2, 22, 11, 50, 0, 0, time.UTC),
+			expected:      4,
+		},
+	}
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			// the pagination marker and limit must not affect the count
+			c, err := s.CountPaginated(suite.ctx, datastore.FilterParams{
+				MaxEntries:    1,
+				LastEntry:     "usage-group/sub-group-1",
+				PathPrefix:    test.prefix,
+				ModifiedSince: test.modifiedSince,
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, c)
+		})
+	}
+}
+
+func TestRepositoryStore_CountRepositoriesWithTagsForPath(t *testing.T) {
+	reloadTagFixtures(t)
+
+	// see testdata/fixtures/[repositories|tags].sql and TestRepositoryStore_FindPagingatedRepositoriesForPath
+	tt := []struct {
+		name     string
+		repo     *models.Repository
+		expected int
+	}{
+		{
+			name:     "non existent path",
+			repo:     &models.Repository{NamespaceID: 1, Path: "non-existent"},
+			expected: 0,
+		},
+		{
+			name:     "no repositories with tags",
+			repo:     &models.Repository{NamespaceID: 2, Path: "a-test-group"},
+			expected: 0,
+		},
+		{
+			name:     "top level path",
+			repo:     &models.Repository{NamespaceID: 3, Path: "usage-group"},
+			expected: 5,
+		},
+		{
+			name:     "nested path including base repository",
+			repo:     &models.Repository{NamespaceID: 3, Path: "usage-group/sub-group-1"},
+			expected: 3,
+		},
+	}
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := s.CountRepositoriesWithTagsForPath(suite.ctx, test.repo, datastore.FilterParams{})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, c)
+		})
+	}
+}
+
+func TestRepositoryStore_TagsCount(t *testing.T) {
+	reloadTagFixtures(t)
+
+	// see testdata/fixtures/tags.sql (sorted):
+	// 1.0.0
+	// rc2
+	// stable-91ac07a9
+	// stable-9ede8db0
+	r := &models.Repository{NamespaceID: 1, ID: 4}
+
+	tt := []struct {
+		name     string
+		filters  datastore.FilterParams
+		expected int
+	}{
+		{
+			name:     "all",
+			expected: 4,
+		},
+		{
+			name:     "pagination markers are ignored",
+			filters:  datastore.FilterParams{MaxEntries: 1, LastEntry: "rc2", BeforeEntry: "stable-9ede8db0"},
+			expected: 4,
+		},
+		{
+			name:     "name",
+			filters:  datastore.FilterParams{Name: "stable"},
+			expected: 2,
+		},
+		{
+			name:     "name prefix",
+			filters:  datastore.FilterParams{NamePrefix: "stable-9e"},
+			expected: 1,
+		},
+		{
+			name:     "name regex",
+			filters:  datastore.FilterParams{NameRegex: "^[0-9]"},
+			expected: 1,
+		},
+		{
+			name:     "unknown artifact type",
+			filters:  datastore.FilterParams{ArtifactType: "application/vnd.unknown"},
+			expected: 0,
+		},
+	}
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := s.TagsCount(suite.ctx, r, test.filters)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, c)
+		})
+	}
+}
+
 func TestRepositoryStore_FindManifestByDigest(t *testing.T) {
 	reloadManifestFixtures(t)
 
@@ -1449,6 +1834,124 @@ func TestRepositoryStore_FindTagByName(t *testing.T) {
 	require.Equal(t, expected, tag)
 }
 
+func TestRepositoryStore_LockTagByName(t *testing.T) {
+	reloadTagFixtures(t)
+
+	tx, err := suite.db.BeginTx(suite.ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	s := datastore.NewRepositoryStore(tx)
+	r := &models.Repository{NamespaceID: 1, ID: 4}
+	tag, err := s.LockTagByName(suite.ctx, r, "1.0.0")
+	require.NoError(t, err)
+
+	// see testdata/fixtures/tags.sql
+	expected := &models.Tag{
+		ID:           4,
+		NamespaceID:  1,
+		Name:         "1.0.0",
+		RepositoryID: 4,
+		ManifestID:   3,
+		CreatedAt:    testutil.ParseTimestamp(t, "2020-03-02 17:57:46.283783", tag.CreatedAt.Location()),
+	}
+	require.Equal(t, expected, tag)
+
+	tag, err = s.LockTagByName(suite.ctx, r, "unknown")
+	require.NoError(t, err)
+	require.Nil(t, tag)
+}
+
+func TestRepositoryStore_LockManifestTags(t *testing.T) {
+	reloadTagFixtures(t)
+
+	tx, err := suite.db.BeginTx(suite.ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	s := datastore.NewRepositoryStore(tx)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+	tt, err := s.LockManifestTags(suite.ctx, r, &models.Manifest{NamespaceID: 1, ID: 1})
+	require.NoError(t, err)
+
+	// see testdata/fixtures/tags.sql
+	expected := models.Tags{
+		{
+			ID:           1,
+			NamespaceID:  1,
+			Name:         "1.0.0",
+			RepositoryID: 3,
+			ManifestID:   1,
+			CreatedAt:    testutil.ParseTimestamp(t, "2020-03-02 17:57:43.283783", tt[0].CreatedAt.Location()),
+		},
+	}
+	require.Equal(t, expected, tt)
+
+	tt, err = s.LockManifestTags(suite.ctx, r, &models.Manifest{NamespaceID: 1, ID: 100})
+	require.NoError(t, err)
+	require.Empty(t, tt)
+}
+
+func TestRepositoryStore_FindTagsByNames(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 4}
+	tt, err := s.FindTagsByNames(suite.ctx, r, []string{"stable-91ac07a9", "1.0.0", "unknown"})
+	require.NoError(t, err)
+
+	// see testdata/fixtures/tags.sql
+	local := tt[0].CreatedAt.Location()
+	expected := models.Tags{
+		{
+			ID:           4,
+			NamespaceID:  1,
+			Name:         "1.0.0",
+			RepositoryID: 4,
+			ManifestID:   3,
+			CreatedAt:    testutil.ParseTimestamp(t, "2020-03-02 17:57:46.283783", local),
+		},
+		{
+			ID:           6,
+			NamespaceID:  1,
+			Name:         "stable-91ac07a9",
+			RepositoryID: 4,
+			ManifestID:   4,
+			CreatedAt:    testutil.ParseTimestamp(t, "2020-04-15 09:47:26.461413", local),
+		},
+	}
+	require.Equal(t, expected, tt)
+}
+
+func TestRepositoryStore_FindTagsByNames_None(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	tt, err := s.FindTagsByNames(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4}, []string{"unknown"})
+	require.NoError(t, err)
+	require.Empty(t, tt)
+}
+
+func TestRepositoryStore_FindTagsByNameRegex(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 4}
+
+	// see testdata/fixtures/tags.sql
+	tt, err := s.FindTagsByNameRegex(suite.ctx, r, "^stable-", 10)
+	require.NoError(t, err)
+	require.Len(t, tt, 2)
+	require.Equal(t, "stable-91ac07a9", tt[0].Name)
+	require.Equal(t, "stable-9ede8db0", tt[1].Name)
+
+	// limit
+	tt, err = s.FindTagsByNameRegex(suite.ctx, r, "^stable-", 1)
+	require.NoError(t, err)
+	require.Len(t, tt, 1)
+	require.Equal(t, "stable-91ac07a9", tt[0].Name)
+}
+
 func TestRepositoryStore_Blobs(t *testing.T) {
 	reloadBlobFixtures(t)
 
@@ -1537,6 +2040,85 @@ func TestRepositoryStore_FindBlobByDigest_NotFound(t *testing.T) {
 	require.Nil(t, b)
 }
 
+func TestRepositoryStore_FindByBlob(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/repository_blobs.sql
+	d := digest.Digest("sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9")
+
+	tests := []struct {
+		name               string
+		pathPrefix         string
+		excludedNamespaces []string
+		expectedPaths      []string
+	}{
+		{
+			name: "any repository",
+			expectedPaths: []string{
+				"gitlab-org/gitlab-test/backend",
+				"gitlab-org/gitlab-test/frontend",
+				"a-test-group/foo",
+				"a-test-group/bar",
+			},
+		},
+		{
+			name:          "top-level namespace",
+			pathPrefix:    "a-test-group/",
+			expectedPaths: []string{"a-test-group/foo", "a-test-group/bar"},
+		},
+		{
+			name:          "nested path",
+			pathPrefix:    "gitlab-org/gitlab-test/front",
+			expectedPaths: []string{"gitlab-org/gitlab-test/frontend"},
+		},
+		{
+			name:               "excluded namespaces",
+			excludedNamespaces: []string{"gitlab-org", "unknown"},
+			expectedPaths:      []string{"a-test-group/foo", "a-test-group/bar"},
+		},
+		{
+			name:               "top-level namespace and excluded namespaces",
+			pathPrefix:         "gitlab-org/",
+			excludedNamespaces: []string{"a-test-group"},
+			expectedPaths:      []string{"gitlab-org/gitlab-test/backend", "gitlab-org/gitlab-test/frontend"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := s.FindByBlob(suite.ctx, d, test.pathPrefix, test.excludedNamespaces)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.Contains(t, test.expectedPaths, r.Path)
+		})
+	}
+}
+
+func TestRepositoryStore_FindByBlob_NotFound(t *testing.T) {
+	reloadBlobFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/repository_blobs.sql
+	r, err := s.FindByBlob(suite.ctx, "sha256:d9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9", "", nil)
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	r, err = s.FindByBlob(suite.ctx, "sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9", "usage-group/", nil)
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	r, err = s.FindByBlob(suite.ctx, "sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9", "unknown/", nil)
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	r, err = s.FindByBlob(suite.ctx, "sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9", "", []string{"gitlab-org", "a-test-group"})
+	require.NoError(t, err)
+	require.Nil(t, r)
+}
+
 func TestRepositoryStore_ExistsBlobByDigest(t *testing.T) {
 	reloadBlobFixtures(t)
 
@@ -1651,6 +2233,52 @@ func TestRepositoryStore_Size_NotFound(t *testing.T) {
 	require.Zero(t, size)
 }
 
+func TestRepositoryStore_SizeBreakdown(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	b, err := s.SizeBreakdown(suite.ctx, &models.Repository{NamespaceID: 3, ID: 10}, 10)
+	require.NoError(t, err)
+
+	// layers size must match the deduplicated repository size (see TestRepositoryStore_Size)
+	require.Equal(t, int64(1659463), b.LayersSize)
+	require.GreaterOrEqual(t, b.SharedLayersSize, int64(0))
+	require.LessOrEqual(t, b.SharedLayersSize, b.LayersSize)
+	require.Equal(t, b.LayersSize, b.UniqueLayersSize()+b.SharedLayersSize)
+	require.Positive(t, b.ManifestsSize)
+
+	require.NotEmpty(t, b.LargestImages)
+	for i, img := range b.LargestImages {
+		require.NotEmpty(t, img.Tag)
+		require.NotEmpty(t, img.Digest)
+		require.NotEmpty(t, img.MediaType)
+		if i > 0 {
+			require.LessOrEqual(t, img.Size, b.LargestImages[i-1].Size)
+		}
+	}
+}
+
+func TestRepositoryStore_SizeBreakdown_Limit(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	b, err := s.SizeBreakdown(suite.ctx, &models.Repository{NamespaceID: 3, ID: 10}, 1)
+	require.NoError(t, err)
+	require.Len(t, b.LargestImages, 1)
+}
+
+func TestRepositoryStore_SizeBreakdown_Empty(t *testing.T) {
+	reloadManifestFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	b, err := s.SizeBreakdown(suite.ctx, &models.Repository{NamespaceID: 3, ID: 8}, 10)
+	require.NoError(t, err)
+	require.Equal(t, &models.RepositorySizeBreakdown{LargestImages: make([]*models.ImageSize, 0)}, b)
+}
+
 func TestRepositoryStore_Size_SingleRepositoryCache(t *testing.T) {
 	reloadRepositoryFixtures(t)
 
@@ -2308,6 +2936,44 @@ func TestRepositoryStore_DeleteTagByName_NotFoundDoesNotFail(t *testing.T) {
 	require.False(t, found)
 }
 
+func TestRepositoryStore_DeleteTagsByNames(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/tags.sql
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	deleted, err := s.DeleteTagsByNames(suite.ctx, r, []string{"latest", "1.0.0", "10.0.0"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"1.0.0", "latest"}, deleted)
+
+	tt, err := s.Tags(suite.ctx, r)
+	require.NoError(t, err)
+	require.Len(t, tt, 2)
+	for _, tag := range tt {
+		require.NotContains(t, deleted, tag.Name)
+	}
+
+	// tags with the same name in other repositories must not be affected
+	tag, err := s.FindTagByName(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4}, "1.0.0")
+	require.NoError(t, err)
+	require.NotNil(t, tag)
+}
+
+func TestRepositoryStore_DeleteTagsByNames_NotFoundDoesNotFail(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// see testdata/fixtures/tags.sql
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	deleted, err := s.DeleteTagsByNames(suite.ctx, r, []string{"10.0.0"})
+	require.NoError(t, err)
+	require.Empty(t, deleted)
+}
+
 func TestRepositoryStore_DeleteManifest(t *testing.T) {
 	reloadManifestFixtures(t)
 
@@ -2484,6 +3150,8 @@ func TestRepositoryStore_TagsDetailPaginated(t *testing.T) {
 		limit        int
 		beforeName   string
 		lastName     string
+		namePrefix   string
+		nameRegex    string
 		expectedTags []*models.TagDetail
 	}{
 		{
@@ -2549,6 +3217,40 @@ func TestRepositoryStore_TagsDetailPaginated(t *testing.T) {
 			beforeName:   "z-does-not-exist",
 			expectedTags: allTags,
 		},
+		// name filter tests
+		{
+			name:         "name prefix",
+			limit:        100,
+			namePrefix:   "stable-",
+			expectedTags: []*models.TagDetail{thirdTag, fourthTag},
+		},
+		{
+			name:         "name prefix with last name",
+			limit:        100,
+			lastName:     "stable-91ac07a9",
+			namePrefix:   "stable-",
+			expectedTags: []*models.TagDetail{fourthTag},
+		},
+		{
+			name:         "name regex",
+			limit:        100,
+			nameRegex:    "^[0-9.]+$|^rc[0-9]+$",
+			expectedTags: []*models.TagDetail{firstTag, secondTag},
+		},
+		{
+			name:         "name prefix and regex",
+			limit:        100,
+			namePrefix:   "stable-",
+			nameRegex:    "[0-9]$",
+			expectedTags: []*models.TagDetail{fourthTag},
+		},
+		{
+			name:         "name regex before name",
+			limit:        100,
+			beforeName:   "stable-9ede8db0",
+			nameRegex:    "a",
+			expectedTags: []*models.TagDetail{thirdTag},
+		},
 	}
 
 	s := datastore.NewRepositoryStore(suite.db)
@@ -2558,6 +3260,8 @@ func TestRepositoryStore_TagsDetailPaginated(t *testing.T) {
 			filters := datastore.FilterParams{
 				BeforeEntry: test.beforeName,
 				LastEntry:   test.lastName,
+				NamePrefix:  test.namePrefix,
+				NameRegex:   test.nameRegex,
 				MaxEntries:  test.limit,
 			}
 
@@ -3130,6 +3834,113 @@ func TestRepositoryStore_FindPagingatedRepositoriesForPath_None(t *testing.T) {
 	require.Empty(t, rr)
 }
 
+func TestRepositoryStore_FindPaginatedSummariesForPath(t *testing.T) {
+	reloadTagFixtures(t)
+
+	type summary struct {
+		path            string
+		tagCount        int
+		lastPublishedAt string
+	}
+
+	// see testdata/fixtures/[repositories|tags].sql
+	all := []summary{
+		{path: "usage-group/sub-group-1", tagCount: 1, lastPublishedAt: "2022-02-22T12:01:05.114178Z"},
+		{path: "usage-group/sub-group-1/repository-1", tagCount: 6, lastPublishedAt: "2021-11-24T11:59:13.421427Z"},
+		{path: "usage-group/sub-group-1/repository-2", tagCount: 1, lastPublishedAt: "2022-02-22T11:59:13.421427Z"},
+		{path: "usage-group/sub-group-2/repository-1", tagCount: 1, lastPublishedAt: "2022-02-22T11:59:13.421427Z"},
+		{path: "usage-group/sub-group-2/repository-1/sub-repository-1", tagCount: 1, lastPublishedAt: "2022-02-22T11:59:13.421427Z"},
+	}
+
+	tt := []struct {
+		name     string
+		limit    int
+		path     string
+		lastPath string
+		expected []summary
+	}{
+		{
+			name:     "no limit and no last path",
+			limit:    100,
+			path:     "usage-group",
+			expected: all,
+		},
+		{
+			name:     "limit",
+			limit:    2,
+			path:     "usage-group",
+			expected: all[:2],
+		},
+		{
+			name:     "last path",
+			limit:    100,
+			path:     "usage-group",
+			lastPath: "usage-group/sub-group-1/repository-2",
+			expected: all[3:],
+		},
+		{
+			name:     "limit and last path",
+			limit:    1,
+			path:     "usage-group",
+			lastPath: "usage-group/sub-group-1",
+			expected: all[1:2],
+		},
+		{
+			name:     "sub group",
+			limit:    100,
+			path:     "usage-group/sub-group-2",
+			expected: all[3:],
+		},
+		{
+			name:     "last path after all repositories",
+			limit:    100,
+			path:     "usage-group",
+			lastPath: "z-does-not-exist",
+			expected: []summary{},
+		},
+	}
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			filters := datastore.FilterParams{
+				MaxEntries: test.limit,
+				LastEntry:  test.lastPath,
+			}
+
+			ss, err := s.FindPaginatedSummariesForPath(suite.ctx, &models.Repository{NamespaceID: 3, Path: test.path}, filters)
+			require.NoError(t, err)
+
+			actual := make([]summary, 0, len(ss))
+			for _, rs := range ss {
+				require.NotZero(t, rs.ID)
+				require.NotEmpty(t, rs.CreatedAt)
+				actual = append(actual, summary{
+					path:            rs.Path,
+					tagCount:        rs.TagCount,
+					lastPublishedAt: rs.LastPublishedAt.UTC().Format(time.RFC3339Nano),
+				})
+			}
+			require.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestRepositoryStore_FindPaginatedSummariesForPath_None(t *testing.T) {
+	reloadTagFixtures(t)
+
+	s := datastore.NewRepositoryStore(suite.db)
+
+	// a-test-group and its sub-repositories have no tags
+	ss, err := s.FindPaginatedSummariesForPath(suite.ctx, &models.Repository{
+		NamespaceID: 2,
+		Path:        "a-test-group",
+	}, datastore.FilterParams{MaxEntries: 100})
+	require.NoError(t, err)
+	require.Empty(t, ss)
+}
+
 func TestRepositoryStore_RenamePathForSubRepositories(t *testing.T) {
 	reloadRepositoryFixtures(t)
 	test := struct {
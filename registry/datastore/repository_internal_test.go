@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/docker/distribution/registry/datastore/models"
@@ -48,6 +49,30 @@ func Test_sqlPartialMatch(t *testing.T) {
 	}
 }
 
+func Test_sqlPrefixMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{
+			name: "no metacharacters",
+			arg:  "v1.",
+			want: "v1.%",
+		},
+		{
+			name: "wildcards",
+			arg:  "a%b_c",
+			want: `a\%b\_c%`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, sqlPrefixMatch(tt.arg))
+		})
+	}
+}
+
 func Test_tagsDetailPaginatedQuery(t *testing.T) {
 	r := &models.Repository{ID: 123, NamespaceID: 456}
 	baseArgs := []any{r.NamespaceID, r.ID, sqlPartialMatch("")}
@@ -57,16 +82,21 @@ func Test_tagsDetailPaginatedQuery(t *testing.T) {
 			encode(m.digest, 'hex') AS digest,
 			encode(m.configuration_blob_digest, 'hex') AS config_digest,
 			mt.media_type,
+			mta.media_type AS artifact_type,
 			m.total_size,
 			t.created_at,
 			t.updated_at,
-			GREATEST(t.created_at, t.updated_at) as published_at
+			GREATEST(t.created_at, t.updated_at) as published_at,
+			GREATEST(t.last_pulled_at, m.last_pulled_at) AS last_pulled_at,
+			m.chart_name,
+			m.chart_version
 		FROM
 			tags AS t
 			JOIN manifests AS m ON m.top_level_namespace_id = t.top_level_namespace_id
 				AND m.repository_id = t.repository_id
 				AND m.id = t.manifest_id
 			JOIN media_types AS mt ON mt.id = m.media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
 		WHERE
 			t.top_level_namespace_id = $1
 			AND t.repository_id = $2
@@ -219,3 +249,90 @@ func Test_tagsDetailPaginatedQuery(t *testing.T) {
 		})
 	}
 }
+
+func Test_tagsDetailPaginatedQuery_NameFilters(t *testing.T) {
+	r := &models.Repository{ID: 123, NamespaceID: 456}
+
+	tcs := map[string]struct {
+		filters       FilterParams
+		expectedWhere string
+		expectedArgs  []any
+	}{
+		"prefix": {
+			filters: FilterParams{MaxEntries: 5, NamePrefix: "v1."},
+			expectedWhere: `AND t.name LIKE $3
+			AND t.name LIKE $5
+			ORDER BY name asc LIMIT $4`,
+			expectedArgs: []any{r.NamespaceID, r.ID, sqlPartialMatch(""), 5, "v1.%"},
+		},
+		"regex": {
+			filters: FilterParams{MaxEntries: 5, NameRegex: "^v[0-9]+$"},
+			expectedWhere: `AND t.name LIKE $3
+			AND t.name ~ $5
+			ORDER BY name asc LIMIT $4`,
+			expectedArgs: []any{r.NamespaceID, r.ID, sqlPartialMatch(""), 5, "^v[0-9]+$"},
+		},
+		"prefix and regex with last entry order by published_at": {
+			filters: FilterParams{
+				MaxEntries:  5,
+				Name:        "rc",
+				NamePrefix:  "v1.",
+				NameRegex:   "-rc[0-9]+$",
+				LastEntry:   "abc",
+				PublishedAt: "TIMESTAMP",
+				OrderBy:     "published_at",
+				SortOrder:   OrderDesc,
+			},
+			expectedWhere: `AND t.name LIKE $3
+			AND t.name LIKE $7
+			AND t.name ~ $8
+			AND t.name < $4
+		AND GREATEST(t.created_at,t.updated_at) <= $5
+		ORDER BY
+			published_at desc,
+			t.name desc
+		LIMIT $6`,
+			expectedArgs: []any{r.NamespaceID, r.ID, sqlPartialMatch("rc"), "abc", "TIMESTAMP", 5, "v1.%", "-rc[0-9]+$"},
+		},
+		"artifact type": {
+			filters: FilterParams{MaxEntries: 5, ArtifactType: "application/vnd.example.sbom"},
+			expectedWhere: `AND t.name LIKE $3
+			AND t.manifest_id IN (
+				SELECT
+					am.id
+				FROM
+					manifests AS am
+					JOIN media_types AS amt ON amt.id = am.artifact_media_type_id
+				WHERE
+					am.top_level_namespace_id = $1
+					AND am.repository_id = $2
+					AND amt.media_type = $5)
+			ORDER BY name asc LIMIT $4`,
+			expectedArgs: []any{r.NamespaceID, r.ID, sqlPartialMatch(""), 5, "application/vnd.example.sbom"},
+		},
+		"prefix and artifact type": {
+			filters: FilterParams{MaxEntries: 5, NamePrefix: "v1.", ArtifactType: "application/vnd.example.sbom"},
+			expectedWhere: `AND t.name LIKE $5
+			AND t.manifest_id IN (
+				SELECT
+					am.id
+				FROM
+					manifests AS am
+					JOIN media_types AS amt ON amt.id = am.artifact_media_type_id
+				WHERE
+					am.top_level_namespace_id = $1
+					AND am.repository_id = $2
+					AND amt.media_type = $6)
+			ORDER BY name asc LIMIT $4`,
+			expectedArgs: []any{r.NamespaceID, r.ID, sqlPartialMatch(""), 5, "v1.%", "application/vnd.example.sbom"},
+		},
+	}
+
+	for tn, tc := range tcs {
+		t.Run(tn, func(t *testing.T) {
+			q, args := tagsDetailPaginatedQuery(r, tc.filters)
+			require.True(t, strings.HasSuffix(q, tc.expectedWhere), q)
+			require.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
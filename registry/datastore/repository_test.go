@@ -58,3 +58,28 @@ func TestCentralRepositoryCache(t *testing.T) {
 
 	require.NoError(t, redisMock.ExpectationsWereMet())
 }
+
+func TestCentralRepositoryCache_SizeWithDescendants(t *testing.T) {
+	repo := &models.Repository{ID: 1, NamespaceID: 1, Name: "gitlab-org", Path: "gitlab-org"}
+
+	ttl := 30 * time.Minute
+	redisCache, redisMock := testutil.RedisCacheMock(t, ttl)
+	cache := datastore.NewCentralRepositoryCache(redisCache)
+	ctx := context.Background()
+
+	key := "registry:db:{repository:gitlab-org:4034e0be2fa66b23fcb020bd19c952bd9d0fc58444da3b1b68e986d8ebe2716d}:swd-size"
+
+	redisMock.ExpectGet(key).RedisNil()
+	_, ok := cache.GetSizeWithDescendants(ctx, repo)
+	require.False(t, ok)
+
+	redisMock.ExpectSet(key, "123", ttl).SetVal("OK")
+	cache.SetSizeWithDescendants(ctx, repo, 123)
+
+	redisMock.ExpectGet(key).SetVal("123")
+	size, ok := cache.GetSizeWithDescendants(ctx, repo)
+	require.True(t, ok)
+	require.Equal(t, int64(123), size)
+
+	require.NoError(t, redisMock.ExpectationsWereMet())
+}
@@ -0,0 +1,150 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+const (
+	// MaxRepositoryLabelKeyLength and MaxRepositoryLabelValueLength are the maximum length of the key and value of a
+	// repository label, as enforced by the database schema.
+	MaxRepositoryLabelKeyLength   = 63
+	MaxRepositoryLabelValueLength = 255
+)
+
+// RepositoryLabelMatch is a condition on the labels of a repository: it must have a label with the given key and,
+// unless AnyValue is set, the given value.
+type RepositoryLabelMatch struct {
+	Key      string
+	Value    string
+	AnyValue bool
+}
+
+// String returns the condition in the `<key>=<value>` format, or just `<key>` if any value matches.
+func (m RepositoryLabelMatch) String() string {
+	if m.AnyValue {
+		return m.Key
+	}
+	return m.Key + "=" + m.Value
+}
+
+// RepositoryLabelStore is the interface that a repository label store should conform to.
+type RepositoryLabelStore interface {
+	FindByRepository(ctx context.Context, r *models.Repository) (map[string]string, error)
+	ReplaceForRepository(ctx context.Context, r *models.Repository, labels map[string]string) error
+}
+
+// repositoryLabelStore is the concrete implementation of a RepositoryLabelStore.
+type repositoryLabelStore struct {
+	db Queryer
+}
+
+// NewRepositoryLabelStore builds a new repository label store.
+func NewRepositoryLabelStore(db Queryer) RepositoryLabelStore {
+	return &repositoryLabelStore{db: db}
+}
+
+// FindByRepository finds all labels of a given repository, as a map of keys to values.
+func (s *repositoryLabelStore) FindByRepository(ctx context.Context, r *models.Repository) (map[string]string, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_label_find_by_repository")()
+	q := `SELECT
+			key,
+			value
+		FROM
+			repository_labels
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("finding repository labels: %w", err)
+	}
+	defer rows.Close()
+
+	labels := make(map[string]string)
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, fmt.Errorf("scanning repository label: %w", err)
+		}
+		labels[k] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning repository labels: %w", err)
+	}
+
+	return labels, nil
+}
+
+// ReplaceForRepository replaces all labels of a given repository with the given ones. This should be executed within a
+// transaction to avoid leaving the repository with no labels in case of failure.
+func (s *repositoryLabelStore) ReplaceForRepository(ctx context.Context, r *models.Repository, labels map[string]string) error {
+	defer metrics.InstrumentQuery(ctx, "repository_label_replace_for_repository")()
+
+	q := `DELETE FROM repository_labels
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2`
+	if _, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID); err != nil {
+		return fmt.Errorf("deleting repository labels: %w", err)
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	q = `INSERT INTO repository_labels (top_level_namespace_id, repository_id, key, value)
+			VALUES %s`
+
+	values := make([]string, 0, len(keys))
+	args := []any{r.NamespaceID, r.ID}
+	for _, k := range keys {
+		values = append(values, fmt.Sprintf("($1, $2, $%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, k, labels[k])
+	}
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(q, strings.Join(values, ", ")), args...); err != nil {
+		return fmt.Errorf("creating repository labels: %w", err)
+	}
+
+	return nil
+}
+
+// repositoryLabelFilters builds the SQL conditions to filter repositories by the labels in `filters.Labels`, for a query
+// on the repositories table aliased as `r`. Repositories must satisfy all conditions. Placeholders are numbered starting
+// at `argPos`. An empty string and no arguments are returned if no label conditions are set.
+func repositoryLabelFilters(filters FilterParams, argPos int) (string, []any) {
+	var (
+		conds strings.Builder
+		args  []any
+	)
+	for _, match := range filters.Labels {
+		conds.WriteString(`
+			AND EXISTS (
+				SELECT
+				FROM
+					repository_labels AS rl
+				WHERE
+					rl.top_level_namespace_id = r.top_level_namespace_id
+					AND rl.repository_id = r.id`)
+		args = append(args, match.Key)
+		fmt.Fprintf(&conds, "\n\t\t\t\t\tAND rl.key = $%d", argPos+len(args)-1)
+		if !match.AnyValue {
+			args = append(args, match.Value)
+			fmt.Fprintf(&conds, "\n\t\t\t\t\tAND rl.value = $%d", argPos+len(args)-1)
+		}
+		conds.WriteString(")")
+	}
+
+	return conds.String(), args
+}
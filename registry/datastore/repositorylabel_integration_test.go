@@ -0,0 +1,154 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryLabelStore_ReplaceForRepository(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.RepositoryLabelsTable))
+
+	s := datastore.NewRepositoryLabelStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	ll, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Empty(t, ll)
+
+	err = s.ReplaceForRepository(suite.ctx, r, map[string]string{"team": "backend", "compliance": "pci", "empty": ""})
+	require.NoError(t, err)
+
+	ll, err = s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team": "backend", "compliance": "pci", "empty": ""}, ll)
+
+	err = s.ReplaceForRepository(suite.ctx, r, map[string]string{"team": "frontend"})
+	require.NoError(t, err)
+
+	ll, err = s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team": "frontend"}, ll)
+
+	// other repositories are not affected
+	ll, err = s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+	require.Empty(t, ll)
+}
+
+func TestRepositoryLabelStore_ReplaceForRepository_Empty(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.RepositoryLabelsTable))
+
+	s := datastore.NewRepositoryLabelStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	require.NoError(t, s.ReplaceForRepository(suite.ctx, r, map[string]string{"team": "backend"}))
+	require.NoError(t, s.ReplaceForRepository(suite.ctx, r, nil))
+
+	ll, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Empty(t, ll)
+}
+
+func TestRepositoryLabelStore_ReplaceForRepository_ValueTooLong(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.RepositoryLabelsTable))
+
+	s := datastore.NewRepositoryLabelStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	err := s.ReplaceForRepository(suite.ctx, r, map[string]string{"team": strings.Repeat("a", datastore.MaxRepositoryLabelValueLength+1)})
+	require.Error(t, err)
+}
+
+func TestRepositoryStore_LabelFilters(t *testing.T) {
+	reloadTagFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.RepositoryLabelsTable))
+
+	// see testdata/fixtures/[repositories|manifests|tags].sql
+	ls := datastore.NewRepositoryLabelStore(suite.db)
+	require.NoError(t, ls.ReplaceForRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3}, map[string]string{"team": "backend", "tier": "1"}))
+	require.NoError(t, ls.ReplaceForRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4}, map[string]string{"team": "frontend"}))
+	require.NoError(t, ls.ReplaceForRepository(suite.ctx, &models.Repository{NamespaceID: 2, ID: 6}, map[string]string{"team": "backend"}))
+
+	tt := []struct {
+		name                 string
+		labels               []datastore.RepositoryLabelMatch
+		expectedCatalogPaths []string
+		expectedGroupPaths   []string
+	}{
+		{
+			name:                 "key and value",
+			labels:               []datastore.RepositoryLabelMatch{{Key: "team", Value: "backend"}},
+			expectedCatalogPaths: []string{"a-test-group/foo", "gitlab-org/gitlab-test/backend"},
+			expectedGroupPaths:   []string{"gitlab-org/gitlab-test/backend"},
+		},
+		{
+			name:                 "key only",
+			labels:               []datastore.RepositoryLabelMatch{{Key: "team", AnyValue: true}},
+			expectedCatalogPaths: []string{"a-test-group/foo", "gitlab-org/gitlab-test/backend", "gitlab-org/gitlab-test/frontend"},
+			expectedGroupPaths:   []string{"gitlab-org/gitlab-test/backend", "gitlab-org/gitlab-test/frontend"},
+		},
+		{
+			name:                 "multiple",
+			labels:               []datastore.RepositoryLabelMatch{{Key: "team", AnyValue: true}, {Key: "tier", Value: "1"}},
+			expectedCatalogPaths: []string{"gitlab-org/gitlab-test/backend"},
+			expectedGroupPaths:   []string{"gitlab-org/gitlab-test/backend"},
+		},
+		{
+			name:                 "no match",
+			labels:               []datastore.RepositoryLabelMatch{{Key: "team", Value: "ops"}},
+			expectedCatalogPaths: []string{},
+			expectedGroupPaths:   []string{},
+		},
+	}
+
+	s := datastore.NewRepositoryStore(suite.db)
+	group := &models.Repository{NamespaceID: 1, Path: "gitlab-org"}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			filters := datastore.FilterParams{MaxEntries: 100, Labels: test.labels}
+
+			rr, err := s.FindAllPaginated(suite.ctx, filters)
+			require.NoError(t, err)
+			paths := make([]string, 0, len(rr))
+			for _, r := range rr {
+				paths = append(paths, r.Path)
+			}
+			require.Equal(t, test.expectedCatalogPaths, paths)
+
+			c, err := s.CountPaginated(suite.ctx, filters)
+			require.NoError(t, err)
+			require.Equal(t, len(test.expectedCatalogPaths), c)
+
+			rr, err = s.FindPagingatedRepositoriesForPath(suite.ctx, group, filters)
+			require.NoError(t, err)
+			paths = make([]string, 0, len(rr))
+			for _, r := range rr {
+				paths = append(paths, r.Path)
+			}
+			require.Equal(t, test.expectedGroupPaths, paths)
+
+			ss, err := s.FindPaginatedSummariesForPath(suite.ctx, group, filters)
+			require.NoError(t, err)
+			paths = make([]string, 0, len(ss))
+			for _, rs := range ss {
+				paths = append(paths, rs.Path)
+			}
+			require.Equal(t, test.expectedGroupPaths, paths)
+
+			c, err = s.CountRepositoriesWithTagsForPath(suite.ctx, group, filters)
+			require.NoError(t, err)
+			require.Equal(t, len(test.expectedGroupPaths), c)
+		})
+	}
+}
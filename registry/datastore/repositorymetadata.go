@@ -0,0 +1,112 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// RepositoryMetadataStore is the interface that a repository metadata store should conform to.
+type RepositoryMetadataStore interface {
+	FindByRepository(ctx context.Context, r *models.Repository) (*models.RepositoryMetadata, error)
+	CreateOrUpdate(ctx context.Context, m *models.RepositoryMetadata) error
+	Delete(ctx context.Context, r *models.Repository) (bool, error)
+}
+
+// repositoryMetadataStore is the concrete implementation of a RepositoryMetadataStore.
+type repositoryMetadataStore struct {
+	db Queryer
+}
+
+// NewRepositoryMetadataStore builds a new repository metadata store.
+func NewRepositoryMetadataStore(db Queryer) RepositoryMetadataStore {
+	return &repositoryMetadataStore{db: db}
+}
+
+// FindByRepository finds the metadata of a given repository. Returns nil if the repository has no metadata.
+func (s *repositoryMetadataStore) FindByRepository(ctx context.Context, r *models.Repository) (*models.RepositoryMetadata, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_metadata_find_by_repository")()
+	q := `SELECT
+			top_level_namespace_id,
+			repository_id,
+			description,
+			readme,
+			links,
+			created_at,
+			updated_at
+		FROM
+			repository_metadata
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	var links []byte
+	m := new(models.RepositoryMetadata)
+	row := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID)
+	if err := row.Scan(&m.NamespaceID, &m.RepositoryID, &m.Description, &m.Readme, &links, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("scanning repository metadata: %w", err)
+		}
+		return nil, nil
+	}
+	if err := json.Unmarshal(links, &m.Links); err != nil {
+		return nil, fmt.Errorf("parsing repository metadata links: %w", err)
+	}
+
+	return m, nil
+}
+
+// CreateOrUpdate creates the metadata of a repository or replaces the existing one.
+func (s *repositoryMetadataStore) CreateOrUpdate(ctx context.Context, m *models.RepositoryMetadata) error {
+	defer metrics.InstrumentQuery(ctx, "repository_metadata_create_or_update")()
+	q := `INSERT INTO repository_metadata (top_level_namespace_id, repository_id, description, readme, links)
+			VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (top_level_namespace_id, repository_id)
+			DO UPDATE SET
+				description = EXCLUDED.description,
+				readme = EXCLUDED.readme,
+				links = EXCLUDED.links,
+				updated_at = now()
+		RETURNING
+			created_at, updated_at`
+
+	links := m.Links
+	if links == nil {
+		links = make([]models.RepositoryLink, 0)
+	}
+	payload, err := json.Marshal(links)
+	if err != nil {
+		return fmt.Errorf("marshaling repository metadata links: %w", err)
+	}
+
+	row := s.db.QueryRowContext(ctx, q, m.NamespaceID, m.RepositoryID, m.Description, m.Readme, payload)
+	if err := row.Scan(&m.CreatedAt, &m.UpdatedAt); err != nil {
+		return fmt.Errorf("creating or updating repository metadata: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes the metadata of a repository. Returns false if the repository had no metadata.
+func (s *repositoryMetadataStore) Delete(ctx context.Context, r *models.Repository) (bool, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_metadata_delete")()
+	q := `DELETE FROM repository_metadata
+		WHERE top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	res, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID)
+	if err != nil {
+		return false, fmt.Errorf("deleting repository metadata: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("deleting repository metadata: %w", err)
+	}
+
+	return n == 1, nil
+}
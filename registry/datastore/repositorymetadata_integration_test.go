@@ -0,0 +1,102 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadRepositoryMetadataFixtures(tb testing.TB) {
+	tb.Helper()
+
+	reloadRepositoryFixtures(tb)
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.RepositoryMetadataTable))
+}
+
+func TestRepositoryMetadataStore_CreateOrUpdate(t *testing.T) {
+	reloadRepositoryMetadataFixtures(t)
+
+	s := datastore.NewRepositoryMetadataStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	m, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Nil(t, m)
+
+	// create
+	m = &models.RepositoryMetadata{
+		NamespaceID:  r.NamespaceID,
+		RepositoryID: r.ID,
+		Description:  "An example image",
+		Readme:       "# Example\n\nUsage: `docker run example`",
+		Links: []models.RepositoryLink{
+			{Name: "Source", URL: "https://gitlab.com/example/example"},
+			{Name: "Docs", URL: "https://example.com/docs"},
+		},
+	}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, m))
+	require.NotZero(t, m.CreatedAt)
+	require.False(t, m.UpdatedAt.Valid)
+
+	got, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, m.Description, got.Description)
+	require.Equal(t, m.Readme, got.Readme)
+	require.Equal(t, m.Links, got.Links)
+
+	// update
+	m.Description = ""
+	m.Links = nil
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, m))
+	require.True(t, m.UpdatedAt.Valid)
+
+	got, err = s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Empty(t, got.Description)
+	require.Equal(t, m.Readme, got.Readme)
+	require.Empty(t, got.Links)
+
+	// other repositories are not affected
+	got, err = s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestRepositoryMetadataStore_CreateOrUpdate_DescriptionTooLong(t *testing.T) {
+	reloadRepositoryMetadataFixtures(t)
+
+	s := datastore.NewRepositoryMetadataStore(suite.db)
+	m := &models.RepositoryMetadata{
+		NamespaceID:  1,
+		RepositoryID: 3,
+		Description:  strings.Repeat("a", 256),
+	}
+	require.Error(t, s.CreateOrUpdate(suite.ctx, m))
+}
+
+func TestRepositoryMetadataStore_Delete(t *testing.T) {
+	reloadRepositoryMetadataFixtures(t)
+
+	s := datastore.NewRepositoryMetadataStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	found, err := s.Delete(suite.ctx, r)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, &models.RepositoryMetadata{NamespaceID: r.NamespaceID, RepositoryID: r.ID, Description: "foo"}))
+
+	found, err = s.Delete(suite.ctx, r)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	m, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
@@ -0,0 +1,270 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+)
+
+// RepositoryPurgeStore is the interface that a repository purge store should conform to.
+type RepositoryPurgeStore interface {
+	FindByRepository(ctx context.Context, r *models.Repository) (*models.RepositoryPurge, error)
+	Start(ctx context.Context, r *models.Repository) (*models.RepositoryPurge, bool, error)
+	ClaimNextDue(ctx context.Context, lease time.Duration) (*models.RepositoryPurge, error)
+	Update(ctx context.Context, p *models.RepositoryPurge) error
+	FindUnreferencedManifests(ctx context.Context, r *models.Repository, limit int) ([]digest.Digest, error)
+	UnlinkBlobs(ctx context.Context, r *models.Repository, limit int) (int64, error)
+}
+
+// repositoryPurgeStore is the concrete implementation of a RepositoryPurgeStore.
+type repositoryPurgeStore struct {
+	db Queryer
+}
+
+// NewRepositoryPurgeStore builds a new repository purge store.
+func NewRepositoryPurgeStore(db Queryer) RepositoryPurgeStore {
+	return &repositoryPurgeStore{db: db}
+}
+
+const repositoryPurgeColumns = `rp.top_level_namespace_id,
+			rp.repository_id,
+			rp.tags_deleted,
+			rp.manifests_deleted,
+			rp.blobs_unlinked,
+			rp.last_error,
+			rp.next_run_at,
+			rp.completed_at,
+			rp.created_at,
+			rp.updated_at`
+
+func scanRepositoryPurge(row *sql.Row, extra ...any) (*models.RepositoryPurge, error) {
+	p := new(models.RepositoryPurge)
+
+	dest := []any{
+		&p.NamespaceID, &p.RepositoryID, &p.TagsDeleted, &p.ManifestsDeleted, &p.BlobsUnlinked, &p.LastError,
+		&p.NextRunAt, &p.CompletedAt, &p.CreatedAt, &p.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("scanning repository purge: %w", err)
+		}
+		return nil, nil
+	}
+
+	return p, nil
+}
+
+// FindByRepository finds the last purge of a given repository. Returns nil if the repository was never purged.
+func (s *repositoryPurgeStore) FindByRepository(ctx context.Context, r *models.Repository) (*models.RepositoryPurge, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_purge_find_by_repository")()
+	q := `SELECT
+			` + repositoryPurgeColumns + `
+		FROM
+			repository_purges AS rp
+		WHERE
+			rp.top_level_namespace_id = $1
+			AND rp.repository_id = $2`
+
+	return scanRepositoryPurge(s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID))
+}
+
+// Start starts a purge of a given repository, due right away, replacing any completed one. If a purge is already in
+// progress, it is returned as is. A boolean is returned to denote whether a new purge was started or not.
+func (s *repositoryPurgeStore) Start(ctx context.Context, r *models.Repository) (*models.RepositoryPurge, bool, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_purge_start")()
+	q := `INSERT INTO repository_purges AS rp (top_level_namespace_id, repository_id)
+			VALUES ($1, $2)
+		ON CONFLICT (top_level_namespace_id, repository_id)
+			DO UPDATE SET
+				created_at = now(),
+				updated_at = NULL,
+				next_run_at = now(),
+				completed_at = NULL,
+				tags_deleted = 0,
+				manifests_deleted = 0,
+				blobs_unlinked = 0,
+				last_error = NULL
+			WHERE
+				rp.completed_at IS NOT NULL
+		RETURNING
+			` + repositoryPurgeColumns
+
+	p, err := scanRepositoryPurge(s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID))
+	if err != nil {
+		return nil, false, fmt.Errorf("starting repository purge: %w", err)
+	}
+	if p != nil {
+		return p, true, nil
+	}
+
+	// nothing was returned because a purge is already in progress
+	p, err = s.FindByRepository(ctx, r)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return p, false, nil
+}
+
+// ClaimNextDue finds the purge in progress that has been due the longest and postpones its next run by lease, so that
+// it is not claimed again while being processed. Purges being claimed concurrently are skipped, allowing multiple
+// registry instances to cooperate. If the instance processing a purge stops, the purge is claimed again once the lease
+// expires. Returns nil if no purge is due.
+func (s *repositoryPurgeStore) ClaimNextDue(ctx context.Context, lease time.Duration) (*models.RepositoryPurge, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_purge_claim_next_due")()
+	q := `UPDATE
+			repository_purges AS rp
+		SET
+			next_run_at = now() + make_interval(secs => $1)
+		FROM
+			repositories AS r
+		WHERE (rp.top_level_namespace_id, rp.repository_id) = (
+				SELECT
+					top_level_namespace_id,
+					repository_id
+				FROM
+					repository_purges
+				WHERE
+					completed_at IS NULL
+					AND next_run_at <= now()
+				ORDER BY
+					next_run_at
+				LIMIT 1
+				FOR UPDATE
+					SKIP LOCKED)
+			AND r.top_level_namespace_id = rp.top_level_namespace_id
+			AND r.id = rp.repository_id
+		RETURNING
+			` + repositoryPurgeColumns + `,
+			r.path`
+
+	var path string
+	p, err := scanRepositoryPurge(s.db.QueryRowContext(ctx, q, lease.Seconds()), &path)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	p.RepositoryPath = path
+
+	return p, nil
+}
+
+// Update records the progress of a given purge, namely the deleted counts, the last error and the next run. The purge
+// is marked as completed if its CompletedAt attribute is valid, using the current time.
+func (s *repositoryPurgeStore) Update(ctx context.Context, p *models.RepositoryPurge) error {
+	defer metrics.InstrumentQuery(ctx, "repository_purge_update")()
+	q := `UPDATE
+			repository_purges
+		SET
+			tags_deleted = $3,
+			manifests_deleted = $4,
+			blobs_unlinked = $5,
+			last_error = $6,
+			next_run_at = $7,
+			completed_at = CASE WHEN $8 THEN
+				now()
+			ELSE
+				NULL
+			END,
+			updated_at = now()
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+		RETURNING
+			completed_at, updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, p.NamespaceID, p.RepositoryID, p.TagsDeleted, p.ManifestsDeleted, p.BlobsUnlinked,
+		p.LastError, p.NextRunAt, p.CompletedAt.Valid)
+	if err := row.Scan(&p.CompletedAt, &p.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the repository was deleted in the meantime, and the purge with it
+			return nil
+		}
+		return fmt.Errorf("updating repository purge: %w", err)
+	}
+
+	return nil
+}
+
+// FindUnreferencedManifests finds up to limit manifests of a given repository that are not referenced by any other
+// manifest of the repository, such as manifest lists and images not part of any list. These can be deleted right away,
+// while the manifests they reference can only be deleted once they are.
+func (s *repositoryPurgeStore) FindUnreferencedManifests(ctx context.Context, r *models.Repository, limit int) ([]digest.Digest, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_purge_find_unreferenced_manifests")()
+	q := `SELECT
+			encode(m.digest, 'hex')
+		FROM
+			manifests AS m
+		WHERE
+			m.top_level_namespace_id = $1
+			AND m.repository_id = $2
+			AND NOT EXISTS (
+				SELECT
+					1
+				FROM
+					manifest_references AS mr
+				WHERE
+					mr.top_level_namespace_id = m.top_level_namespace_id
+					AND mr.repository_id = m.repository_id
+					AND mr.child_id = m.id)
+		ORDER BY
+			m.id
+		LIMIT $3`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding unreferenced manifests: %w", err)
+	}
+	defer rows.Close()
+
+	dd := make([]digest.Digest, 0)
+	for rows.Next() {
+		var dgst Digest
+		if err := rows.Scan(&dgst); err != nil {
+			return nil, fmt.Errorf("scanning manifest digest: %w", err)
+		}
+		d, err := dgst.Parse()
+		if err != nil {
+			return nil, err
+		}
+		dd = append(dd, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("finding unreferenced manifests: %w", err)
+	}
+
+	return dd, nil
+}
+
+// UnlinkBlobs unlinks up to limit blobs from a given repository. Returns the number of unlinked blobs.
+func (s *repositoryPurgeStore) UnlinkBlobs(ctx context.Context, r *models.Repository, limit int) (int64, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_purge_unlink_blobs")()
+	q := `DELETE FROM repository_blobs
+		WHERE (top_level_namespace_id, repository_id, id) IN (
+				SELECT
+					top_level_namespace_id,
+					repository_id,
+					id
+				FROM
+					repository_blobs
+				WHERE
+					top_level_namespace_id = $1
+					AND repository_id = $2
+				LIMIT $3)`
+
+	res, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID, limit)
+	if err != nil {
+		return 0, fmt.Errorf("unlinking blobs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("unlinking blobs: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,135 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadRepositoryPurgeFixtures(tb testing.TB) {
+	tb.Helper()
+
+	testutil.ReloadFixtures(
+		tb, suite.db, suite.basePath,
+		testutil.NamespacesTable, testutil.RepositoriesTable, testutil.BlobsTable, testutil.ManifestsTable,
+		testutil.TagsTable, testutil.ManifestReferencesTable, testutil.RepositoryBlobsTable,
+	)
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.RepositoryPurgesTable))
+}
+
+func TestRepositoryPurgeStore_Start(t *testing.T) {
+	reloadRepositoryPurgeFixtures(t)
+
+	s := datastore.NewRepositoryPurgeStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	p, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	// start
+	p, started, err := s.Start(suite.ctx, r)
+	require.NoError(t, err)
+	require.True(t, started)
+	require.Equal(t, r.ID, p.RepositoryID)
+	require.False(t, p.Completed())
+	require.WithinDuration(t, time.Now(), p.NextRunAt, time.Minute)
+
+	// a purge in progress is left as is
+	p.TagsDeleted = 5
+	require.NoError(t, s.Update(suite.ctx, p))
+	got, started, err := s.Start(suite.ctx, r)
+	require.NoError(t, err)
+	require.False(t, started)
+	require.EqualValues(t, 5, got.TagsDeleted)
+
+	// a completed purge is replaced
+	p.CompletedAt.Valid = true
+	require.NoError(t, s.Update(suite.ctx, p))
+	require.True(t, p.Completed())
+	got, started, err = s.Start(suite.ctx, r)
+	require.NoError(t, err)
+	require.True(t, started)
+	require.False(t, got.Completed())
+	require.Zero(t, got.TagsDeleted)
+}
+
+func TestRepositoryPurgeStore_ClaimNextDue(t *testing.T) {
+	reloadRepositoryPurgeFixtures(t)
+
+	s := datastore.NewRepositoryPurgeStore(suite.db)
+	r3 := &models.Repository{NamespaceID: 1, ID: 3}
+	r4 := &models.Repository{NamespaceID: 1, ID: 4}
+
+	p, err := s.ClaimNextDue(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	_, _, err = s.Start(suite.ctx, r3)
+	require.NoError(t, err)
+	p4, _, err := s.Start(suite.ctx, r4)
+	require.NoError(t, err)
+
+	// postponed purges are not due
+	p4.NextRunAt = time.Now().Add(time.Hour)
+	require.NoError(t, s.Update(suite.ctx, p4))
+
+	p, err = s.ClaimNextDue(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, r3.ID, p.RepositoryID)
+	require.Equal(t, "gitlab-org/gitlab-test/backend", p.RepositoryPath)
+	require.True(t, p.NextRunAt.After(time.Now()))
+
+	// claimed purges are leased
+	p, err = s.ClaimNextDue(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, p)
+}
+
+func TestRepositoryPurgeStore_FindUnreferencedManifests(t *testing.T) {
+	reloadRepositoryPurgeFixtures(t)
+
+	s := datastore.NewRepositoryPurgeStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	// manifests 1 and 2 are referenced by the manifest list 6
+	dd, err := s.FindUnreferencedManifests(suite.ctx, r, 10)
+	require.NoError(t, err)
+	require.Equal(t, []digest.Digest{"sha256:dc27c897a7e24710a2821878456d56f3965df7cc27398460aa6f21f8b385d2d0"}, dd)
+
+	dd, err = s.FindUnreferencedManifests(suite.ctx, &models.Repository{NamespaceID: 1, ID: 1}, 10)
+	require.NoError(t, err)
+	require.Empty(t, dd)
+}
+
+func TestRepositoryPurgeStore_UnlinkBlobs(t *testing.T) {
+	reloadRepositoryPurgeFixtures(t)
+
+	s := datastore.NewRepositoryPurgeStore(suite.db)
+	rs := datastore.NewRepositoryStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	bb, err := rs.Blobs(suite.ctx, r)
+	require.NoError(t, err)
+	require.Greater(t, len(bb), 2)
+
+	n, err := s.UnlinkBlobs(suite.ctx, r, 2)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, n)
+
+	n, err = s.UnlinkBlobs(suite.ctx, r, 100)
+	require.NoError(t, err)
+	require.EqualValues(t, len(bb)-2, n)
+
+	bb, err = rs.Blobs(suite.ctx, r)
+	require.NoError(t, err)
+	require.Empty(t, bb)
+}
@@ -0,0 +1,76 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// RepositorySettingsStore is the interface that a repository settings store should conform to.
+type RepositorySettingsStore interface {
+	FindByRepository(ctx context.Context, r *models.Repository) (*models.RepositorySettings, error)
+	CreateOrUpdate(ctx context.Context, s *models.RepositorySettings) error
+}
+
+// repositorySettingsStore is the concrete implementation of a RepositorySettingsStore.
+type repositorySettingsStore struct {
+	db Queryer
+}
+
+// NewRepositorySettingsStore builds a new repository settings store.
+func NewRepositorySettingsStore(db Queryer) RepositorySettingsStore {
+	return &repositorySettingsStore{db: db}
+}
+
+// FindByRepository finds the settings of a given repository. Returns nil if the repository has no settings.
+func (s *repositorySettingsStore) FindByRepository(ctx context.Context, r *models.Repository) (*models.RepositorySettings, error) {
+	defer metrics.InstrumentQuery(ctx, "repository_settings_find_by_repository")()
+	q := `SELECT
+			top_level_namespace_id,
+			repository_id,
+			manifest_payload_size_limit,
+			read_only,
+			created_at,
+			updated_at
+		FROM
+			repository_settings
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2`
+
+	rs := new(models.RepositorySettings)
+	row := s.db.QueryRowContext(ctx, q, r.NamespaceID, r.ID)
+	if err := row.Scan(&rs.NamespaceID, &rs.RepositoryID, &rs.ManifestPayloadSizeLimit, &rs.ReadOnly, &rs.CreatedAt, &rs.UpdatedAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("scanning repository settings: %w", err)
+		}
+		return nil, nil
+	}
+
+	return rs, nil
+}
+
+// CreateOrUpdate creates the settings of a repository or replaces the existing ones.
+func (s *repositorySettingsStore) CreateOrUpdate(ctx context.Context, rs *models.RepositorySettings) error {
+	defer metrics.InstrumentQuery(ctx, "repository_settings_create_or_update")()
+	q := `INSERT INTO repository_settings (top_level_namespace_id, repository_id, manifest_payload_size_limit, read_only)
+			VALUES ($1, $2, $3, $4)
+		ON CONFLICT (top_level_namespace_id, repository_id)
+			DO UPDATE SET
+				manifest_payload_size_limit = EXCLUDED.manifest_payload_size_limit,
+				read_only = EXCLUDED.read_only,
+				updated_at = now()
+		RETURNING
+			created_at, updated_at`
+
+	row := s.db.QueryRowContext(ctx, q, rs.NamespaceID, rs.RepositoryID, rs.ManifestPayloadSizeLimit, rs.ReadOnly)
+	if err := row.Scan(&rs.CreatedAt, &rs.UpdatedAt); err != nil {
+		return fmt.Errorf("creating or updating repository settings: %w", err)
+	}
+
+	return nil
+}
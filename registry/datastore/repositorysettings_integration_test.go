@@ -0,0 +1,75 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadRepositorySettingsFixtures(tb testing.TB) {
+	tb.Helper()
+
+	reloadRepositoryFixtures(tb)
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.RepositorySettingsTable))
+}
+
+func TestRepositorySettingsStore_CreateOrUpdate(t *testing.T) {
+	reloadRepositorySettingsFixtures(t)
+
+	s := datastore.NewRepositorySettingsStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	rs, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Nil(t, rs)
+
+	// create
+	rs = &models.RepositorySettings{
+		NamespaceID:              r.NamespaceID,
+		RepositoryID:             r.ID,
+		ManifestPayloadSizeLimit: sql.NullInt64{Int64: 1024, Valid: true},
+		ReadOnly:                 true,
+	}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, rs))
+	require.NotZero(t, rs.CreatedAt)
+	require.False(t, rs.UpdatedAt.Valid)
+
+	got, err := s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, rs.ManifestPayloadSizeLimit, got.ManifestPayloadSizeLimit)
+	require.True(t, got.ReadOnly)
+
+	// update
+	rs.ManifestPayloadSizeLimit = sql.NullInt64{}
+	rs.ReadOnly = false
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, rs))
+	require.True(t, rs.UpdatedAt.Valid)
+
+	got, err = s.FindByRepository(suite.ctx, r)
+	require.NoError(t, err)
+	require.False(t, got.ManifestPayloadSizeLimit.Valid)
+	require.False(t, got.ReadOnly)
+
+	// other repositories are not affected
+	got, err = s.FindByRepository(suite.ctx, &models.Repository{NamespaceID: 1, ID: 4})
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestRepositorySettingsStore_CreateOrUpdate_InvalidLimit(t *testing.T) {
+	reloadRepositorySettingsFixtures(t)
+
+	s := datastore.NewRepositorySettingsStore(suite.db)
+	rs := &models.RepositorySettings{
+		NamespaceID:              1,
+		RepositoryID:             3,
+		ManifestPayloadSizeLimit: sql.NullInt64{Int64: 0, Valid: true},
+	}
+	require.Error(t, s.CreateOrUpdate(suite.ctx, rs))
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"path"
 
 	"github.com/docker/distribution/registry/datastore/metrics"
 	"github.com/docker/distribution/registry/datastore/models"
@@ -35,11 +36,40 @@ type TagStore interface {
 // tagStore is the concrete implementation of a TagStore.
 type tagStore struct {
 	db Queryer
+	// immutablePatterns is a list of glob patterns. Existing tags whose name match any of these cannot be updated.
+	immutablePatterns []string
+}
+
+// TagStoreOption allows customizing a tagStore with additional options.
+type TagStoreOption func(*tagStore)
+
+// WithImmutableTagPatterns configures the store to reject updates to existing tags whose name matches any of the
+// given glob patterns (see https://pkg.go.dev/path#Match).
+func WithImmutableTagPatterns(patterns ...string) TagStoreOption {
+	return func(s *tagStore) {
+		s.immutablePatterns = append(s.immutablePatterns, patterns...)
+	}
 }
 
 // NewTagStore builds a new tag store.
-func NewTagStore(db Queryer) *tagStore {
-	return &tagStore{db: db}
+func NewTagStore(db Queryer, opts ...TagStoreOption) *tagStore {
+	s := &tagStore{db: db}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+// IsImmutableTagName returns true if name matches any of the given glob patterns. Malformed patterns never match.
+func IsImmutableTagName(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(p, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
 }
 
 func scanFullTag(row *sql.Row) (*models.Tag, error) {
@@ -75,7 +105,7 @@ func scanFullTags(rows *sql.Rows) (models.Tags, error) {
 
 // FindByID finds a Tag by ID.
 func (s *tagStore) FindByID(ctx context.Context, id int64) (*models.Tag, error) {
-	defer metrics.InstrumentQuery("tag_find_by_id")()
+	defer metrics.InstrumentQuery(ctx, "tag_find_by_id")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -95,7 +125,7 @@ func (s *tagStore) FindByID(ctx context.Context, id int64) (*models.Tag, error)
 
 // FindAll finds all tags.
 func (s *tagStore) FindAll(ctx context.Context) (models.Tags, error) {
-	defer metrics.InstrumentQuery("tag_find_all")()
+	defer metrics.InstrumentQuery(ctx, "tag_find_all")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -116,7 +146,7 @@ func (s *tagStore) FindAll(ctx context.Context) (models.Tags, error) {
 
 // Count counts all tags.
 func (s *tagStore) Count(ctx context.Context) (int, error) {
-	defer metrics.InstrumentQuery("tag_count")()
+	defer metrics.InstrumentQuery(ctx, "tag_count")()
 	q := "SELECT COUNT(*) FROM tags"
 	var count int
 
@@ -129,7 +159,7 @@ func (s *tagStore) Count(ctx context.Context) (int, error) {
 
 // Repository finds a tag repository.
 func (s *tagStore) Repository(ctx context.Context, t *models.Tag) (*models.Repository, error) {
-	defer metrics.InstrumentQuery("tag_repository")()
+	defer metrics.InstrumentQuery(ctx, "tag_repository")()
 	q := `SELECT
 			id,
 			top_level_namespace_id,
@@ -150,7 +180,7 @@ func (s *tagStore) Repository(ctx context.Context, t *models.Tag) (*models.Repos
 
 // Manifest finds a tag manifest. A tag can be associated with either a manifest or a manifest list.
 func (s *tagStore) Manifest(ctx context.Context, t *models.Tag) (*models.Manifest, error) {
-	defer metrics.InstrumentQuery("tag_manifest")()
+	defer metrics.InstrumentQuery(ctx, "tag_manifest")()
 	q := `SELECT
 			m.id,
 			m.top_level_namespace_id,
@@ -166,11 +196,15 @@ func (s *tagStore) Manifest(ctx context.Context, t *models.Tag) (*models.Manifes
 			m.non_conformant,
 			m.non_distributable_layers,
 			m.subject_id,
+			mta.media_type as artifact_media_type,
+			m.chart_name,
+			m.chart_version,
 			m.created_at
 		FROM
 			manifests AS m
 			JOIN media_types AS mt ON mt.id = m.media_type_id
 			LEFT JOIN media_types AS mtc ON mtc.id = m.configuration_media_type_id
+			LEFT JOIN media_types AS mta ON mta.id = m.artifact_media_type_id
 		WHERE
 			m.top_level_namespace_id = $1
 			AND m.repository_id = $2
@@ -182,9 +216,14 @@ func (s *tagStore) Manifest(ctx context.Context, t *models.Tag) (*models.Manifes
 
 // CreateOrUpdate upsert a tag. A tag with a given name on a given repository may not exist (in which case it should be
 // inserted), already exist and point to the same manifest (in which case nothing needs to be done) or already exist but
-// points to a different manifest (in which case it should be updated).
+// points to a different manifest (in which case it should be updated). If the tag name matches any of the immutable
+// patterns configured for the store, updates are rejected with ErrTagImmutable.
 func (s *tagStore) CreateOrUpdate(ctx context.Context, t *models.Tag) error {
-	defer metrics.InstrumentQuery("tag_create_or_update")()
+	if IsImmutableTagName(t.Name, s.immutablePatterns) {
+		return s.createImmutable(ctx, t)
+	}
+
+	defer metrics.InstrumentQuery(ctx, "tag_create_or_update")()
 	q := `INSERT INTO tags (top_level_namespace_id, repository_id, manifest_id, name)
 		   VALUES ($1, $2, $3, $4)
 	   ON CONFLICT (top_level_namespace_id, repository_id, name)
@@ -207,3 +246,47 @@ func (s *tagStore) CreateOrUpdate(ctx context.Context, t *models.Tag) error {
 
 	return nil
 }
+
+// createImmutable inserts a tag that cannot be updated once created. If the tag already exists and points to the same
+// manifest nothing needs to be done, otherwise ErrTagImmutable is returned.
+func (s *tagStore) createImmutable(ctx context.Context, t *models.Tag) error {
+	defer metrics.InstrumentQuery(ctx, "tag_create_immutable")()
+	q := `WITH ins AS (
+			INSERT INTO tags (top_level_namespace_id, repository_id, manifest_id, name)
+				VALUES ($1, $2, $3, $4)
+			ON CONFLICT (top_level_namespace_id, repository_id, name)
+				DO NOTHING
+			RETURNING
+				id, manifest_id, created_at, updated_at
+		)
+		SELECT
+			id, manifest_id, created_at, updated_at
+		FROM
+			ins
+		UNION ALL
+		SELECT
+			id, manifest_id, created_at, updated_at
+		FROM
+			tags
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name = $4
+		LIMIT 1`
+
+	var manifestID int64
+	row := s.db.QueryRowContext(ctx, q, t.NamespaceID, t.RepositoryID, t.ManifestID, t.Name)
+	if err := row.Scan(&t.ID, &manifestID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		// this can happen if the manifest is deleted by the online GC while attempting to tag an untagged manifest
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return ErrManifestNotFound
+		}
+		return fmt.Errorf("creating immutable tag: %w", err)
+	}
+	if manifestID != t.ManifestID {
+		return ErrTagImmutable
+	}
+
+	return nil
+}
@@ -422,6 +422,50 @@ func TestTagStore_CreateOrUpdate_Idempotent(t *testing.T) {
 	require.Empty(t, tag.UpdatedAt)
 }
 
+func TestTagStore_CreateOrUpdate_Immutable(t *testing.T) {
+	reloadRepositoryFixtures(t)
+	reloadManifestFixtures(t)
+	require.NoError(t, testutil.TruncateTables(suite.db, testutil.TagsTable))
+
+	s := datastore.NewTagStore(suite.db, datastore.WithImmutableTagPatterns("1.*"))
+
+	// create tag
+	tag := &models.Tag{
+		NamespaceID:  1,
+		Name:         "1.0.0",
+		RepositoryID: 3,
+		ManifestID:   1,
+	}
+	err := s.CreateOrUpdate(suite.ctx, tag)
+	require.NoError(t, err)
+	require.NotEmpty(t, tag.ID)
+	require.NotEmpty(t, tag.CreatedAt)
+
+	// retry is a no-op
+	id := tag.ID
+	err = s.CreateOrUpdate(suite.ctx, tag)
+	require.NoError(t, err)
+	require.Equal(t, id, tag.ID)
+	require.Empty(t, tag.UpdatedAt)
+
+	// switching tag to another manifest is not allowed
+	tag.ManifestID = 2
+	err = s.CreateOrUpdate(suite.ctx, tag)
+	require.ErrorIs(t, err, datastore.ErrTagImmutable)
+
+	// tags that do not match the patterns can still be switched
+	tag = &models.Tag{
+		NamespaceID:  1,
+		Name:         "latest",
+		RepositoryID: 3,
+		ManifestID:   1,
+	}
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, tag))
+	tag.ManifestID = 2
+	require.NoError(t, s.CreateOrUpdate(suite.ctx, tag))
+	require.NotEmpty(t, tag.UpdatedAt)
+}
+
 func TestTagStore_CreateOrUpdate_ManifestNotFound(t *testing.T) {
 	reloadRepositoryFixtures(t)
 	reloadManifestFixtures(t)
@@ -0,0 +1,111 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+)
+
+// TagHistoryStore is the interface that a tag history store should conform to. The history records the manifests that
+// tags pointed to over time.
+type TagHistoryStore interface {
+	// RecordTransition records that the tag with the given name in repository r moved from the manifest with ID
+	// oldManifestID to the manifest with ID newManifestID. Zero IDs denote tag creations and deletions, respectively.
+	RecordTransition(ctx context.Context, r *models.Repository, name string, oldManifestID, newManifestID int64, actor string) error
+	// FindByTag finds up to limit history entries for the tag with the given name in repository r, most recent first.
+	// If before is not zero, only entries with a lower ID are returned, which allows paginating through the history.
+	FindByTag(ctx context.Context, r *models.Repository, name string, before int64, limit int) ([]*models.TagHistoryEntry, error)
+}
+
+type tagHistoryStore struct {
+	db Queryer
+}
+
+// NewTagHistoryStore builds a new tagHistoryStore.
+func NewTagHistoryStore(db Queryer) TagHistoryStore {
+	return &tagHistoryStore{db: db}
+}
+
+// RecordTransition records that the tag with the given name in repository r moved from the manifest with ID
+// oldManifestID to the manifest with ID newManifestID. Zero IDs denote tag creations and deletions, respectively.
+// Manifest digests are copied, so the history survives the deletion of the manifests.
+func (s *tagHistoryStore) RecordTransition(ctx context.Context, r *models.Repository, name string, oldManifestID, newManifestID int64, actor string) error {
+	defer metrics.InstrumentQuery(ctx, "tag_history_record_transition")()
+
+	q := `INSERT INTO tag_history (top_level_namespace_id, repository_id, name, old_digest, new_digest, actor)
+			VALUES ($1, $2, $3,
+				(SELECT digest FROM manifests WHERE top_level_namespace_id = $1 AND repository_id = $2 AND id = $4),
+				(SELECT digest FROM manifests WHERE top_level_namespace_id = $1 AND repository_id = $2 AND id = $5),
+				NULLIF($6, ''))`
+
+	if _, err := s.db.ExecContext(ctx, q, r.NamespaceID, r.ID, name, oldManifestID, newManifestID, actor); err != nil {
+		return fmt.Errorf("recording tag transition: %w", err)
+	}
+
+	return nil
+}
+
+// FindByTag finds up to limit history entries for the tag with the given name in repository r, most recent first.
+// If before is not zero, only entries with a lower ID are returned, which allows paginating through the history.
+func (s *tagHistoryStore) FindByTag(ctx context.Context, r *models.Repository, name string, before int64, limit int) ([]*models.TagHistoryEntry, error) {
+	defer metrics.InstrumentQuery(ctx, "tag_history_find_by_tag")()
+
+	q := `SELECT
+			id,
+			top_level_namespace_id,
+			repository_id,
+			name,
+			encode(old_digest, 'hex'),
+			encode(new_digest, 'hex'),
+			actor,
+			created_at
+		FROM
+			tag_history
+		WHERE
+			top_level_namespace_id = $1
+			AND repository_id = $2
+			AND name = $3
+			AND ($4 = 0 OR id < $4)
+		ORDER BY
+			id DESC
+		LIMIT $5`
+
+	rows, err := s.db.QueryContext(ctx, q, r.NamespaceID, r.ID, name, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding tag history: %w", err)
+	}
+	defer rows.Close()
+
+	ee := make([]*models.TagHistoryEntry, 0)
+	for rows.Next() {
+		var oldDgst, newDgst, actor sql.NullString
+		e := new(models.TagHistoryEntry)
+		if err := rows.Scan(&e.ID, &e.NamespaceID, &e.RepositoryID, &e.Name, &oldDgst, &newDgst, &actor, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning tag history entry: %w", err)
+		}
+		if e.OldDigest, err = parseNullDigest(oldDgst); err != nil {
+			return nil, fmt.Errorf("parsing old digest: %w", err)
+		}
+		if e.NewDigest, err = parseNullDigest(newDgst); err != nil {
+			return nil, fmt.Errorf("parsing new digest: %w", err)
+		}
+		e.Actor = actor.String
+		ee = append(ee, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning tag history: %w", err)
+	}
+
+	return ee, nil
+}
+
+func parseNullDigest(s sql.NullString) (digest.Digest, error) {
+	if !s.Valid {
+		return "", nil
+	}
+	return Digest(s.String).Parse()
+}
@@ -0,0 +1,88 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func reloadTagHistoryFixtures(tb testing.TB) {
+	tb.Helper()
+
+	reloadManifestFixtures(tb)
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.TagHistoryTable))
+}
+
+func TestTagHistoryStore_RecordTransition(t *testing.T) {
+	reloadTagHistoryFixtures(t)
+
+	s := datastore.NewTagHistoryStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	// created, moved and deleted
+	require.NoError(t, s.RecordTransition(suite.ctx, r, "latest", 0, 1, "alice"))
+	require.NoError(t, s.RecordTransition(suite.ctx, r, "latest", 1, 2, "bob"))
+	require.NoError(t, s.RecordTransition(suite.ctx, r, "latest", 2, 0, ""))
+	// other tags are not affected
+	require.NoError(t, s.RecordTransition(suite.ctx, r, "stable", 0, 1, "alice"))
+
+	ee, err := s.FindByTag(suite.ctx, r, "latest", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, ee, 3)
+
+	d1 := digest.Digest("sha256:bd165db4bd480656a539e8e00db265377d162d6b98eebbfe5805d0fbd5144155")
+	d2 := digest.Digest("sha256:56b4b2228127fd594c5ab2925409713bd015ae9aa27eef2e0ddd90bcb2b1533f")
+
+	// most recent first
+	require.Equal(t, d2, ee[0].OldDigest)
+	require.Empty(t, ee[0].NewDigest)
+	require.Empty(t, ee[0].Actor)
+
+	require.Equal(t, d1, ee[1].OldDigest)
+	require.Equal(t, d2, ee[1].NewDigest)
+	require.Equal(t, "bob", ee[1].Actor)
+
+	require.Empty(t, ee[2].OldDigest)
+	require.Equal(t, d1, ee[2].NewDigest)
+	require.Equal(t, "alice", ee[2].Actor)
+	require.Equal(t, "latest", ee[2].Name)
+	require.EqualValues(t, 1, ee[2].NamespaceID)
+	require.EqualValues(t, 3, ee[2].RepositoryID)
+	require.NotZero(t, ee[2].CreatedAt)
+}
+
+func TestTagHistoryStore_FindByTag_Paginated(t *testing.T) {
+	reloadTagHistoryFixtures(t)
+
+	s := datastore.NewTagHistoryStore(suite.db)
+	r := &models.Repository{NamespaceID: 1, ID: 3}
+
+	require.NoError(t, s.RecordTransition(suite.ctx, r, "latest", 0, 1, ""))
+	require.NoError(t, s.RecordTransition(suite.ctx, r, "latest", 1, 2, ""))
+	require.NoError(t, s.RecordTransition(suite.ctx, r, "latest", 2, 1, ""))
+
+	ee, err := s.FindByTag(suite.ctx, r, "latest", 0, 2)
+	require.NoError(t, err)
+	require.Len(t, ee, 2)
+
+	next, err := s.FindByTag(suite.ctx, r, "latest", ee[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	require.Less(t, next[0].ID, ee[1].ID)
+	require.Empty(t, next[0].OldDigest)
+}
+
+func TestTagHistoryStore_FindByTag_None(t *testing.T) {
+	reloadTagHistoryFixtures(t)
+
+	s := datastore.NewTagHistoryStore(suite.db)
+	ee, err := s.FindByTag(suite.ctx, &models.Repository{NamespaceID: 1, ID: 3}, "latest", 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, ee)
+}
@@ -43,6 +43,23 @@ const (
 	GCManifestReviewQueueTable table = "gc_manifest_review_queue"
 	GCTmpBlobsManifestsTable   table = "gc_tmp_blobs_manifests"
 	GCReviewAfterDefaultsTable table = "gc_review_after_defaults"
+	GCReviewStatsTable         table = "gc_review_stats"
+	ImmutableTagPatternsTable  table = "immutable_tag_patterns"
+	CleanupPoliciesTable       table = "cleanup_policies"
+	RepositorySettingsTable    table = "repository_settings"
+	BackgroundMigrationsTable  table = "background_migrations"
+	ImportCheckpointsTable     table = "import_checkpoints"
+	UploadsTable               table = "uploads"
+	TagHistoryTable            table = "tag_history"
+	NotificationEventsTable    table = "notification_events"
+	CorruptedBlobsTable        table = "corrupted_blobs"
+	ManifestAnnotationsTable   table = "manifest_annotations"
+	RepositoryPurgesTable      table = "repository_purges"
+	NamespaceStatisticsTable   table = "namespace_statistics"
+	RepositoryMetadataTable    table = "repository_metadata"
+	RepositoryLabelsTable      table = "repository_labels"
+	ProxyCachedTagsTable       table = "proxy_cached_tags"
+	TagSchemaReferrersTable    table = "tag_schema_referrers"
 )
 
 // AllTables represents all tables in the test database.
@@ -61,6 +78,23 @@ var (
 		GCBlobsLayersTable,
 		GCManifestReviewQueueTable,
 		GCTmpBlobsManifestsTable,
+		GCReviewStatsTable,
+		ImmutableTagPatternsTable,
+		CleanupPoliciesTable,
+		RepositorySettingsTable,
+		BackgroundMigrationsTable,
+		ImportCheckpointsTable,
+		UploadsTable,
+		TagHistoryTable,
+		NotificationEventsTable,
+		CorruptedBlobsTable,
+		ManifestAnnotationsTable,
+		RepositoryPurgesTable,
+		NamespaceStatisticsTable,
+		RepositoryMetadataTable,
+		RepositoryLabelsTable,
+		ProxyCachedTagsTable,
+		TagSchemaReferrersTable,
 	}
 
 	GCTrackBlobUploadsTrigger = trigger{
@@ -149,10 +183,17 @@ func (t table) DumpAsJSON(ctx context.Context, db datastore.Queryer) ([]byte, er
 	case RepositoriesTable, ManifestReferencesTable, RepositoryBlobsTable, LayersTable, TagsTable,
 		GCBlobsConfigurationsTable:
 		tmpl = "SELECT json_agg(t) FROM (SELECT * FROM %s ORDER BY (top_level_namespace_id, id)) t"
-	case GCManifestReviewQueueTable:
+	case GCManifestReviewQueueTable, CleanupPoliciesTable, RepositoryPurgesTable:
 		tmpl = "SELECT json_agg(t) FROM (SELECT * FROM %s ORDER BY (top_level_namespace_id, repository_id)) t"
-	case GCBlobsLayersTable:
+	case NamespaceStatisticsTable:
+		tmpl = "SELECT json_agg(t) FROM (SELECT * FROM %s ORDER BY (top_level_namespace_id, date)) t"
+	case GCBlobsLayersTable, BackgroundMigrationsTable:
 		tmpl = "SELECT json_agg(t) FROM (SELECT * FROM %s ORDER BY id) t"
+	case ImmutableTagPatternsTable:
+		tmpl = "SELECT json_agg(t) FROM (SELECT * FROM %s ORDER BY (top_level_namespace_id, id)) t"
+	case ImportCheckpointsTable:
+		// checkpoints are recorded concurrently, so IDs are not deterministic
+		tmpl = "SELECT json_agg(t) FROM (SELECT step, repository_path FROM %s ORDER BY (step, repository_path)) t"
 	default:
 		tmpl = "SELECT json_agg(t) FROM (SELECT * FROM %s ORDER BY digest) t"
 	}
@@ -0,0 +1,97 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/metrics"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+// UploadStore is the interface that an upload store should conform to. Uploads are tracked from the moment they're
+// started until they're completed or canceled, so that abandoned ones can be purged.
+type UploadStore interface {
+	// Create records a new upload. It's a no-op if an upload with the same UUID already exists.
+	Create(ctx context.Context, u *models.Upload) error
+	// FindStartedBefore finds up to limit uploads started before t, oldest first.
+	FindStartedBefore(ctx context.Context, t time.Time, limit int) ([]*models.Upload, error)
+	// Delete deletes the upload with the given UUID. It's a no-op if no such upload exists.
+	Delete(ctx context.Context, uuid string) error
+}
+
+type uploadStore struct {
+	db Queryer
+}
+
+// NewUploadStore builds a new uploadStore.
+func NewUploadStore(db Queryer) UploadStore {
+	return &uploadStore{db: db}
+}
+
+// Create records a new upload. It's a no-op if an upload with the same UUID already exists.
+func (s *uploadStore) Create(ctx context.Context, u *models.Upload) error {
+	defer metrics.InstrumentQuery(ctx, "upload_create")()
+
+	q := `INSERT INTO uploads (uuid, repository_path)
+			VALUES ($1, $2)
+		ON CONFLICT (uuid)
+			DO NOTHING`
+
+	if _, err := s.db.ExecContext(ctx, q, u.UUID, u.RepositoryPath); err != nil {
+		return fmt.Errorf("creating upload: %w", err)
+	}
+
+	return nil
+}
+
+// FindStartedBefore finds up to limit uploads started before t, oldest first.
+func (s *uploadStore) FindStartedBefore(ctx context.Context, t time.Time, limit int) ([]*models.Upload, error) {
+	defer metrics.InstrumentQuery(ctx, "upload_find_started_before")()
+
+	q := `SELECT
+			id,
+			uuid,
+			repository_path,
+			created_at
+		FROM
+			uploads
+		WHERE
+			created_at < $1
+		ORDER BY
+			created_at
+		LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, q, t, limit)
+	if err != nil {
+		return nil, fmt.Errorf("finding uploads: %w", err)
+	}
+	defer rows.Close()
+
+	uu := make([]*models.Upload, 0)
+	for rows.Next() {
+		u := new(models.Upload)
+		if err := rows.Scan(&u.ID, &u.UUID, &u.RepositoryPath, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning upload: %w", err)
+		}
+		uu = append(uu, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning uploads: %w", err)
+	}
+
+	return uu, nil
+}
+
+// Delete deletes the upload with the given UUID. It's a no-op if no such upload exists.
+func (s *uploadStore) Delete(ctx context.Context, uuid string) error {
+	defer metrics.InstrumentQuery(ctx, "upload_delete")()
+
+	q := "DELETE FROM uploads WHERE uuid = $1"
+
+	if _, err := s.db.ExecContext(ctx, q, uuid); err != nil {
+		return fmt.Errorf("deleting upload: %w", err)
+	}
+
+	return nil
+}
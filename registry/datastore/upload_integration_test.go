@@ -0,0 +1,78 @@
+//go:build integration
+
+package datastore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/datastore/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func unloadUploadFixtures(tb testing.TB) {
+	tb.Helper()
+	require.NoError(tb, testutil.TruncateTables(suite.db, testutil.UploadsTable))
+}
+
+func TestUploadStore_Create(t *testing.T) {
+	unloadUploadFixtures(t)
+
+	s := datastore.NewUploadStore(suite.db)
+	require.NoError(t, s.Create(suite.ctx, &models.Upload{UUID: "a", RepositoryPath: "foo/bar"}))
+
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, uu, 1)
+	require.NotZero(t, uu[0].ID)
+	require.Equal(t, "a", uu[0].UUID)
+	require.Equal(t, "foo/bar", uu[0].RepositoryPath)
+	require.NotZero(t, uu[0].CreatedAt)
+}
+
+func TestUploadStore_Create_IsIdempotent(t *testing.T) {
+	unloadUploadFixtures(t)
+
+	s := datastore.NewUploadStore(suite.db)
+	require.NoError(t, s.Create(suite.ctx, &models.Upload{UUID: "a", RepositoryPath: "foo/bar"}))
+	require.NoError(t, s.Create(suite.ctx, &models.Upload{UUID: "a", RepositoryPath: "foo/bar"}))
+
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, uu, 1)
+}
+
+func TestUploadStore_FindStartedBefore(t *testing.T) {
+	unloadUploadFixtures(t)
+
+	s := datastore.NewUploadStore(suite.db)
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Create(suite.ctx, &models.Upload{UUID: id, RepositoryPath: "foo/bar"}))
+	}
+
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now().Add(-time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, uu)
+
+	uu, err = s.FindStartedBefore(suite.ctx, time.Now().Add(time.Minute), 2)
+	require.NoError(t, err)
+	require.Len(t, uu, 2)
+}
+
+func TestUploadStore_Delete(t *testing.T) {
+	unloadUploadFixtures(t)
+
+	s := datastore.NewUploadStore(suite.db)
+	require.NoError(t, s.Create(suite.ctx, &models.Upload{UUID: "a", RepositoryPath: "foo/bar"}))
+	require.NoError(t, s.Create(suite.ctx, &models.Upload{UUID: "b", RepositoryPath: "foo/bar"}))
+
+	require.NoError(t, s.Delete(suite.ctx, "a"))
+	require.NoError(t, s.Delete(suite.ctx, "unknown"))
+
+	uu, err := s.FindStartedBefore(suite.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, uu, 1)
+	require.Equal(t, "b", uu[0].UUID)
+}
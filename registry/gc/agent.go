@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"math/rand"
+	"sync/atomic"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -41,6 +42,8 @@ type Agent struct {
 	initialInterval time.Duration
 	maxBackoff      time.Duration
 	noIdleBackoff   bool
+	// paused is accessed atomically, 1 means paused.
+	paused int32
 }
 
 // AgentOption provides functional options for NewAgent.
@@ -104,13 +107,35 @@ func NewAgent(w worker.Worker, opts ...AgentOption) *Agent {
 	return a
 }
 
+// Name returns the name of the worker managed by the Agent.
+func (a *Agent) Name() string {
+	return a.worker.Name()
+}
+
+// Pause pauses the Agent. A worker run that is already in progress is not interrupted, but no further runs are
+// started until Resume is called. This method may be called safely from multiple concurrent goroutines.
+func (a *Agent) Pause() {
+	atomic.StoreInt32(&a.paused, 1)
+}
+
+// Resume resumes a paused Agent. This method may be called safely from multiple concurrent goroutines.
+func (a *Agent) Resume() {
+	atomic.StoreInt32(&a.paused, 0)
+}
+
+// Paused returns true if the Agent is paused.
+func (a *Agent) Paused() bool {
+	return atomic.LoadInt32(&a.paused) == 1
+}
+
 // Start starts the Agent. This is a blocking call that runs the worker in a loop. The loop can be stopped if the
 // provided context is canceled. Each worker run is separate by an initial sleep interval (configured through
 // WithInitialInterval) with an additional exponential back off up to a given limit (configured through WithMaxBackoff).
 // The exponential back off is incremented after every failed run or when no task was found (unless
 // WithoutIdleBackoff was provided). The sleep interval is reset to the initial value (removing the exponential back off
 // delay) after every successful run, unless no task was found and WithoutIdleBackoff was not provided. The Agent starts
-// with a randomized jitter of up to 60 seconds to ease concurrency in clustered environments.
+// with a randomized jitter of up to 60 seconds to ease concurrency in clustered environments. While paused (see Pause),
+// worker runs are skipped and the Agent checks again after the initial interval.
 func (a *Agent) Start(ctx context.Context) error {
 	l := a.logger.WithFields(log.Fields{"worker": a.worker.Name()})
 	b := backoffConstructor(a.initialInterval, a.maxBackoff)
@@ -127,6 +152,13 @@ func (a *Agent) Start(ctx context.Context) error {
 			l.Warn("context cancelled, exiting")
 			return ctx.Err()
 		default:
+			if a.Paused() {
+				// check again after the initial interval, without affecting the back off state
+				l.WithFields(log.Fields{"duration_s": a.initialInterval.Seconds()}).Debug("agent paused, skipping run")
+				systemClock.Sleep(a.initialInterval)
+				continue
+			}
+
 			start := systemClock.Now()
 
 			id := newCorrelationID()
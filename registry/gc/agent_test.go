@@ -441,3 +441,63 @@ func Test_newBackoff(t *testing.T) {
 	require.NotNil(t, got)
 	require.Equal(t, want, got)
 }
+
+func TestAgent_PauseResume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	workerMock := wmocks.NewMockWorker(ctrl)
+	workerMock.EXPECT().Name().Return("foo").Times(1)
+
+	agent := NewAgent(workerMock)
+	require.Equal(t, "foo", agent.Name())
+	require.False(t, agent.Paused())
+
+	agent.Pause()
+	require.True(t, agent.Paused())
+	agent.Pause()
+	require.True(t, agent.Paused())
+
+	agent.Resume()
+	require.False(t, agent.Paused())
+}
+
+func TestAgent_Start_Paused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	workerMock := wmocks.NewMockWorker(ctrl)
+
+	backoffMock := mocks.NewMockBackoff(ctrl)
+	stubBackoff(t, backoffMock)
+
+	clockMock := regmocks.NewMockClock(ctrl)
+	testutil.StubClock(t, &systemClock, clockMock)
+
+	agent := NewAgent(workerMock, WithLogger(log.GetLogger()))
+	agent.Pause()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wCtx := correlation.ContextWithCorrelation(ctx, stubCorrelationID(t))
+
+	seedTime := time.Time{}
+	startTime := seedTime.Add(1 * time.Millisecond)
+	backOff := defaultInitialInterval
+
+	gomock.InOrder(
+		workerMock.EXPECT().Name().Times(1),
+		clockMock.EXPECT().Now().Return(seedTime).Times(1),
+		clockMock.EXPECT().Sleep(gomock.Any()).Times(1),
+		// paused, so the worker is not run, and the agent resumes while sleeping
+		clockMock.EXPECT().Sleep(defaultInitialInterval).Do(func(_ time.Duration) { agent.Resume() }).Times(1),
+		clockMock.EXPECT().Now().Return(startTime).Times(1),
+		workerMock.EXPECT().Name().Times(1),
+		workerMock.EXPECT().Run(wCtx).Return(worker.RunResult{Found: true}).Times(1),
+		backoffMock.EXPECT().Reset().Times(1),
+		clockMock.EXPECT().Since(startTime).Return(100*time.Millisecond).Times(1),
+		backoffMock.EXPECT().NextBackOff().Return(backOff).Times(1),
+		workerMock.EXPECT().Name().Times(1),
+		clockMock.EXPECT().Sleep(backOff).Do(func(_ time.Duration) { cancel() }).Times(1),
+	)
+
+	err := agent.Start(ctx)
+	require.NotNil(t, err)
+	require.EqualError(t, context.Canceled, err.Error())
+}
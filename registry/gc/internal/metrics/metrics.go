@@ -14,6 +14,7 @@ var (
 	deleteDurationHist        *prometheus.HistogramVec
 	deleteCounter             *prometheus.CounterVec
 	storageDeleteBytesCounter *prometheus.CounterVec
+	configReclaimedBytesHist  *prometheus.HistogramVec
 	postponeCounter           *prometheus.CounterVec
 	sleepDurationHist         *prometheus.HistogramVec
 
@@ -31,6 +32,7 @@ const (
 	mediaTypeLabel = "media_type"
 	danglingLabel  = "dangling"
 	eventLabel     = "event"
+	classLabel     = "artifact_class"
 
 	blobArtifact     = "blob"
 	manifestArtifact = "manifest"
@@ -50,6 +52,9 @@ const (
 	storageDeleteBytesTotalName = "storage_deleted_bytes_total"
 	storageDeleteBytesTotalDesc = "A counter for bytes deleted from storage during online GC."
 
+	configReclaimedBytesName = "config_reclaimed_bytes"
+	configReclaimedBytesDesc = "A histogram of bytes reclaimed from storage per online GC run that deleted an orphaned configuration blob."
+
 	postponeTotalName = "postpones_total"
 	postponeTotalDesc = "A counter for online GC review postpones."
 
@@ -110,6 +115,18 @@ func init() {
 		[]string{mediaTypeLabel},
 	)
 
+	configReclaimedBytesHist = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      configReclaimedBytesName,
+			Help:      configReclaimedBytesDesc,
+			// 256B to 64MiB
+			Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+		},
+		[]string{classLabel},
+	)
+
 	postponeCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metrics.NamespacePrefix,
@@ -138,6 +155,7 @@ func init() {
 	prometheus.MustRegister(deleteCounter)
 	prometheus.MustRegister(postponeCounter)
 	prometheus.MustRegister(storageDeleteBytesCounter)
+	prometheus.MustRegister(configReclaimedBytesHist)
 	prometheus.MustRegister(sleepDurationHist)
 }
 
@@ -184,6 +202,10 @@ func StorageDeleteBytes(bytes int64, mediaType string) {
 	storageDeleteBytesCounter.WithLabelValues(mediaType).Add(float64(bytes))
 }
 
+func ConfigReclaimedBytes(bytes int64, artifactClass string) {
+	configReclaimedBytesHist.WithLabelValues(artifactClass).Observe(float64(bytes))
+}
+
 func ReviewPostpone(workerName string) {
 	postponeCounter.WithLabelValues(workerName).Inc()
 }
@@ -319,6 +319,48 @@ registry_gc_storage_deleted_bytes_total{media_type="foo"} 444
 	require.NoError(t, err)
 }
 
+func TestConfigReclaimedBytes(t *testing.T) {
+	ConfigReclaimedBytes(300, "image")
+	ConfigReclaimedBytes(2, "artifact")
+	ConfigReclaimedBytes(5000, "image")
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_gc_config_reclaimed_bytes A histogram of bytes reclaimed from storage per online GC run that deleted an orphaned configuration blob.
+# TYPE registry_gc_config_reclaimed_bytes histogram
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="256"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="1024"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="4096"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="16384"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="65536"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="262144"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="1.048576e+06"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="4.194304e+06"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="1.6777216e+07"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="6.7108864e+07"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="artifact",le="+Inf"} 1
+registry_gc_config_reclaimed_bytes_sum{artifact_class="artifact"} 2
+registry_gc_config_reclaimed_bytes_count{artifact_class="artifact"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="256"} 0
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="1024"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="4096"} 1
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="16384"} 2
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="65536"} 2
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="262144"} 2
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="1.048576e+06"} 2
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="4.194304e+06"} 2
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="1.6777216e+07"} 2
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="6.7108864e+07"} 2
+registry_gc_config_reclaimed_bytes_bucket{artifact_class="image",le="+Inf"} 2
+registry_gc_config_reclaimed_bytes_sum{artifact_class="image"} 5300
+registry_gc_config_reclaimed_bytes_count{artifact_class="image"} 2
+`)
+	fullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, configReclaimedBytesName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, fullName)
+	require.NoError(t, err)
+}
+
 func TestReviewPostpone(t *testing.T) {
 	ReviewPostpone("foo")
 	ReviewPostpone("foo")
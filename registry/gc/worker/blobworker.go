@@ -7,20 +7,47 @@ import (
 	"time"
 
 	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/gc/internal/metrics"
 	"github.com/docker/distribution/registry/storage"
 	"github.com/docker/distribution/registry/storage/driver"
 	"github.com/hashicorp/go-multierror"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 const defaultStorageTimeout = 5 * time.Second
 
+const (
+	mediaTypeHelmConfig     = "application/vnd.cncf.helm.config.v1+json"
+	mediaTypeOCIEmptyConfig = "application/vnd.oci.empty.v1+json"
+)
+
+// configArtifactClass classifies an orphaned configuration blob by the kind of artifact it belonged to, based on its
+// media type.
+func configArtifactClass(mediaType string) string {
+	switch mediaType {
+	case schema2.MediaTypeImageConfig, v1.MediaTypeImageConfig:
+		return "image"
+	case schema2.MediaTypePluginConfig:
+		return "plugin"
+	case mediaTypeHelmConfig:
+		return "helm"
+	case mediaTypeOCIEmptyConfig:
+		// the empty descriptor is the recommended configuration for OCI artifacts, such as signatures and SBOMs
+		return "artifact"
+	default:
+		return "other"
+	}
+}
+
 var (
 	// for test purposes (mocking)
-	blobTaskStoreConstructor = datastore.NewGCBlobTaskStore
-	blobStoreConstructor     = datastore.NewBlobStore
+	blobTaskStoreConstructor            = datastore.NewGCBlobTaskStore
+	blobStoreConstructor                = datastore.NewBlobStore
+	namespaceStatisticsStoreConstructor = datastore.NewNamespaceStatisticsStore
 )
 
 var _ Worker = (*BlobWorker)(nil)
@@ -32,6 +59,8 @@ type BlobWorker struct {
 	*baseWorker
 	vacuum         *storage.Vacuum
 	storageTimeout time.Duration
+	// namespaceStatistics enables recording the bytes reclaimed from each top-level namespace in the database.
+	namespaceStatistics bool
 }
 
 // BlobWorkerOption provides functional options for NewBlobWorker.
@@ -44,6 +73,13 @@ func WithBlobLogger(l log.Logger) BlobWorkerOption {
 	}
 }
 
+// WithBlobAuditLogger sets the audit logger used to record deleted blobs.
+func WithBlobAuditLogger(l *audit.Logger) BlobWorkerOption {
+	return func(w *BlobWorker) {
+		w.auditLogger = l
+	}
+}
+
 // WithBlobTxTimeout sets the database transaction timeout for each run. Defaults to 10 seconds.
 func WithBlobTxTimeout(d time.Duration) BlobWorkerOption {
 	return func(w *BlobWorker) {
@@ -59,6 +95,22 @@ func WithBlobStorageTimeout(d time.Duration) BlobWorkerOption {
 	}
 }
 
+// WithBlobReviewStats enables recording the outcome of each review in the database, to be reported by the
+// `database gc-stats` command.
+func WithBlobReviewStats() BlobWorkerOption {
+	return func(w *BlobWorker) {
+		w.reviewStats = true
+	}
+}
+
+// WithBlobNamespaceStatistics enables recording the size of deleted blobs as bytes reclaimed from the top-level
+// namespace they were last unlinked from, in the daily namespace statistics.
+func WithBlobNamespaceStatistics() BlobWorkerOption {
+	return func(w *BlobWorker) {
+		w.namespaceStatistics = true
+	}
+}
+
 func (w *BlobWorker) applyDefaults() {
 	w.baseWorker.applyDefaults()
 	if w.storageTimeout == 0 {
@@ -73,6 +125,7 @@ func NewBlobWorker(db datastore.Handler, storageDeleter driver.StorageDeleter, o
 		vacuum:     storage.NewVacuum(storageDeleter),
 	}
 	w.name = "registry.gc.worker.BlobWorker"
+	w.queue = datastore.GCBlobReviewQueue
 	w.applyDefaults()
 	for _, opt := range opts {
 		opt(w)
@@ -127,6 +180,11 @@ func (w *BlobWorker) processTask(ctx context.Context) RunResult {
 		"created_at":   t.CreatedAt.UTC(),
 		"event":        t.Event,
 	}).Info("processing task")
+	if t.ConfigMediaType != "" {
+		// the blob was the configuration of one or more manifests, all of which may have been deleted since
+		l = l.WithFields(log.Fields{"config_media_type": t.ConfigMediaType})
+		l.Info("reviewing potentially orphaned configuration blob")
+	}
 
 	dangling, err := bts.IsDangling(ctx, t)
 	if err != nil {
@@ -145,9 +203,10 @@ func (w *BlobWorker) processTask(ctx context.Context) RunResult {
 	}
 
 	res.Dangling = dangling
+	var reclaimed int64
 	if dangling {
 		l.Info("the blob is dangling")
-		if err := w.deleteBlob(ctx, tx, t); err != nil {
+		if reclaimed, err = w.deleteBlob(ctx, tx, t); err != nil {
 			res.Err = err
 			return res
 		}
@@ -164,11 +223,35 @@ func (w *BlobWorker) processTask(ctx context.Context) RunResult {
 		res.Err = fmt.Errorf("committing database transaction: %w", err)
 		return res
 	}
+	if dangling {
+		w.auditLogger.Log(ctx, audit.Record{
+			Action:   audit.ActionGCDelete,
+			Artifact: audit.ArtifactBlob,
+			Actor:    audit.GCActor,
+			Digest:   t.Digest,
+		})
+		w.recordReclaimedBytes(ctx, t, reclaimed)
+	}
 
 	return res
 }
 
-func (w *BlobWorker) deleteBlob(ctx context.Context, tx datastore.Transactor, t *models.GCBlobTask) error {
+// recordReclaimedBytes records size as bytes reclaimed from the top-level namespace the blob of t was last unlinked
+// from, if known. Errors are logged but otherwise ignored, as the statistics are informational only. This is done
+// outside the task transaction, so that a failure does not abort it.
+func (w *BlobWorker) recordReclaimedBytes(ctx context.Context, t *models.GCBlobTask, size int64) {
+	if !w.namespaceStatistics || !t.NamespaceID.Valid || size == 0 {
+		return
+	}
+
+	s := &models.NamespaceStatistics{NamespaceID: t.NamespaceID.Int64, Date: systemClock.Now(), BytesReclaimed: size}
+	if err := namespaceStatisticsStoreConstructor(w.db).Increment(ctx, []*models.NamespaceStatistics{s}); err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("failed to record reclaimed bytes")
+	}
+}
+
+// deleteBlob deletes the blob of t from storage and database, returning its size, or zero if unknown.
+func (w *BlobWorker) deleteBlob(ctx context.Context, tx datastore.Transactor, t *models.GCBlobTask) (int64, error) {
 	l := log.GetLogger(log.WithContext(ctx))
 	bs := blobStoreConstructor(tx)
 
@@ -177,6 +260,7 @@ func (w *BlobWorker) deleteBlob(ctx context.Context, tx datastore.Transactor, t
 	defer cancel()
 
 	report := metrics.BlobStorageDelete()
+	var size int64
 	var err error
 	if err = w.vacuum.RemoveBlob(ctx2, t.Digest); err != nil {
 		switch {
@@ -190,7 +274,7 @@ func (w *BlobWorker) deleteBlob(ctx context.Context, tx datastore.Transactor, t
 				err = multierror.Append(err, innerErr)
 			}
 			report(err)
-			return err
+			return 0, err
 		}
 	} else {
 		// get blob media type and size for metrics purposes
@@ -202,9 +286,13 @@ func (w *BlobWorker) deleteBlob(ctx context.Context, tx datastore.Transactor, t
 			if b == nil {
 				// this is unexpected, but it's not a show stopper for GC
 				l.Warn("blob no longer exists on database")
-				return nil
+				return 0, nil
 			}
+			size = b.Size
 			metrics.StorageDeleteBytes(b.Size, b.MediaType)
+			if t.ConfigMediaType != "" {
+				metrics.ConfigReclaimedBytes(b.Size, configArtifactClass(t.ConfigMediaType))
+			}
 		}
 	}
 	report(nil)
@@ -216,7 +304,7 @@ func (w *BlobWorker) deleteBlob(ctx context.Context, tx datastore.Transactor, t
 		case err == datastore.ErrNotFound:
 			// this is unexpected, but it's not a show stopper for GC
 			l.Warn("blob no longer exists on database")
-			return nil
+			return 0, nil
 		case errors.Is(err, context.DeadlineExceeded):
 			// the transaction duration exceeded w.txTimeout and therefore the connection was closed, just return
 		default:
@@ -226,12 +314,12 @@ func (w *BlobWorker) deleteBlob(ctx context.Context, tx datastore.Transactor, t
 			}
 		}
 		report(err)
-		return err
+		return 0, err
 	}
 	l.WithFields(log.Fields{"digest": t.Digest}).Info("blob deleted")
 
 	report(nil)
-	return nil
+	return size, nil
 }
 
 func (w *BlobWorker) postponeTaskAndCommit(ctx context.Context, tx datastore.Transactor, t *models.GCBlobTask) error {
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/datastore"
 	storemock "github.com/docker/distribution/registry/datastore/mocks"
 	"github.com/docker/distribution/registry/datastore/models"
@@ -93,6 +94,27 @@ func Test_NewBlobWorker_WithStorageDeadline(t *testing.T) {
 	require.Equal(t, d, w.storageTimeout)
 }
 
+func Test_NewBlobWorker_WithReviewStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	w := NewBlobWorker(dbMock, driverMock, WithBlobReviewStats())
+
+	require.True(t, w.reviewStats)
+	require.Equal(t, datastore.GCBlobReviewQueue, w.queue)
+}
+
+func Test_NewBlobWorker_WithNamespaceStatistics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	w := NewBlobWorker(dbMock, driverMock, WithBlobNamespaceStatistics())
+
+	require.True(t, w.namespaceStatistics)
+}
+
 func fakeBlobTask() *models.GCBlobTask {
 	return &models.GCBlobTask{
 		Digest:      "sha256:c6f988f4874bb0add23a778f753c65efe992244e148a1d2ec2a8b664fb66bbd1",
@@ -135,6 +157,171 @@ func TestBlobWorker_processTask(t *testing.T) {
 	require.Equal(t, bt.Event, res.Event)
 }
 
+func TestBlobWorker_processTask_OrphanedConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockBlobStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	w := NewBlobWorker(dbMock, driverMock)
+
+	dbCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	driverCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultStorageTimeout)}
+	bt := fakeBlobTask()
+	bt.Event = "manifest_delete"
+	bt.ConfigMediaType = mediaTypeHelmConfig
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(dbCtx, nil).Return(txMock, nil).Times(1),
+		btsMock.EXPECT().Next(dbCtx).Return(bt, nil).Times(1),
+		btsMock.EXPECT().IsDangling(dbCtx, bt).Return(true, nil).Times(1),
+		driverMock.EXPECT().Delete(driverCtx, blobPath(bt.Digest)).Return(nil).Times(1),
+		bsMock.EXPECT().FindByDigest(dbCtx, bt.Digest).Return(&models.Blob{Size: 123, MediaType: "application/octet-stream"}, nil).Times(1),
+		bsMock.EXPECT().Delete(dbCtx, bt.Digest).Return(nil).Times(1),
+		btsMock.EXPECT().Delete(dbCtx, bt).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res := w.processTask(context.Background())
+	require.NoError(t, res.Err)
+	require.True(t, res.Found)
+	require.True(t, res.Dangling)
+	require.Equal(t, bt.Event, res.Event)
+}
+
+func TestBlobWorker_processTask_NamespaceStatistics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockBlobStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	nssMock := storemock.NewMockNamespaceStatisticsStore(ctrl)
+	bkp := namespaceStatisticsStoreConstructor
+	namespaceStatisticsStoreConstructor = func(db datastore.Queryer) datastore.NamespaceStatisticsStore { return nssMock }
+	t.Cleanup(func() { namespaceStatisticsStoreConstructor = bkp })
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	w := NewBlobWorker(dbMock, driverMock, WithBlobNamespaceStatistics())
+
+	dbCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	driverCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultStorageTimeout)}
+	bt := fakeBlobTask()
+	bt.NamespaceID = sql.NullInt64{Int64: 2, Valid: true}
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(dbCtx, nil).Return(txMock, nil).Times(1),
+		btsMock.EXPECT().Next(dbCtx).Return(bt, nil).Times(1),
+		btsMock.EXPECT().IsDangling(dbCtx, bt).Return(true, nil).Times(1),
+		driverMock.EXPECT().Delete(driverCtx, blobPath(bt.Digest)).Return(nil).Times(1),
+		bsMock.EXPECT().FindByDigest(dbCtx, bt.Digest).Return(&models.Blob{Size: 123}, nil).Times(1),
+		bsMock.EXPECT().Delete(dbCtx, bt.Digest).Return(nil).Times(1),
+		btsMock.EXPECT().Delete(dbCtx, bt).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		// reclaimed bytes are recorded once the task transaction is committed, and errors are ignored
+		nssMock.EXPECT().Increment(dbCtx, []*models.NamespaceStatistics{
+			{NamespaceID: 2, Date: clockMock.Now(), BytesReclaimed: 123},
+		}).Return(fakeErrorA).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res := w.processTask(context.Background())
+	require.NoError(t, res.Err)
+	require.True(t, res.Dangling)
+}
+
+func TestBlobWorker_processTask_NamespaceStatistics_UnknownNamespace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockBlobStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	nssMock := storemock.NewMockNamespaceStatisticsStore(ctrl)
+	bkp := namespaceStatisticsStoreConstructor
+	namespaceStatisticsStoreConstructor = func(db datastore.Queryer) datastore.NamespaceStatisticsStore { return nssMock }
+	t.Cleanup(func() { namespaceStatisticsStoreConstructor = bkp })
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	w := NewBlobWorker(dbMock, driverMock, WithBlobNamespaceStatistics())
+
+	dbCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	driverCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultStorageTimeout)}
+	// blobs that were never linked to a repository are not attributed to any namespace
+	bt := fakeBlobTask()
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(dbCtx, nil).Return(txMock, nil).Times(1),
+		btsMock.EXPECT().Next(dbCtx).Return(bt, nil).Times(1),
+		btsMock.EXPECT().IsDangling(dbCtx, bt).Return(true, nil).Times(1),
+		driverMock.EXPECT().Delete(driverCtx, blobPath(bt.Digest)).Return(nil).Times(1),
+		bsMock.EXPECT().FindByDigest(dbCtx, bt.Digest).Return(&models.Blob{Size: 123}, nil).Times(1),
+		bsMock.EXPECT().Delete(dbCtx, bt.Digest).Return(nil).Times(1),
+		btsMock.EXPECT().Delete(dbCtx, bt).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res := w.processTask(context.Background())
+	require.NoError(t, res.Err)
+	require.True(t, res.Dangling)
+}
+
+func Test_configArtifactClass(t *testing.T) {
+	tcs := map[string]string{
+		"application/vnd.docker.container.image.v1+json": "image",
+		"application/vnd.oci.image.config.v1+json":       "image",
+		"application/vnd.docker.plugin.v1+json":          "plugin",
+		"application/vnd.cncf.helm.config.v1+json":       "helm",
+		"application/vnd.oci.empty.v1+json":              "artifact",
+		"application/vnd.acme.rocket.config":             "other",
+	}
+
+	for mediaType, class := range tcs {
+		require.Equal(t, class, configArtifactClass(mediaType), mediaType)
+	}
+}
+
+func TestBlobWorker_processTask_AuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockBlobStores(t, ctrl)
+	stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	driverMock := drivermock.NewMockStorageDeleter(ctrl)
+	sink := &auditSink{}
+	w := NewBlobWorker(dbMock, driverMock, WithBlobAuditLogger(audit.NewLogger(sink)))
+
+	bt := fakeBlobTask()
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(gomock.Any(), nil).Return(txMock, nil).Times(1),
+		btsMock.EXPECT().Next(gomock.Any()).Return(bt, nil).Times(1),
+		btsMock.EXPECT().IsDangling(gomock.Any(), bt).Return(true, nil).Times(1),
+		driverMock.EXPECT().Delete(gomock.Any(), blobPath(bt.Digest)).Return(nil).Times(1),
+		bsMock.EXPECT().FindByDigest(gomock.Any(), bt.Digest).Return(&models.Blob{}, nil).Times(1),
+		bsMock.EXPECT().Delete(gomock.Any(), bt.Digest).Return(nil).Times(1),
+		btsMock.EXPECT().Delete(gomock.Any(), bt).Return(nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res := w.Run(context.Background())
+	require.NoError(t, res.Err)
+
+	require.Len(t, sink.records, 1)
+	r := sink.records[0]
+	require.Equal(t, audit.ActionGCDelete, r.Action)
+	require.Equal(t, audit.ArtifactBlob, r.Artifact)
+	require.Equal(t, audit.GCActor, r.Actor)
+	require.Equal(t, bt.Digest, r.Digest)
+	require.NotEmpty(t, r.CorrelationID)
+}
+
 func TestBlobWorker_processTask_BeginTxError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	clockMock := stubClock(t, time.Now())
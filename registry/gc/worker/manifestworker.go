@@ -7,19 +7,29 @@ import (
 	"time"
 
 	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/gc/internal/metrics"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jackc/pgconn"
+	"github.com/opencontainers/go-digest"
 )
 
 var (
 	// for test purposes (mocking)
 	manifestTaskStoreConstructor = datastore.NewGCManifestTaskStore
 	manifestStoreConstructor     = datastore.NewManifestStore
+	repositoryStoreConstructor   = func(db datastore.Queryer) repositoryReader { return datastore.NewRepositoryStore(db) }
 )
 
+// repositoryReader is the subset of datastore.RepositoryReader used to find the cached entries of deleted manifests.
+type repositoryReader interface {
+	FindByID(ctx context.Context, namespaceID, id int64) (*models.Repository, error)
+	ManifestReferrers(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Manifests, error)
+	ManifestTags(ctx context.Context, r *models.Repository, m *models.Manifest) (models.Tags, error)
+}
+
 var _ Worker = (*ManifestWorker)(nil)
 
 // ManifestWorker is the online GC worker responsible for processing tasks related with manifests. It consumes tasks
@@ -27,6 +37,7 @@ var _ Worker = (*ManifestWorker)(nil)
 // deletes it from the database.
 type ManifestWorker struct {
 	*baseWorker
+	manifestCache datastore.ManifestCache
 }
 
 // ManifestWorkerOption provides functional options for NewManifestWorker.
@@ -39,6 +50,13 @@ func WithManifestLogger(l log.Logger) ManifestWorkerOption {
 	}
 }
 
+// WithManifestAuditLogger sets the audit logger used to record deleted manifests.
+func WithManifestAuditLogger(l *audit.Logger) ManifestWorkerOption {
+	return func(w *ManifestWorker) {
+		w.auditLogger = l
+	}
+}
+
 // WithManifestTxTimeout sets the database transaction timeout for each run. Defaults to 10 seconds.
 func WithManifestTxTimeout(d time.Duration) ManifestWorkerOption {
 	return func(w *ManifestWorker) {
@@ -46,10 +64,27 @@ func WithManifestTxTimeout(d time.Duration) ManifestWorkerOption {
 	}
 }
 
+// WithManifestReviewStats enables recording the outcome of each review in the database, to be reported by the
+// `database gc-stats` command.
+func WithManifestReviewStats() ManifestWorkerOption {
+	return func(w *ManifestWorker) {
+		w.reviewStats = true
+	}
+}
+
+// WithManifestCache sets the cache of manifests and tags served by the registry, so that the entries of deleted
+// manifests are invalidated. Entries are not invalidated if not set.
+func WithManifestCache(c datastore.ManifestCache) ManifestWorkerOption {
+	return func(w *ManifestWorker) {
+		w.manifestCache = c
+	}
+}
+
 // NewManifestWorker creates a new BlobWorker.
 func NewManifestWorker(db datastore.Handler, opts ...ManifestWorkerOption) *ManifestWorker {
 	w := &ManifestWorker{baseWorker: &baseWorker{db: db}}
 	w.name = "registry.gc.worker.ManifestWorker"
+	w.queue = datastore.GCManifestReviewQueue
 	w.applyDefaults()
 	for _, opt := range opts {
 		opt(w)
@@ -114,10 +149,18 @@ func (w *ManifestWorker) processTask(ctx context.Context) RunResult {
 	}
 
 	res.Dangling = dangling
+	var deleted *digest.Digest
+	var cached *cachedEntries
 	if dangling {
+		if w.manifestCache != nil {
+			if cached, err = w.findCachedEntries(ctx, tx, t); err != nil {
+				res.Err = w.handleDBError(ctx, t, err)
+				return res
+			}
+		}
 		l.Info("the manifest is dangling, deleting")
 		// deleting the manifest cascades to the review queue, so we don't need to delete the task directly here
-		if err := w.deleteManifest(ctx, tx, t); err != nil {
+		if deleted, err = w.deleteManifest(ctx, tx, t); err != nil {
 			res.Err = w.handleDBError(ctx, t, err)
 			return res
 		}
@@ -133,11 +176,70 @@ func (w *ManifestWorker) processTask(ctx context.Context) RunResult {
 		res.Err = fmt.Errorf("committing database transaction: %w", err)
 		return res
 	}
+	if deleted != nil && cached != nil {
+		w.manifestCache.Invalidate(ctx, cached.repository, append(cached.digests, *deleted)...)
+		w.manifestCache.InvalidateTags(ctx, cached.repository, cached.tagNames...)
+	}
+	if deleted != nil {
+		w.auditLogger.Log(ctx, audit.Record{
+			Action:       audit.ActionGCDelete,
+			Artifact:     audit.ArtifactManifest,
+			Actor:        audit.GCActor,
+			RepositoryID: t.RepositoryID,
+			Digest:       *deleted,
+		})
+	}
 
 	return res
 }
 
-func (w *ManifestWorker) deleteManifest(ctx context.Context, tx datastore.Transactor, t *models.GCManifestTask) error {
+// cachedEntries are the cached entries of a repository that must be invalidated once a manifest is deleted.
+type cachedEntries struct {
+	repository *models.Repository
+	digests    []digest.Digest
+	tagNames   []string
+}
+
+// findCachedEntries finds the repository of the manifest referenced by t, along with the referrers and tags that the
+// database deletes along with the manifest, so that their cached entries can be invalidated. Returns nil if the
+// repository no longer exists.
+func (w *ManifestWorker) findCachedEntries(ctx context.Context, tx datastore.Transactor, t *models.GCManifestTask) (*cachedEntries, error) {
+	rs := repositoryStoreConstructor(tx)
+
+	r, err := rs.FindByID(ctx, t.NamespaceID, t.RepositoryID)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, nil
+	}
+	c := &cachedEntries{repository: r}
+
+	queue := models.Manifests{{ID: t.ManifestID, NamespaceID: t.NamespaceID, RepositoryID: t.RepositoryID}}
+	for len(queue) > 0 {
+		m := queue[0]
+		tt, err := rs.ManifestTags(ctx, r, m)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tt {
+			c.tagNames = append(c.tagNames, tag.Name)
+		}
+		mm, err := rs.ManifestReferrers(ctx, r, m)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range mm {
+			c.digests = append(c.digests, ref.Digest)
+		}
+		queue = append(queue[1:], mm...)
+	}
+
+	return c, nil
+}
+
+// deleteManifest deletes the manifest referenced by t, returning its digest, or nil if it no longer exists.
+func (w *ManifestWorker) deleteManifest(ctx context.Context, tx datastore.Transactor, t *models.GCManifestTask) (*digest.Digest, error) {
 	l := log.GetLogger(log.WithContext(ctx))
 
 	ms := manifestStoreConstructor(tx)
@@ -146,18 +248,18 @@ func (w *ManifestWorker) deleteManifest(ctx context.Context, tx datastore.Transa
 	dgst, err := ms.Delete(ctx, t.NamespaceID, t.RepositoryID, t.ManifestID)
 	if err != nil {
 		report(err)
-		return err
+		return nil, err
 	}
 	if dgst == nil {
 		// this should never happen because deleting a manifest cascades to the review queue, nevertheless...
 		l.Warn("manifest no longer exists on database, deleting task")
 		mts := manifestTaskStoreConstructor(tx)
-		return mts.Delete(ctx, t)
+		return nil, mts.Delete(ctx, t)
 	}
 	l.WithFields(log.Fields{"digest": dgst, "repository_id": t.RepositoryID}).Info("manifest deleted")
 
 	report(nil)
-	return nil
+	return dgst, nil
 }
 
 // postponeTask will postpone the next review of a GC task by applying an exponential delay based on the amount of times
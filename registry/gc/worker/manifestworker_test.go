@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/datastore"
 	storemock "github.com/docker/distribution/registry/datastore/mocks"
 	"github.com/docker/distribution/registry/datastore/models"
@@ -15,6 +16,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/hashicorp/go-multierror"
 	"github.com/opencontainers/go-digest"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 )
 
@@ -73,6 +75,16 @@ func Test_NewManifestWorker_WithTxDeadline(t *testing.T) {
 	require.Equal(t, d, w.txTimeout)
 }
 
+func Test_NewManifestWorker_WithReviewStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	w := NewManifestWorker(dbMock, WithManifestReviewStats())
+
+	require.True(t, w.reviewStats)
+	require.Equal(t, datastore.GCManifestReviewQueue, w.queue)
+}
+
 func fakeManifestTask() *models.GCManifestTask {
 	return &models.GCManifestTask{
 		RepositoryID: 1,
@@ -112,6 +124,145 @@ func TestManifestWorker_processTask(t *testing.T) {
 	require.Equal(t, mt.Event, res.Event)
 }
 
+// fakeRepositoryReader serves a single repository, with the given referrers and tags of each manifest, by ID.
+type fakeRepositoryReader struct {
+	repository *models.Repository
+	referrers  map[int64]models.Manifests
+	tags       map[int64]models.Tags
+}
+
+func (f *fakeRepositoryReader) FindByID(_ context.Context, namespaceID, id int64) (*models.Repository, error) {
+	if namespaceID != f.repository.NamespaceID || id != f.repository.ID {
+		return nil, nil
+	}
+	return f.repository, nil
+}
+
+func (f *fakeRepositoryReader) ManifestReferrers(_ context.Context, _ *models.Repository, m *models.Manifest) (models.Manifests, error) {
+	return f.referrers[m.ID], nil
+}
+
+func (f *fakeRepositoryReader) ManifestTags(_ context.Context, _ *models.Repository, m *models.Manifest) (models.Tags, error) {
+	return f.tags[m.ID], nil
+}
+
+func TestManifestWorker_processTask_InvalidatesManifestCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockManifestStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	mt := fakeManifestTask()
+	r := &models.Repository{ID: mt.RepositoryID, NamespaceID: mt.NamespaceID, Path: "foo/bar"}
+	m := &models.Manifest{NamespaceID: mt.NamespaceID, RepositoryID: mt.RepositoryID, ID: mt.ManifestID, Digest: digest.FromString("foo")}
+	// the database deletes referrers along with their subject, so these must be invalidated as well
+	ref := &models.Manifest{NamespaceID: mt.NamespaceID, RepositoryID: mt.RepositoryID, ID: m.ID + 1, Digest: digest.FromString("bar")}
+
+	rsBkp := repositoryStoreConstructor
+	repositoryStoreConstructor = func(datastore.Queryer) repositoryReader {
+		return &fakeRepositoryReader{
+			repository: r,
+			referrers:  map[int64]models.Manifests{m.ID: {ref}},
+			tags:       map[int64]models.Tags{ref.ID: {{Name: "sbom"}}},
+		}
+	}
+	t.Cleanup(func() { repositoryStoreConstructor = rsBkp })
+
+	c := testutil.NewRedisCacheController(t, 0)
+	cache := datastore.NewCentralManifestCache(c.Cache, redis.NewClient(&redis.Options{Addr: c.Addr()}), time.Hour)
+	ctx := context.Background()
+	cache.Set(ctx, r, m)
+	cache.Set(ctx, r, ref)
+	cache.SetTag(ctx, r, "sbom", ref.Digest, 0)
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	w := NewManifestWorker(dbMock, WithManifestCache(cache))
+
+	txCtx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(txCtx, nil).Return(txMock, nil).Times(1),
+		mtsMock.EXPECT().Next(txCtx).Return(mt, nil).Times(1),
+		mtsMock.EXPECT().IsDangling(txCtx, mt).Return(true, nil).Times(1),
+		msMock.EXPECT().Delete(txCtx, m.NamespaceID, m.RepositoryID, m.ID).Return(&m.Digest, nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res := w.processTask(ctx)
+	require.NoError(t, res.Err)
+	require.True(t, res.Dangling)
+
+	require.Nil(t, cache.Get(ctx, r, m.Digest))
+	require.Nil(t, cache.Get(ctx, r, ref.Digest))
+	dgst, _ := cache.GetTag(ctx, r, "sbom")
+	require.Empty(t, dgst)
+}
+
+func TestManifestWorker_processTask_AuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockManifestStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	sink := &auditSink{}
+	w := NewManifestWorker(dbMock, WithManifestAuditLogger(audit.NewLogger(sink)))
+
+	ctx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	mt := fakeManifestTask()
+	m := &models.Manifest{RepositoryID: mt.RepositoryID, ID: mt.ManifestID, Digest: digest.FromString("foo")}
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(ctx, nil).Return(txMock, nil).Times(1),
+		mtsMock.EXPECT().Next(ctx).Return(mt, nil).Times(1),
+		mtsMock.EXPECT().IsDangling(ctx, mt).Return(true, nil).Times(1),
+		msMock.EXPECT().Delete(ctx, m.NamespaceID, m.RepositoryID, m.ID).Return(&m.Digest, nil).Times(1),
+		txMock.EXPECT().Commit().Return(nil).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res := w.processTask(context.Background())
+	require.NoError(t, res.Err)
+
+	require.Len(t, sink.records, 1)
+	r := sink.records[0]
+	require.Equal(t, audit.ActionGCDelete, r.Action)
+	require.Equal(t, audit.ArtifactManifest, r.Artifact)
+	require.Equal(t, audit.GCActor, r.Actor)
+	require.Equal(t, mt.RepositoryID, r.RepositoryID)
+	require.Equal(t, m.Digest, r.Digest)
+}
+
+func TestManifestWorker_processTask_AuditLog_CommitError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockManifestStores(t, ctrl)
+	clockMock := stubClock(t, time.Now())
+
+	dbMock := storemock.NewMockHandler(ctrl)
+	txMock := storemock.NewMockTransactor(ctrl)
+	sink := &auditSink{}
+	w := NewManifestWorker(dbMock, WithManifestAuditLogger(audit.NewLogger(sink)))
+
+	ctx := testutil.IsContextWithDeadline{Deadline: clockMock.Now().Add(defaultTxTimeout)}
+	mt := fakeManifestTask()
+	m := &models.Manifest{RepositoryID: mt.RepositoryID, ID: mt.ManifestID, Digest: digest.FromString("foo")}
+
+	gomock.InOrder(
+		dbMock.EXPECT().BeginTx(ctx, nil).Return(txMock, nil).Times(1),
+		mtsMock.EXPECT().Next(ctx).Return(mt, nil).Times(1),
+		mtsMock.EXPECT().IsDangling(ctx, mt).Return(true, nil).Times(1),
+		msMock.EXPECT().Delete(ctx, m.NamespaceID, m.RepositoryID, m.ID).Return(&m.Digest, nil).Times(1),
+		txMock.EXPECT().Commit().Return(sql.ErrConnDone).Times(1),
+		txMock.EXPECT().Rollback().Return(sql.ErrTxDone).Times(1),
+	)
+
+	res := w.processTask(context.Background())
+	require.ErrorIs(t, res.Err, sql.ErrConnDone)
+
+	// the deletion was rolled back and must not be recorded
+	require.Empty(t, sink.records)
+}
+
 func TestManifestWorker_processTask_BeginTxError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	clockMock := stubClock(t, time.Now())
@@ -12,6 +12,7 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/internal"
 	"github.com/getsentry/sentry-go"
@@ -25,6 +26,9 @@ const (
 	defaultTxTimeout = 10 * time.Second
 )
 
+// ReviewStatsRetention is how long the hourly review stats are kept for.
+const ReviewStatsRetention = 7 * 24 * time.Hour
+
 // Worker represents an online GC worker, which is responsible for processing review tasks, determining eligibility
 // for deletion and deleting artifacts from the backend if eligible.
 type Worker interface {
@@ -35,13 +39,24 @@ type Worker interface {
 }
 
 // for test purposes (mocking)
-var systemClock internal.Clock = clock.New()
+var (
+	systemClock             internal.Clock = clock.New()
+	gcStatsStoreConstructor                = datastore.NewGCStatsStore
+)
 
 type baseWorker struct {
 	name      string
 	db        datastore.Handler
 	logger    log.Logger
 	txTimeout time.Duration
+	// auditLogger records deleted artifacts in the audit log. Nil if the audit log is disabled.
+	auditLogger *audit.Logger
+	// queue is the name of the review queue processed by the worker, as recorded in the review stats.
+	queue string
+	// reviewStats enables recording the outcome of each review in the database.
+	reviewStats bool
+	// statsPrunedAt is the hour at which expired review stats were last deleted.
+	statsPrunedAt time.Time
 }
 
 // Name implements Worker.
@@ -84,10 +99,34 @@ func (w *baseWorker) run(ctx context.Context, p processor) RunResult {
 		res.Err = fmt.Errorf("processing task: %w", res.Err)
 		w.logAndReportErr(ctx, res.Err)
 	}
+	if w.reviewStats && (res.Found || res.Err != nil) {
+		w.recordReview(ctx, res)
+	}
 
 	return res
 }
 
+// recordReview records the outcome of a review in the hourly review stats, deleting those older than
+// ReviewStatsRetention once per hour. Errors are logged but otherwise ignored, as the stats are informational only.
+func (w *baseWorker) recordReview(ctx context.Context, res RunResult) {
+	l := log.GetLogger(log.WithContext(ctx))
+	s := gcStatsStoreConstructor(w.db)
+
+	if err := s.RecordReview(ctx, w.queue, res.Dangling, res.Err != nil); err != nil {
+		l.WithError(err).Warn("failed to record review stats")
+		return
+	}
+
+	now := systemClock.Now()
+	if hour := now.Truncate(time.Hour); hour.After(w.statsPrunedAt) {
+		if _, err := s.DeleteReviewStatsBefore(ctx, now.Add(-ReviewStatsRetention)); err != nil {
+			l.WithError(err).Warn("failed to delete expired review stats")
+			return
+		}
+		w.statsPrunedAt = hour
+	}
+}
+
 func (w *baseWorker) logAndReportErr(ctx context.Context, err error) {
 	errortracking.Capture(
 		err,
@@ -138,6 +177,7 @@ func injectCorrelationID(ctx context.Context, logger log.Logger) context.Context
 
 	l := logger.WithFields(log.Fields{correlation.FieldName: id})
 	ctx = log.WithLogger(ctx, l)
+	ctx = correlation.ContextWithCorrelation(ctx, id)
 
 	return ctx
 }
@@ -2,12 +2,15 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
 	dbmock "github.com/docker/distribution/registry/datastore/mocks"
 	"github.com/docker/distribution/registry/internal/testutil"
 	"github.com/golang/mock/gomock"
@@ -24,6 +27,24 @@ func stubClock(tb testing.TB, t time.Time) clock.Clock {
 	return mock
 }
 
+// auditSink is an audit.Sink that keeps written records in memory.
+type auditSink struct {
+	records []audit.Record
+}
+
+func (s *auditSink) Write(b []byte) error {
+	var r audit.Record
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	s.records = append(s.records, r)
+	return nil
+}
+
+func (*auditSink) Close() error {
+	return nil
+}
+
 type isDuration struct {
 	d time.Duration
 }
@@ -68,6 +89,68 @@ func Test_baseWorker_rollbackOnExit_PanicRecover(t *testing.T) {
 	require.PanicsWithError(t, err.Error(), f)
 }
 
+// processorFunc is a processor that returns the result of calling itself.
+type processorFunc func(context.Context) RunResult
+
+func (f processorFunc) processTask(ctx context.Context) RunResult {
+	return f(ctx)
+}
+
+func Test_baseWorker_run_ReviewStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	statsMock := dbmock.NewMockGCStatsStore(ctrl)
+
+	bkp := gcStatsStoreConstructor
+	gcStatsStoreConstructor = func(db datastore.Queryer) datastore.GCStatsStore { return statsMock }
+	t.Cleanup(func() { gcStatsStoreConstructor = bkp })
+
+	now := time.Date(2023, 12, 4, 10, 30, 0, 0, time.UTC)
+	clockMock := clock.NewMock()
+	clockMock.Set(now)
+	testutil.StubClock(t, &systemClock, clockMock)
+
+	w := &baseWorker{queue: "foo", reviewStats: true}
+	w.applyDefaults()
+	run := func(res RunResult) {
+		w.run(context.Background(), processorFunc(func(context.Context) RunResult { return res }))
+	}
+
+	// expired stats are deleted with the first recorded review of each hour
+	gomock.InOrder(
+		statsMock.EXPECT().RecordReview(gomock.Any(), "foo", true, false).Return(nil).Times(1),
+		statsMock.EXPECT().DeleteReviewStatsBefore(gomock.Any(), now.Add(-ReviewStatsRetention)).Return(int64(1), nil).Times(1),
+		statsMock.EXPECT().RecordReview(gomock.Any(), "foo", false, true).Return(nil).Times(1),
+	)
+	run(RunResult{Found: true, Dangling: true})
+	run(RunResult{Err: fakeErrorA})
+	// runs without a task are not recorded
+	run(RunResult{})
+	require.Equal(t, now.Truncate(time.Hour), w.statsPrunedAt)
+
+	clockMock.Add(time.Hour)
+	gomock.InOrder(
+		statsMock.EXPECT().RecordReview(gomock.Any(), "foo", false, false).Return(fakeErrorA).Times(1),
+		statsMock.EXPECT().RecordReview(gomock.Any(), "foo", false, false).Return(nil).Times(1),
+		statsMock.EXPECT().DeleteReviewStatsBefore(gomock.Any(), clockMock.Now().Add(-ReviewStatsRetention)).Return(int64(0), nil).Times(1),
+	)
+	run(RunResult{Found: true})
+	run(RunResult{Found: true})
+	require.Equal(t, clockMock.Now().Truncate(time.Hour), w.statsPrunedAt)
+}
+
+func Test_baseWorker_run_ReviewStatsDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	statsMock := dbmock.NewMockGCStatsStore(ctrl)
+
+	bkp := gcStatsStoreConstructor
+	gcStatsStoreConstructor = func(db datastore.Queryer) datastore.GCStatsStore { return statsMock }
+	t.Cleanup(func() { gcStatsStoreConstructor = bkp })
+
+	w := &baseWorker{queue: "foo"}
+	w.applyDefaults()
+	w.run(context.Background(), processorFunc(func(context.Context) RunResult { return RunResult{Found: true} }))
+}
+
 func Test_exponentialBackoff(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -54,8 +54,10 @@ func TestAPIConformance(t *testing.T) {
 		manifest_Put_Schema2_MissingConfigAndLayers,
 		manifest_Put_Schema2_MissingLayers,
 		manifest_Put_Schema2_ReuseTagManifestToManifest,
+		manifest_Put_Schema2_ImmutableTag,
 		manifest_Put_Schema2_ReferencesExceedLimit,
 		manifest_Put_Schema2_PayloadSizeExceedsLimit,
+		manifest_Put_Schema2_PayloadSizeExceedsRepositoryLimit,
 		manifest_Head_Schema2,
 		manifest_Head_Schema2_MissingManifest,
 		manifest_Get_Schema2_ByDigest_MissingManifest,
@@ -67,6 +69,8 @@ func TestAPIConformance(t *testing.T) {
 		manifest_Get_Schema2_ByTag_NotAssociatedWithRepository,
 		manifest_Get_Schema2_MatchingEtag,
 		manifest_Get_Schema2_NonMatchingEtag,
+		manifest_Get_Schema2_SignatureRequired,
+		manifest_Get_Schema2_SignatureNotEnforced,
 		manifest_Delete_Schema2,
 		manifest_Delete_Schema2_AlreadyDeleted,
 		manifest_Delete_Schema2_Reupload,
@@ -83,6 +87,15 @@ func TestAPIConformance(t *testing.T) {
 		manifest_Put_OCI_ByTag,
 		manifest_Put_OCI_WithSubject,
 		manifest_Put_OCI_WithNonMatchingSubject,
+		manifest_Delete_OCI_CascadeReferrers,
+		manifest_Delete_OCI_CascadeReferrersDisabled,
+		manifest_Delete_OCI_CascadeInvalid,
+		referrers_Get,
+		referrers_Get_FilterByArtifactType,
+		referrers_Get_FilterByArtifactType_Explicit,
+		referrers_Get_FilterByArtifactType_ConfigMediaType,
+		referrers_Get_SubjectNotFound,
+		referrers_Get_InvalidDigest,
 		manifest_Get_OCI_MatchingEtag,
 		manifest_Get_OCI_NonMatchingEtag,
 
@@ -93,6 +106,10 @@ func TestAPIConformance(t *testing.T) {
 		manifest_Put_OCI_WithNonDistributableLayers,
 
 		manifest_Get_ManifestList_FallbackToSchema2,
+		manifest_Get_OCIIndex_ByPlatform,
+		manifest_Get_OCIIndex_ByPlatform_NotFound,
+		manifest_Get_ByPlatform_InvalidPlatform,
+		manifest_Get_ByPlatform_NotAnIndex,
 
 		blob_Head,
 		blob_Head_BlobNotFound,
@@ -108,6 +125,8 @@ func TestAPIConformance(t *testing.T) {
 		tags_Get,
 		tags_Get_EmptyRepository,
 		tags_Get_RepositoryNotFound,
+		tags_Get_MatchingEtag,
+		tags_Get_TotalCount,
 		tags_Delete,
 		tags_Delete_WithAuth,
 		tags_Delete_AllowedMethods,
@@ -120,6 +139,10 @@ func TestAPIConformance(t *testing.T) {
 		catalog_Get,
 		catalog_Get_Empty,
 		catalog_Get_TooLarge,
+		catalog_Get_MatchingEtag,
+		catalog_Get_Filtered,
+		catalog_Get_InvalidFilter,
+		catalog_Get_TotalCount,
 	}
 
 	type envOpt struct {
@@ -336,6 +359,131 @@ func manifest_Put_Schema2_ReuseTagManifestToManifest(t *testing.T, opts ...confi
 	require.NotEqual(t, originalPayload, newPayload)
 }
 
+func manifest_Put_Schema2_ImmutableTag(t *testing.T, opts ...configOpt) {
+	opts = append(opts, withImmutableTags(configuration.ImmutableTagRule{
+		Repository: "schema2/*",
+		Patterns:   []string{"v*"},
+	}))
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	tagName := "v1.0.0"
+	repoPath := "schema2/immutabletag"
+
+	original := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+	manifestURL := buildManifestTagURL(t, env, repoPath, tagName)
+
+	// Pushing the same manifest again is allowed.
+	resp := putManifest(t, "putting same manifest", manifestURL, schema2.MediaTypeManifest, original)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// Pushing a different manifest with the same tag is not.
+	newManifest := seedRandomSchema2Manifest(t, env, repoPath, putByDigest)
+	resp = putManifest(t, "putting different manifest", manifestURL, schema2.MediaTypeManifest, newManifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "overwriting immutable tag", resp, v2.ErrorCodeTagImmutable)
+
+	// Tags that do not match the patterns can still be overwritten.
+	seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
+	seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
+}
+
+func manifest_Get_Schema2_SignatureRequired(t *testing.T, opts ...configOpt) {
+	key, keyPath := newCosignKey(t)
+	opts = append(opts, withSignatures(configuration.SignatureRule{
+		Repository: "schema2/*",
+		Mode:       configuration.SignatureModeEnforce,
+		Cosign:     configuration.CosignTrustRoots{Keys: []string{keyPath}},
+	}))
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	tagName := "latest"
+	repoPath := "schema2/signed"
+
+	dgst := createRepository(t, env, repoPath, tagName)
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+
+	// Unsigned manifests can't be pulled, by tag or digest.
+	resp, err := http.Get(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "pulling unsigned manifest", resp, v2.ErrorCodeManifestSignatureRequired)
+
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	digestRef, err := reference.WithDigest(repoRef, dgst)
+	require.NoError(t, err)
+	digestURL, err := env.builder.BuildManifestURL(digestRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodHead, digestURL, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Signatures made with untrusted keys are rejected.
+	untrustedKey, _ := newCosignKey(t)
+	seedCosignSignature(t, env, repoPath, untrustedKey, dgst)
+
+	resp, err = http.Get(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "pulling manifest with untrusted signature", resp, v2.ErrorCodeManifestSignatureRequired)
+
+	// Signed manifests can be pulled, and so can their signatures.
+	seedCosignSignature(t, env, repoPath, key, dgst)
+
+	resp, err = http.Get(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+
+	sigTag := fmt.Sprintf("%s-%s.sig", dgst.Algorithm(), dgst.Hex())
+	req, err = http.NewRequest(http.MethodGet, buildManifestTagURL(t, env, repoPath, sigTag), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", v1.MediaTypeImageManifest)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Repositories that do not match a rule are not affected.
+	otherPath := "other/unsigned"
+	createRepository(t, env, otherPath, tagName)
+
+	resp, err = http.Get(buildManifestTagURL(t, env, otherPath, tagName))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func manifest_Get_Schema2_SignatureNotEnforced(t *testing.T, opts ...configOpt) {
+	_, keyPath := newCosignKey(t)
+	opts = append(opts, withSignatures(configuration.SignatureRule{
+		Mode:   configuration.SignatureModeWarn,
+		Cosign: configuration.CosignTrustRoots{Keys: []string{keyPath}},
+	}))
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	tagName := "latest"
+	repoPath := "schema2/unsigned"
+	createRepository(t, env, repoPath, tagName)
+
+	resp, err := http.Get(buildManifestTagURL(t, env, repoPath, tagName))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func manifest_Put_Schema2_ByTag(t *testing.T, opts ...configOpt) {
 	env := newTestEnv(t, opts...)
 	defer env.Shutdown()
@@ -1148,6 +1296,36 @@ func manifest_Put_Schema2_PayloadSizeExceedsLimit(t *testing.T, opts ...configOp
 	}
 }
 
+func manifest_Put_Schema2_PayloadSizeExceedsRepositoryLimit(t *testing.T, opts ...configOpt) {
+	opts = append(opts, withPayloadSizeLimits(
+		configuration.ManifestPayloadSizeLimitRule{Repository: "limited/*", Limit: 5},
+		configuration.ManifestPayloadSizeLimitRule{Repository: "unlimited/*", Limit: 0},
+	), withPayloadSizeLimit(10))
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	// The first matching rule applies to the repository.
+	repoPath := "limited/repo"
+	deserializedManifest := seedRandomSchema2Manifest(t, env, repoPath)
+
+	resp := putManifest(t, "putting oversized manifest", buildManifestTagURL(t, env, repoPath, "latest"), schema2.MediaTypeManifest, deserializedManifest.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "manifest put exceeds payload size limit", resp, v2.ErrorCodeManifestPayloadSizeLimit)
+
+	// A rule with a zero limit disables the global limit for matching repositories.
+	seedRandomSchema2Manifest(t, env, "unlimited/repo", putByTag("latest"))
+
+	// The global limit applies to repositories that match no rule.
+	repoPath = "other/repo"
+	deserializedManifest = seedRandomSchema2Manifest(t, env, repoPath)
+
+	resp = putManifest(t, "putting oversized manifest", buildManifestTagURL(t, env, repoPath, "latest"), schema2.MediaTypeManifest, deserializedManifest.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "manifest put exceeds payload size limit", resp, v2.ErrorCodeManifestPayloadSizeLimit)
+}
+
 func manifest_Get_Schema2_ByDigest_MissingManifest(t *testing.T, opts ...configOpt) {
 	env := newTestEnv(t, opts...)
 	defer env.Shutdown()
@@ -1986,173 +2164,524 @@ func manifest_Put_OCI_WithSubject(t *testing.T, opts ...configOpt) {
 	require.Equal(t, digest.FromBytes(subjPayload), fetchedManifest.Subject().Digest)
 }
 
-func manifest_Put_OCI_WithNonMatchingSubject(t *testing.T, opts ...configOpt) {
+func manifest_Delete_OCI_CascadeReferrers(t *testing.T, opts ...configOpt) {
+	opts = append(opts, withDeleteCascadeReferrers)
 	env := newTestEnv(t, opts...)
 	defer env.Shutdown()
 
-	repoPath := "oci/happypath"
+	repoPath := "oci/cascade"
+	tagName := "signature"
 
-	repoRef, err := reference.WithName(repoPath)
+	// the subject has a referrer, which in turn has a tagged referrer of its own
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	sbom := seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject))
+	signature := seedRandomOCIManifest(t, env, repoPath, putByTag(tagName), withSubject(sbom))
+	unrelated := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+
+	resp, err := httpDelete(buildManifestDigestURL(t, env, repoPath, subject) + "?cascade=referrers")
 	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
-	manifest := &ocischema.Manifest{
-		Versioned: manifest.Versioned{
-			SchemaVersion: 2,
-			MediaType:     v1.MediaTypeImageManifest,
-		},
-	}
+	getStatus := func(m *ocischema.DeserializedManifest) int {
+		req, err := http.NewRequest(http.MethodGet, buildManifestDigestURL(t, env, repoPath, m), nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", v1.MediaTypeImageManifest)
 
-	// Create and push config layer
-	cfgPayload, cfgDesc := ociConfig()
-	uploadURLBase, _ := startPushLayer(t, env, repoRef)
-	pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
-	manifest.Config = cfgDesc
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
 
-	// Create one random layer for this manifest
-	manifest.Layers = make([]distribution.Descriptor, 1)
-	rs, dgst, size := createRandomSmallLayer()
-	uploadURLBase, _ = startPushLayer(t, env, repoRef)
-	pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, rs)
-	manifest.Layers[0] = distribution.Descriptor{
-		Digest:    dgst,
-		MediaType: v1.MediaTypeImageLayer,
-		Size:      size,
+		return resp.StatusCode
 	}
 
-	// Set subject as a nonexistent manifest reference
-	manifest.Subject = &distribution.Descriptor{
-		Digest:    digest.Digest("sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
-		MediaType: v1.MediaTypeImageManifest,
-		Size:      42,
-	}
+	require.Equal(t, http.StatusNotFound, getStatus(subject))
+	require.Equal(t, http.StatusNotFound, getStatus(sbom))
+	require.Equal(t, http.StatusNotFound, getStatus(signature))
+	require.Equal(t, http.StatusOK, getStatus(unrelated))
 
-	deserializedManifest, err := ocischema.FromStruct(*manifest)
+	resp, err = http.Get(buildManifestTagURL(t, env, repoPath, tagName))
 	require.NoError(t, err)
-
-	manifestDigestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifest)
-
-	resp := putManifest(t, "putting manifest with missing subject", manifestDigestURL, v1.MediaTypeImageManifest, deserializedManifest)
 	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 
-	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
-	checkBodyHasErrorCodes(t, "putting manifest with missing subject", resp, v2.ErrorCodeManifestBlobUnknown)
+	if env.ns != nil {
+		for _, m := range []*ocischema.DeserializedManifest{subject, sbom, signature} {
+			_, payload, err := m.Payload()
+			require.NoError(t, err)
+
+			expectedEvent := buildEventManifestDeleteByDigest(v1.MediaTypeImageManifest, repoPath, digest.FromBytes(payload))
+			env.ns.AssertEventNotification(t, expectedEvent)
+		}
+	}
 }
 
-func manifest_Get_OCI_NonMatchingEtag(t *testing.T, opts ...configOpt) {
+func manifest_Delete_OCI_CascadeReferrersDisabled(t *testing.T, opts ...configOpt) {
+	opts = append(opts, withDelete)
 	env := newTestEnv(t, opts...)
 	defer env.Shutdown()
 
-	tagName := "ocihappypathtag"
-	repoPath := "oci/happypath"
+	repoPath := "oci/cascade"
 
-	deserializedManifest := seedRandomOCIManifest(t, env, repoPath, putByTag(tagName))
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject))
 
-	// Build URLs.
-	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
-	digestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifest)
+	resp, err := httpDelete(buildManifestDigestURL(t, env, repoPath, subject) + "?cascade=referrers")
+	require.NoError(t, err)
+	defer resp.Body.Close()
 
-	_, payload, err := deserializedManifest.Payload()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "cascading delete disabled", resp, errcode.ErrorCodeUnsupported)
+}
+
+const sociIndexArtifactType = "application/vnd.amazon.soci.index.v2+json"
+
+// seedSOCIIndex pushes a SOCI index, a seekable layer index used by lazy-pulling snapshotters, as a referrer of subject.
+// Like SOCI v2 indexes, it has an empty config and zTOC layers.
+func seedSOCIIndex(t *testing.T, env *testEnv, repoPath string, subject *ocischema.DeserializedManifest) *ocischema.DeserializedManifest {
+	t.Helper()
+
+	repoRef, err := reference.WithName(repoPath)
 	require.NoError(t, err)
 
-	dgst := digest.FromBytes(payload)
+	emptyConfig := []byte("{}")
+	cfgDesc := distribution.Descriptor{
+		MediaType: "application/vnd.oci.empty.v1+json",
+		Digest:    digest.FromBytes(emptyConfig),
+		Size:      int64(len(emptyConfig)),
+	}
+	uploadURLBase, _ := startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(emptyConfig))
 
-	tt := []struct {
-		name        string
-		manifestURL string
-		etag        string
-	}{
-		{
-			name:        "by tag",
-			manifestURL: tagURL,
-		},
-		{
-			name:        "by digest",
-			manifestURL: digestURL,
-		},
-		{
-			name:        "by tag non matching etag",
-			manifestURL: tagURL,
-			etag:        digest.FromString("no match").String(),
-		},
-		{
-			name:        "by digest non matching etag",
-			manifestURL: digestURL,
-			etag:        digest.FromString("no match").String(),
+	rs, dgst, size := createRandomSmallLayer()
+	uploadURLBase, _ = startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, rs)
+
+	_, payload, err := subject.Payload()
+	require.NoError(t, err)
+
+	m, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 2,
+			MediaType:     v1.MediaTypeImageManifest,
 		},
-		{
-			name:        "by tag malformed etag",
-			manifestURL: tagURL,
-			etag:        "bad etag",
+		ArtifactType: sociIndexArtifactType,
+		Config:       cfgDesc,
+		Layers: []distribution.Descriptor{
+			{
+				MediaType:   "application/octet-stream",
+				Digest:      dgst,
+				Size:        size,
+				Annotations: map[string]string{"com.amazon.soci.image-layer-digest": subject.Layers()[0].Digest.String()},
+			},
 		},
-		{
-			name:        "by digest malformed etag",
-			manifestURL: digestURL,
-			etag:        "bad etag",
+		Subject: &distribution.Descriptor{
+			MediaType: v1.MediaTypeImageManifest,
+			Digest:    digest.FromBytes(payload),
+			Size:      int64(len(payload)),
 		},
-	}
-
-	for _, test := range tt {
-		t.Run(test.name, func(t *testing.T) {
-			req, err := http.NewRequest(http.MethodGet, test.manifestURL, nil)
-			require.NoError(t, err)
-
-			req.Header.Set("Accept", v1.MediaTypeImageManifest)
-			if test.etag != "" {
-				req.Header.Set("If-None-Match", test.etag)
-			}
+		Annotations: map[string]string{"com.amazon.soci.build-tool-identifier": "test"},
+	})
+	require.NoError(t, err)
 
-			resp, err := http.DefaultClient.Do(req)
-			require.NoError(t, err)
-			defer resp.Body.Close()
+	resp := putManifest(t, "putting SOCI index", buildManifestDigestURL(t, env, repoPath, m), v1.MediaTypeImageManifest, m)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
 
-			require.Equal(t, http.StatusOK, resp.StatusCode)
-			require.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
-			require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
-			require.Equal(t, fmt.Sprintf(`"%s"`, dgst), resp.Header.Get("ETag"))
+	return m
+}
 
-			var fetchedManifest *ocischema.DeserializedManifest
-			dec := json.NewDecoder(resp.Body)
+func getReferrers(t *testing.T, env *testEnv, repoPath string, dgst digest.Digest, values ...url.Values) (*http.Response, []map[string]interface{}) {
+	t.Helper()
 
-			err = dec.Decode(&fetchedManifest)
-			require.NoError(t, err)
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	ref, err := reference.WithDigest(repoRef, dgst)
+	require.NoError(t, err)
+	u, err := env.builder.BuildReferrersURL(ref, values...)
+	require.NoError(t, err)
 
-			require.EqualValues(t, deserializedManifest, fetchedManifest)
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
 
-			if env.ns != nil {
-				sizeStr := resp.Header.Get("Content-Length")
-				size, err := strconv.Atoi(sizeStr)
-				require.NoError(t, err)
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
 
-				expectedEvent := buildEventManifestPull(v1.MediaTypeImageManifest, repoPath, dgst, int64(size))
-				env.ns.AssertEventNotification(t, expectedEvent)
-			}
-		})
+	var body struct {
+		SchemaVersion int                      `json:"schemaVersion"`
+		MediaType     string                   `json:"mediaType"`
+		Manifests     []map[string]interface{} `json:"manifests"`
 	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, 2, body.SchemaVersion)
+	require.Equal(t, v1.MediaTypeImageIndex, body.MediaType)
+	require.NotNil(t, body.Manifests)
+
+	return resp, body.Manifests
 }
 
-func manifest_Get_OCI_MatchingEtag(t *testing.T, opts ...configOpt) {
+func referrers_Get(t *testing.T, opts ...configOpt) {
 	env := newTestEnv(t, opts...)
 	defer env.Shutdown()
 
-	tagName := "ocihappypathtag"
-	repoPath := "oci/happypath"
+	repoPath := "oci/referrers"
 
-	deserializedManifest := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	signature := seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject))
+	soci := seedSOCIIndex(t, env, repoPath, subject)
+	// referrers of referrers are not listed
+	seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(signature))
 
-	// Build URLs.
-	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
-	digestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifest)
+	_, subjectPayload, err := subject.Payload()
+	require.NoError(t, err)
+	resp, manifests := getReferrers(t, env, repoPath, digest.FromBytes(subjectPayload))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, v1.MediaTypeImageIndex, resp.Header.Get("Content-Type"))
+	require.Empty(t, resp.Header.Get("OCI-Filters-Applied"))
+	require.Len(t, manifests, 2)
 
-	_, payload, err := deserializedManifest.Payload()
+	byDigest := make(map[string]map[string]interface{})
+	for _, m := range manifests {
+		byDigest[m["digest"].(string)] = m
+	}
+
+	_, signaturePayload, err := signature.Payload()
 	require.NoError(t, err)
+	sig, ok := byDigest[digest.FromBytes(signaturePayload).String()]
+	require.True(t, ok)
+	require.Equal(t, v1.MediaTypeImageManifest, sig["mediaType"])
+	// without an explicit artifact type, the config media type is used
+	require.Equal(t, v1.MediaTypeImageConfig, sig["artifactType"])
+	require.EqualValues(t, len(signaturePayload), sig["size"])
 
-	dgst := digest.FromBytes(payload)
+	_, sociPayload, err := soci.Payload()
+	require.NoError(t, err)
+	idx, ok := byDigest[digest.FromBytes(sociPayload).String()]
+	require.True(t, ok)
+	require.Equal(t, sociIndexArtifactType, idx["artifactType"])
+	require.EqualValues(t, len(sociPayload), idx["size"])
+	require.Equal(t, map[string]interface{}{"com.amazon.soci.build-tool-identifier": "test"}, idx["annotations"])
+}
 
-	tt := []struct {
-		name        string
-		manifestURL string
-		etag        string
-	}{
+func referrers_Get_FilterByArtifactType(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	repoPath := "oci/referrers"
+
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject))
+	soci := seedSOCIIndex(t, env, repoPath, subject)
+
+	_, subjectPayload, err := subject.Payload()
+	require.NoError(t, err)
+	resp, manifests := getReferrers(t, env, repoPath, digest.FromBytes(subjectPayload), url.Values{"artifactType": []string{sociIndexArtifactType}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "artifactType", resp.Header.Get("OCI-Filters-Applied"))
+
+	_, sociPayload, err := soci.Payload()
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	require.Equal(t, digest.FromBytes(sociPayload).String(), manifests[0]["digest"])
+
+	resp, manifests = getReferrers(t, env, repoPath, digest.FromBytes(subjectPayload), url.Values{"artifactType": []string{"application/vnd.example.unknown"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "artifactType", resp.Header.Get("OCI-Filters-Applied"))
+	require.Empty(t, manifests)
+}
+
+// referrers_Get_FilterByArtifactType_Explicit tests that filtering referrers by artifact type matches the `artifactType`
+// of image manifests, and that all returned descriptors match the filter, as the OCI distribution spec v1.1 requires.
+func referrers_Get_FilterByArtifactType_Explicit(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	repoPath := "oci/referrers"
+	sbomType := "application/vnd.example.sbom.v1"
+	sigType := "application/vnd.example.signature.v1"
+
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject), withArtifactType(sbomType))
+	seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject), withArtifactType(sbomType))
+	seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject), withArtifactType(sigType))
+
+	_, subjectPayload, err := subject.Payload()
+	require.NoError(t, err)
+	subjectDigest := digest.FromBytes(subjectPayload)
+
+	resp, manifests := getReferrers(t, env, repoPath, subjectDigest)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("OCI-Filters-Applied"))
+	require.Len(t, manifests, 3)
+
+	for artifactType, expectedCount := range map[string]int{sbomType: 2, sigType: 1} {
+		resp, manifests = getReferrers(t, env, repoPath, subjectDigest, url.Values{"artifactType": []string{artifactType}})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "artifactType", resp.Header.Get("OCI-Filters-Applied"))
+		require.Len(t, manifests, expectedCount)
+		for _, m := range manifests {
+			require.Equal(t, artifactType, m["artifactType"])
+		}
+	}
+}
+
+// referrers_Get_FilterByArtifactType_ConfigMediaType tests that filtering referrers by artifact type matches the config
+// media type of image manifests without an explicit `artifactType`.
+func referrers_Get_FilterByArtifactType_ConfigMediaType(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	repoPath := "oci/referrers"
+
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	signature := seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject))
+	seedSOCIIndex(t, env, repoPath, subject)
+
+	_, subjectPayload, err := subject.Payload()
+	require.NoError(t, err)
+	resp, manifests := getReferrers(t, env, repoPath, digest.FromBytes(subjectPayload), url.Values{"artifactType": []string{v1.MediaTypeImageConfig}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "artifactType", resp.Header.Get("OCI-Filters-Applied"))
+
+	_, signaturePayload, err := signature.Payload()
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	require.Equal(t, digest.FromBytes(signaturePayload).String(), manifests[0]["digest"])
+	require.Equal(t, v1.MediaTypeImageConfig, manifests[0]["artifactType"])
+}
+
+func referrers_Get_SubjectNotFound(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	repoPath := "oci/referrers"
+	seedRandomOCIManifest(t, env, repoPath, putByDigest)
+
+	// the OCI distribution spec requires an empty list instead of a 404 for unknown subjects and repositories
+	for _, path := range []string{repoPath, "oci/unknown"} {
+		resp, manifests := getReferrers(t, env, path, digest.FromString("unknown"))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, manifests)
+	}
+}
+
+func referrers_Get_InvalidDigest(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	resp, err := http.Get(env.server.URL + "/v2/oci/referrers/referrers/sha256:invalid")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "invalid digest", resp, v2.ErrorCodeDigestInvalid)
+}
+
+func manifest_Delete_OCI_CascadeInvalid(t *testing.T, opts ...configOpt) {
+	opts = append(opts, withDeleteCascadeReferrers)
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	repoPath := "oci/cascade"
+	tagName := "latest"
+
+	m := seedRandomOCIManifest(t, env, repoPath, putByTag(tagName))
+
+	tt := []struct {
+		name string
+		url  string
+	}{
+		{
+			name: "invalid value",
+			url:  buildManifestDigestURL(t, env, repoPath, m) + "?cascade=tags",
+		},
+		{
+			name: "delete by tag",
+			url:  buildManifestTagURL(t, env, repoPath, tagName) + "?cascade=referrers",
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := httpDelete(test.url)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+			checkBodyHasErrorCodes(t, test.name, resp, errcode.ErrorCodeUnsupported)
+		})
+	}
+}
+
+func manifest_Put_OCI_WithNonMatchingSubject(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	repoPath := "oci/happypath"
+
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+
+	manifest := &ocischema.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 2,
+			MediaType:     v1.MediaTypeImageManifest,
+		},
+	}
+
+	// Create and push config layer
+	cfgPayload, cfgDesc := ociConfig()
+	uploadURLBase, _ := startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
+	manifest.Config = cfgDesc
+
+	// Create one random layer for this manifest
+	manifest.Layers = make([]distribution.Descriptor, 1)
+	rs, dgst, size := createRandomSmallLayer()
+	uploadURLBase, _ = startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, rs)
+	manifest.Layers[0] = distribution.Descriptor{
+		Digest:    dgst,
+		MediaType: v1.MediaTypeImageLayer,
+		Size:      size,
+	}
+
+	// Set subject as a nonexistent manifest reference
+	manifest.Subject = &distribution.Descriptor{
+		Digest:    digest.Digest("sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		MediaType: v1.MediaTypeImageManifest,
+		Size:      42,
+	}
+
+	deserializedManifest, err := ocischema.FromStruct(*manifest)
+	require.NoError(t, err)
+
+	manifestDigestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifest)
+
+	resp := putManifest(t, "putting manifest with missing subject", manifestDigestURL, v1.MediaTypeImageManifest, deserializedManifest)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "putting manifest with missing subject", resp, v2.ErrorCodeManifestBlobUnknown)
+}
+
+func manifest_Get_OCI_NonMatchingEtag(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	tagName := "ocihappypathtag"
+	repoPath := "oci/happypath"
+
+	deserializedManifest := seedRandomOCIManifest(t, env, repoPath, putByTag(tagName))
+
+	// Build URLs.
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	digestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifest)
+
+	_, payload, err := deserializedManifest.Payload()
+	require.NoError(t, err)
+
+	dgst := digest.FromBytes(payload)
+
+	tt := []struct {
+		name        string
+		manifestURL string
+		etag        string
+	}{
+		{
+			name:        "by tag",
+			manifestURL: tagURL,
+		},
+		{
+			name:        "by digest",
+			manifestURL: digestURL,
+		},
+		{
+			name:        "by tag non matching etag",
+			manifestURL: tagURL,
+			etag:        digest.FromString("no match").String(),
+		},
+		{
+			name:        "by digest non matching etag",
+			manifestURL: digestURL,
+			etag:        digest.FromString("no match").String(),
+		},
+		{
+			name:        "by tag malformed etag",
+			manifestURL: tagURL,
+			etag:        "bad etag",
+		},
+		{
+			name:        "by digest malformed etag",
+			manifestURL: digestURL,
+			etag:        "bad etag",
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.manifestURL, nil)
+			require.NoError(t, err)
+
+			req.Header.Set("Accept", v1.MediaTypeImageManifest)
+			if test.etag != "" {
+				req.Header.Set("If-None-Match", test.etag)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+			require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+			require.Equal(t, fmt.Sprintf(`"%s"`, dgst), resp.Header.Get("ETag"))
+
+			var fetchedManifest *ocischema.DeserializedManifest
+			dec := json.NewDecoder(resp.Body)
+
+			err = dec.Decode(&fetchedManifest)
+			require.NoError(t, err)
+
+			require.EqualValues(t, deserializedManifest, fetchedManifest)
+
+			if env.ns != nil {
+				sizeStr := resp.Header.Get("Content-Length")
+				size, err := strconv.Atoi(sizeStr)
+				require.NoError(t, err)
+
+				expectedEvent := buildEventManifestPull(v1.MediaTypeImageManifest, repoPath, dgst, int64(size))
+				env.ns.AssertEventNotification(t, expectedEvent)
+			}
+		})
+	}
+}
+
+func manifest_Get_OCI_MatchingEtag(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	tagName := "ocihappypathtag"
+	repoPath := "oci/happypath"
+
+	deserializedManifest := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+
+	// Build URLs.
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	digestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifest)
+
+	_, payload, err := deserializedManifest.Payload()
+	require.NoError(t, err)
+
+	dgst := digest.FromBytes(payload)
+
+	tt := []struct {
+		name        string
+		manifestURL string
+		etag        string
+	}{
 		{
 			name:        "by tag quoted etag",
 			manifestURL: tagURL,
@@ -2522,56 +3051,182 @@ func manifest_Get_ManifestList_FallbackToSchema2(t *testing.T, opts ...configOpt
 		},
 	}
 
-	deserializedManifestList, err := manifestlist.FromDescriptors(manifestList.Manifests)
-	require.NoError(t, err)
+	deserializedManifestList, err := manifestlist.FromDescriptors(manifestList.Manifests)
+	require.NoError(t, err)
+
+	manifestDigestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifestList)
+	manifestTagURL := buildManifestTagURL(t, env, repoPath, tagName)
+
+	// Push up manifest list.
+	resp := putManifest(t, "putting manifest list no error", manifestTagURL, manifestlist.MediaTypeManifestList, deserializedManifestList)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+	require.Equal(t, manifestDigestURL, resp.Header.Get("Location"))
+
+	_, payload, err := deserializedManifestList.Payload()
+	require.NoError(t, err)
+
+	dgst := digest.FromBytes(payload)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+
+	if env.ns != nil {
+		manifestEvent := buildEventManifestPush(schema2.MediaTypeManifest, repoPath, "", manifestDigest, int64(len(manifestPayload)))
+		env.ns.AssertEventNotification(t, manifestEvent)
+		manifestListEvent := buildEventManifestPush(manifestlist.MediaTypeManifestList, repoPath, tagName, dgst, int64(len(payload)))
+		env.ns.AssertEventNotification(t, manifestListEvent)
+	}
+
+	// Get manifest list with without avertising support for manifest lists.
+	req, err := http.NewRequest(http.MethodGet, manifestTagURL, nil)
+	require.NoError(t, err)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var fetchedManifest *schema2.DeserializedManifest
+	dec := json.NewDecoder(resp.Body)
+
+	err = dec.Decode(&fetchedManifest)
+	require.NoError(t, err)
+
+	require.EqualValues(t, deserializedManifest, fetchedManifest)
+
+	if env.ns != nil {
+		// we need to assert that the fetched manifest is the one that was sent in the event
+		// however, the manifest list pull is not sent at all
+		expectedEvent := buildEventManifestPull(schema2.MediaTypeManifest, repoPath, manifestDigest, int64(len(manifestPayload)))
+		env.ns.AssertEventNotification(t, expectedEvent)
+	}
+}
+
+func getManifestByPlatform(t *testing.T, manifestURL, platform string) *http.Response {
+	t.Helper()
+
+	u, err := url.Parse(manifestURL)
+	require.NoError(t, err)
+	u.RawQuery = url.Values{"platform": []string{platform}}.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func manifest_Get_OCIIndex_ByPlatform(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	tagName := "platformtag"
+	repoPath := "ociindex/platform"
+
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	ml := seedRandomOCIImageIndex(t, env, repoPath, putByTag(tagName))
+	digestURL := buildManifestDigestURL(t, env, repoPath, ml)
+
+	// random platforms may be repeated, in which case the first matching manifest is returned
+	platform := ml.Manifests[1].Platform
+	var expected digest.Digest
+	for _, d := range ml.Manifests {
+		if d.Platform.OS == platform.OS && d.Platform.Architecture == platform.Architecture {
+			expected = d.Digest
+			break
+		}
+	}
+
+	tt := []struct {
+		name        string
+		manifestURL string
+	}{
+		{
+			name:        "by tag",
+			manifestURL: tagURL,
+		},
+		{
+			name:        "by digest",
+			manifestURL: digestURL,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			// clients requesting a platform do not need to advertise support for OCI indexes or manifests
+			resp := getManifestByPlatform(t, test.manifestURL, platform.OS+"/"+platform.Architecture)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, v1.MediaTypeImageManifest, resp.Header.Get("Content-Type"))
+			require.Equal(t, expected.String(), resp.Header.Get("Docker-Content-Digest"))
+
+			p, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, expected, digest.FromBytes(p))
+		})
+	}
+}
+
+func manifest_Get_OCIIndex_ByPlatform_NotFound(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	tagName := "platformtag"
+	repoPath := "ociindex/platform/notfound"
 
-	manifestDigestURL := buildManifestDigestURL(t, env, repoPath, deserializedManifestList)
-	manifestTagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	seedRandomOCIImageIndex(t, env, repoPath, putByTag(tagName))
 
-	// Push up manifest list.
-	resp := putManifest(t, "putting manifest list no error", manifestTagURL, manifestlist.MediaTypeManifestList, deserializedManifestList)
+	resp := getManifestByPlatform(t, tagURL, "windows/s390x")
 	defer resp.Body.Close()
-	require.Equal(t, http.StatusCreated, resp.StatusCode)
-	require.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
-	require.Equal(t, manifestDigestURL, resp.Header.Get("Location"))
 
-	_, payload, err := deserializedManifestList.Payload()
-	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "getting manifest for unknown platform", resp, v2.ErrorCodeManifestUnknown)
+}
 
-	dgst := digest.FromBytes(payload)
-	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+func manifest_Get_ByPlatform_InvalidPlatform(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
 
-	if env.ns != nil {
-		manifestEvent := buildEventManifestPush(schema2.MediaTypeManifest, repoPath, "", manifestDigest, int64(len(manifestPayload)))
-		env.ns.AssertEventNotification(t, manifestEvent)
-		manifestListEvent := buildEventManifestPush(manifestlist.MediaTypeManifestList, repoPath, tagName, dgst, int64(len(payload)))
-		env.ns.AssertEventNotification(t, manifestListEvent)
-	}
+	tagName := "platformtag"
+	repoPath := "ociindex/platform/invalid"
 
-	// Get manifest list with without avertising support for manifest lists.
-	req, err := http.NewRequest(http.MethodGet, manifestTagURL, nil)
-	require.NoError(t, err)
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	seedRandomOCIImageIndex(t, env, repoPath, putByTag(tagName))
 
-	resp, err = http.DefaultClient.Do(req)
-	require.NoError(t, err)
-	defer resp.Body.Close()
+	for _, platform := range []string{"linux", "linux/", "/amd64", "linux/arm/v7/foo"} {
+		t.Run(platform, func(t *testing.T) {
+			resp := getManifestByPlatform(t, tagURL, platform)
+			defer resp.Body.Close()
 
-	require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "getting manifest with invalid platform", resp, v2.ErrorCodePlatformInvalid)
+		})
+	}
+}
 
-	var fetchedManifest *schema2.DeserializedManifest
-	dec := json.NewDecoder(resp.Body)
+func manifest_Get_ByPlatform_NotAnIndex(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
 
-	err = dec.Decode(&fetchedManifest)
+	tagName := "platformtag"
+	repoPath := "schema2/platform"
+
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+	_, payload, err := m.Payload()
 	require.NoError(t, err)
 
-	require.EqualValues(t, deserializedManifest, fetchedManifest)
+	// the platform is ignored for manifests that are not lists or indexes
+	resp := getManifestByPlatform(t, tagURL, "windows/s390x")
+	defer resp.Body.Close()
 
-	if env.ns != nil {
-		// we need to assert that the fetched manifest is the one that was sent in the event
-		// however, the manifest list pull is not sent at all
-		expectedEvent := buildEventManifestPull(schema2.MediaTypeManifest, repoPath, manifestDigest, int64(len(manifestPayload)))
-		env.ns.AssertEventNotification(t, expectedEvent)
-	}
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, digest.FromBytes(payload).String(), resp.Header.Get("Docker-Content-Digest"))
 }
 
 func blob_Get(t *testing.T, opts ...configOpt) {
@@ -2983,6 +3638,93 @@ func tags_Get_RepositoryNotFound(t *testing.T, opts ...configOpt) {
 	checkBodyHasErrorCodes(t, "repository not found", resp, v2.ErrorCodeNameUnknown)
 }
 
+func tags_Get_MatchingEtag(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	createRepository(t, env, imageName.Name(), "latest")
+
+	tagsURL, err := env.builder.BuildTagsURL(imageName)
+	require.NoError(t, err)
+
+	assertListGetMatchingEtag(t, tagsURL, func() {
+		createRepository(t, env, imageName.Name(), "1.0.0")
+	})
+}
+
+// tags_Get_TotalCount tests the opt-in total count headers of the /v2/<name>/tags/list endpoint, which are only
+// supported with the metadata database.
+func tags_Get_TotalCount(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	for _, tag := range []string{"1.0.0", "1.0.1", "latest"} {
+		createRepository(t, env, imageName.Name(), tag)
+	}
+
+	tt := []struct {
+		name          string
+		queryParams   url.Values
+		expectedCount string
+		expectedPages string
+	}{
+		{
+			name:        "not requested",
+			queryParams: url.Values{"n": []string{"2"}},
+		},
+		{
+			name:        "disabled",
+			queryParams: url.Values{"n": []string{"2"}, "count": []string{"false"}},
+		},
+		{
+			name:        "invalid value is ignored",
+			queryParams: url.Values{"n": []string{"2"}, "count": []string{"yes"}},
+		},
+		{
+			name:          "1st page",
+			queryParams:   url.Values{"n": []string{"2"}, "count": []string{"true"}},
+			expectedCount: "3",
+			expectedPages: "2",
+		},
+		{
+			name:          "last page",
+			queryParams:   url.Values{"n": []string{"2"}, "last": []string{"1.0.1"}, "count": []string{"true"}},
+			expectedCount: "3",
+			expectedPages: "2",
+		},
+		{
+			name:          "single page",
+			queryParams:   url.Values{"count": []string{"true"}},
+			expectedCount: "3",
+			expectedPages: "1",
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			tagsURL, err := env.builder.BuildTagsURL(imageName, test.queryParams)
+			require.NoError(t, err)
+
+			resp, err := http.Get(tagsURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			if !env.config.Database.Enabled {
+				require.Empty(t, resp.Header.Get("X-Total-Count"))
+				require.Empty(t, resp.Header.Get("X-Total-Pages"))
+				return
+			}
+			require.Equal(t, test.expectedCount, resp.Header.Get("X-Total-Count"))
+			require.Equal(t, test.expectedPages, resp.Header.Get("X-Total-Pages"))
+		})
+	}
+}
+
 func tags_Get_EmptyRepository(t *testing.T, opts ...configOpt) {
 	opts = append(opts)
 	env := newTestEnv(t, opts...)
@@ -3467,6 +4209,58 @@ func catalog_Get_Empty(t *testing.T, opts ...configOpt) {
 	require.Empty(t, resp.Header.Get("Link"))
 }
 
+func catalog_Get_MatchingEtag(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	createRepository(t, env, "foo/bar", "latest")
+
+	catalogURL, err := env.builder.BuildCatalogURL()
+	require.NoError(t, err)
+
+	assertListGetMatchingEtag(t, catalogURL, func() {
+		createRepository(t, env, "foo/car", "latest")
+	})
+}
+
+// assertListGetMatchingEtag checks that requests to a list endpoint with an If-None-Match header matching the ETag of
+// the current response result in a 304 Not Modified, and that the ETag changes once the list is modified by `update`.
+func assertListGetMatchingEtag(t *testing.T, u string, update func()) {
+	t.Helper()
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("Etag")
+	require.NotEmpty(t, etag)
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Equal(t, etag, resp.Header.Get("Etag"))
+	p, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Empty(t, p)
+
+	update()
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEmpty(t, resp.Header.Get("Etag"))
+	require.NotEqual(t, etag, resp.Header.Get("Etag"))
+}
+
 func catalog_Get_TooLarge(t *testing.T, opts ...configOpt) {
 	env := newTestEnv(t, opts...)
 	defer env.Shutdown()
@@ -3481,3 +4275,206 @@ func catalog_Get_TooLarge(t *testing.T, opts ...configOpt) {
 	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	checkBodyHasErrorCodes(t, "requesting too many repos", resp, v2.ErrorCodePaginationNumberInvalid)
 }
+
+// catalog_Get_Filtered tests the prefix and modified_since filters of the /v2/_catalog endpoint, which are only
+// supported with the metadata database.
+func catalog_Get_Filtered(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	for _, repo := range []string{"bar/a", "foo/a", "foo/b", "foo/c"} {
+		createRepository(t, env, repo, "latest")
+	}
+	since := time.Now().UTC()
+	createRepository(t, env, "foo/d", "latest")
+	createRepository(t, env, "bar/a", "stable")
+
+	catalogURL, err := env.builder.BuildCatalogURL(url.Values{"prefix": []string{"foo/"}})
+	require.NoError(t, err)
+
+	resp, err := http.Get(catalogURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if !env.config.Database.Enabled {
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		checkBodyHasErrorCodes(t, "catalog filters without database", resp, v2.ErrorCodeCatalogFilterInvalid)
+		return
+	}
+
+	tt := []struct {
+		name               string
+		queryParams        url.Values
+		expectedBody       catalogAPIResponse
+		expectedLinkHeader string
+	}{
+		{
+			name:         "prefix",
+			queryParams:  url.Values{"prefix": []string{"foo/"}},
+			expectedBody: catalogAPIResponse{Repositories: []string{"foo/a", "foo/b", "foo/c", "foo/d"}},
+		},
+		{
+			name:               "prefix 1st page",
+			queryParams:        url.Values{"prefix": []string{"foo/"}, "n": []string{"2"}},
+			expectedBody:       catalogAPIResponse{Repositories: []string{"foo/a", "foo/b"}},
+			expectedLinkHeader: `</v2/_catalog?last=foo%2Fb&n=2&prefix=foo%2F>; rel="next"`,
+		},
+		{
+			name:         "prefix last page",
+			queryParams:  url.Values{"prefix": []string{"foo/"}, "n": []string{"2"}, "last": []string{"foo/b"}},
+			expectedBody: catalogAPIResponse{Repositories: []string{"foo/c", "foo/d"}},
+		},
+		{
+			name:         "modified since",
+			queryParams:  url.Values{"modified_since": []string{since.Format(time.RFC3339Nano)}},
+			expectedBody: catalogAPIResponse{Repositories: []string{"bar/a", "foo/d"}},
+		},
+		{
+			name: "prefix and modified since 1st page",
+			queryParams: url.Values{
+				"prefix":         []string{"foo/"},
+				"modified_since": []string{since.Format(time.RFC3339Nano)},
+				"n":              []string{"1"},
+			},
+			expectedBody: catalogAPIResponse{Repositories: []string{"foo/d"}},
+		},
+		{
+			name: "modified since 1st page",
+			queryParams: url.Values{
+				"modified_since": []string{since.Format(time.RFC3339Nano)},
+				"n":              []string{"1"},
+			},
+			expectedBody: catalogAPIResponse{Repositories: []string{"bar/a"}},
+			expectedLinkHeader: fmt.Sprintf(
+				`</v2/_catalog?last=bar%%2Fa&modified_since=%s&n=1>; rel="next"`,
+				url.QueryEscape(since.Format(time.RFC3339Nano)),
+			),
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			catalogURL, err := env.builder.BuildCatalogURL(test.queryParams)
+			require.NoError(t, err)
+
+			resp, err := http.Get(catalogURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var body catalogAPIResponse
+			err = json.NewDecoder(resp.Body).Decode(&body)
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedBody, body)
+			require.Equal(t, test.expectedLinkHeader, resp.Header.Get("Link"))
+
+			var applied []string
+			for _, key := range []string{"prefix", "modified_since"} {
+				if test.queryParams.Has(key) {
+					applied = append(applied, key)
+				}
+			}
+			require.Equal(t, strings.Join(applied, ","), resp.Header.Get("OCI-Filters-Applied"))
+		})
+	}
+}
+
+// catalog_Get_TotalCount tests the opt-in total count headers of the /v2/_catalog endpoint, which are only supported
+// with the metadata database.
+func catalog_Get_TotalCount(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	for _, repo := range []string{"bar/a", "foo/a", "foo/b", "foo/c"} {
+		createRepository(t, env, repo, "latest")
+	}
+
+	tt := []struct {
+		name          string
+		queryParams   url.Values
+		expectedCount string
+		expectedPages string
+	}{
+		{
+			name:        "not requested",
+			queryParams: url.Values{"n": []string{"3"}},
+		},
+		{
+			name:          "1st page",
+			queryParams:   url.Values{"n": []string{"3"}, "count": []string{"true"}},
+			expectedCount: "4",
+			expectedPages: "2",
+		},
+		{
+			name:          "last page",
+			queryParams:   url.Values{"n": []string{"3"}, "last": []string{"foo/b"}, "count": []string{"true"}},
+			expectedCount: "4",
+			expectedPages: "2",
+		},
+		{
+			name:          "prefix",
+			queryParams:   url.Values{"prefix": []string{"foo/"}, "n": []string{"3"}, "count": []string{"true"}},
+			expectedCount: "3",
+			expectedPages: "1",
+		},
+		{
+			name:          "no matches",
+			queryParams:   url.Values{"prefix": []string{"baz/"}, "count": []string{"true"}},
+			expectedCount: "0",
+			expectedPages: "0",
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			if !env.config.Database.Enabled && test.queryParams.Has("prefix") {
+				// catalog filters are not supported without the metadata database
+				return
+			}
+
+			catalogURL, err := env.builder.BuildCatalogURL(test.queryParams)
+			require.NoError(t, err)
+
+			resp, err := http.Get(catalogURL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			if !env.config.Database.Enabled {
+				require.Empty(t, resp.Header.Get("X-Total-Count"))
+				require.Empty(t, resp.Header.Get("X-Total-Pages"))
+				return
+			}
+			require.Equal(t, test.expectedCount, resp.Header.Get("X-Total-Count"))
+			require.Equal(t, test.expectedPages, resp.Header.Get("X-Total-Pages"))
+		})
+	}
+}
+
+// catalog_Get_InvalidFilter tests that the /v2/_catalog endpoint rejects invalid modified_since and label values.
+func catalog_Get_InvalidFilter(t *testing.T, opts ...configOpt) {
+	env := newTestEnv(t, opts...)
+	defer env.Shutdown()
+
+	catalogURL, err := env.builder.BuildCatalogURL(url.Values{"modified_since": []string{"yesterday"}})
+	require.NoError(t, err)
+
+	resp, err := http.Get(catalogURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "invalid modified_since", resp, v2.ErrorCodeCatalogFilterInvalid)
+
+	catalogURL, err = env.builder.BuildCatalogURL(url.Values{"label": []string{"Team=backend"}})
+	require.NoError(t, err)
+
+	resp, err = http.Get(catalogURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "invalid label", resp, v2.ErrorCodeCatalogFilterInvalid)
+}
@@ -3,19 +3,31 @@
 package handlers_test
 
 import (
+	"archive/tar"
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/manifest/helm"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/notifications"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
 	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
@@ -24,6 +36,7 @@ import (
 	dbtestutil "github.com/docker/distribution/registry/datastore/testutil"
 	"github.com/docker/distribution/registry/handlers"
 	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -214,6 +227,73 @@ func TestGitlabAPI_Repository_Get_SizeWithDescendants_NonExistingTopLevel(t *tes
 	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
+func TestGitlabAPI_Repository_Get_SizeBreakdown(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoPath := "foo/bar"
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+
+	// push two tagged images to the target repository, and the first one to another repository under the same
+	// top-level namespace, so that its layers are shared
+	m1 := seedRandomSchema2Manifest(t, env, repoPath, putByTag("a"))
+	m2 := seedRandomSchema2Manifest(t, env, repoPath, putByTag("b"))
+
+	otherRepoPath := "foo/car"
+	for _, d := range m1.References() {
+		assertBlobPostMountResponse(t, env, repoPath, otherRepoPath, d.Digest, http.StatusCreated)
+	}
+	resp := putManifest(t, "putting manifest", buildManifestTagURL(t, env, otherRepoPath, "latest"), schema2.MediaTypeManifest, m1.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var sharedSize, uniqueSize int64
+	for _, d := range m1.Layers() {
+		sharedSize += d.Size
+	}
+	for _, d := range m2.Layers() {
+		uniqueSize += d.Size
+	}
+	// all seeded images share the same configuration blob
+	configsSize := m1.Config().Size
+
+	u, err := env.builder.BuildGitlabV1RepositoryURL(repoRef, url.Values{
+		"size": []string{"breakdown"},
+	})
+	require.NoError(t, err)
+
+	resp, err = http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var r handlers.RepositoryAPIResponse
+	p, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	err = json.Unmarshal(p, &r)
+	require.NoError(t, err)
+
+	require.Equal(t, sharedSize+uniqueSize, *r.Size)
+	require.NotNil(t, r.SizeBreakdown)
+
+	b := r.SizeBreakdown
+	require.Equal(t, sharedSize+uniqueSize, b.Layers)
+	require.Equal(t, sharedSize, b.SharedLayers)
+	require.Equal(t, uniqueSize, b.UniqueLayers)
+	require.Equal(t, configsSize, b.Configs)
+	require.Positive(t, b.Manifests)
+
+	require.Len(t, b.LargestImages, 2)
+	require.GreaterOrEqual(t, b.LargestImages[0].Size, b.LargestImages[1].Size)
+	for _, i := range b.LargestImages {
+		require.Contains(t, []string{"a", "b"}, i.Tag)
+		require.NotEmpty(t, i.Digest)
+		require.Equal(t, schema2.MediaTypeManifest, i.MediaType)
+	}
+}
+
 func TestGitlabAPI_RepositoryTagsList(t *testing.T) {
 	env := newTestEnv(t)
 	t.Cleanup(env.Shutdown)
@@ -615,6 +695,77 @@ func TestGitlabAPI_RepositoryTagsList(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  &v1.ErrorCodeInvalidQueryParamValue,
 		},
+		{
+			name:           "filtered by name prefix",
+			queryParams:    url.Values{"name_prefix": []string{"jyi7b"}},
+			expectedStatus: http.StatusOK,
+			expectedOrderedTags: []string{
+				"jyi7b",
+				"jyi7b-fxt1v",
+			},
+		},
+		{
+			name:           "filtered by name prefix with literal underscore",
+			queryParams:    url.Values{"name_prefix": []string{"x_"}},
+			expectedStatus: http.StatusOK,
+			expectedOrderedTags: []string{
+				"x_y_z",
+			},
+		},
+		{
+			name:           "filtered by name regex",
+			queryParams:    url.Values{"name_regex": []string{"^[a-k].*[0-9]$"}},
+			expectedStatus: http.StatusOK,
+			expectedOrderedTags: []string{
+				"dcsl6",
+				"kb0j5",
+			},
+		},
+		{
+			name:           "filtered by name, name prefix and name regex 1st page",
+			queryParams:    url.Values{"name": []string{"jyi7b"}, "name_prefix": []string{"k"}, "name_regex": []string{"b$"}, "n": []string{"1"}},
+			expectedStatus: http.StatusOK,
+			expectedOrderedTags: []string{
+				"kav2-jyi7b",
+			},
+		},
+		{
+			name:           "filtered by name regex 1st page",
+			queryParams:    url.Values{"name_regex": []string{"jyi7b"}, "n": []string{"2"}},
+			expectedStatus: http.StatusOK,
+			expectedOrderedTags: []string{
+				"jyi7b",
+				"jyi7b-fxt1v",
+			},
+			expectedLinkHeader: `</gitlab/v1/repositories/foo/bar/tags/list/?last=jyi7b-fxt1v&n=2&name_regex=jyi7b>; rel="next"`,
+		},
+		{
+			name:           "filtered by name prefix last page sorted by name descending",
+			queryParams:    url.Values{"name_prefix": []string{"k"}, "last": []string{"kb0j5"}, "n": []string{"1"}, "sort": []string{"-name"}},
+			expectedStatus: http.StatusOK,
+			expectedOrderedTags: []string{
+				"kav2-jyi7b",
+			},
+			expectedLinkHeader: `</gitlab/v1/repositories/foo/bar/tags/list/?before=kav2-jyi7b&n=1&name_prefix=k&sort=-name>; rel="previous"`,
+		},
+		{
+			name:           "invalid name prefix filter value characters",
+			queryParams:    url.Values{"name_prefix": []string{"*foo"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  &v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:           "invalid name regex filter",
+			queryParams:    url.Values{"name_regex": []string{"v1.(0"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  &v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:           "invalid name regex filter length",
+			queryParams:    url.Values{"name_regex": []string{strings.Repeat("a", 257)}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  &v1.ErrorCodeInvalidQueryParamValue,
+		},
 	}
 
 	for _, test := range tt {
@@ -1060,6 +1211,234 @@ func TestGitlabAPI_RepositoryTagsList_OmitEmptyConfigDigest(t *testing.T) {
 	require.NotContains(t, string(payload), "config_digest")
 }
 
+func TestGitlabAPI_RepositoryTagsList_FilterByArtifactType(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	sbomType := "application/vnd.example.sbom.v1"
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("latest"))
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("sbom-a"), withArtifactType(sbomType))
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("sbom-b"), withArtifactType(sbomType))
+
+	tt := []struct {
+		name                  string
+		queryParams           url.Values
+		expectedStatus        int
+		expectedTags          []string
+		expectedFilterHeader  string
+		expectedLinkHeader    string
+		expectedErrorResponse bool
+	}{
+		{
+			name:           "no filters",
+			expectedStatus: http.StatusOK,
+			expectedTags:   []string{"latest", "sbom-a", "sbom-b"},
+		},
+		{
+			name:                 "artifact type",
+			queryParams:          url.Values{"artifact_type": []string{sbomType}},
+			expectedStatus:       http.StatusOK,
+			expectedTags:         []string{"sbom-a", "sbom-b"},
+			expectedFilterHeader: "artifact_type",
+		},
+		{
+			name:                 "artifact type 1st page",
+			queryParams:          url.Values{"artifact_type": []string{sbomType}, "n": []string{"1"}},
+			expectedStatus:       http.StatusOK,
+			expectedTags:         []string{"sbom-a"},
+			expectedFilterHeader: "artifact_type",
+			expectedLinkHeader:   `</gitlab/v1/repositories/foo/bar/tags/list/?artifact_type=application%2Fvnd.example.sbom.v1&last=sbom-a&n=1>; rel="next"`,
+		},
+		{
+			name:                 "name prefix and artifact type",
+			queryParams:          url.Values{"name_prefix": []string{"sbom-b"}, "artifact_type": []string{sbomType}},
+			expectedStatus:       http.StatusOK,
+			expectedTags:         []string{"sbom-b"},
+			expectedFilterHeader: "name_prefix,artifact_type",
+		},
+		{
+			name:                 "unknown artifact type",
+			queryParams:          url.Values{"artifact_type": []string{"application/vnd.example.unknown"}},
+			expectedStatus:       http.StatusOK,
+			expectedTags:         []string{},
+			expectedFilterHeader: "artifact_type",
+		},
+		{
+			name:                  "invalid artifact type",
+			queryParams:           url.Values{"artifact_type": []string{"not a media type"}},
+			expectedStatus:        http.StatusBadRequest,
+			expectedErrorResponse: true,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := env.builder.BuildGitlabV1RepositoryTagsURL(repoRef, test.queryParams)
+			require.NoError(t, err)
+			resp, err := http.Get(u)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+			if test.expectedErrorResponse {
+				checkBodyHasErrorCodes(t, "", resp, v1.ErrorCodeInvalidQueryParamValue)
+				return
+			}
+
+			var body []*handlers.RepositoryTagResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+			names := make([]string, 0, len(body))
+			for _, tag := range body {
+				names = append(names, tag.Name)
+				if test.queryParams.Has("artifact_type") {
+					require.Equal(t, sbomType, tag.ArtifactType)
+				}
+			}
+			require.Equal(t, test.expectedTags, names)
+			require.Equal(t, test.expectedFilterHeader, resp.Header.Get("OCI-Filters-Applied"))
+			require.Equal(t, test.expectedLinkHeader, resp.Header.Get("Link"))
+		})
+	}
+}
+
+func TestGitlabAPI_RepositoryTagsList_HelmChart(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/charts")
+	require.NoError(t, err)
+
+	cfg := []byte(`{"name":"my-chart","version":"1.0.0","apiVersion":"v2"}`)
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("1.0.0"), withConfig(helm.MediaTypeConfig, cfg))
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("2.0.0"), withConfig(helm.MediaTypeConfig, cfg),
+		withAnnotations(map[string]string{
+			"org.opencontainers.image.title":   "my-chart",
+			"org.opencontainers.image.version": "2.0.0",
+		}))
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("latest"))
+
+	u, err := env.builder.BuildGitlabV1RepositoryTagsURL(repoRef)
+	require.NoError(t, err)
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body []*handlers.RepositoryTagResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body, 3)
+
+	// version from the configuration payload
+	require.Equal(t, "1.0.0", body[0].Name)
+	require.Equal(t, "my-chart", body[0].ChartName)
+	require.Equal(t, "1.0.0", body[0].ChartVersion)
+	// version from the manifest annotations
+	require.Equal(t, "2.0.0", body[1].Name)
+	require.Equal(t, "my-chart", body[1].ChartName)
+	require.Equal(t, "2.0.0", body[1].ChartVersion)
+	// not a chart
+	require.Equal(t, "latest", body[2].Name)
+	require.Empty(t, body[2].ChartName)
+	require.Empty(t, body[2].ChartVersion)
+}
+
+func TestGitlabAPI_RepositoryTagsList_TotalCount(t *testing.T) {
+	env := newTestEnv(t, withRedisCache(testutil.RedisServer(t).Addr()))
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	sbomType := "application/vnd.example.sbom.v1"
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("latest"))
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("sbom-a"), withArtifactType(sbomType))
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("sbom-b"), withArtifactType(sbomType))
+
+	tt := []struct {
+		name           string
+		queryParams    url.Values
+		expectedStatus int
+		expectedCount  string
+		expectedPages  string
+	}{
+		{
+			name:           "not requested",
+			queryParams:    url.Values{"n": []string{"2"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "disabled",
+			queryParams:    url.Values{"n": []string{"2"}, "count": []string{"false"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "1st page",
+			queryParams:    url.Values{"n": []string{"2"}, "count": []string{"true"}},
+			expectedStatus: http.StatusOK,
+			expectedCount:  "3",
+			expectedPages:  "2",
+		},
+		{
+			name:           "last page",
+			queryParams:    url.Values{"n": []string{"2"}, "last": []string{"sbom-a"}, "count": []string{"true"}},
+			expectedStatus: http.StatusOK,
+			expectedCount:  "3",
+			expectedPages:  "2",
+		},
+		{
+			name:           "artifact type",
+			queryParams:    url.Values{"n": []string{"1"}, "artifact_type": []string{sbomType}, "count": []string{"true"}},
+			expectedStatus: http.StatusOK,
+			expectedCount:  "2",
+			expectedPages:  "2",
+		},
+		{
+			name:           "name prefix",
+			queryParams:    url.Values{"name_prefix": []string{"sbom-"}, "count": []string{"true"}},
+			expectedStatus: http.StatusOK,
+			expectedCount:  "2",
+			expectedPages:  "1",
+		},
+		{
+			name:           "no matches",
+			queryParams:    url.Values{"name": []string{"unknown"}, "count": []string{"true"}},
+			expectedStatus: http.StatusOK,
+			expectedCount:  "0",
+			expectedPages:  "0",
+		},
+		{
+			name:           "invalid value",
+			queryParams:    url.Values{"count": []string{"yes"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := env.builder.BuildGitlabV1RepositoryTagsURL(repoRef, test.queryParams)
+			require.NoError(t, err)
+			resp, err := http.Get(u)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+			if test.expectedStatus != http.StatusOK {
+				checkBodyHasErrorCodes(t, "", resp, v1.ErrorCodeInvalidQueryParamValue)
+				return
+			}
+			require.Equal(t, test.expectedCount, resp.Header.Get("X-Total-Count"))
+			require.Equal(t, test.expectedPages, resp.Header.Get("X-Total-Pages"))
+		})
+	}
+}
+
 func TestGitlabAPI_SubRepositoryList(t *testing.T) {
 	env := newTestEnv(t)
 	t.Cleanup(env.Shutdown)
@@ -1220,6 +1599,51 @@ func TestGitlabAPI_SubRepositoryList(t *testing.T) {
 // here instead of TestGitlabAPI_SubRepositoryList because we have to create more than 100 repositories
 // w/tags to test this. Doing it in the former test would mean more complicated table test definitions,
 // instead of the current small set of repositories w/tags that make it easy to follow/understand the expected results.
+func TestGitlabAPI_SubRepositoryList_TotalCount(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	baseRepoName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	seedMultipleRepositoriesWithTaggedManifest(t, env, "latest", []string{"foo/bar", "foo/bar/a", "foo/bar/b", "foo/bar/b/c"})
+	// repositories without tags are not listed and therefore not counted
+	seedRandomSchema2Manifest(t, env, "foo/bar/b2", putByDigest)
+
+	u, err := env.builder.BuildGitlabV1SubRepositoriesURL(baseRepoName, url.Values{"n": []string{"3"}, "count": []string{"true"}})
+	require.NoError(t, err)
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "4", resp.Header.Get("X-Total-Count"))
+	require.Equal(t, "2", resp.Header.Get("X-Total-Pages"))
+
+	// the group repositories list includes the same repositories
+	u, err = env.builder.BuildGitlabV1GroupRepositoriesURL(baseRepoName, url.Values{"n": []string{"3"}, "count": []string{"true"}})
+	require.NoError(t, err)
+	resp, err = http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "4", resp.Header.Get("X-Total-Count"))
+	require.Equal(t, "2", resp.Header.Get("X-Total-Pages"))
+
+	// the headers are opt-in
+	u, err = env.builder.BuildGitlabV1SubRepositoriesURL(baseRepoName)
+	require.NoError(t, err)
+	resp, err = http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("X-Total-Count"))
+	require.Empty(t, resp.Header.Get("X-Total-Pages"))
+}
+
 func TestGitlabAPI_SubRepositoryList_DefaultPageSize(t *testing.T) {
 
 	env := newTestEnv(t)
@@ -1321,33 +1745,299 @@ func TestGitlabAPI_SubRepositoryList_NonExistentRepository(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
-func TestGitlabAPI_RenameRepository_WithNoBaseRepository(t *testing.T) {
-	nestedRepos := []string{
+func TestGitlabAPI_GroupRepositoryList(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	sortedReposWithTag := []string{
+		"foo/bar",
 		"foo/bar/a",
+		"foo/baz",
 	}
 
-	baseRepoName, err := reference.WithName("foo/bar")
+	groupName, err := reference.WithName("foo")
 	require.NoError(t, err)
 
-	// create an auth token provider
-	tokenProvider := NewAuthTokenProvider(t)
-
-	// generate one full access auth token for all tests
-	token := tokenProvider.TokenWithActions(fullAccessTokenWithProjectMeta(baseRepoName.Name(), baseRepoName.Name()))
+	tagName := "latest"
+	seedMultipleRepositoriesWithTaggedManifest(t, env, tagName, sortedReposWithTag)
+	// tag a second manifest in foo/bar
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("stable"))
+	// seed a repo under the same group but without tags
+	seedRandomSchema2Manifest(t, env, "foo/qux", putByDigest)
 
 	tt := []struct {
 		name               string
 		queryParams        url.Values
-		requestBody        []byte
-		expectedRespStatus int
-		expectedRespError  *errcode.ErrorCode
-		expectedRespBody   *handlers.RenameRepositoryAPIResponse
+		expectedRepoPaths  []string
+		expectedLinkHeader string
+		expectedStatus     int
+		expectedError      *errcode.ErrorCode
 	}{
 		{
-			name:               "dry run param not set means implicit false",
-			requestBody:        []byte(`{ "name" : "not-bar" }`),
-			expectedRespStatus: http.StatusNoContent,
-			expectedRespBody:   nil,
+			name:              "no query parameters",
+			expectedStatus:    http.StatusOK,
+			expectedRepoPaths: sortedReposWithTag,
+		},
+		{
+			name:               "1st page",
+			queryParams:        url.Values{"n": []string{"2"}},
+			expectedStatus:     http.StatusOK,
+			expectedRepoPaths:  sortedReposWithTag[:2],
+			expectedLinkHeader: fmt.Sprintf(`</gitlab/v1/groups/%s/repositories/?last=%s&n=2>; rel="next"`, groupName.Name(), url.QueryEscape(sortedReposWithTag[1])),
+		},
+		{
+			name:              "last page",
+			queryParams:       url.Values{"last": []string{"foo/bar/a"}, "n": []string{"2"}},
+			expectedStatus:    http.StatusOK,
+			expectedRepoPaths: sortedReposWithTag[2:],
+		},
+		{
+			name:           "zero page size",
+			queryParams:    url.Values{"n": []string{"0"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  &v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:           "invalid marker format",
+			queryParams:    url.Values{"last": []string{":"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  &v1.ErrorCodeInvalidQueryParamValue,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := env.builder.BuildGitlabV1GroupRepositoriesURL(groupName, test.queryParams)
+			require.NoError(t, err)
+			resp, err := http.Get(u)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+
+			if test.expectedError != nil {
+				checkBodyHasErrorCodes(t, "", resp, *test.expectedError)
+				return
+			}
+
+			var body []*handlers.GroupRepositoryAPIResponse
+			err = json.NewDecoder(resp.Body).Decode(&body)
+			require.NoError(t, err)
+
+			actualRepoPaths := make([]string, 0, len(body))
+			for _, r := range body {
+				actualRepoPaths = append(actualRepoPaths, r.Path)
+
+				require.NotEmpty(t, r.CreatedAt)
+				require.NotEmpty(t, r.LastPublishedAt)
+				require.Positive(t, r.Size)
+				if r.Path == "foo/bar" {
+					require.Equal(t, 2, r.TagCount)
+				} else {
+					require.Equal(t, 1, r.TagCount)
+				}
+			}
+			require.Equal(t, test.expectedRepoPaths, actualRepoPaths)
+			require.Equal(t, test.expectedLinkHeader, resp.Header.Get("Link"))
+		})
+	}
+}
+
+func TestGitlabAPI_GroupRepositoryList_NonExistentGroup(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	groupName, err := reference.WithName("foo")
+	require.NoError(t, err)
+
+	u, err := env.builder.BuildGitlabV1GroupRepositoriesURL(groupName)
+	require.NoError(t, err)
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeNameUnknown)
+}
+
+func TestGitlabAPI_GroupManifestSearch(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	groupName, err := reference.WithName("foo")
+	require.NoError(t, err)
+
+	digestOf := func(m *ocischema.DeserializedManifest) string {
+		_, payload, err := m.Payload()
+		require.NoError(t, err)
+		return digest.FromBytes(payload).String()
+	}
+
+	// seed matching manifests in different repositories of the group and a non-matching one
+	m1 := seedRandomOCIManifest(t, env, "foo/bar", putByTag("latest"), withAnnotations(map[string]string{
+		"org.opencontainers.image.vendor": "GitLab",
+		"org.example.stage":               "production",
+	}))
+	m2 := seedRandomOCIManifest(t, env, "foo/baz", putByDigest, withAnnotations(map[string]string{
+		"org.opencontainers.image.vendor": "GitLab",
+	}))
+	seedRandomOCIManifest(t, env, "foo/qux", putByTag("latest"), withAnnotations(map[string]string{
+		"org.opencontainers.image.vendor": "Other",
+	}))
+	// and a matching manifest in a different group
+	seedRandomOCIManifest(t, env, "other/bar", putByTag("latest"), withAnnotations(map[string]string{
+		"org.opencontainers.image.vendor": "GitLab",
+	}))
+
+	d1, d2 := digestOf(m1), digestOf(m2)
+
+	tt := []struct {
+		name               string
+		queryParams        url.Values
+		expectedDigests    []string
+		expectedLinkHeader string
+		expectedStatus     int
+		expectedError      *errcode.ErrorCode
+	}{
+		{
+			name:            "key and value",
+			queryParams:     url.Values{"annotation": []string{"org.opencontainers.image.vendor=GitLab"}},
+			expectedStatus:  http.StatusOK,
+			expectedDigests: []string{d1, d2},
+		},
+		{
+			name:            "multiple conditions",
+			queryParams:     url.Values{"annotation": []string{"org.opencontainers.image.vendor=GitLab", "org.example.stage"}},
+			expectedStatus:  http.StatusOK,
+			expectedDigests: []string{d1},
+		},
+		{
+			name:            "no matches",
+			queryParams:     url.Values{"annotation": []string{"org.example.stage=staging"}},
+			expectedStatus:  http.StatusOK,
+			expectedDigests: []string{},
+		},
+		{
+			name:            "1st page",
+			queryParams:     url.Values{"annotation": []string{"org.opencontainers.image.vendor=GitLab"}, "n": []string{"1"}},
+			expectedStatus:  http.StatusOK,
+			expectedDigests: []string{d1},
+			expectedLinkHeader: fmt.Sprintf(`</gitlab/v1/groups/%s/manifests/search/?annotation=%s&last=%s&n=1>; rel="next"`,
+				groupName.Name(), url.QueryEscape("org.opencontainers.image.vendor=GitLab"), url.QueryEscape("foo/bar@"+d1)),
+		},
+		{
+			name: "last page",
+			queryParams: url.Values{
+				"annotation": []string{"org.opencontainers.image.vendor=GitLab"},
+				"n":          []string{"2"},
+				"last":       []string{"foo/bar@" + d1},
+			},
+			expectedStatus:  http.StatusOK,
+			expectedDigests: []string{d2},
+		},
+		{
+			name:           "no annotation",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  &v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:           "invalid marker format",
+			queryParams:    url.Values{"annotation": []string{"org.example.stage"}, "last": []string{"foo/bar"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  &v1.ErrorCodeInvalidQueryParamValue,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := env.builder.BuildGitlabV1GroupManifestSearchURL(groupName, test.queryParams)
+			require.NoError(t, err)
+			resp, err := http.Get(u)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+
+			if test.expectedError != nil {
+				checkBodyHasErrorCodes(t, "", resp, *test.expectedError)
+				return
+			}
+
+			var body []*handlers.ManifestSearchResultAPIResponse
+			err = json.NewDecoder(resp.Body).Decode(&body)
+			require.NoError(t, err)
+
+			actualDigests := make([]string, 0, len(body))
+			for _, m := range body {
+				actualDigests = append(actualDigests, m.Digest)
+
+				require.Equal(t, m1.MediaType, m.MediaType)
+				require.NotEmpty(t, m.CreatedAt)
+				switch m.Digest {
+				case d1:
+					require.Equal(t, "foo/bar", m.Repository)
+					require.Equal(t, []string{"latest"}, m.Tags)
+					require.Equal(t, m1.Annotations, m.Annotations)
+				case d2:
+					require.Equal(t, "foo/baz", m.Repository)
+					require.Empty(t, m.Tags)
+					require.Equal(t, m2.Annotations, m.Annotations)
+				}
+			}
+			require.Equal(t, test.expectedDigests, actualDigests)
+			require.Equal(t, test.expectedLinkHeader, resp.Header.Get("Link"))
+		})
+	}
+}
+
+func TestGitlabAPI_GroupManifestSearch_NonExistentGroup(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	groupName, err := reference.WithName("foo")
+	require.NoError(t, err)
+
+	u, err := env.builder.BuildGitlabV1GroupManifestSearchURL(groupName, url.Values{"annotation": []string{"org.example.stage"}})
+	require.NoError(t, err)
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeNameUnknown)
+}
+
+func TestGitlabAPI_RenameRepository_WithNoBaseRepository(t *testing.T) {
+	nestedRepos := []string{
+		"foo/bar/a",
+	}
+
+	baseRepoName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	// create an auth token provider
+	tokenProvider := NewAuthTokenProvider(t)
+
+	// generate one full access auth token for all tests
+	token := tokenProvider.TokenWithActions(fullAccessTokenWithProjectMeta(baseRepoName.Name(), baseRepoName.Name()))
+
+	tt := []struct {
+		name               string
+		queryParams        url.Values
+		requestBody        []byte
+		expectedRespStatus int
+		expectedRespError  *errcode.ErrorCode
+		expectedRespBody   *handlers.RenameRepositoryAPIResponse
+	}{
+		{
+			name:               "dry run param not set means implicit false",
+			requestBody:        []byte(`{ "name" : "not-bar" }`),
+			expectedRespStatus: http.StatusNoContent,
+			expectedRespBody:   nil,
 		},
 		{
 			name:               "dry run param is set explicitly to true",
@@ -1549,6 +2239,50 @@ func TestGitlabAPI_RenameRepository_WithBaseRepository(t *testing.T) {
 	}
 }
 
+func TestGitlabAPI_RenameRepository_MoveFilesystemMetadata(t *testing.T) {
+	baseRepoName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	subRepoName, err := reference.WithName("foo/bar/a")
+	require.NoError(t, err)
+
+	tokenProvider := NewAuthTokenProvider(t)
+	token := tokenProvider.TokenWithActions(fullAccessTokenWithProjectMeta(baseRepoName.Name(), baseRepoName.Name()))
+
+	// apply base app config/setup (without authorization) to allow seeding repository with test data
+	env := newTestEnv(t)
+	env.requireDB(t)
+	t.Cleanup(env.Shutdown)
+
+	seedMultipleRepositoriesWithTaggedManifest(t, env, "latest", []string{baseRepoName.Name(), subRepoName.Name()})
+
+	// start (but do not complete) blob uploads, whose state is kept in the filesystem metadata of each repository
+	startPushLayer(t, env, baseRepoName)
+	startPushLayer(t, env, subRepoName)
+
+	// override test config/setup to use token based authorization for all proceeding requests
+	srv := testutil.RedisServer(t)
+	env = newTestEnv(t, withRedisCache(srv.Addr()), withTokenAuth(tokenProvider.CertPath(), defaultIssuerProps()))
+
+	u, err := env.builder.BuildGitlabV1RepositoryURL(baseRepoName)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPatch, u, bytes.NewReader([]byte(`{"name": "not-bar", "move_filesystem_metadata": true}`)))
+	require.NoError(t, err)
+	req = tokenProvider.RequestWithAuthToken(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.RenameRepositoryResultAPIResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+	require.Equal(t, 2, body.RepositoriesCount)
+	// each upload has at least a data and a started at file
+	require.GreaterOrEqual(t, body.MovedFilesCount, 4)
+}
+
 func TestGitlabAPI_RenameRepository_WithoutRedis(t *testing.T) {
 	env := newTestEnv(t)
 	env.requireDB(t)
@@ -1931,3 +2665,2672 @@ func TestGitlabAPI_RenameRepository_InvalidTokenProjectPathMeta(t *testing.T) {
 		})
 	}
 }
+
+func TestGitlabAPI_GCAgents_Get(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	u, err := env.builder.BuildGitlabV1GCAgentsURL()
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// online GC agents are disabled for API tests, see newTestEnvWithConfig
+	var body handlers.GCAgentsAPIResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+	require.Empty(t, body.Agents)
+}
+
+func TestGitlabAPI_GCAgents_DatabaseDisabled(t *testing.T) {
+	env := newTestEnv(t, withDBDisabled)
+	t.Cleanup(env.Shutdown)
+
+	u, err := env.builder.BuildGitlabV1GCAgentsURL()
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeNotImplemented)
+}
+
+func scheduleGCReview(t *testing.T, env *testEnv, body string) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1GCReviewsURL()
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_GCReviews(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
+
+	review := func(body string) handlers.GCReviewAPIResponse {
+		t.Helper()
+
+		resp := scheduleGCReview(t, env, body)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var r handlers.GCReviewAPIResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+		return r
+	}
+
+	r := review(fmt.Sprintf(`{"digest": %q}`, m.Config().Digest))
+	require.EqualValues(t, 1, r.BlobTasks)
+	require.Zero(t, r.ManifestTasks)
+
+	// the config and layer blobs are linked to the repository
+	r = review(fmt.Sprintf(`{"repository": %q}`, repoPath))
+	require.GreaterOrEqual(t, r.BlobTasks, int64(len(m.Layers())+1))
+	require.EqualValues(t, 1, r.ManifestTasks)
+}
+
+func TestGitlabAPI_GCReviews_Errors(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	tcs := map[string]struct {
+		body           string
+		expectedStatus int
+		expectedError  errcode.ErrorCode
+	}{
+		"invalid json":       {`foo`, http.StatusBadRequest, v1.ErrorCodeInvalidJSONBody},
+		"empty":              {`{}`, http.StatusBadRequest, v1.ErrorCodeInvalidBodyParamType},
+		"both set":           {`{"digest": "sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9", "repository": "foo/bar"}`, http.StatusBadRequest, v1.ErrorCodeInvalidBodyParamType},
+		"invalid digest":     {`{"digest": "foo"}`, http.StatusBadRequest, v1.ErrorCodeInvalidBodyParamType},
+		"unknown blob":       {`{"digest": "sha256:c9b1b535fdd91a9855fb7f82348177e5f019329a58c53c47272962dd60f71fc9"}`, http.StatusNotFound, v2.ErrorCodeBlobUnknown},
+		"unknown repository": {`{"repository": "foo/bar"}`, http.StatusNotFound, v2.ErrorCodeNameUnknown},
+	}
+
+	for tn, tc := range tcs {
+		t.Run(tn, func(t *testing.T) {
+			resp := scheduleGCReview(t, env, tc.body)
+			defer resp.Body.Close()
+			require.Equal(t, tc.expectedStatus, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, tc.expectedError)
+		})
+	}
+}
+
+func TestGitlabAPI_GCReviews_DatabaseDisabled(t *testing.T) {
+	env := newTestEnv(t, withDBDisabled)
+	t.Cleanup(env.Shutdown)
+
+	resp := scheduleGCReview(t, env, `{"repository": "foo/bar"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeNotImplemented)
+}
+
+func TestGitlabAPI_SizeRecalculation(t *testing.T) {
+	srv := testutil.RedisServer(t)
+	env := newTestEnv(t, withRedisCache(srv.Addr()), func(config *configuration.Configuration) {
+		config.Database.SizeRecalculation.Enabled = true
+		// make sure that only triggered runs happen during the test
+		config.Database.SizeRecalculation.Interval = time.Hour
+		config.Database.SizeRecalculation.BatchSize = 100
+	})
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	seedRandomSchema2Manifest(t, env, repoRef.Name(), putByTag("latest"))
+
+	u, err := env.builder.BuildGitlabV1SizeRecalculationURL()
+	require.NoError(t, err)
+
+	get := func(t *testing.T) handlers.SizeRecalculationAPIResponse {
+		t.Helper()
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body handlers.SizeRecalculationAPIResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body
+	}
+
+	require.Empty(t, get(t).LastRunAt)
+
+	resp, err := http.Post(u, "application/json", strings.NewReader(`{"reset": true}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// the run happens asynchronously and completes a pass, given the batch size
+	require.Eventually(t, func() bool { return get(t).LastRunAt != "" }, 5*time.Second, 50*time.Millisecond)
+	require.Zero(t, get(t).Checkpoint)
+}
+
+func TestGitlabAPI_SizeRecalculation_Disabled(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	u, err := env.builder.BuildGitlabV1SizeRecalculationURL()
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeNotImplemented)
+}
+
+func TestGitlabAPI_BlobDeduplication(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	// push the same layer to two repositories
+	layer := make([]byte, 1024)
+	_, err := rand.Read(layer)
+	require.NoError(t, err)
+	dgst := digest.FromBytes(layer)
+
+	for _, path := range []string{"foo/bar", "foo/baz"} {
+		repoRef, err := reference.WithName(path)
+		require.NoError(t, err)
+		uploadURLBase, _ := startPushLayer(t, env, repoRef)
+		pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, bytes.NewReader(layer))
+	}
+
+	u, err := env.builder.BuildGitlabV1BlobDeduplicationURL(url.Values{"n": []string{"1000"}, "interval": []string{"day"}})
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var body handlers.DeduplicationAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	require.GreaterOrEqual(t, body.LinkedBlobs, body.UniqueBlobs+1)
+	require.GreaterOrEqual(t, body.SavedBytes, int64(len(layer)))
+	require.Equal(t, body.LinkedBytes-body.UniqueBytes, body.SavedBytes)
+	require.Greater(t, body.Ratio, 1.0)
+
+	require.Contains(t, body.TopSharedBlobs, handlers.SharedBlobAPIResponse{
+		Digest:       dgst.String(),
+		MediaType:    "application/octet-stream",
+		Size:         int64(len(layer)),
+		Repositories: 2,
+		SavedBytes:   int64(len(layer)),
+	})
+
+	// the last period includes the layer that was just pushed, and stats are cumulative
+	require.NotEmpty(t, body.History)
+	require.Equal(t, body.DeduplicationStatsAPIResponse, body.History[len(body.History)-1].DeduplicationStatsAPIResponse)
+}
+
+func TestGitlabAPI_BlobVerification(t *testing.T) {
+	root := t.TempDir()
+	env := newTestEnv(t, withFSDriver(root), func(config *configuration.Configuration) {
+		config.Database.BlobVerification.Enabled = true
+		config.Database.BlobVerification.Interval = 50 * time.Millisecond
+		// large enough to sample all blobs in each run
+		config.Database.BlobVerification.SampleSize = 100000
+	})
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	layer := make([]byte, 1024)
+	_, err := rand.Read(layer)
+	require.NoError(t, err)
+	dgst := digest.FromBytes(layer)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	uploadURLBase, _ := startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, bytes.NewReader(layer))
+
+	// corrupt the layer content in storage
+	corrupted := append([]byte("corrupted"), layer...)
+	path := filepath.Join(root, fmt.Sprintf("/docker/registry/v2/blobs/sha256/%s/%s/data", dgst.Encoded()[0:2], dgst.Encoded()))
+	require.NoError(t, os.WriteFile(path, corrupted, 0o600))
+
+	u, err := env.builder.BuildGitlabV1BlobVerificationURL(url.Values{"n": []string{"1000"}})
+	require.NoError(t, err)
+
+	find := func(t *testing.T) *handlers.CorruptedBlobAPIResponse {
+		t.Helper()
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var body handlers.BlobVerificationAPIResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		for _, b := range body.CorruptedBlobs {
+			if b.Digest == dgst.String() {
+				require.NotEmpty(t, body.LastRunAt)
+				require.GreaterOrEqual(t, body.Total, 1)
+				return &b
+			}
+		}
+		return nil
+	}
+
+	var b *handlers.CorruptedBlobAPIResponse
+	require.Eventually(t, func() bool {
+		b = find(t)
+		return b != nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.Equal(t, digest.FromBytes(corrupted).String(), b.ActualDigest)
+	require.Equal(t, int64(len(layer)), b.Size)
+	require.Equal(t, "digest_mismatch", b.Reason)
+	require.Regexp(t, iso8601MsFormat, b.DetectedAt)
+	require.Regexp(t, iso8601MsFormat, b.CheckedAt)
+
+	// once the content is restored, the blob is no longer reported
+	require.NoError(t, os.WriteFile(path, layer, 0o600))
+	require.Eventually(t, func() bool { return find(t) == nil }, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestGitlabAPI_BlobVerification_Disabled(t *testing.T) {
+	env := newTestEnv(t, withDBDisabled)
+	t.Cleanup(env.Shutdown)
+
+	u, err := env.builder.BuildGitlabV1BlobVerificationURL()
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeNotImplemented)
+}
+
+func TestGitlabAPI_BlobDeduplication_InvalidQueryParams(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	tests := map[string]struct {
+		values       url.Values
+		expectedCode errcode.ErrorCode
+	}{
+		"n not an integer":  {url.Values{"n": []string{"a"}}, v1.ErrorCodeInvalidQueryParamType},
+		"n out of range":    {url.Values{"n": []string{"1001"}}, v1.ErrorCodeInvalidQueryParamValue},
+		"unknown interval":  {url.Values{"interval": []string{"year"}}, v1.ErrorCodeInvalidQueryParamValue},
+		"periods too small": {url.Values{"periods": []string{"0"}}, v1.ErrorCodeInvalidQueryParamValue},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, err := env.builder.BuildGitlabV1BlobDeduplicationURL(test.values)
+			require.NoError(t, err)
+
+			resp, err := http.Get(u)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedCode)
+		})
+	}
+}
+
+func bulkDeleteTags(t *testing.T, env *testEnv, repoRef reference.Named, body string, opts ...requestOpt) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryTagsBulkDeleteURL(repoRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodDelete, u, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(newRequest(req, opts...))
+	require.NoError(t, err)
+
+	return resp
+}
+
+func remainingTags(t *testing.T, env *testEnv, repoRef reference.Named) []string {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryTagsURL(repoRef)
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var list []handlers.RepositoryTagResponse
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(list))
+	for _, tag := range list {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func TestGitlabAPI_RepositoryTagsBulkDelete_ByNames(t *testing.T) {
+	env := newTestEnv(t, withDelete, withTestWebhookNotifications(t))
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepositoryWithMultipleIdenticalTags(t, env, repoRef.Name(), []string{"ci-1", "ci-2", "latest"})
+	seedRandomSchema2Manifest(t, env, repoRef.Name(), putByTag("ci-3"))
+
+	resp := bulkDeleteTags(t, env, repoRef, `{"names": ["ci-3", "ci-1", "unknown"]}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.BulkDeleteTagsAPIResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ci-1", "ci-3"}, body.Tags)
+
+	require.Equal(t, []string{"ci-2", "latest"}, remainingTags(t, env, repoRef))
+
+	env.ns.AssertEventNotification(t, buildEventTagsDelete(repoRef.Name(), []string{"ci-1", "ci-3"}))
+}
+
+func TestGitlabAPI_RepositoryTagsBulkDelete_ByNameRegex(t *testing.T) {
+	env := newTestEnv(t, withDelete, withTestWebhookNotifications(t))
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepositoryWithMultipleIdenticalTags(t, env, repoRef.Name(), []string{"ci-1", "ci-2", "latest", "v1-ci"})
+
+	resp := bulkDeleteTags(t, env, repoRef, `{"name_regex": "^ci-[0-9]+$"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.BulkDeleteTagsAPIResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ci-1", "ci-2"}, body.Tags)
+
+	require.Equal(t, []string{"latest", "v1-ci"}, remainingTags(t, env, repoRef))
+
+	env.ns.AssertEventNotification(t, buildEventTagsDelete(repoRef.Name(), []string{"ci-1", "ci-2"}))
+}
+
+func TestGitlabAPI_RepositoryTagsBulkDelete_NoMatches(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	resp := bulkDeleteTags(t, env, repoRef, `{"name_regex": "^ci-"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.BulkDeleteTagsAPIResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+	require.Empty(t, body.Tags)
+
+	require.Equal(t, []string{"latest"}, remainingTags(t, env, repoRef))
+}
+
+func TestGitlabAPI_RepositoryTagsBulkDelete_InvalidRequest(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	tooManyNames := make([]string, 1001)
+	for i := range tooManyNames {
+		tooManyNames[i] = fmt.Sprintf("%q", fmt.Sprintf("tag-%d", i))
+	}
+
+	tt := []struct {
+		name          string
+		body          string
+		expectedError errcode.ErrorCode
+	}{
+		{
+			name:          "bad json body",
+			body:          `"names": ["latest"]`,
+			expectedError: v1.ErrorCodeInvalidJSONBody,
+		},
+		{
+			name:          "empty body",
+			body:          `{}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "names and name_regex",
+			body:          `{"names": ["latest"], "name_regex": "^ci-"}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "empty names",
+			body:          `{"names": []}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "too many names",
+			body:          fmt.Sprintf(`{"names": [%s]}`, strings.Join(tooManyNames, ",")),
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "invalid name",
+			body:          `{"names": ["-latest"]}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "invalid name regex",
+			body:          `{"name_regex": "^ci-("}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "name regex too long",
+			body:          fmt.Sprintf(`{"name_regex": "%s"}`, strings.Repeat("a", 257)),
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp := bulkDeleteTags(t, env, repoRef, test.body)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedError)
+		})
+	}
+
+	require.Equal(t, []string{"latest"}, remainingTags(t, env, repoRef))
+}
+
+func TestGitlabAPI_RepositoryTagsBulkDelete_RepositoryNotFound(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	resp := bulkDeleteTags(t, env, repoRef, `{"names": ["latest"]}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeNameUnknown)
+}
+
+func TestGitlabAPI_RepositoryTagsBulkDelete_ProtectedTags(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepositoryWithMultipleIdenticalTags(t, env, repoRef.Name(), []string{"ci-1", "ci-2", "v1"})
+
+	tokenProvider := NewAuthTokenProvider(t)
+	authEnv := newTestEnv(t, withDelete, withTokenAuth(tokenProvider.CertPath(), defaultIssuerProps()))
+	t.Cleanup(authEnv.Shutdown)
+	authOpt := witAuthToken(tokenProvider.TokenWithActions(protectedTagsAccessToken(repoRef.Name(), "^v[0-9]")))
+
+	// no tag is deleted if any of them is protected
+	resp := bulkDeleteTags(t, authEnv, repoRef, `{"name_regex": ".*"}`, authOpt)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeTagProtected)
+	require.Equal(t, []string{"ci-1", "ci-2", "v1"}, remainingTags(t, env, repoRef))
+
+	resp = bulkDeleteTags(t, authEnv, repoRef, `{"names": ["ci-1", "ci-2"]}`, authOpt)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"v1"}, remainingTags(t, env, repoRef))
+}
+
+func TestGitlabAPI_RepositoryTagsBulkDelete_DeleteDisabled(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	resp := bulkDeleteTags(t, env, repoRef, `{"names": ["latest"]}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, errcode.ErrorCodeUnsupported)
+
+	require.Equal(t, []string{"latest"}, remainingTags(t, env, repoRef))
+}
+
+func cleanupPolicyRequest(t *testing.T, env *testEnv, method string, repoRef reference.Named, body string, opts ...requestOpt) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryCleanupPolicyURL(repoRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(newRequest(req, opts...))
+	require.NoError(t, err)
+
+	return resp
+}
+
+func previewCleanupPolicy(t *testing.T, env *testEnv, repoRef reference.Named, body string) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryCleanupPolicyPreviewURL(repoRef)
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_RepositoryCleanupPolicy(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	resp := cleanupPolicyRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeCleanupPolicyUnknown)
+
+	resp = cleanupPolicyRequest(t, env, http.MethodPut, repoRef, `{"cadence": "7d", "name_regex_delete": "ci-.+", "keep_n": 2, "older_than": "36h"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.CleanupPolicyAPIResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+	require.True(t, body.Enabled)
+	require.Equal(t, "7d", body.Cadence)
+	require.Equal(t, "ci-.+", body.NameRegexDelete)
+	require.Equal(t, 2, body.KeepN)
+	require.Equal(t, "36h0m0s", body.OlderThan)
+	require.NotEmpty(t, body.NextRunAt)
+	require.NotEmpty(t, body.CreatedAt)
+	require.Empty(t, body.LastRunAt)
+
+	resp = cleanupPolicyRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var got handlers.CleanupPolicyAPIResponse
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	require.NoError(t, err)
+	require.Equal(t, body.Cadence, got.Cadence)
+	require.Equal(t, body.NextRunAt, got.NextRunAt)
+
+	resp = cleanupPolicyRequest(t, env, http.MethodDelete, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = cleanupPolicyRequest(t, env, http.MethodDelete, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeCleanupPolicyUnknown)
+}
+
+func TestGitlabAPI_RepositoryCleanupPolicy_InvalidRequest(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	tt := []struct {
+		name          string
+		body          string
+		expectedError errcode.ErrorCode
+	}{
+		{
+			name:          "bad json body",
+			body:          `"cadence": "1d"`,
+			expectedError: v1.ErrorCodeInvalidJSONBody,
+		},
+		{
+			name:          "missing cadence",
+			body:          `{"name_regex_delete": ".*"}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "cadence too short",
+			body:          `{"cadence": "30m", "name_regex_delete": ".*"}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "missing delete regex",
+			body:          `{"cadence": "1d"}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "invalid keep regex",
+			body:          `{"cadence": "1d", "name_regex_delete": ".*", "name_regex_keep": "("}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "negative keep_n",
+			body:          `{"cadence": "1d", "name_regex_delete": ".*", "keep_n": -1}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "invalid older_than",
+			body:          `{"cadence": "1d", "name_regex_delete": ".*", "older_than": "foo"}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp := cleanupPolicyRequest(t, env, http.MethodPut, repoRef, test.body)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedError)
+		})
+	}
+}
+
+func TestGitlabAPI_RepositoryCleanupPolicy_RepositoryNotFound(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	resp := cleanupPolicyRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeNameUnknown)
+}
+
+func TestGitlabAPI_RepositoryCleanupPolicy_ProtectedTags(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	tokenProvider := NewAuthTokenProvider(t)
+	authEnv := newTestEnv(t, withDelete, withTokenAuth(tokenProvider.CertPath(), defaultIssuerProps()))
+	t.Cleanup(authEnv.Shutdown)
+
+	// policies run without the auth token, so they can't be set while any tags are protected
+	body := `{"cadence": "7d", "name_regex_delete": "ci-.+"}`
+	authOpt := witAuthToken(tokenProvider.TokenWithActions(protectedTagsAccessToken(repoRef.Name(), "^v[0-9]")))
+	resp := cleanupPolicyRequest(t, authEnv, http.MethodPut, repoRef, body, authOpt)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeTagProtected)
+
+	resp = cleanupPolicyRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	authOpt = witAuthToken(tokenProvider.TokenWithActions(protectedTagsAccessToken(repoRef.Name())))
+	resp = cleanupPolicyRequest(t, authEnv, http.MethodPut, repoRef, body, authOpt)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func repositoryPurgeRequest(t *testing.T, env *testEnv, method string, repoRef reference.Named, opts ...requestOpt) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryPurgeURL(repoRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, u, nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(newRequest(req, opts...))
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_RepositoryPurge(t *testing.T) {
+	env := newTestEnv(t, withDelete, withRepositoryPurges)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "a")
+	createRepository(t, env, repoRef.Name(), "b")
+
+	resp := repositoryPurgeRequest(t, env, http.MethodGet, repoRef)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeRepositoryPurgeUnknown)
+
+	resp = repositoryPurgeRequest(t, env, http.MethodPost, repoRef)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var body handlers.RepositoryPurgeAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.NotEmpty(t, body.CreatedAt)
+
+	// poll until completed
+	require.Eventually(t, func() bool {
+		resp := repositoryPurgeRequest(t, env, http.MethodGet, repoRef)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body.Status == "completed"
+	}, 10*time.Second, 50*time.Millisecond)
+
+	require.EqualValues(t, 2, body.TagsDeleted)
+	require.EqualValues(t, 2, body.ManifestsDeleted)
+	require.Positive(t, body.BlobsUnlinked)
+	require.Empty(t, body.LastError)
+	require.NotEmpty(t, body.CompletedAt)
+	require.Empty(t, remainingTags(t, env, repoRef))
+
+	// pushes are allowed again once completed
+	createRepository(t, env, repoRef.Name(), "c")
+	require.Equal(t, []string{"c"}, remainingTags(t, env, repoRef))
+}
+
+func TestGitlabAPI_RepositoryPurge_InProgress(t *testing.T) {
+	// the worker interval is long enough for the purge to remain in progress
+	env := newTestEnv(t, withDelete, func(config *configuration.Configuration) {
+		config.Database.RepositoryPurges = configuration.DatabaseRepositoryPurges{Enabled: true, Interval: time.Hour}
+	})
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	resp := repositoryPurgeRequest(t, env, http.MethodPost, repoRef)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// starting again returns the purge in progress
+	resp = repositoryPurgeRequest(t, env, http.MethodPost, repoRef)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var body handlers.RepositoryPurgeAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "in_progress", body.Status)
+
+	// pushes are rejected while in progress
+	deserializedManifest := seedRandomSchema2Manifest(t, env, "foo/baz")
+	resp = putManifest(t, "putting manifest", buildManifestTagURL(t, env, repoRef.Name(), "new"), schema2.MediaTypeManifest, deserializedManifest.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "manifest put to repository being purged", resp, v2.ErrorCodeRepositoryReadOnly)
+}
+
+func TestGitlabAPI_RepositoryPurge_Disabled(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	resp := repositoryPurgeRequest(t, env, http.MethodPost, repoRef)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, errcode.ErrorCodeUnsupported)
+
+	require.Equal(t, []string{"latest"}, remainingTags(t, env, repoRef))
+}
+
+func TestGitlabAPI_RepositoryPurge_RepositoryNotFound(t *testing.T) {
+	env := newTestEnv(t, withDelete, withRepositoryPurges)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	resp := repositoryPurgeRequest(t, env, http.MethodPost, repoRef)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeNameUnknown)
+}
+
+func TestGitlabAPI_RepositoryPurge_ProtectedTags(t *testing.T) {
+	env := newTestEnv(t, withDelete, withRepositoryPurges)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "v1")
+
+	tokenProvider := NewAuthTokenProvider(t)
+	authEnv := newTestEnv(t, withDelete, withRepositoryPurges, withTokenAuth(tokenProvider.CertPath(), defaultIssuerProps()))
+	t.Cleanup(authEnv.Shutdown)
+	authOpt := witAuthToken(tokenProvider.TokenWithActions(protectedTagsAccessToken(repoRef.Name(), "^v[0-9]")))
+
+	// purges delete all tags without the auth token, so they can't be started while any tags are protected
+	resp := repositoryPurgeRequest(t, authEnv, http.MethodPost, repoRef, authOpt)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeTagProtected)
+
+	resp = repositoryPurgeRequest(t, env, http.MethodGet, repoRef)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeRepositoryPurgeUnknown)
+	require.Equal(t, []string{"v1"}, remainingTags(t, env, repoRef))
+}
+
+func repositoryUploadsRequest(t *testing.T, env *testEnv, method string, repoRef reference.Named, uuid string) *http.Response {
+	t.Helper()
+
+	var u string
+	var err error
+	if uuid == "" {
+		u, err = env.builder.BuildGitlabV1RepositoryUploadsURL(repoRef)
+	} else {
+		u, err = env.builder.BuildGitlabV1RepositoryUploadURL(repoRef, uuid)
+	}
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, u, nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_RepositoryUploads(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	// no uploads
+	resp := repositoryUploadsRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body []handlers.RepositoryUploadAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Empty(t, body)
+
+	// one empty and one partial upload
+	_, emptyUUID := startPushLayer(t, env, repoRef)
+	location, partialUUID := startPushLayer(t, env, repoRef)
+	chunk := []byte("hello world")
+	pushChunk(t, env.builder, repoRef, location, bytes.NewReader(chunk), int64(len(chunk)))
+
+	resp = repositoryUploadsRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body, 2)
+
+	require.Equal(t, emptyUUID, body[0].UUID)
+	require.NotEmpty(t, body[0].StartedAt)
+	require.Zero(t, body[0].Size)
+	require.Empty(t, body[0].LastChunkAt)
+	require.Nil(t, body[0].SecondsSinceLastChunk)
+
+	require.Equal(t, partialUUID, body[1].UUID)
+	require.EqualValues(t, len(chunk), body[1].Size)
+	require.NotEmpty(t, body[1].LastChunkAt)
+	require.NotNil(t, body[1].SecondsSinceLastChunk)
+
+	// cancel the partial upload
+	resp = repositoryUploadsRequest(t, env, http.MethodDelete, repoRef, partialUUID)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = repositoryUploadsRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body, 1)
+	require.Equal(t, emptyUUID, body[0].UUID)
+
+	// the client can no longer resume it
+	resp, _, err = doPushChunk(t, location, bytes.NewReader(chunk))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeBlobUploadUnknown)
+}
+
+func TestGitlabAPI_RepositoryUploads_CancelUnknown(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	resp := repositoryUploadsRequest(t, env, http.MethodDelete, repoRef, "48aa4cb5-9d4c-4e0c-a5ac-8f0b1b1e2f5a")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeBlobUploadUnknown)
+}
+
+func groupStatistics(t *testing.T, env *testEnv, groupRef reference.Named, queryParams url.Values) (*http.Response, handlers.NamespaceStatisticsAPIResponse) {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1GroupStatisticsURL(groupRef, queryParams)
+	require.NoError(t, err)
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+
+	var body handlers.NamespaceStatisticsAPIResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	}
+	return resp, body
+}
+
+func TestGitlabAPI_GroupStatistics(t *testing.T) {
+	env := newTestEnv(t, withNamespaceStatistics)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	groupRef, err := reference.WithName("foo")
+	require.NoError(t, err)
+
+	createRepository(t, env, "foo/bar", "latest")
+	// activity in other namespaces is not accounted for
+	createRepository(t, env, "other/bar", "latest")
+
+	req, err := http.NewRequest(http.MethodGet, buildManifestTagURL(t, env, "foo/bar", "latest"), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", schema2.MediaTypeManifest)
+	pullResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	pullResp.Body.Close()
+	require.Equal(t, http.StatusOK, pullResp.StatusCode)
+
+	today := time.Now().UTC().Format("2006-01-02")
+
+	// counters are written asynchronously
+	var body handlers.NamespaceStatisticsAPIResponse
+	require.Eventually(t, func() bool {
+		var resp *http.Response
+		resp, body = groupStatistics(t, env, groupRef, nil)
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK && body.Totals.Pulls == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.Equal(t, "foo", body.Namespace)
+	require.Equal(t, today, body.To)
+	// the last 30 days are returned by default, including those without activity
+	require.Len(t, body.Days, 30)
+	require.Equal(t, body.From, body.Days[0].Date)
+	require.Equal(t, today, body.Days[29].Date)
+	require.EqualValues(t, 1, body.Totals.Pushes)
+	require.Positive(t, body.Totals.BytesAdded)
+	require.Zero(t, body.Totals.BytesReclaimed)
+	require.Equal(t, body.Totals, body.Days[29].NamespaceStatisticsCounters)
+	require.Zero(t, body.Days[0].NamespaceStatisticsCounters)
+
+	// custom range
+	resp, body := groupStatistics(t, env, groupRef, url.Values{"from": []string{"2023-12-01"}, "to": []string{"2023-12-03"}})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "2023-12-01", body.From)
+	require.Equal(t, "2023-12-03", body.To)
+	require.Len(t, body.Days, 3)
+	require.Zero(t, body.Totals)
+}
+
+func TestGitlabAPI_GroupStatistics_Errors(t *testing.T) {
+	env := newTestEnv(t, withNamespaceStatistics)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	createRepository(t, env, "foo/bar", "latest")
+
+	tt := []struct {
+		name           string
+		group          string
+		queryParams    url.Values
+		expectedStatus int
+		expectedError  errcode.ErrorCode
+	}{
+		{
+			name:           "unknown namespace",
+			group:          "unknown",
+			expectedStatus: http.StatusNotFound,
+			expectedError:  v2.ErrorCodeNameUnknown,
+		},
+		{
+			name:           "subgroup",
+			group:          "foo/bar",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  v2.ErrorCodeNameInvalid,
+		},
+		{
+			name:           "invalid date",
+			group:          "foo",
+			queryParams:    url.Values{"from": []string{"01/12/2023"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:           "from after to",
+			group:          "foo",
+			queryParams:    url.Values{"from": []string{"2023-12-02"}, "to": []string{"2023-12-01"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:           "range too long",
+			group:          "foo",
+			queryParams:    url.Values{"from": []string{"2022-01-01"}, "to": []string{"2023-12-31"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  v1.ErrorCodeInvalidQueryParamValue,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			groupRef, err := reference.WithName(test.group)
+			require.NoError(t, err)
+
+			resp, _ := groupStatistics(t, env, groupRef, test.queryParams)
+			defer resp.Body.Close()
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedError)
+		})
+	}
+}
+
+func TestGitlabAPI_GroupStatistics_Disabled(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	groupRef, err := reference.WithName("foo")
+	require.NoError(t, err)
+
+	resp, _ := groupStatistics(t, env, groupRef, nil)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, errcode.ErrorCodeUnsupported)
+}
+
+func repositorySettingsRequest(t *testing.T, env *testEnv, method string, repoRef reference.Named, body string) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositorySettingsURL(repoRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_RepositorySettings(t *testing.T) {
+	env := newTestEnv(t, withPayloadSizeLimits(configuration.ManifestPayloadSizeLimitRule{Repository: "foo/*", Limit: 1 << 20}))
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	getSettings := func() handlers.RepositorySettingsAPIResponse {
+		t.Helper()
+
+		resp := repositorySettingsRequest(t, env, http.MethodGet, repoRef, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body handlers.RepositorySettingsAPIResponse
+		err := json.NewDecoder(resp.Body).Decode(&body)
+		require.NoError(t, err)
+
+		return body
+	}
+
+	// without an override, the limit from the configuration applies
+	body := getSettings()
+	require.Nil(t, body.ManifestPayloadSizeLimit)
+	require.Equal(t, 1<<20, body.EffectiveManifestPayloadSizeLimit)
+
+	resp := repositorySettingsRequest(t, env, http.MethodPut, repoRef, `{"manifest_payload_size_limit": 5}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	body = getSettings()
+	require.NotNil(t, body.ManifestPayloadSizeLimit)
+	require.EqualValues(t, 5, *body.ManifestPayloadSizeLimit)
+	require.Equal(t, 5, body.EffectiveManifestPayloadSizeLimit)
+
+	// the override is enforced on subsequent pushes
+	deserializedManifest := seedRandomSchema2Manifest(t, env, repoRef.Name())
+	resp = putManifest(t, "putting oversized manifest", buildManifestTagURL(t, env, repoRef.Name(), "new"), schema2.MediaTypeManifest, deserializedManifest.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "manifest put exceeds payload size limit", resp, v2.ErrorCodeManifestPayloadSizeLimit)
+
+	// removing the override falls back to the configuration
+	resp = repositorySettingsRequest(t, env, http.MethodPut, repoRef, `{"manifest_payload_size_limit": null}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	body = getSettings()
+	require.Nil(t, body.ManifestPayloadSizeLimit)
+	require.Equal(t, 1<<20, body.EffectiveManifestPayloadSizeLimit)
+
+	seedRandomSchema2Manifest(t, env, repoRef.Name(), putByTag("new"))
+}
+
+func TestGitlabAPI_RepositorySettings_ReadOnly(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+	manifestURL := buildManifestTagURL(t, env, repoRef.Name(), "latest")
+
+	resp := repositorySettingsRequest(t, env, http.MethodPut, repoRef, `{"read_only": true}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = repositorySettingsRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.RepositorySettingsAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.True(t, body.ReadOnly)
+
+	// pulls succeed
+	resp, err = http.Get(manifestURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// pushes and deletes are rejected
+	deserializedManifest := seedRandomSchema2Manifest(t, env, "foo/baz")
+	resp = putManifest(t, "putting manifest", buildManifestTagURL(t, env, repoRef.Name(), "new"), schema2.MediaTypeManifest, deserializedManifest.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "manifest put to read-only repository", resp, v2.ErrorCodeRepositoryReadOnly)
+
+	resp, err = http.DefaultClient.Do(startPushLayerRequest(t, env, repoRef))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "blob upload to read-only repository", resp, v2.ErrorCodeRepositoryReadOnly)
+
+	resp, err = httpDelete(manifestURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "manifest delete from read-only repository", resp, v2.ErrorCodeRepositoryReadOnly)
+
+	// making the repository writable again allows pushes
+	resp = repositorySettingsRequest(t, env, http.MethodPut, repoRef, `{"read_only": false}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	seedRandomSchema2Manifest(t, env, repoRef.Name(), putByTag("new"))
+}
+
+func TestGitlabAPI_RepositorySettings_InvalidRequest(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	tt := []struct {
+		name          string
+		body          string
+		expectedError errcode.ErrorCode
+	}{
+		{
+			name:          "bad json body",
+			body:          `"manifest_payload_size_limit": 5`,
+			expectedError: v1.ErrorCodeInvalidJSONBody,
+		},
+		{
+			name:          "zero limit",
+			body:          `{"manifest_payload_size_limit": 0}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "negative limit",
+			body:          `{"manifest_payload_size_limit": -1}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "limit too large",
+			body:          `{"manifest_payload_size_limit": 2147483648}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp := repositorySettingsRequest(t, env, http.MethodPut, repoRef, test.body)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedError)
+		})
+	}
+}
+
+func TestGitlabAPI_RepositorySettings_RepositoryNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		resp := repositorySettingsRequest(t, env, method, repoRef, `{"manifest_payload_size_limit": 5}`)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeNameUnknown)
+	}
+}
+
+func repositoryMetadataRequest(t *testing.T, env *testEnv, method string, repoRef reference.Named, body string) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryMetadataURL(repoRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_RepositoryMetadata(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	getRepository := func() handlers.RepositoryAPIResponse {
+		t.Helper()
+
+		u, err := env.builder.BuildGitlabV1RepositoryURL(repoRef)
+		require.NoError(t, err)
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body handlers.RepositoryAPIResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		return body
+	}
+
+	// repositories have no metadata by default
+	resp := repositoryMetadataRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeRepositoryMetadataUnknown)
+	require.Nil(t, getRepository().Metadata)
+
+	// create
+	resp = repositoryMetadataRequest(t, env, http.MethodPut, repoRef, `{
+		"description": "An example image",
+		"readme": "# Example\n\nRun with *docker run foo/bar*.",
+		"links": [{"name": "Source", "url": "https://gitlab.com/foo/bar"}]
+	}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = repositoryMetadataRequest(t, env, http.MethodGet, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.RepositoryMetadataAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "An example image", body.Description)
+	require.Equal(t, "# Example\n\nRun with *docker run foo/bar*.", body.Readme)
+	require.Equal(t, []handlers.RepositoryLinkAPIResponse{{Name: "Source", URL: "https://gitlab.com/foo/bar"}}, body.Links)
+	require.Regexp(t, iso8601MsFormat, body.CreatedAt)
+	require.Empty(t, body.UpdatedAt)
+
+	// the metadata is returned alongside the repository details
+	require.Equal(t, &body, getRepository().Metadata)
+
+	// replace
+	resp = repositoryMetadataRequest(t, env, http.MethodPut, repoRef, `{"description": "Updated"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	md := getRepository().Metadata
+	require.NotNil(t, md)
+	require.Equal(t, "Updated", md.Description)
+	require.Empty(t, md.Readme)
+	require.Empty(t, md.Links)
+	require.Regexp(t, iso8601MsFormat, md.UpdatedAt)
+
+	// delete
+	resp = repositoryMetadataRequest(t, env, http.MethodDelete, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Nil(t, getRepository().Metadata)
+
+	resp = repositoryMetadataRequest(t, env, http.MethodDelete, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeRepositoryMetadataUnknown)
+}
+
+func TestGitlabAPI_RepositoryMetadata_InvalidRequest(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	tt := []struct {
+		name          string
+		body          string
+		expectedError errcode.ErrorCode
+	}{
+		{
+			name:          "bad json body",
+			body:          `"description": "foo"`,
+			expectedError: v1.ErrorCodeInvalidJSONBody,
+		},
+		{
+			name:          "description too long",
+			body:          fmt.Sprintf(`{"description": %q}`, strings.Repeat("a", 256)),
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "readme too long",
+			body:          fmt.Sprintf(`{"readme": %q}`, strings.Repeat("a", 100<<10+1)),
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "link without name",
+			body:          `{"links": [{"url": "https://example.com"}]}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "relative link url",
+			body:          `{"links": [{"name": "Docs", "url": "/docs"}]}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "link url with unsupported scheme",
+			body:          `{"links": [{"name": "Docs", "url": "javascript:alert(1)"}]}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "too many links",
+			body:          `{"links": [` + strings.TrimSuffix(strings.Repeat(`{"name": "a", "url": "https://example.com"},`, 11), ",") + `]}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp := repositoryMetadataRequest(t, env, http.MethodPut, repoRef, test.body)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedError)
+		})
+	}
+}
+
+func TestGitlabAPI_RepositoryMetadata_RepositoryNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		resp := repositoryMetadataRequest(t, env, method, repoRef, `{"description": "foo"}`)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeNameUnknown)
+	}
+}
+
+func repositoryLabelsRequest(t *testing.T, env *testEnv, method string, repoRef reference.Named, body string) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryLabelsURL(repoRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_RepositoryLabels(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	getLabels := func() map[string]string {
+		t.Helper()
+
+		resp := repositoryLabelsRequest(t, env, http.MethodGet, repoRef, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body handlers.RepositoryLabelsAPIResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		return body.Labels
+	}
+
+	// repositories have no labels by default
+	require.Empty(t, getLabels())
+
+	// set
+	resp := repositoryLabelsRequest(t, env, http.MethodPut, repoRef, `{"labels": {"team": "backend", "example.com/compliance": "pci"}}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, map[string]string{"team": "backend", "example.com/compliance": "pci"}, getLabels())
+
+	// replace
+	resp = repositoryLabelsRequest(t, env, http.MethodPut, repoRef, `{"labels": {"team": "frontend"}}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, map[string]string{"team": "frontend"}, getLabels())
+
+	// remove all
+	resp = repositoryLabelsRequest(t, env, http.MethodPut, repoRef, `{"labels": {}}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Empty(t, getLabels())
+}
+
+func TestGitlabAPI_RepositoryLabels_InvalidRequest(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	tt := []struct {
+		name          string
+		body          string
+		expectedError errcode.ErrorCode
+	}{
+		{
+			name:          "bad json body",
+			body:          `"labels": {}`,
+			expectedError: v1.ErrorCodeInvalidJSONBody,
+		},
+		{
+			name:          "non-string value",
+			body:          `{"labels": {"tier": 1}}`,
+			expectedError: v1.ErrorCodeInvalidJSONBody,
+		},
+		{
+			name:          "invalid key",
+			body:          `{"labels": {"Team": "backend"}}`,
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+		{
+			name:          "value too long",
+			body:          fmt.Sprintf(`{"labels": {"team": %q}}`, strings.Repeat("a", 256)),
+			expectedError: v1.ErrorCodeInvalidBodyParamType,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp := repositoryLabelsRequest(t, env, http.MethodPut, repoRef, test.body)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedError)
+		})
+	}
+}
+
+func TestGitlabAPI_RepositoryLabels_RepositoryNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		resp := repositoryLabelsRequest(t, env, method, repoRef, `{"labels": {"team": "backend"}}`)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeNameUnknown)
+	}
+}
+
+func TestGitlabAPI_RepositoryLabels_ListFilters(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	seedMultipleRepositoriesWithTaggedManifest(t, env, "latest", []string{"foo/bar", "foo/bar/a", "foo/bar/b", "foo/bar/c"})
+
+	for path, body := range map[string]string{
+		"foo/bar/a": `{"labels": {"team": "backend", "tier": "1"}}`,
+		"foo/bar/b": `{"labels": {"team": "frontend"}}`,
+		"foo/bar/c": `{"labels": {"team": "backend"}}`,
+	} {
+		repoRef, err := reference.WithName(path)
+		require.NoError(t, err)
+		resp := repositoryLabelsRequest(t, env, http.MethodPut, repoRef, body)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+
+	baseRepoName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	tt := []struct {
+		name          string
+		labels        []string
+		expectedPaths []string
+	}{
+		{
+			name:          "key and value",
+			labels:        []string{"team=backend"},
+			expectedPaths: []string{"foo/bar/a", "foo/bar/c"},
+		},
+		{
+			name:          "key only",
+			labels:        []string{"team"},
+			expectedPaths: []string{"foo/bar/a", "foo/bar/b", "foo/bar/c"},
+		},
+		{
+			name:          "multiple",
+			labels:        []string{"team=backend", "tier"},
+			expectedPaths: []string{"foo/bar/a"},
+		},
+		{
+			name:          "no match",
+			labels:        []string{"team=ops"},
+			expectedPaths: []string{},
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			q := url.Values{"label": test.labels, "count": []string{"true"}}
+
+			u, err := env.builder.BuildGitlabV1SubRepositoriesURL(baseRepoName, q)
+			require.NoError(t, err)
+			resp, err := http.Get(u)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, strconv.Itoa(len(test.expectedPaths)), resp.Header.Get("X-Total-Count"))
+
+			var subRepos []handlers.RepositoryAPIResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&subRepos))
+			paths := make([]string, 0, len(subRepos))
+			for _, r := range subRepos {
+				paths = append(paths, r.Path)
+			}
+			require.Equal(t, test.expectedPaths, paths)
+
+			u, err = env.builder.BuildGitlabV1GroupRepositoriesURL(baseRepoName, q)
+			require.NoError(t, err)
+			resp, err = http.Get(u)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, strconv.Itoa(len(test.expectedPaths)), resp.Header.Get("X-Total-Count"))
+
+			var groupRepos []handlers.GroupRepositoryAPIResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&groupRepos))
+			paths = make([]string, 0, len(groupRepos))
+			for _, r := range groupRepos {
+				paths = append(paths, r.Path)
+			}
+			require.Equal(t, test.expectedPaths, paths)
+
+			u, err = env.builder.BuildCatalogURL(q)
+			require.NoError(t, err)
+			resp, err = http.Get(u)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, strconv.Itoa(len(test.expectedPaths)), resp.Header.Get("X-Total-Count"))
+			require.Equal(t, "label", resp.Header.Get("OCI-Filters-Applied"))
+
+			var catalog struct {
+				Repositories []string `json:"repositories"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&catalog))
+			require.Equal(t, test.expectedPaths, catalog.Repositories)
+		})
+	}
+
+	// label filters are preserved in the Link header
+	u, err := env.builder.BuildGitlabV1SubRepositoriesURL(baseRepoName, url.Values{"label": []string{"team"}, "n": []string{"1"}})
+	require.NoError(t, err)
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	expectedLink := `</gitlab/v1/repository-paths/foo/bar/repositories/list/?label=team&last=foo%2Fbar%2Fa&n=1>; rel="next"`
+	require.Equal(t, expectedLink, resp.Header.Get("Link"))
+
+	// invalid label filters are rejected
+	u, err = env.builder.BuildGitlabV1SubRepositoriesURL(baseRepoName, url.Values{"label": []string{"Team"}})
+	require.NoError(t, err)
+	resp, err = http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeInvalidQueryParamValue)
+}
+
+func TestGitlabAPI_RepositoryCleanupPolicyPreview(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepositoryWithMultipleIdenticalTags(t, env, repoRef.Name(), []string{"ci-1", "ci-2", "latest", "v1"})
+
+	// no stored policy
+	resp := previewCleanupPolicy(t, env, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeCleanupPolicyUnknown)
+
+	// the latest tag is never deleted
+	resp = previewCleanupPolicy(t, env, repoRef, `{"name_regex_delete": ".*", "name_regex_keep": "v.+"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.CleanupPolicyPreviewAPIResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ci-1", "ci-2"}, body.Tags)
+
+	// the stored policy is used when no body is provided
+	resp = cleanupPolicyRequest(t, env, http.MethodPut, repoRef, `{"cadence": "1d", "name_regex_delete": "ci-1"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = previewCleanupPolicy(t, env, repoRef, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ci-1"}, body.Tags)
+
+	// nothing is deleted
+	require.Equal(t, []string{"ci-1", "ci-2", "latest", "v1"}, remainingTags(t, env, repoRef))
+}
+
+func TestGitlabAPI_Replication(t *testing.T) {
+	// the downstream registry uses filesystem metadata to keep its state apart from the upstream database
+	downstream := newTestEnv(t, withDBDisabled)
+	t.Cleanup(downstream.Shutdown)
+
+	env := newTestEnv(t, func(config *configuration.Configuration) {
+		config.Replication.Targets = []configuration.ReplicationTarget{{
+			Name:      "secondary",
+			URL:       downstream.server.URL,
+			Timeout:   time.Minute,
+			Threshold: 1,
+			Backoff:   time.Second,
+			Workers:   1,
+			QueueSize: 10,
+		}}
+	})
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	m := seedRandomSchema2Manifest(t, env, repoRef.Name(), putByTag("latest"))
+
+	u, err := env.builder.BuildGitlabV1ReplicationURL()
+	require.NoError(t, err)
+
+	var body handlers.ReplicationAPIResponse
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		require.NoError(t, err)
+		require.Len(t, body.Targets, 1)
+
+		return body.Targets[0].Replicated+body.Targets[0].Failed > 0
+	}, 10*time.Second, 100*time.Millisecond)
+
+	target := body.Targets[0]
+	require.Equal(t, "secondary", target.Name)
+	require.Equal(t, downstream.server.URL, target.URL)
+	require.EqualValues(t, 1, target.Replicated)
+	require.Zero(t, target.Failed)
+	require.NotEmpty(t, target.LastReplicatedAt)
+	require.Empty(t, target.LastError)
+
+	// the image can be pulled from the downstream registry by tag
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+
+	tagRef, err := reference.WithTag(repoRef, "latest")
+	require.NoError(t, err)
+	manifestURL, err := downstream.builder.BuildManifestURL(tagRef)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, manifestURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", schema2.MediaTypeManifest)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, digest.FromBytes(payload).String(), resp.Header.Get("Docker-Content-Digest"))
+}
+
+func TestGitlabAPI_Replication_NotEnabled(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	u, err := env.builder.BuildGitlabV1ReplicationURL()
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeNotImplemented)
+}
+
+func getTagHistory(t *testing.T, env *testEnv, repoRef reference.Named, tag string, values ...url.Values) *http.Response {
+	t.Helper()
+
+	tagged, err := reference.WithTag(repoRef, tag)
+	require.NoError(t, err)
+	u, err := env.builder.BuildGitlabV1RepositoryTagHistoryURL(tagged, values...)
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_RepositoryTagHistory(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	// create, retag twice (the second time to the same manifest, which is not a transition) and delete
+	d1 := createRepository(t, env, repoRef.Name(), "latest")
+	m := seedRandomSchema2Manifest(t, env, repoRef.Name(), putByTag("latest"))
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	d2 := digest.FromBytes(payload)
+	createRepository(t, env, repoRef.Name(), "stable")
+
+	u := buildManifestTagURL(t, env, repoRef.Name(), "latest")
+	resp := putManifest(t, "re-putting manifest", u, schema2.MediaTypeManifest, m)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = bulkDeleteTags(t, env, repoRef, `{"names": ["latest"]}`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = getTagHistory(t, env, repoRef, "latest")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Link"))
+
+	var body []handlers.TagHistoryEntryAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body, 3)
+
+	require.Equal(t, d2.String(), body[0].OldDigest)
+	require.Empty(t, body[0].NewDigest)
+	require.Equal(t, d1.String(), body[1].OldDigest)
+	require.Equal(t, d2.String(), body[1].NewDigest)
+	require.Empty(t, body[2].OldDigest)
+	require.Equal(t, d1.String(), body[2].NewDigest)
+	for _, e := range body {
+		require.NotEmpty(t, e.CreatedAt)
+	}
+}
+
+func TestGitlabAPI_RepositoryTagHistory_Pagination(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		createRepository(t, env, repoRef.Name(), "latest")
+	}
+
+	resp := getTagHistory(t, env, repoRef, "latest", url.Values{"n": []string{"2"}})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var page []handlers.TagHistoryEntryAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.Len(t, page, 2)
+
+	link := resp.Header.Get("Link")
+	require.Contains(t, link, `rel="next"`)
+	require.Contains(t, link, fmt.Sprintf("before=%d", page[1].ID))
+
+	resp2 := getTagHistory(t, env, repoRef, "latest", url.Values{
+		"n":      []string{"2"},
+		"before": []string{strconv.FormatInt(page[1].ID, 10)},
+	})
+	defer resp2.Body.Close()
+	require.Equal(t, http.StatusOK, resp2.StatusCode)
+	require.Empty(t, resp2.Header.Get("Link"))
+
+	var next []handlers.TagHistoryEntryAPIResponse
+	require.NoError(t, json.NewDecoder(resp2.Body).Decode(&next))
+	require.Len(t, next, 1)
+	require.Empty(t, next[0].OldDigest)
+}
+
+func TestGitlabAPI_RepositoryTagHistory_InvalidQueryParams(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	createRepository(t, env, repoRef.Name(), "latest")
+
+	tt := []struct {
+		name          string
+		values        url.Values
+		expectedError errcode.ErrorCode
+	}{
+		{
+			name:          "n not an integer",
+			values:        url.Values{"n": []string{"a"}},
+			expectedError: v1.ErrorCodeInvalidQueryParamType,
+		},
+		{
+			name:          "n out of range",
+			values:        url.Values{"n": []string{"0"}},
+			expectedError: v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:          "before not an integer",
+			values:        url.Values{"before": []string{"a"}},
+			expectedError: v1.ErrorCodeInvalidQueryParamType,
+		},
+		{
+			name:          "before out of range",
+			values:        url.Values{"before": []string{"0"}},
+			expectedError: v1.ErrorCodeInvalidQueryParamValue,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp := getTagHistory(t, env, repoRef, "latest", test.values)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedError)
+		})
+	}
+}
+
+func TestGitlabAPI_RepositoryTagHistory_RepositoryNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	resp := getTagHistory(t, env, repoRef, "latest")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeNameUnknown)
+}
+
+func withDatabaseNotificationsQueue(endpoints ...configuration.Endpoint) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Notifications = configuration.Notifications{
+			Queue: configuration.NotificationsQueue{
+				Type:        configuration.NotificationsQueueTypeDatabase,
+				MaxAttempts: 1,
+				Retention:   time.Hour,
+				Interval:    100 * time.Millisecond,
+			},
+			Endpoints: endpoints,
+		}
+	}
+}
+
+func getNotifications(t *testing.T, env *testEnv) handlers.NotificationsAPIResponse {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1NotificationsURL()
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.NotificationsAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+func replayNotifications(t *testing.T, env *testEnv, body string) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1NotificationsReplayURL()
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_Notifications_DatabaseQueue(t *testing.T) {
+	var available, received int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&available) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		atomic.AddInt32(&received, 1)
+	}))
+	t.Cleanup(failing.Close)
+	ok := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(ok.Close)
+
+	endpoint := func(name, url string) configuration.Endpoint {
+		return configuration.Endpoint{Name: name, URL: url, Timeout: time.Second, Threshold: 1, Backoff: 10 * time.Millisecond}
+	}
+	env := newTestEnv(t, withDatabaseNotificationsQueue(endpoint("failing", failing.URL), endpoint("ok", ok.URL)))
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	seedRandomSchema2Manifest(t, env, "foo/bar", putByTag("latest"))
+
+	// events are delivered to the available endpoint and marked as failed for the unavailable one
+	var body handlers.NotificationsAPIResponse
+	require.Eventually(t, func() bool {
+		body = getNotifications(t, env)
+		return len(body.Endpoints) == 1 && body.Endpoints[0].Pending == 0
+	}, 10*time.Second, 100*time.Millisecond)
+
+	failed := body.Endpoints[0]
+	require.Equal(t, "failing", failed.Name)
+	require.Positive(t, failed.Failed)
+	require.Empty(t, failed.OldestPendingAt)
+
+	// once the endpoint is available again, failed events can be replayed
+	atomic.StoreInt32(&available, 1)
+
+	resp := replayNotifications(t, env, `{"endpoint": "failing"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var replay handlers.NotificationsReplayAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&replay))
+	require.EqualValues(t, failed.Failed, replay.Requeued)
+
+	require.Eventually(t, func() bool {
+		return len(getNotifications(t, env).Endpoints) == 0
+	}, 10*time.Second, 100*time.Millisecond)
+	require.EqualValues(t, failed.Failed, atomic.LoadInt32(&received))
+}
+
+func TestGitlabAPI_Notifications_Replay_InvalidBody(t *testing.T) {
+	env := newTestEnv(t, withDatabaseNotificationsQueue(configuration.Endpoint{Name: "foo", URL: "http://localhost"}))
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	tt := []struct {
+		name         string
+		body         string
+		expectedCode errcode.ErrorCode
+	}{
+		{name: "invalid json", body: "{", expectedCode: v1.ErrorCodeInvalidJSONBody},
+		{name: "unknown endpoint", body: `{"endpoint": "bar"}`, expectedCode: v1.ErrorCodeInvalidBodyParamType},
+		{name: "invalid since", body: `{"since": "yesterday"}`, expectedCode: v1.ErrorCodeInvalidBodyParamType},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp := replayNotifications(t, env, test.body)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "wrong response body error code", resp, test.expectedCode)
+		})
+	}
+}
+
+func TestGitlabAPI_Notifications_NotEnabled(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	u, err := env.builder.BuildGitlabV1NotificationsURL()
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeNotImplemented)
+
+	resp = replayNotifications(t, env, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v1.ErrorCodeNotImplemented)
+}
+
+func getRepositoryManifest(t *testing.T, env *testEnv, ref reference.Named, values ...url.Values) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryManifestURL(ref, values...)
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestGitlabAPI_RepositoryManifest(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	cfg := []byte(`{"architecture":"amd64","os":"linux","config":{"Entrypoint":["/bin/sh"],"Env":["FOO=bar"],"Labels":{"a":"b"}},"created":"2023-11-27T09:00:00Z"}`)
+	m := seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("latest"), withConfig("application/vnd.oci.image.config.v1+json", cfg))
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
+
+	tagged, err := reference.WithTag(repoRef, "latest")
+	require.NoError(t, err)
+	canonical, err := reference.WithDigest(repoRef, dgst)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name           string
+		ref            reference.Named
+		queryParams    url.Values
+		expectedConfig bool
+	}{
+		{name: "by tag", ref: tagged},
+		{name: "by digest", ref: canonical},
+		{name: "with config", ref: tagged, queryParams: url.Values{"config": []string{"true"}}, expectedConfig: true},
+		{name: "without config", ref: canonical, queryParams: url.Values{"config": []string{"false"}}},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			var values []url.Values
+			if test.queryParams != nil {
+				values = append(values, test.queryParams)
+			}
+			resp := getRepositoryManifest(t, env, test.ref, values...)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+			var body handlers.RepositoryManifestAPIResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+			require.Equal(t, dgst.String(), body.Digest)
+			require.Equal(t, "application/vnd.oci.image.manifest.v1+json", body.MediaType)
+			require.Equal(t, digest.FromBytes(cfg).String(), body.ConfigDigest)
+			require.Equal(t, "application/vnd.oci.image.config.v1+json", body.ConfigMediaType)
+			require.NotEmpty(t, body.Size)
+			require.Regexp(t, iso8601MsFormat, body.CreatedAt)
+			require.JSONEq(t, string(payload), string(body.Manifest))
+			if test.expectedConfig {
+				require.JSONEq(t, string(cfg), string(body.Config))
+			} else {
+				require.Empty(t, body.Config)
+			}
+		})
+	}
+}
+
+func TestGitlabAPI_RepositoryManifest_NonJSONConfig(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("sbom"),
+		withArtifactType("application/vnd.example.sbom.v1"),
+		withConfig("application/vnd.example.sbom.config.v1", []byte("not json")))
+
+	tagged, err := reference.WithTag(repoRef, "sbom")
+	require.NoError(t, err)
+	resp := getRepositoryManifest(t, env, tagged, url.Values{"config": []string{"true"}})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body handlers.RepositoryManifestAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "application/vnd.example.sbom.config.v1", body.ConfigMediaType)
+	require.Empty(t, body.Config)
+}
+
+func TestGitlabAPI_RepositoryManifest_NotFound(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	tagged, err := reference.WithTag(repoRef, "latest")
+	require.NoError(t, err)
+
+	// unknown repository
+	resp := getRepositoryManifest(t, env, tagged)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeNameUnknown)
+
+	// unknown manifest
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("other"))
+	resp = getRepositoryManifest(t, env, tagged)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeManifestUnknown)
+}
+
+func TestGitlabAPI_RepositoryManifest_InvalidQueryParams(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("latest"))
+
+	tagged, err := reference.WithTag(repoRef, "latest")
+	require.NoError(t, err)
+	resp := getRepositoryManifest(t, env, tagged, url.Values{"config": []string{"yes"}})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v1.ErrorCodeInvalidQueryParamValue)
+}
+
+// exportRepositoryManifest exports the manifest identified by ref and returns the files in the exported archive.
+func exportRepositoryManifest(t *testing.T, env *testEnv, ref reference.Named) (*http.Response, map[string][]byte) {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryManifestExportURL(ref)
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(resp.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = b
+	}
+
+	return resp, files
+}
+
+func TestGitlabAPI_RepositoryManifestExport(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	m := seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("latest"))
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
+
+	tagged, err := reference.WithTag(repoRef, "latest")
+	require.NoError(t, err)
+	canonical, err := reference.WithDigest(repoRef, dgst)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name        string
+		ref         reference.Named
+		expectedTag string
+	}{
+		{name: "by tag", ref: tagged, expectedTag: "latest"},
+		{name: "by digest", ref: canonical},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp, files := exportRepositoryManifest(t, env, test.ref)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "application/x-tar", resp.Header.Get("Content-Type"))
+			require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+
+			require.JSONEq(t, `{"imageLayoutVersion":"1.0.0"}`, string(files["oci-layout"]))
+
+			var index struct {
+				Manifests []struct {
+					MediaType   string            `json:"mediaType"`
+					Digest      string            `json:"digest"`
+					Size        int               `json:"size"`
+					Annotations map[string]string `json:"annotations"`
+				} `json:"manifests"`
+			}
+			require.NoError(t, json.Unmarshal(files["index.json"], &index))
+			require.Len(t, index.Manifests, 1)
+			require.Equal(t, "application/vnd.oci.image.manifest.v1+json", index.Manifests[0].MediaType)
+			require.Equal(t, dgst.String(), index.Manifests[0].Digest)
+			require.Equal(t, len(payload), index.Manifests[0].Size)
+			require.Equal(t, test.expectedTag, index.Manifests[0].Annotations["org.opencontainers.image.ref.name"])
+
+			// the manifest, configuration and layers are all in the archive and match their digest
+			require.Len(t, files, 2+1+1+len(m.Layers()))
+			for _, d := range append([]digest.Digest{dgst, m.Config().Digest}, layerDigests(m)...) {
+				b, ok := files["blobs/sha256/"+d.Encoded()]
+				require.True(t, ok, "blob %s not exported", d)
+				require.Equal(t, d, digest.FromBytes(b))
+			}
+		})
+	}
+}
+
+func layerDigests(m *ocischema.DeserializedManifest) []digest.Digest {
+	dd := make([]digest.Digest, 0, len(m.Layers()))
+	for _, l := range m.Layers() {
+		dd = append(dd, l.Digest)
+	}
+	return dd
+}
+
+func TestGitlabAPI_RepositoryManifestExport_Index(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	ml := seedRandomOCIImageIndex(t, env, repoRef.Name(), putByTag("latest"))
+	_, payload, err := ml.Payload()
+	require.NoError(t, err)
+
+	tagged, err := reference.WithTag(repoRef, "latest")
+	require.NoError(t, err)
+	resp, files := exportRepositoryManifest(t, env, tagged)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Contains(t, files, "blobs/sha256/"+digest.FromBytes(payload).Encoded())
+	for _, desc := range ml.References() {
+		b, ok := files["blobs/sha256/"+desc.Digest.Encoded()]
+		require.True(t, ok, "child manifest %s not exported", desc.Digest)
+
+		child := new(ocischema.DeserializedManifest)
+		require.NoError(t, child.UnmarshalJSON(b))
+		for _, d := range append([]digest.Digest{child.Config().Digest}, layerDigests(child)...) {
+			require.Contains(t, files, "blobs/sha256/"+d.Encoded())
+		}
+	}
+}
+
+func TestGitlabAPI_RepositoryManifestExport_NotFound(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	tagged, err := reference.WithTag(repoRef, "latest")
+	require.NoError(t, err)
+
+	// unknown repository
+	resp, _ := exportRepositoryManifest(t, env, tagged)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeNameUnknown)
+
+	// unknown manifest
+	seedRandomOCIManifest(t, env, repoRef.Name(), putByTag("other"))
+	resp, _ = exportRepositoryManifest(t, env, tagged)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeManifestUnknown)
+}
+
+func importRepositoryLayout(t *testing.T, env *testEnv, repoRef reference.Named, body io.Reader, values ...url.Values) *http.Response {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryLayoutImportURL(repoRef, values...)
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "application/x-tar", body)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+// exportRepositoryArchive returns the raw OCI image layout archive exported for ref.
+func exportRepositoryArchive(t *testing.T, env *testEnv, ref reference.Named) []byte {
+	t.Helper()
+
+	u, err := env.builder.BuildGitlabV1RepositoryManifestExportURL(ref)
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestGitlabAPI_RepositoryLayoutImport(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	srcRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	dstRef, err := reference.WithName("baz/qux")
+	require.NoError(t, err)
+
+	ml := seedRandomOCIImageIndex(t, env, srcRef.Name(), putByTag("latest"))
+	_, payload, err := ml.Payload()
+	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
+
+	tagged, err := reference.WithTag(srcRef, "latest")
+	require.NoError(t, err)
+	archive := exportRepositoryArchive(t, env, tagged)
+
+	resp := importRepositoryLayout(t, env, dstRef, bytes.NewReader(archive))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var body handlers.LayoutImportAPIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, []handlers.ImportedManifestAPIResponse{{
+		Digest:    dgst.String(),
+		MediaType: "application/vnd.oci.image.index.v1+json",
+		Tags:      []string{"latest"},
+	}}, body.Manifests)
+	require.NotZero(t, body.BlobCount)
+
+	// the index, its child manifests and their blobs can now be pulled from the target repository
+	dstTagged, err := reference.WithTag(dstRef, "latest")
+	require.NoError(t, err)
+	manifestURL, err := env.builder.BuildManifestURL(dstTagged)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodHead, manifestURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/vnd.oci.image.index.v1+json")
+	headResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer headResp.Body.Close()
+	require.Equal(t, http.StatusOK, headResp.StatusCode)
+	require.Equal(t, dgst.String(), headResp.Header.Get("Docker-Content-Digest"))
+
+	for _, desc := range ml.References() {
+		dstCanonical, err := reference.WithDigest(dstRef, desc.Digest)
+		require.NoError(t, err)
+		resp, files := exportRepositoryManifest(t, env, dstCanonical)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NotEmpty(t, files)
+	}
+
+	// importing the same archive again is idempotent
+	resp = importRepositoryLayout(t, env, dstRef, bytes.NewReader(archive))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestGitlabAPI_RepositoryLayoutImport_Invalid(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	// not a tar archive
+	resp := importRepositoryLayout(t, env, repoRef, strings.NewReader(strings.Repeat("x", 1024)))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v1.ErrorCodeImageLayoutInvalid)
+
+	// a valid archive missing the manifest it references, which is not imported
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"oci-layout": `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json": `{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"` + digest.FromString("missing").String() + `","size":7}]}`,
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(content)), Mode: 0o644}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	resp = importRepositoryLayout(t, env, repoRef, &buf)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v1.ErrorCodeImageLayoutInvalid)
+
+	// importing from URLs is disabled by default
+	resp = importRepositoryLayout(t, env, repoRef, http.NoBody, url.Values{"url": []string{"https://artifacts.example.com/image.tar"}})
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, errcode.ErrorCodeUnsupported)
+}
+
+func TestGitlabAPI_RepositoryLayoutImport_TooLarge(t *testing.T) {
+	env := newTestEnv(t, func(config *configuration.Configuration) {
+		config.Uploads.Import.MaxSize = 1024
+	})
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	srcRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	dstRef, err := reference.WithName("baz/qux")
+	require.NoError(t, err)
+
+	seedRandomOCIManifest(t, env, srcRef.Name(), putByTag("latest"))
+	tagged, err := reference.WithTag(srcRef, "latest")
+	require.NoError(t, err)
+	archive := exportRepositoryArchive(t, env, tagged)
+
+	resp := importRepositoryLayout(t, env, dstRef, bytes.NewReader(archive))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeSizeInvalid)
+}
+
+func TestGitlabAPI_RepositoryEvents(t *testing.T) {
+	env := newTestEnv(t)
+	t.Cleanup(env.Shutdown)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	u, err := env.builder.BuildGitlabV1RepositoryEventsURL(repoRef)
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	seedRandomSchema2Manifest(t, env, repoRef.Name(), putByTag("latest"))
+
+	// blob pushes are streamed too, so skip until we find the tag push
+	r := bufio.NewReader(resp.Body)
+	var eventType string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			var e notifications.Event
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+			require.Equal(t, notifications.EventActionPush, eventType)
+			require.Equal(t, repoRef.Name(), e.Target.Repository)
+			if e.Target.Tag == "latest" {
+				return
+			}
+		}
+	}
+}
@@ -9,8 +9,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -29,10 +34,13 @@ import (
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/auth/token"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/factory"
+	_ "github.com/docker/distribution/registry/storage/driver/middleware/redirect"
 	"github.com/docker/distribution/testutil"
 
 	internaltestutil "github.com/docker/distribution/registry/internal/testutil"
@@ -80,6 +88,225 @@ func TestBlobAPI_Get_BlobNotInDatabase(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, res.StatusCode)
 }
 
+// TestBlobAPI_Get_Range ensures that partial blob downloads, such as those issued by lazy-pulling snapshotters (e.g.
+// stargz or SOCI) to fetch individual files from a layer, are served with the expected status, headers and content.
+func TestBlobAPI_Get_Range(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	args, blobURL := createRepoWithBlob(t, env)
+	content := readBlobArgsLayer(t, args)
+	size := len(content)
+
+	testBlobAPIGetRange(t, http.DefaultClient, blobURL, content)
+
+	// HEAD requests get the same headers as GET requests, but no body
+	req, err := http.NewRequest(http.MethodHead, blobURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=0-9")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, fmt.Sprintf("bytes 0-9/%d", size), resp.Header.Get("Content-Range"))
+	require.Equal(t, "10", resp.Header.Get("Content-Length"))
+
+	// the range is only honored if the If-Range validator matches the blob ETag
+	for _, tc := range []struct {
+		ifRange        string
+		expectedStatus int
+		expectedBody   []byte
+	}{
+		{fmt.Sprintf(`"%s"`, args.layerDigest), http.StatusPartialContent, content[:10]},
+		{`"sha256:0000000000000000000000000000000000000000000000000000000000000000"`, http.StatusOK, content},
+	} {
+		req, err := http.NewRequest(http.MethodGet, blobURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", "bytes=0-9")
+		req.Header.Set("If-Range", tc.ifRange)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, tc.expectedStatus, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, tc.expectedBody, body)
+		require.Equal(t, args.layerDigest.String(), resp.Header.Get("Docker-Content-Digest"))
+	}
+}
+
+// TestBlobAPI_Get_RangeRedirect ensures that partial blob downloads are served by the storage backend when the
+// registry redirects clients to it.
+func TestBlobAPI_Get_RangeRedirect(t *testing.T) {
+	root := t.TempDir()
+	storage := httptest.NewServer(http.FileServer(http.Dir(root)))
+	defer storage.Close()
+
+	env := newTestEnv(t, withFSDriver(root), withStorageRedirect(storage.URL))
+	defer env.Shutdown()
+
+	args, blobURL := createRepoWithBlob(t, env)
+	content := readBlobArgsLayer(t, args)
+
+	// make sure the download is redirected and that the Range header is resent to the storage backend
+	var redirected bool
+	client := &http.Client{
+		Timeout: http.DefaultClient.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			redirected = true
+			require.True(t, strings.HasPrefix(req.URL.String(), storage.URL))
+			require.Equal(t, via[0].Header.Get("Range"), req.Header.Get("Range"))
+			return nil
+		},
+	}
+
+	testBlobAPIGetRange(t, client, blobURL, content)
+	require.True(t, redirected)
+}
+
+// readBlobArgsLayer returns the content of the layer in args.
+func readBlobArgsLayer(t *testing.T, args blobArgs) []byte {
+	t.Helper()
+
+	_, err := args.layerFile.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	content, err := io.ReadAll(args.layerFile)
+	require.NoError(t, err)
+	require.Greater(t, len(content), 100)
+
+	return content
+}
+
+// testBlobAPIGetRange downloads parts of the blob at blobURL with single, multiple and unsatisfiable ranges, verifying
+// the response status and headers, and that the returned content matches the corresponding parts of content.
+func testBlobAPIGetRange(t *testing.T, client *http.Client, blobURL string, content []byte) {
+	t.Helper()
+
+	size := len(content)
+	type part struct {
+		first, last int
+	}
+
+	tt := []struct {
+		name  string
+		rng   string
+		parts []part
+	}{
+		{name: "first bytes", rng: "bytes=0-9", parts: []part{{0, 9}}},
+		{name: "middle bytes", rng: "bytes=20-49", parts: []part{{20, 49}}},
+		{name: "open ended", rng: "bytes=50-", parts: []part{{50, size - 1}}},
+		{name: "suffix", rng: "bytes=-10", parts: []part{{size - 10, size - 1}}},
+		{name: "last byte beyond size", rng: fmt.Sprintf("bytes=90-%d", size+100), parts: []part{{90, size - 1}}},
+		{name: "multiple ranges", rng: "bytes=0-9,20-29,-10", parts: []part{{0, 9}, {20, 29}, {size - 10, size - 1}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, blobURL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Range", tc.rng)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+
+			if len(tc.parts) == 1 {
+				p := tc.parts[0]
+				require.Equal(t, fmt.Sprintf("bytes %d-%d/%d", p.first, p.last, size), resp.Header.Get("Content-Range"))
+				require.Equal(t, strconv.Itoa(p.last-p.first+1), resp.Header.Get("Content-Length"))
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Equal(t, content[p.first:p.last+1], body)
+				return
+			}
+
+			mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			require.Equal(t, "multipart/byteranges", mediaType)
+
+			mr := multipart.NewReader(resp.Body, params["boundary"])
+			for _, p := range tc.parts {
+				mp, err := mr.NextPart()
+				require.NoError(t, err)
+				require.Equal(t, fmt.Sprintf("bytes %d-%d/%d", p.first, p.last, size), mp.Header.Get("Content-Range"))
+
+				body, err := io.ReadAll(mp)
+				require.NoError(t, err)
+				require.Equal(t, content[p.first:p.last+1], body)
+			}
+			_, err = mr.NextPart()
+			require.ErrorIs(t, err, io.EOF)
+		})
+	}
+
+	t.Run("unsatisfiable", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, blobURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", size))
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+		require.Equal(t, fmt.Sprintf("bytes */%d", size), resp.Header.Get("Content-Range"))
+	})
+}
+
+func trackedUploads(t *testing.T, env *testEnv) []string {
+	t.Helper()
+
+	uu, err := datastore.NewUploadStore(env.db).FindStartedBefore(context.Background(), time.Now().Add(time.Hour), 100)
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(uu))
+	for _, u := range uu {
+		ids = append(ids, u.UUID)
+	}
+	return ids
+}
+
+func TestBlobAPI_UploadTracking(t *testing.T) {
+	skipDatabaseNotEnabled(t)
+
+	env := newTestEnv(t, withUploadPurging)
+	defer env.Shutdown()
+
+	args := makeBlobArgs(t)
+
+	// completed uploads are untracked
+	location, id := startPushLayer(t, env, args.imageName)
+	require.Contains(t, trackedUploads(t, env), id)
+
+	pushLayer(t, env.builder, args.imageName, args.layerDigest, location, args.layerFile)
+	require.NotContains(t, trackedUploads(t, env), id)
+
+	// canceled uploads are untracked
+	location, id = startPushLayer(t, env, args.imageName)
+	require.Contains(t, trackedUploads(t, env), id)
+
+	req, err := http.NewRequest(http.MethodDelete, location, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NotContains(t, trackedUploads(t, env), id)
+}
+
+func TestBlobAPI_UploadTracking_Disabled(t *testing.T) {
+	skipDatabaseNotEnabled(t)
+
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	args := makeBlobArgs(t)
+	_, id := startPushLayer(t, env, args.imageName)
+	require.NotContains(t, trackedUploads(t, env), id)
+}
+
 func TestBlobDelete(t *testing.T) {
 	env := newTestEnv(t, withDelete)
 	defer env.Shutdown()
@@ -182,1045 +409,2069 @@ func TestBlobDeleteDisabled(t *testing.T) {
 	checkResponse(t, "status of disabled delete", resp, http.StatusMethodNotAllowed)
 }
 
-func testBlobAPI(t *testing.T, env *testEnv, args blobArgs) *testEnv {
-	imageName := args.imageName
-	layerFile := args.layerFile
-	layerDigest := args.layerDigest
+func TestBlobAPI_RemoteMount(t *testing.T) {
+	srcEnv := newTestEnv(t)
+	defer srcEnv.Shutdown()
 
-	ref, _ := reference.WithDigest(imageName, layerDigest)
-	layerURL, err := env.builder.BuildBlobURL(ref)
-	if err != nil {
-		t.Fatalf("error building url: %v", err)
-	}
+	args := makeBlobArgs(t)
+	uploadURLBase, _ := startPushLayer(t, srcEnv, args.imageName)
+	pushLayer(t, srcEnv.builder, args.imageName, args.layerDigest, uploadURLBase, args.layerFile)
 
-	// ------------------------------------------
-	// Start an upload, check the status then cancel
-	uploadURLBase, uploadUUID := startPushLayer(t, env, imageName)
+	srcURL, err := url.Parse(srcEnv.server.URL)
+	require.NoError(t, err)
 
-	// A status check should work
-	resp, err := http.Get(uploadURLBase)
-	if err != nil {
-		t.Fatalf("unexpected error getting upload status: %v", err)
-	}
-	defer resp.Body.Close()
-	checkResponse(t, "status of deleted upload", resp, http.StatusNoContent)
-	checkHeaders(t, resp, http.Header{
-		"Location":           []string{"*"},
-		"Range":              []string{"0-0"},
-		"Docker-Upload-UUID": []string{uploadUUID},
-	})
+	env := newTestEnv(t, withRemoteMount(srcURL.Host))
+	defer env.Shutdown()
 
-	req, err := http.NewRequest(http.MethodDelete, uploadURLBase, nil)
-	if err != nil {
-		t.Fatalf("unexpected error creating delete request: %v", err)
-	}
+	destName, err := reference.WithName("remote/mount")
+	require.NoError(t, err)
 
-	resp, err = http.DefaultClient.Do(req)
-	if err != nil {
-		t.Fatalf("unexpected error sending delete request: %v", err)
+	mount := func(t *testing.T, registry string, dgst digest.Digest) *http.Response {
+		t.Helper()
+
+		u, err := env.builder.BuildBlobUploadURL(destName, url.Values{
+			"mount":         []string{dgst.String()},
+			"from":          []string{args.imageName.Name()},
+			"from_registry": []string{registry},
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Post(u, "", nil)
+		require.NoError(t, err)
+
+		return resp
 	}
+
+	// mount blob from the source registry
+	resp := mount(t, srcEnv.server.URL, args.layerDigest)
 	defer resp.Body.Close()
+	checkResponse(t, "remote mount blob", resp, http.StatusCreated)
+	require.Equal(t, args.layerDigest.String(), resp.Header.Get("Docker-Content-Digest"))
 
-	checkResponse(t, "deleting upload", resp, http.StatusNoContent)
+	ref, err := reference.WithDigest(destName, args.layerDigest)
+	require.NoError(t, err)
+	blobURL, err := env.builder.BuildBlobURL(ref)
+	require.NoError(t, err)
+	assertGetResponse(t, blobURL, http.StatusOK)
 
-	// A status check should result in 404
-	resp, err = http.Get(uploadURLBase)
-	if err != nil {
-		t.Fatalf("unexpected error getting upload status: %v", err)
-	}
+	// blob unknown to the source registry
+	resp = mount(t, srcEnv.server.URL, digest.FromString("unknown"))
 	defer resp.Body.Close()
-	checkResponse(t, "status of deleted upload", resp, http.StatusNotFound)
+	checkResponse(t, "remote mount unknown blob", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "remote mount unknown blob", resp, v2.ErrorCodeBlobUnknown)
 
-	// -----------------------------------------
-	// Do layer push with an empty body and different digest
-	uploadURLBase, _ = startPushLayer(t, env, imageName)
-	resp, err = doPushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, bytes.NewReader([]byte{}))
-	if err != nil {
-		t.Fatalf("unexpected error doing bad layer push: %v", err)
-	}
+	// registry not allowed
+	resp = mount(t, "http://registry.example.com", args.layerDigest)
 	defer resp.Body.Close()
+	checkResponse(t, "remote mount from disallowed registry", resp, http.StatusForbidden)
+	checkBodyHasErrorCodes(t, "remote mount from disallowed registry", resp, errcode.ErrorCodeDenied)
+}
 
-	checkResponse(t, "bad layer push", resp, http.StatusBadRequest)
-	checkBodyHasErrorCodes(t, "bad layer push", resp, v2.ErrorCodeDigestInvalid)
+func TestAPI_AuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	env := newTestEnv(t, withDelete, withAuditLogFile(path))
+	defer env.Shutdown()
 
-	// -----------------------------------------
-	// Do layer push with an empty body and correct digest
-	zeroDigest, err := digest.FromReader(bytes.NewReader([]byte{}))
-	if err != nil {
-		t.Fatalf("unexpected error digesting empty buffer: %v", err)
-	}
+	repoPath := "foo/bar"
+	dgst := createRepository(t, env, repoPath, "latest")
 
-	uploadURLBase, _ = startPushLayer(t, env, imageName)
-	pushLayer(t, env.builder, imageName, zeroDigest, uploadURLBase, bytes.NewReader([]byte{}))
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	digestRef, err := reference.WithDigest(repoRef, dgst)
+	require.NoError(t, err)
+	manifestURL, err := env.builder.BuildManifestURL(digestRef)
+	require.NoError(t, err)
 
-	// -----------------------------------------
-	// Do layer push with an empty body and correct digest
+	resp, err := httpDelete(manifestURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
-	// This is a valid but empty tarfile!
-	emptyTar := bytes.Repeat([]byte("\x00"), 1024)
-	emptyDigest, err := digest.FromReader(bytes.NewReader(emptyTar))
-	if err != nil {
-		t.Fatalf("unexpected error digesting empty tar: %v", err)
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, audit.Verify(f, nil))
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	var records []audit.Record
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var r audit.Record
+		require.NoError(t, dec.Decode(&r))
+		require.Equal(t, repoPath, r.Repository)
+		require.NotEmpty(t, r.CorrelationID)
+		records = append(records, r)
+	}
+
+	// all layers and the configuration are pushed before the manifest
+	var pushedBlobs int
+	for _, r := range records {
+		if r.Artifact == audit.ArtifactBlob {
+			require.Equal(t, audit.ActionPush, r.Action)
+			pushedBlobs++
+		}
 	}
+	require.NotZero(t, pushedBlobs)
+
+	// the manifest delete may be followed by the deletion of its tags, depending on the metadata backend
+	records = records[pushedBlobs:]
+	require.GreaterOrEqual(t, len(records), 3)
+	require.Equal(t, audit.ActionPush, records[0].Action)
+	require.Equal(t, audit.ArtifactManifest, records[0].Artifact)
+	require.Equal(t, dgst, records[0].Digest)
+	require.Equal(t, schema2.MediaTypeManifest, records[0].MediaType)
+	require.Equal(t, audit.ActionPush, records[1].Action)
+	require.Equal(t, audit.ArtifactTag, records[1].Artifact)
+	require.Equal(t, "latest", records[1].Tag)
+	require.Equal(t, audit.ActionDelete, records[len(records)-1].Action)
+	require.Equal(t, audit.ArtifactManifest, records[len(records)-1].Artifact)
+	require.Equal(t, dgst, records[len(records)-1].Digest)
+}
 
-	uploadURLBase, _ = startPushLayer(t, env, imageName)
-	pushLayer(t, env.builder, imageName, emptyDigest, uploadURLBase, bytes.NewReader(emptyTar))
+func TestBlobAPI_RemoteMount_Disabled(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
 
-	// ------------------------------------------
-	// Now, actually do successful upload.
-	layerLength, _ := layerFile.Seek(0, io.SeekEnd)
-	layerFile.Seek(0, io.SeekStart)
+	args := makeBlobArgs(t)
+	u, err := env.builder.BuildBlobUploadURL(args.imageName, url.Values{
+		"mount":         []string{args.layerDigest.String()},
+		"from":          []string{"foo/bar"},
+		"from_registry": []string{"https://registry.example.com"},
+	})
+	require.NoError(t, err)
 
-	uploadURLBase, _ = startPushLayer(t, env, imageName)
-	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, layerFile)
+	resp, err := http.Post(u, "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
 
-	// ------------------------------------------
-	// Now, push just a chunk
-	layerFile.Seek(0, 0)
+	checkResponse(t, "remote mount disabled", resp, http.StatusMethodNotAllowed)
+	checkBodyHasErrorCodes(t, "remote mount disabled", resp, errcode.ErrorCodeUnsupported)
+}
 
-	canonicalDigester := digest.Canonical.Digester()
-	if _, err := io.Copy(canonicalDigester.Hash(), layerFile); err != nil {
-		t.Fatalf("error copying to digest: %v", err)
-	}
-	canonicalDigest := canonicalDigester.Digest()
+func TestBlobAPI_AnonymousMount(t *testing.T) {
+	env := newTestEnv(t)
+	env.requireDB(t)
+	t.Cleanup(env.Shutdown)
 
-	layerFile.Seek(0, 0)
-	uploadURLBase, _ = startPushLayer(t, env, imageName)
-	uploadURLBase, dgst := pushChunk(t, env.builder, imageName, uploadURLBase, layerFile, layerLength)
-	finishUpload(t, env.builder, imageName, uploadURLBase, dgst)
+	// seed a manifest in the source repository, whose layer will be mounted without naming the source repository
+	srcPath := "foo/src"
+	m := seedRandomOCIManifest(t, env, srcPath, putByDigest)
+	dgst := m.Layers()[0].Digest
 
-	// -----------------------------------------
-	// Push a chunk with unordered content range
+	mount := func(env *testEnv, dstPath string, opts ...requestOpt) *http.Response {
+		t.Helper()
 
-	layerFile.Seek(0, io.SeekStart)
+		dstRef, err := reference.WithName(dstPath)
+		require.NoError(t, err)
+		u, err := env.builder.BuildBlobUploadURL(dstRef, url.Values{"mount": []string{dgst.String()}})
+		require.NoError(t, err)
 
-	uploadURLBase, _ = startPushLayer(t, env, imageName)
-	resp, _, err = doPushChunk(t, uploadURLBase, layerFile, witContentRangeHeader("8-30"))
-	if err != nil {
-		t.Fatalf("unexpected error on pushing layer: %v", err)
+		req, err := http.NewRequest(http.MethodPost, u, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(newRequest(req, opts...))
+		require.NoError(t, err)
+		return resp
 	}
-	defer resp.Body.Close()
-	checkResponse(t, "uploading out of order chunk", resp, http.StatusRequestedRangeNotSatisfiable)
 
-	// ------------------------
-	// Use a head request to see if the layer exists.
-	resp, err = http.Head(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error checking head on existing layer: %v", err)
-	}
+	// without authentication, any repository can be mounted from
+	resp := mount(env, "bar/dst")
 	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
 
-	checkResponse(t, "checking head on existing layer", resp, http.StatusOK)
-	checkHeaders(t, resp, http.Header{
-		"Content-Length":        []string{fmt.Sprint(layerLength)},
-		"Docker-Content-Digest": []string{canonicalDigest.String()},
-	})
+	tokenProvider := NewAuthTokenProvider(t)
+	env = newTestEnv(t, withTokenAuth(tokenProvider.CertPath(), defaultIssuerProps()))
+	t.Cleanup(env.Shutdown)
 
-	// ----------------
-	// Fetch the layer!
-	resp, err = http.Get(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error fetching layer: %v", err)
-	}
+	// without pull access to the source repository, an upload is started instead
+	dstPath := "foo/dst"
+	resp = mount(env, dstPath, witAuthToken(tokenProvider.TokenWithActions(fullAccessToken(dstPath))))
 	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
-	checkResponse(t, "fetching layer", resp, http.StatusOK)
-	checkHeaders(t, resp, http.Header{
-		"Content-Length":        []string{fmt.Sprint(layerLength)},
-		"Docker-Content-Digest": []string{canonicalDigest.String()},
-	})
+	// with pull access to the source repository
+	actions := append(fullAccessToken(dstPath), &token.ResourceActions{Type: "repository", Name: srcPath, Actions: []string{"pull"}})
+	resp = mount(env, dstPath, witAuthToken(tokenProvider.TokenWithActions(actions)))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
 
-	// Verify the body
-	verifier := layerDigest.Verifier()
-	io.Copy(verifier, resp.Body)
+	// with pull access to the source repository through a wildcard scope
+	dstPath = "foo/other"
+	actions = append(fullAccessToken(dstPath), &token.ResourceActions{Type: "repository", Name: "foo/*", Actions: []string{"pull"}})
+	resp = mount(env, dstPath, witAuthToken(tokenProvider.TokenWithActions(actions)))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+}
 
-	if !verifier.Verified() {
-		t.Fatalf("response body did not pass verification")
-	}
+func TestAPI_RateLimiter(t *testing.T) {
+	env := newTestEnv(t, withRedisCache(internaltestutil.RedisServer(t).Addr()), func(config *configuration.Configuration) {
+		config.RateLimiter.Enabled = true
+		config.RateLimiter.Limiters = []configuration.Limiter{
+			{Name: "repository", Key: configuration.RateLimitKeyRepository, Sustained: 2, Period: time.Hour},
+		}
+	})
+	defer env.Shutdown()
 
-	// ----------------
-	// Fetch the layer with an invalid digest
-	badURL := strings.Replace(layerURL, "sha256", "sha257", 1)
-	resp, err = http.Get(badURL)
-	if err != nil {
-		t.Fatalf("unexpected error fetching layer: %v", err)
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	u, err := env.builder.BuildTagsURL(imageName)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
 	}
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
 	defer resp.Body.Close()
 
-	checkResponse(t, "fetching layer bad digest", resp, http.StatusBadRequest)
+	checkResponse(t, "rate limit exceeded", resp, http.StatusTooManyRequests)
+	checkBodyHasErrorCodes(t, "rate limit exceeded", resp, errcode.ErrorCodeTooManyRequests)
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	require.NoError(t, err)
+	require.Positive(t, retryAfter)
+	require.LessOrEqual(t, retryAfter, int((2 * time.Hour).Seconds()))
 
-	// Cache headers
-	resp, err = http.Get(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error fetching layer: %v", err)
-	}
+	// requests to other repositories are not limited
+	u, err = env.builder.BuildBaseURL()
+	require.NoError(t, err)
+	resp, err = http.Get(u)
+	require.NoError(t, err)
 	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
 
-	checkResponse(t, "fetching layer", resp, http.StatusOK)
-	checkHeaders(t, resp, http.Header{
-		"Content-Length":        []string{fmt.Sprint(layerLength)},
-		"Docker-Content-Digest": []string{canonicalDigest.String()},
-		"ETag":                  []string{fmt.Sprintf(`"%s"`, canonicalDigest)},
-		"Cache-Control":         []string{"max-age=31536000"},
+func TestAPI_BlobUploadChunkSizeLimits(t *testing.T) {
+	env := newTestEnv(t, func(config *configuration.Configuration) {
+		config.Uploads.Chunks.MinSize = 4
+		config.Uploads.Chunks.MaxSize = 8
 	})
+	defer env.Shutdown()
 
-	// Matching etag, gives 304
-	etag := resp.Header.Get("Etag")
-	req, err = http.NewRequest(http.MethodGet, layerURL, nil)
-	if err != nil {
-		t.Fatalf("Error constructing request: %s", err)
-	}
-	req.Header.Set("If-None-Match", etag)
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
 
-	resp, err = http.DefaultClient.Do(req)
-	if err != nil {
-		t.Fatalf("Error constructing request: %s", err)
-	}
+	resp, err := http.DefaultClient.Do(startPushLayerRequest(t, env, imageName))
+	require.NoError(t, err)
 	defer resp.Body.Close()
+	checkResponse(t, "starting layer push", resp, http.StatusAccepted)
+	require.Equal(t, "4", resp.Header.Get("OCI-Chunk-Min-Length"))
+	uploadURLBase := resp.Header.Get("Location")
 
-	checkResponse(t, "fetching layer with etag", resp, http.StatusNotModified)
-
-	// Non-matching etag, gives 200
-	req, err = http.NewRequest(http.MethodGet, layerURL, nil)
-	if err != nil {
-		t.Fatalf("Error constructing request: %s", err)
-	}
-	req.Header.Set("If-None-Match", "")
-	resp, err = http.DefaultClient.Do(req)
+	// chunks above the maximum size are rejected
+	resp, _, err = doPushChunk(t, uploadURLBase, bytes.NewReader([]byte("abcdefghij")), witContentRangeHeader("0-9"))
 	require.NoError(t, err)
 	defer resp.Body.Close()
-	checkResponse(t, "fetching layer with invalid etag", resp, http.StatusOK)
+	checkResponse(t, "uploading chunk above maximum size", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "uploading chunk above maximum size", resp, v2.ErrorCodeInvalidContentRange)
 
-	// Missing tests:
-	//	- Upload the same tar file under and different repository and
-	//       ensure the content remains uncorrupted.
-	return env
-}
+	// chunks within limits are accepted
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader([]byte("abcde")), 5, witContentRangeHeader("0-4"))
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader([]byte("fghij")), 10, witContentRangeHeader("5-9"))
 
-func testBlobDelete(t *testing.T, env *testEnv, args blobArgs) {
-	// Upload a layer
-	imageName := args.imageName
-	layerFile := args.layerFile
-	layerDigest := args.layerDigest
+	// the final chunk may be below the minimum size, but must then be the last one
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader([]byte("k")), 11, witContentRangeHeader("10-10"))
 
-	ref, _ := reference.WithDigest(imageName, layerDigest)
-	layerURL, err := env.builder.BuildBlobURL(ref)
-	if err != nil {
-		t.Fatalf(err.Error())
-	}
-	// ---------------
-	// Delete a layer
-	resp, err := httpDelete(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error deleting layer: %v", err)
-	}
+	resp, _, err = doPushChunk(t, uploadURLBase, bytes.NewReader([]byte("lmnop")), witContentRangeHeader("11-15"))
+	require.NoError(t, err)
 	defer resp.Body.Close()
+	checkResponse(t, "uploading chunk after one below minimum size", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "uploading chunk after one below minimum size", resp, v2.ErrorCodeInvalidContentRange)
 
-	checkResponse(t, "deleting layer", resp, http.StatusAccepted)
-	checkHeaders(t, resp, http.Header{
-		"Content-Length": []string{"0"},
+	dgst := digest.FromString("abcdefghijk")
+	resp, err = doPushLayer(t, env.builder, imageName, dgst, uploadURLBase, nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "completing upload", resp, http.StatusCreated)
+}
+
+func TestAPI_BlobUploadStreamedChunkMaxSize(t *testing.T) {
+	env := newTestEnv(t, func(config *configuration.Configuration) {
+		config.Uploads.Chunks.MaxSize = 8
 	})
+	defer env.Shutdown()
 
-	// ---------------
-	// Try and get it back
-	// Use a head request to see if the layer exists.
-	resp, err = http.Head(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error checking head on existing layer: %v", err)
-	}
-	defer resp.Body.Close()
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
 
-	checkResponse(t, "checking existence of deleted layer", resp, http.StatusNotFound)
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
 
-	// Delete already deleted layer
-	resp, err = httpDelete(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error deleting layer: %v", err)
+	// chunks without a range are subject to the maximum size too, whether their length is known in advance or not
+	withContentLength := func(n int64) requestOpt {
+		return func(r *http.Request) {
+			r.ContentLength = n
+		}
 	}
+	resp, _, err := doPushChunk(t, uploadURLBase, bytes.NewReader([]byte("abcdefghij")), withContentLength(10))
+	require.NoError(t, err)
 	defer resp.Body.Close()
+	checkResponse(t, "uploading chunk with length above maximum size", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "uploading chunk with length above maximum size", resp, v2.ErrorCodeInvalidContentRange)
 
-	checkResponse(t, "deleting layer", resp, http.StatusNotFound)
+	// chunks within the limit are accepted
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader([]byte("abcde")), 5)
 
-	// ----------------
-	// Attempt to delete a layer with an invalid digest
-	badURL := strings.Replace(layerURL, "sha256", "sha257", 1)
-	resp, err = httpDelete(badURL)
-	if err != nil {
-		t.Fatalf("unexpected error fetching layer: %v", err)
-	}
+	// in the latter case, the data within the limit is written before noticing, so the upload is canceled
+	resp, _, err = doPushChunk(t, uploadURLBase, bytes.NewReader([]byte("fghijklmnop")))
+	require.NoError(t, err)
 	defer resp.Body.Close()
+	checkResponse(t, "streaming chunk above maximum size", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "streaming chunk above maximum size", resp, v2.ErrorCodeInvalidContentRange)
 
-	checkResponse(t, "deleting layer bad digest", resp, http.StatusBadRequest)
+	resp, err = http.Get(uploadURLBase)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "status of canceled upload", resp, http.StatusNotFound)
+}
 
-	// ----------------
-	// Reupload previously deleted blob
-	layerFile.Seek(0, io.SeekStart)
+func TestAPI_BlobUploadChunkFinalChunkSentWithPut(t *testing.T) {
+	env := newTestEnv(t, func(config *configuration.Configuration) {
+		config.Uploads.Chunks.MinSize = 4
+	})
+	defer env.Shutdown()
 
-	uploadURLBase, _ := startPushLayer(t, env, imageName)
-	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, layerFile)
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
 
-	layerFile.Seek(0, io.SeekStart)
-	canonicalDigester := digest.Canonical.Digester()
-	if _, err := io.Copy(canonicalDigester.Hash(), layerFile); err != nil {
-		t.Fatalf("error copying to digest: %v", err)
-	}
-	canonicalDigest := canonicalDigester.Digest()
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader([]byte("abcde")), 5, witContentRangeHeader("0-4"))
 
-	// ------------------------
-	// Use a head request to see if it exists
-	resp, err = http.Head(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error checking head on existing layer: %v", err)
-	}
+	dgst := digest.FromString("abcdef")
+	resp, err := doPushLayer(t, env.builder, imageName, dgst, uploadURLBase, bytes.NewReader([]byte("f")))
+	require.NoError(t, err)
 	defer resp.Body.Close()
-
-	layerLength, _ := layerFile.Seek(0, io.SeekEnd)
-	checkResponse(t, "checking head on reuploaded layer", resp, http.StatusOK)
-	checkHeaders(t, resp, http.Header{
-		"Content-Length":        []string{fmt.Sprint(layerLength)},
-		"Docker-Content-Digest": []string{canonicalDigest.String()},
-	})
+	checkResponse(t, "completing upload", resp, http.StatusCreated)
 }
 
-func TestDeleteDisabled(t *testing.T) {
+func TestAPI_BlobUploadChunkSizeMismatch(t *testing.T) {
 	env := newTestEnv(t)
 	defer env.Shutdown()
 
-	imageName, _ := reference.WithName("foo/bar")
-	// "build" our layer file
-	layerFile, layerDigest, err := testutil.CreateRandomTarFile()
-	if err != nil {
-		t.Fatalf("error creating random layer file: %v", err)
-	}
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
 
-	ref, _ := reference.WithDigest(imageName, layerDigest)
-	layerURL, err := env.builder.BuildBlobURL(ref)
-	if err != nil {
-		t.Fatalf("Error building blob URL")
-	}
 	uploadURLBase, _ := startPushLayer(t, env, imageName)
-	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, layerFile)
 
-	resp, err := httpDelete(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error deleting layer: %v", err)
+	// the body is larger than the range, whether its length is known in advance or not. In the latter case, the data
+	// within the range is written before noticing, so the upload is canceled.
+	withContentLength := func(n int64) requestOpt {
+		return func(r *http.Request) {
+			r.ContentLength = n
+		}
 	}
+	resp, _, err := doPushChunk(t, uploadURLBase, bytes.NewReader([]byte("abcdefgh")), witContentRangeHeader("0-4"), withContentLength(8))
+	require.NoError(t, err)
 	defer resp.Body.Close()
+	checkResponse(t, "uploading chunk with length mismatching its range", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "uploading chunk with length mismatching its range", resp, v2.ErrorCodeInvalidContentRange)
 
-	checkResponse(t, "deleting layer with delete disabled", resp, http.StatusMethodNotAllowed)
+	resp, _, err = doPushChunk(t, uploadURLBase, bytes.NewReader([]byte("abcdefgh")), witContentRangeHeader("0-4"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "uploading chunk larger than its range", resp, http.StatusRequestedRangeNotSatisfiable)
+	checkBodyHasErrorCodes(t, "uploading chunk larger than its range", resp, v2.ErrorCodeInvalidContentRange)
+
+	resp, err = http.Get(uploadURLBase)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "status of canceled upload", resp, http.StatusNotFound)
 }
 
-func TestDeleteReadOnly(t *testing.T) {
-	rootDir := t.TempDir()
+func TestBlobAPI_MonolithicUpload(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
 
-	setupEnv := newTestEnv(t, withFSDriver(rootDir))
-	defer setupEnv.Shutdown()
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
 
-	imageName, _ := reference.WithName("foo/bar")
-	// "build" our layer file
-	layerFile, layerDigest, err := testutil.CreateRandomTarFile()
-	if err != nil {
-		t.Fatalf("error creating random layer file: %v", err)
-	}
+	content := []byte("monolithic")
+	dgst := digest.FromBytes(content)
 
-	ref, _ := reference.WithDigest(imageName, layerDigest)
-	uploadURLBase, _ := startPushLayer(t, setupEnv, imageName)
-	pushLayer(t, setupEnv.builder, imageName, layerDigest, uploadURLBase, layerFile)
+	// a digest that does not match the content is rejected
+	req := newRequest(startPushLayerRequest(t, env, imageName), withDigestQueryParam(digest.FromString("other")))
+	req.Body = io.NopCloser(bytes.NewReader(content))
+	req.ContentLength = int64(len(content))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "monolithic upload with wrong digest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "monolithic upload with wrong digest", resp, v2.ErrorCodeDigestInvalid)
 
-	// Reconfigure environment with withReadOnly enabled.
-	setupEnv.Shutdown()
-	env := newTestEnv(t, withFSDriver(rootDir), withReadOnly)
-	defer env.Shutdown()
+	req = newRequest(startPushLayerRequest(t, env, imageName), withDigestQueryParam(dgst))
+	req.Body = io.NopCloser(bytes.NewReader(content))
+	req.ContentLength = int64(len(content))
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "monolithic upload", resp, http.StatusCreated)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
 
-	layerURL, err := env.builder.BuildBlobURL(ref)
-	if err != nil {
-		t.Fatalf("Error building blob URL")
-	}
+	ref, err := reference.WithDigest(imageName, dgst)
+	require.NoError(t, err)
+	blobURL, err := env.builder.BuildBlobURL(ref)
+	require.NoError(t, err)
 
-	resp, err := httpDelete(layerURL)
-	if err != nil {
-		t.Fatalf("unexpected error deleting layer: %v", err)
-	}
+	resp, err = http.Get(blobURL)
+	require.NoError(t, err)
 	defer resp.Body.Close()
-
-	checkResponse(t, "deleting layer in read-only mode", resp, http.StatusMethodNotAllowed)
+	checkResponse(t, "fetching uploaded blob", resp, http.StatusOK)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, content, body)
 }
 
-func TestStartPushReadOnly(t *testing.T) {
-	env := newTestEnv(t, withDelete, withReadOnly)
+func TestBlobAPI_GetUploadStatus_Progress(t *testing.T) {
+	env := newTestEnv(t)
 	defer env.Shutdown()
 
-	imageName, _ := reference.WithName("foo/bar")
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
 
-	layerUploadURL, err := env.builder.BuildBlobUploadURL(imageName)
-	if err != nil {
-		t.Fatalf("unexpected error building layer upload url: %v", err)
-	}
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
 
-	resp, err := http.Post(layerUploadURL, "", nil)
-	if err != nil {
-		t.Fatalf("unexpected error starting layer push: %v", err)
-	}
+	// no progress is reported other than the rate until data is received
+	resp, err := http.Get(uploadURLBase)
+	require.NoError(t, err)
 	defer resp.Body.Close()
+	checkResponse(t, "status of empty upload", resp, http.StatusNoContent)
+	require.Equal(t, "0", resp.Header.Get("Gitlab-Upload-Bytes-Per-Second"))
+	require.Empty(t, resp.Header.Get("Gitlab-Upload-Seconds-Since-Last-Chunk"))
 
-	checkResponse(t, "starting push in read-only mode", resp, http.StatusMethodNotAllowed)
-}
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader([]byte("abcde")), 5)
 
-type manifestArgs struct {
-	imageName reference.Named
-	mediaType string
-	manifest  distribution.Manifest
-	dgst      digest.Digest
+	resp, err = http.Get(uploadURLBase)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "status of upload", resp, http.StatusNoContent)
+	checkHeaders(t, resp, http.Header{
+		"Range":                                  []string{"0-4"},
+		"Gitlab-Upload-Bytes-Per-Second":         []string{"*"},
+		"Gitlab-Upload-Seconds-Since-Last-Chunk": []string{"*"},
+	})
+	if dgst := resp.Header.Get("Gitlab-Upload-Partial-Digest"); dgst != "" {
+		// only available with resumable digests
+		require.Equal(t, digest.FromString("abcde").String(), dgst)
+	}
 }
 
-// storageManifestErrDriverFactory implements the factory.StorageDriverFactory interface.
-type storageManifestErrDriverFactory struct{}
+func TestBlobAPI_ChunkDigestValidation(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
 
-const (
-	repositoryWithManifestNotFound    = "manifesttagnotfound"
+	imageName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+
+	// chunks that match their digest are accepted
+	uploadURLBase, _ = pushChunk(t, env.builder, imageName, uploadURLBase, bytes.NewReader([]byte("abcde")), 5,
+		witContentRangeHeader("0-4"), withDigestQueryParam(digest.FromString("abcde")))
+
+	// a corrupt chunk is rejected right away and the upload must be restarted
+	resp, _, err := doPushChunk(t, uploadURLBase, bytes.NewReader([]byte("fghiX")),
+		witContentRangeHeader("5-9"), withDigestQueryParam(digest.FromString("fghij")))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "uploading corrupt chunk", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "uploading corrupt chunk", resp, v2.ErrorCodeDigestInvalid)
+
+	resp, err = http.Get(uploadURLBase)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "status of canceled upload", resp, http.StatusNotFound)
+
+	// invalid digests are rejected without affecting the upload
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	resp, _, err = doPushChunk(t, uploadURLBase, bytes.NewReader([]byte("abcde")), withDigestQueryParam("sha256:invalid"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "uploading chunk with invalid digest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "uploading chunk with invalid digest", resp, v2.ErrorCodeDigestInvalid)
+
+	resp, err = http.Get(uploadURLBase)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "status of upload", resp, http.StatusNoContent)
+}
+
+func testBlobAPI(t *testing.T, env *testEnv, args blobArgs) *testEnv {
+	imageName := args.imageName
+	layerFile := args.layerFile
+	layerDigest := args.layerDigest
+
+	ref, _ := reference.WithDigest(imageName, layerDigest)
+	layerURL, err := env.builder.BuildBlobURL(ref)
+	if err != nil {
+		t.Fatalf("error building url: %v", err)
+	}
+
+	// ------------------------------------------
+	// Start an upload, check the status then cancel
+	uploadURLBase, uploadUUID := startPushLayer(t, env, imageName)
+
+	// A status check should work
+	resp, err := http.Get(uploadURLBase)
+	if err != nil {
+		t.Fatalf("unexpected error getting upload status: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, "status of deleted upload", resp, http.StatusNoContent)
+	checkHeaders(t, resp, http.Header{
+		"Location":           []string{"*"},
+		"Range":              []string{"0-0"},
+		"Docker-Upload-UUID": []string{uploadUUID},
+	})
+
+	req, err := http.NewRequest(http.MethodDelete, uploadURLBase, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating delete request: %v", err)
+	}
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error sending delete request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting upload", resp, http.StatusNoContent)
+
+	// A status check should result in 404
+	resp, err = http.Get(uploadURLBase)
+	if err != nil {
+		t.Fatalf("unexpected error getting upload status: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, "status of deleted upload", resp, http.StatusNotFound)
+
+	// -----------------------------------------
+	// Do layer push with an empty body and different digest
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	resp, err = doPushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, bytes.NewReader([]byte{}))
+	if err != nil {
+		t.Fatalf("unexpected error doing bad layer push: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "bad layer push", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "bad layer push", resp, v2.ErrorCodeDigestInvalid)
+
+	// -----------------------------------------
+	// Do layer push with an empty body and correct digest
+	zeroDigest, err := digest.FromReader(bytes.NewReader([]byte{}))
+	if err != nil {
+		t.Fatalf("unexpected error digesting empty buffer: %v", err)
+	}
+
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	pushLayer(t, env.builder, imageName, zeroDigest, uploadURLBase, bytes.NewReader([]byte{}))
+
+	// -----------------------------------------
+	// Do layer push with an empty body and correct digest
+
+	// This is a valid but empty tarfile!
+	emptyTar := bytes.Repeat([]byte("\x00"), 1024)
+	emptyDigest, err := digest.FromReader(bytes.NewReader(emptyTar))
+	if err != nil {
+		t.Fatalf("unexpected error digesting empty tar: %v", err)
+	}
+
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	pushLayer(t, env.builder, imageName, emptyDigest, uploadURLBase, bytes.NewReader(emptyTar))
+
+	// ------------------------------------------
+	// Now, actually do successful upload.
+	layerLength, _ := layerFile.Seek(0, io.SeekEnd)
+	layerFile.Seek(0, io.SeekStart)
+
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, layerFile)
+
+	// ------------------------------------------
+	// Now, push just a chunk
+	layerFile.Seek(0, 0)
+
+	canonicalDigester := digest.Canonical.Digester()
+	if _, err := io.Copy(canonicalDigester.Hash(), layerFile); err != nil {
+		t.Fatalf("error copying to digest: %v", err)
+	}
+	canonicalDigest := canonicalDigester.Digest()
+
+	layerFile.Seek(0, 0)
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	uploadURLBase, dgst := pushChunk(t, env.builder, imageName, uploadURLBase, layerFile, layerLength)
+	finishUpload(t, env.builder, imageName, uploadURLBase, dgst)
+
+	// -----------------------------------------
+	// Push a chunk with unordered content range
+
+	layerFile.Seek(0, io.SeekStart)
+
+	uploadURLBase, _ = startPushLayer(t, env, imageName)
+	resp, _, err = doPushChunk(t, uploadURLBase, layerFile, witContentRangeHeader("8-30"))
+	if err != nil {
+		t.Fatalf("unexpected error on pushing layer: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, "uploading out of order chunk", resp, http.StatusRequestedRangeNotSatisfiable)
+
+	// ------------------------
+	// Use a head request to see if the layer exists.
+	resp, err = http.Head(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error checking head on existing layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "checking head on existing layer", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Length":        []string{fmt.Sprint(layerLength)},
+		"Docker-Content-Digest": []string{canonicalDigest.String()},
+	})
+
+	// ----------------
+	// Fetch the layer!
+	resp, err = http.Get(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error fetching layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching layer", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Length":        []string{fmt.Sprint(layerLength)},
+		"Docker-Content-Digest": []string{canonicalDigest.String()},
+	})
+
+	// Verify the body
+	verifier := layerDigest.Verifier()
+	io.Copy(verifier, resp.Body)
+
+	if !verifier.Verified() {
+		t.Fatalf("response body did not pass verification")
+	}
+
+	// ----------------
+	// Fetch the layer with an invalid digest
+	badURL := strings.Replace(layerURL, "sha256", "sha257", 1)
+	resp, err = http.Get(badURL)
+	if err != nil {
+		t.Fatalf("unexpected error fetching layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching layer bad digest", resp, http.StatusBadRequest)
+
+	// Cache headers
+	resp, err = http.Get(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error fetching layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching layer", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Length":        []string{fmt.Sprint(layerLength)},
+		"Docker-Content-Digest": []string{canonicalDigest.String()},
+		"ETag":                  []string{fmt.Sprintf(`"%s"`, canonicalDigest)},
+		"Cache-Control":         []string{"max-age=31536000"},
+	})
+
+	// Matching etag, gives 304
+	etag := resp.Header.Get("Etag")
+	req, err = http.NewRequest(http.MethodGet, layerURL, nil)
+	if err != nil {
+		t.Fatalf("Error constructing request: %s", err)
+	}
+	req.Header.Set("If-None-Match", etag)
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error constructing request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching layer with etag", resp, http.StatusNotModified)
+
+	// Non-matching etag, gives 200
+	req, err = http.NewRequest(http.MethodGet, layerURL, nil)
+	if err != nil {
+		t.Fatalf("Error constructing request: %s", err)
+	}
+	req.Header.Set("If-None-Match", "")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	checkResponse(t, "fetching layer with invalid etag", resp, http.StatusOK)
+
+	// Missing tests:
+	//	- Upload the same tar file under and different repository and
+	//       ensure the content remains uncorrupted.
+	return env
+}
+
+func testBlobDelete(t *testing.T, env *testEnv, args blobArgs) {
+	// Upload a layer
+	imageName := args.imageName
+	layerFile := args.layerFile
+	layerDigest := args.layerDigest
+
+	ref, _ := reference.WithDigest(imageName, layerDigest)
+	layerURL, err := env.builder.BuildBlobURL(ref)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	// ---------------
+	// Delete a layer
+	resp, err := httpDelete(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error deleting layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting layer", resp, http.StatusAccepted)
+	checkHeaders(t, resp, http.Header{
+		"Content-Length": []string{"0"},
+	})
+
+	// ---------------
+	// Try and get it back
+	// Use a head request to see if the layer exists.
+	resp, err = http.Head(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error checking head on existing layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "checking existence of deleted layer", resp, http.StatusNotFound)
+
+	// Delete already deleted layer
+	resp, err = httpDelete(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error deleting layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting layer", resp, http.StatusNotFound)
+
+	// ----------------
+	// Attempt to delete a layer with an invalid digest
+	badURL := strings.Replace(layerURL, "sha256", "sha257", 1)
+	resp, err = httpDelete(badURL)
+	if err != nil {
+		t.Fatalf("unexpected error fetching layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting layer bad digest", resp, http.StatusBadRequest)
+
+	// ----------------
+	// Reupload previously deleted blob
+	layerFile.Seek(0, io.SeekStart)
+
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, layerFile)
+
+	layerFile.Seek(0, io.SeekStart)
+	canonicalDigester := digest.Canonical.Digester()
+	if _, err := io.Copy(canonicalDigester.Hash(), layerFile); err != nil {
+		t.Fatalf("error copying to digest: %v", err)
+	}
+	canonicalDigest := canonicalDigester.Digest()
+
+	// ------------------------
+	// Use a head request to see if it exists
+	resp, err = http.Head(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error checking head on existing layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	layerLength, _ := layerFile.Seek(0, io.SeekEnd)
+	checkResponse(t, "checking head on reuploaded layer", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Length":        []string{fmt.Sprint(layerLength)},
+		"Docker-Content-Digest": []string{canonicalDigest.String()},
+	})
+}
+
+func TestDeleteDisabled(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	// "build" our layer file
+	layerFile, layerDigest, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("error creating random layer file: %v", err)
+	}
+
+	ref, _ := reference.WithDigest(imageName, layerDigest)
+	layerURL, err := env.builder.BuildBlobURL(ref)
+	if err != nil {
+		t.Fatalf("Error building blob URL")
+	}
+	uploadURLBase, _ := startPushLayer(t, env, imageName)
+	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, layerFile)
+
+	resp, err := httpDelete(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error deleting layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting layer with delete disabled", resp, http.StatusMethodNotAllowed)
+}
+
+func TestDeleteReadOnly(t *testing.T) {
+	rootDir := t.TempDir()
+
+	setupEnv := newTestEnv(t, withFSDriver(rootDir))
+	defer setupEnv.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+	// "build" our layer file
+	layerFile, layerDigest, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("error creating random layer file: %v", err)
+	}
+
+	ref, _ := reference.WithDigest(imageName, layerDigest)
+	uploadURLBase, _ := startPushLayer(t, setupEnv, imageName)
+	pushLayer(t, setupEnv.builder, imageName, layerDigest, uploadURLBase, layerFile)
+
+	// Reconfigure environment with withReadOnly enabled.
+	setupEnv.Shutdown()
+	env := newTestEnv(t, withFSDriver(rootDir), withReadOnly)
+	defer env.Shutdown()
+
+	layerURL, err := env.builder.BuildBlobURL(ref)
+	if err != nil {
+		t.Fatalf("Error building blob URL")
+	}
+
+	resp, err := httpDelete(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error deleting layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting layer in read-only mode", resp, http.StatusMethodNotAllowed)
+}
+
+func TestStartPushReadOnly(t *testing.T) {
+	env := newTestEnv(t, withDelete, withReadOnly)
+	defer env.Shutdown()
+
+	imageName, _ := reference.WithName("foo/bar")
+
+	layerUploadURL, err := env.builder.BuildBlobUploadURL(imageName)
+	if err != nil {
+		t.Fatalf("unexpected error building layer upload url: %v", err)
+	}
+
+	resp, err := http.Post(layerUploadURL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error starting layer push: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "starting push in read-only mode", resp, http.StatusMethodNotAllowed)
+}
+
+type manifestArgs struct {
+	imageName reference.Named
+	mediaType string
+	manifest  distribution.Manifest
+	dgst      digest.Digest
+}
+
+// storageManifestErrDriverFactory implements the factory.StorageDriverFactory interface.
+type storageManifestErrDriverFactory struct{}
+
+const (
+	repositoryWithManifestNotFound    = "manifesttagnotfound"
 	repositoryWithManifestInvalidPath = "manifestinvalidpath"
 	repositoryWithManifestBadLink     = "manifestbadlink"
 	repositoryWithGenericStorageError = "genericstorageerr"
 )
 
-func (factory *storageManifestErrDriverFactory) Create(parameters map[string]interface{}) (storagedriver.StorageDriver, error) {
-	// Initialize the mock driver
-	var errGenericStorage = errors.New("generic storage error")
-	return &mockErrorDriver{
-		returnErrs: []mockErrorMapping{
-			{
-				pathMatch: fmt.Sprintf("%s/_manifests/tags", repositoryWithManifestNotFound),
-				content:   nil,
-				err:       storagedriver.PathNotFoundError{},
-			},
-			{
-				pathMatch: fmt.Sprintf("%s/_manifests/tags", repositoryWithManifestInvalidPath),
-				content:   nil,
-				err:       storagedriver.InvalidPathError{},
-			},
-			{
-				pathMatch: fmt.Sprintf("%s/_manifests/tags", repositoryWithManifestBadLink),
-				content:   []byte("this is a bad sha"),
-				err:       nil,
-			},
-			{
-				pathMatch: fmt.Sprintf("%s/_manifests/tags", repositoryWithGenericStorageError),
-				content:   nil,
-				err:       errGenericStorage,
-			},
-		},
-	}, nil
-}
+func (factory *storageManifestErrDriverFactory) Create(parameters map[string]interface{}) (storagedriver.StorageDriver, error) {
+	// Initialize the mock driver
+	var errGenericStorage = errors.New("generic storage error")
+	return &mockErrorDriver{
+		returnErrs: []mockErrorMapping{
+			{
+				pathMatch: fmt.Sprintf("%s/_manifests/tags", repositoryWithManifestNotFound),
+				content:   nil,
+				err:       storagedriver.PathNotFoundError{},
+			},
+			{
+				pathMatch: fmt.Sprintf("%s/_manifests/tags", repositoryWithManifestInvalidPath),
+				content:   nil,
+				err:       storagedriver.InvalidPathError{},
+			},
+			{
+				pathMatch: fmt.Sprintf("%s/_manifests/tags", repositoryWithManifestBadLink),
+				content:   []byte("this is a bad sha"),
+				err:       nil,
+			},
+			{
+				pathMatch: fmt.Sprintf("%s/_manifests/tags", repositoryWithGenericStorageError),
+				content:   nil,
+				err:       errGenericStorage,
+			},
+		},
+	}, nil
+}
+
+type mockErrorMapping struct {
+	pathMatch string
+	content   []byte
+	err       error
+}
+
+// mockErrorDriver implements StorageDriver to force storage error on manifest request
+type mockErrorDriver struct {
+	storagedriver.StorageDriver
+	returnErrs []mockErrorMapping
+}
+
+func (dr *mockErrorDriver) GetContent(ctx context.Context, path string) ([]byte, error) {
+	for _, returns := range dr.returnErrs {
+		if strings.Contains(path, returns.pathMatch) {
+			return returns.content, returns.err
+		}
+	}
+	return nil, errors.New("Unknown storage error")
+}
+
+func TestGetManifestWithStorageError(t *testing.T) {
+	factory.Register("storagemanifesterror", &storageManifestErrDriverFactory{})
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"storagemanifesterror": configuration.Parameters{},
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+	}
+	config.HTTP.Headers = headerConfig
+	env1 := newTestEnvWithConfig(t, &config)
+	defer env1.Shutdown()
+
+	repo, _ := reference.WithName(repositoryWithManifestNotFound)
+	testManifestWithStorageError(t, env1, repo, http.StatusNotFound, v2.ErrorCodeManifestUnknown)
+
+	repo, _ = reference.WithName(repositoryWithGenericStorageError)
+	testManifestWithStorageError(t, env1, repo, http.StatusInternalServerError, errcode.ErrorCodeUnknown)
+
+	repo, _ = reference.WithName(repositoryWithManifestInvalidPath)
+	testManifestWithStorageError(t, env1, repo, http.StatusInternalServerError, errcode.ErrorCodeUnknown)
+
+	repo, _ = reference.WithName(repositoryWithManifestBadLink)
+	testManifestWithStorageError(t, env1, repo, http.StatusNotFound, v2.ErrorCodeManifestUnknown)
+}
+
+func testManifestWithStorageError(t *testing.T, env *testEnv, imageName reference.Named, expectedStatusCode int, expectedErrorCode errcode.ErrorCode) {
+	tag := "latest"
+	tagRef, _ := reference.WithTag(imageName, tag)
+	manifestURL, err := env.builder.BuildManifestURL(tagRef)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest url: %v", err)
+	}
+
+	// -----------------------------
+	// Attempt to fetch the manifest
+	resp, err := http.Get(manifestURL)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, "getting non-existent manifest", resp, expectedStatusCode)
+	checkBodyHasErrorCodes(t, "getting non-existent manifest", resp, expectedErrorCode)
+	return
+}
+
+func TestManifestAPI_Get_Schema2NotInDatabase(t *testing.T) {
+	skipDatabaseNotEnabled(t)
+
+	rootDir := t.TempDir()
+
+	env1 := newTestEnv(t, withDBDisabled, withFSDriver(rootDir))
+	defer env1.Shutdown()
+
+	env2 := newTestEnv(t, withFSDriver(rootDir))
+	defer env2.Shutdown()
+
+	tagName := "schema2"
+	repoPath := "schema2/not/in/database"
+
+	// Push up image to filesystem storage only environment.
+	deserializedManifest := seedRandomSchema2Manifest(t, env1, repoPath, putByTag(tagName))
+
+	// Build URLs targeting an environment using the database, we should not
+	// have visibility into filesystem metadata.
+	tagURL := buildManifestTagURL(t, env2, repoPath, tagName)
+	digestURL := buildManifestDigestURL(t, env2, repoPath, deserializedManifest)
+
+	tt := []struct {
+		name        string
+		manifestURL string
+		etag        string
+	}{
+		{
+			name:        "by tag",
+			manifestURL: tagURL,
+		},
+		{
+			name:        "by digest",
+			manifestURL: digestURL,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.manifestURL, nil)
+			require.NoError(t, err)
+
+			req.Header.Set("Accept", schema2.MediaTypeManifest)
+			if test.etag != "" {
+				req.Header.Set("If-None-Match", test.etag)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		})
+	}
+}
+
+// Prevent regression related to https://gitlab.com/gitlab-com/gl-infra/production/-/issues/14260
+func TestManifestAPI_Put_Schema2WritesNoFilesystemBlobLinkMetadata(t *testing.T) {
+	skipDatabaseNotEnabled(t)
+
+	rootDir := t.TempDir()
+
+	env1 := newTestEnv(t, withFSDriver(rootDir))
+	defer env1.Shutdown()
+
+	env2 := newTestEnv(t, withDBDisabled, withFSDriver(rootDir))
+	defer env2.Shutdown()
+
+	tagName := "schema2"
+	repoPath := "schema2/not/in/database"
+
+	// Push up image to database environment.
+	deserializedManifest := seedRandomSchema2Manifest(t, env1, repoPath, putByTag(tagName))
+
+	// Try to get a layer from the filesystem, we should not encounter any layer
+	// metadata written by the database environment.
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+
+	ref, err := reference.WithDigest(repoRef, deserializedManifest.Manifest.Layers[0].Digest)
+	require.NoError(t, err)
+
+	layerURL, err := env2.builder.BuildBlobURL(ref)
+	require.NoError(t, err)
+
+	res, err := http.Get(layerURL)
+	require.NoError(t, err)
+
+	defer res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestManifestAPI_Put_LayerMediaType(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	env.requireDB(t)
+
+	tagName := "schema2unknownlayermediatype"
+	repoPath := "schema2/layermediatype"
+
+	unknownMediaType := "fake/mediatype"
+	// encrypted layer media types are registered on demand
+	encryptedMediaType := "application/vnd.oci.image.layer.v1.tar+lz4+encrypted"
+	genericBlobMediaType := "application/octet-stream"
+
+	tt := []struct {
+		name                    string
+		unknownLayerMediaType   bool
+		encryptedLayerMediaType bool
+	}{
+		{
+			name:                  "known layer media type",
+			unknownLayerMediaType: false,
+		},
+		{
+			name:                  "unknown layer media type",
+			unknownLayerMediaType: true,
+		},
+		{
+			name:                    "unknown encrypted layer media type",
+			encryptedLayerMediaType: true,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			repoRef, err := reference.WithName(repoPath)
+			require.NoError(t, err)
+
+			manifest := &schema2.Manifest{
+				Versioned: manifest.Versioned{
+					SchemaVersion: 2,
+					MediaType:     schema2.MediaTypeManifest,
+				},
+			}
+
+			// Create a manifest config and push up its content.
+			cfgPayload, cfgDesc := schema2Config()
+			uploadURLBase, _ := startPushLayer(t, env, repoRef)
+			pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
+			manifest.Config = cfgDesc
+
+			manifest.Layers = make([]distribution.Descriptor, 1)
+
+			rs, dgst, size := createRandomSmallLayer()
+
+			// Save the layer content as pushLayer exhausts the io.ReadSeeker
+			layerBytes, err := io.ReadAll(rs)
+			require.NoError(t, err)
+
+			uploadURLBase, _ = startPushLayer(t, env, repoRef)
+			pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, bytes.NewReader(layerBytes))
+
+			layerMT := schema2.MediaTypeLayer
+
+			if test.unknownLayerMediaType {
+				layerMT = unknownMediaType
+			}
+			if test.encryptedLayerMediaType {
+				layerMT = encryptedMediaType
+			}
+
+			manifest.Layers[0] = distribution.Descriptor{
+				Digest:    dgst,
+				MediaType: layerMT,
+				Size:      size,
+			}
+
+			deserializedManifest, err := schema2.FromStruct(*manifest)
+			require.NoError(t, err)
+
+			// Build URLs.
+			tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+
+			resp := putManifest(t, "putting manifest", tagURL, schema2.MediaTypeManifest, deserializedManifest.Manifest)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+			// Check layer media type
+			ctx := context.Background()
+			rStore := datastore.NewRepositoryStore(env.db)
+			mStore := datastore.NewManifestStore(env.db)
+			bStore := datastore.NewBlobStore(env.db)
+
+			r, err := rStore.FindByPath(ctx, repoPath)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+
+			dbMfst, err := rStore.FindManifestByTagName(ctx, r, tagName)
+			require.NoError(t, err)
+			require.NotNil(t, dbMfst)
+
+			dbLayers, err := mStore.LayerBlobs(ctx, dbMfst)
+			require.NoError(t, err)
+			require.NotNil(t, dbLayers)
+			require.Len(t, dbLayers, 1)
 
-type mockErrorMapping struct {
-	pathMatch string
-	content   []byte
-	err       error
-}
+			wantMT := layerMT
 
-// mockErrorDriver implements StorageDriver to force storage error on manifest request
-type mockErrorDriver struct {
-	storagedriver.StorageDriver
-	returnErrs []mockErrorMapping
-}
+			if test.unknownLayerMediaType {
+				wantMT = genericBlobMediaType
+			}
 
-func (dr *mockErrorDriver) GetContent(ctx context.Context, path string) ([]byte, error) {
-	for _, returns := range dr.returnErrs {
-		if strings.Contains(path, returns.pathMatch) {
-			return returns.content, returns.err
-		}
+			require.Equal(t, wantMT, dbLayers[0].MediaType)
+
+			// Ensure underlying blob media type is always generic.
+			rBlob, err := rStore.FindBlob(ctx, r, dgst)
+			require.NoError(t, err)
+			require.NotNil(t, rBlob)
+			require.Equal(t, genericBlobMediaType, rBlob.MediaType)
+
+			dbBlob, err := bStore.FindByDigest(ctx, dgst)
+			require.NoError(t, err)
+			require.NotNil(t, dbBlob)
+			require.Equal(t, genericBlobMediaType, dbBlob.MediaType)
+		})
 	}
-	return nil, errors.New("Unknown storage error")
 }
 
-func TestGetManifestWithStorageError(t *testing.T) {
-	factory.Register("storagemanifesterror", &storageManifestErrDriverFactory{})
-	config := configuration.Configuration{
-		Storage: configuration.Storage{
-			"storagemanifesterror": configuration.Parameters{},
-			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
-				"enabled": false,
-			}},
+func TestManifestAPI_Put_Schema2LayersNotAssociatedWithRepositoryButArePresentInDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	tagName := "schema2missinglayerstag"
+	repoPath := "schema2/missinglayers"
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+
+	manifest := &schema2.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 2,
+			MediaType:     schema2.MediaTypeManifest,
 		},
 	}
-	config.HTTP.Headers = headerConfig
-	env1 := newTestEnvWithConfig(t, &config)
-	defer env1.Shutdown()
 
-	repo, _ := reference.WithName(repositoryWithManifestNotFound)
-	testManifestWithStorageError(t, env1, repo, http.StatusNotFound, v2.ErrorCodeManifestUnknown)
+	// Create a manifest config and push up its content.
+	cfgPayload, cfgDesc := schema2Config()
+	uploadURLBase, _ := startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
+	manifest.Config = cfgDesc
 
-	repo, _ = reference.WithName(repositoryWithGenericStorageError)
-	testManifestWithStorageError(t, env1, repo, http.StatusInternalServerError, errcode.ErrorCodeUnknown)
+	// Create and push up 2 random layers to an unrelated repo so that they are
+	// present within the database, but not associated with the manifest's repository.
+	// Then push them to the normal repository with the database disabled.
+	manifest.Layers = make([]distribution.Descriptor, 2)
 
-	repo, _ = reference.WithName(repositoryWithManifestInvalidPath)
-	testManifestWithStorageError(t, env1, repo, http.StatusInternalServerError, errcode.ErrorCodeUnknown)
+	fakeRepoRef, err := reference.WithName("fakerepo")
+	require.NoError(t, err)
 
-	repo, _ = reference.WithName(repositoryWithManifestBadLink)
-	testManifestWithStorageError(t, env1, repo, http.StatusNotFound, v2.ErrorCodeManifestUnknown)
-}
+	for i := range manifest.Layers {
+		rs, dgst, size := createRandomSmallLayer()
 
-func testManifestWithStorageError(t *testing.T, env *testEnv, imageName reference.Named, expectedStatusCode int, expectedErrorCode errcode.ErrorCode) {
-	tag := "latest"
-	tagRef, _ := reference.WithTag(imageName, tag)
-	manifestURL, err := env.builder.BuildManifestURL(tagRef)
-	if err != nil {
-		t.Fatalf("unexpected error getting manifest url: %v", err)
-	}
+		// Save the layer content as pushLayer exhausts the io.ReadSeeker
+		layerBytes, err := io.ReadAll(rs)
+		require.NoError(t, err)
 
-	// -----------------------------
-	// Attempt to fetch the manifest
-	resp, err := http.Get(manifestURL)
-	if err != nil {
-		t.Fatalf("unexpected error getting manifest: %v", err)
+		uploadURLBase, _ := startPushLayer(t, env, fakeRepoRef)
+		pushLayer(t, env.builder, fakeRepoRef, dgst, uploadURLBase, bytes.NewReader(layerBytes))
+
+		// Disable the database so writes only go to the filesytem.
+		env.config.Database.Enabled = false
+
+		uploadURLBase, _ = startPushLayer(t, env, repoRef)
+		pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, bytes.NewReader(layerBytes))
+
+		// Enable the database again so that reads first check the database.
+		env.config.Database.Enabled = true
+
+		manifest.Layers[i] = distribution.Descriptor{
+			Digest:    dgst,
+			MediaType: schema2.MediaTypeLayer,
+			Size:      size,
+		}
 	}
-	defer resp.Body.Close()
-	checkResponse(t, "getting non-existent manifest", resp, expectedStatusCode)
-	checkBodyHasErrorCodes(t, "getting non-existent manifest", resp, expectedErrorCode)
-	return
-}
 
-func TestManifestAPI_Get_Schema2NotInDatabase(t *testing.T) {
-	skipDatabaseNotEnabled(t)
+	deserializedManifest, err := schema2.FromStruct(*manifest)
+	require.NoError(t, err)
 
-	rootDir := t.TempDir()
+	// Build URLs.
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
 
-	env1 := newTestEnv(t, withDBDisabled, withFSDriver(rootDir))
-	defer env1.Shutdown()
+	resp := putManifest(t, "putting manifest, layers not associated with repository", tagURL, schema2.MediaTypeManifest, deserializedManifest.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
 
-	env2 := newTestEnv(t, withFSDriver(rootDir))
-	defer env2.Shutdown()
+// TestManifestAPI_BuildkitIndex tests that the API will accept pushes and pulls of Buildkit cache image index.
+// Related to https://gitlab.com/gitlab-org/container-registry/-/issues/407.
+func TestManifestAPI_BuildkitIndex(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
 
-	tagName := "schema2"
-	repoPath := "schema2/not/in/database"
+	tagName := "latest"
+	repoPath := "cache"
 
-	// Push up image to filesystem storage only environment.
-	deserializedManifest := seedRandomSchema2Manifest(t, env1, repoPath, putByTag(tagName))
+	// Create and push config
+	cfgPayload := `{"layers":[{"blob":"sha256:136482bf81d1fa351b424ebb8c7e34d15f2c5ed3fc0b66b544b8312bda3d52d9","parent":-1},{"blob":"sha256:cc28e5fb26aec14963e8cf2987c137b84755a031068ea9284631a308dc087b35"}],"records":[{"digest":"sha256:16a28dbbe0151c1ab102d9414f78aa338627df3ce3c450905cd36d41b3e3d08e"},{"digest":"sha256:ef9770ef24f7942c1ccbbcac2235d9c0fbafc80d3af78ca0b483886adeac8960"}]}`
+	cfgDesc := distribution.Descriptor{
+		MediaType: mlcompat.MediaTypeBuildxCacheConfig,
+		Digest:    digest.FromString(cfgPayload),
+		Size:      int64(len(cfgPayload)),
+	}
+	assertBlobPutResponse(t, env, repoPath, cfgDesc.Digest, strings.NewReader(cfgPayload), 201)
 
-	// Build URLs targeting an environment using the database, we should not
-	// have visibility into filesystem metadata.
-	tagURL := buildManifestTagURL(t, env2, repoPath, tagName)
-	digestURL := buildManifestDigestURL(t, env2, repoPath, deserializedManifest)
+	// Create and push 2 random layers
+	layers := make([]distribution.Descriptor, 2)
+	for i := range layers {
+		rs, dgst, size := createRandomSmallLayer()
+		assertBlobPutResponse(t, env, repoPath, dgst, rs, 201)
 
-	tt := []struct {
-		name        string
-		manifestURL string
-		etag        string
-	}{
-		{
-			name:        "by tag",
-			manifestURL: tagURL,
+		layers[i] = distribution.Descriptor{
+			MediaType: v1.MediaTypeImageLayerGzip,
+			Digest:    dgst,
+			Size:      size,
+			Annotations: map[string]string{
+				"buildkit/createdat":         time.Now().String(),
+				"containerd.io/uncompressed": digest.FromString(strconv.Itoa(i)).String(),
+			},
+		}
+	}
+
+	idx := &manifestlist.ManifestList{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 2,
+			MediaType:     v1.MediaTypeImageIndex,
 		},
-		{
-			name:        "by digest",
-			manifestURL: digestURL,
+		Manifests: []manifestlist.ManifestDescriptor{
+			{Descriptor: layers[0]},
+			{Descriptor: layers[1]},
+			{Descriptor: cfgDesc},
 		},
 	}
 
-	for _, test := range tt {
-		t.Run(test.name, func(t *testing.T) {
-			req, err := http.NewRequest(http.MethodGet, test.manifestURL, nil)
-			require.NoError(t, err)
+	didx, err := manifestlist.FromDescriptorsWithMediaType(idx.Manifests, v1.MediaTypeImageIndex)
+	require.NoError(t, err)
+	_, payload, err := didx.Payload()
+	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
 
-			req.Header.Set("Accept", schema2.MediaTypeManifest)
-			if test.etag != "" {
-				req.Header.Set("If-None-Match", test.etag)
-			}
+	// Push index
+	assertManifestPutByTagResponse(t, env, repoPath, didx, v1.MediaTypeImageIndex, tagName, 201)
+
+	// Get index
+	u := buildManifestTagURL(t, env, repoPath, tagName)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	require.NoError(t, err)
+
+	req.Header.Set("Accept", v1.MediaTypeImageIndex)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+
+	var respIdx *manifestlist.DeserializedManifestList
+	dec := json.NewDecoder(resp.Body)
+	err = dec.Decode(&respIdx)
+	require.NoError(t, err)
 
-			resp, err := http.DefaultClient.Do(req)
-			require.NoError(t, err)
-			defer resp.Body.Close()
+	require.EqualValues(t, didx, respIdx)
 
-			require.Equal(t, http.StatusNotFound, resp.StatusCode)
-		})
+	// Stat each one of its references
+	for _, d := range didx.References() {
+		assertBlobHeadResponse(t, env, repoPath, d.Digest, 200)
 	}
 }
 
-// Prevent regression related to https://gitlab.com/gitlab-com/gl-infra/production/-/issues/14260
-func TestManifestAPI_Put_Schema2WritesNoFilesystemBlobLinkMetadata(t *testing.T) {
-	skipDatabaseNotEnabled(t)
-
-	rootDir := t.TempDir()
+func TestManifestAPI_OCIIndexNoMediaType(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
 
-	env1 := newTestEnv(t, withFSDriver(rootDir))
-	defer env1.Shutdown()
+	repoRef, err := reference.WithName("foo")
+	require.NoError(t, err)
 
-	env2 := newTestEnv(t, withDBDisabled, withFSDriver(rootDir))
-	defer env2.Shutdown()
+	tag := "latest"
+	tagRef, err := reference.WithTag(repoRef, tag)
+	require.NoError(t, err)
 
-	tagName := "schema2"
-	repoPath := "schema2/not/in/database"
+	sentIndex := seedRandomOCIImageIndex(t, env, repoRef.Name(), putByTag(tag), withoutMediaType)
 
-	// Push up image to database environment.
-	deserializedManifest := seedRandomSchema2Manifest(t, env1, repoPath, putByTag(tagName))
+	manifestURL, err := env.builder.BuildManifestURL(tagRef)
+	require.NoError(t, err)
 
-	// Try to get a layer from the filesystem, we should not encounter any layer
-	// metadata written by the database environment.
-	repoRef, err := reference.WithName(repoPath)
+	req, err := http.NewRequest(http.MethodGet, manifestURL, nil)
 	require.NoError(t, err)
 
-	ref, err := reference.WithDigest(repoRef, deserializedManifest.Manifest.Layers[0].Digest)
+	// v1.MediaTypeImageIndex would be enough, but this replicates the behavior of the Docker client and others
+	req.Header.Set("Accept", schema2.MediaTypeManifest)
+	req.Header.Add("Accept", v1.MediaTypeImageManifest)
+	req.Header.Add("Accept", manifestlist.MediaTypeManifestList)
+	req.Header.Add("Accept", v1.MediaTypeImageIndex)
+
+	resp, err := http.DefaultClient.Do(req)
 	require.NoError(t, err)
+	defer resp.Body.Close()
 
-	layerURL, err := env2.builder.BuildBlobURL(ref)
+	checkResponse(t, "", resp, http.StatusOK)
+
+	// ensure content-type header is properly set and the digest matches the one we know
+	_, payload, err := sentIndex.Payload()
 	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
 
-	res, err := http.Get(layerURL)
+	checkHeaders(t, resp, http.Header{
+		"Content-Type":          []string{v1.MediaTypeImageIndex},
+		"Docker-Content-Digest": []string{dgst.String()},
+	})
+
+	// ensure payload matches the one sent and double-check that the mediaType field is not filled
+	var fetchedIndex *manifestlist.DeserializedManifestList
+	err = json.NewDecoder(resp.Body).Decode(&fetchedIndex)
 	require.NoError(t, err)
 
-	defer res.Body.Close()
-	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	require.EqualValues(t, sentIndex, fetchedIndex)
+	require.Empty(t, fetchedIndex.MediaType)
 }
 
-func TestManifestAPI_Put_LayerMediaType(t *testing.T) {
+// TestManifestAPI_ManifestListWithLayerReferences tests that the API will not
+// accept pushes and pulls of non Buildkit cache image manifest lists which
+// reference blobs.
+// Related to https://gitlab.com/gitlab-org/container-registry/-/issues/407.
+func TestManifestAPI_ManifestListWithLayerReferences(t *testing.T) {
 	env := newTestEnv(t)
 	defer env.Shutdown()
 
-	env.requireDB(t)
+	tagName := "latest"
+	repoPath := "malformed-manifestlist"
 
-	tagName := "schema2unknownlayermediatype"
-	repoPath := "schema2/layermediatype"
+	// Create and push 2 random layers
+	layers := make([]distribution.Descriptor, 2)
+	for i := range layers {
+		rs, dgst, size := createRandomSmallLayer()
+		assertBlobPutResponse(t, env, repoPath, dgst, rs, 201)
 
-	unknownMediaType := "fake/mediatype"
-	genericBlobMediaType := "application/octet-stream"
+		layers[i] = distribution.Descriptor{
+			MediaType: v1.MediaTypeImageLayerGzip,
+			Digest:    dgst,
+			Size:      size,
+		}
+	}
 
-	tt := []struct {
-		name                  string
-		unknownLayerMediaType bool
-	}{
-		{
-			name:                  "known layer media type",
-			unknownLayerMediaType: false,
+	idx := &manifestlist.ManifestList{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 2,
+			MediaType:     manifestlist.MediaTypeManifestList,
 		},
-		{
-			name:                  "unknown layer media type",
-			unknownLayerMediaType: true,
+		Manifests: []manifestlist.ManifestDescriptor{
+			{Descriptor: layers[0]},
+			{Descriptor: layers[1]},
 		},
 	}
 
-	for _, test := range tt {
-		t.Run(test.name, func(t *testing.T) {
-			repoRef, err := reference.WithName(repoPath)
-			require.NoError(t, err)
+	didx, err := manifestlist.FromDescriptorsWithMediaType(idx.Manifests, manifestlist.MediaTypeManifestList)
+	require.NoError(t, err)
 
-			manifest := &schema2.Manifest{
-				Versioned: manifest.Versioned{
-					SchemaVersion: 2,
-					MediaType:     schema2.MediaTypeManifest,
-				},
-			}
+	// Push index, since there is no buildx config layer, we should reject the push as invalid.
+	assertManifestPutByTagResponse(t, env, repoPath, didx, manifestlist.MediaTypeManifestList, tagName, 400)
+	manifestDigestURL := buildManifestDigestURL(t, env, repoPath, didx)
 
-			// Create a manifest config and push up its content.
-			cfgPayload, cfgDesc := schema2Config()
-			uploadURLBase, _ := startPushLayer(t, env, repoRef)
-			pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
-			manifest.Config = cfgDesc
+	resp := putManifest(t, "putting manifest list bad request", manifestDigestURL, manifestlist.MediaTypeManifestList, didx)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 
-			manifest.Layers = make([]distribution.Descriptor, 1)
+	_, p, counts := checkBodyHasErrorCodes(t, "manifest list with layer blobs", resp, v2.ErrorCodeManifestBlobUnknown)
+	expectedCounts := map[errcode.ErrorCode]int{v2.ErrorCodeManifestBlobUnknown: 2}
+	require.EqualValuesf(t, expectedCounts, counts, "response body: %s", p)
+}
 
-			rs, dgst, size := createRandomSmallLayer()
+func TestManifestAPI_Get_Schema1(t *testing.T) {
+	env := newTestEnv(t, withSchema1PreseededInMemoryDriver)
+	defer env.Shutdown()
 
-			// Save the layer content as pushLayer exhausts the io.ReadSeeker
-			layerBytes, err := io.ReadAll(rs)
-			require.NoError(t, err)
+	// Seed manifest in database directly since schema1 manifests are unpushable.
+	if env.config.Database.Enabled {
+		repositoryStore := datastore.NewRepositoryStore(env.db)
+		dbRepo, err := repositoryStore.CreateByPath(env.ctx, preseededSchema1RepoPath)
 
-			uploadURLBase, _ = startPushLayer(t, env, repoRef)
-			pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, bytes.NewReader(layerBytes))
+		mStore := datastore.NewManifestStore(env.db)
 
-			layerMT := schema2.MediaTypeLayer
+		dbManifest := &models.Manifest{
+			NamespaceID:   dbRepo.NamespaceID,
+			RepositoryID:  dbRepo.ID,
+			SchemaVersion: 1,
+			MediaType:     schema1.MediaTypeManifest,
+			Digest:        preseededSchema1Digest,
+			Payload:       models.Payload{},
+		}
 
-			if test.unknownLayerMediaType {
-				layerMT = unknownMediaType
-			}
+		err = mStore.Create(env.ctx, dbManifest)
+		require.NoError(t, err)
 
-			manifest.Layers[0] = distribution.Descriptor{
-				Digest:    dgst,
-				MediaType: layerMT,
-				Size:      size,
-			}
+		tagStore := datastore.NewTagStore(env.db)
 
-			deserializedManifest, err := schema2.FromStruct(*manifest)
-			require.NoError(t, err)
+		dbTag := &models.Tag{
+			Name:         preseededSchema1TagName,
+			NamespaceID:  dbRepo.NamespaceID,
+			RepositoryID: dbRepo.ID,
+			ManifestID:   dbManifest.ID,
+		}
 
-			// Build URLs.
-			tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+		err = tagStore.CreateOrUpdate(env.ctx, dbTag)
+		require.NoError(t, err)
+	}
 
-			resp := putManifest(t, "putting manifest", tagURL, schema2.MediaTypeManifest, deserializedManifest.Manifest)
-			defer resp.Body.Close()
+	// Build URLs.
+	tagURL := buildManifestTagURL(t, env, preseededSchema1RepoPath, preseededSchema1TagName)
 
-			require.Equal(t, http.StatusCreated, resp.StatusCode)
+	repoRef, err := reference.WithName(preseededSchema1RepoPath)
+	require.NoError(t, err)
 
-			// Check layer media type
-			ctx := context.Background()
-			rStore := datastore.NewRepositoryStore(env.db)
-			mStore := datastore.NewManifestStore(env.db)
-			bStore := datastore.NewBlobStore(env.db)
+	digestRef, err := reference.WithDigest(repoRef, preseededSchema1Digest)
+	require.NoError(t, err)
 
-			r, err := rStore.FindByPath(ctx, repoPath)
-			require.NoError(t, err)
-			require.NotNil(t, r)
+	digestURL, err := env.builder.BuildManifestURL(digestRef)
+	require.NoError(t, err)
 
-			dbMfst, err := rStore.FindManifestByTagName(ctx, r, tagName)
-			require.NoError(t, err)
-			require.NotNil(t, dbMfst)
+	tt := []struct {
+		name        string
+		manifestURL string
+		etag        string
+	}{
+		{
+			name:        "by tag",
+			manifestURL: tagURL,
+		},
+		{
+			name:        "by digest",
+			manifestURL: digestURL,
+		},
+	}
 
-			dbLayers, err := mStore.LayerBlobs(ctx, dbMfst)
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.manifestURL, nil)
 			require.NoError(t, err)
-			require.NotNil(t, dbLayers)
-			require.Len(t, dbLayers, 1)
-
-			wantMT := layerMT
-
-			if test.unknownLayerMediaType {
-				wantMT = genericBlobMediaType
-			}
-
-			require.Equal(t, wantMT, dbLayers[0].MediaType)
 
-			// Ensure underlying blob media type is always generic.
-			rBlob, err := rStore.FindBlob(ctx, r, dgst)
+			resp, err := http.DefaultClient.Do(req)
 			require.NoError(t, err)
-			require.NotNil(t, rBlob)
-			require.Equal(t, genericBlobMediaType, rBlob.MediaType)
+			defer resp.Body.Close()
 
-			dbBlob, err := bStore.FindByDigest(ctx, dgst)
-			require.NoError(t, err)
-			require.NotNil(t, dbBlob)
-			require.Equal(t, genericBlobMediaType, dbBlob.MediaType)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "invalid manifest", resp, v2.ErrorCodeManifestInvalid)
 		})
 	}
 }
 
-func TestManifestAPI_Put_Schema2LayersNotAssociatedWithRepositoryButArePresentInDatabase(t *testing.T) {
-	env := newTestEnv(t)
-	defer env.Shutdown()
-
-	tagName := "schema2missinglayerstag"
-	repoPath := "schema2/missinglayers"
-
-	if !env.config.Database.Enabled {
-		t.Skip("skipping test because the metadata database is not enabled")
-	}
-
-	repoRef, err := reference.WithName(repoPath)
-	require.NoError(t, err)
+func TestManifestAPI_Get_Schema1_ConvertOnRead(t *testing.T) {
+	env := newTestEnv(t, withSchema1PreseededInMemoryDriver, withSchema1ConvertOnRead)
+	defer env.Shutdown()
 
-	manifest := &schema2.Manifest{
-		Versioned: manifest.Versioned{
-			SchemaVersion: 2,
-			MediaType:     schema2.MediaTypeManifest,
-		},
+	if env.config.Database.Enabled {
+		t.Skip("schema 1 manifests are only converted for repositories whose metadata is stored in the filesystem")
 	}
 
-	// Create a manifest config and push up its content.
-	cfgPayload, cfgDesc := schema2Config()
-	uploadURLBase, _ := startPushLayer(t, env, repoRef)
-	pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
-	manifest.Config = cfgDesc
-
-	// Create and push up 2 random layers to an unrelated repo so that they are
-	// present within the database, but not associated with the manifest's repository.
-	// Then push them to the normal repository with the database disabled.
-	manifest.Layers = make([]distribution.Descriptor, 2)
+	tagURL := buildManifestTagURL(t, env, preseededConvertibleSchema1RepoPath, preseededConvertibleSchema1TagName)
 
-	fakeRepoRef, err := reference.WithName("fakerepo")
+	repoRef, err := reference.WithName(preseededConvertibleSchema1RepoPath)
+	require.NoError(t, err)
+	digestRef, err := reference.WithDigest(repoRef, preseededConvertibleSchema1Digest)
+	require.NoError(t, err)
+	digestURL, err := env.builder.BuildManifestURL(digestRef)
 	require.NoError(t, err)
 
-	for i := range manifest.Layers {
-		rs, dgst, size := createRandomSmallLayer()
-
-		// Save the layer content as pushLayer exhausts the io.ReadSeeker
-		layerBytes, err := io.ReadAll(rs)
+	getManifest := func(t *testing.T, u string, accept string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
 		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
 
-		uploadURLBase, _ := startPushLayer(t, env, fakeRepoRef)
-		pushLayer(t, env.builder, fakeRepoRef, dgst, uploadURLBase, bytes.NewReader(layerBytes))
+	// pulls by tag from clients accepting schema 2 manifests are converted
+	for i := 0; i < 2; i++ {
+		resp := getManifest(t, tagURL, schema2.MediaTypeManifest)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, schema2.MediaTypeManifest, resp.Header.Get("Content-Type"))
 
-		// Disable the database so writes only go to the filesytem.
-		env.config.Database.Enabled = false
+		p, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, digest.FromBytes(p).String(), resp.Header.Get("Docker-Content-Digest"))
 
-		uploadURLBase, _ = startPushLayer(t, env, repoRef)
-		pushLayer(t, env.builder, repoRef, dgst, uploadURLBase, bytes.NewReader(layerBytes))
+		var m schema2.Manifest
+		require.NoError(t, json.Unmarshal(p, &m))
+		require.Len(t, m.Layers, 1)
+		require.Equal(t, preseededConvertibleSchema1LayerDigest, m.Layers[0].Digest)
+		require.Equal(t, schema2.MediaTypeLayer, m.Layers[0].MediaType)
+		require.Equal(t, schema2.MediaTypeImageConfig, m.Config.MediaType)
 
-		// Enable the database again so that reads first check the database.
-		env.config.Database.Enabled = true
+		// the image configuration can be pulled from the repository
+		configRef, err := reference.WithDigest(repoRef, m.Config.Digest)
+		require.NoError(t, err)
+		configURL, err := env.builder.BuildBlobURL(configRef)
+		require.NoError(t, err)
 
-		manifest.Layers[i] = distribution.Descriptor{
-			Digest:    dgst,
-			MediaType: schema2.MediaTypeLayer,
-			Size:      size,
+		configResp, err := http.Get(configURL)
+		require.NoError(t, err)
+		defer configResp.Body.Close()
+		require.Equal(t, http.StatusOK, configResp.StatusCode)
+
+		var config struct {
+			Architecture string `json:"architecture"`
+			RootFS       struct {
+				DiffIDs []digest.Digest `json:"diff_ids"`
+			} `json:"rootfs"`
 		}
+		require.NoError(t, json.NewDecoder(configResp.Body).Decode(&config))
+		require.Equal(t, "amd64", config.Architecture)
+		require.Equal(t, []digest.Digest{preseededConvertibleSchema1DiffID}, config.RootFS.DiffIDs)
 	}
 
-	deserializedManifest, err := schema2.FromStruct(*manifest)
-	require.NoError(t, err)
+	// clients not accepting schema 2 manifests and pulls by digest are still rejected
+	tt := []struct {
+		name        string
+		manifestURL string
+		accept      string
+	}{
+		{name: "by tag without schema 2 accept header", manifestURL: tagURL},
+		{name: "by digest", manifestURL: digestURL, accept: schema2.MediaTypeManifest},
+	}
 
-	// Build URLs.
-	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			resp := getManifest(t, test.manifestURL, test.accept)
+			defer resp.Body.Close()
 
-	resp := putManifest(t, "putting manifest, layers not associated with repository", tagURL, schema2.MediaTypeManifest, deserializedManifest.Manifest)
-	defer resp.Body.Close()
-	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			checkBodyHasErrorCodes(t, "invalid manifest", resp, v2.ErrorCodeManifestInvalid)
+		})
+	}
 }
 
-// TestManifestAPI_BuildkitIndex tests that the API will accept pushes and pulls of Buildkit cache image index.
-// Related to https://gitlab.com/gitlab-org/container-registry/-/issues/407.
-func TestManifestAPI_BuildkitIndex(t *testing.T) {
-	env := newTestEnv(t)
+func TestManifestAPI_Get_ManifestCache(t *testing.T) {
+	skipDatabaseNotEnabled(t)
+
+	redisSrv := internaltestutil.RedisServer(t)
+	env := newTestEnv(t, withDelete, withRedisCache(redisSrv.Addr()), func(config *configuration.Configuration) {
+		config.Redis.Cache.Manifests.Enabled = true
+		config.Redis.Cache.Manifests.TTL = time.Hour
+	})
 	defer env.Shutdown()
 
+	repoPath := "cache/manifests"
 	tagName := "latest"
-	repoPath := "cache"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
 
-	// Create and push config
-	cfgPayload := `{"layers":[{"blob":"sha256:136482bf81d1fa351b424ebb8c7e34d15f2c5ed3fc0b66b544b8312bda3d52d9","parent":-1},{"blob":"sha256:cc28e5fb26aec14963e8cf2987c137b84755a031068ea9284631a308dc087b35"}],"records":[{"digest":"sha256:16a28dbbe0151c1ab102d9414f78aa338627df3ce3c450905cd36d41b3e3d08e"},{"digest":"sha256:ef9770ef24f7942c1ccbbcac2235d9c0fbafc80d3af78ca0b483886adeac8960"}]}`
-	cfgDesc := distribution.Descriptor{
-		MediaType: mlcompat.MediaTypeBuildxCacheConfig,
-		Digest:    digest.FromString(cfgPayload),
-		Size:      int64(len(cfgPayload)),
-	}
-	assertBlobPutResponse(t, env, repoPath, cfgDesc.Digest, strings.NewReader(cfgPayload), 201)
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	digestURL := buildManifestDigestURL(t, env, repoPath, m)
 
-	// Create and push 2 random layers
-	layers := make([]distribution.Descriptor, 2)
-	for i := range layers {
-		rs, dgst, size := createRandomSmallLayer()
-		assertBlobPutResponse(t, env, repoPath, dgst, rs, 201)
+	rStore := datastore.NewRepositoryStore(env.db)
+	r, err := rStore.FindByPath(env.ctx, repoPath)
+	require.NoError(t, err)
+	repoKey := "registry:db:{repository:cache:" + digest.FromString(repoPath).Hex() + "}"
+	tagKey := fmt.Sprintf("%s:tag:%d:%s", repoKey, r.ID, tagName)
+	manifestKey := repoKey + ":manifest:" + dgst.String()
 
-		layers[i] = distribution.Descriptor{
-			MediaType: v1.MediaTypeImageLayerGzip,
-			Digest:    dgst,
-			Size:      size,
-			Annotations: map[string]string{
-				"buildkit/createdat":         time.Now().String(),
-				"containerd.io/uncompressed": digest.FromString(strconv.Itoa(i)).String(),
-			},
+	assertGet := func(t *testing.T, u string, wantStatus int) {
+		t.Helper()
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, wantStatus, resp.StatusCode)
+		if wantStatus == http.StatusOK {
+			require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
 		}
 	}
 
-	idx := &manifestlist.ManifestList{
-		Versioned: manifest.Versioned{
-			SchemaVersion: 2,
-			MediaType:     v1.MediaTypeImageIndex,
-		},
-		Manifests: []manifestlist.ManifestDescriptor{
-			{Descriptor: layers[0]},
-			{Descriptor: layers[1]},
-			{Descriptor: cfgDesc},
-		},
-	}
+	// pulling by tag caches the tag and manifest
+	assertGet(t, tagURL, http.StatusOK)
+	require.True(t, redisSrv.Exists(tagKey))
+	require.True(t, redisSrv.Exists(manifestKey))
+	require.Equal(t, time.Hour, redisSrv.TTL(manifestKey))
 
-	didx, err := manifestlist.FromDescriptorsWithMediaType(idx.Manifests, v1.MediaTypeImageIndex)
-	require.NoError(t, err)
-	_, payload, err := didx.Payload()
+	// subsequent pulls are served from the cache, even if the tag is deleted from the database behind its back
+	found, err := rStore.DeleteTagByName(env.ctx, r, tagName)
 	require.NoError(t, err)
-	dgst := digest.FromBytes(payload)
+	require.True(t, found)
+	assertGet(t, tagURL, http.StatusOK)
 
-	// Push index
-	assertManifestPutByTagResponse(t, env, repoPath, didx, v1.MediaTypeImageIndex, tagName, 201)
+	// tagging invalidates the cached tag
+	resp := putManifest(t, "putting manifest by tag", tagURL, schema2.MediaTypeManifest, m.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.False(t, redisSrv.Exists(tagKey))
+	assertGet(t, tagURL, http.StatusOK)
+	require.True(t, redisSrv.Exists(tagKey))
 
-	// Get index
-	u := buildManifestTagURL(t, env, repoPath, tagName)
-	req, err := http.NewRequest(http.MethodGet, u, nil)
+	// deleting a tag invalidates the cached tag
+	resp, err = httpDelete(tagURL)
 	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.False(t, redisSrv.Exists(tagKey))
+	assertGet(t, tagURL, http.StatusNotFound)
 
-	req.Header.Set("Accept", v1.MediaTypeImageIndex)
-	resp, err := http.DefaultClient.Do(req)
+	// deleting a manifest invalidates the cached manifest
+	assertGet(t, digestURL, http.StatusOK)
+	require.True(t, redisSrv.Exists(manifestKey))
+	resp, err = httpDelete(digestURL)
 	require.NoError(t, err)
 	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.False(t, redisSrv.Exists(manifestKey))
+	assertGet(t, digestURL, http.StatusNotFound)
+}
 
-	require.Equal(t, http.StatusOK, resp.StatusCode)
-	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+func TestManifestAPI_Get_ManifestCache_ConditionalRequestWithoutCachedManifest(t *testing.T) {
+	skipDatabaseNotEnabled(t)
 
-	var respIdx *manifestlist.DeserializedManifestList
-	dec := json.NewDecoder(resp.Body)
-	err = dec.Decode(&respIdx)
+	redisSrv := internaltestutil.RedisServer(t)
+	env := newTestEnv(t, withRedisCache(redisSrv.Addr()), func(config *configuration.Configuration) {
+		config.Redis.Cache.Manifests.Enabled = true
+		config.Redis.Cache.Manifests.TTL = time.Hour
+	})
+	defer env.Shutdown()
+
+	repoPath := "cache/conditional"
+	tagName := "latest"
+	m := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+	_, payload, err := m.Payload()
 	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
 
-	require.EqualValues(t, didx, respIdx)
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
+	resp, err := http.Get(tagURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
 
-	// Stat each one of its references
-	for _, d := range didx.References() {
-		assertBlobHeadResponse(t, env, repoPath, d.Digest, 200)
-	}
+	// evict the cached manifest and delete the tag from the database behind the cache's back, leaving the tag cached
+	repoKey := "registry:db:{repository:cache:" + digest.FromString(repoPath).Hex() + "}"
+	require.True(t, redisSrv.Del(repoKey+":manifest:"+dgst.String()))
+	rStore := datastore.NewRepositoryStore(env.db)
+	r, err := rStore.FindByPath(env.ctx, repoPath)
+	require.NoError(t, err)
+	found, err := rStore.DeleteTagByName(env.ctx, r, tagName)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// the cached tag digest alone is not enough to answer a conditional request
+	req, err := http.NewRequest(http.MethodGet, tagURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", fmt.Sprintf("%q", dgst))
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
-func TestManifestAPI_OCIIndexNoMediaType(t *testing.T) {
-	env := newTestEnv(t)
+func TestManifestAPI_Delete_ManifestCacheReferrers(t *testing.T) {
+	skipDatabaseNotEnabled(t)
+
+	redisSrv := internaltestutil.RedisServer(t)
+	env := newTestEnv(t, withDelete, withRedisCache(redisSrv.Addr()), func(config *configuration.Configuration) {
+		config.Redis.Cache.Manifests.Enabled = true
+		config.Redis.Cache.Manifests.TTL = time.Hour
+	})
 	defer env.Shutdown()
 
-	repoRef, err := reference.WithName("foo")
+	repoPath := "cache/referrers"
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	referrer := seedRandomOCIManifest(t, env, repoPath, putByTag("sbom"), withSubject(subject))
+	_, payload, err := referrer.Payload()
 	require.NoError(t, err)
+	referrerDgst := digest.FromBytes(payload)
 
-	tag := "latest"
-	tagRef, err := reference.WithTag(repoRef, tag)
+	tagURL := buildManifestTagURL(t, env, repoPath, "sbom")
+	resp, err := http.Get(tagURL)
 	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
 
-	sentIndex := seedRandomOCIImageIndex(t, env, repoRef.Name(), putByTag(tag), withoutMediaType)
-
-	manifestURL, err := env.builder.BuildManifestURL(tagRef)
+	r, err := datastore.NewRepositoryStore(env.db).FindByPath(env.ctx, repoPath)
 	require.NoError(t, err)
-
-	req, err := http.NewRequest(http.MethodGet, manifestURL, nil)
+	repoKey := "registry:db:{repository:cache:" + digest.FromString(repoPath).Hex() + "}"
+	tagKey := fmt.Sprintf("%s:tag:%d:sbom", repoKey, r.ID)
+	manifestKey := repoKey + ":manifest:" + referrerDgst.String()
+	require.True(t, redisSrv.Exists(tagKey))
+	require.True(t, redisSrv.Exists(manifestKey))
+
+	// the database deletes the referrer along with its subject, so its cached manifest and tag must be invalidated
+	resp, err = httpDelete(buildManifestDigestURL(t, env, repoPath, subject))
 	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.False(t, redisSrv.Exists(tagKey))
+	require.False(t, redisSrv.Exists(manifestKey))
 
-	// v1.MediaTypeImageIndex would be enough, but this replicates the behavior of the Docker client and others
-	req.Header.Set("Accept", schema2.MediaTypeManifest)
-	req.Header.Add("Accept", v1.MediaTypeImageManifest)
-	req.Header.Add("Accept", manifestlist.MediaTypeManifestList)
-	req.Header.Add("Accept", v1.MediaTypeImageIndex)
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err = http.Get(tagURL)
 	require.NoError(t, err)
 	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
 
-	checkResponse(t, "", resp, http.StatusOK)
+func TestManifestAPI_Put_OCISubjectHeader(t *testing.T) {
+	skipDatabaseNotEnabled(t)
 
-	// ensure content-type header is properly set and the digest matches the one we know
-	_, payload, err := sentIndex.Payload()
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	repoPath := "referrers/header"
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	_, payload, err := subject.Payload()
 	require.NoError(t, err)
-	dgst := digest.FromBytes(payload)
 
-	checkHeaders(t, resp, http.Header{
-		"Content-Type":          []string{v1.MediaTypeImageIndex},
-		"Docker-Content-Digest": []string{dgst.String()},
-	})
+	put := func(t *testing.T, m *ocischema.DeserializedManifest) *http.Response {
+		t.Helper()
 
-	// ensure payload matches the one sent and double-check that the mediaType field is not filled
-	var fetchedIndex *manifestlist.DeserializedManifestList
-	err = json.NewDecoder(resp.Body).Decode(&fetchedIndex)
-	require.NoError(t, err)
+		resp := putManifest(t, "putting manifest", buildManifestDigestURL(t, env, repoPath, m), v1.MediaTypeImageManifest, m)
+		t.Cleanup(func() { resp.Body.Close() })
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		return resp
+	}
 
-	require.EqualValues(t, sentIndex, fetchedIndex)
-	require.Empty(t, fetchedIndex.MediaType)
+	// manifests with a subject
+	referrer := seedRandomOCIManifest(t, env, repoPath, withSubject(subject))
+	resp := put(t, referrer)
+	require.Equal(t, digest.FromBytes(payload).String(), resp.Header.Get("OCI-Subject"))
+
+	// manifests without a subject
+	resp = put(t, subject)
+	require.Empty(t, resp.Header.Values("OCI-Subject"))
 }
 
-// TestManifestAPI_ManifestListWithLayerReferences tests that the API will not
-// accept pushes and pulls of non Buildkit cache image manifest lists which
-// reference blobs.
-// Related to https://gitlab.com/gitlab-org/container-registry/-/issues/407.
-func TestManifestAPI_ManifestListWithLayerReferences(t *testing.T) {
-	env := newTestEnv(t)
+func TestManifestAPI_Put_ReferrersTagSchema(t *testing.T) {
+	skipDatabaseNotEnabled(t)
+
+	env := newTestEnv(t, withDelete)
 	defer env.Shutdown()
 
-	tagName := "latest"
-	repoPath := "malformed-manifestlist"
+	repoPath := "referrers/tag-schema"
+	subject := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	// a referrer pushed by a client that does not set the subject field, and another one that does
+	legacy := seedRandomOCIManifest(t, env, repoPath, putByDigest)
+	native := seedRandomOCIManifest(t, env, repoPath, putByDigest, withSubject(subject))
+	unrelated := seedRandomOCIManifest(t, env, repoPath, putByDigest)
 
-	// Create and push 2 random layers
-	layers := make([]distribution.Descriptor, 2)
-	for i := range layers {
-		rs, dgst, size := createRandomSmallLayer()
-		assertBlobPutResponse(t, env, repoPath, dgst, rs, 201)
+	digestOf := func(m distribution.Manifest) digest.Digest {
+		_, payload, err := m.Payload()
+		require.NoError(t, err)
+		return digest.FromBytes(payload)
+	}
 
-		layers[i] = distribution.Descriptor{
-			MediaType: v1.MediaTypeImageLayerGzip,
-			Digest:    dgst,
-			Size:      size,
-		}
+	var descriptors []manifestlist.ManifestDescriptor
+	for _, m := range []*ocischema.DeserializedManifest{legacy, native} {
+		descriptors = append(descriptors, manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{Digest: digestOf(m), MediaType: v1.MediaTypeImageManifest},
+		})
+	}
+	index, err := manifestlist.FromDescriptorsWithMediaType(descriptors, v1.MediaTypeImageIndex)
+	require.NoError(t, err)
+
+	// the referrers tag schema encodes the subject digest in the tag name
+	tagURL := buildManifestTagURL(t, env, repoPath, "sha256-"+digestOf(subject).Encoded())
+	resp := putManifest(t, "putting referrers tag schema index", tagURL, v1.MediaTypeImageIndex, index)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// both referrers are now found as such
+	rStore := datastore.NewRepositoryStore(env.db)
+	r, err := rStore.FindByPath(env.ctx, repoPath)
+	require.NoError(t, err)
+	dbSubject, err := rStore.FindManifestByDigest(env.ctx, r, digestOf(subject))
+	require.NoError(t, err)
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	ref, err := reference.WithDigest(repoRef, digestOf(subject))
+	require.NoError(t, err)
+	u, err := env.builder.BuildReferrersURL(ref)
+	require.NoError(t, err)
+	resp, err = http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body v1.Index
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	var referrers []digest.Digest
+	for _, desc := range body.Manifests {
+		referrers = append(referrers, desc.Digest)
 	}
+	require.ElementsMatch(t, []digest.Digest{digestOf(legacy), digestOf(native)}, referrers)
+	require.NotContains(t, referrers, digestOf(unrelated))
+
+	// the subject of the legacy referrer, which reflects its payload, is left untouched
+	dbLegacy, err := rStore.FindManifestByDigest(env.ctx, r, digestOf(legacy))
+	require.NoError(t, err)
+	require.False(t, dbLegacy.SubjectID.Valid)
+	mm, err := rStore.ManifestReferrers(env.ctx, r, dbSubject)
+	require.NoError(t, err)
+	require.Len(t, mm, 1)
+	require.Equal(t, digestOf(native), mm[0].Digest)
+
+	// so deleting the subject only cascades to the referrer that declares it
+	resp, err = httpDelete(buildManifestDigestURL(t, env, repoPath, subject))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	dbNative, err := rStore.FindManifestByDigest(env.ctx, r, digestOf(native))
+	require.NoError(t, err)
+	require.Nil(t, dbNative)
+	dbLegacy, err = rStore.FindManifestByDigest(env.ctx, r, digestOf(legacy))
+	require.NoError(t, err)
+	require.NotNil(t, dbLegacy)
+}
+
+func TestManifestAPI_TagPrecondition(t *testing.T) {
+	env := newTestEnv(t, withDelete)
+	defer env.Shutdown()
 
-	idx := &manifestlist.ManifestList{
-		Versioned: manifest.Versioned{
-			SchemaVersion: 2,
-			MediaType:     manifestlist.MediaTypeManifestList,
-		},
-		Manifests: []manifestlist.ManifestDescriptor{
-			{Descriptor: layers[0]},
-			{Descriptor: layers[1]},
-		},
+	tagName := "latest"
+	repoPath := "tag/precondition"
+
+	digestOf := func(m distribution.Manifest) digest.Digest {
+		_, payload, err := m.Payload()
+		require.NoError(t, err)
+		return digest.FromBytes(payload)
 	}
 
-	didx, err := manifestlist.FromDescriptorsWithMediaType(idx.Manifests, manifestlist.MediaTypeManifestList)
-	require.NoError(t, err)
+	m1 := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tagName))
+	m2 := seedRandomSchema2Manifest(t, env, repoPath, putByDigest)
+	tagURL := buildManifestTagURL(t, env, repoPath, tagName)
 
-	// Push index, since there is no buildx config layer, we should reject the push as invalid.
-	assertManifestPutByTagResponse(t, env, repoPath, didx, manifestlist.MediaTypeManifestList, tagName, 400)
-	manifestDigestURL := buildManifestDigestURL(t, env, repoPath, didx)
+	// the tag is not updated if it does not point to the expected manifest
+	resp := putManifest(t, "putting manifest by tag", tagURL, schema2.MediaTypeManifest, m2, withIfMatchHeader(digestOf(m2).String()))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "putting manifest by tag", resp, v2.ErrorCodeTagPreconditionFailed)
 
-	resp := putManifest(t, "putting manifest list bad request", manifestDigestURL, manifestlist.MediaTypeManifestList, didx)
+	resp = putManifest(t, "putting manifest by tag", tagURL, schema2.MediaTypeManifest, m2, withIfMatchHeader(`"`+digestOf(m1).String()+`"`))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// invalid digests are rejected
+	resp = putManifest(t, "putting manifest by tag", tagURL, schema2.MediaTypeManifest, m2, withIfMatchHeader("foo"))
 	defer resp.Body.Close()
 	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "putting manifest by tag", resp, v2.ErrorCodeDigestInvalid)
 
-	_, p, counts := checkBodyHasErrorCodes(t, "manifest list with layer blobs", resp, v2.ErrorCodeManifestBlobUnknown)
-	expectedCounts := map[errcode.ErrorCode]int{v2.ErrorCodeManifestBlobUnknown: 2}
-	require.EqualValuesf(t, expectedCounts, counts, "response body: %s", p)
+	// the tag is not deleted if it does not point to the expected manifest
+	req, err := http.NewRequest(http.MethodDelete, tagURL, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(newRequest(req, withIfMatchHeader(digestOf(m1).String())))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "deleting tag", resp, v2.ErrorCodeTagPreconditionFailed)
+
+	req, err = http.NewRequest(http.MethodDelete, tagURL, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(newRequest(req, withIfMatchHeader(digestOf(m1).String()+", "+digestOf(m2).String())))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// a tag that does not exist never satisfies a precondition
+	req, err = http.NewRequest(http.MethodDelete, tagURL, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(newRequest(req, withIfMatchHeader("*")))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// the header is ignored when pushing by digest
+	resp = putManifest(t, "putting manifest by digest", buildManifestDigestURL(t, env, repoPath, m1), schema2.MediaTypeManifest, m1, withIfMatchHeader("*"))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
 }
 
-func TestManifestAPI_Get_Schema1(t *testing.T) {
-	env := newTestEnv(t, withSchema1PreseededInMemoryDriver)
+func TestManifestAPI_TagProtection(t *testing.T) {
+	rootDir := t.TempDir()
+	env := newTestEnv(t, withDelete, withFSDriver(rootDir))
 	defer env.Shutdown()
 
-	// Seed manifest in database directly since schema1 manifests are unpushable.
-	if env.config.Database.Enabled {
-		repositoryStore := datastore.NewRepositoryStore(env.db)
-		dbRepo, err := repositoryStore.CreateByPath(env.ctx, preseededSchema1RepoPath)
-
-		mStore := datastore.NewManifestStore(env.db)
+	repoPath := "tag/protection"
+	m1 := seedRandomSchema2Manifest(t, env, repoPath, putByTag("v1"))
+	m2 := seedRandomSchema2Manifest(t, env, repoPath, putByTag("latest"))
 
-		dbManifest := &models.Manifest{
-			NamespaceID:   dbRepo.NamespaceID,
-			RepositoryID:  dbRepo.ID,
-			SchemaVersion: 1,
-			MediaType:     schema1.MediaTypeManifest,
-			Digest:        preseededSchema1Digest,
-			Payload:       models.Payload{},
-		}
+	tokenProvider := NewAuthTokenProvider(t)
+	env = newTestEnv(t, withDelete, withFSDriver(rootDir), withTokenAuth(tokenProvider.CertPath(), defaultIssuerProps()))
+	defer env.Shutdown()
+	authOpt := witAuthToken(tokenProvider.TokenWithActions(protectedTagsAccessToken(repoPath, "^v[0-9]")))
 
-		err = mStore.Create(env.ctx, dbManifest)
+	deleteURL := func(u string) *http.Response {
+		req, err := http.NewRequest(http.MethodDelete, u, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(newRequest(req, authOpt))
 		require.NoError(t, err)
+		return resp
+	}
 
-		tagStore := datastore.NewTagStore(env.db)
+	// protected tags can't be pushed
+	resp := putManifest(t, "putting manifest by tag", buildManifestTagURL(t, env, repoPath, "v2"), schema2.MediaTypeManifest, m2, authOpt)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "putting manifest by tag", resp, v2.ErrorCodeTagProtected)
 
-		dbTag := &models.Tag{
-			Name:         preseededSchema1TagName,
-			NamespaceID:  dbRepo.NamespaceID,
-			RepositoryID: dbRepo.ID,
-			ManifestID:   dbManifest.ID,
-		}
+	resp = putManifest(t, "putting manifest by tag", buildManifestTagURL(t, env, repoPath, "stable"), schema2.MediaTypeManifest, m2, authOpt)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
 
-		err = tagStore.CreateOrUpdate(env.ctx, dbTag)
-		require.NoError(t, err)
-	}
+	// protected tags can't be deleted, neither directly nor along with the manifest they point to
+	resp = deleteURL(buildManifestTagURL(t, env, repoPath, "v1"))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "deleting tag", resp, v2.ErrorCodeTagProtected)
 
-	// Build URLs.
-	tagURL := buildManifestTagURL(t, env, preseededSchema1RepoPath, preseededSchema1TagName)
+	resp = deleteURL(buildManifestDigestURL(t, env, repoPath, m1))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "deleting manifest", resp, v2.ErrorCodeTagProtected)
 
-	repoRef, err := reference.WithName(preseededSchema1RepoPath)
-	require.NoError(t, err)
+	resp = deleteURL(buildManifestTagURL(t, env, repoPath, "stable"))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
-	digestRef, err := reference.WithDigest(repoRef, preseededSchema1Digest)
-	require.NoError(t, err)
+	resp = deleteURL(buildManifestDigestURL(t, env, repoPath, m2))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
-	digestURL, err := env.builder.BuildManifestURL(digestRef)
+	// the protected tag is left untouched
+	req, err := http.NewRequest(http.MethodHead, buildManifestTagURL(t, env, repoPath, "v1"), nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(newRequest(req, authOpt))
 	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
 
-	tt := []struct {
-		name        string
-		manifestURL string
-		etag        string
-	}{
-		{
-			name:        "by tag",
-			manifestURL: tagURL,
-		},
-		{
-			name:        "by digest",
-			manifestURL: digestURL,
-		},
-	}
+func TestManifestAPI_TagProtection_Referrers(t *testing.T) {
+	skipDatabaseNotEnabled(t)
 
-	for _, test := range tt {
-		t.Run(test.name, func(t *testing.T) {
-			req, err := http.NewRequest(http.MethodGet, test.manifestURL, nil)
-			require.NoError(t, err)
+	env := newTestEnv(t, withDelete)
+	defer env.Shutdown()
 
-			resp, err := http.DefaultClient.Do(req)
-			require.NoError(t, err)
-			defer resp.Body.Close()
+	repoPath := "tag/protection-referrers"
+	subject := seedRandomOCIManifest(t, env, repoPath, putByTag("latest"))
+	seedRandomOCIManifest(t, env, repoPath, putByTag("v1-sbom"), withSubject(subject))
 
-			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
-			checkBodyHasErrorCodes(t, "invalid manifest", resp, v2.ErrorCodeManifestInvalid)
-		})
+	tokenProvider := NewAuthTokenProvider(t)
+	authEnv := newTestEnv(t, withDelete, withTokenAuth(tokenProvider.CertPath(), defaultIssuerProps()))
+	defer authEnv.Shutdown()
+	authOpt := witAuthToken(tokenProvider.TokenWithActions(protectedTagsAccessToken(repoPath, "^v[0-9]")))
+
+	// referrers are deleted along with their subject, so the subject can't be deleted if their tags are protected
+	req, err := http.NewRequest(http.MethodDelete, buildManifestDigestURL(t, authEnv, repoPath, subject), nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(newRequest(req, authOpt))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "deleting manifest", resp, v2.ErrorCodeTagProtected)
+
+	for _, tag := range []string{"latest", "v1-sbom"} {
+		resp, err := http.Head(buildManifestTagURL(t, env, repoPath, tag))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, tag)
 	}
 }
 
@@ -1339,6 +2590,140 @@ func TestManifestAPI_Put_DatabaseEnabled_InvalidConfigMediaType(t *testing.T) {
 	require.Equal(t, datastore.ErrUnknownMediaType{MediaType: unknownMediaType}.Error(), errc.Detail)
 }
 
+func TestManifestAPI_Put_DatabaseEnabled_OCIArtifact(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	tagName := "sbom"
+	repoPath := "artifacts"
+
+	// Create and push an empty config with an unknown media type
+	cfgPayload := `{}`
+	cfgDesc := distribution.Descriptor{
+		MediaType: "application/vnd.oci.empty.v1+json",
+		Digest:    digest.FromString(cfgPayload),
+		Size:      int64(len(cfgPayload)),
+	}
+	assertBlobPutResponse(t, env, repoPath, cfgDesc.Digest, strings.NewReader(cfgPayload), 201)
+
+	// Create and push 1 random layer
+	rs, dgst, size := createRandomSmallLayer()
+	assertBlobPutResponse(t, env, repoPath, dgst, rs, 201)
+	layerDesc := distribution.Descriptor{
+		MediaType: "application/spdx+json",
+		Digest:    dgst,
+		Size:      size,
+	}
+
+	m := ocischema.Manifest{
+		Versioned:    ocischema.SchemaVersion,
+		ArtifactType: "application/vnd.example.sbom.v1",
+		Config:       cfgDesc,
+		Layers:       []distribution.Descriptor{layerDesc},
+	}
+
+	dm, err := ocischema.FromStruct(m)
+	require.NoError(t, err)
+
+	// Push artifact
+	u := buildManifestTagURL(t, env, repoPath, tagName)
+	resp := putManifest(t, "", u, v1.MediaTypeImageManifest, dm.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// Pull artifact, the payload must be preserved
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", v1.MediaTypeImageManifest)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var fetched ocischema.DeserializedManifest
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&fetched))
+	require.Equal(t, m.ArtifactType, fetched.ArtifactType())
+}
+
+func TestManifestAPI_Put_DatabaseEnabled_OCIArtifact_InvalidArtifactType(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "artifacts"
+
+	cfgPayload := `{}`
+	cfgDesc := distribution.Descriptor{
+		MediaType: "application/vnd.oci.empty.v1+json",
+		Digest:    digest.FromString(cfgPayload),
+		Size:      int64(len(cfgPayload)),
+	}
+	assertBlobPutResponse(t, env, repoPath, cfgDesc.Digest, strings.NewReader(cfgPayload), 201)
+
+	m := ocischema.Manifest{
+		Versioned:    ocischema.SchemaVersion,
+		ArtifactType: "not a media type",
+		Config:       cfgDesc,
+		Layers:       []distribution.Descriptor{},
+	}
+
+	dm, err := ocischema.FromStruct(m)
+	require.NoError(t, err)
+
+	u := buildManifestTagURL(t, env, repoPath, "latest")
+	resp := putManifest(t, "", u, v1.MediaTypeImageManifest, dm.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeManifestInvalid)
+}
+
+func TestManifestAPI_Put_DatabaseEnabled_OCIArtifact_UnknownArtifactTypeNotAllowed(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.Shutdown()
+
+	if !env.config.Database.Enabled {
+		t.Skip("skipping test because the metadata database is not enabled")
+	}
+
+	repoPath := "artifacts"
+
+	cfgPayload := `{}`
+	cfgDesc := distribution.Descriptor{
+		MediaType: "application/vnd.oci.empty.v1+json",
+		Digest:    digest.FromString(cfgPayload),
+		Size:      int64(len(cfgPayload)),
+	}
+	assertBlobPutResponse(t, env, repoPath, cfgDesc.Digest, strings.NewReader(cfgPayload), 201)
+
+	m := ocischema.Manifest{
+		Versioned: ocischema.SchemaVersion,
+		// valid but not matching any of the allowed patterns
+		ArtifactType: "application/vnd.unknown.artifact.v1",
+		Config:       cfgDesc,
+		Layers:       []distribution.Descriptor{},
+	}
+
+	dm, err := ocischema.FromStruct(m)
+	require.NoError(t, err)
+
+	u := buildManifestTagURL(t, env, repoPath, "latest")
+	resp := putManifest(t, "", u, v1.MediaTypeImageManifest, dm.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeManifestInvalid)
+
+	exists, err := datastore.NewMediaTypeStore(env.db).Exists(context.Background(), m.ArtifactType)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
 func TestManifestAPI_Put_OCIImageIndexByTagManifestsNotPresentInDatabase(t *testing.T) {
 	skipDatabaseNotEnabled(t)
 
@@ -2472,3 +3857,70 @@ func TestExistingRenameLease_Checks_Skipped(t *testing.T) {
 		})
 	}
 }
+
+func TestManifestAPI_Put_BlobMount(t *testing.T) {
+	env := newTestEnv(t)
+	env.requireDB(t)
+	t.Cleanup(env.Shutdown)
+
+	// seed a manifest in the source repository, whose blobs will be mounted into the target repository
+	srcPath, dstPath := "foo/src", "foo/dst"
+	m := seedRandomOCIManifest(t, env, srcPath, putByDigest)
+
+	tokenProvider := NewAuthTokenProvider(t)
+	env = newTestEnv(t, withTokenAuth(tokenProvider.CertPath(), defaultIssuerProps()), func(config *configuration.Configuration) {
+		config.Validation.Manifests.BlobMount = configuration.ManifestBlobMount{Enabled: true, Concurrency: 2}
+	})
+	t.Cleanup(env.Shutdown)
+
+	u := buildManifestDigestURL(t, env, dstPath, m)
+
+	// without pull access to the source repository, blobs are not mounted
+	dstToken := tokenProvider.TokenWithActions(fullAccessToken(dstPath))
+	resp := putManifest(t, "putting manifest without source access", u, v1.MediaTypeImageManifest, m, witAuthToken(dstToken))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeManifestBlobUnknown)
+
+	dstRef, err := reference.WithName(dstPath)
+	require.NoError(t, err)
+	headBlobs := func(expectedStatus int) {
+		t.Helper()
+		for _, desc := range append([]distribution.Descriptor{m.Config()}, m.Layers()...) {
+			ref, err := reference.WithDigest(dstRef, desc.Digest)
+			require.NoError(t, err)
+			blobURL, err := env.builder.BuildBlobURL(ref)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodHead, blobURL, nil)
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(newRequest(req, witAuthToken(dstToken)))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, expectedStatus, resp.StatusCode)
+		}
+	}
+
+	// blobs are only mounted once the manifest is validated, so an invalid manifest does not mount any
+	actions := append(fullAccessToken(dstPath), &token.ResourceActions{Type: "repository", Name: srcPath, Actions: []string{"pull"}})
+	invalid := m.Manifest
+	invalid.Layers = append(append([]distribution.Descriptor{}, m.Layers()...), distribution.Descriptor{
+		MediaType: v1.MediaTypeImageLayer,
+		Digest:    digest.FromString("unknown"),
+		Size:      7,
+	})
+	dm, err := ocischema.FromStruct(invalid)
+	require.NoError(t, err)
+	resp = putManifest(t, "putting invalid manifest with source access", buildManifestDigestURL(t, env, dstPath, dm), v1.MediaTypeImageManifest, dm, witAuthToken(tokenProvider.TokenWithActions(actions)))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "", resp, v2.ErrorCodeManifestBlobUnknown)
+	headBlobs(http.StatusNotFound)
+
+	resp = putManifest(t, "putting manifest with source access", u, v1.MediaTypeImageManifest, m, witAuthToken(tokenProvider.TokenWithActions(actions)))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// the mounted blobs can now be pulled from the target repository
+	headBlobs(http.StatusOK)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	cryptorand "crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"errors"
 	"expvar"
@@ -13,9 +14,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -32,23 +36,41 @@ import (
 	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
 	"github.com/docker/distribution/registry/api/urls"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/token"
+	"github.com/docker/distribution/registry/cleanup"
 	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/bbm"
 	"github.com/docker/distribution/registry/datastore/migrations"
 	"github.com/docker/distribution/registry/gc"
 	"github.com/docker/distribution/registry/gc/worker"
+	"github.com/docker/distribution/registry/integrity"
 	"github.com/docker/distribution/registry/internal"
 	redismetrics "github.com/docker/distribution/registry/internal/metrics/redis"
+	"github.com/docker/distribution/registry/internal/metrics/traffic"
 	registrymiddleware "github.com/docker/distribution/registry/middleware/registry"
 	repositorymiddleware "github.com/docker/distribution/registry/middleware/repository"
+	"github.com/docker/distribution/registry/proxy"
+	"github.com/docker/distribution/registry/pulls"
+	"github.com/docker/distribution/registry/purge"
+	"github.com/docker/distribution/registry/replication"
+	"github.com/docker/distribution/registry/signature"
+	"github.com/docker/distribution/registry/sizes"
 	"github.com/docker/distribution/registry/storage"
 	memorycache "github.com/docker/distribution/registry/storage/cache/memory"
 	rediscache "github.com/docker/distribution/registry/storage/cache/redis"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/factory"
+	"github.com/docker/distribution/registry/storage/driver/isolation"
 	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+	_ "github.com/docker/distribution/registry/storage/driver/middleware/retry"
 	"github.com/docker/distribution/registry/storage/validation"
+	"github.com/docker/distribution/registry/uploads"
+	"github.com/docker/distribution/registry/usage"
+	"github.com/docker/distribution/tracing"
 	"github.com/docker/distribution/version"
+	"github.com/docker/libtrust"
 	gocache "github.com/eko/gocache/lib/v4/cache"
 	libstore "github.com/eko/gocache/lib/v4/store"
 	redisstore "github.com/eko/gocache/store/redis/v4"
@@ -60,6 +82,8 @@ import (
 	"gitlab.com/gitlab-org/labkit/errortracking"
 	metricskit "gitlab.com/gitlab-org/labkit/metrics"
 	"gitlab.com/gitlab-org/labkit/metrics/sqlmetrics"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // randomSecretSize is the number of random bytes to generate if no secret
@@ -85,17 +109,43 @@ type App struct {
 	db       *datastore.DB               // db is the global database handle used across the app.
 	registry distribution.Namespace      // registry is the primary registry backend for the app instance.
 
+	// dbLoadBalancer routes read-only queries to database replicas. Nil if database load balancing is disabled.
+	dbLoadBalancer *datastore.DBLoadBalancer
+	// dbCircuitBreaker detects a degraded database. Nil if the database circuit breaker is disabled.
+	dbCircuitBreaker *datastore.CircuitBreaker
+	// fsMirrorRegistry is a registry backend that writes layer links to the filesystem metadata. It's used instead of
+	// registry for writes, so that they are mirrored to the filesystem, and for reads that fall back to the filesystem
+	// metadata. Nil unless both the database and filesystem mirroring are enabled.
+	fsMirrorRegistry distribution.Namespace
+	// isolation routes the storage objects of isolated top-level namespaces to their dedicated drivers. It's wrapped
+	// by driver. Nil if namespace isolation is disabled.
+	isolation *isolation.Driver
+
 	repoRemover      distribution.RepositoryRemover // repoRemover provides ability to delete repos
 	accessController auth.AccessController          // main access controller for application
 
+	// reloadMu guards the settings replaced when reloading the configuration: the access controller, the rate
+	// limiter and the notification endpoints.
+	reloadMu sync.RWMutex
+
 	// httpHost is a parsed representation of the http.host parameter from
 	// the configuration. Only the Scheme and Host fields are used.
 	httpHost url.URL
 
 	// events contains notification related configuration.
 	events struct {
-		sink   notifications.Sink
-		source notifications.SourceRecord
+		sink      notifications.Sink
+		source    notifications.SourceRecord
+		endpoints []*notifications.Endpoint
+		// endpointsSink fans out events to the notification endpoints, which are replaced when reloading the
+		// configuration.
+		endpointsSink *swappableSink
+		// endpointsConfig is the configuration of the notification endpoints in use.
+		endpointsConfig []configuration.Endpoint
+		// queue is the queue configuration of the notification endpoints, which can't be reloaded.
+		queue notifications.QueueConfig
+		// hub fans out events to clients streaming the events of a repository.
+		hub *notifications.Hub
 	}
 
 	redis redis.UniversalClient
@@ -103,13 +153,79 @@ type App struct {
 	// readOnly is true if the registry is in a read-only maintenance mode
 	readOnly bool
 
+	// proxy is the remote registry of the pull-through cache, if enabled.
+	proxy *proxy.Remote
+
 	manifestURLs validation.ManifestURLs
+	// artifactMediaTypes matches the unknown media types of OCI artifacts which can be registered. None can if nil.
+	artifactMediaTypes *regexp.Regexp
 
-	manifestRefLimit         int
-	manifestPayloadSizeLimit int
+	manifestRefLimit          int
+	manifestPayloadSizeLimit  int
+	manifestPayloadSizeLimits []configuration.ManifestPayloadSizeLimitRule
+	manifestRefConcurrency    int
+	manifestRefTimeout        time.Duration
 
 	// redisCache is the interface for manipulating cached data on Redis.
 	redisCache *gocache.Cache[any]
+	// redisCacheClient is the client of the Redis cache, for features that need direct access to it.
+	redisCacheClient redis.UniversalClient
+
+	// rateLimiter enforces request rate limits. Nil if rate limiting is disabled.
+	rateLimiter *rateLimiter
+	// trustedProxies are the reverse proxies whose forwarded client IPs are trusted.
+	trustedProxies trustedProxies
+
+	// deadlines determines the deadline of Distribution API requests. Nil if no deadlines are configured.
+	deadlines *deadlinePolicy
+	// requestLimits protects against oversized request bodies and slow clients. Nil if no limits are configured.
+	requestLimits *requestLimits
+
+	// remoteMounter fetches blobs from remote registries. Nil if cross-registry blob mounting is disabled.
+	remoteMounter *remoteMounter
+
+	// layoutImportFetcher downloads OCI image layout archives to import. Nil if importing archives from URLs is disabled.
+	layoutImportFetcher *layoutImportFetcher
+
+	// directUploader hands out URLs for trusted clients to upload blob data directly to the storage backend. Nil if
+	// direct uploads are disabled.
+	directUploader *directUploader
+
+	// auditLogger records write operations in the audit log. Nil if the audit log is disabled.
+	auditLogger *audit.Logger
+
+	// repositoryTraffic tracks the pulls and pushes of the most active repositories. Nil if disabled.
+	repositoryTraffic *traffic.Tracker
+
+	// drainer tracks in-flight blob uploads and manifest puts, to drain the app before shutting down.
+	drainer drainer
+
+	// maintenance holds the maintenance windows during which write requests are rejected.
+	maintenance maintenanceScheduler
+
+	// gcAgents holds the running online GC agents, keyed by name (see gcAgentNames).
+	gcAgents map[string]*gc.Agent
+
+	// sizeRecalculator recalculates repository and namespace sizes in the background. Nil if disabled.
+	sizeRecalculator *sizes.Recalculator
+
+	// blobVerifier verifies the integrity of blobs in storage in the background. Nil if disabled.
+	blobVerifier *integrity.Verifier
+
+	// pullTracker records the last time tags and manifests were pulled in the database. Nil if disabled.
+	pullTracker *pulls.Tracker
+
+	// namespaceStatistics aggregates daily usage statistics of top-level namespaces in the database. Nil if disabled.
+	namespaceStatistics *usage.Aggregator
+
+	// replicator mirrors pushed images to downstream registries. Nil if disabled.
+	replicator *replication.Replicator
+
+	// signatureVerifier checks the signatures of pulled manifests. Nil if no signature rules are configured.
+	signatureVerifier *signature.Verifier
+
+	// schema1Converter converts stored schema 1 manifests to schema 2 when pulled. Nil if disabled.
+	schema1Converter *schema1Converter
 }
 
 // NewApp takes a configuration and returns a configured app, ready to serve
@@ -136,6 +252,14 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 		return nil, err
 	}
 
+	if config.Isolation.Enabled {
+		app.isolation, err = applyNamespaceIsolation(app.driver, config.Isolation)
+		if err != nil {
+			return nil, fmt.Errorf("configuring namespace isolation: %w", err)
+		}
+		app.driver = app.isolation
+	}
+
 	log := dcontext.GetLogger(app)
 
 	purgeConfig := uploadPurgeDefaultConfig()
@@ -171,7 +295,7 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 		return nil, err
 	}
 
-	app.driver, err = applyStorageMiddleware(app.driver, config.Middleware["storage"])
+	app.driver, err = applyStorageMiddleware(app.driver, storageMiddlewareWithRetry(config.Storage, config.Middleware["storage"]))
 	if err != nil {
 		return nil, err
 	}
@@ -179,8 +303,9 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 	if err := app.configureSecret(config); err != nil {
 		return nil, err
 	}
-	app.configureEvents(config)
-	app.configureRedis(config)
+	if err := app.configureRedis(config); err != nil {
+		return nil, fmt.Errorf("configuring redis: %w", err)
+	}
 
 	if err := app.configureRedisCache(ctx, config); err != nil {
 		// Because the Redis cache is not a strictly required dependency (data will be served from the metadata DB if
@@ -194,7 +319,19 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 
 	// TODO: Once schema1 code is removed throughout the registry, we will not
 	// need to explicitly configure this.
-	options = append(options, storage.DisableSchema1Pulls)
+	if config.Compatibility.Schema1.ConvertOnRead {
+		// Schema 1 manifests must be read from storage so that they can be converted. Their signatures are stripped
+		// when stored and generated again when read, but never served, so an ephemeral signing key is enough.
+		key, err := libtrust.GenerateECP256PrivateKey()
+		if err != nil {
+			return nil, fmt.Errorf("generating schema 1 signing key: %w", err)
+		}
+		options = append(options, storage.Schema1SigningKey(key))
+		app.schema1Converter = newSchema1Converter()
+		log.Info("schema 1 manifest conversion on read enabled")
+	} else {
+		options = append(options, storage.DisableSchema1Pulls)
+	}
 
 	if config.HTTP.Host != "" {
 		u, err := url.Parse(config.HTTP.Host)
@@ -231,19 +368,20 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 		log.Info("backend redirection disabled")
 	} else {
 		l := log
-		exceptions := config.Storage["redirect"]["exceptions"]
-		if exceptions, ok := exceptions.([]interface{}); ok && len(exceptions) > 0 {
-			s := make([]string, len(exceptions))
-			for i, v := range exceptions {
-				s[i] = fmt.Sprint(v)
-			}
-
-			l.WithField("exceptions", s)
-
+		if s := redirectOptionList(config, "exceptions"); len(s) > 0 {
+			l = l.WithField("exceptions", s)
 			options = append(options, storage.EnableRedirectWithExceptions(s))
 		} else {
 			options = append(options, storage.EnableRedirect)
 		}
+		if s := redirectOptionList(config, "repositories"); len(s) > 0 {
+			l = l.WithField("repositories", s)
+			options = append(options, storage.WithRedirectRepositories(s))
+		}
+		if s := redirectOptionList(config, "clientexceptions"); len(s) > 0 {
+			l = l.WithField("client_exceptions", s)
+			options = append(options, storage.WithRedirectClientExceptions(s))
+		}
 
 		// expiry delay
 		delay := config.Storage["redirect"]["expirydelay"]
@@ -305,20 +443,169 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 			}
 		}
 
+		if allow := config.Validation.Manifests.ArtifactMediaTypes.Allow; len(allow) > 0 {
+			patterns := make([]string, 0, len(allow))
+			for i, s := range allow {
+				if _, err := regexp.Compile(s); err != nil {
+					return nil, fmt.Errorf("validation.manifests.artifactmediatypes.allow[%d]: %w", i, err)
+				}
+				patterns = append(patterns, fmt.Sprintf("(?:%s)", s))
+			}
+			app.artifactMediaTypes = regexp.MustCompile(strings.Join(patterns, "|"))
+		}
+
 		app.manifestRefLimit = config.Validation.Manifests.ReferenceLimit
 		options = append(options, storage.ManifestReferenceLimit(app.manifestRefLimit))
 
 		app.manifestPayloadSizeLimit = config.Validation.Manifests.PayloadSizeLimit
 		options = append(options, storage.ManifestPayloadSizeLimit(app.manifestPayloadSizeLimit))
+		for i, rule := range config.Validation.Manifests.PayloadSizeLimits {
+			if _, err := path.Match(rule.Repository, ""); err != nil {
+				return nil, fmt.Errorf("validation.manifests.payloadsizelimits[%d].repository: %w", i, err)
+			}
+		}
+		app.manifestPayloadSizeLimits = config.Validation.Manifests.PayloadSizeLimits
+
+		app.manifestRefConcurrency = config.Validation.Manifests.ReferenceVerification.Concurrency
+		app.manifestRefTimeout = config.Validation.Manifests.ReferenceVerification.Timeout
+		options = append(options, storage.ManifestReferenceConcurrency(app.manifestRefConcurrency, app.manifestRefTimeout))
+	}
+
+	for i, rule := range config.Policy.Repository.ImmutableTags {
+		if _, err := path.Match(rule.Repository, ""); err != nil {
+			return nil, fmt.Errorf("policy.repository.immutabletags[%d].repository: %w", i, err)
+		}
+		for _, p := range rule.Patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("policy.repository.immutabletags[%d].patterns: %q: %w", i, p, err)
+			}
+		}
+	}
+
+	app.signatureVerifier, err = signature.NewVerifier(config.Policy.Repository.Signatures)
+	if err != nil {
+		return nil, err
+	}
+
+	if chunks := config.Uploads.Chunks; chunks.MinSize < 0 || chunks.MaxSize < 0 {
+		return nil, errors.New("uploads.chunks: minsize and maxsize must not be negative")
+	} else if chunks.MaxSize > 0 && chunks.MinSize > chunks.MaxSize {
+		return nil, fmt.Errorf("uploads.chunks: minsize (%d) must not be greater than maxsize (%d)", chunks.MinSize, chunks.MaxSize)
+	}
+
+	if err := app.SetMaintenanceWindows(config.Maintenance.Windows); err != nil {
+		return nil, fmt.Errorf("maintenance: %w", err)
+	}
+
+	if config.RemoteMount.Enabled {
+		app.remoteMounter, err = newRemoteMounter(config.RemoteMount)
+		if err != nil {
+			return nil, fmt.Errorf("configuring remote blob mounting: %w", err)
+		}
+	}
+
+	app.trustedProxies, err = newTrustedProxies(config.HTTP.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+
+	if len(config.Uploads.Import.Hosts) > 0 {
+		app.layoutImportFetcher, err = newLayoutImportFetcher(config.Uploads.Import)
+		if err != nil {
+			return nil, fmt.Errorf("configuring layout imports: %w", err)
+		}
+	}
+
+	if config.Uploads.Direct.Enabled {
+		app.directUploader, err = newDirectUploader(config.Uploads.Direct, app.driver, app.trustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("uploads.direct: %w", err)
+		}
+		log.WithField("trusted_clients", config.Uploads.Direct.TrustedClients).Info("direct blob uploads enabled")
+	}
+
+	if config.Audit.Enabled {
+		app.auditLogger, err = newAuditLogger(config.Audit)
+		if err != nil {
+			return nil, fmt.Errorf("configuring audit log: %w", err)
+		}
+		log.WithField("sink", config.Audit.Sink).Info("audit log enabled")
+	}
+
+	if pr := config.HTTP.Debug.Prometheus.Repositories; config.HTTP.Debug.Prometheus.Enabled && pr.Enabled {
+		app.repositoryTraffic, err = newRepositoryTraffic(pr)
+		if err != nil {
+			return nil, fmt.Errorf("configuring repository metrics: %w", err)
+		}
+		log.WithFields(logrus.Fields{"limit": pr.Limit, "half_life_s": pr.HalfLife.Seconds()}).Info("repository metrics enabled")
+	}
+
+	if config.Proxy.Enabled() {
+		if !config.Database.Enabled {
+			return nil, errors.New("the pull-through cache requires the database to be enabled")
+		}
+		app.proxy, err = proxy.NewRemote(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("configuring pull-through cache: %w", err)
+		}
+		log.WithFields(logrus.Fields{"remote_url": app.proxy.URL(), "ttl_s": config.Proxy.TTL.Seconds()}).Info("pull-through cache enabled")
+	}
+
+	if config.HTTP.Deadlines.Default != 0 || len(config.HTTP.Deadlines.Rules) > 0 {
+		dp, err := newDeadlinePolicy(config.HTTP.Deadlines)
+		if err != nil {
+			return nil, fmt.Errorf("configuring request deadlines: %w", err)
+		}
+		app.deadlines = dp
+		log.WithFields(logrus.Fields{"default": config.HTTP.Deadlines.Default, "rules": len(dp.rules)}).Info("request deadlines enabled")
+	}
+
+	if rl := config.HTTP.RequestLimits; rl.MaxBodySize != 0 || rl.BodyTimeouts.Blob != 0 || rl.BodyTimeouts.API != 0 {
+		limits, err := newRequestLimits(rl)
+		if err != nil {
+			return nil, fmt.Errorf("configuring request limits: %w", err)
+		}
+		app.requestLimits = limits
+		log.WithFields(logrus.Fields{
+			"max_body_size":     rl.MaxBodySize,
+			"blob_body_timeout": rl.BodyTimeouts.Blob,
+			"api_body_timeout":  rl.BodyTimeouts.API,
+		}).Info("request limits enabled")
+	}
+
+	if config.RateLimiter.Enabled {
+		if !config.Redis.Cache.Enabled {
+			return nil, errors.New("rate limiting requires the redis cache to be enabled")
+		}
+		rl, err := newRateLimiter(app.redisCacheClient, config.RateLimiter, app.trustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("configuring rate limiting: %w", err)
+		}
+		// As for the Redis cache itself, failing to connect to Redis must not prevent the app from starting.
+		if app.redisCacheClient == nil {
+			log.Error("redis cache unavailable, rate limiting disabled")
+		} else {
+			app.rateLimiter = rl
+			log.WithField("limiters", len(config.RateLimiter.Limiters)).Info("rate limiting enabled")
+		}
+	}
+
+	if config.Redis.Cache.UploadSessions.Enabled {
+		// As for the Redis cache itself, failing to connect to Redis must not prevent the app from starting. Uploads
+		// can still be resumed from the storage backend metadata.
+		if app.redisCache == nil {
+			log.Error("redis cache unavailable, shared upload sessions disabled")
+		} else {
+			s := storage.NewRedisUploadSessionStore(app.redisCache, config.Redis.Cache.UploadSessions.TTL)
+			options = append(options, storage.UploadSessions(s))
+			log.Info("shared upload sessions enabled")
+		}
 	}
 
 	// Connect to the metadata database, if enabled.
 	if config.Database.Enabled {
 		log.Warn("the metadata database is a beta feature, please carefully review the documentation before enabling it in production")
 
-		// Do not write or check for repository layer link metadata on the filesystem when the database is enabled.
-		options = append(options, storage.DisableMirrorFS)
-
 		// TODO: this function only exists to test that we are able to connect to the primary database node via its FQDN
 		// This function (and everything related to service discovery) will be removed upon verifying the registry can
 		// establish a connection directly to the primary database node FQDN
@@ -356,7 +643,7 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 			log.Info("successfully connected to primary database node")
 		}()
 
-		db, err := datastore.Open(&datastore.DSN{
+		dsn := datastore.DSN{
 			Host:           config.Database.Host,
 			Port:           config.Database.Port,
 			User:           config.Database.User,
@@ -367,7 +654,8 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 			SSLKey:         config.Database.SSLKey,
 			SSLRootCert:    config.Database.SSLRootCert,
 			ConnectTimeout: config.Database.ConnectTimeout,
-		},
+		}
+		dbOpts := []datastore.OpenOption{
 			datastore.WithLogger(log.WithFields(logrus.Fields{"database": config.Database.DBName})),
 			datastore.WithLogLevel(config.Log.Level),
 			datastore.WithPreparedStatements(config.Database.PreparedStatements),
@@ -377,7 +665,8 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 				MaxLifetime: config.Database.Pool.MaxLifetime,
 				MaxIdleTime: config.Database.Pool.MaxIdleTime,
 			}),
-		)
+		}
+		db, err := datastore.Open(&dsn, dbOpts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to construct database connection: %w", err)
 		}
@@ -397,10 +686,27 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 		app.db = db
 		options = append(options, storage.Database(app.db))
 
+		if config.Database.LoadBalancing.Enabled {
+			lb, err := newDBLoadBalancer(app.Context, config, db, dsn, app.redisCache, dbOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure database load balancing: %w", err)
+			}
+			app.dbLoadBalancer = lb
+			go lb.StartReplicaChecking(app.Context)
+		}
+
+		if config.Database.CircuitBreaker.Enabled {
+			app.dbCircuitBreaker = newDBCircuitBreaker(app.Context, config, db)
+			go app.dbCircuitBreaker.Start(app.Context)
+		}
+
 		if config.HTTP.Debug.Prometheus.Enabled {
-			// Expose database metrics to prometheus.
-			collector := sqlmetrics.NewDBStatsCollector(config.Database.DBName, db)
-			promclient.MustRegister(collector)
+			// Expose the connection pool metrics of the primary database and its replicas to prometheus.
+			for _, pool := range app.dbPools() {
+				collector := sqlmetrics.NewDBStatsCollector(config.Database.DBName, pool,
+					sqlmetrics.WithExtraLabels(map[string]string{"host": pool.Address()}))
+				promclient.MustRegister(collector)
+			}
 		}
 
 		// update online GC settings (if needed) in the background to avoid delaying the app start
@@ -411,7 +717,21 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 			}
 		}()
 
-		startOnlineGC(app.Context, app.db, app.driver, config)
+		// online GC only has to invalidate the manifests it deletes if they can be cached in the first place
+		var manifestCache datastore.ManifestCache
+		if manifestCacheEnabled(app) {
+			manifestCache = getManifestCache(app)
+		}
+		app.gcAgents = startOnlineGC(app.Context, app.db, app.driver, app.auditLogger, manifestCache, config)
+		app.sizeRecalculator = startSizeRecalculation(app.Context, app.db, app.redisCache, config)
+		app.pullTracker = startPullTracking(app.Context, app.db, config)
+		app.namespaceStatistics = startNamespaceStatistics(app.Context, app.db, config)
+		startCleanupPolicies(app, config)
+		startRepositoryPurges(app, config)
+		startUploadPurging(app, config)
+		startProxyExpiry(app, config)
+		startNotificationEventPurging(app, config)
+		startBackgroundMigrations(app.Context, app.db, config)
 
 		// Now that we've started the database successfully, lock the filesystem
 		// to signal that this object storage needs to be managed by the database.
@@ -424,6 +744,11 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 		}
 	}
 
+	// configure events after the database, which may back the notifications queue
+	if err := app.configureEvents(config); err != nil {
+		return nil, err
+	}
+
 	// configure storage caches
 	// It's possible that the metadata database will fill the same original need
 	// as the blob descriptor cache (avoiding slow and/or expensive calls to
@@ -467,7 +792,23 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 		log.Warn("blob descriptor cache is not compatible with metadata database, caching disabled")
 	}
 
+	if config.Database.Enabled && config.Database.MirrorFS {
+		app.fsMirrorRegistry, err = storage.NewRegistry(app.Context, app.driver, options...)
+		if err != nil {
+			return nil, fmt.Errorf("could not create filesystem mirror registry: %w", err)
+		}
+		app.fsMirrorRegistry, err = applyRegistryMiddleware(app, app.fsMirrorRegistry, config.Middleware["registry"])
+		if err != nil {
+			return nil, err
+		}
+		log.Info("mirroring metadata to the filesystem")
+	}
+
 	if app.registry == nil {
+		// Do not write or check for repository layer link metadata on the filesystem when the database is enabled.
+		if config.Database.Enabled {
+			options = append(options, storage.DisableMirrorFS)
+		}
 		// configure the registry if no cache section is available.
 		app.registry, err = storage.NewRegistry(app.Context, app.driver, options...)
 		if err != nil {
@@ -480,12 +821,15 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 		return nil, err
 	}
 
+	// blob verification reads content through the registry, so it can only start once it's configured
+	app.blobVerifier = startBlobVerification(app, config)
+
 	authType := config.Auth.Type()
 
 	if authType != "" && !strings.EqualFold(authType, "none") {
-		accessController, err := auth.GetAccessController(config.Auth.Type(), config.Auth.Parameters())
+		accessController, err := app.newAccessController(config.Auth)
 		if err != nil {
-			return nil, fmt.Errorf("unable to configure authorization (%s): %w", authType, err)
+			return nil, err
 		}
 		app.accessController = accessController
 		log.WithField("auth_type", authType).Debug("configured access controller")
@@ -500,6 +844,26 @@ func NewApp(ctx context.Context, config *configuration.Configuration) (*App, err
 	return app, nil
 }
 
+// newAccessController creates the access controller of the auth method configured in config.
+func (app *App) newAccessController(config configuration.Auth) (auth.AccessController, error) {
+	authType := config.Type()
+	params := config.Parameters()
+	// one-time tokens are recorded in the redis cache, so that they can't be reused with any registry instance
+	if authType == "token" && app.redisCacheClient != nil {
+		params = make(configuration.Parameters, len(config.Parameters())+1)
+		for k, v := range config.Parameters() {
+			params[k] = v
+		}
+		params[token.ReplayCacheOption] = token.NewRedisReplayCache(app.redisCacheClient)
+	}
+
+	accessController, err := auth.GetAccessController(authType, params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure authorization (%s): %w", authType, err)
+	}
+	return accessController, nil
+}
+
 var (
 	onlineGCUpdateJitterMaxSeconds = 60
 	onlineGCUpdateTimeout          = 2 * time.Second
@@ -554,11 +918,362 @@ func updateOnlineGCSettings(ctx context.Context, db datastore.Queryer, config *c
 	return nil
 }
 
-func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storagedriver.StorageDriver, config *configuration.Configuration) {
-	if !config.Database.Enabled || config.GC.Disabled || (config.GC.Blobs.Disabled && config.GC.Manifests.Disabled) {
+// newDBLoadBalancer opens a connection handler for each configured database replica, reusing the primary DSN and open
+// options with the replica host, and creates a load balancer for them and the primary db. Writes to repositories are
+// recorded in redisCache if not nil, so that all registry instances route the subsequent reads to the primary.
+func newDBLoadBalancer(ctx context.Context, config *configuration.Configuration, db *datastore.DB, dsn datastore.DSN, redisCache *gocache.Cache[any], opts ...datastore.OpenOption) (*datastore.DBLoadBalancer, error) {
+	lbConfig := config.Database.LoadBalancing
+
+	replicas := make([]*datastore.DB, 0, len(lbConfig.Hosts))
+	for _, host := range lbConfig.Hosts {
+		replicaDSN := dsn
+		replicaDSN.Host = host
+
+		replica, err := datastore.Open(&replicaDSN, opts...)
+		if err != nil {
+			for _, r := range replicas {
+				r.Close()
+			}
+			return nil, fmt.Errorf("failed to construct database replica %q connection: %w", host, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	lbOpts := []datastore.LoadBalancerOption{
+		datastore.WithLoadBalancerLogger(dcontext.GetLogger(ctx).WithFields(logrus.Fields{"component": "registry.datastore.DBLoadBalancer"})),
+		datastore.WithReplicaCheckInterval(lbConfig.ReplicaCheckInterval),
+		datastore.WithMaxReplicationLag(lbConfig.MaxReplicationLagBytes, lbConfig.MaxReplicationLagTime),
+		datastore.WithStickyDuration(lbConfig.StickyDuration),
+	}
+	if redisCache != nil {
+		lbOpts = append(lbOpts, datastore.WithStickyCache(datastore.NewCentralStickyCache(redisCache)))
+	} else {
+		dcontext.GetLogger(ctx).Warn("redis cache unavailable, database load balancing can only guarantee that clients " +
+			"read their own writes if served by the same registry instance")
+	}
+
+	return datastore.NewDBLoadBalancer(db, replicas, lbOpts...), nil
+}
+
+// newDBCircuitBreaker creates a circuit breaker for the primary db, which logs and reports its state transitions.
+func newDBCircuitBreaker(ctx context.Context, config *configuration.Configuration, db *datastore.DB) *datastore.CircuitBreaker {
+	cbConfig := config.Database.CircuitBreaker
+	l := dcontext.GetLogger(ctx).WithFields(logrus.Fields{"component": "registry.datastore.CircuitBreaker"})
+
+	return datastore.NewCircuitBreaker(db,
+		datastore.WithCircuitBreakerLogger(l),
+		datastore.WithCircuitBreakerCheckInterval(cbConfig.Interval),
+		datastore.WithCircuitBreakerLatencyThreshold(cbConfig.LatencyThreshold),
+		datastore.WithCircuitBreakerThresholds(cbConfig.FailureThreshold, cbConfig.RecoveryThreshold),
+		datastore.WithCircuitBreakerListener(func(open bool) {
+			if !open {
+				return
+			}
+			err := errors.New("database circuit breaker opened")
+			errortracking.Capture(err, errortracking.WithContext(ctx))
+			if config.Database.MirrorFS {
+				l.Warn("serving read requests from the filesystem metadata until the database recovers")
+			}
+		}),
+	)
+}
+
+// dbFallbackToFS reports whether request r should be served from the filesystem metadata instead of the database. This
+// is the case for read requests while the database circuit breaker is open, if filesystem mirroring is enabled.
+func (app *App) dbFallbackToFS(r *http.Request) bool {
+	if app.dbCircuitBreaker == nil || app.fsMirrorRegistry == nil {
+		return false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return app.dbCircuitBreaker.IsOpen()
+}
+
+// replicaDB returns the database handler to use for read-only queries related to the repository with the given path,
+// or to no repository in particular if path is empty. This is a database replica if load balancing is enabled and one
+// is available, otherwise the primary.
+func (app *App) replicaDB(ctx context.Context, path string) datastore.Handler {
+	if app.dbLoadBalancer == nil {
+		return app.db
+	}
+	return app.dbLoadBalancer.Replica(ctx, path)
+}
+
+// recordDBWrite makes read-only queries for the repository targeted by a write request r stick to the primary database
+// for a while, so that clients can read their own writes even if replicas are lagging behind.
+func (app *App) recordDBWrite(ctx *Context, r *http.Request) {
+	if app.dbLoadBalancer == nil || ctx.Repository == nil {
+		return
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return
+	}
+	app.dbLoadBalancer.RecordWrite(ctx, ctx.Repository.Named().Name())
+}
+
+func startSizeRecalculation(ctx context.Context, db *datastore.DB, cache *gocache.Cache[any], config *configuration.Configuration) *sizes.Recalculator {
+	if !config.Database.Enabled || !config.Database.SizeRecalculation.Enabled {
+		return nil
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(ctx))
+
+	// sizes drift in the Redis cache, so there is nothing to recalculate without it
+	if cache == nil {
+		l.Warn("size recalculation requires the Redis cache, skipping")
+		return nil
+	}
+
+	repoCache := datastore.NewCentralRepositoryCache(cache)
+	rc := sizes.NewRecalculator(
+		datastore.NewRepositoryStore(db, datastore.WithRepositoryCache(repoCache)),
+		repoCache,
+		sizes.NewRedisCheckpointStore(cache),
+		sizes.WithLogger(l),
+		sizes.WithInterval(config.Database.SizeRecalculation.Interval),
+		sizes.WithBatchSize(config.Database.SizeRecalculation.BatchSize),
+	)
+
+	go func() {
+		if err := rc.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("size recalculation stopped with error: %w", err))
+			l.WithError(err).Error("size recalculation stopped")
+		}
+	}()
+
+	return rc
+}
+
+func startBlobVerification(app *App, config *configuration.Configuration) *integrity.Verifier {
+	if !config.Database.Enabled || !config.Database.BlobVerification.Enabled {
+		return nil
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(app.Context))
+
+	// isolated namespaces store their blobs in dedicated drivers, which can't be told apart without a repository
+	if config.Isolation.Enabled {
+		l.Warn("blob verification is not compatible with namespace isolation, skipping")
+		return nil
+	}
+
+	bp, ok := app.registry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		l.Warn("unable to convert BlobEnumerator into BlobProvider, skipping blob verification")
+		return nil
+	}
+
+	v := integrity.NewVerifier(
+		datastore.NewBlobStore(app.db),
+		datastore.NewCorruptedBlobStore(app.db),
+		bp,
+		integrity.WithLogger(l),
+		integrity.WithInterval(config.Database.BlobVerification.Interval),
+		integrity.WithSampleSize(config.Database.BlobVerification.SampleSize),
+		integrity.WithMaxSize(config.Database.BlobVerification.MaxSize),
+	)
+
+	go func() {
+		if err := v.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("blob verification stopped with error: %w", err))
+			l.WithError(err).Error("blob verification stopped")
+		}
+	}()
+
+	return v
+}
+
+func startNamespaceStatistics(ctx context.Context, db *datastore.DB, config *configuration.Configuration) *usage.Aggregator {
+	if !config.Database.Enabled || !config.Database.NamespaceStatistics.Enabled {
+		return nil
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(ctx))
+
+	a := usage.NewAggregator(
+		datastore.NewNamespaceStatisticsStore(db),
+		usage.WithLogger(l),
+		usage.WithFlushInterval(config.Database.NamespaceStatistics.FlushInterval),
+		usage.WithRetention(config.Database.NamespaceStatistics.Retention),
+	)
+
+	go func() {
+		if err := a.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("namespace statistics stopped with error: %w", err))
+			l.WithError(err).Error("namespace statistics stopped")
+		}
+	}()
+
+	return a
+}
+
+func startPullTracking(ctx context.Context, db *datastore.DB, config *configuration.Configuration) *pulls.Tracker {
+	if !config.Database.Enabled || !config.Database.PullTracking.Enabled {
+		return nil
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(ctx))
+
+	t := pulls.NewTracker(
+		datastore.NewPullStore(db),
+		pulls.WithLogger(l),
+		pulls.WithThrottle(config.Database.PullTracking.Throttle),
+		pulls.WithFlushInterval(config.Database.PullTracking.FlushInterval),
+		pulls.WithBatchSize(config.Database.PullTracking.BatchSize),
+	)
+
+	go func() {
+		if err := t.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("pull tracking stopped with error: %w", err))
+			l.WithError(err).Error("pull tracking stopped")
+		}
+	}()
+
+	return t
+}
+
+func startCleanupPolicies(app *App, config *configuration.Configuration) {
+	if !config.Database.Enabled || !config.Database.CleanupPolicies.Enabled {
+		return
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(app.Context))
+
+	if !deleteEnabled(config) || app.readOnly {
+		l.Warn("cleanup policies require deletes to be enabled and the registry not to be in read-only mode, skipping")
+		return
+	}
+
+	w := cleanup.NewWorker(
+		datastore.NewCleanupPolicyStore(app.db),
+		&cleanupExecutor{app: app},
+		cleanup.WithLogger(l),
+		cleanup.WithInterval(config.Database.CleanupPolicies.Interval),
+	)
+
+	go func() {
+		if err := w.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("cleanup policies worker stopped with error: %w", err))
+			l.WithError(err).Error("cleanup policies worker stopped")
+		}
+	}()
+}
+
+func startRepositoryPurges(app *App, config *configuration.Configuration) {
+	if !config.Database.Enabled || !config.Database.RepositoryPurges.Enabled {
+		return
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(app.Context))
+
+	if !deleteEnabled(config) || app.readOnly {
+		l.Warn("repository purges require deletes to be enabled and the registry not to be in read-only mode, skipping")
+		return
+	}
+
+	w := purge.NewWorker(
+		datastore.NewRepositoryPurgeStore(app.db),
+		&repositoryPurgeExecutor{app: app, batchSize: config.Database.RepositoryPurges.BatchSize},
+		purge.WithLogger(l),
+		purge.WithInterval(config.Database.RepositoryPurges.Interval),
+	)
+
+	go func() {
+		if err := w.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("repository purges worker stopped with error: %w", err))
+			l.WithError(err).Error("repository purges worker stopped")
+		}
+	}()
+}
+
+func startUploadPurging(app *App, config *configuration.Configuration) {
+	if !config.Database.Enabled || !config.Database.UploadPurging.Enabled {
+		return
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(app.Context))
+
+	if app.readOnly {
+		l.Warn("upload purging requires the registry not to be in read-only mode, skipping")
+		return
+	}
+
+	w := uploads.NewWorker(
+		datastore.NewUploadStore(app.db),
+		&uploadPurger{driver: app.driver},
+		uploads.WithLogger(l),
+		uploads.WithInterval(config.Database.UploadPurging.Interval),
+		uploads.WithAge(config.Database.UploadPurging.Age),
+		uploads.WithBatchSize(config.Database.UploadPurging.BatchSize),
+	)
+
+	go func() {
+		if err := w.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("upload purging worker stopped with error: %w", err))
+			l.WithError(err).Error("upload purging worker stopped")
+		}
+	}()
+}
+
+func startProxyExpiry(app *App, config *configuration.Configuration) {
+	if app.proxy == nil {
+		return
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(app.Context))
+
+	if app.readOnly {
+		l.Warn("pull-through cache expiry requires the registry not to be in read-only mode, skipping")
+		return
+	}
+
+	w := proxy.NewWorker(
+		datastore.NewProxyCachedTagStore(app.db),
+		&proxyTagDeleter{app: app},
+		proxy.WithLogger(l),
+		proxy.WithInterval(config.Proxy.Interval),
+		proxy.WithTTL(config.Proxy.TTL),
+		proxy.WithBatchSize(config.Proxy.BatchSize),
+	)
+
+	go func() {
+		if err := w.Start(app.Context); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("pull-through cache expiry worker stopped with error: %w", err))
+			l.WithError(err).Error("pull-through cache expiry worker stopped")
+		}
+	}()
+}
+
+func startBackgroundMigrations(ctx context.Context, db *datastore.DB, config *configuration.Configuration) {
+	if !config.Database.Enabled || !config.Database.BackgroundMigrations.Enabled {
 		return
 	}
 
+	l := dlog.GetLogger(dlog.WithContext(ctx))
+	w := bbm.NewWorker(
+		db,
+		bbm.WithLogger(l),
+		bbm.WithInterval(config.Database.BackgroundMigrations.Interval),
+		bbm.WithMaxAttempts(config.Database.BackgroundMigrations.MaxAttempts),
+		bbm.WithStatementTimeout(config.Database.BackgroundMigrations.StatementTimeout),
+	)
+
+	go func() {
+		if err := w.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			errortracking.Capture(fmt.Errorf("background migrations worker stopped with error: %w", err))
+			l.WithError(err).Error("background migrations worker stopped")
+		}
+	}()
+}
+
+// startOnlineGC starts the online GC agents in the background and returns them, keyed by name. Manifests deleted by
+// online GC are invalidated in manifestCache, if not nil.
+func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storagedriver.StorageDriver, auditLogger *audit.Logger, manifestCache datastore.ManifestCache, config *configuration.Configuration) map[string]*gc.Agent {
+	if !config.Database.Enabled || config.GC.Disabled || (config.GC.Blobs.Disabled && config.GC.Manifests.Disabled) {
+		return nil
+	}
+
 	l := dlog.GetLogger(dlog.WithContext(ctx))
 
 	aOpts := []gc.AgentOption{
@@ -571,11 +1286,16 @@ func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storaged
 		aOpts = append(aOpts, gc.WithMaxBackoff(config.GC.MaxBackoff))
 	}
 
-	var agents []*gc.Agent
+	agents := make(map[string]*gc.Agent)
 
 	if !config.GC.Blobs.Disabled {
 		bwOpts := []worker.BlobWorkerOption{
 			worker.WithBlobLogger(l),
+			worker.WithBlobAuditLogger(auditLogger),
+			worker.WithBlobReviewStats(),
+		}
+		if config.Database.NamespaceStatistics.Enabled {
+			bwOpts = append(bwOpts, worker.WithBlobNamespaceStatistics())
 		}
 		if config.GC.TransactionTimeout > 0 {
 			bwOpts = append(bwOpts, worker.WithBlobTxTimeout(config.GC.TransactionTimeout))
@@ -589,13 +1309,17 @@ func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storaged
 		if config.GC.Blobs.Interval > 0 {
 			baOpts = append(baOpts, gc.WithInitialInterval(config.GC.Blobs.Interval))
 		}
-		ba := gc.NewAgent(bw, baOpts...)
-		agents = append(agents, ba)
+		agents[gcAgentBlobs] = gc.NewAgent(bw, baOpts...)
 	}
 
 	if !config.GC.Manifests.Disabled {
 		mwOpts := []worker.ManifestWorkerOption{
 			worker.WithManifestLogger(l),
+			worker.WithManifestAuditLogger(auditLogger),
+			worker.WithManifestReviewStats(),
+		}
+		if manifestCache != nil {
+			mwOpts = append(mwOpts, worker.WithManifestCache(manifestCache))
 		}
 		if config.GC.TransactionTimeout > 0 {
 			mwOpts = append(mwOpts, worker.WithManifestTxTimeout(config.GC.TransactionTimeout))
@@ -606,8 +1330,7 @@ func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storaged
 		if config.GC.Manifests.Interval > 0 {
 			maOpts = append(maOpts, gc.WithInitialInterval(config.GC.Manifests.Interval))
 		}
-		ma := gc.NewAgent(mw, maOpts...)
-		agents = append(agents, ma)
+		agents[gcAgentManifests] = gc.NewAgent(mw, maOpts...)
 	}
 
 	for _, a := range agents {
@@ -635,6 +1358,8 @@ func startOnlineGC(ctx context.Context, db *datastore.DB, storageDriver storaged
 			}
 		}(a)
 	}
+
+	return agents
 }
 
 // RegisterHealthChecks is an awful hack to defer health check registration
@@ -737,7 +1462,11 @@ var routeMetricsMiddleware = metricskit.NewHandlerFactory(
 // passed through the application filters and context will be constructed at
 // request time.
 func (app *App) registerDistribution(routeName string, dispatch dispatchFunc) {
-	handler := app.dispatcher(dispatch)
+	class := routeClassAPI
+	if routeName == v2.RouteNameBlobUpload || routeName == v2.RouteNameBlobUploadChunk {
+		class = routeClassBlob
+	}
+	handler := app.deadlineMiddleware(routeName, app.requestLimitsMiddleware(class, app.dispatcher(dispatch)))
 
 	// Chain the handler with prometheus instrumented handler
 	if app.Config.HTTP.Debug.Prometheus.Enabled {
@@ -751,7 +1480,11 @@ func (app *App) registerDistribution(routeName string, dispatch dispatchFunc) {
 }
 
 func (app *App) registerGitlab(route v1.Route, dispatch dispatchFunc) {
-	handler := app.dispatcherGitlab(dispatch)
+	class := routeClassAPI
+	if route.Name == v1.RepositoryLayoutImport.Name {
+		class = routeClassBlob
+	}
+	handler := app.requestLimitsMiddleware(class, app.dispatcherGitlab(dispatch))
 
 	// Chain the handler with prometheus instrumented handler
 	if app.Config.HTTP.Debug.Prometheus.Enabled {
@@ -765,40 +1498,53 @@ func (app *App) registerGitlab(route v1.Route, dispatch dispatchFunc) {
 }
 
 // configureEvents prepares the event sink for action.
-func (app *App) configureEvents(configuration *configuration.Configuration) {
-	// Configure all of the endpoint sinks.
-	var sinks []notifications.Sink
-	for _, endpoint := range configuration.Notifications.Endpoints {
-		if endpoint.Disabled {
-			dcontext.GetLogger(app).Infof("endpoint %s disabled, skipping", endpoint.Name)
-			continue
-		}
-
-		dcontext.GetLogger(app).Infof("configuring endpoint %v (%v), timeout=%s, headers=%v", endpoint.Name, endpoint.URL, endpoint.Timeout, endpoint.Headers)
-		endpoint := notifications.NewEndpoint(endpoint.Name, endpoint.URL, notifications.EndpointConfig{
-			Timeout:           endpoint.Timeout,
-			Threshold:         endpoint.Threshold,
-			Backoff:           endpoint.Backoff,
-			Headers:           endpoint.Headers,
-			IgnoredMediaTypes: endpoint.IgnoredMediaTypes,
-			Ignore:            endpoint.Ignore,
-		})
+func (app *App) configureEvents(config *configuration.Configuration) error {
+	queueConfig, err := app.notificationsQueueConfig(config)
+	if err != nil {
+		return fmt.Errorf("configuring notifications queue: %w", err)
+	}
+	app.events.queue = queueConfig
 
-		sinks = append(sinks, endpoint)
+	endpoints, err := app.newNotificationEndpoints(config.Notifications.Endpoints)
+	if err != nil {
+		return err
+	}
+	app.events.endpoints = endpoints
+	app.events.endpointsConfig = config.Notifications.Endpoints
+	app.events.endpointsSink = newSwappableSink(newEndpointsBroadcaster(endpoints))
+	sinks := []notifications.Sink{app.events.endpointsSink}
 
+	replicator, err := replication.New(&replicationSource{app: app}, config.Replication, replication.WithLogger(dlog.GetLogger(dlog.WithContext(app))))
+	if err != nil {
+		return fmt.Errorf("configuring replication: %w", err)
 	}
+	if replicator != nil {
+		sinks = append(sinks, replicator)
+		app.replicator = replicator
+	}
+
+	app.events.hub = notifications.NewHub()
+	sinks = append(sinks, app.events.hub)
 
 	// TODO: replace broadcaster with a new worker that will consume events from the queue
 	// https://gitlab.com/gitlab-org/container-registry/-/issues/765
 	app.events.sink = notifications.NewBroadcaster(sinks...)
 
 	// Populate registry event source
+	addr := config.HTTP.Addr
+	for _, l := range config.HTTP.Listeners {
+		// use the first network listener, Unix sockets are not reachable by other hosts
+		if l.Net != "unix" {
+			addr = l.Addr
+			break
+		}
+	}
 	hostname, err := os.Hostname()
 	if err != nil {
-		hostname = configuration.HTTP.Addr
+		hostname = addr
 	} else {
 		// try to pick the port off the config
-		_, port, err := net.SplitHostPort(configuration.HTTP.Addr)
+		_, port, err := net.SplitHostPort(addr)
 		if err == nil {
 			hostname = net.JoinHostPort(hostname, port)
 		}
@@ -808,6 +1554,65 @@ func (app *App) configureEvents(configuration *configuration.Configuration) {
 		Addr:       hostname,
 		InstanceID: dcontext.GetStringValue(app, "instance.id"),
 	}
+
+	return nil
+}
+
+// newNotificationEndpoints creates the notification endpoints that are not disabled. All endpoints are validated before
+// creating any of them, so that no endpoint is left running if one is invalid.
+func (app *App) newNotificationEndpoints(configs []configuration.Endpoint) ([]*notifications.Endpoint, error) {
+	endpointConfigs := make([]notifications.EndpointConfig, len(configs))
+	for i, endpoint := range configs {
+		endpointConfigs[i] = notifications.EndpointConfig{
+			Type:              endpoint.Type,
+			Timeout:           endpoint.Timeout,
+			Threshold:         endpoint.Threshold,
+			Backoff:           endpoint.Backoff,
+			Headers:           endpoint.Headers,
+			IgnoredMediaTypes: endpoint.IgnoredMediaTypes,
+			Ignore:            endpoint.Ignore,
+			Kafka:             endpoint.Kafka,
+			NATS:              endpoint.NATS,
+			Queue:             app.events.queue,
+			TLS:               endpoint.TLS,
+			Signing:           endpoint.Signing,
+		}
+		if endpoint.Disabled {
+			continue
+		}
+		if err := endpointConfigs[i].Validate(); err != nil {
+			return nil, fmt.Errorf("configuring notifications endpoint %q: %w", endpoint.Name, err)
+		}
+	}
+
+	endpoints := make([]*notifications.Endpoint, 0, len(configs))
+	for i, endpoint := range configs {
+		if endpoint.Disabled {
+			dcontext.GetLogger(app).Infof("endpoint %s disabled, skipping", endpoint.Name)
+			continue
+		}
+
+		switch endpoint.Type {
+		case configuration.EndpointTypeKafka:
+			dcontext.GetLogger(app).Infof("configuring endpoint %v (kafka topic %v, brokers=%v), timeout=%s", endpoint.Name, endpoint.Kafka.Topic, endpoint.Kafka.Brokers, endpoint.Timeout)
+		case configuration.EndpointTypeNATS:
+			dcontext.GetLogger(app).Infof("configuring endpoint %v (nats servers=%v, subject=%q), timeout=%s", endpoint.Name, endpoint.NATS.Servers, endpoint.NATS.Subject, endpoint.Timeout)
+		default:
+			dcontext.GetLogger(app).Infof("configuring endpoint %v (%v), timeout=%s, headers=%v", endpoint.Name, endpoint.URL, endpoint.Timeout, endpoint.Headers)
+		}
+		endpoints = append(endpoints, notifications.NewEndpoint(endpoint.Name, endpoint.URL, endpointConfigs[i]))
+	}
+
+	return endpoints, nil
+}
+
+// newEndpointsBroadcaster returns a broadcaster of events to endpoints.
+func newEndpointsBroadcaster(endpoints []*notifications.Endpoint) *notifications.Broadcaster {
+	sinks := make([]notifications.Sink, 0, len(endpoints))
+	for _, e := range endpoints {
+		sinks = append(sinks, e)
+	}
+	return notifications.NewBroadcaster(sinks...)
 }
 
 func (app *App) configureRedisCache(ctx context.Context, config *configuration.Configuration) error {
@@ -815,29 +1620,34 @@ func (app *App) configureRedisCache(ctx context.Context, config *configuration.C
 		return nil
 	}
 
-	opts := &redis.UniversalOptions{
-		Addrs:           strings.Split(config.Redis.Cache.Addr, ","),
-		DB:              config.Redis.Cache.DB,
-		Password:        config.Redis.Cache.Password,
-		DialTimeout:     config.Redis.Cache.DialTimeout,
-		ReadTimeout:     config.Redis.Cache.ReadTimeout,
-		WriteTimeout:    config.Redis.Cache.WriteTimeout,
-		PoolSize:        config.Redis.Cache.Pool.Size,
-		ConnMaxLifetime: config.Redis.Cache.Pool.MaxLifetime,
-		MasterName:      config.Redis.Cache.MainName,
+	if err := validateRedisTopology(config.Redis.Cache.MainName, config.Redis.Cache.DB, config.Redis.Cache.Cluster); err != nil {
+		return err
 	}
-	if config.Redis.Cache.TLS.Enabled {
-		opts.TLSConfig = &tls.Config{
-			InsecureSkipVerify: config.Redis.Cache.TLS.Insecure,
-		}
+	tlsConfig, err := redisTLSConfig(config.Redis.Cache.TLS)
+	if err != nil {
+		return err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            strings.Split(config.Redis.Cache.Addr, ","),
+		DB:               config.Redis.Cache.DB,
+		Username:         config.Redis.Cache.Username,
+		Password:         config.Redis.Cache.Password,
+		SentinelUsername: config.Redis.Cache.SentinelUsername,
+		SentinelPassword: config.Redis.Cache.SentinelPassword,
+		DialTimeout:      config.Redis.Cache.DialTimeout,
+		ReadTimeout:      config.Redis.Cache.ReadTimeout,
+		WriteTimeout:     config.Redis.Cache.WriteTimeout,
+		PoolSize:         config.Redis.Cache.Pool.Size,
+		ConnMaxLifetime:  config.Redis.Cache.Pool.MaxLifetime,
+		MasterName:       config.Redis.Cache.MainName,
+		TLSConfig:        tlsConfig,
 	}
 	if config.Redis.Cache.Pool.IdleTimeout > 0 {
 		opts.ConnMaxIdleTime = config.Redis.Cache.Pool.IdleTimeout
 	}
 
-	// redis.NewUniversalClient will take care of returning the appropriate client type (single, cluster or sentinel)
-	// depending on the configuration options. See https://pkg.go.dev/github.com/go-redis/redis/v9#NewUniversalClient.
-	redisClient := redis.NewUniversalClient(opts)
+	redisClient := newRedisClient(opts, config.Redis.Cache.Cluster)
 
 	if config.HTTP.Debug.Prometheus.Enabled {
 		redismetrics.InstrumentClient(
@@ -857,39 +1667,53 @@ func (app *App) configureRedisCache(ctx context.Context, config *configuration.C
 
 	redisStore := redisstore.NewRedis(redisClient, libstore.WithExpiration(redisCacheTTL))
 	app.redisCache = gocache.New[any](redisStore)
+	app.redisCacheClient = redisClient
 
 	dlog.GetLogger(dlog.WithContext(app.Context)).Info("redis cache configured successfully")
 
 	return nil
 }
 
-func (app *App) configureRedis(configuration *configuration.Configuration) {
+func (app *App) configureRedis(configuration *configuration.Configuration) error {
 	if configuration.Redis.Addr == "" {
-		return
+		return nil
 	}
 
-	opts := &redis.UniversalOptions{
-		Addrs:           strings.Split(configuration.Redis.Addr, ","),
-		DB:              configuration.Redis.DB,
-		Password:        configuration.Redis.Password,
-		DialTimeout:     configuration.Redis.DialTimeout,
-		ReadTimeout:     configuration.Redis.ReadTimeout,
-		WriteTimeout:    configuration.Redis.WriteTimeout,
-		PoolSize:        configuration.Redis.Pool.Size,
-		ConnMaxLifetime: configuration.Redis.Pool.MaxLifetime,
-		MasterName:      configuration.Redis.MainName,
+	if err := validateRedisTopology(configuration.Redis.MainName, configuration.Redis.DB, configuration.Redis.Cluster); err != nil {
+		return err
 	}
-	if configuration.Redis.TLS.Enabled {
-		opts.TLSConfig = &tls.Config{
-			InsecureSkipVerify: configuration.Redis.TLS.Insecure,
-		}
+	tlsConfig, err := redisTLSConfig(configuration.Redis.TLS)
+	if err != nil {
+		return err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            strings.Split(configuration.Redis.Addr, ","),
+		DB:               configuration.Redis.DB,
+		Username:         configuration.Redis.Username,
+		Password:         configuration.Redis.Password,
+		SentinelUsername: configuration.Redis.SentinelUsername,
+		SentinelPassword: configuration.Redis.SentinelPassword,
+		DialTimeout:      configuration.Redis.DialTimeout,
+		ReadTimeout:      configuration.Redis.ReadTimeout,
+		WriteTimeout:     configuration.Redis.WriteTimeout,
+		PoolSize:         configuration.Redis.Pool.Size,
+		ConnMaxLifetime:  configuration.Redis.Pool.MaxLifetime,
+		MasterName:       configuration.Redis.MainName,
+		TLSConfig:        tlsConfig,
 	}
 	if configuration.Redis.Pool.IdleTimeout > 0 {
 		opts.ConnMaxIdleTime = configuration.Redis.Pool.IdleTimeout
 	}
-	// NewUniversalClient will take care of returning the appropriate client type (simple or sentinel) depending on the
-	// configuration options. See https://pkg.go.dev/github.com/go-redis/redis/v9#NewUniversalClient.
-	app.redis = redis.NewUniversalClient(opts)
+	app.redis = newRedisClient(opts, configuration.Redis.Cluster)
+
+	if configuration.HTTP.Debug.Prometheus.Enabled {
+		redismetrics.InstrumentClient(
+			app.redis,
+			redismetrics.WithInstanceName("main"),
+			redismetrics.WithMaxConns(opts.PoolSize),
+		)
+	}
 
 	// setup expvar
 	registry := expvar.Get("registry")
@@ -906,6 +1730,63 @@ func (app *App) configureRedis(configuration *configuration.Configuration) {
 	}))
 
 	dlog.GetLogger(dlog.WithContext(app.Context)).Info("main redis configured successfully")
+
+	return nil
+}
+
+// validateRedisTopology checks that the settings of a Redis instance do not mix up the Sentinel and Cluster modes,
+// and that no database other than the default one is selected when the Cluster mode is explicitly enabled, as it does
+// not support them.
+func validateRedisTopology(mainName string, db int, cluster bool) error {
+	if mainName != "" && cluster {
+		return errors.New("redis sentinel (mainname) and cluster modes are mutually exclusive")
+	}
+	if db != 0 && cluster {
+		return fmt.Errorf("redis cluster mode only supports database 0, got %d", db)
+	}
+	return nil
+}
+
+// redisTLSConfig returns the TLS configuration for connections to a Redis instance, or nil if TLS is disabled.
+func redisTLSConfig(config configuration.RedisTLS) (*tls.Config, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.Insecure,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading redis TLS CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in redis TLS CA file %q", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading redis TLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// newRedisClient returns a client of the appropriate type for the given options. Unless cluster is set, this is left
+// to redis.NewUniversalClient, which returns a Sentinel client if a main name is set, a Cluster client if there are
+// multiple addresses, or a simple client otherwise. See https://pkg.go.dev/github.com/redis/go-redis/v9#NewUniversalClient.
+// Setting cluster allows using the Cluster mode with a single seed address.
+func newRedisClient(opts *redis.UniversalOptions, cluster bool) redis.UniversalClient {
+	if cluster {
+		return redis.NewClusterClient(opts.Cluster())
+	}
+	return redis.NewUniversalClient(opts)
 }
 
 // configureSecret creates a random secret if a secret wasn't included in the
@@ -925,13 +1806,27 @@ func (app *App) configureSecret(configuration *configuration.Configuration) erro
 func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close() // ensure that request body is always closed.
 
+	// Start a span for the request, as a child of the caller's span if the trace context was propagated. The span is
+	// named after the matched route once known.
+	ctx, span := tracing.StartSpan(tracing.Extract(r.Context(), r.Header), "HTTP "+r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", "", r)...),
+	)
+
 	// Prepare the context with our own little decorations.
-	ctx := r.Context()
 	ctx = dcontext.WithRequest(ctx, r)
 	ctx, w = dcontext.WithResponseWriter(ctx, w)
 	ctx = dcontext.WithLogger(ctx, dcontext.GetRequestCorrelationLogger(ctx))
 	r = r.WithContext(ctx)
 
+	defer func() {
+		if status, ok := ctx.Value("http.response.status").(int); ok {
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+		}
+		span.End()
+	}()
+
 	if app.Config.Log.AccessLog.Disabled {
 		defer func() {
 			status, ok := ctx.Value("http.response.status").(int)
@@ -951,6 +1846,10 @@ type metaRouter struct {
 	distribution *mux.Router // main application router, configured with dispatchers
 	gitlab       *mux.Router // gitlab specific router
 	v1RouteRegex *regexp.Regexp
+
+	// distributionCORS and gitlabCORS are the CORS policies of each API. Nil if CORS is disabled.
+	distributionCORS *corsPolicy
+	gitlabCORS       *corsPolicy
 }
 
 // initMetaRouter constructs a new metaRouter and attaches it to the app.
@@ -974,6 +1873,7 @@ func (app *App) initMetaRouter() error {
 	app.registerDistribution(v2.RouteNameCatalog, catalogDispatcher)
 	app.registerDistribution(v2.RouteNameTags, tagsDispatcher)
 	app.registerDistribution(v2.RouteNameTag, tagDispatcher)
+	app.registerDistribution(v2.RouteNameReferrers, referrersDispatcher)
 	app.registerDistribution(v2.RouteNameBlob, blobDispatcher)
 	app.registerDistribution(v2.RouteNameBlobUpload, blobUploadDispatcher)
 	app.registerDistribution(v2.RouteNameBlobUploadChunk, blobUploadDispatcher)
@@ -984,10 +1884,43 @@ func (app *App) initMetaRouter() error {
 		return http.HandlerFunc(h.GetBase)
 	})
 	app.registerGitlab(v1.RepositoryTags, repositoryTagsDispatcher)
+	app.registerGitlab(v1.RepositoryImmutableTags, repositoryImmutableTagsDispatcher)
+	app.registerGitlab(v1.RepositoryTagHistory, repositoryTagHistoryDispatcher)
+	app.registerGitlab(v1.RepositoryManifest, repositoryManifestDispatcher)
+	app.registerGitlab(v1.RepositoryManifestExport, repositoryManifestExportDispatcher)
+	app.registerGitlab(v1.RepositoryEvents, repositoryEventsDispatcher)
+	app.registerGitlab(v1.RepositoryLayoutImport, repositoryLayoutImportDispatcher)
+	app.registerGitlab(v1.RepositoryPurge, repositoryPurgeDispatcher)
+	app.registerGitlab(v1.RepositoryCleanupPolicyPreview, repositoryCleanupPolicyPreviewDispatcher)
+	app.registerGitlab(v1.RepositoryCleanupPolicy, repositoryCleanupPolicyDispatcher)
+	app.registerGitlab(v1.RepositoryTagsBulkDelete, repositoryTagsBulkDeleteDispatcher)
 	app.registerGitlab(v1.Repositories, repositoryDispatcher)
 	app.registerGitlab(v1.SubRepositories, subRepositoriesDispatcher)
+	app.registerGitlab(v1.RepositorySettings, repositorySettingsDispatcher)
+	app.registerGitlab(v1.RepositoryMetadata, repositoryMetadataDispatcher)
+	app.registerGitlab(v1.RepositoryLabels, repositoryLabelsDispatcher)
+	app.registerGitlab(v1.RepositoryUploads, repositoryUploadsDispatcher)
+	app.registerGitlab(v1.RepositoryUpload, repositoryUploadsDispatcher)
+	app.registerGitlab(v1.GroupRepositories, groupRepositoriesDispatcher)
+	app.registerGitlab(v1.GroupManifestSearch, groupManifestSearchDispatcher)
+	app.registerGitlab(v1.GroupStatistics, groupStatisticsDispatcher)
+	app.registerGitlab(v1.GCAgents, gcAgentsDispatcher)
+	app.registerGitlab(v1.GCReviews, gcReviewsDispatcher)
+	app.registerGitlab(v1.SizeRecalculation, sizeRecalculationDispatcher)
+	app.registerGitlab(v1.BlobDeduplication, deduplicationDispatcher)
+	app.registerGitlab(v1.BlobVerification, blobVerificationDispatcher)
+	app.registerGitlab(v1.Replication, replicationDispatcher)
+	app.registerGitlab(v1.Notifications, notificationsDispatcher)
+	app.registerGitlab(v1.NotificationsReplay, notificationsReplayDispatcher)
 
 	var err error
+	if app.router.distributionCORS, err = newCORSPolicy("distribution", app.Config.HTTP.CORS.Distribution); err != nil {
+		return fmt.Errorf("configuring CORS: %w", err)
+	}
+	if app.router.gitlabCORS, err = newCORSPolicy("gitlab", app.Config.HTTP.CORS.GitLab); err != nil {
+		return fmt.Errorf("configuring CORS: %w", err)
+	}
+
 	v1PathWithPrefix := fmt.Sprintf("^%s%s.*", strings.TrimSuffix(app.Config.HTTP.Prefix, "/"), v1.Base.Path)
 	app.router.v1RouteRegex, err = regexp.Compile(v1PathWithPrefix)
 	if err != nil {
@@ -1000,11 +1933,11 @@ func (app *App) initMetaRouter() error {
 // ServeHTTP delegates urls to the appropriate router.
 func (m *metaRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if m.v1RouteRegex.MatchString(r.URL.Path) {
-		m.gitlab.ServeHTTP(w, r)
+		m.gitlabCORS.serve(w, r, m.gitlab)
 		return
 	}
 
-	m.distribution.ServeHTTP(w, r)
+	m.distributionCORS.serve(w, r, m.distribution)
 }
 
 // Temporary middleware to add router and http configuration information
@@ -1071,7 +2004,19 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 			}
 		}
 
+		done, ok := app.drainer.track(r)
+		if !ok {
+			rejectDraining(r.Context(), w)
+			return
+		}
+		defer done()
+
+		if app.underMaintenance(w, r) {
+			return
+		}
+
 		ctx := app.context(w, r)
+		traceRequest(ctx, r)
 
 		// attach CF-RayID header to context and pass the key to the logger
 		ctx.Context = dcontext.WithCFRayID(ctx.Context, r)
@@ -1090,9 +2035,16 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 		// sync up context on the request.
 		r = r.WithContext(ctx)
 
+		if app.rateLimited(ctx, w, r) {
+			return
+		}
+
 		// get all metadata either from the database or from the filesystem
 		if app.Config.Database.Enabled {
-			ctx.useDatabase = true
+			ctx.useDatabase = !app.dbFallbackToFS(r)
+			if !ctx.useDatabase {
+				dcontext.GetLogger(ctx).Info("database circuit breaker open, serving request from filesystem metadata")
+			}
 		}
 
 		if app.nameRequired(r) {
@@ -1104,19 +2056,24 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 			}
 			ctx.blobProvider = bp
 
-			repository, err := app.repositoryFromContext(ctx, w)
+			registry := app.registry
+			if app.fsMirrorRegistry != nil && (!ctx.useDatabase || (r.Method != http.MethodGet && r.Method != http.MethodHead)) {
+				registry = app.fsMirrorRegistry
+			}
+			repository, err := repositoryFromContextWithRegistry(ctx, w, registry)
 			if err != nil {
 				return
 			}
 
 			ctx.queueBridge = app.queueBridge(ctx, r)
+			ctx.auditActor = getAuditActor(ctx, r)
 
 			// assign and decorate the authorized repository with an event bridge.
 			ctx.Repository, ctx.RepositoryRemover = notifications.Listen(
 				repository,
 				ctx.App.repoRemover,
 				app.eventBridge(ctx, r),
-				// mirroring is always disabled when the database is enabled
+				// mirroring is disabled when using the database, unless falling back to the filesystem metadata
 				ctx.useDatabase)
 
 			ctx.Repository, err = applyRepoMiddleware(app, ctx.Repository, app.Config.Middleware["repository"])
@@ -1135,12 +2092,18 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 			ctx.repoCache = datastore.NewSingleRepositoryCache()
 		}
 
-		dispatch(ctx, r).ServeHTTP(w, r)
+		// Writes are recorded both before and after being served. The former guarantees that reads issued while a write
+		// is in progress use the primary, and the latter that the sticky duration is not shortened by slow writes.
+		app.recordDBWrite(ctx, r)
+		rejectProxyWrites(dispatch(ctx, r), ctx).ServeHTTP(w, r)
+		app.recordDBWrite(ctx, r)
 
 		// Automated error response handling here. Handlers may return their
 		// own errors if they need different behavior (such as range errors
 		// for layer upload).
 		if ctx.Errors.Len() > 0 {
+			ctx.Errors = deadlineExceededErrors(ctx, ctx.Errors)
+			ctx.Errors = requestLimitErrors(ctx, ctx.Errors)
 			if err := errcode.ServeJSON(w, ctx.Errors); err != nil {
 				dcontext.GetLogger(ctx).Errorf("error serving error json: %v (from %v)", err, ctx.Errors)
 			}
@@ -1152,10 +2115,15 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 
 func (app *App) dispatcherGitlab(dispatch dispatchFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.underMaintenance(w, r) {
+			return
+		}
+
 		ctx := &Context{
 			App:     app,
 			Context: dcontext.WithVars(r.Context(), r),
 		}
+		traceRequest(ctx, r)
 		// attach CF-RayID header to context and pass the key to the logger
 		ctx.Context = dcontext.WithCFRayID(ctx.Context, r)
 		ctx.Context = dcontext.WithLogger(ctx.Context, dcontext.GetLogger(ctx.Context, dcontext.CFRayIDLogKey))
@@ -1173,6 +2141,10 @@ func (app *App) dispatcherGitlab(dispatch dispatchFunc) http.Handler {
 		// sync up context on the request.
 		r = r.WithContext(ctx)
 
+		if app.rateLimited(ctx, w, r) {
+			return
+		}
+
 		if app.nameRequired(r) {
 			repository, err := app.repositoryFromContext(ctx, w)
 			if err != nil {
@@ -1180,14 +2152,20 @@ func (app *App) dispatcherGitlab(dispatch dispatchFunc) http.Handler {
 			}
 
 			ctx.Repository = repository
+			ctx.queueBridge = app.queueBridge(ctx, r)
+			ctx.auditActor = getAuditActor(ctx, r)
 		}
 
-		dispatch(ctx, r).ServeHTTP(w, r)
+		// see the equivalent note in dispatcher
+		app.recordDBWrite(ctx, r)
+		rejectProxyWrites(dispatch(ctx, r), ctx).ServeHTTP(w, r)
+		app.recordDBWrite(ctx, r)
 
 		// Automated error response handling here. Handlers may return their
 		// own errors if they need different behavior (such as range errors
 		// for layer upload).
 		if ctx.Errors.Len() > 0 {
+			ctx.Errors = requestLimitErrors(ctx, ctx.Errors)
 			if err := errcode.ServeJSON(w, ctx.Errors); err != nil {
 				dcontext.GetLogger(ctx).Errorf("error serving error json: %v (from %v)", err, ctx.Errors)
 			}
@@ -1253,6 +2231,10 @@ func (app *App) context(w http.ResponseWriter, r *http.Request) *Context {
 	ctx = dcontext.WithVars(ctx, r)
 	name := dcontext.GetStringValue(ctx, "vars.name")
 	ctx = context.WithValue(ctx, "root_repo", strings.Split(name, "/")[0])
+	if app.Config.Isolation.Enabled && name != "" {
+		// blob paths do not identify a repository, so the isolated storage driver relies on the request context
+		ctx = isolation.WithNamespace(ctx, strings.Split(name, "/")[0])
+	}
 	ctx = dcontext.WithLogger(ctx, dcontext.GetLogger(ctx,
 		"root_repo",
 		"vars.name",
@@ -1284,7 +2266,8 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 	dcontext.GetLogger(context).Debug("authorizing request")
 	repo := getName(context)
 
-	if app.accessController == nil {
+	accessController := app.currentAccessController()
+	if accessController == nil {
 		return nil // access controller is not enabled.
 	}
 
@@ -1293,10 +2276,13 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 	if repo != "" {
 		accessRecords = appendAccessRecords(accessRecords, r.Method, repo)
 		accessRecords = appendRepositoryDetailsAccessRecords(accessRecords, r, repo)
+		accessRecords = appendRepositorySettingsAccessRecord(accessRecords, r)
+		accessRecords = appendRepositoryPurgeAccessRecord(accessRecords, r, repo)
+		accessRecords = appendRepositoryUploadsAccessRecord(accessRecords, r)
 
-		if fromRepo := r.FormValue("from"); fromRepo != "" {
-			// mounting a blob from one repository to another requires pull (GET)
-			// access to the source repository.
+		// mounting a blob from one repository to another requires pull (GET) access to the source repository,
+		// unless the source repository lives in a remote registry, which performs its own authorization.
+		if fromRepo := r.FormValue("from"); fromRepo != "" && r.FormValue(remoteMountRegistryParam) == "" {
 			accessRecords = appendAccessRecords(accessRecords, http.MethodGet, fromRepo)
 		}
 	} else {
@@ -1314,9 +2300,12 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 			return fmt.Errorf("forbidden: no repository name")
 		}
 		accessRecords = appendCatalogAccessRecord(accessRecords, r)
+		accessRecords = appendAdminAccessRecord(accessRecords, r)
 	}
 
-	ctx, err := app.accessController.Authorized(context.Context, accessRecords...)
+	authCtx, span := tracing.StartSpan(context.Context, "auth")
+	ctx, err := accessController.Authorized(authCtx, accessRecords...)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		switch err := err.(type) {
 		case auth.Challenge:
@@ -1338,6 +2327,10 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 		return err
 	}
 
+	// the returned context descends from that of the auth span, which has ended, so restore the request span as the
+	// parent of any subsequent spans
+	ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(context.Context))
+
 	dcontext.GetLogger(ctx, auth.UserNameKey, auth.UserTypeKey, auth.ResourceProjectPathsKey).Info("authorized request")
 	context.Context = ctx
 	return nil
@@ -1375,7 +2368,9 @@ func (app *App) nameRequired(r *http.Request) bool {
 	routeName := route.GetName()
 
 	switch routeName {
-	case v2.RouteNameBase, v2.RouteNameCatalog, v1.Base.Name:
+	case v2.RouteNameBase, v2.RouteNameCatalog, v1.Base.Name, v1.GCAgents.Name, v1.GCReviews.Name, v1.SizeRecalculation.Name,
+		v1.BlobDeduplication.Name, v1.BlobVerification.Name, v1.Replication.Name, v1.Notifications.Name,
+		v1.NotificationsReplay.Name:
 		return false
 	}
 
@@ -1460,19 +2455,60 @@ func appendCatalogAccessRecord(accessRecords []auth.Access, r *http.Request) []a
 	return accessRecords
 }
 
+// Add the access record for the online GC agents and reviews, the size recalculation, the deduplication statistics, the
+// blob verification report, the replication status or the notifications queue if it's our current route
+func appendAdminAccessRecord(accessRecords []auth.Access, r *http.Request) []auth.Access {
+	route := mux.CurrentRoute(r)
+	routeName := route.GetName()
+
+	var name string
+	switch routeName {
+	case v1.GCAgents.Name, v1.GCReviews.Name:
+		name = "gc"
+	case v1.SizeRecalculation.Name:
+		name = "sizes"
+	case v1.BlobDeduplication.Name:
+		name = "statistics"
+	case v1.BlobVerification.Name:
+		name = "integrity"
+	case v1.Replication.Name:
+		name = "replication"
+	case v1.Notifications.Name, v1.NotificationsReplay.Name:
+		name = "notifications"
+	}
+
+	if name != "" {
+		resource := auth.Resource{
+			Type: "registry",
+			Name: name,
+		}
+
+		accessRecords = append(accessRecords,
+			auth.Access{
+				Resource: resource,
+				Action:   "*",
+			})
+	}
+	return accessRecords
+}
+
 func appendRepositoryDetailsAccessRecords(accessRecords []auth.Access, r *http.Request, repo string) []auth.Access {
 	route := mux.CurrentRoute(r)
 	routeName := route.GetName()
 
-	// For now, we only have three operations requiring a custom access record, which are:
+	// For now, we only have six operations requiring a custom access record, which are:
 	// 1. for returning the size of a repository including its descendants.
 	// 2. for returning all the repositories under a given repository base path (including the base repository)
-	// 3. renaming a base repository (name and path) and updating the sub-repositories (path) accordingly
-	// These three operations require an access record of type `repository` and name `<name>/*`
+	// 3. for returning all the repositories under a given group path, along with their size and tag details
+	// 4. for searching the manifests of all the repositories under a given group path by annotation
+	// 5. renaming a base repository (name and path) and updating the sub-repositories (path) accordingly
+	// 6. for returning the usage statistics of a top-level namespace, which cover all of its repositories
+	// These six operations require an access record of type `repository` and name `<name>/*`
 	// (to grant access on all descendants), in addition to the standard access record of type `repository` and
 	// name `<name>` (to grant read access to the base repository), which was appended in the preceding call to
 	// `appendAccessRecords`.
-	if routeName == v1.SubRepositories.Name ||
+	if routeName == v1.SubRepositories.Name || routeName == v1.GroupRepositories.Name || routeName == v1.GroupManifestSearch.Name ||
+		routeName == v1.GroupStatistics.Name ||
 		(routeName == v1.Repositories.Name &&
 			(sizeQueryParamValue(r) == sizeQueryParamSelfWithDescendantsValue || r.Method == http.MethodPatch)) {
 		accessRecords = append(accessRecords, auth.Access{
@@ -1487,6 +2523,64 @@ func appendRepositoryDetailsAccessRecords(accessRecords []auth.Access, r *http.R
 	return accessRecords
 }
 
+// appendRepositorySettingsAccessRecord adds an access record of type `registry` and name `settings` for requests that
+// update the settings of a repository. These can relax limits enforced by the registry, so push access to the
+// repository alone is not enough.
+func appendRepositorySettingsAccessRecord(accessRecords []auth.Access, r *http.Request) []auth.Access {
+	if mux.CurrentRoute(r).GetName() != v1.RepositorySettings.Name || r.Method != http.MethodPut {
+		return accessRecords
+	}
+
+	return append(accessRecords, auth.Access{
+		Resource: auth.Resource{
+			Type: "registry",
+			Name: "settings",
+		},
+		Action: "*",
+	})
+}
+
+// appendRepositoryPurgeAccessRecord requires delete access to the target repository to start a purge, as it deletes
+// all of its tags and manifests.
+func appendRepositoryPurgeAccessRecord(accessRecords []auth.Access, r *http.Request, repo string) []auth.Access {
+	if mux.CurrentRoute(r).GetName() != v1.RepositoryPurge.Name || r.Method != http.MethodPost {
+		return accessRecords
+	}
+
+	return appendAccessRecords(accessRecords, http.MethodDelete, repo)
+}
+
+// appendRepositoryUploadsAccessRecord adds an access record of type `registry` and name `uploads` for requests that
+// list or cancel the in-progress uploads of a repository. These expose and affect the uploads of all clients, so
+// access to the repository alone is not enough.
+func appendRepositoryUploadsAccessRecord(accessRecords []auth.Access, r *http.Request) []auth.Access {
+	if name := mux.CurrentRoute(r).GetName(); name != v1.RepositoryUploads.Name && name != v1.RepositoryUpload.Name {
+		return accessRecords
+	}
+
+	return append(accessRecords, auth.Access{
+		Resource: auth.Resource{
+			Type: "registry",
+			Name: "uploads",
+		},
+		Action: "*",
+	})
+}
+
+// redirectOptionList returns the values of the storage.redirect list option with the given name, if any.
+func redirectOptionList(config *configuration.Configuration, name string) []string {
+	list, ok := config.Storage["redirect"][name].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = fmt.Sprint(v)
+	}
+	return s
+}
+
 // applyRegistryMiddleware wraps a registry instance with the configured middlewares
 func applyRegistryMiddleware(ctx context.Context, registry distribution.Namespace, middlewares []configuration.Middleware) (distribution.Namespace, error) {
 	for _, mw := range middlewares {
@@ -1511,6 +2605,29 @@ func applyRepoMiddleware(ctx context.Context, repository distribution.Repository
 	return repository, nil
 }
 
+// retryingStorageDrivers are the storage drivers which only retry the failed requests of file writers themselves, for
+// which the retry storage middleware is applied with its default policies unless configured explicitly.
+var retryingStorageDrivers = map[string]struct{}{"s3": {}, "s3aws": {}, "gcs": {}}
+
+// storageMiddlewareWithRetry returns the storage middlewares to apply for the given storage configuration, adding the
+// retry middleware as the innermost one if the driver relies on it and it's not configured explicitly. It's not added
+// either if retries are disabled in the driver parameters, with maxretries set to 0.
+func storageMiddlewareWithRetry(storage configuration.Storage, middlewares []configuration.Middleware) []configuration.Middleware {
+	if _, ok := retryingStorageDrivers[storage.Type()]; !ok {
+		return middlewares
+	}
+	if v, ok := storage.Parameters()["maxretries"]; ok && fmt.Sprint(v) == "0" {
+		return middlewares
+	}
+	for _, mw := range middlewares {
+		if mw.Name == "retry" {
+			return middlewares
+		}
+	}
+
+	return append([]configuration.Middleware{{Name: "retry"}}, middlewares...)
+}
+
 // applyStorageMiddleware wraps a storage driver with the configured middlewares
 func applyStorageMiddleware(driver storagedriver.StorageDriver, middlewares []configuration.Middleware) (storagedriver.StorageDriver, error) {
 	for _, mw := range middlewares {
@@ -1523,6 +2640,77 @@ func applyStorageMiddleware(driver storagedriver.StorageDriver, middlewares []co
 	return driver, nil
 }
 
+// applyNamespaceIsolation wraps driver in a storage driver that routes the objects of each isolated top-level namespace
+// to its dedicated driver, created from the corresponding configuration. driver remains in use for other namespaces.
+func applyNamespaceIsolation(driver storagedriver.StorageDriver, config configuration.Isolation) (*isolation.Driver, error) {
+	if len(config.Namespaces) == 0 {
+		return nil, errors.New("at least one namespace must be configured")
+	}
+
+	drivers := make(map[string]storagedriver.StorageDriver, len(config.Namespaces))
+	for ns, s := range config.Namespaces {
+		if s.Type() == "" {
+			return nil, fmt.Errorf("no storage driver configured for namespace %q", ns)
+		}
+		params := s.Parameters()
+		if params == nil {
+			params = make(configuration.Parameters)
+		}
+		d, err := factory.Create(s.Type(), params)
+		if err != nil {
+			return nil, fmt.Errorf("creating storage driver for namespace %q: %w", ns, err)
+		}
+		drivers[ns] = d
+	}
+
+	return isolation.New(driver, drivers)
+}
+
+// sharedStorageScope returns the top-level namespaces that repositories must, or must not, belong to in order to share
+// storage with the repository with the given path (see sharesStorage). If namespace is not empty, repositories must
+// belong to it. Otherwise, they must not belong to any of the excluded namespaces.
+func (app *App) sharedStorageScope(path string) (namespace string, excluded []string) {
+	if !app.Config.Isolation.Enabled {
+		return "", nil
+	}
+	ns := strings.Split(path, "/")[0]
+	if _, ok := app.Config.Isolation.Namespaces[ns]; ok {
+		return ns, nil
+	}
+	for name := range app.Config.Isolation.Namespaces {
+		excluded = append(excluded, name)
+	}
+	sort.Strings(excluded)
+
+	return "", excluded
+}
+
+// namespaceDriver returns the storage driver for the objects of the top-level namespace of repository path. This is
+// the dedicated driver of the namespace if it is isolated, and the application driver otherwise.
+func (app *App) namespaceDriver(path string) storagedriver.StorageDriver {
+	if ns := strings.Split(path, "/")[0]; app.isolation != nil && app.isolation.Isolated(ns) {
+		return app.isolation.NamespaceDriver(ns)
+	}
+	return app.driver
+}
+
+// sharesStorage reports whether the objects of repositories a and b are stored in the same storage driver, which is
+// always the case unless namespace isolation is enabled and they belong to different top-level namespaces, at least
+// one of which is isolated.
+func (app *App) sharesStorage(a, b string) bool {
+	if !app.Config.Isolation.Enabled {
+		return true
+	}
+	nsA, nsB := strings.Split(a, "/")[0], strings.Split(b, "/")[0]
+	if nsA == nsB {
+		return true
+	}
+	_, isolatedA := app.Config.Isolation.Namespaces[nsA]
+	_, isolatedB := app.Config.Isolation.Namespaces[nsB]
+
+	return !isolatedA && !isolatedB
+}
+
 // uploadPurgeDefaultConfig provides a default configuration for upload
 // purging to be used in the absence of configuration in the
 // configuration file
@@ -1611,6 +2799,26 @@ func (app *App) GracefulShutdown(ctx context.Context) error {
 	errors := make(chan error)
 
 	go func() {
+		if app.pullTracker != nil {
+			// write pulls recorded since the last flush, errors are logged by the tracker
+			_ = app.pullTracker.Flush(ctx)
+		}
+		if app.namespaceStatistics != nil {
+			// write counters recorded since the last flush, errors are logged by the aggregator
+			_ = app.namespaceStatistics.Flush(ctx)
+		}
+		if app.replicator != nil {
+			// in-flight replications are aborted and queued ones discarded
+			_ = app.replicator.Close()
+		}
+		if app.dbCircuitBreaker != nil {
+			app.dbCircuitBreaker.Close()
+		}
+		if app.dbLoadBalancer != nil {
+			if err := app.dbLoadBalancer.Close(); err != nil {
+				dlog.GetLogger(dlog.WithContext(ctx)).WithError(err).Error("failed to close database replicas")
+			}
+		}
 		errors <- app.db.Close()
 	}()
 
@@ -1625,6 +2833,14 @@ func (app *App) GracefulShutdown(ctx context.Context) error {
 	}
 }
 
+// CloseEventStreams ends the repository event streams, which would otherwise hold their connections open until the
+// HTTP drain timeout expires during shutdown.
+func (app *App) CloseEventStreams() {
+	if app.events.hub != nil {
+		app.events.hub.Disconnect()
+	}
+}
+
 // DBStats returns the sql.DBStats for the metadata database connection handle.
 func (app *App) DBStats() sql.DBStats {
 	return app.db.Stats()
@@ -9,10 +9,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/docker/distribution/configuration"
 	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/reference"
@@ -28,8 +31,12 @@ import (
 	"github.com/docker/distribution/registry/internal/testutil"
 	"github.com/docker/distribution/registry/storage"
 	memorycache "github.com/docker/distribution/registry/storage/cache/memory"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/registry/storage/driver/isolation"
 	"github.com/docker/distribution/registry/storage/driver/testdriver"
 	"github.com/golang/mock/gomock"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -362,6 +369,88 @@ func TestGitlabAPI_GetRepositoryDetails_SelfWithDescendantsAccessRecords(t *test
 	require.Equal(t, expectedAuthHeader, resp.Header.Get("WWW-Authenticate"))
 }
 
+func TestApp_ReplicaDB(t *testing.T) {
+	primarySQLDB, primaryMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer primarySQLDB.Close()
+	replicaSQLDB, replicaMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer replicaSQLDB.Close()
+
+	primary := &datastore.DB{DB: primarySQLDB}
+	replica := &datastore.DB{DB: replicaSQLDB}
+
+	ctx := context.Background()
+
+	// without load balancing, the primary is always used
+	app := &App{db: primary}
+	require.Same(t, primary, app.replicaDB(ctx, "foo/bar"))
+
+	primaryMock.ExpectQuery("pg_current_wal_insert_lsn").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/0"))
+	replicaMock.ExpectQuery("pg_last_xact_replay_timestamp").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+	replicaMock.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+	app.dbLoadBalancer = datastore.NewDBLoadBalancer(primary, []*datastore.DB{replica}, datastore.WithStickyDuration(time.Hour))
+	app.dbLoadBalancer.CheckReplicas(ctx)
+	require.Same(t, replica, app.replicaDB(ctx, "foo/bar"))
+
+	registry, err := storage.NewRegistry(ctx, testdriver.New())
+	require.NoError(t, err)
+	named, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := registry.Repository(ctx, named)
+	require.NoError(t, err)
+	appCtx := &Context{App: app, Context: ctx, Repository: repo}
+
+	// reads are not recorded
+	app.recordDBWrite(appCtx, httptest.NewRequest(http.MethodGet, "/v2/foo/bar/tags/list", nil))
+	app.recordDBWrite(appCtx, httptest.NewRequest(http.MethodHead, "/v2/foo/bar/manifests/latest", nil))
+	require.Same(t, replica, app.replicaDB(ctx, "foo/bar"))
+
+	// writes make read-only queries for the same repository stick to the primary
+	app.recordDBWrite(appCtx, httptest.NewRequest(http.MethodPut, "/v2/foo/bar/manifests/latest", nil))
+	require.Same(t, primary, app.replicaDB(ctx, "foo/bar"))
+	require.Same(t, replica, app.replicaDB(ctx, "foo/baz"))
+	require.Same(t, replica, app.replicaDB(ctx, ""))
+
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestApp_DBFallbackToFS(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	ctx := context.Background()
+	registry, err := storage.NewRegistry(ctx, testdriver.New())
+	require.NoError(t, err)
+
+	get := httptest.NewRequest(http.MethodGet, "/v2/foo/bar/manifests/latest", nil)
+	head := httptest.NewRequest(http.MethodHead, "/v2/foo/bar/manifests/latest", nil)
+	put := httptest.NewRequest(http.MethodPut, "/v2/foo/bar/manifests/latest", nil)
+
+	// without a circuit breaker, there is never a fallback
+	app := &App{fsMirrorRegistry: registry}
+	require.False(t, app.dbFallbackToFS(get))
+
+	cb := datastore.NewCircuitBreaker(&datastore.DB{DB: sqlDB}, datastore.WithCircuitBreakerThresholds(1, 1))
+	app.dbCircuitBreaker = cb
+	require.False(t, app.dbFallbackToFS(get))
+
+	// once open, reads fall back to the filesystem metadata but writes do not
+	mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("database down"))
+	cb.Check(ctx)
+	require.True(t, app.dbFallbackToFS(get))
+	require.True(t, app.dbFallbackToFS(head))
+	require.False(t, app.dbFallbackToFS(put))
+
+	// without filesystem mirroring, there is nothing to fall back to
+	app.fsMirrorRegistry = nil
+	require.False(t, app.dbFallbackToFS(get))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func Test_updateOnlineGCSettings_SkipIfDatabaseDisabled(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	dbMock := dbmock.NewMockHandler(ctrl)
@@ -749,3 +838,175 @@ func bufferStreamLogger(ctx context.Context, buf *bytes.Buffer) *logrus.Entry {
 
 	return logger.WithFields(fields)
 }
+
+func TestValidateRedisTopology(t *testing.T) {
+	require.NoError(t, validateRedisTopology("", 0, false))
+	require.NoError(t, validateRedisTopology("mymain", 1, false))
+	require.NoError(t, validateRedisTopology("", 0, true))
+	require.EqualError(t, validateRedisTopology("mymain", 0, true), "redis sentinel (mainname) and cluster modes are mutually exclusive")
+	require.EqualError(t, validateRedisTopology("", 1, true), "redis cluster mode only supports database 0, got 1")
+}
+
+func TestRedisTLSConfig(t *testing.T) {
+	got, err := redisTLSConfig(configuration.RedisTLS{Insecure: true})
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	got, err = redisTLSConfig(configuration.RedisTLS{Enabled: true, Insecure: true})
+	require.NoError(t, err)
+	require.True(t, got.InsecureSkipVerify)
+	require.Nil(t, got.RootCAs)
+	require.Empty(t, got.Certificates)
+
+	dir := t.TempDir()
+	_, err = redisTLSConfig(configuration.RedisTLS{Enabled: true, CAFile: filepath.Join(dir, "missing.pem")})
+	require.ErrorContains(t, err, "reading redis TLS CA file")
+
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0600))
+	_, err = redisTLSConfig(configuration.RedisTLS{Enabled: true, CAFile: caFile})
+	require.ErrorContains(t, err, "no valid certificates found in redis TLS CA file")
+
+	_, err = redisTLSConfig(configuration.RedisTLS{Enabled: true, CertFile: caFile, KeyFile: caFile})
+	require.ErrorContains(t, err, "loading redis TLS client certificate")
+}
+
+func TestNewRedisClient(t *testing.T) {
+	tt := []struct {
+		name     string
+		opts     *redis.UniversalOptions
+		cluster  bool
+		expected redis.UniversalClient
+	}{
+		{
+			name:     "simple",
+			opts:     &redis.UniversalOptions{Addrs: []string{"0.0.0.0:6379"}},
+			expected: &redis.Client{},
+		},
+		{
+			name:     "sentinel",
+			opts:     &redis.UniversalOptions{Addrs: []string{"0.0.0.0:26379", "0.0.0.0:26380"}, MasterName: "mymain"},
+			expected: &redis.Client{},
+		},
+		{
+			name:     "cluster with multiple addresses",
+			opts:     &redis.UniversalOptions{Addrs: []string{"0.0.0.0:16379", "0.0.0.0:16380"}},
+			expected: &redis.ClusterClient{},
+		},
+		{
+			name:     "cluster with single address",
+			opts:     &redis.UniversalOptions{Addrs: []string{"0.0.0.0:16379"}},
+			cluster:  true,
+			expected: &redis.ClusterClient{},
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			c := newRedisClient(test.opts, test.cluster)
+			t.Cleanup(func() { c.Close() })
+			require.IsType(t, test.expected, c)
+		})
+	}
+}
+
+func TestApplyNamespaceIsolation(t *testing.T) {
+	def := inmemory.New()
+
+	_, err := applyNamespaceIsolation(def, configuration.Isolation{Enabled: true})
+	require.EqualError(t, err, "at least one namespace must be configured")
+
+	_, err = applyNamespaceIsolation(def, configuration.Isolation{
+		Enabled:    true,
+		Namespaces: map[string]configuration.Storage{"tenant": {}},
+	})
+	require.EqualError(t, err, `no storage driver configured for namespace "tenant"`)
+
+	_, err = applyNamespaceIsolation(def, configuration.Isolation{
+		Enabled:    true,
+		Namespaces: map[string]configuration.Storage{"tenant": {"foo": nil}},
+	})
+	require.Error(t, err)
+
+	d, err := applyNamespaceIsolation(def, configuration.Isolation{
+		Enabled:    true,
+		Namespaces: map[string]configuration.Storage{"tenant": {"inmemory": nil}},
+	})
+	require.NoError(t, err)
+	require.True(t, d.Isolated("tenant"))
+}
+
+func TestApp_NamespaceDriver(t *testing.T) {
+	def := inmemory.New()
+	app := &App{driver: def}
+	require.Equal(t, def, app.namespaceDriver("a/foo"))
+
+	tenant := inmemory.New()
+	d, err := isolation.New(def, map[string]storagedriver.StorageDriver{"a": tenant})
+	require.NoError(t, err)
+	app = &App{driver: d, isolation: d}
+	require.Same(t, tenant, app.namespaceDriver("a/foo/bar"))
+	require.Same(t, d, app.namespaceDriver("b/foo"))
+}
+
+func TestApp_SharesStorage(t *testing.T) {
+	app := &App{Config: &configuration.Configuration{}}
+	require.True(t, app.sharesStorage("a/foo", "b/bar"))
+
+	app.Config.Isolation = configuration.Isolation{
+		Enabled:    true,
+		Namespaces: map[string]configuration.Storage{"a": {"inmemory": nil}, "b": {"inmemory": nil}},
+	}
+	require.True(t, app.sharesStorage("a/foo", "a/bar/baz"))
+	require.False(t, app.sharesStorage("a/foo", "b/bar"))
+	require.False(t, app.sharesStorage("a/foo", "c/bar"))
+	require.True(t, app.sharesStorage("c/foo", "d/bar"))
+}
+
+func TestApp_SharedStorageScope(t *testing.T) {
+	app := &App{Config: &configuration.Configuration{}}
+	ns, excluded := app.sharedStorageScope("a/foo")
+	require.Empty(t, ns)
+	require.Empty(t, excluded)
+
+	app.Config.Isolation = configuration.Isolation{
+		Enabled:    true,
+		Namespaces: map[string]configuration.Storage{"b": {"inmemory": nil}, "a": {"inmemory": nil}},
+	}
+	ns, excluded = app.sharedStorageScope("a/foo")
+	require.Equal(t, "a", ns)
+	require.Empty(t, excluded)
+
+	ns, excluded = app.sharedStorageScope("c/foo")
+	require.Empty(t, ns)
+	require.Equal(t, []string{"a", "b"}, excluded)
+}
+
+func TestStorageMiddlewareWithRetry(t *testing.T) {
+	redirect := configuration.Middleware{Name: "redirect", Options: configuration.Parameters{"baseurl": "https://example.com"}}
+	retry := configuration.Middleware{Name: "retry", Options: configuration.Parameters{"read": map[string]interface{}{"maxattempts": 5}}}
+	storage := func(storageType string, params configuration.Parameters) configuration.Storage {
+		return configuration.Storage{storageType: params}
+	}
+
+	// drivers which do not rely on the retry middleware are left as is
+	require.Equal(t, []configuration.Middleware{redirect}, storageMiddlewareWithRetry(storage("filesystem", nil), []configuration.Middleware{redirect}))
+	require.Empty(t, storageMiddlewareWithRetry(storage("inmemory", nil), nil))
+
+	// the retry middleware is added as the innermost one otherwise
+	require.Equal(t, []configuration.Middleware{{Name: "retry"}, redirect}, storageMiddlewareWithRetry(storage("s3", nil), []configuration.Middleware{redirect}))
+	require.Equal(t, []configuration.Middleware{{Name: "retry"}}, storageMiddlewareWithRetry(storage("gcs", nil), nil))
+	require.Equal(t, []configuration.Middleware{{Name: "retry"}}, storageMiddlewareWithRetry(storage("s3", configuration.Parameters{"maxretries": 3}), nil))
+
+	// unless configured explicitly
+	require.Equal(t, []configuration.Middleware{redirect, retry}, storageMiddlewareWithRetry(storage("s3aws", nil), []configuration.Middleware{redirect, retry}))
+
+	// or retries are disabled in the driver
+	require.Equal(t, []configuration.Middleware{redirect}, storageMiddlewareWithRetry(storage("s3", configuration.Parameters{"maxretries": 0}), []configuration.Middleware{redirect}))
+	require.Empty(t, storageMiddlewareWithRetry(storage("s3", configuration.Parameters{"maxretries": "0"}), nil))
+
+	d, err := applyStorageMiddleware(inmemory.New(), storageMiddlewareWithRetry(storage("gcs", nil), nil))
+	require.NoError(t, err)
+	_, ok := d.(*inmemory.Driver)
+	require.False(t, ok, "expected the driver to be wrapped by the retry middleware")
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/audit"
+)
+
+// newAuditLogger creates an audit logger writing to the sink selected in config.
+func newAuditLogger(config configuration.Audit) (*audit.Logger, error) {
+	var (
+		sink audit.Sink
+		err  error
+	)
+	switch config.Sink {
+	case configuration.AuditSinkFile:
+		if config.File.Path == "" {
+			return nil, fmt.Errorf("file.path is required for the %q sink", config.Sink)
+		}
+		sink, err = audit.NewFileSink(config.File.Path)
+	case configuration.AuditSinkSyslog:
+		sink, err = audit.NewSyslogSink(config.Syslog.Network, config.Syslog.Addr, config.Syslog.Tag)
+	case configuration.AuditSinkHTTP:
+		if config.HTTP.URL == "" {
+			return nil, fmt.Errorf("http.url is required for the %q sink", config.Sink)
+		}
+		sink = audit.NewHTTPSink(config.HTTP.URL, config.HTTP.Timeout, config.HTTP.Headers)
+	default:
+		return nil, fmt.Errorf("unknown sink %q, must be one of %q, %q or %q", config.Sink,
+			configuration.AuditSinkFile, configuration.AuditSinkSyslog, configuration.AuditSinkHTTP)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []audit.LoggerOption
+	if config.HMACKey != "" {
+		opts = append(opts, audit.WithHMACKey([]byte(config.HMACKey)))
+	}
+
+	return audit.NewLogger(sink, opts...), nil
+}
+
+// getAuditActor resolves the actor of the request for the audit log.
+func getAuditActor(ctx *Context, r *http.Request) audit.Actor {
+	return audit.Actor{
+		Name: getUserName(ctx, r),
+		Type: getUserType(ctx),
+		Addr: dcontext.RemoteIP(r),
+	}
+}
+
+// recordAudit records a write operation on the current repository in the audit log, if enabled.
+func (ctx *Context) recordAudit(r audit.Record) {
+	if ctx.auditLogger == nil {
+		return
+	}
+
+	r.Actor = ctx.auditActor
+	if ctx.Repository != nil {
+		r.Repository = ctx.Repository.Named().Name()
+	}
+	ctx.auditLogger.Log(ctx, r)
+}
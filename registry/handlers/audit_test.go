@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuditLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  configuration.Audit
+		wantErr string
+	}{
+		{
+			name: "file",
+			config: configuration.Audit{
+				Sink: configuration.AuditSinkFile,
+				File: configuration.AuditFile{Path: filepath.Join(t.TempDir(), "audit.log")},
+			},
+		},
+		{
+			name:    "file without path",
+			config:  configuration.Audit{Sink: configuration.AuditSinkFile},
+			wantErr: `file.path is required for the "file" sink`,
+		},
+		{
+			name: "http",
+			config: configuration.Audit{
+				Sink:    configuration.AuditSinkHTTP,
+				HMACKey: "secret",
+				HTTP:    configuration.AuditHTTP{URL: "https://audit.example.com"},
+			},
+		},
+		{
+			name:    "http without url",
+			config:  configuration.Audit{Sink: configuration.AuditSinkHTTP},
+			wantErr: `http.url is required for the "http" sink`,
+		},
+		{
+			name:    "unknown sink",
+			config:  configuration.Audit{Sink: "foo"},
+			wantErr: `unknown sink "foo"`,
+		},
+		{
+			name:    "no sink",
+			wantErr: `unknown sink ""`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, err := newAuditLogger(test.config)
+			if test.wantErr != "" {
+				require.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, l)
+			require.NoError(t, l.Close())
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/gorilla/handlers"
 	"github.com/opencontainers/go-digest"
 )
@@ -45,7 +46,7 @@ func blobDispatcher(ctx *Context, r *http.Request) http.Handler {
 	if !ctx.readOnly {
 		mhandler[http.MethodDelete] = http.HandlerFunc(blobHandler.DeleteBlob)
 	}
-	return checkOngoingRename(mhandler, ctx)
+	return checkOngoingRename(checkReadOnlyRepository(mhandler, ctx), ctx)
 }
 
 // blobHandler serves http blob requests.
@@ -93,7 +94,12 @@ func (bh *blobHandler) GetBlob(w http.ResponseWriter, r *http.Request) {
 	blobs := bh.Repository.Blobs(bh)
 
 	if bh.useDatabase {
-		if err := dbBlobLinkExists(bh.Context, bh.db, bh.Repository.Named().Name(), bh.Digest); err != nil {
+		err := dbBlobLinkExists(bh.Context, bh.db, bh.Repository.Named().Name(), bh.Digest)
+		var ec errcode.Error
+		if err != nil && bh.proxyEnabled() && errors.As(err, &ec) && ec.Code == v2.ErrorCodeBlobUnknown {
+			err = bh.fetchProxyBlob()
+		}
+		if err != nil {
 			bh.Errors = append(bh.Errors, errcode.FromUnknownError(err))
 			return
 		}
@@ -192,6 +198,8 @@ func (bh *blobHandler) DeleteBlob(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	bh.recordAudit(audit.Record{Action: audit.ActionDelete, Artifact: audit.ArtifactBlob, Digest: bh.Digest})
+
 	w.Header().Set("Content-Length", "0")
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -4,21 +4,41 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/log"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/gorilla/handlers"
 	"github.com/opencontainers/go-digest"
 )
 
+const (
+	// uploadBytesPerSecondHeader is the upload status header with the average rate at which the upload data was
+	// received since the upload started.
+	uploadBytesPerSecondHeader = "Gitlab-Upload-Bytes-Per-Second"
+	// uploadSecondsSinceLastChunkHeader is the upload status header with the time elapsed since the last chunk was
+	// received. Omitted if no data was received yet.
+	uploadSecondsSinceLastChunkHeader = "Gitlab-Upload-Seconds-Since-Last-Chunk"
+	// uploadPartialDigestHeader is the upload status header with the digest of the data received so far. Omitted if
+	// unknown.
+	uploadPartialDigestHeader = "Gitlab-Upload-Partial-Digest"
+)
+
 // blobUploadDispatcher constructs and returns the blob upload handler for the
 // given request context.
 func blobUploadDispatcher(ctx *Context, r *http.Request) http.Handler {
@@ -45,14 +65,18 @@ func blobUploadDispatcher(ctx *Context, r *http.Request) http.Handler {
 func (buh *blobUploadHandler) validateUpload(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if buh.UUID != "" {
+			// let the storage driver know how much data is about to be written, if known
+			if (r.Method == http.MethodPatch || r.Method == http.MethodPut) && r.ContentLength > 0 {
+				buh.Context.Context = storagedriver.WithSizeHint(buh.Context.Context, r.ContentLength)
+			}
 			h := buh.ResumeBlobUpload(buh.Context, r)
 			if h == nil {
 				h = closeResources(handler, buh.Upload)
 			}
-			checkOngoingRename(h, buh.Context).ServeHTTP(w, r)
+			checkOngoingRename(checkReadOnlyRepository(h, buh.Context), buh.Context).ServeHTTP(w, r)
 			return
 		}
-		checkOngoingRename(handler, buh.Context).ServeHTTP(w, r)
+		checkOngoingRename(checkReadOnlyRepository(handler, buh.Context), buh.Context).ServeHTTP(w, r)
 	})
 }
 
@@ -92,7 +116,8 @@ func dbMountBlob(ctx context.Context, rStore datastore.RepositoryStore, fromRepo
 }
 
 // StartBlobUpload begins the blob upload process and allocates a server-side
-// blob writer session, optionally mounting the blob from a separate repository.
+// blob writer session, optionally mounting the blob from a separate repository,
+// either local or hosted in a remote registry.
 func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Request) {
 	var options []distribution.BlobCreateOption
 	var rStore datastore.RepositoryStore
@@ -107,6 +132,20 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 
 	fromRepo := r.FormValue("from")
 	mountDigest := r.FormValue("mount")
+	if registry := r.FormValue(remoteMountRegistryParam); registry != "" {
+		buh.remoteMountBlob(w, r, registry, fromRepo, mountDigest)
+		return
+	}
+
+	directParts, err := buh.directUploadParts(r)
+	if err != nil {
+		buh.Errors = append(buh.Errors, err)
+		return
+	}
+
+	if mountDigest != "" && fromRepo == "" {
+		fromRepo = buh.anonymousMountSource(mountDigest, rStore)
+	}
 	if mountDigest != "" && fromRepo != "" {
 		opt, err := buh.createBlobMountOption(fromRepo, mountDigest, rStore)
 		if opt != nil && err == nil {
@@ -129,7 +168,9 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 			}
 			if err = buh.writeBlobCreatedHeaders(w, ebm.Descriptor); err != nil {
 				buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+				return
 			}
+			buh.recordAudit(audit.Record{Action: audit.ActionMount, Artifact: audit.ArtifactBlob, Digest: ebm.Descriptor.Digest, MediaType: ebm.Descriptor.MediaType})
 		} else if errors.Is(err, distribution.ErrUnsupported) {
 			buh.Errors = append(buh.Errors, errcode.ErrorCodeUnsupported)
 		} else {
@@ -139,6 +180,21 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 	}
 
 	buh.Upload = upload
+	buh.trackUpload()
+	buh.drainer.startSession(upload.ID())
+
+	// a digest means that the request body is the whole blob, which is uploaded in a single request (monolithic upload)
+	if dgstStr := r.FormValue("digest"); dgstStr != "" {
+		buh.monolithicUpload(w, r, dgstStr)
+		return
+	}
+
+	if directParts > 0 {
+		if err := buh.directUploadResponse(w, r, directParts); err != nil {
+			buh.Errors = append(buh.Errors, errcode.FromUnknownError(err))
+		}
+		return
+	}
 
 	if err := buh.blobUploadResponse(w, r, true); err != nil {
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
@@ -149,6 +205,34 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// monolithicUpload writes the request body to the upload and commits it right away, as the blob identified by dgstStr.
+func (buh *blobUploadHandler) monolithicUpload(w http.ResponseWriter, r *http.Request, dgstStr string) {
+	dgst, err := digest.Parse(dgstStr)
+	if err != nil {
+		buh.Errors = append(buh.Errors, v2.ErrorCodeDigestInvalid.WithDetail("digest parsing failed"))
+		buh.cancelUpload()
+		return
+	}
+
+	if err := copyFullPayload(buh, w, r, buh.Upload, -1, "blob POST"); err != nil {
+		buh.Errors = append(buh.Errors, errcode.FromUnknownError(err))
+		buh.cancelUpload()
+		return
+	}
+
+	buh.completeUpload(w, dgst)
+}
+
+// cancelUpload cancels the upload after an error, freeing its resources.
+func (buh *blobUploadHandler) cancelUpload() {
+	if err := buh.Upload.Cancel(buh); err != nil {
+		// If the cleanup fails, all we can do is observe and report.
+		log.GetLogger(log.WithContext(buh)).WithError(err).Error("error canceling upload after error")
+	}
+	buh.drainer.endSession(buh.Upload.ID())
+	buh.untrackUpload()
+}
+
 // GetUploadStatus returns the status of a given upload, identified by id.
 func (buh *blobUploadHandler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
 	if buh.Upload == nil {
@@ -160,11 +244,35 @@ func (buh *blobUploadHandler) GetUploadStatus(w http.ResponseWriter, r *http.Req
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		return
 	}
+	buh.writeUploadProgressHeaders(w)
 
 	w.Header().Set("Docker-Upload-UUID", buh.UUID)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// writeUploadProgressHeaders writes headers describing the progress of the upload, so that stalled uploads can be
+// diagnosed. Failures to determine the progress are only logged, as the upload status is still valid without them.
+func (buh *blobUploadHandler) writeUploadProgressHeaders(w http.ResponseWriter) {
+	up, err := storage.StatUpload(buh, buh.App.driver, buh.Repository.Named().Name(), buh.UUID)
+	if err != nil {
+		log.GetLogger(log.WithContext(buh)).WithError(err).Warn("failed to determine upload progress")
+		return
+	}
+
+	// the size recorded in the storage backend may lag behind for drivers that write files in parts, in which case the
+	// partial digest doesn't cover all the data received
+	if up.PartialDigest != "" && up.Size == buh.State.Offset {
+		w.Header().Set(uploadPartialDigestHeader, up.PartialDigest.String())
+	}
+	up.Size = buh.State.Offset
+
+	now := time.Now()
+	w.Header().Set(uploadBytesPerSecondHeader, strconv.FormatInt(up.BytesPerSecond(now), 10))
+	if !up.LastChunkAt.IsZero() {
+		w.Header().Set(uploadSecondsSinceLastChunkHeader, strconv.FormatInt(int64(now.Sub(up.LastChunkAt).Seconds()), 10))
+	}
+}
+
 // PatchBlobData writes data to an upload.
 func (buh *blobUploadHandler) PatchBlobData(w http.ResponseWriter, r *http.Request) {
 	if buh.Upload == nil {
@@ -172,6 +280,11 @@ func (buh *blobUploadHandler) PatchBlobData(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if buh.State.Direct {
+		buh.Errors = append(buh.Errors, v2.ErrorCodeDirectUploadInvalid.WithDetail("data of direct uploads must be uploaded to the storage backend"))
+		return
+	}
+
 	ct := r.Header.Get("Content-Type")
 	if ct != "" && ct != "application/octet-stream" {
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(fmt.Errorf("bad Content-Type")))
@@ -181,7 +294,7 @@ func (buh *blobUploadHandler) PatchBlobData(w http.ResponseWriter, r *http.Reque
 	chunkRange := r.Header.Get("Content-Range")
 
 	if chunkRange != "" {
-		startRange, _, err := parseContentRange(chunkRange)
+		startRange, endRange, err := parseContentRange(chunkRange)
 		if err != nil {
 			buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err.Error()))
 			return
@@ -192,13 +305,65 @@ func (buh *blobUploadHandler) PatchBlobData(w http.ResponseWriter, r *http.Reque
 			buh.Errors = append(buh.Errors, v2.ErrorCodeInvalidContentRange)
 			return
 		}
+		size := endRange - startRange + 1
+		if err := validateChunkSize(buh.Config.Uploads.Chunks, size, buh.State.ShortChunk); err != nil {
+			buh.Errors = append(buh.Errors, v2.ErrorCodeInvalidContentRange.WithDetail(err.Error()))
+			return
+		}
+		if r.ContentLength >= 0 && r.ContentLength != size {
+			detail := fmt.Sprintf("Content-Length %d does not match the Content-Range size of %d bytes", r.ContentLength, size)
+			buh.Errors = append(buh.Errors, v2.ErrorCodeInvalidContentRange.WithDetail(detail))
+			return
+		}
+		// guard against clients sending more data than declared in the range when the body length is not known
+		r.Body = &chunkBody{ReadCloser: r.Body, remaining: size, errExceeded: errChunkExceedsRange}
+		// only the final chunk may be below the minimum size, so any further chunk is rejected
+		if minSize := buh.Config.Uploads.Chunks.MinSize; minSize > 0 && size < minSize {
+			buh.State.ShortChunk = true
+		}
+	} else if maxSize := buh.Config.Uploads.Chunks.MaxSize; maxSize > 0 {
+		// streamed chunks are not declared upfront, so their size is only known once received
+		if r.ContentLength > maxSize {
+			detail := fmt.Sprintf("chunk size %d exceeds the maximum of %d bytes", r.ContentLength, maxSize)
+			buh.Errors = append(buh.Errors, v2.ErrorCodeInvalidContentRange.WithDetail(detail))
+			return
+		}
+		r.Body = &chunkBody{ReadCloser: r.Body, remaining: maxSize, errExceeded: errChunkExceedsMaxSize}
 	}
 
-	if err := copyFullPayload(buh, w, r, buh.Upload, -1, "blob PATCH"); err != nil {
+	// the data in the request body is validated against the client supplied digest, if any, as it is received. This
+	// allows clients to detect corrupt chunks right away instead of only when completing the upload.
+	var dest io.Writer = buh.Upload
+	var verifier digest.Verifier
+	if dgstStr := r.FormValue("digest"); dgstStr != "" {
+		dgst, err := digest.Parse(dgstStr)
+		if err != nil {
+			buh.Errors = append(buh.Errors, v2.ErrorCodeDigestInvalid.WithDetail("digest parsing failed"))
+			return
+		}
+		verifier = dgst.Verifier()
+		dest = io.MultiWriter(buh.Upload, verifier)
+	}
+
+	if err := copyFullPayload(buh, w, r, dest, -1, "blob PATCH"); err != nil {
+		if errors.Is(err, errChunkExceedsRange) || errors.Is(err, errChunkExceedsMaxSize) {
+			// the data within the limit was already written and can not be removed from the upload, so it must be
+			// restarted
+			buh.Errors = append(buh.Errors, v2.ErrorCodeInvalidContentRange.WithDetail(err.Error()))
+			buh.cancelUpload()
+			return
+		}
 		buh.Errors = append(buh.Errors, errcode.FromUnknownError(err))
 		return
 	}
 
+	if verifier != nil && !verifier.Verified() {
+		// the corrupt data was already written and can not be removed from the upload, so it must be restarted
+		buh.Errors = append(buh.Errors, v2.ErrorCodeDigestInvalid.WithDetail("chunk digest did not match uploaded content"))
+		buh.cancelUpload()
+		return
+	}
+
 	if err := buh.blobUploadResponse(w, r, false); err != nil {
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		return
@@ -207,10 +372,12 @@ func (buh *blobUploadHandler) PatchBlobData(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusAccepted)
 }
 
-func dbPutBlobUploadComplete(ctx context.Context, db *datastore.DB, repoPath string, desc distribution.Descriptor, repoStoreOpts []datastore.RepositoryStoreOption) error {
+// dbPutBlobUploadComplete creates the uploaded blob in the database, if needed, and links it to the repository, which is
+// also created if needed. Returns the repository.
+func dbPutBlobUploadComplete(ctx context.Context, db *datastore.DB, repoPath string, desc distribution.Descriptor, repoStoreOpts []datastore.RepositoryStoreOption) (*models.Repository, error) {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("beginning database transaction: %w", err)
+		return nil, fmt.Errorf("beginning database transaction: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -222,7 +389,7 @@ func dbPutBlobUploadComplete(ctx context.Context, db *datastore.DB, repoPath str
 		Size:      desc.Size,
 	}
 	if err := bs.CreateOrFind(ctx, b); err != nil {
-		return err
+		return nil, err
 	}
 
 	// create or find repository
@@ -230,19 +397,19 @@ func dbPutBlobUploadComplete(ctx context.Context, db *datastore.DB, repoPath str
 
 	r, err := rStore.CreateOrFindByPath(ctx, repoPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// link blob to repository
 	if err := rStore.LinkBlob(ctx, r, b.Digest); err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("committing database transaction: %w", err)
+		return nil, fmt.Errorf("committing database transaction: %w", err)
 	}
 
-	return nil
+	return r, nil
 }
 
 // PutBlobUploadComplete takes the final request of a blob upload. The
@@ -271,11 +438,27 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		return
 	}
 
+	if buh.State.Direct {
+		// the data was uploaded directly to the storage backend, so there must be nothing else to write
+		if r.ContentLength != 0 {
+			buh.Errors = append(buh.Errors, v2.ErrorCodeDirectUploadInvalid.WithDetail("data of direct uploads must be uploaded to the storage backend"))
+			return
+		}
+		buh.completeUpload(w, dgst)
+		return
+	}
+
 	if err := copyFullPayload(buh, w, r, buh.Upload, -1, "blob PUT"); err != nil {
 		buh.Errors = append(buh.Errors, errcode.FromUnknownError(err))
 		return
 	}
 
+	buh.completeUpload(w, dgst)
+}
+
+// completeUpload commits the upload, verifying the written data against the given digest. The upload is canceled if
+// the commit fails.
+func (buh *blobUploadHandler) completeUpload(w http.ResponseWriter, dgst digest.Digest) {
 	desc, err := buh.Upload.Commit(buh, distribution.Descriptor{
 		Digest: dgst,
 	})
@@ -303,26 +486,28 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		}
 
 		// Clean up the backend blob data if there was an error.
-		if err := buh.Upload.Cancel(buh); err != nil {
-			// If the cleanup fails, all we can do is observe and report.
-			l.WithError(err).Error("error canceling upload after error")
-		}
+		buh.cancelUpload()
 
 		return
 	}
+	buh.drainer.endSession(buh.Upload.ID())
 
 	if buh.useDatabase {
 		var opts []datastore.RepositoryStoreOption
 		if buh.App.redisCache != nil {
 			opts = append(opts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(buh.App.redisCache)))
 		}
-		if err := dbPutBlobUploadComplete(buh.Context, buh.db, buh.Repository.Named().Name(), desc, opts); err != nil {
+		repo, err := dbPutBlobUploadComplete(buh.Context, buh.db, buh.Repository.Named().Name(), desc, opts)
+		if err != nil {
 			e := fmt.Errorf("failed to create blob in database: %w", err)
 			buh.Errors = append(buh.Errors, errcode.FromUnknownError(e))
 			return
 		}
+		buh.recordNamespaceBytesAdded(repo.NamespaceID, desc.Size)
 	}
 
+	buh.untrackUpload()
+
 	if err := buh.writeBlobCreatedHeaders(w, desc); err != nil {
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		return
@@ -333,6 +518,7 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		"size_bytes": desc.Size,
 		"digest":     desc.Digest,
 	}).Info("blob uploaded")
+	buh.recordAudit(audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactBlob, Digest: desc.Digest, MediaType: desc.MediaType})
 }
 
 // CancelBlobUpload cancels an in-progress upload of a blob.
@@ -347,6 +533,8 @@ func (buh *blobUploadHandler) CancelBlobUpload(w http.ResponseWriter, r *http.Re
 		log.GetLogger(log.WithContext(buh)).WithError(err).Error("error encountered canceling upload")
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 	}
+	buh.drainer.endSession(buh.Upload.ID())
+	buh.untrackUpload()
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -397,7 +585,8 @@ func (buh *blobUploadHandler) ResumeBlobUpload(ctx *Context, r *http.Request) ht
 	}
 	buh.Upload = upload
 
-	if size := upload.Size(); size != buh.State.Offset {
+	// the data of direct uploads is written to the storage backend by the client, so the offset is not known
+	if size := upload.Size(); size != buh.State.Offset && !buh.State.Direct {
 		defer upload.Close()
 		log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{
 			"upload_size":  size,
@@ -406,8 +595,15 @@ func (buh *blobUploadHandler) ResumeBlobUpload(ctx *Context, r *http.Request) ht
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			buh.Errors = append(buh.Errors, v2.ErrorCodeBlobUploadInvalid.WithDetail(err))
 			upload.Cancel(buh)
+			buh.drainer.endSession(upload.ID())
 		})
 	}
+
+	// the upload may have been started on another instance, or before a restart, so writing to it opens its session
+	// here, to be waited for when draining
+	if r.Method == http.MethodPatch || r.Method == http.MethodPut {
+		buh.drainer.startSession(upload.ID())
+	}
 	return nil
 }
 
@@ -450,6 +646,55 @@ func (buh *blobUploadHandler) blobUploadResponse(w http.ResponseWriter, r *http.
 	w.Header().Set("Content-Length", "0")
 	w.Header().Set("Range", fmt.Sprintf("0-%d", endRange))
 
+	if minSize := buh.Config.Uploads.Chunks.MinSize; minSize > 0 {
+		w.Header().Set("OCI-Chunk-Min-Length", strconv.FormatInt(minSize, 10))
+	}
+
+	return nil
+}
+
+var (
+	errChunkExceedsRange   = errors.New("chunk is larger than its Content-Range")
+	errChunkExceedsMaxSize = errors.New("chunk exceeds the maximum chunk size")
+)
+
+// chunkBody is the body of a chunk, which fails with errExceeded once more data than the size of its Content-Range, or
+// the maximum chunk size if it has none, is received.
+type chunkBody struct {
+	io.ReadCloser
+	remaining   int64
+	errExceeded error
+}
+
+func (b *chunkBody) Read(p []byte) (int, error) {
+	// read one byte past the range, if there is room for it, to notice excess data
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, b.errExceeded
+	}
+	b.remaining -= int64(n)
+
+	return n, err
+}
+
+// validateChunkSize checks whether a chunk of the given size is within the configured limits. The final chunk of an
+// upload may be below the minimum size, whether it's uploaded with the PUT request that completes the upload or with a
+// PATCH request, as it can't be told apart from others. A chunk is therefore only rejected for being below the minimum
+// size if it follows one that was, as given by afterShortChunk. Chunks uploaded with PUT requests are not subject to
+// these limits.
+func validateChunkSize(config configuration.UploadChunks, size int64, afterShortChunk bool) error {
+	if config.MinSize > 0 && afterShortChunk {
+		return fmt.Errorf("only the final chunk may be below the minimum of %d bytes", config.MinSize)
+	}
+	if config.MaxSize > 0 && size > config.MaxSize {
+		return fmt.Errorf("chunk size %d exceeds the maximum of %d bytes", size, config.MaxSize)
+	}
+
 	return nil
 }
 
@@ -467,6 +712,11 @@ func (buh *blobUploadHandler) createBlobMountOption(fromRepo, mountDigest string
 		return nil, err
 	}
 
+	// blobs can't be mounted across isolated namespaces, as they are not shared between storage drivers
+	if !buh.App.sharesStorage(ref.Name(), buh.Repository.Named().Name()) {
+		return nil, fmt.Errorf("cannot mount blob from %q: repositories do not share storage", ref.Name())
+	}
+
 	canonical, err := reference.WithDigest(ref, dgst)
 	if err != nil {
 		return nil, err
@@ -486,6 +736,77 @@ func (buh *blobUploadHandler) createBlobMountOption(fromRepo, mountDigest string
 	return storage.WithMountFromStat(canonical, &distribution.Descriptor{Digest: b.Digest, Size: b.Size, MediaType: b.MediaType}), nil
 }
 
+// anonymousMountSource returns the path of a repository that the blob identified by mountDigest can be mounted from,
+// for mount requests without a source repository, or an empty string if there is none. Candidates are found with the
+// global index of repository blobs, so this requires the metadata database. Only repositories that the client was
+// granted pull access to, explicitly or through a wildcard scope, and that share storage with the target repository
+// are considered. If there is no access controller, all repositories are.
+func (buh *blobUploadHandler) anonymousMountSource(mountDigest string, rStore datastore.RepositoryStore) string {
+	if !buh.useDatabase {
+		return ""
+	}
+	dgst, err := digest.Parse(mountDigest)
+	if err != nil {
+		return ""
+	}
+
+	target := buh.Repository.Named().Name()
+	l := log.GetLogger(log.WithContext(buh)).WithFields(log.Fields{"digest": dgst})
+
+	var prefixes []string
+	if buh.App.currentAccessController() == nil {
+		prefixes = append(prefixes, "")
+	} else {
+		granted := auth.GrantedAccess(buh)
+		for _, path := range buh.App.blobMountSources(granted, target) {
+			r, err := rStore.FindByPath(buh, path)
+			if err != nil {
+				l.WithError(err).Warn("failed to find blob mount source repository")
+				return ""
+			}
+			if r == nil {
+				continue
+			}
+			ok, err := rStore.ExistsBlob(buh, r, dgst)
+			if err != nil {
+				l.WithError(err).Warn("failed to find blob in mount source repository")
+				return ""
+			}
+			if ok {
+				return path
+			}
+		}
+		for _, a := range granted {
+			if a.Type == "repository" && (a.Action == "pull" || a.Action == "*") && a.IsWildcard() {
+				prefixes = append(prefixes, strings.TrimSuffix(a.Name, "*"))
+			}
+		}
+	}
+
+	// the search is restricted to repositories sharing storage with the target repository, so that the first match is
+	// always mountable
+	namespace, excluded := buh.App.sharedStorageScope(target)
+	for _, prefix := range prefixes {
+		if namespace != "" {
+			if prefix == "" {
+				prefix = namespace
+			} else if ns, _, _ := strings.Cut(prefix, "/"); ns != namespace {
+				continue
+			}
+		}
+		r, err := rStore.FindByBlob(buh, dgst, prefix, excluded)
+		if err != nil {
+			l.WithError(err).Warn("failed to find blob mount source repository")
+			return ""
+		}
+		if r != nil {
+			return r.Path
+		}
+	}
+
+	return ""
+}
+
 // writeBlobCreatedHeaders writes the standard headers describing a newly
 // created blob. A 201 Created is written as well as the canonical URL and
 // blob digest.
@@ -210,13 +210,14 @@ func testDBPutBlobUploadComplete_NonExistentRepoAndBlob(t *testing.T, envOpts ..
 	if env.cache != nil {
 		repoStoreOpts = append(repoStoreOpts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(env.cache)))
 	}
-	err := dbPutBlobUploadComplete(env.ctx, env.db, "foo", desc, repoStoreOpts)
+	repo, err := dbPutBlobUploadComplete(env.ctx, env.db, "foo", desc, repoStoreOpts)
 	require.NoError(t, err)
 
 	// the blob should have been created
 	b := findBlob(t, env, desc.Digest)
 	// and so does the repository
 	r := findRepository(t, env, "foo")
+	require.Equal(t, r.ID, repo.ID)
 	// and the link between blob and repository
 	require.True(t, isBlobLinked(t, env, r, b.Digest))
 }
@@ -240,7 +241,7 @@ func testDBPutBlobUploadComplete_NonExistentRepoAndExistentBlob(t *testing.T, en
 	if env.cache != nil {
 		repoStoreOpts = append(repoStoreOpts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(env.cache)))
 	}
-	err := dbPutBlobUploadComplete(env.ctx, env.db, "foo", desc, repoStoreOpts)
+	_, err := dbPutBlobUploadComplete(env.ctx, env.db, "foo", desc, repoStoreOpts)
 	require.NoError(t, err)
 
 	// the repository should have been created
@@ -268,7 +269,7 @@ func testDBPutBlobUploadComplete_ExistentRepoAndNonExistentBlob(t *testing.T, en
 	if env.cache != nil {
 		repoStoreOpts = append(repoStoreOpts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(env.cache)))
 	}
-	err := dbPutBlobUploadComplete(env.ctx, env.db, r.Path, desc, repoStoreOpts)
+	_, err := dbPutBlobUploadComplete(env.ctx, env.db, r.Path, desc, repoStoreOpts)
 	require.NoError(t, err)
 
 	// the blob should have been created
@@ -297,7 +298,7 @@ func testDBPutBlobUploadComplete_ExistentRepoAndBlobButNotLinked(t *testing.T, e
 	if env.cache != nil {
 		repoStoreOpts = append(repoStoreOpts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(env.cache)))
 	}
-	err := dbPutBlobUploadComplete(env.ctx, env.db, r.Path, desc, repoStoreOpts)
+	_, err := dbPutBlobUploadComplete(env.ctx, env.db, r.Path, desc, repoStoreOpts)
 	require.NoError(t, err)
 
 	// the link between blob and repository should have been created
@@ -325,7 +326,7 @@ func testDBPutBlobUploadComplete_ExistentRepoAndBlobAlreadyLinked(t *testing.T,
 	if env.cache != nil {
 		repoStoreOpts = append(repoStoreOpts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(env.cache)))
 	}
-	err := dbPutBlobUploadComplete(env.ctx, env.db, r.Path, desc, repoStoreOpts)
+	_, err := dbPutBlobUploadComplete(env.ctx, env.db, r.Path, desc, repoStoreOpts)
 	require.NoError(t, err)
 
 	// the link between blob and repository should remain
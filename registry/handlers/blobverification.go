@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+)
+
+const defaultBlobVerificationCorruptedBlobs = 100
+
+type blobVerificationHandler struct {
+	*Context
+}
+
+func blobVerificationDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	blobVerificationHandler := &blobVerificationHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(blobVerificationHandler.GetBlobVerification),
+	}
+}
+
+// CorruptedBlobAPIResponse is a blob whose content failed the integrity verification.
+type CorruptedBlobAPIResponse struct {
+	Digest       string `json:"digest"`
+	ActualDigest string `json:"actual_digest,omitempty"`
+	Size         int64  `json:"size_bytes"`
+	Reason       string `json:"reason"`
+	DetectedAt   string `json:"detected_at"`
+	CheckedAt    string `json:"checked_at"`
+}
+
+// BlobVerificationAPIResponse is the response body for the blob verification report endpoint.
+type BlobVerificationAPIResponse struct {
+	LastRunAt      string                     `json:"last_run_at,omitempty"`
+	Total          int                        `json:"total"`
+	CorruptedBlobs []CorruptedBlobAPIResponse `json:"corrupted_blobs"`
+}
+
+func blobVerificationLimitFromRequest(r *http.Request) (int, error) {
+	q := r.URL.Query()
+	if !q.Has(nQueryParamKey) {
+		return defaultBlobVerificationCorruptedBlobs, nil
+	}
+
+	val, valid := isQueryParamTypeInt(q.Get(nQueryParamKey))
+	if !valid {
+		detail := v1.InvalidQueryParamTypeErrorDetail(nQueryParamKey, nQueryParamValidTypes)
+		return 0, v1.ErrorCodeInvalidQueryParamType.WithDetail(detail)
+	}
+	if !isQueryParamIntValueInBetween(val, nQueryParamValueMin, nQueryParamValueMax) {
+		detail := v1.InvalidQueryParamValueRangeErrorDetail(nQueryParamKey, nQueryParamValueMin, nQueryParamValueMax)
+		return 0, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+	}
+
+	return val, nil
+}
+
+// GetBlobVerification reports the blobs whose content failed the background integrity verification, most recently
+// detected first, along with the time of the last verification run in this registry instance (if enabled).
+func (h *blobVerificationHandler) GetBlobVerification(w http.ResponseWriter, r *http.Request) {
+	if !h.App.Config.Database.Enabled {
+		detail := v1.MissingServerDependencyTypeErrorDetail("database")
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail(detail))
+		return
+	}
+
+	limit, err := blobVerificationLimitFromRequest(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	s := datastore.NewCorruptedBlobStore(h.App.db)
+	total, err := s.Count(h.Context)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	bb, err := s.FindAll(h.Context, limit)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := BlobVerificationAPIResponse{
+		Total:          total,
+		CorruptedBlobs: make([]CorruptedBlobAPIResponse, 0, len(bb)),
+	}
+	if h.App.blobVerifier != nil {
+		if t := h.App.blobVerifier.LastRunAt(); !t.IsZero() {
+			resp.LastRunAt = timeToString(t)
+		}
+	}
+	for _, b := range bb {
+		resp.CorruptedBlobs = append(resp.CorruptedBlobs, CorruptedBlobAPIResponse{
+			Digest:       b.Digest.String(),
+			ActualDigest: b.ActualDigest.String(),
+			Size:         b.Size,
+			Reason:       b.Reason,
+			DetectedAt:   timeToString(b.DetectedAt),
+			CheckedAt:    timeToString(b.CheckedAt),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobVerificationLimitFromRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expected     int
+		expectedCode errcode.ErrorCode
+	}{
+		{
+			name:     "default",
+			expected: defaultBlobVerificationCorruptedBlobs,
+		},
+		{
+			name:     "set",
+			query:    "n=5",
+			expected: 5,
+		},
+		{
+			name:         "not an integer",
+			query:        "n=a",
+			expectedCode: v1.ErrorCodeInvalidQueryParamType,
+		},
+		{
+			name:         "out of range",
+			query:        "n=1001",
+			expectedCode: v1.ErrorCodeInvalidQueryParamValue,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/gitlab/v1/blobs/verification/?"+test.query, nil)
+			n, err := blobVerificationLimitFromRequest(r)
+			if test.expectedCode != 0 {
+				require.Error(t, err)
+				require.Equal(t, test.expectedCode, err.(errcode.Error).Code)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, n)
+		})
+	}
+}
+
+func TestBlobVerificationHandler_DatabaseDisabled(t *testing.T) {
+	app := &App{Config: &configuration.Configuration{}}
+	h := &blobVerificationHandler{Context: &Context{App: app, Context: context.Background()}}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/gitlab/v1/blobs/verification/", nil)
+	h.GetBlobVerification(w, r)
+
+	require.Len(t, h.Errors, 1)
+	require.Equal(t, v1.ErrorCodeNotImplemented, h.Errors[0].(errcode.Error).Code)
+}
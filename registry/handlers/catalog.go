@@ -3,13 +3,13 @@ package handlers
 import (
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
@@ -26,6 +26,9 @@ const (
 
 	defaultMaximumReturnedEntries  = 100
 	maximumReturnEntriesUpperLimit = 1000
+
+	catalogPrefixQueryParamKey        = "prefix"
+	catalogModifiedSinceQueryParamKey = "modified_since"
 )
 
 func catalogDispatcher(ctx *Context, r *http.Request) http.Handler {
@@ -60,11 +63,11 @@ func dbGetCatalog(ctx context.Context, db datastore.Queryer, filters datastore.F
 
 	var moreEntries bool
 	if len(rr) > 0 {
-		n, err := rStore.CountAfterPath(ctx, rr[len(rr)-1].Path)
+		filters.LastEntry = rr[len(rr)-1].Path
+		moreEntries, err = rStore.HasAfterPath(ctx, filters)
 		if err != nil {
 			return nil, false, err
 		}
-		moreEntries = n > 0
 	}
 
 	return repos, moreEntries, nil
@@ -87,18 +90,50 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 	filters := datastore.FilterParams{
 		LastEntry:  lastEntry,
 		MaxEntries: maxEntries,
+		PathPrefix: q.Get(catalogPrefixQueryParamKey),
+	}
+	if v := q.Get(catalogModifiedSinceQueryParamKey); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			ch.Errors = append(ch.Errors, v2.ErrorCodeCatalogFilterInvalid.WithDetail(fmt.Sprintf("%s must be an RFC 3339 timestamp", catalogModifiedSinceQueryParamKey)))
+			return
+		}
+		filters.ModifiedSince = t
+	}
+	if filters.Labels, err = repositoryLabelMatchesFromQuery(q); err != nil {
+		ch.Errors = append(ch.Errors, v2.ErrorCodeCatalogFilterInvalid.WithDetail(err.Error()))
+		return
 	}
+	if !ch.useDatabase && (filters.PathPrefix != "" || !filters.ModifiedSince.IsZero() || len(filters.Labels) > 0) {
+		ch.Errors = append(ch.Errors, v2.ErrorCodeCatalogFilterInvalid.WithDetail("catalog filters require the metadata database"))
+		return
+	}
+	// As with `n`, an invalid value is ignored instead of rejected, for consistency with the rest of the V2 API.
+	countTotal, _ := countQueryParamValue(q)
 
 	var filled int
 	var repos []string
 
 	if ch.useDatabase {
-		repos, moreEntries, err = dbGetCatalog(ch.Context, ch.db, filters)
+		db := ch.App.replicaDB(ch, "")
+		repos, moreEntries, err = dbGetCatalog(ch.Context, db, filters)
 		if err != nil {
 			ch.Errors = append(ch.Errors, errcode.FromUnknownError(err))
 			return
 		}
 		filled = len(repos)
+
+		// Total count headers are only supported by the metadata database backend
+		if countTotal {
+			total, err := newTotalCounter(ch.App).count(ch.Context, "catalog", filters, func() (int, error) {
+				return datastore.NewRepositoryStore(db).CountPaginated(ch.Context, filters)
+			})
+			if err != nil {
+				ch.Errors = append(ch.Errors, errcode.FromUnknownError(err))
+				return
+			}
+			setTotalCountHeaders(w, total, filters.MaxEntries)
+		}
 	} else {
 		repos = make([]string, filters.MaxEntries)
 
@@ -114,6 +149,9 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if applied := appliedFilters(filters); len(applied) > 0 {
+		w.Header().Set(filtersAppliedHeader, strings.Join(applied, ","))
+	}
 
 	// Add a link header if there are more entries to retrieve
 	if moreEntries {
@@ -128,8 +166,7 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	enc := json.NewEncoder(w)
-	if err := enc.Encode(catalogAPIResponse{
+	if err := writeJSONWithETag(w, r, catalogAPIResponse{
 		Repositories: repos[0:filled],
 	}); err != nil {
 		ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
@@ -137,6 +174,35 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// appliedFilters returns the query parameter keys of the filters set in filters, in the order in which they are
+// documented. Pagination parameters are not filters and are therefore never included.
+func appliedFilters(filters datastore.FilterParams) []string {
+	var applied []string
+	if filters.Name != "" {
+		applied = append(applied, tagNameQueryParamKey)
+	}
+	if filters.NamePrefix != "" {
+		applied = append(applied, tagNamePrefixQueryParamKey)
+	}
+	if filters.NameRegex != "" {
+		applied = append(applied, tagNameRegexQueryParamKey)
+	}
+	if filters.ArtifactType != "" {
+		applied = append(applied, artifactTypeQueryParamKey)
+	}
+	if filters.PathPrefix != "" {
+		applied = append(applied, catalogPrefixQueryParamKey)
+	}
+	if !filters.ModifiedSince.IsZero() {
+		applied = append(applied, catalogModifiedSinceQueryParamKey)
+	}
+	if len(filters.Labels) > 0 {
+		applied = append(applied, labelQueryParamKey)
+	}
+
+	return applied
+}
+
 // Use the original URL from the request to create a new URL for
 // the link header
 func createLinkEntry(origURL string, filters datastore.FilterParams, publishedBefore, publishedLast string) (string, error) {
@@ -195,6 +261,24 @@ func generateLink(originalURL, rel string, filters datastore.FilterParams, publi
 	if filters.Name != "" {
 		qValues.Add(tagNameQueryParamKey, filters.Name)
 	}
+	if filters.NamePrefix != "" {
+		qValues.Add(tagNamePrefixQueryParamKey, filters.NamePrefix)
+	}
+	if filters.NameRegex != "" {
+		qValues.Add(tagNameRegexQueryParamKey, filters.NameRegex)
+	}
+	if filters.ArtifactType != "" {
+		qValues.Add(artifactTypeQueryParamKey, filters.ArtifactType)
+	}
+	if filters.PathPrefix != "" {
+		qValues.Add(catalogPrefixQueryParamKey, filters.PathPrefix)
+	}
+	if !filters.ModifiedSince.IsZero() {
+		qValues.Add(catalogModifiedSinceQueryParamKey, filters.ModifiedSince.UTC().Format(time.RFC3339Nano))
+	}
+	for _, l := range filters.Labels {
+		qValues.Add(labelQueryParamKey, l.String())
+	}
 
 	orderBy := filters.OrderBy
 	if orderBy != "" {
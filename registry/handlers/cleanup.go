@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/cleanup"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+)
+
+const (
+	cleanupPolicyCadenceBodyParamKey         = "cadence"
+	cleanupPolicyNameRegexDeleteBodyParamKey = "name_regex_delete"
+	cleanupPolicyNameRegexKeepBodyParamKey   = "name_regex_keep"
+	cleanupPolicyKeepNBodyParamKey           = "keep_n"
+	cleanupPolicyOlderThanBodyParamKey       = "older_than"
+
+	minCleanupPolicyCadence         = time.Hour
+	maxCleanupPolicyKeepN           = 1000
+	maxCleanupPolicyNameRegexLength = 255
+)
+
+// parseCleanupPolicyDuration parses a duration in days, such as `14d`, or in the format accepted by time.ParseDuration.
+func parseCleanupPolicyDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// formatCleanupPolicyDuration formats a duration in days if possible, for readability, or as time.Duration otherwise.
+func formatCleanupPolicyDuration(d time.Duration) string {
+	day := 24 * time.Hour
+	if d > 0 && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
+
+type repositoryCleanupPolicyHandler struct {
+	*Context
+}
+
+func repositoryCleanupPolicyDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryCleanupPolicyHandler := &repositoryCleanupPolicyHandler{
+		Context: ctx,
+	}
+
+	h := handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryCleanupPolicyHandler.GetCleanupPolicy),
+	}
+	if !ctx.readOnly {
+		h[http.MethodPut] = http.HandlerFunc(repositoryCleanupPolicyHandler.PutCleanupPolicy)
+		h[http.MethodDelete] = http.HandlerFunc(repositoryCleanupPolicyHandler.DeleteCleanupPolicy)
+	}
+
+	return h
+}
+
+func repositoryCleanupPolicyPreviewDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryCleanupPolicyHandler := &repositoryCleanupPolicyHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodPost: http.HandlerFunc(repositoryCleanupPolicyHandler.PreviewCleanupPolicy),
+	}
+}
+
+// CleanupPolicyAPIRequest is the request body for the repository cleanup policy endpoints. Durations are expressed in
+// days, such as `14d`, or in the Go duration format, such as `12h`.
+type CleanupPolicyAPIRequest struct {
+	Enabled         *bool  `json:"enabled,omitempty"`
+	Cadence         string `json:"cadence"`
+	NameRegexDelete string `json:"name_regex_delete"`
+	NameRegexKeep   string `json:"name_regex_keep,omitempty"`
+	KeepN           int    `json:"keep_n,omitempty"`
+	OlderThan       string `json:"older_than,omitempty"`
+}
+
+// CleanupPolicyAPIResponse is the response body for the repository cleanup policy endpoint.
+type CleanupPolicyAPIResponse struct {
+	Enabled         bool   `json:"enabled"`
+	Cadence         string `json:"cadence"`
+	NameRegexDelete string `json:"name_regex_delete"`
+	NameRegexKeep   string `json:"name_regex_keep,omitempty"`
+	KeepN           int    `json:"keep_n"`
+	OlderThan       string `json:"older_than,omitempty"`
+	NextRunAt       string `json:"next_run_at"`
+	LastRunAt       string `json:"last_run_at,omitempty"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at,omitempty"`
+}
+
+// CleanupPolicyPreviewAPIResponse is the response body for the repository cleanup policy preview endpoint. Tags holds
+// the names of the tags that the policy would delete, lexicographically sorted.
+type CleanupPolicyPreviewAPIResponse struct {
+	Tags []string `json:"tags"`
+}
+
+func cleanupPolicyToAPIResponse(p *models.CleanupPolicy) CleanupPolicyAPIResponse {
+	resp := CleanupPolicyAPIResponse{
+		Enabled:         p.Enabled,
+		Cadence:         formatCleanupPolicyDuration(p.Cadence),
+		NameRegexDelete: p.NameRegexDelete,
+		NameRegexKeep:   p.NameRegexKeep,
+		KeepN:           p.KeepN,
+		NextRunAt:       timeToString(p.NextRunAt),
+		CreatedAt:       timeToString(p.CreatedAt),
+	}
+	if p.OlderThan > 0 {
+		resp.OlderThan = formatCleanupPolicyDuration(p.OlderThan)
+	}
+	if p.LastRunAt.Valid {
+		resp.LastRunAt = timeToString(p.LastRunAt.Time)
+	}
+	if p.UpdatedAt.Valid {
+		resp.UpdatedAt = timeToString(p.UpdatedAt.Time)
+	}
+	return resp
+}
+
+// decodeRequest decodes and validates a cleanup policy request body into a policy for the given repository. The
+// cadence is only required if requireCadence is true. Any validation errors are appended to the handler errors.
+func (h *repositoryCleanupPolicyHandler) decodeRequest(body io.Reader, repo *models.Repository, requireCadence bool) (*models.CleanupPolicy, bool) {
+	var req CleanupPolicyAPIRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return nil, false
+	}
+
+	invalid := func(detail string) (*models.CleanupPolicy, bool) {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return nil, false
+	}
+
+	p := &models.CleanupPolicy{
+		NamespaceID:     repo.NamespaceID,
+		RepositoryID:    repo.ID,
+		RepositoryPath:  repo.Path,
+		Enabled:         req.Enabled == nil || *req.Enabled,
+		NameRegexDelete: req.NameRegexDelete,
+		NameRegexKeep:   req.NameRegexKeep,
+		KeepN:           req.KeepN,
+	}
+
+	if req.Cadence != "" || requireCadence {
+		d, err := parseCleanupPolicyDuration(req.Cadence)
+		if err != nil || d < minCleanupPolicyCadence {
+			return invalid(fmt.Sprintf("the '%s' body parameter must be a duration of at least %s", cleanupPolicyCadenceBodyParamKey, minCleanupPolicyCadence))
+		}
+		p.Cadence = d
+	}
+	if req.NameRegexDelete == "" {
+		return invalid(v1.MissingBodyParamErrorDetail(cleanupPolicyNameRegexDeleteBodyParamKey))
+	}
+	for key, expr := range map[string]string{
+		cleanupPolicyNameRegexDeleteBodyParamKey: req.NameRegexDelete,
+		cleanupPolicyNameRegexKeepBodyParamKey:   req.NameRegexKeep,
+	} {
+		if _, err := regexp.Compile(expr); err != nil || len(expr) > maxCleanupPolicyNameRegexLength {
+			return invalid(v1.InvalidBodyParamValueRegexErrorDetail(key, maxCleanupPolicyNameRegexLength))
+		}
+	}
+	if req.KeepN < 0 || req.KeepN > maxCleanupPolicyKeepN {
+		return invalid(fmt.Sprintf("the '%s' body parameter must be between 0 and %d", cleanupPolicyKeepNBodyParamKey, maxCleanupPolicyKeepN))
+	}
+	if req.OlderThan != "" {
+		d, err := parseCleanupPolicyDuration(req.OlderThan)
+		if err != nil || d < 0 {
+			return invalid(fmt.Sprintf("the '%s' body parameter must be a positive duration", cleanupPolicyOlderThanBodyParamKey))
+		}
+		p.OlderThan = d
+	}
+
+	return p, true
+}
+
+func (h *repositoryCleanupPolicyHandler) findRepository() (*models.Repository, bool) {
+	path := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.db).FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil, false
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return nil, false
+	}
+	return repo, true
+}
+
+func (h *repositoryCleanupPolicyHandler) writeResponse(w http.ResponseWriter, resp any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// GetCleanupPolicy returns the cleanup policy of a repository.
+func (h *repositoryCleanupPolicyHandler) GetCleanupPolicy(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	p, err := datastore.NewCleanupPolicyStore(h.db).FindByRepository(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if p == nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeCleanupPolicyUnknown.WithDetail(map[string]string{"name": repo.Path}))
+		return
+	}
+
+	h.writeResponse(w, cleanupPolicyToAPIResponse(p))
+}
+
+// PutCleanupPolicy creates or replaces the cleanup policy of a repository.
+func (h *repositoryCleanupPolicyHandler) PutCleanupPolicy(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+	p, ok := h.decodeRequest(r.Body, repo, true)
+	if !ok {
+		return
+	}
+
+	// policies delete tags periodically in the background, without the auth token of this request
+	if err := newTagProtection(h, repo.Path).checkUnattended("setting a cleanup policy"); err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	if err := datastore.NewCleanupPolicyStore(h.db).CreateOrUpdate(h.Context, p); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	h.writeResponse(w, cleanupPolicyToAPIResponse(p))
+}
+
+// DeleteCleanupPolicy deletes the cleanup policy of a repository.
+func (h *repositoryCleanupPolicyHandler) DeleteCleanupPolicy(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	found, err := datastore.NewCleanupPolicyStore(h.db).DeleteByRepository(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if !found {
+		h.Errors = append(h.Errors, v1.ErrorCodeCleanupPolicyUnknown.WithDetail(map[string]string{"name": repo.Path}))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PreviewCleanupPolicy returns the tags that a cleanup policy would delete if executed right away, without deleting
+// them. The policy is read from the request body if any, otherwise the cleanup policy of the repository is used.
+func (h *repositoryCleanupPolicyHandler) PreviewCleanupPolicy(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	var p *models.CleanupPolicy
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if p, ok = h.decodeRequest(strings.NewReader(string(body)), repo, false); !ok {
+			return
+		}
+	} else {
+		p, err = datastore.NewCleanupPolicyStore(h.db).FindByRepository(h.Context, repo)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		if p == nil {
+			h.Errors = append(h.Errors, v1.ErrorCodeCleanupPolicyUnknown.WithDetail(map[string]string{"name": repo.Path}))
+			return
+		}
+	}
+
+	tt, err := evaluateCleanupPolicy(h.Context, h.db, h.App.Config, repo, p)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := CleanupPolicyPreviewAPIResponse{Tags: make([]string, 0, len(tt))}
+	for _, t := range tt {
+		resp.Tags = append(resp.Tags, t.Name)
+	}
+	h.writeResponse(w, resp)
+}
+
+// evaluateCleanupPolicy returns the tags of repo that policy p deletes if executed now, sorted by name. Tags that are
+// immutable, as per the registry configuration or the repository immutable tag patterns, are never deleted.
+func evaluateCleanupPolicy(ctx context.Context, db datastore.Queryer, config *configuration.Configuration, repo *models.Repository, p *models.CleanupPolicy) (models.Tags, error) {
+	tt, err := datastore.NewRepositoryStore(db).Tags(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := datastore.NewImmutableTagPatternStore(db).FindByRepository(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, immutableTagPatternsForRepository(config.Policy.Repository.ImmutableTags, repo.Path)...)
+
+	return cleanup.Evaluate(p, tt, patterns, time.Now())
+}
+
+// cleanupExecutor is a cleanup.Executor that deletes tags from the metadata database, as done by the GitLab V1 API
+// tags bulk delete endpoint, and records the deletes in the audit log.
+type cleanupExecutor struct {
+	app *App
+}
+
+// Execute implements cleanup.Executor.
+func (e *cleanupExecutor) Execute(ctx context.Context, p *models.CleanupPolicy) ([]string, error) {
+	// TODO: remove as part of https://gitlab.com/gitlab-org/container-registry/-/issues/1056
+	var repoCache datastore.RepositoryCache
+	if e.app.redisCache != nil {
+		repoCache = datastore.NewCentralRepositoryCache(e.app.redisCache)
+	} else {
+		repoCache = datastore.NewSingleRepositoryCache()
+	}
+
+	repo, err := datastore.NewRepositoryStore(e.app.db, datastore.WithRepositoryCache(repoCache)).FindByPath(ctx, p.RepositoryPath)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		// the repository was deleted in the meantime, and the policy with it
+		return nil, nil
+	}
+
+	rs, err := datastore.NewRepositorySettingsStore(e.app.db).FindByRepository(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if rs != nil && rs.ReadOnly {
+		// read-only repositories are archived, so their tags must be kept
+		return nil, nil
+	}
+
+	tt, err := evaluateCleanupPolicy(ctx, e.app.db, e.app.Config, repo, p)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]string, 0, len(tt))
+	for len(tt) > 0 {
+		n := maxTagsToBulkDelete
+		if n > len(tt) {
+			n = len(tt)
+		}
+		names := make([]string, 0, n)
+		for _, t := range tt[:n] {
+			names = append(names, t.Name)
+		}
+		tt = tt[n:]
+
+		// policies can only be set if no tags are protected, see PutCleanupPolicy
+		dd, err := dbDeleteTags(ctx, e.app.db, repoCache, getManifestCache(e.app), repo.Path, names, "", nil)
+		if err != nil {
+			var unknown distribution.ErrRepositoryUnknown
+			if errors.As(err, &unknown) {
+				break
+			}
+			return deleted, err
+		}
+		for _, tag := range dd {
+			if e.app.auditLogger != nil {
+				e.app.auditLogger.Log(ctx, audit.Record{
+					Action:     audit.ActionCleanupDelete,
+					Artifact:   audit.ArtifactTag,
+					Actor:      audit.CleanupActor,
+					Repository: repo.Path,
+					Tag:        tag,
+				})
+			}
+		}
+		deleted = append(deleted, dd...)
+	}
+
+	return deleted, nil
+}
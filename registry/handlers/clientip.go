@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies are the CIDR ranges of the reverse proxies in front of the registry, the only ones whose forwarded
+// client IPs are trusted.
+type trustedProxies []*net.IPNet
+
+func newTrustedProxies(cidrs []string) (trustedProxies, error) {
+	tp := make(trustedProxies, 0, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("trustedproxies[%d]: %w", i, err)
+		}
+		tp = append(tp, n)
+	}
+
+	return tp, nil
+}
+
+// clientIP returns the IP of the client of r, or nil if it can not be determined. Headers set by clients can be
+// spoofed, so X-Forwarded-For is only taken into account for requests received from trusted proxies, walking it from
+// the right while addresses are trusted proxies themselves. X-Real-Ip is ignored, as proxies can't append to it.
+func (tp trustedProxies) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(hops) - 1; i >= 0 && ip != nil && ipNetsContain(tp, ip); i-- {
+		ip = net.ParseIP(hops[i])
+	}
+
+	return ip
+}
+
+func ipNetsContain(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTrustedProxies(t *testing.T) {
+	tp, err := newTrustedProxies([]string{"127.0.0.0/8", "10.0.0.0/8"})
+	require.NoError(t, err)
+	require.Len(t, tp, 2)
+
+	tp, err = newTrustedProxies(nil)
+	require.NoError(t, err)
+	require.Empty(t, tp)
+
+	_, err = newTrustedProxies([]string{"127.0.0.0/8", "127.0.0.1"})
+	require.EqualError(t, err, "trustedproxies[1]: invalid CIDR address: 127.0.0.1")
+}
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	tp, err := newTrustedProxies([]string{"127.0.0.0/8"})
+	require.NoError(t, err)
+
+	tcs := map[string]struct {
+		proxies    trustedProxies
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       net.IP
+	}{
+		"direct":                           {proxies: tp, remoteAddr: "10.1.2.3:1234", want: net.ParseIP("10.1.2.3")},
+		"behind proxy":                     {proxies: tp, remoteAddr: "127.0.0.1:1234", forwarded: "10.1.2.3", want: net.ParseIP("10.1.2.3")},
+		"behind proxies":                   {proxies: tp, remoteAddr: "127.0.0.1:1234", forwarded: "10.1.2.3, 127.0.0.2", want: net.ParseIP("10.1.2.3")},
+		"spoofed header behind proxy":      {proxies: tp, remoteAddr: "127.0.0.1:1234", forwarded: "10.1.2.3, 8.8.8.8", want: net.ParseIP("8.8.8.8")},
+		"spoofed forwarded header":         {proxies: tp, remoteAddr: "8.8.8.8:1234", forwarded: "10.1.2.3", want: net.ParseIP("8.8.8.8")},
+		"spoofed real ip header":           {proxies: tp, remoteAddr: "8.8.8.8:1234", realIP: "10.1.2.3", want: net.ParseIP("8.8.8.8")},
+		"forwarded header without proxies": {remoteAddr: "127.0.0.1:1234", forwarded: "10.1.2.3", want: net.ParseIP("127.0.0.1")},
+		"invalid forwarded header":         {proxies: tp, remoteAddr: "127.0.0.1:1234", forwarded: "foo"},
+		"invalid remote address":           {proxies: tp, remoteAddr: "foo"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			if tc.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-Ip", tc.realIP)
+			}
+			require.True(t, tc.want.Equal(tc.proxies.clientIP(r)), "got %v, want %v", tc.proxies.clientIP(r), tc.want)
+		})
+	}
+}
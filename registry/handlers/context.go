@@ -10,6 +10,7 @@ import (
 	"github.com/docker/distribution/notifications"
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/distribution/registry/api/urls"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/opencontainers/go-digest"
@@ -45,6 +46,9 @@ type Context struct {
 
 	// queueBridge is used to connect the handler with the notifications.Queue
 	queueBridge *notifications.QueueBridge
+
+	// auditActor is the actor recorded in the audit log for write operations in this request.
+	auditActor audit.Actor
 }
 
 // Value overrides context.Context.Value to ensure that calls are routed to
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+)
+
+var (
+	corsDefaultAllowedMethods = []string{http.MethodGet, http.MethodHead}
+	corsDefaultAllowedHeaders = []string{"Accept", "Authorization", "Content-Type"}
+)
+
+// corsPolicy determines which cross-origin requests browsers are allowed to make to an API.
+type corsPolicy struct {
+	anyOrigin      bool
+	origins        map[string]struct{}
+	methods        map[string]struct{}
+	headers        map[string]struct{}
+	allowedMethods string
+	allowedHeaders string
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+// newCORSPolicy validates the CORS configuration of an API, identified by name in error messages, and returns the
+// corresponding policy. Nil is returned if CORS is disabled.
+func newCORSPolicy(name string, config configuration.CORSPolicy) (*corsPolicy, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	if len(config.AllowedOrigins) == 0 {
+		return nil, fmt.Errorf("http.cors.%s.allowedorigins must not be empty", name)
+	}
+	if config.MaxAge < 0 {
+		return nil, fmt.Errorf("http.cors.%s.maxage must not be negative", name)
+	}
+
+	p := &corsPolicy{
+		origins:     make(map[string]struct{}, len(config.AllowedOrigins)),
+		methods:     make(map[string]struct{}),
+		headers:     make(map[string]struct{}),
+		credentials: config.AllowCredentials,
+	}
+
+	for i, o := range config.AllowedOrigins {
+		if o == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("http.cors.%s.allowedorigins[%d]: invalid origin %q, must be in the form <scheme>://<host>[:<port>]", name, i, o)
+		}
+		p.origins[strings.ToLower(strings.TrimSuffix(o, "/"))] = struct{}{}
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, fmt.Errorf("http.cors.%s.allowcredentials can't be used along with the '*' origin", name)
+	}
+
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = corsDefaultAllowedMethods
+	}
+	normalized := make([]string, 0, len(methods))
+	for _, m := range methods {
+		m = strings.ToUpper(m)
+		p.methods[m] = struct{}{}
+		normalized = append(normalized, m)
+	}
+	p.allowedMethods = strings.Join(normalized, ", ")
+
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = corsDefaultAllowedHeaders
+	}
+	for _, h := range headers {
+		p.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	p.allowedHeaders = strings.Join(headers, ", ")
+	p.exposedHeaders = strings.Join(config.ExposedHeaders, ", ")
+
+	if config.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(config.MaxAge.Seconds()))
+	}
+
+	return p, nil
+}
+
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	_, ok := p.origins[strings.ToLower(origin)]
+	return ok
+}
+
+// allowsHeaders reports whether all headers in the comma separated list of headers of a preflight request are allowed.
+func (p *corsPolicy) allowsHeaders(headers string) bool {
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if _, ok := p.headers[http.CanonicalHeaderKey(h)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// allowOrigin sets the response headers that allow requests from origin.
+func (p *corsPolicy) allowOrigin(w http.ResponseWriter, origin string) {
+	if p.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// serve applies the policy to r, before passing it to next. Preflight requests are answered directly, so that these
+// don't go through authentication, which browsers never provide credentials for. The policy is not applied if nil.
+func (p *corsPolicy) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if p == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// responses depend on the origin, so these must not be reused by caches across origins
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	reqMethod := r.Header.Get("Access-Control-Request-Method")
+	if r.Method != http.MethodOptions || reqMethod == "" {
+		// not a preflight request, browsers enforce the policy based on the response headers
+		if p.allowsOrigin(origin) {
+			p.allowOrigin(w, origin)
+			if p.exposedHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", p.exposedHeaders)
+			}
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	_, methodAllowed := p.methods[strings.ToUpper(reqMethod)]
+	if !p.allowsOrigin(origin) || !methodAllowed || !p.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	p.allowOrigin(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", p.allowedMethods)
+	w.Header().Set("Access-Control-Allow-Headers", p.allowedHeaders)
+	if p.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
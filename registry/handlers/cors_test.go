@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCORSPolicy_Disabled(t *testing.T) {
+	p, err := newCORSPolicy("gitlab", configuration.CORSPolicy{AllowedOrigins: []string{"https://ui.example.com"}})
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	// a nil policy lets requests through untouched
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodOptions, "/gitlab/v1/", nil)
+	r.Header.Set("Origin", "https://ui.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	p.serve(rec, r, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Empty(t, rec.Header())
+}
+
+func TestNewCORSPolicy_Invalid(t *testing.T) {
+	tcs := map[string]configuration.CORSPolicy{
+		"no origins":              {Enabled: true},
+		"origin without scheme":   {Enabled: true, AllowedOrigins: []string{"ui.example.com"}},
+		"origin with path":        {Enabled: true, AllowedOrigins: []string{"https://ui.example.com/app"}},
+		"origin with bad scheme":  {Enabled: true, AllowedOrigins: []string{"ftp://ui.example.com"}},
+		"credentials with any":    {Enabled: true, AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		"negative preflight age":  {Enabled: true, AllowedOrigins: []string{"*"}, MaxAge: -time.Second},
+		"credentials with any #2": {Enabled: true, AllowedOrigins: []string{"https://ui.example.com", "*"}, AllowCredentials: true},
+	}
+
+	for tn, tc := range tcs {
+		t.Run(tn, func(t *testing.T) {
+			_, err := newCORSPolicy("gitlab", tc)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCORSPolicy_Serve(t *testing.T) {
+	p, err := newCORSPolicy("gitlab", configuration.CORSPolicy{
+		Enabled:          true,
+		AllowedOrigins:   []string{"https://UI.example.com/"},
+		AllowedMethods:   []string{"get", http.MethodDelete},
+		ExposedHeaders:   []string{"Link", "X-Total-Count"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tcs := []struct {
+		name            string
+		method          string
+		headers         map[string]string
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		{
+			name:           "same origin",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusTeapot,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		{
+			name:           "allowed origin",
+			method:         http.MethodGet,
+			headers:        map[string]string{"Origin": "https://ui.example.com"},
+			expectedStatus: http.StatusTeapot,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://ui.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "Link, X-Total-Count",
+			},
+		},
+		{
+			name:           "disallowed origin",
+			method:         http.MethodGet,
+			headers:        map[string]string{"Origin": "https://evil.example.com"},
+			expectedStatus: http.StatusTeapot,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		{
+			name:   "preflight",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://ui.example.com",
+				"Access-Control-Request-Method":  http.MethodDelete,
+				"Access-Control-Request-Headers": "authorization, content-type",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://ui.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, DELETE",
+				"Access-Control-Allow-Headers":     "Accept, Authorization, Content-Type",
+				"Access-Control-Max-Age":           "600",
+			},
+		},
+		{
+			name:   "preflight with disallowed origin",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": http.MethodGet,
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:   "preflight with disallowed method",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://ui.example.com",
+				"Access-Control-Request-Method": http.MethodPut,
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:   "preflight with disallowed header",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://ui.example.com",
+				"Access-Control-Request-Method":  http.MethodGet,
+				"Access-Control-Request-Headers": "authorization, x-custom",
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "options without preflight",
+			method:         http.MethodOptions,
+			headers:        map[string]string{"Origin": "https://ui.example.com"},
+			expectedStatus: http.StatusTeapot,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "https://ui.example.com",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/gitlab/v1/", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			p.serve(rec, r, next)
+
+			require.Equal(t, tc.expectedStatus, rec.Code)
+			require.Contains(t, rec.Header().Values("Vary"), "Origin")
+			for k, v := range tc.expectedHeaders {
+				require.Equal(t, v, rec.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func TestCORSPolicy_Serve_AnyOrigin(t *testing.T) {
+	p, err := newCORSPolicy("distribution", configuration.CORSPolicy{Enabled: true, AllowedOrigins: []string{"*"}})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodOptions, "/v2/", nil)
+	r.Header.Set("Origin", "https://ui.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodHead)
+	rec := httptest.NewRecorder()
+	p.serve(rec, r, http.NotFoundHandler())
+
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "GET, HEAD", rec.Header().Get("Access-Control-Allow-Methods"))
+	require.Empty(t, rec.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestMetaRouter_CORS(t *testing.T) {
+	app := &App{
+		Config: &configuration.Configuration{},
+	}
+	app.Config.HTTP.CORS.GitLab = configuration.CORSPolicy{Enabled: true, AllowedOrigins: []string{"https://ui.example.com"}}
+	require.NoError(t, app.initMetaRouter())
+
+	preflight := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, path, nil)
+		r.Header.Set("Origin", "https://ui.example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		rec := httptest.NewRecorder()
+		app.router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// preflight requests are answered before reaching the API router, which would require authentication
+	rec := preflight("/gitlab/v1/repositories/foo/bar/tags/list/")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "https://ui.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// each API has its own policy
+	require.NotNil(t, app.router.gitlabCORS)
+	require.Nil(t, app.router.distributionCORS)
+
+	app.Config.HTTP.CORS.Distribution = configuration.CORSPolicy{Enabled: true}
+	require.Error(t, app.initMetaRouter())
+}
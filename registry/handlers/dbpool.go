@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/sirupsen/logrus"
+)
+
+// DBPoolStats reports the state of a database connection pool.
+type DBPoolStats struct {
+	// Host is the address of the database server.
+	Host string `json:"host"`
+	// MaxOpen is the maximum number of open connections. Zero means unlimited.
+	MaxOpen int `json:"max_open"`
+	// Open is the number of established connections, both in use and idle.
+	Open int `json:"open"`
+	// InUse is the number of connections currently in use.
+	InUse int `json:"in_use"`
+	// Idle is the number of idle connections.
+	Idle int `json:"idle"`
+	// WaitCount is the total number of connections waited for.
+	WaitCount int64 `json:"wait_count"`
+	// WaitDurationSeconds is the total time blocked waiting for a new connection.
+	WaitDurationSeconds float64 `json:"wait_duration_seconds"`
+}
+
+// DBPoolStatus reports the state of the connection pools of the primary database and its replicas, if any.
+type DBPoolStatus struct {
+	Primary  DBPoolStats   `json:"primary"`
+	Replicas []DBPoolStats `json:"replicas,omitempty"`
+}
+
+// DBPoolResizeRequest is the request body used to resize the database connection pools at runtime. Unset fields are
+// left unchanged.
+type DBPoolResizeRequest struct {
+	// MaxOpen is the maximum number of open connections. Zero means unlimited.
+	MaxOpen *int `json:"max_open"`
+	// MaxIdle is the maximum number of idle connections. Zero means no idle connections are retained.
+	MaxIdle *int `json:"max_idle"`
+}
+
+func (req DBPoolResizeRequest) validate() error {
+	if req.MaxOpen == nil && req.MaxIdle == nil {
+		return fmt.Errorf("at least one of max_open and max_idle must be set")
+	}
+	if req.MaxOpen != nil && *req.MaxOpen < 0 {
+		return fmt.Errorf("max_open must not be negative")
+	}
+	if req.MaxIdle != nil && *req.MaxIdle < 0 {
+		return fmt.Errorf("max_idle must not be negative")
+	}
+	return nil
+}
+
+// dbPools returns the connection handlers of the primary database and its replicas, if any. The primary is always
+// the first element. Returns nil if the database is disabled.
+func (app *App) dbPools() []*datastore.DB {
+	if app.db == nil {
+		return nil
+	}
+
+	dbs := []*datastore.DB{app.db}
+	if app.dbLoadBalancer != nil {
+		dbs = append(dbs, app.dbLoadBalancer.Replicas()...)
+	}
+	return dbs
+}
+
+func dbPoolStats(db *datastore.DB) DBPoolStats {
+	s := db.Stats()
+
+	return DBPoolStats{
+		Host:                db.Address(),
+		MaxOpen:             s.MaxOpenConnections,
+		Open:                s.OpenConnections,
+		InUse:               s.InUse,
+		Idle:                s.Idle,
+		WaitCount:           s.WaitCount,
+		WaitDurationSeconds: s.WaitDuration.Seconds(),
+	}
+}
+
+// DBPoolStatus reports the state of the database connection pools.
+func (app *App) DBPoolStatus() DBPoolStatus {
+	var status DBPoolStatus
+	for i, db := range app.dbPools() {
+		if i == 0 {
+			status.Primary = dbPoolStats(db)
+			continue
+		}
+		status.Replicas = append(status.Replicas, dbPoolStats(db))
+	}
+
+	return status
+}
+
+// ResizeDBPools applies the given limits to the connection pools of the primary database and its replicas. Changes
+// are not persisted, the configured `database.pool` settings apply again after a restart.
+func (app *App) ResizeDBPools(req DBPoolResizeRequest) error {
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	l := dcontext.GetLogger(app)
+	for _, db := range app.dbPools() {
+		// The order matters, as setting the maximum number of open connections lowers the maximum number of idle
+		// connections if needed.
+		if req.MaxOpen != nil {
+			db.SetMaxOpenConns(*req.MaxOpen)
+			l.WithFields(logrus.Fields{"host": db.Address(), "max_open": *req.MaxOpen}).Info("resized database connection pool")
+		}
+		if req.MaxIdle != nil {
+			db.SetMaxIdleConns(*req.MaxIdle)
+			l.WithFields(logrus.Fields{"host": db.Address(), "max_idle": *req.MaxIdle}).Info("resized database idle connection pool")
+		}
+	}
+
+	return nil
+}
+
+// DBPoolHandler returns the handler of the database connection pool admin endpoint, exposed on the debug server. A GET
+// request reports the state of the connection pools, while a PUT request resizes them (see DBPoolResizeRequest) and
+// reports their updated state.
+func (app *App) DBPoolHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req DBPoolResizeRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			if err := app.ResizeDBPools(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(app.DBPoolStatus()); err != nil {
+			dcontext.GetLogger(app).WithError(err).Error("error writing database pool status")
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBPoolHandler(t *testing.T) {
+	// connections are established lazily, so no database server is needed
+	sqlDB, err := sql.Open("pgx", "postgres://localhost:5432/registry")
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	sqlDB.SetMaxOpenConns(10)
+
+	app := &App{Context: context.Background(), db: &datastore.DB{DB: sqlDB}}
+	h := app.DBPoolHandler()
+
+	status := func(method, body string) (int, DBPoolStatus) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/debug/database/pool", strings.NewReader(body)))
+
+		var s DBPoolStatus
+		if w.Code == http.StatusOK {
+			require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&s))
+		}
+		return w.Code, s
+	}
+
+	code, s := status(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 10, s.Primary.MaxOpen)
+	require.Zero(t, s.Primary.InUse)
+	require.Empty(t, s.Replicas)
+
+	code, s = status(http.MethodPut, `{"max_open": 50, "max_idle": 25}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 50, s.Primary.MaxOpen)
+	require.Equal(t, 50, sqlDB.Stats().MaxOpenConnections)
+
+	// unset fields are left unchanged
+	code, s = status(http.MethodPut, `{"max_idle": 5}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 50, s.Primary.MaxOpen)
+
+	for _, body := range []string{`{}`, `{"max_open": -1}`, `{"max_idle": -1}`, `{"max_open": "50"}`, `foo`} {
+		code, _ = status(http.MethodPut, body)
+		require.Equal(t, http.StatusBadRequest, code, body)
+	}
+	require.Equal(t, 50, sqlDB.Stats().MaxOpenConnections)
+
+	code, _ = status(http.MethodPost, "")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+}
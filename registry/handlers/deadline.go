@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+)
+
+// deadlineRule sets the deadline of requests matching a route and a set of methods.
+type deadlineRule struct {
+	route    string
+	methods  map[string]struct{}
+	deadline time.Duration
+}
+
+func (r deadlineRule) matches(route, method string) bool {
+	if r.route != "" && r.route != route {
+		return false
+	}
+	if len(r.methods) == 0 {
+		return true
+	}
+	_, ok := r.methods[method]
+	return ok
+}
+
+// deadlinePolicy determines the deadline of Distribution API requests.
+type deadlinePolicy struct {
+	def   time.Duration
+	rules []deadlineRule
+}
+
+func newDeadlinePolicy(config configuration.Deadlines) (*deadlinePolicy, error) {
+	if config.Default < 0 {
+		return nil, errors.New("http.deadlines.default must not be negative")
+	}
+
+	routes := make(map[string]struct{})
+	for _, d := range v2.APIDescriptor.RouteDescriptors {
+		routes[d.Name] = struct{}{}
+	}
+
+	p := &deadlinePolicy{def: config.Default}
+	for i, c := range config.Rules {
+		if c.Route != "" {
+			if _, ok := routes[c.Route]; !ok {
+				return nil, fmt.Errorf("http.deadlines.rules[%d]: unknown route %q", i, c.Route)
+			}
+		}
+		if c.Deadline < 0 {
+			return nil, fmt.Errorf("http.deadlines.rules[%d]: deadline must not be negative", i)
+		}
+
+		r := deadlineRule{route: c.Route, methods: make(map[string]struct{}, len(c.Methods)), deadline: c.Deadline}
+		for _, m := range c.Methods {
+			r.methods[strings.ToUpper(m)] = struct{}{}
+		}
+		p.rules = append(p.rules, r)
+	}
+
+	return p, nil
+}
+
+// deadline returns the deadline of requests with the given method to the given route. Zero means no deadline.
+func (p *deadlinePolicy) deadline(route, method string) time.Duration {
+	if p == nil {
+		return 0
+	}
+	for _, r := range p.rules {
+		if r.matches(route, method) {
+			return r.deadline
+		}
+	}
+	return p.def
+}
+
+type requestDeadlineKey struct{}
+
+// requestDeadline is the deadline applied to a request, kept in the request context to report it once exceeded.
+type requestDeadline struct {
+	route    string
+	deadline time.Duration
+}
+
+// deadlineMiddleware applies the request deadline policy to the requests of the given Distribution API route. The
+// deadline is set on the request context, so that it propagates to everything done while serving the request.
+func (app *App) deadlineMiddleware(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := app.deadlines.deadline(route, r.Method)
+		if d == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		ctx = context.WithValue(ctx, requestDeadlineKey{}, requestDeadline{route: route, deadline: d})
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// deadlineExceededErrors replaces errs with a single ErrorCodeRequestTimeout error if the request deadline was
+// exceeded, as the errors caused by the cancellation of storage driver calls and database queries are not meaningful
+// to clients. Otherwise, errs is returned unchanged.
+func deadlineExceededErrors(ctx context.Context, errs errcode.Errors) errcode.Errors {
+	rd, ok := ctx.Value(requestDeadlineKey{}).(requestDeadline)
+	if !ok || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errs
+	}
+
+	return errcode.Errors{errcode.ErrorCodeRequestTimeout.WithDetail(map[string]string{
+		"route":    rd.route,
+		"deadline": rd.deadline.String(),
+	})}
+}
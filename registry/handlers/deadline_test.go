@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeadlinePolicy(t *testing.T) {
+	p, err := newDeadlinePolicy(configuration.Deadlines{
+		Default: time.Minute,
+		Rules: []configuration.DeadlineRule{
+			{Route: v2.RouteNameManifest, Methods: []string{"get", http.MethodHead}, Deadline: 10 * time.Second},
+			{Route: v2.RouteNameBlobUploadChunk, Methods: []string{http.MethodPut, http.MethodPatch}, Deadline: 5 * time.Minute},
+			{Route: v2.RouteNameBlob, Deadline: 0},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 10*time.Second, p.deadline(v2.RouteNameManifest, http.MethodGet))
+	require.Equal(t, 10*time.Second, p.deadline(v2.RouteNameManifest, http.MethodHead))
+	require.Equal(t, time.Minute, p.deadline(v2.RouteNameManifest, http.MethodPut))
+	require.Equal(t, 5*time.Minute, p.deadline(v2.RouteNameBlobUploadChunk, http.MethodPut))
+	require.Equal(t, time.Duration(0), p.deadline(v2.RouteNameBlob, http.MethodGet))
+	require.Equal(t, time.Minute, p.deadline(v2.RouteNameTags, http.MethodGet))
+
+	var nilPolicy *deadlinePolicy
+	require.Zero(t, nilPolicy.deadline(v2.RouteNameManifest, http.MethodGet))
+}
+
+func TestNewDeadlinePolicy_Invalid(t *testing.T) {
+	tcs := map[string]configuration.Deadlines{
+		"negative default":  {Default: -time.Second},
+		"negative deadline": {Rules: []configuration.DeadlineRule{{Route: v2.RouteNameManifest, Deadline: -time.Second}}},
+		"unknown route":     {Rules: []configuration.DeadlineRule{{Route: "manifests", Deadline: time.Second}}},
+	}
+
+	for tn, tc := range tcs {
+		t.Run(tn, func(t *testing.T) {
+			_, err := newDeadlinePolicy(tc)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDeadlineMiddleware(t *testing.T) {
+	p, err := newDeadlinePolicy(configuration.Deadlines{
+		Rules: []configuration.DeadlineRule{
+			{Route: v2.RouteNameManifest, Methods: []string{http.MethodGet}, Deadline: time.Millisecond},
+		},
+	})
+	require.NoError(t, err)
+	app := &App{Context: context.Background(), deadlines: p}
+
+	var errs errcode.Errors
+	h := app.deadlineMiddleware(v2.RouteNameManifest, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// simulate a slow storage driver call
+		<-r.Context().Done()
+		errs = deadlineExceededErrors(r.Context(), errcode.Errors{errcode.FromUnknownError(r.Context().Err())})
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v2/foo/manifests/latest", nil))
+
+	require.Len(t, errs, 1)
+	var e errcode.Error
+	require.True(t, errors.As(errs[0], &e))
+	require.Equal(t, errcode.ErrorCodeRequestTimeout, e.Code)
+	require.Equal(t, http.StatusGatewayTimeout, e.Code.Descriptor().HTTPStatusCode)
+	require.Equal(t, map[string]string{"route": v2.RouteNameManifest, "deadline": "1ms"}, e.Detail)
+
+	// requests without a deadline are left untouched
+	h = app.deadlineMiddleware(v2.RouteNameManifest, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		require.False(t, ok)
+
+		in := errcode.Errors{errcode.ErrorCodeUnknown}
+		require.Equal(t, in, deadlineExceededErrors(r.Context(), in))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/v2/foo/manifests/latest", nil))
+}
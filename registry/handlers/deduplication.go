@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+)
+
+const (
+	defaultDeduplicationTopSharedBlobs = 10
+	intervalQueryParamKey              = "interval"
+	periodsQueryParamKey               = "periods"
+	periodsQueryParamValueMin          = 1
+	periodsQueryParamValueMax          = 1000
+	defaultDeduplicationPeriods        = 12
+)
+
+var intervalQueryParamValidValues = []string{
+	datastore.DeduplicationIntervalDay,
+	datastore.DeduplicationIntervalWeek,
+	datastore.DeduplicationIntervalMonth,
+}
+
+type deduplicationHandler struct {
+	*Context
+}
+
+func deduplicationDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	deduplicationHandler := &deduplicationHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(deduplicationHandler.GetDeduplicationStatistics),
+	}
+}
+
+// DeduplicationStatsAPIResponse is the deduplication efficiency at a point in time.
+type DeduplicationStatsAPIResponse struct {
+	UniqueBlobs int64   `json:"unique_blobs"`
+	UniqueBytes int64   `json:"unique_bytes"`
+	LinkedBlobs int64   `json:"linked_blobs"`
+	LinkedBytes int64   `json:"linked_bytes"`
+	SavedBytes  int64   `json:"saved_bytes"`
+	Ratio       float64 `json:"ratio"`
+}
+
+// SharedBlobAPIResponse is a blob linked to more than one repository.
+type SharedBlobAPIResponse struct {
+	Digest       string `json:"digest"`
+	MediaType    string `json:"media_type"`
+	Size         int64  `json:"size_bytes"`
+	Repositories int64  `json:"repositories"`
+	SavedBytes   int64  `json:"saved_bytes"`
+}
+
+// DeduplicationSnapshotAPIResponse is the deduplication efficiency at the end of a period.
+type DeduplicationSnapshotAPIResponse struct {
+	Period string `json:"period"`
+	DeduplicationStatsAPIResponse
+}
+
+// DeduplicationAPIResponse is the response body for the deduplication statistics endpoint.
+type DeduplicationAPIResponse struct {
+	DeduplicationStatsAPIResponse
+	TopSharedBlobs []SharedBlobAPIResponse            `json:"top_shared_blobs"`
+	History        []DeduplicationSnapshotAPIResponse `json:"history"`
+}
+
+type deduplicationParams struct {
+	topSharedBlobs int
+	interval       string
+	periods        int
+}
+
+func deduplicationParamsFromRequest(r *http.Request) (deduplicationParams, error) {
+	p := deduplicationParams{
+		topSharedBlobs: defaultDeduplicationTopSharedBlobs,
+		interval:       datastore.DeduplicationIntervalMonth,
+		periods:        defaultDeduplicationPeriods,
+	}
+
+	q := r.URL.Query()
+	if q.Has(nQueryParamKey) {
+		val, valid := isQueryParamTypeInt(q.Get(nQueryParamKey))
+		if !valid {
+			detail := v1.InvalidQueryParamTypeErrorDetail(nQueryParamKey, nQueryParamValidTypes)
+			return p, v1.ErrorCodeInvalidQueryParamType.WithDetail(detail)
+		}
+		if !isQueryParamIntValueInBetween(val, nQueryParamValueMin, nQueryParamValueMax) {
+			detail := v1.InvalidQueryParamValueRangeErrorDetail(nQueryParamKey, nQueryParamValueMin, nQueryParamValueMax)
+			return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		p.topSharedBlobs = val
+	}
+
+	if q.Has(intervalQueryParamKey) {
+		val := strings.ToLower(strings.TrimSpace(q.Get(intervalQueryParamKey)))
+		valid := false
+		for _, v := range intervalQueryParamValidValues {
+			if val == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			detail := v1.InvalidQueryParamValueErrorDetail(intervalQueryParamKey, intervalQueryParamValidValues)
+			return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		p.interval = val
+	}
+
+	if q.Has(periodsQueryParamKey) {
+		val, valid := isQueryParamTypeInt(q.Get(periodsQueryParamKey))
+		if !valid {
+			detail := v1.InvalidQueryParamTypeErrorDetail(periodsQueryParamKey, nQueryParamValidTypes)
+			return p, v1.ErrorCodeInvalidQueryParamType.WithDetail(detail)
+		}
+		if !isQueryParamIntValueInBetween(val, periodsQueryParamValueMin, periodsQueryParamValueMax) {
+			detail := v1.InvalidQueryParamValueRangeErrorDetail(periodsQueryParamKey, periodsQueryParamValueMin, periodsQueryParamValueMax)
+			return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		p.periods = val
+	}
+
+	return p, nil
+}
+
+// GetDeduplicationStatistics reports how much storage is saved by blob deduplication across repositories: the current
+// stats, the blobs that save the most and the evolution over time.
+func (h *deduplicationHandler) GetDeduplicationStatistics(w http.ResponseWriter, r *http.Request) {
+	if !h.App.Config.Database.Enabled {
+		detail := v1.MissingServerDependencyTypeErrorDetail("database")
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail(detail))
+		return
+	}
+
+	params, err := deduplicationParamsFromRequest(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	// these are expensive aggregations, so favor replicas if available
+	s := datastore.NewDeduplicationStore(h.App.replicaDB(h, ""))
+
+	stats, err := s.Stats(h.Context)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	shared, err := s.TopSharedBlobs(h.Context, params.topSharedBlobs)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	history, err := s.History(h.Context, params.interval, params.periods)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := DeduplicationAPIResponse{
+		DeduplicationStatsAPIResponse: DeduplicationStatsAPIResponse{
+			UniqueBlobs: stats.UniqueBlobs,
+			UniqueBytes: stats.UniqueBytes,
+			LinkedBlobs: stats.LinkedBlobs,
+			LinkedBytes: stats.LinkedBytes,
+			SavedBytes:  stats.SavedBytes(),
+			Ratio:       stats.Ratio(),
+		},
+		TopSharedBlobs: make([]SharedBlobAPIResponse, 0, len(shared)),
+		History:        make([]DeduplicationSnapshotAPIResponse, 0, len(history)),
+	}
+	for _, b := range shared {
+		resp.TopSharedBlobs = append(resp.TopSharedBlobs, SharedBlobAPIResponse{
+			Digest:       b.Digest.String(),
+			MediaType:    b.MediaType,
+			Size:         b.Size,
+			Repositories: b.Repositories,
+			SavedBytes:   b.SavedBytes(),
+		})
+	}
+	for _, ss := range history {
+		resp.History = append(resp.History, DeduplicationSnapshotAPIResponse{
+			Period: timeToString(ss.Time),
+			DeduplicationStatsAPIResponse: DeduplicationStatsAPIResponse{
+				UniqueBlobs: ss.UniqueBlobs,
+				UniqueBytes: ss.UniqueBytes,
+				LinkedBlobs: ss.LinkedBlobs,
+				LinkedBytes: ss.LinkedBytes,
+				SavedBytes:  ss.SavedBytes(),
+				Ratio:       ss.Ratio(),
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
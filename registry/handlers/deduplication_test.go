@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicationParamsFromRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expected     deduplicationParams
+		expectedCode errcode.ErrorCode
+	}{
+		{
+			name:     "defaults",
+			expected: deduplicationParams{topSharedBlobs: 10, interval: datastore.DeduplicationIntervalMonth, periods: 12},
+		},
+		{
+			name:     "all set",
+			query:    "n=5&interval=Week&periods=52",
+			expected: deduplicationParams{topSharedBlobs: 5, interval: datastore.DeduplicationIntervalWeek, periods: 52},
+		},
+		{
+			name:         "n not an integer",
+			query:        "n=a",
+			expectedCode: v1.ErrorCodeInvalidQueryParamType,
+		},
+		{
+			name:         "n out of range",
+			query:        "n=0",
+			expectedCode: v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:         "unknown interval",
+			query:        "interval=year",
+			expectedCode: v1.ErrorCodeInvalidQueryParamValue,
+		},
+		{
+			name:         "periods not an integer",
+			query:        "periods=1.5",
+			expectedCode: v1.ErrorCodeInvalidQueryParamType,
+		},
+		{
+			name:         "periods out of range",
+			query:        "periods=1001",
+			expectedCode: v1.ErrorCodeInvalidQueryParamValue,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/gitlab/v1/statistics/deduplication/?"+test.query, nil)
+			p, err := deduplicationParamsFromRequest(r)
+			if test.expectedCode != 0 {
+				require.Error(t, err)
+				require.Equal(t, test.expectedCode, err.(errcode.Error).Code)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, p)
+		})
+	}
+}
+
+func TestDeduplicationHandler_DatabaseDisabled(t *testing.T) {
+	app := &App{Config: &configuration.Configuration{}}
+	h := &deduplicationHandler{Context: &Context{App: app, Context: context.Background()}}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/gitlab/v1/statistics/deduplication/", nil)
+	h.GetDeduplicationStatistics(w, r)
+
+	require.Len(t, h.Errors, 1)
+	require.Equal(t, v1.ErrorCodeNotImplemented, h.Errors[0].(errcode.Error).Code)
+}
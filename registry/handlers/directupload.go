@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/internal/feature"
+	"github.com/docker/distribution/log"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+const (
+	// directUploadPartsHeader is the header used by clients to request a direct upload when starting a blob upload,
+	// set to the number of parts in which the blob data is going to be uploaded.
+	directUploadPartsHeader = "Gitlab-Direct-Upload-Parts"
+
+	defaultDirectUploadExpiry   = time.Hour
+	defaultDirectUploadMaxParts = 100
+	// maxDirectUploadParts is the maximum number of parts supported by storage backends, as limited by S3.
+	maxDirectUploadParts = 10000
+)
+
+// directUploader hands out URLs for trusted clients to upload blob data directly to the storage backend.
+type directUploader struct {
+	trustedClients []*net.IPNet
+	trustedProxies trustedProxies
+	expiry         time.Duration
+	maxParts       int
+}
+
+func newDirectUploader(config configuration.UploadsDirect, driver storagedriver.StorageDriver, proxies trustedProxies) (*directUploader, error) {
+	if _, ok := driver.(storagedriver.DirectUploader); !ok {
+		return nil, fmt.Errorf("storage driver %q does not support direct uploads", driver.Name())
+	}
+	if len(config.TrustedClients) == 0 {
+		return nil, errors.New("at least one trusted client must be configured")
+	}
+
+	du := &directUploader{
+		trustedProxies: proxies,
+		expiry:         config.Expiry,
+		maxParts:       config.MaxParts,
+	}
+	for i, c := range config.TrustedClients {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("trustedclients[%d]: %w", i, err)
+		}
+		du.trustedClients = append(du.trustedClients, n)
+	}
+	if du.expiry <= 0 {
+		du.expiry = defaultDirectUploadExpiry
+	}
+	if du.maxParts == 0 {
+		du.maxParts = defaultDirectUploadMaxParts
+	}
+	if du.maxParts < 0 || du.maxParts > maxDirectUploadParts {
+		return nil, fmt.Errorf("maxparts must be between 1 and %d", maxDirectUploadParts)
+	}
+
+	return du, nil
+}
+
+// trusted returns whether the client of r is allowed to upload directly to the storage backend.
+func (du *directUploader) trusted(r *http.Request) bool {
+	ip := du.trustedProxies.clientIP(r)
+	return ip != nil && ipNetsContain(du.trustedClients, ip)
+}
+
+// DirectUploadAPIResponse is the response body for a blob upload started as a direct upload.
+type DirectUploadAPIResponse struct {
+	PartURLs  []string `json:"part_urls"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// directUploadParts returns the number of parts of the direct upload requested with r, if any. Zero is returned if
+// the client did not request a direct upload, or if direct uploads are not available to it, in which case the upload
+// proceeds as a regular one.
+func (buh *blobUploadHandler) directUploadParts(r *http.Request) (int, error) {
+	val := r.Header.Get(directUploadPartsHeader)
+	if val == "" {
+		return 0, nil
+	}
+
+	du := buh.App.directUploader
+	l := log.GetLogger(log.WithContext(buh))
+	if du == nil || !feature.DirectUploads.Enabled() {
+		l.Debug("direct uploads not available, falling back to regular upload")
+		return 0, nil
+	}
+	if !du.trusted(r) {
+		l.WithFields(log.Fields{"client_ip": du.trustedProxies.clientIP(r)}).Info("client not trusted for direct uploads, falling back to regular upload")
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 || n > du.maxParts {
+		detail := fmt.Sprintf("%s header must be a number between 1 and %d", directUploadPartsHeader, du.maxParts)
+		return 0, v2.ErrorCodeDirectUploadInvalid.WithDetail(detail)
+	}
+
+	return n, nil
+}
+
+// directUploadResponse writes the response for a blob upload started as a direct upload of n parts, including the
+// URLs to which the client must upload each part. The upload is canceled if the URLs can not be obtained.
+func (buh *blobUploadHandler) directUploadResponse(w http.ResponseWriter, r *http.Request, n int) error {
+	du := buh.App.directUploader
+	expiresAt := time.Now().Add(du.expiry)
+	urls, err := storage.DirectUploadURLs(buh, buh.App.driver, buh.Repository.Named().Name(), buh.Upload.ID(), n, du.expiry)
+	if err != nil {
+		buh.cancelUpload()
+		return fmt.Errorf("obtaining direct upload URLs: %w", err)
+	}
+
+	// the upload data is not going to be written through the registry, so its size is expected to differ from the
+	// offset in the upload state when resuming it
+	buh.State.Direct = true
+	if err := buh.blobUploadResponse(w, r, true); err != nil {
+		return err
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Docker-Upload-UUID", buh.Upload.ID())
+	w.Header().Set(directUploadPartsHeader, strconv.Itoa(n))
+	w.WriteHeader(http.StatusAccepted)
+
+	return json.NewEncoder(w).Encode(DirectUploadAPIResponse{
+		PartURLs:  urls,
+		ExpiresAt: timeToString(expiresAt),
+	})
+}
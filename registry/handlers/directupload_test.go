@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/internal/feature"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+// directUploadDriver is an in-memory storage driver that supports direct uploads, returning fake URLs.
+type directUploadDriver struct {
+	storagedriver.StorageDriver
+}
+
+func (directUploadDriver) DirectUploadURLs(_ context.Context, _ string, n int, _ time.Duration) ([]string, error) {
+	return make([]string, n), nil
+}
+
+func TestNewDirectUploader(t *testing.T) {
+	d := directUploadDriver{inmemory.New()}
+
+	tcs := []struct {
+		name         string
+		config       configuration.UploadsDirect
+		driver       storagedriver.StorageDriver
+		wantErr      bool
+		wantExpiry   time.Duration
+		wantMaxParts int
+	}{
+		{
+			name:    "unsupported driver",
+			config:  configuration.UploadsDirect{Enabled: true, TrustedClients: []string{"10.0.0.0/8"}},
+			driver:  inmemory.New(),
+			wantErr: true,
+		},
+		{
+			name:    "no trusted clients",
+			config:  configuration.UploadsDirect{Enabled: true},
+			driver:  d,
+			wantErr: true,
+		},
+		{
+			name:    "invalid trusted client",
+			config:  configuration.UploadsDirect{Enabled: true, TrustedClients: []string{"10.0.0.1"}},
+			driver:  d,
+			wantErr: true,
+		},
+		{
+			name:    "max parts out of range",
+			config:  configuration.UploadsDirect{Enabled: true, TrustedClients: []string{"10.0.0.0/8"}, MaxParts: 10001},
+			driver:  d,
+			wantErr: true,
+		},
+		{
+			name:         "defaults",
+			config:       configuration.UploadsDirect{Enabled: true, TrustedClients: []string{"10.0.0.0/8"}},
+			driver:       d,
+			wantExpiry:   defaultDirectUploadExpiry,
+			wantMaxParts: defaultDirectUploadMaxParts,
+		},
+		{
+			name: "custom",
+			config: configuration.UploadsDirect{
+				Enabled:        true,
+				TrustedClients: []string{"10.0.0.0/8"},
+				Expiry:         10 * time.Minute,
+				MaxParts:       1000,
+			},
+			driver:       d,
+			wantExpiry:   10 * time.Minute,
+			wantMaxParts: 1000,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			du, err := newDirectUploader(tc.config, tc.driver, nil)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantExpiry, du.expiry)
+			require.Equal(t, tc.wantMaxParts, du.maxParts)
+		})
+	}
+}
+
+func TestDirectUploader_Trusted(t *testing.T) {
+	proxies, err := newTrustedProxies([]string{"127.0.0.0/8"})
+	require.NoError(t, err)
+	du, err := newDirectUploader(configuration.UploadsDirect{
+		Enabled:        true,
+		TrustedClients: []string{"10.0.0.0/8", "192.168.1.0/24"},
+	}, directUploadDriver{inmemory.New()}, proxies)
+	require.NoError(t, err)
+
+	tcs := map[string]struct {
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       bool
+	}{
+		"trusted":                      {remoteAddr: "10.1.2.3:1234", want: true},
+		"trusted in second range":      {remoteAddr: "192.168.1.10:1234", want: true},
+		"untrusted":                    {remoteAddr: "192.168.2.10:1234"},
+		"trusted behind proxy":         {remoteAddr: "127.0.0.1:1234", forwarded: "10.1.2.3", want: true},
+		"trusted behind proxies":       {remoteAddr: "127.0.0.1:1234", forwarded: "10.1.2.3, 127.0.0.2", want: true},
+		"untrusted behind proxy":       {remoteAddr: "127.0.0.1:1234", forwarded: "8.8.8.8"},
+		"spoofed header behind proxy":  {remoteAddr: "127.0.0.1:1234", forwarded: "10.1.2.3, 8.8.8.8"},
+		"spoofed forwarded header":     {remoteAddr: "8.8.8.8:1234", forwarded: "10.1.2.3"},
+		"spoofed real ip header":       {remoteAddr: "8.8.8.8:1234", realIP: "10.1.2.3"},
+		"forwarded header from client": {remoteAddr: "10.1.2.3:1234", forwarded: "8.8.8.8", want: true},
+		"invalid forwarded header":     {remoteAddr: "127.0.0.1:1234", forwarded: "foo"},
+		"invalid remote address":       {remoteAddr: "foo"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v2/foo/blobs/uploads/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			if tc.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-Ip", tc.realIP)
+			}
+			require.Equal(t, tc.want, du.trusted(r))
+		})
+	}
+}
+
+func TestBlobUploadHandler_DirectUploadParts(t *testing.T) {
+	du, err := newDirectUploader(configuration.UploadsDirect{
+		Enabled:        true,
+		TrustedClients: []string{"10.0.0.0/8"},
+		MaxParts:       10,
+	}, directUploadDriver{inmemory.New()}, nil)
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name       string
+		uploader   *directUploader
+		disabledFF bool
+		remoteAddr string
+		header     string
+		want       int
+		wantCode   errcode.ErrorCode
+	}{
+		{
+			name:     "not requested",
+			uploader: du,
+		},
+		{
+			name:   "disabled",
+			header: "2",
+		},
+		{
+			name:       "feature flag disabled",
+			uploader:   du,
+			disabledFF: true,
+			header:     "2",
+		},
+		{
+			name:       "untrusted client",
+			uploader:   du,
+			remoteAddr: "192.168.1.10:1234",
+			header:     "2",
+		},
+		{
+			name:     "valid",
+			uploader: du,
+			header:   "2",
+			want:     2,
+		},
+		{
+			name:     "not a number",
+			uploader: du,
+			header:   "a",
+			wantCode: v2.ErrorCodeDirectUploadInvalid,
+		},
+		{
+			name:     "zero",
+			uploader: du,
+			header:   "0",
+			wantCode: v2.ErrorCodeDirectUploadInvalid,
+		},
+		{
+			name:     "above maximum",
+			uploader: du,
+			header:   "11",
+			wantCode: v2.ErrorCodeDirectUploadInvalid,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(feature.DirectUploads.EnvVariable, "true")
+			if tc.disabledFF {
+				t.Setenv(feature.DirectUploads.EnvVariable, "false")
+			}
+
+			app := &App{Config: &configuration.Configuration{}, directUploader: tc.uploader}
+			buh := &blobUploadHandler{Context: &Context{App: app, Context: context.Background()}}
+
+			r := httptest.NewRequest(http.MethodPost, "/v2/foo/blobs/uploads/", nil)
+			r.RemoteAddr = "10.1.2.3:1234"
+			if tc.remoteAddr != "" {
+				r.RemoteAddr = tc.remoteAddr
+			}
+			if tc.header != "" {
+				r.Header.Set(directUploadPartsHeader, tc.header)
+			}
+
+			n, err := buh.directUploadParts(r)
+			if tc.wantCode != 0 {
+				require.Error(t, err)
+				require.Equal(t, tc.wantCode, err.(errcode.Error).Code)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, n)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/gorilla/mux"
+)
+
+// drainPollInterval is the interval at which in-flight requests are checked while waiting for them to complete.
+const drainPollInterval = 100 * time.Millisecond
+
+// drainSessionIdleTimeout is the time after which an upload session without new requests is considered abandoned, so
+// that it's neither waited for when draining nor kept in memory.
+const drainSessionIdleTimeout = 15 * time.Minute
+
+// drainer keeps track of open blob upload sessions and in-flight blob upload and manifest put requests, so that the
+// registry can be drained before shutting down, without interrupting pushes. Once draining, new blob uploads are
+// rejected, while requests of uploads already in progress and manifest puts are still accepted, so that ongoing pushes
+// can complete. The zero value is ready to use.
+type drainer struct {
+	uploads      int64
+	manifestPuts int64
+
+	mu       sync.Mutex
+	draining chan struct{}
+	// sessions maps the IDs of the blob uploads started or resumed on this instance that were not yet committed or
+	// canceled to the time of their last request. Unlike the request counters, sessions outlive the requests in between
+	// chunks.
+	sessions map[string]time.Time
+}
+
+// ch returns the channel closed once draining starts.
+func (d *drainer) ch() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining == nil {
+		d.draining = make(chan struct{})
+	}
+	return d.draining
+}
+
+// DrainStatus reports the progress of a drain.
+type DrainStatus struct {
+	// Draining is true once a drain has started.
+	Draining bool `json:"draining"`
+	// Uploads is the number of in-flight blob upload requests.
+	Uploads int64 `json:"uploads"`
+	// ManifestPuts is the number of in-flight manifest put requests.
+	ManifestPuts int64 `json:"manifest_puts"`
+	// Sessions is the number of open blob upload sessions, from start to commit or cancel.
+	Sessions int `json:"sessions"`
+}
+
+// start starts draining. Returns false if already draining.
+func (d *drainer) start() bool {
+	ch := d.ch()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	select {
+	case <-ch:
+		return false
+	default:
+		close(ch)
+		return true
+	}
+}
+
+func (d *drainer) isDraining() bool {
+	select {
+	case <-d.ch():
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *drainer) status() DrainStatus {
+	draining := d.isDraining()
+
+	d.mu.Lock()
+	d.pruneSessions()
+	sessions := len(d.sessions)
+	d.mu.Unlock()
+
+	return DrainStatus{
+		Draining:     draining,
+		Uploads:      atomic.LoadInt64(&d.uploads),
+		ManifestPuts: atomic.LoadInt64(&d.manifestPuts),
+		Sessions:     sessions,
+	}
+}
+
+// startSession records the blob upload with the given ID as open, or refreshes the time of its last request if it
+// already is.
+func (d *drainer) startSession(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.sessions == nil {
+		d.sessions = make(map[string]time.Time)
+	}
+	d.pruneSessions()
+	d.sessions[id] = time.Now()
+}
+
+// endSession records the blob upload with the given ID as committed or canceled.
+func (d *drainer) endSession(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.sessions, id)
+}
+
+// pruneSessions forgets the sessions abandoned by clients. Must be called with mu held.
+func (d *drainer) pruneSessions() {
+	for id, last := range d.sessions {
+		if time.Since(last) > drainSessionIdleTimeout {
+			delete(d.sessions, id)
+		}
+	}
+}
+
+// track records the request r as in-flight if it's a blob upload or manifest put, returning a function that must be
+// called once it completes. Returns false if r starts a new blob upload while draining, in which case it must be
+// rejected.
+func (d *drainer) track(r *http.Request) (func(), bool) {
+	var counter *int64
+
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return func() {}, true
+	}
+	switch route.GetName() {
+	case v2.RouteNameBlobUpload:
+		// a POST starts a new upload, or mounts a blob
+		if r.Method == http.MethodPost && d.isDraining() {
+			return nil, false
+		}
+		counter = &d.uploads
+	case v2.RouteNameBlobUploadChunk:
+		counter = &d.uploads
+	case v2.RouteNameManifest:
+		if r.Method != http.MethodPut {
+			return func() {}, true
+		}
+		counter = &d.manifestPuts
+	default:
+		return func() {}, true
+	}
+
+	atomic.AddInt64(counter, 1)
+	return func() { atomic.AddInt64(counter, -1) }, true
+}
+
+// wait blocks until there are no open upload sessions nor in-flight requests, or ctx is done.
+func (d *drainer) wait(ctx context.Context) error {
+	t := time.NewTicker(drainPollInterval)
+	defer t.Stop()
+
+	for {
+		if s := d.status(); s.Uploads == 0 && s.ManifestPuts == 0 && s.Sessions == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Drain starts draining the application. New blob uploads are rejected from now on, while open blob uploads and
+// in-flight manifest puts are allowed to complete. Calling Drain more than once has no effect.
+func (app *App) Drain() {
+	if app.drainer.start() {
+		s := app.drainer.status()
+		dcontext.GetLogger(app).WithFields(map[string]interface{}{
+			"uploads":       s.Uploads,
+			"manifest_puts": s.ManifestPuts,
+			"sessions":      s.Sessions,
+		}).Info("draining: rejecting new blob uploads and waiting for in-flight pushes")
+	}
+}
+
+// Draining returns a channel that is closed once the application starts draining.
+func (app *App) Draining() <-chan struct{} {
+	return app.drainer.ch()
+}
+
+// DrainStatus returns the current status of the drain.
+func (app *App) DrainStatus() DrainStatus {
+	return app.drainer.status()
+}
+
+// WaitDrained blocks until all open blob uploads are committed or canceled and all in-flight blob upload and manifest
+// put requests complete, or ctx is done, in which case its error is returned.
+func (app *App) WaitDrained(ctx context.Context) error {
+	return app.drainer.wait(ctx)
+}
+
+// DrainHandler returns a handler to drain the application through the debug server. A POST request starts draining,
+// and both POST and GET requests respond with the drain status in JSON format.
+func (app *App) DrainHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			app.Drain()
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(app.DrainStatus()); err != nil {
+			dcontext.GetLogger(app).WithError(err).Error("error writing drain status")
+		}
+	})
+}
+
+// rejectDraining responds to a request rejected because the application is draining.
+func rejectDraining(ctx context.Context, w http.ResponseWriter) {
+	// ask clients to reconnect, most likely to another instance
+	w.Header().Set("Connection", "close")
+	if err := errcode.ServeJSON(w, errcode.ErrorCodeUnavailable.WithDetail("registry is draining")); err != nil {
+		dcontext.GetLogger(ctx).Errorf("error serving error json: %v", err)
+	}
+}
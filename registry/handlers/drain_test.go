@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// trackRequest routes a request with the given method and path through the v2 router and calls d.track with it.
+func trackRequest(t *testing.T, d *drainer, method, path string) (func(), bool) {
+	t.Helper()
+
+	var (
+		done    func()
+		ok      bool
+		matched bool
+	)
+	router := v2.Router()
+	for _, name := range []string{v2.RouteNameBlobUpload, v2.RouteNameBlobUploadChunk, v2.RouteNameManifest, v2.RouteNameTags} {
+		router.GetRoute(name).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			matched = true
+			done, ok = d.track(r)
+		}))
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+	require.True(t, matched, "no route matched %s %s", method, path)
+	return done, ok
+}
+
+func TestDrainer_Track(t *testing.T) {
+	var d drainer
+
+	doneUpload, ok := trackRequest(t, &d, http.MethodPost, "/v2/foo/bar/blobs/uploads/")
+	require.True(t, ok)
+	doneChunk, ok := trackRequest(t, &d, http.MethodPatch, "/v2/foo/bar/blobs/uploads/abc")
+	require.True(t, ok)
+	doneManifest, ok := trackRequest(t, &d, http.MethodPut, "/v2/foo/bar/manifests/latest")
+	require.True(t, ok)
+	doneGet, ok := trackRequest(t, &d, http.MethodGet, "/v2/foo/bar/manifests/latest")
+	require.True(t, ok)
+	doneGet()
+
+	require.Equal(t, DrainStatus{Uploads: 2, ManifestPuts: 1}, d.status())
+
+	require.True(t, d.start())
+	require.False(t, d.start())
+
+	// new uploads are rejected while draining, but ongoing pushes can complete
+	_, ok = trackRequest(t, &d, http.MethodPost, "/v2/foo/bar/blobs/uploads/")
+	require.False(t, ok)
+	doneChunk2, ok := trackRequest(t, &d, http.MethodPut, "/v2/foo/bar/blobs/uploads/abc")
+	require.True(t, ok)
+	doneTags, ok := trackRequest(t, &d, http.MethodGet, "/v2/foo/bar/tags/list")
+	require.True(t, ok)
+	doneTags()
+
+	require.Equal(t, DrainStatus{Draining: true, Uploads: 3, ManifestPuts: 1}, d.status())
+
+	doneUpload()
+	doneChunk()
+	doneChunk2()
+	doneManifest()
+	require.Equal(t, DrainStatus{Draining: true}, d.status())
+}
+
+func TestDrainer_Wait(t *testing.T) {
+	var d drainer
+	require.NoError(t, d.wait(context.Background()))
+
+	done, ok := trackRequest(t, &d, http.MethodPut, "/v2/foo/bar/manifests/latest")
+	require.True(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*drainPollInterval)
+	defer cancel()
+	require.ErrorIs(t, d.wait(ctx), context.DeadlineExceeded)
+
+	errc := make(chan error, 1)
+	go func() { errc <- d.wait(context.Background()) }()
+	done()
+
+	select {
+	case err := <-errc:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return after in-flight requests completed")
+	}
+}
+
+func TestDrainer_Sessions(t *testing.T) {
+	var d drainer
+
+	d.startSession("a")
+	d.startSession("b")
+	// resuming an open upload does not count it again
+	d.startSession("a")
+	require.Equal(t, DrainStatus{Sessions: 2}, d.status())
+
+	// sessions are waited for in between requests
+	ctx, cancel := context.WithTimeout(context.Background(), 2*drainPollInterval)
+	defer cancel()
+	require.ErrorIs(t, d.wait(ctx), context.DeadlineExceeded)
+
+	errc := make(chan error, 1)
+	go func() { errc <- d.wait(context.Background()) }()
+	d.endSession("a")
+	d.endSession("b")
+	// ending an unknown session has no effect
+	d.endSession("c")
+
+	select {
+	case err := <-errc:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return after upload sessions ended")
+	}
+	require.Equal(t, DrainStatus{}, d.status())
+}
+
+func TestDrainer_Sessions_Abandoned(t *testing.T) {
+	var d drainer
+
+	d.startSession("a")
+	d.startSession("b")
+	d.mu.Lock()
+	d.sessions["a"] = time.Now().Add(-drainSessionIdleTimeout - time.Second)
+	d.mu.Unlock()
+
+	// abandoned sessions are forgotten
+	require.Equal(t, DrainStatus{Sessions: 1}, d.status())
+
+	d.endSession("b")
+	require.NoError(t, d.wait(context.Background()))
+}
+
+func TestDrainHandler(t *testing.T) {
+	app := &App{Context: context.Background()}
+	h := app.DrainHandler()
+
+	status := func(method string) (int, DrainStatus) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/debug/drain", nil))
+
+		var s DrainStatus
+		if w.Code == http.StatusOK {
+			require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&s))
+		}
+		return w.Code, s
+	}
+
+	code, s := status(http.MethodGet)
+	require.Equal(t, http.StatusOK, code)
+	require.False(t, s.Draining)
+
+	code, _ = status(http.MethodDelete)
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, s = status(http.MethodPost)
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, s.Draining)
+
+	select {
+	case <-app.Draining():
+	default:
+		t.Fatal("expected application to be draining")
+	}
+}
+
+func TestRejectDraining(t *testing.T) {
+	w := httptest.NewRecorder()
+	rejectDraining(context.Background(), w)
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "close", w.Header().Get("Connection"))
+	require.Contains(t, w.Body.String(), "registry is draining")
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/gc"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+)
+
+// Names of the online GC agents, matching the corresponding configuration subsections.
+const (
+	gcAgentBlobs     = "blobs"
+	gcAgentManifests = "manifests"
+)
+
+var gcAgentNames = []string{gcAgentBlobs, gcAgentManifests}
+
+type gcAgentsHandler struct {
+	*Context
+}
+
+func gcAgentsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	gcAgentsHandler := &gcAgentsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet:   http.HandlerFunc(gcAgentsHandler.GetAgents),
+		http.MethodPatch: http.HandlerFunc(gcAgentsHandler.UpdateAgents),
+	}
+}
+
+// GCAgentAPIResponse is the API counterpart for the state of an online GC agent and its review queue.
+type GCAgentAPIResponse struct {
+	Name            string `json:"name"`
+	Paused          bool   `json:"paused"`
+	QueueSize       int    `json:"queue_size"`
+	NextReviewAfter string `json:"next_review_after,omitempty"`
+}
+
+// GCAgentsAPIResponse is the response body for the online GC agents endpoint.
+type GCAgentsAPIResponse struct {
+	Agents []GCAgentAPIResponse `json:"agents"`
+}
+
+// GCAgentsAPIRequest is the request body for the online GC agents endpoint. An empty list of agents targets all
+// running agents.
+type GCAgentsAPIRequest struct {
+	Paused *bool    `json:"paused"`
+	Agents []string `json:"agents,omitempty"`
+}
+
+func (h *gcAgentsHandler) databaseEnabled() bool {
+	if !h.App.Config.Database.Enabled {
+		detail := v1.MissingServerDependencyTypeErrorDetail("database")
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail(detail))
+		return false
+	}
+	return true
+}
+
+// gcQueueStore is the subset of the GC task store interfaces used to inspect a review queue.
+type gcQueueStore interface {
+	Count(ctx context.Context) (int, error)
+	NextReviewAfter(ctx context.Context) (sql.NullTime, error)
+}
+
+// GetAgents lists the online GC agents running in this registry instance, along with the current size and next review
+// date of their review queues.
+func (h *gcAgentsHandler) GetAgents(w http.ResponseWriter, r *http.Request) {
+	if !h.databaseEnabled() {
+		return
+	}
+
+	resp := GCAgentsAPIResponse{Agents: make([]GCAgentAPIResponse, 0, len(h.App.gcAgents))}
+	for _, name := range gcAgentNames {
+		a, ok := h.App.gcAgents[name]
+		if !ok {
+			continue
+		}
+
+		count, next, err := h.App.gcQueueState(h.Context, name)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+
+		agent := GCAgentAPIResponse{
+			Name:      name,
+			Paused:    a.Paused(),
+			QueueSize: count,
+		}
+		if next.Valid {
+			agent.NextReviewAfter = timeToString(next.Time)
+		}
+		resp.Agents = append(resp.Agents, agent)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// UpdateAgents pauses or resumes the online GC agents running in this registry instance. The state is kept in memory,
+// so it does not survive a restart and does not affect other registry instances.
+func (h *gcAgentsHandler) UpdateAgents(w http.ResponseWriter, r *http.Request) {
+	if !h.databaseEnabled() {
+		return
+	}
+
+	var req GCAgentsAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return
+	}
+	if req.Paused == nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail("the 'paused' body parameter must be a boolean"))
+		return
+	}
+
+	names := req.Agents
+	if len(names) == 0 {
+		names = h.App.runningGCAgentNames()
+	}
+
+	agents := make([]*gc.Agent, 0, len(names))
+	for _, name := range names {
+		a, ok := h.App.gcAgents[name]
+		if !ok {
+			detail := fmt.Sprintf("the 'agents' body parameter elements must be one of the running agents: %s, got %q", strings.Join(h.App.runningGCAgentNames(), ", "), name)
+			h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+			return
+		}
+		agents = append(agents, a)
+	}
+
+	l := log.GetLogger(log.WithContext(h))
+	for _, a := range agents {
+		if *req.Paused {
+			a.Pause()
+		} else {
+			a.Resume()
+		}
+		l.WithFields(log.Fields{"worker": a.Name(), "paused": *req.Paused}).Info("online GC agent state updated")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// gcQueueState returns the size and next review date of the review queue of the named online GC agent.
+func (app *App) gcQueueState(ctx context.Context, name string) (int, sql.NullTime, error) {
+	var s gcQueueStore
+	if name == gcAgentBlobs {
+		s = datastore.NewGCBlobTaskStore(app.db)
+	} else {
+		s = datastore.NewGCManifestTaskStore(app.db)
+	}
+
+	count, err := s.Count(ctx)
+	if err != nil {
+		return 0, sql.NullTime{}, err
+	}
+	next, err := s.NextReviewAfter(ctx)
+	if err != nil {
+		return 0, sql.NullTime{}, err
+	}
+	return count, next, nil
+}
+
+func (app *App) runningGCAgentNames() []string {
+	names := make([]string, 0, len(app.gcAgents))
+	for _, name := range gcAgentNames {
+		if _, ok := app.gcAgents[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type gcReviewsHandler struct {
+	*Context
+}
+
+func gcReviewsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	gcReviewsHandler := &gcReviewsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodPost: http.HandlerFunc(gcReviewsHandler.ScheduleReview),
+	}
+}
+
+// GCReviewAPIRequest is the request body for the online GC reviews endpoint. Exactly one of the fields must be set.
+type GCReviewAPIRequest struct {
+	Digest     string `json:"digest,omitempty"`
+	Repository string `json:"repository,omitempty"`
+}
+
+// GCReviewAPIResponse is the response body for the online GC reviews endpoint.
+type GCReviewAPIResponse struct {
+	BlobTasks     int64 `json:"blob_tasks"`
+	ManifestTasks int64 `json:"manifest_tasks"`
+}
+
+const (
+	gcReviewDigestBodyParamKey     = "digest"
+	gcReviewRepositoryBodyParamKey = "repository"
+)
+
+// ScheduleReview queues a blob, or all manifests and blobs of a repository, for immediate review by the online GC
+// agents, instead of waiting for the review delay to elapse. Existing tasks are rescheduled.
+func (h *gcReviewsHandler) ScheduleReview(w http.ResponseWriter, r *http.Request) {
+	if !h.App.Config.Database.Enabled {
+		detail := v1.MissingServerDependencyTypeErrorDetail("database")
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail(detail))
+		return
+	}
+
+	var req GCReviewAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return
+	}
+	if (req.Digest == "") == (req.Repository == "") {
+		detail := fmt.Sprintf("exactly one of the '%s' and '%s' body parameters must be set", gcReviewDigestBodyParamKey, gcReviewRepositoryBodyParamKey)
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return
+	}
+
+	var resp GCReviewAPIResponse
+	var ok bool
+	if req.Digest != "" {
+		resp, ok = h.reviewBlob(req.Digest)
+	} else {
+		resp, ok = h.reviewRepository(req.Repository)
+	}
+	if !ok {
+		return
+	}
+
+	l := log.GetLogger(log.WithContext(h))
+	l.WithFields(log.Fields{
+		"digest":         req.Digest,
+		"repository":     req.Repository,
+		"blob_tasks":     resp.BlobTasks,
+		"manifest_tasks": resp.ManifestTasks,
+	}).Info("scheduled online GC review")
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+func (h *gcReviewsHandler) reviewBlob(dgstStr string) (GCReviewAPIResponse, bool) {
+	dgst, err := digest.Parse(dgstStr)
+	if err != nil {
+		detail := fmt.Sprintf("the '%s' body parameter must be a valid digest: %v", gcReviewDigestBodyParamKey, err)
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return GCReviewAPIResponse{}, false
+	}
+
+	if err := datastore.NewGCBlobTaskStore(h.db).ReviewNow(h.Context, dgst); err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			h.Errors = append(h.Errors, v2.ErrorCodeBlobUnknown.WithDetail(dgst))
+		} else {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		}
+		return GCReviewAPIResponse{}, false
+	}
+
+	return GCReviewAPIResponse{BlobTasks: 1}, true
+}
+
+func (h *gcReviewsHandler) reviewRepository(path string) (GCReviewAPIResponse, bool) {
+	var resp GCReviewAPIResponse
+
+	repo, err := datastore.NewRepositoryStore(h.db).FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return resp, false
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return resp, false
+	}
+
+	if err := h.reviewRepositoryTx(repo, &resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return resp, false
+	}
+
+	return resp, true
+}
+
+func (h *gcReviewsHandler) reviewRepositoryTx(repo *models.Repository, resp *GCReviewAPIResponse) error {
+	tx, err := h.db.BeginTx(h.Context, nil)
+	if err != nil {
+		return fmt.Errorf("creating database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	resp.ManifestTasks, err = datastore.NewGCManifestTaskStore(tx).ReviewRepositoryManifestsNow(h.Context, repo)
+	if err != nil {
+		return err
+	}
+	resp.BlobTasks, err = datastore.NewGCBlobTaskStore(tx).ReviewRepositoryBlobsNow(h.Context, repo)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing database transaction: %w", err)
+	}
+	return nil
+}
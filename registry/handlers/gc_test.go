@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/gc"
+	wmocks "github.com/docker/distribution/registry/gc/worker/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCAgentsHandler_UpdateAgents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	bw := wmocks.NewMockWorker(ctrl)
+	bw.EXPECT().Name().Return("blob").AnyTimes()
+	mw := wmocks.NewMockWorker(ctrl)
+	mw.EXPECT().Name().Return("manifest").AnyTimes()
+
+	config := &configuration.Configuration{}
+	config.Database.Enabled = true
+	app := &App{
+		Config: config,
+		gcAgents: map[string]*gc.Agent{
+			gcAgentBlobs:     gc.NewAgent(bw),
+			gcAgentManifests: gc.NewAgent(mw),
+		},
+	}
+
+	update := func(t *testing.T, body string) (*httptest.ResponseRecorder, errcode.Errors) {
+		t.Helper()
+
+		h := &gcAgentsHandler{Context: &Context{App: app, Context: context.Background()}}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPatch, "/gitlab/v1/gc/agents/", strings.NewReader(body))
+		h.UpdateAgents(w, r)
+
+		return w, h.Errors
+	}
+
+	// pause a single agent
+	w, errs := update(t, `{"paused": true, "agents": ["blobs"]}`)
+	require.Empty(t, errs)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.True(t, app.gcAgents[gcAgentBlobs].Paused())
+	require.False(t, app.gcAgents[gcAgentManifests].Paused())
+
+	// pause all agents
+	_, errs = update(t, `{"paused": true}`)
+	require.Empty(t, errs)
+	require.True(t, app.gcAgents[gcAgentBlobs].Paused())
+	require.True(t, app.gcAgents[gcAgentManifests].Paused())
+
+	// resume all agents
+	_, errs = update(t, `{"paused": false}`)
+	require.Empty(t, errs)
+	require.False(t, app.gcAgents[gcAgentBlobs].Paused())
+	require.False(t, app.gcAgents[gcAgentManifests].Paused())
+
+	// unknown agent, no state changes
+	_, errs = update(t, `{"paused": true, "agents": ["manifests", "foo"]}`)
+	require.Len(t, errs, 1)
+	require.Equal(t, v1.ErrorCodeInvalidBodyParamType, errs[0].(errcode.Error).Code)
+	require.False(t, app.gcAgents[gcAgentManifests].Paused())
+
+	// missing paused parameter
+	_, errs = update(t, `{"agents": ["blobs"]}`)
+	require.Len(t, errs, 1)
+	require.Equal(t, v1.ErrorCodeInvalidBodyParamType, errs[0].(errcode.Error).Code)
+
+	// invalid body
+	_, errs = update(t, `{"paused": "yes"}`)
+	require.Len(t, errs, 1)
+	require.Equal(t, v1.ErrorCodeInvalidJSONBody, errs[0].(errcode.Error).Code)
+
+	// database disabled
+	config.Database.Enabled = false
+	_, errs = update(t, `{"paused": true}`)
+	require.Len(t, errs, 1)
+	require.Equal(t, v1.ErrorCodeNotImplemented, errs[0].(errcode.Error).Code)
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/gitlab/v1/metadatapb"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/gc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcAuthorizationMetadataKey is the gRPC metadata key holding the registry JWT of a request, as `Bearer <token>`.
+const grpcAuthorizationMetadataKey = "authorization"
+
+// NewGRPCServer returns a gRPC server exposing the metadata API of the app. Requests are authorized against the app
+// access controller, if any, with the registry JWT sent in the `authorization` metadata key. Transport security,
+// including client certificate verification (mTLS), is configured through opts.
+func (app *App) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(app.grpcUnaryInterceptor))
+	s := grpc.NewServer(opts...)
+	metadatapb.RegisterMetadataServer(s, &metadataServer{app: app})
+	return s
+}
+
+// grpcUnaryInterceptor logs gRPC requests and authorizes them against the app access controller.
+func (app *App) grpcUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx = dcontext.WithLogger(ctx, dcontext.GetLogger(app.Context).WithFields(log.Fields{"grpc_method": info.FullMethod}))
+	l := log.GetLogger(log.WithContext(ctx))
+
+	start := time.Now()
+	ctx, err := app.grpcAuthorized(ctx, info.FullMethod, req)
+	if err == nil {
+		var resp any
+		resp, err = handler(ctx, req)
+		if err == nil {
+			l.WithFields(log.Fields{"duration_ms": time.Since(start).Milliseconds()}).Info("grpc request completed")
+			return resp, nil
+		}
+	}
+
+	st := status.Convert(err)
+	l.WithFields(log.Fields{
+		"duration_ms": time.Since(start).Milliseconds(),
+		"grpc_code":   st.Code().String(),
+	}).WithError(err).Warn("grpc request failed")
+	return nil, err
+}
+
+// grpcAccessRecords returns the access required by a gRPC request, which mirrors that of the HTTP API.
+func grpcAccessRecords(method string, req any) []auth.Access {
+	switch r := req.(type) {
+	case *metadatapb.GetRepositoryRequest:
+		return appendAccessRecords(nil, http.MethodGet, r.GetPath())
+	case *metadatapb.ListRepositoryTagsRequest:
+		return appendAccessRecords(nil, http.MethodGet, r.GetPath())
+	}
+
+	switch method {
+	case metadatapb.Metadata_ListGCAgents_FullMethodName, metadatapb.Metadata_UpdateGCAgents_FullMethodName:
+		return []auth.Access{{Resource: auth.Resource{Type: "registry", Name: "gc"}, Action: "*"}}
+	}
+	return nil
+}
+
+// grpcAuthorized checks if a gRPC request can proceed, returning the authorized context. Requests are always allowed
+// if the app has no access controller, in which case clients are expected to be authenticated by mTLS.
+func (app *App) grpcAuthorized(ctx context.Context, method string, req any) (context.Context, error) {
+	accessController := app.currentAccessController()
+	if accessController == nil {
+		return ctx, nil
+	}
+
+	access := grpcAccessRecords(method, req)
+	if len(access) == 0 {
+		// every method must require some access, otherwise it would be open to all clients
+		return nil, status.Error(codes.PermissionDenied, "unknown method access")
+	}
+
+	// access controllers read credentials from the HTTP request in the context, so build one out of the request
+	// metadata
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, method, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(grpcAuthorizationMetadataKey); len(v) > 0 {
+			r.Header.Set("Authorization", v[0])
+		}
+	}
+
+	authCtx, err := accessController.Authorized(dcontext.WithRequest(ctx, r), access...)
+	if err != nil {
+		var challenge auth.Challenge
+		if errors.As(err, &challenge) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Error("error checking authorization")
+		return nil, status.Error(codes.PermissionDenied, "authorization failed")
+	}
+	return authCtx, nil
+}
+
+// metadataServer implements the gRPC metadata API on top of the app metadata database and online GC agents.
+type metadataServer struct {
+	metadatapb.UnimplementedMetadataServer
+	app *App
+}
+
+func (s *metadataServer) databaseEnabled() error {
+	if !s.app.Config.Database.Enabled {
+		return status.Error(codes.Unimplemented, "the metadata database is not enabled")
+	}
+	return nil
+}
+
+func validateGRPCRepositoryPath(path string) error {
+	if _, err := reference.WithName(path); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid repository path %q: %v", path, err)
+	}
+	return nil
+}
+
+func grpcTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// GetRepository implements metadatapb.MetadataServer.
+func (s *metadataServer) GetRepository(ctx context.Context, req *metadatapb.GetRepositoryRequest) (*metadatapb.Repository, error) {
+	if err := s.databaseEnabled(); err != nil {
+		return nil, err
+	}
+	if err := validateGRPCRepositoryPath(req.GetPath()); err != nil {
+		return nil, err
+	}
+
+	var opts []datastore.RepositoryStoreOption
+	if s.app.redisCache != nil {
+		opts = append(opts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(s.app.redisCache)))
+	}
+	store := datastore.NewRepositoryStore(s.app.db, opts...)
+
+	repo, err := store.FindByPath(ctx, req.GetPath())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if repo == nil {
+		// as for the HTTP API, the size of a group with descendants can be measured even if there is no repository
+		// with the group path, as long as its top-level namespace exists
+		if req.GetSize() != metadatapb.SizeType_SIZE_TYPE_SELF_WITH_DESCENDANTS {
+			return nil, status.Errorf(codes.NotFound, "repository %q not found", req.GetPath())
+		}
+		repo = &models.Repository{Path: req.GetPath()}
+		n, err := datastore.NewNamespaceStore(s.app.db).FindByName(ctx, repo.TopLevelPathSegment())
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if n == nil {
+			return nil, status.Errorf(codes.NotFound, "repository %q not found", req.GetPath())
+		}
+		repo.NamespaceID = n.ID
+		repo.Name = repo.Path[strings.LastIndex(repo.Path, "/")+1:]
+	}
+
+	resp := &metadatapb.Repository{
+		Name:      repo.Name,
+		Path:      repo.Path,
+		CreatedAt: grpcTimestamp(repo.CreatedAt),
+	}
+	if repo.UpdatedAt.Valid {
+		resp.UpdatedAt = grpcTimestamp(repo.UpdatedAt.Time)
+	}
+
+	var size int64
+	precision := sizePrecisionDefault
+	switch req.GetSize() {
+	case metadatapb.SizeType_SIZE_TYPE_UNSPECIFIED:
+		return resp, nil
+	case metadatapb.SizeType_SIZE_TYPE_SELF:
+		size, err = store.Size(ctx, repo)
+	case metadatapb.SizeType_SIZE_TYPE_SELF_WITH_DESCENDANTS:
+		size, precision, err = sizeWithDescendants(ctx, store, repo)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown size type %d", req.GetSize())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp.SizeBytes = &size
+	resp.SizePrecision = precision
+
+	return resp, nil
+}
+
+// ListRepositoryTags implements metadatapb.MetadataServer.
+func (s *metadataServer) ListRepositoryTags(ctx context.Context, req *metadatapb.ListRepositoryTagsRequest) (*metadatapb.ListRepositoryTagsResponse, error) {
+	if err := s.databaseEnabled(); err != nil {
+		return nil, err
+	}
+	if err := validateGRPCRepositoryPath(req.GetPath()); err != nil {
+		return nil, err
+	}
+
+	filters := datastore.FilterParams{MaxEntries: defaultMaximumReturnedEntries}
+	if n := req.GetPageSize(); n != 0 {
+		if n < nQueryParamValueMin || n > nQueryParamValueMax {
+			return nil, status.Errorf(codes.InvalidArgument, "page_size must be between %d and %d", nQueryParamValueMin, nQueryParamValueMax)
+		}
+		filters.MaxEntries = int(n)
+	}
+	if t := req.GetPageToken(); t != "" {
+		if !tagNameBodyParamPattern.MatchString(t) {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		filters.LastEntry = t
+	}
+	if name := req.GetName(); name != "" {
+		if !tagNameQueryParamPattern.MatchString(name) {
+			return nil, status.Errorf(codes.InvalidArgument, "name must match %s", tagNameQueryParamPattern)
+		}
+		filters.Name = name
+	}
+
+	store := datastore.NewRepositoryStore(s.app.db)
+	repo, err := store.FindByPath(ctx, req.GetPath())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if repo == nil {
+		return nil, status.Errorf(codes.NotFound, "repository %q not found", req.GetPath())
+	}
+
+	tags, err := store.TagsDetailPaginated(ctx, repo, filters)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &metadatapb.ListRepositoryTagsResponse{Tags: make([]*metadatapb.Tag, 0, len(tags))}
+	for _, t := range tags {
+		tag := &metadatapb.Tag{
+			Name:        t.Name,
+			Digest:      t.Digest.String(),
+			MediaType:   t.MediaType,
+			SizeBytes:   t.Size,
+			CreatedAt:   grpcTimestamp(t.CreatedAt),
+			PublishedAt: grpcTimestamp(t.PublishedAt),
+		}
+		if t.ConfigDigest.Valid {
+			tag.ConfigDigest = t.ConfigDigest.Digest.String()
+		}
+		if t.UpdatedAt.Valid {
+			tag.UpdatedAt = grpcTimestamp(t.UpdatedAt.Time)
+		}
+		resp.Tags = append(resp.Tags, tag)
+	}
+
+	if len(tags) > 0 {
+		filters.LastEntry = tags[len(tags)-1].Name
+		more, err := store.HasTagsAfterName(ctx, repo, filters)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if more {
+			resp.NextPageToken = filters.LastEntry
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *metadataServer) gcAgents(ctx context.Context) (*metadatapb.ListGCAgentsResponse, error) {
+	resp := &metadatapb.ListGCAgentsResponse{Agents: make([]*metadatapb.GCAgent, 0, len(s.app.gcAgents))}
+	for _, name := range s.app.runningGCAgentNames() {
+		count, next, err := s.app.gcQueueState(ctx, name)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		agent := &metadatapb.GCAgent{
+			Name:      name,
+			Paused:    s.app.gcAgents[name].Paused(),
+			QueueSize: int64(count),
+		}
+		if next.Valid {
+			agent.NextReviewAfter = grpcTimestamp(next.Time)
+		}
+		resp.Agents = append(resp.Agents, agent)
+	}
+	return resp, nil
+}
+
+// ListGCAgents implements metadatapb.MetadataServer.
+func (s *metadataServer) ListGCAgents(ctx context.Context, _ *metadatapb.ListGCAgentsRequest) (*metadatapb.ListGCAgentsResponse, error) {
+	if err := s.databaseEnabled(); err != nil {
+		return nil, err
+	}
+	return s.gcAgents(ctx)
+}
+
+// UpdateGCAgents implements metadatapb.MetadataServer. As for the HTTP API, the state is kept in memory, so it does
+// not survive a restart and does not affect other registry instances.
+func (s *metadataServer) UpdateGCAgents(ctx context.Context, req *metadatapb.UpdateGCAgentsRequest) (*metadatapb.ListGCAgentsResponse, error) {
+	if err := s.databaseEnabled(); err != nil {
+		return nil, err
+	}
+
+	names := req.GetAgents()
+	if len(names) == 0 {
+		names = s.app.runningGCAgentNames()
+	}
+
+	agents := make([]*gc.Agent, 0, len(names))
+	for _, name := range names {
+		a, ok := s.app.gcAgents[name]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "agents must be one of the running agents: %s, got %q", strings.Join(s.app.runningGCAgentNames(), ", "), name)
+		}
+		agents = append(agents, a)
+	}
+
+	l := log.GetLogger(log.WithContext(ctx))
+	for _, a := range agents {
+		if req.GetPaused() {
+			a.Pause()
+		} else {
+			a.Resume()
+		}
+		l.WithFields(log.Fields{"worker": a.Name(), "paused": req.GetPaused()}).Info("online GC agent state updated")
+	}
+
+	return s.gcAgents(ctx)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/gitlab/v1/metadatapb"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/gc"
+	wmocks "github.com/docker/distribution/registry/gc/worker/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestGRPCClient(t *testing.T, app *App) metadatapb.MetadataClient {
+	t.Helper()
+
+	ln := bufconn.Listen(1 << 20)
+	s := app.NewGRPCServer()
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return metadatapb.NewMetadataClient(conn)
+}
+
+func withGRPCToken(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, grpcAuthorizationMetadataKey, "Bearer token")
+}
+
+func TestGRPC_Authorization(t *testing.T) {
+	ac, err := auth.GetAccessController("silly", map[string]any{"realm": "realm", "service": "service"})
+	require.NoError(t, err)
+
+	config := &configuration.Configuration{}
+	app := &App{Config: config, Context: context.Background(), accessController: ac}
+	c := newTestGRPCClient(t, app)
+
+	// no token
+	_, err = c.GetRepository(context.Background(), &metadatapb.GetRepositoryRequest{Path: "foo/bar"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// authorized, but the database is not enabled
+	_, err = c.GetRepository(withGRPCToken(context.Background()), &metadatapb.GetRepositoryRequest{Path: "foo/bar"})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestGRPC_InvalidArguments(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.Database.Enabled = true
+	app := &App{Config: config, Context: context.Background()}
+	c := newTestGRPCClient(t, app)
+	ctx := context.Background()
+
+	_, err := c.GetRepository(ctx, &metadatapb.GetRepositoryRequest{Path: "Foo/Bar"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = c.ListRepositoryTags(ctx, &metadatapb.ListRepositoryTagsRequest{Path: "foo/bar", PageSize: 1001})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = c.ListRepositoryTags(ctx, &metadatapb.ListRepositoryTagsRequest{Path: "foo/bar", PageToken: "-invalid"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = c.ListRepositoryTags(ctx, &metadatapb.ListRepositoryTagsRequest{Path: "foo/bar", Name: "a/b"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPC_UpdateGCAgents_UnknownAgent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	bw := wmocks.NewMockWorker(ctrl)
+	bw.EXPECT().Name().Return("blob").AnyTimes()
+
+	config := &configuration.Configuration{}
+	config.Database.Enabled = true
+	app := &App{
+		Config:   config,
+		Context:  context.Background(),
+		gcAgents: map[string]*gc.Agent{gcAgentBlobs: gc.NewAgent(bw)},
+	}
+	c := newTestGRPCClient(t, app)
+
+	_, err := c.UpdateGCAgents(context.Background(), &metadatapb.UpdateGCAgentsRequest{Paused: true, Agents: []string{"blobs", "manifests"}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), `agents must be one of the running agents: blobs, got "manifests"`)
+	require.False(t, app.gcAgents[gcAgentBlobs].Paused())
+}
+
+func TestGRPCAccessRecords(t *testing.T) {
+	access := grpcAccessRecords(metadatapb.Metadata_GetRepository_FullMethodName, &metadatapb.GetRepositoryRequest{Path: "foo/bar"})
+	require.Equal(t, []auth.Access{{Resource: auth.Resource{Type: "repository", Name: "foo/bar"}, Action: "pull"}}, access)
+
+	access = grpcAccessRecords(metadatapb.Metadata_UpdateGCAgents_FullMethodName, &metadatapb.UpdateGCAgentsRequest{})
+	require.Equal(t, []auth.Access{{Resource: auth.Resource{Type: "registry", Name: "gc"}, Action: "*"}}, access)
+
+	require.Empty(t, grpcAccessRecords("/unknown", nil))
+}
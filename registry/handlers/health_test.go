@@ -212,3 +212,116 @@ func TestHTTPHealthCheck(t *testing.T) {
 		t.Fatal("expected 0 items in health check results")
 	}
 }
+
+func TestReadinessChecks(t *testing.T) {
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+		Health: configuration.Health{
+			Readiness: configuration.Readiness{
+				Enabled:   true,
+				Interval:  time.Hour,
+				Timeout:   time.Second,
+				Threshold: 1,
+			},
+		},
+		Notifications: configuration.Notifications{
+			Endpoints: []configuration.Endpoint{
+				{Name: "test", URL: "http://127.0.0.1:0", Timeout: time.Second},
+			},
+		},
+	}
+
+	app, err := NewApp(context.Background(), config)
+	require.NoError(t, err)
+	registry := health.NewRegistry()
+	app.RegisterReadinessChecks(registry)
+
+	require.Eventually(t, func() bool {
+		return registry.Report().Status == "ready"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	report := registry.Report()
+	require.Len(t, report.Checks, 2)
+	require.Contains(t, report.Checks, "storagedriver_inmemory")
+	require.Contains(t, report.Checks, "notifications")
+}
+
+func TestReadinessChecks_Disabled(t *testing.T) {
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+		Health: configuration.Health{
+			Readiness: configuration.Readiness{
+				Enabled:       true,
+				Interval:      time.Hour,
+				StorageDriver: configuration.ReadinessCheck{Disabled: true},
+				Notifications: configuration.ReadinessNotifications{Disabled: true},
+			},
+		},
+	}
+
+	app, err := NewApp(context.Background(), config)
+	require.NoError(t, err)
+	registry := health.NewRegistry()
+	app.RegisterReadinessChecks(registry)
+	require.Empty(t, registry.Report().Checks)
+
+	// nothing is registered unless enabled
+	app.Config.Health.Readiness.Enabled = false
+	app.Config.Health.Readiness.StorageDriver.Disabled = false
+	app.RegisterReadinessChecks(registry)
+	require.Empty(t, registry.Report().Checks)
+}
+
+func TestReadinessChecks_NotificationEndpointsReloaded(t *testing.T) {
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+		Health: configuration.Health{
+			Readiness: configuration.Readiness{
+				Enabled:       true,
+				Interval:      10 * time.Millisecond,
+				Timeout:       time.Second,
+				Threshold:     1,
+				StorageDriver: configuration.ReadinessCheck{Disabled: true},
+				// fail as soon as there is any endpoint, even with no events pending delivery
+				Notifications: configuration.ReadinessNotifications{MaxPending: -1},
+			},
+		},
+	}
+
+	app, err := NewApp(context.Background(), config)
+	require.NoError(t, err)
+	registry := health.NewRegistry()
+	app.RegisterReadinessChecks(registry)
+
+	// the check passes while there are no endpoints
+	require.Eventually(t, func() bool {
+		return registry.Report().Status == "ready"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, registry.Report().Checks, "notifications")
+
+	// endpoints added by a reload are checked
+	reloaded := *config
+	reloaded.Notifications.Endpoints = []configuration.Endpoint{
+		{Name: "test", URL: "http://127.0.0.1:0", Timeout: time.Second},
+	}
+	require.NoError(t, app.Reload(&reloaded))
+
+	require.Eventually(t, func() bool {
+		return registry.Report().Status != "ready"
+	}, 5*time.Second, 10*time.Millisecond)
+}
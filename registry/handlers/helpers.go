@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +12,7 @@ import (
 
 	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/opencontainers/go-digest"
 )
 
 // closeResources closes all the provided resources after running the target
@@ -38,7 +41,8 @@ func copyFullPayload(ctx context.Context, responseWriter http.ResponseWriter, r
 
 	// Read in the data, if any.
 	copied, err := io.Copy(destWriter, body)
-	if clientClosed != nil && (err != nil || (r.ContentLength > 0 && copied < r.ContentLength)) {
+	// exceeding the request limits cancels the request context as well, but must be reported to the client
+	if clientClosed != nil && !requestLimitExceeded(err) && (err != nil || (r.ContentLength > 0 && copied < r.ContentLength)) {
 		// Didn't receive as much content as expected. Did the client
 		// disconnect during the request? If so, avoid returning a 400
 		// error to keep the logs cleaner.
@@ -96,3 +100,28 @@ func parseContentRange(contentRange string) (startRange int64, endRange int64, e
 
 	return
 }
+
+// writeJSONWithETag encodes v as the JSON response body and sets an ETag header computed from the body and the Link
+// header, which must therefore be set beforehand. If the request includes a matching If-None-Match header, a 304 Not
+// Modified response is written instead, without a body. This allows clients polling paginated lists to avoid
+// downloading a page that did not change.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+
+	digester := digest.Canonical.Digester()
+	digester.Hash().Write(buf.Bytes())
+	digester.Hash().Write([]byte(w.Header().Get("Link")))
+	etag := digester.Digest().String()
+
+	w.Header().Set("Etag", fmt.Sprintf(`"%s"`, etag))
+	if etagMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -22,6 +22,12 @@ type blobUploadState struct {
 
 	// StartedAt is the original start time of the upload.
 	StartedAt time.Time
+
+	// Direct is true if the upload data is uploaded by the client directly to the storage backend.
+	Direct bool `json:",omitempty"`
+
+	// ShortChunk is true if a chunk below the minimum chunk size was uploaded, which must then be the final chunk.
+	ShortChunk bool `json:",omitempty"`
 }
 
 type hmacKey string
@@ -28,6 +28,12 @@ var blobUploadStates = []blobUploadState{
 		UUID:   "dead-1234-beef-0987",
 		Offset: 8675309,
 	},
+	{
+		Name:   "direct",
+		UUID:   "abcd-1234-qwer-0987",
+		Offset: 0,
+		Direct: true,
+	},
 }
 
 var secrets = []string{
@@ -4,10 +4,17 @@ package handlers_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"math/rand"
@@ -78,6 +85,10 @@ func withDelete(config *configuration.Configuration) {
 	config.Storage["delete"] = configuration.Parameters{"enabled": true}
 }
 
+func withDeleteCascadeReferrers(config *configuration.Configuration) {
+	config.Storage["delete"] = configuration.Parameters{"enabled": true, "cascadereferrers": true}
+}
+
 func withAccessLog(config *configuration.Configuration) {
 	config.Log.AccessLog.Disabled = false
 }
@@ -108,10 +119,70 @@ func withFSDriver(path string) configOpt {
 	}
 }
 
+// withStorageRedirect redirects blob downloads to baseURL through the redirect storage middleware.
+func withStorageRedirect(baseURL string) configOpt {
+	return func(config *configuration.Configuration) {
+		if config.Middleware == nil {
+			config.Middleware = make(map[string][]configuration.Middleware)
+		}
+		config.Middleware["storage"] = append(config.Middleware["storage"], configuration.Middleware{
+			Name:    "redirect",
+			Options: configuration.Parameters{"baseurl": baseURL},
+		})
+	}
+}
+
 func withSchema1PreseededInMemoryDriver(config *configuration.Configuration) {
 	config.Storage["schema1Preseededinmemorydriver"] = configuration.Parameters{}
 }
 
+func withSchema1ConvertOnRead(config *configuration.Configuration) {
+	config.Compatibility.Schema1.ConvertOnRead = true
+}
+
+// withUploadPurging enables the tracking of uploads in the database. The purging worker interval is long enough for it
+// not to interfere with tests.
+func withUploadPurging(config *configuration.Configuration) {
+	config.Database.UploadPurging = configuration.DatabaseUploadPurging{
+		Enabled:  true,
+		Interval: time.Hour,
+	}
+}
+
+// withRepositoryPurges enables the processing of repository purges, one tag, manifest or blob link at a time, with an
+// interval short enough for purges to complete quickly.
+func withRepositoryPurges(config *configuration.Configuration) {
+	config.Database.RepositoryPurges = configuration.DatabaseRepositoryPurges{
+		Enabled:   true,
+		Interval:  10 * time.Millisecond,
+		BatchSize: 1,
+	}
+}
+
+// withNamespaceStatistics enables the recording of namespace statistics, with a flush interval short enough for
+// recorded counters to be written quickly.
+func withNamespaceStatistics(config *configuration.Configuration) {
+	config.Database.NamespaceStatistics = configuration.DatabaseNamespaceStatistics{
+		Enabled:       true,
+		FlushInterval: 10 * time.Millisecond,
+		Retention:     24 * time.Hour,
+	}
+}
+
+// withProxy configures the registry as a pull-through cache of the registry at remoteURL, expiring cached tags after ttl
+// with an interval short enough for expired tags to be deleted quickly.
+func withProxy(remoteURL string, ttl time.Duration) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Proxy = configuration.Proxy{
+			RemoteURL: remoteURL,
+			TTL:       ttl,
+			Interval:  10 * time.Millisecond,
+			BatchSize: 100,
+			Timeout:   time.Minute,
+		}
+	}
+}
+
 func withDBDisabled(config *configuration.Configuration) {
 	config.Database.Enabled = false
 }
@@ -154,6 +225,40 @@ func withPayloadSizeLimit(n int) configOpt {
 	}
 }
 
+func withPayloadSizeLimits(rules ...configuration.ManifestPayloadSizeLimitRule) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Validation.Manifests.PayloadSizeLimits = rules
+	}
+}
+
+func withImmutableTags(rules ...configuration.ImmutableTagRule) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Policy.Repository.ImmutableTags = rules
+	}
+}
+
+func withSignatures(rules ...configuration.SignatureRule) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Policy.Repository.Signatures = rules
+	}
+}
+
+func withRemoteMount(registries ...string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.RemoteMount.Enabled = true
+		config.RemoteMount.Registries = registries
+		config.RemoteMount.Insecure = true
+	}
+}
+
+func withAuditLogFile(path string) configOpt {
+	return func(config *configuration.Configuration) {
+		config.Audit.Enabled = true
+		config.Audit.Sink = configuration.AuditSinkFile
+		config.Audit.File.Path = path
+	}
+}
+
 func withRedisCache(srvAddr string) configOpt {
 	return func(config *configuration.Configuration) {
 		config.Redis.Cache.Enabled = true
@@ -161,6 +266,19 @@ func withRedisCache(srvAddr string) configOpt {
 	}
 }
 
+func withTestWebhookNotifications(t *testing.T) configOpt {
+	return withWebhookNotifications(configuration.Notifications{
+		Endpoints: []configuration.Endpoint{
+			{
+				Name:      t.Name(),
+				Timeout:   100 * time.Millisecond,
+				Threshold: 1,
+				Backoff:   100 * time.Millisecond,
+			},
+		},
+	})
+}
+
 func withWebhookNotifications(notifCfg configuration.Notifications) configOpt {
 	return func(config *configuration.Configuration) {
 		config.Notifications = notifCfg
@@ -224,6 +342,8 @@ func newConfig(opts ...configOpt) configuration.Configuration {
 		},
 	}
 	config.HTTP.Headers = headerConfig
+	// allow the registration of the artifact media types used across tests
+	config.Validation.Manifests.ArtifactMediaTypes.Allow = []string{`^application/vnd\.example\.`}
 
 	if os.Getenv("REGISTRY_DATABASE_ENABLED") == "true" {
 		dsn, err := datastoretestutil.NewDSNFromEnv()
@@ -270,6 +390,13 @@ var (
 	preseededSchema1RepoPath = "schema1/preseeded"
 	preseededSchema1TagName  = "schema1preseededtag"
 	preseededSchema1Digest   digest.Digest
+
+	// preseeded schema1 manifest with a layer, which can be converted to schema2
+	preseededConvertibleSchema1RepoPath    = "schema1/convertible"
+	preseededConvertibleSchema1TagName     = "schema1convertibletag"
+	preseededConvertibleSchema1Digest      digest.Digest
+	preseededConvertibleSchema1LayerDigest digest.Digest
+	preseededConvertibleSchema1DiffID      digest.Digest
 )
 
 // schema1PreseededInMemoryDriverFactory implements the factory.StorageDriverFactory interface.
@@ -313,9 +440,66 @@ func (factory *schema1PreseededInMemoryDriverFactory) Create(parameters map[stri
 	d.PutContent(ctx, manifestRevisionLinkPath, []byte(dgst))
 	d.PutContent(ctx, blobDataPath, sm.Canonical)
 
+	if err := preseedConvertibleSchema1Manifest(ctx, d, pk); err != nil {
+		return nil, err
+	}
+
 	return d, nil
 }
 
+// preseedConvertibleSchema1Manifest seeds a schema1 manifest referencing a gzip compressed layer.
+func preseedConvertibleSchema1Manifest(ctx context.Context, d storagedriver.StorageDriver, pk libtrust.PrivateKey) error {
+	uncompressed := []byte("schema1 layer")
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(uncompressed); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	layer := buf.Bytes()
+	layerDigest := digest.FromBytes(layer)
+	preseededConvertibleSchema1LayerDigest = layerDigest
+	preseededConvertibleSchema1DiffID = digest.FromBytes(uncompressed)
+
+	unsignedManifest := &schema1.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name:         preseededConvertibleSchema1RepoPath,
+		Tag:          preseededConvertibleSchema1TagName,
+		Architecture: "amd64",
+		FSLayers:     []schema1.FSLayer{{BlobSum: layerDigest}},
+		History: []schema1.History{{
+			V1Compatibility: `{"id":"3690474eb5b4b26fdfbd89c6e159e8cc376ca76ef48032a30fa6aafd56337880","architecture":"amd64","os":"linux","created":"2015-10-31T22:22:54.690851953Z","container_config":{"Cmd":["/bin/sh -c #(nop) ADD file in /"]}}`,
+		}},
+	}
+
+	sm, err := schema1.Sign(unsignedManifest, pk)
+	if err != nil {
+		return err
+	}
+
+	dgst := digest.FromBytes(sm.Canonical)
+	preseededConvertibleSchema1Digest = dgst
+
+	paths := map[string][]byte{
+		fmt.Sprintf("/docker/registry/v2/repositories/%s/_manifests/tags/%s/current/link", preseededConvertibleSchema1RepoPath, preseededConvertibleSchema1TagName): []byte(dgst),
+		fmt.Sprintf("/docker/registry/v2/repositories/%s/_manifests/revisions/sha256/%s/link", preseededConvertibleSchema1RepoPath, dgst.Hex()):                     []byte(dgst),
+		fmt.Sprintf("/docker/registry/v2/repositories/%s/_layers/sha256/%s/link", preseededConvertibleSchema1RepoPath, layerDigest.Hex()):                           []byte(layerDigest),
+		fmt.Sprintf("/docker/registry/v2/blobs/sha256/%s/%s/data", dgst.Hex()[0:2], dgst.Hex()):                                                                     sm.Canonical,
+		fmt.Sprintf("/docker/registry/v2/blobs/sha256/%s/%s/data", layerDigest.Hex()[0:2], layerDigest.Hex()):                                                       layer,
+	}
+	for path, content := range paths {
+		if err := d.PutContent(ctx, path, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type testEnv struct {
 	pk          libtrust.PrivateKey
 	ctx         context.Context
@@ -468,6 +652,10 @@ type manifestOpts struct {
 	withoutMediaType   bool
 	authToken          string
 	subjectManifest    *ocischema.DeserializedManifest
+	artifactType       string
+	configMediaType    string
+	configPayload      []byte
+	annotations        map[string]string
 	// Non-optional values which be passed through by the testing func for ease of use.
 	repoPath string
 }
@@ -503,6 +691,25 @@ func withSubject(subject *ocischema.DeserializedManifest) manifestOptsFunc {
 	}
 }
 
+func withArtifactType(mediaType string) manifestOptsFunc {
+	return func(t *testing.T, env *testEnv, opts *manifestOpts) {
+		opts.artifactType = mediaType
+	}
+}
+
+func withConfig(mediaType string, payload []byte) manifestOptsFunc {
+	return func(t *testing.T, env *testEnv, opts *manifestOpts) {
+		opts.configMediaType = mediaType
+		opts.configPayload = payload
+	}
+}
+
+func withAnnotations(annotations map[string]string) manifestOptsFunc {
+	return func(t *testing.T, env *testEnv, opts *manifestOpts) {
+		opts.annotations = annotations
+	}
+}
+
 func withAuthToken(token string) manifestOptsFunc {
 	return func(t *testing.T, env *testEnv, opts *manifestOpts) {
 		opts.authToken = token
@@ -716,6 +923,8 @@ func seedRandomOCIManifest(t *testing.T, env *testEnv, repoPath string, opts ...
 			SchemaVersion: 2,
 			MediaType:     v1.MediaTypeImageManifest,
 		},
+		ArtifactType: config.artifactType,
+		Annotations:  config.annotations,
 	}
 
 	// Use the config from the subject manifest, if present;
@@ -724,6 +933,14 @@ func seedRandomOCIManifest(t *testing.T, env *testEnv, repoPath string, opts ...
 		manifest.Config = config.subjectManifest.Config()
 	} else {
 		cfgPayload, cfgDesc := ociConfig()
+		if config.configMediaType != "" {
+			cfgPayload = config.configPayload
+			cfgDesc = distribution.Descriptor{
+				MediaType: config.configMediaType,
+				Digest:    digest.FromBytes(cfgPayload),
+				Size:      int64(len(cfgPayload)),
+			}
+		}
 		uploadURLBase, _ := startPushLayer(t, env, repoRef)
 		pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
 		manifest.Config = cfgDesc
@@ -922,6 +1139,16 @@ func buildEventManifestDeleteByTag(mediaType, repoPath, tag string, opts ...even
 	return buildEventManifestDelete(mediaType, repoPath, tag, "", opts...)
 }
 
+func buildEventTagsDelete(repoPath string, tags []string) notifications.Event {
+	return notifications.Event{
+		Action: "delete",
+		Target: notifications.Target{
+			Repository: repoPath,
+			Tags:       tags,
+		},
+	}
+}
+
 type eventOpt func(event *notifications.Event)
 
 func buildEventManifestDelete(mediaType, repoPath, tagName string, dgst digest.Digest, opts ...eventOpt) notifications.Event {
@@ -1358,6 +1585,62 @@ func checkErr(t *testing.T, err error, msg string) {
 	}
 }
 
+// newCosignKey generates a key to sign images with cosign and writes its public key to a temporary file. Returns the key
+// and the path of the public key file.
+func newCosignKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	require.NoError(t, err)
+
+	return key, path
+}
+
+// seedCosignSignature pushes a cosign signature of the manifest with the given digest, signed with key, under the tag
+// used by cosign for registries without support for referrers.
+func seedCosignSignature(t *testing.T, env *testEnv, repoPath string, key *ecdsa.PrivateKey, dgst digest.Digest) {
+	t.Helper()
+
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, repoPath, dgst))
+	h := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(cryptorand.Reader, key, h[:])
+	require.NoError(t, err)
+
+	cfgPayload, cfgDesc := ociConfig()
+	uploadURLBase, _ := startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, cfgDesc.Digest, uploadURLBase, bytes.NewReader(cfgPayload))
+
+	payloadDgst := digest.FromBytes(payload)
+	uploadURLBase, _ = startPushLayer(t, env, repoRef)
+	pushLayer(t, env.builder, repoRef, payloadDgst, uploadURLBase, bytes.NewReader(payload))
+
+	m, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: ocischema.SchemaVersion,
+		Config:    cfgDesc,
+		Layers: []distribution.Descriptor{{
+			MediaType:   "application/vnd.dev.cosign.simplesigning.v1+json",
+			Digest:      payloadDgst,
+			Size:        int64(len(payload)),
+			Annotations: map[string]string{"dev.cosignproject.cosign/signature": base64.StdEncoding.EncodeToString(sig)},
+		}},
+	})
+	require.NoError(t, err)
+
+	tag := fmt.Sprintf("%s-%s.sig", dgst.Algorithm(), dgst.Hex())
+	resp := putManifest(t, "putting cosign signature", buildManifestTagURL(t, env, repoPath, tag), v1.MediaTypeImageManifest, m.Manifest)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
 func createRepository(t *testing.T, env *testEnv, repoPath string, tag string) digest.Digest {
 	deserializedManifest := seedRandomSchema2Manifest(t, env, repoPath, putByTag(tag))
 
@@ -1848,6 +2131,18 @@ func deleteAccessTokenWithProjectMeta(projectPath, repositoryName string) []*tok
 	}
 }
 
+// protectedTagsAccessToken grants full access to a repository, except for the tags matching the given patterns.
+func protectedTagsAccessToken(repositoryName string, patterns ...string) []*token.ResourceActions {
+	return []*token.ResourceActions{
+		{
+			Type:    "repository",
+			Name:    repositoryName,
+			Actions: []string{"pull", "push", "delete"},
+			Meta:    &token.Meta{ProtectedTagPatterns: patterns},
+		},
+	}
+}
+
 // requireRenameTTLInRange makes sure that the rename operation TTL is within an acceptable range of an expected duration
 func requireRenameTTLInRange(t *testing.T, actualTTL time.Time, expectedTTLDuration time.Duration) {
 	t.Helper()
@@ -1901,6 +2196,20 @@ func witContentRangeHeader(contentRange string) requestOpt {
 	}
 }
 
+func withDigestQueryParam(dgst digest.Digest) requestOpt {
+	return func(r *http.Request) {
+		q := r.URL.Query()
+		q.Set("digest", dgst.String())
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+func withIfMatchHeader(value string) requestOpt {
+	return func(r *http.Request) {
+		r.Header.Set("If-Match", value)
+	}
+}
+
 func newRequest(request *http.Request, opts ...requestOpt) *http.Request {
 	for _, o := range opts {
 		o(request)
@@ -0,0 +1,630 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/manifest/manifestlist"
+	mlcompat "github.com/docker/distribution/manifest/manifestlist/compat"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// layoutImportURLQueryParamKey is the query parameter that holds the URL to download the archive from, instead of
+	// reading it from the request body.
+	layoutImportURLQueryParamKey = "url"
+	// maxLayoutFileSize is the maximum size of the oci-layout file of an archive.
+	maxLayoutFileSize = 4 << 10
+
+	defaultLayoutImportTimeout = 30 * time.Minute
+)
+
+var errLayoutArchiveTooLarge = errors.New("archive exceeds the maximum size")
+
+// layoutImportFetcher downloads OCI image layout archives from allowed hosts.
+type layoutImportFetcher struct {
+	client *http.Client
+	hosts  map[string]struct{}
+}
+
+func newLayoutImportFetcher(config configuration.UploadsImport) (*layoutImportFetcher, error) {
+	hosts := make(map[string]struct{}, len(config.Hosts))
+	for i, h := range config.Hosts {
+		if h == "" || strings.Contains(h, "/") {
+			return nil, fmt.Errorf("hosts[%d]: invalid host %q, must be in the form host[:port]", i, h)
+		}
+		hosts[strings.ToLower(h)] = struct{}{}
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultLayoutImportTimeout
+	}
+
+	return &layoutImportFetcher{
+		client: &http.Client{Timeout: timeout},
+		hosts:  hosts,
+	}, nil
+}
+
+// validate checks that the archive URL uses HTTPS and targets one of the allowed hosts.
+func (f *layoutImportFetcher) validate(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing archive URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported archive URL scheme %q", u.Scheme)
+	}
+	if _, ok := f.hosts[strings.ToLower(u.Host)]; !ok {
+		return nil, fmt.Errorf("archive host %q not allowed", u.Host)
+	}
+
+	return u, nil
+}
+
+// fetch issues a GET request for the archive at the given URL. The caller is responsible for closing the body.
+func (f *layoutImportFetcher) fetch(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading archive: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading archive: unexpected status code %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// sizeLimitedReader reads from r, returning errLayoutArchiveTooLarge if more than the given number of bytes are read.
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// there is no way to tell whether the limit was exceeded other than reading past it
+		if n, err := l.r.Read(make([]byte, 1)); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, errLayoutArchiveTooLarge
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+
+	return n, err
+}
+
+// layoutArchive is the content of an OCI image layout archive. JSON blobs, which may be manifests or configurations,
+// are held in memory until the archive is fully read, as only then it is known which blobs are manifests. All other
+// blobs are written to storage while reading the archive.
+type layoutArchive struct {
+	layout    []byte
+	index     []byte
+	documents map[digest.Digest][]byte
+	blobs     map[digest.Digest]int64
+}
+
+// readLayoutArchive reads an OCI image layout archive from r, verifying the digest of all blobs. Blobs that are not
+// JSON documents are written with store, which must verify their digest.
+func readLayoutArchive(r io.Reader, store func(dgst digest.Digest, r io.Reader) (int64, error)) (*layoutArchive, error) {
+	archive := &layoutArchive{
+		documents: make(map[digest.Digest][]byte),
+		blobs:     make(map[digest.Digest]int64),
+	}
+	invalid := func(format string, args ...any) error {
+		return v1.ErrorCodeImageLayoutInvalid.WithDetail(fmt.Sprintf(format, args...))
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, errLayoutArchiveTooLarge) {
+				return nil, err
+			}
+			return nil, invalid("reading archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		switch {
+		case name == ocispec.ImageLayoutFile:
+			if archive.layout, err = readLayoutFile(tr, hdr, maxLayoutFileSize); err != nil {
+				return nil, err
+			}
+		case name == "index.json":
+			if archive.index, err = readLayoutFile(tr, hdr, maxManifestBodySize); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, "blobs/"):
+			parts := strings.Split(name, "/")
+			if len(parts) != 3 {
+				return nil, invalid("unexpected blob path %q", hdr.Name)
+			}
+			dgst := digest.NewDigestFromEncoded(digest.Algorithm(parts[1]), parts[2])
+			if err := dgst.Validate(); err != nil {
+				return nil, invalid("invalid blob path %q: %v", hdr.Name, err)
+			}
+
+			// manifests and configurations are JSON documents, which are small enough to be held in memory
+			br := bufio.NewReader(tr)
+			if first, err := br.Peek(1); err == nil && first[0] == '{' && hdr.Size <= maxManifestBodySize {
+				payload, err := io.ReadAll(br)
+				if err != nil {
+					return nil, invalid("reading blob %s: %v", dgst, err)
+				}
+				if dgst.Algorithm().FromBytes(payload) != dgst {
+					return nil, invalid("digest of blob %s does not match its content", dgst)
+				}
+				archive.documents[dgst] = payload
+				continue
+			}
+
+			size, err := store(dgst, br)
+			if err != nil {
+				return nil, err
+			}
+			archive.blobs[dgst] = size
+		}
+	}
+
+	if archive.layout == nil {
+		return nil, invalid("%s file not found", ocispec.ImageLayoutFile)
+	}
+	var layout ocispec.ImageLayout
+	if err := json.Unmarshal(archive.layout, &layout); err != nil || !strings.HasPrefix(layout.Version, "1.") {
+		return nil, invalid("unsupported image layout version")
+	}
+	if archive.index == nil {
+		return nil, invalid("index.json file not found")
+	}
+
+	return archive, nil
+}
+
+// readLayoutFile reads the content of a layout file from tr, up to the given size.
+func readLayoutFile(tr *tar.Reader, hdr *tar.Header, maxSize int64) ([]byte, error) {
+	if hdr.Size > maxSize {
+		return nil, v1.ErrorCodeImageLayoutInvalid.WithDetail(fmt.Sprintf("%s file exceeds the maximum size of %d bytes", hdr.Name, maxSize))
+	}
+	b, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, v1.ErrorCodeImageLayoutInvalid.WithDetail(fmt.Sprintf("reading %s file: %v", hdr.Name, err))
+	}
+
+	return b, nil
+}
+
+// layoutImportManifest is a manifest to import.
+type layoutImportManifest struct {
+	desc     distribution.Descriptor
+	manifest distribution.Manifest
+	// tags are the tags to point to the manifest, as set in the layout index.
+	tags []string
+}
+
+// layoutImportPlan is the list of manifests of an archive to import, in the order they must be imported, i.e. each
+// manifest after those it references, along with the media type of the blobs they reference.
+type layoutImportPlan struct {
+	manifests []*layoutImportManifest
+	// roots are the manifests referenced by the layout index.
+	roots []*layoutImportManifest
+	// blobMediaTypes are the media types of the blobs referenced by the manifests.
+	blobMediaTypes map[digest.Digest]string
+}
+
+// planLayoutImport determines which manifests of the archive to import, starting from those in the layout index,
+// whose `org.opencontainers.image.ref.name` annotation, if any, is the tag to point to them. Manifests must be in the
+// archive, while the blobs they reference may also already exist in the target repository.
+func planLayoutImport(archive *layoutArchive, repo reference.Named) (*layoutImportPlan, error) {
+	invalid := func(format string, args ...any) error {
+		return v1.ErrorCodeImageLayoutInvalid.WithDetail(fmt.Sprintf(format, args...))
+	}
+
+	var index ocispec.Index
+	if err := json.Unmarshal(archive.index, &index); err != nil {
+		return nil, invalid("parsing index.json: %v", err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, invalid("index.json references no manifests")
+	}
+
+	plan := &layoutImportPlan{blobMediaTypes: make(map[digest.Digest]string)}
+	planned := make(map[digest.Digest]*layoutImportManifest)
+
+	var addManifest func(desc distribution.Descriptor) (*layoutImportManifest, error)
+	addManifest = func(desc distribution.Descriptor) (*layoutImportManifest, error) {
+		if m, ok := planned[desc.Digest]; ok {
+			return m, nil
+		}
+		payload, ok := archive.documents[desc.Digest]
+		if !ok {
+			return nil, invalid("manifest %s not found in archive", desc.Digest)
+		}
+		mfst, _, err := distribution.UnmarshalManifest(desc.MediaType, payload)
+		if err != nil {
+			return nil, invalid("parsing manifest %s: %v", desc.Digest, err)
+		}
+
+		switch mfst := mfst.(type) {
+		case *manifestlist.DeserializedManifestList:
+			refs := mlcompat.References(mfst)
+			for _, ref := range refs.Manifests {
+				if _, err := addManifest(ref); err != nil {
+					return nil, err
+				}
+			}
+			// indexes such as buildx cache manifests may reference blobs directly
+			for _, ref := range refs.Blobs {
+				plan.blobMediaTypes[ref.Digest] = ref.MediaType
+			}
+		case distribution.ManifestV2:
+			plan.blobMediaTypes[mfst.Config().Digest] = mfst.Config().MediaType
+			for _, l := range mfst.Layers() {
+				if len(l.URLs) == 0 {
+					plan.blobMediaTypes[l.Digest] = l.MediaType
+				}
+			}
+		default:
+			return nil, invalid("manifest %s has unsupported media type %q", desc.Digest, desc.MediaType)
+		}
+
+		m := &layoutImportManifest{desc: desc, manifest: mfst}
+		planned[desc.Digest] = m
+		plan.manifests = append(plan.manifests, m)
+
+		return m, nil
+	}
+
+	for _, desc := range index.Manifests {
+		m, err := addManifest(distribution.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size})
+		if err != nil {
+			return nil, err
+		}
+		if tag := desc.Annotations[ocispec.AnnotationRefName]; tag != "" {
+			if _, err := reference.WithTag(repo, tag); err != nil {
+				return nil, invalid("invalid tag %q for manifest %s", tag, desc.Digest)
+			}
+			m.tags = append(m.tags, tag)
+		}
+		plan.roots = append(plan.roots, m)
+	}
+
+	return plan, nil
+}
+
+// dbLinkImportedBlobs creates the given blobs and links them to the repository with the given path, all at once.
+func dbLinkImportedBlobs(ctx context.Context, db *datastore.DB, repoPath string, descs []distribution.Descriptor, repoStoreOpts []datastore.RepositoryStoreOption) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rStore := datastore.NewRepositoryStore(tx, repoStoreOpts...)
+	r, err := rStore.CreateOrFindByPath(ctx, repoPath)
+	if err != nil {
+		return err
+	}
+
+	bs := datastore.NewBlobStore(tx)
+	for _, desc := range descs {
+		b := &models.Blob{
+			MediaType: desc.MediaType,
+			Digest:    desc.Digest,
+			Size:      desc.Size,
+		}
+		if err := bs.CreateOrFind(ctx, b); err != nil {
+			return err
+		}
+		if err := rStore.LinkBlob(ctx, r, b.Digest); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing database transaction: %w", err)
+	}
+
+	return nil
+}
+
+type repositoryLayoutImportHandler struct {
+	*Context
+}
+
+func repositoryLayoutImportDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryLayoutImportHandler := &repositoryLayoutImportHandler{
+		Context: ctx,
+	}
+
+	// the GitLab API is only available with the database, but the dispatcher does not flag it for the read-only and
+	// ongoing rename checks, nor for the manifest writer used to push imported manifests
+	ctx.useDatabase = true
+
+	ihandler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		ihandler[http.MethodPost] = http.HandlerFunc(repositoryLayoutImportHandler.ImportLayout)
+	}
+
+	return checkOngoingRename(checkReadOnlyRepository(ihandler, ctx), ctx)
+}
+
+// LayoutImportAPIResponse is the response body of the repository OCI image layout import endpoint.
+type LayoutImportAPIResponse struct {
+	Manifests []ImportedManifestAPIResponse `json:"manifests"`
+	BlobCount int                           `json:"blob_count"`
+}
+
+// ImportedManifestAPIResponse is a manifest referenced by the index of an imported OCI image layout.
+type ImportedManifestAPIResponse struct {
+	Digest    string   `json:"digest"`
+	MediaType string   `json:"media_type"`
+	Tags      []string `json:"tags"`
+}
+
+// layoutImportBody returns the archive to import, either read from the request body or downloaded from the URL set
+// in the `url` query parameter. The caller is responsible for closing it.
+func (h *repositoryLayoutImportHandler) layoutImportBody(r *http.Request) (io.ReadCloser, error) {
+	rawURL := r.URL.Query().Get(layoutImportURLQueryParamKey)
+	if rawURL == "" {
+		return r.Body, nil
+	}
+	if h.App.layoutImportFetcher == nil {
+		return nil, errcode.ErrorCodeUnsupported.WithDetail("importing archives from URLs is disabled")
+	}
+	u, err := h.App.layoutImportFetcher.validate(rawURL)
+	if err != nil {
+		return nil, errcode.ErrorCodeDenied.WithDetail(err.Error())
+	}
+	body, err := h.App.layoutImportFetcher.fetch(h, u)
+	if err != nil {
+		return nil, v1.ErrorCodeImageLayoutInvalid.WithDetail(err.Error())
+	}
+
+	return body, nil
+}
+
+// storeBlob writes a blob of an imported archive to storage, verifying its digest.
+func (h *repositoryLayoutImportHandler) storeBlob(dgst digest.Digest, r io.Reader) (int64, error) {
+	upload, err := h.Repository.Blobs(h).Create(h)
+	if err != nil {
+		return 0, err
+	}
+	defer upload.Close()
+
+	if _, err := upload.ReadFrom(r); err != nil {
+		if err := upload.Cancel(h); err != nil {
+			log.GetLogger(log.WithContext(h)).WithError(err).Error("error canceling upload after error")
+		}
+		if errors.Is(err, errLayoutArchiveTooLarge) {
+			return 0, err
+		}
+		return 0, v1.ErrorCodeImageLayoutInvalid.WithDetail(fmt.Sprintf("reading blob %s: %v", dgst, err))
+	}
+
+	desc, err := upload.Commit(h, distribution.Descriptor{Digest: dgst})
+	if err != nil {
+		if err := upload.Cancel(h); err != nil {
+			log.GetLogger(log.WithContext(h)).WithError(err).Error("error canceling upload after error")
+		}
+		if errors.As(err, &distribution.ErrBlobInvalidDigest{}) {
+			return 0, v1.ErrorCodeImageLayoutInvalid.WithDetail(fmt.Sprintf("digest of blob %s does not match its content", dgst))
+		}
+		return 0, err
+	}
+
+	return desc.Size, nil
+}
+
+// ImportLayout imports the images of an OCI image layout archive into the repository. The blobs of the archive are
+// written to storage as the archive is read, verifying their digest. Once the archive is fully read, the blobs are
+// linked to the repository, then the manifests are pushed and validated as if pushed through the `/v2/` API, each
+// after those it references, and finally the tags are created. Tags are therefore only created if all manifests were
+// imported, while the blobs and manifests imported before a failure are left for the online garbage collector.
+func (h *repositoryLayoutImportHandler) ImportLayout(w http.ResponseWriter, r *http.Request) {
+	l := log.GetLogger(log.WithContext(h))
+
+	body, err := h.layoutImportBody(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+	defer body.Close()
+
+	var archiveReader io.Reader = body
+	maxSize := h.App.Config.Uploads.Import.MaxSize
+	if maxSize > 0 {
+		archiveReader = &sizeLimitedReader{r: body, remaining: maxSize}
+	}
+
+	archive, err := readLayoutArchive(archiveReader, h.storeBlob)
+	if err != nil {
+		switch {
+		case errors.Is(err, errLayoutArchiveTooLarge):
+			h.Errors = append(h.Errors, v2.ErrorCodeSizeInvalid.WithDetail(fmt.Sprintf("the archive exceeds the maximum size of %d bytes", maxSize)))
+		default:
+			h.appendImportError(err)
+		}
+		return
+	}
+
+	plan, err := planLayoutImport(archive, h.Repository.Named())
+	if err != nil {
+		h.appendImportError(err)
+		return
+	}
+	protection := newTagProtection(h, h.Repository.Named().Name())
+	for _, m := range plan.roots {
+		if err := protection.check(m.tags...); err != nil {
+			h.Errors = append(h.Errors, err)
+			return
+		}
+	}
+
+	// configurations are also JSON documents, so these were held in memory and must now be written to storage
+	blobs := make([]distribution.Descriptor, 0, len(archive.blobs))
+	for dgst, mediaType := range plan.blobMediaTypes {
+		payload, ok := archive.documents[dgst]
+		if !ok {
+			continue
+		}
+		desc, err := h.Repository.Blobs(h).Put(h, mediaType, payload)
+		if err != nil {
+			h.appendImportError(err)
+			return
+		}
+		blobs = append(blobs, desc)
+	}
+	for dgst, size := range archive.blobs {
+		mediaType, ok := plan.blobMediaTypes[dgst]
+		if !ok {
+			mediaType = "application/octet-stream"
+		}
+		blobs = append(blobs, distribution.Descriptor{MediaType: mediaType, Digest: dgst, Size: size})
+	}
+
+	var opts []datastore.RepositoryStoreOption
+	if h.App.redisCache != nil {
+		opts = append(opts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(h.App.redisCache)))
+	}
+	if err := dbLinkImportedBlobs(h, h.db, h.Repository.Named().Name(), blobs, opts); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("failed to create blobs in database: %w", err)))
+		return
+	}
+	for _, desc := range blobs {
+		h.recordAudit(audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactBlob, Digest: desc.Digest, MediaType: desc.MediaType})
+	}
+
+	// manifests are pushed with the same validations as through the /v2/ API
+	bp, ok := h.App.registry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("unable to convert BlobEnumerator into BlobProvider")))
+		return
+	}
+	h.blobProvider = bp
+	imh := &manifestHandler{Context: h.Context}
+	manifestWriter, err := imh.newManifestWriter()
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	for _, m := range plan.manifests {
+		imh.Digest, imh.Tag = m.desc.Digest, ""
+		if err := imh.applyResourcePolicy(m.manifest); err != nil {
+			h.Errors = append(h.Errors, err)
+			return
+		}
+		if err := manifestWriter.Put(imh, m.manifest); err != nil {
+			imh.appendPutError(err)
+			return
+		}
+		h.recordAudit(audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactManifest, Digest: m.desc.Digest, MediaType: m.desc.MediaType})
+	}
+
+	for _, m := range plan.roots {
+		for _, tag := range m.tags {
+			imh.Digest, imh.Tag = m.desc.Digest, tag
+			if err := manifestWriter.Tag(imh, m.manifest, tag, m.desc); err != nil {
+				imh.appendPutError(err)
+				return
+			}
+			h.recordAudit(audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactTag, Tag: tag, Digest: m.desc.Digest})
+		}
+	}
+
+	resp := LayoutImportAPIResponse{
+		Manifests: make([]ImportedManifestAPIResponse, 0, len(plan.roots)),
+		BlobCount: len(blobs),
+	}
+	for _, m := range plan.manifests {
+		if len(m.tags) == 0 {
+			if err := h.queueBridge.ManifestPushed(h.Repository.Named(), m.manifest); err != nil {
+				l.WithError(err).Error("dispatching manifest push to listener")
+			}
+		}
+		for _, tag := range m.tags {
+			if err := h.queueBridge.ManifestPushed(h.Repository.Named(), m.manifest, distribution.WithTagOption{Tag: tag}); err != nil {
+				l.WithError(err).Error("dispatching manifest push to listener")
+			}
+		}
+	}
+	seen := make(map[digest.Digest]struct{}, len(plan.roots))
+	for _, m := range plan.roots {
+		if _, ok := seen[m.desc.Digest]; ok {
+			continue
+		}
+		seen[m.desc.Digest] = struct{}{}
+
+		tags := m.tags
+		if tags == nil {
+			tags = make([]string, 0)
+		}
+		resp.Manifests = append(resp.Manifests, ImportedManifestAPIResponse{
+			Digest:    m.desc.Digest.String(),
+			MediaType: m.desc.MediaType,
+			Tags:      tags,
+		})
+	}
+	h.recordPush()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		l.WithError(err).Error("failed to encode layout import response")
+		return
+	}
+
+	l.WithFields(log.Fields{
+		"manifest_count": len(plan.manifests),
+		"blob_count":     len(blobs),
+	}).Info("image layout imported")
+}
+
+func (h *repositoryLayoutImportHandler) appendImportError(err error) {
+	switch err := err.(type) {
+	case errcode.Error:
+		h.Errors = append(h.Errors, err)
+	default:
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+type layoutArchiveFile struct {
+	name    string
+	content []byte
+}
+
+func buildLayoutArchive(t *testing.T, files ...layoutArchiveFile) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Size: int64(len(f.content)), Mode: 0o644}))
+		_, err := tw.Write(f.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	return buf.Bytes()
+}
+
+func layoutBlobFile(content []byte) layoutArchiveFile {
+	return layoutArchiveFile{name: "blobs/sha256/" + digest.FromBytes(content).Encoded(), content: content}
+}
+
+func requireImageLayoutInvalid(t *testing.T, err error, detail string) {
+	t.Helper()
+
+	var errc errcode.Error
+	require.True(t, errors.As(err, &errc), "unexpected error type %T", err)
+	require.Equal(t, v1.ErrorCodeImageLayoutInvalid, errc.Code)
+	require.Contains(t, errc.Detail, detail)
+}
+
+func TestReadLayoutArchive(t *testing.T) {
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layer := []byte("layer")
+	layerDgst := digest.FromBytes(layer)
+
+	data := buildLayoutArchive(t,
+		layoutArchiveFile{name: "./oci-layout", content: []byte(`{"imageLayoutVersion":"1.0.0"}`)},
+		layoutArchiveFile{name: "index.json", content: []byte(`{"schemaVersion":2,"manifests":[]}`)},
+		layoutBlobFile(config),
+		layoutBlobFile(layer),
+	)
+
+	stored := make(map[digest.Digest][]byte)
+	store := func(dgst digest.Digest, r io.Reader) (int64, error) {
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		stored[dgst] = b
+		return int64(len(b)), nil
+	}
+
+	archive, err := readLayoutArchive(bytes.NewReader(data), store)
+	require.NoError(t, err)
+	require.Equal(t, map[digest.Digest][]byte{digest.FromBytes(config): config}, archive.documents)
+	require.Equal(t, map[digest.Digest]int64{layerDgst: int64(len(layer))}, archive.blobs)
+	require.Equal(t, map[digest.Digest][]byte{layerDgst: layer}, stored)
+}
+
+func TestReadLayoutArchive_Invalid(t *testing.T) {
+	layout := layoutArchiveFile{name: "oci-layout", content: []byte(`{"imageLayoutVersion":"1.0.0"}`)}
+	index := layoutArchiveFile{name: "index.json", content: []byte(`{"schemaVersion":2,"manifests":[]}`)}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		detail string
+	}{
+		{
+			name:   "not a tar archive",
+			data:   []byte(strings.Repeat("x", 1024)),
+			detail: "reading archive",
+		},
+		{
+			name:   "missing oci-layout",
+			data:   buildLayoutArchive(t, index),
+			detail: "oci-layout file not found",
+		},
+		{
+			name:   "unsupported version",
+			data:   buildLayoutArchive(t, layoutArchiveFile{name: "oci-layout", content: []byte(`{"imageLayoutVersion":"2.0.0"}`)}, index),
+			detail: "unsupported image layout version",
+		},
+		{
+			name:   "missing index",
+			data:   buildLayoutArchive(t, layout),
+			detail: "index.json file not found",
+		},
+		{
+			name:   "nested blob path",
+			data:   buildLayoutArchive(t, layout, index, layoutArchiveFile{name: "blobs/sha256/foo/bar", content: []byte("{}")}),
+			detail: "unexpected blob path",
+		},
+		{
+			name:   "invalid blob digest",
+			data:   buildLayoutArchive(t, layout, index, layoutArchiveFile{name: "blobs/sha256/foo", content: []byte("{}")}),
+			detail: "invalid blob path",
+		},
+		{
+			name: "document digest mismatch",
+			data: buildLayoutArchive(t, layout, index, layoutArchiveFile{
+				name:    "blobs/sha256/" + digest.FromString("other").Encoded(),
+				content: []byte("{}"),
+			}),
+			detail: "does not match its content",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := readLayoutArchive(bytes.NewReader(test.data), func(digest.Digest, io.Reader) (int64, error) {
+				return 0, nil
+			})
+			requireImageLayoutInvalid(t, err, test.detail)
+		})
+	}
+}
+
+func TestReadLayoutArchive_TooLarge(t *testing.T) {
+	data := buildLayoutArchive(t,
+		layoutArchiveFile{name: "oci-layout", content: []byte(`{"imageLayoutVersion":"1.0.0"}`)},
+		layoutArchiveFile{name: "index.json", content: []byte(`{"schemaVersion":2,"manifests":[]}`)},
+	)
+
+	_, err := readLayoutArchive(&sizeLimitedReader{r: bytes.NewReader(data), remaining: int64(len(data))}, nil)
+	require.NoError(t, err)
+
+	_, err = readLayoutArchive(&sizeLimitedReader{r: bytes.NewReader(data), remaining: 1024}, nil)
+	require.ErrorIs(t, err, errLayoutArchiveTooLarge)
+}
+
+func TestPlanLayoutImport(t *testing.T) {
+	repo, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	configDgst := digest.FromBytes(config)
+	layerDgst := digest.FromString("layer")
+
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: configDgst, Size: int64(len(config))},
+		Layers:    []ocispec.Descriptor{{MediaType: ocispec.MediaTypeImageLayer, Digest: layerDgst, Size: 5}},
+	})
+	require.NoError(t, err)
+	manifestDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(manifest), Size: int64(len(manifest))}
+
+	imageIndex, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{manifestDesc},
+	})
+	require.NoError(t, err)
+	indexDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.FromBytes(imageIndex), Size: int64(len(imageIndex))}
+
+	layoutIndex := func(descs ...ocispec.Descriptor) []byte {
+		b, err := json.Marshal(ocispec.Index{Versioned: specs.Versioned{SchemaVersion: 2}, Manifests: descs})
+		require.NoError(t, err)
+		return b
+	}
+	withTag := func(desc ocispec.Descriptor, tag string) ocispec.Descriptor {
+		desc.Annotations = map[string]string{ocispec.AnnotationRefName: tag}
+		return desc
+	}
+	documents := map[digest.Digest][]byte{
+		configDgst:          config,
+		manifestDesc.Digest: manifest,
+		indexDesc.Digest:    imageIndex,
+	}
+
+	archive := &layoutArchive{
+		index:     layoutIndex(withTag(indexDesc, "multi"), withTag(manifestDesc, "latest"), manifestDesc),
+		documents: documents,
+	}
+	plan, err := planLayoutImport(archive, repo)
+	require.NoError(t, err)
+
+	// referenced manifests come first, and are only planned once
+	require.Len(t, plan.manifests, 2)
+	require.Equal(t, manifestDesc.Digest, plan.manifests[0].desc.Digest)
+	require.Equal(t, indexDesc.Digest, plan.manifests[1].desc.Digest)
+	require.Equal(t, []string{"latest"}, plan.manifests[0].tags)
+	require.Equal(t, []string{"multi"}, plan.manifests[1].tags)
+	require.Len(t, plan.roots, 3)
+	require.Equal(t, map[digest.Digest]string{
+		configDgst: ocispec.MediaTypeImageConfig,
+		layerDgst:  ocispec.MediaTypeImageLayer,
+	}, plan.blobMediaTypes)
+
+	t.Run("missing manifest", func(t *testing.T) {
+		archive := &layoutArchive{
+			index:     layoutIndex(indexDesc),
+			documents: map[digest.Digest][]byte{indexDesc.Digest: imageIndex},
+		}
+		_, err := planLayoutImport(archive, repo)
+		requireImageLayoutInvalid(t, err, "manifest "+manifestDesc.Digest.String()+" not found in archive")
+	})
+
+	t.Run("invalid tag", func(t *testing.T) {
+		archive := &layoutArchive{index: layoutIndex(withTag(manifestDesc, "-invalid")), documents: documents}
+		_, err := planLayoutImport(archive, repo)
+		requireImageLayoutInvalid(t, err, `invalid tag "-invalid"`)
+	})
+
+	t.Run("empty index", func(t *testing.T) {
+		archive := &layoutArchive{index: layoutIndex(), documents: documents}
+		_, err := planLayoutImport(archive, repo)
+		requireImageLayoutInvalid(t, err, "index.json references no manifests")
+	})
+}
+
+func TestLayoutImportFetcher_Validate(t *testing.T) {
+	_, err := newLayoutImportFetcher(configuration.UploadsImport{Hosts: []string{"example.com/path"}})
+	require.EqualError(t, err, `hosts[0]: invalid host "example.com/path", must be in the form host[:port]`)
+
+	f, err := newLayoutImportFetcher(configuration.UploadsImport{Hosts: []string{"artifacts.example.com", "Example.com:8443"}})
+	require.NoError(t, err)
+	require.Equal(t, defaultLayoutImportTimeout, f.client.Timeout)
+
+	for _, rawURL := range []string{
+		"https://artifacts.example.com/image.tar",
+		"https://example.com:8443/image.tar",
+	} {
+		_, err := f.validate(rawURL)
+		require.NoError(t, err, rawURL)
+	}
+
+	for _, rawURL := range []string{
+		"http://artifacts.example.com/image.tar",
+		"https://example.com/image.tar",
+		"https://other.example.com/image.tar",
+		"file:///etc/passwd",
+	} {
+		_, err := f.validate(rawURL)
+		require.Error(t, err, rawURL)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/gorilla/mux"
+)
+
+// maintenanceScheduler keeps track of the maintenance windows during which write requests are rejected. The zero value
+// is ready to use.
+type maintenanceScheduler struct {
+	mu      sync.RWMutex
+	windows []configuration.MaintenanceWindow
+}
+
+// validateMaintenanceWindows checks that every window has a start and end time, with the end after the start.
+func validateMaintenanceWindows(windows []configuration.MaintenanceWindow) error {
+	for i, w := range windows {
+		if w.Start.IsZero() || w.End.IsZero() {
+			return fmt.Errorf("windows[%d]: start and end must be set", i)
+		}
+		if !w.End.After(w.Start) {
+			return fmt.Errorf("windows[%d]: end (%s) must be after start (%s)", i, w.End.Format(time.RFC3339), w.Start.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// set replaces the maintenance windows, which must be valid.
+func (m *maintenanceScheduler) set(windows []configuration.MaintenanceWindow) {
+	ww := make([]configuration.MaintenanceWindow, len(windows))
+	copy(ww, windows)
+	sort.Slice(ww, func(i, j int) bool { return ww[i].Start.Before(ww[j].Start) })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.windows = ww
+}
+
+// list returns the maintenance windows, sorted by start time.
+func (m *maintenanceScheduler) list() []configuration.MaintenanceWindow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ww := make([]configuration.MaintenanceWindow, len(m.windows))
+	copy(ww, m.windows)
+	return ww
+}
+
+// active returns the maintenance window in progress at the given time, if any. If more than one window is in
+// progress, the one that ends last is returned.
+func (m *maintenanceScheduler) active(now time.Time) (configuration.MaintenanceWindow, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var active configuration.MaintenanceWindow
+	var found bool
+	for _, w := range m.windows {
+		if w.Start.After(now) {
+			// windows are sorted by start time, so none of the remaining ones has started yet
+			break
+		}
+		if now.Before(w.End) && (!found || w.End.After(active.End)) {
+			active, found = w, true
+		}
+	}
+
+	return active, found
+}
+
+// isWriteRequest returns whether r is a write request, i.e. a request that may change the registry contents.
+func isWriteRequest(r *http.Request) bool {
+	// cleanup policy previews are POST requests which only read the repository tags
+	if route := mux.CurrentRoute(r); route != nil && route.GetName() == v1.RepositoryCleanupPolicyPreview.Name {
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// underMaintenance checks whether r is a write request made during a maintenance window, in which case it responds
+// with a 503 Service Unavailable error and a Retry-After header set to the number of seconds until the window ends.
+func (app *App) underMaintenance(w http.ResponseWriter, r *http.Request) bool {
+	if !isWriteRequest(r) {
+		return false
+	}
+	now := time.Now()
+	mw, ok := app.maintenance.active(now)
+	if !ok {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(mw.End.Sub(now).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	detail := fmt.Sprintf("registry is under maintenance until %s", mw.End.UTC().Format(time.RFC3339))
+	if mw.Reason != "" {
+		detail = fmt.Sprintf("%s: %s", detail, mw.Reason)
+	}
+	if err := errcode.ServeJSON(w, errcode.ErrorCodeUnavailable.WithDetail(detail)); err != nil {
+		dcontext.GetLogger(r.Context()).Errorf("error serving error json: %v", err)
+	}
+
+	return true
+}
+
+// SetMaintenanceWindows replaces the maintenance windows, during which write requests are rejected. An error is
+// returned if any of the windows is not valid, in which case the current windows are kept.
+func (app *App) SetMaintenanceWindows(windows []configuration.MaintenanceWindow) error {
+	if err := validateMaintenanceWindows(windows); err != nil {
+		return err
+	}
+	app.maintenance.set(windows)
+	dcontext.GetLogger(app).WithField("windows", len(windows)).Info("maintenance windows updated")
+
+	return nil
+}
+
+// MaintenanceWindow is a maintenance window as represented by the maintenance handler.
+type MaintenanceWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// MaintenanceStatus reports the maintenance windows and whether one is in progress.
+type MaintenanceStatus struct {
+	// Active is true if a maintenance window is in progress, during which write requests are rejected.
+	Active bool `json:"active"`
+	// Windows is the list of maintenance windows, sorted by start time.
+	Windows []MaintenanceWindow `json:"windows"`
+}
+
+// MaintenanceStatus returns the current maintenance status.
+func (app *App) MaintenanceStatus() MaintenanceStatus {
+	_, active := app.maintenance.active(time.Now())
+	s := MaintenanceStatus{Active: active, Windows: make([]MaintenanceWindow, 0)}
+	for _, w := range app.maintenance.list() {
+		s.Windows = append(s.Windows, MaintenanceWindow{Start: w.Start, End: w.End, Reason: w.Reason})
+	}
+
+	return s
+}
+
+// maxMaintenanceRequestBodySize is the maximum size of the body of requests to the maintenance handler.
+const maxMaintenanceRequestBodySize = 1 << 20
+
+// MaintenanceHandler returns a handler to manage maintenance windows through the debug server. A PUT request replaces
+// the maintenance windows with those in the request body, a JSON object with a `windows` list, and a DELETE request
+// removes all of them. GET, PUT and DELETE requests respond with the maintenance status in JSON format. Changes only
+// apply to this instance and are lost on restart or configuration reload.
+func (app *App) MaintenanceHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body struct {
+				Windows []MaintenanceWindow `json:"windows"`
+			}
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMaintenanceRequestBodySize))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&body); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			windows := make([]configuration.MaintenanceWindow, 0, len(body.Windows))
+			for _, mw := range body.Windows {
+				windows = append(windows, configuration.MaintenanceWindow{Start: mw.Start, End: mw.End, Reason: mw.Reason})
+			}
+			if err := app.SetMaintenanceWindows(windows); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			app.maintenance.set(nil)
+			dcontext.GetLogger(app).Info("maintenance windows cleared")
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(app.MaintenanceStatus()); err != nil {
+			dcontext.GetLogger(app).WithError(err).Error("error writing maintenance status")
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMaintenanceWindows(t *testing.T) {
+	now := time.Now()
+
+	require.NoError(t, validateMaintenanceWindows(nil))
+	require.NoError(t, validateMaintenanceWindows([]configuration.MaintenanceWindow{{Start: now, End: now.Add(time.Hour)}}))
+
+	err := validateMaintenanceWindows([]configuration.MaintenanceWindow{{Start: now}})
+	require.EqualError(t, err, "windows[0]: start and end must be set")
+
+	err = validateMaintenanceWindows([]configuration.MaintenanceWindow{
+		{Start: now, End: now.Add(time.Hour)},
+		{Start: now, End: now},
+	})
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "windows[1]: end"))
+}
+
+func TestMaintenanceScheduler_Active(t *testing.T) {
+	now := time.Now()
+	var m maintenanceScheduler
+
+	_, ok := m.active(now)
+	require.False(t, ok)
+
+	m.set([]configuration.MaintenanceWindow{
+		{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour), Reason: "future"},
+		{Start: now.Add(-time.Hour), End: now.Add(10 * time.Minute), Reason: "short"},
+		{Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour), Reason: "past"},
+		{Start: now.Add(-time.Minute), End: now.Add(30 * time.Minute), Reason: "long"},
+	})
+
+	// windows are kept sorted by start time
+	var reasons []string
+	for _, w := range m.list() {
+		reasons = append(reasons, w.Reason)
+	}
+	require.Equal(t, []string{"past", "short", "long", "future"}, reasons)
+
+	// the overlapping window that ends last is returned
+	w, ok := m.active(now)
+	require.True(t, ok)
+	require.Equal(t, "long", w.Reason)
+
+	w, ok = m.active(now.Add(90 * time.Minute))
+	require.True(t, ok)
+	require.Equal(t, "future", w.Reason)
+
+	_, ok = m.active(now.Add(45 * time.Minute))
+	require.False(t, ok)
+	_, ok = m.active(now.Add(2 * time.Hour))
+	require.False(t, ok)
+}
+
+func TestUnderMaintenance(t *testing.T) {
+	app := &App{Context: context.Background()}
+	end := time.Now().Add(time.Minute)
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		if app.underMaintenance(w, httptest.NewRequest(method, "/v2/foo/bar/manifests/latest", nil)) {
+			return w
+		}
+		return nil
+	}
+
+	require.Nil(t, serve(http.MethodPut))
+
+	require.NoError(t, app.SetMaintenanceWindows([]configuration.MaintenanceWindow{
+		{Start: time.Now().Add(-time.Minute), End: end, Reason: "storage migration"},
+	}))
+
+	// reads are served during maintenance
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		require.Nil(t, serve(method), method)
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		w := serve(method)
+		require.NotNil(t, w, method)
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.Contains(t, w.Body.String(), "UNAVAILABLE")
+		require.Contains(t, w.Body.String(), "registry is under maintenance until "+end.UTC().Format(time.RFC3339)+": storage migration")
+
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		require.NoError(t, err)
+		require.Greater(t, retryAfter, 0)
+		require.LessOrEqual(t, retryAfter, 60)
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	app := &App{Context: context.Background()}
+	h := app.MaintenanceHandler()
+
+	status := func(method, body string) (int, MaintenanceStatus) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/debug/maintenance", strings.NewReader(body)))
+
+		var s MaintenanceStatus
+		if w.Code == http.StatusOK {
+			require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&s))
+		}
+		return w.Code, s
+	}
+
+	code, s := status(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, MaintenanceStatus{Windows: []MaintenanceWindow{}}, s)
+
+	start := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	end := start.Add(time.Hour)
+	body := `{"windows":[{"start":"` + start.Format(time.RFC3339) + `","end":"` + end.Format(time.RFC3339) + `","reason":"storage migration"}]}`
+	code, s = status(http.MethodPut, body)
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, s.Active)
+	require.Len(t, s.Windows, 1)
+	require.True(t, start.Equal(s.Windows[0].Start))
+	require.True(t, end.Equal(s.Windows[0].End))
+	require.Equal(t, "storage migration", s.Windows[0].Reason)
+
+	// invalid requests keep the current windows
+	code, _ = status(http.MethodPut, `{"windows":[{"start":"`+end.Format(time.RFC3339)+`","end":"`+start.Format(time.RFC3339)+`"}]}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = status(http.MethodPut, `{"foo":"bar"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = status(http.MethodPut, `not json`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, s = status(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, s.Active)
+	require.Len(t, s.Windows, 1)
+
+	code, _ = status(http.MethodPost, "")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, s = status(http.MethodDelete, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, MaintenanceStatus{Windows: []MaintenanceWindow{}}, s)
+}
+
+func TestGitlabAPI_UnderMaintenance(t *testing.T) {
+	app, err := NewApp(context.Background(), testConfig())
+	require.NoError(t, err)
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	require.NoError(t, app.SetMaintenanceWindows([]configuration.MaintenanceWindow{
+		{Start: time.Now().Add(-time.Minute), End: time.Now().Add(time.Minute)},
+	}))
+
+	do := func(t *testing.T, method, path string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	tcs := map[string]struct {
+		method string
+		path   string
+	}{
+		"tags bulk delete":    {method: http.MethodDelete, path: "/gitlab/v1/repositories/foo/bar/tags/"},
+		"repository rename":   {method: http.MethodPatch, path: "/gitlab/v1/repositories/foo/bar/"},
+		"repository purge":    {method: http.MethodPost, path: "/gitlab/v1/repositories/foo/bar/purge/"},
+		"layout import":       {method: http.MethodPost, path: "/gitlab/v1/repositories/foo/bar/import/"},
+		"repository labels":   {method: http.MethodPut, path: "/gitlab/v1/repository-labels/foo/bar/"},
+		"repository settings": {method: http.MethodPut, path: "/gitlab/v1/repository-settings/foo/bar/"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			resp := do(t, tc.method, tc.path)
+			require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+			require.NotEmpty(t, resp.Header.Get("Retry-After"))
+		})
+	}
+
+	// reads, including cleanup policy previews, are served during maintenance, which here means reaching authorization
+	require.Equal(t, http.StatusUnauthorized, do(t, http.MethodGet, "/gitlab/v1/repositories/foo/bar/").StatusCode)
+	require.Equal(t, http.StatusUnauthorized, do(t, http.MethodPost, "/gitlab/v1/repositories/foo/bar/tags/cleanup-policy/preview/").StatusCode)
+}
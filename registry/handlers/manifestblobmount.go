@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/sync/errgroup"
+)
+
+// blobMountSources returns the paths of the repositories that blobs can be mounted from into the repository with the
+// given path during manifest pushes. These are the repositories that the client was granted pull access to, sharing
+// storage with the target repository. Wildcard scopes are not expanded.
+func (app *App) blobMountSources(granted []auth.Access, target string) []string {
+	seen := make(map[string]struct{})
+	var paths []string
+	for _, a := range granted {
+		if a.Type != "repository" || (a.Action != "pull" && a.Action != "*") || a.IsWildcard() || a.Name == target {
+			continue
+		}
+		if _, ok := seen[a.Name]; ok || !app.sharesStorage(a.Name, target) {
+			continue
+		}
+		seen[a.Name] = struct{}{}
+		paths = append(paths, a.Name)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// blobMount is a blob to be mounted into a repository from the source repository with the given path.
+type blobMount struct {
+	blob   *models.Blob
+	source string
+}
+
+// mountableBlobStatter is the distribution.BlobStatter of manifest validation, which also finds the blobs that are
+// going to be mounted into the repository.
+type mountableBlobStatter struct {
+	distribution.BlobStatter
+	mounts map[digest.Digest]blobMount
+}
+
+func (s mountableBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	if m, ok := s.mounts[dgst]; ok {
+		return distribution.Descriptor{Digest: m.blob.Digest, Size: m.blob.Size, MediaType: m.blob.MediaType}, nil
+	}
+	return s.BlobStatter.Stat(ctx, dgst)
+}
+
+// dbFindManifestBlobMounts finds the blobs referenced by an image manifest that are unknown to the target repository
+// but can be mounted from other repositories that the client has pull access to, so that the manifest can be pushed
+// without uploading them first. Source repositories are searched concurrently. Nothing is written: the blobs are only
+// linked to the target repository by dbPutManifestV2, once the manifest is validated, in the same transaction as the
+// manifest. Blobs that are not found in any source repository are left for the manifest validation to report as
+// unknown.
+func dbFindManifestBlobMounts(imh *manifestHandler, rStore datastore.RepositoryStore, mfst distribution.ManifestV2) (map[digest.Digest]blobMount, error) {
+	config := imh.App.Config.Validation.Manifests.BlobMount
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	repoPath := imh.Repository.Named().Name()
+	sources := imh.App.blobMountSources(auth.GrantedAccess(imh), repoPath)
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	target, err := rStore.FindByPath(imh, repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[digest.Digest]struct{})
+	var missing []digest.Digest
+	for _, desc := range append([]distribution.Descriptor{mfst.Config()}, mfst.Layers()...) {
+		// blobs with URLs are not required to exist in the registry
+		if _, ok := seen[desc.Digest]; ok || len(desc.URLs) > 0 {
+			continue
+		}
+		seen[desc.Digest] = struct{}{}
+
+		if target != nil {
+			b, err := rStore.FindBlob(imh, target, desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if b != nil {
+				continue
+			}
+		}
+		missing = append(missing, desc.Digest)
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	srcRepos := make([]*models.Repository, 0, len(sources))
+	for _, path := range sources {
+		r, err := rStore.FindByPath(imh, path)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			srcRepos = append(srcRepos, r)
+		}
+	}
+	if len(srcRepos) == 0 {
+		return nil, nil
+	}
+
+	found := make([]blobMount, len(missing))
+	g, ctx := errgroup.WithContext(imh.Context)
+	g.SetLimit(config.Concurrency)
+	for i, d := range missing {
+		i, d := i, d
+		g.Go(func() error {
+			for _, src := range srcRepos {
+				b, err := rStore.FindBlob(ctx, src, d)
+				if err != nil {
+					return err
+				}
+				if b != nil {
+					found[i] = blobMount{blob: b, source: src.Path}
+					return nil
+				}
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	mounts := make(map[digest.Digest]blobMount)
+	for _, m := range found {
+		if m.blob != nil {
+			mounts[m.blob.Digest] = m
+		}
+	}
+
+	return mounts, nil
+}
+
+// dbMountManifestBlobs links the blobs found by dbFindManifestBlobMounts to the target repository, using the
+// repository store of the transaction creating the manifest.
+func dbMountManifestBlobs(imh *manifestHandler, rStore datastore.RepositoryStore, target *models.Repository, mounts map[digest.Digest]blobMount) error {
+	// link in a consistent order, so that concurrent pushes mounting the same blobs can't deadlock
+	dgsts := make([]digest.Digest, 0, len(mounts))
+	for d := range mounts {
+		dgsts = append(dgsts, d)
+	}
+	sort.Slice(dgsts, func(i, j int) bool { return dgsts[i] < dgsts[j] })
+
+	l := log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{"repository": target.Path})
+	for _, d := range dgsts {
+		if err := rStore.LinkBlob(imh, target, d); err != nil {
+			return err
+		}
+		l.WithFields(log.Fields{"source": mounts[d].source, "digest": d}).Info("blob mounted during manifest push")
+	}
+
+	return nil
+}
+
+// recordBlobMountAudits records the blobs mounted by dbMountManifestBlobs, once committed.
+func recordBlobMountAudits(imh *manifestHandler, mounts map[digest.Digest]blobMount) {
+	for _, m := range mounts {
+		imh.recordAudit(audit.Record{Action: audit.ActionMount, Artifact: audit.ArtifactBlob, Digest: m.blob.Digest, MediaType: m.blob.MediaType})
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_BlobMountSources(t *testing.T) {
+	access := func(typ, name, action string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: typ, Name: name}, Action: action}
+	}
+
+	granted := []auth.Access{
+		access("repository", "a/target", "pull"),
+		access("repository", "a/target", "push"),
+		access("repository", "b/foo", "push"),
+		access("repository", "b/bar", "pull"),
+		access("repository", "b/bar", "*"),
+		access("repository", "a/baz", "*"),
+		access("repository", "c/*", "pull"),
+		access("registry", "catalog", "*"),
+		access("repository", "a/qux", "pull"),
+	}
+
+	app := &App{Config: &configuration.Configuration{}}
+	require.Equal(t, []string{"a/baz", "a/qux", "b/bar"}, app.blobMountSources(granted, "a/target"))
+	require.Empty(t, app.blobMountSources(nil, "a/target"))
+
+	app.Config.Isolation = configuration.Isolation{
+		Enabled:    true,
+		Namespaces: map[string]configuration.Storage{"b": {"inmemory": nil}},
+	}
+	require.Equal(t, []string{"a/baz", "a/qux"}, app.blobMountSources(granted, "a/target"))
+}
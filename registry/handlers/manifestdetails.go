@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+)
+
+// configQueryParamKey is the query parameter used to inline the configuration payload in the manifest details response.
+const configQueryParamKey = "config"
+
+type repositoryManifestHandler struct {
+	*Context
+}
+
+func repositoryManifestDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryManifestHandler := &repositoryManifestHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryManifestHandler.GetManifest),
+	}
+}
+
+// RepositoryManifestAPIResponse is the response body of the repository manifest details endpoint. Config is only set
+// if requested with the `config` query parameter and the manifest has a JSON configuration.
+type RepositoryManifestAPIResponse struct {
+	Digest          string          `json:"digest"`
+	MediaType       string          `json:"media_type"`
+	ArtifactType    string          `json:"artifact_type,omitempty"`
+	Size            int64           `json:"size_bytes"`
+	ConfigDigest    string          `json:"config_digest,omitempty"`
+	ConfigMediaType string          `json:"config_media_type,omitempty"`
+	CreatedAt       string          `json:"created_at"`
+	Manifest        json.RawMessage `json:"manifest"`
+	Config          json.RawMessage `json:"config,omitempty"`
+}
+
+// configQueryParamValue extracts the value of the `config` query parameter from q. False is returned if the parameter
+// is not set, and an error is returned if its value is neither "true" nor "false".
+func configQueryParamValue(q url.Values) (bool, error) {
+	if !q.Has(configQueryParamKey) {
+		return false, nil
+	}
+	switch q.Get(configQueryParamKey) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		detail := v1.InvalidQueryParamValueErrorDetail(configQueryParamKey, []string{"true", "false"})
+		return false, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+	}
+}
+
+// dbFindManifestByReference finds the manifest of repo identified by ref, which is either a tag or a digest.
+func dbFindManifestByReference(ctx context.Context, rStore datastore.RepositoryStore, repo *models.Repository, ref string) (*models.Manifest, error) {
+	if dgst, err := digest.Parse(ref); err == nil {
+		return rStore.FindManifestByDigest(ctx, repo, dgst)
+	}
+
+	return rStore.FindManifestByTagName(ctx, repo, ref)
+}
+
+// GetManifest returns the details of a manifest, identified by tag or digest, along with its payload. If requested,
+// the configuration payload is inlined, sparing clients from fetching the configuration blob separately.
+func (h *repositoryManifestHandler) GetManifest(w http.ResponseWriter, r *http.Request) {
+	inlineConfig, err := configQueryParamValue(r.URL.Query())
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	path := h.Repository.Named().Name()
+	rStore := datastore.NewRepositoryStore(h.db)
+	repo, err := rStore.FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return
+	}
+
+	ref := getReference(h)
+	m, err := dbFindManifestByReference(h.Context, rStore, repo, ref)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if m == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeManifestUnknown.WithDetail(map[string]string{"reference": ref}))
+		return
+	}
+
+	resp := RepositoryManifestAPIResponse{
+		Digest:    m.Digest.String(),
+		MediaType: m.MediaType,
+		Size:      m.TotalSize,
+		CreatedAt: timeToString(m.CreatedAt),
+		Manifest:  json.RawMessage(m.Payload),
+	}
+	if m.ArtifactType.Valid {
+		resp.ArtifactType = m.ArtifactType.String
+	}
+	if m.Configuration != nil {
+		resp.ConfigDigest = m.Configuration.Digest.String()
+		resp.ConfigMediaType = m.Configuration.MediaType
+
+		if inlineConfig {
+			payload, err := h.configPayload(m.Configuration)
+			if err != nil {
+				h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+				return
+			}
+			// configurations of arbitrary artifacts are not necessarily JSON, in which case they can't be inlined
+			if json.Valid(payload) {
+				resp.Config = payload
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// configPayload returns the payload of cfg. Payloads over datastore.ConfigSizeLimit are not cached in the database,
+// so these are read from the configuration blob in common blob storage.
+func (h *repositoryManifestHandler) configPayload(cfg *models.Configuration) ([]byte, error) {
+	if len(cfg.Payload) > 0 {
+		return cfg.Payload, nil
+	}
+
+	bp, ok := h.App.registry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert BlobEnumerator into BlobProvider")
+	}
+
+	return bp.Get(h, cfg.Digest)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigQueryParamValue(t *testing.T) {
+	tt := []struct {
+		name        string
+		query       url.Values
+		expected    bool
+		expectedErr bool
+	}{
+		{name: "not set", query: url.Values{}},
+		{name: "true", query: url.Values{"config": []string{"true"}}, expected: true},
+		{name: "false", query: url.Values{"config": []string{"false"}}},
+		{name: "empty", query: url.Values{"config": []string{""}}, expectedErr: true},
+		{name: "invalid", query: url.Values{"config": []string{"1"}}, expectedErr: true},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := configQueryParamValue(test.query)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, got)
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/manifest/manifestlist"
+	mlcompat "github.com/docker/distribution/manifest/manifestlist/compat"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type repositoryManifestExportHandler struct {
+	*Context
+}
+
+func repositoryManifestExportDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryManifestExportHandler := &repositoryManifestExportHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryManifestExportHandler.ExportManifest),
+	}
+}
+
+// imageLayoutBlob is a blob in an OCI image layout. The payload of manifests is held in memory, while other blobs are
+// read from storage when written to the layout.
+type imageLayoutBlob struct {
+	digest  digest.Digest
+	size    int64
+	payload []byte
+}
+
+// imageLayoutExport is the content of an OCI image layout exported from a repository.
+type imageLayoutExport struct {
+	index ocispec.Index
+	blobs []imageLayoutBlob
+}
+
+// dbImageLayoutExport collects the content of the OCI image layout for m, which includes m itself and, recursively, the
+// manifests and blobs it references. Layers with URLs are not stored in the registry, so these are left out. An error
+// is returned if any other reference is unknown to repo.
+func dbImageLayoutExport(ctx context.Context, rStore datastore.RepositoryStore, repo *models.Repository, m *models.Manifest, tag string) (*imageLayoutExport, error) {
+	desc := ocispec.Descriptor{
+		MediaType: m.MediaType,
+		Digest:    m.Digest,
+		Size:      int64(len(m.Payload)),
+	}
+	if tag != "" {
+		desc.Annotations = map[string]string{ocispec.AnnotationRefName: tag}
+	}
+
+	export := &imageLayoutExport{
+		index: ocispec.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: []ocispec.Descriptor{desc},
+		},
+	}
+	seen := make(map[digest.Digest]struct{})
+
+	var addBlob func(dgst digest.Digest) error
+	addBlob = func(dgst digest.Digest) error {
+		if _, ok := seen[dgst]; ok {
+			return nil
+		}
+		b, err := rStore.FindBlob(ctx, repo, dgst)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return v2.ErrorCodeBlobUnknown.WithDetail(dgst)
+		}
+		seen[dgst] = struct{}{}
+		export.blobs = append(export.blobs, imageLayoutBlob{digest: dgst, size: b.Size})
+		return nil
+	}
+
+	var addManifest func(m *models.Manifest) error
+	addManifest = func(m *models.Manifest) error {
+		if _, ok := seen[m.Digest]; ok {
+			return nil
+		}
+		seen[m.Digest] = struct{}{}
+		export.blobs = append(export.blobs, imageLayoutBlob{digest: m.Digest, size: int64(len(m.Payload)), payload: m.Payload})
+
+		if m.MediaType == schema1.MediaTypeSignedManifest || m.MediaType == schema1.MediaTypeManifest {
+			return v2.ErrorCodeManifestInvalid.WithDetail("schema 1 manifests can not be exported")
+		}
+		mfst, _, err := distribution.UnmarshalManifest(m.MediaType, m.Payload)
+		if err != nil {
+			return err
+		}
+
+		switch mfst := mfst.(type) {
+		case *manifestlist.DeserializedManifestList:
+			refs := mlcompat.References(mfst)
+			for _, desc := range refs.Manifests {
+				child, err := rStore.FindManifestByDigest(ctx, repo, desc.Digest)
+				if err != nil {
+					return err
+				}
+				if child == nil {
+					return v2.ErrorCodeManifestUnknown.WithDetail(map[string]string{"digest": desc.Digest.String()})
+				}
+				if err := addManifest(child); err != nil {
+					return err
+				}
+			}
+			// indexes such as buildx cache manifests may reference blobs directly
+			for _, desc := range refs.Blobs {
+				if err := addBlob(desc.Digest); err != nil {
+					return err
+				}
+			}
+		case distribution.ManifestV2:
+			if err := addBlob(mfst.Config().Digest); err != nil {
+				return err
+			}
+			for _, desc := range mfst.Layers() {
+				if len(desc.URLs) > 0 {
+					continue
+				}
+				if err := addBlob(desc.Digest); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unable to export manifest with media type %q", m.MediaType)
+		}
+
+		return nil
+	}
+
+	if err := addManifest(m); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// write writes the OCI image layout as a tar archive to w. All entries have the given modification time, so that
+// exporting the same manifest twice produces the same archive. Blobs without an in-memory payload are read with open.
+func (e *imageLayoutExport) write(w io.Writer, modTime time.Time, open func(dgst digest.Digest) (io.ReadCloser, error)) error {
+	tw := tar.NewWriter(w)
+
+	writeFile := func(name string, size int64, r io.Reader) error {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     size,
+			Mode:     0o644,
+			ModTime:  modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	writeJSON := func(name string, v any) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return writeFile(name, int64(len(b)), bytes.NewReader(b))
+	}
+
+	if err := writeJSON(ocispec.ImageLayoutFile, ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion}); err != nil {
+		return err
+	}
+	if err := writeJSON("index.json", e.index); err != nil {
+		return err
+	}
+
+	writeDir := func(name string) error {
+		return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0o755, ModTime: modTime})
+	}
+
+	if err := writeDir("blobs/"); err != nil {
+		return err
+	}
+	dirs := make(map[string]struct{})
+	for _, b := range e.blobs {
+		// blobs are stored under a directory for each digest algorithm
+		dir := path.Join("blobs", b.digest.Algorithm().String()) + "/"
+		if _, ok := dirs[dir]; !ok {
+			dirs[dir] = struct{}{}
+			if err := writeDir(dir); err != nil {
+				return err
+			}
+		}
+
+		name := dir + b.digest.Encoded()
+		if b.payload != nil {
+			if err := writeFile(name, b.size, bytes.NewReader(b.payload)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := open(b.digest)
+		if err != nil {
+			return fmt.Errorf("opening blob %s: %w", b.digest, err)
+		}
+		err = writeFile(name, b.size, io.LimitReader(rc, b.size))
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("writing blob %s: %w", b.digest, err)
+		}
+	}
+
+	return tw.Close()
+}
+
+// ExportManifest streams a tar archive of the manifest identified by tag or digest, along with its configuration,
+// layers and, for indexes, child manifests, in the OCI image layout format. This allows exporting an image for
+// air-gapped environments without additional tooling. All references are verified to exist before streaming starts.
+func (h *repositoryManifestExportHandler) ExportManifest(w http.ResponseWriter, r *http.Request) {
+	repoPath := h.Repository.Named().Name()
+	rStore := datastore.NewRepositoryStore(h.db)
+	repo, err := rStore.FindByPath(h.Context, repoPath)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath}))
+		return
+	}
+
+	ref := getReference(h)
+	m, err := dbFindManifestByReference(h.Context, rStore, repo, ref)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if m == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeManifestUnknown.WithDetail(map[string]string{"reference": ref}))
+		return
+	}
+
+	var tag string
+	if _, err := digest.Parse(ref); err != nil {
+		tag = ref
+	}
+	export, err := dbImageLayoutExport(h.Context, rStore, repo, m, tag)
+	if err != nil {
+		switch err := err.(type) {
+		case errcode.Error:
+			h.Errors = append(h.Errors, err)
+		default:
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		}
+		return
+	}
+
+	bp, ok := h.App.registry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("unable to convert BlobEnumerator into BlobProvider")))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", m.Digest.Encoded()+".tar"))
+	w.Header().Set("Docker-Content-Digest", m.Digest.String())
+	w.WriteHeader(http.StatusOK)
+
+	open := func(dgst digest.Digest) (io.ReadCloser, error) {
+		return bp.Open(h, dgst)
+	}
+	// once streaming started, errors can no longer be reported to the client, which is left with a truncated archive
+	if err := export.write(w, m.CreatedAt, open); err != nil {
+		log.GetLogger(log.WithContext(h)).WithError(err).Warn("failed to stream manifest export")
+		return
+	}
+
+	log.GetLogger(log.WithContext(h)).WithFields(log.Fields{
+		"digest":     m.Digest,
+		"blob_count": len(export.blobs),
+	}).Info("manifest exported")
+}
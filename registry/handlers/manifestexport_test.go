@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageLayoutExport_Write(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2}`)
+	layer := []byte("layer")
+	mDgst, lDgst := digest.FromBytes(manifest), digest.FromBytes(layer)
+
+	e := &imageLayoutExport{
+		index: ocispec.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			Manifests: []ocispec.Descriptor{{MediaType: ocispec.MediaTypeImageManifest, Digest: mDgst, Size: int64(len(manifest))}},
+		},
+		blobs: []imageLayoutBlob{
+			{digest: mDgst, size: int64(len(manifest)), payload: manifest},
+			{digest: lDgst, size: int64(len(layer))},
+		},
+	}
+
+	modTime := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	var opened []digest.Digest
+	open := func(dgst digest.Digest) (io.ReadCloser, error) {
+		opened = append(opened, dgst)
+		return io.NopCloser(bytes.NewReader(layer)), nil
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, e.write(&buf, modTime, open))
+	require.Equal(t, []digest.Digest{lDgst}, opened)
+
+	var names []string
+	files := make(map[string]string)
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, modTime, hdr.ModTime.UTC())
+
+		names = append(names, hdr.Name)
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(b)
+	}
+
+	require.Equal(t, []string{
+		"oci-layout",
+		"index.json",
+		"blobs/",
+		"blobs/sha256/",
+		"blobs/sha256/" + mDgst.Encoded(),
+		"blobs/sha256/" + lDgst.Encoded(),
+	}, names)
+	require.JSONEq(t, `{"imageLayoutVersion":"1.0.0"}`, files["oci-layout"])
+	require.JSONEq(t, `{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"`+mDgst.String()+`","size":19}]}`, files["index.json"])
+	require.Equal(t, string(manifest), files["blobs/sha256/"+mDgst.Encoded()])
+	require.Equal(t, string(layer), files["blobs/sha256/"+lDgst.Encoded()])
+}
+
+func TestImageLayoutExport_Write_OpenError(t *testing.T) {
+	e := &imageLayoutExport{blobs: []imageLayoutBlob{{digest: digest.FromString("layer"), size: 5}}}
+
+	err := e.write(io.Discard, time.Now(), func(digest.Digest) (io.ReadCloser, error) {
+		return nil, errors.New("not found")
+	})
+	require.EqualError(t, err, "opening blob "+digest.FromString("layer").String()+": not found")
+}
+
+func TestImageLayoutExport_Write_ShortBlob(t *testing.T) {
+	e := &imageLayoutExport{blobs: []imageLayoutBlob{{digest: digest.FromString("layer"), size: 10}}}
+
+	// blobs shorter than their recorded size must not produce a valid archive
+	err := e.write(io.Discard, time.Now(), func(digest.Digest) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader([]byte("layer"))), nil
+	})
+	require.Error(t, err)
+}
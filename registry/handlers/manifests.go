@@ -8,10 +8,13 @@ import (
 	"fmt"
 	"mime"
 	"net/http"
+	gopath "path"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/log"
 	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/manifest/manifestlist"
@@ -22,9 +25,11 @@ import (
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/storage"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/validation"
 	"github.com/gorilla/handlers"
@@ -39,6 +44,8 @@ const (
 	defaultOS           = "linux"
 	maxManifestBodySize = 4 << 20
 	imageClass          = "image"
+
+	platformQueryParamKey = "platform"
 )
 
 type storageType int
@@ -93,7 +100,7 @@ func manifestDispatcher(ctx *Context, r *http.Request) http.Handler {
 		mhandler[http.MethodDelete] = http.HandlerFunc(manifestHandler.DeleteManifest)
 	}
 
-	return checkOngoingRename(mhandler, ctx)
+	return checkOngoingRename(checkReadOnlyRepository(mhandler, ctx), ctx)
 }
 
 // manifestHandler handles http operations on image manifests.
@@ -103,6 +110,9 @@ type manifestHandler struct {
 	// One of tag or digest gets set, depending on what is present in context.
 	Tag    string
 	Digest digest.Digest
+
+	// tagPrecondition is the condition that Tag must satisfy to be updated or deleted, if any.
+	tagPrecondition *tagPrecondition
 }
 
 // GetManifest fetches the image manifest from the storage backend, if it exists.
@@ -110,21 +120,21 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 	l := log.GetLogger(log.WithContext(imh))
 	l.Debug("GetImageManifest")
 
+	platform, err := platformFromRequest(r)
+	if err != nil {
+		imh.Errors = append(imh.Errors, v2.ErrorCodePlatformInvalid.WithDetail(err))
+		return
+	}
+
 	manifestGetter, err := imh.newManifestGetter(r)
 	if err != nil {
 		imh.Errors = append(imh.Errors, err)
 		return
 	}
 
-	var (
-		manifest distribution.Manifest
-		getErr   error
-	)
-
-	if imh.Tag != "" {
-		manifest, imh.Digest, getErr = manifestGetter.GetByTag(imh.Context, imh.Tag)
-	} else {
-		manifest, getErr = manifestGetter.GetByDigest(imh.Context, imh.Digest)
+	manifest, getErr := imh.getManifest(manifestGetter)
+	if getErr != nil && imh.proxyEnabled() && isManifestUnknown(getErr) {
+		manifestGetter, manifest, getErr = imh.getProxyManifest(r)
 	}
 	if getErr != nil {
 		switch {
@@ -160,14 +170,24 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 	}
 
 	if manifestType == manifestSchema1 {
-		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithMessage("Schema 1 manifest not supported"))
-		return
+		sm, ok := manifest.(*schema1.SignedManifest)
+		if !ok || !imh.canConvertSchema1(r) {
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithMessage("Schema 1 manifest not supported"))
+			return
+		}
+		manifest, imh.Digest, err = imh.convertSchema1Manifest(sm)
+		if err != nil {
+			imh.Errors = append(imh.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		manifestType = manifestSchema2
 	}
 	if manifestType == ociImageManifestSchema && !supports(r, ociImageManifestSchema) {
 		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithMessage("OCI manifest found, but accept header does not support OCI manifests"))
 		return
 	}
-	if manifestType == ociImageIndexSchema && !supports(r, ociImageIndexSchema) {
+	// Clients requesting a platform want the manifest it resolves to, not the index itself.
+	if manifestType == ociImageIndexSchema && platform == nil && !supports(r, ociImageIndexSchema) {
 		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithMessage("OCI index found, but accept header does not support OCI indexes"))
 		return
 	}
@@ -176,10 +196,10 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 		logIfManifestListInvalid(imh, manifestList, http.MethodGet)
 	}
 
-	// Only rewrite manifests lists when they are being fetched by tag. If they
-	// are being fetched by digest, we can't return something not matching the digest.
-	if imh.Tag != "" && manifestType == manifestlistSchema && !supports(r, manifestlistSchema) {
-		manifest, err = imh.rewriteManifestList(manifestList)
+	// Only rewrite manifests lists when they are being fetched by tag, unless a platform was explicitly requested. If
+	// they are being fetched by digest, we can't return something not matching the digest.
+	if isManifestList && (platform != nil || imh.Tag != "" && manifestType == manifestlistSchema && !supports(r, manifestlistSchema)) {
+		manifest, err = imh.rewriteManifestList(manifestList, platform)
 		if err != nil {
 			switch err := err.(type) {
 			case distribution.ErrManifestUnknownRevision:
@@ -199,6 +219,11 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := imh.verifySignature(imh.Digest, p); err != nil {
+		imh.Errors = append(imh.Errors, err)
+		return
+	}
+
 	if err := imh.queueBridge.ManifestPulled(imh.Repository.Named(), manifest, distribution.WithTagOption{Tag: imh.Tag}); err != nil {
 		l.WithError(err).Error("dispatching manifest pull to queue")
 	}
@@ -209,6 +234,13 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 	w.Write(p)
 
 	if r.Method == http.MethodGet {
+		imh.recordPull()
+		if g, ok := manifestGetter.(*dbManifestGetter); ok {
+			imh.recordLastPulled(g.manifest)
+			if g.manifest != nil {
+				imh.recordNamespacePull(g.manifest.NamespaceID)
+			}
+		}
 		l.WithFields(log.Fields{
 			"media_type":      manifestType.MediaType(),
 			"size_bytes":      len(p),
@@ -219,6 +251,53 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// getManifest gets the requested manifest, by tag or digest, through manifestGetter. When getting it by tag, the digest
+// of the handler is set to that of the tagged manifest.
+func (imh *manifestHandler) getManifest(manifestGetter manifestGetter) (distribution.Manifest, error) {
+	if imh.Tag == "" {
+		return manifestGetter.GetByDigest(imh.Context, imh.Digest)
+	}
+
+	var (
+		manifest distribution.Manifest
+		err      error
+	)
+	manifest, imh.Digest, err = manifestGetter.GetByTag(imh.Context, imh.Tag)
+	return manifest, err
+}
+
+// recordLastPulled records the pull of the given manifest, through the current tag if any, so that their last pulled
+// timestamp is updated in the database, if pull tracking is enabled.
+func (imh *manifestHandler) recordLastPulled(m *models.Manifest) {
+	if imh.pullTracker == nil || m == nil {
+		return
+	}
+	imh.pullTracker.Record(&models.Pull{
+		NamespaceID:  m.NamespaceID,
+		RepositoryID: m.RepositoryID,
+		ManifestID:   m.ID,
+		TagName:      imh.Tag,
+		PulledAt:     time.Now(),
+	})
+}
+
+// recordManifestNamespacePush records the push of a manifest to the current repository in the statistics of its
+// top-level namespace, if enabled. The repository was just found or created while writing the manifest, so it's looked
+// up in the repository cache.
+func (imh *manifestHandler) recordManifestNamespacePush() {
+	if imh.namespaceStatistics == nil || !imh.useDatabase {
+		return
+	}
+
+	rStore := datastore.NewRepositoryStore(imh.App.db, datastore.WithRepositoryCache(getRepoCache(imh)))
+	r, err := rStore.FindByPath(imh.Context, imh.Repository.Named().Name())
+	if err != nil || r == nil {
+		log.GetLogger(log.WithContext(imh)).WithError(err).Warn("failed to record manifest push in namespace statistics")
+		return
+	}
+	imh.recordNamespacePush(r.NamespaceID)
+}
+
 func supports(req *http.Request, st storageType) bool {
 	// this parsing of Accept headers is not quite as full-featured as godoc.org's parser, but we don't care about "q=" values
 	// https://github.com/golang/gddo/blob/e91d4165076d7474d20abda83f92d15c7ebc3e81/httputil/header/header.go#L165-L202
@@ -258,17 +337,77 @@ func supports(req *http.Request, st storageType) bool {
 	return false
 }
 
-func (imh *manifestHandler) rewriteManifestList(manifestList *manifestlist.DeserializedManifestList) (distribution.Manifest, error) {
-	l := log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{
-		"manifest_list_digest": imh.Digest.String(),
-		"default_arch":         defaultArch,
-		"default_os":           defaultOS})
-	l.Info("client does not advertise support for manifest lists, selecting a manifest image for the default arch and os")
+// platformFromRequest parses the platform requested through the `platform` query parameter, in the
+// `os/architecture[/variant]` format. It returns nil if no platform was requested.
+func platformFromRequest(r *http.Request) (*manifestlist.PlatformSpec, error) {
+	s := r.URL.Query().Get(platformQueryParamKey)
+	if s == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("platform %q is not in the os/architecture[/variant] format", s)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("platform %q is not in the os/architecture[/variant] format", s)
+		}
+	}
+
+	platform := &manifestlist.PlatformSpec{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+
+	return platform, nil
+}
+
+// platformMatches reports whether the platform of a manifest list entry satisfies the wanted platform. The variant is
+// only compared if wanted, in which case `arm64` entries without a variant are assumed to be `v8`.
+func platformMatches(got, want manifestlist.PlatformSpec) bool {
+	if got.OS != want.OS || got.Architecture != want.Architecture {
+		return false
+	}
+	if want.Variant == "" {
+		return true
+	}
+
+	variant := got.Variant
+	if variant == "" && got.Architecture == "arm64" {
+		variant = "v8"
+	}
+
+	return variant == want.Variant
+}
 
-	// Find the image manifest corresponding to the default platform.
+func formatPlatform(p manifestlist.PlatformSpec) string {
+	if p.Variant == "" {
+		return p.OS + "/" + p.Architecture
+	}
+	return p.OS + "/" + p.Architecture + "/" + p.Variant
+}
+
+// rewriteManifestList returns the manifest of the manifest list for the given platform, or for the default platform if
+// nil. The manifest digest of the handler is updated accordingly.
+func (imh *manifestHandler) rewriteManifestList(manifestList *manifestlist.DeserializedManifestList, platform *manifestlist.PlatformSpec) (distribution.Manifest, error) {
+	l := log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{"manifest_list_digest": imh.Digest.String()})
+
+	want := manifestlist.PlatformSpec{OS: defaultOS, Architecture: defaultArch}
+	if platform != nil {
+		want = *platform
+		l.WithFields(log.Fields{"platform": formatPlatform(want)}).Info("selecting a manifest image for the requested platform")
+	} else {
+		l.WithFields(log.Fields{
+			"default_arch": defaultArch,
+			"default_os":   defaultOS,
+		}).Info("client does not advertise support for manifest lists, selecting a manifest image for the default arch and os")
+	}
+
+	// Find the image manifest corresponding to the wanted platform.
 	var manifestDigest digest.Digest
 	for _, manifestDescriptor := range manifestList.Manifests {
-		if manifestDescriptor.Platform.Architecture == defaultArch && manifestDescriptor.Platform.OS == defaultOS {
+		if platformMatches(manifestDescriptor.Platform, want) {
 			manifestDigest = manifestDescriptor.Digest
 			break
 		}
@@ -276,8 +415,8 @@ func (imh *manifestHandler) rewriteManifestList(manifestList *manifestlist.Deser
 
 	if manifestDigest == "" {
 		return nil, v2.ErrorCodeManifestUnknown.WithDetail(
-			fmt.Errorf("manifest list %s does not contain a manifest image for the platform %s/%s",
-				imh.Digest, defaultOS, defaultArch))
+			fmt.Errorf("manifest list %s does not contain a manifest image for the platform %s",
+				imh.Digest, formatPlatform(want)))
 	}
 
 	// TODO: We're passing an empty request here to skip etag matching logic.
@@ -311,8 +450,20 @@ func (imh *manifestHandler) newManifestWriter() (manifestWriter, error) {
 	if !imh.useDatabase {
 		return newFSManifestWriter(imh)
 	}
+	if !imh.mirrorFS() {
+		return &dbManifestWriter{}, nil
+	}
+
+	repo, err := imh.fsMirrorRepository()
+	if err != nil {
+		return nil, err
+	}
+	mirror, err := newFSManifestWriterForRepository(imh, repo)
+	if err != nil {
+		return nil, err
+	}
 
-	return &dbManifestWriter{}, nil
+	return &mirroredManifestWriter{manifestWriter: &dbManifestWriter{}, mirror: mirror}, nil
 }
 
 type manifestGetter interface {
@@ -322,14 +473,26 @@ type manifestGetter interface {
 
 type dbManifestGetter struct {
 	datastore.RepositoryStore
-	repoPath string
-	req      *http.Request
+	manifestCache datastore.ManifestCache
+	repoPath      string
+	req           *http.Request
+
+	// manifest is the last manifest successfully retrieved, either from the cache or the database.
+	manifest *models.Manifest
 }
 
 func newDBManifestGetter(imh *manifestHandler, req *http.Request) (*dbManifestGetter, error) {
+	repoPath := imh.Repository.Named().Name()
+	repoCache := imh.repoCache
+	if manifestCacheEnabled(imh.App) {
+		// repositories must be looked up in Redis as well, otherwise cached manifests would still require a database query
+		repoCache = getRepoCache(imh)
+	}
+
 	return &dbManifestGetter{
-		RepositoryStore: datastore.NewRepositoryStore(imh.App.db, datastore.WithRepositoryCache(imh.repoCache)),
-		repoPath:        imh.Repository.Named().Name(),
+		RepositoryStore: datastore.NewRepositoryStore(imh.App.replicaDB(imh, repoPath), datastore.WithRepositoryCache(repoCache)),
+		manifestCache:   getManifestCache(imh.App),
+		repoPath:        repoPath,
 		req:             req,
 	}, nil
 }
@@ -346,15 +509,31 @@ func (g *dbManifestGetter) GetByTag(ctx context.Context, tagName string) (distri
 		return nil, "", distribution.ErrTagUnknown{Tag: tagName}
 	}
 
-	l.Info("getting manifest by tag from database")
-	dbManifest, err := g.FindManifestByTagName(ctx, dbRepo, tagName)
-	if err != nil {
-		return nil, "", err
+	// The cached tag digest is only trusted once the manifest it points to is found in the cache as well, which is
+	// invalidated along with the tag when the manifest is deleted.
+	var dbManifest *models.Manifest
+	dgst, generation := g.manifestCache.GetTag(ctx, dbRepo, tagName)
+	if dgst != "" {
+		dbManifest = g.manifestCache.Get(ctx, dbRepo, dgst)
 	}
 
-	// at the DB level a tag has a FK to manifests, so a tag cannot exist unless it points to an existing manifest
-	if dbManifest == nil {
-		return nil, "", distribution.ErrTagUnknown{Tag: tagName}
+	if dbManifest != nil {
+		l.Info("getting manifest by tag from cache")
+	} else {
+		l.Info("getting manifest by tag from database")
+		dbManifest, err = g.FindManifestByTagName(ctx, dbRepo, tagName)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// at the DB level a tag has a FK to manifests, so a tag cannot exist unless it points to an existing manifest
+		if dbManifest == nil {
+			return nil, "", distribution.ErrTagUnknown{Tag: tagName}
+		}
+
+		g.manifestCache.Set(ctx, dbRepo, dbManifest)
+		// not cached if the tag was invalidated since its generation was read, as the digest may be stale by now
+		g.manifestCache.SetTag(ctx, dbRepo, tagName, dbManifest.Digest, generation)
 	}
 
 	if etagMatch(g.req, dbManifest.Digest.String()) {
@@ -365,6 +544,7 @@ func (g *dbManifestGetter) GetByTag(ctx context.Context, tagName string) (distri
 	if err != nil {
 		return nil, "", err
 	}
+	g.manifest = dbManifest
 
 	return manifest, dbManifest.Digest, nil
 }
@@ -388,6 +568,12 @@ func (g *dbManifestGetter) GetByDigest(ctx context.Context, dgst digest.Digest)
 			Revision: dgst,
 		}
 	}
+
+	if dbManifest := g.manifestCache.Get(ctx, dbRepo, dgst); dbManifest != nil {
+		l.Info("getting manifest by digest from cache")
+		g.manifest = dbManifest
+		return dbManifestToManifest(dbManifest)
+	}
 	l.Info("getting manifest by digest from database")
 
 	// Find manifest by its digest
@@ -401,6 +587,8 @@ func (g *dbManifestGetter) GetByDigest(ctx context.Context, dgst digest.Digest)
 			Revision: dgst,
 		}
 	}
+	g.manifestCache.Set(ctx, dbRepo, dbManifest)
+	g.manifest = dbManifest
 
 	return dbManifestToManifest(dbManifest)
 }
@@ -459,10 +647,29 @@ type fsManifestWriter struct {
 	ms      distribution.ManifestService
 	ts      distribution.TagService
 	options []distribution.ManifestServiceOption
+	// precondition is the tag precondition to check before tagging, if any. It is not set when mirroring database
+	// writes, as the precondition was already checked against the database.
+	precondition *tagPrecondition
 }
 
 func newFSManifestWriter(imh *manifestHandler) (*fsManifestWriter, error) {
-	manifestService, err := imh.Repository.Manifests(imh)
+	w, err := newFSManifestWriterForRepository(imh, imh.Repository)
+	if err != nil {
+		return nil, err
+	}
+	w.precondition = imh.tagPrecondition
+
+	return w, nil
+}
+
+// newFSManifestWriterForRepository creates a manifest writer for the filesystem metadata of repo, which may differ from
+// the request repository when mirroring database writes.
+func newFSManifestWriterForRepository(imh *manifestHandler, repo distribution.Repository) (*fsManifestWriter, error) {
+	payloadSizeLimit, err := imh.manifestPayloadSizeLimit()
+	if err != nil {
+		return nil, err
+	}
+	manifestService, err := repo.Manifests(imh, storage.WithManifestPayloadSizeLimit(payloadSizeLimit))
 	if err != nil {
 		return nil, err
 	}
@@ -473,7 +680,7 @@ func newFSManifestWriter(imh *manifestHandler) (*fsManifestWriter, error) {
 	}
 
 	return &fsManifestWriter{
-		ts:      imh.Repository.Tags(imh),
+		ts:      repo.Tags(imh),
 		ms:      manifestService,
 		options: options,
 	}, nil
@@ -486,6 +693,19 @@ func (p *fsManifestWriter) Put(imh *manifestHandler, mfst distribution.Manifest)
 }
 
 func (p *fsManifestWriter) Tag(imh *manifestHandler, _ distribution.Manifest, tag string, desc distribution.Descriptor) error {
+	if err := fsCheckTagPrecondition(imh, p.ts, tag, p.precondition); err != nil {
+		return err
+	}
+	if datastore.IsImmutableTagName(tag, imh.immutableTagPatterns()) {
+		current, err := p.ts.Get(imh, tag)
+		if err != nil && !errors.As(err, &distribution.ErrTagUnknown{}) {
+			return err
+		}
+		if err == nil && current.Digest != desc.Digest {
+			return datastore.ErrTagImmutable
+		}
+	}
+
 	return p.ts.Tag(imh, tag, desc)
 }
 
@@ -511,7 +731,7 @@ func (p *dbManifestWriter) Tag(imh *manifestHandler, mfst distribution.Manifest,
 	// To be removed on completion of: https://gitlab.com/groups/gitlab-org/-/epics/9050
 	repoCache := getRepoCache(imh)
 
-	if err := dbTagManifest(imh, imh.db, repoCache, imh.Digest, imh.Tag, repoName); err != nil {
+	if err := dbTagManifest(imh, imh.db, repoCache, getManifestCache(imh.App), imh.Digest, imh.Tag, repoName, imh.immutableTagPatterns(), imh.tagPrecondition); err != nil {
 		if errors.Is(err, datastore.ErrManifestNotFound) {
 			// If online GC was already reviewing the manifest that we want to tag, and that manifest had no
 			// tags before the review start, the API is unable to stop the GC from deleting the manifest (as
@@ -524,7 +744,7 @@ func (p *dbManifestWriter) Tag(imh *manifestHandler, mfst distribution.Manifest,
 			if err = p.Put(imh, mfst); err != nil {
 				return fmt.Errorf("failed to recreate manifest in database: %w", err)
 			}
-			if err = dbTagManifest(imh, imh.db, repoCache, imh.Digest, imh.Tag, repoName); err != nil {
+			if err = dbTagManifest(imh, imh.db, repoCache, getManifestCache(imh.App), imh.Digest, imh.Tag, repoName, imh.immutableTagPatterns(), imh.tagPrecondition); err != nil {
 				return fmt.Errorf("failed to create tag in database after manifest recreate: %w", err)
 			}
 		} else {
@@ -532,6 +752,18 @@ func (p *dbManifestWriter) Tag(imh *manifestHandler, mfst distribution.Manifest,
 		}
 	}
 
+	// Referrers tag schema indexes are a fallback for the subject field, so failing to index them must not fail pushes.
+	if subject, ok := referrersTagSubject(tag); ok {
+		if ml, ok := mfst.(*manifestlist.DeserializedManifestList); ok {
+			if err := dbIndexReferrersTag(imh, ml, subject); err != nil {
+				log.GetLogger(log.WithContext(imh)).WithError(err).WithFields(log.Fields{
+					"tag_name":       tag,
+					"subject_digest": subject,
+				}).Error("failed to index referrers tag schema image index")
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -621,6 +853,13 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 	l := log.GetLogger(log.WithContext(imh))
 	l.Debug("PutImageManifest")
 
+	if imh.Tag != "" {
+		if err := newTagProtection(imh, imh.Repository.Named().Name()).check(imh.Tag); err != nil {
+			imh.Errors = append(imh.Errors, err)
+			return
+		}
+	}
+
 	var jsonBuf bytes.Buffer
 	if err := copyFullPayload(imh, w, r, &jsonBuf, maxManifestBodySize, "image manifest PUT"); err != nil {
 		// copyFullPayload reports the error if necessary
@@ -647,6 +886,11 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 		}
 	} else if imh.Tag != "" {
 		imh.Digest = desc.Digest
+		// preconditions only apply to tags, the header is ignored when pushing by digest
+		if imh.tagPrecondition, err = parseTagPrecondition(r); err != nil {
+			imh.Errors = append(imh.Errors, err)
+			return
+		}
 	} else {
 		imh.Errors = append(imh.Errors, v2.ErrorCodeTagInvalid.WithDetail("no tag or digest specified"))
 		return
@@ -694,6 +938,13 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 		l.WithError(err).Error("dispatching manifest push to listener")
 	}
 
+	imh.recordAudit(audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactManifest, Digest: imh.Digest, MediaType: desc.MediaType})
+	imh.recordPush()
+	imh.recordManifestNamespacePush()
+	if imh.Tag != "" {
+		imh.recordAudit(audit.Record{Action: audit.ActionPush, Artifact: audit.ArtifactTag, Tag: imh.Tag, Digest: imh.Digest})
+	}
+
 	// Construct a canonical url for the uploaded manifest.
 	ref, err := reference.WithDigest(imh.Repository.Named(), imh.Digest)
 	if err != nil {
@@ -712,6 +963,14 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Location", location)
 	w.Header().Set("Docker-Content-Digest", imh.Digest.String())
 
+	// The subject is only indexed in the database. This tells clients that they don't need to maintain a referrers tag
+	// schema image index for it, see https://github.com/opencontainers/distribution-spec/blob/v1.1.0/spec.md#pushing-manifests-with-subject
+	if ocim, ok := manifest.(distribution.ManifestOCI); ok && imh.useDatabase {
+		if subject := ocim.Subject(); subject.Digest != "" {
+			w.Header().Set("OCI-Subject", subject.Digest.String())
+		}
+	}
+
 	w.WriteHeader(http.StatusCreated)
 
 	l.WithFields(log.Fields{
@@ -740,6 +999,10 @@ func (imh *manifestHandler) appendPutError(err error) {
 		imh.Errors = append(imh.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
 		return
 	}
+	if errors.Is(err, datastore.ErrTagImmutable) {
+		imh.Errors = append(imh.Errors, v2.ErrorCodeTagImmutable.WithDetail(fmt.Sprintf("tag %q is immutable", imh.Tag)))
+		return
+	}
 
 	switch err := err.(type) {
 	case distribution.ErrManifestVerification:
@@ -755,6 +1018,8 @@ func (imh *manifestHandler) appendPutError(err error) {
 				imh.Errors = append(imh.Errors, v2.ErrorCodeManifestReferenceLimit.WithDetail(err))
 			case distribution.ErrManifestPayloadSizeExceedsLimit:
 				imh.Errors = append(imh.Errors, v2.ErrorCodeManifestPayloadSizeLimit.WithDetail(err.Error()))
+			case distribution.ErrManifestReferencesVerificationTimeout:
+				imh.Errors = append(imh.Errors, errcode.ErrorCodeUnavailable.WithDetail(verificationError.Error()))
 			default:
 				if errors.Is(verificationError, digest.ErrDigestInvalidFormat) {
 					imh.Errors = append(imh.Errors, v2.ErrorCodeDigestInvalid)
@@ -788,7 +1053,9 @@ const (
 	manifestTagGCLockTimeout  = 5 * time.Second
 )
 
-func dbTagManifest(ctx context.Context, db datastore.Handler, cache datastore.RepositoryCache, dgst digest.Digest, tagName, path string) error {
+// dbTagManifest tags a manifest, creating the tag or updating it to point to the manifest. If precondition is not nil,
+// the tag is only created or updated if it satisfies it.
+func dbTagManifest(ctx context.Context, db datastore.Handler, cache datastore.RepositoryCache, manifestCache datastore.ManifestCache, dgst digest.Digest, tagName, path string, immutablePatterns []string, precondition *tagPrecondition) error {
 	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": path, "manifest_digest": dgst, "tag_name": tagName})
 	l.Debug("tagging manifest")
 
@@ -808,6 +1075,14 @@ func dbTagManifest(ctx context.Context, db datastore.Handler, cache datastore.Re
 		return fmt.Errorf("manifest %s not found in database", dgst)
 	}
 
+	// Immutable tag patterns can be set through the configuration file or the GitLab V1 API. The latter are persisted in
+	// the database, so we need to merge both.
+	pp, err := datastore.NewImmutableTagPatternStore(db).FindByRepository(ctx, dbRepo)
+	if err != nil {
+		return err
+	}
+	immutablePatterns = append(immutablePatterns, pp...)
+
 	l.Debug("creating tag")
 
 	// We need to find and lock a GC manifest task that is related with the manifest that we're about to tag. This
@@ -831,7 +1106,20 @@ func dbTagManifest(ctx context.Context, db datastore.Handler, cache datastore.Re
 		return err
 	}
 
-	tagStore := datastore.NewTagStore(tx)
+	// Grab the manifest the tag currently points to (if any) within the transaction, so that the transition can be
+	// recorded in the tag history. If there is a precondition, the tag is locked until the transaction ends, so that
+	// it can not change after being checked.
+	var oldTag *models.Tag
+	if precondition != nil {
+		oldTag, err = dbCheckTagPrecondition(ctx, datastore.NewRepositoryStore(tx), dbRepo, tagName, precondition)
+	} else {
+		oldTag, err = datastore.NewRepositoryStore(tx).FindTagByName(ctx, dbRepo, tagName)
+	}
+	if err != nil {
+		return err
+	}
+
+	tagStore := datastore.NewTagStore(tx, datastore.WithImmutableTagPatterns(immutablePatterns...))
 	if err := tagStore.CreateOrUpdate(ctx, &models.Tag{
 		Name:         tagName,
 		NamespaceID:  dbRepo.NamespaceID,
@@ -841,10 +1129,97 @@ func dbTagManifest(ctx context.Context, db datastore.Handler, cache datastore.Re
 		return err
 	}
 
+	var oldManifestID int64
+	if oldTag != nil {
+		oldManifestID = oldTag.ManifestID
+	}
+	if oldManifestID != dbManifest.ID {
+		ths := datastore.NewTagHistoryStore(tx)
+		if err := ths.RecordTransition(ctx, dbRepo, tagName, oldManifestID, dbManifest.ID, tagHistoryActor(ctx)); err != nil {
+			return err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("committing database transaction: %w", err)
 	}
 	cache.InvalidateSize(ctx, dbRepo)
+	// the tag may have pointed to a different manifest before
+	manifestCache.InvalidateTags(ctx, dbRepo, tagName)
+	return nil
+}
+
+// referrersTagRegexp matches the tags of the OCI referrers tag schema, which clients push when a registry does not
+// support the referrers API. These tags point to an image index listing the referrers of the manifest whose digest
+// is encoded in the tag. Tags of digests other than sha256 are truncated, so the digest can't be recovered from them.
+var referrersTagRegexp = regexp.MustCompile(`^sha256-([a-f0-9]{64})$`)
+
+// referrersTagSubject returns the digest of the subject encoded in tag, if it follows the referrers tag schema.
+func referrersTagSubject(tag string) (digest.Digest, bool) {
+	m := referrersTagRegexp.FindStringSubmatch(tag)
+	if m == nil {
+		return "", false
+	}
+	return digest.NewDigestFromEncoded(digest.SHA256, m[1]), true
+}
+
+// dbIndexReferrersTag indexes the manifests listed in a referrers tag schema image index as referrers of the manifest
+// with digest subject, so that they are found along those that declare their subject directly. These associations are
+// stored apart from the manifests subject, which only ever reflects their payload, so deleting the subject does not
+// cascade to them. Listed manifests that declare a subject themselves are skipped, as is everything if the subject
+// does not exist in the repository.
+func dbIndexReferrersTag(imh *manifestHandler, ml *manifestlist.DeserializedManifestList, subject digest.Digest) error {
+	repoPath := imh.Repository.Named().Name()
+	l := log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{"repository": repoPath, "subject_digest": subject})
+
+	rStore := datastore.NewRepositoryStore(imh.db, datastore.WithRepositoryCache(getRepoCache(imh)))
+	r, err := rStore.FindByPath(imh, repoPath)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return fmt.Errorf("repository %q not found in database", repoPath)
+	}
+
+	dbSubject, err := rStore.FindManifestByDigest(imh, r, subject)
+	if err != nil {
+		return err
+	}
+	if dbSubject == nil {
+		l.Info("subject of referrers tag schema image index not found, skipping indexing")
+		return nil
+	}
+
+	mStore := datastore.NewManifestStore(imh.db)
+	var indexed []digest.Digest
+	for _, desc := range ml.References() {
+		if desc.Digest == subject {
+			continue
+		}
+		m, err := rStore.FindManifestByDigest(imh, r, desc.Digest)
+		if err != nil {
+			return err
+		}
+		if m == nil || m.SubjectID.Valid {
+			continue
+		}
+		ok, err := mStore.AssociateTagSchemaReferrer(imh, m, dbSubject)
+		if err != nil {
+			// the referrer or the subject may have been deleted by the online GC in the meantime
+			if errors.Is(err, datastore.ErrRefManifestNotFound) {
+				continue
+			}
+			return fmt.Errorf("indexing referrer %s: %w", desc.Digest, err)
+		}
+		if ok {
+			indexed = append(indexed, desc.Digest)
+		}
+	}
+
+	if len(indexed) > 0 {
+		l.WithFields(log.Fields{"referrers": len(indexed)}).Info("indexed referrers from referrers tag schema image index")
+	}
+
 	return nil
 }
 
@@ -852,11 +1227,25 @@ func dbPutManifestOCI(imh *manifestHandler, manifest *ocischema.DeserializedMani
 	repoReader := datastore.NewRepositoryStore(imh.App.db, datastore.WithRepositoryCache(getRepoCache(imh)))
 	repoPath := imh.Repository.Named().Name()
 
+	payloadSizeLimit, err := imh.manifestPayloadSizeLimit()
+	if err != nil {
+		return err
+	}
+
+	// blobs unknown to the repository may be mounted from other repositories, which validation must take into account
+	mounts, err := dbFindManifestBlobMounts(imh, repoReader, manifest)
+	if err != nil {
+		return err
+	}
+
 	v := validation.NewOCIValidator(
 		&datastore.RepositoryManifestService{RepositoryReader: repoReader, RepositoryPath: repoPath},
-		&datastore.RepositoryBlobService{RepositoryReader: repoReader, RepositoryPath: repoPath},
+		mountableBlobStatter{
+			BlobStatter: &datastore.RepositoryBlobService{RepositoryReader: repoReader, RepositoryPath: repoPath},
+			mounts:      mounts,
+		},
 		imh.App.manifestRefLimit,
-		imh.App.manifestPayloadSizeLimit,
+		payloadSizeLimit,
 		imh.App.manifestURLs,
 	)
 
@@ -864,18 +1253,32 @@ func dbPutManifestOCI(imh *manifestHandler, manifest *ocischema.DeserializedMani
 		return err
 	}
 
-	return dbPutManifestV2(imh, manifest, payload, false)
+	return dbPutManifestV2(imh, manifest, payload, false, mounts)
 }
 
 func dbPutManifestSchema2(imh *manifestHandler, manifest *schema2.DeserializedManifest, payload []byte) error {
 	repoReader := datastore.NewRepositoryStore(imh.App.db, datastore.WithRepositoryCache(getRepoCache(imh)))
 	repoPath := imh.Repository.Named().Name()
 
+	payloadSizeLimit, err := imh.manifestPayloadSizeLimit()
+	if err != nil {
+		return err
+	}
+
+	// blobs unknown to the repository may be mounted from other repositories, which validation must take into account
+	mounts, err := dbFindManifestBlobMounts(imh, repoReader, manifest)
+	if err != nil {
+		return err
+	}
+
 	v := validation.NewSchema2Validator(
 		&datastore.RepositoryManifestService{RepositoryReader: repoReader, RepositoryPath: repoPath},
-		&datastore.RepositoryBlobService{RepositoryReader: repoReader, RepositoryPath: repoPath},
+		mountableBlobStatter{
+			BlobStatter: &datastore.RepositoryBlobService{RepositoryReader: repoReader, RepositoryPath: repoPath},
+			mounts:      mounts,
+		},
 		imh.App.manifestRefLimit,
-		imh.App.manifestPayloadSizeLimit,
+		payloadSizeLimit,
 		imh.App.manifestURLs,
 	)
 
@@ -883,10 +1286,13 @@ func dbPutManifestSchema2(imh *manifestHandler, manifest *schema2.DeserializedMa
 		return err
 	}
 
-	return dbPutManifestV2(imh, manifest, payload, false)
+	return dbPutManifestV2(imh, manifest, payload, false, mounts)
 }
 
-func dbPutManifestV2(imh *manifestHandler, mfst distribution.ManifestV2, payload []byte, nonConformant bool) error {
+// dbPutManifestV2 creates the manifest in the database, if not found, along with the repository, if needed. Blobs
+// mounted from other repositories, as found by dbFindManifestBlobMounts, are linked to the repository in the same
+// transaction.
+func dbPutManifestV2(imh *manifestHandler, mfst distribution.ManifestV2, payload []byte, nonConformant bool, mounts map[digest.Digest]blobMount) error {
 	repoPath := imh.Repository.Named().Name()
 
 	l := log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{
@@ -895,14 +1301,24 @@ func dbPutManifestV2(imh *manifestHandler, mfst distribution.ManifestV2, payload
 		"schema_version":  mfst.Version().SchemaVersion,
 	})
 
+	tx, err := imh.App.db.BeginTx(imh, nil)
+	if err != nil {
+		return fmt.Errorf("beginning database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	// create or find target repository
-	rStore := datastore.NewRepositoryStore(imh.App.db, datastore.WithRepositoryCache(getRepoCache(imh)))
+	rStore := datastore.NewRepositoryStore(tx, datastore.WithRepositoryCache(getRepoCache(imh)))
 	dbRepo, err := rStore.CreateOrFindByPath(imh, repoPath)
 	if err != nil {
 		return err
 	}
 	l.Info("putting manifest")
 
+	if err := dbMountManifestBlobs(imh, rStore, dbRepo, mounts); err != nil {
+		return err
+	}
+
 	// Find the config now to ensure that the config's blob is associated with the repository.
 	dbCfgBlob, err := dbFindRepositoryBlob(imh.Context, rStore, mfst.Config(), dbRepo.Path)
 	if err != nil {
@@ -965,13 +1381,25 @@ func dbPutManifestV2(imh *manifestHandler, mfst distribution.ManifestV2, payload
 				m.SubjectID.Int64 = dbSubject.ID
 				m.SubjectID.Valid = true
 			}
+
+			// OCI artifacts can have arbitrary artifact and config media types, so we register them on demand
+			if at := ocim.ArtifactType(); at != "" {
+				if err := dbRegisterArtifactMediaTypes(imh, at, cfg.MediaType); err != nil {
+					return err
+				}
+				m.ArtifactType.String = at
+				m.ArtifactType.Valid = true
+			}
 		}
 
+		// record the name and version of Helm charts, so that they can be listed without parsing the manifest
+		datastore.SetHelmChartMetadata(m, mfst)
+
 		// check if the manifest references non-distributable layers and mark it as such on the DB
 		ll := mfst.DistributableLayers()
 		m.NonDistributableLayers = len(ll) < len(mfst.Layers())
 
-		mStore := datastore.NewManifestStore(imh.App.db)
+		mStore := datastore.NewManifestStore(tx)
 		// Use CreateOrFind to prevent race conditions while pushing the same manifest with digest for different tags
 		if err := mStore.CreateOrFind(imh, m); err != nil {
 			return err
@@ -999,6 +1427,52 @@ func dbPutManifestV2(imh *manifestHandler, mfst distribution.ManifestV2, payload
 				return err
 			}
 		}
+
+		// index the manifest annotations, so that manifests can be searched by them
+		if err := datastore.NewManifestAnnotationStore(tx).Create(imh.Context, dbManifest, datastore.PayloadAnnotations(payload)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing database transaction: %w", err)
+	}
+	recordBlobMountAudits(imh, mounts)
+
+	return nil
+}
+
+// maxMediaTypeLength is the maximum length of a media type, as enforced by the database schema.
+const maxMediaTypeLength = 255
+
+// mediaTypeRegexp matches media types as defined by RFC 6838, without parameters. Each name is at most 127 characters
+// long, which keeps media types within maxMediaTypeLength.
+var mediaTypeRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]{0,126}/[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]{0,126}$`)
+
+// dbRegisterArtifactMediaTypes validates the given media types and registers those that are not registered yet in the
+// database. Media types are only registered if allowed by the validation.manifests.artifactmediatypes configuration,
+// otherwise datastore.ErrUnknownMediaType is returned.
+func dbRegisterArtifactMediaTypes(imh *manifestHandler, mediaTypes ...string) error {
+	mtStore := datastore.NewMediaTypeStore(imh.App.db)
+
+	for _, mt := range mediaTypes {
+		if !mediaTypeRegexp.MatchString(mt) {
+			return v2.ErrorCodeManifestInvalid.WithDetail(fmt.Sprintf("invalid artifact media type %q", mt))
+		}
+		exists, err := mtStore.Exists(imh.Context, mt)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if imh.App.artifactMediaTypes == nil || !imh.App.artifactMediaTypes.MatchString(mt) {
+			return datastore.ErrUnknownMediaType{MediaType: mt}
+		}
+		if _, err := mtStore.CreateOrFind(imh.Context, mt); err != nil {
+			return err
+		}
+		log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{"media_type": mt}).Info("artifact media type registered")
 	}
 
 	return nil
@@ -1018,6 +1492,24 @@ func layerMediaTypeExists(imh *manifestHandler, mt string) bool {
 		return true
 	}
 
+	// Layers encrypted with ocicrypt use the media type of their plain counterparts with an `+encrypted` suffix, so we
+	// register them on demand instead of falling back to the generic media type of the blob. Only those of known plain
+	// layer media types are, which keeps their number bounded.
+	if ocischema.IsEncryptedLayer(mt) && mediaTypeRegexp.MatchString(mt) {
+		known, err := mtStore.Exists(imh.Context, ocischema.DecryptedLayerMediaType(mt))
+		if err != nil {
+			l.WithError(err).Error("error checking for existence of decrypted layer media type")
+			return false
+		}
+		if known {
+			if _, err := mtStore.CreateOrFind(imh.Context, mt); err != nil {
+				l.WithError(err).Warn("error registering encrypted layer media type")
+				return false
+			}
+			return true
+		}
+	}
+
 	l.Warn("unknown layer media type")
 
 	return false
@@ -1085,6 +1577,11 @@ func dbPutManifestList(imh *manifestHandler, manifestList *manifestlist.Deserial
 	l.Debug("putting manifest list")
 
 	rStore := datastore.NewRepositoryStore(imh.App.db, datastore.WithRepositoryCache(getRepoCache(imh)))
+	payloadSizeLimit, err := imh.manifestPayloadSizeLimit()
+	if err != nil {
+		return err
+	}
+
 	v := validation.NewManifestListValidator(
 		&datastore.RepositoryManifestService{
 			RepositoryReader: rStore,
@@ -1092,7 +1589,8 @@ func dbPutManifestList(imh *manifestHandler, manifestList *manifestlist.Deserial
 		},
 		&datastore.RepositoryBlobService{RepositoryReader: rStore, RepositoryPath: repoPath},
 		imh.App.manifestRefLimit,
-		imh.App.manifestPayloadSizeLimit,
+		payloadSizeLimit,
+		validation.WithConcurrentReferenceVerification(imh.App.manifestRefConcurrency, imh.App.manifestRefTimeout),
 	)
 
 	if err := v.Validate(imh, manifestList); err != nil {
@@ -1183,6 +1681,11 @@ func dbPutManifestList(imh *manifestHandler, manifestList *manifestlist.Deserial
 		}
 	}
 
+	// index the annotations of OCI image indexes, so that they can be searched by them
+	if err := datastore.NewManifestAnnotationStore(tx).Create(ctx, ml, datastore.PayloadAnnotations(payload)); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit database transaction: %w", err)
 	}
@@ -1190,6 +1693,80 @@ func dbPutManifestList(imh *manifestHandler, manifestList *manifestlist.Deserial
 	return nil
 }
 
+// immutableTagPatterns returns the immutable tag patterns that apply to the target repository according to the
+// policy configuration.
+func (imh *manifestHandler) immutableTagPatterns() []string {
+	if imh.App == nil {
+		return nil
+	}
+	return immutableTagPatternsForRepository(imh.App.Config.Policy.Repository.ImmutableTags, imh.Repository.Named().Name())
+}
+
+// immutableTagPatternsForRepository returns the tag patterns of all rules whose repository pattern matches path.
+func immutableTagPatternsForRepository(rules []configuration.ImmutableTagRule, path string) []string {
+	var pp []string
+	for _, r := range rules {
+		if r.Repository != "" {
+			if ok, err := gopath.Match(r.Repository, path); err != nil || !ok {
+				continue
+			}
+		}
+		pp = append(pp, r.Patterns...)
+	}
+	return pp
+}
+
+// configManifestPayloadSizeLimit returns the maximum payload size of manifests pushed to the repository with the given
+// path, as set in the first matching configuration rule, or in the global configuration otherwise. Zero means no limit.
+func (app *App) configManifestPayloadSizeLimit(path string) int {
+	for _, r := range app.manifestPayloadSizeLimits {
+		if r.Repository != "" {
+			if ok, err := gopath.Match(r.Repository, path); err != nil || !ok {
+				continue
+			}
+		}
+		return r.Limit
+	}
+	return app.manifestPayloadSizeLimit
+}
+
+// effectiveManifestPayloadSizeLimit returns the maximum payload size of manifests pushed to the repository with the
+// given path and settings. A limit set in the repository settings takes precedence over the configuration. Zero means
+// no limit.
+func (app *App) effectiveManifestPayloadSizeLimit(path string, rs *models.RepositorySettings) int {
+	if !app.Config.Validation.Enabled {
+		return 0
+	}
+	if rs != nil && rs.ManifestPayloadSizeLimit.Valid {
+		return int(rs.ManifestPayloadSizeLimit.Int64)
+	}
+	return app.configManifestPayloadSizeLimit(path)
+}
+
+// manifestPayloadSizeLimit returns the maximum payload size of manifests pushed to the repository. With the metadata
+// database, a limit set in the repository settings takes precedence over the configuration. Zero means no limit.
+func (imh *manifestHandler) manifestPayloadSizeLimit() (int, error) {
+	path := imh.Repository.Named().Name()
+	if !imh.App.Config.Validation.Enabled || !imh.useDatabase {
+		return imh.App.effectiveManifestPayloadSizeLimit(path, nil), nil
+	}
+
+	r, err := datastore.NewRepositoryStore(imh.db, datastore.WithRepositoryCache(getRepoCache(imh))).FindByPath(imh, path)
+	if err != nil {
+		return 0, err
+	}
+	// the repository does not exist yet if this is its first push, in which case it has no settings either
+	var rs *models.RepositorySettings
+	if r != nil {
+		rs, err = datastore.NewRepositorySettingsStore(imh.db).FindByRepository(imh, r)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return imh.App.effectiveManifestPayloadSizeLimit(path, rs), nil
+}
+
 // applyResourcePolicy checks whether the resource class matches what has
 // been authorized and allowed by the policy configuration.
 func (imh *manifestHandler) applyResourcePolicy(manifest distribution.Manifest) error {
@@ -1239,7 +1816,7 @@ func (imh *manifestHandler) applyResourcePolicy(manifest distribution.Manifest)
 
 	var foundResource bool
 	for _, r := range resources {
-		if r.Name == n {
+		if r.Covers(n) {
 			if r.Class == "" {
 				r.Class = imageClass
 			}
@@ -1271,11 +1848,16 @@ func dbPutBuildkitIndex(imh *manifestHandler, ml *manifestlist.DeserializedManif
 		return fmt.Errorf("converting buildkit index to manifest: %w", err)
 	}
 
+	payloadSizeLimit, err := imh.manifestPayloadSizeLimit()
+	if err != nil {
+		return err
+	}
+
 	v := validation.NewOCIValidator(
 		&datastore.RepositoryManifestService{RepositoryReader: repoReader, RepositoryPath: repoPath},
 		&datastore.RepositoryBlobService{RepositoryReader: repoReader, RepositoryPath: repoPath},
 		imh.App.manifestRefLimit,
-		imh.App.manifestPayloadSizeLimit,
+		payloadSizeLimit,
 		imh.App.manifestURLs,
 	)
 
@@ -1287,7 +1869,7 @@ func dbPutBuildkitIndex(imh *manifestHandler, ml *manifestlist.DeserializedManif
 	// Within dbPutManifestOCIOrSchema2 we use this value for the `manifests.payload` column and source the value for
 	// the `manifests.digest` column from `imh.Digest`, and not from `m`. Therefore, we keep behavioral consistency for
 	// the outside world by preserving the index payload and digest while storing things internally as an OCI manifest.
-	return dbPutManifestV2(imh, m, payload, true)
+	return dbPutManifestV2(imh, m, payload, true, nil)
 }
 
 const (
@@ -1298,18 +1880,21 @@ const (
 // dbDeleteManifest replicates the DeleteManifest action in the metadata database. This method doesn't actually delete
 // a manifest from the database (that's a task for GC, if a manifest is unreferenced), it only deletes the record that
 // associates the manifest with a digest d with the repository with path repoPath. Any tags that reference the manifest
-// within the repository are also deleted.
-func dbDeleteManifest(ctx context.Context, db datastore.Handler, cache datastore.RepositoryCache, repoPath string, d digest.Digest) error {
+// within the repository are also deleted. If cascadeReferrers is true, all manifests that reference the manifest as
+// their subject, directly or through other referrers, are explicitly deleted as well, and their digests returned.
+// Otherwise, the database deletes them implicitly. If any of the tags to delete, those of referrers included, is
+// protected by protection, nothing is deleted. Deleted manifests and tags are invalidated in manifestCache.
+func dbDeleteManifest(ctx context.Context, db datastore.Handler, cache datastore.RepositoryCache, manifestCache datastore.ManifestCache, repoPath string, d digest.Digest, cascadeReferrers bool, protection *tagProtection) ([]digest.Digest, error) {
 	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": repoPath, "digest": d})
 	l.Debug("deleting manifest from repository in database")
 
 	rStore := datastore.NewRepositoryStore(db, datastore.WithRepositoryCache(cache))
 	r, err := rStore.FindByPath(ctx, repoPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if r == nil {
-		return fmt.Errorf("repository not found in database: %w", err)
+		return nil, fmt.Errorf("repository not found in database: %w", err)
 	}
 
 	// We need to find the manifest first and then lookup for any manifest it references (if it's a manifest list). This
@@ -1317,37 +1902,56 @@ func dbDeleteManifest(ctx context.Context, db datastore.Handler, cache datastore
 	// https://gitlab.com/gitlab-org/container-registry/-/blob/master/docs-gitlab/db/online-garbage-collection.md#deleting-the-last-referencing-manifest-list
 	m, err := rStore.FindManifestByDigest(ctx, r, d)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if m == nil {
-		return datastore.ErrManifestNotFound
+		return nil, datastore.ErrManifestNotFound
 	}
 
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create database transaction: %w", err)
+		return nil, fmt.Errorf("failed to create database transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	switch m.MediaType {
-	case manifestlist.MediaTypeManifestList, v1.MediaTypeImageIndex:
-		mStore := datastore.NewManifestStore(tx)
-		mm, err := mStore.References(ctx, m)
-		if err != nil {
-			return err
-		}
+	rStore = datastore.NewRepositoryStore(tx, datastore.WithRepositoryCache(cache))
 
-		// This should never happen, as it's not possible to delete a child manifest if it's referenced by a list, which
-		// means that we'll always have at least one child manifest here. Nevertheless, log error if this ever happens.
-		if len(mm) == 0 {
-			l.Error("stored manifest list has no references")
-			break
-		}
-		ids := make([]int64, 0, len(mm))
-		for _, m := range mm {
-			ids = append(ids, m.ID)
+	// Referrers are found even if not cascading explicitly, as the database deletes them along with their subject
+	// regardless, so their GC tasks must be locked, their tags checked against protection and their cached manifests
+	// and tags invalidated.
+	referrers, err := dbFindReferrers(ctx, rStore, r, m)
+	if err != nil {
+		return nil, err
+	}
+
+	// The GC tasks of referrers are locked as well, as they are about to be deleted along with their subject.
+	ids := make([]int64, 0, len(referrers))
+	for _, ref := range referrers {
+		ids = append(ids, ref.ID)
+	}
+	for _, m := range append(models.Manifests{m}, referrers...) {
+		switch m.MediaType {
+		case manifestlist.MediaTypeManifestList, v1.MediaTypeImageIndex:
+			mStore := datastore.NewManifestStore(tx)
+			mm, err := mStore.References(ctx, m)
+			if err != nil {
+				return nil, err
+			}
+
+			// This should never happen, as it's not possible to delete a child manifest if it's referenced by a list,
+			// which means that we'll always have at least one child manifest here. Nevertheless, log error if this ever
+			// happens.
+			if len(mm) == 0 {
+				l.WithFields(log.Fields{"list_digest": m.Digest}).Error("stored manifest list has no references")
+				continue
+			}
+			for _, m := range mm {
+				ids = append(ids, m.ID)
+			}
 		}
+	}
 
+	if len(ids) > 0 {
 		// Prevent long running transactions by setting an upper limit of manifestDeleteGCLockTimeout. If the GC is
 		// holding the lock of a related review record, the processing there should be fast enough to avoid this.
 		// Regardless, we should not let transactions open (and clients waiting) for too long. If this sensible timeout
@@ -1358,24 +1962,112 @@ func dbDeleteManifest(ctx context.Context, db datastore.Handler, cache datastore
 
 		mts := datastore.NewGCManifestTaskStore(tx)
 		if _, err := mts.FindAndLockNBefore(ctx, r.NamespaceID, r.ID, ids, time.Now().Add(manifestDeleteGCReviewWindow)); err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	// Tags are deleted along with the manifests that they point to, so we must find them beforehand to check that none
+	// is protected and to invalidate their cached references. The manifests and tags are locked, so that no tags can be
+	// pointed to them until the transaction ends. Referrers are found again while locked, in case any was pushed since.
+	referrers, tagNames, err := dbLockManifestTags(ctx, rStore, r, m)
+	if err != nil {
+		return nil, err
+	}
+	if err := protection.check(tagNames...); err != nil {
+		return nil, err
+	}
+
+	// Referrers are deleted before their subjects. Although the database would delete them along with their subject,
+	// doing so explicitly allows reporting them to the caller.
+	deleted := make([]digest.Digest, 0, len(referrers))
+	if cascadeReferrers {
+		for _, ref := range referrers {
+			found, err := rStore.DeleteManifest(ctx, r, ref.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("deleting referrer %s: %w", ref.Digest, err)
+			}
+			// the referrer may have been deleted by online GC in the meantime
+			if found {
+				deleted = append(deleted, ref.Digest)
+			}
 		}
 	}
 
-	rStore = datastore.NewRepositoryStore(tx, datastore.WithRepositoryCache(cache))
 	found, err := rStore.DeleteManifest(ctx, r, d)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !found {
-		return datastore.ErrManifestNotFound
+		return nil, datastore.ErrManifestNotFound
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit database transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit database transaction: %w", err)
 	}
+	invalidated := make([]digest.Digest, 0, len(referrers)+1)
+	for _, ref := range referrers {
+		invalidated = append(invalidated, ref.Digest)
+	}
+	manifestCache.Invalidate(ctx, r, append(invalidated, d)...)
+	manifestCache.InvalidateTags(ctx, r, tagNames...)
 
-	return nil
+	return deleted, nil
+}
+
+// dbLockManifestTags locks m and all manifests that reference it as their subject, directly or through other
+// referrers, along with their tags, until the end of the transaction of rStore. Each manifest is locked before its
+// referrers are read, so that no referrers can be added to it concurrently. Returns the referrers, sorted as by
+// dbFindReferrers, and the names of all tags.
+func dbLockManifestTags(ctx context.Context, rStore datastore.RepositoryStore, r *models.Repository, m *models.Manifest) (models.Manifests, []string, error) {
+	var referrers models.Manifests
+	var tagNames []string
+
+	queue := models.Manifests{m}
+	for len(queue) > 0 {
+		tt, err := rStore.LockManifestTags(ctx, r, queue[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, t := range tt {
+			tagNames = append(tagNames, t.Name)
+		}
+		mm, err := rStore.ManifestReferrers(ctx, r, queue[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		queue = append(queue[1:], mm...)
+		referrers = append(referrers, mm...)
+	}
+
+	for i, j := 0, len(referrers)-1; i < j; i, j = i+1, j-1 {
+		referrers[i], referrers[j] = referrers[j], referrers[i]
+	}
+
+	return referrers, tagNames, nil
+}
+
+// dbFindReferrers finds all manifests that reference m as their subject, either directly or through other referrers,
+// such as the signature of an SBOM. Referrers are sorted so that each one precedes its own subject.
+func dbFindReferrers(ctx context.Context, rStore datastore.RepositoryStore, r *models.Repository, m *models.Manifest) (models.Manifests, error) {
+	var referrers models.Manifests
+
+	// A manifest can only be pushed after its subject, so there can be no cycles.
+	queue := models.Manifests{m}
+	for len(queue) > 0 {
+		mm, err := rStore.ManifestReferrers(ctx, r, queue[0])
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue[1:], mm...)
+		referrers = append(referrers, mm...)
+	}
+
+	// referrers were found breadth-first, so reversing them places each one before its subject
+	for i, j := 0, len(referrers)-1; i < j; i, j = i+1, j-1 {
+		referrers[i], referrers[j] = referrers[j], referrers[i]
+	}
+
+	return referrers, nil
 }
 
 func (imh *manifestHandler) appendTagDeleteError(err error) {
@@ -1396,6 +2088,9 @@ func (imh *manifestHandler) deleteTag() error {
 
 	if !imh.useDatabase {
 		tagService := imh.Repository.Tags(imh)
+		if err := fsCheckTagPrecondition(imh, tagService, imh.Tag, imh.tagPrecondition); err != nil {
+			return err
+		}
 		if err := tagService.Untag(imh.Context, imh.Tag); err != nil {
 			return err
 		}
@@ -1408,83 +2103,237 @@ func (imh *manifestHandler) deleteTag() error {
 			repoCache = imh.repoCache
 		}
 
-		if err := dbDeleteTag(imh.Context, imh.db, repoCache, imh.Repository.Named().Name(), imh.Tag); err != nil {
+		if err := dbDeleteTag(imh.Context, imh.db, repoCache, getManifestCache(imh.App), imh.Repository.Named().Name(), imh.Tag, imh.tagPrecondition); err != nil {
 			return err
 		}
+		imh.mirrorFSUntag(imh.Tag)
 	}
 
 	if err := imh.queueBridge.TagDeleted(imh.Repository.Named(), imh.Tag); err != nil {
 		l.WithError(err).Error("dispatching tag delete to queue")
 	}
+	imh.recordAudit(audit.Record{Action: audit.ActionDelete, Artifact: audit.ArtifactTag, Tag: imh.Tag})
 
 	return nil
 }
 
-func (imh *manifestHandler) deleteManifest() error {
+func (imh *manifestHandler) deleteManifest(cascadeReferrers bool) error {
 	l := log.GetLogger(log.WithContext(imh))
 	l.Debug("DeleteImageManifest")
 
+	// a manifest can not be deleted along with its tags if any of them is protected
+	protection := newTagProtection(imh, imh.Repository.Named().Name())
+
+	var deletedReferrers []digest.Digest
 	if !imh.useDatabase {
 		manifests, err := imh.Repository.Manifests(imh)
 		if err != nil {
 			return err
 		}
 
-		err = manifests.Delete(imh, imh.Digest)
-		if err != nil {
-			return err
-		}
-
-		tagService := imh.Repository.Tags(imh)
-		referencedTags, err := tagService.Lookup(imh, distribution.Descriptor{Digest: imh.Digest})
-		if err != nil {
-			return err
+		var referrers []digest.Digest
+		if cascadeReferrers {
+			// Look for referrers using the undecorated repository, to avoid emitting a pull event for each manifest read.
+			repo, err := imh.App.registry.Repository(imh, imh.Repository.Named())
+			if err != nil {
+				return err
+			}
+			ms, err := repo.Manifests(imh)
+			if err != nil {
+				return err
+			}
+			if referrers, err = fsFindReferrers(imh, ms, imh.Digest); err != nil {
+				return err
+			}
 		}
 
-		for _, tag := range referencedTags {
-			if err = tagService.Untag(imh, tag); err != nil {
-				// ignore if the tag no longer exists
-				if errors.As(err, &storagedriver.PathNotFoundError{}) {
-					continue
+		// the tags of referrers are deleted along with them, so they must not be protected either
+		if protection != nil {
+			for _, d := range append(referrers, imh.Digest) {
+				tags, err := imh.Repository.Tags(imh).Lookup(imh, distribution.Descriptor{Digest: d})
+				if err != nil {
+					return err
+				}
+				if err := protection.check(tags...); err != nil {
+					return err
 				}
-				return err
 			}
+		}
 
-			// we also need to send the event here since we decoupled the events from the storage drivers
-			if err := imh.queueBridge.TagDeleted(imh.Repository.Named(), tag); err != nil {
-				l.WithError(err).Error("queuing tag delete inside manifest delete handler")
+		for _, d := range referrers {
+			if err := imh.fsDeleteManifest(manifests, d); err != nil {
+				return fmt.Errorf("deleting referrer %s: %w", d, err)
 			}
+			deletedReferrers = append(deletedReferrers, d)
+		}
+
+		if err := imh.fsDeleteManifest(manifests, imh.Digest); err != nil {
+			return err
 		}
 	} else {
 		// To be removed on completion of: https://gitlab.com/groups/gitlab-org/-/epics/9050
 		repoCache := getRepoCache(imh)
 
-		if err := dbDeleteManifest(imh.Context, imh.db, repoCache, imh.Repository.Named().String(), imh.Digest); err != nil {
+		var err error
+		deletedReferrers, err = dbDeleteManifest(imh.Context, imh.db, repoCache, getManifestCache(imh.App), imh.Repository.Named().String(), imh.Digest, cascadeReferrers, protection)
+		if err != nil {
 			return err
 		}
+		imh.mirrorFSDeleteManifests(append(deletedReferrers, imh.Digest)...)
 	}
 
-	if err := imh.queueBridge.ManifestDeleted(imh.Repository.Named(), imh.Digest); err != nil {
-		l.WithError(err).Error("queuing manifest delete")
+	if len(deletedReferrers) > 0 {
+		l.WithFields(log.Fields{"referrers": len(deletedReferrers)}).Info("deleted manifest referrers")
+	}
+	for _, d := range append(deletedReferrers, imh.Digest) {
+		if err := imh.queueBridge.ManifestDeleted(imh.Repository.Named(), d); err != nil {
+			l.WithError(err).Error("queuing manifest delete")
+		}
+		imh.recordAudit(audit.Record{Action: audit.ActionDelete, Artifact: audit.ArtifactManifest, Digest: d})
 	}
 
 	return nil
 }
 
+// fsDeleteManifest deletes the manifest with digest d from the storage backend, along with any tags that reference it.
+func (imh *manifestHandler) fsDeleteManifest(manifests distribution.ManifestService, d digest.Digest) error {
+	l := log.GetLogger(log.WithContext(imh))
+
+	if err := manifests.Delete(imh, d); err != nil {
+		return err
+	}
+
+	tagService := imh.Repository.Tags(imh)
+	referencedTags, err := tagService.Lookup(imh, distribution.Descriptor{Digest: d})
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range referencedTags {
+		if err = tagService.Untag(imh, tag); err != nil {
+			// ignore if the tag no longer exists
+			if errors.As(err, &storagedriver.PathNotFoundError{}) {
+				continue
+			}
+			return err
+		}
+
+		// we also need to send the event here since we decoupled the events from the storage drivers
+		if err := imh.queueBridge.TagDeleted(imh.Repository.Named(), tag); err != nil {
+			l.WithError(err).Error("queuing tag delete inside manifest delete handler")
+		}
+		imh.recordAudit(audit.Record{Action: audit.ActionDelete, Artifact: audit.ArtifactTag, Tag: tag, Digest: d})
+	}
+
+	return nil
+}
+
+// fsFindReferrers finds all manifests that reference the manifest with digest d as their subject, either directly or
+// through other referrers, such as the signature of an SBOM. As the storage backend has no index of referrers, all
+// manifests in the repository must be read. Referrers are sorted so that each one precedes its own subject.
+func fsFindReferrers(ctx context.Context, manifests distribution.ManifestService, d digest.Digest) ([]digest.Digest, error) {
+	enumerator, ok := manifests.(distribution.ManifestEnumerator)
+	if !ok {
+		return nil, distribution.ErrUnsupported
+	}
+
+	bySubject := make(map[digest.Digest][]digest.Digest)
+	err := enumerator.Enumerate(ctx, func(dgst digest.Digest) error {
+		m, err := manifests.Get(ctx, dgst)
+		if err != nil {
+			return fmt.Errorf("fetching manifest %s: %w", dgst, err)
+		}
+		if ocim, ok := m.(distribution.ManifestOCI); ok {
+			if subject := ocim.Subject(); subject.Digest != "" {
+				bySubject[subject.Digest] = append(bySubject[subject.Digest], dgst)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A manifest can only be pushed after its subject, so there can be no cycles.
+	var referrers []digest.Digest
+	queue := []digest.Digest{d}
+	for len(queue) > 0 {
+		dd := bySubject[queue[0]]
+		queue = append(queue[1:], dd...)
+		referrers = append(referrers, dd...)
+	}
+
+	// referrers were found breadth-first, so reversing them places each one before its subject
+	for i, j := 0, len(referrers)-1; i < j; i, j = i+1, j-1 {
+		referrers[i], referrers[j] = referrers[j], referrers[i]
+	}
+
+	return referrers, nil
+}
+
+const (
+	// cascadeQueryParamKey is the query parameter used to request the deletion of manifests related to the one being
+	// deleted.
+	cascadeQueryParamKey = "cascade"
+	// cascadeReferrersQueryParamValue requests the deletion of all manifests that reference the deleted manifest as
+	// their subject, such as signatures and SBOMs.
+	cascadeReferrersQueryParamValue = "referrers"
+)
+
+// cascadeReferrersEnabled returns whether clients are allowed to request the deletion of a manifest's referrers along
+// with the manifest.
+func cascadeReferrersEnabled(config *configuration.Configuration) bool {
+	if d, ok := config.Storage["delete"]; ok {
+		if cascade, ok := d["cascadereferrers"].(bool); ok {
+			return cascade
+		}
+	}
+	return false
+}
+
 // DeleteManifest removes the manifest with the given digest or the tag with the given name from the registry.
-func (imh *manifestHandler) DeleteManifest(w http.ResponseWriter, _ *http.Request) {
+func (imh *manifestHandler) DeleteManifest(w http.ResponseWriter, r *http.Request) {
 	if !deleteEnabled(imh.App.Config) {
 		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported)
 		return
 	}
 
+	var cascadeReferrers bool
+	switch v := r.URL.Query().Get(cascadeQueryParamKey); v {
+	case "":
+	case cascadeReferrersQueryParamValue:
+		if imh.Tag != "" {
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported.WithDetail("cascading deletes are only supported when deleting manifests by digest"))
+			return
+		}
+		if !cascadeReferrersEnabled(imh.App.Config) {
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported.WithDetail("cascading deletes of referrers are disabled"))
+			return
+		}
+		cascadeReferrers = true
+	default:
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported.WithDetail(fmt.Sprintf("invalid %q query parameter value %q", cascadeQueryParamKey, v)))
+		return
+	}
+
 	if imh.Tag != "" {
+		// preconditions only apply to tags, the header is ignored when deleting by digest
+		precondition, err := parseTagPrecondition(r)
+		if err != nil {
+			imh.Errors = append(imh.Errors, err)
+			return
+		}
+		imh.tagPrecondition = precondition
+		if err := newTagProtection(imh, imh.Repository.Named().Name()).check(imh.Tag); err != nil {
+			imh.Errors = append(imh.Errors, err)
+			return
+		}
 		if err := imh.deleteTag(); err != nil {
 			imh.appendTagDeleteError(err)
 			return
 		}
 	} else {
-		if err := imh.deleteManifest(); err != nil {
+		if err := imh.deleteManifest(cascadeReferrers); err != nil {
 			imh.appendManifestDeleteError(err)
 			return
 		}
@@ -1533,6 +2382,20 @@ func logIfManifestListInvalid(ctx context.Context, ml *manifestlist.Deserialized
 	l.Warn("invalid manifest list/index reference(s), please report this issue to GitLab at https://gitlab.com/gitlab-org/container-registry/-/issues/409")
 }
 
+// manifestCacheEnabled reports whether manifests should be cached in Redis.
+func manifestCacheEnabled(app *App) bool {
+	return app != nil && app.redisCache != nil && app.Config.Redis.Cache.Manifests.Enabled
+}
+
+// getManifestCache selects the cache type to be used for a manifest cache. Manifests are only cached in Redis, if
+// enabled, otherwise a non-operational cache is used.
+func getManifestCache(app *App) datastore.ManifestCache {
+	if manifestCacheEnabled(app) {
+		return datastore.NewCentralManifestCache(app.redisCache, app.redisCacheClient, app.Config.Redis.Cache.Manifests.TTL)
+	}
+	return datastore.NewNoOpManifestCache()
+}
+
 // getRepoCache selects the cache type to be used for a repository cache. It selects between the (default)
 // in-memory cache and a redis cache. The redis cache takes precedence if it is avaialible.
 func getRepoCache(imh *manifestHandler) (repoCache datastore.RepositoryCache) {
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlatformFromRequest(t *testing.T) {
+	tcs := []struct {
+		name    string
+		query   string
+		want    *manifestlist.PlatformSpec
+		wantErr bool
+	}{
+		{name: "none", query: ""},
+		{name: "os and architecture", query: "?platform=linux/amd64", want: &manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}},
+		{name: "with variant", query: "?platform=linux/arm/v7", want: &manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{name: "os only", query: "?platform=linux", wantErr: true},
+		{name: "empty architecture", query: "?platform=linux/", wantErr: true},
+		{name: "too many components", query: "?platform=linux/arm/v7/foo", wantErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := platformFromRequest(httptest.NewRequest("GET", "/v2/foo/manifests/latest"+tc.query, nil))
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	tcs := []struct {
+		name string
+		got  manifestlist.PlatformSpec
+		want manifestlist.PlatformSpec
+		ok   bool
+	}{
+		{
+			name: "same",
+			got:  manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"},
+			want: manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"},
+			ok:   true,
+		},
+		{
+			name: "different architecture",
+			got:  manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"},
+			want: manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			name: "different os",
+			got:  manifestlist.PlatformSpec{OS: "windows", Architecture: "amd64"},
+			want: manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			name: "any variant",
+			got:  manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v6"},
+			want: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm"},
+			ok:   true,
+		},
+		{
+			name: "different variant",
+			got:  manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v6"},
+			want: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			name: "default arm64 variant",
+			got:  manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"},
+			want: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			ok:   true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.ok, platformMatches(tc.got, tc.want))
+		})
+	}
+}
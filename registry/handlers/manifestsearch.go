@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	annotationQueryParamKey = "annotation"
+	// maxAnnotationQueryParams is the maximum number of annotation conditions in a single manifest search.
+	maxAnnotationQueryParams = 10
+)
+
+type groupManifestSearchHandler struct {
+	*Context
+}
+
+func groupManifestSearchDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	groupManifestSearchHandler := &groupManifestSearchHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(groupManifestSearchHandler.SearchManifests),
+	}
+}
+
+// ManifestSearchResultAPIResponse is a manifest in the response body of the group manifest search endpoint.
+type ManifestSearchResultAPIResponse struct {
+	Repository  string            `json:"repository"`
+	Digest      string            `json:"digest"`
+	MediaType   string            `json:"media_type"`
+	Tags        []string          `json:"tags"`
+	Annotations map[string]string `json:"annotations"`
+	CreatedAt   string            `json:"created_at"`
+}
+
+// manifestSearchParamsFromRequest parses the query parameters of a manifest search. Each `annotation` parameter is a
+// condition in the `<key>=<value>` format, or just `<key>` to match any value. The `last` parameter identifies the last
+// manifest of the previous page in the `<repository path>@<digest>` format.
+func manifestSearchParamsFromRequest(r *http.Request) (datastore.AnnotationSearchParams, error) {
+	p := datastore.AnnotationSearchParams{MaxEntries: defaultMaximumReturnedEntries}
+
+	q := r.URL.Query()
+	if q.Has(nQueryParamKey) {
+		val, valid := isQueryParamTypeInt(q.Get(nQueryParamKey))
+		if !valid {
+			detail := v1.InvalidQueryParamTypeErrorDetail(nQueryParamKey, nQueryParamValidTypes)
+			return p, v1.ErrorCodeInvalidQueryParamType.WithDetail(detail)
+		}
+		if !isQueryParamIntValueInBetween(val, nQueryParamValueMin, nQueryParamValueMax) {
+			detail := v1.InvalidQueryParamValueRangeErrorDetail(nQueryParamKey, nQueryParamValueMin, nQueryParamValueMax)
+			return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		p.MaxEntries = val
+	}
+
+	annotations := q[annotationQueryParamKey]
+	if len(annotations) == 0 || len(annotations) > maxAnnotationQueryParams {
+		detail := fmt.Sprintf("between 1 and %d '%s' query parameters must be set", maxAnnotationQueryParams, annotationQueryParamKey)
+		return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+	}
+	for _, a := range annotations {
+		key, value, hasValue := strings.Cut(a, "=")
+		if key == "" || utf8.RuneCountInString(key) > datastore.MaxAnnotationKeyLength || utf8.RuneCountInString(value) > datastore.MaxAnnotationValueLength {
+			detail := fmt.Sprintf("the '%s' query parameter value must be a key of up to %d characters, optionally followed by '=' and a value of up to %d characters",
+				annotationQueryParamKey, datastore.MaxAnnotationKeyLength, datastore.MaxAnnotationValueLength)
+			return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		p.Matches = append(p.Matches, datastore.AnnotationMatch{Key: key, Value: value, AnyValue: !hasValue})
+	}
+
+	if q.Has(lastQueryParamKey) {
+		path, dgst, _ := strings.Cut(q.Get(lastQueryParamKey), "@")
+		named, nameErr := reference.WithName(path)
+		d, dgstErr := digest.Parse(dgst)
+		if nameErr != nil || dgstErr != nil {
+			detail := fmt.Sprintf("the '%s' query parameter value must be in the '<repository path>@<digest>' format", lastQueryParamKey)
+			return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		p.LastRepositoryPath = named.Name()
+		p.LastDigest = d
+	}
+
+	return p, nil
+}
+
+// SearchManifests finds the manifests of the repositories under a group (namespace) path, including the repository with
+// the group path itself, whose annotations satisfy all the conditions in the request. Results are sorted by repository
+// path and digest, with a Link header pointing to the next page when there may be more results.
+func (h *groupManifestSearchHandler) SearchManifests(w http.ResponseWriter, r *http.Request) {
+	params, err := manifestSearchParamsFromRequest(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	group := &models.Repository{Path: h.Repository.Named().Name()}
+	namespace, err := datastore.NewNamespaceStore(h.db).FindByName(h.Context, group.TopLevelPathSegment())
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if namespace == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"namespace": group.TopLevelPathSegment()}))
+		return
+	}
+	group.NamespaceID = namespace.ID
+
+	mm, err := datastore.NewManifestAnnotationStore(h.db).SearchForPath(h.Context, group, params)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	// a full page means there may be more results
+	if len(mm) == params.MaxEntries {
+		last := mm[len(mm)-1]
+		u := *r.URL
+		q := u.Query()
+		q.Set(nQueryParamKey, strconv.Itoa(params.MaxEntries))
+		q.Set(lastQueryParamKey, last.RepositoryPath+"@"+last.Digest.String())
+		u.RawQuery = q.Encode()
+		u.Fragment = ""
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), linkNext))
+	}
+
+	resp := make([]ManifestSearchResultAPIResponse, 0, len(mm))
+	for _, m := range mm {
+		resp = append(resp, ManifestSearchResultAPIResponse{
+			Repository:  m.RepositoryPath,
+			Digest:      m.Digest.String(),
+			MediaType:   m.MediaType,
+			Tags:        m.Tags,
+			Annotations: m.Annotations,
+			CreatedAt:   timeToString(m.CreatedAt),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestSearchParamsFromRequest(t *testing.T) {
+	d := digest.FromString("foo")
+
+	tcs := map[string]struct {
+		query    url.Values
+		expected datastore.AnnotationSearchParams
+	}{
+		"key and value": {
+			query: url.Values{"annotation": {"org.opencontainers.image.vendor=GitLab"}},
+			expected: datastore.AnnotationSearchParams{
+				Matches:    []datastore.AnnotationMatch{{Key: "org.opencontainers.image.vendor", Value: "GitLab"}},
+				MaxEntries: defaultMaximumReturnedEntries,
+			},
+		},
+		"key only": {
+			query: url.Values{"annotation": {"org.example.stage"}},
+			expected: datastore.AnnotationSearchParams{
+				Matches:    []datastore.AnnotationMatch{{Key: "org.example.stage", AnyValue: true}},
+				MaxEntries: defaultMaximumReturnedEntries,
+			},
+		},
+		"empty value": {
+			query: url.Values{"annotation": {"org.example.stage="}},
+			expected: datastore.AnnotationSearchParams{
+				Matches:    []datastore.AnnotationMatch{{Key: "org.example.stage"}},
+				MaxEntries: defaultMaximumReturnedEntries,
+			},
+		},
+		"value with separator": {
+			query: url.Values{"annotation": {"org.example.args=a=b"}},
+			expected: datastore.AnnotationSearchParams{
+				Matches:    []datastore.AnnotationMatch{{Key: "org.example.args", Value: "a=b"}},
+				MaxEntries: defaultMaximumReturnedEntries,
+			},
+		},
+		"multiple with pagination": {
+			query: url.Values{
+				"annotation": {"a=1", "b"},
+				"n":          {"10"},
+				"last":       {"foo/bar@" + d.String()},
+			},
+			expected: datastore.AnnotationSearchParams{
+				Matches:            []datastore.AnnotationMatch{{Key: "a", Value: "1"}, {Key: "b", AnyValue: true}},
+				LastRepositoryPath: "foo/bar",
+				LastDigest:         d,
+				MaxEntries:         10,
+			},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/gitlab/v1/groups/foo/manifests/search/?"+tc.query.Encode(), nil)
+			p, err := manifestSearchParamsFromRequest(r)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, p)
+		})
+	}
+}
+
+func TestManifestSearchParamsFromRequest_Invalid(t *testing.T) {
+	tooMany := make([]string, maxAnnotationQueryParams+1)
+	for i := range tooMany {
+		tooMany[i] = "a"
+	}
+
+	tcs := map[string]struct {
+		query        url.Values
+		expectedCode errcode.ErrorCode
+	}{
+		"no annotation":     {query: url.Values{}, expectedCode: v1.ErrorCodeInvalidQueryParamValue},
+		"too many":          {query: url.Values{"annotation": tooMany}, expectedCode: v1.ErrorCodeInvalidQueryParamValue},
+		"empty key":         {query: url.Values{"annotation": {"=GitLab"}}, expectedCode: v1.ErrorCodeInvalidQueryParamValue},
+		"too long key":      {query: url.Values{"annotation": {strings.Repeat("k", 256)}}, expectedCode: v1.ErrorCodeInvalidQueryParamValue},
+		"too long value":    {query: url.Values{"annotation": {"k=" + strings.Repeat("v", 256)}}, expectedCode: v1.ErrorCodeInvalidQueryParamValue},
+		"invalid page size": {query: url.Values{"annotation": {"a"}, "n": {"0"}}, expectedCode: v1.ErrorCodeInvalidQueryParamValue},
+		"non-numeric n":     {query: url.Values{"annotation": {"a"}, "n": {"a"}}, expectedCode: v1.ErrorCodeInvalidQueryParamType},
+		"last without digest": {
+			query:        url.Values{"annotation": {"a"}, "last": {"foo/bar"}},
+			expectedCode: v1.ErrorCodeInvalidQueryParamValue,
+		},
+		"last with invalid path": {
+			query:        url.Values{"annotation": {"a"}, "last": {"Foo@" + digest.FromString("foo").String()}},
+			expectedCode: v1.ErrorCodeInvalidQueryParamValue,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/gitlab/v1/groups/foo/manifests/search/?"+tc.query.Encode(), nil)
+			_, err := manifestSearchParamsFromRequest(r)
+			var errc errcode.Error
+			require.ErrorAs(t, err, &errc)
+			require.Equal(t, tc.expectedCode, errc.Code)
+		})
+	}
+}
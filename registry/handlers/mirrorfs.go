@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/opencontainers/go-digest"
+)
+
+// mirrorFS reports whether writes to the database should be mirrored to the filesystem metadata, so that it can be used
+// as a fallback for reads while the database is degraded.
+func (ctx *Context) mirrorFS() bool {
+	return ctx.useDatabase && ctx.App.fsMirrorRegistry != nil
+}
+
+// fsMirrorRepository returns the repository targeted by the request from the filesystem mirror registry. Unlike
+// Repository, it's not decorated with notifications, so mirrored writes do not emit duplicate events.
+func (ctx *Context) fsMirrorRepository() (distribution.Repository, error) {
+	return ctx.App.fsMirrorRegistry.Repository(ctx, ctx.Repository.Named())
+}
+
+// mirroredManifestWriter writes manifests and tags to the database and then mirrors them to the filesystem metadata.
+// Mirroring is best-effort, as the database is the source of truth: failures are logged but not returned. For example,
+// manifests that reference layers pushed before mirroring was enabled cannot be mirrored, as their layer links are
+// missing from the filesystem metadata.
+type mirroredManifestWriter struct {
+	manifestWriter
+	mirror manifestWriter
+}
+
+func (p *mirroredManifestWriter) Put(imh *manifestHandler, mfst distribution.Manifest) error {
+	if err := p.manifestWriter.Put(imh, mfst); err != nil {
+		return err
+	}
+	if err := p.mirror.Put(imh, mfst); err != nil {
+		log.GetLogger(log.WithContext(imh)).WithError(err).Warn("failed to mirror manifest to filesystem metadata")
+	}
+	return nil
+}
+
+func (p *mirroredManifestWriter) Tag(imh *manifestHandler, mfst distribution.Manifest, tag string, desc distribution.Descriptor) error {
+	if err := p.manifestWriter.Tag(imh, mfst, tag, desc); err != nil {
+		return err
+	}
+	if err := p.mirror.Tag(imh, mfst, tag, desc); err != nil {
+		log.GetLogger(log.WithContext(imh)).WithError(err).Warn("failed to mirror tag to filesystem metadata")
+	}
+	return nil
+}
+
+// mirrorFSUntag removes a tag from the filesystem metadata, if mirroring is enabled. Failures are only logged.
+func (ctx *Context) mirrorFSUntag(tag string) {
+	if !ctx.mirrorFS() {
+		return
+	}
+
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"tag_name": tag})
+	repo, err := ctx.fsMirrorRepository()
+	if err != nil {
+		l.WithError(err).Warn("failed to mirror tag delete to filesystem metadata")
+		return
+	}
+	if err := repo.Tags(ctx).Untag(ctx, tag); err != nil && !isFSMirrorNotFound(err) {
+		l.WithError(err).Warn("failed to mirror tag delete to filesystem metadata")
+	}
+}
+
+// mirrorFSDeleteManifests removes the manifests with the given digests from the filesystem metadata, along with any
+// tags that reference them, if mirroring is enabled. Failures are only logged.
+func (ctx *Context) mirrorFSDeleteManifests(dgsts ...digest.Digest) {
+	if !ctx.mirrorFS() {
+		return
+	}
+
+	l := log.GetLogger(log.WithContext(ctx))
+	repo, err := ctx.fsMirrorRepository()
+	if err != nil {
+		l.WithError(err).Warn("failed to mirror manifest delete to filesystem metadata")
+		return
+	}
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		l.WithError(err).Warn("failed to mirror manifest delete to filesystem metadata")
+		return
+	}
+	ts := repo.Tags(ctx)
+
+	for _, d := range dgsts {
+		dl := l.WithFields(log.Fields{"digest": d})
+		if err := ms.Delete(ctx, d); err != nil && !isFSMirrorNotFound(err) {
+			dl.WithError(err).Warn("failed to mirror manifest delete to filesystem metadata")
+			continue
+		}
+		tags, err := ts.Lookup(ctx, distribution.Descriptor{Digest: d})
+		if err != nil {
+			dl.WithError(err).Warn("failed to mirror manifest delete to filesystem metadata")
+			continue
+		}
+		for _, tag := range tags {
+			if err := ts.Untag(ctx, tag); err != nil && !isFSMirrorNotFound(err) {
+				dl.WithFields(log.Fields{"tag_name": tag}).WithError(err).Warn("failed to mirror tag delete to filesystem metadata")
+			}
+		}
+	}
+}
+
+// isFSMirrorNotFound reports whether err means that the target of a mirrored delete was not found in the filesystem
+// metadata, which is expected for content written before mirroring was enabled.
+func isFSMirrorNotFound(err error) bool {
+	return errors.As(err, &storagedriver.PathNotFoundError{}) ||
+		errors.As(err, &distribution.ErrTagUnknown{}) ||
+		errors.As(err, &distribution.ErrManifestUnknownRevision{}) ||
+		errors.Is(err, distribution.ErrBlobUnknown)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+)
+
+const (
+	fromQueryParamKey = "from"
+	toQueryParamKey   = "to"
+
+	statisticsDateLayout = "2006-01-02"
+	// defaultStatisticsDays is the number of days returned by the group statistics endpoint when no range is requested.
+	defaultStatisticsDays = 30
+	// maxStatisticsDays is the maximum number of days that can be requested from the group statistics endpoint.
+	maxStatisticsDays = 366
+)
+
+// recordNamespacePush records a manifest push to the given top-level namespace in the namespace statistics, if enabled.
+func (ctx *Context) recordNamespacePush(namespaceID int64) {
+	if ctx.namespaceStatistics == nil {
+		return
+	}
+	ctx.namespaceStatistics.RecordPush(namespaceID)
+}
+
+// recordNamespacePull records a manifest pull from the given top-level namespace in the namespace statistics, if
+// enabled.
+func (ctx *Context) recordNamespacePull(namespaceID int64) {
+	if ctx.namespaceStatistics == nil {
+		return
+	}
+	ctx.namespaceStatistics.RecordPull(namespaceID)
+}
+
+// recordNamespaceBytesAdded records the upload of a blob of the given size to the given top-level namespace in the
+// namespace statistics, if enabled.
+func (ctx *Context) recordNamespaceBytesAdded(namespaceID, size int64) {
+	if ctx.namespaceStatistics == nil {
+		return
+	}
+	ctx.namespaceStatistics.RecordBytesAdded(namespaceID, size)
+}
+
+type groupStatisticsHandler struct {
+	*Context
+}
+
+func groupStatisticsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	groupStatisticsHandler := &groupStatisticsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(groupStatisticsHandler.GetStatistics),
+	}
+}
+
+// NamespaceStatisticsCounters are the usage counters of a namespace in the response body of the group statistics
+// endpoint.
+type NamespaceStatisticsCounters struct {
+	Pushes         int64 `json:"pushes"`
+	Pulls          int64 `json:"pulls"`
+	BytesAdded     int64 `json:"bytes_added"`
+	BytesReclaimed int64 `json:"bytes_reclaimed"`
+}
+
+func (c *NamespaceStatisticsCounters) add(s *models.NamespaceStatistics) {
+	c.Pushes += s.Pushes
+	c.Pulls += s.Pulls
+	c.BytesAdded += s.BytesAdded
+	c.BytesReclaimed += s.BytesReclaimed
+}
+
+// NamespaceStatisticsDayAPIResponse is the usage counters of a namespace for a given day in the response body of the
+// group statistics endpoint.
+type NamespaceStatisticsDayAPIResponse struct {
+	Date string `json:"date"`
+	NamespaceStatisticsCounters
+}
+
+// NamespaceStatisticsAPIResponse is the response body for the group statistics endpoint. Days holds an entry for every
+// day of the requested range, in ascending order, with zeroed counters for days without activity.
+type NamespaceStatisticsAPIResponse struct {
+	Namespace string                              `json:"namespace"`
+	From      string                              `json:"from"`
+	To        string                              `json:"to"`
+	Totals    NamespaceStatisticsCounters         `json:"totals"`
+	Days      []NamespaceStatisticsDayAPIResponse `json:"days"`
+}
+
+// statisticsRangeFromRequest parses the `from` and `to` query parameters of a group statistics request, both inclusive
+// dates in the `YYYY-MM-DD` format (UTC). Defaults to the last 30 days, up to and including the current day.
+func statisticsRangeFromRequest(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+
+	parse := func(key string, def time.Time) (time.Time, error) {
+		if !q.Has(key) {
+			return def, nil
+		}
+		t, err := time.Parse(statisticsDateLayout, q.Get(key))
+		if err != nil {
+			detail := fmt.Sprintf("the '%s' query parameter value must be a date in the 'YYYY-MM-DD' format", key)
+			return time.Time{}, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		return t, nil
+	}
+
+	to, err := parse(toQueryParamKey, time.Now().UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, err := parse(fromQueryParamKey, to.AddDate(0, 0, -(defaultStatisticsDays-1)))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if from.After(to) {
+		detail := fmt.Sprintf("the '%s' query parameter value must not be after that of '%s'", fromQueryParamKey, toQueryParamKey)
+		return time.Time{}, time.Time{}, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxStatisticsDays {
+		detail := fmt.Sprintf("the requested range must not exceed %d days", maxStatisticsDays)
+		return time.Time{}, time.Time{}, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+	}
+
+	return from, to, nil
+}
+
+// GetStatistics returns the daily usage statistics of a top-level namespace between two dates, along with their totals.
+func (h *groupStatisticsHandler) GetStatistics(w http.ResponseWriter, r *http.Request) {
+	if !h.App.Config.Database.NamespaceStatistics.Enabled {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported)
+		return
+	}
+
+	path := h.Repository.Named().Name()
+	if strings.Contains(path, "/") {
+		// statistics are recorded for top-level namespaces only, so those of subgroups can't be told apart
+		h.Errors = append(h.Errors, v2.ErrorCodeNameInvalid.WithDetail("statistics are only available for top-level namespaces"))
+		return
+	}
+
+	from, to, err := statisticsRangeFromRequest(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	namespace, err := datastore.NewNamespaceStore(h.db).FindByName(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if namespace == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"namespace": path}))
+		return
+	}
+
+	ss, err := datastore.NewNamespaceStatisticsStore(h.db).FindByNamespace(h.Context, namespace.ID, from, to)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	byDate := make(map[string]*models.NamespaceStatistics, len(ss))
+	for _, s := range ss {
+		byDate[s.Date.UTC().Format(statisticsDateLayout)] = s
+	}
+
+	resp := NamespaceStatisticsAPIResponse{
+		Namespace: namespace.Name,
+		From:      from.Format(statisticsDateLayout),
+		To:        to.Format(statisticsDateLayout),
+		Days:      make([]NamespaceStatisticsDayAPIResponse, 0, int(to.Sub(from).Hours()/24)+1),
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := NamespaceStatisticsDayAPIResponse{Date: d.Format(statisticsDateLayout)}
+		if s, ok := byDate[day.Date]; ok {
+			day.add(s)
+			resp.Totals.add(s)
+		}
+		resp.Days = append(resp.Days, day)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
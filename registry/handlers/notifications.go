@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	dlog "github.com/docker/distribution/log"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+)
+
+// notificationEventPurgingInterval is how often failed notification events past the retention window are deleted.
+const notificationEventPurgingInterval = time.Hour
+
+// notificationEventStore is a notifications.EventStore backed by the metadata database.
+type notificationEventStore struct {
+	db datastore.Queryer
+}
+
+// Add implements notifications.EventStore.
+func (s *notificationEventStore) Add(ctx context.Context, endpoint string, payload []byte) error {
+	return datastore.NewNotificationEventStore(s.db).Create(ctx, &models.NotificationEvent{Endpoint: endpoint, Payload: payload})
+}
+
+// Claim implements notifications.EventStore.
+func (s *notificationEventStore) Claim(ctx context.Context, endpoint string, limit int, lease time.Duration) ([]notifications.StoredEvent, error) {
+	ee, err := datastore.NewNotificationEventStore(s.db).Claim(ctx, endpoint, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	se := make([]notifications.StoredEvent, 0, len(ee))
+	for _, e := range ee {
+		se = append(se, notifications.StoredEvent{ID: e.ID, Payload: e.Payload})
+	}
+	return se, nil
+}
+
+// Delete implements notifications.EventStore.
+func (s *notificationEventStore) Delete(ctx context.Context, id int64) error {
+	return datastore.NewNotificationEventStore(s.db).Delete(ctx, id)
+}
+
+// Fail implements notifications.EventStore.
+func (s *notificationEventStore) Fail(ctx context.Context, id int64) error {
+	return datastore.NewNotificationEventStore(s.db).Fail(ctx, id)
+}
+
+// OldestPending implements notifications.EventStore.
+func (s *notificationEventStore) OldestPending(ctx context.Context, endpoint string) (time.Time, error) {
+	return datastore.NewNotificationEventStore(s.db).OldestPendingAt(ctx, endpoint)
+}
+
+// notificationsQueueConfig returns the queue configuration for notification endpoints, validating the queue type.
+func (app *App) notificationsQueueConfig(config *configuration.Configuration) (notifications.QueueConfig, error) {
+	q := config.Notifications.Queue
+
+	switch q.Type {
+	case "", configuration.NotificationsQueueTypeMemory:
+		return notifications.QueueConfig{}, nil
+	case configuration.NotificationsQueueTypeDatabase:
+		if !config.Database.Enabled {
+			return notifications.QueueConfig{}, errors.New("the database notifications queue requires the database to be enabled")
+		}
+		return notifications.QueueConfig{
+			Store:       &notificationEventStore{db: app.db},
+			MaxAttempts: q.MaxAttempts,
+			Interval:    q.Interval,
+		}, nil
+	default:
+		return notifications.QueueConfig{}, fmt.Errorf("unknown notifications queue type %q", q.Type)
+	}
+}
+
+// startNotificationEventPurging periodically deletes the notification events that failed before the retention window,
+// as they can no longer be replayed.
+func startNotificationEventPurging(app *App, config *configuration.Configuration) {
+	if config.Notifications.Queue.Type != configuration.NotificationsQueueTypeDatabase {
+		return
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(app.Context))
+	s := datastore.NewNotificationEventStore(app.db)
+
+	go func() {
+		t := time.NewTicker(notificationEventPurgingInterval)
+		defer t.Stop()
+
+		for {
+			n, err := s.DeleteFailedBefore(app.Context, time.Now().Add(-config.Notifications.Queue.Retention))
+			if err != nil {
+				l.WithError(err).Error("failed to purge failed notification events")
+			} else if n > 0 {
+				l.WithFields(dlog.Fields{"count": n}).Info("purged failed notification events")
+			}
+
+			select {
+			case <-app.Context.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+type notificationsHandler struct {
+	*Context
+}
+
+func notificationsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	notificationsHandler := &notificationsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(notificationsHandler.GetNotifications),
+	}
+}
+
+func notificationsReplayDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	notificationsHandler := &notificationsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodPost: http.HandlerFunc(notificationsHandler.ReplayNotifications),
+	}
+}
+
+// NotificationEndpointAPIResponse describes the events of a notification endpoint in the persistent queue.
+type NotificationEndpointAPIResponse struct {
+	Name            string `json:"name"`
+	Pending         int    `json:"pending"`
+	Failed          int    `json:"failed"`
+	OldestPendingAt string `json:"oldest_pending_at,omitempty"`
+}
+
+// NotificationsAPIResponse is the response body for the notifications endpoint.
+type NotificationsAPIResponse struct {
+	Endpoints []NotificationEndpointAPIResponse `json:"endpoints"`
+}
+
+// NotificationsReplayAPIRequest is the request body for the notifications replay endpoint. All fields are optional.
+type NotificationsReplayAPIRequest struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Since    string `json:"since,omitempty"`
+}
+
+// NotificationsReplayAPIResponse is the response body for the notifications replay endpoint.
+type NotificationsReplayAPIResponse struct {
+	Requeued int64 `json:"requeued"`
+}
+
+const (
+	notificationsReplayEndpointBodyParamKey = "endpoint"
+	notificationsReplaySinceBodyParamKey    = "since"
+)
+
+func (h *notificationsHandler) queueEnabled() bool {
+	if !h.App.Config.Database.Enabled {
+		detail := v1.MissingServerDependencyTypeErrorDetail("database")
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail(detail))
+		return false
+	}
+	if h.App.Config.Notifications.Queue.Type != configuration.NotificationsQueueTypeDatabase {
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail("the database notifications queue is not enabled"))
+		return false
+	}
+	return true
+}
+
+// GetNotifications returns the number of pending and failed events of each notification endpoint in the persistent
+// queue, along with the creation date of the oldest pending event.
+func (h *notificationsHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	if !h.queueEnabled() {
+		return
+	}
+
+	ss, err := datastore.NewNotificationEventStore(h.db).Stats(h.Context)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := NotificationsAPIResponse{Endpoints: make([]NotificationEndpointAPIResponse, 0, len(ss))}
+	for _, s := range ss {
+		e := NotificationEndpointAPIResponse{
+			Name:    s.Endpoint,
+			Pending: s.Pending,
+			Failed:  s.Failed,
+		}
+		if s.OldestPendingAt.Valid {
+			e.OldestPendingAt = timeToString(s.OldestPendingAt.Time)
+		}
+		resp.Endpoints = append(resp.Endpoints, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// ReplayNotifications requeues the notification events that failed within the retention window, or since the given
+// date, optionally only for a given endpoint.
+func (h *notificationsHandler) ReplayNotifications(w http.ResponseWriter, r *http.Request) {
+	if !h.queueEnabled() {
+		return
+	}
+
+	endpoint, since, ok := h.decodeReplayRequest(r.Body)
+	if !ok {
+		return
+	}
+
+	n, err := datastore.NewNotificationEventStore(h.db).Requeue(h.Context, endpoint, since)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	l := dlog.GetLogger(dlog.WithContext(h.Context))
+	l.WithFields(dlog.Fields{"endpoint": endpoint, "since": since, "count": n}).Info("requeued failed notification events")
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(NotificationsReplayAPIResponse{Requeued: n}); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// decodeReplayRequest decodes and validates a replay request body. An empty body replays all events that failed
+// within the retention window. Any validation errors are appended to the handler errors.
+func (h *notificationsHandler) decodeReplayRequest(body io.Reader) (string, time.Time, bool) {
+	var req NotificationsReplayAPIRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return "", time.Time{}, false
+	}
+
+	invalid := func(detail string) (string, time.Time, bool) {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return "", time.Time{}, false
+	}
+
+	if req.Endpoint != "" && !h.App.notificationEndpointConfigured(req.Endpoint) {
+		return invalid(fmt.Sprintf("the '%s' body parameter must be the name of a configured endpoint", notificationsReplayEndpointBodyParamKey))
+	}
+
+	since := time.Now().Add(-h.App.Config.Notifications.Queue.Retention)
+	if req.Since != "" {
+		t, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			return invalid(fmt.Sprintf("the '%s' body parameter must be an RFC 3339 date", notificationsReplaySinceBodyParamKey))
+		}
+		since = t
+	}
+
+	return req.Endpoint, since, true
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/proxy"
+	"github.com/opencontainers/go-digest"
+)
+
+// proxyCache implements proxy.Cache, caching the content fetched from the remote registry of the pull-through cache in
+// the repository of the request, as if it was pushed.
+type proxyCache struct {
+	*Context
+}
+
+// newProxyCache creates a proxy.Cache for the repository of the request, bound to ctx.
+func newProxyCache(h *Context, ctx context.Context) *proxyCache {
+	c := *h
+	c.Context = ctx
+	return &proxyCache{Context: &c}
+}
+
+func (c *proxyCache) repositoryStoreOptions() []datastore.RepositoryStoreOption {
+	var opts []datastore.RepositoryStoreOption
+	if c.App.redisCache != nil {
+		opts = append(opts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(c.App.redisCache)))
+	}
+	return opts
+}
+
+func (c *proxyCache) repositoryStore() datastore.RepositoryStore {
+	return datastore.NewRepositoryStore(c.db, c.repositoryStoreOptions()...)
+}
+
+// ManifestExists implements proxy.Cache.
+func (c *proxyCache) ManifestExists(ctx context.Context, dgst digest.Digest) (bool, error) {
+	rStore := c.repositoryStore()
+	r, err := rStore.FindByPath(ctx, c.Repository.Named().Name())
+	if err != nil || r == nil {
+		return false, err
+	}
+	m, err := rStore.FindManifestByDigest(ctx, r, dgst)
+	if err != nil {
+		return false, err
+	}
+
+	return m != nil, nil
+}
+
+// BlobExists implements proxy.Cache.
+func (c *proxyCache) BlobExists(ctx context.Context, dgst digest.Digest) (bool, error) {
+	rStore := c.repositoryStore()
+	r, err := rStore.FindByPath(ctx, c.Repository.Named().Name())
+	if err != nil || r == nil {
+		return false, err
+	}
+
+	return rStore.ExistsBlob(ctx, r, dgst)
+}
+
+// PutBlob implements proxy.Cache. The blob is written to the storage backend and recorded in the database like a
+// pushed one, so that it counts towards the size of its namespace and is garbage collected once unreferenced.
+func (c *proxyCache) PutBlob(ctx context.Context, desc distribution.Descriptor, r io.Reader) error {
+	bw, err := c.Repository.Blobs(ctx).Create(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(bw, r); err != nil {
+		_ = bw.Cancel(ctx)
+		return err
+	}
+	committed, err := bw.Commit(ctx, distribution.Descriptor{Digest: desc.Digest, Size: desc.Size})
+	if err != nil {
+		_ = bw.Cancel(ctx)
+		return err
+	}
+
+	repo, err := dbPutBlobUploadComplete(ctx, c.db, c.Repository.Named().Name(), committed, c.repositoryStoreOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create blob in database: %w", err)
+	}
+	c.recordNamespaceBytesAdded(repo.NamespaceID, committed.Size)
+
+	return nil
+}
+
+// PutManifest implements proxy.Cache.
+func (c *proxyCache) PutManifest(ctx context.Context, m distribution.Manifest, dgst digest.Digest) error {
+	return (&dbManifestWriter{}).Put(c.manifestHandler(ctx, dgst, ""), m)
+}
+
+// Tag implements proxy.Cache. The tag is recorded as cached before being created, so that it expires even if the
+// request fails after creating it.
+func (c *proxyCache) Tag(ctx context.Context, m distribution.Manifest, name string, dgst digest.Digest) error {
+	repoPath := c.Repository.Named().Name()
+	r, err := c.repositoryStore().FindByPath(ctx, repoPath)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return distribution.ErrRepositoryUnknown{Name: repoPath}
+	}
+	if err := datastore.NewProxyCachedTagStore(c.db).Touch(ctx, r, name); err != nil {
+		return err
+	}
+
+	return (&dbManifestWriter{}).Tag(c.manifestHandler(ctx, dgst, name), m, name, distribution.Descriptor{Digest: dgst})
+}
+
+// manifestHandler returns a handler for writing the manifest with digest dgst, and tag, if not empty, bound to ctx.
+func (c *proxyCache) manifestHandler(ctx context.Context, dgst digest.Digest, tag string) *manifestHandler {
+	return &manifestHandler{Context: newProxyCache(c.Context, ctx).Context, Digest: dgst, Tag: tag}
+}
+
+// proxyEnabled reports whether content not found locally should be fetched from the remote registry of the
+// pull-through cache. The cache is only used along with the database, not when falling back to the filesystem metadata.
+func (ctx *Context) proxyEnabled() bool {
+	return ctx.App.proxy != nil && ctx.useDatabase
+}
+
+// fetchTimeout returns a context bound to the configured timeout for fetching content from the remote registry.
+func (ctx *Context) fetchTimeout() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, ctx.App.Config.Proxy.Timeout)
+}
+
+// recordProxyFetch makes queries for the repository of the request stick to the primary database, as reads issued right
+// after caching content must see it even if replicas are lagging behind.
+func (ctx *Context) recordProxyFetch() {
+	if ctx.App.dbLoadBalancer == nil {
+		return
+	}
+	ctx.App.dbLoadBalancer.RecordWrite(ctx, ctx.Repository.Named().Name())
+}
+
+// isManifestUnknown reports whether err is the error of a manifest getter for a manifest or tag that does not exist.
+func isManifestUnknown(err error) bool {
+	return errors.As(err, &distribution.ErrManifestUnknownRevision{}) ||
+		errors.As(err, &distribution.ErrManifestUnknown{}) ||
+		errors.As(err, &distribution.ErrTagUnknown{})
+}
+
+// getProxyManifest fetches the requested manifest from the remote registry of the pull-through cache, along with
+// everything it references, and gets it once cached through a new getter, which is returned. An unknown manifest or tag
+// error is returned if it's not found in the remote registry either.
+func (imh *manifestHandler) getProxyManifest(r *http.Request) (manifestGetter, distribution.Manifest, error) {
+	ctx, cancel := imh.fetchTimeout()
+	defer cancel()
+
+	l := log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{"remote_url": imh.App.proxy.URL()})
+
+	cache := newProxyCache(imh.Context, ctx)
+	name := imh.Repository.Named()
+	dgst := imh.Digest
+	var err error
+	if imh.Tag != "" {
+		_, dgst, err = imh.App.proxy.FetchTag(ctx, name, imh.Tag, cache)
+	} else {
+		_, err = imh.App.proxy.FetchManifest(ctx, name, imh.Digest, cache)
+	}
+	if err != nil {
+		if errors.Is(err, proxy.ErrNotFound) {
+			if imh.Tag != "" {
+				return nil, nil, distribution.ErrTagUnknown{Tag: imh.Tag}
+			}
+			return nil, nil, distribution.ErrManifestUnknownRevision{Name: name.Name(), Revision: imh.Digest}
+		}
+		return nil, nil, fmt.Errorf("fetching manifest from remote registry: %w", err)
+	}
+	imh.recordProxyFetch()
+	l.WithFields(log.Fields{"tag_name": imh.Tag, "digest": dgst}).Info("manifest fetched from remote registry")
+
+	g, err := imh.newManifestGetter(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := imh.getManifest(g)
+
+	return g, m, err
+}
+
+// fetchProxyBlob fetches the requested blob from the remote registry of the pull-through cache and caches it. An
+// unknown blob error is returned if it's not found in the remote registry.
+func (bh *blobHandler) fetchProxyBlob() error {
+	ctx, cancel := bh.fetchTimeout()
+	defer cancel()
+
+	if err := bh.App.proxy.FetchBlob(ctx, bh.Repository.Named(), bh.Digest, newProxyCache(bh.Context, ctx)); err != nil {
+		if errors.Is(err, proxy.ErrNotFound) {
+			return v2.ErrorCodeBlobUnknown.WithDetail(bh.Digest)
+		}
+		return fmt.Errorf("fetching blob from remote registry: %w", err)
+	}
+	bh.recordProxyFetch()
+	log.GetLogger(log.WithContext(bh)).WithFields(log.Fields{
+		"remote_url": bh.App.proxy.URL(),
+		"digest":     bh.Digest,
+	}).Info("blob fetched from remote registry")
+
+	return nil
+}
+
+// rejectProxyWrites rejects the requests that would write to the repository of the request if the registry is a
+// pull-through cache, as its content can only be fetched from the remote registry.
+func rejectProxyWrites(handler http.Handler, h *Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, isRegistryWrite := writeMethods[r.Method]; isRegistryWrite && h.App.proxy != nil {
+			h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported.WithMessage("the registry is a pull-through cache, writes are not supported"))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// proxyTagDeleter implements proxy.Deleter, deleting expired cached tags from the database.
+type proxyTagDeleter struct {
+	app *App
+}
+
+// DeleteTag implements proxy.Deleter.
+func (d *proxyTagDeleter) DeleteTag(ctx context.Context, repoPath, name string) error {
+	var repoCache datastore.RepositoryCache
+	if d.app.redisCache != nil {
+		repoCache = datastore.NewCentralRepositoryCache(d.app.redisCache)
+	} else {
+		repoCache = datastore.NewSingleRepositoryCache()
+	}
+
+	_, err := dbDeleteTags(ctx, d.app.db, repoCache, getManifestCache(d.app), repoPath, []string{name}, "", nil)
+	if errors.As(err, &distribution.ErrRepositoryUnknown{}) {
+		return nil
+	}
+	return err
+}
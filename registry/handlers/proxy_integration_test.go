@@ -0,0 +1,186 @@
+//go:build integration && handlers_test
+
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// newProxyTestEnvs creates a remote registry, which uses filesystem metadata to keep its state apart from the database,
+// and a pull-through cache of it.
+func newProxyTestEnvs(t *testing.T, ttl time.Duration) (*testEnv, *testEnv) {
+	t.Helper()
+
+	remote := newTestEnv(t, withDBDisabled)
+	t.Cleanup(remote.Shutdown)
+
+	env := newTestEnv(t, withProxy(remote.server.URL, ttl))
+	t.Cleanup(env.Shutdown)
+	env.requireDB(t)
+
+	return remote, env
+}
+
+func getManifestByTag(t *testing.T, env *testEnv, repoPath, tagName string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, buildManifestTagURL(t, env, repoPath, tagName), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", schema2.MediaTypeManifest)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestProxy_PullManifestByTag(t *testing.T) {
+	remote, env := newProxyTestEnvs(t, time.Hour)
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, remote, repoPath, putByTag("latest"))
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+	dgst := digest.FromBytes(payload)
+
+	resp := getManifestByTag(t, env, repoPath, "latest")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+
+	// the image is cached, blobs included, and the tag is recorded as cached
+	repoRef, err := reference.WithName(repoPath)
+	require.NoError(t, err)
+	for _, desc := range m.References() {
+		ref, err := reference.WithDigest(repoRef, desc.Digest)
+		require.NoError(t, err)
+		u, err := env.builder.BuildBlobURL(ref)
+		require.NoError(t, err)
+
+		resp, err := http.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		p, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, desc.Digest, digest.FromBytes(p))
+	}
+
+	tt, err := datastore.NewProxyCachedTagStore(env.db).FindCachedBefore(env.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, tt, 1)
+	require.Equal(t, repoPath, tt[0].RepositoryPath)
+	require.Equal(t, "latest", tt[0].Name)
+
+	// the cached manifest is served without the remote registry
+	remote.Shutdown()
+	resp2 := getManifestByTag(t, env, repoPath, "latest")
+	defer resp2.Body.Close()
+	require.Equal(t, http.StatusOK, resp2.StatusCode)
+	require.Equal(t, dgst.String(), resp2.Header.Get("Docker-Content-Digest"))
+}
+
+func TestProxy_PullManifestByDigest(t *testing.T) {
+	remote, env := newProxyTestEnvs(t, time.Hour)
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, remote, repoPath, putByDigest)
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+
+	resp, err := http.Get(buildManifestDigestURL(t, env, repoPath, m))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, digest.FromBytes(payload).String(), resp.Header.Get("Docker-Content-Digest"))
+
+	// manifests pulled by digest are not tagged, so there is nothing to expire
+	tt, err := datastore.NewProxyCachedTagStore(env.db).FindCachedBefore(env.ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, tt)
+}
+
+func TestProxy_PullManifest_NotFound(t *testing.T) {
+	_, env := newProxyTestEnvs(t, time.Hour)
+
+	resp := getManifestByTag(t, env, "foo/bar", "latest")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeManifestUnknown)
+}
+
+func TestProxy_PullBlob_NotFound(t *testing.T) {
+	_, env := newProxyTestEnvs(t, time.Hour)
+
+	repoRef, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	ref, err := reference.WithDigest(repoRef, digest.FromString("foo"))
+	require.NoError(t, err)
+	u, err := env.builder.BuildBlobURL(ref)
+	require.NoError(t, err)
+
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, v2.ErrorCodeBlobUnknown)
+}
+
+func TestProxy_PushRejected(t *testing.T) {
+	remote, env := newProxyTestEnvs(t, time.Hour)
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, remote, repoPath, putByTag("latest"))
+
+	resp := putManifest(t, "putting manifest to pull-through cache", buildManifestTagURL(t, env, repoPath, "latest"), schema2.MediaTypeManifest, m)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	checkBodyHasErrorCodes(t, "wrong response body error code", resp, errcode.ErrorCodeUnsupported)
+}
+
+func TestProxy_TagExpiry(t *testing.T) {
+	remote, env := newProxyTestEnvs(t, time.Second)
+
+	repoPath := "foo/bar"
+	m := seedRandomSchema2Manifest(t, remote, repoPath, putByTag("latest"))
+
+	resp := getManifestByTag(t, env, repoPath, "latest")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the expired tag is deleted, along with the record of it being cached
+	s := datastore.NewProxyCachedTagStore(env.db)
+	require.Eventually(t, func() bool {
+		tt, err := s.FindCachedBefore(env.ctx, time.Now().Add(time.Minute), 10)
+		require.NoError(t, err)
+		return len(tt) == 0
+	}, 10*time.Second, 50*time.Millisecond)
+
+	rStore := datastore.NewRepositoryStore(env.db)
+	r, err := rStore.FindByPath(env.ctx, repoPath)
+	require.NoError(t, err)
+	tag, err := rStore.FindTagByName(env.ctx, r, "latest")
+	require.NoError(t, err)
+	require.Nil(t, tag)
+
+	// and fetched again on the next pull, pointing to the current remote manifest
+	m2 := seedRandomSchema2Manifest(t, remote, repoPath, putByTag("latest"))
+	require.NotEqual(t, m, m2)
+	_, payload, err := m2.Payload()
+	require.NoError(t, err)
+
+	resp2 := getManifestByTag(t, env, repoPath, "latest")
+	defer resp2.Body.Close()
+	require.Equal(t, http.StatusOK, resp2.StatusCode)
+	require.Equal(t, digest.FromBytes(payload).String(), resp2.Header.Get("Docker-Content-Digest"))
+}
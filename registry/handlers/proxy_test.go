@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewApp_ProxyRequiresDatabase(t *testing.T) {
+	config := testConfig()
+	config.Proxy = configuration.Proxy{RemoteURL: "https://registry.example.com"}
+
+	_, err := NewApp(context.Background(), config)
+	require.EqualError(t, err, "the pull-through cache requires the database to be enabled")
+}
+
+func TestRejectProxyWrites(t *testing.T) {
+	remote, err := proxy.NewRemote(configuration.Proxy{RemoteURL: "https://registry.example.com"})
+	require.NoError(t, err)
+
+	tcs := map[string]struct {
+		proxy    *proxy.Remote
+		method   string
+		rejected bool
+	}{
+		"get":               {proxy: remote, method: http.MethodGet},
+		"head":              {proxy: remote, method: http.MethodHead},
+		"post":              {proxy: remote, method: http.MethodPost, rejected: true},
+		"put":               {proxy: remote, method: http.MethodPut, rejected: true},
+		"patch":             {proxy: remote, method: http.MethodPatch, rejected: true},
+		"delete":            {proxy: remote, method: http.MethodDelete, rejected: true},
+		"put without proxy": {method: http.MethodPut},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := &Context{App: &App{proxy: tc.proxy}, Context: context.Background()}
+
+			var served bool
+			next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { served = true })
+
+			r := httptest.NewRequest(tc.method, "/v2/foo/bar/manifests/latest", nil)
+			rejectProxyWrites(next, ctx).ServeHTTP(httptest.NewRecorder(), r)
+
+			require.Equal(t, !tc.rejected, served)
+			if tc.rejected {
+				require.Len(t, ctx.Errors, 1)
+				require.Equal(t, errcode.ErrorCodeUnsupported, ctx.Errors[0].(errcode.Error).Code)
+			} else {
+				require.Empty(t, ctx.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRateLimitPeriod = time.Minute
+	rateLimitBurstPeriod   = time.Second
+	rateLimitKeyPrefix     = "registry:ratelimit"
+)
+
+// slidingWindowScript checks and records a request against one or more sliding windows. Each window is approximated
+// with two fixed window counters, the current and the previous one, weighting the latter by how much of it still
+// overlaps with the sliding window. The request is only recorded if it fits within all windows, otherwise the number
+// of milliseconds to wait until it would fit is returned.
+//
+// KEYS: previous and current counter keys of each window, in that order.
+// ARGV: the current time in milliseconds, followed by the size in milliseconds and the limit of each window.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local wait = 0
+for i = 1, #KEYS / 2 do
+	local size = tonumber(ARGV[2 * i])
+	local limit = tonumber(ARGV[2 * i + 1])
+	local prev = tonumber(redis.call("GET", KEYS[2 * i - 1]) or "0")
+	local cur = tonumber(redis.call("GET", KEYS[2 * i]) or "0")
+	local remaining = size - now % size
+	if prev * remaining / size + cur + 1 > limit then
+		local w
+		if cur + 1 <= limit then
+			-- fits once enough of the previous window slides out
+			w = remaining - (limit - 1 - cur) * size / prev
+		else
+			-- fits once enough of the current window slides out, after it becomes the previous one
+			w = remaining + size - (limit - 1) * size / cur
+		end
+		wait = math.max(wait, math.ceil(w))
+	end
+end
+if wait > 0 then
+	return wait
+end
+for i = 1, #KEYS / 2 do
+	redis.call("INCR", KEYS[2 * i])
+	redis.call("PEXPIRE", KEYS[2 * i], 2 * tonumber(ARGV[2 * i]))
+end
+return 0
+`)
+
+// rateLimitWindow is a sliding window with a maximum number of requests.
+type rateLimitWindow struct {
+	size  time.Duration
+	limit int
+}
+
+// limiter limits requests by the value of a request attribute, such as the client IP.
+type limiter struct {
+	name    string
+	key     string
+	windows []rateLimitWindow
+}
+
+// rateLimiter enforces request rate limits. Requests are counted in Redis, so that limits are shared by all registry
+// instances.
+type rateLimiter struct {
+	client   redis.UniversalClient
+	limiters []limiter
+	proxies  trustedProxies
+	now      func() time.Time
+}
+
+func newRateLimiter(client redis.UniversalClient, config configuration.RateLimiter, proxies trustedProxies) (*rateLimiter, error) {
+	rl := &rateLimiter{client: client, proxies: proxies, now: time.Now}
+
+	names := make(map[string]struct{}, len(config.Limiters))
+	for i, c := range config.Limiters {
+		if c.Name == "" {
+			return nil, fmt.Errorf("ratelimiter.limiters[%d]: name must be set", i)
+		}
+		if _, ok := names[c.Name]; ok {
+			return nil, fmt.Errorf("ratelimiter.limiters[%d]: duplicate name %q", i, c.Name)
+		}
+		names[c.Name] = struct{}{}
+
+		switch c.Key {
+		case configuration.RateLimitKeyIP, configuration.RateLimitKeyToken, configuration.RateLimitKeyRepository:
+		default:
+			return nil, fmt.Errorf("ratelimiter.limiters[%d]: invalid key %q, must be one of %q", i, c.Key,
+				[]string{configuration.RateLimitKeyIP, configuration.RateLimitKeyToken, configuration.RateLimitKeyRepository})
+		}
+		if c.Burst < 0 || c.Sustained < 0 || c.Period < 0 {
+			return nil, fmt.Errorf("ratelimiter.limiters[%d]: burst, sustained and period must not be negative", i)
+		}
+		if c.Burst == 0 && c.Sustained == 0 {
+			return nil, fmt.Errorf("ratelimiter.limiters[%d]: at least one of burst or sustained must be set", i)
+		}
+
+		l := limiter{name: c.Name, key: c.Key}
+		if c.Burst > 0 {
+			l.windows = append(l.windows, rateLimitWindow{size: rateLimitBurstPeriod, limit: c.Burst})
+		}
+		if c.Sustained > 0 {
+			period := c.Period
+			if period == 0 {
+				period = defaultRateLimitPeriod
+			}
+			l.windows = append(l.windows, rateLimitWindow{size: period, limit: c.Sustained})
+		}
+		rl.limiters = append(rl.limiters, l)
+	}
+
+	return rl, nil
+}
+
+// rateLimitExceededError is returned when a request exceeds a rate limit.
+type rateLimitExceededError struct {
+	limiter    string
+	retryAfter time.Duration
+}
+
+func (e rateLimitExceededError) Error() string {
+	return fmt.Sprintf("rate limit %q exceeded, retry after %s", e.limiter, e.retryAfter)
+}
+
+// allow checks the request against each limiter in turn, recording it against those it fits in. A
+// rateLimitExceededError is returned for the first limit exceeded.
+func (rl *rateLimiter) allow(ctx context.Context, r *http.Request) error {
+	for _, l := range rl.limiters {
+		value := rl.keyValue(ctx, r, l.key)
+		if value == "" {
+			// the request cannot be identified by this key, e.g. anonymous requests can't be limited by token
+			continue
+		}
+
+		wait, err := rl.record(ctx, l, value)
+		if err != nil {
+			return fmt.Errorf("checking rate limit %q: %w", l.name, err)
+		}
+		if wait > 0 {
+			return rateLimitExceededError{limiter: l.name, retryAfter: wait}
+		}
+	}
+
+	return nil
+}
+
+// record records a request against a limiter, unless it exceeds any of its windows, in which case the time to wait
+// before retrying is returned.
+func (rl *rateLimiter) record(ctx context.Context, l limiter, value string) (time.Duration, error) {
+	now := rl.now().UnixMilli()
+
+	keys := make([]string, 0, 2*len(l.windows))
+	args := make([]interface{}, 0, 1+2*len(l.windows))
+	args = append(args, now)
+	for _, w := range l.windows {
+		size := w.size.Milliseconds()
+		bucket := now / size
+		// the hash tag ensures that all keys of a limiter and value map to the same Redis Cluster slot, as required
+		// for scripts
+		prefix := fmt.Sprintf("%s:{%s:%s}:%d", rateLimitKeyPrefix, l.name, value, size)
+		keys = append(keys, fmt.Sprintf("%s:%d", prefix, bucket-1), fmt.Sprintf("%s:%d", prefix, bucket))
+		args = append(args, size, w.limit)
+	}
+
+	wait, err := slidingWindowScript.Run(ctx, rl.client, keys, args...).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// keyValue returns the value of the request attribute identified by key, or an empty string if not set. The client IP
+// is only taken from forwarding headers set by trusted proxies, so that clients can't evade limits by spoofing them.
+func (rl *rateLimiter) keyValue(ctx context.Context, r *http.Request, key string) string {
+	switch key {
+	case configuration.RateLimitKeyIP:
+		ip := rl.proxies.clientIP(r)
+		if ip == nil {
+			return ""
+		}
+		return ip.String()
+	case configuration.RateLimitKeyToken:
+		authz := r.Header.Get("Authorization")
+		if authz == "" {
+			return ""
+		}
+		// avoid storing credentials in Redis
+		sum := sha256.Sum256([]byte(authz))
+		return hex.EncodeToString(sum[:])
+	case configuration.RateLimitKeyRepository:
+		return getName(ctx)
+	}
+
+	return ""
+}
+
+// rateLimited checks whether the request exceeds any rate limit, in which case it responds with a 429 Too Many
+// Requests error including a Retry-After header. If rate limits can't be checked, e.g. because Redis is unavailable,
+// the request is allowed, as rate limiting is not critical for serving requests.
+func (app *App) rateLimited(ctx *Context, w http.ResponseWriter, r *http.Request) bool {
+	rl := app.currentRateLimiter()
+	if rl == nil {
+		return false
+	}
+
+	err := rl.allow(ctx, r)
+	if err == nil {
+		return false
+	}
+
+	l := dcontext.GetLogger(ctx)
+	var limitErr rateLimitExceededError
+	if !errors.As(err, &limitErr) {
+		l.WithError(err).Warn("failed checking rate limits, allowing request")
+		return false
+	}
+
+	l.WithField("limiter", limitErr.limiter).Info("rate limit exceeded")
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.retryAfter.Seconds()))))
+	ctx.Errors = append(ctx.Errors, errcode.ErrorCodeTooManyRequests.WithDetail(map[string]string{"limiter": limitErr.limiter}))
+	if err := errcode.ServeJSON(w, ctx.Errors); err != nil {
+		l.Errorf("error serving error json: %v (from %v)", err, ctx.Errors)
+	}
+	app.logError(ctx, r, ctx.Errors)
+
+	return true
+}
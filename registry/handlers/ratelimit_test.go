@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	tcs := []struct {
+		name    string
+		config  configuration.RateLimiter
+		wantErr string
+	}{
+		{
+			name: "valid",
+			config: configuration.RateLimiter{Limiters: []configuration.Limiter{
+				{Name: "ip", Key: configuration.RateLimitKeyIP, Burst: 10, Sustained: 100, Period: time.Hour},
+				{Name: "token", Key: configuration.RateLimitKeyToken, Burst: 10},
+				{Name: "repository", Key: configuration.RateLimitKeyRepository, Sustained: 100},
+			}},
+		},
+		{
+			name: "no name",
+			config: configuration.RateLimiter{Limiters: []configuration.Limiter{
+				{Key: configuration.RateLimitKeyIP, Burst: 10},
+			}},
+			wantErr: "ratelimiter.limiters[0]: name must be set",
+		},
+		{
+			name: "duplicate name",
+			config: configuration.RateLimiter{Limiters: []configuration.Limiter{
+				{Name: "a", Key: configuration.RateLimitKeyIP, Burst: 10},
+				{Name: "a", Key: configuration.RateLimitKeyToken, Burst: 10},
+			}},
+			wantErr: `ratelimiter.limiters[1]: duplicate name "a"`,
+		},
+		{
+			name: "invalid key",
+			config: configuration.RateLimiter{Limiters: []configuration.Limiter{
+				{Name: "a", Key: "user", Burst: 10},
+			}},
+			wantErr: `ratelimiter.limiters[0]: invalid key "user", must be one of ["ip" "token" "repository"]`,
+		},
+		{
+			name: "negative limit",
+			config: configuration.RateLimiter{Limiters: []configuration.Limiter{
+				{Name: "a", Key: configuration.RateLimitKeyIP, Burst: -1},
+			}},
+			wantErr: "ratelimiter.limiters[0]: burst, sustained and period must not be negative",
+		},
+		{
+			name: "no limit",
+			config: configuration.RateLimiter{Limiters: []configuration.Limiter{
+				{Name: "a", Key: configuration.RateLimitKeyIP, Period: time.Hour},
+			}},
+			wantErr: "ratelimiter.limiters[0]: at least one of burst or sustained must be set",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newRateLimiter(nil, tc.config, nil)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func newTestRateLimiter(t *testing.T, now *time.Time, limiters ...configuration.Limiter) *rateLimiter {
+	t.Helper()
+
+	proxies, err := newTrustedProxies([]string{"127.0.0.0/8"})
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: testutil.RedisServer(t).Addr()})
+	rl, err := newRateLimiter(client, configuration.RateLimiter{Enabled: true, Limiters: limiters}, proxies)
+	require.NoError(t, err)
+	rl.now = func() time.Time { return *now }
+
+	return rl
+}
+
+func requireRateLimitExceeded(t *testing.T, err error, limiter string, retryAfter time.Duration) {
+	t.Helper()
+
+	var limitErr rateLimitExceededError
+	require.True(t, errors.As(err, &limitErr), err)
+	require.Equal(t, limiter, limitErr.limiter)
+	require.Equal(t, retryAfter, limitErr.retryAfter)
+}
+
+func TestRateLimiter_Burst(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	rl := newTestRateLimiter(t, &now, configuration.Limiter{Name: "ip", Key: configuration.RateLimitKeyIP, Burst: 3})
+
+	r := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rl.allow(context.Background(), r))
+	}
+
+	// the current window is full, so the request only fits once it becomes the previous one and 1/3 of it slides out
+	requireRateLimitExceeded(t, rl.allow(context.Background(), r), "ip", 1334*time.Millisecond)
+
+	// requests from other clients are not affected
+	other := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	other.RemoteAddr = "192.0.2.2:1234"
+	require.NoError(t, rl.allow(context.Background(), other))
+
+	now = now.Add(2 * time.Second)
+	require.NoError(t, rl.allow(context.Background(), r))
+}
+
+func TestRateLimiter_ForwardedClientIP(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	rl := newTestRateLimiter(t, &now, configuration.Limiter{Name: "ip", Key: configuration.RateLimitKeyIP, Burst: 1})
+
+	newRequest := func(remoteAddr, forwarded string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-For", forwarded)
+		return r
+	}
+
+	// clients can't evade limits by spoofing forwarding headers
+	require.NoError(t, rl.allow(context.Background(), newRequest("192.0.2.1:1234", "198.51.100.1")))
+	requireRateLimitExceeded(t, rl.allow(context.Background(), newRequest("192.0.2.1:1234", "198.51.100.2")), "ip", 2*time.Second)
+
+	// but clients behind trusted proxies are limited separately
+	require.NoError(t, rl.allow(context.Background(), newRequest("127.0.0.1:1234", "198.51.100.1")))
+	require.NoError(t, rl.allow(context.Background(), newRequest("127.0.0.1:1234", "198.51.100.2")))
+	requireRateLimitExceeded(t, rl.allow(context.Background(), newRequest("127.0.0.1:1234", "198.51.100.2")), "ip", 2*time.Second)
+}
+
+func TestRateLimiter_SustainedSlidingWindow(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	rl := newTestRateLimiter(t, &now, configuration.Limiter{
+		Name:      "token",
+		Key:       configuration.RateLimitKeyToken,
+		Sustained: 10,
+		Period:    10 * time.Second,
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	r.Header.Set("Authorization", "Bearer abc")
+	for i := 0; i < 10; i++ {
+		require.NoError(t, rl.allow(context.Background(), r))
+	}
+	requireRateLimitExceeded(t, rl.allow(context.Background(), r), "token", 11*time.Second)
+
+	// halfway through the next window, half of the previous one still overlaps with the sliding window and counts as 5
+	now = now.Add(15 * time.Second)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, rl.allow(context.Background(), r))
+	}
+	requireRateLimitExceeded(t, rl.allow(context.Background(), r), "token", time.Second)
+
+	// anonymous requests can't be limited by token
+	anonymous := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	require.NoError(t, rl.allow(context.Background(), anonymous))
+}
+
+func TestRateLimiter_MultipleLimiters(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	rl := newTestRateLimiter(t, &now,
+		configuration.Limiter{Name: "ip", Key: configuration.RateLimitKeyIP, Burst: 100},
+		configuration.Limiter{Name: "repository", Key: configuration.RateLimitKeyRepository, Burst: 1},
+	)
+
+	ctx := context.WithValue(context.Background(), "vars.name", "foo/bar")
+	r := httptest.NewRequest(http.MethodGet, "/v2/foo/bar/tags/list", nil)
+	require.NoError(t, rl.allow(ctx, r))
+	requireRateLimitExceeded(t, rl.allow(ctx, r), "repository", 2*time.Second)
+
+	// requests to other repositories or without a repository are not affected
+	require.NoError(t, rl.allow(context.WithValue(context.Background(), "vars.name", "foo/baz"), r))
+	require.NoError(t, rl.allow(context.Background(), r))
+}
+
+func TestRateLimiter_RedisUnavailable(t *testing.T) {
+	srv := testutil.RedisServer(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	rl, err := newRateLimiter(client, configuration.RateLimiter{Limiters: []configuration.Limiter{
+		{Name: "ip", Key: configuration.RateLimitKeyIP, Burst: 1},
+	}}, nil)
+	require.NoError(t, err)
+	srv.Close()
+
+	r := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	err = rl.allow(dcontext.Background(), r)
+	require.Error(t, err)
+	require.False(t, errors.As(err, &rateLimitExceededError{}))
+}
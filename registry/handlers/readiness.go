@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/health"
+	"github.com/docker/distribution/notifications"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/redis/go-redis/v9"
+)
+
+// RegisterReadinessChecks registers the checks of the dependencies required to serve requests in the given health
+// registry, if enabled. Only the dependencies in use are checked. Like RegisterHealthChecks, this should only be
+// called once per registry process.
+func (app *App) RegisterReadinessChecks(registry *health.Registry) {
+	config := app.Config.Health.Readiness
+	if !config.Enabled {
+		return
+	}
+
+	register := func(name string, threshold int, check health.CheckFunc) {
+		if threshold == 0 {
+			threshold = config.Threshold
+		}
+		dcontext.GetLogger(app).WithField("check", name).Info("configuring readiness check")
+		registry.Register(name, health.NewProbe(check, config.Interval, threshold))
+	}
+
+	if !config.Database.Disabled && app.db != nil {
+		register("database", config.Database.Threshold, func() error {
+			ctx, cancel := context.WithTimeout(app, config.Timeout)
+			defer cancel()
+			return app.db.PingContext(ctx)
+		})
+	}
+
+	if !config.Redis.Disabled {
+		if app.redis != nil {
+			register("redis", config.Redis.Threshold, pingRedis(app, app.redis, config.Timeout))
+		}
+		if app.redisCacheClient != nil {
+			register("redis_cache", config.Redis.Threshold, pingRedis(app, app.redisCacheClient, config.Timeout))
+		}
+	}
+
+	if !config.StorageDriver.Disabled {
+		register("storagedriver_"+app.Config.Storage.Type(), config.StorageDriver.Threshold, func() error {
+			ctx, cancel := context.WithTimeout(app, config.Timeout)
+			defer cancel()
+
+			_, err := app.driver.Stat(ctx, "/") // "/" should always exist
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				err = nil // pass this through, backend is responding, but this path doesn't exist.
+			}
+			return err
+		})
+	}
+
+	// the notification endpoints can be reloaded, so they are only looked up when checking. The check passes if there
+	// are none.
+	if !config.Notifications.Disabled {
+		register("notifications", config.Notifications.Threshold, func() error {
+			for _, e := range app.notificationEndpoints() {
+				var em notifications.EndpointMetrics
+				e.ReadMetrics(&em)
+				if em.Pending > config.Notifications.MaxPending {
+					return fmt.Errorf("endpoint %q has %d events pending delivery, over the limit of %d", e.Name(), em.Pending, config.Notifications.MaxPending)
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// pingRedis returns a check that pings the Redis server(s) behind client.
+func pingRedis(ctx context.Context, client redis.UniversalClient, timeout time.Duration) health.CheckFunc {
+	return func() error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return client.Ping(ctx).Err()
+	}
+}
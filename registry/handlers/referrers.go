@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	referrersArtifactTypeQueryParamKey = "artifactType"
+	// filtersAppliedHeader lists the filters applied to the response of a listing request, as defined in the OCI
+	// Distribution spec v1.1.
+	filtersAppliedHeader = "OCI-Filters-Applied"
+)
+
+// referrersDispatcher constructs the referrers handler api endpoint.
+func referrersDispatcher(ctx *Context, r *http.Request) http.Handler {
+	dgst, err := getDigest(ctx)
+	if err != nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx.Errors = append(ctx.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+		})
+	}
+
+	referrersHandler := &referrersHandler{
+		Context: ctx,
+		Digest:  dgst,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(referrersHandler.GetReferrers),
+	}
+}
+
+// referrersHandler handles requests for the referrers of a manifest.
+type referrersHandler struct {
+	*Context
+
+	Digest digest.Digest
+}
+
+// referrerDescriptor describes a referrer, as defined by the OCI distribution spec. The artifact type is not part of
+// distribution.Descriptor, hence this dedicated type.
+type referrerDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       digest.Digest     `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type referrersAPIResponse struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     string               `json:"mediaType"`
+	Manifests     []referrerDescriptor `json:"manifests"`
+}
+
+// newReferrerDescriptor builds the descriptor of the referrer with the given media type, digest and payload. The
+// artifact type is that of the manifest or, for image manifests without one, the media type of their configuration.
+func newReferrerDescriptor(mediaType string, dgst digest.Digest, payload []byte) (referrerDescriptor, error) {
+	var m struct {
+		ArtifactType string `json:"artifactType"`
+		Config       struct {
+			MediaType string `json:"mediaType"`
+		} `json:"config"`
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return referrerDescriptor{}, fmt.Errorf("parsing referrer %s: %w", dgst, err)
+	}
+
+	artifactType := m.ArtifactType
+	if artifactType == "" && mediaType == v1.MediaTypeImageManifest {
+		artifactType = m.Config.MediaType
+	}
+
+	return referrerDescriptor{
+		MediaType:    mediaType,
+		ArtifactType: artifactType,
+		Digest:       dgst,
+		Size:         int64(len(payload)),
+		Annotations:  m.Annotations,
+	}, nil
+}
+
+// dbGetReferrers returns the descriptors of the manifests that reference the manifest with digest dgst as their
+// subject. Following the OCI distribution spec, the list is empty if the repository or the subject are not found.
+func dbGetReferrers(ctx context.Context, db datastore.Queryer, repoPath string, dgst digest.Digest) ([]referrerDescriptor, error) {
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": repoPath, "digest": dgst})
+	l.Debug("finding referrers in database")
+
+	rStore := datastore.NewRepositoryStore(db)
+	r, err := rStore.FindByPath(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, nil
+	}
+	m, err := rStore.FindManifestByDigest(ctx, r, dgst)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, nil
+	}
+
+	mm, err := rStore.ManifestReferrers(ctx, r, m)
+	if err != nil {
+		return nil, err
+	}
+	tsmm, err := rStore.ManifestTagSchemaReferrers(ctx, r, m)
+	if err != nil {
+		return nil, err
+	}
+	mm = mergeReferrers(mm, tsmm)
+
+	descs := make([]referrerDescriptor, 0, len(mm))
+	for _, ref := range mm {
+		desc, err := newReferrerDescriptor(ref.MediaType, ref.Digest, ref.Payload)
+		if err != nil {
+			return nil, err
+		}
+		descs = append(descs, desc)
+	}
+
+	return descs, nil
+}
+
+// mergeReferrers merges the referrers declaring their subject directly with those indexed through the referrers tag
+// schema, ordered by ID and without duplicates.
+func mergeReferrers(mm, tsmm models.Manifests) models.Manifests {
+	seen := make(map[int64]struct{}, len(mm))
+	for _, m := range mm {
+		seen[m.ID] = struct{}{}
+	}
+	for _, m := range tsmm {
+		if _, ok := seen[m.ID]; !ok {
+			mm = append(mm, m)
+		}
+	}
+	sort.Slice(mm, func(i, j int) bool { return mm[i].ID < mm[j].ID })
+
+	return mm
+}
+
+// fsGetReferrers returns the descriptors of the manifests that reference the manifest with digest dgst as their
+// subject. Referrers are not indexed in the filesystem metadata, so this requires reading all manifests in the
+// repository. The undecorated repository is used to avoid emitting a pull event for each manifest read.
+func (h *referrersHandler) fsGetReferrers() ([]referrerDescriptor, error) {
+	repo, err := h.App.registry.Repository(h, h.Repository.Named())
+	if err != nil {
+		return nil, err
+	}
+	ms, err := repo.Manifests(h)
+	if err != nil {
+		return nil, err
+	}
+	enumerator, ok := ms.(distribution.ManifestEnumerator)
+	if !ok {
+		return nil, distribution.ErrUnsupported
+	}
+
+	var descs []referrerDescriptor
+	err = enumerator.Enumerate(h, func(dgst digest.Digest) error {
+		m, err := ms.Get(h, dgst)
+		if err != nil {
+			return fmt.Errorf("fetching manifest %s: %w", dgst, err)
+		}
+		ocim, ok := m.(distribution.ManifestOCI)
+		if !ok || ocim.Subject().Digest != h.Digest {
+			return nil
+		}
+
+		mediaType, payload, err := m.Payload()
+		if err != nil {
+			return err
+		}
+		desc, err := newReferrerDescriptor(mediaType, dgst, payload)
+		if err != nil {
+			return err
+		}
+		descs = append(descs, desc)
+		return nil
+	})
+	if err != nil {
+		if errors.As(err, &storagedriver.PathNotFoundError{}) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return descs, nil
+}
+
+// GetReferrers returns an image index listing the referrers of a manifest, optionally filtered by artifact type.
+func (h *referrersHandler) GetReferrers(w http.ResponseWriter, r *http.Request) {
+	log.GetLogger(log.WithContext(h)).Debug("GetReferrers")
+
+	var descs []referrerDescriptor
+	var err error
+	if h.useDatabase {
+		path := h.Repository.Named().Name()
+		descs, err = dbGetReferrers(h.Context, h.App.replicaDB(h, path), path, h.Digest)
+	} else {
+		descs, err = h.fsGetReferrers()
+	}
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	artifactType := r.URL.Query().Get(referrersArtifactTypeQueryParamKey)
+	manifests := make([]referrerDescriptor, 0, len(descs))
+	for _, desc := range descs {
+		if artifactType == "" || desc.ArtifactType == artifactType {
+			manifests = append(manifests, desc)
+		}
+	}
+
+	w.Header().Set("Content-Type", v1.MediaTypeImageIndex)
+	if artifactType != "" {
+		w.Header().Set(filtersAppliedHeader, referrersArtifactTypeQueryParamKey)
+	}
+
+	if err := json.NewEncoder(w).Encode(referrersAPIResponse{
+		SchemaVersion: 2,
+		MediaType:     v1.MediaTypeImageIndex,
+		Manifests:     manifests,
+	}); err != nil {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
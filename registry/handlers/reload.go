@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Reload applies the settings of config that can be changed at runtime: rate limits, notification endpoints and the
+// token authentication settings, such as its root certificate bundle. Each setting is validated before replacing the
+// one in use, independently of the others. The errors of the settings that couldn't be applied are returned, in which
+// case their current value is kept.
+func (app *App) Reload(config *configuration.Configuration) error {
+	var errs *multierror.Error
+	if err := app.reloadRateLimiter(config); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("reloading rate limits: %w", err))
+	}
+	if err := app.reloadNotificationEndpoints(config); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("reloading notification endpoints: %w", err))
+	}
+	if err := app.reloadAccessController(config); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("reloading auth: %w", err))
+	}
+	return errs.ErrorOrNil()
+}
+
+func (app *App) currentAccessController() auth.AccessController {
+	app.reloadMu.RLock()
+	defer app.reloadMu.RUnlock()
+	return app.accessController
+}
+
+func (app *App) currentRateLimiter() *rateLimiter {
+	app.reloadMu.RLock()
+	defer app.reloadMu.RUnlock()
+	return app.rateLimiter
+}
+
+// notificationEndpoints returns the notification endpoints in use.
+func (app *App) notificationEndpoints() []*notifications.Endpoint {
+	app.reloadMu.RLock()
+	defer app.reloadMu.RUnlock()
+	return app.events.endpoints
+}
+
+// notificationEndpointConfigured determines whether a notification endpoint with the given name is configured, even
+// if disabled.
+func (app *App) notificationEndpointConfigured(name string) bool {
+	app.reloadMu.RLock()
+	defer app.reloadMu.RUnlock()
+
+	for _, e := range app.events.endpointsConfig {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *App) reloadRateLimiter(config *configuration.Configuration) error {
+	var rl *rateLimiter
+	if config.RateLimiter.Enabled {
+		if app.redisCacheClient == nil {
+			return errors.New("rate limiting requires the redis cache to be available")
+		}
+		var err error
+		if rl, err = newRateLimiter(app.redisCacheClient, config.RateLimiter, app.trustedProxies); err != nil {
+			return err
+		}
+	}
+
+	app.reloadMu.Lock()
+	app.rateLimiter = rl
+	app.reloadMu.Unlock()
+
+	dcontext.GetLoggerWithField(app, "limiters", len(config.RateLimiter.Limiters)).Info("rate limits reloaded")
+	return nil
+}
+
+// reloadNotificationEndpoints replaces the notification endpoints if their configuration changed. The events pending
+// delivery to the previous endpoints are flushed in the background. The notifications queue configuration can't be
+// reloaded, so the new endpoints keep using the current queue.
+func (app *App) reloadNotificationEndpoints(config *configuration.Configuration) error {
+	if app.events.endpointsSink == nil {
+		return errors.New("notifications are not configured")
+	}
+
+	app.reloadMu.RLock()
+	unchanged := reflect.DeepEqual(app.events.endpointsConfig, config.Notifications.Endpoints)
+	app.reloadMu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	endpoints, err := app.newNotificationEndpoints(config.Notifications.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	app.reloadMu.Lock()
+	app.events.endpoints = endpoints
+	app.events.endpointsConfig = config.Notifications.Endpoints
+	app.reloadMu.Unlock()
+	previous := app.events.endpointsSink.swap(newEndpointsBroadcaster(endpoints))
+
+	l := dcontext.GetLoggerWithField(app, "endpoints", len(endpoints))
+	l.Info("notification endpoints reloaded")
+	go func() {
+		if err := previous.Close(); err != nil {
+			l.WithError(err).Error("failed to close previous notification endpoints")
+		}
+	}()
+
+	return nil
+}
+
+// reloadAccessController replaces the access controller of the token auth method, so that changes to its settings,
+// such as a rotated root certificate bundle, are applied. Other auth methods have nothing to reload, and changing the
+// auth method requires a restart.
+func (app *App) reloadAccessController(config *configuration.Configuration) error {
+	current := app.Config.Auth.Type()
+	if !strings.EqualFold(config.Auth.Type(), current) {
+		return fmt.Errorf("changing the auth method from %q to %q requires a restart", current, config.Auth.Type())
+	}
+	if current != "token" {
+		return nil
+	}
+
+	ac, err := app.newAccessController(config.Auth)
+	if err != nil {
+		return err
+	}
+
+	app.reloadMu.Lock()
+	app.accessController = ac
+	app.reloadMu.Unlock()
+
+	dcontext.GetLogger(app).Info("token auth settings reloaded")
+	return nil
+}
+
+// swappableSink is a notifications.Sink writing to a sink that can be replaced at runtime.
+type swappableSink struct {
+	mu   sync.RWMutex
+	sink notifications.Sink
+}
+
+func newSwappableSink(sink notifications.Sink) *swappableSink {
+	return &swappableSink{sink: sink}
+}
+
+// Write writes event to the current sink.
+func (s *swappableSink) Write(event *notifications.Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sink.Write(event)
+}
+
+// Close closes the current sink.
+func (s *swappableSink) Close() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sink.Close()
+}
+
+// swap replaces the current sink, returning the previous one. No event is written to the previous sink once swap
+// returns, so that it can be closed.
+func (s *swappableSink) swap(sink notifications.Sink) notifications.Sink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.sink
+	s.sink = sink
+	return previous
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRootCertBundle writes a self-signed certificate to a temporary file, returning its path.
+func writeRootCertBundle(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "token-issuer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "root.crt")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	return path
+}
+
+func tokenAuthConfig(realm, rootCertBundle string) configuration.Auth {
+	return configuration.Auth{
+		"token": {
+			"realm":          realm,
+			"service":        "service-test",
+			"issuer":         "issuer-test",
+			"rootcertbundle": rootCertBundle,
+		},
+	}
+}
+
+func TestReload_AccessController(t *testing.T) {
+	bundle := writeRootCertBundle(t)
+	config := testConfig()
+	config.Auth = tokenAuthConfig("realm-a", bundle)
+	app, err := NewApp(context.Background(), config)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	realm := func() string {
+		resp, err := http.Get(server.URL + "/v2/")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		return resp.Header.Get("WWW-Authenticate")
+	}
+	require.Contains(t, realm(), `realm="realm-a"`)
+
+	reloaded := testConfig()
+	reloaded.Auth = tokenAuthConfig("realm-b", bundle)
+	require.NoError(t, app.Reload(reloaded))
+	require.Contains(t, realm(), `realm="realm-b"`)
+
+	// invalid settings are not applied
+	reloaded.Auth = tokenAuthConfig("realm-c", filepath.Join(t.TempDir(), "missing.crt"))
+	require.Error(t, app.Reload(reloaded))
+	require.Contains(t, realm(), `realm="realm-b"`)
+
+	// the auth method can't be changed
+	require.ErrorContains(t, app.Reload(testConfig()), `changing the auth method from "token" to "silly" requires a restart`)
+	require.Contains(t, realm(), `realm="realm-b"`)
+}
+
+func TestReload_NotificationEndpoints(t *testing.T) {
+	app, err := NewApp(context.Background(), testConfig())
+	require.NoError(t, err)
+	require.Empty(t, app.notificationEndpoints())
+
+	reloaded := testConfig()
+	reloaded.Notifications.Endpoints = []configuration.Endpoint{
+		{Name: "a", URL: "http://127.0.0.1:1/events", Timeout: time.Second, Threshold: 1, Backoff: time.Second},
+		{Name: "b", URL: "http://127.0.0.1:1/events", Disabled: true},
+	}
+	require.NoError(t, app.Reload(reloaded))
+
+	endpoints := app.notificationEndpoints()
+	require.Len(t, endpoints, 1)
+	require.Equal(t, "a", endpoints[0].Name())
+	require.True(t, app.notificationEndpointConfigured("b"))
+
+	// endpoints are not replaced if their configuration didn't change
+	require.NoError(t, app.Reload(reloaded))
+	require.Same(t, endpoints[0], app.notificationEndpoints()[0])
+
+	// invalid endpoints are not applied
+	invalid := testConfig()
+	invalid.Notifications.Endpoints = []configuration.Endpoint{{Name: "c", Type: "carrier-pigeon"}}
+	require.Error(t, app.Reload(invalid))
+	require.Same(t, endpoints[0], app.notificationEndpoints()[0])
+	require.False(t, app.notificationEndpointConfigured("c"))
+
+	require.NoError(t, app.Reload(testConfig()))
+	require.Empty(t, app.notificationEndpoints())
+}
+
+func TestReload_RateLimiter(t *testing.T) {
+	app, err := NewApp(context.Background(), testConfig())
+	require.NoError(t, err)
+
+	reloaded := testConfig()
+	reloaded.RateLimiter.Enabled = true
+	reloaded.RateLimiter.Limiters = []configuration.Limiter{{Name: "ip", Key: configuration.RateLimitKeyIP, Burst: 10}}
+	require.ErrorContains(t, app.Reload(reloaded), "rate limiting requires the redis cache to be available")
+	require.Nil(t, app.currentRateLimiter())
+
+	require.NoError(t, app.Reload(testConfig()))
+	require.Nil(t, app.currentRateLimiter())
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/api/urls"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// remoteMountRegistryParam is the blob upload query parameter that holds the base URL of the remote registry to
+	// mount a blob from, e.g. `https://registry.example.com`.
+	remoteMountRegistryParam = "from_registry"
+	// remoteMountAuthorizationHeader is the request header that holds the credentials (typically a bearer token)
+	// used to pull the blob from the remote registry. Its value is forwarded as is in the Authorization header.
+	remoteMountAuthorizationHeader = "Gitlab-Remote-Mount-Authorization"
+
+	defaultRemoteMountTimeout = 10 * time.Minute
+)
+
+var (
+	errRemoteMountBlobUnknown = errors.New("blob unknown to remote registry")
+	errRemoteMountDenied      = errors.New("access to remote blob denied")
+)
+
+// remoteMounter fetches blobs from allowed remote registries.
+type remoteMounter struct {
+	client     *http.Client
+	registries map[string]struct{}
+	insecure   bool
+}
+
+func newRemoteMounter(config configuration.RemoteMount) (*remoteMounter, error) {
+	if len(config.Registries) == 0 {
+		return nil, errors.New("at least one registry must be configured")
+	}
+
+	registries := make(map[string]struct{}, len(config.Registries))
+	for i, r := range config.Registries {
+		if r == "" || strings.Contains(r, "/") {
+			return nil, fmt.Errorf("registries[%d]: invalid host %q, must be in the form host[:port]", i, r)
+		}
+		registries[strings.ToLower(r)] = struct{}{}
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultRemoteMountTimeout
+	}
+
+	return &remoteMounter{
+		client:     &http.Client{Timeout: timeout},
+		registries: registries,
+		insecure:   config.Insecure,
+	}, nil
+}
+
+// blobURL validates the remote registry base URL against the list of allowed registries and returns the URL of the
+// target blob on the remote registry.
+func (rm *remoteMounter) blobURL(registry string, repo reference.Named, dgst digest.Digest) (string, error) {
+	u, err := url.Parse(registry)
+	if err != nil {
+		return "", fmt.Errorf("parsing remote registry URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !rm.insecure {
+			return "", fmt.Errorf("insecure remote registry %q not allowed", u.Host)
+		}
+	default:
+		return "", fmt.Errorf("unsupported remote registry URL scheme %q", u.Scheme)
+	}
+	if _, ok := rm.registries[strings.ToLower(u.Host)]; !ok {
+		return "", fmt.Errorf("remote registry %q not allowed", u.Host)
+	}
+
+	ref, err := reference.WithDigest(repo, dgst)
+	if err != nil {
+		return "", err
+	}
+
+	return urls.NewBuilder(&url.URL{Scheme: u.Scheme, Host: u.Host}, false).BuildBlobURL(ref)
+}
+
+// fetch issues a GET request for the blob at the given URL. The caller is responsible for closing the response body.
+func (rm *remoteMounter) fetch(ctx context.Context, blobURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blobURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authorization != "" {
+		// The Go HTTP client does not forward this header when following redirects to a different host, so
+		// credentials are not leaked to blob storage backends.
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := rm.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching remote blob: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound:
+		err = errRemoteMountBlobUnknown
+	case http.StatusUnauthorized, http.StatusForbidden:
+		err = errRemoteMountDenied
+	default:
+		err = fmt.Errorf("fetching remote blob: unexpected status code %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	return nil, err
+}
+
+// remoteMountBlob fetches a blob from a remote registry and streams it straight into the storage backend, verifying
+// its digest along the way. This spares clients from downloading and re-uploading blobs that already exist elsewhere.
+func (buh *blobUploadHandler) remoteMountBlob(w http.ResponseWriter, r *http.Request, registry, fromRepo, mountDigest string) {
+	if buh.App.remoteMounter == nil {
+		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnsupported.WithDetail("cross-registry blob mounting is disabled"))
+		return
+	}
+
+	dgst, err := digest.Parse(mountDigest)
+	if err != nil {
+		buh.Errors = append(buh.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+		return
+	}
+	repo, err := reference.WithName(fromRepo)
+	if err != nil {
+		buh.Errors = append(buh.Errors, v2.ErrorCodeNameInvalid.WithDetail(err))
+		return
+	}
+	blobURL, err := buh.App.remoteMounter.blobURL(registry, repo, dgst)
+	if err != nil {
+		buh.Errors = append(buh.Errors, errcode.ErrorCodeDenied.WithDetail(err.Error()))
+		return
+	}
+
+	l := log.GetLogger(log.WithContext(buh)).WithFields(log.Fields{
+		"remote_registry": registry,
+		"source":          fromRepo,
+		"destination":     buh.Repository.Named().Name(),
+		"digest":          dgst,
+	})
+	l.Info("cross registry blob mounting")
+
+	resp, err := buh.App.remoteMounter.fetch(buh, blobURL, r.Header.Get(remoteMountAuthorizationHeader))
+	if err != nil {
+		switch {
+		case errors.Is(err, errRemoteMountBlobUnknown):
+			buh.Errors = append(buh.Errors, v2.ErrorCodeBlobUnknown.WithDetail(dgst))
+		case errors.Is(err, errRemoteMountDenied):
+			buh.Errors = append(buh.Errors, errcode.ErrorCodeDenied.WithDetail(err.Error()))
+		default:
+			buh.Errors = append(buh.Errors, errcode.FromUnknownError(err))
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	upload, err := buh.Repository.Blobs(buh).Create(buh)
+	if err != nil {
+		if errors.Is(err, distribution.ErrUnsupported) {
+			buh.Errors = append(buh.Errors, errcode.ErrorCodeUnsupported)
+		} else {
+			buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+		return
+	}
+	defer upload.Close()
+
+	desc, err := buh.copyRemoteBlob(upload, resp.Body, dgst)
+	if err != nil {
+		switch err := err.(type) {
+		case distribution.ErrBlobInvalidDigest:
+			buh.Errors = append(buh.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+		default:
+			l.WithError(err).Error("error mounting remote blob")
+			buh.Errors = append(buh.Errors, errcode.FromUnknownError(err))
+		}
+
+		// Clean up the backend blob data if there was an error.
+		if err := upload.Cancel(buh); err != nil {
+			l.WithError(err).Error("error canceling upload after error")
+		}
+		return
+	}
+
+	if buh.useDatabase {
+		var opts []datastore.RepositoryStoreOption
+		if buh.App.redisCache != nil {
+			opts = append(opts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(buh.App.redisCache)))
+		}
+		repo, err := dbPutBlobUploadComplete(buh.Context, buh.db, buh.Repository.Named().Name(), desc, opts)
+		if err != nil {
+			e := fmt.Errorf("failed to create blob in database: %w", err)
+			buh.Errors = append(buh.Errors, errcode.FromUnknownError(e))
+			return
+		}
+		buh.recordNamespaceBytesAdded(repo.NamespaceID, desc.Size)
+	}
+
+	if err := buh.writeBlobCreatedHeaders(w, desc); err != nil {
+		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	l.WithFields(log.Fields{"size_bytes": desc.Size}).Info("remote blob mounted")
+	buh.recordAudit(audit.Record{Action: audit.ActionMount, Artifact: audit.ArtifactBlob, Digest: desc.Digest, MediaType: desc.MediaType})
+}
+
+func (buh *blobUploadHandler) copyRemoteBlob(upload distribution.BlobWriter, body io.Reader, dgst digest.Digest) (distribution.Descriptor, error) {
+	if _, err := upload.ReadFrom(body); err != nil {
+		return distribution.Descriptor{}, fmt.Errorf("copying remote blob: %w", err)
+	}
+
+	return upload.Commit(buh, distribution.Descriptor{Digest: dgst})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRemoteMounter(t *testing.T) {
+	tcs := []struct {
+		name       string
+		config     configuration.RemoteMount
+		wantErr    bool
+		wantClient bool
+	}{
+		{
+			name:    "no registries",
+			config:  configuration.RemoteMount{Enabled: true},
+			wantErr: true,
+		},
+		{
+			name:    "registry with scheme",
+			config:  configuration.RemoteMount{Enabled: true, Registries: []string{"https://registry.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty registry",
+			config:  configuration.RemoteMount{Enabled: true, Registries: []string{""}},
+			wantErr: true,
+		},
+		{
+			name:   "valid",
+			config: configuration.RemoteMount{Enabled: true, Registries: []string{"registry.example.com", "Registry.Example.org:5000"}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			rm, err := newRemoteMounter(tc.config)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, defaultRemoteMountTimeout, rm.client.Timeout)
+			require.Contains(t, rm.registries, "registry.example.org:5000")
+		})
+	}
+}
+
+func TestRemoteMounter_BlobURL(t *testing.T) {
+	repo, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	dgst := digest.FromString("foo")
+
+	tcs := []struct {
+		name     string
+		insecure bool
+		registry string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "allowed",
+			registry: "https://registry.example.com",
+			want:     "https://registry.example.com/v2/foo/bar/blobs/" + dgst.String(),
+		},
+		{
+			name:     "allowed with path is ignored",
+			registry: "https://REGISTRY.example.com/some/path",
+			want:     "https://REGISTRY.example.com/v2/foo/bar/blobs/" + dgst.String(),
+		},
+		{
+			name:     "not allowed",
+			registry: "https://registry.example.org",
+			wantErr:  true,
+		},
+		{
+			name:     "insecure not allowed",
+			registry: "http://registry.example.com",
+			wantErr:  true,
+		},
+		{
+			name:     "insecure allowed",
+			insecure: true,
+			registry: "http://registry.example.com",
+			want:     "http://registry.example.com/v2/foo/bar/blobs/" + dgst.String(),
+		},
+		{
+			name:     "no scheme",
+			registry: "registry.example.com",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			rm, err := newRemoteMounter(configuration.RemoteMount{
+				Registries: []string{"registry.example.com"},
+				Insecure:   tc.insecure,
+			})
+			require.NoError(t, err)
+
+			got, err := rm.blobURL(tc.registry, repo, dgst)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRemoteMounter_Fetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer valid":
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("blob"))
+		case "Bearer error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	rm, err := newRemoteMounter(configuration.RemoteMount{Registries: []string{u.Host}, Insecure: true})
+	require.NoError(t, err)
+
+	resp, err := rm.fetch(context.Background(), srv.URL+"/blob", "Bearer valid")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "blob", string(b))
+
+	_, err = rm.fetch(context.Background(), srv.URL+"/missing", "Bearer valid")
+	require.ErrorIs(t, err, errRemoteMountBlobUnknown)
+
+	_, err = rm.fetch(context.Background(), srv.URL+"/blob", "")
+	require.ErrorIs(t, err, errRemoteMountDenied)
+
+	_, err = rm.fetch(context.Background(), srv.URL+"/blob", "Bearer error")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, errRemoteMountBlobUnknown)
+	require.NotErrorIs(t, err, errRemoteMountDenied)
+}
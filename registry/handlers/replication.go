@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
+)
+
+// replicationSource is a replication.Source that reads manifests from the metadata database, or from the filesystem
+// metadata if the database is disabled, and blobs from the storage backend.
+type replicationSource struct {
+	app *App
+}
+
+// Manifest implements replication.Source.
+func (s *replicationSource) Manifest(ctx context.Context, repo reference.Named, dgst digest.Digest) (distribution.Manifest, error) {
+	if !s.app.Config.Database.Enabled {
+		r, err := s.app.registry.Repository(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		ms, err := r.Manifests(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ms.Get(ctx, dgst)
+	}
+
+	rStore := datastore.NewRepositoryStore(s.app.db)
+	r, err := rStore.FindByPath(ctx, repo.Name())
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, distribution.ErrRepositoryUnknown{Name: repo.Name()}
+	}
+	m, err := rStore.FindManifestByDigest(ctx, r, dgst)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, distribution.ErrManifestUnknownRevision{Name: repo.Name(), Revision: dgst}
+	}
+
+	return dbManifestToManifest(m)
+}
+
+// Blob implements replication.Source.
+func (s *replicationSource) Blob(ctx context.Context, _ reference.Named, dgst digest.Digest) (io.ReadCloser, error) {
+	bp, ok := s.app.registry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		return nil, errors.New("unable to convert BlobEnumerator into BlobProvider")
+	}
+
+	return bp.Open(ctx, dgst)
+}
+
+type replicationHandler struct {
+	*Context
+}
+
+func replicationDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	replicationHandler := &replicationHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(replicationHandler.GetReplication),
+	}
+}
+
+// ReplicationTargetAPIResponse describes the replication state of a downstream registry.
+type ReplicationTargetAPIResponse struct {
+	Name             string `json:"name"`
+	URL              string `json:"url"`
+	Pending          int    `json:"pending"`
+	Replicated       int64  `json:"replicated"`
+	Failed           int64  `json:"failed"`
+	Dropped          int64  `json:"dropped"`
+	LastReplicatedAt string `json:"last_replicated_at,omitempty"`
+	LastFailedAt     string `json:"last_failed_at,omitempty"`
+	LastError        string `json:"last_error,omitempty"`
+}
+
+// ReplicationAPIResponse is the response body for the replication endpoint.
+type ReplicationAPIResponse struct {
+	Targets []ReplicationTargetAPIResponse `json:"targets"`
+}
+
+// GetReplication returns the replication state of each enabled downstream registry in this registry instance.
+func (h *replicationHandler) GetReplication(w http.ResponseWriter, r *http.Request) {
+	if h.App.replicator == nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail("replication is not enabled"))
+		return
+	}
+
+	ss := h.App.replicator.Status()
+	resp := ReplicationAPIResponse{Targets: make([]ReplicationTargetAPIResponse, 0, len(ss))}
+	for _, s := range ss {
+		t := ReplicationTargetAPIResponse{
+			Name:       s.Name,
+			URL:        s.URL,
+			Pending:    s.Pending,
+			Replicated: s.Replicated,
+			Failed:     s.Failed,
+			Dropped:    s.Dropped,
+			LastError:  s.LastError,
+		}
+		if !s.LastReplicatedAt.IsZero() {
+			t.LastReplicatedAt = timeToString(s.LastReplicatedAt)
+		}
+		if !s.LastFailedAt.IsZero() {
+			t.LastFailedAt = timeToString(s.LastFailedAt)
+		}
+		resp.Targets = append(resp.Targets, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
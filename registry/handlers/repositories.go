@@ -2,27 +2,35 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
 	"path"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/docker/distribution"
 	"github.com/docker/distribution/internal/feature"
 	"github.com/docker/distribution/log"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
 	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
 	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/storage"
 
 	"github.com/gorilla/handlers"
 	"github.com/jackc/pgconn"
@@ -50,21 +58,55 @@ type RepositoryAPIResponse struct {
 	Path          string `json:"path"`
 	Size          *int64 `json:"size_bytes,omitempty"`
 	SizePrecision string `json:"size_precision,omitempty"`
-	CreatedAt     string `json:"created_at"`
-	UpdatedAt     string `json:"updated_at,omitempty"`
+	// SizeBreakdown is only set when requesting the size with `size=breakdown`.
+	SizeBreakdown *RepositorySizeBreakdownAPIResponse `json:"size_breakdown,omitempty"`
+	CreatedAt     string                              `json:"created_at"`
+	UpdatedAt     string                              `json:"updated_at,omitempty"`
+	// Metadata is only set if the repository has metadata.
+	Metadata *RepositoryMetadataAPIResponse `json:"metadata,omitempty"`
 }
+
+// RepositorySizeBreakdownAPIResponse is the API counterpart for models.RepositorySizeBreakdown.
+type RepositorySizeBreakdownAPIResponse struct {
+	Layers        int64                  `json:"layers_bytes"`
+	UniqueLayers  int64                  `json:"unique_layers_bytes"`
+	SharedLayers  int64                  `json:"shared_layers_bytes"`
+	Configs       int64                  `json:"configs_bytes"`
+	Manifests     int64                  `json:"manifests_bytes"`
+	LargestImages []ImageSizeAPIResponse `json:"largest_images"`
+}
+
+// ImageSizeAPIResponse is the API counterpart for models.ImageSize.
+type ImageSizeAPIResponse struct {
+	Tag       string `json:"tag"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size_bytes"`
+}
+
 type RenameRepositoryAPIResponse struct {
 	TTL time.Time `json:"ttl"`
 }
 
+// RenameRepositoryResultAPIResponse is the response body of a rename request that also moved the filesystem metadata.
+type RenameRepositoryResultAPIResponse struct {
+	RepositoriesCount int `json:"repositories_count"`
+	MovedFilesCount   int `json:"moved_files_count"`
+}
+
 type RenameRepositoryAPIRequest struct {
 	Name string `json:"name"`
+	// MoveFilesystemMetadata enables moving the filesystem metadata (if any) of the renamed repositories, such as
+	// in-progress blob uploads, to the new path.
+	MoveFilesystemMetadata bool `json:"move_filesystem_metadata,omitempty"`
 }
 
 const (
 	sizeQueryParamKey                      = "size"
 	sizeQueryParamSelfValue                = "self"
 	sizeQueryParamSelfWithDescendantsValue = "self_with_descendants"
+	sizeQueryParamBreakdownValue           = "breakdown"
+	sizeBreakdownLargestImagesLimit        = 10
 	nQueryParamKey                         = "n"
 	nQueryParamValueMin                    = 1
 	nQueryParamValueMax                    = 1000
@@ -72,11 +114,20 @@ const (
 	lastQueryParamKey                      = "last"
 	dryRunParamKey                         = "dry_run"
 	tagNameQueryParamKey                   = "name"
+	tagNamePrefixQueryParamKey             = "name_prefix"
+	tagNameRegexQueryParamKey              = "name_regex"
+	tagNameRegexQueryParamMaxLength        = 256
+	artifactTypeQueryParamKey              = "artifact_type"
 	sortQueryParamKey                      = "sort"
 	publishedAtQueryParamKey               = "published_at"
 	sortOrderDescPrefix                    = "-"
 	defaultDryRunRenameOperationTimeout    = 5 * time.Second
+	renameMetadataMoveTimeout              = 30 * time.Second
 	maxRepositoriesToRename                = 1000
+	tagNamesBodyParamKey                   = "names"
+	tagNameRegexBodyParamKey               = "name_regex"
+	tagNameRegexBodyParamMaxLength         = 256
+	maxTagsToBulkDelete                    = 1000
 )
 
 var (
@@ -85,6 +136,7 @@ var (
 	sizeQueryParamValidValues = []string{
 		sizeQueryParamSelfValue,
 		sizeQueryParamSelfWithDescendantsValue,
+		sizeQueryParamBreakdownValue,
 	}
 
 	// sortQueryParamValidValues is a list of accepted values to sort by.
@@ -98,6 +150,10 @@ var (
 
 	tagQueryParamPattern = reference.TagRegexp
 
+	// tagNameBodyParamPattern matches valid tag names, as described in
+	// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests.
+	tagNameBodyParamPattern = regexp.MustCompile("^" + reference.TagRegexp.String() + "$")
+
 	// tagNameQueryParamPattern is a modified version of the OCI Distribution tag name regexp pattern (described in
 	// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests) to allow the tag name
 	// filter string to start with `.` and `-` characters (not just `_`). This is required to support a partial match.
@@ -180,6 +236,22 @@ const (
 	sizePrecisionUntagged = "untagged"
 )
 
+// sizeWithDescendants returns the size of repo and all of its descendants, falling back to an estimate that also
+// accounts for untagged layers if the precise measurement times out. The precision of the returned size is either
+// sizePrecisionDefault or sizePrecisionUntagged.
+func sizeWithDescendants(ctx context.Context, store datastore.RepositoryStore, repo *models.Repository) (int64, string, error) {
+	size, err := store.SizeWithDescendants(ctx, repo)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		// if this same query has timed out in the last 24h OR times out now, fallback to estimation
+		if errors.Is(err, datastore.ErrSizeHasTimedOut) || (errors.As(err, &pgErr) && pgErr.Code == pgerrcode.QueryCanceled) {
+			size, err = store.EstimatedSizeWithDescendants(ctx, repo)
+			return size, sizePrecisionUntagged, err
+		}
+	}
+	return size, sizePrecisionDefault, err
+}
+
 func (h *repositoryHandler) GetRepository(w http.ResponseWriter, r *http.Request) {
 	l := log.GetLogger(log.WithContext(h)).WithFields(log.Fields{"path": h.Repository.Named().Name()})
 	l.Debug("GetRepository")
@@ -245,6 +317,17 @@ func (h *repositoryHandler) GetRepository(w http.ResponseWriter, r *http.Request
 	if repo.UpdatedAt.Valid {
 		resp.UpdatedAt = timeToString(repo.UpdatedAt.Time)
 	}
+	// repositories that don't exist have no metadata, see the size_with_descendants special case above
+	if repo.ID != 0 {
+		m, err := datastore.NewRepositoryMetadataStore(h.db).FindByRepository(h.Context, repo)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		if m != nil {
+			resp.Metadata = newRepositoryMetadataAPIResponse(m)
+		}
+	}
 
 	if withSize {
 		var size int64
@@ -256,16 +339,15 @@ func (h *repositoryHandler) GetRepository(w http.ResponseWriter, r *http.Request
 		switch sizeVal {
 		case sizeQueryParamSelfValue:
 			size, err = store.Size(ctx, repo)
-		case sizeQueryParamSelfWithDescendantsValue:
-			size, err = store.SizeWithDescendants(ctx, repo)
-			if err != nil {
-				var pgErr *pgconn.PgError
-				// if this same query has timed out in the last 24h OR times out now, fallback to estimation
-				if errors.Is(err, datastore.ErrSizeHasTimedOut) || (errors.As(err, &pgErr) && pgErr.Code == pgerrcode.QueryCanceled) {
-					size, err = store.EstimatedSizeWithDescendants(ctx, repo)
-					precision = sizePrecisionUntagged
-				}
+		case sizeQueryParamBreakdownValue:
+			var b *models.RepositorySizeBreakdown
+			b, err = store.SizeBreakdown(ctx, repo, sizeBreakdownLargestImagesLimit)
+			if err == nil {
+				size = b.LayersSize
+				resp.SizeBreakdown = newRepositorySizeBreakdownAPIResponse(b)
 			}
+		case sizeQueryParamSelfWithDescendantsValue:
+			size, precision, err = sizeWithDescendants(ctx, store, repo)
 		}
 		l.WithError(err).WithFields(log.Fields{
 			"size_bytes":   size,
@@ -292,6 +374,27 @@ func (h *repositoryHandler) GetRepository(w http.ResponseWriter, r *http.Request
 	}
 }
 
+func newRepositorySizeBreakdownAPIResponse(b *models.RepositorySizeBreakdown) *RepositorySizeBreakdownAPIResponse {
+	resp := &RepositorySizeBreakdownAPIResponse{
+		Layers:        b.LayersSize,
+		UniqueLayers:  b.UniqueLayersSize(),
+		SharedLayers:  b.SharedLayersSize,
+		Configs:       b.ConfigsSize,
+		Manifests:     b.ManifestsSize,
+		LargestImages: make([]ImageSizeAPIResponse, 0, len(b.LargestImages)),
+	}
+	for _, i := range b.LargestImages {
+		resp.LargestImages = append(resp.LargestImages, ImageSizeAPIResponse{
+			Tag:       i.Tag,
+			Digest:    i.Digest.String(),
+			MediaType: i.MediaType,
+			Size:      i.Size,
+		})
+	}
+
+	return resp
+}
+
 type repositoryTagsHandler struct {
 	*Context
 }
@@ -314,10 +417,14 @@ type RepositoryTagResponse struct {
 	Digest       string `json:"digest"`
 	ConfigDigest string `json:"config_digest,omitempty"`
 	MediaType    string `json:"media_type"`
+	ArtifactType string `json:"artifact_type,omitempty"`
 	Size         int64  `json:"size_bytes"`
 	CreatedAt    string `json:"created_at"`
 	UpdatedAt    string `json:"updated_at,omitempty"`
 	PublishedAt  string `json:"published_at,omitempty"`
+	LastPulledAt string `json:"last_pulled_at,omitempty"`
+	ChartName    string `json:"chart_name,omitempty"`
+	ChartVersion string `json:"chart_version,omitempty"`
 }
 
 func tagNameQueryParamValue(r *http.Request) string {
@@ -398,6 +505,35 @@ func filterParamsFromRequest(r *http.Request) (datastore.FilterParams, error) {
 	}
 	filters.Name = nameFilter
 
+	prefixFilter := q.Get(tagNamePrefixQueryParamKey)
+	if prefixFilter != "" {
+		if !queryParamValueMatchesPattern(prefixFilter, tagNameQueryParamPattern) {
+			detail := v1.InvalidQueryParamValuePatternErrorDetail(tagNamePrefixQueryParamKey, tagNameQueryParamPattern)
+			return filters, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+	}
+	filters.NamePrefix = prefixFilter
+
+	regexFilter := q.Get(tagNameRegexQueryParamKey)
+	if regexFilter != "" {
+		// The expression is evaluated by the database, whose regular expression syntax is not the same as that of Go,
+		// but is similar enough to reject obviously invalid values early.
+		if _, err := regexp.Compile(regexFilter); err != nil || len(regexFilter) > tagNameRegexQueryParamMaxLength {
+			detail := v1.InvalidQueryParamValueRegexErrorDetail(tagNameRegexQueryParamKey, tagNameRegexQueryParamMaxLength)
+			return filters, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+	}
+	filters.NameRegex = regexFilter
+
+	artifactTypeFilter := q.Get(artifactTypeQueryParamKey)
+	if artifactTypeFilter != "" {
+		if _, _, err := mime.ParseMediaType(artifactTypeFilter); err != nil || len(artifactTypeFilter) > maxMediaTypeLength {
+			detail := v1.InvalidQueryParamValueMediaTypeErrorDetail(artifactTypeQueryParamKey, maxMediaTypeLength)
+			return filters, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+	}
+	filters.ArtifactType = artifactTypeFilter
+
 	sort := sortQueryParamValue(q)
 	if sort != "" {
 		if !isQueryParamValueValid(sort, sortQueryParamValidValues) {
@@ -428,13 +564,18 @@ func getSortOrderParams(sort string) (string, datastore.SortOrder) {
 
 // GetTags retrieves a list of tag details for a given repository. This includes support for marker-based pagination
 // using limit (`n`) and last (`last`) query parameters, as in the Docker/OCI Distribution tags list API. `n` is capped
-// to 100 entries by default.
+// to 100 entries by default. The filters applied to the list are reported in the `OCI-Filters-Applied` header.
 func (h *repositoryTagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 	filters, err := filterParamsFromRequest(r)
 	if err != nil {
 		h.Errors = append(h.Errors, err)
 		return
 	}
+	countTotal, err := v1CountQueryParamValue(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
 
 	path := h.Repository.Named().Name()
 	rStore := datastore.NewRepositoryStore(h.db)
@@ -454,6 +595,18 @@ func (h *repositoryTagsHandler) GetTags(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if countTotal {
+		list := fmt.Sprintf("tags:%d:%d", repo.NamespaceID, repo.ID)
+		total, err := newTotalCounter(h.App).count(h.Context, list, filters, func() (int, error) {
+			return rStore.TagsCount(h.Context, repo, filters)
+		})
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		setTotalCountHeaders(w, total, filters.MaxEntries)
+	}
+
 	// Add a link header if there are more entries to retrieve
 	if len(tagsList) > 0 {
 		filters.LastEntry = tagsList[len(tagsList)-1].Name
@@ -495,6 +648,9 @@ func (h *repositoryTagsHandler) GetTags(w http.ResponseWriter, r *http.Request)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if applied := appliedFilters(filters); len(applied) > 0 {
+		w.Header().Set(filtersAppliedHeader, strings.Join(applied, ","))
+	}
 
 	resp := make([]RepositoryTagResponse, 0, len(tagsList))
 	for _, t := range tagsList {
@@ -509,12 +665,153 @@ func (h *repositoryTagsHandler) GetTags(w http.ResponseWriter, r *http.Request)
 		if t.ConfigDigest.Valid {
 			d.ConfigDigest = t.ConfigDigest.Digest.String()
 		}
+		if t.ArtifactType.Valid {
+			d.ArtifactType = t.ArtifactType.String
+		}
 		if t.UpdatedAt.Valid {
 			d.UpdatedAt = timeToString(t.UpdatedAt.Time)
 		}
+		if t.LastPulledAt.Valid {
+			d.LastPulledAt = timeToString(t.LastPulledAt.Time)
+		}
+		if t.ChartName.Valid {
+			d.ChartName = t.ChartName.String
+		}
+		if t.ChartVersion.Valid {
+			d.ChartVersion = t.ChartVersion.String
+		}
+		resp = append(resp, d)
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+type groupRepositoriesHandler struct {
+	*Context
+}
+
+func groupRepositoriesDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	groupRepositoriesHandler := &groupRepositoriesHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(groupRepositoriesHandler.GetGroupRepositories),
+	}
+}
+
+// GroupRepositoryAPIResponse is the API counterpart for models.RepositorySummary.
+type GroupRepositoryAPIResponse struct {
+	Name            string `json:"name"`
+	Path            string `json:"path"`
+	Size            int64  `json:"size_bytes"`
+	TagCount        int    `json:"tag_count"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at,omitempty"`
+	LastPublishedAt string `json:"last_published_at"`
+}
+
+// GetGroupRepositories retrieves the list of repositories with at least one tag under a group (namespace) path,
+// including the repository with the group path itself, if any. Each repository includes its size, tag count and the
+// last time a tag was published. Pagination works as for GetSubRepositories.
+func (h *groupRepositoriesHandler) GetGroupRepositories(w http.ResponseWriter, r *http.Request) {
+	filters, err := filterParamsFromRequest(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+	countTotal, err := v1CountQueryParamValue(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+	if filters.Labels, err = repositoryLabelMatchesFromQuery(r.URL.Query()); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidQueryParamValue.WithDetail(err.Error()))
+		return
+	}
+
+	path := h.Repository.Named().Name()
+	group := &models.Repository{Path: path}
+
+	nStore := datastore.NewNamespaceStore(h.db)
+	namespace, err := nStore.FindByName(h.Context, group.TopLevelPathSegment())
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if namespace == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"namespace": group.TopLevelPathSegment()}))
+		return
+	}
+	group.NamespaceID = namespace.ID
+
+	var cache datastore.RepositoryCache = datastore.NewNoOpRepositoryCache()
+	if h.App.redisCache != nil {
+		cache = datastore.NewCentralRepositoryCache(h.App.redisCache)
+	}
+	rStore := datastore.NewRepositoryStore(h.db, datastore.WithRepositoryCache(cache))
+
+	summaries, err := rStore.FindPaginatedSummariesForPath(h.Context, group, filters)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	if countTotal {
+		total, err := countRepositoriesWithTagsForPath(h.Context, rStore, group, filters)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		setTotalCountHeaders(w, total, filters.MaxEntries)
+	}
+
+	resp := make([]GroupRepositoryAPIResponse, 0, len(summaries))
+	for _, rs := range summaries {
+		// use the cached size, if any, to avoid calculating the size of every repository in the page
+		if cached := cache.Get(h.Context, rs.Path); cached != nil && cached.ID == rs.ID {
+			rs.Size = cached.Size
+		}
+		size, err := rStore.Size(h.Context, &rs.Repository)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+
+		d := GroupRepositoryAPIResponse{
+			Name:            rs.Name,
+			Path:            rs.Path,
+			Size:            size,
+			TagCount:        rs.TagCount,
+			CreatedAt:       timeToString(rs.CreatedAt),
+			LastPublishedAt: timeToString(rs.LastPublishedAt),
+		}
+		if rs.UpdatedAt.Valid {
+			d.UpdatedAt = timeToString(rs.UpdatedAt.Time)
+		}
 		resp = append(resp, d)
 	}
 
+	// Add a link header if there might be more entries to retrieve
+	if len(summaries) == filters.MaxEntries {
+		filters.LastEntry = summaries[len(summaries)-1].Path
+		urlStr, err := createLinkEntry(r.URL.String(), filters, "", "")
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+
+		if urlStr != "" {
+			w.Header().Set("Link", urlStr)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(resp); err != nil {
 		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
@@ -545,6 +842,15 @@ func (h *subRepositoriesHandler) GetSubRepositories(w http.ResponseWriter, r *ht
 		h.Errors = append(h.Errors, err)
 		return
 	}
+	countTotal, err := v1CountQueryParamValue(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+	if filters.Labels, err = repositoryLabelMatchesFromQuery(r.URL.Query()); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidQueryParamValue.WithDetail(err.Error()))
+		return
+	}
 
 	// extract the repository name to create the a preliminary repository
 	path := h.Repository.Named().Name()
@@ -575,6 +881,15 @@ func (h *subRepositoriesHandler) GetSubRepositories(w http.ResponseWriter, r *ht
 		return
 	}
 
+	if countTotal {
+		total, err := countRepositoriesWithTagsForPath(h.Context, rStore, repo, filters)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		setTotalCountHeaders(w, total, filters.MaxEntries)
+	}
+
 	// Add a link header if there might be more entries to retrieve
 	if len(repoList) == filters.MaxEntries {
 		filters.LastEntry = repoList[len(repoList)-1].Path
@@ -777,17 +1092,46 @@ func (h *repositoryHandler) RenameRepository(w http.ResponseWriter, r *http.Requ
 
 	// only commit the transaction if the request was not a dry-run
 	if !dryRun {
+		// The filesystem metadata is moved before committing, so that the repositories are only renamed in the
+		// database if it was moved as well. Files already moved are moved back if either step fails.
+		var movedFiles int
+		if renameObject.MoveFilesystemMetadata {
+			movedFiles, err = h.moveRenamedRepositoryMetadata(projectPath, repo.Path, newPath)
+			if err != nil {
+				h.undoRenamedRepositoryMetadataMove(projectPath, repo.Path, newPath, movedFiles)
+				h.Errors = append(h.Errors,
+					errcode.FromUnknownError(fmt.Errorf("failed to move filesystem metadata: %w", err)))
+				return
+			}
+		}
+
 		if err := tx.Commit(); err != nil {
+			if renameObject.MoveFilesystemMetadata {
+				h.undoRenamedRepositoryMetadataMove(projectPath, repo.Path, newPath, movedFiles)
+			}
 			h.Errors = append(h.Errors,
 				errcode.FromUnknownError(fmt.Errorf("failed to commit database transaction: %w", err)))
 			return
 		}
-		w.WriteHeader(http.StatusNoContent)
 
 		// When a lease fails to be destroyed after it is no longer needed it should not impact the response to the caller.
 		// The lease will eventually expire regardless, but we still need to record these failed cases.
-		if err := rlstore.Destroy(h.Context, lease); err != nil {
-			errortracking.Capture(err, errortracking.WithContext(h.Context))
+		defer func() {
+			if err := rlstore.Destroy(h.Context, lease); err != nil {
+				errortracking.Capture(err, errortracking.WithContext(h.Context))
+			}
+		}()
+
+		if !renameObject.MoveFilesystemMetadata {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		resp := RenameRepositoryResultAPIResponse{RepositoriesCount: repoCount, MovedFilesCount: movedFiles}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(&resp); err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
 		}
 	} else {
 		w.WriteHeader(http.StatusAccepted)
@@ -805,6 +1149,46 @@ func (h *repositoryHandler) RenameRepository(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// moveRenamedRepositoryMetadata moves the filesystem metadata of a renamed repository (and all its sub-repositories)
+// from oldPath to newPath, in the storage driver of their top-level namespace. The project lease is extended while doing
+// so, blocking writes to the project repositories until the move is complete or renameMetadataMoveTimeout is exceeded.
+// The number of moved files is returned.
+func (h *repositoryHandler) moveRenamedRepositoryMetadata(projectPath, oldPath, newPath string) (int, error) {
+	plStore, err := datastore.NewProjectLeaseStore(datastore.NewCentralProjectLeaseCache(h.App.redisCache))
+	if err != nil {
+		return 0, err
+	}
+	if err := plStore.Set(h.Context, projectPath, renameMetadataMoveTimeout+1*time.Second); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(h.Context, renameMetadataMoveTimeout)
+	defer cancel()
+
+	return storage.MoveRepository(ctx, h.App.namespaceDriver(oldPath), oldPath, newPath)
+}
+
+// undoRenamedRepositoryMetadataMove moves the movedFiles filesystem metadata files already moved from oldPath to
+// newPath back, as the rename is being rolled back. Failures are only logged and reported, as files left under newPath
+// do not affect the repositories under oldPath.
+func (h *repositoryHandler) undoRenamedRepositoryMetadataMove(projectPath, oldPath, newPath string, movedFiles int) {
+	if movedFiles == 0 {
+		return
+	}
+
+	l := log.GetLogger(log.WithContext(h)).WithFields(log.Fields{"path": oldPath, "rename_path": newPath})
+	n, err := h.moveRenamedRepositoryMetadata(projectPath, newPath, oldPath)
+	if err != nil {
+		l.WithError(err).WithFields(log.Fields{
+			"moved_files":    movedFiles,
+			"restored_files": n,
+		}).Error("failed to restore filesystem metadata of repository after rename failure")
+		errortracking.Capture(err, errortracking.WithContext(h.Context))
+		return
+	}
+	l.WithFields(log.Fields{"restored_files": n}).Info("restored filesystem metadata of repository after rename failure")
+}
+
 // enforceRenameLease makes sure a conflicting rename lease does not already exist for `forPath` that is not granted to `grantedToPath`
 // if a rename lease exist for the `forPath` that is not granted to `grantedToPath` it returns an errcode.Error.
 // if a rename lease exist for the `forPath` with the same `grantedToPath` it refreshes the TTL the lease.
@@ -1029,3 +1413,776 @@ func checkOngoingRename(handler http.Handler, h *Context) http.Handler {
 		handler.ServeHTTP(w, r)
 	})
 }
+
+// checkReadOnlyRepository is a wrapper around http request handlers. It prevents write and delete requests from
+// proceeding to the wrapped handler if the repository was made read-only through its settings, or while it's being
+// purged. Repositories that do not exist yet have no settings, so their first push is always allowed.
+func checkReadOnlyRepository(handler http.Handler, h *Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, isRegistryWrite := writeMethods[r.Method]; !isRegistryWrite || !h.useDatabase {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		var opts []datastore.RepositoryStoreOption
+		if h.App.redisCache != nil {
+			opts = append(opts, datastore.WithRepositoryCache(datastore.NewCentralRepositoryCache(h.App.redisCache)))
+		}
+
+		path := h.Repository.Named().Name()
+		repo, err := datastore.NewRepositoryStore(h.db, opts...).FindByPath(h, path)
+		if err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+		if repo != nil {
+			rs, err := datastore.NewRepositorySettingsStore(h.db).FindByRepository(h, repo)
+			if err != nil {
+				h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+				return
+			}
+			if rs != nil && rs.ReadOnly {
+				h.Errors = append(h.Errors, v2.ErrorCodeRepositoryReadOnly.WithDetail(map[string]string{"name": path}))
+				return
+			}
+			p, err := datastore.NewRepositoryPurgeStore(h.db).FindByRepository(h, repo)
+			if err != nil {
+				h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+				return
+			}
+			if p != nil && !p.Completed() {
+				h.Errors = append(h.Errors, v2.ErrorCodeRepositoryReadOnly.WithDetail(map[string]string{
+					"name":   path,
+					"reason": "repository purge in progress",
+				}))
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type repositoryImmutableTagsHandler struct {
+	*Context
+}
+
+func repositoryImmutableTagsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryImmutableTagsHandler := &repositoryImmutableTagsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryImmutableTagsHandler.GetImmutableTagPatterns),
+		http.MethodPut: http.HandlerFunc(repositoryImmutableTagsHandler.PutImmutableTagPatterns),
+	}
+}
+
+// ImmutableTagPatternsAPIResponse is the response body for the repository immutable tag patterns endpoint. Patterns are
+// those set through the API, while ConfigPatterns are those that apply to the repository as per the registry
+// configuration file, which cannot be changed through the API.
+type ImmutableTagPatternsAPIResponse struct {
+	Patterns       []string `json:"patterns"`
+	ConfigPatterns []string `json:"config_patterns,omitempty"`
+}
+
+// ImmutableTagPatternsAPIRequest is the request body for the repository immutable tag patterns endpoint.
+type ImmutableTagPatternsAPIRequest struct {
+	Patterns []string `json:"patterns"`
+}
+
+const (
+	maxImmutableTagPatterns      = 10
+	maxImmutableTagPatternLength = 255
+)
+
+func (h *repositoryImmutableTagsHandler) findRepository() (*models.Repository, bool) {
+	path := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.db).FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil, false
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return nil, false
+	}
+	return repo, true
+}
+
+// GetImmutableTagPatterns lists the immutable tag patterns of a repository.
+func (h *repositoryImmutableTagsHandler) GetImmutableTagPatterns(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	pp, err := datastore.NewImmutableTagPatternStore(h.db).FindByRepository(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := ImmutableTagPatternsAPIResponse{
+		Patterns:       pp,
+		ConfigPatterns: immutableTagPatternsForRepository(h.App.Config.Policy.Repository.ImmutableTags, repo.Path),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// PutImmutableTagPatterns replaces the immutable tag patterns of a repository. Existing tags that match the new
+// patterns become immutable right away. An empty list of patterns removes all patterns from the repository.
+func (h *repositoryImmutableTagsHandler) PutImmutableTagPatterns(w http.ResponseWriter, r *http.Request) {
+	var req ImmutableTagPatternsAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return
+	}
+	if len(req.Patterns) > maxImmutableTagPatterns {
+		detail := fmt.Sprintf("the 'patterns' body parameter must not have more than %d elements", maxImmutableTagPatterns)
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return
+	}
+	for _, p := range req.Patterns {
+		if _, err := path.Match(p, ""); err != nil || p == "" || len(p) > maxImmutableTagPatternLength {
+			detail := fmt.Sprintf("the 'patterns' body parameter elements must be valid glob patterns with up to %d characters, got %q", maxImmutableTagPatternLength, p)
+			h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+			return
+		}
+	}
+
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	tx, err := h.db.BeginTx(h.Context, nil)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("failed to create database transaction: %w", err)))
+		return
+	}
+	defer tx.Rollback()
+
+	if err := datastore.NewImmutableTagPatternStore(tx).ReplaceForRepository(h.Context, repo, req.Patterns); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("committing database transaction: %w", err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type repositorySettingsHandler struct {
+	*Context
+}
+
+func repositorySettingsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositorySettingsHandler := &repositorySettingsHandler{
+		Context: ctx,
+	}
+
+	h := handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositorySettingsHandler.GetRepositorySettings),
+	}
+	if !ctx.readOnly {
+		h[http.MethodPut] = http.HandlerFunc(repositorySettingsHandler.PutRepositorySettings)
+	}
+
+	return h
+}
+
+// RepositorySettingsAPIResponse is the response body for the repository settings endpoint. ManifestPayloadSizeLimit is
+// the limit set through the API, if any, while EffectiveManifestPayloadSizeLimit is the limit that applies to the
+// repository once the registry configuration is taken into account. Zero means no limit.
+type RepositorySettingsAPIResponse struct {
+	ManifestPayloadSizeLimit          *int64 `json:"manifest_payload_size_limit"`
+	EffectiveManifestPayloadSizeLimit int    `json:"effective_manifest_payload_size_limit"`
+	ReadOnly                          bool   `json:"read_only"`
+}
+
+// RepositorySettingsAPIRequest is the request body for the repository settings endpoint. A null (or missing)
+// ManifestPayloadSizeLimit removes the override, falling back to the registry configuration. A false (or missing)
+// ReadOnly makes the repository writable again.
+type RepositorySettingsAPIRequest struct {
+	ManifestPayloadSizeLimit *int64 `json:"manifest_payload_size_limit"`
+	ReadOnly                 bool   `json:"read_only"`
+}
+
+// maxRepositoryManifestPayloadSizeLimit is the largest manifest payload size limit that can be set for a repository.
+const maxRepositoryManifestPayloadSizeLimit = math.MaxInt32
+
+func (h *repositorySettingsHandler) findRepository() (*models.Repository, bool) {
+	path := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.db).FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil, false
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return nil, false
+	}
+	return repo, true
+}
+
+// GetRepositorySettings returns the settings of a repository.
+func (h *repositorySettingsHandler) GetRepositorySettings(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	rs, err := datastore.NewRepositorySettingsStore(h.db).FindByRepository(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := RepositorySettingsAPIResponse{
+		EffectiveManifestPayloadSizeLimit: h.App.effectiveManifestPayloadSizeLimit(repo.Path, rs),
+	}
+	if rs != nil {
+		if rs.ManifestPayloadSizeLimit.Valid {
+			resp.ManifestPayloadSizeLimit = &rs.ManifestPayloadSizeLimit.Int64
+		}
+		resp.ReadOnly = rs.ReadOnly
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// PutRepositorySettings replaces the settings of a repository. The new manifest payload size limit applies to
+// subsequent manifest pushes only, existing manifests are not affected. Read-only repositories reject pushes and
+// deletes, which is useful to archive a repository without removing its images.
+func (h *repositorySettingsHandler) PutRepositorySettings(w http.ResponseWriter, r *http.Request) {
+	var req RepositorySettingsAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return
+	}
+	if l := req.ManifestPayloadSizeLimit; l != nil && (*l <= 0 || *l > maxRepositoryManifestPayloadSizeLimit) {
+		detail := fmt.Sprintf("the 'manifest_payload_size_limit' body parameter must be null or a positive integer up to %d, got %d", maxRepositoryManifestPayloadSizeLimit, *l)
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return
+	}
+
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	rs := &models.RepositorySettings{
+		NamespaceID:  repo.NamespaceID,
+		RepositoryID: repo.ID,
+		ReadOnly:     req.ReadOnly,
+	}
+	if req.ManifestPayloadSizeLimit != nil {
+		rs.ManifestPayloadSizeLimit = sql.NullInt64{Int64: *req.ManifestPayloadSizeLimit, Valid: true}
+	}
+	if err := datastore.NewRepositorySettingsStore(h.db).CreateOrUpdate(h.Context, rs); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type repositoryMetadataHandler struct {
+	*Context
+}
+
+func repositoryMetadataDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryMetadataHandler := &repositoryMetadataHandler{
+		Context: ctx,
+	}
+
+	h := handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryMetadataHandler.GetRepositoryMetadata),
+	}
+	if !ctx.readOnly {
+		h[http.MethodPut] = http.HandlerFunc(repositoryMetadataHandler.PutRepositoryMetadata)
+		h[http.MethodDelete] = http.HandlerFunc(repositoryMetadataHandler.DeleteRepositoryMetadata)
+	}
+
+	return h
+}
+
+// RepositoryMetadataAPIResponse is the API counterpart for models.RepositoryMetadata.
+type RepositoryMetadataAPIResponse struct {
+	Description string                      `json:"description"`
+	Readme      string                      `json:"readme"`
+	Links       []RepositoryLinkAPIResponse `json:"links"`
+	CreatedAt   string                      `json:"created_at"`
+	UpdatedAt   string                      `json:"updated_at,omitempty"`
+}
+
+// RepositoryLinkAPIResponse is the API counterpart for models.RepositoryLink.
+type RepositoryLinkAPIResponse struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// RepositoryMetadataAPIRequest is the request body for the repository metadata endpoint.
+type RepositoryMetadataAPIRequest struct {
+	Description string                      `json:"description"`
+	Readme      string                      `json:"readme"`
+	Links       []RepositoryLinkAPIResponse `json:"links"`
+}
+
+const (
+	// maxRepositoryDescriptionLength is the maximum length of a repository description, in characters.
+	maxRepositoryDescriptionLength = 255
+	// maxRepositoryReadmeLength is the maximum length of a repository README, in characters.
+	maxRepositoryReadmeLength = 100 << 10
+	// maxRepositoryLinks is the maximum number of links of a repository.
+	maxRepositoryLinks = 10
+	// maxRepositoryLinkNameLength is the maximum length of the name of a repository link, in characters.
+	maxRepositoryLinkNameLength = 255
+	// maxRepositoryLinkURLLength is the maximum length of the URL of a repository link, in characters.
+	maxRepositoryLinkURLLength = 2048
+)
+
+// validate returns a description of the first invalid attribute of the request, if any.
+func (req *RepositoryMetadataAPIRequest) validate() string {
+	if n := utf8.RuneCountInString(req.Description); n > maxRepositoryDescriptionLength {
+		return fmt.Sprintf("the 'description' body parameter must be at most %d characters long, got %d", maxRepositoryDescriptionLength, n)
+	}
+	if n := utf8.RuneCountInString(req.Readme); n > maxRepositoryReadmeLength {
+		return fmt.Sprintf("the 'readme' body parameter must be at most %d characters long, got %d", maxRepositoryReadmeLength, n)
+	}
+	if len(req.Links) > maxRepositoryLinks {
+		return fmt.Sprintf("the 'links' body parameter must have at most %d elements, got %d", maxRepositoryLinks, len(req.Links))
+	}
+	for i, l := range req.Links {
+		if l.Name == "" || utf8.RuneCountInString(l.Name) > maxRepositoryLinkNameLength {
+			return fmt.Sprintf("the 'links[%d].name' body parameter must be between 1 and %d characters long", i, maxRepositoryLinkNameLength)
+		}
+		if len(l.URL) > maxRepositoryLinkURLLength {
+			return fmt.Sprintf("the 'links[%d].url' body parameter must be at most %d characters long", i, maxRepositoryLinkURLLength)
+		}
+		u, err := url.Parse(l.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Sprintf("the 'links[%d].url' body parameter must be an absolute http or https URL, got %q", i, l.URL)
+		}
+	}
+	return ""
+}
+
+func newRepositoryMetadataAPIResponse(m *models.RepositoryMetadata) *RepositoryMetadataAPIResponse {
+	resp := &RepositoryMetadataAPIResponse{
+		Description: m.Description,
+		Readme:      m.Readme,
+		Links:       make([]RepositoryLinkAPIResponse, 0, len(m.Links)),
+		CreatedAt:   timeToString(m.CreatedAt),
+	}
+	if m.UpdatedAt.Valid {
+		resp.UpdatedAt = timeToString(m.UpdatedAt.Time)
+	}
+	for _, l := range m.Links {
+		resp.Links = append(resp.Links, RepositoryLinkAPIResponse{Name: l.Name, URL: l.URL})
+	}
+	return resp
+}
+
+func (h *repositoryMetadataHandler) findRepository() (*models.Repository, bool) {
+	path := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.db).FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil, false
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return nil, false
+	}
+	return repo, true
+}
+
+// GetRepositoryMetadata returns the metadata of a repository.
+func (h *repositoryMetadataHandler) GetRepositoryMetadata(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	m, err := datastore.NewRepositoryMetadataStore(h.db).FindByRepository(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if m == nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeRepositoryMetadataUnknown.WithDetail(map[string]string{"name": repo.Path}))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(newRepositoryMetadataAPIResponse(m)); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// PutRepositoryMetadata creates or replaces the metadata of a repository.
+func (h *repositoryMetadataHandler) PutRepositoryMetadata(w http.ResponseWriter, r *http.Request) {
+	var req RepositoryMetadataAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return
+	}
+	if detail := req.validate(); detail != "" {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return
+	}
+
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	m := &models.RepositoryMetadata{
+		NamespaceID:  repo.NamespaceID,
+		RepositoryID: repo.ID,
+		Description:  req.Description,
+		Readme:       req.Readme,
+		Links:        make([]models.RepositoryLink, 0, len(req.Links)),
+	}
+	for _, l := range req.Links {
+		m.Links = append(m.Links, models.RepositoryLink{Name: l.Name, URL: l.URL})
+	}
+	if err := datastore.NewRepositoryMetadataStore(h.db).CreateOrUpdate(h.Context, m); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteRepositoryMetadata deletes the metadata of a repository.
+func (h *repositoryMetadataHandler) DeleteRepositoryMetadata(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	found, err := datastore.NewRepositoryMetadataStore(h.db).Delete(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if !found {
+		h.Errors = append(h.Errors, v1.ErrorCodeRepositoryMetadataUnknown.WithDetail(map[string]string{"name": repo.Path}))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type repositoryLabelsHandler struct {
+	*Context
+}
+
+func repositoryLabelsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryLabelsHandler := &repositoryLabelsHandler{
+		Context: ctx,
+	}
+
+	h := handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryLabelsHandler.GetRepositoryLabels),
+	}
+	if !ctx.readOnly {
+		h[http.MethodPut] = http.HandlerFunc(repositoryLabelsHandler.PutRepositoryLabels)
+	}
+
+	return h
+}
+
+// RepositoryLabelsAPIResponse is the response body for the repository labels endpoint.
+type RepositoryLabelsAPIResponse struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// RepositoryLabelsAPIRequest is the request body for the repository labels endpoint.
+type RepositoryLabelsAPIRequest struct {
+	Labels map[string]string `json:"labels"`
+}
+
+const (
+	// labelQueryParamKey is the query parameter used to filter repository lists by label.
+	labelQueryParamKey = "label"
+	// maxLabelQueryParams is the maximum number of label conditions in a single repository list request.
+	maxLabelQueryParams = 10
+	// maxRepositoryLabels is the maximum number of labels of a repository.
+	maxRepositoryLabels = 20
+)
+
+// repositoryLabelKeyPattern matches valid repository label keys: lowercase alphanumeric characters, optionally
+// separated by `.`, `_`, `-` or `/`, so that keys can be namespaced (e.g. `example.com/team`).
+var repositoryLabelKeyPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._/-]*[a-z0-9])?$`)
+
+// validRepositoryLabelKey returns whether key is a valid repository label key.
+func validRepositoryLabelKey(key string) bool {
+	return len(key) <= datastore.MaxRepositoryLabelKeyLength && repositoryLabelKeyPattern.MatchString(key)
+}
+
+// validate returns a description of the first invalid attribute of the request, if any.
+func (req *RepositoryLabelsAPIRequest) validate() string {
+	if len(req.Labels) > maxRepositoryLabels {
+		return fmt.Sprintf("the 'labels' body parameter must have at most %d entries, got %d", maxRepositoryLabels, len(req.Labels))
+	}
+	keys := make([]string, 0, len(req.Labels))
+	for k := range req.Labels {
+		keys = append(keys, k)
+	}
+	// report the same invalid label on every request
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !validRepositoryLabelKey(k) {
+			return fmt.Sprintf("the 'labels' body parameter keys must be up to %d lowercase alphanumeric characters, optionally separated by '.', '_', '-' or '/', got %q",
+				datastore.MaxRepositoryLabelKeyLength, k)
+		}
+		if n := utf8.RuneCountInString(req.Labels[k]); n > datastore.MaxRepositoryLabelValueLength {
+			return fmt.Sprintf("the 'labels.%s' body parameter must be at most %d characters long, got %d", k, datastore.MaxRepositoryLabelValueLength, n)
+		}
+	}
+	return ""
+}
+
+// repositoryLabelMatchesFromQuery parses the `label` query parameters of a repository list request. Each parameter is a
+// condition in the `<key>=<value>` format, or just `<key>` to match any value. The returned error describes the first
+// invalid condition, if any.
+func repositoryLabelMatchesFromQuery(q url.Values) ([]datastore.RepositoryLabelMatch, error) {
+	labels := q[labelQueryParamKey]
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	if len(labels) > maxLabelQueryParams {
+		return nil, fmt.Errorf("at most %d '%s' query parameters can be set", maxLabelQueryParams, labelQueryParamKey)
+	}
+
+	matches := make([]datastore.RepositoryLabelMatch, 0, len(labels))
+	for _, l := range labels {
+		key, value, hasValue := strings.Cut(l, "=")
+		if !validRepositoryLabelKey(key) || utf8.RuneCountInString(value) > datastore.MaxRepositoryLabelValueLength {
+			return nil, fmt.Errorf("the '%s' query parameter value must be a valid label key, optionally followed by '=' and a value of up to %d characters, got %q",
+				labelQueryParamKey, datastore.MaxRepositoryLabelValueLength, l)
+		}
+		matches = append(matches, datastore.RepositoryLabelMatch{Key: key, Value: value, AnyValue: !hasValue})
+	}
+
+	return matches, nil
+}
+
+func (h *repositoryLabelsHandler) findRepository() (*models.Repository, bool) {
+	path := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.db).FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil, false
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return nil, false
+	}
+	return repo, true
+}
+
+// GetRepositoryLabels returns the labels of a repository.
+func (h *repositoryLabelsHandler) GetRepositoryLabels(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	labels, err := datastore.NewRepositoryLabelStore(h.db).FindByRepository(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(RepositoryLabelsAPIResponse{Labels: labels}); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// PutRepositoryLabels replaces the labels of a repository. An empty set of labels removes all labels from the
+// repository.
+func (h *repositoryLabelsHandler) PutRepositoryLabels(w http.ResponseWriter, r *http.Request) {
+	var req RepositoryLabelsAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return
+	}
+	if detail := req.validate(); detail != "" {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return
+	}
+
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	tx, err := h.db.BeginTx(h.Context, nil)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("failed to create database transaction: %w", err)))
+		return
+	}
+	defer tx.Rollback()
+
+	if err := datastore.NewRepositoryLabelStore(tx).ReplaceForRepository(h.Context, repo, req.Labels); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("committing database transaction: %w", err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type repositoryTagsBulkDeleteHandler struct {
+	*Context
+}
+
+func repositoryTagsBulkDeleteDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryTagsBulkDeleteHandler := &repositoryTagsBulkDeleteHandler{
+		Context: ctx,
+	}
+
+	thandler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		thandler[http.MethodDelete] = http.HandlerFunc(repositoryTagsBulkDeleteHandler.DeleteTags)
+	}
+
+	return checkOngoingRename(checkReadOnlyRepository(thandler, ctx), ctx)
+}
+
+// BulkDeleteTagsAPIRequest is the request body for the repository tags bulk delete endpoint. Exactly one of Names or
+// NameRegex must be set.
+type BulkDeleteTagsAPIRequest struct {
+	Names     []string `json:"names,omitempty"`
+	NameRegex string   `json:"name_regex,omitempty"`
+}
+
+// BulkDeleteTagsAPIResponse is the response body for the repository tags bulk delete endpoint. Tags holds the names
+// of the deleted tags, lexicographically sorted.
+type BulkDeleteTagsAPIResponse struct {
+	Tags []string `json:"tags"`
+}
+
+func (h *repositoryTagsBulkDeleteHandler) validateRequest(req BulkDeleteTagsAPIRequest) bool {
+	if req.Names != nil && req.NameRegex != "" {
+		detail := v1.MutuallyExclusiveParametersErrorDetail(tagNamesBodyParamKey, tagNameRegexBodyParamKey)
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return false
+	}
+	if req.NameRegex != "" {
+		if _, err := regexp.Compile(req.NameRegex); err != nil || len(req.NameRegex) > tagNameRegexBodyParamMaxLength {
+			detail := v1.InvalidBodyParamValueRegexErrorDetail(tagNameRegexBodyParamKey, tagNameRegexBodyParamMaxLength)
+			h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+			return false
+		}
+		return true
+	}
+	if req.Names == nil {
+		detail := v1.MissingBodyParamErrorDetail(tagNamesBodyParamKey, tagNameRegexBodyParamKey)
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return false
+	}
+	if len(req.Names) == 0 || len(req.Names) > maxTagsToBulkDelete {
+		detail := v1.InvalidBodyParamLengthErrorDetail(tagNamesBodyParamKey, 1, maxTagsToBulkDelete)
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+		return false
+	}
+	for _, name := range req.Names {
+		if !tagNameBodyParamPattern.MatchString(name) {
+			detail := v1.InvalidBodyParamValuePatternErrorDetail(tagNamesBodyParamKey, tagNameBodyParamPattern)
+			h.Errors = append(h.Errors, v1.ErrorCodeInvalidBodyParamType.WithDetail(detail))
+			return false
+		}
+	}
+
+	return true
+}
+
+// DeleteTags deletes multiple tags of a repository at once, identified either by a list of names or by a name regular
+// expression. All deletes are performed within a single database transaction and a single notification event is
+// emitted for all deleted tags. Names for which no tag exists are ignored.
+func (h *repositoryTagsBulkDeleteHandler) DeleteTags(w http.ResponseWriter, r *http.Request) {
+	l := log.GetLogger(log.WithContext(h))
+
+	if !deleteEnabled(h.App.Config) {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported)
+		return
+	}
+
+	var req BulkDeleteTagsAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return
+	}
+	if !h.validateRequest(req) {
+		return
+	}
+
+	// TODO: remove as part of https://gitlab.com/gitlab-org/container-registry/-/issues/1056
+	var repoCache datastore.RepositoryCache
+	if h.App.redisCache != nil {
+		repoCache = datastore.NewCentralRepositoryCache(h.App.redisCache)
+	} else {
+		repoCache = datastore.NewSingleRepositoryCache()
+	}
+
+	path := h.Repository.Named().Name()
+	deleted, err := dbDeleteTags(h.Context, h.db, repoCache, getManifestCache(h.App), path, req.Names, req.NameRegex, newTagProtection(h, path))
+	if err != nil {
+		switch err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		default:
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		}
+		return
+	}
+
+	if len(deleted) > 0 {
+		if err := h.queueBridge.TagsDeleted(h.Repository.Named(), deleted); err != nil {
+			l.WithError(err).Error("dispatching tags bulk delete to queue")
+		}
+	}
+	for _, tag := range deleted {
+		h.recordAudit(audit.Record{Action: audit.ActionDelete, Artifact: audit.ArtifactTag, Tag: tag})
+	}
+	l.WithFields(log.Fields{"repository": path, "tags_count": len(deleted)}).Info("tags deleted")
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(BulkDeleteTagsAPIResponse{Tags: deleted}); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryLabelMatchesFromQuery(t *testing.T) {
+	tcs := map[string]struct {
+		query    url.Values
+		expected []datastore.RepositoryLabelMatch
+	}{
+		"none": {
+			query: url.Values{},
+		},
+		"key and value": {
+			query:    url.Values{"label": {"team=backend"}},
+			expected: []datastore.RepositoryLabelMatch{{Key: "team", Value: "backend"}},
+		},
+		"key only": {
+			query:    url.Values{"label": {"example.com/compliance"}},
+			expected: []datastore.RepositoryLabelMatch{{Key: "example.com/compliance", AnyValue: true}},
+		},
+		"empty value": {
+			query:    url.Values{"label": {"team="}},
+			expected: []datastore.RepositoryLabelMatch{{Key: "team"}},
+		},
+		"value with separator": {
+			query:    url.Values{"label": {"args=a=b"}},
+			expected: []datastore.RepositoryLabelMatch{{Key: "args", Value: "a=b"}},
+		},
+		"multiple": {
+			query:    url.Values{"label": {"team=backend", "tier"}},
+			expected: []datastore.RepositoryLabelMatch{{Key: "team", Value: "backend"}, {Key: "tier", AnyValue: true}},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			mm, err := repositoryLabelMatchesFromQuery(tc.query)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, mm)
+		})
+	}
+}
+
+func TestRepositoryLabelMatchesFromQuery_Invalid(t *testing.T) {
+	tooMany := make([]string, maxLabelQueryParams+1)
+	for i := range tooMany {
+		tooMany[i] = "team"
+	}
+
+	tcs := map[string]url.Values{
+		"too many":         {"label": tooMany},
+		"empty key":        {"label": {"=backend"}},
+		"uppercase key":    {"label": {"Team=backend"}},
+		"invalid key":      {"label": {"team name=backend"}},
+		"key ends in dash": {"label": {"team-"}},
+		"too long key":     {"label": {strings.Repeat("k", datastore.MaxRepositoryLabelKeyLength+1)}},
+		"too long value":   {"label": {"team=" + strings.Repeat("v", datastore.MaxRepositoryLabelValueLength+1)}},
+	}
+
+	for name, query := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := repositoryLabelMatchesFromQuery(query)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestRepositoryLabelsAPIRequest_Validate(t *testing.T) {
+	tooMany := make(map[string]string, maxRepositoryLabels+1)
+	for i := 0; i <= maxRepositoryLabels; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+
+	tcs := map[string]struct {
+		labels   map[string]string
+		expected string
+	}{
+		"none": {},
+		"valid": {
+			labels: map[string]string{"team": "backend", "example.com/compliance-class": "pci", "tier_1": ""},
+		},
+		"too many": {
+			labels:   tooMany,
+			expected: "the 'labels' body parameter must have at most 20 entries, got 21",
+		},
+		"invalid key": {
+			labels:   map[string]string{"team": "backend", "Tier": "1"},
+			expected: `the 'labels' body parameter keys must be up to 63 lowercase alphanumeric characters, optionally separated by '.', '_', '-' or '/', got "Tier"`,
+		},
+		"too long value": {
+			labels:   map[string]string{"team": strings.Repeat("v", datastore.MaxRepositoryLabelValueLength+1)},
+			expected: "the 'labels.team' body parameter must be at most 255 characters long, got 256",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			req := &RepositoryLabelsAPIRequest{Labels: tc.labels}
+			require.Equal(t, tc.expected, req.validate())
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/gorilla/handlers"
+)
+
+// repositoryEventsKeepAliveInterval is the interval at which comments are sent to clients streaming repository
+// events, so that idle connections are not closed by intermediate proxies.
+var repositoryEventsKeepAliveInterval = 30 * time.Second
+
+// repositoryEventsActions are the actions of the events streamed to clients. Pulls and cross repository blob mounts
+// are left out, as these do not change the repository contents that automation reacts to.
+var repositoryEventsActions = map[string]bool{
+	notifications.EventActionPush:   true,
+	notifications.EventActionDelete: true,
+}
+
+type repositoryEventsHandler struct {
+	*Context
+}
+
+func repositoryEventsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryEventsHandler := &repositoryEventsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryEventsHandler.StreamEvents),
+	}
+}
+
+// StreamEvents streams the push and delete events of a repository to the client as Server-Sent Events, until the
+// client disconnects or the registry shuts down. Only events generated by this registry instance after the client
+// connected are streamed.
+func (h *repositoryEventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(fmt.Errorf("response writer does not support streaming")))
+		return
+	}
+
+	l := dcontext.GetLogger(h)
+	events, cancel := h.App.events.hub.Subscribe(getName(h))
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// disable response buffering in NGINX based proxies
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(repositoryEventsKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// the hub was closed, the registry is shutting down
+				return
+			}
+			if !repositoryEventsActions[event.Action] {
+				continue
+			}
+			// headers were already sent, so errors can only be logged from now on
+			if err := writeServerSentEvent(w, event); err != nil {
+				l.WithError(err).Warn("failed to write repository event to stream")
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeServerSentEvent writes event to w in the Server-Sent Events format, using the event action as event type and
+// the JSON encoded event as data. The user JWT and the client address of the request that generated the event are
+// left out, as these are not meant to be disclosed to other users with access to the repository.
+func writeServerSentEvent(w http.ResponseWriter, event *notifications.Event) error {
+	// events are shared with other sinks, so we must not modify them
+	e := *event
+	e.Actor.User = ""
+	e.Request.Addr = ""
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Action, data)
+	return err
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+// readServerSentEvent reads the next event from a Server-Sent Events stream, skipping comments.
+func readServerSentEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+
+	fields := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		k, v, _ := strings.Cut(line, ": ")
+		fields[k] = v
+	}
+}
+
+func TestRepositoryEventsHandler_StreamEvents(t *testing.T) {
+	app := &App{}
+	app.events.hub = notifications.NewHub()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = mux.SetURLVars(r, map[string]string{"name": "foo/bar"})
+		ctx := &Context{App: app, Context: dcontext.WithVars(r.Context(), r)}
+		repositoryEventsDispatcher(ctx, r).ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// headers are flushed after subscribing, so no events are missed from now on
+	require.Equal(t, 1, app.events.hub.Subscribers())
+
+	pull := &notifications.Event{ID: "1", Action: notifications.EventActionPull}
+	pull.Target.Repository = "foo/bar"
+	other := &notifications.Event{ID: "2", Action: notifications.EventActionPush}
+	other.Target.Repository = "foo/baz"
+	push := &notifications.Event{ID: "3", Action: notifications.EventActionPush}
+	push.Target.Repository = "foo/bar"
+	push.Target.Tag = "latest"
+	push.Actor.Name = "john.doe"
+	push.Actor.User = "secret-jwt"
+	push.Request.Addr = "10.0.0.1"
+
+	for _, e := range []*notifications.Event{pull, other, push} {
+		require.NoError(t, app.events.hub.Write(e))
+	}
+
+	fields := readServerSentEvent(t, bufio.NewReader(resp.Body))
+	require.Equal(t, "3", fields["id"])
+	require.Equal(t, notifications.EventActionPush, fields["event"])
+
+	var e notifications.Event
+	require.NoError(t, json.Unmarshal([]byte(fields["data"]), &e))
+	require.Equal(t, "latest", e.Target.Tag)
+	require.Equal(t, "john.doe", e.Actor.Name)
+	require.Empty(t, e.Actor.User)
+	require.Empty(t, e.Request.Addr)
+	// the original event is left untouched
+	require.Equal(t, "secret-jwt", push.Actor.User)
+
+	// the stream ends when the hub is closed
+	require.NoError(t, app.events.hub.Close())
+	_, err = bufio.NewReader(resp.Body).ReadString('\n')
+	require.Error(t, err)
+}
+
+func TestRepositoryEventsHandler_StreamEvents_KeepAlive(t *testing.T) {
+	defer func(d time.Duration) { repositoryEventsKeepAliveInterval = d }(repositoryEventsKeepAliveInterval)
+	repositoryEventsKeepAliveInterval = 10 * time.Millisecond
+
+	app := &App{}
+	app.events.hub = notifications.NewHub()
+	defer app.events.hub.Close()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = mux.SetURLVars(r, map[string]string{"name": "foo/bar"})
+		ctx := &Context{App: app, Context: dcontext.WithVars(r.Context(), r)}
+		repositoryEventsDispatcher(ctx, r).ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	require.NoError(t, err)
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, ": keep-alive\n", line)
+
+	// the subscription is cancelled once the client disconnects
+	require.NoError(t, resp.Body.Close())
+	require.Eventually(t, func() bool { return app.events.hub.Subscribers() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
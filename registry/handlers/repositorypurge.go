@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/gorilla/handlers"
+)
+
+type repositoryPurgeHandler struct {
+	*Context
+}
+
+func repositoryPurgeDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryPurgeHandler := &repositoryPurgeHandler{
+		Context: ctx,
+	}
+
+	h := handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryPurgeHandler.GetPurge),
+	}
+	if !ctx.readOnly {
+		h[http.MethodPost] = http.HandlerFunc(repositoryPurgeHandler.StartPurge)
+	}
+
+	// the GitLab V1 API dispatcher does not enable the database by default, so it's done here for the ongoing rename
+	// check to apply
+	ctx.useDatabase = true
+
+	return checkOngoingRename(h, ctx)
+}
+
+// RepositoryPurgeAPIResponse is the response body for the repository purge endpoint. The status is `in_progress` until
+// all tags, manifests and blob links of the repository have been deleted, and `completed` afterwards.
+type RepositoryPurgeAPIResponse struct {
+	Status           string `json:"status"`
+	TagsDeleted      int64  `json:"tags_deleted"`
+	ManifestsDeleted int64  `json:"manifests_deleted"`
+	BlobsUnlinked    int64  `json:"blobs_unlinked"`
+	LastError        string `json:"last_error,omitempty"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at,omitempty"`
+	CompletedAt      string `json:"completed_at,omitempty"`
+}
+
+const (
+	repositoryPurgeStatusInProgress = "in_progress"
+	repositoryPurgeStatusCompleted  = "completed"
+)
+
+func repositoryPurgeToAPIResponse(p *models.RepositoryPurge) RepositoryPurgeAPIResponse {
+	resp := RepositoryPurgeAPIResponse{
+		Status:           repositoryPurgeStatusInProgress,
+		TagsDeleted:      p.TagsDeleted,
+		ManifestsDeleted: p.ManifestsDeleted,
+		BlobsUnlinked:    p.BlobsUnlinked,
+		LastError:        p.LastError.String,
+		CreatedAt:        timeToString(p.CreatedAt),
+	}
+	if p.Completed() {
+		resp.Status = repositoryPurgeStatusCompleted
+		resp.CompletedAt = timeToString(p.CompletedAt.Time)
+	}
+	if p.UpdatedAt.Valid {
+		resp.UpdatedAt = timeToString(p.UpdatedAt.Time)
+	}
+	return resp
+}
+
+func (h *repositoryPurgeHandler) findRepository() (*models.Repository, bool) {
+	path := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.db).FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return nil, false
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return nil, false
+	}
+	return repo, true
+}
+
+func (h *repositoryPurgeHandler) writeResponse(w http.ResponseWriter, status int, p *models.RepositoryPurge) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(repositoryPurgeToAPIResponse(p)); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// GetPurge returns the status of the last purge of a repository.
+func (h *repositoryPurgeHandler) GetPurge(w http.ResponseWriter, r *http.Request) {
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	p, err := datastore.NewRepositoryPurgeStore(h.db).FindByRepository(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if p == nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeRepositoryPurgeUnknown.WithDetail(map[string]string{"name": repo.Path}))
+		return
+	}
+
+	h.writeResponse(w, http.StatusOK, p)
+}
+
+// StartPurge starts the purge of all tags, manifests and blob links of a repository, processed asynchronously in
+// batches by the repository purges worker. If a purge is already in progress, its status is returned instead. Writes to
+// the repository are rejected until the purge is completed.
+func (h *repositoryPurgeHandler) StartPurge(w http.ResponseWriter, r *http.Request) {
+	if !deleteEnabled(h.App.Config) || !h.App.Config.Database.RepositoryPurges.Enabled {
+		h.Errors = append(h.Errors, errcode.ErrorCodeUnsupported)
+		return
+	}
+
+	repo, ok := h.findRepository()
+	if !ok {
+		return
+	}
+
+	// purges delete all tags of the repository in the background, without the auth token of this request
+	if err := newTagProtection(h, repo.Path).checkUnattended("repository purge"); err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	// read-only repositories are archived, so their contents must be kept
+	rs, err := datastore.NewRepositorySettingsStore(h.db).FindByRepository(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if rs != nil && rs.ReadOnly {
+		h.Errors = append(h.Errors, v2.ErrorCodeRepositoryReadOnly.WithDetail(map[string]string{"name": repo.Path}))
+		return
+	}
+
+	p, started, err := datastore.NewRepositoryPurgeStore(h.db).Start(h.Context, repo)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if started {
+		log.GetLogger(log.WithContext(h)).WithFields(log.Fields{"repository": repo.Path}).Info("repository purge started")
+	}
+
+	h.writeResponse(w, http.StatusAccepted, p)
+}
+
+// repositoryPurgeExecutor is a purge.Executor that deletes the contents of a repository from the metadata database, as
+// done by the tags bulk delete and manifest delete endpoints, and records the deletes in the audit log. Tags are deleted
+// first, then manifests, starting with those not referenced by others, and finally blob links. Blobs and manifests
+// left dangling are deleted from storage by online garbage collection.
+type repositoryPurgeExecutor struct {
+	app       *App
+	batchSize int
+}
+
+func (e *repositoryPurgeExecutor) audit(ctx context.Context, r audit.Record) {
+	if e.app.auditLogger == nil {
+		return
+	}
+	r.Action = audit.ActionPurgeDelete
+	r.Actor = audit.PurgeActor
+	e.app.auditLogger.Log(ctx, r)
+}
+
+// ExecuteBatch implements purge.Executor.
+func (e *repositoryPurgeExecutor) ExecuteBatch(ctx context.Context, p *models.RepositoryPurge) (bool, error) {
+	// TODO: remove as part of https://gitlab.com/gitlab-org/container-registry/-/issues/1056
+	var repoCache datastore.RepositoryCache
+	if e.app.redisCache != nil {
+		repoCache = datastore.NewCentralRepositoryCache(e.app.redisCache)
+	} else {
+		repoCache = datastore.NewSingleRepositoryCache()
+	}
+
+	rStore := datastore.NewRepositoryStore(e.app.db, datastore.WithRepositoryCache(repoCache))
+	repo, err := rStore.FindByPath(ctx, p.RepositoryPath)
+	if err != nil {
+		return false, err
+	}
+	if repo == nil {
+		// the repository was deleted in the meantime, and the purge with it
+		return true, nil
+	}
+
+	tt, err := rStore.TagsPaginated(ctx, repo, datastore.FilterParams{MaxEntries: e.batchSize})
+	if err != nil {
+		return false, err
+	}
+	if len(tt) > 0 {
+		names := make([]string, 0, len(tt))
+		for _, t := range tt {
+			names = append(names, t.Name)
+		}
+		// purges can only be started if no tags are protected, see StartPurge
+		deleted, err := dbDeleteTags(ctx, e.app.db, repoCache, getManifestCache(e.app), repo.Path, names, "", nil)
+		if err != nil {
+			var unknown distribution.ErrRepositoryUnknown
+			if errors.As(err, &unknown) {
+				return true, nil
+			}
+			return false, err
+		}
+		for _, tag := range deleted {
+			e.audit(ctx, audit.Record{Artifact: audit.ArtifactTag, Repository: repo.Path, Tag: tag})
+		}
+		p.TagsDeleted += int64(len(deleted))
+		return false, nil
+	}
+
+	pStore := datastore.NewRepositoryPurgeStore(e.app.db)
+	dd, err := pStore.FindUnreferencedManifests(ctx, repo, e.batchSize)
+	if err != nil {
+		return false, err
+	}
+	if len(dd) > 0 {
+		for _, d := range dd {
+			// referrers are deleted along with their subject, so they may be gone already
+			referrers, err := dbDeleteManifest(ctx, e.app.db, repoCache, getManifestCache(e.app), repo.Path, d, true, nil)
+			if err != nil {
+				if errors.Is(err, datastore.ErrManifestNotFound) {
+					continue
+				}
+				return false, err
+			}
+			for _, dgst := range append(referrers, d) {
+				e.audit(ctx, audit.Record{Artifact: audit.ArtifactManifest, Repository: repo.Path, Digest: dgst})
+			}
+			p.ManifestsDeleted += int64(len(referrers)) + 1
+		}
+		return false, nil
+	}
+
+	n, err := pStore.UnlinkBlobs(ctx, repo, e.batchSize)
+	if err != nil {
+		return false, err
+	}
+	p.BlobsUnlinked += n
+
+	return n == 0, nil
+}
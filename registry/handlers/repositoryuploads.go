@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/gorilla/handlers"
+)
+
+type repositoryUploadsHandler struct {
+	*Context
+}
+
+func repositoryUploadsDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryUploadsHandler := &repositoryUploadsHandler{
+		Context: ctx,
+	}
+
+	h := handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryUploadsHandler.ListUploads),
+	}
+	if !ctx.readOnly {
+		h[http.MethodDelete] = http.HandlerFunc(repositoryUploadsHandler.CancelUpload)
+	}
+
+	// the GitLab V1 API dispatcher does not enable the database by default, so it's done here for canceled uploads to
+	// be untracked
+	ctx.useDatabase = true
+
+	return h
+}
+
+// RepositoryUploadAPIResponse is the API counterpart for storage.UploadProgress.
+type RepositoryUploadAPIResponse struct {
+	UUID           string `json:"uuid"`
+	StartedAt      string `json:"started_at"`
+	Size           int64  `json:"size_bytes"`
+	BytesPerSecond int64  `json:"bytes_per_second"`
+	// LastChunkAt and SecondsSinceLastChunk are only set if data was received and the storage backend exposes when.
+	LastChunkAt           string `json:"last_chunk_at,omitempty"`
+	SecondsSinceLastChunk *int64 `json:"seconds_since_last_chunk,omitempty"`
+}
+
+func newRepositoryUploadAPIResponse(up *storage.UploadProgress, now time.Time) RepositoryUploadAPIResponse {
+	resp := RepositoryUploadAPIResponse{
+		UUID:           up.ID,
+		StartedAt:      timeToString(up.StartedAt),
+		Size:           up.Size,
+		BytesPerSecond: up.BytesPerSecond(now),
+	}
+	if !up.LastChunkAt.IsZero() {
+		resp.LastChunkAt = timeToString(up.LastChunkAt)
+		since := int64(now.Sub(up.LastChunkAt).Seconds())
+		resp.SecondsSinceLastChunk = &since
+	}
+	return resp
+}
+
+// ListUploads lists the in-progress blob uploads of a repository, oldest first.
+func (h *repositoryUploadsHandler) ListUploads(w http.ResponseWriter, r *http.Request) {
+	uu, err := storage.ListUploads(h, h.App.driver, h.Repository.Named().Name())
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	now := time.Now()
+	resp := make([]RepositoryUploadAPIResponse, 0, len(uu))
+	for _, up := range uu {
+		resp = append(resp, newRepositoryUploadAPIResponse(up, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// CancelUpload cancels an in-progress blob upload of a repository, as if canceled by the client that started it. Any
+// subsequent request of the client for the upload fails with a BLOB_UPLOAD_UNKNOWN error.
+func (h *repositoryUploadsHandler) CancelUpload(w http.ResponseWriter, r *http.Request) {
+	id := getUploadUUID(h)
+	l := log.GetLogger(log.WithContext(h)).WithFields(log.Fields{"upload_uuid": id})
+
+	upload, err := h.Repository.Blobs(h).Resume(h, id)
+	if err != nil {
+		if errors.Is(err, distribution.ErrBlobUploadUnknown) {
+			h.Errors = append(h.Errors, v2.ErrorCodeBlobUploadUnknown.WithDetail(map[string]string{"uuid": id}))
+			return
+		}
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if err := upload.Cancel(h); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	if h.trackUploads() {
+		if err := datastore.NewUploadStore(h.db).Delete(h, id); err != nil {
+			l.WithError(err).Warn("failed to untrack upload in database")
+		}
+	}
+	l.Info("blob upload canceled")
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+)
+
+// routeClass groups routes sharing the same request limits.
+type routeClass string
+
+const (
+	// routeClassBlob covers routes receiving blob data, which are exempt from the maximum body size.
+	routeClassBlob routeClass = "blob"
+	// routeClassAPI covers all other routes.
+	routeClassAPI routeClass = "api"
+)
+
+type connContextKey struct{}
+
+// ConnContext stores the connection of requests in their context, so that request body timeouts can be enforced
+// through the connection read deadline. It's meant to be used as the ConnContext of the registry http.Server.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// requestLimits protects the registry against oversized request bodies and slow clients.
+type requestLimits struct {
+	maxBodySize  int64
+	bodyTimeouts map[routeClass]time.Duration
+}
+
+func newRequestLimits(config configuration.RequestLimits) (*requestLimits, error) {
+	if config.MaxBodySize < 0 {
+		return nil, errors.New("http.requestlimits.maxbodysize must not be negative")
+	}
+	if config.BodyTimeouts.Blob < 0 || config.BodyTimeouts.API < 0 {
+		return nil, errors.New("http.requestlimits.bodytimeouts must not be negative")
+	}
+
+	return &requestLimits{
+		maxBodySize: config.MaxBodySize,
+		bodyTimeouts: map[routeClass]time.Duration{
+			routeClassBlob: config.BodyTimeouts.Blob,
+			routeClassAPI:  config.BodyTimeouts.API,
+		},
+	}, nil
+}
+
+// maxBodySizeFor returns the maximum body size of requests to routes of the given class. Zero means no limit.
+func (l *requestLimits) maxBodySizeFor(class routeClass) int64 {
+	if class == routeClassBlob {
+		return 0
+	}
+	return l.maxBodySize
+}
+
+type requestBodyKey struct{}
+
+// limitedBody is a request body enforcing the request limits. Once a limit is exceeded, reads fail with the
+// corresponding errcode.Error, which is also recorded to report it to the client. The body timeout is an idle
+// timeout: the read deadline of conn is extended each time data is received.
+type limitedBody struct {
+	io.ReadCloser
+	header  http.Header
+	conn    net.Conn
+	class   routeClass
+	limit   int64
+	timeout time.Duration
+	read    int64
+	err     error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if n > 0 && b.conn != nil {
+		_ = b.conn.SetReadDeadline(time.Now().Add(b.timeout))
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		return n, err
+	}
+
+	var netErr net.Error
+	switch {
+	case b.timeout > 0 && errors.As(err, &netErr) && netErr.Timeout():
+		b.err = errcode.ErrorCodeRequestBodyTimeout.WithDetail(map[string]string{
+			"class":   string(b.class),
+			"timeout": b.timeout.String(),
+		})
+		// the rest of the body can't be read, so the connection can't be reused. Closing it also prevents the server
+		// from trying to read the rest of the body before replying.
+		b.header.Set("Connection", "close")
+	case b.limit > 0 && b.read >= b.limit:
+		b.err = requestBodyTooLargeError(b.class, b.limit)
+	default:
+		return n, err
+	}
+
+	return n, b.err
+}
+
+func requestBodyTooLargeError(class routeClass, limit int64) error {
+	return errcode.ErrorCodeRequestBodyTooLarge.WithDetail(map[string]string{
+		"class": string(class),
+		"limit": strconv.FormatInt(limit, 10),
+	})
+}
+
+// requestLimitsMiddleware applies the request limits to the requests of routes of the given class. Requests
+// announcing a body larger than the maximum size are rejected right away. Body timeouts bound the time without
+// receiving any data, and are enforced by setting the read deadline of the underlying connection, which is only
+// possible for HTTP/1 requests, as HTTP/2 connections are shared by concurrent requests.
+func (app *App) requestLimitsMiddleware(class routeClass, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.requestLimits == nil || r.Body == nil || r.Body == http.NoBody {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		limit := app.requestLimits.maxBodySizeFor(class)
+		if limit > 0 && r.ContentLength > limit {
+			if err := errcode.ServeJSON(w, requestBodyTooLargeError(class, limit)); err != nil {
+				dcontext.GetLogger(r.Context()).Errorf("error serving error json: %v", err)
+			}
+			return
+		}
+
+		body := &limitedBody{ReadCloser: r.Body, header: w.Header(), class: class, limit: limit}
+		if limit > 0 {
+			body.ReadCloser = http.MaxBytesReader(w, r.Body, limit)
+		}
+		if d := app.requestLimits.bodyTimeouts[class]; d > 0 && r.ProtoMajor == 1 {
+			if c, ok := r.Context().Value(connContextKey{}).(net.Conn); ok && c.SetReadDeadline(time.Now().Add(d)) == nil {
+				body.conn = c
+				body.timeout = d
+				// the server only resets the read deadline when reading the next request, so an idle connection
+				// would otherwise be closed once the deadline is reached
+				defer func() {
+					if body.err == nil {
+						c.SetReadDeadline(time.Time{})
+					}
+				}()
+			}
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), requestBodyKey{}, body))
+		r.Body = body
+		h.ServeHTTP(w, r)
+	})
+}
+
+// requestLimitExceeded determines whether err was caused by a request body exceeding the request limits.
+func requestLimitExceeded(err error) bool {
+	var ec errcode.Error
+	if !errors.As(err, &ec) {
+		return false
+	}
+	return ec.Code == errcode.ErrorCodeRequestBodyTooLarge || ec.Code == errcode.ErrorCodeRequestBodyTimeout
+}
+
+// requestLimitErrors replaces errs with the error of the request limit exceeded while reading the request body, if
+// any, as the errors reported by handlers failing to read or parse the body are not meaningful to clients. Otherwise,
+// errs is returned unchanged.
+func requestLimitErrors(ctx context.Context, errs errcode.Errors) errcode.Errors {
+	body, ok := ctx.Value(requestBodyKey{}).(*limitedBody)
+	if !ok || body.err == nil {
+		return errs
+	}
+	return errcode.Errors{body.err}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequestLimits_Invalid(t *testing.T) {
+	_, err := newRequestLimits(configuration.RequestLimits{MaxBodySize: -1})
+	require.EqualError(t, err, "http.requestlimits.maxbodysize must not be negative")
+
+	_, err = newRequestLimits(configuration.RequestLimits{BodyTimeouts: configuration.BodyTimeouts{API: -time.Second}})
+	require.EqualError(t, err, "http.requestlimits.bodytimeouts must not be negative")
+}
+
+func TestRequestLimitsMiddleware_MaxBodySize(t *testing.T) {
+	limits, err := newRequestLimits(configuration.RequestLimits{MaxBodySize: 4})
+	require.NoError(t, err)
+	app := &App{Context: context.Background(), requestLimits: limits}
+
+	var (
+		readErr error
+		errs    errcode.Errors
+	)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+		errs = requestLimitErrors(r.Context(), errcode.Errors{errcode.ErrorCodeUnknown})
+	})
+
+	// requests announcing a larger body are rejected right away
+	rec := httptest.NewRecorder()
+	app.requestLimitsMiddleware(routeClassAPI, h).ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", strings.NewReader("12345")))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	var body struct{ Errors []errcode.Error }
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	require.Len(t, body.Errors, 1)
+	require.Equal(t, errcode.ErrorCodeRequestBodyTooLarge, body.Errors[0].Code)
+
+	// requests with a body of unknown size fail once the limit is exceeded
+	req := httptest.NewRequest(http.MethodPut, "/", io.MultiReader(strings.NewReader("12345")))
+	req.ContentLength = -1
+	app.requestLimitsMiddleware(routeClassAPI, h).ServeHTTP(httptest.NewRecorder(), req)
+	require.True(t, requestLimitExceeded(readErr))
+	require.Len(t, errs, 1)
+
+	var e errcode.Error
+	require.True(t, errors.As(errs[0], &e))
+	require.Equal(t, errcode.ErrorCodeRequestBodyTooLarge, e.Code)
+	require.Equal(t, map[string]string{"class": "api", "limit": "4"}, e.Detail)
+
+	// blob uploads are not limited
+	app.requestLimitsMiddleware(routeClassBlob, h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/", strings.NewReader("12345")))
+	require.NoError(t, readErr)
+	require.Equal(t, errcode.Errors{errcode.ErrorCodeUnknown}, errs)
+}
+
+func TestRequestLimitsMiddleware_BodyTimeout(t *testing.T) {
+	limits, err := newRequestLimits(configuration.RequestLimits{BodyTimeouts: configuration.BodyTimeouts{API: 50 * time.Millisecond}})
+	require.NoError(t, err)
+	app := &App{Context: context.Background(), requestLimits: limits}
+
+	readErr := make(chan error, 1)
+	s := httptest.NewUnstartedServer(app.requestLimitsMiddleware(routeClassAPI, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		readErr <- err
+		_ = errcode.ServeJSON(w, requestLimitErrors(r.Context(), errcode.Errors{errcode.FromUnknownError(err)}))
+	})))
+	s.Config.ConnContext = ConnContext
+	s.Start()
+	defer s.Close()
+
+	// send part of the body, then stall
+	c, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = fmt.Fprint(c, "PUT / HTTP/1.1\r\nHost: registry\r\nContent-Length: 10\r\n\r\n123")
+	require.NoError(t, err)
+
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(5*time.Second)))
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.True(t, requestLimitExceeded(<-readErr))
+	require.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	require.True(t, resp.Close)
+
+	var body struct{ Errors []errcode.Error }
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Errors, 1)
+	require.Equal(t, errcode.ErrorCodeRequestBodyTimeout, body.Errors[0].Code)
+}
+
+func TestRequestLimitsMiddleware_BodyTimeoutExtendedWhileReceiving(t *testing.T) {
+	limits, err := newRequestLimits(configuration.RequestLimits{BodyTimeouts: configuration.BodyTimeouts{Blob: 100 * time.Millisecond}})
+	require.NoError(t, err)
+	app := &App{Context: context.Background(), requestLimits: limits}
+
+	readErr := make(chan error, 1)
+	s := httptest.NewUnstartedServer(app.requestLimitsMiddleware(routeClassBlob, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		readErr <- err
+		w.WriteHeader(http.StatusCreated)
+	})))
+	s.Config.ConnContext = ConnContext
+	s.Start()
+	defer s.Close()
+
+	c, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = fmt.Fprint(c, "PUT / HTTP/1.1\r\nHost: registry\r\nContent-Length: 10\r\n\r\n")
+	require.NoError(t, err)
+
+	// send the body slowly, taking longer than the timeout overall but never stalling for as long
+	for i := 0; i < 10; i++ {
+		time.Sleep(30 * time.Millisecond)
+		_, err = fmt.Fprint(c, i)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(5*time.Second)))
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, <-readErr)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+}
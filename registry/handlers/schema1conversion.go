@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+// maxSchema1ConvertedLayers is the maximum number of layer descriptions cached by a schema1Converter. The cache is
+// cleared once full, which only costs decompressing layers again on the next conversions.
+const maxSchema1ConvertedLayers = 10000
+
+// schema1LayerDescription is the cached description of a layer referenced by a schema 1 manifest.
+type schema1LayerDescription struct {
+	desc   distribution.Descriptor
+	diffID digest.Digest
+}
+
+// schema1Converter converts stored schema 1 manifests to schema 2 manifests on read. Converting a manifest requires the
+// uncompressed digest of all its layers, so these are cached in memory to avoid decompressing layers on every pull.
+type schema1Converter struct {
+	mu     sync.Mutex
+	layers map[digest.Digest]schema1LayerDescription
+}
+
+func newSchema1Converter() *schema1Converter {
+	return &schema1Converter{layers: make(map[digest.Digest]schema1LayerDescription)}
+}
+
+func (c *schema1Converter) cached(dgst digest.Digest) (schema1LayerDescription, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.layers[dgst]
+	return l, ok
+}
+
+func (c *schema1Converter) cache(dgst digest.Digest, l schema1LayerDescription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.layers) >= maxSchema1ConvertedLayers {
+		c.layers = make(map[digest.Digest]schema1LayerDescription)
+	}
+	c.layers[dgst] = l
+}
+
+// convert converts m to a schema 2 manifest, publishing its image configuration to blobs if needed.
+func (c *schema1Converter) convert(ctx context.Context, m *schema1.SignedManifest, blobs distribution.BlobStore) (*schema2.DeserializedManifest, error) {
+	return schema1.ToSchema2(ctx, m, blobs, &schema1LayerDescriber{converter: c, blobs: blobs})
+}
+
+// schema1LayerDescriber is a schema1.LayerDescriber that reads layers from a repository blob store.
+type schema1LayerDescriber struct {
+	converter *schema1Converter
+	blobs     distribution.BlobStore
+}
+
+// Describe implements schema1.LayerDescriber.
+func (ld *schema1LayerDescriber) Describe(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, digest.Digest, error) {
+	if l, ok := ld.converter.cached(dgst); ok {
+		return l.desc, l.diffID, nil
+	}
+
+	desc, err := ld.blobs.Stat(ctx, dgst)
+	if err != nil {
+		return distribution.Descriptor{}, "", err
+	}
+
+	rc, err := ld.blobs.Open(ctx, dgst)
+	if err != nil {
+		return distribution.Descriptor{}, "", err
+	}
+	defer rc.Close()
+
+	// schema 1 layers are always gzip compressed tarballs
+	gr, err := gzip.NewReader(rc)
+	if err != nil {
+		return distribution.Descriptor{}, "", fmt.Errorf("decompressing layer: %w", err)
+	}
+	defer gr.Close()
+
+	digester := digest.Canonical.Digester()
+	if _, err := io.Copy(digester.Hash(), gr); err != nil {
+		return distribution.Descriptor{}, "", fmt.Errorf("decompressing layer: %w", err)
+	}
+
+	l := schema1LayerDescription{desc: desc, diffID: digester.Digest()}
+	ld.converter.cache(dgst, l)
+
+	return l.desc, l.diffID, nil
+}
+
+// canConvertSchema1 returns whether a schema 1 manifest fetched with r can be served converted to schema 2. Conversion
+// must be enabled and the manifest fetched by tag, as the converted manifest doesn't match the requested digest
+// otherwise. The client must also accept schema 2 manifests. Conversion is not available in read-only mode, as the
+// image configuration of the converted manifest may have to be written to storage.
+func (imh *manifestHandler) canConvertSchema1(r *http.Request) bool {
+	return imh.App.schema1Converter != nil && !imh.App.readOnly && imh.Tag != "" && supports(r, manifestSchema2)
+}
+
+// convertSchema1Manifest converts a stored schema 1 manifest to schema 2, returning the converted manifest along with
+// its digest.
+func (imh *manifestHandler) convertSchema1Manifest(m *schema1.SignedManifest) (*schema2.DeserializedManifest, digest.Digest, error) {
+	converted, err := imh.App.schema1Converter.convert(imh, m, imh.Repository.Blobs(imh))
+	if err != nil {
+		return nil, "", fmt.Errorf("converting schema 1 manifest: %w", err)
+	}
+
+	_, p, err := converted.Payload()
+	if err != nil {
+		return nil, "", err
+	}
+	dgst := digest.FromBytes(p)
+
+	log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{
+		"schema1_digest": imh.Digest,
+		"digest":         dgst,
+		"tag_name":       imh.Tag,
+	}).Info("converted schema 1 manifest to schema 2")
+
+	return converted, dgst, nil
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/signature"
+	"github.com/opencontainers/go-digest"
+)
+
+// dbSignatureStore is a signature.Store backed by the metadata database.
+type dbSignatureStore struct {
+	datastore.RepositoryStore
+	app  *App
+	repo *models.Repository
+}
+
+// Referrers implements signature.Store.
+func (s *dbSignatureStore) Referrers(ctx context.Context, dgst digest.Digest) ([]digest.Digest, error) {
+	m, err := s.FindManifestByDigest(ctx, s.repo, dgst)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	mm, err := s.ManifestReferrers(ctx, s.repo, m)
+	if err != nil {
+		return nil, err
+	}
+
+	dd := make([]digest.Digest, 0, len(mm))
+	for _, m := range mm {
+		dd = append(dd, m.Digest)
+	}
+	return dd, nil
+}
+
+// Tag implements signature.Store.
+func (s *dbSignatureStore) Tag(ctx context.Context, name string) (digest.Digest, error) {
+	m, err := s.FindManifestByTagName(ctx, s.repo, name)
+	if err != nil || m == nil {
+		return "", err
+	}
+	return m.Digest, nil
+}
+
+// Manifest implements signature.Store.
+func (s *dbSignatureStore) Manifest(ctx context.Context, dgst digest.Digest) ([]byte, error) {
+	m, err := s.FindManifestByDigest(ctx, s.repo, dgst)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, distribution.ErrManifestUnknownRevision{Name: s.repo.Path, Revision: dgst}
+	}
+	return m.Payload, nil
+}
+
+// Blob implements signature.Store.
+func (s *dbSignatureStore) Blob(ctx context.Context, dgst digest.Digest) ([]byte, error) {
+	return getBlob(ctx, s.app, dgst)
+}
+
+// fsSignatureStore is a signature.Store backed by the filesystem metadata. Referrers are not indexed, so only cosign
+// signatures stored under a tag can be found.
+type fsSignatureStore struct {
+	app  *App
+	repo distribution.Repository
+}
+
+// Referrers implements signature.Store.
+func (*fsSignatureStore) Referrers(context.Context, digest.Digest) ([]digest.Digest, error) {
+	return nil, nil
+}
+
+// Tag implements signature.Store.
+func (s *fsSignatureStore) Tag(ctx context.Context, name string) (digest.Digest, error) {
+	desc, err := s.repo.Tags(ctx).Get(ctx, name)
+	if err != nil {
+		if errors.As(err, &distribution.ErrTagUnknown{}) {
+			return "", nil
+		}
+		return "", err
+	}
+	return desc.Digest, nil
+}
+
+// Manifest implements signature.Store.
+func (s *fsSignatureStore) Manifest(ctx context.Context, dgst digest.Digest) ([]byte, error) {
+	ms, err := s.repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ms.Get(ctx, dgst)
+	if err != nil {
+		return nil, err
+	}
+	_, p, err := m.Payload()
+	return p, err
+}
+
+// Blob implements signature.Store.
+func (s *fsSignatureStore) Blob(ctx context.Context, dgst digest.Digest) ([]byte, error) {
+	return getBlob(ctx, s.app, dgst)
+}
+
+func getBlob(ctx context.Context, app *App, dgst digest.Digest) ([]byte, error) {
+	bp, ok := app.registry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		return nil, errors.New("unable to convert BlobEnumerator into BlobProvider")
+	}
+	return bp.Get(ctx, dgst)
+}
+
+// verifySignature checks the manifest being pulled against the signature policy of the repository, if any. Returns an
+// error to respond with if the pull must be denied.
+func (imh *manifestHandler) verifySignature(dgst digest.Digest, payload []byte) error {
+	repoPath := imh.Repository.Named().Name()
+	policy := imh.App.signatureVerifier.Policy(repoPath)
+	if policy == nil {
+		return nil
+	}
+
+	var store signature.Store
+	if imh.useDatabase {
+		rStore := datastore.NewRepositoryStore(imh.App.replicaDB(imh, repoPath))
+		r, err := rStore.FindByPath(imh, repoPath)
+		if err != nil {
+			return errcode.FromUnknownError(err)
+		}
+		if r == nil {
+			return v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath})
+		}
+		store = &dbSignatureStore{RepositoryStore: rStore, app: imh.App, repo: r}
+	} else {
+		store = &fsSignatureStore{app: imh.App, repo: imh.Repository}
+	}
+
+	l := log.GetLogger(log.WithContext(imh)).WithFields(log.Fields{
+		"repository": repoPath,
+		"digest":     dgst,
+		"tag_name":   imh.Tag,
+		"enforced":   policy.Enforced(),
+	})
+
+	err := policy.Verify(imh, store, dgst, payload)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, signature.ErrUnsigned), errors.Is(err, signature.ErrUntrusted):
+		if !policy.Enforced() {
+			l.WithError(err).Warn("pulled manifest does not have a valid signature")
+			return nil
+		}
+		l.WithError(err).Warn("denying pull of manifest without a valid signature")
+		return v2.ErrorCodeManifestSignatureRequired.WithDetail(err.Error())
+	default:
+		// fail closed if signatures can't be read, unless the policy is not enforced
+		l.WithError(err).Error("verifying manifest signatures")
+		if !policy.Enforced() {
+			return nil
+		}
+		return errcode.FromUnknownError(err)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/gorilla/handlers"
+)
+
+type sizeRecalculationHandler struct {
+	*Context
+}
+
+func sizeRecalculationDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	sizeRecalculationHandler := &sizeRecalculationHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet:  http.HandlerFunc(sizeRecalculationHandler.GetSizeRecalculation),
+		http.MethodPost: http.HandlerFunc(sizeRecalculationHandler.TriggerSizeRecalculation),
+	}
+}
+
+// SizeRecalculationAPIResponse is the response body for the size recalculation endpoint.
+type SizeRecalculationAPIResponse struct {
+	Checkpoint int64  `json:"checkpoint"`
+	LastRunAt  string `json:"last_run_at,omitempty"`
+}
+
+// SizeRecalculationAPIRequest is the request body for the size recalculation endpoint.
+type SizeRecalculationAPIRequest struct {
+	Reset bool `json:"reset"`
+}
+
+func (h *sizeRecalculationHandler) enabled() bool {
+	if !h.App.Config.Database.Enabled {
+		detail := v1.MissingServerDependencyTypeErrorDetail("database")
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail(detail))
+		return false
+	}
+	if h.App.sizeRecalculator == nil {
+		h.Errors = append(h.Errors, v1.ErrorCodeNotImplemented.WithDetail("size recalculation is not enabled"))
+		return false
+	}
+	return true
+}
+
+// GetSizeRecalculation returns the state of the background size recalculation, namely the checkpoint of the current
+// pass and the time of the last run in this registry instance.
+func (h *sizeRecalculationHandler) GetSizeRecalculation(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled() {
+		return
+	}
+
+	checkpoint, err := h.App.sizeRecalculator.Checkpoint(h.Context)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	resp := SizeRecalculationAPIResponse{Checkpoint: checkpoint}
+	if t := h.App.sizeRecalculator.LastRunAt(); !t.IsZero() {
+		resp.LastRunAt = timeToString(t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
+
+// TriggerSizeRecalculation requests an immediate size recalculation run in this registry instance, optionally
+// resetting the checkpoint to start a new pass. The run happens asynchronously.
+func (h *sizeRecalculationHandler) TriggerSizeRecalculation(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled() {
+		return
+	}
+
+	// the request body is optional
+	var req SizeRecalculationAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.Errors = append(h.Errors, v1.ErrorCodeInvalidJSONBody.WithDetail("invalid json"))
+		return
+	}
+
+	if req.Reset {
+		if err := h.App.sizeRecalculator.Reset(h.Context); err != nil {
+			h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+			return
+		}
+	}
+	h.App.sizeRecalculator.Trigger()
+
+	log.GetLogger(log.WithContext(h)).WithFields(log.Fields{"reset": req.Reset}).Info("size recalculation triggered")
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/sizes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeRecalculationHandler(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.Database.Enabled = true
+	checkpoints := sizes.NewMemoryCheckpointStore()
+	app := &App{
+		Config:           config,
+		sizeRecalculator: sizes.NewRecalculator(nil, nil, checkpoints),
+	}
+	ctx := context.Background()
+
+	serve := func(t *testing.T, method string, body io.Reader) (*httptest.ResponseRecorder, errcode.Errors) {
+		t.Helper()
+
+		h := &sizeRecalculationHandler{Context: &Context{App: app, Context: ctx}}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/gitlab/v1/sizes/recalculation/", body)
+		if method == http.MethodGet {
+			h.GetSizeRecalculation(w, r)
+		} else {
+			h.TriggerSizeRecalculation(w, r)
+		}
+
+		return w, h.Errors
+	}
+
+	require.NoError(t, checkpoints.Set(ctx, 10))
+
+	// get state
+	w, errs := serve(t, http.MethodGet, nil)
+	require.Empty(t, errs)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp SizeRecalculationAPIResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(t, SizeRecalculationAPIResponse{Checkpoint: 10}, resp)
+
+	// trigger without a body keeps the checkpoint
+	w, errs = serve(t, http.MethodPost, nil)
+	require.Empty(t, errs)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	checkpoint, err := checkpoints.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), checkpoint)
+
+	// trigger with reset
+	w, errs = serve(t, http.MethodPost, strings.NewReader(`{"reset": true}`))
+	require.Empty(t, errs)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	checkpoint, err = checkpoints.Get(ctx)
+	require.NoError(t, err)
+	require.Zero(t, checkpoint)
+
+	// invalid body
+	_, errs = serve(t, http.MethodPost, strings.NewReader(`{"reset": "yes"}`))
+	require.Len(t, errs, 1)
+	require.Equal(t, v1.ErrorCodeInvalidJSONBody, errs[0].(errcode.Error).Code)
+
+	// size recalculation disabled
+	app.sizeRecalculator = nil
+	_, errs = serve(t, http.MethodGet, nil)
+	require.Len(t, errs, 1)
+	require.Equal(t, v1.ErrorCodeNotImplemented, errs[0].(errcode.Error).Code)
+
+	// database disabled
+	config.Database.Enabled = false
+	_, errs = serve(t, http.MethodPost, nil)
+	require.Len(t, errs, 1)
+	require.Equal(t, v1.ErrorCodeNotImplemented, errs[0].(errcode.Error).Code)
+}
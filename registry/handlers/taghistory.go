@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/gorilla/handlers"
+)
+
+const tagHistoryBeforeQueryParamValueMin = 1
+
+type repositoryTagHistoryHandler struct {
+	*Context
+}
+
+func repositoryTagHistoryDispatcher(ctx *Context, _ *http.Request) http.Handler {
+	repositoryTagHistoryHandler := &repositoryTagHistoryHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(repositoryTagHistoryHandler.GetTagHistory),
+	}
+}
+
+// TagHistoryEntryAPIResponse is a single transition of a tag in the response body of the repository tag history
+// endpoint. OldDigest is empty when the tag was created and NewDigest is empty when the tag was deleted.
+type TagHistoryEntryAPIResponse struct {
+	ID        int64  `json:"id"`
+	OldDigest string `json:"old_digest,omitempty"`
+	NewDigest string `json:"new_digest,omitempty"`
+	Actor     string `json:"actor,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type tagHistoryParams struct {
+	maxEntries int
+	before     int64
+}
+
+func tagHistoryParamsFromRequest(r *http.Request) (tagHistoryParams, error) {
+	p := tagHistoryParams{maxEntries: defaultMaximumReturnedEntries}
+
+	q := r.URL.Query()
+	if q.Has(nQueryParamKey) {
+		val, valid := isQueryParamTypeInt(q.Get(nQueryParamKey))
+		if !valid {
+			detail := v1.InvalidQueryParamTypeErrorDetail(nQueryParamKey, nQueryParamValidTypes)
+			return p, v1.ErrorCodeInvalidQueryParamType.WithDetail(detail)
+		}
+		if !isQueryParamIntValueInBetween(val, nQueryParamValueMin, nQueryParamValueMax) {
+			detail := v1.InvalidQueryParamValueRangeErrorDetail(nQueryParamKey, nQueryParamValueMin, nQueryParamValueMax)
+			return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		p.maxEntries = val
+	}
+
+	if q.Has(beforeQueryParamKey) {
+		val, err := strconv.ParseInt(q.Get(beforeQueryParamKey), 10, 64)
+		if err != nil {
+			detail := v1.InvalidQueryParamTypeErrorDetail(beforeQueryParamKey, nQueryParamValidTypes)
+			return p, v1.ErrorCodeInvalidQueryParamType.WithDetail(detail)
+		}
+		if val < tagHistoryBeforeQueryParamValueMin {
+			detail := fmt.Sprintf("the '%s' query parameter value must be greater than or equal to %d", beforeQueryParamKey, tagHistoryBeforeQueryParamValueMin)
+			return p, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+		}
+		p.before = val
+	}
+
+	return p, nil
+}
+
+// GetTagHistory lists the transitions of a tag in a repository, most recent first. Results are paginated by entry ID,
+// with a Link header pointing to the next page when there may be older entries.
+func (h *repositoryTagHistoryHandler) GetTagHistory(w http.ResponseWriter, r *http.Request) {
+	params, err := tagHistoryParamsFromRequest(r)
+	if err != nil {
+		h.Errors = append(h.Errors, err)
+		return
+	}
+
+	path := h.Repository.Named().Name()
+	repo, err := datastore.NewRepositoryStore(h.db).FindByPath(h.Context, path)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+	if repo == nil {
+		h.Errors = append(h.Errors, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": path}))
+		return
+	}
+
+	tagName := getTag(h)
+	ee, err := datastore.NewTagHistoryStore(h.db).FindByTag(h.Context, repo, tagName, params.before, params.maxEntries)
+	if err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+
+	// a full page means there may be older entries
+	if len(ee) == params.maxEntries {
+		u := *r.URL
+		q := url.Values{}
+		q.Add(nQueryParamKey, strconv.Itoa(params.maxEntries))
+		q.Add(beforeQueryParamKey, strconv.FormatInt(ee[len(ee)-1].ID, 10))
+		u.RawQuery = q.Encode()
+		u.Fragment = ""
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), linkNext))
+	}
+
+	resp := make([]TagHistoryEntryAPIResponse, 0, len(ee))
+	for _, e := range ee {
+		resp = append(resp, TagHistoryEntryAPIResponse{
+			ID:        e.ID,
+			OldDigest: e.OldDigest.String(),
+			NewDigest: e.NewDigest.String(),
+			Actor:     e.Actor,
+			CreatedAt: timeToString(e.CreatedAt),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(resp); err != nil {
+		h.Errors = append(h.Errors, errcode.FromUnknownError(err))
+		return
+	}
+}
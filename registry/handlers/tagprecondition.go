@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/opencontainers/go-digest"
+)
+
+// tagPrecondition is the condition set by clients with the If-Match header for a tag to be updated or deleted. The
+// operation is only carried out if the tag exists and points to one of the given manifest digests, or to any manifest
+// if the wildcard was used.
+type tagPrecondition struct {
+	any     bool
+	digests []digest.Digest
+}
+
+// parseTagPrecondition parses the If-Match header of r, a comma-separated list of manifest digests or the `*` wildcard.
+// Digests may be quoted, as entity tags usually are. A nil precondition is returned if the header is not set.
+func parseTagPrecondition(r *http.Request) (*tagPrecondition, error) {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	p := &tagPrecondition{}
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.Trim(strings.TrimSpace(s), `"`)
+			if s == "*" {
+				p.any = true
+				continue
+			}
+			d, err := digest.Parse(s)
+			if err != nil {
+				return nil, v2.ErrorCodeDigestInvalid.WithDetail(fmt.Sprintf("invalid If-Match header value %q: %v", s, err))
+			}
+			p.digests = append(p.digests, d)
+		}
+	}
+
+	return p, nil
+}
+
+// matches returns whether a tag pointing to the manifest with digest d satisfies the precondition. An empty digest
+// stands for a tag that does not exist, which never does.
+func (p *tagPrecondition) matches(d digest.Digest) bool {
+	if d == "" {
+		return false
+	}
+	if p.any {
+		return true
+	}
+	for _, pd := range p.digests {
+		if pd == d {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns a tag precondition failed error if a tag pointing to the manifest with digest d does not satisfy the
+// precondition. A nil precondition is always satisfied.
+func (p *tagPrecondition) check(tagName string, d digest.Digest) error {
+	if p == nil || p.matches(d) {
+		return nil
+	}
+	if d == "" {
+		return v2.ErrorCodeTagPreconditionFailed.WithDetail(fmt.Sprintf("tag %q does not exist", tagName))
+	}
+
+	return v2.ErrorCodeTagPreconditionFailed.WithDetail(fmt.Sprintf("tag %q points to %s", tagName, d))
+}
+
+// dbCheckTagPrecondition locks the tag with the given name and checks it against the precondition p, so that the tag
+// can not change until the end of the transaction in which rStore operates. The locked tag is returned, which is nil if
+// the tag does not exist and there is no precondition.
+func dbCheckTagPrecondition(ctx context.Context, rStore datastore.RepositoryStore, r *models.Repository, tagName string, p *tagPrecondition) (*models.Tag, error) {
+	t, err := rStore.LockTagByName(ctx, r, tagName)
+	if err != nil {
+		return nil, err
+	}
+
+	var d digest.Digest
+	if t != nil {
+		m, err := rStore.FindManifestByTagName(ctx, r, tagName)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			d = m.Digest
+		}
+	}
+	if err := p.check(tagName, d); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// fsCheckTagPrecondition checks the tag with the given name against the precondition p. Unlike with the database, the
+// tag is not locked, so a concurrent change is not detected if it happens after the check.
+func fsCheckTagPrecondition(ctx context.Context, ts distribution.TagService, tagName string, p *tagPrecondition) error {
+	if p == nil {
+		return nil
+	}
+
+	var d digest.Digest
+	desc, err := ts.Get(ctx, tagName)
+	switch {
+	case err == nil:
+		d = desc.Digest
+	case !errors.As(err, &distribution.ErrTagUnknown{}):
+		return err
+	}
+
+	return p.check(tagName, d)
+}
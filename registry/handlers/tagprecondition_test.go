@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTagPrecondition(t *testing.T) {
+	d1 := digest.FromString("foo")
+	d2 := digest.FromString("bar")
+
+	tcs := map[string]struct {
+		header   []string
+		expected *tagPrecondition
+	}{
+		"none":     {},
+		"digest":   {header: []string{d1.String()}, expected: &tagPrecondition{digests: []digest.Digest{d1}}},
+		"quoted":   {header: []string{`"` + d1.String() + `"`}, expected: &tagPrecondition{digests: []digest.Digest{d1}}},
+		"list":     {header: []string{d1.String() + ` , "` + d2.String() + `"`}, expected: &tagPrecondition{digests: []digest.Digest{d1, d2}}},
+		"repeated": {header: []string{d1.String(), d2.String()}, expected: &tagPrecondition{digests: []digest.Digest{d1, d2}}},
+		"wildcard": {header: []string{"*"}, expected: &tagPrecondition{any: true}},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("DELETE", "/v2/foo/manifests/latest", nil)
+			for _, v := range tc.header {
+				r.Header.Add("If-Match", v)
+			}
+			p, err := parseTagPrecondition(r)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, p)
+		})
+	}
+
+	r := httptest.NewRequest("DELETE", "/v2/foo/manifests/latest", nil)
+	r.Header.Set("If-Match", "latest")
+	_, err := parseTagPrecondition(r)
+	var errc errcode.Error
+	require.ErrorAs(t, err, &errc)
+	require.Equal(t, v2.ErrorCodeDigestInvalid, errc.Code)
+}
+
+func TestTagPrecondition_Check(t *testing.T) {
+	d1 := digest.FromString("foo")
+	d2 := digest.FromString("bar")
+
+	var p *tagPrecondition
+	require.NoError(t, p.check("latest", ""))
+	require.NoError(t, p.check("latest", d1))
+
+	p = &tagPrecondition{digests: []digest.Digest{d1}}
+	require.NoError(t, p.check("latest", d1))
+
+	err := p.check("latest", d2)
+	var errc errcode.Error
+	require.ErrorAs(t, err, &errc)
+	require.Equal(t, v2.ErrorCodeTagPreconditionFailed, errc.Code)
+	require.Equal(t, `tag "latest" points to `+d2.String(), errc.Detail)
+
+	err = p.check("latest", "")
+	require.ErrorAs(t, err, &errc)
+	require.Equal(t, `tag "latest" does not exist`, errc.Detail)
+
+	p = &tagPrecondition{any: true}
+	require.NoError(t, p.check("latest", d2))
+	require.Error(t, p.check("latest", ""))
+}
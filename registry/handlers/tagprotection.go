@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/docker/distribution/log"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/auth"
+)
+
+// tagProtection holds the protected tag patterns that the auth token of a request was issued with for a repository.
+// Protected tags can not be pushed or deleted by the request, regardless of the actions granted by the token. Patterns
+// are regular expressions, matched anywhere within tag names unless anchored.
+type tagProtection struct {
+	patterns []*regexp.Regexp
+	// invalid is set if any of the patterns could not be compiled, in which case all tags are protected, as it is not
+	// possible to tell which ones the issuer meant to protect.
+	invalid bool
+}
+
+// newTagProtection returns the tag protection of the repository with the given path for the request in ctx. A nil tag
+// protection is returned if there are no protected tag patterns.
+func newTagProtection(ctx context.Context, repoPath string) *tagProtection {
+	patterns := auth.ProtectedTagPatterns(ctx, repoPath)
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	p := &tagProtection{patterns: make([]*regexp.Regexp, 0, len(patterns))}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.GetLogger(log.WithContext(ctx)).WithError(err).WithFields(log.Fields{
+				"repository": repoPath,
+				"pattern":    pattern,
+			}).Warn("invalid protected tag pattern, protecting all tags")
+			p.invalid = true
+			continue
+		}
+		p.patterns = append(p.patterns, re)
+	}
+
+	return p
+}
+
+// protected returns whether the tag with the given name is protected.
+func (p *tagProtection) protected(tagName string) bool {
+	if p.invalid {
+		return true
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(tagName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns a tag protected error if any of the tags with the given names is protected. A nil tag protection
+// protects no tags.
+func (p *tagProtection) check(tagNames ...string) error {
+	if p == nil {
+		return nil
+	}
+	for _, name := range tagNames {
+		if p.protected(name) {
+			return v2.ErrorCodeTagProtected.WithDetail(fmt.Sprintf("tag %q is protected", name))
+		}
+	}
+
+	return nil
+}
+
+// checkUnattended returns a tag protected error if any tags are protected. This applies to actions requested with the
+// auth token that delete tags later on without it, such as repository purges and cleanup policies, as it is not
+// possible to tell which tags these will delete. A nil tag protection protects no tags.
+func (p *tagProtection) checkUnattended(action string) error {
+	if p == nil {
+		return nil
+	}
+	return v2.ErrorCodeTagProtected.WithDetail(fmt.Sprintf("%s is not allowed while tags are protected", action))
+}
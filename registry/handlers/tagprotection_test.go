@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTagProtection(t *testing.T) {
+	ctx := auth.WithProtectedTagPatterns(context.Background(), map[string][]string{
+		"group/project": {"^v[0-9]"},
+		"group/*":       {"^latest$"},
+		"other/project": {"[invalid"},
+	})
+
+	require.Nil(t, newTagProtection(context.Background(), "group/project"))
+	require.Nil(t, newTagProtection(ctx, "foo/bar"))
+
+	p := newTagProtection(ctx, "group/project")
+	require.NotNil(t, p)
+	require.False(t, p.invalid)
+	require.Len(t, p.patterns, 2)
+
+	p = newTagProtection(ctx, "other/project")
+	require.NotNil(t, p)
+	require.True(t, p.invalid)
+	require.Empty(t, p.patterns)
+}
+
+func TestTagProtection_Check(t *testing.T) {
+	var p *tagProtection
+	require.NoError(t, p.check("v1", "latest"))
+
+	ctx := auth.WithProtectedTagPatterns(context.Background(), map[string][]string{
+		"group/project": {"^v[0-9]", "stable"},
+	})
+	p = newTagProtection(ctx, "group/project")
+	require.NoError(t, p.check())
+	require.NoError(t, p.check("latest", "dev", "version"))
+
+	for _, tag := range []string{"v1", "v2.0.1", "stable", "1.0-stable-amd64"} {
+		err := p.check("latest", tag)
+		var errc errcode.Error
+		require.ErrorAs(t, err, &errc, tag)
+		require.Equal(t, v2.ErrorCodeTagProtected, errc.Code)
+		require.Equal(t, `tag "`+tag+`" is protected`, errc.Detail)
+	}
+
+	// invalid patterns protect all tags
+	p = &tagProtection{invalid: true}
+	require.Error(t, p.check("latest"))
+}
+
+func TestTagProtection_CheckUnattended(t *testing.T) {
+	var p *tagProtection
+	require.NoError(t, p.checkUnattended("repository purge"))
+
+	ctx := auth.WithProtectedTagPatterns(context.Background(), map[string][]string{
+		"group/project": {"^v[0-9]"},
+	})
+	err := newTagProtection(ctx, "group/project").checkUnattended("repository purge")
+	var errc errcode.Error
+	require.ErrorAs(t, err, &errc)
+	require.Equal(t, v2.ErrorCodeTagProtected, errc.Code)
+	require.Equal(t, "repository purge is not allowed while tags are protected", errc.Detail)
+}
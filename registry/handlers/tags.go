@@ -2,17 +2,20 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/log"
 	"github.com/docker/distribution/registry/api/errcode"
 	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/audit"
+	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/gorilla/handlers"
 )
@@ -38,22 +41,24 @@ type tagsAPIResponse struct {
 	Tags []string `json:"tags"`
 }
 
-func dbGetTags(ctx context.Context, db datastore.Queryer, repoPath string, filters datastore.FilterParams) ([]string, bool, error) {
+// dbGetTags returns a page of tags of the repository with the given path, and whether there are more entries to
+// retrieve. The total number of tags across all pages is also returned if counter is not nil, otherwise it is zero.
+func dbGetTags(ctx context.Context, db datastore.Queryer, repoPath string, filters datastore.FilterParams, counter *totalCounter) ([]string, bool, int, error) {
 	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": repoPath, "limit": filters.MaxEntries, "marker": filters.LastEntry})
 	l.Debug("finding tags in database")
 
 	rStore := datastore.NewRepositoryStore(db)
 	r, err := rStore.FindByPath(ctx, repoPath)
 	if err != nil {
-		return nil, false, err
+		return nil, false, 0, err
 	}
 	if r == nil {
-		return nil, false, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath})
+		return nil, false, 0, v2.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": repoPath})
 	}
 
 	tt, err := rStore.TagsPaginated(ctx, r, filters)
 	if err != nil {
-		return nil, false, err
+		return nil, false, 0, err
 	}
 
 	tags := make([]string, 0, len(tt))
@@ -66,11 +71,22 @@ func dbGetTags(ctx context.Context, db datastore.Queryer, repoPath string, filte
 		filters.LastEntry = tt[len(tt)-1].Name
 		moreEntries, err = rStore.HasTagsAfterName(ctx, r, filters)
 		if err != nil {
-			return nil, false, err
+			return nil, false, 0, err
 		}
 	}
 
-	return tags, moreEntries, nil
+	var total int
+	if counter != nil {
+		list := fmt.Sprintf("tags:%d:%d", r.NamespaceID, r.ID)
+		total, err = counter.count(ctx, list, filters, func() (int, error) {
+			return rStore.TagsCount(ctx, r, filters)
+		})
+		if err != nil {
+			return nil, false, 0, err
+		}
+	}
+
+	return tags, moreEntries, total, nil
 }
 
 // GetTags returns a json list of tags for a specific image name.
@@ -92,9 +108,18 @@ func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 
 	var tags []string
 	var moreEntries bool
+	var counter *totalCounter
+	var total int
 
 	if th.useDatabase {
-		tags, moreEntries, err = dbGetTags(th.Context, th.db, th.Repository.Named().Name(), filters)
+		// Total count headers are only supported by the metadata database backend. As with `n`, an invalid value is
+		// ignored instead of rejected, for consistency with the rest of the V2 API.
+		if countTotal, _ := countQueryParamValue(q); countTotal {
+			counter = newTotalCounter(th.App)
+		}
+
+		path := th.Repository.Named().Name()
+		tags, moreEntries, total, err = dbGetTags(th.Context, th.App.replicaDB(th, path), path, filters, counter)
 		if err != nil {
 			th.Errors = append(th.Errors, errcode.FromUnknownError(err))
 			return
@@ -119,6 +144,9 @@ func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if counter != nil {
+		setTotalCountHeaders(w, total, filters.MaxEntries)
+	}
 
 	// Add a link header if there are more entries to retrieve (only supported by the metadata database backend)
 	if moreEntries {
@@ -133,8 +161,7 @@ func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	enc := json.NewEncoder(w)
-	if err := enc.Encode(tagsAPIResponse{
+	if err := writeJSONWithETag(w, r, tagsAPIResponse{
 		Name: th.Repository.Named().Name(),
 		Tags: tags,
 	}); err != nil {
@@ -156,7 +183,7 @@ func tagDispatcher(ctx *Context, r *http.Request) http.Handler {
 		thandler[http.MethodDelete] = http.HandlerFunc(tagHandler.DeleteTag)
 	}
 
-	return checkOngoingRename(thandler, ctx)
+	return checkOngoingRename(checkReadOnlyRepository(thandler, ctx), ctx)
 }
 
 // tagHandler handles requests for a specific tag under a repository name.
@@ -170,7 +197,8 @@ const (
 	tagDeleteGCLockTimeout  = 5 * time.Second
 )
 
-func dbDeleteTag(ctx context.Context, db datastore.Handler, cache datastore.RepositoryCache, repoPath string, tagName string) error {
+// dbDeleteTag deletes a tag from a repository. If precondition is not nil, the tag is only deleted if it satisfies it.
+func dbDeleteTag(ctx context.Context, db datastore.Handler, cache datastore.RepositoryCache, manifestCache datastore.ManifestCache, repoPath string, tagName string, precondition *tagPrecondition) error {
 	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": repoPath, "tag_name": tagName})
 	l.Debug("deleting tag from repository in database")
 
@@ -192,6 +220,9 @@ func dbDeleteTag(ctx context.Context, db datastore.Handler, cache datastore.Repo
 		return err
 	}
 	if t == nil {
+		if precondition != nil {
+			return precondition.check(tagName, "")
+		}
 		return distribution.ErrTagUnknown{Tag: tagName}
 	}
 
@@ -218,6 +249,23 @@ func dbDeleteTag(ctx context.Context, db datastore.Handler, cache datastore.Repo
 	// lock on the review queue in case of conflict. Not using the same transaction for both operations (i.e., using
 	// `tx` for `FindAndLockBefore` and `db` for `DeleteTagByName`) would therefore result in a deadlock.
 	rStore = datastore.NewRepositoryStore(tx, datastore.WithRepositoryCache(cache))
+
+	// The precondition must be checked against the tag locked within the transaction, as it may have changed since it
+	// was first read. If it now points to a different manifest, the related GC manifest review record must be locked
+	// as well.
+	if precondition != nil {
+		lt, err := dbCheckTagPrecondition(txCtx, rStore, r, tagName, precondition)
+		if err != nil {
+			return err
+		}
+		if lt.ManifestID != t.ManifestID {
+			if _, err := mts.FindAndLockBefore(txCtx, r.NamespaceID, r.ID, lt.ManifestID, time.Now().Add(tagDeleteGCReviewWindow)); err != nil {
+				return err
+			}
+		}
+		t = lt
+	}
+
 	found, err := rStore.DeleteTagByName(txCtx, r, tagName)
 	if err != nil {
 		return err
@@ -226,13 +274,113 @@ func dbDeleteTag(ctx context.Context, db datastore.Handler, cache datastore.Repo
 		return distribution.ErrTagUnknown{Tag: tagName}
 	}
 
+	ths := datastore.NewTagHistoryStore(tx)
+	if err := ths.RecordTransition(txCtx, r, tagName, t.ManifestID, 0, tagHistoryActor(ctx)); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit database transaction: %w", err)
 	}
+	manifestCache.InvalidateTags(ctx, r, tagName)
 
 	return nil
 }
 
+// tagHistoryActor returns the name of the user to whom tag transitions are attributed in the tag history, or an
+// empty string if the request is anonymous.
+func tagHistoryActor(ctx context.Context) string {
+	return dcontext.GetStringValue(ctx, auth.UserNameKey)
+}
+
+// dbDeleteTags deletes multiple tags of a repository within a single database transaction. Tags are identified either
+// by a list of names or by a name regular expression, in which case up to maxTagsToBulkDelete matching tags are
+// deleted. If any of the tags is protected, none is deleted. The names of the deleted tags are returned,
+// lexicographically sorted.
+func dbDeleteTags(ctx context.Context, db datastore.Handler, cache datastore.RepositoryCache, manifestCache datastore.ManifestCache, repoPath string, names []string, nameRegex string, protection *tagProtection) ([]string, error) {
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository": repoPath})
+	l.Debug("deleting tags from repository in database")
+
+	rStore := datastore.NewRepositoryStore(db, datastore.WithRepositoryCache(cache))
+	r, err := rStore.FindByPath(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, distribution.ErrRepositoryUnknown{Name: repoPath}
+	}
+
+	// See dbDeleteTag for the rationale behind the transaction timeout and the locking of related GC manifest review
+	// records. The only difference here is that all records are locked at once, in a deterministic order.
+	txCtx, cancel := context.WithTimeout(ctx, tagDeleteGCLockTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(txCtx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rStore = datastore.NewRepositoryStore(tx, datastore.WithRepositoryCache(cache))
+
+	var tt models.Tags
+	if nameRegex != "" {
+		tt, err = rStore.FindTagsByNameRegex(txCtx, r, nameRegex, maxTagsToBulkDelete)
+	} else {
+		tt, err = rStore.FindTagsByNames(txCtx, r, names)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(tt) == 0 {
+		return []string{}, nil
+	}
+
+	found := make([]string, 0, len(tt))
+	ids := make([]int64, 0, len(tt))
+	seen := make(map[int64]struct{}, len(tt))
+	for _, t := range tt {
+		found = append(found, t.Name)
+		if _, ok := seen[t.ManifestID]; !ok {
+			seen[t.ManifestID] = struct{}{}
+			ids = append(ids, t.ManifestID)
+		}
+	}
+
+	if err := protection.check(found...); err != nil {
+		return nil, err
+	}
+
+	mts := datastore.NewGCManifestTaskStore(tx)
+	if _, err := mts.FindAndLockNBefore(txCtx, r.NamespaceID, r.ID, ids, time.Now().Add(tagDeleteGCReviewWindow)); err != nil {
+		return nil, err
+	}
+
+	deleted, err := rStore.DeleteTagsByNames(txCtx, r, found)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestIDs := make(map[string]int64, len(tt))
+	for _, t := range tt {
+		manifestIDs[t.Name] = t.ManifestID
+	}
+	ths := datastore.NewTagHistoryStore(tx)
+	actor := tagHistoryActor(ctx)
+	for _, name := range deleted {
+		if err := ths.RecordTransition(txCtx, r, name, manifestIDs[name], 0, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit database transaction: %w", err)
+	}
+	manifestCache.InvalidateTags(ctx, r, deleted...)
+
+	return deleted, nil
+}
+
 // DeleteTag deletes a tag for a specific image name.
 func (th *tagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 	l := log.GetLogger(log.WithContext(th))
@@ -241,8 +389,22 @@ func (th *tagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 		"GitLab 17.0. Please use the new DELETE /v2/<name>/manifests/<tag> endpoint to delete tags. " +
 		"See https://gitlab.com/gitlab-org/container-registry/-/issues/1094 for more details")
 
+	precondition, err := parseTagPrecondition(r)
+	if err != nil {
+		th.Errors = append(th.Errors, err)
+		return
+	}
+	if err := newTagProtection(th, th.Repository.Named().Name()).check(th.Tag); err != nil {
+		th.Errors = append(th.Errors, err)
+		return
+	}
+
 	if !th.useDatabase {
 		tagService := th.Repository.Tags(th)
+		if err := fsCheckTagPrecondition(th, tagService, th.Tag, precondition); err != nil {
+			th.appendDeleteTagError(err)
+			return
+		}
 		if err := tagService.Untag(th.Context, th.Tag); err != nil {
 			th.appendDeleteTagError(err)
 			return
@@ -256,15 +418,17 @@ func (th *tagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 			repoCache = th.repoCache
 		}
 
-		if err := dbDeleteTag(th.Context, th.db, repoCache, th.Repository.Named().Name(), th.Tag); err != nil {
+		if err := dbDeleteTag(th.Context, th.db, repoCache, getManifestCache(th.App), th.Repository.Named().Name(), th.Tag, precondition); err != nil {
 			th.appendDeleteTagError(err)
 			return
 		}
+		th.mirrorFSUntag(th.Tag)
 	}
 
 	if err := th.queueBridge.TagDeleted(th.Repository.Named(), th.Tag); err != nil {
 		l.WithError(err).Error("dispatching tag delete to queue")
 	}
+	th.recordAudit(audit.Record{Action: audit.ActionDelete, Artifact: audit.ArtifactTag, Tag: th.Tag})
 
 	w.WriteHeader(http.StatusAccepted)
 }
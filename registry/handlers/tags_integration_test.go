@@ -6,10 +6,12 @@ import (
 	"testing"
 
 	"github.com/docker/distribution/manifest/schema2"
-
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/datastore"
 	"github.com/docker/distribution/registry/datastore/models"
 	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -51,7 +53,7 @@ func TestDeleteTagDB(t *testing.T) {
 
 	// Test
 
-	err = dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), r.Path, tag.Name)
+	err = dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), datastore.NewNoOpManifestCache(), r.Path, tag.Name, nil)
 	require.NoError(t, err)
 
 	// the tag shouldn't be there
@@ -102,7 +104,7 @@ func TestDeleteTagDB_SizeInvalidated_WithCentralRepositoryCache(t *testing.T) {
 	require.NoError(t, err)
 
 	// delete the tag
-	err = dbDeleteTag(env.ctx, env.db, cache, r.Path, tag.Name)
+	err = dbDeleteTag(env.ctx, env.db, cache, datastore.NewNoOpManifestCache(), r.Path, tag.Name, nil)
 	require.NoError(t, err)
 
 	// the size attribute of the cached repository object is also removed
@@ -153,7 +155,7 @@ func TestDeleteTagDB_SizeInvalidated_WithSingleRepositoryCache(t *testing.T) {
 	require.NoError(t, err)
 
 	// delete the tag
-	err = dbDeleteTag(env.ctx, env.db, cache, r.Path, tag.Name)
+	err = dbDeleteTag(env.ctx, env.db, cache, datastore.NewNoOpManifestCache(), r.Path, tag.Name, nil)
 	require.NoError(t, err)
 
 	// the size attribute of the cached repository object is also removed
@@ -165,7 +167,7 @@ func TestDeleteTagDB_RepositoryNotFound(t *testing.T) {
 	env := newEnv(t)
 	defer env.shutdown(t)
 
-	err := dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), "foo", "bar")
+	err := dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), datastore.NewNoOpManifestCache(), "foo", "bar", nil)
 	require.Error(t, err, "repository not found in database")
 
 }
@@ -180,6 +182,60 @@ func TestDeleteTagDB_TagNotFound(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, r)
 
-	err = dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), r.Path, "bar")
+	err = dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), datastore.NewNoOpManifestCache(), r.Path, "bar", nil)
 	require.Error(t, err, "repository not found in database")
 }
+
+func TestDeleteTagDB_Precondition(t *testing.T) {
+	env := newEnv(t)
+	defer env.shutdown(t)
+
+	rStore := datastore.NewRepositoryStore(env.db)
+	r, err := rStore.CreateByPath(env.ctx, "foo")
+	require.NoError(t, err)
+
+	mStore := datastore.NewManifestStore(env.db)
+	m := &models.Manifest{
+		NamespaceID:   r.NamespaceID,
+		RepositoryID:  r.ID,
+		SchemaVersion: 2,
+		MediaType:     schema2.MediaTypeManifest,
+		Digest:        "sha256:bca3c0bf2ca0cde987ad9cab2dac986047a0ccff282f1b23df282ef05e3a10a6",
+		Payload:       models.Payload{},
+	}
+	require.NoError(t, mStore.Create(env.ctx, m))
+
+	tStore := datastore.NewTagStore(env.db)
+	tag := &models.Tag{
+		Name:         "latest",
+		NamespaceID:  r.NamespaceID,
+		RepositoryID: r.ID,
+		ManifestID:   m.ID,
+	}
+	require.NoError(t, tStore.CreateOrUpdate(env.ctx, tag))
+
+	// the tag is kept if it does not point to the expected manifest
+	p := &tagPrecondition{digests: []digest.Digest{"sha256:2b3a43c53f70d4a7ec5a8da9e8c0d6d6d5f5c4e7b0a1f2e3d4c5b6a798091a2b"}}
+	err = dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), datastore.NewNoOpManifestCache(), r.Path, tag.Name, p)
+	var errc errcode.Error
+	require.ErrorAs(t, err, &errc)
+	require.Equal(t, v2.ErrorCodeTagPreconditionFailed, errc.Code)
+
+	found, err := tStore.FindByID(env.ctx, tag.ID)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+
+	// and deleted otherwise
+	p = &tagPrecondition{digests: []digest.Digest{m.Digest}}
+	err = dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), datastore.NewNoOpManifestCache(), r.Path, tag.Name, p)
+	require.NoError(t, err)
+
+	found, err = tStore.FindByID(env.ctx, tag.ID)
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	// a tag that does not exist never satisfies a precondition
+	err = dbDeleteTag(env.ctx, env.db, datastore.NewNoOpRepositoryCache(), datastore.NewNoOpManifestCache(), r.Path, tag.Name, &tagPrecondition{any: true})
+	require.ErrorAs(t, err, &errc)
+	require.Equal(t, v2.ErrorCodeTagPreconditionFailed, errc.Code)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	v1 "github.com/docker/distribution/registry/api/gitlab/v1"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+)
+
+const (
+	// countQueryParamKey is the query parameter used to opt in to the total count headers of paginated list endpoints.
+	countQueryParamKey = "count"
+	// totalCountHeader reports the total number of entries across all pages of a paginated list.
+	totalCountHeader = "X-Total-Count"
+	// totalPagesHeader reports the total number of pages of a paginated list for the requested page size (`n`).
+	totalPagesHeader = "X-Total-Pages"
+	// totalCountCacheTTL is the expiration of total counts cached in Redis. Counts are not invalidated on writes, so
+	// this bounds how stale a reported total can be, in exchange for not counting all entries on every page request.
+	totalCountCacheTTL = 30 * time.Second
+)
+
+// countQueryParamValue extracts the value of the `count` query parameter from q. False is returned if the parameter is
+// not set, and an error is returned if its value is neither "true" nor "false".
+func countQueryParamValue(q url.Values) (bool, error) {
+	if !q.Has(countQueryParamKey) {
+		return false, nil
+	}
+	switch v := q.Get(countQueryParamKey); v {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown value: %s", v)
+	}
+}
+
+// v1CountQueryParamValue extracts the value of the `count` query parameter for the GitLab V1 API list endpoints, where,
+// unlike in the V2 API, invalid values are rejected.
+func v1CountQueryParamValue(r *http.Request) (bool, error) {
+	countTotal, err := countQueryParamValue(r.URL.Query())
+	if err != nil {
+		detail := v1.InvalidQueryParamValueErrorDetail(countQueryParamKey, []string{"true", "false"})
+		return false, v1.ErrorCodeInvalidQueryParamValue.WithDetail(detail)
+	}
+
+	return countTotal, nil
+}
+
+// totalCounter obtains the total number of entries of paginated lists, caching them in Redis if available.
+type totalCounter struct {
+	cache datastore.CountCache
+}
+
+func newTotalCounter(app *App) *totalCounter {
+	var cache datastore.CountCache = datastore.NewNoOpCountCache()
+	if app.redisCache != nil {
+		cache = datastore.NewCentralCountCache(app.redisCache, totalCountCacheTTL)
+	}
+	return &totalCounter{cache: cache}
+}
+
+// count returns the total number of entries of the list identified by list, with the given filters applied. The cached
+// count is returned if any, otherwise countFn is used to count the entries, and the result is cached.
+func (c *totalCounter) count(ctx context.Context, list string, filters datastore.FilterParams, countFn func() (int, error)) (int, error) {
+	key := totalCountCacheKey(list, filters)
+	if count, ok := c.cache.Get(ctx, key); ok {
+		return count, nil
+	}
+
+	count, err := countFn()
+	if err != nil {
+		return 0, err
+	}
+	c.cache.Set(ctx, key, count)
+
+	return count, nil
+}
+
+// totalCountCacheKey builds the cache key for the total count of the list identified by list. Only filters affect the
+// total count, so pagination parameters are not part of the key.
+func totalCountCacheKey(list string, filters datastore.FilterParams) string {
+	v := url.Values{}
+	v.Set("list", list)
+	v.Set(tagNameQueryParamKey, filters.Name)
+	v.Set(tagNamePrefixQueryParamKey, filters.NamePrefix)
+	v.Set(tagNameRegexQueryParamKey, filters.NameRegex)
+	v.Set(artifactTypeQueryParamKey, filters.ArtifactType)
+	v.Set(catalogPrefixQueryParamKey, filters.PathPrefix)
+	if !filters.ModifiedSince.IsZero() {
+		v.Set(catalogModifiedSinceQueryParamKey, filters.ModifiedSince.UTC().Format(time.RFC3339Nano))
+	}
+	for _, l := range filters.Labels {
+		v.Add(labelQueryParamKey, l.String())
+	}
+
+	return v.Encode()
+}
+
+// setTotalCountHeaders sets the total count headers of a paginated list response, where total is the number of
+// entries across all pages and pageSize is the maximum number of entries per page.
+func setTotalCountHeaders(w http.ResponseWriter, total, pageSize int) {
+	w.Header().Set(totalCountHeader, strconv.Itoa(total))
+	w.Header().Set(totalPagesHeader, strconv.Itoa((total+pageSize-1)/pageSize))
+}
+
+// countRepositoriesWithTagsForPath returns the total number of repositories with at least one tag under the path of
+// repo, as listed by the GitLab V1 sub-repositories and group repositories endpoints. Both share the same cached count.
+// These lists can only be filtered by labels, so other filters are ignored.
+func countRepositoriesWithTagsForPath(ctx *Context, rStore datastore.RepositoryStore, repo *models.Repository, filters datastore.FilterParams) (int, error) {
+	list := fmt.Sprintf("repositories:%d:%s", repo.NamespaceID, repo.Path)
+	filters = datastore.FilterParams{Labels: filters.Labels}
+	return newTotalCounter(ctx.App).count(ctx, list, filters, func() (int, error) {
+		return rStore.CountRepositoriesWithTagsForPath(ctx, repo, filters)
+	})
+}
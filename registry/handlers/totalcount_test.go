@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountQueryParamValue(t *testing.T) {
+	tt := []struct {
+		name        string
+		query       url.Values
+		expected    bool
+		expectedErr bool
+	}{
+		{name: "not set", query: url.Values{}},
+		{name: "true", query: url.Values{"count": []string{"true"}}, expected: true},
+		{name: "false", query: url.Values{"count": []string{"false"}}},
+		{name: "empty", query: url.Values{"count": []string{""}}, expectedErr: true},
+		{name: "invalid", query: url.Values{"count": []string{"1"}}, expectedErr: true},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := countQueryParamValue(test.query)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func TestSetTotalCountHeaders(t *testing.T) {
+	tt := []struct {
+		total         int
+		pageSize      int
+		expectedPages string
+	}{
+		{total: 0, pageSize: 100, expectedPages: "0"},
+		{total: 1, pageSize: 100, expectedPages: "1"},
+		{total: 100, pageSize: 100, expectedPages: "1"},
+		{total: 101, pageSize: 100, expectedPages: "2"},
+		{total: 5, pageSize: 1, expectedPages: "5"},
+	}
+
+	for _, test := range tt {
+		w := httptest.NewRecorder()
+		setTotalCountHeaders(w, test.total, test.pageSize)
+		require.Equal(t, test.expectedPages, w.Header().Get(totalPagesHeader))
+	}
+
+	w := httptest.NewRecorder()
+	setTotalCountHeaders(w, 101, 100)
+	require.Equal(t, "101", w.Header().Get(totalCountHeader))
+}
+
+func TestTotalCounter_Count(t *testing.T) {
+	ctx := context.Background()
+	c := &totalCounter{cache: datastore.NewCentralCountCache(testutil.RedisCache(t, 0), time.Minute)}
+
+	var calls int
+	countFn := func() (int, error) {
+		calls++
+		return 3, nil
+	}
+
+	filters := datastore.FilterParams{NamePrefix: "sbom-", MaxEntries: 2}
+	count, err := c.count(ctx, "tags:1:2", filters, countFn)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, 1, calls)
+
+	// pagination parameters do not affect the cache key
+	filters.LastEntry = "sbom-a"
+	filters.MaxEntries = 1
+	count, err = c.count(ctx, "tags:1:2", filters, countFn)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, 1, calls)
+
+	// filters and lists do
+	filters.NamePrefix = "sbom-b"
+	_, err = c.count(ctx, "tags:1:2", filters, countFn)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	_, err = c.count(ctx, "tags:1:3", filters, countFn)
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	// errors are not cached
+	_, err = c.count(ctx, "tags:1:4", filters, func() (int, error) { return 0, errors.New("foo") })
+	require.EqualError(t, err, "foo")
+	count, err = c.count(ctx, "tags:1:4", filters, countFn)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, 4, calls)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const repositorySpanAttribute = "registry.repository"
+
+// traceRequest names the span of the request after the matched route, and records the target repository, if any.
+func traceRequest(ctx context.Context, r *http.Request) {
+	span := trace.SpanFromContext(ctx)
+	if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+		span.SetName(route.GetName())
+		span.SetAttributes(semconv.HTTPRouteKey.String(route.GetName()))
+	}
+	if name := dcontext.GetStringValue(ctx, "vars.name"); name != "" {
+		span.SetAttributes(attribute.String(repositorySpanAttribute, name))
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dcontext "github.com/docker/distribution/context"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceRequest(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test")
+
+	router := v2.Router()
+	router.GetRoute(v2.RouteNameManifest).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "HTTP GET")
+		defer span.End()
+
+		traceRequest(dcontext.WithVars(ctx, r), r)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/v2/foo/bar/manifests/latest", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, v2.RouteNameManifest, spans[0].Name())
+	require.Contains(t, spans[0].Attributes(), attribute.String("http.route", v2.RouteNameManifest))
+	require.Contains(t, spans[0].Attributes(), attribute.String(repositorySpanAttribute, "foo/bar"))
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/internal/metrics/traffic"
+	promclient "github.com/prometheus/client_golang/prometheus"
+)
+
+// newRepositoryTraffic creates a tracker for the pulls and pushes of the most active repositories and registers it with
+// the default Prometheus registry. If a tracker was already registered, such as by another App within the same
+// process, that one is reused.
+func newRepositoryTraffic(config configuration.PrometheusRepositories) (*traffic.Tracker, error) {
+	if config.Limit < 1 {
+		return nil, fmt.Errorf("limit must be greater than zero, got %d", config.Limit)
+	}
+	if config.HalfLife <= 0 {
+		return nil, fmt.Errorf("halflife must be greater than zero, got %s", config.HalfLife)
+	}
+
+	t := traffic.NewTracker(config.Limit, config.HalfLife)
+	if err := promclient.Register(t); err != nil {
+		var are promclient.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return nil, err
+		}
+		existing, ok := are.ExistingCollector.(*traffic.Tracker)
+		if !ok {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	return t, nil
+}
+
+// recordPull records a manifest pull from the current repository in the repository metrics, if enabled.
+func (ctx *Context) recordPull() {
+	if ctx.repositoryTraffic == nil || ctx.Repository == nil {
+		return
+	}
+	ctx.repositoryTraffic.Pull(ctx.Repository.Named().Name())
+}
+
+// recordPush records a manifest push to the current repository in the repository metrics, if enabled.
+func (ctx *Context) recordPush() {
+	if ctx.repositoryTraffic == nil || ctx.Repository == nil {
+		return
+	}
+	ctx.repositoryTraffic.Push(ctx.Repository.Named().Name())
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRepositoryTraffic(t *testing.T) {
+	config := configuration.PrometheusRepositories{Enabled: true, Limit: 10, HalfLife: time.Hour}
+
+	tr, err := newRepositoryTraffic(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { promclient.Unregister(tr) })
+
+	// the tracker registered by another App is reused
+	tr2, err := newRepositoryTraffic(config)
+	require.NoError(t, err)
+	require.Same(t, tr, tr2)
+}
+
+func TestNewRepositoryTraffic_Invalid(t *testing.T) {
+	_, err := newRepositoryTraffic(configuration.PrometheusRepositories{Enabled: true, HalfLife: time.Hour})
+	require.EqualError(t, err, "limit must be greater than zero, got 0")
+
+	_, err = newRepositoryTraffic(configuration.PrometheusRepositories{Enabled: true, Limit: 1, HalfLife: -time.Hour})
+	require.EqualError(t, err, "halflife must be greater than zero, got -1h0m0s")
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// uploadPurger implements uploads.Purger, purging the files of abandoned uploads from the storage backend.
+type uploadPurger struct {
+	driver storagedriver.StorageDriver
+}
+
+// Purge implements uploads.Purger.
+func (p *uploadPurger) Purge(ctx context.Context, repoPath, uuid string) error {
+	return storage.PurgeUpload(ctx, p.driver, repoPath, uuid)
+}
+
+// trackUploads reports whether blob uploads should be tracked in the database, so that abandoned ones can be purged.
+func (ctx *Context) trackUploads() bool {
+	return ctx.useDatabase && ctx.App.Config.Database.UploadPurging.Enabled
+}
+
+// trackUpload records the start of a blob upload in the database, if tracking is enabled. Failures are only logged, as
+// untracked uploads are still purged by the storage upload purging routine, if enabled.
+func (buh *blobUploadHandler) trackUpload() {
+	if !buh.trackUploads() {
+		return
+	}
+
+	u := &models.Upload{UUID: buh.Upload.ID(), RepositoryPath: buh.Repository.Named().Name()}
+	if err := datastore.NewUploadStore(buh.db).Create(buh, u); err != nil {
+		log.GetLogger(log.WithContext(buh)).WithError(err).Warn("failed to track upload in database")
+	}
+}
+
+// untrackUpload removes a completed or canceled blob upload from the database, if tracking is enabled. Failures are
+// only logged, as the upload purging worker eventually untracks it.
+func (buh *blobUploadHandler) untrackUpload() {
+	if !buh.trackUploads() {
+		return
+	}
+
+	if err := datastore.NewUploadStore(buh.db).Delete(buh, buh.Upload.ID()); err != nil {
+		log.GetLogger(log.WithContext(buh)).WithError(err).Warn("failed to untrack upload in database")
+	}
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	runDurationHist      *prometheus.HistogramVec
+	runCounter           *prometheus.CounterVec
+	verifiedCounter      prometheus.Counter
+	verifiedBytesCounter prometheus.Counter
+	corruptedCounter     *prometheus.CounterVec
+
+	timeSince = time.Since // for test purposes only
+)
+
+const (
+	subsystem = "blob_verification"
+
+	errorLabel  = "error"
+	reasonLabel = "reason"
+
+	runDurationName = "run_duration_seconds"
+	runDurationDesc = "A histogram of latencies for blob verification worker runs."
+	runTotalName    = "runs_total"
+	runTotalDesc    = "A counter for blob verification worker runs."
+
+	verifiedTotalName = "verified_total"
+	verifiedTotalDesc = "A counter for blobs whose content was verified."
+
+	verifiedBytesTotalName = "verified_bytes_total"
+	verifiedBytesTotalDesc = "A counter for the bytes of blob content read during verifications."
+
+	corruptedTotalName = "corrupted_total"
+	corruptedTotalDesc = "A counter for blobs whose content failed verification."
+)
+
+func init() {
+	runDurationHist = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      runDurationName,
+			Help:      runDurationDesc,
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{errorLabel},
+	)
+
+	runCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      runTotalName,
+			Help:      runTotalDesc,
+		},
+		[]string{errorLabel},
+	)
+
+	verifiedCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      verifiedTotalName,
+			Help:      verifiedTotalDesc,
+		},
+	)
+
+	verifiedBytesCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      verifiedBytesTotalName,
+			Help:      verifiedBytesTotalDesc,
+		},
+	)
+
+	corruptedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      corruptedTotalName,
+			Help:      corruptedTotalDesc,
+		},
+		[]string{reasonLabel},
+	)
+
+	prometheus.MustRegister(runDurationHist)
+	prometheus.MustRegister(runCounter)
+	prometheus.MustRegister(verifiedCounter)
+	prometheus.MustRegister(verifiedBytesCounter)
+	prometheus.MustRegister(corruptedCounter)
+}
+
+// WorkerRun starts measuring a blob verification worker run. The returned function must be called with the run error
+// (if any) once the run is complete.
+func WorkerRun() func(err error) {
+	start := time.Now()
+	return func(err error) {
+		failed := strconv.FormatBool(err != nil)
+		runCounter.WithLabelValues(failed).Inc()
+		runDurationHist.WithLabelValues(failed).Observe(timeSince(start).Seconds())
+	}
+}
+
+// Verified counts a blob whose content was verified, having read the given number of bytes.
+func Verified(bytes int64) {
+	verifiedCounter.Inc()
+	verifiedBytesCounter.Add(float64(bytes))
+}
+
+// Corrupted counts a blob whose content failed verification for the given reason.
+func Corrupted(reason string) {
+	corruptedCounter.WithLabelValues(reason).Inc()
+}
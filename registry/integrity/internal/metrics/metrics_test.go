@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func mockTimeSince(d time.Duration) func() {
+	bkp := timeSince
+	timeSince = func(_ time.Time) time.Duration { return d }
+	return func() { timeSince = bkp }
+}
+
+func TestWorkerRun(t *testing.T) {
+	restore := mockTimeSince(10 * time.Millisecond)
+	defer restore()
+
+	WorkerRun()(nil)
+	WorkerRun()(errors.New("foo"))
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_blob_verification_run_duration_seconds A histogram of latencies for blob verification worker runs.
+# TYPE registry_blob_verification_run_duration_seconds histogram
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="0.005"} 0
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="0.01"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="0.025"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="0.05"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="0.1"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="0.25"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="0.5"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="1"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="2.5"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="5"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="10"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="false",le="+Inf"} 1
+registry_blob_verification_run_duration_seconds_sum{error="false"} 0.01
+registry_blob_verification_run_duration_seconds_count{error="false"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="0.005"} 0
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="0.01"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="0.025"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="0.05"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="0.1"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="0.25"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="0.5"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="1"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="2.5"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="5"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="10"} 1
+registry_blob_verification_run_duration_seconds_bucket{error="true",le="+Inf"} 1
+registry_blob_verification_run_duration_seconds_sum{error="true"} 0.01
+registry_blob_verification_run_duration_seconds_count{error="true"} 1
+# HELP registry_blob_verification_runs_total A counter for blob verification worker runs.
+# TYPE registry_blob_verification_runs_total counter
+registry_blob_verification_runs_total{error="false"} 1
+registry_blob_verification_runs_total{error="true"} 1
+`)
+	durationFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, runDurationName)
+	totalFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, runTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, durationFullName, totalFullName)
+	require.NoError(t, err)
+}
+
+func TestVerifiedAndCorrupted(t *testing.T) {
+	Verified(100)
+	Verified(20)
+	Corrupted("digest_mismatch")
+	Corrupted("missing")
+	Corrupted("missing")
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_blob_verification_corrupted_total A counter for blobs whose content failed verification.
+# TYPE registry_blob_verification_corrupted_total counter
+registry_blob_verification_corrupted_total{reason="digest_mismatch"} 1
+registry_blob_verification_corrupted_total{reason="missing"} 2
+# HELP registry_blob_verification_verified_bytes_total A counter for the bytes of blob content read during verifications.
+# TYPE registry_blob_verification_verified_bytes_total counter
+registry_blob_verification_verified_bytes_total 120
+# HELP registry_blob_verification_verified_total A counter for blobs whose content was verified.
+# TYPE registry_blob_verification_verified_total counter
+registry_blob_verification_verified_total 2
+`)
+	verifiedFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, verifiedTotalName)
+	verifiedBytesFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, verifiedBytesTotalName)
+	corruptedFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, corruptedTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, verifiedFullName, verifiedBytesFullName, corruptedFullName)
+	require.NoError(t, err)
+}
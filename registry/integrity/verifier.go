@@ -0,0 +1,333 @@
+// Package integrity provides a background worker that verifies the integrity of the blobs in storage, detecting those
+// whose content is missing or no longer matches their digest.
+package integrity
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/integrity/internal/metrics"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+	"gitlab.com/gitlab-org/labkit/errortracking"
+)
+
+const (
+	componentKey = "component"
+	verifierName = "registry.integrity.Verifier"
+
+	defaultInterval   = time.Hour
+	defaultSampleSize = 10
+
+	// ReasonMissing identifies blobs whose content was not found in storage.
+	ReasonMissing = "missing"
+	// ReasonDigestMismatch identifies blobs whose content in storage does not match their digest.
+	ReasonDigestMismatch = "digest_mismatch"
+)
+
+// lowestDigest sorts before all other blob digests, and is used to wrap around once the highest digest is reached.
+var lowestDigest = digest.NewDigestFromEncoded(digest.SHA256, "0000000000000000000000000000000000000000000000000000000000000000")
+
+// BlobStore is the subset of datastore.BlobStore used to sample blobs.
+type BlobStore interface {
+	FindAllAfterDigest(ctx context.Context, d digest.Digest, limit int) (models.Blobs, error)
+}
+
+// CorruptedBlobStore is the subset of datastore.CorruptedBlobStore used to record verification failures.
+type CorruptedBlobStore interface {
+	Record(ctx context.Context, b *models.CorruptedBlob) error
+	Delete(ctx context.Context, d digest.Digest) (bool, error)
+}
+
+// ContentProvider provides access to the content of blobs in storage. It's satisfied by distribution.BlobProvider.
+type ContentProvider interface {
+	Open(ctx context.Context, dgst digest.Digest) (distribution.ReadSeekCloser, error)
+}
+
+// Result holds the outcome of a single Verifier run.
+type Result struct {
+	// Verified is the number of blobs whose content was read and checked.
+	Verified int
+	// Corrupted is the number of verified blobs that failed the check.
+	Corrupted int
+	// Resolved is the number of previously corrupted blobs that passed the check.
+	Resolved int
+	// Skipped is the number of sampled blobs that were not verified for being larger than the configured maximum size.
+	Skipped int
+}
+
+// Verifier periodically verifies the content of a random sample of blobs. Each blob is read from storage and hashed,
+// and its digest compared with the expected one. Blobs whose content is missing or doesn't match are recorded as
+// corrupted and reported as errors, while blobs that were previously recorded but now pass the check are cleared.
+//
+// Samples are made of consecutive blobs, sorted by digest, starting at a random position. As digests are uniformly
+// distributed, this is equivalent to a random sample, while being cheap to query.
+type Verifier struct {
+	blobs      BlobStore
+	corrupted  CorruptedBlobStore
+	content    ContentProvider
+	logger     log.Logger
+	interval   time.Duration
+	sampleSize int
+	maxSize    int64
+
+	mu        sync.Mutex
+	lastRunAt time.Time
+}
+
+// VerifierOption provides functional options for NewVerifier.
+type VerifierOption func(*Verifier)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) VerifierOption {
+	return func(v *Verifier) {
+		v.logger = l
+	}
+}
+
+// WithInterval sets the interval between runs. Defaults to 1 hour.
+func WithInterval(d time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.interval = d
+	}
+}
+
+// WithSampleSize sets the number of blobs sampled in each run. Defaults to 10.
+func WithSampleSize(n int) VerifierOption {
+	return func(v *Verifier) {
+		v.sampleSize = n
+	}
+}
+
+// WithMaxSize sets the size in bytes above which sampled blobs are skipped, to bound the amount of data read from
+// storage in each run. Defaults to 0 (no limit).
+func WithMaxSize(n int64) VerifierOption {
+	return func(v *Verifier) {
+		v.maxSize = n
+	}
+}
+
+func (v *Verifier) applyDefaults() {
+	if v.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		v.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if v.interval == 0 {
+		v.interval = defaultInterval
+	}
+	if v.sampleSize == 0 {
+		v.sampleSize = defaultSampleSize
+	}
+}
+
+// NewVerifier creates a new Verifier.
+func NewVerifier(blobs BlobStore, corrupted CorruptedBlobStore, content ContentProvider, opts ...VerifierOption) *Verifier {
+	v := &Verifier{
+		blobs:     blobs,
+		corrupted: corrupted,
+		content:   content,
+	}
+	v.applyDefaults()
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	v.logger = v.logger.WithFields(log.Fields{componentKey: verifierName})
+
+	return v
+}
+
+// sample returns up to sampleSize blobs, starting at a random digest and wrapping around if needed.
+func (v *Verifier) sample(ctx context.Context) (models.Blobs, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generating random digest: %w", err)
+	}
+	pivot := digest.FromBytes(b)
+
+	bb, err := v.blobs.FindAllAfterDigest(ctx, pivot, v.sampleSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(bb) == v.sampleSize {
+		return bb, nil
+	}
+
+	more, err := v.blobs.FindAllAfterDigest(ctx, lowestDigest, v.sampleSize-len(bb))
+	if err != nil {
+		return nil, err
+	}
+	// with fewer blobs than the sample size, the wrap around reaches the ones already sampled
+	seen := make(map[digest.Digest]struct{}, len(bb))
+	for _, b := range bb {
+		seen[b.Digest] = struct{}{}
+	}
+	for _, b := range more {
+		if _, ok := seen[b.Digest]; !ok {
+			bb = append(bb, b)
+		}
+	}
+
+	return bb, nil
+}
+
+// Run verifies a random sample of blobs. Verification stops at the first error other than a failed check.
+func (v *Verifier) Run(ctx context.Context) (*Result, error) {
+	bb, err := v.sample(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for _, b := range bb {
+		if v.maxSize > 0 && b.Size > v.maxSize {
+			res.Skipped++
+			continue
+		}
+		if err := v.verify(ctx, b, res); err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+func (v *Verifier) verify(ctx context.Context, b *models.Blob, res *Result) error {
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"digest": b.Digest, "size_bytes": b.Size})
+
+	cb := &models.CorruptedBlob{Digest: b.Digest, Size: b.Size}
+	n, actual, err := v.hash(ctx, b.Digest)
+	switch {
+	case errors.Is(err, distribution.ErrBlobUnknown):
+		cb.Reason = ReasonMissing
+	case err != nil:
+		return fmt.Errorf("verifying blob %q: %w", b.Digest, err)
+	case actual != b.Digest:
+		cb.Reason = ReasonDigestMismatch
+		cb.ActualDigest = actual
+	}
+	metrics.Verified(n)
+	res.Verified++
+
+	if cb.Reason == "" {
+		found, err := v.corrupted.Delete(ctx, b.Digest)
+		if err != nil {
+			return err
+		}
+		if found {
+			res.Resolved++
+			l.Info("previously corrupted blob passed verification")
+		}
+		return nil
+	}
+
+	if err := v.corrupted.Record(ctx, cb); err != nil {
+		return err
+	}
+	metrics.Corrupted(cb.Reason)
+	res.Corrupted++
+
+	l = l.WithFields(log.Fields{"reason": cb.Reason, "actual_digest": cb.ActualDigest, "detected_at": cb.DetectedAt})
+	err = fmt.Errorf("corrupted blob detected: %s", cb.Reason)
+	errortracking.Capture(
+		err,
+		errortracking.WithContext(ctx),
+		errortracking.WithField(componentKey, verifierName),
+		errortracking.WithField("digest", b.Digest.String()),
+	)
+	l.WithError(err).Error("corrupted blob detected")
+
+	return nil
+}
+
+// hash reads the content of the blob with digest dgst, returning the number of bytes read and the actual digest,
+// computed with the same algorithm.
+func (v *Verifier) hash(ctx context.Context, dgst digest.Digest) (int64, digest.Digest, error) {
+	if err := dgst.Validate(); err != nil {
+		return 0, "", err
+	}
+
+	rc, err := v.content.Open(ctx, dgst)
+	if err != nil {
+		return 0, "", err
+	}
+	defer rc.Close()
+
+	digester := dgst.Algorithm().Digester()
+	n, err := io.Copy(digester.Hash(), rc)
+	if err != nil {
+		return n, "", err
+	}
+
+	return n, digester.Digest(), nil
+}
+
+// LastRunAt returns the time at which the last run by this Verifier completed, or the zero time if there was none.
+func (v *Verifier) LastRunAt() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.lastRunAt
+}
+
+// Start starts the Verifier. This is a blocking call that runs the verification every configured interval until the
+// provided context is canceled.
+func (v *Verifier) Start(ctx context.Context) error {
+	v.logger.WithFields(log.Fields{
+		"interval_s":     v.interval.Seconds(),
+		"sample_size":    v.sampleSize,
+		"max_size_bytes": v.maxSize,
+	}).Info("starting blob verification")
+
+	t := time.NewTicker(v.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			v.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		id := correlation.SafeRandomID()
+		rCtx := correlation.ContextWithCorrelation(ctx, id)
+		l := v.logger.WithFields(log.Fields{correlation.FieldName: id})
+		rCtx = log.WithLogger(rCtx, l)
+
+		start := time.Now()
+		report := metrics.WorkerRun()
+		res, err := v.Run(rCtx)
+		report(err)
+
+		v.mu.Lock()
+		v.lastRunAt = time.Now()
+		v.mu.Unlock()
+
+		if res != nil {
+			l = l.WithFields(log.Fields{
+				"verified":  res.Verified,
+				"corrupted": res.Corrupted,
+				"resolved":  res.Resolved,
+				"skipped":   res.Skipped,
+			})
+		}
+		l = l.WithFields(log.Fields{"duration_s": time.Since(start).Seconds()})
+		if err != nil {
+			l.WithError(err).Error("blob verification run failed")
+			continue
+		}
+		l.Info("blob verification run complete")
+	}
+}
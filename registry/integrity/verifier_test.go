@@ -0,0 +1,181 @@
+package integrity_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/integrity"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBlobStore is an integrity.BlobStore that serves a fixed list of blobs, sorted by digest.
+type fakeBlobStore struct {
+	blobs models.Blobs
+	err   error
+}
+
+func (s *fakeBlobStore) FindAllAfterDigest(_ context.Context, d digest.Digest, limit int) (models.Blobs, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	bb := make(models.Blobs, 0, limit)
+	for _, b := range s.blobs {
+		if b.Digest > d && len(bb) < limit {
+			cp := *b
+			bb = append(bb, &cp)
+		}
+	}
+	return bb, nil
+}
+
+// fakeCorruptedBlobStore is an in-memory integrity.CorruptedBlobStore.
+type fakeCorruptedBlobStore struct {
+	blobs map[digest.Digest]*models.CorruptedBlob
+}
+
+func (s *fakeCorruptedBlobStore) Record(_ context.Context, b *models.CorruptedBlob) error {
+	s.blobs[b.Digest] = b
+	return nil
+}
+
+func (s *fakeCorruptedBlobStore) Delete(_ context.Context, d digest.Digest) (bool, error) {
+	_, ok := s.blobs[d]
+	delete(s.blobs, d)
+	return ok, nil
+}
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+// fakeContentProvider serves blob content from memory, failing with distribution.ErrBlobUnknown for unknown digests.
+type fakeContentProvider struct {
+	content map[digest.Digest][]byte
+	err     error
+	opened  []digest.Digest
+}
+
+func (p *fakeContentProvider) Open(_ context.Context, dgst digest.Digest) (distribution.ReadSeekCloser, error) {
+	p.opened = append(p.opened, dgst)
+	if p.err != nil {
+		return nil, p.err
+	}
+	c, ok := p.content[dgst]
+	if !ok {
+		return nil, distribution.ErrBlobUnknown
+	}
+	return nopCloser{bytes.NewReader(c)}, nil
+}
+
+func newTestVerifier(t *testing.T, opts ...integrity.VerifierOption) (*integrity.Verifier, *fakeBlobStore, *fakeCorruptedBlobStore, *fakeContentProvider) {
+	t.Helper()
+
+	good, corrupted, missing := []byte("good"), []byte("corrupted"), []byte("missing")
+	blobs := models.Blobs{
+		{Digest: digest.FromBytes(good), Size: int64(len(good))},
+		{Digest: digest.FromBytes(corrupted), Size: int64(len(corrupted))},
+		{Digest: digest.FromBytes(missing), Size: int64(len(missing))},
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Digest < blobs[j].Digest })
+
+	bs := &fakeBlobStore{blobs: blobs}
+	cbs := &fakeCorruptedBlobStore{blobs: make(map[digest.Digest]*models.CorruptedBlob)}
+	cp := &fakeContentProvider{content: map[digest.Digest][]byte{
+		digest.FromBytes(good):      good,
+		digest.FromBytes(corrupted): []byte("c0rrupted"),
+	}}
+
+	return integrity.NewVerifier(bs, cbs, cp, opts...), bs, cbs, cp
+}
+
+func TestVerifier_Run(t *testing.T) {
+	// the sample size covers all blobs, so that all of them are verified regardless of the random starting point
+	v, _, cbs, cp := newTestVerifier(t, integrity.WithSampleSize(5))
+
+	// a previously corrupted blob that now passes the check is cleared
+	goodDgst := digest.FromString("good")
+	cbs.blobs[goodDgst] = &models.CorruptedBlob{Digest: goodDgst, Reason: integrity.ReasonMissing}
+
+	res, err := v.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &integrity.Result{Verified: 3, Corrupted: 2, Resolved: 1}, res)
+	require.Len(t, cp.opened, 3)
+
+	require.Len(t, cbs.blobs, 2)
+	require.NotContains(t, cbs.blobs, goodDgst)
+
+	mismatch := cbs.blobs[digest.FromString("corrupted")]
+	require.NotNil(t, mismatch)
+	require.Equal(t, integrity.ReasonDigestMismatch, mismatch.Reason)
+	require.Equal(t, digest.FromString("c0rrupted"), mismatch.ActualDigest)
+	require.EqualValues(t, 9, mismatch.Size)
+
+	missing := cbs.blobs[digest.FromString("missing")]
+	require.NotNil(t, missing)
+	require.Equal(t, integrity.ReasonMissing, missing.Reason)
+	require.Empty(t, missing.ActualDigest)
+}
+
+func TestVerifier_Run_SampleSize(t *testing.T) {
+	v, _, _, cp := newTestVerifier(t, integrity.WithSampleSize(2))
+
+	res, err := v.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Verified)
+	require.Len(t, cp.opened, 2)
+	require.NotEqual(t, cp.opened[0], cp.opened[1])
+}
+
+func TestVerifier_Run_MaxSize(t *testing.T) {
+	v, _, cbs, cp := newTestVerifier(t, integrity.WithSampleSize(5), integrity.WithMaxSize(5))
+
+	res, err := v.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &integrity.Result{Verified: 1, Skipped: 2}, res)
+	require.Equal(t, []digest.Digest{digest.FromString("good")}, cp.opened)
+	require.Empty(t, cbs.blobs)
+}
+
+func TestVerifier_Run_StoreError(t *testing.T) {
+	v, bs, _, _ := newTestVerifier(t)
+	bs.err = errors.New("foo")
+
+	res, err := v.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.Nil(t, res)
+}
+
+func TestVerifier_Run_ContentError(t *testing.T) {
+	v, _, cbs, cp := newTestVerifier(t, integrity.WithSampleSize(5))
+	cp.err = errors.New("foo")
+
+	// errors other than missing content are not a sign of corruption and abort the run
+	res, err := v.Run(context.Background())
+	require.ErrorIs(t, err, cp.err)
+	require.Equal(t, &integrity.Result{}, res)
+	require.Len(t, cp.opened, 1)
+	require.Empty(t, cbs.blobs)
+}
+
+func TestVerifier_Start(t *testing.T) {
+	v, _, cbs, _ := newTestVerifier(t, integrity.WithSampleSize(5), integrity.WithInterval(10*time.Millisecond))
+	require.True(t, v.LastRunAt().IsZero())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- v.Start(ctx) }()
+
+	require.Eventually(t, func() bool { return !v.LastRunAt().IsZero() }, time.Second, 10*time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Len(t, cbs.blobs, 2)
+}
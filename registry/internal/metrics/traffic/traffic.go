@@ -0,0 +1,180 @@
+// Package traffic provides a Prometheus collector for the pull and push counts of the most active repositories. Tracking
+// all repositories would lead to unbounded label cardinality, so only a fixed number of them is tracked at a time. The
+// activity of each tracked repository decays exponentially over time, so that repositories that are no longer active
+// are eventually replaced by others that became active since.
+package traffic
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/docker/distribution/metrics"
+	"github.com/docker/distribution/registry/internal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subsystem       = "repository"
+	repositoryLabel = "repository"
+
+	pullsName     = "pulls_total"
+	pullsDesc     = "A counter of manifest pulls of the most active repositories, since they became one of them."
+	pushesName    = "pushes_total"
+	pushesDesc    = "A counter of manifest pushes of the most active repositories, since they became one of them."
+	activityName  = "activity_score"
+	activityDesc  = "The exponentially decayed number of manifest pulls and pushes of the most active repositories."
+	evictionsName = "activity_evictions_total"
+	evictionsDesc = "A counter of repositories that stopped being tracked as one of the most active."
+)
+
+// for test purposes (mocking)
+var systemClock internal.Clock = clock.New()
+
+type entry struct {
+	pulls, pushes float64
+	// score is the decayed activity at updatedAt
+	score     float64
+	updatedAt time.Time
+}
+
+// Tracker tracks the pull and push counts of the most active repositories, and exposes them as Prometheus metrics. It
+// implements the Space-Saving algorithm: once the maximum number of repositories is tracked, activity of a new one
+// replaces the tracked repository with the lowest activity, inheriting its score, so that repositories with sustained
+// activity are not evicted by a burst of new ones. Counts of a repository start from zero once it's tracked. Tracker
+// may be used safely from multiple goroutines.
+type Tracker struct {
+	mu        sync.Mutex
+	size      int
+	halfLife  time.Duration
+	entries   map[string]*entry
+	evictions float64
+
+	pullsDesc     *prometheus.Desc
+	pushesDesc    *prometheus.Desc
+	activityDesc  *prometheus.Desc
+	evictionsDesc *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*Tracker)(nil)
+
+// NewTracker creates a new Tracker for up to size repositories, whose activity halves after each halfLife period
+// without pulls or pushes.
+func NewTracker(size int, halfLife time.Duration) *Tracker {
+	return &Tracker{
+		size:     size,
+		halfLife: halfLife,
+		entries:  make(map[string]*entry, size),
+		pullsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metrics.NamespacePrefix, subsystem, pullsName),
+			pullsDesc,
+			[]string{repositoryLabel},
+			nil,
+		),
+		pushesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metrics.NamespacePrefix, subsystem, pushesName),
+			pushesDesc,
+			[]string{repositoryLabel},
+			nil,
+		),
+		activityDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metrics.NamespacePrefix, subsystem, activityName),
+			activityDesc,
+			[]string{repositoryLabel},
+			nil,
+		),
+		evictionsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metrics.NamespacePrefix, subsystem, evictionsName),
+			evictionsDesc,
+			nil,
+			nil,
+		),
+	}
+}
+
+// Pull records a manifest pull from the repository with the given path.
+func (t *Tracker) Pull(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.hit(path).pulls++
+}
+
+// Push records a manifest push to the repository with the given path.
+func (t *Tracker) Push(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.hit(path).pushes++
+}
+
+// decayed returns the score of e decayed until now.
+func (t *Tracker) decayed(e *entry, now time.Time) float64 {
+	elapsed := now.Sub(e.updatedAt)
+	if elapsed <= 0 {
+		return e.score
+	}
+	return e.score * math.Exp2(-float64(elapsed)/float64(t.halfLife))
+}
+
+// hit increments the activity of the repository with the given path, tracking it if needed. Must be called with the
+// lock held.
+func (t *Tracker) hit(path string) *entry {
+	now := systemClock.Now()
+
+	e, ok := t.entries[path]
+	if !ok {
+		e = &entry{}
+		if len(t.entries) >= t.size {
+			e.score = t.evict(now)
+		}
+		t.entries[path] = e
+	} else {
+		e.score = t.decayed(e, now)
+	}
+	e.score++
+	e.updatedAt = now
+
+	return e
+}
+
+// evict stops tracking the repository with the lowest activity, returning its decayed score. Must be called with the
+// lock held.
+func (t *Tracker) evict(now time.Time) float64 {
+	var (
+		minPath  string
+		minScore = math.Inf(1)
+	)
+	for p, e := range t.entries {
+		if s := t.decayed(e, now); s < minScore {
+			minPath, minScore = p, s
+		}
+	}
+	delete(t.entries, minPath)
+	t.evictions++
+
+	return minScore
+}
+
+// Describe implements prometheus.Collector.
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.pullsDesc
+	ch <- t.pushesDesc
+	ch <- t.activityDesc
+	ch <- t.evictionsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := systemClock.Now()
+	for p, e := range t.entries {
+		ch <- prometheus.MustNewConstMetric(t.pullsDesc, prometheus.CounterValue, e.pulls, p)
+		ch <- prometheus.MustNewConstMetric(t.pushesDesc, prometheus.CounterValue, e.pushes, p)
+		ch <- prometheus.MustNewConstMetric(t.activityDesc, prometheus.GaugeValue, t.decayed(e, now), p)
+	}
+	ch <- prometheus.MustNewConstMetric(t.evictionsDesc, prometheus.CounterValue, t.evictions)
+}
@@ -0,0 +1,118 @@
+package traffic
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func mockClock(t *testing.T) *clock.Mock {
+	t.Helper()
+
+	c := clock.NewMock()
+	c.Set(time.Date(2023, 10, 26, 9, 0, 0, 0, time.UTC))
+	systemClock = c
+	t.Cleanup(func() { systemClock = clock.New() })
+
+	return c
+}
+
+func TestTracker_Collect(t *testing.T) {
+	mockClock(t)
+	tr := NewTracker(10, time.Hour)
+
+	tr.Pull("a/b")
+	tr.Pull("a/b")
+	tr.Push("a/b")
+	tr.Push("c")
+
+	expected := `
+# HELP registry_repository_activity_evictions_total A counter of repositories that stopped being tracked as one of the most active.
+# TYPE registry_repository_activity_evictions_total counter
+registry_repository_activity_evictions_total 0
+# HELP registry_repository_activity_score The exponentially decayed number of manifest pulls and pushes of the most active repositories.
+# TYPE registry_repository_activity_score gauge
+registry_repository_activity_score{repository="a/b"} 3
+registry_repository_activity_score{repository="c"} 1
+# HELP registry_repository_pulls_total A counter of manifest pulls of the most active repositories, since they became one of them.
+# TYPE registry_repository_pulls_total counter
+registry_repository_pulls_total{repository="a/b"} 2
+registry_repository_pulls_total{repository="c"} 0
+# HELP registry_repository_pushes_total A counter of manifest pushes of the most active repositories, since they became one of them.
+# TYPE registry_repository_pushes_total counter
+registry_repository_pushes_total{repository="a/b"} 1
+registry_repository_pushes_total{repository="c"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(tr, strings.NewReader(expected)))
+}
+
+func TestTracker_Decay(t *testing.T) {
+	c := mockClock(t)
+	tr := NewTracker(10, time.Hour)
+
+	for i := 0; i < 4; i++ {
+		tr.Pull("a")
+	}
+	c.Add(time.Hour)
+	require.InDelta(t, 2, tr.decayed(tr.entries["a"], c.Now()), 1e-9)
+
+	tr.Pull("a")
+	c.Add(2 * time.Hour)
+	require.InDelta(t, 0.75, tr.decayed(tr.entries["a"], c.Now()), 1e-9)
+	// counts don't decay
+	require.Equal(t, float64(5), tr.entries["a"].pulls)
+}
+
+func TestTracker_EvictsLeastActive(t *testing.T) {
+	c := mockClock(t)
+	tr := NewTracker(2, time.Hour)
+
+	tr.Pull("a")
+	tr.Pull("a")
+	tr.Pull("a")
+	tr.Pull("b")
+	tr.Pull("b")
+
+	// b is the least active, so c replaces it and inherits its score
+	tr.Pull("c")
+	require.Len(t, tr.entries, 2)
+	require.Contains(t, tr.entries, "a")
+	require.Contains(t, tr.entries, "c")
+	require.Equal(t, float64(3), tr.entries["c"].score)
+	require.Equal(t, float64(1), tr.entries["c"].pulls)
+	require.Equal(t, float64(1), tr.evictions)
+
+	// once a is no longer active, its activity decays below that of c, which therefore stays tracked
+	c.Add(2 * time.Hour)
+	tr.Push("c")
+	tr.Push("d")
+	require.Contains(t, tr.entries, "c")
+	require.Contains(t, tr.entries, "d")
+	require.NotContains(t, tr.entries, "a")
+	require.Equal(t, float64(2), tr.evictions)
+}
+
+func TestTracker_Concurrency(t *testing.T) {
+	tr := NewTracker(5, time.Hour)
+
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < 100; j++ {
+				tr.Pull(strings.Repeat("a", i%7+1))
+				tr.Push(strings.Repeat("b", j%3+1))
+			}
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+
+	require.LessOrEqual(t, len(tr.entries), 5)
+	require.Equal(t, 5, testutil.CollectAndCount(tr, "registry_repository_pulls_total"))
+}
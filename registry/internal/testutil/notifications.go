@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -188,6 +189,11 @@ func (ns *NotificationServer) validateManifestDelete(t *testing.T, expectedEvent
 		return fmt.Errorf("expected tag to be empty but but got: %q", receivedEvent.Target.Tag)
 	}
 
+	// bulk tag deletes send a single event with the list of deleted tags
+	if !reflect.DeepEqual(expectedEvent.Target.Tags, receivedEvent.Target.Tags) {
+		return fmt.Errorf("expected tags: %q but got: %q", expectedEvent.Target.Tags, receivedEvent.Target.Tags)
+	}
+
 	if expectedEvent.Actor != receivedEvent.Actor {
 		return fmt.Errorf("expected actor: %q but got: %q", expectedEvent.Actor, receivedEvent.Actor)
 	}
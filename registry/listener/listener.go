@@ -27,13 +27,15 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 }
 
 // NewListener announces on laddr and net. Accepted values of the net are
-// 'unix' and 'tcp'
+// 'unix', 'tcp', 'tcp4' (IPv4 only) and 'tcp6' (IPv6 only)
 func NewListener(net, laddr string) (net.Listener, error) {
 	switch net {
 	case "unix":
 		return newUnixListener(laddr)
-	case "tcp", "": // an empty net means tcp
-		return newTCPListener(laddr)
+	case "tcp", "tcp4", "tcp6":
+		return newTCPListener(net, laddr)
+	case "": // an empty net means tcp
+		return newTCPListener("tcp", laddr)
 	default:
 		return nil, fmt.Errorf("unknown address type %s", net)
 	}
@@ -64,8 +66,8 @@ func isSocket(m os.FileMode) bool {
 	return m&os.ModeSocket != 0
 }
 
-func newTCPListener(laddr string) (net.Listener, error) {
-	ln, err := net.Listen("tcp", laddr)
+func newTCPListener(network, laddr string) (net.Listener, error) {
+	ln, err := net.Listen(network, laddr)
 	if err != nil {
 		return nil, err
 	}
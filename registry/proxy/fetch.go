@@ -0,0 +1,216 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Cache is the local storage of a repository, to which the content fetched from the remote registry is written.
+type Cache interface {
+	// ManifestExists reports whether the manifest with the given digest is cached.
+	ManifestExists(ctx context.Context, dgst digest.Digest) (bool, error)
+	// BlobExists reports whether the blob with the given digest is cached.
+	BlobExists(ctx context.Context, dgst digest.Digest) (bool, error)
+	// PutBlob caches the blob described by desc, reading its content from r. The content must be verified against
+	// desc.
+	PutBlob(ctx context.Context, desc distribution.Descriptor, r io.Reader) error
+	// PutManifest caches manifest m, with digest dgst. Everything it references is cached before.
+	PutManifest(ctx context.Context, m distribution.Manifest, dgst digest.Digest) error
+	// Tag caches the tag with the given name, pointing to the cached manifest m with digest dgst.
+	Tag(ctx context.Context, m distribution.Manifest, name string, dgst digest.Digest) error
+}
+
+// FetchTag fetches the manifest tagged with the given name in the repository with the same name on the remote
+// registry, along with everything it references, and caches them, tag included. The manifest and its digest are
+// returned. Returns ErrNotFound if the tag is not found in the remote registry.
+func (r *Remote) FetchTag(ctx context.Context, name reference.Named, tag string, cache Cache) (distribution.Manifest, digest.Digest, error) {
+	f, err := r.fetcher(ctx, name, cache)
+	if err != nil {
+		return nil, "", err
+	}
+
+	m, dgst, err := f.getManifest(ctx, "", distribution.WithTag(tag))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := f.fetchManifest(ctx, m, dgst); err != nil {
+		return nil, "", err
+	}
+	if err := cache.Tag(ctx, m, tag, dgst); err != nil {
+		return nil, "", fmt.Errorf("caching tag: %w", err)
+	}
+
+	return m, dgst, nil
+}
+
+// FetchManifest fetches the manifest with digest dgst in the repository with the same name on the remote registry,
+// along with everything it references, and caches them. Returns ErrNotFound if the manifest is not found in the
+// remote registry.
+func (r *Remote) FetchManifest(ctx context.Context, name reference.Named, dgst digest.Digest, cache Cache) (distribution.Manifest, error) {
+	f, err := r.fetcher(ctx, name, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	m, _, err := f.getManifest(ctx, dgst)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.fetchManifest(ctx, m, dgst); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// FetchBlob fetches the blob with digest dgst in the repository with the same name on the remote registry and caches
+// it. Returns ErrNotFound if the blob is not found in the remote registry.
+func (r *Remote) FetchBlob(ctx context.Context, name reference.Named, dgst digest.Digest, cache Cache) error {
+	f, err := r.fetcher(ctx, name, cache)
+	if err != nil {
+		return err
+	}
+
+	desc, err := f.blobs.Stat(ctx, dgst)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("blob %s: %w", dgst, ErrNotFound)
+		}
+		return fmt.Errorf("checking remote blob %s: %w", dgst, err)
+	}
+
+	return f.fetchBlob(ctx, desc)
+}
+
+// fetcher copies content from a repository of the remote registry to the cache.
+type fetcher struct {
+	manifests distribution.ManifestService
+	blobs     distribution.BlobStore
+	cache     Cache
+}
+
+func (r *Remote) fetcher(ctx context.Context, name reference.Named, cache Cache) (*fetcher, error) {
+	repo, err := r.Repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fetcher{manifests: ms, blobs: repo.Blobs(ctx), cache: cache}, nil
+}
+
+// getManifest gets a manifest from the remote registry, by digest dgst or by tag if a tag option is given, and
+// returns it along with its digest, which is verified against dgst if not empty.
+func (f *fetcher) getManifest(ctx context.Context, dgst digest.Digest, opts ...distribution.ManifestServiceOption) (distribution.Manifest, digest.Digest, error) {
+	m, err := f.manifests.Get(ctx, dgst, opts...)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, "", fmt.Errorf("manifest: %w", ErrNotFound)
+		}
+		return nil, "", fmt.Errorf("getting remote manifest: %w", err)
+	}
+
+	_, payload, err := m.Payload()
+	if err != nil {
+		return nil, "", err
+	}
+	actual := digest.FromBytes(payload)
+	if dgst != "" && actual != dgst {
+		return nil, "", fmt.Errorf("remote manifest digest %s does not match requested digest %s", actual, dgst)
+	}
+
+	return m, actual, nil
+}
+
+// fetchManifest caches manifest m, with digest dgst, after caching the manifests and blobs it references that are not
+// cached yet. Descriptors of external blobs, which are not pushed to registries, are skipped.
+func (f *fetcher) fetchManifest(ctx context.Context, m distribution.Manifest, dgst digest.Digest) error {
+	var refs []distribution.Descriptor
+	if mv2, ok := m.(distribution.ManifestV2); ok {
+		// the subject of OCI manifests may not exist, so it's not fetched
+		refs = append([]distribution.Descriptor{mv2.Config()}, mv2.Layers()...)
+	} else {
+		refs = m.References()
+	}
+	_, isList := m.(*manifestlist.DeserializedManifestList)
+
+	for _, desc := range refs {
+		if len(desc.URLs) > 0 {
+			continue
+		}
+		// manifest lists reference manifests, but non-conformant ones may reference blobs as well
+		if isList && isManifestMediaType(desc.MediaType) {
+			exists, err := f.cache.ManifestExists(ctx, desc.Digest)
+			if err != nil {
+				return fmt.Errorf("checking cached manifest %s: %w", desc.Digest, err)
+			}
+			if exists {
+				continue
+			}
+			child, _, err := f.getManifest(ctx, desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := f.fetchManifest(ctx, child, desc.Digest); err != nil {
+				return err
+			}
+			continue
+		}
+
+		exists, err := f.cache.BlobExists(ctx, desc.Digest)
+		if err != nil {
+			return fmt.Errorf("checking cached blob %s: %w", desc.Digest, err)
+		}
+		if exists {
+			continue
+		}
+		if err := f.fetchBlob(ctx, desc); err != nil {
+			return err
+		}
+	}
+
+	if err := f.cache.PutManifest(ctx, m, dgst); err != nil {
+		return fmt.Errorf("caching manifest %s: %w", dgst, err)
+	}
+
+	return nil
+}
+
+// fetchBlob caches the blob described by desc.
+func (f *fetcher) fetchBlob(ctx context.Context, desc distribution.Descriptor) error {
+	rc, err := f.blobs.Open(ctx, desc.Digest)
+	if err != nil {
+		return fmt.Errorf("opening remote blob %s: %w", desc.Digest, err)
+	}
+	defer rc.Close()
+
+	if err := f.cache.PutBlob(ctx, desc, rc); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("blob %s: %w", desc.Digest, ErrNotFound)
+		}
+		return fmt.Errorf("caching blob %s: %w", desc.Digest, err)
+	}
+
+	return nil
+}
+
+// isManifestMediaType reports whether mediaType is the media type of an image manifest or manifest list.
+func isManifestMediaType(mediaType string) bool {
+	switch mediaType {
+	case schema2.MediaTypeManifest, v1.MediaTypeImageManifest, manifestlist.MediaTypeManifestList, v1.MediaTypeImageIndex:
+		return true
+	default:
+		return false
+	}
+}
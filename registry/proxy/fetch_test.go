@@ -0,0 +1,371 @@
+package proxy_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/proxy"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	remoteRepo     = "foo/bar"
+	remoteUsername = "user"
+	remotePassword = "pass"
+)
+
+type remoteManifest struct {
+	mediaType string
+	payload   []byte
+}
+
+// fakeRemote is a remote registry serving a fixed set of manifests and blobs of a single repository, which requires
+// basic authentication.
+type fakeRemote struct {
+	mu        sync.Mutex
+	manifests map[string]remoteManifest
+	blobs     map[digest.Digest][]byte
+	requests  []string
+}
+
+func newFakeRemote() *fakeRemote {
+	return &fakeRemote{
+		manifests: make(map[string]remoteManifest),
+		blobs:     make(map[digest.Digest][]byte),
+	}
+}
+
+func (r *fakeRemote) addBlob(mediaType string, content []byte) distribution.Descriptor {
+	dgst := digest.FromBytes(content)
+	r.blobs[dgst] = content
+	return distribution.Descriptor{MediaType: mediaType, Size: int64(len(content)), Digest: dgst}
+}
+
+func (r *fakeRemote) addManifest(tb testing.TB, m distribution.Manifest, tag string) distribution.Descriptor {
+	tb.Helper()
+
+	mt, payload, err := m.Payload()
+	require.NoError(tb, err)
+	dgst := digest.FromBytes(payload)
+
+	r.manifests[dgst.String()] = remoteManifest{mediaType: mt, payload: payload}
+	if tag != "" {
+		r.manifests[tag] = remoteManifest{mediaType: mt, payload: payload}
+	}
+	return distribution.Descriptor{MediaType: mt, Size: int64(len(payload)), Digest: dgst}
+}
+
+func (r *fakeRemote) requested() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.requests...)
+}
+
+func (r *fakeRemote) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if u, p, ok := req.BasicAuth(); !ok || u != remoteUsername || p != remotePassword {
+		w.Header().Set("WWW-Authenticate", `Basic realm="remote"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if req.URL.Path == "/v2/" {
+		return
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+	r.mu.Unlock()
+
+	prefix := "/v2/" + remoteRepo + "/"
+	switch {
+	case strings.HasPrefix(req.URL.Path, prefix+"manifests/"):
+		m, ok := r.manifests[strings.TrimPrefix(req.URL.Path, prefix+"manifests/")]
+		if !ok {
+			writeRemoteError(w, v2.ErrorCodeManifestUnknown)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(m.payload).String())
+		_, _ = w.Write(m.payload)
+	case strings.HasPrefix(req.URL.Path, prefix+"blobs/"):
+		b, ok := r.blobs[digest.Digest(strings.TrimPrefix(req.URL.Path, prefix+"blobs/"))]
+		if !ok {
+			writeRemoteError(w, v2.ErrorCodeBlobUnknown)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(b)
+		}
+	default:
+		writeRemoteError(w, v2.ErrorCodeNameUnknown)
+	}
+}
+
+func writeRemoteError(w http.ResponseWriter, code errcode.ErrorCode) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code.Descriptor().HTTPStatusCode)
+	_ = json.NewEncoder(w).Encode(errcode.Errors{code})
+}
+
+// fakeCache is a proxy.Cache that keeps the cached content in memory.
+type fakeCache struct {
+	manifests map[digest.Digest]distribution.Manifest
+	blobs     map[digest.Digest][]byte
+	tags      map[string]digest.Digest
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{
+		manifests: make(map[digest.Digest]distribution.Manifest),
+		blobs:     make(map[digest.Digest][]byte),
+		tags:      make(map[string]digest.Digest),
+	}
+}
+
+func (c *fakeCache) ManifestExists(_ context.Context, dgst digest.Digest) (bool, error) {
+	_, ok := c.manifests[dgst]
+	return ok, nil
+}
+
+func (c *fakeCache) BlobExists(_ context.Context, dgst digest.Digest) (bool, error) {
+	_, ok := c.blobs[dgst]
+	return ok, nil
+}
+
+func (c *fakeCache) PutBlob(_ context.Context, desc distribution.Descriptor, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if digest.FromBytes(b) != desc.Digest {
+		return fmt.Errorf("blob digest mismatch")
+	}
+	c.blobs[desc.Digest] = b
+	return nil
+}
+
+func (c *fakeCache) PutManifest(_ context.Context, m distribution.Manifest, dgst digest.Digest) error {
+	for _, desc := range m.References() {
+		_, isBlob := c.blobs[desc.Digest]
+		_, isManifest := c.manifests[desc.Digest]
+		if !isBlob && !isManifest {
+			return fmt.Errorf("reference %s not cached", desc.Digest)
+		}
+	}
+	c.manifests[dgst] = m
+	return nil
+}
+
+func (c *fakeCache) Tag(_ context.Context, _ distribution.Manifest, name string, dgst digest.Digest) error {
+	c.tags[name] = dgst
+	return nil
+}
+
+func newTestRemote(tb testing.TB, h http.Handler) *proxy.Remote {
+	tb.Helper()
+
+	s := httptest.NewServer(h)
+	tb.Cleanup(s.Close)
+
+	r, err := proxy.NewRemote(configuration.Proxy{RemoteURL: s.URL + "/", Username: remoteUsername, Password: remotePassword})
+	require.NoError(tb, err)
+	return r
+}
+
+func repoName(tb testing.TB) reference.Named {
+	tb.Helper()
+
+	n, err := reference.WithName(remoteRepo)
+	require.NoError(tb, err)
+	return n
+}
+
+// addImage adds an image with a config and a layer to remote, tagged with the given name if not empty.
+func addImage(tb testing.TB, remote *fakeRemote, tag, seed string) (distribution.Descriptor, []distribution.Descriptor) {
+	tb.Helper()
+
+	config := remote.addBlob(schema2.MediaTypeImageConfig, []byte(`{"seed":"`+seed+`"}`))
+	layer := remote.addBlob(schema2.MediaTypeLayer, []byte("layer "+seed))
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+		Layers:    []distribution.Descriptor{layer},
+	})
+	require.NoError(tb, err)
+
+	return remote.addManifest(tb, m, tag), []distribution.Descriptor{config, layer}
+}
+
+func TestNewRemote_InvalidURL(t *testing.T) {
+	for _, u := range []string{"", "foo", "ftp://example.com", "http://"} {
+		_, err := proxy.NewRemote(configuration.Proxy{RemoteURL: u})
+		require.Error(t, err, u)
+	}
+}
+
+func TestRemote_FetchTag(t *testing.T) {
+	remote := newFakeRemote()
+	desc, blobs := addImage(t, remote, "latest", "a")
+	r := newTestRemote(t, remote)
+	require.False(t, strings.HasSuffix(r.URL(), "/"))
+
+	cache := newFakeCache()
+	m, dgst, err := r.FetchTag(context.Background(), repoName(t), "latest", cache)
+	require.NoError(t, err)
+	require.Equal(t, desc.Digest, dgst)
+	require.Equal(t, schema2.MediaTypeManifest, mustMediaType(t, m))
+
+	require.Contains(t, cache.manifests, desc.Digest)
+	for _, b := range blobs {
+		require.Equal(t, remote.blobs[b.Digest], cache.blobs[b.Digest])
+	}
+	require.Equal(t, desc.Digest, cache.tags["latest"])
+}
+
+func TestRemote_FetchTag_SkipsCachedBlobs(t *testing.T) {
+	remote := newFakeRemote()
+	_, blobs := addImage(t, remote, "latest", "a")
+	r := newTestRemote(t, remote)
+
+	cache := newFakeCache()
+	cache.blobs[blobs[1].Digest] = remote.blobs[blobs[1].Digest]
+
+	_, _, err := r.FetchTag(context.Background(), repoName(t), "latest", cache)
+	require.NoError(t, err)
+
+	// only the config is fetched, the layer is already cached
+	require.NotContains(t, remote.requested(), "GET /v2/"+remoteRepo+"/blobs/"+blobs[1].Digest.String())
+	require.Contains(t, remote.requested(), "GET /v2/"+remoteRepo+"/blobs/"+blobs[0].Digest.String())
+}
+
+func TestRemote_FetchTag_ManifestList(t *testing.T) {
+	remote := newFakeRemote()
+	amd64, amd64Blobs := addImage(t, remote, "", "amd64")
+	arm64, _ := addImage(t, remote, "", "arm64")
+	ml, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+		{Descriptor: amd64, Platform: manifestlist.PlatformSpec{Architecture: "amd64", OS: "linux"}},
+		{Descriptor: arm64, Platform: manifestlist.PlatformSpec{Architecture: "arm64", OS: "linux"}},
+	})
+	require.NoError(t, err)
+	desc := remote.addManifest(t, ml, "latest")
+	r := newTestRemote(t, remote)
+
+	cache := newFakeCache()
+	// already cached child manifests are not fetched again
+	cache.manifests[arm64.Digest] = ml
+
+	_, dgst, err := r.FetchTag(context.Background(), repoName(t), "latest", cache)
+	require.NoError(t, err)
+	require.Equal(t, desc.Digest, dgst)
+
+	require.Contains(t, cache.manifests, amd64.Digest)
+	require.Contains(t, cache.manifests, desc.Digest)
+	for _, b := range amd64Blobs {
+		require.Contains(t, cache.blobs, b.Digest)
+	}
+	require.Len(t, cache.blobs, 2)
+	require.NotContains(t, remote.requested(), "GET /v2/"+remoteRepo+"/manifests/"+arm64.Digest.String())
+	require.Equal(t, desc.Digest, cache.tags["latest"])
+}
+
+func TestRemote_FetchTag_NotFound(t *testing.T) {
+	r := newTestRemote(t, newFakeRemote())
+
+	cache := newFakeCache()
+	_, _, err := r.FetchTag(context.Background(), repoName(t), "latest", cache)
+	require.ErrorIs(t, err, proxy.ErrNotFound)
+	require.Empty(t, cache.tags)
+}
+
+func TestRemote_FetchTag_BlobNotFound(t *testing.T) {
+	remote := newFakeRemote()
+	desc, blobs := addImage(t, remote, "latest", "a")
+	delete(remote.blobs, blobs[1].Digest)
+	r := newTestRemote(t, remote)
+
+	cache := newFakeCache()
+	_, _, err := r.FetchTag(context.Background(), repoName(t), "latest", cache)
+	require.ErrorIs(t, err, proxy.ErrNotFound)
+	// the manifest is not cached without the blobs it references
+	require.NotContains(t, cache.manifests, desc.Digest)
+	require.Empty(t, cache.tags)
+}
+
+func TestRemote_FetchTag_Unauthorized(t *testing.T) {
+	remote := newFakeRemote()
+	addImage(t, remote, "latest", "a")
+	s := httptest.NewServer(remote)
+	t.Cleanup(s.Close)
+
+	r, err := proxy.NewRemote(configuration.Proxy{RemoteURL: s.URL, Username: remoteUsername, Password: "wrong"})
+	require.NoError(t, err)
+
+	_, _, err = r.FetchTag(context.Background(), repoName(t), "latest", newFakeCache())
+	require.Error(t, err)
+	require.NotErrorIs(t, err, proxy.ErrNotFound)
+}
+
+func TestRemote_FetchManifest(t *testing.T) {
+	remote := newFakeRemote()
+	desc, _ := addImage(t, remote, "", "a")
+	r := newTestRemote(t, remote)
+
+	cache := newFakeCache()
+	_, err := r.FetchManifest(context.Background(), repoName(t), desc.Digest, cache)
+	require.NoError(t, err)
+	require.Contains(t, cache.manifests, desc.Digest)
+	require.Empty(t, cache.tags)
+}
+
+func TestRemote_FetchManifest_DigestMismatch(t *testing.T) {
+	remote := newFakeRemote()
+	desc, _ := addImage(t, remote, "", "a")
+	other, _ := addImage(t, remote, "", "b")
+	// the remote serves another manifest than the requested one
+	remote.manifests[desc.Digest.String()] = remote.manifests[other.Digest.String()]
+	r := newTestRemote(t, remote)
+
+	cache := newFakeCache()
+	_, err := r.FetchManifest(context.Background(), repoName(t), desc.Digest, cache)
+	require.EqualError(t, err, fmt.Sprintf("remote manifest digest %s does not match requested digest %s", other.Digest, desc.Digest))
+	require.Empty(t, cache.manifests)
+}
+
+func TestRemote_FetchBlob(t *testing.T) {
+	remote := newFakeRemote()
+	desc := remote.addBlob(schema2.MediaTypeLayer, []byte("foo"))
+	r := newTestRemote(t, remote)
+
+	cache := newFakeCache()
+	require.NoError(t, r.FetchBlob(context.Background(), repoName(t), desc.Digest, cache))
+	require.Equal(t, []byte("foo"), cache.blobs[desc.Digest])
+
+	err := r.FetchBlob(context.Background(), repoName(t), digest.FromString("bar"), cache)
+	require.ErrorIs(t, err, proxy.ErrNotFound)
+}
+
+func mustMediaType(tb testing.TB, m distribution.Manifest) string {
+	tb.Helper()
+
+	mt, _, err := m.Payload()
+	require.NoError(tb, err)
+	return mt
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/docker/distribution/registry/client/transport"
+)
+
+// ErrNotFound is returned when a manifest or blob is not found in the remote registry.
+var ErrNotFound = errors.New("not found in the remote registry")
+
+// staticCredentials is an auth.CredentialStore holding the fixed credentials of the remote registry.
+type staticCredentials struct {
+	username, password string
+}
+
+// Basic implements auth.CredentialStore.
+func (c staticCredentials) Basic(*url.URL) (string, string) {
+	return c.username, c.password
+}
+
+// RefreshToken implements auth.CredentialStore.
+func (staticCredentials) RefreshToken(*url.URL, string) string {
+	return ""
+}
+
+// SetRefreshToken implements auth.CredentialStore.
+func (staticCredentials) SetRefreshToken(*url.URL, string, string) {}
+
+// contextTransport binds outgoing requests to a context, as the registry client does not propagate contexts, so that
+// in-flight requests are aborted when the request that triggered a fetch is canceled or times out.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// Remote is a client of the remote registry of the pull-through cache.
+type Remote struct {
+	url      string
+	username string
+	password string
+
+	transport http.RoundTripper
+}
+
+// RemoteOption provides functional options for NewRemote.
+type RemoteOption func(*Remote)
+
+// WithTransport sets the base HTTP transport used to reach the remote registry. Defaults to http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) RemoteOption {
+	return func(r *Remote) {
+		r.transport = rt
+	}
+}
+
+// NewRemote creates a client of the remote registry configured in config.
+func NewRemote(config configuration.Proxy, opts ...RemoteOption) (*Remote, error) {
+	u, err := url.Parse(config.RemoteURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote url %q, must be in the form http[s]://host[:port]", config.RemoteURL)
+	}
+
+	r := &Remote{
+		url:       strings.TrimSuffix(config.RemoteURL, "/"),
+		username:  config.Username,
+		password:  config.Password,
+		transport: http.DefaultTransport,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// URL returns the base URL of the remote registry.
+func (r *Remote) URL() string {
+	return r.url
+}
+
+// Repository returns a client for the repository with the given name on the remote registry, authorized to pull from
+// it. The remote registry is pinged first to find out which authentication schemes it supports. Requests are bound to
+// ctx.
+func (r *Remote) Repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
+	base := &contextTransport{ctx: ctx, base: r.transport}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url+"/v2/", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := (&http.Client{Transport: base}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("pinging remote registry: %w", err)
+	}
+	resp.Body.Close()
+
+	cm := challenge.NewSimpleManager()
+	if err := cm.AddResponse(resp); err != nil {
+		return nil, fmt.Errorf("parsing remote registry challenges: %w", err)
+	}
+
+	creds := staticCredentials{username: r.username, password: r.password}
+	authorizer := auth.NewAuthorizer(cm,
+		auth.NewTokenHandler(base, creds, name.Name(), "pull"),
+		auth.NewBasicHandler(creds),
+	)
+
+	return client.NewRepository(name, r.url, transport.NewTransport(base, authorizer))
+}
+
+// isNotFound reports whether err is a response of the remote registry for a manifest, blob or repository that does not
+// exist.
+func isNotFound(err error) bool {
+	if errors.Is(err, distribution.ErrBlobUnknown) {
+		return true
+	}
+
+	var errs errcode.Errors
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			if isNotFound(e) {
+				return true
+			}
+		}
+		return false
+	}
+	var ec errcode.ErrorCoder
+	if errors.As(err, &ec) {
+		return ec.ErrorCode().Descriptor().HTTPStatusCode == http.StatusNotFound
+	}
+	var ue *client.UnexpectedHTTPResponseError
+	if errors.As(err, &ue) {
+		return ue.StatusCode == http.StatusNotFound
+	}
+
+	return false
+}
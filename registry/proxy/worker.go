@@ -0,0 +1,192 @@
+// Package proxy implements the pull-through cache mode of the registry, in which manifests and blobs not found locally
+// are fetched from a remote registry and cached, and cached tags are deleted once expired, so that they are fetched
+// again on the next pull.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+const (
+	componentKey = "component"
+	workerName   = "registry.proxy.Worker"
+
+	defaultInterval  = time.Minute
+	defaultTTL       = 7 * 24 * time.Hour
+	defaultBatchSize = 100
+)
+
+// Store is the subset of datastore.ProxyCachedTagStore used to expire cached tags.
+type Store interface {
+	FindCachedBefore(ctx context.Context, t time.Time, limit int) ([]*models.ProxyCachedTag, error)
+	Delete(ctx context.Context, ct *models.ProxyCachedTag) error
+}
+
+// Deleter deletes a cached tag from a repository. Deleting a tag that no longer exists must not fail.
+type Deleter interface {
+	DeleteTag(ctx context.Context, repoPath, name string) error
+}
+
+// Worker periodically deletes the cached tags that were fetched from the remote registry longer than a given TTL ago,
+// so that they are fetched again on the next pull. The manifests and blobs that are no longer referenced are then
+// deleted by the online garbage collector. A cached tag is only forgotten once deleted, so failed deletes are retried on
+// the next run.
+type Worker struct {
+	store     Store
+	deleter   Deleter
+	logger    log.Logger
+	interval  time.Duration
+	ttl       time.Duration
+	batchSize int
+}
+
+// WorkerOption provides functional options for NewWorker.
+type WorkerOption func(*Worker)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) WorkerOption {
+	return func(w *Worker) {
+		w.logger = l
+	}
+}
+
+// WithInterval sets the interval between runs. Defaults to 1 minute.
+func WithInterval(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.interval = d
+	}
+}
+
+// WithTTL sets the time after which a cached tag expires. Defaults to 7 days.
+func WithTTL(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.ttl = d
+	}
+}
+
+// WithBatchSize sets the maximum number of expired tags deleted in each run. Defaults to 100.
+func WithBatchSize(n int) WorkerOption {
+	return func(w *Worker) {
+		w.batchSize = n
+	}
+}
+
+func (w *Worker) applyDefaults() {
+	if w.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		w.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if w.interval == 0 {
+		w.interval = defaultInterval
+	}
+	if w.ttl == 0 {
+		w.ttl = defaultTTL
+	}
+	if w.batchSize == 0 {
+		w.batchSize = defaultBatchSize
+	}
+}
+
+// NewWorker creates a new Worker.
+func NewWorker(store Store, deleter Deleter, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		store:   store,
+		deleter: deleter,
+	}
+	w.applyDefaults()
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.logger = w.logger.WithFields(log.Fields{componentKey: workerName})
+
+	return w
+}
+
+// Run deletes up to the configured batch size of tags cached longer than the configured TTL ago. Tags that fail to be
+// deleted are skipped and remain cached. It returns the number of deleted tags and, if any delete failed, the last
+// error.
+func (w *Worker) Run(ctx context.Context) (int, error) {
+	tt, err := w.store.FindCachedBefore(ctx, time.Now().Add(-w.ttl), w.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	l := log.GetLogger(log.WithContext(ctx))
+
+	var deleted, failed int
+	var lastErr error
+	for _, ct := range tt {
+		tl := l.WithFields(log.Fields{
+			"repository_path": ct.RepositoryPath,
+			"tag_name":        ct.Name,
+			"cached_at":       ct.CachedAt.String(),
+		})
+
+		if err := w.deleter.DeleteTag(ctx, ct.RepositoryPath, ct.Name); err != nil {
+			tl.WithError(err).Warn("failed to delete expired cached tag")
+			failed++
+			lastErr = err
+			continue
+		}
+		if err := w.store.Delete(ctx, ct); err != nil {
+			return deleted, err
+		}
+		tl.Info("expired cached tag deleted")
+		deleted++
+	}
+
+	if lastErr != nil {
+		return deleted, fmt.Errorf("failed to delete %d expired cached tags, last error: %w", failed, lastErr)
+	}
+
+	return deleted, nil
+}
+
+// Start starts the Worker. This is a blocking call that, every configured interval, deletes a batch of expired cached
+// tags until the provided context is canceled.
+func (w *Worker) Start(ctx context.Context) error {
+	w.logger.WithFields(log.Fields{
+		"interval_s": w.interval.Seconds(),
+		"ttl_s":      w.ttl.Seconds(),
+		"batch_size": w.batchSize,
+	}).Info("starting proxy cache expiry worker")
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		id := correlation.SafeRandomID()
+		rCtx := correlation.ContextWithCorrelation(ctx, id)
+		l := w.logger.WithFields(log.Fields{correlation.FieldName: id})
+		rCtx = log.WithLogger(rCtx, l)
+
+		start := time.Now()
+		n, err := w.Run(rCtx)
+		l = l.WithFields(log.Fields{"duration_s": time.Since(start).Seconds(), "tags_count": n})
+		if err != nil {
+			l.WithError(err).Error("proxy cache expiry run failed")
+			continue
+		}
+		if n > 0 {
+			l.Info("proxy cache expiry run completed")
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package proxy_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is a proxy.Store that serves a fixed set of cached tags.
+type fakeStore struct {
+	mu      sync.Mutex
+	tags    []*models.ProxyCachedTag
+	findErr error
+}
+
+func (s *fakeStore) FindCachedBefore(_ context.Context, t time.Time, limit int) ([]*models.ProxyCachedTag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	var tt []*models.ProxyCachedTag
+	for _, ct := range s.tags {
+		if ct.CachedAt.Before(t) && len(tt) < limit {
+			tt = append(tt, ct)
+		}
+	}
+	return tt, nil
+}
+
+func (s *fakeStore) Delete(_ context.Context, ct *models.ProxyCachedTag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, t := range s.tags {
+		if t.Name == ct.Name {
+			s.tags = append(s.tags[:i], s.tags[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) cached() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.tags))
+	for _, ct := range s.tags {
+		names = append(names, ct.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fakeDeleter is a proxy.Deleter that records deleted tags, failing for the given names.
+type fakeDeleter struct {
+	mu      sync.Mutex
+	deleted []string
+	fails   map[string]error
+}
+
+func (d *fakeDeleter) DeleteTag(_ context.Context, _, name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.fails[name]; err != nil {
+		return err
+	}
+	d.deleted = append(d.deleted, name)
+	return nil
+}
+
+func newCachedTag(name string, age time.Duration) *models.ProxyCachedTag {
+	return &models.ProxyCachedTag{RepositoryPath: "foo/bar", Name: name, CachedAt: time.Now().Add(-age)}
+}
+
+func TestWorker_Run(t *testing.T) {
+	s := &fakeStore{tags: []*models.ProxyCachedTag{
+		newCachedTag("a", 8*24*time.Hour),
+		newCachedTag("b", time.Hour),
+		newCachedTag("c", 10*24*time.Hour),
+	}}
+	d := &fakeDeleter{}
+	w := proxy.NewWorker(s, d)
+
+	n, err := w.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.ElementsMatch(t, []string{"a", "c"}, d.deleted)
+	// recently cached tags are kept
+	require.Equal(t, []string{"b"}, s.cached())
+}
+
+func TestWorker_Run_TTL(t *testing.T) {
+	s := &fakeStore{tags: []*models.ProxyCachedTag{
+		newCachedTag("a", 2*time.Hour),
+		newCachedTag("b", 30*time.Minute),
+	}}
+	d := &fakeDeleter{}
+	w := proxy.NewWorker(s, d, proxy.WithTTL(time.Hour))
+
+	n, err := w.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []string{"a"}, d.deleted)
+	require.Equal(t, []string{"b"}, s.cached())
+}
+
+func TestWorker_Run_BatchSize(t *testing.T) {
+	s := &fakeStore{tags: []*models.ProxyCachedTag{
+		newCachedTag("a", 8*24*time.Hour),
+		newCachedTag("b", 8*24*time.Hour),
+		newCachedTag("c", 8*24*time.Hour),
+	}}
+	w := proxy.NewWorker(s, &fakeDeleter{}, proxy.WithBatchSize(2))
+
+	n, err := w.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Len(t, s.cached(), 1)
+}
+
+func TestWorker_Run_DeleteError(t *testing.T) {
+	s := &fakeStore{tags: []*models.ProxyCachedTag{
+		newCachedTag("a", 8*24*time.Hour),
+		newCachedTag("b", 8*24*time.Hour),
+	}}
+	d := &fakeDeleter{fails: map[string]error{"a": errors.New("foo")}}
+	w := proxy.NewWorker(s, d)
+
+	n, err := w.Run(context.Background())
+	require.EqualError(t, err, "failed to delete 1 expired cached tags, last error: foo")
+	require.Equal(t, 1, n)
+	// tags that failed to be deleted remain cached, so they're retried on the next run
+	require.Equal(t, []string{"a"}, s.cached())
+}
+
+func TestWorker_Run_FindError(t *testing.T) {
+	s := &fakeStore{findErr: errors.New("foo")}
+	w := proxy.NewWorker(s, &fakeDeleter{})
+
+	n, err := w.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.Zero(t, n)
+}
+
+func TestWorker_Start(t *testing.T) {
+	s := &fakeStore{tags: []*models.ProxyCachedTag{
+		newCachedTag("a", 2*time.Hour),
+		newCachedTag("b", 2*time.Hour),
+		newCachedTag("c", 2*time.Hour),
+	}}
+	w := proxy.NewWorker(s, &fakeDeleter{},
+		proxy.WithInterval(10*time.Millisecond),
+		proxy.WithTTL(time.Hour),
+		proxy.WithBatchSize(1),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- w.Start(ctx) }()
+
+	// all expired tags are deleted, one batch per run
+	require.Eventually(t, func() bool { return len(s.cached()) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+}
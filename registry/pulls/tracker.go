@@ -0,0 +1,190 @@
+// Package pulls provides a background worker that records the last time tags and manifests were pulled in the
+// metadata database. Pulls are throttled and written in batches, to keep the write load on the database low.
+package pulls
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	componentKey = "component"
+	trackerName  = "registry.pulls.Tracker"
+
+	defaultThrottle      = 10 * time.Minute
+	defaultFlushInterval = 30 * time.Second
+	defaultBatchSize     = 500
+)
+
+// Store is the subset of datastore.PullStore used to record pulls.
+type Store interface {
+	UpdateLastPulledAt(ctx context.Context, pulls []*models.Pull) error
+}
+
+// pullKey identifies a pull for throttling purposes.
+type pullKey struct {
+	namespaceID, repositoryID, manifestID int64
+	tagName                               string
+}
+
+// Tracker buffers pulls in memory and periodically writes them to the database. Repeated pulls of the same manifest,
+// through the same tag, are only recorded once per throttle period. Recorded timestamps are therefore approximate,
+// lagging behind by up to the throttle period plus the flush interval.
+type Tracker struct {
+	store         Store
+	logger        log.Logger
+	throttle      time.Duration
+	flushInterval time.Duration
+	batchSize     int
+
+	mu      sync.Mutex
+	pending []*models.Pull
+	recent  map[pullKey]time.Time
+}
+
+// TrackerOption provides functional options for NewTracker.
+type TrackerOption func(*Tracker)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) TrackerOption {
+	return func(t *Tracker) {
+		t.logger = l
+	}
+}
+
+// WithThrottle sets the minimum time between two recorded pulls of the same manifest through the same tag. Defaults to
+// 10 minutes.
+func WithThrottle(d time.Duration) TrackerOption {
+	return func(t *Tracker) {
+		t.throttle = d
+	}
+}
+
+// WithFlushInterval sets the interval between writes to the database. Defaults to 30 seconds.
+func WithFlushInterval(d time.Duration) TrackerOption {
+	return func(t *Tracker) {
+		t.flushInterval = d
+	}
+}
+
+// WithBatchSize sets the maximum number of pulls written to the database in each statement. Defaults to 500.
+func WithBatchSize(n int) TrackerOption {
+	return func(t *Tracker) {
+		t.batchSize = n
+	}
+}
+
+func (t *Tracker) applyDefaults() {
+	if t.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		t.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if t.throttle == 0 {
+		t.throttle = defaultThrottle
+	}
+	if t.flushInterval == 0 {
+		t.flushInterval = defaultFlushInterval
+	}
+	if t.batchSize == 0 {
+		t.batchSize = defaultBatchSize
+	}
+}
+
+// NewTracker creates a new Tracker.
+func NewTracker(store Store, opts ...TrackerOption) *Tracker {
+	t := &Tracker{
+		store:  store,
+		recent: make(map[pullKey]time.Time),
+	}
+	t.applyDefaults()
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	t.logger = t.logger.WithFields(log.Fields{componentKey: trackerName})
+
+	return t
+}
+
+// Record buffers a pull, to be written to the database on the next flush, unless the same manifest was recorded as
+// pulled through the same tag within the throttle period. This method may be called safely from multiple concurrent
+// goroutines.
+func (t *Tracker) Record(p *models.Pull) {
+	k := pullKey{p.NamespaceID, p.RepositoryID, p.ManifestID, p.TagName}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.recent[k]; ok && p.PulledAt.Sub(last) < t.throttle {
+		return
+	}
+	t.recent[k] = p.PulledAt
+	t.pending = append(t.pending, p)
+}
+
+// Flush writes all buffered pulls to the database, in batches of the configured size. Pulls of failed batches are
+// dropped, as timestamps are refreshed by subsequent pulls anyway. The first error encountered, if any, is returned.
+func (t *Tracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = nil
+	// forget pulls older than the throttle period, so that memory usage is bounded by the number of distinct pulls
+	// within that period
+	now := time.Now()
+	for k, last := range t.recent {
+		if now.Sub(last) >= t.throttle {
+			delete(t.recent, k)
+		}
+	}
+	t.mu.Unlock()
+
+	var firstErr error
+	for len(pending) > 0 {
+		n := t.batchSize
+		if n > len(pending) {
+			n = len(pending)
+		}
+		if err := t.store.UpdateLastPulledAt(ctx, pending[:n]); err != nil {
+			t.logger.WithError(err).WithFields(log.Fields{"pulls": n}).Warn("failed to record pulls")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		pending = pending[n:]
+	}
+
+	return firstErr
+}
+
+// Start starts the Tracker. This is a blocking call that flushes buffered pulls every configured interval until the
+// provided context is canceled. Pulls buffered since the last flush are not written once the context is canceled, use
+// Flush for that.
+func (t *Tracker) Start(ctx context.Context) error {
+	t.logger.WithFields(log.Fields{
+		"throttle_s":       t.throttle.Seconds(),
+		"flush_interval_s": t.flushInterval.Seconds(),
+		"batch_size":       t.batchSize,
+	}).Info("starting pull tracking")
+
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-ticker.C:
+			// errors are logged by Flush
+			_ = t.Flush(ctx)
+		}
+	}
+}
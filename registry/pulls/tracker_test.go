@@ -0,0 +1,128 @@
+package pulls_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/pulls"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is a pulls.Store that records the batches it receives, optionally failing for a given number of calls.
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]*models.Pull
+	fails   int
+}
+
+func (s *fakeStore) UpdateLastPulledAt(_ context.Context, pulls []*models.Pull) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("foo")
+	}
+	s.batches = append(s.batches, pulls)
+	return nil
+}
+
+func (s *fakeStore) pulls() []*models.Pull {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pp []*models.Pull
+	for _, b := range s.batches {
+		pp = append(pp, b...)
+	}
+	return pp
+}
+
+func TestTracker_Throttle(t *testing.T) {
+	s := &fakeStore{}
+	tr := pulls.NewTracker(s, pulls.WithThrottle(time.Hour))
+
+	now := time.Now()
+	byTag := &models.Pull{NamespaceID: 1, RepositoryID: 2, ManifestID: 3, TagName: "latest", PulledAt: now}
+	byDigest := &models.Pull{NamespaceID: 1, RepositoryID: 2, ManifestID: 3, PulledAt: now}
+	otherTag := &models.Pull{NamespaceID: 1, RepositoryID: 2, ManifestID: 3, TagName: "1.0", PulledAt: now}
+
+	tr.Record(byTag)
+	tr.Record(byDigest)
+	tr.Record(otherTag)
+	// repeated pulls within the throttle period are ignored
+	tr.Record(&models.Pull{NamespaceID: 1, RepositoryID: 2, ManifestID: 3, TagName: "latest", PulledAt: now.Add(time.Minute)})
+	tr.Record(&models.Pull{NamespaceID: 1, RepositoryID: 2, ManifestID: 3, PulledAt: now.Add(time.Minute)})
+
+	require.NoError(t, tr.Flush(context.Background()))
+	require.ElementsMatch(t, []*models.Pull{byTag, byDigest, otherTag}, s.pulls())
+
+	// once the throttle period elapses, pulls are recorded again
+	later := &models.Pull{NamespaceID: 1, RepositoryID: 2, ManifestID: 3, TagName: "latest", PulledAt: now.Add(time.Hour)}
+	tr.Record(later)
+
+	require.NoError(t, tr.Flush(context.Background()))
+	require.ElementsMatch(t, []*models.Pull{byTag, byDigest, otherTag, later}, s.pulls())
+}
+
+func TestTracker_Flush_Batches(t *testing.T) {
+	s := &fakeStore{}
+	tr := pulls.NewTracker(s, pulls.WithBatchSize(2))
+
+	// nothing to flush
+	require.NoError(t, tr.Flush(context.Background()))
+	require.Empty(t, s.batches)
+
+	for i := int64(1); i <= 5; i++ {
+		tr.Record(&models.Pull{NamespaceID: 1, RepositoryID: 1, ManifestID: i, PulledAt: time.Now()})
+	}
+
+	require.NoError(t, tr.Flush(context.Background()))
+	require.Len(t, s.batches, 3)
+	require.Len(t, s.batches[0], 2)
+	require.Len(t, s.batches[1], 2)
+	require.Len(t, s.batches[2], 1)
+
+	// buffered pulls are written only once
+	require.NoError(t, tr.Flush(context.Background()))
+	require.Len(t, s.batches, 3)
+}
+
+func TestTracker_Flush_Error(t *testing.T) {
+	s := &fakeStore{fails: 1}
+	tr := pulls.NewTracker(s, pulls.WithBatchSize(2))
+
+	for i := int64(1); i <= 3; i++ {
+		tr.Record(&models.Pull{NamespaceID: 1, RepositoryID: 1, ManifestID: i, PulledAt: time.Now()})
+	}
+
+	// the failed batch is dropped, but the remaining ones are still written
+	require.EqualError(t, tr.Flush(context.Background()), "foo")
+	require.Len(t, s.batches, 1)
+	require.Equal(t, int64(3), s.batches[0][0].ManifestID)
+
+	require.NoError(t, tr.Flush(context.Background()))
+	require.Len(t, s.batches, 1)
+}
+
+func TestTracker_Start(t *testing.T) {
+	s := &fakeStore{}
+	tr := pulls.NewTracker(s, pulls.WithFlushInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- tr.Start(ctx) }()
+
+	p := &models.Pull{NamespaceID: 1, RepositoryID: 1, ManifestID: 1, PulledAt: time.Now()}
+	tr.Record(p)
+
+	require.Eventually(t, func() bool { return len(s.pulls()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, p, s.pulls()[0])
+
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+}
@@ -0,0 +1,185 @@
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+const (
+	componentKey = "component"
+	workerName   = "registry.purge.Worker"
+
+	defaultInterval = time.Second
+	defaultLease    = 5 * time.Minute
+
+	// maxErrorLength is the maximum length of the last error recorded for a purge.
+	maxErrorLength = 255
+)
+
+// Store is the subset of datastore.RepositoryPurgeStore used to process repository purges.
+type Store interface {
+	ClaimNextDue(ctx context.Context, lease time.Duration) (*models.RepositoryPurge, error)
+	Update(ctx context.Context, p *models.RepositoryPurge) error
+}
+
+// Executor deletes the next batch of tags, manifests or blob links of a purged repository, incrementing the
+// corresponding counts of p. It returns true once there is nothing left to delete.
+type Executor interface {
+	ExecuteBatch(ctx context.Context, p *models.RepositoryPurge) (bool, error)
+}
+
+// Worker processes repository purges in batches. Each run claims the next due purge and executes a single batch of
+// deletions for it, after which the purge is postponed by the configured interval, limiting the rate at which each
+// repository is purged. Progress is persisted after every batch, so purges resume where they left off if a registry
+// instance stops. Failed batches are recorded and retried on the next run.
+type Worker struct {
+	store    Store
+	executor Executor
+	logger   log.Logger
+	interval time.Duration
+	lease    time.Duration
+}
+
+// WorkerOption provides functional options for NewWorker.
+type WorkerOption func(*Worker)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) WorkerOption {
+	return func(w *Worker) {
+		w.logger = l
+	}
+}
+
+// WithInterval sets the interval between batches of the same purge, which is also the interval between checks for due
+// purges. Defaults to 1 second.
+func WithInterval(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.interval = d
+	}
+}
+
+// WithLease sets for how long a claimed purge is held by the worker before it can be claimed again, in case the
+// worker stops while processing it. Defaults to 5 minutes.
+func WithLease(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.lease = d
+	}
+}
+
+func (w *Worker) applyDefaults() {
+	if w.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		w.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if w.interval == 0 {
+		w.interval = defaultInterval
+	}
+	if w.lease == 0 {
+		w.lease = defaultLease
+	}
+}
+
+// NewWorker creates a new Worker.
+func NewWorker(store Store, executor Executor, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		store:    store,
+		executor: executor,
+	}
+	w.applyDefaults()
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.logger = w.logger.WithFields(log.Fields{componentKey: workerName})
+
+	return w
+}
+
+// Run claims the next due purge, if any, and executes a single batch of deletions for it. It returns the processed
+// purge, or nil if none was due. Batch errors are recorded in the purge and returned, after persisting its progress.
+func (w *Worker) Run(ctx context.Context) (*models.RepositoryPurge, error) {
+	p, err := w.store.ClaimNextDue(ctx, w.lease)
+	if err != nil || p == nil {
+		return nil, err
+	}
+
+	done, execErr := w.executor.ExecuteBatch(ctx, p)
+	if execErr != nil {
+		msg := execErr.Error()
+		if len(msg) > maxErrorLength {
+			msg = msg[:maxErrorLength]
+		}
+		p.LastError = sql.NullString{String: msg, Valid: true}
+	} else {
+		p.LastError = sql.NullString{}
+		p.CompletedAt.Valid = done
+	}
+	p.NextRunAt = time.Now().Add(w.interval)
+
+	if err := w.store.Update(ctx, p); err != nil {
+		return p, err
+	}
+
+	return p, execErr
+}
+
+// Start starts the Worker. This is a blocking call that, every configured interval, processes a batch of each due purge
+// until the provided context is canceled.
+func (w *Worker) Start(ctx context.Context) error {
+	w.logger.WithFields(log.Fields{
+		"interval_s": w.interval.Seconds(),
+		"lease_s":    w.lease.Seconds(),
+	}).Info("starting repository purges worker")
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		for ctx.Err() == nil {
+			id := correlation.SafeRandomID()
+			rCtx := correlation.ContextWithCorrelation(ctx, id)
+			l := w.logger.WithFields(log.Fields{correlation.FieldName: id})
+			rCtx = log.WithLogger(rCtx, l)
+
+			start := time.Now()
+			p, err := w.Run(rCtx)
+			if p == nil && err == nil {
+				// no more due purges
+				break
+			}
+			if p != nil {
+				l = l.WithFields(log.Fields{
+					"repository_path":   p.RepositoryPath,
+					"repository_id":     p.RepositoryID,
+					"tags_deleted":      p.TagsDeleted,
+					"manifests_deleted": p.ManifestsDeleted,
+					"blobs_unlinked":    p.BlobsUnlinked,
+					"completed":         p.Completed(),
+				})
+			}
+			l = l.WithFields(log.Fields{"duration_s": time.Since(start).Seconds()})
+			if err != nil {
+				l.WithError(err).Error("repository purge batch failed")
+				// avoid hammering a failing database, remaining due purges are processed on the next tick
+				break
+			}
+			l.Info("repository purge batch processed")
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package purge_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/purge"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is a purge.Store that serves a queue of due purges, requeuing incomplete ones once updated.
+type fakeStore struct {
+	mu       sync.Mutex
+	due      []*models.RepositoryPurge
+	claimErr error
+	updated  []models.RepositoryPurge
+	lease    time.Duration
+}
+
+func (s *fakeStore) ClaimNextDue(_ context.Context, lease time.Duration) (*models.RepositoryPurge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lease = lease
+	if s.claimErr != nil {
+		return nil, s.claimErr
+	}
+	if len(s.due) == 0 || s.due[0].NextRunAt.After(time.Now()) {
+		return nil, nil
+	}
+	p := s.due[0]
+	s.due = s.due[1:]
+	return p, nil
+}
+
+func (s *fakeStore) Update(_ context.Context, p *models.RepositoryPurge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updated = append(s.updated, *p)
+	if !p.Completed() {
+		s.due = append(s.due, p)
+	}
+	return nil
+}
+
+func (s *fakeStore) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.due)
+}
+
+// fakeExecutor is a purge.Executor that deletes one tag per batch until batches are exhausted, failing with err if set.
+type fakeExecutor struct {
+	batches int
+	err     error
+}
+
+func (e *fakeExecutor) ExecuteBatch(_ context.Context, p *models.RepositoryPurge) (bool, error) {
+	if e.err != nil {
+		return false, e.err
+	}
+	if p.TagsDeleted == int64(e.batches) {
+		return true, nil
+	}
+	p.TagsDeleted++
+	return false, nil
+}
+
+func TestWorker_Run(t *testing.T) {
+	p := &models.RepositoryPurge{NamespaceID: 1, RepositoryID: 2}
+	s := &fakeStore{due: []*models.RepositoryPurge{p}}
+	w := purge.NewWorker(s, &fakeExecutor{batches: 1}, purge.WithInterval(time.Hour), purge.WithLease(time.Minute))
+
+	got, err := w.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, p, got)
+	require.EqualValues(t, 1, got.TagsDeleted)
+	require.False(t, got.Completed())
+	require.Equal(t, time.Minute, s.lease)
+	require.Len(t, s.updated, 1)
+	// the next batch is postponed by the interval
+	require.WithinDuration(t, time.Now().Add(time.Hour), got.NextRunAt, time.Minute)
+
+	// nothing else is due
+	got, err = w.Run(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	// the last batch completes the purge
+	p.NextRunAt = time.Time{}
+	got, err = w.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, got.Completed())
+	require.Len(t, s.updated, 2)
+}
+
+func TestWorker_Run_ExecuteError(t *testing.T) {
+	p := &models.RepositoryPurge{NamespaceID: 1, RepositoryID: 2}
+	s := &fakeStore{due: []*models.RepositoryPurge{p}}
+	w := purge.NewWorker(s, &fakeExecutor{err: errors.New(strings.Repeat("x", 300))})
+
+	got, err := w.Run(context.Background())
+	require.Error(t, err)
+	require.Equal(t, p, got)
+	// failed batches are recorded, and retried later on
+	require.Len(t, s.updated, 1)
+	require.True(t, got.LastError.Valid)
+	require.Len(t, got.LastError.String, 255)
+	require.False(t, got.Completed())
+	require.Equal(t, 1, s.pending())
+}
+
+func TestWorker_Run_ClaimError(t *testing.T) {
+	s := &fakeStore{claimErr: errors.New("foo")}
+	w := purge.NewWorker(s, &fakeExecutor{})
+
+	got, err := w.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.Nil(t, got)
+}
+
+func TestWorker_Start(t *testing.T) {
+	s := &fakeStore{due: []*models.RepositoryPurge{
+		{NamespaceID: 1, RepositoryID: 1},
+		{NamespaceID: 1, RepositoryID: 2},
+	}}
+	w := purge.NewWorker(s, &fakeExecutor{batches: 3}, purge.WithInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- w.Start(ctx) }()
+
+	// all purges are processed batch by batch until completed
+	require.Eventually(t, func() bool { return s.pending() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Len(t, s.updated, 8)
+}
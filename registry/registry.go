@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -21,6 +22,7 @@ import (
 	"github.com/docker/distribution/registry/handlers"
 	"github.com/docker/distribution/registry/internal/dns"
 	"github.com/docker/distribution/registry/listener"
+	"github.com/docker/distribution/tracing"
 	"github.com/docker/distribution/uuid"
 	"github.com/docker/distribution/version"
 
@@ -34,6 +36,8 @@ import (
 	"gitlab.com/gitlab-org/labkit/monitoring"
 	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 var errSkipTLSConfig = errors.New("no TLS config found")
@@ -61,13 +65,21 @@ var ServeCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		shutdownTracing, err := tracing.Configure(ctx, config.Tracing)
+		if err != nil {
+			log.Fatalln(fmt.Errorf("configuring tracing: %w", err))
+		}
+
 		registry, err := NewRegistry(ctx, config)
 		if err != nil {
 			log.Fatalln(err)
 		}
+		registry.reloadConfig = func() (*configuration.Configuration, error) {
+			return resolveConfiguration(args)
+		}
 
 		go func() {
-			opts, err := configureMonitoring(ctx, config)
+			opts, err := configureMonitoring(ctx, config, registry.app)
 			if err != nil {
 				log.WithError(err).Error("failed to configure monitoring service, skipping")
 				return
@@ -81,7 +93,12 @@ var ServeCmd = &cobra.Command{
 		// if running in FIPS mode, this emits an info log message saying so
 		fips.Check()
 
-		if err = registry.ListenAndServe(); err != nil {
+		err = registry.ListenAndServe()
+		// flush pending spans before exiting
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.WithError(err).Error("failed to shut down tracing")
+		}
+		if err != nil {
 			log.Fatalln(err)
 		}
 	},
@@ -92,6 +109,10 @@ type Registry struct {
 	config *configuration.Configuration
 	app    *handlers.App
 	server *http.Server
+	// grpcServer serves the gRPC metadata API, if enabled.
+	grpcServer *grpc.Server
+	// reloadConfig resolves the configuration again when a reload signal is received. Reloads are ignored if nil.
+	reloadConfig func() (*configuration.Configuration, error)
 }
 
 // NewRegistry creates a new registry from a context and configuration struct.
@@ -116,6 +137,7 @@ func NewRegistry(ctx context.Context, config *configuration.Configuration) (*Reg
 	if err := app.RegisterHealthChecks(); err != nil {
 		return nil, err
 	}
+	app.RegisterReadinessChecks(health.ReadinessRegistry)
 
 	handler := panicHandler(app)
 	if handler, err = configureReporting(config, handler); err != nil {
@@ -129,92 +151,289 @@ func NewRegistry(ctx context.Context, config *configuration.Configuration) (*Reg
 	handler = correlation.InjectCorrelationID(handler, correlation.WithPropagation())
 
 	server := &http.Server{
-		Handler: handler,
+		Handler:           handler,
+		ReadHeaderTimeout: config.HTTP.RequestLimits.ReadHeaderTimeout,
+		// connections are made available to handlers to enforce request body timeouts
+		ConnContext: handlers.ConnContext,
 	}
+	// event streams never end on their own, so these must be closed for the server to shut down gracefully
+	server.RegisterOnShutdown(app.CloseEventStreams)
 
-	return &Registry{
+	registry := &Registry{
 		app:    app,
 		config: config,
 		server: server,
-	}, nil
+	}
+
+	if config.GRPC.Addr != "" {
+		var opts []grpc.ServerOption
+		tlsConf, err := getTLSConfig(ctx, config.GRPC.TLS, false)
+		if err != nil && !errors.Is(err, errSkipTLSConfig) {
+			return nil, fmt.Errorf("configuring TLS for grpc: %w", err)
+		}
+		if tlsConf != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConf)))
+		}
+		registry.grpcServer = app.NewGRPCServer(opts...)
+	}
+
+	return registry, nil
 }
 
 // Channel to capture signals used to gracefully shutdown the registry.
 // It is global to ease unit testing
 var quit = make(chan os.Signal, 1)
 
+// Channel to capture signals used to drain the registry before shutting it down.
+// It is global to ease unit testing
+var drain = make(chan os.Signal, 1)
+
+// Channel to capture signals used to reload the configuration.
+// It is global to ease unit testing
+var reload = make(chan os.Signal, 1)
+
+// defaultUploadsDrainTimeout is the default time to wait for in-flight blob uploads and manifest puts to complete when
+// draining the registry.
+const defaultUploadsDrainTimeout = 5 * time.Minute
+
 // ListenAndServe runs the registry's HTTP server.
 func (registry *Registry) ListenAndServe() error {
-	config := registry.config
-
-	ln, err := listener.NewListener(config.HTTP.Net, config.HTTP.Addr)
+	lns, err := registry.listen()
 	if err != nil {
 		return err
 	}
 
-	tlsConf, err := getTLSConfig(registry.app.Context, config.HTTP.TLS, config.HTTP.HTTP2.Disabled)
-	if err != nil && !errors.Is(err, errSkipTLSConfig) {
+	// Setup channel to get notified on SIGTERM and interrupt signals.
+	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
+	// Setup channel to get notified on SIGUSR1 signals, to drain before shutting down.
+	signal.Notify(drain, syscall.SIGUSR1)
+	// Setup channel to get notified on SIGHUP signals, to reload the configuration.
+	signal.Notify(reload, syscall.SIGHUP)
+	serveErr := make(chan error, len(lns))
+
+	// Start serving in goroutines and listen for stop signal in main thread
+	for _, ln := range lns {
+		go func(ln net.Listener) {
+			serveErr <- registry.server.Serve(ln)
+		}(ln)
+	}
+
+	if registry.grpcServer != nil {
+		ln, err := listener.NewListener(registry.config.GRPC.Net, registry.config.GRPC.Addr)
+		if err != nil {
+			return fmt.Errorf("listening for grpc: %w", err)
+		}
+		dcontext.GetLogger(registry.app).Infof("grpc listening on %v (%s)", ln.Addr(), ln.Addr().Network())
+
+		go func() {
+			serveErr <- registry.grpcServer.Serve(ln)
+		}()
+	}
+
+	var (
+		draining = registry.app.Draining()
+		drained  chan error
+		drainLog *log.Entry
+	)
+	// stops waiting for the drain if shutting down for any other reason
+	drainCtx, cancelDrain := context.WithCancel(context.Background())
+	defer cancelDrain()
+
+	for {
+		select {
+		case err := <-serveErr:
+			return err
+		case s := <-drain:
+			log.WithField("drain_signal", s.String()).Info("received drain signal")
+			registry.app.Drain()
+		case s := <-reload:
+			registry.reload(log.WithField("reload_signal", s.String()))
+		case <-draining:
+			// the closed channel must not be selected again
+			draining = nil
+
+			timeout := registry.config.Uploads.DrainTimeout
+			if timeout == 0 {
+				timeout = defaultUploadsDrainTimeout
+			}
+			drainLog = log.WithField("uploads_drain_timeout", timeout)
+			drainLog.Info("waiting for open blob uploads and in-flight manifest puts to complete...")
+
+			// wait in the background, so that stop and reload signals are still handled while draining
+			drained = make(chan error, 1)
+			go func() {
+				ctx, cancel := context.WithTimeout(drainCtx, timeout)
+				defer cancel()
+				drained <- registry.app.WaitDrained(ctx)
+			}()
+		case err := <-drained:
+			if err != nil {
+				s := registry.app.DrainStatus()
+				drainLog.WithFields(log.Fields{
+					"uploads":       s.Uploads,
+					"manifest_puts": s.ManifestPuts,
+					"sessions":      s.Sessions,
+				}).Warn("timed out waiting for open blob uploads and in-flight manifest puts")
+			} else {
+				drainLog.Info("open blob uploads and in-flight manifest puts completed")
+			}
+
+			return registry.shutdown(drainLog)
+		case s := <-quit:
+			return registry.shutdown(log.WithField("quit_signal", s.String()))
+		}
+	}
+}
+
+// reload resolves the configuration again and applies the settings that can be changed at runtime: the log level,
+// maintenance windows, rate limits, notification endpoints and token auth settings. Changes to any other setting
+// require a restart. Settings that fail validation are logged and keep their current value.
+func (registry *Registry) reload(l *log.Entry) {
+	if registry.reloadConfig == nil {
+		l.Warn("configuration reload not supported, ignoring signal")
+		return
+	}
+	l.Info("reloading configuration")
+
+	config, err := registry.reloadConfig()
+	if err != nil {
+		l.WithError(err).Error("failed to reload configuration, keeping current settings")
+		return
+	}
+	if err := reloadLogLevel(config); err != nil {
+		l.WithError(err).Error("failed to reload log level, keeping current one")
+	}
+	if err := registry.app.SetMaintenanceWindows(config.Maintenance.Windows); err != nil {
+		l.WithError(err).Error("failed to reload maintenance windows, keeping current ones")
+	}
+	if err := registry.app.Reload(config); err != nil {
+		l.WithError(err).Error("failed to reload some settings, keeping their current value")
+	}
+}
+
+// reloadLogLevel applies the log level of config to the application logger. The access logger has no level.
+func reloadLogLevel(config *configuration.Configuration) error {
+	lvl, err := log.ParseLevel(config.Log.Level.String())
+	if err != nil {
 		return err
 	}
+	if lvl != log.GetLevel() {
+		log.WithFields(log.Fields{"from": log.GetLevel().String(), "to": lvl.String()}).Info("log level changed")
+		log.SetLevel(lvl)
+	}
+	return nil
+}
+
+// shutdown gracefully stops the HTTP server and closes the database connections.
+func (registry *Registry) shutdown(l *log.Entry) error {
+	l = l.WithFields(log.Fields{
+		"http_drain_timeout":     registry.config.HTTP.DrainTimeout,
+		"database_drain_timeout": registry.config.Database.DrainTimeout,
+	})
+	l.Info("attempting to stop server gracefully...")
+
+	// shutdown the server with a grace period of configured timeout
+	if registry.config.HTTP.DrainTimeout != 0 {
+		l.Info("draining http connections")
+		ctx, cancel := context.WithTimeout(context.Background(), registry.config.HTTP.DrainTimeout)
+		defer cancel()
+		if err := registry.server.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
 
-	if tlsConf != nil {
-		ln = tls.NewListener(ln, tlsConf)
+	if registry.grpcServer != nil {
+		l.Info("stopping grpc server")
+		registry.stopGRPC(registry.config.HTTP.DrainTimeout)
+	}
 
-		dcontext.GetLogger(registry.app).Infof("listening on %v, tls", ln.Addr())
-	} else {
-		dcontext.GetLogger(registry.app).Infof("listening on %v", ln.Addr())
+	if registry.config.Database.Enabled {
+		l.Info("closing database connections")
+
+		ctx := context.Background()
+		var cancel context.CancelFunc
+
+		// Drain database with grace period, rather than waiting indefinitely.
+		if registry.config.Database.DrainTimeout != 0 {
+			ctx, cancel = context.WithTimeout(ctx, registry.config.Database.DrainTimeout)
+			defer cancel()
+		}
+
+		if err := registry.app.GracefulShutdown(ctx); err != nil {
+			return err
+		}
 	}
 
-	// Setup channel to get notified on SIGTERM and interrupt signals.
-	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
-	serveErr := make(chan error)
+	l.Info("graceful shutdown successful")
+	return nil
+}
 
-	// Start serving in goroutine and listen for stop signal in main thread
+// stopGRPC gracefully stops the gRPC server, waiting for in-flight requests up to timeout, if not zero, before
+// stopping forcibly.
+func (registry *Registry) stopGRPC(timeout time.Duration) {
+	if timeout == 0 {
+		registry.grpcServer.GracefulStop()
+		return
+	}
+
+	done := make(chan struct{})
 	go func() {
-		serveErr <- registry.server.Serve(ln)
+		registry.grpcServer.GracefulStop()
+		close(done)
 	}()
 
 	select {
-	case err := <-serveErr:
-		return err
-	case s := <-quit:
-		log := log.WithFields(log.Fields{
-			"quit_signal":            s.String(),
-			"http_drain_timeout":     registry.config.HTTP.DrainTimeout,
-			"database_drain_timeout": registry.config.Database.DrainTimeout,
-		})
-		log.Info("attempting to stop server gracefully...")
-
-		// shutdown the server with a grace period of configured timeout
-		if registry.config.HTTP.DrainTimeout != 0 {
-			log.Info("draining http connections")
-			ctx, cancel := context.WithTimeout(context.Background(), registry.config.HTTP.DrainTimeout)
-			defer cancel()
-			if err := registry.server.Shutdown(ctx); err != nil {
-				return err
-			}
-		}
+	case <-done:
+	case <-time.After(timeout):
+		registry.grpcServer.Stop()
+	}
+}
 
-		if registry.config.Database.Enabled {
-			log.Info("closing database connections")
+// listen announces on all configured addresses, returning the corresponding listeners, wrapped with TLS if configured.
+// Either the list of listeners or the single address (and TLS configuration) of the http section are used.
+func (registry *Registry) listen() ([]net.Listener, error) {
+	config := registry.config
 
-			ctx := context.Background()
-			var cancel context.CancelFunc
+	listeners := config.HTTP.Listeners
+	if len(listeners) == 0 {
+		listeners = []configuration.Listener{{Net: config.HTTP.Net, Addr: config.HTTP.Addr, TLS: config.HTTP.TLS}}
+	}
 
-			// Drain database with grace period, rather than waiting indefinitely.
-			if registry.config.Database.DrainTimeout != 0 {
-				ctx, cancel = context.WithTimeout(ctx, registry.config.Database.DrainTimeout)
-				defer cancel()
-			}
+	lns := make([]net.Listener, 0, len(listeners))
+	closeAll := func() {
+		for _, ln := range lns {
+			ln.Close()
+		}
+	}
+
+	for i, lc := range listeners {
+		ln, err := listener.NewListener(lc.Net, lc.Addr)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
 
-			if err := registry.app.GracefulShutdown(ctx); err != nil {
-				return err
+		tlsConf, err := getTLSConfig(registry.app.Context, lc.TLS, config.HTTP.HTTP2.Disabled)
+		if err != nil && !errors.Is(err, errSkipTLSConfig) {
+			ln.Close()
+			closeAll()
+			if len(config.HTTP.Listeners) > 0 {
+				return nil, fmt.Errorf("configuring TLS for http.listeners[%d]: %w", i, err)
 			}
+			return nil, err
 		}
 
-		log.Info("graceful shutdown successful")
-		return nil
+		if tlsConf != nil {
+			ln = tls.NewListener(ln, tlsConf)
+
+			dcontext.GetLogger(registry.app).Infof("listening on %v (%s), tls", ln.Addr(), ln.Addr().Network())
+		} else {
+			dcontext.GetLogger(registry.app).Infof("listening on %v (%s)", ln.Addr(), ln.Addr().Network())
+		}
+		lns = append(lns, ln)
 	}
+
+	return lns, nil
 }
 
 func getTLSConfig(ctx context.Context, config configuration.TLS, http2Disabled bool) (*tls.Config, error) {
@@ -372,7 +591,9 @@ func configureAccessLogging(config *configuration.Configuration, h http.Handler)
 	return logkit.AccessLogger(h, logkit.WithAccessLogger(logger), logkit.WithExtraFields(extraFieldGenerator)), nil
 }
 
-func configureMonitoring(ctx context.Context, config *configuration.Configuration) ([]monitoring.Option, error) {
+// configureMonitoring configures the debug server. The drain and maintenance endpoints are only exposed if app is not
+// nil.
+func configureMonitoring(ctx context.Context, config *configuration.Configuration, app *handlers.App) ([]monitoring.Option, error) {
 	l := dcontext.GetLogger(ctx)
 
 	var opts []monitoring.Option
@@ -387,6 +608,21 @@ func configureMonitoring(ctx context.Context, config *configuration.Configuratio
 		mux := http.NewServeMux()
 		mux.HandleFunc("/debug/health", health.StatusHandler)
 		l.WithFields(log.Fields{"address": addr, "path": "/debug/health"}).Info("starting health checker")
+		if app != nil {
+			mux.Handle("/debug/drain", app.DrainHandler())
+			l.WithFields(log.Fields{"address": addr, "path": "/debug/drain"}).Info("starting drain handler")
+			mux.Handle("/debug/maintenance", app.MaintenanceHandler())
+			l.WithFields(log.Fields{"address": addr, "path": "/debug/maintenance"}).Info("starting maintenance handler")
+			if config.Database.Enabled {
+				mux.Handle("/debug/database/pool", app.DBPoolHandler())
+				l.WithFields(log.Fields{"address": addr, "path": "/debug/database/pool"}).Info("starting database pool handler")
+			}
+		}
+		if config.Health.Readiness.Enabled {
+			mux.Handle("/readiness", health.ReadinessHandler(health.ReadinessRegistry))
+			mux.HandleFunc("/liveness", health.LivenessHandler)
+			l.WithFields(log.Fields{"address": addr, "path": "/readiness"}).Info("starting readiness checker")
+		}
 
 		opts = []monitoring.Option{
 			monitoring.WithServeMux(mux),
@@ -578,6 +814,34 @@ func validate(config *configuration.Configuration) error {
 		}
 	}
 
+	// Validate http listeners.
+	if len(config.HTTP.Listeners) > 0 {
+		if config.HTTP.Addr != "" || config.HTTP.Net != "" || config.HTTP.TLS.Certificate != "" || config.HTTP.TLS.LetsEncrypt.CacheFile != "" {
+			errs = multierror.Append(errs, errors.New("'http.listeners' can not be used along with 'http.addr', 'http.net' or 'http.tls'"))
+		}
+		for i, l := range config.HTTP.Listeners {
+			if l.Addr == "" {
+				errs = multierror.Append(errs, fmt.Errorf("'http.listeners[%d].addr' is required", i))
+			}
+		}
+	}
+
+	// Validate grpc section. The gRPC API must authenticate clients, either with client certificates or registry JWTs.
+	if config.GRPC.Addr != "" {
+		if !config.Database.Enabled {
+			errs = multierror.Append(errs, errors.New("'grpc' requires the metadata database to be enabled"))
+		}
+		if config.GRPC.TLS.LetsEncrypt.CacheFile != "" {
+			errs = multierror.Append(errs, errors.New("'grpc.tls.letsencrypt' is not supported"))
+		}
+		if len(config.GRPC.TLS.ClientCAs) > 0 && config.GRPC.TLS.Certificate == "" {
+			errs = multierror.Append(errs, errors.New("'grpc.tls.clientcas' requires 'grpc.tls.certificate'"))
+		}
+		if len(config.GRPC.TLS.ClientCAs) == 0 && config.Auth.Type() == "" {
+			errs = multierror.Append(errs, errors.New("'grpc' requires either 'grpc.tls.clientcas' or an 'auth' section"))
+		}
+	}
+
 	//  Validate and/or Log potential issues with azure `trimlegacyrootprefix` and `legacyrootprefix` configuration options.
 	if ac, ok := config.Storage["azure"]; ok {
 		var legacyPrefix, legacyPrefixIsBool, trimLegacyPrefix, trimLegacyPrefixIsBool bool
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"syscall"
 	"testing"
@@ -20,6 +21,7 @@ import (
 	"github.com/docker/distribution/registry/internal/testutil"
 	_ "github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"gitlab.com/gitlab-org/labkit/monitoring"
 )
@@ -167,6 +169,233 @@ func TestGracefulShutdown_HTTPDrainTimeout(t *testing.T) {
 	}
 }
 
+func TestGracefulShutdown_Drain(t *testing.T) {
+	registry, err := setupRegistry()
+	require.NoError(t, err)
+
+	errchan := make(chan error, 1)
+	go func() {
+		errchan <- registry.ListenAndServe()
+	}()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", registry.config.HTTP.Addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// any signal sent on this channel triggers the drain
+	drain <- syscall.SIGUSR1
+
+	// without in-flight uploads, the registry shuts down right away
+	select {
+	case err := <-errchan:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("registry did not shut down after draining")
+	}
+	require.True(t, registry.app.DrainStatus().Draining)
+}
+
+func TestGracefulShutdown_DrainOpenUpload(t *testing.T) {
+	registry, err := setupRegistry()
+	require.NoError(t, err)
+
+	reloaded := make(chan struct{}, 1)
+	registry.reloadConfig = func() (*configuration.Configuration, error) {
+		defer func() { reloaded <- struct{}{} }()
+		return &configuration.Configuration{}, nil
+	}
+
+	errchan := make(chan error, 1)
+	go func() {
+		errchan <- registry.ListenAndServe()
+	}()
+
+	// start a blob upload, leaving its session open in between requests
+	baseURL := "http://" + registry.config.HTTP.Addr
+	require.Eventually(t, func() bool {
+		resp, err := http.Post(baseURL+"/v2/foo/bar/blobs/uploads/", "", nil)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusAccepted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	drain <- syscall.SIGUSR1
+	require.Eventually(t, func() bool {
+		return registry.app.DrainStatus().Draining
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, registry.app.DrainStatus().Sessions)
+
+	// the registry waits for the open upload, while still handling signals
+	reload <- syscall.SIGHUP
+	select {
+	case <-reloaded:
+	case err := <-errchan:
+		t.Fatalf("registry shut down with an open upload: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("registry did not reload the configuration while draining")
+	}
+
+	quit <- syscall.SIGTERM
+	select {
+	case err := <-errchan:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("registry did not shut down while draining")
+	}
+}
+
+func TestListenAndServe_Reload(t *testing.T) {
+	registry, err := setupRegistry()
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	end := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	reloaded := make(chan struct{}, 1)
+	registry.reloadConfig = func() (*configuration.Configuration, error) {
+		defer func() { reloaded <- struct{}{} }()
+
+		config := &configuration.Configuration{}
+		config.Maintenance.Windows = []configuration.MaintenanceWindow{{Start: start, End: end, Reason: "storage migration"}}
+		return config, nil
+	}
+
+	errchan := make(chan error, 1)
+	go func() {
+		errchan <- registry.ListenAndServe()
+	}()
+
+	// any signal sent on this channel triggers the reload
+	reload <- syscall.SIGHUP
+
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("registry did not reload the configuration")
+	}
+	require.Eventually(t, func() bool {
+		return registry.app.MaintenanceStatus().Active
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s := registry.app.MaintenanceStatus()
+	require.Len(t, s.Windows, 1)
+	require.True(t, start.Equal(s.Windows[0].Start))
+	require.True(t, end.Equal(s.Windows[0].End))
+	require.Equal(t, "storage migration", s.Windows[0].Reason)
+
+	quit <- syscall.SIGTERM
+	select {
+	case err := <-errchan:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("registry did not shut down")
+	}
+}
+
+func TestReloadLogLevel(t *testing.T) {
+	defer logrus.SetLevel(logrus.GetLevel())
+	logrus.SetLevel(logrus.InfoLevel)
+
+	config := &configuration.Configuration{}
+	config.Log.Level = "debug"
+	require.NoError(t, reloadLogLevel(config))
+	require.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+	// invalid levels are not applied
+	config.Log.Level = "verbose"
+	require.Error(t, reloadLogLevel(config))
+	require.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+}
+
+func TestListen_Listeners(t *testing.T) {
+	registry, err := setupRegistry()
+	require.NoError(t, err)
+
+	tlsNet, tlsHost := "tcp6", "[::1]"
+	if ln, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		// IPv6 is not available in all test environments
+		tlsNet, tlsHost = "tcp4", "127.0.0.1"
+	} else {
+		ln.Close()
+	}
+	socket := filepath.Join(t.TempDir(), "registry.sock")
+
+	registry.config.HTTP.Addr = ""
+	registry.config.HTTP.Listeners = []configuration.Listener{
+		{
+			Net:  tlsNet,
+			Addr: tlsHost + ":0",
+			TLS: configuration.TLS{
+				Certificate: testutil.TLSCertFilename(t),
+				Key:         testutil.TLSKeytFilename(t),
+			},
+		},
+		{Net: "tcp4", Addr: "127.0.0.1:0"},
+		{Net: "unix", Addr: socket},
+	}
+
+	lns, err := registry.listen()
+	require.NoError(t, err)
+	require.Len(t, lns, 3)
+	for _, ln := range lns {
+		go registry.server.Serve(ln)
+	}
+	defer registry.server.Close()
+
+	get := func(t *testing.T, c *http.Client, u string) {
+		t.Helper()
+
+		resp, err := c.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// TLS listener
+	c := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	get(t, c, "https://"+lns[0].Addr().String()+"/v2/")
+
+	// plain HTTP listener
+	c = &http.Client{Timeout: 5 * time.Second}
+	get(t, c, "http://"+lns[1].Addr().String()+"/v2/")
+
+	// Unix socket listener
+	c = &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	get(t, c, "http://unix/v2/")
+}
+
+func TestListen_TLSError(t *testing.T) {
+	registry, err := setupRegistry()
+	require.NoError(t, err)
+
+	addr := freeLnAddr(t).String()
+	registry.config.HTTP.Addr = ""
+	registry.config.HTTP.Listeners = []configuration.Listener{
+		{Addr: addr},
+		{Addr: "127.0.0.1:0", TLS: configuration.TLS{Certificate: "/foo/cert.pem", Key: "/foo/key.pem"}},
+	}
+
+	_, err = registry.listen()
+	require.ErrorContains(t, err, "configuring TLS for http.listeners[1]")
+
+	// listeners opened before the failure must be closed
+	ln, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+}
+
 func requireEnvNotSet(t *testing.T, names ...string) {
 	t.Helper()
 
@@ -305,7 +534,7 @@ func TestConfigureMonitoring(t *testing.T) {
 			},
 			monitorConfigFunc: func(config *configuration.Configuration) func() {
 				return func() {
-					opts, err := configureMonitoring(context.Background(), config)
+					opts, err := configureMonitoring(context.Background(), config, nil)
 					require.NoError(t, err)
 					err = monitoring.Start(opts...)
 					require.NoError(t, err)
@@ -328,7 +557,7 @@ func TestConfigureMonitoring(t *testing.T) {
 			},
 			monitorConfigFunc: func(config *configuration.Configuration) func() {
 				return func() {
-					opts, err := configureMonitoring(context.Background(), config)
+					opts, err := configureMonitoring(context.Background(), config, nil)
 					require.NoError(t, err)
 					// Use local Prometheus registry for each test, otherwise different tests may attempt to register the same
 					// metrics in the default Prometheus registry, causing a panic.
@@ -355,7 +584,7 @@ func TestConfigureMonitoring(t *testing.T) {
 			},
 			monitorConfigFunc: func(config *configuration.Configuration) func() {
 				return func() {
-					opts, err := configureMonitoring(context.Background(), config)
+					opts, err := configureMonitoring(context.Background(), config, nil)
 					require.NoError(t, err)
 					// Use local Prometheus registry for each test, otherwise different tests may attempt to register the same
 					// metrics in the default Prometheus registry, causing a panic.
@@ -482,3 +711,133 @@ func Test_validate_redirect(t *testing.T) {
 		})
 	}
 }
+
+func Test_validate_listeners(t *testing.T) {
+	tests := []struct {
+		name          string
+		http          func(config *configuration.Configuration)
+		expectedError string
+	}{
+		{
+			name: "single address",
+			http: func(config *configuration.Configuration) {
+				config.HTTP.Addr = ":5000"
+			},
+		},
+		{
+			name: "listeners",
+			http: func(config *configuration.Configuration) {
+				config.HTTP.Listeners = []configuration.Listener{
+					{Net: "tcp6", Addr: "[::]:5000"},
+					{Net: "unix", Addr: "/run/registry.sock"},
+				}
+			},
+		},
+		{
+			name: "listeners and address",
+			http: func(config *configuration.Configuration) {
+				config.HTTP.Addr = ":5000"
+				config.HTTP.Listeners = []configuration.Listener{{Addr: ":5001"}}
+			},
+			expectedError: "1 error occurred:\n\t* 'http.listeners' can not be used along with 'http.addr', 'http.net' or 'http.tls'\n\n",
+		},
+		{
+			name: "listeners and tls",
+			http: func(config *configuration.Configuration) {
+				config.HTTP.TLS.Certificate = "/foo/cert.pem"
+				config.HTTP.Listeners = []configuration.Listener{{Addr: ":5001"}}
+			},
+			expectedError: "1 error occurred:\n\t* 'http.listeners' can not be used along with 'http.addr', 'http.net' or 'http.tls'\n\n",
+		},
+		{
+			name: "listener without address",
+			http: func(config *configuration.Configuration) {
+				config.HTTP.Listeners = []configuration.Listener{{Addr: ":5001"}, {Net: "tcp6"}}
+			},
+			expectedError: "1 error occurred:\n\t* 'http.listeners[1].addr' is required\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Storage: map[string]configuration.Parameters{},
+			}
+			tt.http(cfg)
+
+			if tt.expectedError != "" {
+				require.EqualError(t, validate(cfg), tt.expectedError)
+			} else {
+				require.NoError(t, validate(cfg))
+			}
+		})
+	}
+}
+
+func Test_validate_grpc(t *testing.T) {
+	tests := []struct {
+		name          string
+		grpc          func(config *configuration.Configuration)
+		expectedError string
+	}{
+		{
+			name: "disabled",
+			grpc: func(config *configuration.Configuration) {},
+		},
+		{
+			name: "mtls",
+			grpc: func(config *configuration.Configuration) {
+				config.GRPC.Addr = ":5002"
+				config.GRPC.TLS.Certificate = "/foo/cert.pem"
+				config.GRPC.TLS.ClientCAs = []string{"/foo/ca.pem"}
+			},
+		},
+		{
+			name: "jwt",
+			grpc: func(config *configuration.Configuration) {
+				config.GRPC.Addr = ":5002"
+				config.Auth = configuration.Auth{"token": configuration.Parameters{}}
+			},
+		},
+		{
+			name: "no authentication",
+			grpc: func(config *configuration.Configuration) {
+				config.GRPC.Addr = ":5002"
+			},
+			expectedError: "1 error occurred:\n\t* 'grpc' requires either 'grpc.tls.clientcas' or an 'auth' section\n\n",
+		},
+		{
+			name: "client CAs without certificate",
+			grpc: func(config *configuration.Configuration) {
+				config.GRPC.Addr = ":5002"
+				config.GRPC.TLS.ClientCAs = []string{"/foo/ca.pem"}
+			},
+			expectedError: "1 error occurred:\n\t* 'grpc.tls.clientcas' requires 'grpc.tls.certificate'\n\n",
+		},
+		{
+			name: "database disabled",
+			grpc: func(config *configuration.Configuration) {
+				config.Database.Enabled = false
+				config.GRPC.Addr = ":5002"
+				config.Auth = configuration.Auth{"token": configuration.Parameters{}}
+			},
+			expectedError: "1 error occurred:\n\t* 'grpc' requires the metadata database to be enabled\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Storage: map[string]configuration.Parameters{},
+			}
+			cfg.Database.Enabled = true
+			tt.grpc(cfg)
+
+			if tt.expectedError != "" {
+				require.EqualError(t, validate(cfg), tt.expectedError)
+			} else {
+				require.NoError(t, validate(cfg))
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/reference"
+)
+
+// replicateManifest pushes manifest m to the remote repository, optionally tagged, after pushing everything it
+// references. The child manifests of manifest lists are replicated recursively, while for other manifests, the
+// referenced blobs are copied. Content that already exists downstream is skipped.
+func (t *target) replicateManifest(ctx context.Context, remote distribution.Repository, repo reference.Named, m distribution.Manifest, tag string) error {
+	ms, err := remote.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := m.(*manifestlist.DeserializedManifestList); ok {
+		for _, desc := range m.References() {
+			exists, err := ms.Exists(ctx, desc.Digest)
+			if err != nil {
+				return fmt.Errorf("checking downstream manifest %s: %w", desc.Digest, err)
+			}
+			if exists {
+				continue
+			}
+			child, err := t.source.Manifest(ctx, repo, desc.Digest)
+			if err != nil {
+				return fmt.Errorf("reading manifest %s: %w", desc.Digest, err)
+			}
+			if err := t.replicateManifest(ctx, remote, repo, child, ""); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, desc := range m.References() {
+			if err := t.replicateBlob(ctx, remote, repo, desc); err != nil {
+				return err
+			}
+		}
+	}
+
+	var opts []distribution.ManifestServiceOption
+	if tag != "" {
+		opts = append(opts, distribution.WithTag(tag))
+	}
+	if _, err := ms.Put(ctx, m, opts...); err != nil {
+		return fmt.Errorf("pushing manifest: %w", err)
+	}
+
+	return nil
+}
+
+// replicateBlob copies a blob to the remote repository, unless it already exists there.
+func (t *target) replicateBlob(ctx context.Context, remote distribution.Repository, repo reference.Named, desc distribution.Descriptor) error {
+	bs := remote.Blobs(ctx)
+
+	_, err := bs.Stat(ctx, desc.Digest)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, distribution.ErrBlobUnknown) {
+		return fmt.Errorf("checking downstream blob %s: %w", desc.Digest, err)
+	}
+
+	rc, err := t.source.Blob(ctx, repo, desc.Digest)
+	if err != nil {
+		return fmt.Errorf("reading blob %s: %w", desc.Digest, err)
+	}
+	defer rc.Close()
+
+	bw, err := bs.Create(ctx)
+	if err != nil {
+		return fmt.Errorf("starting downstream blob upload %s: %w", desc.Digest, err)
+	}
+	if _, err := bw.ReadFrom(rc); err != nil {
+		return fmt.Errorf("uploading blob %s: %w", desc.Digest, err)
+	}
+	if _, err := bw.Commit(ctx, desc); err != nil {
+		return fmt.Errorf("committing blob %s: %w", desc.Digest, err)
+	}
+
+	return nil
+}
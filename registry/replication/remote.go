@@ -0,0 +1,76 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/docker/distribution/registry/client/transport"
+)
+
+// staticCredentials is an auth.CredentialStore holding the fixed credentials of a replication target.
+type staticCredentials struct {
+	username, password string
+}
+
+// Basic implements auth.CredentialStore.
+func (c staticCredentials) Basic(*url.URL) (string, string) {
+	return c.username, c.password
+}
+
+// RefreshToken implements auth.CredentialStore.
+func (staticCredentials) RefreshToken(*url.URL, string) string {
+	return ""
+}
+
+// SetRefreshToken implements auth.CredentialStore.
+func (staticCredentials) SetRefreshToken(*url.URL, string, string) {}
+
+// contextTransport binds outgoing requests to a context, as the registry client does not propagate contexts, so that
+// in-flight requests are aborted when a replication attempt times out or the replicator is closed.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// remoteRepository returns a client for the repository with the given name on the downstream registry, authorized to
+// pull and push to it. The downstream registry is pinged first to find out which authentication schemes it supports.
+func (t *target) remoteRepository(ctx context.Context, repo reference.Named) (distribution.Repository, error) {
+	base := &contextTransport{ctx: ctx, base: t.transport}
+
+	pingURL := strings.TrimSuffix(t.url, "/") + "/v2/"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := (&http.Client{Transport: base}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("pinging downstream registry: %w", err)
+	}
+	resp.Body.Close()
+
+	cm := challenge.NewSimpleManager()
+	if err := cm.AddResponse(resp); err != nil {
+		return nil, fmt.Errorf("parsing downstream registry challenges: %w", err)
+	}
+
+	creds := staticCredentials{username: t.username, password: t.password}
+	authorizer := auth.NewAuthorizer(cm,
+		auth.NewTokenHandler(base, creds, repo.Name(), "pull", "push"),
+		auth.NewBasicHandler(creds),
+	)
+
+	return client.NewRepository(repo, t.url, transport.NewTransport(base, authorizer))
+}
@@ -0,0 +1,382 @@
+// Package replication mirrors images pushed to the registry to one or more downstream registries. It subscribes to the
+// registry push events and, for each configured target, copies the pushed manifest and all the blobs and manifests it
+// references, skipping those already present downstream.
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	gopath "path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	componentKey   = "component"
+	replicatorName = "registry.replication.Replicator"
+)
+
+// ErrQueueFull is returned by Write when the queue of a target is full. The event is dropped for that target.
+var ErrQueueFull = errors.New("replication queue is full")
+
+// Source provides read access to the images stored in the registry.
+type Source interface {
+	// Manifest returns the manifest with the given digest in the given repository.
+	Manifest(ctx context.Context, repo reference.Named, dgst digest.Digest) (distribution.Manifest, error)
+	// Blob opens the blob with the given digest, referenced by a manifest in the given repository.
+	Blob(ctx context.Context, repo reference.Named, dgst digest.Digest) (io.ReadCloser, error)
+}
+
+// Status describes the replication state of a target in this registry instance.
+type Status struct {
+	Name             string
+	URL              string
+	Pending          int
+	Replicated       int64
+	Failed           int64
+	Dropped          int64
+	LastReplicatedAt time.Time
+	LastFailedAt     time.Time
+	LastError        string
+}
+
+// job is a request to replicate a manifest, optionally tagging it downstream.
+type job struct {
+	repo   reference.Named
+	digest digest.Digest
+	tag    string
+}
+
+// target replicates images to a single downstream registry using a bounded queue and a fixed pool of workers.
+type target struct {
+	name         string
+	url          string
+	username     string
+	password     string
+	repositories []string
+	timeout      time.Duration
+	threshold    int
+	backoff      time.Duration
+	workers      int
+
+	source    Source
+	transport http.RoundTripper
+	logger    log.Logger
+
+	jobs chan *job
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Replicator is a notifications.Sink that replicates pushed manifests to the configured downstream registries.
+type Replicator struct {
+	targets []*target
+	logger  log.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// Option provides functional options for New.
+type Option func(*Replicator, *options)
+
+type options struct {
+	transport http.RoundTripper
+}
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) Option {
+	return func(r *Replicator, _ *options) {
+		r.logger = l
+	}
+}
+
+// WithTransport sets the base HTTP transport used to reach downstream registries. Defaults to
+// http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(_ *Replicator, o *options) {
+		o.transport = rt
+	}
+}
+
+// New creates a Replicator for the enabled targets in config, and starts their workers. Returns nil if there are no
+// enabled targets.
+func New(source Source, config configuration.Replication, opts ...Option) (*Replicator, error) {
+	o := &options{transport: http.DefaultTransport}
+	r := &Replicator{}
+	for _, opt := range opts {
+		opt(r, o)
+	}
+	if r.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		r.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	r.logger = r.logger.WithFields(log.Fields{componentKey: replicatorName})
+
+	names := make(map[string]struct{}, len(config.Targets))
+	for i, tc := range config.Targets {
+		if err := validateTarget(tc); err != nil {
+			return nil, fmt.Errorf("replication target %d: %w", i, err)
+		}
+		if _, ok := names[tc.Name]; ok {
+			return nil, fmt.Errorf("replication target %d: duplicate name %q", i, tc.Name)
+		}
+		names[tc.Name] = struct{}{}
+
+		if tc.Disabled {
+			r.logger.WithFields(log.Fields{"target": tc.Name}).Info("replication target disabled, skipping")
+			continue
+		}
+
+		r.targets = append(r.targets, &target{
+			name:         tc.Name,
+			url:          tc.URL,
+			username:     tc.Username,
+			password:     tc.Password,
+			repositories: tc.Repositories,
+			timeout:      tc.Timeout,
+			threshold:    tc.Threshold,
+			backoff:      tc.Backoff,
+			workers:      tc.Workers,
+			source:       source,
+			transport:    o.transport,
+			logger:       r.logger.WithFields(log.Fields{"target": tc.Name}),
+			jobs:         make(chan *job, tc.QueueSize),
+			status:       Status{Name: tc.Name, URL: tc.URL},
+		})
+	}
+	if len(r.targets) == 0 {
+		return nil, nil
+	}
+
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	for _, t := range r.targets {
+		for w := 0; w < t.workers; w++ {
+			t.wg.Add(1)
+			go t.run(r.ctx)
+		}
+	}
+
+	return r, nil
+}
+
+func validateTarget(tc configuration.ReplicationTarget) error {
+	if tc.Name == "" {
+		return errors.New("name must be set")
+	}
+	if tc.Threshold <= 0 || tc.Workers <= 0 || tc.QueueSize <= 0 || tc.Timeout <= 0 {
+		return errors.New("threshold, workers, queuesize and timeout must be positive")
+	}
+	u, err := url.Parse(tc.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q, must be in the form http[s]://host[:port]", tc.URL)
+	}
+	for _, p := range tc.Repositories {
+		if _, err := gopath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid repository pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// Write implements notifications.Sink. Manifest push events are queued for replication to each target covering the
+// event repository. Other events are ignored. Write never blocks: if the queue of a target is full, the event is
+// dropped for that target and ErrQueueFull is returned.
+func (r *Replicator) Write(event *notifications.Event) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return notifications.ErrSinkClosed
+	}
+	if !isManifestPush(event) {
+		return nil
+	}
+
+	repo, err := reference.WithName(event.Target.Repository)
+	if err != nil {
+		return fmt.Errorf("parsing event repository: %w", err)
+	}
+	j := &job{repo: repo, digest: event.Target.Digest, tag: event.Target.Tag}
+
+	var dropped bool
+	for _, t := range r.targets {
+		if !t.covers(repo.Name()) {
+			continue
+		}
+		select {
+		case t.jobs <- j:
+		default:
+			dropped = true
+			t.mu.Lock()
+			t.status.Dropped++
+			t.mu.Unlock()
+			t.logger.WithFields(log.Fields{
+				"repository": repo.Name(),
+				"digest":     j.digest,
+				"tag_name":   j.tag,
+			}).Warn("replication queue is full, dropping event")
+		}
+	}
+	if dropped {
+		return ErrQueueFull
+	}
+
+	return nil
+}
+
+// Close implements notifications.Sink. It stops accepting events and aborts in-flight replications. Queued events
+// are discarded.
+func (r *Replicator) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return errors.New("replicator: already closed")
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	r.cancel()
+	for _, t := range r.targets {
+		t.wg.Wait()
+	}
+
+	return nil
+}
+
+func (r *Replicator) String() string {
+	return fmt.Sprintf("replicator{targets: %d}", len(r.targets))
+}
+
+// Status returns the replication status of each target, sorted by name.
+func (r *Replicator) Status() []Status {
+	ss := make([]Status, 0, len(r.targets))
+	for _, t := range r.targets {
+		t.mu.Lock()
+		s := t.status
+		t.mu.Unlock()
+		s.Pending = len(t.jobs)
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Name < ss[j].Name })
+
+	return ss
+}
+
+// isManifestPush returns whether the event describes the push of a manifest, as opposed to other actions or blobs.
+func isManifestPush(event *notifications.Event) bool {
+	if event.Action != notifications.EventActionPush || event.Target.Digest == "" {
+		return false
+	}
+	for _, mt := range distribution.ManifestMediaTypes() {
+		if event.Target.MediaType == mt {
+			return true
+		}
+	}
+	return false
+}
+
+// covers returns whether images pushed to the repository with the given path are replicated to this target.
+func (t *target) covers(path string) bool {
+	if len(t.repositories) == 0 {
+		return true
+	}
+	for _, p := range t.repositories {
+		if ok, err := gopath.Match(p, path); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// run processes queued jobs until the context is canceled.
+func (t *target) run(ctx context.Context) {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-t.jobs:
+			t.process(ctx, j)
+		}
+	}
+}
+
+// process replicates a job, retrying up to the configured threshold, and records the outcome.
+func (t *target) process(ctx context.Context, j *job) {
+	l := t.logger.WithFields(log.Fields{"repository": j.repo.Name(), "digest": j.digest, "tag_name": j.tag})
+
+	start := time.Now()
+	var err error
+	for attempt := 1; attempt <= t.threshold; attempt++ {
+		if err = t.attempt(ctx, j); err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		l.WithError(err).WithFields(log.Fields{"attempt": attempt}).Warn("replication attempt failed")
+		if attempt == t.threshold {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(t.backoff):
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		t.status.Failed++
+		t.status.LastFailedAt = time.Now()
+		t.status.LastError = err.Error()
+		l.WithError(err).Error("replication failed")
+		return
+	}
+	t.status.Replicated++
+	t.status.LastReplicatedAt = time.Now()
+	l.WithFields(log.Fields{"duration_s": time.Since(start).Seconds()}).Info("image replicated")
+}
+
+// attempt makes a single attempt at replicating a job, bounded by the configured timeout.
+func (t *target) attempt(ctx context.Context, j *job) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	remote, err := t.remoteRepository(ctx, j.repo)
+	if err != nil {
+		return err
+	}
+	m, err := t.source.Manifest(ctx, j.repo, j.digest)
+	if err != nil {
+		return fmt.Errorf("reading manifest: %w", err)
+	}
+
+	return t.replicateManifest(ctx, remote, j.repo, m, j.tag)
+}
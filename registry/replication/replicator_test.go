@@ -0,0 +1,444 @@
+package replication_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/replication"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource is a replication.Source backed by in-memory manifests and blobs.
+type fakeSource struct {
+	manifests map[digest.Digest]distribution.Manifest
+	blobs     map[digest.Digest][]byte
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{
+		manifests: make(map[digest.Digest]distribution.Manifest),
+		blobs:     make(map[digest.Digest][]byte),
+	}
+}
+
+func (s *fakeSource) Manifest(_ context.Context, _ reference.Named, dgst digest.Digest) (distribution.Manifest, error) {
+	m, ok := s.manifests[dgst]
+	if !ok {
+		return nil, distribution.ErrManifestUnknownRevision{Revision: dgst}
+	}
+	return m, nil
+}
+
+func (s *fakeSource) Blob(_ context.Context, _ reference.Named, dgst digest.Digest) (io.ReadCloser, error) {
+	b, ok := s.blobs[dgst]
+	if !ok {
+		return nil, distribution.ErrBlobUnknown
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (s *fakeSource) addBlob(p []byte) distribution.Descriptor {
+	d := digest.FromBytes(p)
+	s.blobs[d] = p
+	return distribution.Descriptor{MediaType: schema2.MediaTypeLayer, Digest: d, Size: int64(len(p))}
+}
+
+func (s *fakeSource) addManifest(t *testing.T, m distribution.Manifest) distribution.Descriptor {
+	t.Helper()
+
+	mt, p, err := m.Payload()
+	require.NoError(t, err)
+	d := digest.FromBytes(p)
+	s.manifests[d] = m
+	return distribution.Descriptor{MediaType: mt, Digest: d, Size: int64(len(p))}
+}
+
+func (s *fakeSource) addImage(t *testing.T, layer string) distribution.Descriptor {
+	t.Helper()
+
+	cfg := s.addBlob([]byte(`{"config":{}}`))
+	cfg.MediaType = schema2.MediaTypeImageConfig
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    cfg,
+		Layers:    []distribution.Descriptor{s.addBlob([]byte(layer))},
+	})
+	require.NoError(t, err)
+	return s.addManifest(t, m)
+}
+
+var (
+	uploadPath = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/(.*)$`)
+	blobPath   = regexp.MustCompile(`^/v2/(.+)/blobs/(.+)$`)
+	manPath    = regexp.MustCompile(`^/v2/(.+)/manifests/(.+)$`)
+)
+
+// fakeRegistry is a minimal downstream registry that accepts blob uploads and manifest pushes.
+type fakeRegistry struct {
+	*httptest.Server
+
+	username, password string
+	// failManifestPuts is the number of manifest pushes to fail before accepting them.
+	failManifestPuts int
+
+	mu        sync.Mutex
+	uploads   map[string][]byte
+	blobs     map[digest.Digest][]byte
+	manifests map[string][]byte
+	uploaded  int
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	t.Helper()
+
+	r := &fakeRegistry{
+		uploads:   make(map[string][]byte),
+		blobs:     make(map[digest.Digest][]byte),
+		manifests: make(map[string][]byte),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *fakeRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.username != "" {
+		if u, p, ok := req.BasicAuth(); !ok || u != r.username || p != r.password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/v2/" {
+		return
+	}
+	if m := uploadPath.FindStringSubmatch(req.URL.Path); m != nil {
+		r.serveUpload(w, req, m[1], m[2])
+		return
+	}
+	if m := blobPath.FindStringSubmatch(req.URL.Path); m != nil {
+		b, ok := r.blobs[digest.Digest(m[2])]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(b)))
+		return
+	}
+	if m := manPath.FindStringSubmatch(req.URL.Path); m != nil {
+		key := m[1] + ":" + m[2]
+		switch req.Method {
+		case http.MethodHead:
+			if _, ok := r.manifests[key]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			if r.failManifestPuts > 0 {
+				r.failManifestPuts--
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			p, _ := io.ReadAll(req.Body)
+			r.manifests[key] = p
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(p).String())
+			w.WriteHeader(http.StatusCreated)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (r *fakeRegistry) serveUpload(w http.ResponseWriter, req *http.Request, name, id string) {
+	switch req.Method {
+	case http.MethodPost:
+		id = fmt.Sprint(len(r.uploads) + 1)
+		r.uploads[id] = nil
+		w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPatch:
+		p, _ := io.ReadAll(req.Body)
+		r.uploads[id] = append(r.uploads[id], p...)
+		w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+		w.Header().Set("Range", fmt.Sprintf("0-%d", len(r.uploads[id])-1))
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		d := digest.Digest(req.URL.Query().Get("digest"))
+		if digest.FromBytes(r.uploads[id]) != d {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[d] = r.uploads[id]
+		r.uploaded++
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (r *fakeRegistry) manifest(ref string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.manifests[ref]
+	return p, ok
+}
+
+func (r *fakeRegistry) uploadCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.uploaded
+}
+
+func targetConfig(name, url string) configuration.ReplicationTarget {
+	return configuration.ReplicationTarget{
+		Name:      name,
+		URL:       url,
+		Timeout:   5 * time.Second,
+		Threshold: 1,
+		Backoff:   10 * time.Millisecond,
+		Workers:   1,
+		QueueSize: 10,
+	}
+}
+
+func newReplicator(t *testing.T, source replication.Source, targets ...configuration.ReplicationTarget) *replication.Replicator {
+	t.Helper()
+
+	r, err := replication.New(source, configuration.Replication{Targets: targets})
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	t.Cleanup(func() { _ = r.Close() })
+
+	return r
+}
+
+func pushEvent(repo string, desc distribution.Descriptor, tag string) *notifications.Event {
+	return &notifications.Event{
+		Action: notifications.EventActionPush,
+		Target: notifications.Target{Descriptor: desc, Repository: repo, Tag: tag},
+	}
+}
+
+func waitForStatus(t *testing.T, r *replication.Replicator, done func(replication.Status) bool) replication.Status {
+	t.Helper()
+
+	var s replication.Status
+	require.Eventually(t, func() bool {
+		s = r.Status()[0]
+		return done(s)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return s
+}
+
+func replicated(n int64) func(replication.Status) bool {
+	return func(s replication.Status) bool { return s.Replicated == n }
+}
+
+func TestReplicator_Manifest(t *testing.T) {
+	source := newFakeSource()
+	desc := source.addImage(t, "layer")
+	downstream := newFakeRegistry(t)
+
+	r := newReplicator(t, source, targetConfig("mirror", downstream.URL))
+	require.NoError(t, r.Write(pushEvent("foo/bar", desc, "latest")))
+
+	s := waitForStatus(t, r, replicated(1))
+	require.Zero(t, s.Failed)
+	require.False(t, s.LastReplicatedAt.IsZero())
+
+	_, p, err := source.manifests[desc.Digest].Payload()
+	require.NoError(t, err)
+	got, ok := downstream.manifest("foo/bar:latest")
+	require.True(t, ok)
+	require.Equal(t, p, got)
+	require.Equal(t, 2, downstream.uploadCount())
+
+	// blobs already present downstream are not uploaded again
+	require.NoError(t, r.Write(pushEvent("foo/bar", desc, "v1")))
+	waitForStatus(t, r, replicated(2))
+	require.Equal(t, 2, downstream.uploadCount())
+}
+
+func TestReplicator_ManifestList(t *testing.T) {
+	source := newFakeSource()
+	amd64 := source.addImage(t, "amd64")
+	arm64 := source.addImage(t, "arm64")
+	ml, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+		{Descriptor: amd64, Platform: manifestlist.PlatformSpec{Architecture: "amd64", OS: "linux"}},
+		{Descriptor: arm64, Platform: manifestlist.PlatformSpec{Architecture: "arm64", OS: "linux"}},
+	})
+	require.NoError(t, err)
+	desc := source.addManifest(t, ml)
+	downstream := newFakeRegistry(t)
+
+	r := newReplicator(t, source, targetConfig("mirror", downstream.URL))
+	require.NoError(t, r.Write(pushEvent("foo/bar", desc, "latest")))
+	waitForStatus(t, r, replicated(1))
+
+	_, ok := downstream.manifest("foo/bar:latest")
+	require.True(t, ok)
+	_, ok = downstream.manifest("foo/bar:" + amd64.Digest.String())
+	require.True(t, ok)
+	_, ok = downstream.manifest("foo/bar:" + arm64.Digest.String())
+	require.True(t, ok)
+	// the config blob is shared by both images
+	require.Equal(t, 3, downstream.uploadCount())
+}
+
+func TestReplicator_BasicAuth(t *testing.T) {
+	source := newFakeSource()
+	desc := source.addImage(t, "layer")
+	downstream := newFakeRegistry(t)
+	downstream.username, downstream.password = "replicator", "secret"
+
+	cfg := targetConfig("mirror", downstream.URL)
+	cfg.Username, cfg.Password = "replicator", "secret"
+
+	r := newReplicator(t, source, cfg)
+	require.NoError(t, r.Write(pushEvent("foo/bar", desc, "latest")))
+	waitForStatus(t, r, replicated(1))
+
+	_, ok := downstream.manifest("foo/bar:latest")
+	require.True(t, ok)
+}
+
+func TestReplicator_Retries(t *testing.T) {
+	source := newFakeSource()
+	desc := source.addImage(t, "layer")
+	downstream := newFakeRegistry(t)
+	downstream.failManifestPuts = 2
+
+	cfg := targetConfig("mirror", downstream.URL)
+	cfg.Threshold = 3
+
+	r := newReplicator(t, source, cfg)
+	require.NoError(t, r.Write(pushEvent("foo/bar", desc, "latest")))
+	s := waitForStatus(t, r, replicated(1))
+	require.Zero(t, s.Failed)
+}
+
+func TestReplicator_Failure(t *testing.T) {
+	source := newFakeSource()
+	desc := source.addImage(t, "layer")
+	downstream := newFakeRegistry(t)
+	downstream.failManifestPuts = 2
+
+	cfg := targetConfig("mirror", downstream.URL)
+	cfg.Threshold = 2
+
+	r := newReplicator(t, source, cfg)
+	require.NoError(t, r.Write(pushEvent("foo/bar", desc, "latest")))
+	s := waitForStatus(t, r, func(s replication.Status) bool { return s.Failed == 1 })
+	require.Zero(t, s.Replicated)
+	require.False(t, s.LastFailedAt.IsZero())
+	require.Contains(t, s.LastError, "pushing manifest")
+
+	_, ok := downstream.manifest("foo/bar:latest")
+	require.False(t, ok)
+}
+
+func TestReplicator_Repositories(t *testing.T) {
+	source := newFakeSource()
+	desc := source.addImage(t, "layer")
+	downstream := newFakeRegistry(t)
+
+	cfg := targetConfig("mirror", downstream.URL)
+	cfg.Repositories = []string{"foo/*"}
+
+	r := newReplicator(t, source, cfg)
+	require.NoError(t, r.Write(pushEvent("bar/foo", desc, "latest")))
+	require.NoError(t, r.Write(pushEvent("foo/bar", desc, "latest")))
+	waitForStatus(t, r, replicated(1))
+
+	_, ok := downstream.manifest("bar/foo:latest")
+	require.False(t, ok)
+	_, ok = downstream.manifest("foo/bar:latest")
+	require.True(t, ok)
+}
+
+func TestReplicator_IgnoresOtherEvents(t *testing.T) {
+	source := newFakeSource()
+	desc := source.addImage(t, "layer")
+	downstream := newFakeRegistry(t)
+
+	r := newReplicator(t, source, targetConfig("mirror", downstream.URL))
+
+	pull := pushEvent("foo/bar", desc, "latest")
+	pull.Action = notifications.EventActionPull
+	require.NoError(t, r.Write(pull))
+	blob := pushEvent("foo/bar", distribution.Descriptor{MediaType: schema2.MediaTypeLayer, Digest: desc.Digest}, "")
+	require.NoError(t, r.Write(blob))
+
+	// events are processed in order, so once this one is replicated the previous ones were skipped
+	require.NoError(t, r.Write(pushEvent("foo/bar", desc, "v1")))
+	s := waitForStatus(t, r, replicated(1))
+	require.Zero(t, s.Failed)
+
+	_, ok := downstream.manifest("foo/bar:latest")
+	require.False(t, ok)
+}
+
+func TestReplicator_Close(t *testing.T) {
+	r, err := replication.New(newFakeSource(), configuration.Replication{
+		Targets: []configuration.ReplicationTarget{targetConfig("mirror", "https://mirror.example.com")},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, r.Close())
+	require.ErrorIs(t, r.Write(pushEvent("foo/bar", distribution.Descriptor{}, "")), notifications.ErrSinkClosed)
+	require.Error(t, r.Close())
+}
+
+func TestNew(t *testing.T) {
+	valid := targetConfig("mirror", "https://mirror.example.com")
+	disabled := targetConfig("disabled", "https://mirror.example.org")
+	disabled.Disabled = true
+
+	r, err := replication.New(newFakeSource(), configuration.Replication{})
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	r, err = replication.New(newFakeSource(), configuration.Replication{Targets: []configuration.ReplicationTarget{disabled}})
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	invalid := func(f func(*configuration.ReplicationTarget)) configuration.ReplicationTarget {
+		tc := valid
+		f(&tc)
+		return tc
+	}
+	tcs := map[string][]configuration.ReplicationTarget{
+		"no name":         {invalid(func(tc *configuration.ReplicationTarget) { tc.Name = "" })},
+		"invalid url":     {invalid(func(tc *configuration.ReplicationTarget) { tc.URL = "mirror.example.com" })},
+		"invalid pattern": {invalid(func(tc *configuration.ReplicationTarget) { tc.Repositories = []string{"["} })},
+		"no workers":      {invalid(func(tc *configuration.ReplicationTarget) { tc.Workers = 0 })},
+		"duplicate name":  {valid, valid},
+	}
+	for name, targets := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := replication.New(newFakeSource(), configuration.Replication{Targets: targets})
+			require.Error(t, err)
+		})
+	}
+}
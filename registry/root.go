@@ -1,16 +1,20 @@
 package registry
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jszwec/csvutil"
@@ -18,12 +22,17 @@ import (
 	"github.com/docker/distribution/configuration"
 	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/dump"
 	"github.com/docker/distribution/registry/datastore/migrations"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/gc/worker"
 	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/factory"
 	"github.com/docker/distribution/registry/storage/inventory"
 	"github.com/docker/distribution/version"
 	"github.com/docker/libtrust"
+	"github.com/hashicorp/go-multierror"
 	"github.com/olekukonko/tablewriter"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -43,15 +52,20 @@ func init() {
 	MigrateCmd.AddCommand(MigrateVersionCmd)
 	MigrateStatusCmd.Flags().BoolVarP(&upToDateCheck, "up-to-date", "u", false, "check if all known migrations are applied")
 	MigrateStatusCmd.Flags().BoolVarP(&skipPostDeployment, "skip-post-deployment", "s", false, "ignore post deployment migrations")
+	MigrateStatusCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output the status in JSON format")
 	MigrateCmd.AddCommand(MigrateStatusCmd)
 	MigrateUpCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "do not commit changes to the database")
 	MigrateUpCmd.Flags().VarP(nullableInt{&maxNumMigrations}, "limit", "n", "limit the number of migrations (all by default)")
 	MigrateUpCmd.Flags().BoolVarP(&skipPostDeployment, "skip-post-deployment", "s", false, "do not apply post deployment migrations")
+	MigrateUpCmd.Flags().BoolVarP(&skipPreflight, "skip-preflight", "p", false, "do not check for long-running transactions locking the tables to migrate")
+	MigrateUpCmd.Flags().DurationVarP(&preflightThreshold, "preflight-threshold", "t", time.Minute, "minimum age of the transactions reported by the pre-flight check")
 	MigrateCmd.AddCommand(MigrateUpCmd)
 	MigrateDownCmd.Flags().BoolVarP(&force, "force", "f", false, "no confirmation message")
 	MigrateDownCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "do not commit changes to the database")
 	MigrateDownCmd.Flags().VarP(nullableInt{&maxNumMigrations}, "limit", "n", "limit the number of migrations (all by default)")
 	MigrateCmd.AddCommand(MigrateDownCmd)
+	MigrateBackgroundCmd.Flags().StringVarP(&retryBackgroundMigration, "retry", "r", "", "reactivate the failed background migration with the given name")
+	MigrateCmd.AddCommand(MigrateBackgroundCmd)
 	DBCmd.AddCommand(MigrateCmd)
 
 	DBCmd.AddCommand(ImportCmd)
@@ -64,6 +78,22 @@ func init() {
 	ImportCmd.Flags().BoolVarP(&importAllRepos, "step-two", "2", false, "perform step two of a multi-step import: alias for `all-repositories`")
 	ImportCmd.Flags().BoolVarP(&importCommonBlobs, "common-blobs", "B", false, "import all blob metadata from common storage")
 	ImportCmd.Flags().BoolVarP(&importCommonBlobs, "step-three", "3", false, "perform step three of a multi-step import: alias for `common-blobs`")
+	ImportCmd.Flags().BoolVarP(&resumeImport, "resume", "R", false, "skip repositories already (pre)imported by a previous, interrupted run of the same step")
+	ImportCmd.Flags().BoolVarP(&validateOnly, "validate-only", "V", false, "report filesystem metadata inconsistencies in JSON format without writing to the database")
+	ImportCmd.Flags().BoolVarP(&syncImport, "sync", "s", false, "continuously mirror filesystem metadata changes into the database until interrupted")
+	ImportCmd.Flags().DurationVarP(&syncInterval, "sync-interval", "i", 5*time.Minute, "pause between sync passes")
+	ImportCmd.Flags().IntVarP(&repositoryConcurrency, "repository-concurrency", "w", 1, "number of repositories to (pre)import concurrently")
+
+	DBCmd.AddCommand(ExportMetadataCmd)
+	ExportMetadataCmd.Flags().StringVarP(&outputPath, "output", "o", "", "path of the file to write the dump to (stdout by default)")
+	DBCmd.AddCommand(ImportMetadataCmd)
+	ImportMetadataCmd.Flags().StringVarP(&inputPath, "input", "f", "", "path of the file to read the dump from (stdin by default)")
+	DBCmd.AddCommand(GCStatsCmd)
+	CheckMirroringCmd.Flags().IntVarP(&sampleSize, "sample", "n", 100, "number of randomly sampled repositories to check (0 checks all repositories)")
+	CheckMirroringCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output the report in JSON format")
+	DBCmd.AddCommand(CheckMirroringCmd)
+	GCStatsCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output the statistics in JSON format")
+	GCStatsCmd.Flags().DurationVarP(&statsPeriod, "period", "p", 24*time.Hour, "period over which to report the processed and failed reviews (up to 168h)")
 
 	InventoryCmd.Flags().StringVarP(&format, "format", "f", "text", "which format to write output to, text output produces an additional summary for convenience, options: text, json, csv")
 	InventoryCmd.Flags().BoolVarP(&countTags, "tag-count", "t", true, "count repository tags, set this to false to increase inventory speed")
@@ -71,22 +101,35 @@ func init() {
 
 // Command flag vars
 var (
-	requireEmptyDatabase bool
-	debugAddr            string
-	dryRun               bool
-	force                bool
-	maxNumMigrations     *int
-	removeUntagged       bool
-	showVersion          bool
-	skipPostDeployment   bool
-	upToDateCheck        bool
-	preImport            bool
-	format               string
-	countTags            bool
-	rowCount             bool
-	importCommonBlobs    bool
-	importAllRepos       bool
-	tagConcurrency       *int
+	requireEmptyDatabase     bool
+	debugAddr                string
+	dryRun                   bool
+	force                    bool
+	jsonOutput               bool
+	maxNumMigrations         *int
+	removeUntagged           bool
+	retryBackgroundMigration string
+	showVersion              bool
+	skipPostDeployment       bool
+	skipPreflight            bool
+	preflightThreshold       time.Duration
+	upToDateCheck            bool
+	preImport                bool
+	format                   string
+	countTags                bool
+	rowCount                 bool
+	importCommonBlobs        bool
+	importAllRepos           bool
+	tagConcurrency           *int
+	resumeImport             bool
+	repositoryConcurrency    int
+	validateOnly             bool
+	syncImport               bool
+	syncInterval             time.Duration
+	outputPath               string
+	inputPath                string
+	statsPeriod              time.Duration
+	sampleSize               int
 )
 
 var parallelwalkKey = "parallelwalk"
@@ -259,6 +302,18 @@ var MigrateUpCmd = &cobra.Command{
 			fmt.Println(strings.Join(plan, "\n"))
 		}
 
+		if !skipPreflight && len(plan) > 0 {
+			res, err := m.UpNPreflight(*maxNumMigrations, preflightThreshold)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to run pre-flight check: %v", err)
+				os.Exit(1)
+			}
+			if res.Blocked() {
+				printPreflightLocks(os.Stderr, res)
+				os.Exit(1)
+			}
+		}
+
 		if !dryRun {
 			start := time.Now()
 			n, err := m.UpN(*maxNumMigrations)
@@ -387,20 +442,29 @@ var MigrateStatusCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		if upToDateCheck {
-			upToDate := true
-			for _, s := range statuses {
-				if s.AppliedAt == nil {
-					if !s.PostDeployment || !skipPostDeployment {
-						upToDate = false
-						break
-					}
+		upToDate := true
+		for _, s := range statuses {
+			if s.AppliedAt == nil {
+				if !s.PostDeployment || !skipPostDeployment {
+					upToDate = false
+					break
 				}
 			}
+		}
+
+		if upToDateCheck {
 			fmt.Println(upToDate)
 			return
 		}
 
+		if jsonOutput {
+			if err := printMigrationStatusJSON(os.Stdout, m, statuses, upToDate); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to output database status: %v", err)
+				os.Exit(1)
+			}
+			return
+		}
+
 		table := tablewriter.NewWriter(os.Stdout)
 		table.SetHeader([]string{"Migration", "Applied"})
 		table.SetColWidth(80)
@@ -437,6 +501,221 @@ var MigrateStatusCmd = &cobra.Command{
 	},
 }
 
+// MigrateBackgroundCmd is the `background` sub-command of `database migrate` that shows the progress of background
+// migrations and allows retrying failed ones.
+var MigrateBackgroundCmd = &cobra.Command{
+	Use:   "background",
+	Short: "Show background migrations progress",
+	Long: "Show background migrations progress.\n" +
+		"Background migrations are enqueued by schema migrations and processed in batches by registry instances with " +
+		"background migrations enabled.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args, configuration.WithoutStorageValidation())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		db, err := dbFromConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		s := datastore.NewBackgroundMigrationStore(db)
+
+		if retryBackgroundMigration != "" {
+			m, err := s.FindByName(ctx, retryBackgroundMigration)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to find background migration: %v", err)
+				os.Exit(1)
+			}
+			if m == nil {
+				fmt.Fprintf(os.Stderr, "background migration %q not found\n", retryBackgroundMigration)
+				os.Exit(1)
+			}
+			if m.Status != models.BackgroundMigrationFailed {
+				fmt.Fprintf(os.Stderr, "background migration %q is %s, only failed migrations can be retried\n", m.Name, m.Status)
+				os.Exit(1)
+			}
+
+			m.Status = models.BackgroundMigrationActive
+			m.FailedAttempts = 0
+			if err := s.Update(ctx, m); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to retry background migration: %v", err)
+				os.Exit(1)
+			}
+			fmt.Printf("OK: background migration %q reactivated\n", m.Name)
+			return
+		}
+
+		mm, err := s.FindAll(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to find background migrations: %v", err)
+			os.Exit(1)
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Migration", "Job", "Status", "Progress", "Processed Rows", "Error"})
+		table.SetColWidth(80)
+		for _, m := range mm {
+			table.Append([]string{
+				m.Name,
+				m.JobName,
+				string(m.Status),
+				fmt.Sprintf("%.2f%%", m.Progress()*100),
+				strconv.FormatInt(m.ProcessedRows, 10),
+				m.ErrorMessage.String,
+			})
+		}
+		table.Render()
+	},
+}
+
+// migrationStatusJSON is the JSON representation of a single migration status.
+type migrationStatusJSON struct {
+	ID             string     `json:"id"`
+	PostDeployment bool       `json:"post_deployment"`
+	AppliedAt      *time.Time `json:"applied_at,omitempty"`
+}
+
+// migrateStatusJSON is the JSON output of `database migrate status --json`.
+type migrateStatusJSON struct {
+	CurrentVersion string                 `json:"current_version"`
+	LatestVersion  string                 `json:"latest_version"`
+	UpToDate       bool                   `json:"up_to_date"`
+	SchemaChecksum string                 `json:"schema_checksum"`
+	Applied        []*migrationStatusJSON `json:"applied"`
+	Pending        []*migrationStatusJSON `json:"pending"`
+	Unknown        []*migrationStatusJSON `json:"unknown"`
+}
+
+func printMigrationStatusJSON(w io.Writer, m migrationStatusReporter, statuses map[string]*migrations.MigrationStatus, upToDate bool) error {
+	current, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("detecting database version: %w", err)
+	}
+	latest, err := m.LatestVersion()
+	if err != nil {
+		return fmt.Errorf("detecting latest version: %w", err)
+	}
+	checksum, err := m.SchemaChecksum()
+	if err != nil {
+		return err
+	}
+
+	out := migrateStatusJSON{
+		CurrentVersion: current,
+		LatestVersion:  latest,
+		UpToDate:       upToDate,
+		SchemaChecksum: checksum,
+		Applied:        make([]*migrationStatusJSON, 0),
+		Pending:        make([]*migrationStatusJSON, 0),
+		Unknown:        make([]*migrationStatusJSON, 0),
+	}
+
+	var ids []string
+	for id := range statuses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		s := statuses[id]
+		if s.PostDeployment && skipPostDeployment {
+			continue
+		}
+
+		ms := &migrationStatusJSON{ID: id, PostDeployment: s.PostDeployment, AppliedAt: s.AppliedAt}
+		switch {
+		case s.Unknown:
+			out.Unknown = append(out.Unknown, ms)
+		case s.AppliedAt != nil:
+			out.Applied = append(out.Applied, ms)
+		default:
+			out.Pending = append(out.Pending, ms)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// migrationStatusReporter is the subset of the migrator used to build the JSON status output.
+type migrationStatusReporter interface {
+	Version() (string, error)
+	LatestVersion() (string, error)
+	SchemaChecksum() (string, error)
+}
+
+func printPreflightLocks(w io.Writer, res *migrations.PreflightResult) {
+	fmt.Fprintf(w, "pre-flight check failed: the pending migrations alter tables locked by transactions open for more than %s\n", preflightThreshold)
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"PID", "Table", "Lock Mode", "State", "Transaction Age", "Query"})
+	table.SetColWidth(80)
+	for _, l := range res.Locks {
+		table.Append([]string{
+			strconv.Itoa(l.PID),
+			l.Table,
+			l.Mode,
+			l.State,
+			l.Age.Round(time.Second).String(),
+			l.Query,
+		})
+	}
+	table.Render()
+
+	fmt.Fprintln(w, "Applying the migrations now would wait for these transactions to finish, blocking all other queries on "+
+		"the same tables in the meantime. Wait for them to complete or, if safe to do so, cancel them with "+
+		"`SELECT pg_cancel_backend(<pid>)` (or `SELECT pg_terminate_backend(<pid>)` for idle sessions) and try again. "+
+		"Use --skip-preflight to bypass this check.")
+}
+
+// orphanedUploadAge is the age after which incomplete blob uploads are reported as orphaned by the import validation.
+// This matches the default age of the upload purging maintenance task.
+const orphanedUploadAge = 168 * time.Hour
+
+const uploadRepositoriesDir = "/docker/registry/v2/repositories/"
+
+// addOrphanedUploads adds the blob uploads started before olderThan to the validation report.
+func addOrphanedUploads(ctx context.Context, d storagedriver.StorageDriver, report *datastore.ValidationReport, olderThan time.Time) error {
+	// this is a dry run of the upload purging, so nothing is deleted
+	uploads, errs := storage.PurgeUploads(ctx, d, olderThan, false)
+	if len(errs) > 0 {
+		return multierror.Append(nil, errs...)
+	}
+
+	for _, u := range uploads {
+		// upload paths have the form <root>/docker/registry/v2/repositories/<repository>/_uploads/<id>
+		var repo string
+		if i := strings.LastIndex(u, "/_uploads/"); i >= 0 {
+			if j := strings.Index(u[:i], uploadRepositoriesDir); j >= 0 {
+				repo = u[j+len(uploadRepositoriesDir) : i]
+			}
+		}
+		report.AddIssue(&datastore.ValidationIssue{
+			Type:       datastore.ValidationIssueOrphanedUpload,
+			Repository: repo,
+			Path:       u,
+			Detail:     fmt.Sprintf("upload started before %s", olderThan.UTC().Format(time.RFC3339)),
+		})
+	}
+	report.Sort()
+
+	return nil
+}
+
+func printValidationReport(w io.Writer, report *datastore.ValidationReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(report)
+}
+
 // ImportCmd is the `import` sub-command of `database` that imports metadata from the filesystem into the database.
 var ImportCmd = &cobra.Command{
 	Use:   "import",
@@ -471,6 +750,40 @@ var ImportCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		if repositoryConcurrency < 1 || repositoryConcurrency > 50 {
+			fmt.Fprint(os.Stderr, "repository-concurrency must be between 1 and 50\n")
+			os.Exit(1)
+		}
+
+		if repositoryConcurrency > 1 && dryRun {
+			fmt.Fprint(os.Stderr, "repository-concurrency can't be used with dry-run\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		if validateOnly && (preImport || importAllRepos || importCommonBlobs || dryRun || resumeImport || requireEmptyDatabase) {
+			fmt.Fprint(os.Stderr, "validate-only can't be used with step, dry-run, resume or require-empty-database flags\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		if syncImport && (preImport || importAllRepos || importCommonBlobs || dryRun || resumeImport || requireEmptyDatabase || validateOnly) {
+			fmt.Fprint(os.Stderr, "sync can't be used with step, dry-run, resume, require-empty-database or validate-only flags\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		if syncImport && syncInterval <= 0 {
+			fmt.Fprint(os.Stderr, "sync-interval must be greater than zero\n")
+			os.Exit(1)
+		}
+
+		if resumeImport && requireEmptyDatabase {
+			fmt.Fprint(os.Stderr, "resume can't be used with require-empty-database\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
 		parameters := config.Storage.Parameters()
 		if parameters[parallelwalkKey] == true {
 			parameters[parallelwalkKey] = false
@@ -536,9 +849,40 @@ var ImportCmd = &cobra.Command{
 		if tagConcurrency != nil {
 			opts = append(opts, datastore.WithTagConcurrency(*tagConcurrency))
 		}
+		if resumeImport {
+			opts = append(opts, datastore.WithResume)
+		}
+		opts = append(opts, datastore.WithRepositoryConcurrency(repositoryConcurrency))
 
 		p := datastore.NewImporter(db, registry, opts...)
 
+		if validateOnly {
+			report, err := p.Validate(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to validate metadata: %v", err)
+				os.Exit(1)
+			}
+			if err := addOrphanedUploads(ctx, driver, report, time.Now().Add(-orphanedUploadAge)); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to find orphaned uploads: %v", err)
+				os.Exit(1)
+			}
+			if err := printValidationReport(os.Stdout, report); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to print validation report: %v", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if syncImport {
+			sCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if err := p.Sync(sCtx, syncInterval); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to sync metadata: %v", err)
+				os.Exit(1)
+			}
+			return
+		}
+
 		switch {
 		case preImport:
 			err = p.PreImportAll(ctx)
@@ -557,6 +901,372 @@ var ImportCmd = &cobra.Command{
 	},
 }
 
+// ExportMetadataCmd is the `export-metadata` sub-command of `database` that exports the database metadata as a portable
+// dump.
+var ExportMetadataCmd = &cobra.Command{
+	Use:   "export-metadata",
+	Short: "Export database metadata as a portable dump",
+	Long: "Export all repositories, blob links, manifests and tags as a versioned, newline-delimited JSON dump.\n" +
+		"The dump is independent of the database engine and can be loaded with the 'import-metadata' command.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		db, err := dbFromConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+			os.Exit(1)
+		}
+
+		var w io.Writer = os.Stdout
+		if outputPath != "" {
+			f, err := os.Create(outputPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create output file: %v", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+		bw := bufio.NewWriter(w)
+
+		stats, err := dump.Export(dcontext.Background(), db, bw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export metadata: %v", err)
+			os.Exit(1)
+		}
+		if err := bw.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write dump: %v", err)
+			os.Exit(1)
+		}
+		printDumpStats(os.Stderr, "exported", stats)
+	},
+}
+
+// ImportMetadataCmd is the `import-metadata` sub-command of `database` that imports a dump created with the
+// `export-metadata` sub-command.
+var ImportMetadataCmd = &cobra.Command{
+	Use:   "import-metadata",
+	Short: "Import a metadata dump into the database",
+	Long: "Import a metadata dump created with the 'export-metadata' command into the database.\n" +
+		"Each repository is imported within its own transaction. Existing records are kept and tags are updated, so an " +
+		"interrupted import can be safely rerun.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		db, err := dbFromConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+			os.Exit(1)
+		}
+
+		m := migrations.NewMigrator(db.DB, migrations.SkipPostDeployment)
+		pending, err := m.HasPending()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to check database migrations status: %v", err)
+			os.Exit(1)
+		}
+		if pending {
+			fmt.Fprintf(os.Stderr, "there are pending database migrations, use the 'registry database migrate' CLI "+
+				"command to check and apply them")
+			os.Exit(1)
+		}
+
+		var r io.Reader = os.Stdin
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open input file: %v", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		stats, err := dump.Import(dcontext.Background(), db, bufio.NewReader(r))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to import metadata: %v", err)
+			os.Exit(1)
+		}
+		printDumpStats(os.Stderr, "imported", stats)
+	},
+}
+
+func printDumpStats(w io.Writer, action string, stats *dump.Stats) {
+	fmt.Fprintf(w, "%s %d repositories, %d blob links, %d manifests and %d tags\n",
+		action, stats.Repositories, stats.Blobs, stats.Manifests, stats.Tags)
+}
+
+// GCStatsCmd is the `gc-stats` sub-command of `database` that reports the online garbage collection health.
+var GCStatsCmd = &cobra.Command{
+	Use:   "gc-stats",
+	Short: "Show online garbage collection statistics",
+	Long: "Show online garbage collection statistics from the database.\n" +
+		"Reports the size of the review queues, the oldest pending review, and the number of reviews processed and " +
+		"failed per hour by all registry instances over the given period.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args, configuration.WithoutStorageValidation())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+		if statsPeriod < time.Hour || statsPeriod > worker.ReviewStatsRetention {
+			fmt.Fprintf(os.Stderr, "period must be between 1h and %s\n", worker.ReviewStatsRetention)
+			os.Exit(1)
+		}
+
+		db, err := dbFromConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		s := datastore.NewGCStatsStore(db)
+		now := time.Now()
+
+		queues, err := s.QueueStats(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to find review queue stats: %v", err)
+			os.Exit(1)
+		}
+		reviews, err := s.FindReviewStats(ctx, now.Add(-statsPeriod))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to find review stats: %v", err)
+			os.Exit(1)
+		}
+
+		stats := newGCStats(queues, reviews, now, statsPeriod)
+		if jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(stats); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to output stats: %v", err)
+				os.Exit(1)
+			}
+			return
+		}
+		printGCStats(os.Stdout, stats)
+	},
+}
+
+// gcHourlyStatsJSON is the JSON representation of the reviews of an online GC queue during an hour.
+type gcHourlyStatsJSON struct {
+	Hour      time.Time `json:"hour"`
+	Processed int64     `json:"processed"`
+	Dangling  int64     `json:"dangling"`
+	Failed    int64     `json:"failed"`
+}
+
+// gcQueueStatsJSON is the JSON representation of the statistics of an online GC review queue.
+type gcQueueStatsJSON struct {
+	Name                     string               `json:"name"`
+	Size                     int                  `json:"size"`
+	Pending                  int                  `json:"pending"`
+	OldestPendingReviewAfter *time.Time           `json:"oldest_pending_review_after,omitempty"`
+	OldestPendingAgeSeconds  float64              `json:"oldest_pending_age_seconds"`
+	Retried                  int                  `json:"retried"`
+	MaxReviewCount           int                  `json:"max_review_count"`
+	Processed                int64                `json:"processed"`
+	ProcessedPerHour         float64              `json:"processed_per_hour"`
+	Dangling                 int64                `json:"dangling"`
+	Failed                   int64                `json:"failed"`
+	Hourly                   []*gcHourlyStatsJSON `json:"hourly"`
+}
+
+// gcStatsJSON is the JSON output of `database gc-stats --json`.
+type gcStatsJSON struct {
+	GeneratedAt   time.Time           `json:"generated_at"`
+	PeriodSeconds float64             `json:"period_seconds"`
+	Queues        []*gcQueueStatsJSON `json:"queues"`
+}
+
+// newGCStats combines the state of the review queues with their hourly review stats over the given period.
+func newGCStats(queues []*models.GCQueueStats, reviews []*models.GCReviewStats, now time.Time, period time.Duration) *gcStatsJSON {
+	out := &gcStatsJSON{
+		GeneratedAt:   now.UTC(),
+		PeriodSeconds: period.Seconds(),
+		Queues:        make([]*gcQueueStatsJSON, 0, len(queues)),
+	}
+
+	byName := make(map[string]*gcQueueStatsJSON, len(queues))
+	for _, q := range queues {
+		qs := &gcQueueStatsJSON{
+			Name:           q.Queue,
+			Size:           q.Size,
+			Pending:        q.Pending,
+			Retried:        q.Retried,
+			MaxReviewCount: q.MaxReviewCount,
+			Hourly:         make([]*gcHourlyStatsJSON, 0),
+		}
+		if q.OldestPendingReviewAfter.Valid {
+			t := q.OldestPendingReviewAfter.Time.UTC()
+			qs.OldestPendingReviewAfter = &t
+			qs.OldestPendingAgeSeconds = now.Sub(t).Seconds()
+		}
+		out.Queues = append(out.Queues, qs)
+		byName[q.Queue] = qs
+	}
+
+	for _, r := range reviews {
+		qs, ok := byName[r.Queue]
+		if !ok {
+			continue
+		}
+		qs.Processed += r.Processed
+		qs.Dangling += r.Dangling
+		qs.Failed += r.Failed
+		qs.Hourly = append(qs.Hourly, &gcHourlyStatsJSON{
+			Hour:      r.Hour.UTC(),
+			Processed: r.Processed,
+			Dangling:  r.Dangling,
+			Failed:    r.Failed,
+		})
+	}
+	for _, qs := range out.Queues {
+		qs.ProcessedPerHour = float64(qs.Processed) / period.Hours()
+	}
+
+	return out
+}
+
+func printGCStats(w io.Writer, stats *gcStatsJSON) {
+	fmt.Fprintf(w, "Online GC statistics, reviews over the last %s\n", time.Duration(stats.PeriodSeconds*float64(time.Second)))
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Queue", "Size", "Pending", "Oldest Pending", "Retried", "Max Review Count", "Processed/h", "Dangling", "Failed"})
+	table.SetColWidth(80)
+	for _, qs := range stats.Queues {
+		var oldest string
+		if qs.OldestPendingReviewAfter != nil {
+			oldest = time.Duration(qs.OldestPendingAgeSeconds * float64(time.Second)).Round(time.Second).String()
+		}
+		table.Append([]string{
+			qs.Name,
+			strconv.Itoa(qs.Size),
+			strconv.Itoa(qs.Pending),
+			oldest,
+			strconv.Itoa(qs.Retried),
+			strconv.Itoa(qs.MaxReviewCount),
+			strconv.FormatFloat(qs.ProcessedPerHour, 'f', 2, 64),
+			strconv.FormatInt(qs.Dangling, 10),
+			strconv.FormatInt(qs.Failed, 10),
+		})
+	}
+	table.Render()
+}
+
+// CheckMirroringCmd is the `check-mirroring` sub-command of `database` that reports divergences between the filesystem
+// metadata and the database.
+var CheckMirroringCmd = &cobra.Command{
+	Use:   "check-mirroring",
+	Short: "Check the filesystem metadata and the database for divergences",
+	Long: "Compare the tags and manifests of a random sample of repositories in the filesystem metadata and the " +
+		"database, and report divergences by severity.\n" +
+		"Critical divergences, such as tags missing in the database or pointing to a different manifest, would change " +
+		"what clients pull once the filesystem metadata is no longer used. Warnings are divergences where the " +
+		"filesystem metadata lags behind the database.\n" +
+		"Exits with status 2 if critical divergences are found. Nothing is written to the database or the filesystem.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+		if sampleSize < 0 {
+			fmt.Fprint(os.Stderr, "sample must not be negative\n")
+			os.Exit(1)
+		}
+
+		driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct %s driver: %v", config.Storage.Type(), err)
+			os.Exit(1)
+		}
+
+		ctx := dcontext.Background()
+		ctx, err = configureLogging(ctx, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to configure logging with config: %s", err)
+			os.Exit(1)
+		}
+
+		k, err := libtrust.GenerateECP256PrivateKey()
+		if err != nil {
+			fmt.Fprint(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		registry, err := storage.NewRegistry(ctx, driver, storage.Schema1SigningKey(k))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct registry: %v", err)
+			os.Exit(1)
+		}
+
+		db, err := dbFromConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct database connection: %v", err)
+			os.Exit(1)
+		}
+
+		report, err := datastore.NewImporter(db, registry).CheckMirroring(ctx, sampleSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to check mirroring: %v", err)
+			os.Exit(1)
+		}
+
+		if jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to output report: %v", err)
+				os.Exit(1)
+			}
+		} else {
+			printDivergenceReport(os.Stdout, report)
+		}
+
+		if report.Critical() {
+			os.Exit(2)
+		}
+	},
+}
+
+func printDivergenceReport(w io.Writer, report *datastore.DivergenceReport) {
+	fmt.Fprintf(w, "Checked %d repositories, %d tags and %d manifests: %d critical divergences, %d warnings\n",
+		report.Repositories, report.Tags, report.Manifests,
+		report.Summary[datastore.DivergenceSeverityCritical], report.Summary[datastore.DivergenceSeverityWarning])
+	if len(report.Divergences) == 0 {
+		return
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Severity", "Type", "Repository", "Tag", "Filesystem Digest", "Database Digest"})
+	table.SetColWidth(80)
+	for _, d := range report.Divergences {
+		table.Append([]string{
+			string(d.Severity),
+			string(d.Type),
+			d.Repository,
+			d.Tag,
+			d.FilesystemDigest.String(),
+			d.DatabaseDigest.String(),
+		})
+	}
+	table.Render()
+}
+
 // InventoryCmd is a registry subcommand that collects registry data.
 var InventoryCmd = &cobra.Command{
 	Use:   "inventory <config>",
@@ -0,0 +1,267 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/migrations"
+	"github.com/docker/distribution/registry/datastore/models"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMigrationStatusReporter struct{}
+
+func (fakeMigrationStatusReporter) Version() (string, error) { return "2_b", nil }
+
+func (fakeMigrationStatusReporter) LatestVersion() (string, error) { return "4_d", nil }
+
+func (fakeMigrationStatusReporter) SchemaChecksum() (string, error) { return "sha256:abc", nil }
+
+func TestPrintMigrationStatusJSON(t *testing.T) {
+	appliedAt := time.Date(2022, time.March, 1, 10, 0, 0, 0, time.UTC)
+	statuses := map[string]*migrations.MigrationStatus{
+		"1_a": {AppliedAt: &appliedAt},
+		"2_b": {AppliedAt: &appliedAt, PostDeployment: true},
+		"3_c": {},
+		"4_d": {PostDeployment: true},
+		"0_z": {AppliedAt: &appliedAt, Unknown: true},
+	}
+
+	expected := `{
+  "current_version": "2_b",
+  "latest_version": "4_d",
+  "up_to_date": false,
+  "schema_checksum": "sha256:abc",
+  "applied": [
+    {
+      "id": "1_a",
+      "post_deployment": false,
+      "applied_at": "2022-03-01T10:00:00Z"
+    },
+    {
+      "id": "2_b",
+      "post_deployment": true,
+      "applied_at": "2022-03-01T10:00:00Z"
+    }
+  ],
+  "pending": [
+    {
+      "id": "3_c",
+      "post_deployment": false
+    },
+    {
+      "id": "4_d",
+      "post_deployment": true
+    }
+  ],
+  "unknown": [
+    {
+      "id": "0_z",
+      "post_deployment": false,
+      "applied_at": "2022-03-01T10:00:00Z"
+    }
+  ]
+}
+`
+
+	var buf bytes.Buffer
+	require.NoError(t, printMigrationStatusJSON(&buf, fakeMigrationStatusReporter{}, statuses, false))
+	require.Equal(t, expected, buf.String())
+}
+
+func TestPrintPreflightLocks(t *testing.T) {
+	res := &migrations.PreflightResult{
+		Tables: []string{"manifests"},
+		Locks: []*migrations.TableLock{
+			{PID: 1234, Table: "manifests", Mode: "AccessShareLock", State: "idle in transaction", Query: "SELECT 1", Age: 90 * time.Second},
+		},
+	}
+
+	var buf bytes.Buffer
+	printPreflightLocks(&buf, res)
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "pre-flight check failed"))
+	for _, s := range []string{"1234", "manifests", "AccessShareLock", "idle in transaction", "1m30s", "pg_cancel_backend", "--skip-preflight"} {
+		require.Contains(t, out, s)
+	}
+}
+
+func addTestUpload(t *testing.T, d storagedriver.StorageDriver, repo string, startedAt time.Time) {
+	t.Helper()
+
+	dir := "/docker/registry/v2/repositories/" + repo + "/_uploads/" + uuid.Generate().String()
+	require.NoError(t, d.PutContent(context.Background(), dir+"/data", []byte("")))
+	require.NoError(t, d.PutContent(context.Background(), dir+"/startedat", []byte(startedAt.Format(time.RFC3339))))
+}
+
+func TestAddOrphanedUploads(t *testing.T) {
+	d := inmemory.New()
+	now := time.Now()
+	addTestUpload(t, d, "foo/bar", now.Add(-200*time.Hour))
+	addTestUpload(t, d, "foo/bar", now.Add(-time.Hour))
+	addTestUpload(t, d, "baz", now.Add(-300*time.Hour))
+
+	report := datastore.NewValidationReport()
+	require.NoError(t, addOrphanedUploads(context.Background(), d, report, now.Add(-orphanedUploadAge)))
+
+	require.Len(t, report.Issues, 2)
+	require.Equal(t, 2, report.Summary[datastore.ValidationIssueOrphanedUpload])
+	require.Equal(t, "baz", report.Issues[0].Repository)
+	require.Equal(t, "foo/bar", report.Issues[1].Repository)
+	for _, i := range report.Issues {
+		require.Equal(t, datastore.ValidationIssueOrphanedUpload, i.Type)
+		require.Contains(t, i.Path, "/"+i.Repository+"/_uploads/")
+	}
+}
+
+func TestPrintValidationReport(t *testing.T) {
+	report := datastore.NewValidationReport()
+	report.AddIssue(&datastore.ValidationIssue{
+		Type:       datastore.ValidationIssueBrokenTagLink,
+		Repository: "foo",
+		Tag:        "latest",
+		Detail:     "missing tag link",
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, printValidationReport(&buf, report))
+
+	expected := `{
+  "repositories": 0,
+  "tags": 0,
+  "manifests": 0,
+  "summary": {
+    "broken_tag_link": 1
+  },
+  "issues": [
+    {
+      "type": "broken_tag_link",
+      "repository": "foo",
+      "tag": "latest",
+      "detail": "missing tag link"
+    }
+  ]
+}
+`
+	require.Equal(t, expected, buf.String())
+}
+
+func TestNewGCStats(t *testing.T) {
+	now := time.Date(2023, time.December, 4, 10, 30, 0, 0, time.UTC)
+	queues := []*models.GCQueueStats{
+		{
+			Queue:                    "blobs",
+			Size:                     10,
+			Pending:                  4,
+			OldestPendingReviewAfter: sql.NullTime{Time: now.Add(-90 * time.Minute), Valid: true},
+			Retried:                  2,
+			MaxReviewCount:           3,
+		},
+		{Queue: "manifests", Size: 1},
+	}
+	reviews := []*models.GCReviewStats{
+		{Queue: "blobs", Hour: now.Add(-90 * time.Minute).Truncate(time.Hour), Processed: 6, Dangling: 2, Failed: 1},
+		{Queue: "manifests", Hour: now.Add(-90 * time.Minute).Truncate(time.Hour), Processed: 1},
+		{Queue: "blobs", Hour: now.Truncate(time.Hour), Processed: 2},
+		{Queue: "unknown", Hour: now.Truncate(time.Hour), Processed: 100},
+	}
+
+	stats := newGCStats(queues, reviews, now, 2*time.Hour)
+	require.Equal(t, now, stats.GeneratedAt)
+	require.Equal(t, float64(7200), stats.PeriodSeconds)
+	require.Len(t, stats.Queues, 2)
+
+	blobs := stats.Queues[0]
+	require.Equal(t, "blobs", blobs.Name)
+	require.Equal(t, 10, blobs.Size)
+	require.Equal(t, 4, blobs.Pending)
+	require.NotNil(t, blobs.OldestPendingReviewAfter)
+	require.Equal(t, now.Add(-90*time.Minute), *blobs.OldestPendingReviewAfter)
+	require.Equal(t, float64(5400), blobs.OldestPendingAgeSeconds)
+	require.Equal(t, 2, blobs.Retried)
+	require.Equal(t, 3, blobs.MaxReviewCount)
+	require.Equal(t, int64(8), blobs.Processed)
+	require.Equal(t, float64(4), blobs.ProcessedPerHour)
+	require.Equal(t, int64(2), blobs.Dangling)
+	require.Equal(t, int64(1), blobs.Failed)
+	require.Len(t, blobs.Hourly, 2)
+
+	manifests := stats.Queues[1]
+	require.Equal(t, "manifests", manifests.Name)
+	require.Nil(t, manifests.OldestPendingReviewAfter)
+	require.Zero(t, manifests.OldestPendingAgeSeconds)
+	require.Equal(t, int64(1), manifests.Processed)
+	require.Equal(t, 0.5, manifests.ProcessedPerHour)
+	require.Equal(t, []*gcHourlyStatsJSON{{Hour: now.Add(-90 * time.Minute).Truncate(time.Hour), Processed: 1}}, manifests.Hourly)
+}
+
+func TestPrintGCStats(t *testing.T) {
+	now := time.Date(2023, time.December, 4, 10, 30, 0, 0, time.UTC)
+	queues := []*models.GCQueueStats{
+		{
+			Queue:                    "blobs",
+			Size:                     10,
+			Pending:                  4,
+			OldestPendingReviewAfter: sql.NullTime{Time: now.Add(-90 * time.Minute), Valid: true},
+			Retried:                  2,
+			MaxReviewCount:           3,
+		},
+		{Queue: "manifests"},
+	}
+	reviews := []*models.GCReviewStats{{Queue: "blobs", Hour: now.Truncate(time.Hour), Processed: 48, Dangling: 5, Failed: 1}}
+
+	var buf bytes.Buffer
+	printGCStats(&buf, newGCStats(queues, reviews, now, 24*time.Hour))
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "Online GC statistics, reviews over the last 24h0m0s\n"))
+	require.Contains(t, out, "PROCESSED/H")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Regexp(t, `^\| blobs +\| +10 \| +4 \| 1h30m0s +\| +2 \| +3 \| +2\.00 \| +5 \| +1 \|$`, lines[4])
+	require.Regexp(t, `^\| manifests +\| +0 \| +0 \| +\| +0 \| +0 \| +0\.00 \| +0 \| +0 \|$`, lines[5])
+}
+
+func TestPrintDivergenceReport(t *testing.T) {
+	report := datastore.NewDivergenceReport()
+
+	var buf bytes.Buffer
+	printDivergenceReport(&buf, report)
+	require.Equal(t, "Checked 0 repositories, 0 tags and 0 manifests: 0 critical divergences, 0 warnings\n", buf.String())
+
+	report.Repositories = 2
+	report.Tags = 3
+	report.Manifests = 4
+	report.AddDivergence(&datastore.Divergence{
+		Type:           datastore.DivergenceTagMissingInFilesystem,
+		Repository:     "foo/bar",
+		Tag:            "old",
+		DatabaseDigest: "sha256:b",
+	})
+	report.AddDivergence(&datastore.Divergence{
+		Type:             datastore.DivergenceTagDigestMismatch,
+		Repository:       "foo/bar",
+		Tag:              "latest",
+		FilesystemDigest: "sha256:a",
+		DatabaseDigest:   "sha256:b",
+	})
+	report.Sort()
+	require.True(t, report.Critical())
+
+	buf.Reset()
+	printDivergenceReport(&buf, report)
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "Checked 2 repositories, 3 tags and 4 manifests: 1 critical divergences, 1 warnings\n"))
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Regexp(t, `^\| critical +\| tag_digest_mismatch +\| foo/bar +\| latest +\| sha256:a +\| sha256:b +\|$`, lines[4])
+	require.Regexp(t, `^\| warning +\| tag_missing_in_filesystem +\| foo/bar +\| old +\| +\| sha256:b +\|$`, lines[5])
+}
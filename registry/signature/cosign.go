@@ -0,0 +1,156 @@
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// cosignArtifactType is the artifact type of cosign signatures stored as referrers.
+	cosignArtifactType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	// cosignPayloadMediaType is the media type of the layers holding the signed payload of cosign signatures.
+	cosignPayloadMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// cosignSignatureAnnotation is the layer annotation holding the base64 encoded signature of the payload.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// cosignPayload is the subset of the simple signing payload signed by cosign that identifies the signed image.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest digest.Digest `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// cosignVerifier verifies cosign signatures made with one of a set of public keys.
+type cosignVerifier struct {
+	keys []crypto.PublicKey
+}
+
+// newCosignVerifier parses the PEM encoded public keys in the given files.
+func newCosignVerifier(pems ...[]byte) (*cosignVerifier, error) {
+	v := &cosignVerifier{}
+	for _, b := range pems {
+		for {
+			var block *pem.Block
+			block, b = pem.Decode(b)
+			if block == nil {
+				break
+			}
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parsing public key: %w", err)
+			}
+			switch key.(type) {
+			case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+			default:
+				return nil, fmt.Errorf("unsupported public key type %T", key)
+			}
+			v.keys = append(v.keys, key)
+		}
+	}
+	if len(v.keys) == 0 {
+		return nil, errors.New("no PEM encoded public keys found")
+	}
+
+	return v, nil
+}
+
+// cosignTag returns the name of the tag under which cosign stores the signatures of the manifest with the given
+// digest, for registries without support for referrers.
+func cosignTag(dgst digest.Digest) string {
+	return fmt.Sprintf("%s-%s.sig", dgst.Algorithm(), dgst.Hex())
+}
+
+// isCosignSignature returns whether a manifest holds cosign signatures.
+func isCosignSignature(m ocischema.Manifest) bool {
+	if m.ArtifactType == cosignArtifactType || m.Config.MediaType == cosignArtifactType {
+		return true
+	}
+	for _, l := range m.Layers {
+		if l.MediaType == cosignPayloadMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// verify checks that one of the signatures in manifest m signs the manifest with the given digest with a trusted key.
+func (v *cosignVerifier) verify(ctx context.Context, store Store, m ocischema.Manifest, dgst digest.Digest) error {
+	err := invalidf("no cosign signature layers")
+	for _, l := range m.Layers {
+		if l.MediaType != cosignPayloadMediaType {
+			continue
+		}
+		sig, decErr := base64.StdEncoding.DecodeString(l.Annotations[cosignSignatureAnnotation])
+		if decErr != nil || len(sig) == 0 {
+			err = invalidf("missing or malformed %s annotation", cosignSignatureAnnotation)
+			continue
+		}
+		payload, readErr := readBlob(ctx, store, l)
+		if readErr != nil {
+			if !errors.Is(readErr, errInvalidSignature) {
+				return readErr
+			}
+			err = readErr
+			continue
+		}
+
+		var p cosignPayload
+		if jsonErr := json.Unmarshal(payload, &p); jsonErr != nil {
+			err = invalidf("malformed payload: %v", jsonErr)
+			continue
+		}
+		if p.Critical.Image.DockerManifestDigest != dgst {
+			err = invalidf("payload signs manifest %q", p.Critical.Image.DockerManifestDigest)
+			continue
+		}
+		if !v.verifySignature(payload, sig) {
+			err = invalidf("signature does not match any trusted cosign key")
+			continue
+		}
+		return nil
+	}
+
+	return err
+}
+
+// verifySignature returns whether sig is a valid signature of payload by any of the trusted keys. As cosign does,
+// ECDSA and RSA signatures are made over the SHA-256 hash of the payload, using ASN.1 and PKCS #1 v1.5 respectively.
+func (v *cosignVerifier) verifySignature(payload, sig []byte) bool {
+	h := sha256.Sum256(payload)
+	for _, key := range v.keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, h[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
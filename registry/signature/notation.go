@@ -0,0 +1,230 @@
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha512" // registers SHA-384 and SHA-512
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// notationArtifactType is the artifact type of notation signatures.
+	notationArtifactType = "application/vnd.cncf.notary.signature"
+	// notationJWSMediaType is the media type of the layers holding JWS signature envelopes. COSE envelopes are not
+	// supported.
+	notationJWSMediaType = "application/jose+json"
+	// notationPayloadContentType is the content type of the payload signed by notation.
+	notationPayloadContentType = "application/vnd.cncf.notary.payload.v1+json"
+)
+
+// jwsEnvelope is a JWS signature envelope in the flattened JSON serialization, as produced by notation.
+type jwsEnvelope struct {
+	Payload   string `json:"payload"`
+	Protected string `json:"protected"`
+	Header    struct {
+		CertificateChain []string `json:"x5c"`
+	} `json:"header"`
+	Signature string `json:"signature"`
+}
+
+// jwsProtectedHeader is the subset of the protected header of notation signature envelopes used for verification.
+type jwsProtectedHeader struct {
+	Algorithm   string `json:"alg"`
+	ContentType string `json:"cty"`
+}
+
+// notationPayload is the payload signed by notation.
+type notationPayload struct {
+	TargetArtifact struct {
+		Digest digest.Digest `json:"digest"`
+	} `json:"targetArtifact"`
+}
+
+// notationVerifier verifies notation signatures made with certificates issued by one of a set of root certificate
+// authorities.
+type notationVerifier struct {
+	roots *x509.CertPool
+}
+
+// newNotationVerifier parses the PEM encoded root certificates in the given files.
+func newNotationVerifier(pems ...[]byte) (*notationVerifier, error) {
+	roots := x509.NewCertPool()
+	var ok bool
+	for _, b := range pems {
+		if roots.AppendCertsFromPEM(b) {
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, errors.New("no PEM encoded certificates found")
+	}
+
+	return &notationVerifier{roots: roots}, nil
+}
+
+// isNotationSignature returns whether a manifest holds a notation signature.
+func isNotationSignature(m ocischema.Manifest) bool {
+	return m.ArtifactType == notationArtifactType || m.Config.MediaType == notationArtifactType
+}
+
+// verify checks that one of the signature envelopes in manifest m signs the manifest with the given digest with a
+// certificate issued by a trusted authority.
+func (v *notationVerifier) verify(ctx context.Context, store Store, m ocischema.Manifest, dgst digest.Digest) error {
+	err := invalidf("no JWS signature envelopes")
+	for _, l := range m.Layers {
+		if l.MediaType != notationJWSMediaType {
+			continue
+		}
+		b, readErr := readBlob(ctx, store, l)
+		if readErr != nil {
+			if !errors.Is(readErr, errInvalidSignature) {
+				return readErr
+			}
+			err = readErr
+			continue
+		}
+		if err = v.verifyEnvelope(b, dgst); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// verifyEnvelope checks that a JWS envelope is signed with a trusted certificate and that its payload targets the
+// manifest with the given digest.
+func (v *notationVerifier) verifyEnvelope(b []byte, dgst digest.Digest) error {
+	var env jwsEnvelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return invalidf("malformed envelope: %v", err)
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return invalidf("malformed protected header: %v", err)
+	}
+	var header jwsProtectedHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return invalidf("malformed protected header: %v", err)
+	}
+	if header.ContentType != notationPayloadContentType {
+		return invalidf("unsupported payload content type %q", header.ContentType)
+	}
+
+	leaf, err := v.verifyCertificateChain(env.Header.CertificateChain)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return invalidf("malformed signature: %v", err)
+	}
+	if err := verifyJWS(header.Algorithm, leaf.PublicKey, []byte(env.Protected+"."+env.Payload), sig); err != nil {
+		return err
+	}
+
+	rawPayload, err := base64.RawURLEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return invalidf("malformed payload: %v", err)
+	}
+	var p notationPayload
+	if err := json.Unmarshal(rawPayload, &p); err != nil {
+		return invalidf("malformed payload: %v", err)
+	}
+	if p.TargetArtifact.Digest != dgst {
+		return invalidf("payload signs manifest %q", p.TargetArtifact.Digest)
+	}
+
+	return nil
+}
+
+// verifyCertificateChain parses a base64 encoded certificate chain, starting with the signing certificate, and checks
+// that it chains to a trusted root. Returns the signing certificate.
+func (v *notationVerifier) verifyCertificateChain(chain []string) (*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, invalidf("missing certificate chain")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(chain))
+	for _, c := range chain {
+		der, err := base64.StdEncoding.DecodeString(c)
+		if err != nil {
+			return nil, invalidf("malformed certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, invalidf("malformed certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, invalidf("untrusted certificate: %v", err)
+	}
+
+	return certs[0], nil
+}
+
+// verifyJWS checks a JWS signature of the given signing input, for the RSASSA-PSS and ECDSA algorithms supported by
+// notation.
+func verifyJWS(alg string, key crypto.PublicKey, input, sig []byte) error {
+	var h crypto.Hash
+	switch alg {
+	case "PS256", "ES256":
+		h = crypto.SHA256
+	case "PS384", "ES384":
+		h = crypto.SHA384
+	case "PS512", "ES512":
+		h = crypto.SHA512
+	default:
+		return invalidf("unsupported algorithm %q", alg)
+	}
+	hasher := h.New()
+	hasher.Write(input)
+	hashed := hasher.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'P' {
+			return invalidf("algorithm %q does not match RSA key", alg)
+		}
+		if err := rsa.VerifyPSS(k, h, hashed, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			return invalidf("signature does not match signing certificate")
+		}
+	case *ecdsa.PublicKey:
+		if alg[0] != 'E' {
+			return invalidf("algorithm %q does not match ECDSA key", alg)
+		}
+		// JWS ECDSA signatures are the concatenation of r and s, each the size of the curve order
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return invalidf("signature does not match signing certificate")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, hashed, r, s) {
+			return invalidf("signature does not match signing certificate")
+		}
+	default:
+		return invalidf("unsupported signing certificate key type %T", key)
+	}
+
+	return nil
+}
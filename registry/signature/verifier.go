@@ -0,0 +1,247 @@
+// Package signature verifies that manifests are signed with cosign or notation by a trusted key or certificate
+// authority. Signatures are looked up among the referrers of a manifest and, for cosign, under the conventional
+// `sha256-<hex>.sig` tag.
+package signature
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/opencontainers/go-digest"
+)
+
+var (
+	// ErrUnsigned is returned by Policy.Verify when a manifest has no signatures.
+	ErrUnsigned = errors.New("manifest is not signed")
+	// ErrUntrusted is returned by Policy.Verify when none of the signatures of a manifest is valid and made with a
+	// trusted key or certificate.
+	ErrUntrusted = errors.New("manifest has no valid signature from a trusted signer")
+)
+
+// Store provides read access to the repository being pulled from.
+type Store interface {
+	// Referrers returns the digests of the manifests that have the manifest with the given digest as subject.
+	Referrers(ctx context.Context, dgst digest.Digest) ([]digest.Digest, error)
+	// Tag returns the digest of the manifest tagged with the given name, or an empty digest if the tag does not exist.
+	Tag(ctx context.Context, name string) (digest.Digest, error)
+	// Manifest returns the payload of the manifest with the given digest.
+	Manifest(ctx context.Context, dgst digest.Digest) ([]byte, error)
+	// Blob returns the content of the blob with the given digest.
+	Blob(ctx context.Context, dgst digest.Digest) ([]byte, error)
+}
+
+// Verifier holds the signature rules of the registry.
+type Verifier struct {
+	policies []*Policy
+}
+
+// Policy is the signature requirement for the repositories matching a path pattern.
+type Policy struct {
+	repository string
+	mode       configuration.SignatureMode
+	cosign     *cosignVerifier
+	notation   *notationVerifier
+}
+
+// NewVerifier loads the trust roots of the given rules. Returns nil if there are no rules.
+func NewVerifier(rules []configuration.SignatureRule) (*Verifier, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	v := &Verifier{}
+	for i, rule := range rules {
+		p, err := newPolicy(rule)
+		if err != nil {
+			return nil, fmt.Errorf("policy.repository.signatures[%d]: %w", i, err)
+		}
+		v.policies = append(v.policies, p)
+	}
+
+	return v, nil
+}
+
+func newPolicy(rule configuration.SignatureRule) (*Policy, error) {
+	if _, err := gopath.Match(rule.Repository, ""); err != nil {
+		return nil, fmt.Errorf("repository: %w", err)
+	}
+	switch rule.Mode {
+	case "", configuration.SignatureModeEnforce:
+		rule.Mode = configuration.SignatureModeEnforce
+	case configuration.SignatureModeWarn:
+	default:
+		return nil, fmt.Errorf("mode: unknown mode %q, must be one of %q or %q", rule.Mode, configuration.SignatureModeEnforce, configuration.SignatureModeWarn)
+	}
+	if len(rule.Cosign.Keys) == 0 && len(rule.Notation.Certificates) == 0 {
+		return nil, errors.New("at least one cosign key or notation certificate must be configured")
+	}
+
+	p := &Policy{repository: rule.Repository, mode: rule.Mode}
+	if len(rule.Cosign.Keys) > 0 {
+		var pems [][]byte
+		for _, path := range rule.Cosign.Keys {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("cosign: %w", err)
+			}
+			pems = append(pems, b)
+		}
+		cv, err := newCosignVerifier(pems...)
+		if err != nil {
+			return nil, fmt.Errorf("cosign: %w", err)
+		}
+		p.cosign = cv
+	}
+	if len(rule.Notation.Certificates) > 0 {
+		var pems [][]byte
+		for _, path := range rule.Notation.Certificates {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("notation: %w", err)
+			}
+			pems = append(pems, b)
+		}
+		nv, err := newNotationVerifier(pems...)
+		if err != nil {
+			return nil, fmt.Errorf("notation: %w", err)
+		}
+		p.notation = nv
+	}
+
+	return p, nil
+}
+
+// Policy returns the policy of the first rule matching the repository with the given path, or nil if signatures are
+// not required for it. Safe to call on a nil Verifier.
+func (v *Verifier) Policy(path string) *Policy {
+	if v == nil {
+		return nil
+	}
+	for _, p := range v.policies {
+		if p.repository == "" {
+			return p
+		}
+		if ok, err := gopath.Match(p.repository, path); err == nil && ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// Enforced returns whether pulls of manifests without a valid signature must be denied, as opposed to logged.
+func (p *Policy) Enforced() bool {
+	return p.mode == configuration.SignatureModeEnforce
+}
+
+// Verify checks that the manifest with the given digest and payload has at least one valid signature from a trusted
+// signer. Signatures and other artifacts referring to a manifest are exempt, as they are pulled by clients to verify
+// images. Returns ErrUnsigned or ErrUntrusted, wrapped with the reasons signatures were rejected, if verification
+// fails, or any other error if signatures could not be read from store.
+func (p *Policy) Verify(ctx context.Context, store Store, dgst digest.Digest, payload []byte) error {
+	if exempt(payload) {
+		return nil
+	}
+
+	candidates, err := store.Referrers(ctx, dgst)
+	if err != nil {
+		return fmt.Errorf("finding referrers: %w", err)
+	}
+	if p.cosign != nil {
+		tagged, err := store.Tag(ctx, cosignTag(dgst))
+		if err != nil {
+			return fmt.Errorf("finding cosign signature tag: %w", err)
+		}
+		if tagged != "" {
+			candidates = append(candidates, tagged)
+		}
+	}
+
+	var found int
+	var reasons []string
+	for _, c := range candidates {
+		b, err := store.Manifest(ctx, c)
+		if err != nil {
+			return fmt.Errorf("reading signature manifest %s: %w", c, err)
+		}
+		var m ocischema.Manifest
+		if err := json.Unmarshal(b, &m); err != nil {
+			// referrers may be of any manifest type, ignore those that are not OCI manifests
+			continue
+		}
+
+		var verr error
+		switch {
+		case isCosignSignature(m):
+			if p.cosign == nil {
+				continue
+			}
+			found++
+			verr = p.cosign.verify(ctx, store, m, dgst)
+		case isNotationSignature(m):
+			if p.notation == nil {
+				continue
+			}
+			found++
+			verr = p.notation.verify(ctx, store, m, dgst)
+		default:
+			continue
+		}
+		if verr == nil {
+			return nil
+		}
+		if !errors.Is(verr, errInvalidSignature) {
+			return verr
+		}
+		reasons = append(reasons, fmt.Sprintf("signature %s: %v", c, verr))
+	}
+
+	if found == 0 {
+		return ErrUnsigned
+	}
+	return fmt.Errorf("%w: %s", ErrUntrusted, strings.Join(reasons, "; "))
+}
+
+// maxBlobSize is the maximum size of the signature blobs read for verification. Signature payloads and envelopes are
+// a few kilobytes at most.
+const maxBlobSize = 4 << 20
+
+// errInvalidSignature is wrapped by the errors that describe why a signature was rejected, as opposed to errors
+// reading it.
+var errInvalidSignature = errors.New("invalid signature")
+
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errInvalidSignature, fmt.Sprintf(format, args...))
+}
+
+// exempt returns whether the manifest with the given payload does not require a signature, either because it is a
+// signature itself or it refers to another manifest, like attestations and SBOMs.
+func exempt(payload []byte) bool {
+	var m ocischema.Manifest
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return false
+	}
+	return m.Subject != nil || isCosignSignature(m) || isNotationSignature(m)
+}
+
+// readBlob reads a blob referenced by a signature manifest, checking that its content matches the descriptor.
+func readBlob(ctx context.Context, store Store, desc distribution.Descriptor) ([]byte, error) {
+	if desc.Size > maxBlobSize {
+		return nil, invalidf("blob %s is larger than %d bytes", desc.Digest, maxBlobSize)
+	}
+	b, err := store.Blob(ctx, desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("reading signature blob %s: %w", desc.Digest, err)
+	}
+	if desc.Digest.Algorithm().Available() && desc.Digest.Algorithm().FromBytes(b) != desc.Digest {
+		return nil, invalidf("content of blob %s does not match its digest", desc.Digest)
+	}
+	return b, nil
+}
@@ -0,0 +1,443 @@
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is an in-memory Store.
+type fakeStore struct {
+	manifests map[digest.Digest][]byte
+	blobs     map[digest.Digest][]byte
+	tags      map[string]digest.Digest
+	referrers map[digest.Digest][]digest.Digest
+	err       error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		manifests: make(map[digest.Digest][]byte),
+		blobs:     make(map[digest.Digest][]byte),
+		tags:      make(map[string]digest.Digest),
+		referrers: make(map[digest.Digest][]digest.Digest),
+	}
+}
+
+func (s *fakeStore) Referrers(_ context.Context, dgst digest.Digest) ([]digest.Digest, error) {
+	return s.referrers[dgst], s.err
+}
+
+func (s *fakeStore) Tag(_ context.Context, name string) (digest.Digest, error) {
+	return s.tags[name], nil
+}
+
+func (s *fakeStore) Manifest(_ context.Context, dgst digest.Digest) ([]byte, error) {
+	b, ok := s.manifests[dgst]
+	if !ok {
+		return nil, distribution.ErrManifestUnknownRevision{Revision: dgst}
+	}
+	return b, nil
+}
+
+func (s *fakeStore) Blob(_ context.Context, dgst digest.Digest) ([]byte, error) {
+	b, ok := s.blobs[dgst]
+	if !ok {
+		return nil, distribution.ErrBlobUnknown
+	}
+	return b, nil
+}
+
+func (s *fakeStore) putBlob(b []byte) distribution.Descriptor {
+	dgst := digest.FromBytes(b)
+	s.blobs[dgst] = b
+	return distribution.Descriptor{Digest: dgst, Size: int64(len(b))}
+}
+
+func (s *fakeStore) putManifest(t *testing.T, m ocischema.Manifest) digest.Digest {
+	t.Helper()
+
+	m.Versioned = ocischema.SchemaVersion
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+	dgst := digest.FromBytes(b)
+	s.manifests[dgst] = b
+	return dgst
+}
+
+// putImage stores a random image manifest and returns its digest and payload.
+func (s *fakeStore) putImage(t *testing.T) (digest.Digest, []byte) {
+	t.Helper()
+
+	config := s.putBlob([]byte(fmt.Sprintf(`{"created": %q}`, time.Now().String())))
+	config.MediaType = v1.MediaTypeImageConfig
+	dgst := s.putManifest(t, ocischema.Manifest{Config: config})
+	return dgst, s.manifests[dgst]
+}
+
+// putCosignSignature stores a cosign signature of the manifest with digest dgst, signed with key, and returns the
+// signature manifest digest. The signature is stored under the cosign tag unless subject is true.
+func (s *fakeStore) putCosignSignature(t *testing.T, key *ecdsa.PrivateKey, dgst digest.Digest, subject bool) digest.Digest {
+	t.Helper()
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"example.com/foo"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, dgst))
+	h := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	require.NoError(t, err)
+
+	layer := s.putBlob(payload)
+	layer.MediaType = cosignPayloadMediaType
+	layer.Annotations = map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)}
+	config := s.putBlob([]byte("{}"))
+	config.MediaType = v1.MediaTypeImageConfig
+
+	m := ocischema.Manifest{Config: config, Layers: []distribution.Descriptor{layer}}
+	if subject {
+		m.ArtifactType = cosignArtifactType
+		m.Subject = &distribution.Descriptor{MediaType: v1.MediaTypeImageManifest, Digest: dgst}
+	}
+	sigDgst := s.putManifest(t, m)
+	if subject {
+		s.referrers[dgst] = append(s.referrers[dgst], sigDgst)
+	} else {
+		s.tags[cosignTag(dgst)] = sigDgst
+	}
+
+	return sigDgst
+}
+
+// putNotationSignature stores a notation signature of the manifest with digest dgst, signed with the given key and
+// certificate chain, as a referrer.
+func (s *fakeStore) putNotationSignature(t *testing.T, key crypto.Signer, chain []*x509.Certificate, dgst digest.Digest) digest.Digest {
+	t.Helper()
+
+	alg := "ES256"
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		alg = "PS256"
+	}
+	protected := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"alg":%q,"crit":["io.cncf.notary.signingScheme"],"cty":%q,"io.cncf.notary.signingScheme":"notary.x509"}`, alg, notationPayloadContentType)))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"targetArtifact":{"mediaType":%q,"digest":%q,"size":1}}`, v1.MediaTypeImageManifest, dgst)))
+
+	h := sha256.Sum256([]byte(protected + "." + payload))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPSS(rand.Reader, k, crypto.SHA256, h[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, h[:])
+		require.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+
+	x5c := make([]string, 0, len(chain))
+	for _, c := range chain {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(c.Raw))
+	}
+	env, err := json.Marshal(map[string]any{
+		"payload":   payload,
+		"protected": protected,
+		"header":    map[string]any{"x5c": x5c},
+		"signature": base64.RawURLEncoding.EncodeToString(sig),
+	})
+	require.NoError(t, err)
+
+	layer := s.putBlob(env)
+	layer.MediaType = notationJWSMediaType
+	config := s.putBlob([]byte("{}"))
+	config.MediaType = notationArtifactType
+
+	sigDgst := s.putManifest(t, ocischema.Manifest{
+		Config:  config,
+		Layers:  []distribution.Descriptor{layer},
+		Subject: &distribution.Descriptor{MediaType: v1.MediaTypeImageManifest, Digest: dgst},
+	})
+	s.referrers[dgst] = append(s.referrers[dgst], sigDgst)
+
+	return sigDgst
+}
+
+func newECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// newCertificate creates a certificate for key, signed by parent and parentKey, or self-signed if parent is nil.
+func newCertificate(t *testing.T, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		tmpl.Subject.CommonName = "ca"
+		tmpl.IsCA = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func certificatePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func TestPolicy_Verify_Cosign(t *testing.T) {
+	ctx := context.Background()
+	key := newECDSAKey(t)
+	cv, err := newCosignVerifier(publicKeyPEM(t, key.Public()))
+	require.NoError(t, err)
+	p := &Policy{mode: configuration.SignatureModeEnforce, cosign: cv}
+
+	store := newFakeStore()
+
+	// unsigned
+	dgst, payload := store.putImage(t)
+	require.ErrorIs(t, p.Verify(ctx, store, dgst, payload), ErrUnsigned)
+
+	// signed under the cosign tag
+	store.putCosignSignature(t, key, dgst, false)
+	require.NoError(t, p.Verify(ctx, store, dgst, payload))
+
+	// signed as a referrer
+	dgst, payload = store.putImage(t)
+	store.putCosignSignature(t, key, dgst, true)
+	require.NoError(t, p.Verify(ctx, store, dgst, payload))
+
+	// signed with an untrusted key
+	dgst, payload = store.putImage(t)
+	store.putCosignSignature(t, newECDSAKey(t), dgst, false)
+	err = p.Verify(ctx, store, dgst, payload)
+	require.ErrorIs(t, err, ErrUntrusted)
+	require.Contains(t, err.Error(), "signature does not match any trusted cosign key")
+
+	// signature of another manifest stored under the cosign tag
+	otherDgst, _ := store.putImage(t)
+	dgst, payload = store.putImage(t)
+	store.tags[cosignTag(dgst)] = store.putCosignSignature(t, key, otherDgst, false)
+	err = p.Verify(ctx, store, dgst, payload)
+	require.ErrorIs(t, err, ErrUntrusted)
+	require.Contains(t, err.Error(), "payload signs manifest")
+
+	// signature payload tampered with
+	dgst, payload = store.putImage(t)
+	sigDgst := store.putCosignSignature(t, key, dgst, false)
+	var m ocischema.Manifest
+	require.NoError(t, json.Unmarshal(store.manifests[sigDgst], &m))
+	store.blobs[m.Layers[0].Digest] = []byte("{}")
+	err = p.Verify(ctx, store, dgst, payload)
+	require.ErrorIs(t, err, ErrUntrusted)
+	require.Contains(t, err.Error(), "does not match its digest")
+}
+
+func TestPolicy_Verify_Notation(t *testing.T) {
+	ctx := context.Background()
+
+	caKey := newECDSAKey(t)
+	ca := newCertificate(t, caKey, nil, nil)
+	nv, err := newNotationVerifier(certificatePEM(ca))
+	require.NoError(t, err)
+	p := &Policy{mode: configuration.SignatureModeEnforce, notation: nv}
+
+	store := newFakeStore()
+
+	// unsigned
+	dgst, payload := store.putImage(t)
+	require.ErrorIs(t, p.Verify(ctx, store, dgst, payload), ErrUnsigned)
+
+	// signed with an ECDSA certificate issued by the trusted CA
+	leafKey := newECDSAKey(t)
+	leaf := newCertificate(t, leafKey, ca, caKey)
+	store.putNotationSignature(t, leafKey, []*x509.Certificate{leaf, ca}, dgst)
+	require.NoError(t, p.Verify(ctx, store, dgst, payload))
+
+	// signed with an RSA certificate issued by the trusted CA
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaLeaf := newCertificate(t, rsaKey, ca, caKey)
+	dgst, payload = store.putImage(t)
+	store.putNotationSignature(t, rsaKey, []*x509.Certificate{rsaLeaf}, dgst)
+	require.NoError(t, p.Verify(ctx, store, dgst, payload))
+
+	// signed with a certificate issued by an untrusted CA
+	otherCAKey := newECDSAKey(t)
+	otherCA := newCertificate(t, otherCAKey, nil, nil)
+	otherLeaf := newCertificate(t, leafKey, otherCA, otherCAKey)
+	dgst, payload = store.putImage(t)
+	store.putNotationSignature(t, leafKey, []*x509.Certificate{otherLeaf, otherCA}, dgst)
+	err = p.Verify(ctx, store, dgst, payload)
+	require.ErrorIs(t, err, ErrUntrusted)
+	require.Contains(t, err.Error(), "untrusted certificate")
+
+	// signed with a key that does not match the certificate
+	dgst, payload = store.putImage(t)
+	store.putNotationSignature(t, newECDSAKey(t), []*x509.Certificate{leaf}, dgst)
+	err = p.Verify(ctx, store, dgst, payload)
+	require.ErrorIs(t, err, ErrUntrusted)
+	require.Contains(t, err.Error(), "signature does not match signing certificate")
+
+	// cosign signatures are ignored when no cosign keys are configured
+	dgst, payload = store.putImage(t)
+	store.putCosignSignature(t, newECDSAKey(t), dgst, true)
+	require.ErrorIs(t, p.Verify(ctx, store, dgst, payload), ErrUnsigned)
+}
+
+func TestPolicy_Verify_Exempt(t *testing.T) {
+	ctx := context.Background()
+	key := newECDSAKey(t)
+	cv, err := newCosignVerifier(publicKeyPEM(t, key.Public()))
+	require.NoError(t, err)
+	p := &Policy{mode: configuration.SignatureModeEnforce, cosign: cv}
+
+	store := newFakeStore()
+	dgst, _ := store.putImage(t)
+
+	// signatures, stored as referrers or not, and other referrers do not need to be signed
+	for _, sigDgst := range []digest.Digest{
+		store.putCosignSignature(t, key, dgst, false),
+		store.putCosignSignature(t, key, dgst, true),
+		store.putManifest(t, ocischema.Manifest{
+			Config:  distribution.Descriptor{MediaType: "application/vnd.example.sbom"},
+			Subject: &distribution.Descriptor{MediaType: v1.MediaTypeImageManifest, Digest: dgst},
+		}),
+	} {
+		require.NoError(t, p.Verify(ctx, store, sigDgst, store.manifests[sigDgst]))
+	}
+
+	// images of other types must be signed
+	payload := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{},"layers":[]}`, schema2.MediaTypeManifest))
+	require.ErrorIs(t, p.Verify(ctx, store, digest.FromBytes(payload), payload), ErrUnsigned)
+}
+
+func TestPolicy_Verify_StoreError(t *testing.T) {
+	key := newECDSAKey(t)
+	cv, err := newCosignVerifier(publicKeyPEM(t, key.Public()))
+	require.NoError(t, err)
+	p := &Policy{mode: configuration.SignatureModeEnforce, cosign: cv}
+
+	store := newFakeStore()
+	dgst, payload := store.putImage(t)
+	store.err = errors.New("foo")
+
+	err = p.Verify(context.Background(), store, dgst, payload)
+	require.EqualError(t, err, "finding referrers: foo")
+	require.False(t, errors.Is(err, ErrUnsigned) || errors.Is(err, ErrUntrusted))
+}
+
+func TestNewVerifier(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "cosign.pub")
+	require.NoError(t, os.WriteFile(keyPath, publicKeyPEM(t, newECDSAKey(t).Public()), 0600))
+	caKey := newECDSAKey(t)
+	caPath := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caPath, certificatePEM(newCertificate(t, caKey, nil, nil)), 0600))
+
+	v, err := NewVerifier(nil)
+	require.NoError(t, err)
+	require.Nil(t, v)
+	require.Nil(t, v.Policy("foo/bar"))
+
+	v, err = NewVerifier([]configuration.SignatureRule{
+		{Repository: "foo/*", Mode: configuration.SignatureModeWarn, Cosign: configuration.CosignTrustRoots{Keys: []string{keyPath}}},
+		{Repository: "bar/*", Notation: configuration.NotationTrustRoots{Certificates: []string{caPath}}},
+	})
+	require.NoError(t, err)
+
+	p := v.Policy("foo/bar")
+	require.NotNil(t, p)
+	require.False(t, p.Enforced())
+	require.NotNil(t, p.cosign)
+	require.Nil(t, p.notation)
+
+	p = v.Policy("bar/foo")
+	require.NotNil(t, p)
+	require.True(t, p.Enforced())
+	require.Nil(t, p.cosign)
+	require.NotNil(t, p.notation)
+
+	require.Nil(t, v.Policy("baz/foo"))
+
+	tcs := map[string]struct {
+		rule configuration.SignatureRule
+		err  string
+	}{
+		"invalid repository pattern": {
+			rule: configuration.SignatureRule{Repository: "[", Cosign: configuration.CosignTrustRoots{Keys: []string{keyPath}}},
+			err:  "policy.repository.signatures[0]: repository: syntax error in pattern",
+		},
+		"unknown mode": {
+			rule: configuration.SignatureRule{Mode: "foo", Cosign: configuration.CosignTrustRoots{Keys: []string{keyPath}}},
+			err:  `policy.repository.signatures[0]: mode: unknown mode "foo", must be one of "enforce" or "warn"`,
+		},
+		"no trust roots": {
+			rule: configuration.SignatureRule{},
+			err:  "policy.repository.signatures[0]: at least one cosign key or notation certificate must be configured",
+		},
+		"missing key file": {
+			rule: configuration.SignatureRule{Cosign: configuration.CosignTrustRoots{Keys: []string{filepath.Join(dir, "missing")}}},
+			err:  "policy.repository.signatures[0]: cosign: open " + filepath.Join(dir, "missing") + ": no such file or directory",
+		},
+		"certificate as cosign key": {
+			rule: configuration.SignatureRule{Cosign: configuration.CosignTrustRoots{Keys: []string{caPath}}},
+			err:  "policy.repository.signatures[0]: cosign: no PEM encoded public keys found",
+		},
+		"key as notation certificate": {
+			rule: configuration.SignatureRule{Notation: configuration.NotationTrustRoots{Certificates: []string{keyPath}}},
+			err:  "policy.repository.signatures[0]: notation: no PEM encoded certificates found",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewVerifier([]configuration.SignatureRule{tc.rule})
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package sizes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	gocache "github.com/eko/gocache/lib/v4/cache"
+	libstore "github.com/eko/gocache/lib/v4/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// checkpointKey is the Redis key under which the size recalculation checkpoint is stored. The used key format is
+// described in https://gitlab.com/gitlab-org/container-registry/-/blob/master/docs-gitlab/redis-dev-guidelines.md#key-format.
+const checkpointKey = "registry:db:{size-recalculation}:checkpoint"
+
+// checkpointOpTimeout defines the timeout applied to checkpoint operations against Redis.
+const checkpointOpTimeout = 500 * time.Millisecond
+
+// CheckpointStore persists the ID of the last repository whose size was recalculated, so that a recalculation pass can
+// be resumed across worker runs.
+type CheckpointStore interface {
+	// Get returns the ID of the last processed repository, or zero if a new pass should be started.
+	Get(ctx context.Context) (int64, error)
+	// Set records the ID of the last processed repository. Zero resets the checkpoint, starting a new pass.
+	Set(ctx context.Context, id int64) error
+}
+
+// memoryCheckpointStore is a CheckpointStore that keeps the checkpoint in memory. The checkpoint is therefore lost on
+// restart and not shared with other registry instances.
+type memoryCheckpointStore struct {
+	// id is accessed atomically.
+	id int64
+}
+
+// NewMemoryCheckpointStore creates a new CheckpointStore backed by memory.
+func NewMemoryCheckpointStore() *memoryCheckpointStore {
+	return &memoryCheckpointStore{}
+}
+
+// Get implements CheckpointStore.
+func (s *memoryCheckpointStore) Get(context.Context) (int64, error) {
+	return atomic.LoadInt64(&s.id), nil
+}
+
+// Set implements CheckpointStore.
+func (s *memoryCheckpointStore) Set(_ context.Context, id int64) error {
+	atomic.StoreInt64(&s.id, id)
+	return nil
+}
+
+// redisCheckpointStore is a CheckpointStore backed by Redis. The checkpoint survives restarts and is shared across all
+// registry instances, which therefore cooperate on the same recalculation pass.
+type redisCheckpointStore struct {
+	cache *gocache.Cache[any]
+}
+
+// NewRedisCheckpointStore creates a new CheckpointStore backed by Redis.
+func NewRedisCheckpointStore(cache *gocache.Cache[any]) *redisCheckpointStore {
+	return &redisCheckpointStore{cache: cache}
+}
+
+// Get implements CheckpointStore.
+func (s *redisCheckpointStore) Get(ctx context.Context) (int64, error) {
+	getCtx, cancel := context.WithTimeout(ctx, checkpointOpTimeout)
+	defer cancel()
+
+	tmp, err := s.cache.Get(getCtx, checkpointKey)
+	if err != nil {
+		// a wrapped redis.Nil is returned when the key is not found in Redis
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading size recalculation checkpoint: %w", err)
+	}
+
+	v, ok := tmp.(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected size recalculation checkpoint type %T", tmp)
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing size recalculation checkpoint: %w", err)
+	}
+
+	return id, nil
+}
+
+// Set implements CheckpointStore. The checkpoint never expires.
+func (s *redisCheckpointStore) Set(ctx context.Context, id int64) error {
+	setCtx, cancel := context.WithTimeout(ctx, checkpointOpTimeout)
+	defer cancel()
+
+	if err := s.cache.Set(setCtx, checkpointKey, strconv.FormatInt(id, 10), libstore.WithExpiration(0)); err != nil {
+		return fmt.Errorf("writing size recalculation checkpoint: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package sizes_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/docker/distribution/registry/sizes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCheckpointStore(t *testing.T) {
+	s := sizes.NewMemoryCheckpointStore()
+	ctx := context.Background()
+
+	id, err := s.Get(ctx)
+	require.NoError(t, err)
+	require.Zero(t, id)
+
+	require.NoError(t, s.Set(ctx, 10))
+	id, err = s.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), id)
+}
+
+func TestRedisCheckpointStore(t *testing.T) {
+	// the global TTL must not apply to checkpoints, which may need to survive for as long as a pass takes
+	c := testutil.NewRedisCacheController(t, time.Hour)
+	s := sizes.NewRedisCheckpointStore(c.Cache)
+	ctx := context.Background()
+
+	id, err := s.Get(ctx)
+	require.NoError(t, err)
+	require.Zero(t, id)
+
+	require.NoError(t, s.Set(ctx, 10))
+	id, err = s.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), id)
+
+	key := "registry:db:{size-recalculation}:checkpoint"
+	require.True(t, c.Exists(key))
+	require.Zero(t, c.TTL(key))
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	runDurationHist     *prometheus.HistogramVec
+	runCounter          *prometheus.CounterVec
+	recalculatedCounter *prometheus.CounterVec
+	driftCounter        *prometheus.CounterVec
+	driftBytesCounter   *prometheus.CounterVec
+
+	timeSince = time.Since // for test purposes only
+)
+
+const (
+	subsystem = "size_recalculation"
+
+	errorLabel = "error"
+	kindLabel  = "kind"
+
+	// RepositoryKind identifies repository sizes, excluding descendants.
+	RepositoryKind = "repository"
+	// NamespaceKind identifies top-level namespace sizes, including descendants.
+	NamespaceKind = "namespace"
+
+	runDurationName = "run_duration_seconds"
+	runDurationDesc = "A histogram of latencies for size recalculation worker runs."
+	runTotalName    = "runs_total"
+	runTotalDesc    = "A counter for size recalculation worker runs."
+
+	recalculatedTotalName = "recalculated_total"
+	recalculatedTotalDesc = "A counter for sizes recalculated."
+
+	driftTotalName = "drifts_total"
+	driftTotalDesc = "A counter for recalculated sizes that differed from the previously known ones."
+
+	driftBytesTotalName = "drift_bytes_total"
+	driftBytesTotalDesc = "A counter for the absolute difference in bytes between recalculated sizes and the previously known ones."
+)
+
+func init() {
+	runDurationHist = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      runDurationName,
+			Help:      runDurationDesc,
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{errorLabel},
+	)
+
+	runCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      runTotalName,
+			Help:      runTotalDesc,
+		},
+		[]string{errorLabel},
+	)
+
+	recalculatedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      recalculatedTotalName,
+			Help:      recalculatedTotalDesc,
+		},
+		[]string{kindLabel},
+	)
+
+	driftCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      driftTotalName,
+			Help:      driftTotalDesc,
+		},
+		[]string{kindLabel},
+	)
+
+	driftBytesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      driftBytesTotalName,
+			Help:      driftBytesTotalDesc,
+		},
+		[]string{kindLabel},
+	)
+
+	prometheus.MustRegister(runDurationHist)
+	prometheus.MustRegister(runCounter)
+	prometheus.MustRegister(recalculatedCounter)
+	prometheus.MustRegister(driftCounter)
+	prometheus.MustRegister(driftBytesCounter)
+}
+
+// WorkerRun starts measuring a size recalculation worker run. The returned function must be called with the run error
+// (if any) once the run is complete.
+func WorkerRun() func(err error) {
+	start := time.Now()
+	return func(err error) {
+		failed := strconv.FormatBool(err != nil)
+		runCounter.WithLabelValues(failed).Inc()
+		runDurationHist.WithLabelValues(failed).Observe(timeSince(start).Seconds())
+	}
+}
+
+// Recalculated counts a recalculated size of the given kind (RepositoryKind or NamespaceKind). A drift is recorded if
+// previous was known (not nil) and differs from current.
+func Recalculated(kind string, previous *int64, current int64) {
+	recalculatedCounter.WithLabelValues(kind).Inc()
+	if previous == nil || *previous == current {
+		return
+	}
+
+	drift := current - *previous
+	if drift < 0 {
+		drift = -drift
+	}
+	driftCounter.WithLabelValues(kind).Inc()
+	driftBytesCounter.WithLabelValues(kind).Add(float64(drift))
+}
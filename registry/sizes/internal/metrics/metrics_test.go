@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func mockTimeSince(d time.Duration) func() {
+	bkp := timeSince
+	timeSince = func(_ time.Time) time.Duration { return d }
+	return func() { timeSince = bkp }
+}
+
+func TestWorkerRun(t *testing.T) {
+	restore := mockTimeSince(10 * time.Millisecond)
+	defer restore()
+
+	WorkerRun()(nil)
+	WorkerRun()(nil)
+	WorkerRun()(errors.New("foo"))
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_size_recalculation_run_duration_seconds A histogram of latencies for size recalculation worker runs.
+# TYPE registry_size_recalculation_run_duration_seconds histogram
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="0.005"} 0
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="0.01"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="0.025"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="0.05"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="0.1"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="0.25"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="0.5"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="1"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="2.5"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="5"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="10"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="false",le="+Inf"} 2
+registry_size_recalculation_run_duration_seconds_sum{error="false"} 0.02
+registry_size_recalculation_run_duration_seconds_count{error="false"} 2
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="0.005"} 0
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="0.01"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="0.025"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="0.05"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="0.1"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="0.25"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="0.5"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="1"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="2.5"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="5"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="10"} 1
+registry_size_recalculation_run_duration_seconds_bucket{error="true",le="+Inf"} 1
+registry_size_recalculation_run_duration_seconds_sum{error="true"} 0.01
+registry_size_recalculation_run_duration_seconds_count{error="true"} 1
+# HELP registry_size_recalculation_runs_total A counter for size recalculation worker runs.
+# TYPE registry_size_recalculation_runs_total counter
+registry_size_recalculation_runs_total{error="false"} 2
+registry_size_recalculation_runs_total{error="true"} 1
+`)
+	durationFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, runDurationName)
+	totalFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, runTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, durationFullName, totalFullName)
+	require.NoError(t, err)
+}
+
+func TestRecalculated(t *testing.T) {
+	previous := int64(100)
+
+	// unknown previous size
+	Recalculated(RepositoryKind, nil, 50)
+	// no drift
+	Recalculated(RepositoryKind, &previous, 100)
+	// drifts
+	Recalculated(RepositoryKind, &previous, 150)
+	Recalculated(RepositoryKind, &previous, 80)
+	Recalculated(NamespaceKind, &previous, 130)
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_size_recalculation_drift_bytes_total A counter for the absolute difference in bytes between recalculated sizes and the previously known ones.
+# TYPE registry_size_recalculation_drift_bytes_total counter
+registry_size_recalculation_drift_bytes_total{kind="namespace"} 30
+registry_size_recalculation_drift_bytes_total{kind="repository"} 70
+# HELP registry_size_recalculation_drifts_total A counter for recalculated sizes that differed from the previously known ones.
+# TYPE registry_size_recalculation_drifts_total counter
+registry_size_recalculation_drifts_total{kind="namespace"} 1
+registry_size_recalculation_drifts_total{kind="repository"} 2
+# HELP registry_size_recalculation_recalculated_total A counter for sizes recalculated.
+# TYPE registry_size_recalculation_recalculated_total counter
+registry_size_recalculation_recalculated_total{kind="namespace"} 1
+registry_size_recalculation_recalculated_total{kind="repository"} 4
+`)
+	recalculatedFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, recalculatedTotalName)
+	driftFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, driftTotalName)
+	driftBytesFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, driftBytesTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, recalculatedFullName, driftFullName, driftBytesFullName)
+	require.NoError(t, err)
+}
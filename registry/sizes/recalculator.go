@@ -0,0 +1,274 @@
+// Package sizes provides a background worker that recalculates the size of repositories and top-level namespaces,
+// detecting drifts between the sizes cached in Redis and the actual ones.
+package sizes
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/sizes/internal/metrics"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+const (
+	componentKey     = "component"
+	recalculatorName = "registry.sizes.Recalculator"
+
+	defaultInterval  = time.Minute
+	defaultBatchSize = 100
+)
+
+// RepositoryStore is the subset of datastore.RepositoryStore used to recalculate sizes. The store should be configured
+// with the same cache provided to NewRecalculator, so that recalculated sizes replace the cached ones.
+type RepositoryStore interface {
+	FindAllAfterID(ctx context.Context, id int64, limit int) (models.Repositories, error)
+	Size(ctx context.Context, r *models.Repository) (int64, error)
+	SizeWithDescendants(ctx context.Context, r *models.Repository) (int64, error)
+}
+
+// Result holds the outcome of a single Recalculator run.
+type Result struct {
+	// Repositories is the number of repositories whose size was recalculated.
+	Repositories int
+	// Namespaces is the number of top-level namespaces whose size (including descendants) was recalculated.
+	Namespaces int
+	// Drifts is the number of recalculated sizes that differed from the previously known ones.
+	Drifts int
+	// Completed is true if the run reached the last repository, completing a recalculation pass.
+	Completed bool
+}
+
+// Recalculator periodically recalculates the size of all repositories and top-level namespaces in batches, using a
+// checkpoint to resume from where the previous run left off. Recalculated sizes are compared with the previously known
+// ones, found in the repository cache, to detect drifts. These can happen when sizes are not invalidated after changes
+// that happen outside the request flow, such as online garbage collection.
+type Recalculator struct {
+	store       RepositoryStore
+	cache       datastore.RepositoryCache
+	checkpoints CheckpointStore
+	logger      log.Logger
+	interval    time.Duration
+	batchSize   int
+
+	trigger chan struct{}
+
+	mu        sync.Mutex
+	lastRunAt time.Time
+}
+
+// RecalculatorOption provides functional options for NewRecalculator.
+type RecalculatorOption func(*Recalculator)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) RecalculatorOption {
+	return func(rc *Recalculator) {
+		rc.logger = l
+	}
+}
+
+// WithInterval sets the interval between runs. Defaults to 1 minute.
+func WithInterval(d time.Duration) RecalculatorOption {
+	return func(rc *Recalculator) {
+		rc.interval = d
+	}
+}
+
+// WithBatchSize sets the maximum number of repositories processed in each run. Defaults to 100.
+func WithBatchSize(n int) RecalculatorOption {
+	return func(rc *Recalculator) {
+		rc.batchSize = n
+	}
+}
+
+func (rc *Recalculator) applyDefaults() {
+	if rc.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		rc.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if rc.interval == 0 {
+		rc.interval = defaultInterval
+	}
+	if rc.batchSize == 0 {
+		rc.batchSize = defaultBatchSize
+	}
+}
+
+// NewRecalculator creates a new Recalculator.
+func NewRecalculator(store RepositoryStore, cache datastore.RepositoryCache, checkpoints CheckpointStore, opts ...RecalculatorOption) *Recalculator {
+	rc := &Recalculator{
+		store:       store,
+		cache:       cache,
+		checkpoints: checkpoints,
+		trigger:     make(chan struct{}, 1),
+	}
+	rc.applyDefaults()
+
+	for _, opt := range opts {
+		opt(rc)
+	}
+
+	rc.logger = rc.logger.WithFields(log.Fields{componentKey: recalculatorName})
+
+	return rc
+}
+
+// Run recalculates the size of the next batch of repositories after the current checkpoint. The checkpoint is advanced
+// up to the last successfully processed repository, even if an error occurs. Once the last repository is processed,
+// the checkpoint is reset, so that the next run starts a new pass.
+func (rc *Recalculator) Run(ctx context.Context) (*Result, error) {
+	id, err := rc.checkpoints.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rr, err := rc.store.FindAllAfterID(ctx, id, rc.batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for _, r := range rr {
+		if err = rc.recalculate(ctx, r, res); err != nil {
+			break
+		}
+		id = r.ID
+	}
+	if err == nil && len(rr) < rc.batchSize {
+		res.Completed = true
+		id = 0
+	}
+
+	if setErr := rc.checkpoints.Set(ctx, id); setErr != nil && err == nil {
+		err = setErr
+	}
+
+	return res, err
+}
+
+func (rc *Recalculator) recalculate(ctx context.Context, r *models.Repository, res *Result) error {
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"repository_id": r.ID, "repository_path": r.Path})
+
+	var previous *int64
+	if cached := rc.cache.Get(ctx, r.Path); cached != nil && cached.ID == r.ID {
+		previous = cached.Size
+	}
+	size, err := rc.store.Size(ctx, r)
+	if err != nil {
+		return err
+	}
+	metrics.Recalculated(metrics.RepositoryKind, previous, size)
+	res.Repositories++
+	if previous != nil && *previous != size {
+		res.Drifts++
+		l.WithFields(log.Fields{"previous_size_bytes": *previous, "size_bytes": size}).Info("repository size drift detected")
+	}
+
+	if !r.IsTopLevel() {
+		return nil
+	}
+
+	previous = nil
+	if s, ok := rc.cache.GetSizeWithDescendants(ctx, r); ok {
+		previous = &s
+	}
+	size, err = rc.store.SizeWithDescendants(ctx, r)
+	if err != nil {
+		if errors.Is(err, datastore.ErrSizeHasTimedOut) {
+			// avoid consecutive failures, the namespace size will be recalculated during the next pass
+			l.WithError(err).Info("skipping namespace size recalculation")
+			return nil
+		}
+		return err
+	}
+	metrics.Recalculated(metrics.NamespaceKind, previous, size)
+	res.Namespaces++
+	if previous != nil && *previous != size {
+		res.Drifts++
+		l.WithFields(log.Fields{"previous_size_bytes": *previous, "size_bytes": size}).Info("namespace size drift detected")
+	}
+
+	return nil
+}
+
+// Trigger requests an immediate run, without waiting for the configured interval. Requests made while a run is already
+// pending are coalesced. This method may be called safely from multiple concurrent goroutines.
+func (rc *Recalculator) Trigger() {
+	select {
+	case rc.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Reset resets the checkpoint, so that the next run starts a new recalculation pass.
+func (rc *Recalculator) Reset(ctx context.Context) error {
+	return rc.checkpoints.Set(ctx, 0)
+}
+
+// Checkpoint returns the ID of the last repository processed in the current pass, or zero if a new pass is due.
+func (rc *Recalculator) Checkpoint(ctx context.Context) (int64, error) {
+	return rc.checkpoints.Get(ctx)
+}
+
+// LastRunAt returns the time at which the last run by this Recalculator completed, or the zero time if there was none.
+func (rc *Recalculator) LastRunAt() time.Time {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.lastRunAt
+}
+
+// Start starts the Recalculator. This is a blocking call that runs the recalculation every configured interval, or
+// immediately when requested through Trigger, until the provided context is canceled.
+func (rc *Recalculator) Start(ctx context.Context) error {
+	rc.logger.WithFields(log.Fields{"interval_s": rc.interval.Seconds(), "batch_size": rc.batchSize}).Info("starting size recalculation")
+
+	t := time.NewTicker(rc.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			rc.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-t.C:
+		case <-rc.trigger:
+		}
+
+		id := correlation.SafeRandomID()
+		rCtx := correlation.ContextWithCorrelation(ctx, id)
+		l := rc.logger.WithFields(log.Fields{correlation.FieldName: id})
+		rCtx = log.WithLogger(rCtx, l)
+
+		start := time.Now()
+		report := metrics.WorkerRun()
+		res, err := rc.Run(rCtx)
+		report(err)
+
+		rc.mu.Lock()
+		rc.lastRunAt = time.Now()
+		rc.mu.Unlock()
+
+		if res != nil {
+			l = l.WithFields(log.Fields{
+				"repositories": res.Repositories,
+				"namespaces":   res.Namespaces,
+				"drifts":       res.Drifts,
+				"completed":    res.Completed,
+			})
+		}
+		l = l.WithFields(log.Fields{"duration_s": time.Since(start).Seconds()})
+		if err != nil {
+			l.WithError(err).Error("size recalculation run failed")
+			continue
+		}
+		l.Info("size recalculation run complete")
+	}
+}
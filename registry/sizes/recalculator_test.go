@@ -0,0 +1,217 @@
+package sizes_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/docker/distribution/registry/sizes"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepositoryStore is a sizes.RepositoryStore that serves a fixed list of repositories, sorted by ID, and their
+// sizes. Like the real store, recalculated sizes are written to the cache.
+type fakeRepositoryStore struct {
+	cache     datastore.RepositoryCache
+	repos     models.Repositories
+	sizes     map[int64]int64
+	swdSizes  map[int64]int64
+	swdErrors map[int64]error
+	sizeErr   error
+}
+
+func (s *fakeRepositoryStore) FindAllAfterID(_ context.Context, id int64, limit int) (models.Repositories, error) {
+	rr := make(models.Repositories, 0, limit)
+	for _, r := range s.repos {
+		if r.ID > id && len(rr) < limit {
+			// return copies, as the real store does
+			cp := *r
+			rr = append(rr, &cp)
+		}
+	}
+	return rr, nil
+}
+
+func (s *fakeRepositoryStore) Size(ctx context.Context, r *models.Repository) (int64, error) {
+	if s.sizeErr != nil {
+		return 0, s.sizeErr
+	}
+	size := s.sizes[r.ID]
+	r.Size = &size
+	s.cache.Set(ctx, r)
+	return size, nil
+}
+
+func (s *fakeRepositoryStore) SizeWithDescendants(ctx context.Context, r *models.Repository) (int64, error) {
+	if err := s.swdErrors[r.ID]; err != nil {
+		return 0, err
+	}
+	size := s.swdSizes[r.ID]
+	s.cache.SetSizeWithDescendants(ctx, r, size)
+	return size, nil
+}
+
+func newTestRecalculator(t *testing.T) (*sizes.Recalculator, *fakeRepositoryStore, datastore.RepositoryCache) {
+	t.Helper()
+
+	c := testutil.NewRedisCacheController(t, 0)
+	cache := datastore.NewCentralRepositoryCache(c.Cache)
+	store := &fakeRepositoryStore{
+		cache: cache,
+		repos: models.Repositories{
+			{ID: 1, NamespaceID: 1, Name: "a", Path: "a"},
+			{ID: 2, NamespaceID: 1, Name: "b", Path: "a/b"},
+			{ID: 3, NamespaceID: 2, Name: "c", Path: "c"},
+		},
+		sizes:     map[int64]int64{1: 10, 2: 20, 3: 30},
+		swdSizes:  map[int64]int64{1: 25, 3: 30},
+		swdErrors: make(map[int64]error),
+	}
+
+	rc := sizes.NewRecalculator(store, cache, sizes.NewMemoryCheckpointStore(), sizes.WithBatchSize(2))
+
+	return rc, store, cache
+}
+
+func TestRecalculator_Run(t *testing.T) {
+	rc, store, cache := newTestRecalculator(t)
+	ctx := context.Background()
+
+	// cache sizes that drifted from the actual ones (repository a/b and namespace a), or not (repository a)
+	size := int64(10)
+	cache.Set(ctx, &models.Repository{ID: 1, NamespaceID: 1, Name: "a", Path: "a", Size: &size})
+	size = 15
+	cache.Set(ctx, &models.Repository{ID: 2, NamespaceID: 1, Name: "b", Path: "a/b", Size: &size})
+	cache.SetSizeWithDescendants(ctx, store.repos[0], 40)
+
+	// first batch
+	res, err := rc.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &sizes.Result{Repositories: 2, Namespaces: 1, Drifts: 2}, res)
+
+	checkpoint, err := rc.Checkpoint(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), checkpoint)
+
+	// recalculated sizes replace the cached ones
+	cached := cache.Get(ctx, "a/b")
+	require.NotNil(t, cached)
+	require.NotNil(t, cached.Size)
+	require.Equal(t, int64(20), *cached.Size)
+	swd, ok := cache.GetSizeWithDescendants(ctx, store.repos[0])
+	require.True(t, ok)
+	require.Equal(t, int64(25), swd)
+
+	// last batch completes the pass and resets the checkpoint
+	res, err = rc.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &sizes.Result{Repositories: 1, Namespaces: 1, Completed: true}, res)
+
+	checkpoint, err = rc.Checkpoint(ctx)
+	require.NoError(t, err)
+	require.Zero(t, checkpoint)
+
+	// a new pass finds no drifts
+	res, err = rc.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &sizes.Result{Repositories: 2, Namespaces: 1}, res)
+}
+
+func TestRecalculator_Run_CachedRepositoryMismatch(t *testing.T) {
+	rc, _, cache := newTestRecalculator(t)
+	ctx := context.Background()
+
+	// a cached repository with the same path but a different ID was since deleted and should be ignored
+	size := int64(100)
+	cache.Set(ctx, &models.Repository{ID: 10, NamespaceID: 1, Name: "b", Path: "a/b", Size: &size})
+
+	res, err := rc.Run(ctx)
+	require.NoError(t, err)
+	require.Zero(t, res.Drifts)
+}
+
+func TestRecalculator_Run_SizeWithDescendantsTimedOut(t *testing.T) {
+	rc, store, _ := newTestRecalculator(t)
+	ctx := context.Background()
+
+	store.swdErrors[1] = datastore.ErrSizeHasTimedOut
+
+	res, err := rc.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &sizes.Result{Repositories: 2}, res)
+}
+
+func TestRecalculator_Run_Error(t *testing.T) {
+	rc, store, _ := newTestRecalculator(t)
+	ctx := context.Background()
+
+	// the checkpoint is only advanced up to the last successfully processed repository
+	fooErr := errors.New("foo")
+	store.swdErrors[1] = fooErr
+
+	res, err := rc.Run(ctx)
+	require.ErrorIs(t, err, fooErr)
+	require.Equal(t, &sizes.Result{Repositories: 1}, res)
+
+	checkpoint, err := rc.Checkpoint(ctx)
+	require.NoError(t, err)
+	require.Zero(t, checkpoint)
+
+	delete(store.swdErrors, 1)
+	store.swdErrors[3] = fooErr
+
+	_, err = rc.Run(ctx)
+	require.NoError(t, err)
+	_, err = rc.Run(ctx)
+	require.ErrorIs(t, err, fooErr)
+
+	checkpoint, err = rc.Checkpoint(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), checkpoint)
+}
+
+func TestRecalculator_Reset(t *testing.T) {
+	rc, _, _ := newTestRecalculator(t)
+	ctx := context.Background()
+
+	_, err := rc.Run(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, rc.Reset(ctx))
+	checkpoint, err := rc.Checkpoint(ctx)
+	require.NoError(t, err)
+	require.Zero(t, checkpoint)
+}
+
+func TestRecalculator_Start_Trigger(t *testing.T) {
+	c := testutil.NewRedisCacheController(t, 0)
+	cache := datastore.NewCentralRepositoryCache(c.Cache)
+	store := &fakeRepositoryStore{
+		cache: cache,
+		repos: models.Repositories{{ID: 1, NamespaceID: 1, Name: "a", Path: "a/b"}},
+		sizes: map[int64]int64{1: 10},
+	}
+	rc := sizes.NewRecalculator(store, cache, sizes.NewMemoryCheckpointStore(), sizes.WithInterval(time.Hour))
+	require.True(t, rc.LastRunAt().IsZero())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- rc.Start(ctx) }()
+
+	// no run happens before the interval elapses, unless triggered
+	rc.Trigger()
+	require.Eventually(t, func() bool { return !rc.LastRunAt().IsZero() }, time.Second, 10*time.Millisecond)
+	require.NotNil(t, cache.Get(ctx, "a/b"))
+
+	cancel()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("size recalculation did not stop after cancelling the context")
+	}
+}
@@ -2,11 +2,15 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/docker/distribution"
+	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/log"
 	"github.com/docker/distribution/notifications/meta"
 	"github.com/docker/distribution/registry/storage/driver"
@@ -17,8 +21,27 @@ import (
 const blobCacheControlMaxAge = 365 * 24 * time.Hour
 
 type redirect struct {
-	enabled     bool          // allows toggling redirects to storage backends for blob downloads
-	expiryDelay time.Duration // allows setting a custom delay for the presigned URLs expiration (defaults to 20m)
+	enabled          bool          // allows toggling redirects to storage backends for blob downloads
+	expiryDelay      time.Duration // allows setting a custom delay for the presigned URLs expiration (defaults to 20m)
+	clientExceptions []*net.IPNet  // clients within these ranges are not redirected
+}
+
+// allowsClient returns whether the client of r can be redirected, based on its IP address.
+func (rd redirect) allowsClient(r *http.Request) bool {
+	if len(rd.clientExceptions) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(dcontext.RemoteIP(r))
+	if ip == nil {
+		return true
+	}
+	for _, n := range rd.clientExceptions {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
 }
 
 // blobServer simply serves blobs from a driver instance using a path function
@@ -45,9 +68,12 @@ func (bs *blobServer) ServeBlob(ctx context.Context, w http.ResponseWriter, r *h
 		"size_bytes": desc.Size,
 		"digest":     desc.Digest,
 	})
+	if rng := r.Header.Get("Range"); rng != "" {
+		l = l.WithFields(log.Fields{"range": rng})
+	}
 
 	var redirect bool
-	if bs.redirect.enabled {
+	if bs.redirect.enabled && bs.redirect.allowsClient(r) {
 		opts := map[string]interface{}{"method": r.Method}
 		if bs.redirect.expiryDelay > 0 {
 			opts["expiry"] = time.Now().Add(bs.redirect.expiryDelay)
@@ -60,7 +86,8 @@ func (bs *blobServer) ServeBlob(ctx context.Context, w http.ResponseWriter, r *h
 		redirectURL, err := bs.driver.URLFor(ctx, path, opts)
 		switch err.(type) {
 		case nil:
-			// Redirect to storage URL.
+			// Redirect to storage URL. Clients resend any Range header when following the redirect, so ranged requests are
+			// served by the storage backend, which is then responsible for the Content-Range of the partial response.
 			http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 			redirect = true
 			metrics.BlobDownload(redirect, desc.Size)
@@ -77,7 +104,12 @@ func (bs *blobServer) ServeBlob(ctx context.Context, w http.ResponseWriter, r *h
 		}
 	}
 
-	br, err := newFileReader(ctx, bs.driver, path, desc.Size)
+	// Ranged requests are served without read-ahead buffering, as they usually target a small portion of the blob.
+	newReader := newFileReader
+	if r.Header.Get("Range") != "" {
+		newReader = newUnbufferedFileReader
+	}
+	br, err := newReader(ctx, bs.driver, path, desc.Size)
 	if err != nil {
 		return nil, err
 	}
@@ -95,12 +127,15 @@ func (bs *blobServer) ServeBlob(ctx context.Context, w http.ResponseWriter, r *h
 		w.Header().Set("Content-Type", desc.MediaType)
 	}
 
-	if w.Header().Get("Content-Length") == "" {
-		// Set the content length if not already set.
-		w.Header().Set("Content-Length", fmt.Sprint(desc.Size))
+	// The Content-Length is set by http.ServeContent, as it depends on the requested ranges, if any. Setting it here
+	// would result in a mismatch between the header and the body of partial and unsatisfiable range responses.
+	vr := newVerifyingReader(br, desc.Digest, desc.Size)
+	http.ServeContent(w, r, desc.Digest.String(), time.Time{}, vr)
+	if vr.err != nil {
+		// the response was already started, so all that can be done is leaving it short of its Content-Length
+		l.WithError(vr.err).Error("blob content does not match its digest")
+		return &meta.Blob{StorageBackend: bs.driver.Name(), Redirected: redirect}, nil
 	}
-
-	http.ServeContent(w, r, desc.Digest.String(), time.Time{}, br)
 	metrics.BlobDownload(redirect, desc.Size)
 	if r.Method == http.MethodGet {
 		l.WithFields(log.Fields{"redirect": redirect}).Info("blob downloaded")
@@ -108,3 +143,70 @@ func (bs *blobServer) ServeBlob(ctx context.Context, w http.ResponseWriter, r *h
 
 	return &meta.Blob{StorageBackend: bs.driver.Name(), Redirected: redirect}, nil
 }
+
+// verifyingReader verifies the content of a blob against its digest while it is read sequentially from the start. The
+// read that would complete a blob that does not match its digest fails instead, so that corrupted content is never
+// served whole. Seeking anywhere other than the start, as done to serve ranged requests, disables verification, as
+// the content is then only read in part.
+type verifyingReader struct {
+	io.ReadSeeker
+	dgst     digest.Digest
+	size     int64
+	verifier digest.Verifier
+	offset   int64
+	err      error
+}
+
+func newVerifyingReader(rs io.ReadSeeker, dgst digest.Digest, size int64) *verifyingReader {
+	vr := &verifyingReader{ReadSeeker: rs, dgst: dgst, size: size}
+	vr.reset()
+	return vr
+}
+
+// reset restarts verification, if the digest algorithm is available.
+func (vr *verifyingReader) reset() {
+	vr.verifier = nil
+	vr.offset = 0
+	if vr.dgst.Algorithm().Available() {
+		vr.verifier = vr.dgst.Verifier()
+	}
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	if vr.err != nil {
+		return 0, vr.err
+	}
+
+	n, err := vr.ReadSeeker.Read(p)
+	if vr.verifier == nil {
+		return n, err
+	}
+
+	// writes to a digest verifier never fail
+	_, _ = vr.verifier.Write(p[:n])
+	vr.offset += int64(n)
+	if vr.offset >= vr.size {
+		if !vr.verifier.Verified() {
+			vr.err = distribution.ErrBlobInvalidDigest{Digest: vr.dgst, Reason: errors.New("content does not match digest")}
+			return 0, vr.err
+		}
+		vr.verifier = nil
+	}
+
+	return n, err
+}
+
+func (vr *verifyingReader) Seek(offset int64, whence int) (int64, error) {
+	n, err := vr.ReadSeeker.Seek(offset, whence)
+	if err != nil {
+		return n, err
+	}
+
+	if n == 0 {
+		vr.reset()
+	} else {
+		vr.verifier = nil
+	}
+
+	return n, nil
+}
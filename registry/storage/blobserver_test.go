@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+type staticBlobStatter distribution.Descriptor
+
+func (s staticBlobStatter) Stat(_ context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	if dgst != s.Digest {
+		return distribution.Descriptor{}, distribution.ErrBlobUnknown
+	}
+	return distribution.Descriptor(s), nil
+}
+
+// newTestBlobServer returns a blob server for a single blob with digest dgst, stored with the given content.
+func newTestBlobServer(t *testing.T, dgst digest.Digest, content []byte) *blobServer {
+	t.Helper()
+
+	d := inmemory.New()
+	path := "/blobs/" + dgst.Encoded()
+	require.NoError(t, d.PutContent(context.Background(), path, content))
+
+	return &blobServer{
+		driver:  d,
+		statter: staticBlobStatter{Digest: dgst, Size: int64(len(content)), MediaType: "application/octet-stream"},
+		pathFn:  func(digest.Digest) (string, error) { return path, nil },
+	}
+}
+
+func serveTestBlob(t *testing.T, bs *blobServer, dgst digest.Digest, rng string) *http.Response {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if rng != "" {
+		r.Header.Set("Range", rng)
+	}
+	w := httptest.NewRecorder()
+	_, err := bs.ServeBlob(context.Background(), w, r, dgst)
+	require.NoError(t, err)
+
+	return w.Result()
+}
+
+func TestBlobServer_ServeBlob(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	dgst := digest.FromBytes(content)
+	bs := newTestBlobServer(t, dgst, content)
+
+	resp := serveTestBlob(t, bs, dgst, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, fmt.Sprint(len(content)), resp.Header.Get("Content-Length"))
+	require.Equal(t, dgst.String(), resp.Header.Get("Docker-Content-Digest"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, content, body)
+}
+
+func TestBlobServer_ServeBlob_Range(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	dgst := digest.FromBytes(content)
+	bs := newTestBlobServer(t, dgst, content)
+
+	resp := serveTestBlob(t, bs, dgst, "bytes=5-9")
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "5", resp.Header.Get("Content-Length"))
+	require.Equal(t, fmt.Sprintf("bytes 5-9/%d", len(content)), resp.Header.Get("Content-Range"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, content[5:10], body)
+
+	resp = serveTestBlob(t, bs, dgst, fmt.Sprintf("bytes=%d-", len(content)))
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	require.Equal(t, fmt.Sprintf("bytes */%d", len(content)), resp.Header.Get("Content-Range"))
+}
+
+func TestBlobServer_ServeBlob_MultipleRanges(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	dgst := digest.FromBytes(content)
+	bs := newTestBlobServer(t, dgst, content)
+
+	resp := serveTestBlob(t, bs, dgst, "bytes=0-1,5-9,-3")
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/byteranges", mediaType)
+
+	expected := []struct {
+		first, last int
+	}{{0, 1}, {5, 9}, {17, 19}}
+
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for _, e := range expected {
+		p, err := mr.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, "application/octet-stream", p.Header.Get("Content-Type"))
+		require.Equal(t, fmt.Sprintf("bytes %d-%d/%d", e.first, e.last, len(content)), p.Header.Get("Content-Range"))
+		body, err := io.ReadAll(p)
+		require.NoError(t, err)
+		require.Equal(t, content[e.first:e.last+1], body)
+	}
+	_, err = mr.NextPart()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestBlobServer_ServeBlob_DigestMismatch(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	dgst := digest.FromBytes(content)
+	corrupted := []byte("0123456789abcdefghiJ")
+	bs := newTestBlobServer(t, dgst, corrupted)
+
+	// the response is left short of its Content-Length, without the read that would complete it
+	resp := serveTestBlob(t, bs, dgst, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, fmt.Sprint(len(content)), resp.Header.Get("Content-Length"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Less(t, len(body), len(content))
+
+	// partial content can't be verified, so ranges are still served
+	resp = serveTestBlob(t, bs, dgst, "bytes=15-19")
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, corrupted[15:], body)
+}
+
+func TestVerifyingReader(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	dgst := digest.FromBytes(content)
+
+	newReader := func(content []byte) *verifyingReader {
+		return newVerifyingReader(bytes.NewReader(content), dgst, int64(len(content)))
+	}
+
+	// matching content
+	vr := newReader(content)
+	p, err := io.ReadAll(vr)
+	require.NoError(t, err)
+	require.Equal(t, content, p)
+	require.NoError(t, vr.err)
+
+	// mismatching content
+	vr = newReader([]byte("0123456789abcdefghiJ"))
+	_, err = io.ReadAll(vr)
+	var invalidErr distribution.ErrBlobInvalidDigest
+	require.ErrorAs(t, err, &invalidErr)
+	require.Equal(t, dgst, invalidErr.Digest)
+
+	// seeking away from the start disables verification, and seeking back restarts it
+	vr = newReader([]byte("0123456789abcdefghiJ"))
+	_, err = vr.Seek(10, io.SeekStart)
+	require.NoError(t, err)
+	p, err = io.ReadAll(vr)
+	require.NoError(t, err)
+	require.Equal(t, []byte("abcdefghiJ"), p)
+	_, err = vr.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadAll(vr)
+	require.ErrorAs(t, err, &invalidErr)
+}
@@ -42,6 +42,9 @@ type blobWriter struct {
 
 	resumableDigestEnabled bool
 	committed              bool
+
+	// session is the shared state of the upload, if saved in an upload session store
+	session *UploadSession
 }
 
 var _ distribution.BlobWriter = &blobWriter{}
@@ -369,6 +372,13 @@ func (bw *blobWriter) removeResources(ctx context.Context) error {
 		return err
 	}
 
+	if s := bw.blobStore.uploadSessions; s != nil {
+		if err := s.Delete(ctx, bw.blobStore.repository.Named().Name(), bw.id); err != nil {
+			// sessions expire, so there is no need to fail here
+			dcontext.GetLogger(ctx).WithError(err).Warn("failed to delete upload session")
+		}
+	}
+
 	// Resolve and delete the containing directory, which should include any
 	// upload related files.
 	dirPath := path.Dir(dataPath)
@@ -388,6 +398,24 @@ func (bw *blobWriter) removeResources(ctx context.Context) error {
 	return nil
 }
 
+// saveSession records the current offset and hash state of the upload in the upload session store, if any. Failures
+// are only logged, as the upload can still be resumed from the storage backend metadata.
+func (bw *blobWriter) saveSession(ctx context.Context, hashState []byte) {
+	s := bw.blobStore.uploadSessions
+	if s == nil {
+		return
+	}
+	if bw.session == nil {
+		bw.session = &UploadSession{Repository: bw.blobStore.repository.Named().Name(), ID: bw.id, StartedAt: bw.startedAt}
+	}
+	bw.session.Offset = bw.written
+	bw.session.HashState = hashState
+
+	if err := s.Set(ctx, bw.session); err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Warn("failed to save upload session")
+	}
+}
+
 func (bw *blobWriter) Reader() (io.ReadCloser, error) {
 	// todo(richardscothern): Change to exponential backoff, i=0.5, e=2, n=4
 	try := 1
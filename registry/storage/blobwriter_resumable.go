@@ -32,6 +32,16 @@ func (bw *blobWriter) resumeDigest(ctx context.Context) error {
 		return nil
 	}
 
+	// Prefer the hash state of the shared upload session, if any, as the ones in the storage backend may not be
+	// visible to this instance.
+	if s := bw.session; s != nil && s.Offset == offset && len(s.HashState) > 0 {
+		if err := h.UnmarshalBinary(s.HashState); err != nil {
+			return err
+		}
+		bw.written = offset
+		return nil
+	}
+
 	// List hash states from storage backend.
 	var hashStateMatch hashStateEntry
 	hashStates, err := bw.getStoredHashStates(ctx)
@@ -140,5 +150,10 @@ func (bw *blobWriter) storeHashState(ctx context.Context) error {
 		return err
 	}
 
-	return bw.driver.PutContent(ctx, uploadHashStatePath, state)
+	if err := bw.driver.PutContent(ctx, uploadHashStatePath, state); err != nil {
+		return err
+	}
+	bw.saveSession(ctx, state)
+
+	return nil
 }
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/distribution"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// DirectUploadURLs returns n URLs, valid for the given expiry, to which clients can upload the data of the upload with
+// the given UUID in the named repository directly to the storage backend, as consecutive parts. The upload must have
+// been created and closed, but no data written to it yet. Once all parts are uploaded, the upload is completed as usual,
+// by resuming and committing it, which verifies the uploaded data against the expected digest.
+// distribution.ErrUnsupported is returned if driver does not implement storagedriver.DirectUploader.
+func DirectUploadURLs(ctx context.Context, driver storagedriver.StorageDriver, name, id string, n int, expiry time.Duration) ([]string, error) {
+	du, ok := driver.(storagedriver.DirectUploader)
+	if !ok {
+		return nil, distribution.ErrUnsupported
+	}
+
+	dataPath, err := pathFor(uploadDataPathSpec{name: name, id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return du.DirectUploadURLs(ctx, dataPath, n, expiry)
+}
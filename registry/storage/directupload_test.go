@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+// directUploadDriver is an in-memory storage driver that returns fake direct upload URLs, identifying the path, part
+// number and expiry.
+type directUploadDriver struct {
+	*inmemory.Driver
+}
+
+func (d *directUploadDriver) DirectUploadURLs(_ context.Context, path string, n int, expiry time.Duration) ([]string, error) {
+	urls := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		urls = append(urls, fmt.Sprintf("https://storage.example.com%s?part=%d&expiry=%s", path, i, expiry))
+	}
+	return urls, nil
+}
+
+func TestDirectUploadURLs(t *testing.T) {
+	ctx := context.Background()
+	d := &directUploadDriver{inmemory.New()}
+
+	urls, err := DirectUploadURLs(ctx, d, "foo/bar", "abc", 2, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"https://storage.example.com/docker/registry/v2/repositories/foo/bar/_uploads/abc/data?part=1&expiry=1h0m0s",
+		"https://storage.example.com/docker/registry/v2/repositories/foo/bar/_uploads/abc/data?part=2&expiry=1h0m0s",
+	}, urls)
+}
+
+func TestDirectUploadURLs_Unsupported(t *testing.T) {
+	_, err := DirectUploadURLs(context.Background(), inmemory.New(), "foo/bar", "abc", 1, time.Hour)
+	require.ErrorIs(t, err, distribution.ErrUnsupported)
+}
@@ -45,7 +45,10 @@ import (
 	dcontext "github.com/docker/distribution/context"
 	prometheus "github.com/docker/distribution/metrics"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/tracing"
 	"github.com/docker/go-metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -90,10 +93,20 @@ func (base *Base) setDriverName(e error) error {
 	}
 }
 
+// startSpan starts a tracing span for the storage driver operation op on path.
+func (base *Base) startSpan(ctx context.Context, op, path string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, "storage."+op, trace.WithAttributes(
+		attribute.String("storage.driver", base.Name()),
+		attribute.String("storage.path", path),
+	))
+}
+
 // GetContent wraps GetContent of underlying storage driver.
 func (base *Base) GetContent(ctx context.Context, path string) ([]byte, error) {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.GetContent(%q)", base.Name(), path)
+	ctx, span := base.startSpan(ctx, "GetContent", path)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(path) {
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
@@ -109,6 +122,8 @@ func (base *Base) GetContent(ctx context.Context, path string) ([]byte, error) {
 func (base *Base) PutContent(ctx context.Context, path string, content []byte) error {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.PutContent(%q)", base.Name(), path)
+	ctx, span := base.startSpan(ctx, "PutContent", path)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(path) {
 		return storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
@@ -124,6 +139,8 @@ func (base *Base) PutContent(ctx context.Context, path string, content []byte) e
 func (base *Base) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.Reader(%q, %d)", base.Name(), path, offset)
+	ctx, span := base.startSpan(ctx, "Reader", path)
+	defer span.End()
 
 	if offset < 0 {
 		return nil, storagedriver.InvalidOffsetError{Path: path, Offset: offset, DriverName: base.StorageDriver.Name()}
@@ -141,6 +158,8 @@ func (base *Base) Reader(ctx context.Context, path string, offset int64) (io.Rea
 func (base *Base) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.Writer(%q, %v)", base.Name(), path, append)
+	ctx, span := base.startSpan(ctx, "Writer", path)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(path) {
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
@@ -154,6 +173,8 @@ func (base *Base) Writer(ctx context.Context, path string, append bool) (storage
 func (base *Base) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.Stat(%q)", base.Name(), path)
+	ctx, span := base.startSpan(ctx, "Stat", path)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(path) && path != "/" {
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
@@ -169,6 +190,8 @@ func (base *Base) Stat(ctx context.Context, path string) (storagedriver.FileInfo
 func (base *Base) List(ctx context.Context, path string) ([]string, error) {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.List(%q)", base.Name(), path)
+	ctx, span := base.startSpan(ctx, "List", path)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(path) && path != "/" {
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
@@ -184,6 +207,8 @@ func (base *Base) List(ctx context.Context, path string) ([]string, error) {
 func (base *Base) Move(ctx context.Context, sourcePath string, destPath string) error {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.Move(%q, %q", base.Name(), sourcePath, destPath)
+	ctx, span := base.startSpan(ctx, "Move", sourcePath)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(sourcePath) {
 		return storagedriver.InvalidPathError{Path: sourcePath, DriverName: base.StorageDriver.Name()}
@@ -201,6 +226,8 @@ func (base *Base) Move(ctx context.Context, sourcePath string, destPath string)
 func (base *Base) Delete(ctx context.Context, path string) error {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.Delete(%q)", base.Name(), path)
+	ctx, span := base.startSpan(ctx, "Delete", path)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(path) {
 		return storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
@@ -216,6 +243,8 @@ func (base *Base) Delete(ctx context.Context, path string) error {
 func (base *Base) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.URLFor(%q)", base.Name(), path)
+	ctx, span := base.startSpan(ctx, "URLFor", path)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(path) {
 		return "", storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
@@ -231,6 +260,8 @@ func (base *Base) URLFor(ctx context.Context, path string, options map[string]in
 func (base *Base) Walk(ctx context.Context, path string, f storagedriver.WalkFn) error {
 	ctx, done := dcontext.WithTrace(ctx)
 	defer done("%s.Walk(%q)", base.Name(), path)
+	ctx, span := base.startSpan(ctx, "Walk", path)
+	defer span.End()
 
 	if !storagedriver.PathRegexp.MatchString(path) && path != "/" {
 		return storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
@@ -285,7 +285,7 @@ func (d *driver) Name() string {
 func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
 	name := d.pathToKey(path)
 	var rc io.ReadCloser
-	err := retry(func() error {
+	err := call(func() error {
 		var err error
 		rc, err = d.storageClient.Bucket(d.bucket).Object(name).NewReader(ctx)
 		return err
@@ -308,7 +308,7 @@ func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
 // PutContent stores the []byte content at a location designated by "path".
 // This should primarily be used for small objects.
 func (d *driver) PutContent(ctx context.Context, path string, contents []byte) error {
-	return retry(func() error {
+	return call(func() error {
 		wc := d.storageClient.Bucket(d.bucket).Object(d.pathToKey(path)).NewWriter(ctx)
 		wc.ContentType = "application/octet-stream"
 		h := md5.New()
@@ -375,7 +375,7 @@ func getObject(client *http.Client, bucket string, name string, offset int64) (*
 		req.Header.Set("Range", fmt.Sprintf("bytes=%v-", offset))
 	}
 	var res *http.Response
-	err = retry(func() error {
+	err = call(func() error {
 		var err error
 		res, err = client.Do(req)
 		return err
@@ -625,35 +625,42 @@ func (w *writer) init(path string) error {
 
 type request func() error
 
+// call issues a request, translating rate limiting errors. Failed requests are not retried, which is left to the retry
+// storage middleware.
+func call(req request) error {
+	err := req()
+
+	var gerr *googleapi.Error
+	if ok := errors.As(err, &gerr); ok && gerr.Code == http.StatusTooManyRequests {
+		metrics.StorageRatelimit()
+		return storagedriver.TooManyRequestsError{Cause: err}
+	}
+
+	return err
+}
+
+// retry issues a request like call, retrying it with exponential backoff while it fails with a server or rate limiting
+// error. It is used for the requests of file writers once opened, which the retry storage middleware cannot retry.
 func retry(req request) error {
 	backoff := time.Second
 	var err error
 	for i := 0; i < maxTries; i++ {
-		err = req()
+		err = call(req)
 		if err == nil {
 			return nil
 		}
 
-		status, ok := err.(*googleapi.Error)
-		if !ok || (status.Code != 429 && status.Code < http.StatusInternalServerError) {
+		var gerr *googleapi.Error
+		if !errors.As(err, &gerr) || (gerr.Code != http.StatusTooManyRequests && gerr.Code < http.StatusInternalServerError) {
 			return err
 		}
 
-		if status.Code == http.StatusTooManyRequests {
-			metrics.StorageRatelimit()
-		}
-
-		time.Sleep(backoff - time.Second + (time.Duration(rand.Int31n(1000)) * time.Millisecond))
-		if i <= 4 {
+		if i < maxTries-1 {
+			time.Sleep(backoff - time.Second + (time.Duration(rand.Int31n(1000)) * time.Millisecond))
 			backoff = backoff * 2
 		}
 	}
 
-	var gerr *googleapi.Error
-	if ok := errors.As(err, &gerr); ok && gerr.Code == http.StatusTooManyRequests {
-		return storagedriver.TooManyRequestsError{Cause: err}
-	}
-
 	return err
 }
 
@@ -899,14 +906,14 @@ func (d *driver) DeleteFiles(ctx context.Context, paths []string) (int, error) {
 }
 
 func storageDeleteObject(ctx context.Context, client *storage.Client, bucket string, name string) error {
-	return retry(func() error {
+	return call(func() error {
 		return client.Bucket(bucket).Object(name).Delete(ctx)
 	})
 }
 
 func storageStatObject(ctx context.Context, client *storage.Client, bucket string, name string) (*storage.ObjectAttrs, error) {
 	var obj *storage.ObjectAttrs
-	err := retry(func() error {
+	err := call(func() error {
 		var err error
 		obj, err = client.Bucket(bucket).Object(name).Attrs(ctx)
 		return err
@@ -916,7 +923,7 @@ func storageStatObject(ctx context.Context, client *storage.Client, bucket strin
 
 func storageListObjects(ctx context.Context, client *storage.Client, bucket string, q *storage.Query) (*storage.ObjectIterator, error) {
 	var objs *storage.ObjectIterator
-	err := retry(func() error {
+	err := call(func() error {
 		var err error
 		objs = client.Bucket(bucket).Objects(ctx, q)
 		return err
@@ -926,7 +933,7 @@ func storageListObjects(ctx context.Context, client *storage.Client, bucket stri
 
 func storageCopyObject(ctx context.Context, client *storage.Client, srcBucket, srcName string, destBucket, destName string, attrs *storage.ObjectAttrs) (*storage.ObjectAttrs, error) {
 	var obj *storage.ObjectAttrs
-	err := retry(func() error {
+	err := call(func() error {
 		var err error
 		src := client.Bucket(srcBucket).Object(srcName)
 		dst := client.Bucket(destBucket).Object(destName)
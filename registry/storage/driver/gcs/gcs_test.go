@@ -4,6 +4,7 @@ package gcs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/url"
@@ -210,7 +211,7 @@ func TestCommit(t *testing.T) {
 	}
 }
 
-func TestRetry(t *testing.T) {
+func TestCall(t *testing.T) {
 	if skipGCS() != "" {
 		t.Skip(skipGCS())
 	}
@@ -225,7 +226,7 @@ func TestRetry(t *testing.T) {
 		}
 	}
 
-	err := retry(func() error {
+	err := call(func() error {
 		return &googleapi.Error{
 			Code:    503,
 			Message: "google api error",
@@ -233,7 +234,7 @@ func TestRetry(t *testing.T) {
 	})
 	assertError("googleapi: Error 503: google api error", err)
 
-	err = retry(func() error {
+	err = call(func() error {
 		return &googleapi.Error{
 			Code:    404,
 			Message: "google api error",
@@ -241,10 +242,69 @@ func TestRetry(t *testing.T) {
 	})
 	assertError("googleapi: Error 404: google api error", err)
 
-	err = retry(func() error {
+	err = call(func() error {
 		return fmt.Errorf("error")
 	})
 	assertError("error", err)
+
+	err = call(func() error {
+		return &googleapi.Error{
+			Code:    429,
+			Message: "google api error",
+		}
+	})
+	assertError("googleapi: Error 429: google api error", errors.Unwrap(err))
+	require.ErrorAs(t, err, &storagedriver.TooManyRequestsError{})
+}
+
+func TestRetry(t *testing.T) {
+	if skipGCS() != "" {
+		t.Skip(skipGCS())
+	}
+
+	var attempts int
+	err := retry(func() error {
+		attempts++
+		return &googleapi.Error{
+			Code:    503,
+			Message: "google api error",
+		}
+	})
+	require.EqualError(t, err, "googleapi: Error 503: google api error")
+	require.Equal(t, maxTries, attempts)
+
+	attempts = 0
+	err = retry(func() error {
+		attempts++
+		if attempts < 3 {
+			return &googleapi.Error{
+				Code:    429,
+				Message: "google api error",
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = retry(func() error {
+		attempts++
+		return &googleapi.Error{
+			Code:    404,
+			Message: "google api error",
+		}
+	})
+	require.EqualError(t, err, "googleapi: Error 404: google api error")
+	require.Equal(t, 1, attempts)
+
+	attempts = 0
+	err = retry(func() error {
+		attempts++
+		return fmt.Errorf("error")
+	})
+	require.EqualError(t, err, "error")
+	require.Equal(t, 1, attempts)
 }
 
 func TestEmptyRootList(t *testing.T) {
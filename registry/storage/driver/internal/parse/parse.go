@@ -3,6 +3,7 @@ package parse
 import (
 	"fmt"
 	"strconv"
+	"time"
 )
 
 func Bool(parameters map[string]interface{}, name string, defaultt bool) (bool, error) {
@@ -22,3 +23,80 @@ func Bool(parameters map[string]interface{}, name string, defaultt bool) (bool,
 		return defaultt, fmt.Errorf("cannot parse %q with type %T as bool", name, value)
 	}
 }
+
+// Int parses the parameter with the given name as an integer, which can be a string or any integer type. defaultt is
+// returned if the parameter is not set.
+func Int(parameters map[string]interface{}, name string, defaultt int) (int, error) {
+	switch value := parameters[name].(type) {
+	case string:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultt, fmt.Errorf("cannot parse %q string as int: %w", name, err)
+		}
+
+		return v, nil
+	case int:
+		return value, nil
+	case int32:
+		return int(value), nil
+	case int64:
+		return int(value), nil
+	case uint:
+		return int(value), nil
+	case uint32:
+		return int(value), nil
+	case uint64:
+		return int(value), nil
+	case nil:
+		return defaultt, nil
+	default:
+		return defaultt, fmt.Errorf("cannot parse %q with type %T as int", name, value)
+	}
+}
+
+// Float parses the parameter with the given name as a floating-point number, which can be a string, a float or an
+// integer. defaultt is returned if the parameter is not set.
+func Float(parameters map[string]interface{}, name string, defaultt float64) (float64, error) {
+	switch value := parameters[name].(type) {
+	case string:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return defaultt, fmt.Errorf("cannot parse %q string as float: %w", name, err)
+		}
+
+		return v, nil
+	case float64:
+		return value, nil
+	case float32:
+		return float64(value), nil
+	case nil:
+		return defaultt, nil
+	default:
+		v, err := Int(parameters, name, 0)
+		if err != nil {
+			return defaultt, fmt.Errorf("cannot parse %q with type %T as float", name, value)
+		}
+
+		return float64(v), nil
+	}
+}
+
+// Duration parses the parameter with the given name as a duration, which can be a string in the format accepted by
+// time.ParseDuration or a time.Duration. defaultt is returned if the parameter is not set.
+func Duration(parameters map[string]interface{}, name string, defaultt time.Duration) (time.Duration, error) {
+	switch value := parameters[name].(type) {
+	case string:
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return defaultt, fmt.Errorf("cannot parse %q string as duration: %w", name, err)
+		}
+
+		return v, nil
+	case time.Duration:
+		return value, nil
+	case nil:
+		return defaultt, nil
+	default:
+		return defaultt, fmt.Errorf("cannot parse %q with type %T as duration", name, value)
+	}
+}
@@ -2,6 +2,7 @@ package parse
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -94,3 +95,146 @@ func TestBool(t *testing.T) {
 		})
 	}
 }
+
+func TestInt(t *testing.T) {
+	tests := map[string]struct {
+		param          interface{}
+		expected       int
+		expectedErrMsg string
+	}{
+		"valid_string": {
+			param:    "3",
+			expected: 3,
+		},
+		"valid_int": {
+			param:    3,
+			expected: 3,
+		},
+		"valid_int64": {
+			param:    int64(3),
+			expected: 3,
+		},
+		"nil": {
+			param:    nil,
+			expected: 5,
+		},
+		"invalid_string": {
+			param:          "three",
+			expected:       5,
+			expectedErrMsg: `cannot parse "param" string as int: strconv.Atoi: parsing "three": invalid syntax`,
+		},
+		"invalid_param": {
+			param:          1.5,
+			expected:       5,
+			expectedErrMsg: `cannot parse "param" with type float64 as int`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Int(map[string]interface{}{"param": test.param}, "param", 5)
+
+			if test.expectedErrMsg != "" {
+				require.EqualError(t, err, test.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func TestFloat(t *testing.T) {
+	tests := map[string]struct {
+		param          interface{}
+		expected       float64
+		expectedErrMsg string
+	}{
+		"valid_string": {
+			param:    "0.5",
+			expected: 0.5,
+		},
+		"valid_float": {
+			param:    0.5,
+			expected: 0.5,
+		},
+		"valid_int": {
+			param:    2,
+			expected: 2,
+		},
+		"nil": {
+			param:    nil,
+			expected: 1,
+		},
+		"invalid_string": {
+			param:          "half",
+			expected:       1,
+			expectedErrMsg: `cannot parse "param" string as float: strconv.ParseFloat: parsing "half": invalid syntax`,
+		},
+		"invalid_param": {
+			param:          true,
+			expected:       1,
+			expectedErrMsg: `cannot parse "param" with type bool as float`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Float(map[string]interface{}{"param": test.param}, "param", 1)
+
+			if test.expectedErrMsg != "" {
+				require.EqualError(t, err, test.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := map[string]struct {
+		param          interface{}
+		expected       time.Duration
+		expectedErrMsg string
+	}{
+		"valid_string": {
+			param:    "1m",
+			expected: time.Minute,
+		},
+		"valid_duration": {
+			param:    time.Minute,
+			expected: time.Minute,
+		},
+		"nil": {
+			param:    nil,
+			expected: time.Second,
+		},
+		"invalid_string": {
+			param:          "soon",
+			expected:       time.Second,
+			expectedErrMsg: `cannot parse "param" string as duration: time: invalid duration "soon"`,
+		},
+		"invalid_param": {
+			param:          60,
+			expected:       time.Second,
+			expectedErrMsg: `cannot parse "param" with type int as duration`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Duration(map[string]interface{}{"param": test.param}, "param", time.Second)
+
+			if test.expectedErrMsg != "" {
+				require.EqualError(t, err, test.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, test.expected, got)
+		})
+	}
+}
@@ -0,0 +1,329 @@
+// Package isolation provides a storage driver that stores the objects of each isolated top-level namespace in a
+// dedicated storage driver instance, such as a separate bucket with its own credentials, for strict tenant isolation.
+package isolation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// repositoriesPrefix is the root path of repository objects, such as manifest and layer links and upload sessions, as
+// defined in the storage path layout (see storage.pathFor). The first path segment after it is the top-level namespace.
+const repositoriesPrefix = "/docker/registry/v2/repositories/"
+
+type namespaceKey struct{}
+
+// WithNamespace returns a context carrying the top-level namespace targeted by a request. Objects whose path does not
+// identify a namespace, such as blobs, are stored in the driver of the namespace carried by the context.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+// NamespaceFromContext returns the top-level namespace carried by ctx, if any.
+func NamespaceFromContext(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns
+}
+
+// Driver is a storage driver that routes operations to the driver of the top-level namespace they target. Namespaces
+// without a dedicated driver use the default one. The namespace is determined by the object path for repository
+// objects, and by the context (see WithNamespace) for all others, such as blobs.
+//
+// Operations whose namespace can't be determined are served by the default driver, except for deletions, which are
+// applied to all drivers (e.g. when online GC deletes a dangling blob, which may be stored in any of them), and
+// listings and walks, which span all drivers (e.g. when walking the repositories root path to purge uploads).
+type Driver struct {
+	defaultDriver storagedriver.StorageDriver
+	namespaces    map[string]storagedriver.StorageDriver
+}
+
+var _ storagedriver.StorageDriver = &Driver{}
+
+// New creates a driver that routes operations targeting each namespace in namespaces to the corresponding driver,
+// and all others to defaultDriver.
+func New(defaultDriver storagedriver.StorageDriver, namespaces map[string]storagedriver.StorageDriver) (*Driver, error) {
+	if defaultDriver == nil {
+		return nil, errors.New("default storage driver is required")
+	}
+	for ns, d := range namespaces {
+		if ns == "" || strings.Contains(ns, "/") {
+			return nil, fmt.Errorf("invalid top-level namespace name %q", ns)
+		}
+		if d == nil {
+			return nil, fmt.Errorf("storage driver for namespace %q is required", ns)
+		}
+	}
+
+	return &Driver{defaultDriver: defaultDriver, namespaces: namespaces}, nil
+}
+
+// Isolated reports whether namespace has a dedicated driver.
+func (d *Driver) Isolated(namespace string) bool {
+	_, ok := d.namespaces[namespace]
+	return ok
+}
+
+// NamespaceDriver returns the driver storing the objects of namespace, which is the default driver unless it is
+// isolated.
+func (d *Driver) NamespaceDriver(namespace string) storagedriver.StorageDriver {
+	if nd, ok := d.namespaces[namespace]; ok {
+		return nd
+	}
+	return d.defaultDriver
+}
+
+// namespace returns the top-level namespace targeted by an operation on path, if any.
+func namespace(ctx context.Context, path string) (string, bool) {
+	if strings.HasPrefix(path, repositoriesPrefix) {
+		if ns := strings.SplitN(strings.TrimPrefix(path, repositoriesPrefix), "/", 2)[0]; ns != "" {
+			return ns, true
+		}
+		// the repositories root path spans all namespaces
+		return "", false
+	}
+	if ns := NamespaceFromContext(ctx); ns != "" {
+		return ns, true
+	}
+	return "", false
+}
+
+// driverFor returns the driver for the namespace targeted by an operation on path, and whether it could be
+// determined. The default driver is returned if not.
+func (d *Driver) driverFor(ctx context.Context, path string) (storagedriver.StorageDriver, bool) {
+	ns, ok := namespace(ctx, path)
+	if !ok {
+		return d.defaultDriver, false
+	}
+	if nd, ok := d.namespaces[ns]; ok {
+		return nd, true
+	}
+	return d.defaultDriver, true
+}
+
+// all returns the default driver followed by the namespace drivers, sorted by namespace name.
+func (d *Driver) all() []storagedriver.StorageDriver {
+	names := make([]string, 0, len(d.namespaces))
+	for ns := range d.namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+
+	drivers := []storagedriver.StorageDriver{d.defaultDriver}
+	for _, ns := range names {
+		drivers = append(drivers, d.namespaces[ns])
+	}
+	return drivers
+}
+
+// Name returns the name of the default driver, as this driver only routes operations to others.
+func (d *Driver) Name() string {
+	return d.defaultDriver.Name()
+}
+
+// GetContent implements storagedriver.StorageDriver.
+func (d *Driver) GetContent(ctx context.Context, path string) ([]byte, error) {
+	sd, _ := d.driverFor(ctx, path)
+	return sd.GetContent(ctx, path)
+}
+
+// PutContent implements storagedriver.StorageDriver.
+func (d *Driver) PutContent(ctx context.Context, path string, content []byte) error {
+	sd, _ := d.driverFor(ctx, path)
+	return sd.PutContent(ctx, path, content)
+}
+
+// Reader implements storagedriver.StorageDriver.
+func (d *Driver) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	sd, _ := d.driverFor(ctx, path)
+	return sd.Reader(ctx, path, offset)
+}
+
+// Writer implements storagedriver.StorageDriver.
+func (d *Driver) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
+	sd, _ := d.driverFor(ctx, path)
+	return sd.Writer(ctx, path, append)
+}
+
+// Stat implements storagedriver.StorageDriver.
+func (d *Driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	sd, _ := d.driverFor(ctx, path)
+	return sd.Stat(ctx, path)
+}
+
+// List implements storagedriver.StorageDriver. If the namespace can't be determined, the entries of all drivers are
+// merged.
+func (d *Driver) List(ctx context.Context, path string) ([]string, error) {
+	if sd, ok := d.driverFor(ctx, path); ok {
+		return sd.List(ctx, path)
+	}
+
+	seen := make(map[string]struct{})
+	var (
+		entries  []string
+		notFound int
+	)
+	drivers := d.all()
+	for _, sd := range drivers {
+		ee, err := sd.List(ctx, path)
+		if err != nil {
+			if errors.As(err, new(storagedriver.PathNotFoundError)) {
+				notFound++
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range ee {
+			if _, ok := seen[e]; !ok {
+				seen[e] = struct{}{}
+				entries = append(entries, e)
+			}
+		}
+	}
+	if notFound == len(drivers) {
+		return nil, storagedriver.PathNotFoundError{Path: path, DriverName: d.Name()}
+	}
+	sort.Strings(entries)
+
+	return entries, nil
+}
+
+// Move implements storagedriver.StorageDriver. Objects can't be moved across drivers.
+func (d *Driver) Move(ctx context.Context, sourcePath, destPath string) error {
+	src, _ := d.driverFor(ctx, sourcePath)
+	dst, _ := d.driverFor(ctx, destPath)
+	if src != dst {
+		return fmt.Errorf("moving %q to %q across isolated namespaces is not supported", sourcePath, destPath)
+	}
+	return src.Move(ctx, sourcePath, destPath)
+}
+
+// Delete implements storagedriver.StorageDriver. If the namespace can't be determined, path is deleted from all
+// drivers, and storagedriver.PathNotFoundError is only returned if it was not found in any of them.
+func (d *Driver) Delete(ctx context.Context, path string) error {
+	if sd, ok := d.driverFor(ctx, path); ok {
+		return sd.Delete(ctx, path)
+	}
+
+	drivers := d.all()
+	var notFound int
+	for _, sd := range drivers {
+		if err := sd.Delete(ctx, path); err != nil {
+			if errors.As(err, new(storagedriver.PathNotFoundError)) {
+				notFound++
+				continue
+			}
+			return err
+		}
+	}
+	if notFound == len(drivers) {
+		return storagedriver.PathNotFoundError{Path: path, DriverName: d.Name()}
+	}
+
+	return nil
+}
+
+// DeleteFiles implements storagedriver.StorageDriver. Files whose namespace can't be determined are deleted from all
+// drivers, and are only counted once.
+func (d *Driver) DeleteFiles(ctx context.Context, paths []string) (int, error) {
+	routed := make(map[storagedriver.StorageDriver][]string)
+	var unresolved []string
+	for _, p := range paths {
+		if sd, ok := d.driverFor(ctx, p); ok {
+			routed[sd] = append(routed[sd], p)
+		} else {
+			unresolved = append(unresolved, p)
+		}
+	}
+
+	var count int
+	for sd, pp := range routed {
+		n, err := sd.DeleteFiles(ctx, pp)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	if len(unresolved) > 0 {
+		var deleted int
+		for _, sd := range d.all() {
+			// files are deleted from every driver, but counting them more than once would misreport the result
+			n, err := sd.DeleteFiles(ctx, unresolved)
+			if err != nil {
+				return count + deleted, err
+			}
+			if n > deleted {
+				deleted = n
+			}
+		}
+		count += deleted
+	}
+
+	return count, nil
+}
+
+// URLFor implements storagedriver.StorageDriver.
+func (d *Driver) URLFor(ctx context.Context, path string, options map[string]any) (string, error) {
+	sd, _ := d.driverFor(ctx, path)
+	return sd.URLFor(ctx, path, options)
+}
+
+// Walk implements storagedriver.StorageDriver. If the namespace can't be determined, all drivers are walked in
+// sequence, so files are only sorted within each driver.
+func (d *Driver) Walk(ctx context.Context, path string, f storagedriver.WalkFn) error {
+	return d.walk(ctx, path, f, storagedriver.StorageDriver.Walk)
+}
+
+// WalkParallel implements storagedriver.StorageDriver. If the namespace can't be determined, all drivers are walked
+// in sequence.
+func (d *Driver) WalkParallel(ctx context.Context, path string, f storagedriver.WalkFn) error {
+	return d.walk(ctx, path, f, storagedriver.StorageDriver.WalkParallel)
+}
+
+func (d *Driver) walk(ctx context.Context, path string, f storagedriver.WalkFn, walkFn func(storagedriver.StorageDriver, context.Context, string, storagedriver.WalkFn) error) error {
+	if sd, ok := d.driverFor(ctx, path); ok {
+		return walkFn(sd, ctx, path, f)
+	}
+
+	drivers := d.all()
+	var notFound int
+	for _, sd := range drivers {
+		if err := walkFn(sd, ctx, path, f); err != nil {
+			if errors.As(err, new(storagedriver.PathNotFoundError)) {
+				notFound++
+				continue
+			}
+			return err
+		}
+	}
+	if notFound == len(drivers) {
+		return storagedriver.PathNotFoundError{Path: path, DriverName: d.Name()}
+	}
+
+	return nil
+}
+
+// ExistsPath implements storagedriver.StorageDriver. If the namespace can't be determined, path exists if it exists
+// in any driver.
+func (d *Driver) ExistsPath(ctx context.Context, path string) (bool, error) {
+	if sd, ok := d.driverFor(ctx, path); ok {
+		return sd.ExistsPath(ctx, path)
+	}
+
+	for _, sd := range d.all() {
+		exists, err := sd.ExistsPath(ctx, path)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
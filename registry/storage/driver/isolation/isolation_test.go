@@ -0,0 +1,199 @@
+package isolation
+
+import (
+	"context"
+	"testing"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+const blobPath = "/docker/registry/v2/blobs/sha256/ab/abcd/data"
+
+func newTestDriver(t *testing.T) (*Driver, *inmemory.Driver, *inmemory.Driver) {
+	t.Helper()
+
+	def, tenant := inmemory.New(), inmemory.New()
+	d, err := New(def, map[string]storagedriver.StorageDriver{"tenant": tenant})
+	require.NoError(t, err)
+
+	return d, def, tenant
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, nil)
+	require.Error(t, err)
+
+	_, err = New(inmemory.New(), map[string]storagedriver.StorageDriver{"a/b": inmemory.New()})
+	require.EqualError(t, err, `invalid top-level namespace name "a/b"`)
+
+	_, err = New(inmemory.New(), map[string]storagedriver.StorageDriver{"a": nil})
+	require.EqualError(t, err, `storage driver for namespace "a" is required`)
+
+	d, err := New(inmemory.New(), map[string]storagedriver.StorageDriver{"a": inmemory.New()})
+	require.NoError(t, err)
+	require.True(t, d.Isolated("a"))
+	require.False(t, d.Isolated("b"))
+	require.Equal(t, "inmemory", d.Name())
+}
+
+func TestDriver_NamespaceDriver(t *testing.T) {
+	d, def, tenant := newTestDriver(t)
+
+	require.Same(t, tenant, d.NamespaceDriver("tenant"))
+	require.Same(t, def, d.NamespaceDriver("other"))
+}
+
+func TestDriver_RoutesByPath(t *testing.T) {
+	d, def, tenant := newTestDriver(t)
+	ctx := context.Background()
+
+	tenantPath := "/docker/registry/v2/repositories/tenant/app/_layers/sha256/abcd/link"
+	otherPath := "/docker/registry/v2/repositories/other/app/_layers/sha256/abcd/link"
+
+	require.NoError(t, d.PutContent(ctx, tenantPath, []byte("a")))
+	require.NoError(t, d.PutContent(ctx, otherPath, []byte("b")))
+
+	exists, err := tenant.ExistsPath(ctx, tenantPath)
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = def.ExistsPath(ctx, tenantPath)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	exists, err = def.ExistsPath(ctx, otherPath)
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = tenant.ExistsPath(ctx, otherPath)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// the path takes precedence over the context
+	content, err := d.GetContent(WithNamespace(ctx, "other"), tenantPath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), content)
+}
+
+func TestDriver_RoutesByContext(t *testing.T) {
+	d, def, tenant := newTestDriver(t)
+	ctx := context.Background()
+
+	require.NoError(t, d.PutContent(WithNamespace(ctx, "tenant"), blobPath, []byte("a")))
+
+	exists, err := tenant.ExistsPath(ctx, blobPath)
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = def.ExistsPath(ctx, blobPath)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// without a namespace, the default driver is used
+	_, err = d.GetContent(ctx, blobPath)
+	require.ErrorAs(t, err, new(storagedriver.PathNotFoundError))
+	// but existence checks span all drivers
+	exists, err = d.ExistsPath(ctx, blobPath)
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestDriver_Move(t *testing.T) {
+	d, _, tenant := newTestDriver(t)
+	ctx := WithNamespace(context.Background(), "tenant")
+
+	uploadPath := "/docker/registry/v2/repositories/tenant/app/_uploads/123/data"
+	require.NoError(t, d.PutContent(ctx, uploadPath, []byte("a")))
+	require.NoError(t, d.Move(ctx, uploadPath, blobPath))
+
+	content, err := tenant.GetContent(ctx, blobPath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), content)
+
+	otherPath := "/docker/registry/v2/repositories/other/app/_uploads/123/data"
+	require.NoError(t, d.PutContent(ctx, otherPath, []byte("b")))
+	require.Error(t, d.Move(ctx, otherPath, blobPath))
+}
+
+func TestDriver_DeleteUnresolved(t *testing.T) {
+	d, def, tenant := newTestDriver(t)
+	ctx := context.Background()
+
+	require.NoError(t, def.PutContent(ctx, blobPath, []byte("a")))
+	require.NoError(t, tenant.PutContent(ctx, blobPath, []byte("a")))
+
+	require.NoError(t, d.Delete(ctx, blobPath))
+	for _, sd := range []storagedriver.StorageDriver{def, tenant} {
+		exists, err := sd.ExistsPath(ctx, blobPath)
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+
+	err := d.Delete(ctx, blobPath)
+	require.ErrorAs(t, err, new(storagedriver.PathNotFoundError))
+
+	// deleting from a single driver is enough
+	require.NoError(t, tenant.PutContent(ctx, blobPath, []byte("a")))
+	require.NoError(t, d.Delete(ctx, blobPath))
+	exists, err := tenant.ExistsPath(ctx, blobPath)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestDriver_DeleteFiles(t *testing.T) {
+	d, def, tenant := newTestDriver(t)
+	ctx := context.Background()
+
+	tenantPath := "/docker/registry/v2/repositories/tenant/app/_manifests/revisions/sha256/abcd/link"
+	require.NoError(t, tenant.PutContent(ctx, tenantPath, []byte("a")))
+	require.NoError(t, def.PutContent(ctx, blobPath, []byte("a")))
+	require.NoError(t, tenant.PutContent(ctx, blobPath, []byte("a")))
+
+	count, err := d.DeleteFiles(ctx, []string{tenantPath, blobPath})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	for _, p := range []string{tenantPath, blobPath} {
+		exists, err := d.ExistsPath(ctx, p)
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+}
+
+func TestDriver_ListAndWalkUnresolved(t *testing.T) {
+	d, def, tenant := newTestDriver(t)
+	ctx := context.Background()
+
+	require.NoError(t, d.PutContent(ctx, "/docker/registry/v2/repositories/tenant/a/link", []byte("a")))
+	require.NoError(t, d.PutContent(ctx, "/docker/registry/v2/repositories/other/b/link", []byte("b")))
+
+	entries, err := d.List(ctx, "/docker/registry/v2/repositories")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/docker/registry/v2/repositories/other",
+		"/docker/registry/v2/repositories/tenant",
+	}, entries)
+
+	// each namespace is only listed by its own driver
+	entries, err = def.List(ctx, "/docker/registry/v2/repositories")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/docker/registry/v2/repositories/other"}, entries)
+	entries, err = tenant.List(ctx, "/docker/registry/v2/repositories")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/docker/registry/v2/repositories/tenant"}, entries)
+
+	var walked []string
+	err = d.Walk(ctx, "/docker/registry/v2/repositories", func(fi storagedriver.FileInfo) error {
+		if !fi.IsDir() {
+			walked = append(walked, fi.Path())
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"/docker/registry/v2/repositories/tenant/a/link",
+		"/docker/registry/v2/repositories/other/b/link",
+	}, walked)
+
+	_, err = d.List(ctx, "/docker/registry/v2/blobs")
+	require.ErrorAs(t, err, new(storagedriver.PathNotFoundError))
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	dcontext "github.com/docker/distribution/context"
+)
+
+// cdnDomain is an additional CloudFront distribution, serving the clients within the given CIDR ranges or countries.
+type cdnDomain struct {
+	baseURL   string
+	networks  []*net.IPNet
+	countries map[string]struct{}
+}
+
+// matches checks if a client with the given IP and country code should be served by the domain.
+func (d *cdnDomain) matches(ip net.IP, country string) bool {
+	if ip != nil {
+		for _, n := range d.networks {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	if country != "" {
+		if _, ok := d.countries[strings.ToUpper(country)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeBaseURL defaults the scheme of baseURL to HTTPS and ensures it ends with a slash.
+func normalizeBaseURL(baseURL string) (string, error) {
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return "", err
+	}
+	return baseURL, nil
+}
+
+// parseStringList parses a list of strings from a middleware option, which may be a YAML list or a comma separated
+// string.
+func parseStringList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		var list []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		return list, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %T", item)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("expected a list of strings, got %T", v)
+	}
+}
+
+// parseDomains parses the domains option, a list of CloudFront distributions with the CIDR ranges and countries of the
+// clients they serve.
+func parseDomains(v interface{}) ([]*cdnDomain, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("domains must be a list")
+	}
+
+	domains := make([]*cdnDomain, 0, len(items))
+	for i, item := range items {
+		options := make(map[string]interface{})
+		switch item := item.(type) {
+		case map[string]interface{}:
+			options = item
+		case map[interface{}]interface{}:
+			for k, v := range item {
+				options[fmt.Sprint(k)] = v
+			}
+		default:
+			return nil, fmt.Errorf("domains[%d] must be a map", i)
+		}
+
+		base, ok := options["baseurl"].(string)
+		if !ok || base == "" {
+			return nil, fmt.Errorf("domains[%d]: no baseurl provided", i)
+		}
+		baseURL, err := normalizeBaseURL(base)
+		if err != nil {
+			return nil, fmt.Errorf("domains[%d]: invalid baseurl: %v", i, err)
+		}
+		d := &cdnDomain{baseURL: baseURL, countries: make(map[string]struct{})}
+
+		if v, ok := options["cidrs"]; ok {
+			cidrs, err := parseStringList(v)
+			if err != nil {
+				return nil, fmt.Errorf("domains[%d]: invalid cidrs: %v", i, err)
+			}
+			for _, cidr := range cidrs {
+				_, n, err := net.ParseCIDR(cidr)
+				if err != nil {
+					return nil, fmt.Errorf("domains[%d]: invalid cidrs: %v", i, err)
+				}
+				d.networks = append(d.networks, n)
+			}
+		}
+		if v, ok := options["countries"]; ok {
+			countries, err := parseStringList(v)
+			if err != nil {
+				return nil, fmt.Errorf("domains[%d]: invalid countries: %v", i, err)
+			}
+			for _, c := range countries {
+				d.countries[strings.ToUpper(strings.TrimSpace(c))] = struct{}{}
+			}
+		}
+		if len(d.networks) == 0 && len(d.countries) == 0 {
+			return nil, fmt.Errorf("domains[%d]: at least one of cidrs or countries must be provided", i)
+		}
+
+		domains = append(domains, d)
+	}
+
+	return domains, nil
+}
+
+// selectBaseURL returns the base URL of the first domain serving the client of the request in ctx, or the default one
+// if none does. The client country code is read from the countryHeader request header, set by an upstream proxy.
+func (lh *cloudFrontStorageMiddleware) selectBaseURL(ctx context.Context) string {
+	if len(lh.domains) == 0 {
+		return lh.baseURL
+	}
+
+	request, err := dcontext.GetRequest(ctx)
+	if err != nil {
+		dcontext.GetLogger(ctx).WithError(err).Warn("the CloudFront middleware cannot parse the request, using the default domain")
+		return lh.baseURL
+	}
+	ip := net.ParseIP(dcontext.RemoteIP(request))
+	var country string
+	if lh.countryHeader != "" {
+		country = strings.TrimSpace(request.Header.Get(lh.countryHeader))
+	}
+
+	for _, d := range lh.domains {
+		if d.matches(ip, country) {
+			return d.baseURL
+		}
+	}
+	return lh.baseURL
+}
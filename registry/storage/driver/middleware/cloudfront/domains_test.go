@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dcontext "github.com/docker/distribution/context"
+)
+
+func TestParseDomains(t *testing.T) {
+	domains, err := parseDomains([]interface{}{
+		map[interface{}]interface{}{
+			"baseurl": "eu.cdn.example.com",
+			"cidrs":   []interface{}{"10.0.0.0/8", "2001:db8::/32"},
+		},
+		map[string]interface{}{
+			"baseurl":   "https://us.cdn.example.com/",
+			"countries": "us, ca",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, 2, len(domains))
+	assertEqual(t, "https://eu.cdn.example.com/", domains[0].baseURL)
+	assertEqual(t, 2, len(domains[0].networks))
+	assertEqual(t, "https://us.cdn.example.com/", domains[1].baseURL)
+	assertEqual(t, map[string]struct{}{"US": {}, "CA": {}}, domains[1].countries)
+}
+
+func TestParseDomains_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		domains interface{}
+		err     string
+	}{
+		{name: "not a list", domains: "cdn.example.com", err: "domains must be a list"},
+		{name: "not a map", domains: []interface{}{"cdn.example.com"}, err: "domains[0] must be a map"},
+		{name: "no baseurl", domains: []interface{}{map[string]interface{}{"cidrs": "10.0.0.0/8"}}, err: "domains[0]: no baseurl provided"},
+		{
+			name:    "invalid cidr",
+			domains: []interface{}{map[string]interface{}{"baseurl": "cdn.example.com", "cidrs": "10.0.0.0"}},
+			err:     "domains[0]: invalid cidrs",
+		},
+		{
+			name:    "no clients",
+			domains: []interface{}{map[string]interface{}{"baseurl": "cdn.example.com"}},
+			err:     "domains[0]: at least one of cidrs or countries must be provided",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseDomains(test.domains)
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSelectBaseURL(t *testing.T) {
+	domains, err := parseDomains([]interface{}{
+		map[string]interface{}{"baseurl": "eu.cdn.example.com", "cidrs": "10.0.0.0/8", "countries": "de"},
+		map[string]interface{}{"baseurl": "us.cdn.example.com", "countries": "us"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lh := &cloudFrontStorageMiddleware{
+		baseURL:       "https://cdn.example.com/",
+		domains:       domains,
+		countryHeader: "X-Client-Country",
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		country  string
+		expected string
+	}{
+		{name: "cidr match", ip: "10.1.2.3", expected: "https://eu.cdn.example.com/"},
+		{name: "country match", ip: "192.168.0.1", country: "US", expected: "https://us.cdn.example.com/"},
+		{name: "country match is case insensitive", ip: "192.168.0.1", country: "de", expected: "https://eu.cdn.example.com/"},
+		{name: "first match wins", ip: "10.1.2.3", country: "US", expected: "https://eu.cdn.example.com/"},
+		{name: "no match", ip: "192.168.0.1", country: "FR", expected: "https://cdn.example.com/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.ip + ":12345"
+			if test.country != "" {
+				req.Header.Set("X-Client-Country", test.country)
+			}
+			ctx := dcontext.WithRequest(context.Background(), req)
+			assertEqual(t, test.expected, lh.selectBaseURL(ctx))
+		})
+	}
+
+	// without a request, the default domain is used
+	assertEqual(t, "https://cdn.example.com/", lh.selectBaseURL(context.Background()))
+}
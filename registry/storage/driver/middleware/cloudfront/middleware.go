@@ -7,7 +7,7 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
-	"net/url"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -27,20 +27,42 @@ type cloudFrontStorageMiddleware struct {
 	urlSigner *sign.URLSigner
 	baseURL   string
 	duration  time.Duration
+
+	// domains are the additional CloudFront distributions serving specific clients, the first match wins.
+	domains       []*cdnDomain
+	countryHeader string
+
+	// cookieSigner is set when signing with cookies instead of URLs, for browser-based pulls.
+	cookieSigner *sign.CookieSigner
 }
 
+const (
+	signingURL    = "url"
+	signingCookie = "cookie"
+)
+
 var _ storagedriver.StorageDriver = &cloudFrontStorageMiddleware{}
 
 // newCloudFrontLayerHandler constructs and returns a new CloudFront
 // LayerHandler implementation.
 // Required options: baseurl, privatekey, keypairid
 
-// Optional options: ipFilteredBy, awsregion
+// Optional options: ipFilteredBy, awsregion, domains, countryheader, signing, cookiedomain
 // ipfilteredby: valid value "none|aws|awsregion". "none", do not filter any IP, default value. "aws", only aws IP goes
 //
 //	to S3 directly. "awsregion", only regions listed in awsregion options goes to S3 directly
 //
 // awsregion: a comma separated string of AWS regions.
+// domains: a list of additional distributions, each with a baseurl and the cidrs and/or countries of the clients it
+//
+//	serves. Clients matching none are served by baseurl.
+//
+// countryheader: the request header holding the client country code, set by an upstream proxy. Required by countries.
+// signing: valid value "url|cookie". "url", sign URLs, default value. "cookie", set signed cookies valid for the whole
+//
+//	distribution on the redirect response, and redirect to the unsigned URL.
+//
+// cookiedomain: the domain of signed cookies, shared by the registry and the distributions. Required by cookie signing.
 func newCloudFrontStorageMiddleware(storageDriver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
 	// parse baseurl
 	base, ok := options["baseurl"]
@@ -51,13 +73,8 @@ func newCloudFrontStorageMiddleware(storageDriver storagedriver.StorageDriver, o
 	if !ok {
 		return nil, fmt.Errorf("baseurl must be a string")
 	}
-	if !strings.Contains(baseURL, "://") {
-		baseURL = "https://" + baseURL
-	}
-	if !strings.HasSuffix(baseURL, "/") {
-		baseURL += "/"
-	}
-	if _, err := url.Parse(baseURL); err != nil {
+	baseURL, err := normalizeBaseURL(baseURL)
+	if err != nil {
 		return nil, fmt.Errorf("invalid baseurl: %v", err)
 	}
 
@@ -173,12 +190,63 @@ func newCloudFrontStorageMiddleware(storageDriver storagedriver.StorageDriver, o
 		}
 	}
 
+	// parse domains
+	var domains []*cdnDomain
+	if d, ok := options["domains"]; ok {
+		domains, err = parseDomains(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// parse countryheader
+	var countryHeader string
+	if h, ok := options["countryheader"]; ok {
+		if countryHeader, ok = h.(string); !ok {
+			return nil, fmt.Errorf("countryheader must be a string")
+		}
+	}
+	if countryHeader == "" {
+		for i, d := range domains {
+			if len(d.countries) > 0 {
+				return nil, fmt.Errorf("domains[%d]: countries require a countryheader", i)
+			}
+		}
+	}
+
+	// parse signing
+	var cookieSigner *sign.CookieSigner
+	signing := signingURL
+	if s, ok := options["signing"]; ok {
+		if signing, ok = s.(string); !ok {
+			return nil, fmt.Errorf("signing must be a string")
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(signing)) {
+	case "", signingURL:
+	case signingCookie:
+		cookieDomain, ok := options["cookiedomain"].(string)
+		if !ok || cookieDomain == "" {
+			return nil, fmt.Errorf("no cookiedomain provided, required by cookie signing")
+		}
+		cookieSigner = sign.NewCookieSigner(keypairID, privateKey, func(o *sign.CookieOptions) {
+			o.Path = "/"
+			o.Domain = cookieDomain
+			o.Secure = true
+		})
+	default:
+		return nil, fmt.Errorf("signing only allows a string with the following value: url|cookie")
+	}
+
 	return &cloudFrontStorageMiddleware{
 		StorageDriver: storageDriver,
 		urlSigner:     urlSigner,
 		baseURL:       baseURL,
 		duration:      duration,
 		awsIPs:        awsIPs,
+		domains:       domains,
+		countryHeader: countryHeader,
+		cookieSigner:  cookieSigner,
 	}, nil
 }
 
@@ -201,8 +269,26 @@ func (lh *cloudFrontStorageMiddleware) URLFor(ctx context.Context, path string,
 		return lh.StorageDriver.URLFor(ctx, path, options)
 	}
 
+	baseURL := lh.selectBaseURL(ctx)
+	expires := time.Now().Add(lh.duration)
+
+	if lh.cookieSigner != nil {
+		if w, err := dcontext.GetResponseWriter(ctx); err == nil {
+			// the cookies grant access to the whole distribution, so that browsers can reuse them for all blobs
+			cookies, err := lh.cookieSigner.SignWithPolicy(sign.NewCannedPolicy(baseURL+"*", expires))
+			if err != nil {
+				return "", err
+			}
+			for _, c := range cookies {
+				http.SetCookie(w, c)
+			}
+			return baseURL + keyer.S3BucketKey(path), nil
+		}
+		dcontext.GetLogger(ctx).Warn("the CloudFront middleware cannot set signed cookies without a response, falling back to a signed URL")
+	}
+
 	// Get signed cloudfront url.
-	cfURL, err := lh.urlSigner.Sign(lh.baseURL+keyer.S3BucketKey(path), time.Now().Add(lh.duration))
+	cfURL, err := lh.urlSigner.Sign(baseURL+keyer.S3BucketKey(path), expires)
 	if err != nil {
 		return "", err
 	}
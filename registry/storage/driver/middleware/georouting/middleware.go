@@ -0,0 +1,154 @@
+// Package georouting provides a storage middleware that redirects blob downloads to region-local replicas of the
+// storage backend, based on the client IP address or country.
+package georouting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/log"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+	"github.com/docker/distribution/registry/storage/internal/metrics"
+)
+
+const (
+	fallbackNotReplicated = "not_replicated"
+	fallbackError         = "error"
+)
+
+// geoRoutingStorageMiddleware redirects blob downloads of clients within the CIDR ranges or countries of a replica
+// to that replica, and all others to the wrapped storage driver. Replicas are expected to be kept in sync with the
+// wrapped storage driver by the storage backend, such as with bucket replication, and to share its layout.
+type geoRoutingStorageMiddleware struct {
+	storagedriver.StorageDriver
+
+	// replicas serve specific clients, the first match wins.
+	replicas      []*replica
+	countryHeader string
+	// checkReplication enables checking that a blob exists in a replica before redirecting to it, to account for
+	// replication lag.
+	checkReplication bool
+}
+
+var _ storagedriver.StorageDriver = &geoRoutingStorageMiddleware{}
+
+// newGeoRoutingStorageMiddleware constructs and returns a new geo routing storagedriver.StorageDriver implementation.
+// Required options: replicas
+// Optional options: countryheader, checkreplication
+// replicas: a list of replicas, each with a name, the storage driver configuration for the replica and the cidrs
+//
+//	and/or countries of the clients it serves. Clients matching none are served by the wrapped storage driver.
+//
+// countryheader: the request header holding the client country code, set by an upstream proxy. Required by countries.
+// checkreplication: whether to check that a blob exists in a replica before redirecting to it. Defaults to true.
+func newGeoRoutingStorageMiddleware(storageDriver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+	// parse replicas
+	r, ok := options["replicas"]
+	if !ok {
+		return nil, fmt.Errorf("no replicas provided")
+	}
+	replicas, err := parseReplicas(r, factory.Create)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse countryheader
+	var countryHeader string
+	if h, ok := options["countryheader"]; ok {
+		if countryHeader, ok = h.(string); !ok {
+			return nil, fmt.Errorf("countryheader must be a string")
+		}
+	}
+	if countryHeader == "" {
+		for i, r := range replicas {
+			if len(r.countries) > 0 {
+				return nil, fmt.Errorf("replicas[%d]: countries require a countryheader", i)
+			}
+		}
+	}
+
+	// parse checkreplication
+	checkReplication := true
+	if c, ok := options["checkreplication"]; ok {
+		if checkReplication, ok = c.(bool); !ok {
+			return nil, fmt.Errorf("checkreplication must be a boolean")
+		}
+	}
+
+	return &geoRoutingStorageMiddleware{
+		StorageDriver:    storageDriver,
+		replicas:         replicas,
+		countryHeader:    countryHeader,
+		checkReplication: checkReplication,
+	}, nil
+}
+
+// selectReplica returns the first replica serving the client of the request in ctx, or nil if none does. The client
+// country code is read from the countryHeader request header, set by an upstream proxy.
+func (gr *geoRoutingStorageMiddleware) selectReplica(ctx context.Context) *replica {
+	request, err := dcontext.GetRequest(ctx)
+	if err != nil {
+		log.GetLogger(log.WithContext(ctx)).WithError(err).Warn("the geo routing middleware cannot parse the request, using the primary storage")
+		return nil
+	}
+	ip := net.ParseIP(dcontext.RemoteIP(request))
+	var country string
+	if gr.countryHeader != "" {
+		country = strings.TrimSpace(request.Header.Get(gr.countryHeader))
+	}
+
+	for _, r := range gr.replicas {
+		if r.matches(ip, country) {
+			return r
+		}
+	}
+	return nil
+}
+
+// URLFor returns a URL which may be used to retrieve the content stored at the given path from the replica serving
+// the client of the request in ctx, or from the wrapped storage driver if there is none or if the content is not
+// available in the replica.
+func (gr *geoRoutingStorageMiddleware) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	r := gr.selectReplica(ctx)
+	if r == nil {
+		metrics.GeoRedirect("", "")
+		return gr.StorageDriver.URLFor(ctx, path, options)
+	}
+
+	l := log.GetLogger(log.WithContext(ctx)).WithFields(log.Fields{"replica": r.name, "path": path})
+
+	if gr.checkReplication {
+		if _, err := r.driver.Stat(ctx, path); err != nil {
+			reason := fallbackError
+			if errors.As(err, &storagedriver.PathNotFoundError{}) {
+				reason = fallbackNotReplicated
+				l.Info("blob not replicated yet, using the primary storage")
+			} else {
+				l.WithError(err).Warn("failed to check blob in replica, using the primary storage")
+			}
+			metrics.GeoRedirect(r.name, reason)
+			return gr.StorageDriver.URLFor(ctx, path, options)
+		}
+	}
+
+	u, err := r.driver.URLFor(ctx, path, options)
+	if err != nil {
+		l.WithError(err).Warn("failed to build replica URL, using the primary storage")
+		metrics.GeoRedirect(r.name, fallbackError)
+		return gr.StorageDriver.URLFor(ctx, path, options)
+	}
+
+	metrics.GeoRedirect(r.name, "")
+	return u, nil
+}
+
+// init registers the geo routing middleware.
+func init() {
+	storagemiddleware.Register("georouting", newGeoRoutingStorageMiddleware)
+}
@@ -0,0 +1,230 @@
+package georouting
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dcontext "github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+// urlDriver is an in-memory storage driver which supports URLFor, returning URLs under baseURL.
+type urlDriver struct {
+	*inmemory.Driver
+	baseURL string
+}
+
+func (d *urlDriver) URLFor(_ context.Context, path string, _ map[string]interface{}) (string, error) {
+	return d.baseURL + path, nil
+}
+
+func newURLDriver(baseURL string) *urlDriver {
+	return &urlDriver{Driver: inmemory.New(), baseURL: baseURL}
+}
+
+// testFactory returns a driverFactory creating urlDrivers, with the baseurl parameter, and keeping track of them.
+func testFactory(drivers map[string]*urlDriver) driverFactory {
+	return func(name string, parameters map[string]interface{}) (storagedriver.StorageDriver, error) {
+		if name != "test" {
+			return nil, errors.New("unknown driver")
+		}
+		baseURL, _ := parameters["baseurl"].(string)
+		d := newURLDriver(baseURL)
+		drivers[baseURL] = d
+		return d, nil
+	}
+}
+
+func TestParseReplicas(t *testing.T) {
+	drivers := make(map[string]*urlDriver)
+	replicas, err := parseReplicas([]interface{}{
+		map[interface{}]interface{}{
+			"name":    "eu",
+			"cidrs":   []interface{}{"10.0.0.0/8", "2001:db8::/32"},
+			"storage": map[interface{}]interface{}{"test": map[interface{}]interface{}{"baseurl": "https://eu.example.com"}},
+		},
+		map[string]interface{}{
+			"name":      "us",
+			"countries": "us, ca",
+			"storage":   map[string]interface{}{"test": map[string]interface{}{"baseurl": "https://us.example.com"}},
+		},
+	}, testFactory(drivers))
+	require.NoError(t, err)
+
+	require.Len(t, replicas, 2)
+	require.Equal(t, "eu", replicas[0].name)
+	require.Len(t, replicas[0].networks, 2)
+	require.Same(t, drivers["https://eu.example.com"], replicas[0].driver)
+	require.Equal(t, "us", replicas[1].name)
+	require.Equal(t, map[string]struct{}{"US": {}, "CA": {}}, replicas[1].countries)
+	require.Same(t, drivers["https://us.example.com"], replicas[1].driver)
+}
+
+func TestParseReplicas_Invalid(t *testing.T) {
+	storage := map[string]interface{}{"test": nil}
+
+	tests := []struct {
+		name     string
+		replicas interface{}
+		err      string
+	}{
+		{name: "not a list", replicas: "eu", err: "replicas must be a non-empty list"},
+		{name: "empty", replicas: []interface{}{}, err: "replicas must be a non-empty list"},
+		{name: "not a map", replicas: []interface{}{"eu"}, err: "replicas[0] must be a map"},
+		{name: "no name", replicas: []interface{}{map[string]interface{}{"cidrs": "10.0.0.0/8", "storage": storage}}, err: "replicas[0]: no name provided"},
+		{
+			name: "duplicate name",
+			replicas: []interface{}{
+				map[string]interface{}{"name": "eu", "cidrs": "10.0.0.0/8", "storage": storage},
+				map[string]interface{}{"name": "eu", "cidrs": "11.0.0.0/8", "storage": storage},
+			},
+			err: `replicas[1]: duplicate name "eu"`,
+		},
+		{
+			name:     "invalid cidr",
+			replicas: []interface{}{map[string]interface{}{"name": "eu", "cidrs": "10.0.0.0", "storage": storage}},
+			err:      "replicas[0]: invalid cidrs",
+		},
+		{
+			name:     "no clients",
+			replicas: []interface{}{map[string]interface{}{"name": "eu", "storage": storage}},
+			err:      "replicas[0]: at least one of cidrs or countries must be provided",
+		},
+		{
+			name:     "no storage",
+			replicas: []interface{}{map[string]interface{}{"name": "eu", "cidrs": "10.0.0.0/8"}},
+			err:      "replicas[0]: no storage provided",
+		},
+		{
+			name: "multiple storage drivers",
+			replicas: []interface{}{map[string]interface{}{
+				"name": "eu", "cidrs": "10.0.0.0/8", "storage": map[string]interface{}{"test": nil, "other": nil},
+			}},
+			err: "replicas[0]: storage must be a map with a single storage driver",
+		},
+		{
+			name: "unknown storage driver",
+			replicas: []interface{}{map[string]interface{}{
+				"name": "eu", "cidrs": "10.0.0.0/8", "storage": map[string]interface{}{"other": nil},
+			}},
+			err: `replicas[0]: creating "other" storage driver`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseReplicas(test.replicas, testFactory(make(map[string]*urlDriver)))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestNewGeoRoutingStorageMiddleware_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		err     string
+	}{
+		{name: "no replicas", options: map[string]interface{}{}, err: "no replicas provided"},
+		{
+			name: "countries without countryheader",
+			options: map[string]interface{}{
+				"replicas": []interface{}{map[string]interface{}{
+					"name": "eu", "countries": "de", "storage": map[string]interface{}{"inmemory": nil},
+				}},
+			},
+			err: "replicas[0]: countries require a countryheader",
+		},
+		{
+			name: "invalid checkreplication",
+			options: map[string]interface{}{
+				"replicas": []interface{}{map[string]interface{}{
+					"name": "eu", "cidrs": "10.0.0.0/8", "storage": map[string]interface{}{"inmemory": nil},
+				}},
+				"checkreplication": "yes",
+			},
+			err: "checkreplication must be a boolean",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newGeoRoutingStorageMiddleware(inmemory.New(), test.options)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func requestContext(ip, country string) context.Context {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":12345"
+	if country != "" {
+		req.Header.Set("X-Client-Country", country)
+	}
+	return dcontext.WithRequest(context.Background(), req)
+}
+
+func TestURLFor(t *testing.T) {
+	const path = "/docker/registry/v2/blobs/sha256/ab/abcd/data"
+
+	drivers := make(map[string]*urlDriver)
+	replicas, err := parseReplicas([]interface{}{
+		map[string]interface{}{
+			"name": "eu", "cidrs": "10.0.0.0/8", "countries": "de",
+			"storage": map[string]interface{}{"test": map[string]interface{}{"baseurl": "https://eu.example.com"}},
+		},
+		map[string]interface{}{
+			"name": "us", "countries": "us",
+			"storage": map[string]interface{}{"test": map[string]interface{}{"baseurl": "https://us.example.com"}},
+		},
+	}, testFactory(drivers))
+	require.NoError(t, err)
+
+	// the blob was only replicated to eu
+	ctx := context.Background()
+	primary := newURLDriver("https://primary.example.com")
+	require.NoError(t, primary.PutContent(ctx, path, []byte("foo")))
+	require.NoError(t, drivers["https://eu.example.com"].PutContent(ctx, path, []byte("foo")))
+
+	gr := &geoRoutingStorageMiddleware{
+		StorageDriver:    primary,
+		replicas:         replicas,
+		countryHeader:    "X-Client-Country",
+		checkReplication: true,
+	}
+
+	tests := []struct {
+		name             string
+		ip               string
+		country          string
+		checkReplication bool
+		expected         string
+	}{
+		{name: "cidr match", ip: "10.1.2.3", checkReplication: true, expected: "https://eu.example.com" + path},
+		{name: "country match is case insensitive", ip: "192.168.0.1", country: "de", checkReplication: true, expected: "https://eu.example.com" + path},
+		{name: "first match wins", ip: "10.1.2.3", country: "US", checkReplication: true, expected: "https://eu.example.com" + path},
+		{name: "not replicated", ip: "192.168.0.1", country: "US", checkReplication: true, expected: "https://primary.example.com" + path},
+		{name: "not replicated without check", ip: "192.168.0.1", country: "US", expected: "https://us.example.com" + path},
+		{name: "no match", ip: "192.168.0.1", country: "FR", checkReplication: true, expected: "https://primary.example.com" + path},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gr.checkReplication = test.checkReplication
+			u, err := gr.URLFor(requestContext(test.ip, test.country), path, nil)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, u)
+		})
+	}
+
+	// without a request, the primary storage is used
+	u, err := gr.URLFor(ctx, path, nil)
+	require.NoError(t, err)
+	require.Equal(t, "https://primary.example.com"+path, u)
+}
@@ -0,0 +1,178 @@
+package georouting
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// replica is a region-local replica of the storage backend, serving the clients within the given CIDR ranges or
+// countries.
+type replica struct {
+	name      string
+	driver    storagedriver.StorageDriver
+	networks  []*net.IPNet
+	countries map[string]struct{}
+}
+
+// matches checks if a client with the given IP and country code should be served by the replica.
+func (r *replica) matches(ip net.IP, country string) bool {
+	if ip != nil {
+		for _, n := range r.networks {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	if country != "" {
+		if _, ok := r.countries[strings.ToUpper(country)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// driverFactory creates a storage driver by name, with the given parameters.
+type driverFactory func(name string, parameters map[string]interface{}) (storagedriver.StorageDriver, error)
+
+// toStringMap converts a map parsed from YAML, which may have non-string keys, to a map with string keys.
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, v := range v {
+			m[fmt.Sprint(k)] = v
+		}
+		return m, true
+	default:
+		return nil, false
+	}
+}
+
+// parseStringList parses a list of strings from a middleware option, which may be a YAML list or a comma separated
+// string.
+func parseStringList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		var list []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		return list, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %T", item)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("expected a list of strings, got %T", v)
+	}
+}
+
+// parseStorage creates the storage driver of a replica from its storage option, a map with a single key, the name of
+// the storage driver, and its parameters as value, as in the top-level storage configuration.
+func parseStorage(v interface{}, create driverFactory) (storagedriver.StorageDriver, error) {
+	storage, ok := toStringMap(v)
+	if !ok || len(storage) != 1 {
+		return nil, fmt.Errorf("storage must be a map with a single storage driver")
+	}
+
+	var name string
+	var p interface{}
+	for k, v := range storage {
+		name, p = k, v
+	}
+
+	params := make(map[string]interface{})
+	if p != nil {
+		if params, ok = toStringMap(p); !ok {
+			return nil, fmt.Errorf("storage parameters of %q must be a map", name)
+		}
+	}
+	d, err := create(name, params)
+	if err != nil {
+		return nil, fmt.Errorf("creating %q storage driver: %w", name, err)
+	}
+	return d, nil
+}
+
+// parseReplicas parses the replicas option, a list of replicas with their storage driver configuration and the CIDR
+// ranges and countries of the clients they serve.
+func parseReplicas(v interface{}, create driverFactory) ([]*replica, error) {
+	items, ok := v.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("replicas must be a non-empty list")
+	}
+
+	names := make(map[string]struct{}, len(items))
+	replicas := make([]*replica, 0, len(items))
+	for i, item := range items {
+		options, ok := toStringMap(item)
+		if !ok {
+			return nil, fmt.Errorf("replicas[%d] must be a map", i)
+		}
+
+		name, ok := options["name"].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("replicas[%d]: no name provided", i)
+		}
+		if _, ok := names[name]; ok {
+			return nil, fmt.Errorf("replicas[%d]: duplicate name %q", i, name)
+		}
+		names[name] = struct{}{}
+		r := &replica{name: name, countries: make(map[string]struct{})}
+
+		if v, ok := options["cidrs"]; ok {
+			cidrs, err := parseStringList(v)
+			if err != nil {
+				return nil, fmt.Errorf("replicas[%d]: invalid cidrs: %v", i, err)
+			}
+			for _, cidr := range cidrs {
+				_, n, err := net.ParseCIDR(cidr)
+				if err != nil {
+					return nil, fmt.Errorf("replicas[%d]: invalid cidrs: %v", i, err)
+				}
+				r.networks = append(r.networks, n)
+			}
+		}
+		if v, ok := options["countries"]; ok {
+			countries, err := parseStringList(v)
+			if err != nil {
+				return nil, fmt.Errorf("replicas[%d]: invalid countries: %v", i, err)
+			}
+			for _, c := range countries {
+				r.countries[strings.ToUpper(strings.TrimSpace(c))] = struct{}{}
+			}
+		}
+		if len(r.networks) == 0 && len(r.countries) == 0 {
+			return nil, fmt.Errorf("replicas[%d]: at least one of cidrs or countries must be provided", i)
+		}
+
+		s, ok := options["storage"]
+		if !ok {
+			return nil, fmt.Errorf("replicas[%d]: no storage provided", i)
+		}
+		d, err := parseStorage(s, create)
+		if err != nil {
+			return nil, fmt.Errorf("replicas[%d]: %w", i, err)
+		}
+		r.driver = d
+
+		replicas = append(replicas, r)
+	}
+
+	return replicas, nil
+}
@@ -0,0 +1,71 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// budgetWindow is the number of seconds over which retries are weighed against requests.
+const budgetWindow = 10
+
+// budgetBucket counts the requests and retries within one second of the budget window.
+type budgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+// budget limits retries to a ratio of the requests made over the last budgetWindow seconds, plus a minimum number of
+// retries per second, so that retries can not multiply the load on a storage backend which is already failing.
+type budget struct {
+	ratio        float64
+	minPerSecond float64
+
+	mu      sync.Mutex
+	buckets [budgetWindow]budgetBucket
+	now     func() time.Time // for test purposes only
+}
+
+func newBudget(ratio, minPerSecond float64) *budget {
+	return &budget{ratio: ratio, minPerSecond: minPerSecond, now: time.Now}
+}
+
+// bucket returns the bucket of the current second, resetting it if it was last used for an earlier second. Must be
+// called with b.mu held.
+func (b *budget) bucket() *budgetBucket {
+	second := b.now().Unix()
+	bk := &b.buckets[second%budgetWindow]
+	if bk.second != second {
+		*bk = budgetBucket{second: second}
+	}
+	return bk
+}
+
+// request records a request.
+func (b *budget) request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bucket().requests++
+}
+
+// withdraw records a retry and returns true if the budget allows it, otherwise it returns false.
+func (b *budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.bucket()
+	var requests, retries int
+	for _, bk := range b.buckets {
+		if current.second-bk.second < budgetWindow {
+			requests += bk.requests
+			retries += bk.retries
+		}
+	}
+	if float64(retries) >= b.minPerSecond*budgetWindow+b.ratio*float64(requests) {
+		return false
+	}
+
+	current.retries++
+	return true
+}
@@ -0,0 +1,276 @@
+// Package retry provides a storage middleware that retries failed storage driver operations, with a retry policy per
+// class of operations.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/distribution/log"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+	"github.com/docker/distribution/registry/storage/internal/metrics"
+)
+
+// Operation classes, each with its own retry policy.
+const (
+	classRead   = "read"
+	classWrite  = "write"
+	classList   = "list"
+	classDelete = "delete"
+)
+
+// Reasons for giving up retrying an operation.
+const (
+	exhaustedAttempts = "attempts"
+	exhaustedBudget   = "budget"
+)
+
+var classes = []string{classRead, classWrite, classList, classDelete}
+
+// retryStorageMiddleware retries failed operations of the wrapped storage driver according to the retry policy of
+// their class:
+//
+//   - read: GetContent, Reader, Stat and ExistsPath
+//   - write: PutContent, Move and Writer, which only covers opening the file writer, not writing to it
+//   - list: List
+//   - delete: Delete and DeleteFiles
+//
+// Walk and WalkParallel are not retried, as the walk function may have side effects, and neither is URLFor, which
+// rarely reaches the storage backend. Errors which are not caused by the storage backend, such as when a path does not
+// exist, are never retried.
+type retryStorageMiddleware struct {
+	storagedriver.StorageDriver
+
+	policies map[string]*policy
+	sleep    func(ctx context.Context, d time.Duration) error // for test purposes only
+}
+
+var _ storagedriver.StorageDriver = &retryStorageMiddleware{}
+
+// newRetryStorageMiddleware constructs and returns a new retry storagedriver.StorageDriver implementation.
+// Optional options: read, write, list, delete
+// Each option is the retry policy of the operation class of the same name, a map with the following optional keys:
+// maxattempts: the maximum number of attempts of an operation, including the first one. Defaults to 3.
+// initialbackoff: the time to wait before the first retry. Defaults to 100ms.
+// maxbackoff: the maximum time to wait between retries. Defaults to 5s.
+// multiplier: the factor by which the backoff grows after each retry. Defaults to 2.
+// jitter: the fraction by which backoffs are randomly increased or decreased. Defaults to 0.2.
+// budget: a map with the ratio of retries to requests allowed over the last 10 seconds, 0.1 by default, and the
+//
+//	minretriespersecond allowed regardless of the number of requests, 10 by default.
+func newRetryStorageMiddleware(storageDriver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+	policies := make(map[string]*policy, len(classes))
+	for _, class := range classes {
+		p, err := parsePolicy(options[class])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s retry policy: %w", class, err)
+		}
+		policies[class] = p
+	}
+
+	return &retryStorageMiddleware{
+		StorageDriver: storageDriver,
+		policies:      policies,
+		sleep:         sleep,
+	}, nil
+}
+
+// sleep waits for d, or until ctx is done, in which case the context error is returned.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryable returns whether an operation which failed with err may succeed if retried.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// the catch-all storage driver error does not unwrap its enclosed error
+	var sdErr storagedriver.Error
+	if errors.As(err, &sdErr) && sdErr.Enclosed != nil {
+		err = sdErr.Enclosed
+	}
+
+	switch {
+	case errors.As(err, &storagedriver.PathNotFoundError{}),
+		errors.As(err, &storagedriver.InvalidPathError{}),
+		errors.As(err, &storagedriver.InvalidOffsetError{}),
+		errors.As(err, &storagedriver.ErrUnsupportedMethod{}):
+		return false
+	default:
+		return true
+	}
+}
+
+// do calls op, retrying it according to the retry policy of the given class until it succeeds, fails with an error
+// which is not retryable, or the attempts or retry budget are exhausted. The error of the last attempt is returned.
+func (r *retryStorageMiddleware) do(ctx context.Context, class, method, path string, op func() error) error {
+	p := r.policies[class]
+	p.budget.request()
+
+	err := op()
+	for attempt := 1; err != nil && retryable(err) && ctx.Err() == nil; attempt++ {
+		l := log.GetLogger(log.WithContext(ctx)).WithError(err).WithFields(log.Fields{
+			"driver":          r.StorageDriver.Name(),
+			"method":          method,
+			"path":            path,
+			"operation_class": class,
+			"attempt":         attempt,
+		})
+
+		if attempt >= p.maxAttempts {
+			if p.maxAttempts > 1 {
+				metrics.StorageRetryExhausted(class, exhaustedAttempts)
+				l.Warn("storage driver operation failed, giving up after reaching the maximum number of attempts")
+			}
+			return err
+		}
+		if !p.budget.withdraw() {
+			metrics.StorageRetryExhausted(class, exhaustedBudget)
+			l.Warn("storage driver operation failed, not retrying as the retry budget is exhausted")
+			return err
+		}
+
+		backoff := p.backoff(attempt)
+		l.WithFields(log.Fields{"backoff_s": backoff.Seconds()}).Info("storage driver operation failed, retrying")
+		if r.sleep(ctx, backoff) != nil {
+			return err
+		}
+		metrics.StorageRetry(class)
+		err = op()
+	}
+
+	return err
+}
+
+// GetContent wraps GetContent of the storage driver, retrying it according to the read retry policy.
+func (r *retryStorageMiddleware) GetContent(ctx context.Context, path string) ([]byte, error) {
+	var content []byte
+	err := r.do(ctx, classRead, "GetContent", path, func() error {
+		var err error
+		content, err = r.StorageDriver.GetContent(ctx, path)
+		return err
+	})
+	return content, err
+}
+
+// Reader wraps Reader of the storage driver, retrying it according to the read retry policy. Only opening the reader
+// is retried, not reading from it.
+func (r *retryStorageMiddleware) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	err := r.do(ctx, classRead, "Reader", path, func() error {
+		var err error
+		rc, err = r.StorageDriver.Reader(ctx, path, offset)
+		return err
+	})
+	return rc, err
+}
+
+// Stat wraps Stat of the storage driver, retrying it according to the read retry policy.
+func (r *retryStorageMiddleware) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	var fi storagedriver.FileInfo
+	err := r.do(ctx, classRead, "Stat", path, func() error {
+		var err error
+		fi, err = r.StorageDriver.Stat(ctx, path)
+		return err
+	})
+	return fi, err
+}
+
+// ExistsPath wraps ExistsPath of the storage driver, retrying it according to the read retry policy.
+func (r *retryStorageMiddleware) ExistsPath(ctx context.Context, path string) (bool, error) {
+	var exists bool
+	err := r.do(ctx, classRead, "ExistsPath", path, func() error {
+		var err error
+		exists, err = r.StorageDriver.ExistsPath(ctx, path)
+		return err
+	})
+	return exists, err
+}
+
+// PutContent wraps PutContent of the storage driver, retrying it according to the write retry policy.
+func (r *retryStorageMiddleware) PutContent(ctx context.Context, path string, content []byte) error {
+	return r.do(ctx, classWrite, "PutContent", path, func() error {
+		return r.StorageDriver.PutContent(ctx, path, content)
+	})
+}
+
+// Writer wraps Writer of the storage driver, retrying it according to the write retry policy. Only opening the file
+// writer is retried, not writing to it.
+func (r *retryStorageMiddleware) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
+	var fw storagedriver.FileWriter
+	err := r.do(ctx, classWrite, "Writer", path, func() error {
+		var err error
+		fw, err = r.StorageDriver.Writer(ctx, path, append)
+		return err
+	})
+	return fw, err
+}
+
+// Move wraps Move of the storage driver, retrying it according to the write retry policy. Moves are not idempotent, as
+// an attempt may fail after moving the file, for example if the response of the storage backend was lost. A retry
+// which finds no file at the source path is therefore considered successful if there is one at the destination path.
+func (r *retryStorageMiddleware) Move(ctx context.Context, sourcePath, destPath string) error {
+	var attempts int
+	return r.do(ctx, classWrite, "Move", sourcePath, func() error {
+		attempts++
+		err := r.StorageDriver.Move(ctx, sourcePath, destPath)
+		if attempts > 1 && errors.As(err, &storagedriver.PathNotFoundError{}) {
+			if _, statErr := r.StorageDriver.Stat(ctx, destPath); statErr == nil {
+				return nil
+			}
+		}
+		return err
+	})
+}
+
+// List wraps List of the storage driver, retrying it according to the list retry policy.
+func (r *retryStorageMiddleware) List(ctx context.Context, path string) ([]string, error) {
+	var list []string
+	err := r.do(ctx, classList, "List", path, func() error {
+		var err error
+		list, err = r.StorageDriver.List(ctx, path)
+		return err
+	})
+	return list, err
+}
+
+// Delete wraps Delete of the storage driver, retrying it according to the delete retry policy.
+func (r *retryStorageMiddleware) Delete(ctx context.Context, path string) error {
+	return r.do(ctx, classDelete, "Delete", path, func() error {
+		return r.StorageDriver.Delete(ctx, path)
+	})
+}
+
+// DeleteFiles wraps DeleteFiles of the storage driver, retrying it according to the delete retry policy. As deletes
+// are idempotent, all files are deleted again on retries. The highest count of deleted files of all attempts is
+// returned.
+func (r *retryStorageMiddleware) DeleteFiles(ctx context.Context, paths []string) (int, error) {
+	var count int
+	err := r.do(ctx, classDelete, "DeleteFiles", "", func() error {
+		n, err := r.StorageDriver.DeleteFiles(ctx, paths)
+		if n > count {
+			count = n
+		}
+		return err
+	})
+	return count, err
+}
+
+// init registers the retry middleware.
+func init() {
+	storagemiddleware.Register("retry", newRetryStorageMiddleware)
+}
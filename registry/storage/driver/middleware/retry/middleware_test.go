@@ -0,0 +1,261 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyDriver is an in-memory storage driver whose operations fail with the errors in errs, in order, before
+// succeeding.
+type flakyDriver struct {
+	*inmemory.Driver
+	errs  []error
+	calls int
+}
+
+func (d *flakyDriver) fail() error {
+	d.calls++
+	if len(d.errs) == 0 {
+		return nil
+	}
+	err := d.errs[0]
+	d.errs = d.errs[1:]
+	return err
+}
+
+func (d *flakyDriver) GetContent(ctx context.Context, path string) ([]byte, error) {
+	if err := d.fail(); err != nil {
+		return nil, err
+	}
+	return d.Driver.GetContent(ctx, path)
+}
+
+func (d *flakyDriver) Delete(ctx context.Context, path string) error {
+	if err := d.fail(); err != nil {
+		return err
+	}
+	return d.Driver.Delete(ctx, path)
+}
+
+// Move moves the file and then fails with the errors in errs, in order, as if the response of the storage backend was
+// lost.
+func (d *flakyDriver) Move(ctx context.Context, sourcePath, destPath string) error {
+	if err := d.Driver.Move(ctx, sourcePath, destPath); err != nil {
+		return err
+	}
+	return d.fail()
+}
+
+func newTestMiddleware(t *testing.T, d storagedriver.StorageDriver, options map[string]interface{}) (*retryStorageMiddleware, *[]time.Duration) {
+	t.Helper()
+
+	sd, err := newRetryStorageMiddleware(d, options)
+	require.NoError(t, err)
+	r := sd.(*retryStorageMiddleware)
+
+	var sleeps []time.Duration
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return ctx.Err()
+	}
+	for _, p := range r.policies {
+		p.rand = func() float64 { return 0.5 }
+	}
+
+	return r, &sleeps
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	errBackend := errors.New("backend unavailable")
+
+	d := &flakyDriver{Driver: inmemory.New(), errs: []error{errBackend, errBackend}}
+	require.NoError(t, d.PutContent(ctx, "/foo", []byte("bar")))
+	r, sleeps := newTestMiddleware(t, d, nil)
+
+	content, err := r.GetContent(ctx, "/foo")
+	require.NoError(t, err)
+	require.Equal(t, []byte("bar"), content)
+	require.Equal(t, 3, d.calls)
+	require.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, *sleeps)
+}
+
+func TestRetry_MaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	errBackend := errors.New("backend unavailable")
+
+	d := &flakyDriver{Driver: inmemory.New(), errs: []error{errBackend, errBackend, errBackend}}
+	r, sleeps := newTestMiddleware(t, d, map[string]interface{}{
+		"delete": map[interface{}]interface{}{"maxattempts": 2, "initialbackoff": "1s"},
+	})
+
+	err := r.Delete(ctx, "/foo")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, 2, d.calls)
+	require.Equal(t, []time.Duration{time.Second}, *sleeps)
+}
+
+func TestRetry_NotRetryable(t *testing.T) {
+	ctx := context.Background()
+
+	d := &flakyDriver{Driver: inmemory.New()}
+	r, sleeps := newTestMiddleware(t, d, nil)
+
+	_, err := r.GetContent(ctx, "/foo")
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+	require.Equal(t, 1, d.calls)
+	require.Empty(t, *sleeps)
+
+	d.errs = []error{storagedriver.Error{DriverName: "test", Enclosed: storagedriver.InvalidPathError{Path: "foo"}}}
+	_, err = r.GetContent(ctx, "foo")
+	require.Error(t, err)
+	require.Equal(t, 2, d.calls)
+	require.Empty(t, *sleeps)
+}
+
+func TestRetry_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errBackend := errors.New("backend unavailable")
+
+	d := &flakyDriver{Driver: inmemory.New(), errs: []error{errBackend, errBackend}}
+	r, sleeps := newTestMiddleware(t, d, nil)
+	cancel()
+
+	_, err := r.GetContent(ctx, "/foo")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, 1, d.calls)
+	require.Empty(t, *sleeps)
+}
+
+func TestRetry_BudgetExhausted(t *testing.T) {
+	ctx := context.Background()
+	errBackend := errors.New("backend unavailable")
+
+	d := &flakyDriver{Driver: inmemory.New()}
+	r, _ := newTestMiddleware(t, d, map[string]interface{}{
+		"read": map[string]interface{}{
+			"maxattempts": 2,
+			"budget":      map[string]interface{}{"ratio": 0.5, "minretriespersecond": 0},
+		},
+	})
+
+	// one retry is allowed for every two requests
+	d.errs = []error{errBackend, errBackend}
+	_, err := r.GetContent(ctx, "/foo")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, 2, d.calls)
+
+	d.errs = []error{errBackend, errBackend}
+	_, err = r.GetContent(ctx, "/foo")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, 3, d.calls)
+}
+
+func TestRetry_MoveAmbiguousSuccess(t *testing.T) {
+	ctx := context.Background()
+	errBackend := errors.New("backend unavailable")
+
+	d := &flakyDriver{Driver: inmemory.New(), errs: []error{errBackend}}
+	require.NoError(t, d.PutContent(ctx, "/foo", []byte("bar")))
+	r, sleeps := newTestMiddleware(t, d, nil)
+
+	// the first attempt moved the file despite failing, so the retry finds no source but the destination exists
+	require.NoError(t, r.Move(ctx, "/foo", "/baz"))
+	require.Len(t, *sleeps, 1)
+
+	content, err := r.GetContent(ctx, "/baz")
+	require.NoError(t, err)
+	require.Equal(t, []byte("bar"), content)
+
+	// a missing source is still an error on the first attempt
+	err = r.Move(ctx, "/foo", "/baz")
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+}
+
+func TestNewRetryStorageMiddleware_StringOptions(t *testing.T) {
+	sd, err := newRetryStorageMiddleware(inmemory.New(), map[string]interface{}{
+		"read": map[interface{}]interface{}{
+			"maxattempts":    "5",
+			"initialbackoff": "1s",
+			"multiplier":     "1.5",
+			"jitter":         "0",
+			"budget":         map[interface{}]interface{}{"ratio": "0.5", "minretriespersecond": "1"},
+		},
+	})
+	require.NoError(t, err)
+
+	p := sd.(*retryStorageMiddleware).policies[classRead]
+	require.Equal(t, 5, p.maxAttempts)
+	require.Equal(t, time.Second, p.initialBackoff)
+	require.Equal(t, 1.5, p.multiplier)
+	require.Zero(t, p.jitter)
+}
+
+func TestBackoff(t *testing.T) {
+	p, err := parsePolicy(map[string]interface{}{"initialbackoff": "1s", "maxbackoff": "5s", "multiplier": 3, "jitter": 0.5})
+	require.NoError(t, err)
+
+	p.rand = func() float64 { return 0.5 }
+	require.Equal(t, time.Second, p.backoff(1))
+	require.Equal(t, 3*time.Second, p.backoff(2))
+	require.Equal(t, 5*time.Second, p.backoff(3))
+
+	p.rand = func() float64 { return 0 }
+	require.Equal(t, 500*time.Millisecond, p.backoff(1))
+	p.rand = func() float64 { return 1 }
+	require.Equal(t, 7500*time.Millisecond, p.backoff(3))
+}
+
+func TestBudget(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := newBudget(0.1, 1)
+	b.now = func() time.Time { return now }
+
+	// the minimum number of retries is allowed over the window, regardless of requests
+	for i := 0; i < budgetWindow; i++ {
+		require.True(t, b.withdraw())
+	}
+	require.False(t, b.withdraw())
+
+	// requests add to the budget
+	for i := 0; i < 10; i++ {
+		b.request()
+	}
+	require.True(t, b.withdraw())
+	require.False(t, b.withdraw())
+
+	// retries older than the window are forgotten
+	now = now.Add(budgetWindow * time.Second)
+	require.True(t, b.withdraw())
+}
+
+func TestNewRetryStorageMiddleware_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		err     string
+	}{
+		{name: "not a map", options: map[string]interface{}{"read": 3}, err: "invalid read retry policy: must be a map"},
+		{name: "maxattempts", options: map[string]interface{}{"write": map[string]interface{}{"maxattempts": 0}}, err: "invalid write retry policy: maxattempts must be a positive integer"},
+		{name: "initialbackoff", options: map[string]interface{}{"list": map[string]interface{}{"initialbackoff": "soon"}}, err: "invalid list retry policy: invalid initialbackoff"},
+		{name: "maxbackoff", options: map[string]interface{}{"read": map[string]interface{}{"initialbackoff": "1s", "maxbackoff": "10ms"}}, err: "invalid read retry policy: maxbackoff must not be less than initialbackoff"},
+		{name: "multiplier", options: map[string]interface{}{"read": map[string]interface{}{"multiplier": 0.5}}, err: "invalid read retry policy: multiplier must be a number greater than or equal to 1"},
+		{name: "jitter", options: map[string]interface{}{"delete": map[string]interface{}{"jitter": 2}}, err: "invalid delete retry policy: jitter must be a number between 0 and 1"},
+		{name: "budget", options: map[string]interface{}{"read": map[string]interface{}{"budget": 0.1}}, err: "invalid read retry policy: budget must be a map"},
+		{name: "budget ratio", options: map[string]interface{}{"read": map[string]interface{}{"budget": map[string]interface{}{"ratio": -1}}}, err: "invalid read retry policy: budget ratio must be a non-negative number"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newRetryStorageMiddleware(inmemory.New(), test.options)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.err)
+		})
+	}
+}
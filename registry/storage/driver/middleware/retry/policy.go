@@ -0,0 +1,123 @@
+package retry
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/docker/distribution/registry/storage/driver/internal/parse"
+)
+
+const (
+	defaultMaxAttempts               = 3
+	defaultInitialBackoff            = 100 * time.Millisecond
+	defaultMaxBackoff                = 5 * time.Second
+	defaultMultiplier                = 2.0
+	defaultJitter                    = 0.2
+	defaultBudgetRatio               = 0.1
+	defaultBudgetMinRetriesPerSecond = 10.0
+)
+
+// policy is the retry policy of a class of storage driver operations.
+type policy struct {
+	// maxAttempts is the maximum number of attempts of an operation, including the first one. Operations are not
+	// retried if set to 1.
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	// jitter is the fraction by which backoffs are randomly increased or decreased, so that operations which failed at
+	// the same time are not retried at the same time.
+	jitter float64
+	budget *budget
+
+	rand func() float64 // for test purposes only
+}
+
+// backoff returns how long to wait before the given retry, starting at 1.
+func (p *policy) backoff(retry int) time.Duration {
+	d := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(retry-1))
+	if d > float64(p.maxBackoff) {
+		d = float64(p.maxBackoff)
+	}
+	// a random factor in [1-jitter, 1+jitter)
+	d *= 1 + p.jitter*(2*p.rand()-1)
+
+	return time.Duration(d)
+}
+
+// parsePolicy parses the retry policy of an operation class from its middleware option, a map with the maxattempts,
+// initialbackoff, maxbackoff, multiplier, jitter and budget keys, all optional. A nil option results in the default
+// policy.
+func parsePolicy(v interface{}) (*policy, error) {
+	p := &policy{
+		maxAttempts:    defaultMaxAttempts,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+		multiplier:     defaultMultiplier,
+		jitter:         defaultJitter,
+		rand:           rand.Float64,
+	}
+	ratio, minPerSecond := defaultBudgetRatio, defaultBudgetMinRetriesPerSecond
+
+	options := make(map[string]interface{})
+	if v != nil {
+		var ok bool
+		if options, ok = toStringMap(v); !ok {
+			return nil, fmt.Errorf("must be a map")
+		}
+	}
+
+	var err error
+	if p.maxAttempts, err = parse.Int(options, "maxattempts", p.maxAttempts); err != nil || p.maxAttempts < 1 {
+		return nil, fmt.Errorf("maxattempts must be a positive integer")
+	}
+	if p.initialBackoff, err = parse.Duration(options, "initialbackoff", p.initialBackoff); err != nil {
+		return nil, fmt.Errorf("invalid initialbackoff: %w", err)
+	}
+	if p.maxBackoff, err = parse.Duration(options, "maxbackoff", p.maxBackoff); err != nil {
+		return nil, fmt.Errorf("invalid maxbackoff: %w", err)
+	}
+	if p.maxBackoff < p.initialBackoff {
+		return nil, fmt.Errorf("maxbackoff must not be less than initialbackoff")
+	}
+	if p.multiplier, err = parse.Float(options, "multiplier", p.multiplier); err != nil || p.multiplier < 1 {
+		return nil, fmt.Errorf("multiplier must be a number greater than or equal to 1")
+	}
+	if p.jitter, err = parse.Float(options, "jitter", p.jitter); err != nil || p.jitter < 0 || p.jitter > 1 {
+		return nil, fmt.Errorf("jitter must be a number between 0 and 1")
+	}
+
+	if o, ok := options["budget"]; ok {
+		b, ok := toStringMap(o)
+		if !ok {
+			return nil, fmt.Errorf("budget must be a map")
+		}
+		if ratio, err = parse.Float(b, "ratio", ratio); err != nil || ratio < 0 {
+			return nil, fmt.Errorf("budget ratio must be a non-negative number")
+		}
+		if minPerSecond, err = parse.Float(b, "minretriespersecond", minPerSecond); err != nil || minPerSecond < 0 {
+			return nil, fmt.Errorf("budget minretriespersecond must be a non-negative number")
+		}
+	}
+	p.budget = newBudget(ratio, minPerSecond)
+
+	return p, nil
+}
+
+// toStringMap converts a map parsed from YAML, which may have non-string keys, to a map with string keys.
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, v := range v {
+			m[fmt.Sprint(k)] = v
+		}
+		return m, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,174 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// preflightTimeout bounds the time taken by all preflight checks.
+	preflightTimeout = time.Minute
+	// preflightRoot is the directory, relative to the root directory, under which preflight probe objects are written.
+	preflightRoot = "/_preflight"
+	// preflightProbeSize is the size of the preflight probe objects.
+	preflightProbeSize = 1024
+)
+
+// PreflightError describes a failed preflight check.
+type PreflightError struct {
+	// Check is the name of the check that failed.
+	Check string
+	// Permission is the S3 permission the check relies on.
+	Permission string
+	Err        error
+}
+
+func (e *PreflightError) Error() string {
+	msg := fmt.Sprintf("s3 preflight: %s check failed: %v", e.Check, e.Err)
+	if isAccessDenied(e.Err) {
+		msg += fmt.Sprintf(" (make sure the credentials are granted the %s permission)", e.Permission)
+	}
+	return msg
+}
+
+func (e *PreflightError) Unwrap() error {
+	return e.Err
+}
+
+func isAccessDenied(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	switch awsErr.Code() {
+	case "AccessDenied", "Forbidden", "SignatureDoesNotMatch", "InvalidAccessKeyId":
+		return true
+	}
+	var reqErr awserr.RequestFailure
+	return errors.As(err, &reqErr) && reqErr.StatusCode() == 403
+}
+
+// preflight checks that the bucket is reachable, that the credentials allow every operation the driver relies on,
+// and that the backend provides the read-after-write, list-after-write and read-after-delete consistency it expects.
+// It writes, reads and deletes a few probe objects under the root directory, and returns a *PreflightError for the
+// first check that failed.
+func (d *driver) preflight(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	l := log.WithFields(log.Fields{"bucket": d.Bucket, "root_directory": d.RootDirectory})
+	start := time.Now()
+
+	if _, err := d.S3.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(d.Bucket)}); err != nil {
+		return &PreflightError{Check: "bucket access", Permission: "s3:ListBucket", Err: err}
+	}
+
+	dir := path.Join(preflightRoot, uuid.Generate().String())
+	defer func() {
+		// clean up probes left behind by failed checks, ignoring those that were never written or already deleted
+		if err := d.Delete(ctx, dir); err != nil && !errors.As(err, new(storagedriver.PathNotFoundError)) {
+			l.WithError(err).Warn("S3: failed to delete preflight probe objects")
+		}
+	}()
+
+	content := make([]byte, preflightProbeSize)
+	if _, err := rand.Read(content); err != nil {
+		return fmt.Errorf("s3 preflight: generating probe content: %w", err)
+	}
+
+	objectPath := path.Join(dir, "object")
+	if err := d.PutContent(ctx, objectPath, content); err != nil {
+		return &PreflightError{Check: "object write", Permission: "s3:PutObject", Err: err}
+	}
+	if err := d.checkProbe(ctx, objectPath, content); err != nil {
+		return err
+	}
+
+	multipartPath := path.Join(dir, "multipart")
+	if err := d.putMultipartProbe(ctx, multipartPath, content); err != nil {
+		return &PreflightError{Check: "multipart upload", Permission: "s3:PutObject", Err: err}
+	}
+	if err := d.checkProbe(ctx, multipartPath, content); err != nil {
+		return err
+	}
+
+	children, err := d.List(ctx, dir)
+	if err != nil {
+		return &PreflightError{Check: "object list", Permission: "s3:ListBucket", Err: err}
+	}
+	if len(children) != 2 {
+		return &PreflightError{
+			Check:      "list-after-write consistency",
+			Permission: "s3:ListBucket",
+			Err:        fmt.Errorf("listed %d of the 2 probe objects just written", len(children)),
+		}
+	}
+
+	if err := d.Delete(ctx, dir); err != nil {
+		return &PreflightError{Check: "object delete", Permission: "s3:DeleteObject", Err: err}
+	}
+	for _, p := range []string{objectPath, multipartPath} {
+		_, err := d.GetContent(ctx, p)
+		if err == nil {
+			return &PreflightError{
+				Check:      "read-after-delete consistency",
+				Permission: "s3:GetObject",
+				Err:        fmt.Errorf("probe object %q is still readable after being deleted", p),
+			}
+		}
+		if !errors.As(err, new(storagedriver.PathNotFoundError)) {
+			return &PreflightError{Check: "read-after-delete consistency", Permission: "s3:GetObject", Err: err}
+		}
+	}
+
+	l.WithField("duration_s", time.Since(start).Seconds()).Info("S3: preflight checks passed")
+	return nil
+}
+
+// putMultipartProbe writes content to path with a multipart upload.
+func (d *driver) putMultipartProbe(ctx context.Context, path string, content []byte) error {
+	w, err := d.Writer(ctx, path, false)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		_ = w.Cancel()
+		return err
+	}
+	return w.Commit()
+}
+
+// checkProbe checks that the probe object at path can be read back with the expected content.
+func (d *driver) checkProbe(ctx context.Context, path string, content []byte) error {
+	b, err := d.GetContent(ctx, path)
+	if err != nil {
+		if errors.As(err, new(storagedriver.PathNotFoundError)) {
+			return &PreflightError{
+				Check:      "read-after-write consistency",
+				Permission: "s3:GetObject",
+				Err:        fmt.Errorf("probe object %q not found right after being written", path),
+			}
+		}
+		return &PreflightError{Check: "object read", Permission: "s3:GetObject", Err: err}
+	}
+	if !bytes.Equal(b, content) {
+		return &PreflightError{
+			Check:      "read-after-write consistency",
+			Permission: "s3:GetObject",
+			Err:        fmt.Errorf("probe object %q read back with different content", path),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/require"
+)
+
+// mockBucket is a minimal in-memory S3 bucket, with injectable failures and consistency anomalies.
+type mockBucket struct {
+	s3iface.S3API
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int64][]byte
+
+	// errs maps operation names to the error they should fail with
+	errs map[string]error
+	// staleReads makes reads miss objects, as if they were not propagated yet
+	staleReads bool
+	// staleDeletes makes deletes succeed without removing objects
+	staleDeletes bool
+}
+
+func newMockBucket() *mockBucket {
+	return &mockBucket{
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int64][]byte),
+		errs:    make(map[string]error),
+	}
+}
+
+func (m *mockBucket) HeadBucketWithContext(ctx aws.Context, input *s3.HeadBucketInput, opts ...request.Option) (*s3.HeadBucketOutput, error) {
+	if err := m.errs["HeadBucket"]; err != nil {
+		return nil, err
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (m *mockBucket) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if err := m.errs["PutObject"]; err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[*input.Key] = b
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockBucket) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if err := m.errs["GetObject"]; err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.objects[*input.Key]
+	if !ok || m.staleReads {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func (m *mockBucket) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	if err := m.errs["CreateMultipartUpload"]; err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads[*input.Key] = make(map[int64][]byte)
+	return &s3.CreateMultipartUploadOutput{UploadId: input.Key}, nil
+}
+
+func (m *mockBucket) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	b, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads[*input.UploadId][*input.PartNumber] = b
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+}
+
+func (m *mockBucket) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	if err := m.errs["CompleteMultipartUpload"]; err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b []byte
+	for _, p := range input.MultipartUpload.Parts {
+		b = append(b, m.uploads[*input.UploadId][*p.PartNumber]...)
+	}
+	delete(m.uploads, *input.UploadId)
+	m.objects[*input.Key] = b
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockBucket) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.uploads, *input.UploadId)
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (m *mockBucket) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	if err := m.errs["ListObjectsV2"]; err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for k := range m.objects {
+		if strings.HasPrefix(k, *input.Prefix) && (input.StartAfter == nil || k > *input.StartAfter) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	prefixes := make(map[string]bool)
+	for _, k := range keys {
+		if input.Delimiter != nil {
+			if i := strings.Index(k[len(*input.Prefix):], *input.Delimiter); i >= 0 {
+				p := k[:len(*input.Prefix)+i+1]
+				if !prefixes[p] {
+					prefixes[p] = true
+					out.CommonPrefixes = append(out.CommonPrefixes, &s3.CommonPrefix{Prefix: aws.String(p)})
+				}
+				continue
+			}
+		}
+		out.Contents = append(out.Contents, &s3.Object{
+			Key:          aws.String(k),
+			Size:         aws.Int64(int64(len(m.objects[k]))),
+			LastModified: aws.Time(time.Now()),
+		})
+	}
+	return out, nil
+}
+
+func (m *mockBucket) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	if err := m.errs["DeleteObjects"]; err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.staleDeletes {
+		for _, o := range input.Delete.Objects {
+			delete(m.objects, *o.Key)
+		}
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func TestDriver_preflight(t *testing.T) {
+	accessDenied := awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "")
+
+	tests := []struct {
+		name      string
+		setup     func(m *mockBucket)
+		wantCheck string
+		wantErr   string
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:      "bucket not found",
+			setup:     func(m *mockBucket) { m.errs["HeadBucket"] = awserr.New("NotFound", "not found", nil) },
+			wantCheck: "bucket access",
+			wantErr:   "s3 preflight: bucket access check failed: NotFound: not found",
+		},
+		{
+			name:      "write access denied",
+			setup:     func(m *mockBucket) { m.errs["PutObject"] = accessDenied },
+			wantCheck: "object write",
+			wantErr:   "make sure the credentials are granted the s3:PutObject permission",
+		},
+		{
+			name:      "read access denied",
+			setup:     func(m *mockBucket) { m.errs["GetObject"] = accessDenied },
+			wantCheck: "object read",
+			wantErr:   "make sure the credentials are granted the s3:GetObject permission",
+		},
+		{
+			name: "multipart upload not supported",
+			setup: func(m *mockBucket) {
+				m.errs["CompleteMultipartUpload"] = awserr.New("NotImplemented", "not implemented", nil)
+			},
+			wantCheck: "multipart upload",
+			wantErr:   "s3 preflight: multipart upload check failed: NotImplemented: not implemented",
+		},
+		{
+			name:      "stale reads",
+			setup:     func(m *mockBucket) { m.staleReads = true },
+			wantCheck: "read-after-write consistency",
+			wantErr:   "not found right after being written",
+		},
+		{
+			name:      "stale deletes",
+			setup:     func(m *mockBucket) { m.staleDeletes = true },
+			wantCheck: "read-after-delete consistency",
+			wantErr:   "is still readable after being deleted",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newMockBucket()
+			if test.setup != nil {
+				test.setup(m)
+			}
+			d := &driver{
+				S3:            newS3Wrapper(m),
+				Bucket:        "test",
+				ChunkSize:     minChunkSize,
+				RootDirectory: "/root",
+			}
+
+			err := d.preflight(context.Background())
+			if test.wantCheck == "" {
+				require.NoError(t, err)
+				require.Empty(t, m.objects)
+				return
+			}
+
+			var perr *PreflightError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, test.wantCheck, perr.Check)
+			require.ErrorContains(t, err, test.wantErr)
+
+			// probe objects are cleaned up, unless deletes have no effect
+			if !m.staleDeletes {
+				require.Empty(t, m.objects)
+			}
+		})
+	}
+}
@@ -43,10 +43,12 @@ import (
 	"github.com/docker/distribution/registry/storage/driver/base"
 	"github.com/docker/distribution/registry/storage/driver/factory"
 	"github.com/docker/distribution/registry/storage/driver/internal/parse"
+	"github.com/docker/distribution/registry/storage/internal/metrics"
 	"github.com/docker/distribution/version"
 	"github.com/hashicorp/go-multierror"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/gitlab-org/labkit/fips"
+	"golang.org/x/sync/semaphore"
 )
 
 const driverName = "s3aws"
@@ -65,6 +67,9 @@ const defaultChunkSize = 2 * minChunkSize
 // This is defined in order to prevent infinite loops (i.e. an unbounded amount of parts-pages).
 const maxListRespLoop = 10000
 
+// maxPartNumber is the maximum number of parts of a multipart upload allowed by S3.
+const maxPartNumber = 10000
+
 const (
 	// defaultMultipartCopyChunkSize defines the default chunk size for all
 	// but the last Upload Part - Copy operation of a multipart copy.
@@ -81,6 +86,21 @@ const (
 	defaultMultipartCopyThresholdSize = 32 << 20
 )
 
+const (
+	// defaultMultipartUploadMaxConcurrency defines the default maximum number
+	// of concurrent Upload Part operations per blob write. Parts are uploaded
+	// sequentially by default.
+	defaultMultipartUploadMaxConcurrency = 1
+
+	// adaptiveChunkSizeTargetParts is the number of parts in which a write is
+	// split when adaptive chunk sizing is enabled and its size is known.
+	adaptiveChunkSizeTargetParts = 100
+
+	// maxAdaptiveChunkSize is the largest chunk size picked by adaptive chunk
+	// sizing, which bounds the memory used by each writer.
+	maxAdaptiveChunkSize = 128 << 20
+)
+
 // listMax is the largest amount of objects you can request from S3 in a list call
 const listMax = 1000
 
@@ -103,7 +123,8 @@ const noStorageClass = "NONE"
 
 // defaults related to exponential backoff
 const (
-	// defaultMaxRetries is how many times the driver will retry failed requests.
+	// defaultMaxRetries is how many times the driver will retry the failed
+	// requests of file writers, which the retry storage middleware cannot retry.
 	defaultMaxRetries          = 5
 	defaultInitialInterval     = backoff.DefaultInitialInterval
 	defaultRandomizationFactor = backoff.DefaultRandomizationFactor
@@ -123,30 +144,35 @@ var errMaxListRespExceeded = fmt.Errorf("layer parts pages exceeds the maximum o
 
 // DriverParameters A struct that encapsulates all of the driver parameters after all values have been set
 type DriverParameters struct {
-	AccessKey                   string
-	SecretKey                   string
-	Bucket                      string
-	Region                      string
-	RegionEndpoint              string
-	Encrypt                     bool
-	KeyID                       string
-	Secure                      bool
-	SkipVerify                  bool
-	V4Auth                      bool
-	ChunkSize                   int64
-	MultipartCopyChunkSize      int64
-	MultipartCopyMaxConcurrency int64
-	MultipartCopyThresholdSize  int64
-	RootDirectory               string
-	StorageClass                string
-	ObjectACL                   string
-	SessionToken                string
-	PathStyle                   bool
-	MaxRequestsPerSecond        int64
-	MaxRetries                  int64
-	ParallelWalk                bool
-	LogLevel                    aws.LogLevelType
-	ObjectOwnership             bool
+	AccessKey                     string
+	SecretKey                     string
+	Bucket                        string
+	Region                        string
+	RegionEndpoint                string
+	Encrypt                       bool
+	KeyID                         string
+	Secure                        bool
+	SkipVerify                    bool
+	V4Auth                        bool
+	ChunkSize                     int64
+	MultipartCopyChunkSize        int64
+	MultipartCopyMaxConcurrency   int64
+	MultipartCopyThresholdSize    int64
+	MultipartUploadMaxConcurrency int64
+	MultipartUploadMaxBufferSize  int64
+	AdaptiveChunkSize             bool
+	RootDirectory                 string
+	StorageClass                  string
+	ObjectACL                     string
+	SessionToken                  string
+	PathStyle                     bool
+	MaxRequestsPerSecond          int64
+	MaxRetries                    int64
+	ParallelWalk                  bool
+	LogLevel                      aws.LogLevelType
+	ObjectOwnership               bool
+	Preflight                     bool
+	Accelerate                    bool
 }
 
 func init() {
@@ -182,19 +208,31 @@ func (factory *s3DriverFactory) Create(parameters map[string]interface{}) (stora
 }
 
 type driver struct {
-	S3                          *s3wrapper
-	Bucket                      string
-	ChunkSize                   int64
-	Encrypt                     bool
-	KeyID                       string
-	MultipartCopyChunkSize      int64
-	MultipartCopyMaxConcurrency int64
-	MultipartCopyThresholdSize  int64
-	RootDirectory               string
-	StorageClass                string
-	ObjectACL                   string
-	ObjectOwnership             bool
-	ParallelWalk                bool
+	S3                            *s3wrapper
+	Bucket                        string
+	ChunkSize                     int64
+	Encrypt                       bool
+	KeyID                         string
+	MultipartCopyChunkSize        int64
+	MultipartCopyMaxConcurrency   int64
+	MultipartCopyThresholdSize    int64
+	MultipartUploadMaxConcurrency int64
+	AdaptiveChunkSize             bool
+	RootDirectory                 string
+	StorageClass                  string
+	ObjectACL                     string
+	ObjectOwnership               bool
+	ParallelWalk                  bool
+
+	// presigner presigns the requests for direct uploads, using S3 Transfer
+	// Acceleration endpoints if enabled. Presigning doesn't make network
+	// calls, so it's not subject to rate limiting.
+	presigner *s3.S3
+
+	// uploadBuffers bounds the memory held by in flight part uploads across
+	// all writers. Nil if unbounded.
+	uploadBuffers     *semaphore.Weighted
+	uploadBuffersSize int64
 }
 
 type baseEmbed struct {
@@ -345,6 +383,23 @@ func parseParameters(parameters map[string]interface{}) (*DriverParameters, erro
 		result = multierror.Append(result, err)
 	}
 
+	multipartUploadMaxConcurrency, err := getParameterAsInt64(parameters, "multipartuploadmaxconcurrency", defaultMultipartUploadMaxConcurrency, 1, math.MaxInt64)
+	if err != nil {
+		err := fmt.Errorf("converting multipartuploadmaxconcurrency to valid int64: %w", err)
+		result = multierror.Append(result, err)
+	}
+
+	multipartUploadMaxBufferSize, err := getParameterAsInt64(parameters, "multipartuploadmaxbuffersize", 0, 0, math.MaxInt64)
+	if err != nil {
+		err := fmt.Errorf("converting multipartuploadmaxbuffersize to valid int64: %w", err)
+		result = multierror.Append(result, err)
+	}
+
+	adaptiveChunkSizeBool, err := parse.Bool(parameters, "adaptivechunksize", false)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	rootDirectory := parameters["rootdirectory"]
 	if rootDirectory == nil {
 		rootDirectory = ""
@@ -421,6 +476,21 @@ func parseParameters(parameters map[string]interface{}) (*DriverParameters, erro
 		result = multierror.Append(result, err)
 	}
 
+	preflightBool, err := parse.Bool(parameters, "preflight", false)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	accelerateBool, err := parse.Bool(parameters, "accelerate", false)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	// S3 Transfer Acceleration is only available on AWS, with virtual hosted-style requests
+	if accelerateBool && (regionEndpoint != "" || pathStyleBool) {
+		err := errors.New("accelerate parameter can not be used along with the regionendpoint or pathstyle parameters")
+		result = multierror.Append(result, err)
+	}
+
 	maxRequestsPerSecond, err := getParameterAsInt64(parameters, "maxrequestspersecond", defaultMaxRequestsPerSecond, 0, math.MaxInt64)
 	if err != nil {
 		err = fmt.Errorf("converting maxrequestspersecond to valid int64: %w", err)
@@ -429,7 +499,7 @@ func parseParameters(parameters map[string]interface{}) (*DriverParameters, erro
 
 	maxRetries, err := getParameterAsInt64(parameters, "maxretries", defaultMaxRetries, 0, math.MaxInt64)
 	if err != nil {
-		err := fmt.Errorf("converting maxrequestspersecond to valid int64: %w", err)
+		err := fmt.Errorf("converting maxretries to valid int64: %w", err)
 		result = multierror.Append(result, err)
 	}
 
@@ -457,6 +527,9 @@ func parseParameters(parameters map[string]interface{}) (*DriverParameters, erro
 		multipartCopyChunkSize,
 		multipartCopyMaxConcurrency,
 		multipartCopyThresholdSize,
+		multipartUploadMaxConcurrency,
+		multipartUploadMaxBufferSize,
+		adaptiveChunkSizeBool,
 		fmt.Sprint(rootDirectory),
 		storageClass,
 		objectACL,
@@ -467,6 +540,8 @@ func parseParameters(parameters map[string]interface{}) (*DriverParameters, erro
 		parallelWalkBool,
 		logLevel,
 		objectOwnership,
+		preflightBool,
+		accelerateBool,
 	}, nil
 }
 
@@ -555,6 +630,11 @@ func New(params *DriverParameters) (*Driver, error) {
 		setv2Handlers(s3obj)
 	}
 
+	presigner := s3obj
+	if params.Accelerate {
+		presigner = s3.New(sess, aws.NewConfig().WithS3UseAccelerate(true))
+	}
+
 	// TODO Currently multipart uploads have no timestamps, so this would be unwise
 	// if you initiated a new s3driver while another one is running on the same bucket.
 	// multis, _, err := bucket.ListMulti("", "")
@@ -580,19 +660,32 @@ func New(params *DriverParameters) (*Driver, error) {
 	)
 
 	d := &driver{
-		S3:                          w,
-		Bucket:                      params.Bucket,
-		ChunkSize:                   params.ChunkSize,
-		Encrypt:                     params.Encrypt,
-		KeyID:                       params.KeyID,
-		MultipartCopyChunkSize:      params.MultipartCopyChunkSize,
-		MultipartCopyMaxConcurrency: params.MultipartCopyMaxConcurrency,
-		MultipartCopyThresholdSize:  params.MultipartCopyThresholdSize,
-		RootDirectory:               params.RootDirectory,
-		StorageClass:                params.StorageClass,
-		ObjectACL:                   params.ObjectACL,
-		ParallelWalk:                params.ParallelWalk,
-		ObjectOwnership:             params.ObjectOwnership,
+		S3:                            w,
+		Bucket:                        params.Bucket,
+		ChunkSize:                     params.ChunkSize,
+		Encrypt:                       params.Encrypt,
+		KeyID:                         params.KeyID,
+		MultipartCopyChunkSize:        params.MultipartCopyChunkSize,
+		MultipartCopyMaxConcurrency:   params.MultipartCopyMaxConcurrency,
+		MultipartCopyThresholdSize:    params.MultipartCopyThresholdSize,
+		MultipartUploadMaxConcurrency: params.MultipartUploadMaxConcurrency,
+		AdaptiveChunkSize:             params.AdaptiveChunkSize,
+		RootDirectory:                 params.RootDirectory,
+		StorageClass:                  params.StorageClass,
+		ObjectACL:                     params.ObjectACL,
+		ParallelWalk:                  params.ParallelWalk,
+		ObjectOwnership:               params.ObjectOwnership,
+		presigner:                     presigner,
+	}
+	if params.MultipartUploadMaxBufferSize > 0 {
+		d.uploadBuffers = semaphore.NewWeighted(params.MultipartUploadMaxBufferSize)
+		d.uploadBuffersSize = params.MultipartUploadMaxBufferSize
+	}
+
+	if params.Preflight {
+		if err := d.preflight(context.Background()); err != nil {
+			return nil, err
+		}
 	}
 
 	return &Driver{
@@ -679,7 +772,7 @@ func (d *driver) Writer(ctx context.Context, path string, appendParam bool) (sto
 		if err != nil {
 			return nil, err
 		}
-		return d.newWriter(key, *resp.UploadId, nil), nil
+		return d.newWriter(ctx, key, *resp.UploadId, nil), nil
 	}
 
 	resp, err := d.S3.ListMultipartUploadsWithContext(
@@ -728,7 +821,7 @@ func (d *driver) Writer(ctx context.Context, path string, appendParam bool) (sto
 			allParts = append(allParts, listResp.Parts...)
 			respLoopCount++
 		}
-		return d.newWriter(key, *multi.UploadId, allParts), nil
+		return d.newWriter(ctx, key, *multi.UploadId, allParts), nil
 	}
 	return nil, storagedriver.PathNotFoundError{Path: path}
 }
@@ -1131,6 +1224,51 @@ func (d *driver) URLFor(ctx context.Context, path string, options map[string]int
 	return req.Presign(expiresIn)
 }
 
+// DirectUploadURLs returns n presigned URLs, valid for the given expiry, to
+// upload parts 1 to n of the multipart upload in progress at the given path.
+// If enabled, the URLs use S3 Transfer Acceleration endpoints. As required by
+// S3, all parts but the last must be at least 5MB in size.
+func (d *driver) DirectUploadURLs(ctx context.Context, path string, n int, expiry time.Duration) ([]string, error) {
+	if n < 1 || n > maxPartNumber {
+		return nil, fmt.Errorf("number of parts must be between 1 and %d, got %d", maxPartNumber, n)
+	}
+
+	key := d.s3Path(path)
+	resp, err := d.S3.ListMultipartUploadsWithContext(
+		ctx,
+		&s3.ListMultipartUploadsInput{
+			Bucket: aws.String(d.Bucket),
+			Prefix: aws.String(key),
+		})
+	if err != nil {
+		return nil, parseError(path, err)
+	}
+
+	for _, multi := range resp.Uploads {
+		if key != *multi.Key {
+			continue
+		}
+
+		urls := make([]string, 0, n)
+		for i := 1; i <= n; i++ {
+			req, _ := d.presigner.UploadPartRequest(&s3.UploadPartInput{
+				Bucket:     aws.String(d.Bucket),
+				Key:        aws.String(key),
+				UploadId:   multi.UploadId,
+				PartNumber: aws.Int64(int64(i)),
+			})
+			u, err := req.Presign(expiry)
+			if err != nil {
+				return nil, fmt.Errorf("presigning upload of part %d: %w", i, err)
+			}
+			urls = append(urls, u)
+		}
+		return urls, nil
+	}
+
+	return nil, storagedriver.PathNotFoundError{Path: path}
+}
+
 // Walk traverses a filesystem defined within driver, starting
 // from the given path, calling f on each file
 func (d *driver) Walk(ctx context.Context, from string, f storagedriver.WalkFn) error {
@@ -1467,6 +1605,11 @@ func (d *Driver) S3BucketKey(path string) string {
 	return d.StorageDriver.(*driver).s3Path(path)
 }
 
+// DirectUploadURLs implements storagedriver.DirectUploader.
+func (d *Driver) DirectUploadURLs(ctx context.Context, path string, n int, expiry time.Duration) ([]string, error) {
+	return d.StorageDriver.(*driver).DirectUploadURLs(ctx, path, n, expiry)
+}
+
 func parseError(path string, err error) error {
 	if s3Err, ok := err.(awserr.Error); ok && s3Err.Code() == "NoSuchKey" {
 		return storagedriver.PathNotFoundError{Path: path}
@@ -1510,35 +1653,79 @@ func (d *driver) getStorageClass() *string {
 	return aws.String(d.StorageClass)
 }
 
+// chunkSize returns the size of the parts in which a write is split. With
+// adaptive chunk sizing enabled, it grows with the size hint found in ctx, if
+// any, so that large writes are uploaded in fewer and larger parts.
+func (d *driver) chunkSize(ctx context.Context) int64 {
+	hint, ok := storagedriver.SizeHint(ctx)
+	if !d.AdaptiveChunkSize || !ok {
+		return d.ChunkSize
+	}
+
+	// round up to a multiple of 1MiB
+	size := (hint + adaptiveChunkSizeTargetParts - 1) / adaptiveChunkSizeTargetParts
+	size = (size + 1<<20 - 1) &^ (1<<20 - 1)
+	if size > maxAdaptiveChunkSize {
+		size = maxAdaptiveChunkSize
+	}
+	if size < d.ChunkSize {
+		size = d.ChunkSize
+	}
+	return size
+}
+
 // writer attempts to upload parts to S3 in a buffered fashion where the last
 // part is at least as large as the chunksize, so the multipart upload could be
 // cleanly resumed in the future. This is violated if Close is called after less
 // than a full chunk is written.
+//
+// If the driver allows concurrent part uploads, full parts are uploaded in the
+// background while the following ones are buffered. Close, Commit and Cancel
+// wait for all of them to finish.
 type writer struct {
-	driver      *driver
-	key         string
-	uploadID    string
-	parts       []*s3.Part
-	size        int64
-	readyPart   []byte
-	pendingPart []byte
-	closed      bool
-	committed   bool
-	canceled    bool
+	driver         *driver
+	key            string
+	uploadID       string
+	chunkSize      int64
+	parts          []*s3.Part
+	nextPartNumber int64
+	size           int64
+	readyPart      []byte
+	pendingPart    []byte
+	closed         bool
+	committed      bool
+	canceled       bool
+
+	// slots bounds the number of concurrent part uploads. Nil if parts are
+	// uploaded sequentially.
+	slots   chan struct{}
+	uploads sync.WaitGroup
+
+	// mu guards the results of background part uploads, which are merged
+	// into parts once all of them finish.
+	mu        sync.Mutex
+	uploaded  []*s3.Part
+	uploadErr error
 }
 
-func (d *driver) newWriter(key, uploadID string, parts []*s3.Part) storagedriver.FileWriter {
+func (d *driver) newWriter(ctx context.Context, key, uploadID string, parts []*s3.Part) storagedriver.FileWriter {
 	var size int64
 	for _, part := range parts {
 		size += *part.Size
 	}
-	return &writer{
-		driver:   d,
-		key:      key,
-		uploadID: uploadID,
-		parts:    parts,
-		size:     size,
+	w := &writer{
+		driver:         d,
+		key:            key,
+		uploadID:       uploadID,
+		chunkSize:      d.chunkSize(ctx),
+		parts:          parts,
+		nextPartNumber: int64(len(parts) + 1),
+		size:           size,
+	}
+	if d.MultipartUploadMaxConcurrency > 1 {
+		w.slots = make(chan struct{}, d.MultipartUploadMaxConcurrency)
 	}
+	return w
 }
 
 type completedParts []*s3.CompletedPart
@@ -1647,13 +1834,14 @@ func (w *writer) Write(p []byte) (int, error) {
 				},
 			}
 		}
+		w.nextPartNumber = int64(len(w.parts) + 1)
 	}
 
 	var n int
 
 	for len(p) > 0 {
 		// If no parts are ready to write, fill up the first part
-		if neededBytes := int(w.driver.ChunkSize) - len(w.readyPart); neededBytes > 0 {
+		if neededBytes := int(w.chunkSize) - len(w.readyPart); neededBytes > 0 {
 			if len(p) >= neededBytes {
 				w.readyPart = append(w.readyPart, p[:neededBytes]...)
 				n += neededBytes
@@ -1665,7 +1853,7 @@ func (w *writer) Write(p []byte) (int, error) {
 			}
 		}
 
-		if neededBytes := int(w.driver.ChunkSize) - len(w.pendingPart); neededBytes > 0 {
+		if neededBytes := int(w.chunkSize) - len(w.pendingPart); neededBytes > 0 {
 			if len(p) >= neededBytes {
 				w.pendingPart = append(w.pendingPart, p[:neededBytes]...)
 				n += neededBytes
@@ -1695,7 +1883,12 @@ func (w *writer) Close() error {
 		return fmt.Errorf("already closed")
 	}
 	w.closed = true
-	return w.flushPart()
+
+	err := w.flushPart()
+	if waitErr := w.wait(); err == nil {
+		err = waitErr
+	}
+	return err
 }
 
 func (w *writer) Cancel() error {
@@ -1705,6 +1898,10 @@ func (w *writer) Cancel() error {
 		return fmt.Errorf("already committed")
 	}
 	w.canceled = true
+
+	// let in flight part uploads finish, so that they do not outlive the upload
+	_ = w.wait()
+
 	_, err := w.driver.S3.AbortMultipartUploadWithContext(
 		context.Background(),
 		&s3.AbortMultipartUploadInput{
@@ -1726,6 +1923,9 @@ func (w *writer) Commit() error {
 		return fmt.Errorf("already canceled")
 	}
 	err := w.flushPart()
+	if waitErr := w.wait(); err == nil {
+		err = waitErr
+	}
 	if err != nil {
 		return err
 	}
@@ -1771,32 +1971,114 @@ func (w *writer) flushPart() error {
 		// nothing to write
 		return nil
 	}
-	if len(w.pendingPart) < int(w.driver.ChunkSize) {
+	if len(w.pendingPart) < int(w.chunkSize) {
 		// closing with a small pending part
 		// combine ready and pending to avoid writing a small part
 		w.readyPart = append(w.readyPart, w.pendingPart...)
 		w.pendingPart = nil
 	}
 
-	partNumber := aws.Int64(int64(len(w.parts) + 1))
+	if w.slots != nil {
+		if err := w.uploadPartAsync(w.nextPartNumber, w.readyPart); err != nil {
+			return err
+		}
+	} else {
+		part, err := w.uploadPart(w.nextPartNumber, w.readyPart)
+		if err != nil {
+			return err
+		}
+		w.parts = append(w.parts, part)
+	}
+	w.nextPartNumber++
+	w.readyPart = w.pendingPart
+	w.pendingPart = nil
+	return nil
+}
+
+// uploadPart uploads buf as the part partNumber of the multipart upload.
+func (w *writer) uploadPart(partNumber int64, buf []byte) (*s3.Part, error) {
+	done := metrics.PartUpload(driverName)
+
 	resp, err := w.driver.S3.UploadPartWithContext(
 		context.Background(),
 		&s3.UploadPartInput{
 			Bucket:     aws.String(w.driver.Bucket),
 			Key:        aws.String(w.key),
-			PartNumber: partNumber,
+			PartNumber: aws.Int64(partNumber),
 			UploadId:   aws.String(w.uploadID),
-			Body:       bytes.NewReader(w.readyPart),
+			Body:       bytes.NewReader(buf),
 		})
 	if err != nil {
-		return err
+		done(0)
+		return nil, err
 	}
-	w.parts = append(w.parts, &s3.Part{
+	done(int64(len(buf)))
+
+	return &s3.Part{
 		ETag:       resp.ETag,
-		PartNumber: partNumber,
-		Size:       aws.Int64(int64(len(w.readyPart))),
-	})
-	w.readyPart = w.pendingPart
-	w.pendingPart = nil
+		PartNumber: aws.Int64(partNumber),
+		Size:       aws.Int64(int64(len(buf))),
+	}, nil
+}
+
+// uploadPartAsync uploads buf as the part partNumber of the multipart upload in
+// the background, blocking until an upload slot of the writer and enough of the
+// driver buffer budget are available. It returns the error of any previous
+// background upload, in which case buf is not uploaded.
+func (w *writer) uploadPartAsync(partNumber int64, buf []byte) error {
+	w.mu.Lock()
+	err := w.uploadErr
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	w.slots <- struct{}{}
+
+	// parts larger than the whole budget would never fit, so they take all of it
+	weight := int64(len(buf))
+	if w.driver.uploadBuffers != nil {
+		if weight > w.driver.uploadBuffersSize {
+			weight = w.driver.uploadBuffersSize
+		}
+		// acquiring with a non-cancelable context never fails
+		_ = w.driver.uploadBuffers.Acquire(context.Background(), weight)
+	}
+
+	w.uploads.Add(1)
+	go func() {
+		defer func() {
+			if w.driver.uploadBuffers != nil {
+				w.driver.uploadBuffers.Release(weight)
+			}
+			<-w.slots
+			w.uploads.Done()
+		}()
+
+		part, err := w.uploadPart(partNumber, buf)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if err != nil {
+			if w.uploadErr == nil {
+				w.uploadErr = err
+			}
+			return
+		}
+		w.uploaded = append(w.uploaded, part)
+	}()
+
 	return nil
 }
+
+// wait waits for all background part uploads to finish, adds them to the
+// uploaded parts and returns the error of the first one that failed, if any.
+func (w *writer) wait() error {
+	w.uploads.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.parts = append(w.parts, w.uploaded...)
+	w.uploaded = nil
+	return w.uploadErr
+}
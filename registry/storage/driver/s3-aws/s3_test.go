@@ -3,12 +3,14 @@ package s3
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
 	"regexp"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,6 +23,7 @@ import (
 	"github.com/docker/distribution/registry/internal/testutil"
 	"github.com/hashicorp/go-multierror"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
 
 	"gopkg.in/check.v1"
 
@@ -151,6 +154,9 @@ func init() {
 			defaultMultipartCopyChunkSize,
 			defaultMultipartCopyMaxConcurrency,
 			defaultMultipartCopyThresholdSize,
+			defaultMultipartUploadMaxConcurrency,
+			0,
+			false,
 			rootDirectory,
 			storageClass,
 			objectACL,
@@ -161,6 +167,8 @@ func init() {
 			parallelWalkBool,
 			logLevelType,
 			objectOwnershipBool,
+			false,
+			false,
 		}
 
 		return New(parameters)
@@ -248,6 +256,18 @@ func Test_parseParameters(t *testing.T) {
 			driverParamName: "ParallelWalk",
 			defaultt:        false,
 		},
+		"preflight": {
+			parameters:      p,
+			paramName:       "preflight",
+			driverParamName: "Preflight",
+			defaultt:        false,
+		},
+		"accelerate": {
+			parameters:      p,
+			paramName:       "accelerate",
+			driverParamName: "Accelerate",
+			defaultt:        false,
+		},
 		"accesskey": {
 			parameters:      p,
 			paramName:       "accesskey",
@@ -789,18 +809,48 @@ func TestDeleteFilesPartialError(t *testing.T) {
 	}
 }
 
-type mockPutObjectWithContextRetryableError struct {
+// mockRetryableError fails the calls of file writers and others with errors which may be retried, counting the
+// calls which are not issued by file writers.
+type mockRetryableError struct {
 	s3iface.S3API
+	calls int
 }
 
-func (m *mockPutObjectWithContextRetryableError) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+func (m *mockRetryableError) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	m.calls++
 	return nil, awserr.New(request.ErrCodeRequestError, "expected test failure", nil)
 }
 
-func (m *mockPutObjectWithContextRetryableError) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+func (m *mockRetryableError) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	m.calls++
 	return awserr.NewRequestFailure(nil, http.StatusInternalServerError, "expected test failure")
 }
 
+func (m *mockRetryableError) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-id")}, nil
+}
+
+func (m *mockRetryableError) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	return nil, awserr.NewRequestFailure(nil, http.StatusServiceUnavailable, "expected test failure")
+}
+
+func (m *mockRetryableError) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// writeAndCommit writes content to path with a new file writer of d and commits it.
+func writeAndCommit(t *testing.T, d *Driver, path string, content []byte) error {
+	t.Helper()
+
+	w, err := d.Writer(context.Background(), path, false)
+	require.NoError(t, err)
+
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
 func TestBackoffDisabledByDefault(t *testing.T) {
 	if skipS3() != "" {
 		t.Skip(skipS3())
@@ -816,11 +866,11 @@ func TestBackoffDisabledByDefault(t *testing.T) {
 
 	// mock the underlying S3 client
 	d.baseEmbed.Base.StorageDriver.(*driver).S3 = newS3Wrapper(
-		&mockPutObjectWithContextRetryableError{},
+		&mockRetryableError{},
 		withBackoffNotify(notifyFn),
 	)
 
-	err := d.PutContent(context.Background(), "/test/file", []byte{})
+	err := writeAndCommit(t, d, "/test/file", []byte("content"))
 	require.Error(t, err)
 	require.Zero(t, retries)
 }
@@ -840,12 +890,12 @@ func TestBackoffDisabledBySettingZeroRetries(t *testing.T) {
 
 	// mock the underlying S3 client
 	d.baseEmbed.Base.StorageDriver.(*driver).S3 = newS3Wrapper(
-		&mockPutObjectWithContextRetryableError{},
+		&mockRetryableError{},
 		withExponentialBackoff(0),
 		withBackoffNotify(notifyFn),
 	)
 
-	err := d.PutContent(context.Background(), "/test/file", []byte{})
+	err := writeAndCommit(t, d, "/test/file", []byte("content"))
 	require.Error(t, err)
 	require.Zero(t, retries)
 }
@@ -865,32 +915,56 @@ func TestBackoffRetriesRetryableErrors(t *testing.T) {
 
 	// mock the underlying S3 client
 	d.baseEmbed.Base.StorageDriver.(*driver).S3 = newS3Wrapper(
-		&mockPutObjectWithContextRetryableError{},
+		&mockRetryableError{},
 		withBackoffNotify(notifyFn),
 		withExponentialBackoff(defaultMaxRetries),
 	)
 
 	start := time.Now()
-	err := d.PutContent(context.Background(), "/test/file", []byte{})
+	err := writeAndCommit(t, d, "/test/file", []byte("content"))
 	require.Error(t, err)
 	require.Equal(t, defaultMaxRetries, retries)
 	require.WithinDuration(t, time.Now(), start, time.Second*10)
+}
+
+// TestBackoffOnlyRetriesFileWriters ensures that the calls which are not issued by file writers are not retried by
+// the driver, as these are retried by the retry storage middleware.
+func TestBackoffOnlyRetriesFileWriters(t *testing.T) {
+	if skipS3() != "" {
+		t.Skip(skipS3())
+	}
+
+	d := newTempDirDriver(t)
+
+	var retries int
+
+	notifyFn := func(err error, t time.Duration) {
+		retries++
+	}
+
+	// mock the underlying S3 client
+	mock := &mockRetryableError{}
+	d.baseEmbed.Base.StorageDriver.(*driver).S3 = newS3Wrapper(
+		mock,
+		withBackoffNotify(notifyFn),
+		withExponentialBackoff(defaultMaxRetries),
+	)
+
+	err := d.PutContent(context.Background(), "/test/file", []byte{})
+	require.Error(t, err)
+	require.Equal(t, 1, mock.calls)
 
-	start = time.Now()
 	err = d.Walk(context.Background(), "test/", func(storagedriver.FileInfo) error { return nil })
 	require.Error(t, err)
-	require.Equal(t, defaultMaxRetries, retries)
-	require.WithinDuration(t, time.Now(), start, time.Second*10)
+	require.Equal(t, 2, mock.calls)
+	require.Zero(t, retries)
 }
 
-type mockPutObjectWithContextPermanentError struct {
-	s3iface.S3API
+type mockUploadPartPermanentError struct {
+	mockRetryableError
 }
 
-func (m *mockPutObjectWithContextPermanentError) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
-	return nil, awserr.New(request.ErrCodeInvalidPresignExpire, "expected test failure", nil)
-}
-func (m *mockPutObjectWithContextPermanentError) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+func (m *mockUploadPartPermanentError) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
 	return nil, awserr.NewRequestFailure(nil, http.StatusForbidden, "expected test failure")
 }
 
@@ -909,18 +983,27 @@ func TestBackoffDoesNotRetryPermanentErrors(t *testing.T) {
 
 	// mock the underlying S3 client
 	d.baseEmbed.Base.StorageDriver.(*driver).S3 = newS3Wrapper(
-		&mockPutObjectWithContextPermanentError{},
+		&mockUploadPartPermanentError{},
 		withBackoffNotify(notifyFn),
 		withExponentialBackoff(200),
 	)
 
-	err := d.PutContent(context.Background(), "/test/file", []byte{})
+	err := writeAndCommit(t, d, "/test/file", []byte("content"))
 	require.Error(t, err)
 	require.Zero(t, retries)
+}
 
-	_, err = d.List(context.Background(), "/test/")
-	require.Error(t, err)
-	require.Zero(t, retries)
+type mockCompleteMultipartUploadError struct {
+	mockRetryableError
+}
+
+func (m *mockCompleteMultipartUploadError) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+}
+
+// CompleteMultipartUploadWithContext mocks a presign expire error while completing a multipart upload.
+func (m *mockCompleteMultipartUploadError) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	return nil, awserr.New(request.ErrCodeInvalidPresignExpire, "expected test failure", nil)
 }
 
 func TestBackoffDoesNotRetryNonRequestErrors(t *testing.T) {
@@ -938,12 +1021,12 @@ func TestBackoffDoesNotRetryNonRequestErrors(t *testing.T) {
 
 	// mock the underlying S3 client
 	d.baseEmbed.Base.StorageDriver.(*driver).S3 = newS3Wrapper(
-		&mockDeleteObjectsError{},
+		&mockCompleteMultipartUploadError{},
 		withBackoffNotify(notifyFn),
 		withExponentialBackoff(200),
 	)
 
-	_, err := d.DeleteFiles(context.Background(), []string{"/test/file1", "/test/file2"})
+	err := writeAndCommit(t, d, "/test/file", []byte("content"))
 	require.Error(t, err)
 	require.Zero(t, retries)
 }
@@ -997,3 +1080,250 @@ func TestExistsPathNotFound(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, exists)
 }
+
+func TestParseParameters_MultipartUpload(t *testing.T) {
+	p := map[string]interface{}{
+		"region":                        "us-west-2",
+		"bucket":                        "test",
+		"multipartuploadmaxconcurrency": "8",
+		"multipartuploadmaxbuffersize":  fmt.Sprint(256 << 20),
+		"adaptivechunksize":             "true",
+	}
+
+	params, err := parseParameters(p)
+	require.NoError(t, err)
+	require.Equal(t, int64(8), params.MultipartUploadMaxConcurrency)
+	require.Equal(t, int64(256<<20), params.MultipartUploadMaxBufferSize)
+	require.True(t, params.AdaptiveChunkSize)
+
+	params, err = parseParameters(map[string]interface{}{"region": "us-west-2", "bucket": "test"})
+	require.NoError(t, err)
+	require.Equal(t, int64(defaultMultipartUploadMaxConcurrency), params.MultipartUploadMaxConcurrency)
+	require.Zero(t, params.MultipartUploadMaxBufferSize)
+	require.False(t, params.AdaptiveChunkSize)
+
+	p["multipartuploadmaxconcurrency"] = "0"
+	p["multipartuploadmaxbuffersize"] = "-1"
+	_, err = parseParameters(p)
+	require.ErrorContains(t, err, "multipartuploadmaxconcurrency")
+	require.ErrorContains(t, err, "multipartuploadmaxbuffersize")
+}
+
+func TestParseParameters_Accelerate(t *testing.T) {
+	p := map[string]interface{}{
+		"region":     "us-west-2",
+		"bucket":     "test",
+		"accelerate": "true",
+	}
+
+	params, err := parseParameters(p)
+	require.NoError(t, err)
+	require.True(t, params.Accelerate)
+
+	p["pathstyle"] = "true"
+	_, err = parseParameters(p)
+	require.ErrorContains(t, err, "accelerate")
+
+	delete(p, "pathstyle")
+	p["regionendpoint"] = "http://minio:9000"
+	_, err = parseParameters(p)
+	require.ErrorContains(t, err, "accelerate")
+}
+
+func TestDirectUploadURLs(t *testing.T) {
+	if skipS3() != "" {
+		t.Skip(skipS3())
+	}
+
+	ctx := context.Background()
+	d := newTempDirDriver(t)
+
+	fp := "/foo"
+	_, err := d.DirectUploadURLs(ctx, fp, 1, time.Hour)
+	require.ErrorAs(t, err, &storagedriver.PathNotFoundError{})
+
+	w, err := d.Writer(ctx, fp, false)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = d.DirectUploadURLs(ctx, fp, maxPartNumber+1, time.Hour)
+	require.Error(t, err)
+
+	urls, err := d.DirectUploadURLs(ctx, fp, 2, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+
+	// upload the content directly to S3, bypassing the driver
+	content := []byte("foo")
+	req, err := http.NewRequest(http.MethodPut, urls[0], bytes.NewReader(content))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	w, err = d.Writer(ctx, fp, true)
+	require.NoError(t, err)
+	require.EqualValues(t, len(content), w.Size())
+	require.NoError(t, w.Commit())
+
+	got, err := d.GetContent(ctx, fp)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+}
+
+func TestDriver_chunkSize(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		adaptive bool
+		hint     int64
+		expected int64
+	}{
+		{name: "disabled", hint: 1 << 30, expected: minChunkSize},
+		{name: "no hint", adaptive: true, expected: minChunkSize},
+		{name: "small hint", adaptive: true, hint: 100 << 20, expected: minChunkSize},
+		{name: "large hint", adaptive: true, hint: 1 << 30, expected: 11 << 20},
+		{name: "huge hint", adaptive: true, hint: 1 << 40, expected: maxAdaptiveChunkSize},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &driver{ChunkSize: minChunkSize, AdaptiveChunkSize: test.adaptive}
+			ctx := ctx
+			if test.hint > 0 {
+				ctx = storagedriver.WithSizeHint(ctx, test.hint)
+			}
+			require.Equal(t, test.expected, d.chunkSize(ctx))
+		})
+	}
+}
+
+// mockMultipartUpload records the parts of a multipart upload, optionally failing the upload of a given part.
+type mockMultipartUpload struct {
+	s3iface.S3API
+
+	mu          sync.Mutex
+	parts       map[int64]int
+	inFlight    int
+	maxInFlight int
+	failPart    int64
+	completed   []*s3.CompletedPart
+}
+
+func (m *mockMultipartUpload) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	m.mu.Lock()
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+	m.mu.Unlock()
+
+	// give other uploads a chance to overlap with this one
+	time.Sleep(10 * time.Millisecond)
+	b, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	if *input.PartNumber == m.failPart {
+		return nil, awserr.New("InternalError", "expected test failure", nil)
+	}
+	m.parts[*input.PartNumber] = len(b)
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprint(*input.PartNumber))}, nil
+}
+
+func (m *mockMultipartUpload) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	m.completed = input.MultipartUpload.Parts
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockMultipartUpload) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func newMultipartUploadDriver(mock *mockMultipartUpload, concurrency, bufferSize int64) *driver {
+	d := &driver{
+		S3:                            newS3Wrapper(mock),
+		Bucket:                        "test",
+		ChunkSize:                     minChunkSize,
+		MultipartUploadMaxConcurrency: concurrency,
+	}
+	if bufferSize > 0 {
+		d.uploadBuffers = semaphore.NewWeighted(bufferSize)
+		d.uploadBuffersSize = bufferSize
+	}
+	return d
+}
+
+func TestWriter_Commit_ConcurrentParts(t *testing.T) {
+	tests := []struct {
+		name                string
+		concurrency         int64
+		bufferSize          int64
+		expectedMaxInFlight int
+	}{
+		{name: "sequential", concurrency: 1, expectedMaxInFlight: 1},
+		{name: "concurrent", concurrency: 3, expectedMaxInFlight: 3},
+		{name: "memory bounded", concurrency: 3, bufferSize: 2 * minChunkSize, expectedMaxInFlight: 2},
+		{name: "memory bounded below chunk size", concurrency: 3, bufferSize: 1, expectedMaxInFlight: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := &mockMultipartUpload{parts: make(map[int64]int)}
+			d := newMultipartUploadDriver(mock, test.concurrency, test.bufferSize)
+			w := d.newWriter(context.Background(), "foo", "bar", nil)
+
+			// 7.5 chunks, written in odd-sized writes
+			content := make([]byte, 15*minChunkSize/2)
+			rand.Read(content)
+			for p := content; len(p) > 0; {
+				n := 3 << 20
+				if n > len(p) {
+					n = len(p)
+				}
+				written, err := w.Write(p[:n])
+				require.NoError(t, err)
+				require.Equal(t, n, written)
+				p = p[n:]
+			}
+			require.Equal(t, int64(len(content)), w.Size())
+			require.NoError(t, w.Commit())
+
+			// the trailing half chunk is merged into the last part
+			require.Len(t, mock.parts, 7)
+			var total int
+			for i := int64(1); i <= 6; i++ {
+				require.Equal(t, minChunkSize, mock.parts[i])
+				total += mock.parts[i]
+			}
+			require.Equal(t, 3*minChunkSize/2, mock.parts[7])
+			require.Equal(t, len(content), total+mock.parts[7])
+
+			require.Len(t, mock.completed, 7)
+			for i, p := range mock.completed {
+				require.Equal(t, int64(i+1), *p.PartNumber)
+				require.Equal(t, fmt.Sprint(i+1), *p.ETag)
+			}
+			require.Equal(t, test.expectedMaxInFlight, mock.maxInFlight)
+		})
+	}
+}
+
+func TestWriter_Commit_ConcurrentPartError(t *testing.T) {
+	mock := &mockMultipartUpload{parts: make(map[int64]int), failPart: 2}
+	d := newMultipartUploadDriver(mock, 2, 0)
+	w := d.newWriter(context.Background(), "foo", "bar", nil)
+
+	content := make([]byte, 4*minChunkSize)
+	_, err := w.Write(content)
+	require.NoError(t, err)
+
+	require.ErrorContains(t, w.Commit(), "expected test failure")
+	require.Nil(t, mock.completed)
+}
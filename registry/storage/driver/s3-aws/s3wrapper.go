@@ -3,6 +3,7 @@ package s3
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -53,6 +54,8 @@ func withExponentialBackoff(max int64) wrapperOpt {
 
 // s3wrapper implements a subset of s3iface.S3API allowing us to rate limit,
 // retry, add trace logging, or otherwise improve s3 calls made by the driver.
+// Only the calls made by file writers once opened are retried, as these are
+// out of reach of the retry storage middleware, which retries the others.
 type s3wrapper struct {
 	s3 s3iface.S3API
 	*rate.Limiter
@@ -91,7 +94,7 @@ func newS3Wrapper(s3 s3iface.S3API, opts ...wrapperOpt) *s3wrapper {
 func (w *s3wrapper) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
 	var out *s3.PutObjectOutput
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.PutObjectWithContext(ctx, input, opts...)
 
@@ -108,7 +111,7 @@ func (w *s3wrapper) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInp
 func (w *s3wrapper) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
 	var out *s3.GetObjectOutput
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.GetObjectWithContext(ctx, input, opts...)
 
@@ -125,7 +128,7 @@ func (w *s3wrapper) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInp
 func (w *s3wrapper) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
 	var out *s3.CreateMultipartUploadOutput
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.CreateMultipartUploadWithContext(ctx, input, opts...)
 
@@ -142,7 +145,7 @@ func (w *s3wrapper) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.
 func (w *s3wrapper) ListMultipartUploadsWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, opts ...request.Option) (*s3.ListMultipartUploadsOutput, error) {
 	var out *s3.ListMultipartUploadsOutput
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.ListMultipartUploadsWithContext(ctx, input, opts...)
 
@@ -159,7 +162,7 @@ func (w *s3wrapper) ListMultipartUploadsWithContext(ctx aws.Context, input *s3.L
 func (w *s3wrapper) ListPartsWithContext(ctx aws.Context, input *s3.ListPartsInput, opts ...request.Option) (*s3.ListPartsOutput, error) {
 	var out *s3.ListPartsOutput
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.ListPartsWithContext(ctx, input, opts...)
 		// make sure we never have a situation where `IsTruncated` is empty if we have a response
@@ -182,7 +185,7 @@ func (w *s3wrapper) ListPartsWithContext(ctx aws.Context, input *s3.ListPartsInp
 func (w *s3wrapper) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
 	var out *s3.ListObjectsV2Output
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.ListObjectsV2WithContext(ctx, input, opts...)
 
@@ -199,7 +202,7 @@ func (w *s3wrapper) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObje
 func (w *s3wrapper) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
 	var out *s3.CopyObjectOutput
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.CopyObjectWithContext(ctx, input, opts...)
 
@@ -250,7 +253,7 @@ func (w *s3wrapper) CompleteMultipartUploadWithContext(ctx aws.Context, input *s
 func (w *s3wrapper) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
 	var out *s3.DeleteObjectsOutput
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.DeleteObjectsWithContext(ctx, input, opts...)
 
@@ -275,7 +278,7 @@ func (w *s3wrapper) HeadObjectRequest(input *s3.HeadObjectInput) (*request.Reque
 }
 
 func (w *s3wrapper) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, f func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
-	return w.waitRetryNotify(ctx, func() error {
+	return w.wait(ctx, func() error {
 		return w.s3.ListObjectsV2PagesWithContext(ctx, input, f, opts...)
 	})
 }
@@ -283,7 +286,7 @@ func (w *s3wrapper) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.Lis
 func (w *s3wrapper) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
 	var out *s3.AbortMultipartUploadOutput
 
-	err := w.waitRetryNotify(ctx, func() error {
+	err := w.wait(ctx, func() error {
 		var err error
 		out, err = w.s3.AbortMultipartUploadWithContext(ctx, input, opts...)
 
@@ -301,7 +304,22 @@ func (w *s3wrapper) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.A
 func (w *s3wrapper) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
 	var out *s3.UploadPartOutput
 
+	// the part body is consumed by each attempt, so it must be rewound for retries
+	var start int64
+	if input.Body != nil {
+		var err error
+		if start, err = input.Body.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+
 	err := w.waitRetryNotify(ctx, func() error {
+		if input.Body != nil {
+			if _, err := input.Body.Seek(start, io.SeekStart); err != nil {
+				return backoff.Permanent(err)
+			}
+		}
+
 		var err error
 		out, err = w.s3.UploadPartWithContext(ctx, input, opts...)
 
@@ -316,6 +334,35 @@ func (w *s3wrapper) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartI
 	return out, err
 }
 
+func (w *s3wrapper) HeadBucketWithContext(ctx aws.Context, input *s3.HeadBucketInput, opts ...request.Option) (*s3.HeadBucketOutput, error) {
+	var out *s3.HeadBucketOutput
+
+	err := w.wait(ctx, func() error {
+		var err error
+		out, err = w.s3.HeadBucketWithContext(ctx, input, opts...)
+
+		// a nil response must be captured as an error (if no error is provided)
+		if out == nil && err == nil {
+			err = nilRespError("HeadBucketWithContext")
+		}
+
+		return err
+	})
+
+	return out, err
+}
+
+// wait waits for the rate limiter to allow a call and then issues it with f.
+func (w *s3wrapper) wait(ctx aws.Context, f func() error) error {
+	if err := w.Wait(ctx); err != nil {
+		return err
+	}
+
+	return f()
+}
+
+// waitRetryNotify is like wait, but retries failed calls according to the
+// backoff of the wrapper, notifying each retry.
 func (w *s3wrapper) waitRetryNotify(ctx aws.Context, f backoff.Operation) error {
 	err := backoff.RetryNotify(func() error {
 		if err := w.Wait(ctx); err != nil {
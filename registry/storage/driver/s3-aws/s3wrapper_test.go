@@ -1,12 +1,18 @@
 package s3
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
 	"net/http"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/cenkalti/backoff/v4"
 	"github.com/stretchr/testify/require"
 )
@@ -77,3 +83,33 @@ func TestWrapAWSerr(t *testing.T) {
 		})
 	}
 }
+
+// mockUploadPartTransientError fails the first upload of a part with a server error, after reading its body, and
+// records the bodies of all uploads.
+type mockUploadPartTransientError struct {
+	s3iface.S3API
+	bodies [][]byte
+}
+
+func (m *mockUploadPartTransientError) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	b, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.bodies = append(m.bodies, b)
+
+	if len(m.bodies) == 1 {
+		return nil, aNonPermanentAWSRequestError
+	}
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+}
+
+func TestUploadPartRetriesWithWholeBody(t *testing.T) {
+	mock := &mockUploadPartTransientError{}
+	w := newS3Wrapper(mock, withExponentialBackoff(1))
+
+	out, err := w.UploadPartWithContext(context.Background(), &s3.UploadPartInput{Body: bytes.NewReader([]byte("part"))})
+	require.NoError(t, err)
+	require.Equal(t, "etag", aws.StringValue(out.ETag))
+	require.Equal(t, [][]byte{[]byte("part"), []byte("part")}, mock.bodies)
+}
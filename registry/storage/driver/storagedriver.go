@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Version is a string representing the storage driver version, of the form
@@ -140,6 +141,32 @@ type FileWriter interface {
 	Commit() error
 }
 
+// DirectUploader is an optional interface implemented by storage drivers that let clients upload the content of a file
+// directly to the storage backend, bypassing the registry.
+type DirectUploader interface {
+	// DirectUploadURLs returns n URLs, valid for the given expiry, to which clients can upload the content of the file
+	// being written at "path", as consecutive parts, using PUT requests. The file must have been opened for writing with
+	// StorageDriver.Writer and closed before any part was written to it. The uploaded content is made available by
+	// resuming the write with StorageDriver.Writer and committing the resulting FileWriter.
+	DirectUploadURLs(ctx context.Context, path string, n int, expiry time.Duration) ([]string, error)
+}
+
+type sizeHintKey struct{}
+
+// WithSizeHint returns a copy of ctx carrying a hint of how many bytes are
+// about to be written to a FileWriter obtained with it. Drivers may use it to
+// tune how content is buffered and uploaded, but must not rely on it being
+// accurate.
+func WithSizeHint(ctx context.Context, size int64) context.Context {
+	return context.WithValue(ctx, sizeHintKey{}, size)
+}
+
+// SizeHint returns the size hint set in ctx with WithSizeHint, if any.
+func SizeHint(ctx context.Context) (int64, bool) {
+	size, ok := ctx.Value(sizeHintKey{}).(int64)
+	return size, ok && size > 0
+}
+
 // PathRegexp is the regular expression which each file path must match. A
 // file path is absolute, beginning with a slash and containing a positive
 // number of path components separated by slashes, where each component is
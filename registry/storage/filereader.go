@@ -24,6 +24,8 @@ type fileReader struct {
 	path string
 	size int64 // size is the total size, must be set.
 
+	unbuffered bool // if set, reads are not buffered internally
+
 	// mutable fields
 	rc     io.ReadCloser // remote read closer
 	brd    *bufio.Reader // internal buffered io
@@ -44,6 +46,19 @@ func newFileReader(ctx context.Context, driver storagedriver.StorageDriver, path
 	}, nil
 }
 
+// newUnbufferedFileReader initializes a file reader like newFileReader, but without internal buffering. Buffered
+// readers read ahead up to fileReaderBufferSize bytes from the storage backend, which is wasteful when serving small
+// ranges of a file, such as those requested by lazy-pulling snapshotters.
+func newUnbufferedFileReader(ctx context.Context, driver storagedriver.StorageDriver, path string, size int64) (*fileReader, error) {
+	fr, err := newFileReader(ctx, driver, path, size)
+	if err != nil {
+		return nil, err
+	}
+	fr.unbuffered = true
+
+	return fr, nil
+}
+
 func (fr *fileReader) Read(p []byte) (n int, err error) {
 	if fr.err != nil {
 		return 0, fr.err
@@ -108,6 +123,9 @@ func (fr *fileReader) reader() (io.Reader, error) {
 	}
 
 	if fr.rc != nil {
+		if fr.unbuffered {
+			return fr.rc, nil
+		}
 		return fr.brd, nil
 	}
 
@@ -128,6 +146,10 @@ func (fr *fileReader) reader() (io.Reader, error) {
 
 	fr.rc = rc
 
+	if fr.unbuffered {
+		return fr.rc, nil
+	}
+
 	if fr.brd == nil {
 		fr.brd = bufio.NewReaderSize(fr.rc, fileReaderBufferSize)
 	} else {
@@ -51,6 +51,36 @@ func TestSimpleRead(t *testing.T) {
 	}
 }
 
+func TestUnbufferedFileReader(t *testing.T) {
+	driver, err := filesystem.FromParameters(map[string]interface{}{"rootdirectory": t.TempDir()})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	path := "/patterned"
+	content := bytes.Repeat([]byte("01234567890ab"), 1024)
+	require.NoError(t, driver.PutContent(ctx, path, content))
+
+	fr, err := newUnbufferedFileReader(ctx, driver, path, int64(len(content)))
+	require.NoError(t, err)
+	defer fr.Close()
+
+	// no read-ahead buffer is allocated
+	_, err = fr.Seek(100, io.SeekStart)
+	require.NoError(t, err)
+	p := make([]byte, 10)
+	_, err = io.ReadFull(fr, p)
+	require.NoError(t, err)
+	require.Equal(t, content[100:110], p)
+	require.Nil(t, fr.brd)
+
+	// seeking to the end and reading the rest works as with a buffered reader
+	_, err = fr.Seek(-20, io.SeekEnd)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(fr)
+	require.NoError(t, err)
+	require.Equal(t, content[len(content)-20:], rest)
+}
+
 func TestFileReaderSeek(t *testing.T) {
 	// With Go 1.18, the inmemory driver fails due to issues with changes to the
 	// implementation of io.SectionReader:
@@ -11,7 +11,13 @@ import (
 var (
 	blobDownloadBytesHist, blobUploadBytesHist *prometheus.HistogramVec
 	cdnRedirectTotal                           *prometheus.CounterVec
+	geoRedirectTotal                           *prometheus.CounterVec
 	rateLimitStorageTotal                      prometheus.Counter
+	partUploadBytesTotal                       *prometheus.CounterVec
+	partUploadDurationHist                     *prometheus.HistogramVec
+	partUploadsInFlight                        *prometheus.GaugeVec
+	retryTotal                                 *prometheus.CounterVec
+	retryExhaustedTotal                        *prometheus.CounterVec
 
 	timeSince = time.Since // for test purposes only
 )
@@ -24,13 +30,32 @@ const (
 	blobUploadBytesName   = "blob_upload_bytes"
 	blobUploadBytesDesc   = "A histogram of new blob upload bytes for the storage backend."
 
-	cdnRedirectBackendLabel      = "backend"
-	cdnRedirectBypassLabel       = "bypass"
-	cdnRedirectBypassReasonLabel = "bypass_reason"
-	cdnRedirectTotalName         = "cdn_redirects_total"
-	cdnRedirectTotalDesc         = "A counter of CDN redirections for blob downloads."
-	rateLimitStorageName         = "rate_limit_total"
-	rateLimitStorageDesc         = "A counter of requests to the storage driver that hit a rate limit."
+	cdnRedirectBackendLabel        = "backend"
+	cdnRedirectBypassLabel         = "bypass"
+	cdnRedirectBypassReasonLabel   = "bypass_reason"
+	cdnRedirectTotalName           = "cdn_redirects_total"
+	cdnRedirectTotalDesc           = "A counter of CDN redirections for blob downloads."
+	geoRedirectReplicaLabel        = "replica"
+	geoRedirectFallbackReasonLabel = "fallback_reason"
+	geoRedirectTotalName           = "geo_redirects_total"
+	geoRedirectTotalDesc           = "A counter of geo-aware redirections for blob downloads."
+	rateLimitStorageName           = "rate_limit_total"
+	rateLimitStorageDesc           = "A counter of requests to the storage driver that hit a rate limit."
+
+	driverLabel              = "driver"
+	partUploadBytesTotalName = "part_upload_bytes_total"
+	partUploadBytesTotalDesc = "A counter of bytes uploaded as parts of multipart blob writes by the storage driver."
+	partUploadDurationName   = "part_upload_duration_seconds"
+	partUploadDurationDesc   = "A histogram of latencies for part uploads of multipart blob writes by the storage driver."
+	partUploadsInFlightName  = "part_uploads_in_flight"
+	partUploadsInFlightDesc  = "A gauge of part uploads of multipart blob writes in progress in the storage driver."
+
+	retryOperationClassLabel  = "operation_class"
+	retryExhaustedReasonLabel = "reason"
+	retryTotalName            = "retries_total"
+	retryTotalDesc            = "A counter of storage driver operations retried by the retry middleware."
+	retryExhaustedTotalName   = "retry_exhaustions_total"
+	retryExhaustedTotalDesc   = "A counter of failed storage driver operations that the retry middleware gave up retrying."
 )
 
 func init() {
@@ -89,6 +114,16 @@ func init() {
 		[]string{cdnRedirectBackendLabel, cdnRedirectBypassLabel, cdnRedirectBypassReasonLabel},
 	)
 
+	geoRedirectTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      geoRedirectTotalName,
+			Help:      geoRedirectTotalDesc,
+		},
+		[]string{geoRedirectReplicaLabel, geoRedirectFallbackReasonLabel},
+	)
+
 	rateLimitStorageTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Namespace: metrics.NamespacePrefix,
@@ -98,10 +133,67 @@ func init() {
 		},
 	)
 
+	partUploadBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      partUploadBytesTotalName,
+			Help:      partUploadBytesTotalDesc,
+		},
+		[]string{driverLabel},
+	)
+
+	partUploadDurationHist = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      partUploadDurationName,
+			Help:      partUploadDurationDesc,
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{driverLabel},
+	)
+
+	partUploadsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      partUploadsInFlightName,
+			Help:      partUploadsInFlightDesc,
+		},
+		[]string{driverLabel},
+	)
+
 	prometheus.MustRegister(blobDownloadBytesHist)
 	prometheus.MustRegister(blobUploadBytesHist)
+	retryTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      retryTotalName,
+			Help:      retryTotalDesc,
+		},
+		[]string{retryOperationClassLabel},
+	)
+
+	retryExhaustedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NamespacePrefix,
+			Subsystem: subsystem,
+			Name:      retryExhaustedTotalName,
+			Help:      retryExhaustedTotalDesc,
+		},
+		[]string{retryOperationClassLabel, retryExhaustedReasonLabel},
+	)
+
 	prometheus.MustRegister(cdnRedirectTotal)
+	prometheus.MustRegister(geoRedirectTotal)
 	prometheus.MustRegister(rateLimitStorageTotal)
+	prometheus.MustRegister(partUploadBytesTotal)
+	prometheus.MustRegister(partUploadDurationHist)
+	prometheus.MustRegister(partUploadsInFlight)
+	prometheus.MustRegister(retryTotal)
+	prometheus.MustRegister(retryExhaustedTotal)
 }
 
 func BlobDownload(redirect bool, size int64) {
@@ -112,6 +204,12 @@ func CDNRedirect(backend string, bypass bool, bypassReason string) {
 	cdnRedirectTotal.WithLabelValues(backend, strconv.FormatBool(bypass), bypassReason).Inc()
 }
 
+// GeoRedirect tracks a blob download redirection by the geo routing middleware. replica is the name of the replica
+// serving the client, if any, and fallbackReason why the primary storage was used instead, if it was.
+func GeoRedirect(replica string, fallbackReason string) {
+	geoRedirectTotal.WithLabelValues(replica, fallbackReason).Inc()
+}
+
 func StorageRatelimit() {
 	rateLimitStorageTotal.Inc()
 }
@@ -119,3 +217,29 @@ func StorageRatelimit() {
 func BlobUpload(size int64) {
 	blobUploadBytesHist.WithLabelValues().Observe(float64(size))
 }
+
+// PartUpload tracks a part upload of a multipart blob write by the storage driver named driver. It should be called
+// when the upload starts. The returned function must be called once the upload finishes, with the number of bytes
+// uploaded, which is zero if the upload failed.
+func PartUpload(driver string) func(size int64) {
+	start := time.Now()
+	partUploadsInFlight.WithLabelValues(driver).Inc()
+
+	return func(size int64) {
+		partUploadsInFlight.WithLabelValues(driver).Dec()
+		partUploadDurationHist.WithLabelValues(driver).Observe(timeSince(start).Seconds())
+		partUploadBytesTotal.WithLabelValues(driver).Add(float64(size))
+	}
+}
+
+// StorageRetry tracks a retry of a storage driver operation of the given class by the retry middleware.
+func StorageRetry(operationClass string) {
+	retryTotal.WithLabelValues(operationClass).Inc()
+}
+
+// StorageRetryExhausted tracks a failed storage driver operation of the given class that the retry middleware gave up
+// retrying, either because the maximum number of attempts was reached or because the retry budget was exhausted, as
+// told by reason.
+func StorageRetryExhausted(operationClass, reason string) {
+	retryExhaustedTotal.WithLabelValues(operationClass, reason).Inc()
+}
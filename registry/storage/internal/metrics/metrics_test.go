@@ -104,6 +104,60 @@ registry_storage_cdn_redirects_total{backend="storage",bypass="true",bypass_reas
 	require.NoError(t, err)
 }
 
+func TestGeoRedirect(t *testing.T) {
+	GeoRedirect("eu", "")
+	GeoRedirect("eu", "not_replicated")
+	GeoRedirect("", "")
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_storage_geo_redirects_total A counter of geo-aware redirections for blob downloads.
+# TYPE registry_storage_geo_redirects_total counter
+registry_storage_geo_redirects_total{fallback_reason="",replica=""} 1
+registry_storage_geo_redirects_total{fallback_reason="",replica="eu"} 1
+registry_storage_geo_redirects_total{fallback_reason="not_replicated",replica="eu"} 1
+`)
+	totalFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, geoRedirectTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, totalFullName)
+	require.NoError(t, err)
+}
+
+func TestStorageRetry(t *testing.T) {
+	StorageRetry("read")
+	StorageRetry("read")
+	StorageRetry("delete")
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_storage_retries_total A counter of storage driver operations retried by the retry middleware.
+# TYPE registry_storage_retries_total counter
+registry_storage_retries_total{operation_class="delete"} 1
+registry_storage_retries_total{operation_class="read"} 2
+`)
+	totalFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, retryTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, totalFullName)
+	require.NoError(t, err)
+}
+
+func TestStorageRetryExhausted(t *testing.T) {
+	StorageRetryExhausted("write", "attempts")
+	StorageRetryExhausted("write", "budget")
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_storage_retry_exhaustions_total A counter of failed storage driver operations that the retry middleware gave up retrying.
+# TYPE registry_storage_retry_exhaustions_total counter
+registry_storage_retry_exhaustions_total{operation_class="write",reason="attempts"} 1
+registry_storage_retry_exhaustions_total{operation_class="write",reason="budget"} 1
+`)
+	totalFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, retryExhaustedTotalName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, totalFullName)
+	require.NoError(t, err)
+}
+
 func TestStorageLimit(t *testing.T) {
 	restore := mockTimeSince(10 * time.Millisecond)
 	defer restore()
@@ -164,3 +218,61 @@ registry_storage_blob_upload_bytes_count 3
 	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, durationFullName, totalFullName)
 	require.NoError(t, err)
 }
+
+func TestPartUpload(t *testing.T) {
+	restore := mockTimeSince(10 * time.Millisecond)
+	defer restore()
+
+	done := PartUpload("foo")
+	PartUpload("bar")(2048)
+	done(1024)
+	PartUpload("foo")
+
+	var expected bytes.Buffer
+	expected.WriteString(`
+# HELP registry_storage_part_upload_bytes_total A counter of bytes uploaded as parts of multipart blob writes by the storage driver.
+# TYPE registry_storage_part_upload_bytes_total counter
+registry_storage_part_upload_bytes_total{driver="bar"} 2048
+registry_storage_part_upload_bytes_total{driver="foo"} 1024
+# HELP registry_storage_part_upload_duration_seconds A histogram of latencies for part uploads of multipart blob writes by the storage driver.
+# TYPE registry_storage_part_upload_duration_seconds histogram
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="0.005"} 0
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="0.01"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="0.025"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="0.05"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="0.1"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="0.25"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="0.5"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="1"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="2.5"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="5"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="10"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="bar",le="+Inf"} 1
+registry_storage_part_upload_duration_seconds_sum{driver="bar"} 0.01
+registry_storage_part_upload_duration_seconds_count{driver="bar"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="0.005"} 0
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="0.01"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="0.025"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="0.05"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="0.1"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="0.25"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="0.5"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="1"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="2.5"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="5"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="10"} 1
+registry_storage_part_upload_duration_seconds_bucket{driver="foo",le="+Inf"} 1
+registry_storage_part_upload_duration_seconds_sum{driver="foo"} 0.01
+registry_storage_part_upload_duration_seconds_count{driver="foo"} 1
+# HELP registry_storage_part_uploads_in_flight A gauge of part uploads of multipart blob writes in progress in the storage driver.
+# TYPE registry_storage_part_uploads_in_flight gauge
+registry_storage_part_uploads_in_flight{driver="bar"} 0
+registry_storage_part_uploads_in_flight{driver="foo"} 1
+`)
+	bytesFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, partUploadBytesTotalName)
+	durationFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, partUploadDurationName)
+	inFlightFullName := fmt.Sprintf("%s_%s_%s", metrics.NamespacePrefix, subsystem, partUploadsInFlightName)
+
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, &expected, bytesFullName, durationFullName, inFlightFullName)
+	require.NoError(t, err)
+}
@@ -37,6 +37,9 @@ type linkedBlobStore struct {
 	// do not write blob link paths to filesystem, but still allow blob puts to common blob store
 	disableMirrorFS bool
 
+	// uploadSessions persists the state of blob uploads, if not nil, so that they can be resumed by any instance
+	uploadSessions UploadSessionStore
+
 	// linkPath allows one to control the repository blob link set to which
 	// the blob store dispatches. This allows linked blobs stored at different
 	// paths to be operated on with the same logic.
@@ -194,7 +197,15 @@ func (lbs *linkedBlobStore) Create(ctx context.Context, options ...distribution.
 		return nil, err
 	}
 
-	return lbs.newBlobUpload(ctx, uuid, path, startedAt, false)
+	session := &UploadSession{Repository: lbs.repository.Named().Name(), ID: uuid, StartedAt: startedAt}
+	if lbs.uploadSessions != nil {
+		if err := lbs.uploadSessions.Set(ctx, session); err != nil {
+			// the upload can still be resumed from the storage backend metadata
+			dcontext.GetLogger(ctx).WithError(err).Warn("failed to save upload session")
+		}
+	}
+
+	return lbs.newBlobUpload(ctx, uuid, path, startedAt, session, false)
 }
 
 func (lbs *linkedBlobStore) Resume(ctx context.Context, id string) (distribution.BlobWriter, error) {
@@ -209,19 +220,44 @@ func (lbs *linkedBlobStore) Resume(ctx context.Context, id string) (distribution
 		return nil, err
 	}
 
-	startedAtBytes, err := lbs.blobStore.driver.GetContent(ctx, startedAtPath)
-	if err != nil {
-		switch err := err.(type) {
-		case driver.PathNotFoundError:
-			return nil, distribution.ErrBlobUploadUnknown
-		default:
-			return nil, err
+	// Prefer the shared upload session, if any, as the storage backend metadata may not be visible to this instance.
+	var session *UploadSession
+	if lbs.uploadSessions != nil {
+		session, err = lbs.uploadSessions.Get(ctx, lbs.repository.Named().Name(), id)
+		if err != nil {
+			dcontext.GetLogger(ctx).WithError(err).Warn("failed to read upload session, falling back to storage backend")
 		}
 	}
 
-	startedAt, err := time.Parse(time.RFC3339, string(startedAtBytes))
-	if err != nil {
-		return nil, err
+	var startedAt time.Time
+	if session != nil {
+		// sessions outlive uploads canceled or purged from the storage backend, so the upload directory must still
+		// exist. Its metadata files may not be visible yet, but the directory is as soon as the upload has started.
+		if _, err := lbs.blobStore.driver.Stat(ctx, path.Dir(startedAtPath)); err != nil {
+			if !errors.As(err, &driver.PathNotFoundError{}) {
+				return nil, err
+			}
+			if err := lbs.uploadSessions.Delete(ctx, lbs.repository.Named().Name(), id); err != nil {
+				dcontext.GetLogger(ctx).WithError(err).Warn("failed to delete stale upload session")
+			}
+			return nil, distribution.ErrBlobUploadUnknown
+		}
+		startedAt = session.StartedAt
+	} else {
+		startedAtBytes, err := lbs.blobStore.driver.GetContent(ctx, startedAtPath)
+		if err != nil {
+			switch err := err.(type) {
+			case driver.PathNotFoundError:
+				return nil, distribution.ErrBlobUploadUnknown
+			default:
+				return nil, err
+			}
+		}
+
+		startedAt, err = time.Parse(time.RFC3339, string(startedAtBytes))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	path, err := pathFor(uploadDataPathSpec{
@@ -233,7 +269,7 @@ func (lbs *linkedBlobStore) Resume(ctx context.Context, id string) (distribution
 		return nil, err
 	}
 
-	return lbs.newBlobUpload(ctx, id, path, startedAt, true)
+	return lbs.newBlobUpload(ctx, id, path, startedAt, session, true)
 }
 
 func (lbs *linkedBlobStore) Delete(ctx context.Context, dgst digest.Digest) error {
@@ -338,7 +374,7 @@ func (lbs *linkedBlobStore) mount(ctx context.Context, sourceRepo reference.Name
 }
 
 // newBlobUpload allocates a new upload controller with the given state.
-func (lbs *linkedBlobStore) newBlobUpload(ctx context.Context, uuid, path string, startedAt time.Time, append bool) (distribution.BlobWriter, error) {
+func (lbs *linkedBlobStore) newBlobUpload(ctx context.Context, uuid, path string, startedAt time.Time, session *UploadSession, append bool) (distribution.BlobWriter, error) {
 	fw, err := lbs.driver.Writer(ctx, path, append)
 	if err != nil {
 		return nil, err
@@ -354,6 +390,7 @@ func (lbs *linkedBlobStore) newBlobUpload(ctx context.Context, uuid, path string
 		driver:                 lbs.driver,
 		path:                   path,
 		resumableDigestEnabled: lbs.resumableDigestEnabled,
+		session:                session,
 	}
 
 	return bw, nil
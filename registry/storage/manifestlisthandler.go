@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/docker/distribution"
 	dcontext "github.com/docker/distribution/context"
@@ -18,6 +19,8 @@ type manifestListHandler struct {
 	ctx                      context.Context
 	manifestRefLimit         int
 	manifestPayloadSizeLimit int
+	manifestRefConcurrency   int
+	manifestRefTimeout       time.Duration
 }
 
 var _ ManifestHandler = &manifestListHandler{}
@@ -71,7 +74,13 @@ func (ms *manifestListHandler) verifyManifest(ctx context.Context, mnfst *manife
 
 	blobService := ms.repository.Blobs(ctx)
 
-	v := validation.NewManifestListValidator(manifestService, blobService, ms.manifestRefLimit, ms.manifestPayloadSizeLimit)
+	v := validation.NewManifestListValidator(
+		manifestService,
+		blobService,
+		ms.manifestRefLimit,
+		ms.manifestPayloadSizeLimit,
+		validation.WithConcurrentReferenceVerification(ms.manifestRefConcurrency, ms.manifestRefTimeout),
+	)
 
 	return v.Validate(ctx, mnfst)
 }
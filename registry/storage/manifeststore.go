@@ -38,6 +38,33 @@ type manifestStore struct {
 
 var _ distribution.ManifestService = &manifestStore{}
 
+// WithManifestPayloadSizeLimit is a distribution.ManifestServiceOption that overrides the maximum payload size of the
+// manifests and manifest lists put through a manifest service, set with the ManifestPayloadSizeLimit registry option.
+// This allows limits to vary per repository.
+func WithManifestPayloadSizeLimit(n int) distribution.ManifestServiceOption {
+	return manifestPayloadSizeLimitOption(n)
+}
+
+type manifestPayloadSizeLimitOption int
+
+// Apply implements distribution.ManifestServiceOption.
+func (o manifestPayloadSizeLimitOption) Apply(ms distribution.ManifestService) error {
+	mstore, ok := ms.(*manifestStore)
+	if !ok {
+		return fmt.Errorf("manifest payload size limit option is a storage-only option")
+	}
+	if h, ok := mstore.schema2Handler.(*schema2ManifestHandler); ok {
+		h.manifestPayloadSizeLimit = int(o)
+	}
+	if h, ok := mstore.manifestListHandler.(*manifestListHandler); ok {
+		h.manifestPayloadSizeLimit = int(o)
+	}
+	if h, ok := mstore.ocischemaHandler.(*ocischemaManifestHandler); ok {
+		h.manifestPayloadSizeLimit = int(o)
+	}
+	return nil
+}
+
 func (ms *manifestStore) Exists(ctx context.Context, dgst digest.Digest) (bool, error) {
 	dcontext.GetLogger(ms.ctx).Debug("(*manifestStore).Exists")
 
@@ -547,6 +547,41 @@ func testOCIManifestStorage(t *testing.T, testname string, includeMediaTypes boo
 // Storing empty manifests are not an expected behavior of the registry, but
 // but they may still be encountered on the storage backend due to a previous
 // bug, corrupted data, or the backend's data being modified directly.
+func TestManifestPayloadSizeLimitOption(t *testing.T) {
+	repoName, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	env := newManifestStoreTestEnv(t, repoName, "thetag", ManifestPayloadSizeLimit(10))
+
+	m, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: ocischema.SchemaVersion,
+		Config: distribution.Descriptor{
+			MediaType: v1.MediaTypeImageConfig,
+			Digest:    digest.FromString("config"),
+			Size:      6,
+		},
+	})
+	require.NoError(t, err)
+	_, payload, err := m.Payload()
+	require.NoError(t, err)
+
+	// the registry limit applies by default
+	ms, err := env.repository.Manifests(env.ctx)
+	require.NoError(t, err)
+	_, err = ms.Put(env.ctx, m)
+	var verr distribution.ErrManifestVerification
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr, 1)
+	require.IsType(t, distribution.ErrManifestPayloadSizeExceedsLimit{}, verr[0])
+
+	// the option overrides it, so validation proceeds and fails due to the missing config blob instead
+	ms, err = env.repository.Manifests(env.ctx, WithManifestPayloadSizeLimit(len(payload)))
+	require.NoError(t, err)
+	_, err = ms.Put(env.ctx, m)
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr, 1)
+	require.IsType(t, distribution.ErrManifestBlobUnknown{}, verr[0])
+}
+
 func TestEmptyManifestContent(t *testing.T) {
 	repoRef, err := reference.WithName("foo/bar")
 	require.NoError(t, err)
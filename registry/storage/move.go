@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/sirupsen/logrus"
+)
+
+// moveRepositoryProgressInterval is the number of moved files between progress log entries.
+const moveRepositoryProgressInterval = 1000
+
+// MoveRepository moves the filesystem metadata of a repository from oldName to newName. Because repository
+// directories are nested, this includes the metadata of all sub-repositories of oldName. Files are moved one by one,
+// as not all storage drivers support moving directories. The number of moved files is returned. Nothing is moved if no
+// metadata exists for oldName.
+func MoveRepository(ctx context.Context, d driver.StorageDriver, oldName, newName string) (int, error) {
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		return 0, err
+	}
+	oldDir := path.Join(root, oldName)
+	newDir := path.Join(root, newName)
+
+	log := dcontext.GetLogger(ctx).WithFields(logrus.Fields{"old_path": oldDir, "new_path": newDir})
+	log.Info("moving repository metadata")
+	start := time.Now()
+
+	// list all files before moving any, to avoid walking a tree that is being modified
+	var files []string
+	err = d.Walk(ctx, oldDir, func(fi driver.FileInfo) error {
+		if !fi.IsDir() {
+			files = append(files, fi.Path())
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.As(err, &driver.PathNotFoundError{}) {
+			log.Info("no repository metadata to move")
+			return 0, nil
+		}
+		return 0, fmt.Errorf("listing repository metadata: %w", err)
+	}
+
+	for i, src := range files {
+		dst := path.Join(newDir, strings.TrimPrefix(src, oldDir))
+		if err := d.Move(ctx, src, dst); err != nil {
+			return i, fmt.Errorf("moving repository metadata file %q: %w", src, err)
+		}
+		if (i+1)%moveRepositoryProgressInterval == 0 {
+			log.WithFields(logrus.Fields{"moved_files": i + 1, "total_files": len(files)}).Info("moving repository metadata")
+		}
+	}
+
+	// remove any leftover (empty) directories
+	if err := d.Delete(ctx, oldDir); err != nil && !errors.As(err, &driver.PathNotFoundError{}) {
+		return len(files), fmt.Errorf("deleting repository metadata directory: %w", err)
+	}
+
+	log.WithFields(logrus.Fields{
+		"moved_files": len(files),
+		"duration_s":  time.Since(start).Seconds(),
+	}).Info("repository metadata moved")
+
+	return len(files), nil
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveRepository(t *testing.T) {
+	d := inmemory.New()
+	ctx := context.Background()
+
+	// base repository and sub-repository uploads, plus an unrelated repository with a common prefix
+	baseID, subID, otherID := uuid.Generate().String(), uuid.Generate().String(), uuid.Generate().String()
+	addUploads(ctx, t, d, baseID, "foo/bar", time.Now())
+	addUploads(ctx, t, d, subID, "foo/bar/a", time.Now())
+	addUploads(ctx, t, d, otherID, "foo/barbar", time.Now())
+
+	n, err := MoveRepository(ctx, d, "foo/bar", "foo/baz")
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	for _, spec := range []pathSpec{
+		uploadDataPathSpec{name: "foo/baz", id: baseID},
+		uploadStartedAtPathSpec{name: "foo/baz", id: baseID},
+		uploadDataPathSpec{name: "foo/baz/a", id: subID},
+		uploadStartedAtPathSpec{name: "foo/baz/a", id: subID},
+		uploadDataPathSpec{name: "foo/barbar", id: otherID},
+	} {
+		p, err := pathFor(spec)
+		require.NoError(t, err)
+		_, err = d.Stat(ctx, p)
+		require.NoError(t, err, p)
+	}
+
+	root, err := pathFor(repositoriesRootPathSpec{})
+	require.NoError(t, err)
+	_, err = d.Stat(ctx, root+"/foo/bar")
+	require.ErrorAs(t, err, &driver.PathNotFoundError{})
+}
+
+func TestMoveRepository_NotFound(t *testing.T) {
+	d := inmemory.New()
+
+	n, err := MoveRepository(context.Background(), d, "foo/bar", "foo/baz")
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
@@ -94,6 +94,7 @@ const (
 //
 //	Uploads:
 //
+//	uploadsPathSpec:                <root>/v2/repositories/<name>/_uploads
 //	uploadDataPathSpec:             <root>/v2/repositories/<name>/_uploads/<id>/data
 //	uploadStartedAtPathSpec:        <root>/v2/repositories/<name>/_uploads/<id>/startedat
 //	uploadHashStatePathSpec:        <root>/v2/repositories/<name>/_uploads/<id>/hashstates/<algorithm>/<offset>
@@ -241,6 +242,8 @@ func pathFor(spec pathSpec) (string, error) {
 		blobPathPrefix := append(rootPrefix, "blobs")
 		return path.Join(append(blobPathPrefix, components...)...), nil
 
+	case uploadsPathSpec:
+		return path.Join(append(repoPrefix, v.name, "_uploads")...), nil
 	case uploadDataPathSpec:
 		return path.Join(append(repoPrefix, v.name, "_uploads", v.id, "data")...), nil
 	case uploadStartedAtPathSpec:
@@ -400,6 +403,14 @@ type blobDataPathSpec struct {
 
 func (blobDataPathSpec) pathSpec() {}
 
+// uploadsPathSpec defines the path parameters of the directory holding the
+// in-progress uploads of a repository.
+type uploadsPathSpec struct {
+	name string
+}
+
+func (uploadsPathSpec) pathSpec() {}
+
 // uploadDataPathSpec defines the path parameters of the data file for
 // uploads.
 type uploadDataPathSpec struct {
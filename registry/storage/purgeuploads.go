@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path"
 	"strings"
@@ -79,6 +80,34 @@ func PurgeUploads(ctx context.Context, driver storageDriver.StorageDriver, older
 	return deleted, errors
 }
 
+// PurgeUpload deletes the files of the upload with the given UUID in the named repository. Before that, any write to
+// the upload data file left in progress on the storage backend is canceled. For drivers that upload files in parts,
+// such as S3, this aborts the multipart upload, which would otherwise retain the uploaded parts indefinitely, as they
+// are not visible as regular objects and are therefore not removed when deleting the upload directory.
+func PurgeUpload(ctx context.Context, driver storageDriver.StorageDriver, name, id string) error {
+	dataPath, err := pathFor(uploadDataPathSpec{name: name, id: id})
+	if err != nil {
+		return err
+	}
+
+	fw, err := driver.Writer(ctx, dataPath, true)
+	switch {
+	case err == nil:
+		if err := fw.Cancel(); err != nil {
+			return fmt.Errorf("canceling upload data write: %w", err)
+		}
+	case errors.As(err, &storageDriver.PathNotFoundError{}):
+	default:
+		return fmt.Errorf("resuming upload data write: %w", err)
+	}
+
+	if err := driver.Delete(ctx, path.Dir(dataPath)); err != nil && !errors.As(err, &storageDriver.PathNotFoundError{}) {
+		return fmt.Errorf("deleting upload files: %w", err)
+	}
+
+	return nil
+}
+
 // getOutstandingUploads walks the upload directory, collecting files
 // which could be eligible for deletion.  The only reliable way to
 // classify the age of a file is with the date stored in the startedAt
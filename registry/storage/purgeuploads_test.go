@@ -163,3 +163,68 @@ func TestPurgeMissingStartedAt(t *testing.T) {
 		t.Errorf("Files unexpectedly deleted: %s", deleted)
 	}
 }
+
+// multipartDriver simulates a storage driver that keeps pending multipart uploads, which can only be discovered by
+// resuming a write and are not removed by deleting their parent directory.
+type multipartDriver struct {
+	driver.StorageDriver
+	pending map[string]bool
+}
+
+func (d *multipartDriver) Writer(ctx context.Context, path string, append bool) (driver.FileWriter, error) {
+	if append && !d.pending[path] {
+		return nil, driver.PathNotFoundError{Path: path}
+	}
+	d.pending[path] = true
+	return &multipartWriter{d: d, path: path}, nil
+}
+
+type multipartWriter struct {
+	driver.FileWriter
+	d    *multipartDriver
+	path string
+}
+
+func (w *multipartWriter) Cancel() error {
+	delete(w.d.pending, w.path)
+	return nil
+}
+
+func TestPurgeUpload(t *testing.T) {
+	ctx := context.Background()
+	d := &multipartDriver{StorageDriver: inmemory.New(), pending: make(map[string]bool)}
+
+	id := uuid.Generate().String()
+	addUploads(ctx, t, d, id, "test-repo", time.Now())
+	dataPath, err := pathFor(uploadDataPathSpec{name: "test-repo", id: id})
+	if err != nil {
+		t.Fatalf("Unable to resolve path")
+	}
+	d.pending[dataPath] = true
+
+	other := uuid.Generate().String()
+	addUploads(ctx, t, d, other, "test-repo", time.Now())
+
+	if err := PurgeUpload(ctx, d, "test-repo", id); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if d.pending[dataPath] {
+		t.Errorf("Pending upload not aborted")
+	}
+	if _, err := d.Stat(ctx, path.Dir(dataPath)); err == nil {
+		t.Errorf("Upload directory not deleted")
+	}
+
+	otherPath, err := pathFor(uploadDataPathSpec{name: "test-repo", id: other})
+	if err != nil {
+		t.Fatalf("Unable to resolve path")
+	}
+	if _, err := d.Stat(ctx, otherPath); err != nil {
+		t.Errorf("Unrelated upload deleted: %v", err)
+	}
+
+	// purging is idempotent
+	if err := PurgeUpload(ctx, d, "test-repo", id); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"net"
 	"regexp"
 	"time"
 
@@ -32,9 +33,13 @@ type registry struct {
 	manifestURLs                 validation.ManifestURLs
 	manifestsRefLimit            int
 	manifestsPayloadSizeLimit    int
+	manifestsRefConcurrency      int
+	manifestsRefTimeout          time.Duration
 	driver                       storagedriver.StorageDriver
 	db                           *datastore.DB
 	redirectExceptions           []*regexp.Regexp
+	redirectRepositories         []*regexp.Regexp
+	uploadSessions               UploadSessionStore
 }
 
 // RegistryOption is the type used for functional options for NewRegistry.
@@ -65,6 +70,38 @@ func EnableRedirectWithExceptions(exceptions []string) RegistryOption {
 	}
 }
 
+// WithRedirectRepositories limits redirections to repositories whose paths match any of the given patterns. Exceptions
+// set with EnableRedirectWithExceptions take precedence.
+func WithRedirectRepositories(patterns []string) RegistryOption {
+	return func(registry *registry) error {
+		for _, p := range patterns {
+			r, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("configuring storage redirect repository: %v", err)
+			}
+
+			registry.redirectRepositories = append(registry.redirectRepositories, r)
+		}
+		return nil
+	}
+}
+
+// WithRedirectClientExceptions disables redirections for clients whose IP address is within any of the given CIDR
+// ranges, such as those of clients in the same network as the registry, which are served directly instead.
+func WithRedirectClientExceptions(cidrs []string) RegistryOption {
+	return func(registry *registry) error {
+		for _, c := range cidrs {
+			_, n, err := net.ParseCIDR(c)
+			if err != nil {
+				return fmt.Errorf("configuring storage redirect client exception: %v", err)
+			}
+
+			registry.blobServer.redirect.clientExceptions = append(registry.blobServer.redirect.clientExceptions, n)
+		}
+		return nil
+	}
+}
+
 // WithRedirectExpiryDelay sets a custom expiry delay for presigned URLs used for redirecting clients to supported
 // storage backends for blob downloads.
 func WithRedirectExpiryDelay(d time.Duration) RegistryOption {
@@ -128,6 +165,17 @@ func ManifestPayloadSizeLimit(n int) RegistryOption {
 	}
 }
 
+// ManifestReferenceConcurrency is a functional option for NewRegistry. It
+// sets the maximum number of manifest list references verified concurrently,
+// and the deadline for verifying all of them.
+func ManifestReferenceConcurrency(n int, timeout time.Duration) RegistryOption {
+	return func(registry *registry) error {
+		registry.manifestsRefConcurrency = n
+		registry.manifestsRefTimeout = timeout
+		return nil
+	}
+}
+
 // Schema1SigningKey returns a functional option for NewRegistry. It sets the
 // key for signing  all schema1 manifests.
 func Schema1SigningKey(key libtrust.PrivateKey) RegistryOption {
@@ -169,6 +217,15 @@ func Database(db *datastore.DB) RegistryOption {
 	}
 }
 
+// UploadSessions is a functional option for NewRegistry. It persists the state of blob uploads in the given store, in
+// addition to the storage backend, so that uploads can be resumed by any registry instance.
+func UploadSessions(s UploadSessionStore) RegistryOption {
+	return func(registry *registry) error {
+		registry.uploadSessions = s
+		return nil
+	}
+}
+
 // DisableWriteMetadataToFS is a functional option for NewRegistry. It instructs
 // the blob metadata not to be linked to the filesystem metadata.
 func DisableMirrorFS(registry *registry) error {
@@ -337,6 +394,8 @@ func (repo *repository) Manifests(ctx context.Context, options ...distribution.M
 			blobStore:                blobStore,
 			manifestRefLimit:         repo.registry.manifestsRefLimit,
 			manifestPayloadSizeLimit: repo.registry.manifestsPayloadSizeLimit,
+			manifestRefConcurrency:   repo.registry.manifestsRefConcurrency,
+			manifestRefTimeout:       repo.registry.manifestsRefTimeout,
 		},
 		ocischemaHandler: &ocischemaManifestHandler{
 			ctx:                      ctx,
@@ -390,6 +449,16 @@ func (repo *repository) Blobs(ctx context.Context) distribution.BlobStore {
 		}
 	}
 
+	if blobServer.redirect.enabled && len(repo.redirectRepositories) > 0 {
+		blobServer.redirect.enabled = false
+		for _, r := range repo.redirectRepositories {
+			if r.MatchString(repo.name.Name()) {
+				blobServer.redirect.enabled = true
+				break
+			}
+		}
+	}
+
 	return &linkedBlobStore{
 		registry:             repo.registry,
 		blobStore:            repo.blobStore,
@@ -404,6 +473,7 @@ func (repo *repository) Blobs(ctx context.Context) distribution.BlobStore {
 		deleteEnabled:          repo.registry.deleteEnabled,
 		resumableDigestEnabled: repo.resumableDigestEnabled,
 		disableMirrorFS:        repo.disableMirrorFS,
+		uploadSessions:         repo.uploadSessions,
 	}
 }
 
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -95,6 +97,80 @@ func TestNewRegistry_RedirectExpiryDelay(t *testing.T) {
 	require.Equal(t, expiryDelay, r.blobServer.redirect.expiryDelay)
 }
 
+func TestNewRegistry_RedirectRepositories(t *testing.T) {
+	reg, err := NewRegistry(
+		context.Background(),
+		inmemory.New(),
+		EnableRedirectWithExceptions([]string{"^public/internal"}),
+		WithRedirectRepositories([]string{"^public/", "^library/alpine$"}),
+	)
+	require.NoError(t, err)
+
+	r, ok := reg.(*registry)
+	require.True(t, ok)
+	require.Len(t, r.redirectRepositories, 2)
+
+	expectRedirect(t, r, "public/foo", true)
+	expectRedirect(t, r, "public/foo/bar", true)
+	expectRedirect(t, r, "library/alpine", true)
+	expectRedirect(t, r, "library/alpine-edge", false)
+	expectRedirect(t, r, "private/foo", false)
+	// exceptions take precedence
+	expectRedirect(t, r, "public/internal/foo", false)
+
+	// Global direction is not effected by repository specific patterns.
+	require.True(t, r.blobServer.redirect.enabled)
+}
+
+func TestNewRegistry_RedirectRepositories_InvalidRegex(t *testing.T) {
+	_, err := NewRegistry(context.Background(), inmemory.New(), EnableRedirect, WithRedirectRepositories([]string{"><(((('>"}))
+	require.EqualError(t, err, "configuring storage redirect repository: error parsing regexp: missing closing ): `><(((('>`")
+}
+
+func TestNewRegistry_RedirectClientExceptions(t *testing.T) {
+	reg, err := NewRegistry(
+		context.Background(),
+		inmemory.New(),
+		EnableRedirect,
+		WithRedirectClientExceptions([]string{"10.0.0.0/8", "fd00::/8"}),
+	)
+	require.NoError(t, err)
+
+	r, ok := reg.(*registry)
+	require.True(t, ok)
+	require.Len(t, r.blobServer.redirect.clientExceptions, 2)
+
+	tcs := map[string]struct {
+		remoteAddr    string
+		forwardedFor  string
+		wantRedirects bool
+	}{
+		"internal":           {remoteAddr: "10.1.2.3:1234"},
+		"internal ipv6":      {remoteAddr: "[fd00::1]:1234"},
+		"external":           {remoteAddr: "192.0.2.1:1234", wantRedirects: true},
+		"external proxied":   {remoteAddr: "10.1.2.3:1234", forwardedFor: "192.0.2.1", wantRedirects: true},
+		"internal proxied":   {remoteAddr: "192.0.2.1:1234", forwardedFor: "10.1.2.3"},
+		"unparseable client": {remoteAddr: "foo", wantRedirects: true},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v2/foo/bar/blobs/sha256:abc", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+
+			require.Equal(t, tc.wantRedirects, r.blobServer.redirect.allowsClient(req))
+		})
+	}
+}
+
+func TestNewRegistry_RedirectClientExceptions_InvalidCIDR(t *testing.T) {
+	_, err := NewRegistry(context.Background(), inmemory.New(), EnableRedirect, WithRedirectClientExceptions([]string{"10.0.0.0"}))
+	require.EqualError(t, err, "configuring storage redirect client exception: invalid CIDR address: 10.0.0.0")
+}
+
 func TestRepositoryExists(t *testing.T) {
 	ctx := context.Background()
 
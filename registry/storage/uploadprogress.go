@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/opencontainers/go-digest"
+)
+
+// UploadProgress describes the progress of an in-progress blob upload, as recorded in the storage backend.
+type UploadProgress struct {
+	// ID is the upload UUID.
+	ID        string
+	StartedAt time.Time
+	// Size is the number of bytes received so far.
+	Size int64
+	// LastChunkAt is the time at which the last chunk was received. Zero if no data was received yet, or if the storage
+	// backend does not expose it for in-progress uploads.
+	LastChunkAt time.Time
+	// PartialDigest is the digest of the bytes received so far. Empty if unknown, such as when resumable digests are
+	// disabled. Only set by StatUpload.
+	PartialDigest digest.Digest
+}
+
+// BytesPerSecond returns the average rate at which the upload data was received between its start and now.
+func (p *UploadProgress) BytesPerSecond(now time.Time) int64 {
+	elapsed := now.Sub(p.StartedAt).Seconds()
+	if elapsed <= 0 {
+		return p.Size
+	}
+	return int64(float64(p.Size) / elapsed)
+}
+
+// StatUpload returns the progress of the upload with the given UUID in the named repository. Returns
+// distribution.ErrBlobUploadUnknown if no such upload exists.
+func StatUpload(ctx context.Context, driver storagedriver.StorageDriver, name, id string) (*UploadProgress, error) {
+	return statUpload(ctx, driver, name, id, true)
+}
+
+// ListUploads returns the progress of all in-progress uploads in the named repository, oldest first. The partial
+// digest of uploads is not computed.
+func ListUploads(ctx context.Context, driver storagedriver.StorageDriver, name string) ([]*UploadProgress, error) {
+	uploadsPath, err := pathFor(uploadsPathSpec{name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := driver.List(ctx, uploadsPath)
+	if err != nil {
+		if errors.As(err, &storagedriver.PathNotFoundError{}) {
+			return make([]*UploadProgress, 0), nil
+		}
+		return nil, fmt.Errorf("listing uploads: %w", err)
+	}
+
+	pp := make([]*UploadProgress, 0, len(paths))
+	for _, p := range paths {
+		up, err := statUpload(ctx, driver, name, path.Base(p), false)
+		if err != nil {
+			// the upload may have completed or been canceled since listing
+			if errors.Is(err, distribution.ErrBlobUploadUnknown) {
+				continue
+			}
+			return nil, err
+		}
+		pp = append(pp, up)
+	}
+	sort.Slice(pp, func(i, j int) bool { return pp[i].StartedAt.Before(pp[j].StartedAt) })
+
+	return pp, nil
+}
+
+func statUpload(ctx context.Context, driver storagedriver.StorageDriver, name, id string, withDigest bool) (*UploadProgress, error) {
+	startedAtPath, err := pathFor(uploadStartedAtPathSpec{name: name, id: id})
+	if err != nil {
+		return nil, err
+	}
+	b, err := driver.GetContent(ctx, startedAtPath)
+	if err != nil {
+		if errors.As(err, &storagedriver.PathNotFoundError{}) {
+			return nil, distribution.ErrBlobUploadUnknown
+		}
+		return nil, fmt.Errorf("reading upload start time: %w", err)
+	}
+	startedAt, err := time.Parse(time.RFC3339, string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parsing upload start time: %w", err)
+	}
+	up := &UploadProgress{ID: id, StartedAt: startedAt}
+
+	dataPath, err := pathFor(uploadDataPathSpec{name: name, id: id})
+	if err != nil {
+		return nil, err
+	}
+	fi, err := driver.Stat(ctx, dataPath)
+	switch {
+	case err == nil:
+		up.Size = fi.Size()
+		if up.Size > 0 {
+			up.LastChunkAt = fi.ModTime()
+		}
+	case errors.As(err, &storagedriver.PathNotFoundError{}):
+		// drivers writing files in parts, such as S3, only expose the upload data once complete
+	default:
+		return nil, fmt.Errorf("checking upload data: %w", err)
+	}
+
+	// a hash state is saved after every chunk, so the latest one also reveals the progress of uploads whose data isn't
+	// exposed by the storage driver yet
+	offset, hashStatePath, err := latestHashState(ctx, driver, name, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset == 0 {
+		return up, nil
+	}
+	if offset > up.Size {
+		up.Size = offset
+	}
+	if fi, err := driver.Stat(ctx, hashStatePath); err == nil && fi.ModTime().After(up.LastChunkAt) {
+		up.LastChunkAt = fi.ModTime()
+	}
+	if withDigest && offset == up.Size {
+		if up.PartialDigest, err = partialDigest(ctx, driver, hashStatePath); err != nil {
+			return nil, err
+		}
+	}
+
+	return up, nil
+}
+
+// latestHashState returns the offset and path of the canonical digest hash state with the highest offset of an upload.
+// The returned offset is zero if there is none.
+func latestHashState(ctx context.Context, driver storagedriver.StorageDriver, name, id string) (int64, string, error) {
+	prefix, err := pathFor(uploadHashStatePathSpec{name: name, id: id, alg: digest.Canonical, list: true})
+	if err != nil {
+		return 0, "", err
+	}
+	paths, err := driver.List(ctx, prefix)
+	if err != nil {
+		if errors.As(err, &storagedriver.PathNotFoundError{}) {
+			return 0, "", nil
+		}
+		return 0, "", fmt.Errorf("listing upload hash states: %w", err)
+	}
+
+	var (
+		latest     int64
+		latestPath string
+	)
+	for _, p := range paths {
+		offset, err := strconv.ParseInt(path.Base(p), 10, 64)
+		if err != nil {
+			continue
+		}
+		if offset > latest {
+			latest, latestPath = offset, p
+		}
+	}
+
+	return latest, latestPath, nil
+}
+
+// partialDigest restores the canonical digest hash state stored at the given path and returns the corresponding
+// digest.
+func partialDigest(ctx context.Context, driver storagedriver.StorageDriver, hashStatePath string) (digest.Digest, error) {
+	state, err := driver.GetContent(ctx, hashStatePath)
+	if err != nil {
+		return "", fmt.Errorf("reading upload hash state: %w", err)
+	}
+
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return "", fmt.Errorf("restoring upload hash state: %w", err)
+	}
+
+	return digest.NewDigest(digest.Canonical, h), nil
+}
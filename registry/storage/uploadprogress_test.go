@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatUpload(t *testing.T) {
+	ctx := context.Background()
+	d := inmemory.New()
+	reg, err := NewRegistry(ctx, d)
+	require.NoError(t, err)
+	named, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := reg.Repository(ctx, named)
+	require.NoError(t, err)
+
+	_, err = StatUpload(ctx, d, "foo/bar", "unknown")
+	require.ErrorIs(t, err, distribution.ErrBlobUploadUnknown)
+
+	bw, err := repo.Blobs(ctx).Create(ctx)
+	require.NoError(t, err)
+	require.NoError(t, bw.Close())
+
+	up, err := StatUpload(ctx, d, "foo/bar", bw.ID())
+	require.NoError(t, err)
+	require.Equal(t, bw.ID(), up.ID)
+	require.Equal(t, bw.StartedAt().Truncate(time.Second).UTC(), up.StartedAt.UTC())
+	require.Zero(t, up.Size)
+	require.Zero(t, up.LastChunkAt)
+	require.Empty(t, up.PartialDigest)
+
+	// write two chunks, closing the writer after each one as the upload handlers do
+	bw, err = repo.Blobs(ctx).Resume(ctx, bw.ID())
+	require.NoError(t, err)
+	_, err = bw.Write([]byte("hello "))
+	require.NoError(t, err)
+	require.NoError(t, bw.Close())
+
+	bw, err = repo.Blobs(ctx).Resume(ctx, bw.ID())
+	require.NoError(t, err)
+	_, err = bw.Write([]byte("world"))
+	require.NoError(t, err)
+	require.NoError(t, bw.Close())
+
+	up, err = StatUpload(ctx, d, "foo/bar", bw.ID())
+	require.NoError(t, err)
+	require.EqualValues(t, 11, up.Size)
+	require.NotZero(t, up.LastChunkAt)
+	require.Equal(t, digest.FromString("hello world"), up.PartialDigest)
+}
+
+func TestListUploads(t *testing.T) {
+	ctx := context.Background()
+	d := inmemory.New()
+
+	pp, err := ListUploads(ctx, d, "foo/bar")
+	require.NoError(t, err)
+	require.Empty(t, pp)
+
+	now := time.Now()
+	addUploads(ctx, t, d, "b", "foo/bar", now)
+	addUploads(ctx, t, d, "a", "foo/bar", now.Add(-time.Hour))
+	addUploads(ctx, t, d, "c", "foo/baz", now)
+
+	pp, err = ListUploads(ctx, d, "foo/bar")
+	require.NoError(t, err)
+	require.Len(t, pp, 2)
+	require.Equal(t, "a", pp[0].ID)
+	require.Equal(t, "b", pp[1].ID)
+}
+
+func TestUploadProgress_BytesPerSecond(t *testing.T) {
+	now := time.Now()
+	up := &UploadProgress{StartedAt: now.Add(-10 * time.Second), Size: 1000}
+	require.EqualValues(t, 100, up.BytesPerSecond(now))
+
+	up.StartedAt = now
+	require.EqualValues(t, 1000, up.BytesPerSecond(now))
+}
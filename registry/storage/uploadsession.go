@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gocache "github.com/eko/gocache/lib/v4/cache"
+	"github.com/eko/gocache/lib/v4/marshaler"
+	libstore "github.com/eko/gocache/lib/v4/store"
+	"github.com/opencontainers/go-digest"
+	"github.com/redis/go-redis/v9"
+)
+
+// uploadSessionOpTimeout defines the timeout applied to upload session operations against Redis.
+const uploadSessionOpTimeout = 500 * time.Millisecond
+
+// UploadSession is the state of a blob upload required to resume it. Persisting it outside of the storage backend
+// allows uploads to be resumed by any registry instance, such as when a load balancer routes the chunks of an upload
+// to different instances.
+type UploadSession struct {
+	// Repository is the path of the repository that the upload belongs to.
+	Repository string
+	// ID is the upload UUID.
+	ID        string
+	StartedAt time.Time
+	// Offset is the number of bytes digested by HashState. Both are only saved when the hash state of the upload is
+	// stored, at the end of each chunk, so these may lag behind the upload data if a chunk failed midway. The upload
+	// data size in the storage backend is authoritative, and HashState is only used if Offset matches it.
+	Offset int64
+	// HashState is the serialized state of the upload digester at Offset. Empty if resumable digests are disabled.
+	HashState []byte
+}
+
+// UploadSessionStore persists the state of blob uploads so that they can be resumed across registry instances.
+type UploadSessionStore interface {
+	// Get returns the session of the upload with the given ID in the given repository. Returns nil if not found.
+	Get(ctx context.Context, repo, id string) (*UploadSession, error)
+	// Set creates or updates an upload session.
+	Set(ctx context.Context, s *UploadSession) error
+	// Delete deletes the session of the upload with the given ID in the given repository, if any.
+	Delete(ctx context.Context, repo, id string) error
+}
+
+// redisUploadSessionStore is an UploadSessionStore backed by Redis.
+type redisUploadSessionStore struct {
+	cache *gocache.Cache[any]
+	// marshaler provides access to a MessagePack backed marshaling interface
+	marshaler *marshaler.Marshaler
+	// ttl is the expiration applied to sessions, refreshed on every update
+	ttl time.Duration
+}
+
+// NewRedisUploadSessionStore creates a new UploadSessionStore backed by Redis. Sessions expire after ttl without
+// updates, so that those of abandoned uploads are eventually removed.
+func NewRedisUploadSessionStore(cache *gocache.Cache[any], ttl time.Duration) *redisUploadSessionStore {
+	return &redisUploadSessionStore{cache: cache, marshaler: marshaler.New(cache), ttl: ttl}
+}
+
+// key generates a valid Redis key string for the session of the upload with the given ID in the repository with the
+// given path. The used key format is described in
+// https://gitlab.com/gitlab-org/container-registry/-/blob/master/docs-gitlab/redis-dev-guidelines.md#key-format.
+func (s *redisUploadSessionStore) key(repo, id string) string {
+	nsPrefix := strings.Split(repo, "/")[0]
+	hex := digest.FromString(repo).Hex()
+	return fmt.Sprintf("registry:storage:{repository:%s:%s}:upload:%s", nsPrefix, hex, id)
+}
+
+// Get implements UploadSessionStore.
+func (s *redisUploadSessionStore) Get(ctx context.Context, repo, id string) (*UploadSession, error) {
+	getCtx, cancel := context.WithTimeout(ctx, uploadSessionOpTimeout)
+	defer cancel()
+
+	tmp, err := s.marshaler.Get(getCtx, s.key(repo, id), new(UploadSession))
+	if err != nil {
+		// a wrapped redis.Nil is returned when the key is not found in Redis
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading upload session: %w", err)
+	}
+
+	us, ok := tmp.(*UploadSession)
+	if !ok {
+		return nil, fmt.Errorf("unexpected upload session type %T", tmp)
+	}
+	// Double check that the session belongs to the requested repository. This prevents resuming uploads of other
+	// repositories in case of a path hash collision.
+	if us.Repository != repo || us.ID != id {
+		return nil, nil
+	}
+
+	return us, nil
+}
+
+// Set implements UploadSessionStore.
+func (s *redisUploadSessionStore) Set(ctx context.Context, us *UploadSession) error {
+	setCtx, cancel := context.WithTimeout(ctx, uploadSessionOpTimeout)
+	defer cancel()
+
+	if err := s.marshaler.Set(setCtx, s.key(us.Repository, us.ID), us, libstore.WithExpiration(s.ttl)); err != nil {
+		return fmt.Errorf("writing upload session: %w", err)
+	}
+
+	return nil
+}
+
+// Delete implements UploadSessionStore.
+func (s *redisUploadSessionStore) Delete(ctx context.Context, repo, id string) error {
+	delCtx, cancel := context.WithTimeout(ctx, uploadSessionOpTimeout)
+	defer cancel()
+
+	if err := s.cache.Delete(delCtx, s.key(repo, id)); err != nil {
+		return fmt.Errorf("deleting upload session: %w", err)
+	}
+
+	return nil
+}
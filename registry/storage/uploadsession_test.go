@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/internal/testutil"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisUploadSessionStore(t *testing.T) {
+	c := testutil.NewRedisCacheController(t, 0)
+	s := NewRedisUploadSessionStore(c.Cache, time.Hour)
+	ctx := context.Background()
+
+	us, err := s.Get(ctx, "foo/bar", "abc")
+	require.NoError(t, err)
+	require.Nil(t, us)
+
+	expected := &UploadSession{
+		Repository: "foo/bar",
+		ID:         "abc",
+		StartedAt:  time.Date(2023, 10, 26, 9, 0, 0, 0, time.UTC),
+		Offset:     10,
+		HashState:  []byte("state"),
+	}
+	require.NoError(t, s.Set(ctx, expected))
+
+	key := "registry:storage:{repository:foo:" + digest.FromString("foo/bar").Hex() + "}:upload:abc"
+	require.True(t, c.Exists(key))
+	require.Equal(t, time.Hour, c.TTL(key))
+
+	us, err = s.Get(ctx, "foo/bar", "abc")
+	require.NoError(t, err)
+	require.Equal(t, expected.Repository, us.Repository)
+	require.Equal(t, expected.ID, us.ID)
+	require.True(t, expected.StartedAt.Equal(us.StartedAt))
+	require.Equal(t, expected.Offset, us.Offset)
+	require.Equal(t, expected.HashState, us.HashState)
+
+	require.NoError(t, s.Delete(ctx, "foo/bar", "abc"))
+	require.False(t, c.Exists(key))
+	require.NoError(t, s.Delete(ctx, "foo/bar", "abc"))
+}
+
+// TestBlobUpload_ResumeWithUploadSession simulates an upload whose chunks are handled by different registry instances,
+// where the storage backend metadata written by the first instance is not visible to the second one.
+func TestBlobUpload_ResumeWithUploadSession(t *testing.T) {
+	ctx := context.Background()
+	d := inmemory.New()
+	sessions := NewRedisUploadSessionStore(testutil.RedisCache(t, 0), time.Hour)
+	name, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+
+	newBlobStore := func() distribution.BlobStore {
+		reg, err := NewRegistry(ctx, d, UploadSessions(sessions))
+		require.NoError(t, err)
+		repo, err := reg.Repository(ctx, name)
+		require.NoError(t, err)
+		return repo.Blobs(ctx)
+	}
+
+	data := make([]byte, 1<<20)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	dgst := digest.FromBytes(data)
+	half := len(data) / 2
+
+	// first chunk
+	bw, err := newBlobStore().Create(ctx)
+	require.NoError(t, err)
+	_, err = io.Copy(bw, bytes.NewReader(data[:half]))
+	require.NoError(t, err)
+	require.NoError(t, bw.Close())
+
+	us, err := sessions.Get(ctx, name.Name(), bw.ID())
+	require.NoError(t, err)
+	require.NotNil(t, us)
+	require.Equal(t, int64(half), us.Offset)
+	require.NotEmpty(t, us.HashState)
+
+	// remove the upload metadata from the storage backend, keeping the data only
+	uploadDir := path.Dir(bw.(*blobWriter).path)
+	require.NoError(t, d.Delete(ctx, path.Join(uploadDir, "startedat")))
+	require.NoError(t, d.Delete(ctx, path.Join(uploadDir, "hashstates")))
+
+	// second chunk
+	bw, err = newBlobStore().Resume(ctx, bw.ID())
+	require.NoError(t, err)
+	require.Equal(t, int64(half), bw.Size())
+	_, err = io.Copy(bw, bytes.NewReader(data[half:]))
+	require.NoError(t, err)
+	// the digester state was restored from the session, so there is no need to read the data back
+	require.Equal(t, int64(len(data)), bw.(*blobWriter).written)
+
+	desc, err := bw.Commit(ctx, distribution.Descriptor{Digest: dgst})
+	require.NoError(t, err)
+	require.Equal(t, dgst, desc.Digest)
+	require.Equal(t, int64(len(data)), desc.Size)
+
+	us, err = sessions.Get(ctx, name.Name(), bw.ID())
+	require.NoError(t, err)
+	require.Nil(t, us)
+}
+
+func TestBlobUpload_ResumeWithoutUploadSession(t *testing.T) {
+	ctx := context.Background()
+	sessions := NewRedisUploadSessionStore(testutil.RedisCache(t, 0), time.Hour)
+	reg, err := NewRegistry(ctx, inmemory.New(), UploadSessions(sessions))
+	require.NoError(t, err)
+	name, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := reg.Repository(ctx, name)
+	require.NoError(t, err)
+
+	_, err = repo.Blobs(ctx).Resume(ctx, "unknown")
+	require.ErrorIs(t, err, distribution.ErrBlobUploadUnknown)
+}
+
+func TestBlobUpload_ResumeWithStaleUploadSession(t *testing.T) {
+	ctx := context.Background()
+	sessions := NewRedisUploadSessionStore(testutil.RedisCache(t, 0), time.Hour)
+	d := inmemory.New()
+	reg, err := NewRegistry(ctx, d, UploadSessions(sessions))
+	require.NoError(t, err)
+	name, err := reference.WithName("foo/bar")
+	require.NoError(t, err)
+	repo, err := reg.Repository(ctx, name)
+	require.NoError(t, err)
+
+	bw, err := repo.Blobs(ctx).Create(ctx)
+	require.NoError(t, err)
+	_, err = io.Copy(bw, bytes.NewReader([]byte("foo")))
+	require.NoError(t, err)
+	require.NoError(t, bw.Close())
+
+	// the upload is removed from the storage backend, such as when purging old uploads, but its session is left behind
+	require.NoError(t, d.Delete(ctx, path.Dir(bw.(*blobWriter).path)))
+	us, err := sessions.Get(ctx, name.Name(), bw.ID())
+	require.NoError(t, err)
+	require.NotNil(t, us)
+
+	_, err = repo.Blobs(ctx).Resume(ctx, bw.ID())
+	require.ErrorIs(t, err, distribution.ErrBlobUploadUnknown)
+
+	us, err = sessions.Get(ctx, name.Name(), bw.ID())
+	require.NoError(t, err)
+	require.Nil(t, us)
+}
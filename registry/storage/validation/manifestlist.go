@@ -3,6 +3,8 @@ package validation
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/manifestlist"
@@ -13,11 +15,29 @@ import (
 // verifies all manifest references.
 type ManifestListValidator struct {
 	baseValidator
+
+	// refConcurrency is the maximum number of references verified concurrently. References are verified sequentially
+	// if less than or equal to one.
+	refConcurrency int
+	// refTimeout is the deadline for verifying all references concurrently. Zero disables the deadline.
+	refTimeout time.Duration
+}
+
+// ManifestListValidatorOption is a functional option for NewManifestListValidator.
+type ManifestListValidatorOption func(*ManifestListValidator)
+
+// WithConcurrentReferenceVerification verifies the references of manifest lists with up to concurrency workers, giving
+// up on those not verified within timeout. A timeout of zero disables the deadline.
+func WithConcurrentReferenceVerification(concurrency int, timeout time.Duration) ManifestListValidatorOption {
+	return func(v *ManifestListValidator) {
+		v.refConcurrency = concurrency
+		v.refTimeout = timeout
+	}
 }
 
 // NewManifestListValidator returns a new ManifestListValidator.
-func NewManifestListValidator(exister ManifestExister, bs distribution.BlobStatter, refLimit, payloadLimit int) *ManifestListValidator {
-	return &ManifestListValidator{
+func NewManifestListValidator(exister ManifestExister, bs distribution.BlobStatter, refLimit, payloadLimit int, opts ...ManifestListValidatorOption) *ManifestListValidator {
+	v := &ManifestListValidator{
 		baseValidator: baseValidator{
 			manifestExister: exister,
 			blobStatter:     bs,
@@ -25,6 +45,11 @@ func NewManifestListValidator(exister ManifestExister, bs distribution.BlobStatt
 			payloadLimit:    payloadLimit,
 		},
 	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
 }
 
 // Validate ensures that the manifest content is valid from the
@@ -58,34 +83,124 @@ func (v *ManifestListValidator) Validate(ctx context.Context, mnfst *manifestlis
 	// and reject all others.
 	//
 	// https://github.com/distribution/distribution/pull/864
+	verify := v.verifyManifestReference
 	if mlcompat.LikelyBuildxCache(mnfst) {
-		for _, blobDescriptor := range mnfst.References() {
-			_, err := v.blobStatter.Stat(ctx, blobDescriptor.Digest)
-			if err != nil {
-				if err != distribution.ErrBlobUnknown {
-					errs = append(errs, err)
-				}
+		verify = v.verifyBlobReference
+	}
+	errs = append(errs, v.verifyReferences(ctx, mnfst.References(), verify)...)
 
-				// On error here, we always append unknown blob errors.
-				errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: blobDescriptor.Digest})
-			}
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// verifyBlobReference checks that the blob referenced by desc exists.
+func (v *ManifestListValidator) verifyBlobReference(ctx context.Context, desc distribution.Descriptor) []error {
+	var errs []error
+	if _, err := v.blobStatter.Stat(ctx, desc.Digest); err != nil {
+		if err != distribution.ErrBlobUnknown {
+			errs = append(errs, err)
 		}
-	} else {
-		for _, manifestDescriptor := range mnfst.References() {
-			exists, err := v.manifestExister.Exists(ctx, manifestDescriptor.Digest)
-			if err != nil && err != distribution.ErrBlobUnknown {
-				errs = append(errs, err)
-			}
-			if err != nil || !exists {
-				// On error here, we always append unknown blob errors.
-				errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: manifestDescriptor.Digest})
+
+		// On error here, we always append unknown blob errors.
+		errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: desc.Digest})
+	}
+
+	return errs
+}
+
+// verifyManifestReference checks that the manifest referenced by desc exists.
+func (v *ManifestListValidator) verifyManifestReference(ctx context.Context, desc distribution.Descriptor) []error {
+	var errs []error
+	exists, err := v.manifestExister.Exists(ctx, desc.Digest)
+	if err != nil && err != distribution.ErrBlobUnknown {
+		errs = append(errs, err)
+	}
+	if err != nil || !exists {
+		// On error here, we always append unknown blob errors.
+		errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: desc.Digest})
+	}
+
+	return errs
+}
+
+// verifyReferences verifies all refs with verify and returns the errors of those that failed, in the order of refs.
+// When concurrent verification is enabled, refs are verified by a pool of workers and, if they are not all verified
+// before the deadline, an ErrManifestReferencesVerificationTimeout is appended to the errors of those that were.
+func (v *ManifestListValidator) verifyReferences(ctx context.Context, refs []distribution.Descriptor, verify func(context.Context, distribution.Descriptor) []error) []error {
+	var errs []error
+	if v.refConcurrency <= 1 || len(refs) <= 1 {
+		for _, ref := range refs {
+			errs = append(errs, verify(ctx, ref)...)
+		}
+		return errs
+	}
+
+	verifyCtx := ctx
+	if v.refTimeout > 0 {
+		var cancel context.CancelFunc
+		verifyCtx, cancel = context.WithTimeout(ctx, v.refTimeout)
+		defer cancel()
+	}
+
+	results := make([][]error, len(refs))
+	verified := make([]bool, len(refs))
+	indexes := make(chan int)
+
+	workers := v.refConcurrency
+	if workers > len(refs) {
+		workers = len(refs)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				rErrs := verify(verifyCtx, refs[i])
+				// results of verifications interrupted by the deadline are inconclusive
+				if verifyCtx.Err() == nil {
+					results[i] = rErrs
+					verified[i] = true
+				}
 			}
+		}()
+	}
+
+feed:
+	for i := range refs {
+		select {
+		case indexes <- i:
+		case <-verifyCtx.Done():
+			break feed
 		}
 	}
+	close(indexes)
+	wg.Wait()
 
-	if len(errs) != 0 {
-		return errs
+	var unverified int
+	for i := range refs {
+		if !verified[i] {
+			unverified++
+			continue
+		}
+		errs = append(errs, results[i]...)
 	}
 
-	return nil
+	if unverified > 0 {
+		// the parent context was canceled, there is no deadline to report
+		if err := ctx.Err(); err != nil {
+			return append(errs, err)
+		}
+		errs = append(errs, distribution.ErrManifestReferencesVerificationTimeout{
+			Unverified: unverified,
+			References: len(refs),
+			Timeout:    v.refTimeout,
+		})
+	}
+
+	return errs
 }
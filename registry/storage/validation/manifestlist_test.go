@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/manifestlist"
 	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/storage/validation"
+	"github.com/docker/distribution/testutil"
 	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/require"
 )
@@ -260,3 +263,94 @@ func TestVerifyManifest_ManifestList_PayloadLimits(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyManifest_ManifestList_ConcurrentReferenceVerification(t *testing.T) {
+	ctx := context.Background()
+
+	registry := createRegistry(t)
+	repo := makeRepository(t, registry, "test")
+
+	var descriptors []manifestlist.ManifestDescriptor
+	var expectedErr distribution.ErrManifestVerification
+	for i := 0; i < 20; i++ {
+		if i%3 == 0 {
+			dgst := digest.FromString(fmt.Sprintf("fake-digest-%d", i))
+			descriptors = append(descriptors, manifestlist.ManifestDescriptor{
+				Descriptor: distribution.Descriptor{Digest: dgst, MediaType: schema2.MediaTypeManifest},
+			})
+			expectedErr = append(expectedErr, distribution.ErrManifestBlobUnknown{Digest: dgst})
+			continue
+		}
+		descriptors = append(descriptors, makeManifestDescriptor(t, repo))
+	}
+
+	dml, err := manifestlist.FromDescriptors(descriptors)
+	require.NoError(t, err)
+
+	manifestService, err := testutil.MakeManifestService(repo)
+	require.NoError(t, err)
+
+	v := validation.NewManifestListValidator(
+		manifestService,
+		repo.Blobs(ctx),
+		0,
+		0,
+		validation.WithConcurrentReferenceVerification(4, time.Minute),
+	)
+
+	// all missing references are reported at once, in the order they are referenced
+	err = v.Validate(ctx, dml)
+	require.Equal(t, expectedErr, err)
+}
+
+// slowManifestExister is a validation.ManifestExister that blocks on the given digests until the context is done.
+type slowManifestExister struct {
+	slow map[digest.Digest]bool
+}
+
+func (e *slowManifestExister) Exists(ctx context.Context, dgst digest.Digest) (bool, error) {
+	if e.slow[dgst] {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	return false, nil
+}
+
+func TestVerifyManifest_ManifestList_ConcurrentReferenceVerification_Timeout(t *testing.T) {
+	ctx := context.Background()
+
+	registry := createRegistry(t)
+	repo := makeRepository(t, registry, "test")
+
+	exister := &slowManifestExister{slow: make(map[digest.Digest]bool)}
+	var descriptors []manifestlist.ManifestDescriptor
+	for i := 0; i < 5; i++ {
+		dgst := digest.FromString(fmt.Sprintf("fake-digest-%d", i))
+		// the first two references are verified before the deadline
+		if i >= 2 {
+			exister.slow[dgst] = true
+		}
+		descriptors = append(descriptors, manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{Digest: dgst, MediaType: schema2.MediaTypeManifest},
+		})
+	}
+
+	dml, err := manifestlist.FromDescriptors(descriptors)
+	require.NoError(t, err)
+
+	timeout := 50 * time.Millisecond
+	v := validation.NewManifestListValidator(
+		exister,
+		repo.Blobs(ctx),
+		0,
+		0,
+		validation.WithConcurrentReferenceVerification(2, timeout),
+	)
+
+	err = v.Validate(ctx, dml)
+	require.Equal(t, distribution.ErrManifestVerification{
+		distribution.ErrManifestBlobUnknown{Digest: descriptors[0].Digest},
+		distribution.ErrManifestBlobUnknown{Digest: descriptors[1].Digest},
+		distribution.ErrManifestReferencesVerificationTimeout{Unverified: 3, References: 5, Timeout: timeout},
+	}, err)
+}
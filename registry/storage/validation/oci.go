@@ -52,7 +52,8 @@ func (v *OCIValidator) Validate(ctx context.Context, mnfst *ocischema.Deserializ
 	for _, descriptor := range mnfst.References() {
 		var err error
 
-		switch descriptor.MediaType {
+		// layers encrypted with ocicrypt are validated the same as their plain counterparts
+		switch ocischema.DecryptedLayerMediaType(descriptor.MediaType) {
 		case v1.MediaTypeImageLayer, v1.MediaTypeImageLayerGzip, v1.MediaTypeImageLayerNonDistributable, v1.MediaTypeImageLayerNonDistributableGzip:
 			for _, u := range descriptor.URLs {
 				if !validURL(u, v.manifestURLs) {
@@ -36,6 +36,12 @@ func TestVerifyManifest_OCI_NonDistributableLayer(t *testing.T) {
 		MediaType: v1.MediaTypeImageLayerNonDistributableGzip,
 	}
 
+	encryptedNonDistributableLayer := distribution.Descriptor{
+		Digest:    digest.FromString("encryptedNonDistributableLayer"),
+		Size:      6323,
+		MediaType: "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip+encrypted",
+	}
+
 	type testcase struct {
 		BaseLayer distribution.Descriptor
 		URLs      []string
@@ -98,6 +104,16 @@ func TestVerifyManifest_OCI_NonDistributableLayer(t *testing.T) {
 			[]string{"https://foo/bar"},
 			nil,
 		},
+		{
+			encryptedNonDistributableLayer,
+			[]string{"http://nope/bar"},
+			errInvalidURL,
+		},
+		{
+			encryptedNonDistributableLayer,
+			[]string{"https://foo/bar"},
+			nil,
+		},
 	}
 
 	for _, c := range cases {
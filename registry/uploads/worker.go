@@ -0,0 +1,185 @@
+package uploads
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/sirupsen/logrus"
+	"gitlab.com/gitlab-org/labkit/correlation"
+)
+
+const (
+	componentKey = "component"
+	workerName   = "registry.uploads.Worker"
+
+	defaultInterval  = 10 * time.Minute
+	defaultAge       = 24 * time.Hour
+	defaultBatchSize = 100
+)
+
+// Store is the subset of datastore.UploadStore used to purge abandoned uploads.
+type Store interface {
+	FindStartedBefore(ctx context.Context, t time.Time, limit int) ([]*models.Upload, error)
+	Delete(ctx context.Context, uuid string) error
+}
+
+// Purger deletes the files of an upload from the storage backend, aborting any write left in progress.
+type Purger interface {
+	Purge(ctx context.Context, repoPath, uuid string) error
+}
+
+// Worker periodically purges uploads that were started longer than a given age ago and neither completed nor canceled
+// since. An upload is only untracked once its files are purged, so failed purges are retried on the next run.
+type Worker struct {
+	store     Store
+	purger    Purger
+	logger    log.Logger
+	interval  time.Duration
+	age       time.Duration
+	batchSize int
+}
+
+// WorkerOption provides functional options for NewWorker.
+type WorkerOption func(*Worker)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) WorkerOption {
+	return func(w *Worker) {
+		w.logger = l
+	}
+}
+
+// WithInterval sets the interval between runs. Defaults to 10 minutes.
+func WithInterval(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.interval = d
+	}
+}
+
+// WithAge sets the age after which an upload is purged. Defaults to 24 hours.
+func WithAge(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.age = d
+	}
+}
+
+// WithBatchSize sets the maximum number of uploads purged in each run. Defaults to 100.
+func WithBatchSize(n int) WorkerOption {
+	return func(w *Worker) {
+		w.batchSize = n
+	}
+}
+
+func (w *Worker) applyDefaults() {
+	if w.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		w.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if w.interval == 0 {
+		w.interval = defaultInterval
+	}
+	if w.age == 0 {
+		w.age = defaultAge
+	}
+	if w.batchSize == 0 {
+		w.batchSize = defaultBatchSize
+	}
+}
+
+// NewWorker creates a new Worker.
+func NewWorker(store Store, purger Purger, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		store:  store,
+		purger: purger,
+	}
+	w.applyDefaults()
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.logger = w.logger.WithFields(log.Fields{componentKey: workerName})
+
+	return w
+}
+
+// Run purges up to the configured batch size of uploads older than the configured age. Uploads that fail to be purged
+// are skipped and remain tracked. It returns the number of purged uploads and, if any purge failed, the last error.
+func (w *Worker) Run(ctx context.Context) (int, error) {
+	uu, err := w.store.FindStartedBefore(ctx, time.Now().Add(-w.age), w.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	l := log.GetLogger(log.WithContext(ctx))
+
+	var purged, failed int
+	var lastErr error
+	for _, u := range uu {
+		ul := l.WithFields(log.Fields{
+			"repository_path": u.RepositoryPath,
+			"upload_uuid":     u.UUID,
+			"created_at":      u.CreatedAt.String(),
+		})
+		if err := w.purger.Purge(ctx, u.RepositoryPath, u.UUID); err != nil {
+			ul.WithError(err).Warn("failed to purge upload")
+			failed++
+			lastErr = err
+			continue
+		}
+		if err := w.store.Delete(ctx, u.UUID); err != nil {
+			return purged, err
+		}
+		ul.Info("upload purged")
+		purged++
+	}
+
+	if lastErr != nil {
+		return purged, fmt.Errorf("failed to purge %d uploads, last error: %w", failed, lastErr)
+	}
+
+	return purged, nil
+}
+
+// Start starts the Worker. This is a blocking call that, every configured interval, purges a batch of abandoned uploads
+// until the provided context is canceled.
+func (w *Worker) Start(ctx context.Context) error {
+	w.logger.WithFields(log.Fields{
+		"interval_s": w.interval.Seconds(),
+		"age_s":      w.age.Seconds(),
+		"batch_size": w.batchSize,
+	}).Info("starting upload purging worker")
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		id := correlation.SafeRandomID()
+		rCtx := correlation.ContextWithCorrelation(ctx, id)
+		l := w.logger.WithFields(log.Fields{correlation.FieldName: id})
+		rCtx = log.WithLogger(rCtx, l)
+
+		start := time.Now()
+		n, err := w.Run(rCtx)
+		l = l.WithFields(log.Fields{"duration_s": time.Since(start).Seconds(), "uploads_count": n})
+		if err != nil {
+			l.WithError(err).Error("upload purging run failed")
+			continue
+		}
+		if n > 0 {
+			l.Info("upload purging run completed")
+		}
+	}
+}
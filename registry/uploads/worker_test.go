@@ -0,0 +1,162 @@
+package uploads_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/uploads"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is an uploads.Store that serves a fixed set of tracked uploads.
+type fakeStore struct {
+	mu      sync.Mutex
+	uploads []*models.Upload
+	findErr error
+}
+
+func (s *fakeStore) FindStartedBefore(_ context.Context, t time.Time, limit int) ([]*models.Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	var uu []*models.Upload
+	for _, u := range s.uploads {
+		if u.CreatedAt.Before(t) && len(uu) < limit {
+			uu = append(uu, u)
+		}
+	}
+	return uu, nil
+}
+
+func (s *fakeStore) Delete(_ context.Context, uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, u := range s.uploads {
+		if u.UUID == uuid {
+			s.uploads = append(s.uploads[:i], s.uploads[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) tracked() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.uploads))
+	for _, u := range s.uploads {
+		ids = append(ids, u.UUID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// fakePurger is an uploads.Purger that records purged uploads, failing for the given UUIDs.
+type fakePurger struct {
+	mu     sync.Mutex
+	purged []string
+	fails  map[string]error
+}
+
+func (p *fakePurger) Purge(_ context.Context, _, uuid string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.fails[uuid]; err != nil {
+		return err
+	}
+	p.purged = append(p.purged, uuid)
+	return nil
+}
+
+func newUpload(uuid string, age time.Duration) *models.Upload {
+	return &models.Upload{UUID: uuid, RepositoryPath: "foo/bar", CreatedAt: time.Now().Add(-age)}
+}
+
+func TestWorker_Run(t *testing.T) {
+	s := &fakeStore{uploads: []*models.Upload{
+		newUpload("a", 48*time.Hour),
+		newUpload("b", time.Hour),
+		newUpload("c", 25*time.Hour),
+	}}
+	p := &fakePurger{}
+	w := uploads.NewWorker(s, p)
+
+	n, err := w.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.ElementsMatch(t, []string{"a", "c"}, p.purged)
+	// recent uploads are kept
+	require.Equal(t, []string{"b"}, s.tracked())
+}
+
+func TestWorker_Run_BatchSize(t *testing.T) {
+	s := &fakeStore{uploads: []*models.Upload{
+		newUpload("a", 48*time.Hour),
+		newUpload("b", 48*time.Hour),
+		newUpload("c", 48*time.Hour),
+	}}
+	w := uploads.NewWorker(s, &fakePurger{}, uploads.WithBatchSize(2))
+
+	n, err := w.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Len(t, s.tracked(), 1)
+}
+
+func TestWorker_Run_PurgeError(t *testing.T) {
+	s := &fakeStore{uploads: []*models.Upload{
+		newUpload("a", 48*time.Hour),
+		newUpload("b", 48*time.Hour),
+	}}
+	p := &fakePurger{fails: map[string]error{"a": errors.New("foo")}}
+	w := uploads.NewWorker(s, p)
+
+	n, err := w.Run(context.Background())
+	require.EqualError(t, err, "failed to purge 1 uploads, last error: foo")
+	require.Equal(t, 1, n)
+	// uploads that failed to be purged remain tracked, so they're retried on the next run
+	require.Equal(t, []string{"a"}, s.tracked())
+}
+
+func TestWorker_Run_FindError(t *testing.T) {
+	s := &fakeStore{findErr: errors.New("foo")}
+	w := uploads.NewWorker(s, &fakePurger{})
+
+	n, err := w.Run(context.Background())
+	require.EqualError(t, err, "foo")
+	require.Zero(t, n)
+}
+
+func TestWorker_Start(t *testing.T) {
+	s := &fakeStore{uploads: []*models.Upload{
+		newUpload("a", 48*time.Hour),
+		newUpload("b", 48*time.Hour),
+		newUpload("c", 48*time.Hour),
+	}}
+	w := uploads.NewWorker(s, &fakePurger{},
+		uploads.WithInterval(10*time.Millisecond),
+		uploads.WithAge(time.Hour),
+		uploads.WithBatchSize(1),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- w.Start(ctx) }()
+
+	// all abandoned uploads are purged, one batch per run
+	require.Eventually(t, func() bool { return len(s.tracked()) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+}
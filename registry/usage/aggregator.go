@@ -0,0 +1,230 @@
+// Package usage provides a background worker that rolls up per-namespace usage counters, such as pushes and pulls, into
+// daily statistics in the metadata database. Counters are aggregated in memory and written periodically, to keep the
+// write load on the database low.
+package usage
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/log"
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	componentKey   = "component"
+	aggregatorName = "registry.usage.Aggregator"
+
+	defaultFlushInterval = 30 * time.Second
+	defaultRetention     = 90 * 24 * time.Hour
+
+	// pruneInterval is the minimum interval between deletions of statistics past the retention period.
+	pruneInterval = time.Hour
+)
+
+// Store is the subset of datastore.NamespaceStatisticsStore used to record namespace statistics.
+type Store interface {
+	Increment(ctx context.Context, ss []*models.NamespaceStatistics) error
+	DeleteOlderThan(ctx context.Context, date time.Time) (int64, error)
+}
+
+// statisticsKey identifies the counters of a namespace for a given day.
+type statisticsKey struct {
+	namespaceID int64
+	date        time.Time
+}
+
+// Aggregator buffers namespace usage counters in memory, merged by namespace and day, and periodically adds them to
+// those in the database. Statistics older than the retention period are deleted periodically. Recorded statistics are
+// therefore approximate, lagging behind by up to the flush interval.
+type Aggregator struct {
+	store         Store
+	logger        log.Logger
+	flushInterval time.Duration
+	retention     time.Duration
+
+	mu        sync.Mutex
+	pending   map[statisticsKey]*models.NamespaceStatistics
+	lastPrune time.Time
+}
+
+// AggregatorOption provides functional options for NewAggregator.
+type AggregatorOption func(*Aggregator)
+
+// WithLogger sets the logger.
+func WithLogger(l log.Logger) AggregatorOption {
+	return func(a *Aggregator) {
+		a.logger = l
+	}
+}
+
+// WithFlushInterval sets the interval between writes to the database. Defaults to 30 seconds.
+func WithFlushInterval(d time.Duration) AggregatorOption {
+	return func(a *Aggregator) {
+		a.flushInterval = d
+	}
+}
+
+// WithRetention sets for how long daily statistics are kept in the database. Defaults to 90 days.
+func WithRetention(d time.Duration) AggregatorOption {
+	return func(a *Aggregator) {
+		a.retention = d
+	}
+}
+
+func (a *Aggregator) applyDefaults() {
+	if a.logger == nil {
+		defaultLogger := logrus.New()
+		defaultLogger.SetOutput(io.Discard)
+		a.logger = log.FromLogrusLogger(defaultLogger)
+	}
+	if a.flushInterval == 0 {
+		a.flushInterval = defaultFlushInterval
+	}
+	if a.retention == 0 {
+		a.retention = defaultRetention
+	}
+}
+
+// NewAggregator creates a new Aggregator.
+func NewAggregator(store Store, opts ...AggregatorOption) *Aggregator {
+	a := &Aggregator{
+		store:   store,
+		pending: make(map[statisticsKey]*models.NamespaceStatistics),
+	}
+	a.applyDefaults()
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	a.logger = a.logger.WithFields(log.Fields{componentKey: aggregatorName})
+
+	return a
+}
+
+// Record buffers the counters of s, to be added to those of the same namespace and day (UTC) on the next flush. The
+// current day is used if s.Date is not set. This method may be called safely from multiple concurrent goroutines.
+func (a *Aggregator) Record(s *models.NamespaceStatistics) {
+	date := s.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	date = date.UTC().Truncate(24 * time.Hour)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.record(statisticsKey{s.NamespaceID, date}, s)
+}
+
+func (a *Aggregator) record(k statisticsKey, s *models.NamespaceStatistics) {
+	if p, ok := a.pending[k]; ok {
+		p.Add(s)
+		return
+	}
+	p := *s
+	p.Date = k.date
+	a.pending[k] = &p
+}
+
+// RecordPush records a push to the namespace with the given ID.
+func (a *Aggregator) RecordPush(namespaceID int64) {
+	a.Record(&models.NamespaceStatistics{NamespaceID: namespaceID, Pushes: 1})
+}
+
+// RecordPull records a pull from the namespace with the given ID.
+func (a *Aggregator) RecordPull(namespaceID int64) {
+	a.Record(&models.NamespaceStatistics{NamespaceID: namespaceID, Pulls: 1})
+}
+
+// RecordBytesAdded records the upload of size bytes to the namespace with the given ID.
+func (a *Aggregator) RecordBytesAdded(namespaceID, size int64) {
+	a.Record(&models.NamespaceStatistics{NamespaceID: namespaceID, BytesAdded: size})
+}
+
+// Flush adds all buffered counters to those in the database. Counters are buffered again if the write fails, so that
+// they are retried on the next flush.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = make(map[statisticsKey]*models.NamespaceStatistics)
+	a.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ss := make([]*models.NamespaceStatistics, 0, len(pending))
+	for _, s := range pending {
+		ss = append(ss, s)
+	}
+
+	if err := a.store.Increment(ctx, ss); err != nil {
+		a.logger.WithError(err).WithFields(log.Fields{"statistics": len(ss)}).Warn("failed to record namespace statistics")
+
+		// memory usage is bounded by the number of distinct namespaces and days, so it's safe to keep them around
+		a.mu.Lock()
+		for k, s := range pending {
+			a.record(k, s)
+		}
+		a.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// Prune deletes statistics older than the retention period, if not done within the last hour. Returns the number of
+// deleted rows.
+func (a *Aggregator) Prune(ctx context.Context) (int64, error) {
+	now := time.Now()
+
+	a.mu.Lock()
+	if now.Sub(a.lastPrune) < pruneInterval {
+		a.mu.Unlock()
+		return 0, nil
+	}
+	a.lastPrune = now
+	a.mu.Unlock()
+
+	n, err := a.store.DeleteOlderThan(ctx, now.Add(-a.retention))
+	if err != nil {
+		a.logger.WithError(err).Warn("failed to prune namespace statistics")
+		return 0, err
+	}
+	if n > 0 {
+		a.logger.WithFields(log.Fields{"deleted": n}).Info("pruned namespace statistics")
+	}
+
+	return n, nil
+}
+
+// Start starts the Aggregator. This is a blocking call that flushes buffered counters and prunes old statistics every
+// configured interval until the provided context is canceled. Counters buffered since the last flush are not written
+// once the context is canceled, use Flush for that.
+func (a *Aggregator) Start(ctx context.Context) error {
+	a.logger.WithFields(log.Fields{
+		"flush_interval_s": a.flushInterval.Seconds(),
+		"retention_s":      a.retention.Seconds(),
+	}).Info("starting namespace statistics")
+
+	ticker := time.NewTicker(a.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.logger.Warn("context cancelled, exiting")
+			return ctx.Err()
+		case <-ticker.C:
+			// errors are logged by Flush and Prune
+			_ = a.Flush(ctx)
+			_, _ = a.Prune(ctx)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package usage_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/datastore/models"
+	"github.com/docker/distribution/registry/usage"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is a usage.Store that records the statistics it receives, optionally failing for a given number of calls.
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]*models.NamespaceStatistics
+	fails   int
+	prunes  []time.Time
+}
+
+func (s *fakeStore) Increment(_ context.Context, ss []*models.NamespaceStatistics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("foo")
+	}
+	s.batches = append(s.batches, ss)
+	return nil
+}
+
+func (s *fakeStore) DeleteOlderThan(_ context.Context, date time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prunes = append(s.prunes, date)
+	return 1, nil
+}
+
+// statistics returns all received statistics, sorted by namespace and date.
+func (s *fakeStore) statistics() []*models.NamespaceStatistics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ss []*models.NamespaceStatistics
+	for _, b := range s.batches {
+		ss = append(ss, b...)
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		if ss[i].NamespaceID != ss[j].NamespaceID {
+			return ss[i].NamespaceID < ss[j].NamespaceID
+		}
+		return ss[i].Date.Before(ss[j].Date)
+	})
+	return ss
+}
+
+func TestAggregator_Record(t *testing.T) {
+	s := &fakeStore{}
+	a := usage.NewAggregator(s)
+
+	d1 := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	d2 := time.Date(2023, 12, 2, 1, 0, 0, 0, time.UTC)
+
+	// counters are merged by namespace and day
+	a.Record(&models.NamespaceStatistics{NamespaceID: 1, Date: d1, Pushes: 1, BytesAdded: 10})
+	a.Record(&models.NamespaceStatistics{NamespaceID: 1, Date: d1.Add(time.Hour), Pulls: 2, BytesAdded: 5})
+	a.Record(&models.NamespaceStatistics{NamespaceID: 1, Date: d2, Pulls: 1})
+	a.Record(&models.NamespaceStatistics{NamespaceID: 2, Date: d1, BytesReclaimed: 7})
+
+	require.NoError(t, a.Flush(context.Background()))
+	require.Len(t, s.batches, 1)
+	require.Equal(t, []*models.NamespaceStatistics{
+		{NamespaceID: 1, Date: d1.Truncate(24 * time.Hour), Pushes: 1, Pulls: 2, BytesAdded: 15},
+		{NamespaceID: 1, Date: d2.Truncate(24 * time.Hour), Pulls: 1},
+		{NamespaceID: 2, Date: d1.Truncate(24 * time.Hour), BytesReclaimed: 7},
+	}, s.statistics())
+
+	// nothing left to flush
+	require.NoError(t, a.Flush(context.Background()))
+	require.Len(t, s.batches, 1)
+}
+
+func TestAggregator_RecordHelpers(t *testing.T) {
+	s := &fakeStore{}
+	a := usage.NewAggregator(s)
+
+	a.RecordPush(1)
+	a.RecordPull(1)
+	a.RecordPull(1)
+	a.RecordBytesAdded(1, 100)
+
+	require.NoError(t, a.Flush(context.Background()))
+	ss := s.statistics()
+	require.Len(t, ss, 1)
+	require.EqualValues(t, 1, ss[0].Pushes)
+	require.EqualValues(t, 2, ss[0].Pulls)
+	require.EqualValues(t, 100, ss[0].BytesAdded)
+	require.Equal(t, time.Now().UTC().Truncate(24*time.Hour), ss[0].Date)
+}
+
+func TestAggregator_Flush_Error(t *testing.T) {
+	s := &fakeStore{fails: 1}
+	a := usage.NewAggregator(s)
+
+	a.RecordPush(1)
+	require.EqualError(t, a.Flush(context.Background()), "foo")
+	require.Empty(t, s.statistics())
+
+	// failed counters are retried on the next flush, along with new ones
+	a.RecordPush(1)
+	require.NoError(t, a.Flush(context.Background()))
+	ss := s.statistics()
+	require.Len(t, ss, 1)
+	require.EqualValues(t, 2, ss[0].Pushes)
+}
+
+func TestAggregator_Prune(t *testing.T) {
+	s := &fakeStore{}
+	a := usage.NewAggregator(s, usage.WithRetention(48*time.Hour))
+
+	n, err := a.Prune(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+	require.Len(t, s.prunes, 1)
+	require.WithinDuration(t, time.Now().Add(-48*time.Hour), s.prunes[0], time.Minute)
+
+	// pruning is throttled
+	n, err = a.Prune(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Len(t, s.prunes, 1)
+}
+
+func TestAggregator_Start(t *testing.T) {
+	s := &fakeStore{}
+	a := usage.NewAggregator(s, usage.WithFlushInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- a.Start(ctx) }()
+
+	a.RecordPull(1)
+	require.Eventually(t, func() bool { return len(s.statistics()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Len(t, s.prunes, 1)
+}
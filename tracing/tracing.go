@@ -0,0 +1,145 @@
+// Package tracing provides distributed tracing with OpenTelemetry. Spans are always created through the global tracer
+// provider, which is a no-op until Configure is called, so instrumented code paths have no tracing overhead unless
+// tracing is enabled.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/docker/distribution"
+
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+)
+
+// propagator is used to propagate trace context across process boundaries. It's set as the global propagator by
+// Configure but used directly, so that trace context is propagated even if spans are not exported.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Configure sets up the global tracer provider to export spans according to config. The returned function flushes
+// pending spans and stops the exporter, and must be called before exiting. Configure is a no-op if tracing is
+// disabled.
+func Configure(ctx context.Context, config configuration.Tracing) (func(context.Context) error, error) {
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	rate := 1.0
+	if config.SampleRate != nil {
+		rate = *config.SampleRate
+	}
+	if rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("sample rate must be between 0 and 1, got %v", rate)
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+	if config.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(config.URLPath))
+	}
+	if config.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(config.Headers))
+	}
+	if config.Timeout > 0 {
+		opts = append(opts, otlptracehttp.WithTimeout(config.Timeout))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(config.ServiceName),
+		semconv.ServiceVersionKey.String(version.Version),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(rate))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
+
+	return tp.Shutdown, nil
+}
+
+// StartSpan starts a new span with the given name, as a child of the span in ctx, if any. The returned context holds
+// the new span. The span must be ended by the caller, usually with EndSpan.
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// EndSpan ends span, recording err and marking the span as failed if not nil.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetAttributes sets attributes on the span in ctx, if any.
+func SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+}
+
+// Extract returns a copy of ctx with the trace context propagated in the headers of an incoming request, if any.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// Inject adds the trace context of ctx to the headers of an outgoing request.
+func Inject(ctx context.Context, h http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+// TraceContext is the W3C trace context of a span in a serializable form. It allows propagating trace context to
+// work done asynchronously, after the originating request has completed.
+type TraceContext struct {
+	TraceParent string
+	TraceState  string
+}
+
+// IsZero reports whether tc holds no trace context.
+func (tc TraceContext) IsZero() bool {
+	return tc.TraceParent == ""
+}
+
+// TraceContextFrom returns the trace context of the span in ctx. The result is zero if ctx has no span.
+func TraceContextFrom(ctx context.Context) TraceContext {
+	c := make(propagation.MapCarrier)
+	propagation.TraceContext{}.Inject(ctx, c)
+	return TraceContext{TraceParent: c[traceParentHeader], TraceState: c[traceStateHeader]}
+}
+
+// WithTraceContext returns a copy of ctx with the trace context tc, previously obtained with TraceContextFrom, so that
+// spans started from it continue the originating trace.
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	if tc.IsZero() {
+		return ctx
+	}
+	c := propagation.MapCarrier{traceParentHeader: tc.TraceParent}
+	if tc.TraceState != "" {
+		c[traceStateHeader] = tc.TraceState
+	}
+	return propagation.TraceContext{}.Extract(ctx, c)
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	traceState  = "vendor=value"
+)
+
+func TestConfigure_Disabled(t *testing.T) {
+	shutdown, err := Configure(context.Background(), configuration.Tracing{})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+}
+
+func TestConfigure_InvalidSampleRate(t *testing.T) {
+	rate := 1.5
+	_, err := Configure(context.Background(), configuration.Tracing{Enabled: true, SampleRate: &rate})
+	require.EqualError(t, err, "sample rate must be between 0 and 1, got 1.5")
+}
+
+func TestExtractInject(t *testing.T) {
+	in := http.Header{}
+	in.Set(traceParentHeader, traceParent)
+	in.Set(traceStateHeader, traceState)
+
+	ctx := Extract(context.Background(), in)
+	sc := trace.SpanContextFromContext(ctx)
+	require.True(t, sc.IsValid())
+	require.True(t, sc.IsRemote())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+
+	out := http.Header{}
+	Inject(ctx, out)
+	require.Equal(t, traceParent, out.Get(traceParentHeader))
+	require.Equal(t, traceState, out.Get(traceStateHeader))
+}
+
+func TestTraceContext(t *testing.T) {
+	require.True(t, TraceContextFrom(context.Background()).IsZero())
+	require.Equal(t, context.Background(), WithTraceContext(context.Background(), TraceContext{}))
+
+	in := http.Header{}
+	in.Set(traceParentHeader, traceParent)
+	in.Set(traceStateHeader, traceState)
+
+	tc := TraceContextFrom(Extract(context.Background(), in))
+	require.Equal(t, TraceContext{TraceParent: traceParent, TraceState: traceState}, tc)
+
+	sc := trace.SpanContextFromContext(WithTraceContext(context.Background(), tc))
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID().String())
+	require.Equal(t, traceState, sc.TraceState().String())
+}
+
+func TestEndSpan(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "ok")
+	EndSpan(span, nil)
+	_, span = tracer.Start(context.Background(), "failed")
+	EndSpan(span, errors.New("foo"))
+
+	spans := sr.Ended()
+	require.Len(t, spans, 2)
+
+	require.Equal(t, "ok", spans[0].Name())
+	require.Equal(t, codes.Unset, spans[0].Status().Code)
+	require.Empty(t, spans[0].Events())
+
+	require.Equal(t, "failed", spans[1].Name())
+	require.Equal(t, codes.Error, spans[1].Status().Code)
+	require.Equal(t, "foo", spans[1].Status().Description)
+	require.Len(t, spans[1].Events(), 1)
+}